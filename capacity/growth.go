@@ -0,0 +1,64 @@
+package capacity
+
+import (
+	"time"
+)
+
+// minObservedSpan is the shortest history window considered meaningful for projections
+const minObservedSpan = time.Hour
+
+// maxProjectionDays caps how far ahead exhaustion dates are projected
+const maxProjectionDays = 36500
+
+// CalculateGrowth fits a least-squares line through used bytes over time and projects
+// when the series will run out of space. Returns nil with fewer than two samples or
+// when the samples span less than an hour.
+func CalculateGrowth(samples []Sample) *Growth {
+	if len(samples) < 2 {
+		return nil
+	}
+
+	first := samples[0]
+	last := samples[len(samples)-1]
+	span := last.Timestamp.Sub(first.Timestamp)
+	if span < minObservedSpan {
+		return nil
+	}
+
+	// Regress used bytes against elapsed days since the first sample
+	n := float64(len(samples))
+	sumX, sumY, sumXY, sumX2 := 0.0, 0.0, 0.0, 0.0
+	for _, s := range samples {
+		x := s.Timestamp.Sub(first.Timestamp).Hours() / 24
+		y := float64(s.UsedBytes)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumX2 += x * x
+	}
+
+	denominator := n*sumX2 - sumX*sumX
+	if denominator == 0 {
+		return nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+
+	growth := &Growth{
+		SampleCount:       len(samples),
+		FirstSample:       first.Timestamp,
+		LastSample:        last.Timestamp,
+		ObservedDays:      span.Hours() / 24,
+		GrowthBytesPerDay: slope,
+	}
+
+	// Only project exhaustion when usage is actually growing (and within a century,
+	// beyond which the projection is meaningless and overflows time.Duration)
+	if slope > 0 && last.AvailableBytes > 0 && float64(last.AvailableBytes)/slope < maxProjectionDays {
+		days := float64(last.AvailableBytes) / slope
+		fullDate := last.Timestamp.Add(time.Duration(days * 24 * float64(time.Hour)))
+		growth.DaysUntilFull = &days
+		growth.ProjectedFullDate = &fullDate
+	}
+
+	return growth
+}
@@ -0,0 +1,89 @@
+package capacity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalculateGrowth(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	const gib = int64(1 << 30)
+
+	tests := []struct {
+		name           string
+		samples        []Sample
+		wantNil        bool
+		wantPerDay     float64
+		wantDaysToFull float64 // 0 = no projection expected
+	}{
+		{
+			name:    "no samples",
+			samples: nil,
+			wantNil: true,
+		},
+		{
+			name: "single sample",
+			samples: []Sample{
+				{Timestamp: base, UsedBytes: 10 * gib, AvailableBytes: 90 * gib},
+			},
+			wantNil: true,
+		},
+		{
+			name: "span too short",
+			samples: []Sample{
+				{Timestamp: base, UsedBytes: 10 * gib, AvailableBytes: 90 * gib},
+				{Timestamp: base.Add(10 * time.Minute), UsedBytes: 11 * gib, AvailableBytes: 89 * gib},
+			},
+			wantNil: true,
+		},
+		{
+			name: "steady growth of 1 GiB per day",
+			samples: []Sample{
+				{Timestamp: base, UsedBytes: 10 * gib, AvailableBytes: 90 * gib},
+				{Timestamp: base.Add(24 * time.Hour), UsedBytes: 11 * gib, AvailableBytes: 89 * gib},
+				{Timestamp: base.Add(48 * time.Hour), UsedBytes: 12 * gib, AvailableBytes: 88 * gib},
+			},
+			wantPerDay:     float64(gib),
+			wantDaysToFull: 88,
+		},
+		{
+			name: "shrinking usage has no projection",
+			samples: []Sample{
+				{Timestamp: base, UsedBytes: 12 * gib, AvailableBytes: 88 * gib},
+				{Timestamp: base.Add(48 * time.Hour), UsedBytes: 10 * gib, AvailableBytes: 90 * gib},
+			},
+			wantPerDay: -float64(gib),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			growth := CalculateGrowth(tt.samples)
+			if tt.wantNil {
+				if growth != nil {
+					t.Errorf("CalculateGrowth() = %+v, want nil", growth)
+				}
+				return
+			}
+			if growth == nil {
+				t.Fatalf("CalculateGrowth() = nil, want growth")
+			}
+			if math.Abs(growth.GrowthBytesPerDay-tt.wantPerDay) > 1 {
+				t.Errorf("GrowthBytesPerDay = %v, want %v", growth.GrowthBytesPerDay, tt.wantPerDay)
+			}
+			if tt.wantDaysToFull == 0 {
+				if growth.DaysUntilFull != nil {
+					t.Errorf("DaysUntilFull = %v, want nil", *growth.DaysUntilFull)
+				}
+				return
+			}
+			if growth.DaysUntilFull == nil {
+				t.Fatalf("DaysUntilFull = nil, want %v", tt.wantDaysToFull)
+			}
+			if math.Abs(*growth.DaysUntilFull-tt.wantDaysToFull) > 0.01 {
+				t.Errorf("DaysUntilFull = %v, want %v", *growth.DaysUntilFull, tt.wantDaysToFull)
+			}
+		})
+	}
+}
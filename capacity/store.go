@@ -0,0 +1,142 @@
+package capacity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// HistoryStore provides thread-safe storage for usage samples keyed by pool or dataset name,
+// optionally persisted to a JSON file so history survives restarts
+type HistoryStore struct {
+	mu        sync.RWMutex
+	series    map[string][]Sample
+	path      string
+	retention time.Duration
+}
+
+// NewHistoryStore creates a new history store. If path is non-empty, existing
+// history is loaded from it.
+func NewHistoryStore(path string, retention time.Duration) (*HistoryStore, error) {
+	s := &HistoryStore{
+		series:    make(map[string][]Sample),
+		path:      path,
+		retention: retention,
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read capacity history: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.series); err != nil {
+		return nil, fmt.Errorf("failed to parse capacity history %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Add appends a sample to the named series and drops samples outside the retention window
+func (s *HistoryStore) Add(name string, sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series[name] = append(s.series[name], sample)
+	s.pruneLocked(name, sample.Timestamp)
+}
+
+// Series returns a copy of the samples for a name, oldest first
+func (s *HistoryStore) Series(name string) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	samples := s.series[name]
+	out := make([]Sample, len(samples))
+	copy(out, samples)
+	return out
+}
+
+// Names returns all tracked series names in alphabetical order
+func (s *HistoryStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the history to disk (no-op for in-memory stores)
+func (s *HistoryStore) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	data, err := json.Marshal(s.series)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal capacity history: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write capacity history: %w", err)
+	}
+
+	return nil
+}
+
+// Expire drops series whose newest sample is outside the retention window,
+// such as datasets that have since been deleted
+func (s *HistoryStore) Expire(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-s.retention)
+	for name, samples := range s.series {
+		if len(samples) == 0 || samples[len(samples)-1].Timestamp.Before(cutoff) {
+			delete(s.series, name)
+		}
+	}
+}
+
+// pruneLocked removes samples older than the retention window. Must be called with mu held.
+func (s *HistoryStore) pruneLocked(name string, now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-s.retention)
+	samples := s.series[name]
+	idx := 0
+	for idx < len(samples) && samples[idx].Timestamp.Before(cutoff) {
+		idx++
+	}
+	if idx > 0 {
+		s.series[name] = append([]Sample(nil), samples[idx:]...)
+	}
+}
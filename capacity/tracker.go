@@ -0,0 +1,219 @@
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Tracker periodically samples pool and dataset usage and keeps a local time
+// series so that growth rates can be computed across server restarts
+type Tracker struct {
+	client   *truenas.Client
+	store    *HistoryStore
+	datasets *HistoryStore
+	config   TrackerConfig
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewTracker creates a new capacity tracker, loading any persisted history
+func NewTracker(client *truenas.Client, config TrackerConfig) (*Tracker, error) {
+	store, err := NewHistoryStore(config.Path, config.Retention)
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := NewHistoryStore(config.DatasetPath, config.Retention)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Tracker{
+		client:   client,
+		store:    store,
+		datasets: datasets,
+		config:   config,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Start begins background sampling. Does nothing if sampling is disabled.
+func (t *Tracker) Start() {
+	if t.config.SampleInterval <= 0 {
+		return
+	}
+	go t.run()
+}
+
+// Shutdown stops background sampling
+func (t *Tracker) Shutdown() {
+	t.cancel()
+}
+
+// Enabled reports whether background sampling is configured
+func (t *Tracker) Enabled() bool {
+	return t.config.SampleInterval > 0
+}
+
+// SampleInterval returns the configured sampling interval
+func (t *Tracker) SampleInterval() time.Duration {
+	return t.config.SampleInterval
+}
+
+// run is the main sampling loop
+func (t *Tracker) run() {
+	// Take an initial sample so history starts accumulating immediately
+	if err := t.SampleNow(); err != nil {
+		slog.Warn("Capacity sampling failed", "error", err)
+	}
+
+	ticker := time.NewTicker(t.config.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.SampleNow(); err != nil {
+				slog.Warn("Capacity sampling failed", "error", err)
+			}
+		}
+	}
+}
+
+// SampleNow records the current usage of every pool and dataset and persists
+// the history
+func (t *Tracker) SampleNow() error {
+	if err := t.samplePools(); err != nil {
+		return err
+	}
+	return t.sampleDatasets()
+}
+
+func (t *Tracker) samplePools() error {
+	result, err := t.client.Call("pool.query")
+	if err != nil {
+		return fmt.Errorf("failed to query pools: %w", err)
+	}
+
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil {
+		return fmt.Errorf("failed to parse pools: %w", err)
+	}
+
+	now := time.Now()
+	for _, pool := range pools {
+		name, _ := pool["name"].(string)
+		used, usedOk := pool["allocated"].(float64)
+		free, freeOk := pool["free"].(float64)
+		if name == "" || !usedOk || !freeOk {
+			continue
+		}
+
+		t.store.Add(name, Sample{
+			Timestamp:      now,
+			UsedBytes:      int64(used),
+			AvailableBytes: int64(free),
+		})
+	}
+
+	return t.store.Save()
+}
+
+// sampleDatasets records the used and available bytes of every filesystem
+// dataset. Hidden system datasets (e.g. tank/.system) are skipped.
+func (t *Tracker) sampleDatasets() error {
+	result, err := t.client.Call("pool.dataset.query",
+		[]interface{}{[]interface{}{"type", "=", "FILESYSTEM"}},
+		map[string]interface{}{
+			"extra": map[string]interface{}{
+				"flat":              true,
+				"retrieve_children": false,
+				"properties":        []string{"used", "available", "quota"},
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query datasets: %w", err)
+	}
+
+	var datasets []map[string]interface{}
+	if err := json.Unmarshal(result, &datasets); err != nil {
+		return fmt.Errorf("failed to parse datasets: %w", err)
+	}
+
+	now := time.Now()
+	for _, dataset := range datasets {
+		name, _ := dataset["name"].(string)
+		if name == "" || strings.Contains(name, "/.") {
+			continue
+		}
+		used, usedOk := parsedProperty(dataset, "used")
+		available, availableOk := parsedProperty(dataset, "available")
+		if !usedOk || !availableOk {
+			continue
+		}
+		quota, _ := parsedProperty(dataset, "quota")
+
+		t.datasets.Add(name, Sample{
+			Timestamp:      now,
+			UsedBytes:      used,
+			AvailableBytes: available,
+			QuotaBytes:     quota,
+		})
+	}
+	t.datasets.Expire(now)
+
+	return t.datasets.Save()
+}
+
+// parsedProperty reads the parsed value of a dataset property
+func parsedProperty(dataset map[string]interface{}, name string) (int64, bool) {
+	prop, ok := dataset[name].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	value, ok := prop["parsed"].(float64)
+	return int64(value), ok
+}
+
+// History returns the recorded samples for a pool, oldest first
+func (t *Tracker) History(pool string) []Sample {
+	return t.store.Series(pool)
+}
+
+// Pools returns the names of all pools with recorded history
+func (t *Tracker) Pools() []string {
+	return t.store.Names()
+}
+
+// Growth computes the growth trend for a pool, or nil if there is not enough history
+func (t *Tracker) Growth(pool string) *Growth {
+	return CalculateGrowth(t.store.Series(pool))
+}
+
+// DatasetHistory returns the recorded samples for a dataset, oldest first
+func (t *Tracker) DatasetHistory(dataset string) []Sample {
+	return t.datasets.Series(dataset)
+}
+
+// Datasets returns the names of all datasets with recorded history
+func (t *Tracker) Datasets() []string {
+	return t.datasets.Names()
+}
+
+// DatasetGrowth computes the growth trend for a dataset, or nil if there is not
+// enough history. Projections run to the dataset's quota when it has one,
+// otherwise to its pool's free space.
+func (t *Tracker) DatasetGrowth(dataset string) *Growth {
+	return CalculateGrowth(t.datasets.Series(dataset))
+}
@@ -0,0 +1,76 @@
+package capacity
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func datasetRecord(name string, used, available, quota float64) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      name,
+		"type":      "FILESYSTEM",
+		"used":      map[string]interface{}{"parsed": used},
+		"available": map[string]interface{}{"parsed": available},
+		"quota":     map[string]interface{}{"parsed": quota},
+	}
+}
+
+func TestTrackerSamplesDatasets(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetRecords("pool.query", []map[string]interface{}{
+		{"name": "tank", "allocated": float64(40 << 30), "free": float64(60 << 30)},
+	})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		datasetRecord("tank", 40<<30, 60<<30, 0),
+		datasetRecord("tank/media", 30<<30, 20<<30, 50<<30),
+		datasetRecord("tank/.system", 1<<30, 60<<30, 0),
+		{"name": "tank/vm-disk", "type": "VOLUME"},
+	})
+
+	dir := t.TempDir()
+	config := TrackerConfig{
+		SampleInterval: time.Hour,
+		Retention:      24 * time.Hour,
+		Path:           filepath.Join(dir, "capacity_history.json"),
+		DatasetPath:    filepath.Join(dir, "dataset_history.json"),
+	}
+	tracker, err := NewTracker(server.Client(t), config)
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	if err := tracker.SampleNow(); err != nil {
+		t.Fatalf("SampleNow failed: %v", err)
+	}
+
+	if got := tracker.Datasets(); len(got) != 2 || got[0] != "tank" || got[1] != "tank/media" {
+		t.Errorf("Datasets() = %v, want [tank tank/media]", got)
+	}
+	media := tracker.DatasetHistory("tank/media")
+	if len(media) != 1 || media[0].QuotaBytes != 50<<30 || media[0].AvailableBytes != 20<<30 {
+		t.Errorf("tank/media history = %+v", media)
+	}
+
+	// History is persisted separately from pool history
+	reloaded, err := NewTracker(server.Client(t), config)
+	if err != nil {
+		t.Fatalf("reloading tracker failed: %v", err)
+	}
+	if len(reloaded.DatasetHistory("tank/media")) != 1 || len(reloaded.History("tank")) != 1 {
+		t.Error("reloaded tracker lost recorded history")
+	}
+}
+
+func TestHistoryStoreExpire(t *testing.T) {
+	store, _ := NewHistoryStore("", 24*time.Hour)
+	now := time.Now()
+	store.Add("tank/old", Sample{Timestamp: now.Add(-48 * time.Hour)})
+	store.Add("tank/new", Sample{Timestamp: now})
+
+	store.Expire(now)
+	if names := store.Names(); len(names) != 1 || names[0] != "tank/new" {
+		t.Errorf("Names() after Expire = %v, want [tank/new]", names)
+	}
+}
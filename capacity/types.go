@@ -0,0 +1,38 @@
+package capacity
+
+import (
+	"time"
+)
+
+// Sample is a single point-in-time usage measurement for a pool or dataset.
+// A dataset's available bytes already account for its quota.
+type Sample struct {
+	Timestamp      time.Time `json:"timestamp"`
+	UsedBytes      int64     `json:"used_bytes"`
+	AvailableBytes int64     `json:"available_bytes"`
+	QuotaBytes     int64     `json:"quota_bytes,omitempty"` // Dataset quota (0 = none)
+}
+
+// TotalBytes returns the usable capacity at the time of the sample
+func (s Sample) TotalBytes() int64 {
+	return s.UsedBytes + s.AvailableBytes
+}
+
+// TrackerConfig configures the background sampling behavior
+type TrackerConfig struct {
+	SampleInterval time.Duration // How often to sample usage (0 = disabled)
+	Retention      time.Duration // How long samples are kept
+	Path           string        // JSON file used to persist pool history ("" = in-memory only)
+	DatasetPath    string        // JSON file used to persist dataset history ("" = in-memory only)
+}
+
+// Growth summarizes the usage trend of a series of samples
+type Growth struct {
+	SampleCount       int        `json:"sample_count"`
+	FirstSample       time.Time  `json:"first_sample"`
+	LastSample        time.Time  `json:"last_sample"`
+	ObservedDays      float64    `json:"observed_days"`
+	GrowthBytesPerDay float64    `json:"growth_bytes_per_day"`
+	DaysUntilFull     *float64   `json:"days_until_full,omitempty"`
+	ProjectedFullDate *time.Time `json:"projected_full_date,omitempty"`
+}
@@ -0,0 +1,190 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// Entry is the catalog.get_app_details result for one app, keyed by the
+// TrueNAS version it was fetched from since app schemas change between releases
+type Entry struct {
+	App           string          `json:"app"`
+	Train         string          `json:"train"`
+	SystemVersion string          `json:"system_version"`
+	AppVersion    string          `json:"app_version,omitempty"`
+	FetchedAt     time.Time       `json:"fetched_at"`
+	Details       json.RawMessage `json:"details"`
+}
+
+// Cache stores catalog app details for a TTL, optionally persisted to a JSON
+// file so schemas remain available offline and across restarts
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]*Entry
+	path    string
+	ttl     time.Duration
+	limit   int
+}
+
+// NewCache creates a cache whose entries are fresh for ttl (0 disables
+// caching), keeping at most limit entries (0 = unlimited). If path is
+// non-empty, existing entries are loaded from it.
+func NewCache(path string, ttl time.Duration, limit int) (*Cache, error) {
+	c := &Cache{entries: make(map[string]*Entry), path: path, ttl: ttl, limit: limit}
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read catalog cache: %w", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog cache %s: %w", path, err)
+	}
+	for _, e := range entries {
+		c.entries[key(e.SystemVersion, e.Train, e.App)] = e
+	}
+
+	return c, nil
+}
+
+func key(systemVersion, train, app string) string {
+	return systemVersion + "/" + train + "/" + app
+}
+
+// Enabled reports whether lookups may be served from the cache
+func (c *Cache) Enabled() bool {
+	return c.ttl > 0
+}
+
+// TTL returns how long entries stay fresh
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Fresh reports whether the entry is younger than the TTL at now
+func (c *Cache) Fresh(e *Entry, now time.Time) bool {
+	return now.Sub(e.FetchedAt) < c.ttl
+}
+
+// Get returns the entry for app on train fetched from systemVersion, or nil
+func (c *Cache) Get(systemVersion, train, app string) *Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.entries[key(systemVersion, train, app)]
+}
+
+// Newest returns the most recently fetched entry for app on train from any
+// TrueNAS version, or nil. It is the fallback when TrueNAS is unreachable.
+func (c *Cache) Newest(train, app string) *Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var newest *Entry
+	for _, e := range c.entries {
+		if e.Train == train && e.App == app && (newest == nil || e.FetchedAt.After(newest.FetchedAt)) {
+			newest = e
+		}
+	}
+	return newest
+}
+
+// Put stores an entry, replacing any for the same version, train, and app,
+// and drops the oldest entries beyond the limit
+func (c *Cache) Put(e *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key(e.SystemVersion, e.Train, e.App)] = e
+	if c.limit > 0 && len(c.entries) > c.limit {
+		entries := c.sortedLocked()
+		for _, old := range entries[c.limit:] {
+			delete(c.entries, key(old.SystemVersion, old.Train, old.App))
+		}
+	}
+	return c.saveLocked()
+}
+
+// Invalidate removes the entries for app on train from every TrueNAS
+// version. Empty app or train match all. Returns the number removed.
+func (c *Cache) Invalidate(train, app string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for k, e := range c.entries {
+		if (train == "" || e.Train == train) && (app == "" || e.App == app) {
+			delete(c.entries, k)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.saveLocked()
+}
+
+// List returns all entries, newest first, without their details
+func (c *Cache) List() []Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := c.sortedLocked()
+	list := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		summary := *e
+		summary.Details = nil
+		list = append(list, summary)
+	}
+	return list
+}
+
+// sortedLocked returns the entries newest first. Must be called with mu held.
+func (c *Cache) sortedLocked() []*Entry {
+	entries := make([]*Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].FetchedAt.After(entries[j].FetchedAt)
+	})
+	return entries
+}
+
+// saveLocked writes the cache to disk (no-op for in-memory caches). Must be
+// called with mu held.
+func (c *Cache) saveLocked() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(c.sortedLocked())
+	if err != nil {
+		return fmt.Errorf("failed to marshal catalog cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create catalog cache directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(c.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write catalog cache: %w", err)
+	}
+
+	return nil
+}
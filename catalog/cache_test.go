@@ -0,0 +1,54 @@
+package catalog
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCachePersistsAndEvicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog_cache.json")
+	cache, err := NewCache(path, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+
+	now := time.Now()
+	entries := []*Entry{
+		{App: "plex", Train: "stable", SystemVersion: "25.04.0", FetchedAt: now.Add(-3 * time.Hour), Details: json.RawMessage(`{"latest_version":"1.0.0"}`)},
+		{App: "plex", Train: "stable", SystemVersion: "25.04.1", FetchedAt: now.Add(-2 * time.Hour), Details: json.RawMessage(`{"latest_version":"1.1.0"}`)},
+		{App: "jellyfin", Train: "stable", SystemVersion: "25.04.1", FetchedAt: now.Add(-time.Minute), Details: json.RawMessage(`{"latest_version":"2.0.0"}`)},
+	}
+	for _, e := range entries {
+		if err := cache.Put(e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	reloaded, err := NewCache(path, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if e := reloaded.Get("25.04.0", "stable", "plex"); e != nil {
+		t.Errorf("oldest entry survived the limit: %+v", e)
+	}
+	if e := reloaded.Newest("stable", "plex"); e == nil || e.SystemVersion != "25.04.1" {
+		t.Errorf("Newest(plex) = %+v, want the 25.04.1 entry", e)
+	}
+	if e := reloaded.Get("25.04.1", "stable", "jellyfin"); e == nil || !reloaded.Fresh(e, now) {
+		t.Errorf("jellyfin entry = %+v, want fresh", e)
+	}
+	if e := reloaded.Get("25.04.1", "stable", "plex"); reloaded.Fresh(e, now) {
+		t.Errorf("2h old entry reported fresh with a 1h TTL")
+	}
+
+	removed, err := reloaded.Invalidate("", "plex")
+	if err != nil || removed != 1 {
+		t.Fatalf("Invalidate(plex) = %d, %v; want 1", removed, err)
+	}
+	list := reloaded.List()
+	if len(list) != 1 || list[0].App != "jellyfin" || list[0].Details != nil {
+		t.Errorf("List() = %+v, want jellyfin without details", list)
+	}
+}
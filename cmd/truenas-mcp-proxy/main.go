@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/truenas/truenas-mcp/logging"
+	"github.com/truenas/truenas-mcp/proxy"
+)
+
+const (
+	Version = "0.2.0"
+)
+
+func main() {
+	cfg, err := proxy.LoadConfig()
+	if err != nil {
+		if err.Error() == "version requested" {
+			fmt.Printf("truenas-mcp-proxy version %s\n", Version)
+			os.Exit(0)
+		}
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+
+	logs, err := logging.Setup(cfg.Log)
+	if err != nil {
+		logging.Fatal("Invalid logging configuration", "error", err)
+	}
+	defer logs.Close()
+
+	p, err := proxy.NewProxy(cfg)
+	if err != nil {
+		logging.Fatal("Failed to create proxy", "error", err)
+	}
+	if err := p.Run(); err != nil {
+		logging.Fatal("Proxy error", "error", err)
+	}
+}
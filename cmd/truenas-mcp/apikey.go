@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// API key sources: the key can be read from a file, printed by a command
+// (such as a password manager's CLI), or looked up in the OS keyring, so it
+// does not appear in process listings or MCP client configuration
+
+// keyringService is the keyring service name API keys are stored under
+const keyringService = "truenas-mcp"
+
+// secretCommandTimeout bounds an API key command or keyring lookup, which may
+// prompt to unlock a keyring
+const secretCommandTimeout = 30 * time.Second
+
+// apiKeySource is where an API key is read from; at most one field is set
+type apiKeySource struct {
+	File    string
+	Command string
+	Keyring string // account name under keyringService
+}
+
+// set reports whether the key comes from a source rather than being given
+func (s apiKeySource) set() bool {
+	return s.File != "" || s.Command != "" || s.Keyring != ""
+}
+
+// resolve reads the API key from the source
+func (s apiKeySource) resolve() (string, error) {
+	given := 0
+	for _, value := range []string{s.File, s.Command, s.Keyring} {
+		if value != "" {
+			given++
+		}
+	}
+	if given > 1 {
+		return "", fmt.Errorf("give only one of an API key file, command, or keyring entry")
+	}
+
+	var key string
+	switch {
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key file: %w", err)
+		}
+		key = string(data)
+	case s.Command != "":
+		shell, args := shellCommand(s.Command)
+		out, err := runSecretCommand(shell, args...)
+		if err != nil {
+			return "", fmt.Errorf("API key command failed: %w", err)
+		}
+		key = out
+	case s.Keyring != "":
+		name, args, err := keyringLookup(runtime.GOOS, s.Keyring)
+		if err != nil {
+			return "", err
+		}
+		out, err := runSecretCommand(name, args...)
+		if err != nil {
+			return "", fmt.Errorf("keyring lookup of %s/%s failed: %w", keyringService, s.Keyring, err)
+		}
+		key = out
+	}
+	key = strings.TrimSpace(key)
+	if key == "" && s.set() {
+		return "", fmt.Errorf("the API key source is empty")
+	}
+	return key, nil
+}
+
+// shellCommand returns how the platform shell runs command
+func shellCommand(command string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", command}
+	}
+	return "sh", []string{"-c", command}
+}
+
+// keyringLookup returns the command that prints the password stored for
+// account under keyringService: the login keychain on macOS and the Secret
+// Service (GNOME Keyring, KWallet) on Linux and BSD
+func keyringLookup(goos, account string) (string, []string, error) {
+	switch goos {
+	case "darwin":
+		return "security", []string{"find-generic-password", "-s", keyringService, "-a", account, "-w"}, nil
+	case "windows":
+		return "", nil, fmt.Errorf("the Windows credential manager is not supported; use --api-key-command with a tool that prints the key")
+	}
+	return "secret-tool", []string{"lookup", "service", keyringService, "account", account}, nil
+}
+
+// runSecretCommand runs a command and returns its output. Standard error is
+// passed through, so the command can prompt; its output is never logged.
+func runSecretCommand(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("timed out after %s", secretCommandTimeout)
+		}
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// resolveSystemKeys reads the API keys of further systems from their sources
+func resolveSystemKeys(systems []systemConfig) error {
+	for i := range systems {
+		source := systems[i].apiKeySource()
+		if !source.set() {
+			continue
+		}
+		if systems[i].APIKey != "" {
+			return fmt.Errorf("system %s: give either api_key or an API key source, not both", systems[i].Name)
+		}
+		key, err := source.resolve()
+		if err != nil {
+			return fmt.Errorf("system %s: %w", systems[i].Name, err)
+		}
+		systems[i].APIKey = key
+	}
+	return nil
+}
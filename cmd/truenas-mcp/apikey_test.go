@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeySource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("1-from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := apiKeySource{File: path}.resolve()
+	if err != nil || key != "1-from-file" {
+		t.Errorf("file source = %q, %v", key, err)
+	}
+
+	if runtime.GOOS != "windows" {
+		key, err = apiKeySource{Command: "echo 1-from-command"}.resolve()
+		if err != nil || key != "1-from-command" {
+			t.Errorf("command source = %q, %v", key, err)
+		}
+		if _, err := (apiKeySource{Command: "exit 3"}).resolve(); err == nil {
+			t.Error("failing command succeeded")
+		}
+		if _, err := (apiKeySource{Command: "true"}).resolve(); err == nil || !strings.Contains(err.Error(), "empty") {
+			t.Errorf("empty command output error = %v", err)
+		}
+	}
+
+	if _, err := (apiKeySource{File: path, Keyring: "nas1"}).resolve(); err == nil {
+		t.Error("two sources were accepted")
+	}
+	if _, err := (apiKeySource{File: filepath.Join(t.TempDir(), "missing")}).resolve(); err == nil {
+		t.Error("missing key file was accepted")
+	}
+}
+
+func TestKeyringLookup(t *testing.T) {
+	name, args, err := keyringLookup("darwin", "nas1")
+	if err != nil || name != "security" || strings.Join(args, " ") != "find-generic-password -s truenas-mcp -a nas1 -w" {
+		t.Errorf("darwin lookup = %s %v, %v", name, args, err)
+	}
+	name, args, err = keyringLookup("linux", "nas1")
+	if err != nil || name != "secret-tool" || strings.Join(args, " ") != "lookup service truenas-mcp account nas1" {
+		t.Errorf("linux lookup = %s %v, %v", name, args, err)
+	}
+	if _, _, err := keyringLookup("windows", "nas1"); err == nil {
+		t.Error("windows lookup should point to --api-key-command")
+	}
+}
+
+func TestResolveSystemKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nas2-key")
+	if err := os.WriteFile(path, []byte("2-from-file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	systems := []systemConfig{
+		{Name: "nas2", URL: "nas2.local", APIKeyFile: path},
+		{Name: "nas3", URL: "nas3.local", APIKey: "3-inline"},
+	}
+	if err := resolveSystemKeys(systems); err != nil {
+		t.Fatalf("resolveSystemKeys failed: %v", err)
+	}
+	if systems[0].APIKey != "2-from-file" || systems[1].APIKey != "3-inline" {
+		t.Errorf("resolved keys = %q, %q", systems[0].APIKey, systems[1].APIKey)
+	}
+
+	both := []systemConfig{{Name: "nas2", URL: "nas2.local", APIKey: "2-inline", APIKeyFile: path}}
+	if err := resolveSystemKeys(both); err == nil {
+		t.Error("api_key and api_key_file together were accepted")
+	}
+}
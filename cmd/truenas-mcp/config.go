@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Server settings in the config file
+//
+// Besides the connection written by setup, the config file can hold any
+// command-line flag under its name, with dashes or underscores (log_level,
+// enable_tools, task_poll_interval, ...). Flags and environment variables
+// take precedence over it. Files ending in .toml are read as TOML, others as
+// JSON.
+
+// flagEnvVars are the environment variables that set flags; the config file
+// does not override them
+var flagEnvVars = map[string]string{
+	"truenas-url":     "TRUENAS_URL",
+	"api-key":         "TRUENAS_API_KEY",
+	"api-key-file":    "TRUENAS_API_KEY_FILE",
+	"api-key-command": "TRUENAS_API_KEY_COMMAND",
+	"api-key-keyring": "TRUENAS_API_KEY_KEYRING",
+	"username":        "TRUENAS_USERNAME",
+	"password":        "TRUENAS_PASSWORD",
+	"auth-token":      "TRUENAS_AUTH_TOKEN",
+	"data-dir":        "TRUENAS_MCP_DATA_DIR",
+	"read-only":       "TRUENAS_MCP_READ_ONLY",
+	"http-auth-token": "TRUENAS_MCP_HTTP_AUTH_TOKEN",
+}
+
+// unsettableFlags cannot be set from the config file
+var unsettableFlags = map[string]bool{
+	"config":     true,
+	"version":    true,
+	"dump-tools": true,
+}
+
+// isTOMLConfig reports whether a config file is read as TOML
+func isTOMLConfig(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// fileConfigKeys returns the config file keys held in fileConfig's fields
+func fileConfigKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(fileConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// UnmarshalJSON reads the connection fields and keeps every other key in
+// Settings
+func (c *fileConfig) UnmarshalJSON(data []byte) error {
+	type plain fileConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	fields := fileConfigKeys()
+	c.Settings = nil
+	for key, value := range all {
+		if fields[key] {
+			continue
+		}
+		if c.Settings == nil {
+			c.Settings = map[string]interface{}{}
+		}
+		c.Settings[key] = value
+	}
+	return nil
+}
+
+// MarshalJSON writes the connection fields and Settings as one object, so
+// setup keeps settings it does not manage
+func (c fileConfig) MarshalJSON() ([]byte, error) {
+	type plain fileConfig
+	data, err := json.Marshal(plain(c))
+	if err != nil || len(c.Settings) == 0 {
+		return data, err
+	}
+	merged := map[string]interface{}{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range c.Settings {
+		merged[key] = value
+	}
+	return json.Marshal(merged)
+}
+
+// applyConfigSettings sets flags from the config file's settings, skipping
+// flags given on the command line or by their environment variable
+func applyConfigSettings(fs *flag.FlagSet, settings map[string]interface{}) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil || unsettableFlags[name] {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if env := flagEnvVars[name]; given[name] || env != "" && os.Getenv(env) != "" {
+			continue
+		}
+		value, err := settingValue(settings[key])
+		if err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// settingValue formats a config file value as a flag value. Lists become
+// comma-separated values and tables comma-separated name=value pairs, as in
+// enable_tools = ["query_*", "get_*"] or [tool_timeouts] query = "45s".
+func settingValue(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			s, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pairs := make([]string, 0, len(value))
+		for _, name := range names {
+			s, err := settingValue(value[name])
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, name+"="+s)
+		}
+		return strings.Join(pairs, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// loadCAFile reads the PEM certificates that TrueNAS TLS certificates are
+// verified against
+func loadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTOMLConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	err := os.WriteFile(path, []byte(`
+truenas_url = "nas.local"
+api_key = "1-abc"
+log_level = "debug"
+enable_tools = ["query_*", "get_*"]
+max_response_bytes = 65536
+
+[tool_timeouts]
+query = "45s"
+
+[[systems]]
+name = "backup"
+url = "backup.local"
+api_key = "2-def"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	if cfg.TrueNASURL != "nas.local" || cfg.APIKey != "1-abc" || len(cfg.Systems) != 1 || cfg.Systems[0].URL != "backup.local" {
+		t.Errorf("connection = %+v", cfg)
+	}
+	if len(cfg.Settings) != 4 || cfg.Settings["log_level"] != "debug" {
+		t.Errorf("settings = %v", cfg.Settings)
+	}
+
+	if err := os.WriteFile(path, []byte(`truenas_url = nas.local`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigFile(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("invalid TOML error = %v", err)
+	}
+}
+
+func TestApplyConfigSettings(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := fs.String("log-level", "info", "")
+	format := fs.String("log-format", "text", "")
+	enable := fs.String("enable-tools", "", "")
+	timeouts := fs.String("tool-timeouts", "", "")
+	poll := fs.Duration("task-poll-interval", 5*time.Second, "")
+	maxBytes := fs.Int("max-response-bytes", 0, "")
+	dataDir := fs.String("data-dir", "", "")
+	fs.Bool("version", false, "")
+	if err := fs.Parse([]string{"--log-format", "json"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TRUENAS_MCP_DATA_DIR", "/from/env")
+
+	err := applyConfigSettings(fs, map[string]interface{}{
+		"log_level":          "debug",
+		"log-format":         "text",
+		"enable_tools":       []interface{}{"query_*", "get_*"},
+		"tool_timeouts":      map[string]interface{}{"query": "45s", "job": "10m"},
+		"task_poll_interval": "2s",
+		"max_response_bytes": float64(65536),
+		"data_dir":           "/from/file",
+	})
+	if err != nil {
+		t.Fatalf("applyConfigSettings failed: %v", err)
+	}
+	if *level != "debug" || *enable != "query_*,get_*" || *timeouts != "job=10m,query=45s" || *poll != 2*time.Second || *maxBytes != 65536 {
+		t.Errorf("settings not applied: level=%s enable=%s timeouts=%s poll=%s max=%d", *level, *enable, *timeouts, *poll, *maxBytes)
+	}
+	// Flags and environment variables win over the file
+	if *format != "json" || *dataDir != "" {
+		t.Errorf("file overrode a flag or environment variable: format=%s data-dir=%s", *format, *dataDir)
+	}
+
+	for _, settings := range []map[string]interface{}{
+		{"no_such_flag": true},
+		{"version": true},
+		{"task_poll_interval": "often"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Duration("task-poll-interval", 5*time.Second, "")
+		fs.Bool("version", false, "")
+		if err := applyConfigSettings(fs, settings); err == nil {
+			t.Errorf("applyConfigSettings(%v) succeeded, want an error", settings)
+		}
+	}
+}
+
+func TestSetupKeepsSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := &fileConfig{TrueNASURL: "nas.local", APIKey: "1-abc", Settings: map[string]interface{}{"log_level": "warn"}}
+	if err := saveConfigFile(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.APIKey != "1-abc" || loaded.Settings["log_level"] != "warn" {
+		t.Errorf("round-tripped config = %+v", loaded)
+	}
+
+	if err := runSetup([]string{"--non-interactive", "--config", filepath.Join(t.TempDir(), "config.toml")}, strings.NewReader(""), &strings.Builder{}); err == nil {
+		t.Error("setup wrote a TOML config file")
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+const (
+	// sessionHeader carries the session ID assigned at initialize
+	sessionHeader = "Mcp-Session-Id"
+
+	maxHTTPBodyBytes  = 10 << 20
+	sseKeepAlive      = 30 * time.Second
+	sseStreamBuffer   = 64
+	sessionReapPeriod = time.Minute
+)
+
+// HTTPConfig configures the Streamable HTTP transport
+type HTTPConfig struct {
+	Addr           string        // Listen address (e.g., "127.0.0.1:8080")
+	Path           string        // MCP endpoint path (default "/mcp")
+	AllowedOrigins []string      // Browser origins allowed to connect (requests without Origin are always allowed)
+	SessionTimeout time.Duration // Idle time after which a session is dropped (0 = never)
+	AuthToken      string        // Bearer token clients must send ("" = no authentication; loopback only)
+}
+
+// checkHTTPListen refuses to serve on an address reachable from other hosts
+// without a token, since the endpoint has full use of the TrueNAS API key
+func checkHTTPListen(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("listening on %s requires --http-auth-token; without one only loopback addresses (such as 127.0.0.1) are allowed", addr)
+}
+
+// HTTPHandler serves MCP over the Streamable HTTP transport. Each client
+// gets its own session (and log level) from initialize, identified by the
+// Mcp-Session-Id header. Responses are returned as JSON; server
+// notifications are delivered on the session's GET event stream.
+type HTTPHandler struct {
+	registry mcp.ToolRegistry
+	config   HTTPConfig
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is a Session plus its HTTP connection state
+type httpSession struct {
+	*Session
+
+	mu       sync.Mutex
+	lastSeen time.Time
+	stream   chan []byte // Non-nil while a GET event stream is open
+}
+
+func NewHTTPHandler(registry mcp.ToolRegistry, config HTTPConfig) *HTTPHandler {
+	if config.Path == "" {
+		config.Path = "/mcp"
+	}
+	return &HTTPHandler{
+		registry: registry,
+		config:   config,
+		sessions: make(map[string]*httpSession),
+	}
+}
+
+// Run serves until SIGINT or SIGTERM, then shuts down gracefully
+func (h *HTTPHandler) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle(h.config.Path, h)
+	server := &http.Server{
+		Addr:              h.config.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Request contexts end with ctx so open event streams close on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go h.reapSessions(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Serving MCP over HTTP", "addr", h.config.Addr, "path", h.config.Path)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("HTTP server error: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("HTTP server shutdown: %w", err)
+	}
+	return nil
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("HTTP request", "method", r.Method, "path", r.URL.Path, "session", r.Header.Get(sessionHeader))
+
+	// Browsers always send Origin; rejecting unknown ones prevents DNS rebinding
+	if origin := r.Header.Get("Origin"); origin != "" && !h.originAllowed(origin) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="truenas-mcp"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleStream(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized reports whether the request carries the configured bearer token
+func (h *HTTPHandler) authorized(r *http.Request) bool {
+	if h.config.AuthToken == "" {
+		return true
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(h.config.AuthToken)) == 1
+}
+
+func (h *HTTPHandler) originAllowed(origin string) bool {
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePost handles one JSON-RPC message or a batch of them
+func (h *HTTPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodyBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read request body: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	var raw []json.RawMessage
+	if batch {
+		err = json.Unmarshal(body, &raw)
+	} else {
+		raw = []json.RawMessage{body}
+	}
+	if err != nil || len(raw) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse(nil, -32700, "Parse error: invalid JSON-RPC batch"))
+		return
+	}
+
+	reqs := make([]*mcp.Request, 0, len(raw))
+	for _, msg := range raw {
+		var req mcp.Request
+		if err := json.Unmarshal(msg, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse(nil, -32700, fmt.Sprintf("Parse error: %v", err)))
+			return
+		}
+		reqs = append(reqs, &req)
+	}
+
+	var session *httpSession
+	if len(reqs) == 1 && reqs[0].Method == "initialize" {
+		session = h.newSession()
+		w.Header().Set(sessionHeader, session.ID)
+	} else {
+		var status int
+		if session, status = h.lookupSession(r); session == nil {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+
+	var responses []*mcp.Response
+	for _, req := range reqs {
+		// Responses to server requests carry no method; the server sends none
+		if req.Method == "" {
+			continue
+		}
+		slog.Debug("Handling method", "method", req.Method, "id", req.ID, "session", session.ID)
+		if req.Method == "initialize" && len(reqs) > 1 {
+			responses = append(responses, errorResponse(req.ID, -32600, "initialize must not be part of a batch"))
+			continue
+		}
+		if resp := session.handleRequest(r.Context(), req); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+
+	switch {
+	case len(responses) == 0:
+		w.WriteHeader(http.StatusAccepted)
+	case batch:
+		writeJSON(w, http.StatusOK, responses)
+	default:
+		writeJSON(w, http.StatusOK, responses[0])
+	}
+}
+
+// handleStream opens a server-sent event stream for the session's notifications
+func (h *HTTPHandler) handleStream(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	session, status := h.lookupSession(r)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	stream := make(chan []byte, sseStreamBuffer)
+	session.mu.Lock()
+	if session.stream != nil {
+		session.mu.Unlock()
+		http.Error(w, "An event stream is already open for this session", http.StatusConflict)
+		return
+	}
+	session.stream = stream
+	session.mu.Unlock()
+
+	defer func() {
+		session.mu.Lock()
+		if session.stream == stream {
+			session.stream = nil
+		}
+		session.lastSeen = time.Now()
+		session.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, open := <-stream:
+			if !open {
+				// Session was deleted
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			// Comment lines keep proxies from timing out an idle stream
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleDelete ends a session at the client's request
+func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, status := h.lookupSession(r)
+	if session == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	h.removeSession(session.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HTTPHandler) newSession() *httpSession {
+	session := &httpSession{lastSeen: time.Now()}
+	session.Session = NewSession(uuid.New().String(), h.registry, httpProtocolVersion, session.send)
+
+	h.mu.Lock()
+	h.sessions[session.ID] = session
+	h.mu.Unlock()
+
+	slog.Debug("Created HTTP session", "session", session.ID)
+	return session
+}
+
+// lookupSession returns the session named by the request header, or nil
+// and the HTTP status to reply with
+func (h *HTTPHandler) lookupSession(r *http.Request) (*httpSession, int) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	h.mu.Lock()
+	session, ok := h.sessions[id]
+	h.mu.Unlock()
+	if !ok {
+		// Tells the client to start over with a new initialize
+		return nil, http.StatusNotFound
+	}
+
+	session.mu.Lock()
+	session.lastSeen = time.Now()
+	session.mu.Unlock()
+	return session, http.StatusOK
+}
+
+func (h *HTTPHandler) removeSession(id string) {
+	h.mu.Lock()
+	session, ok := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	if session.stream != nil {
+		close(session.stream)
+		session.stream = nil
+	}
+	session.mu.Unlock()
+	session.cancelAll()
+	h.registry.EndSession(id)
+
+	slog.Debug("Removed HTTP session", "session", id)
+}
+
+// reapSessions drops sessions that have been idle longer than the timeout.
+// Sessions with an open event stream are never idle.
+func (h *HTTPHandler) reapSessions(ctx context.Context) {
+	if h.config.SessionTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(sessionReapPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.expireSessions(now)
+		}
+	}
+}
+
+// expireSessions removes sessions idle since before now minus the timeout
+func (h *HTTPHandler) expireSessions(now time.Time) {
+	h.mu.Lock()
+	var expired []string
+	for id, session := range h.sessions {
+		session.mu.Lock()
+		if session.stream == nil && now.Sub(session.lastSeen) > h.config.SessionTimeout {
+			expired = append(expired, id)
+		}
+		session.mu.Unlock()
+	}
+	h.mu.Unlock()
+
+	for _, id := range expired {
+		slog.Info("Expiring idle MCP session", "session", id)
+		h.removeSession(id)
+	}
+}
+
+// notifyEvent forwards a watched middleware event to every session
+func (h *HTTPHandler) notifyEvent(ev events.Event) {
+	h.mu.Lock()
+	sessions := make([]*httpSession, 0, len(h.sessions))
+	for _, session := range h.sessions {
+		sessions = append(sessions, session)
+	}
+	h.mu.Unlock()
+
+	for _, session := range sessions {
+		session.notifyEvent(ev)
+	}
+}
+
+// send queues a server-initiated message on the session's event stream.
+// Messages are dropped while no stream is open.
+func (s *httpSession) send(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == nil {
+		return nil
+	}
+	select {
+	case s.stream <- data:
+		return nil
+	default:
+		return fmt.Errorf("event stream for session %s is full", s.ID)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
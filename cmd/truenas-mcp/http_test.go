@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+// fakeRegistry serves a single echo tool
+type fakeRegistry struct{}
+
+func (fakeRegistry) ListTools() []mcp.Tool {
+	return []mcp.Tool{{Name: "echo", InputSchema: map[string]interface{}{"type": "object"}}}
+}
+
+func (fakeRegistry) CallTool(name string, args map[string]interface{}) (string, error) {
+	return fakeRegistry{}.CallToolWithCorrelationID("", name, args)
+}
+
+func (fakeRegistry) CallToolWithCorrelationID(correlationID, name string, args map[string]interface{}) (string, error) {
+	if name != "echo" {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	return fmt.Sprintf("%v", args["text"]), nil
+}
+
+func (fakeRegistry) CallToolInSession(ctx context.Context, sessionID, correlationID, name string, args map[string]interface{}) (string, error) {
+	if name == "wait" {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return fakeRegistry{}.CallToolWithCorrelationID(correlationID, name, args)
+}
+
+func (fakeRegistry) EndSession(sessionID string) {}
+
+func (fakeRegistry) ListResources() []mcp.Resource { return nil }
+
+func (fakeRegistry) ListResourceTemplates() []mcp.ResourceTemplate { return nil }
+
+func (fakeRegistry) ListPrompts() []mcp.Prompt { return nil }
+
+func (fakeRegistry) GetPrompt(name string, args map[string]string) (*mcp.PromptGetResult, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (fakeRegistry) ReadResource(ctx context.Context, uri string) (string, error) {
+	return "", fmt.Errorf("not found")
+}
+
+func post(t *testing.T, url, session, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func initialize(t *testing.T, url string) string {
+	t.Helper()
+	resp := post(t, url, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d", resp.StatusCode)
+	}
+	var result struct {
+		Result mcp.InitializeResult `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode initialize: %v", err)
+	}
+	if result.Result.ProtocolVersion != httpProtocolVersion {
+		t.Errorf("protocolVersion = %s, want %s", result.Result.ProtocolVersion, httpProtocolVersion)
+	}
+	session := resp.Header.Get(sessionHeader)
+	if session == "" {
+		t.Fatal("initialize response has no session ID")
+	}
+	return session
+}
+
+func TestHTTPSessions(t *testing.T) {
+	handler := NewHTTPHandler(fakeRegistry{}, HTTPConfig{AllowedOrigins: []string{"https://app.example"}})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := server.URL + "/mcp"
+
+	first := initialize(t, url)
+	second := initialize(t, url)
+	if first == second {
+		t.Fatalf("two clients share session %s", first)
+	}
+
+	if resp := post(t, url, "", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request without session status = %d, want 400", resp.StatusCode)
+	}
+	if resp := post(t, url, "bogus", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("request with unknown session status = %d, want 404", resp.StatusCode)
+	}
+	if resp := post(t, url, first, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", resp.StatusCode)
+	}
+
+	resp := post(t, url, first, `[{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}},{"jsonrpc":"2.0","id":4,"method":"tools/list"}]`)
+	var batch []struct {
+		ID     float64            `json:"id"`
+		Result mcp.ToolCallResult `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatalf("failed to decode batch response: %v", err)
+	}
+	if len(batch) != 2 || batch[0].ID != 3 || batch[0].Result.Content[0].Text != "hi" {
+		t.Errorf("batch response = %+v", batch)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc":"2.0","id":5,"method":"tools/list"}`))
+	req.Header.Set(sessionHeader, first)
+	req.Header.Set("Origin", "https://evil.example")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin = %v, %v; want 403", resp, err)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, url, nil)
+	req.Header.Set(sessionHeader, first)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE = %v, %v; want 204", resp, err)
+	}
+	if resp := post(t, url, first, `{"jsonrpc":"2.0","id":6,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted session status = %d, want 404", resp.StatusCode)
+	}
+	if resp := post(t, url, second, `{"jsonrpc":"2.0","id":6,"method":"tools/list"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("other session status = %d, want 200", resp.StatusCode)
+	}
+
+	handler.config.SessionTimeout = time.Minute
+	handler.expireSessions(time.Now().Add(2 * time.Minute))
+	if resp := post(t, url, second, `{"jsonrpc":"2.0","id":7,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("idle session status = %d, want 404 after expiry", resp.StatusCode)
+	}
+}
+
+func TestHTTPAuthToken(t *testing.T) {
+	handler := NewHTTPHandler(fakeRegistry{}, HTTPConfig{AuthToken: "s3cret"})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := server.URL + "/mcp"
+
+	for name, header := range map[string]string{"missing": "", "wrong": "Bearer nope", "basic": "Basic s3cret"} {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s token status = %d, want 401 with a challenge", name, resp.StatusCode)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	req.Header.Set("Authorization", "bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("valid token status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestCheckHTTPListen(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:8080", "[::1]:8080", "localhost:8080"} {
+		if err := checkHTTPListen(addr, ""); err != nil {
+			t.Errorf("checkHTTPListen(%s) = %v, want loopback allowed without a token", addr, err)
+		}
+	}
+	for _, addr := range []string{"0.0.0.0:8080", ":8080", "192.168.1.5:8080", "[::]:8080"} {
+		if err := checkHTTPListen(addr, ""); err == nil {
+			t.Errorf("checkHTTPListen(%s) allowed a network address without a token", addr)
+		}
+		if err := checkHTTPListen(addr, "s3cret"); err != nil {
+			t.Errorf("checkHTTPListen(%s) with a token = %v", addr, err)
+		}
+	}
+}
+
+func TestHTTPEventStream(t *testing.T) {
+	handler := NewHTTPHandler(fakeRegistry{}, HTTPConfig{})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := server.URL + "/mcp"
+
+	session := initialize(t, url)
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set(sessionHeader, session)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET stream = %v, %v", resp, err)
+	}
+	defer resp.Body.Close()
+
+	handler.notifyEvent(events.Event{Category: "alert", Level: "WARNING", Summary: "pool degraded"})
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended before the notification: %v", err)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if !strings.Contains(data, `"method":"notifications/message"`) || !strings.Contains(data, "pool degraded") {
+				t.Errorf("notification = %s", data)
+			}
+			break
+		}
+	}
+}
+
+func TestHTTPCancelledToolCall(t *testing.T) {
+	handler := NewHTTPHandler(fakeRegistry{}, HTTPConfig{})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := server.URL + "/mcp"
+
+	session := initialize(t, url)
+
+	// The wait tool runs until its context is cancelled
+	status := make(chan int, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc":"2.0","id":8,"method":"tools/call","params":{"name":"wait"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(sessionHeader, session)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	// The call may not be running yet; cancel until it returns
+	deadline := time.After(5 * time.Second)
+	for {
+		post(t, url, session, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8,"reason":"user stopped"}}`)
+		select {
+		case code := <-status:
+			if code != http.StatusAccepted {
+				t.Errorf("cancelled call status = %d, want 202 with no response", code)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("tool call was not cancelled")
+		}
+	}
+}
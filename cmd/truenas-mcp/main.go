@@ -2,34 +2,126 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	// Embedded zone data so NAS timezones resolve on hosts without zoneinfo
+	_ "time/tzdata"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/catalog"
+	"github.com/truenas/truenas-mcp/compliance"
+	"github.com/truenas/truenas-mcp/deletion"
+	"github.com/truenas/truenas-mcp/digest"
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/inventory"
+	"github.com/truenas/truenas-mcp/logging"
 	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/netdata"
+	"github.com/truenas/truenas-mcp/schedule"
 	"github.com/truenas/truenas-mcp/tasks"
 	"github.com/truenas/truenas-mcp/tools"
 	"github.com/truenas/truenas-mcp/truenas"
 )
 
 var (
-	truenasURL = flag.String("truenas-url", "", "TrueNAS hostname or WebSocket URL (e.g., 'truenas.local' or 'ws://10.0.0.1/websocket')")
-	apiKey     = flag.String("api-key", "", "TrueNAS API key for middleware authentication")
-	insecure   = flag.Bool("insecure", false, "Skip TLS certificate verification (for self-signed certs)")
-	versionFlg = flag.Bool("version", false, "Print version and exit")
-	debug      = flag.Bool("debug", false, "Enable debug logging")
+	truenasURL    = flag.String("truenas-url", "", "TrueNAS hostname or WebSocket URL (e.g., 'truenas.local' or 'ws://10.0.0.1/websocket')")
+	apiKey        = flag.String("api-key", "", "TrueNAS API key for middleware authentication")
+	apiKeyFile    = flag.String("api-key-file", "", "Read the TrueNAS API key from this file instead of --api-key")
+	apiKeyCommand = flag.String("api-key-command", "", "Run this shell command and use its output as the TrueNAS API key (e.g., a password manager CLI)")
+	apiKeyKeyring = flag.String("api-key-keyring", "", "Read the TrueNAS API key from the OS keyring entry for this account under the service 'truenas-mcp'")
+	username      = flag.String("username", "", "TrueNAS username to log in with instead of an API key, for systems where API keys are disabled (use with --password)")
+	password      = flag.String("password", "", "Password for --username (prefer TRUENAS_PASSWORD: flags are visible to other local users)")
+	otp           = flag.String("otp", "", "Two-factor one-time password for --username when the account requires one")
+	authToken     = flag.String("auth-token", "", "TrueNAS session token (from auth.generate_token) to log in with instead of an API key")
+	insecure      = flag.Bool("insecure", false, "Skip TLS certificate verification (for self-signed certs)")
+	versionFlg    = flag.Bool("version", false, "Print version and exit")
+	debug         = flag.Bool("debug", false, "Enable debug logging (same as --log-level debug)")
+	logLevel      = flag.String("log-level", "info", "Minimum log level: debug, info, warn, or error")
+	logFormat     = flag.String("log-format", logging.FormatText, "Log format: 'text' or 'json'")
+	logFile       = flag.String("log-file", "", "Append logs to this file instead of stderr (stdout carries the MCP protocol)")
+	readOnly      = flag.Bool("read-only", false, "Register only query tools and refuse every tool that changes TrueNAS (for monitoring-only deployments)")
+	dataDir       = flag.String("data-dir", "", "Directory for locally persisted state such as capacity history (default: user config dir/truenas-mcp)")
+
+	capacityInterval  = flag.Duration("capacity-sample-interval", 0, "How often to record pool and dataset usage for growth trends (e.g., 1h; 0 disables)")
+	capacityRetention = flag.Duration("capacity-retention", 365*24*time.Hour, "How long recorded capacity history is kept")
+
+	digestSchedule = flag.String("digest-schedule", "", "Generate a health digest on a schedule: 'daily' or 'weekly' (default: disabled)")
+	digestHour     = flag.Int("digest-hour", 7, "Local hour of day (0-23) at which scheduled digests are generated")
+	digestEmail    = flag.String("digest-email", "", "Comma-separated email recipients for scheduled digests (sent via TrueNAS mail.send)")
+	digestAlert    = flag.String("digest-alert-service", "", "Name of a TrueNAS alert service (Mail, Slack, or Mattermost) that scheduled digests are sent through")
+
+	eventCacheMaxAge = flag.Duration("event-cache-max-age", 10*time.Minute, "Serve list_alerts and query_jobs from an event-fed cache, fully re-queried at least this often (0 disables the cache)")
+
+	catalogCacheTTL = flag.Duration("catalog-cache-ttl", 6*time.Hour, "How long app catalog details are cached locally before being refetched (0 disables the cache)")
+
+	deletionGracePeriod = flag.Duration("deletion-grace-period", 0, "Defer delete tools by this long so deletions can be undone (e.g., 1h; 0 deletes immediately)")
+
+	updatePreflight = flag.String("update-preflight", "", "Policy for apply_update preflight checks as check=block|warn|ignore pairs, where check is all or a check name (default: block on every check; e.g., 'all=warn,pools=block')")
+
+	enableTools  = flag.String("enable-tools", "", "Comma-separated tools to expose; all others are hidden (supports * wildcards, e.g., 'query_*,create_dataset'; default: all tools)")
+	disableTools = flag.String("disable-tools", "", "Comma-separated tools to hide, applied after --enable-tools (supports * wildcards, e.g., 'system_reboot,delete_*')")
+
+	toolTimeouts = flag.String("tool-timeouts", "", "Override tool timeouts as name=duration pairs, where name is query, dry_run, job, or a tool name (e.g., 'query=45s,analyze_capacity=2m')")
+
+	maxResponseBytes = flag.Int("max-response-bytes", 256*1024, "Cut tool responses longer than this many bytes short, marking them truncated with a hint on how to page (0 = no limit)")
+
+	netdataURL    = flag.String("netdata-url", "", "Netdata base URL for per-second chart queries (e.g., 'http://truenas.local:19999'; default: disabled)")
+	netdataCharts = flag.String("netdata-charts", "", "Comma-separated Netdata chart ID prefixes that may be queried (default: system., cpu., mem., disk., net., zfs., nfsd. and disk I/O detail charts)")
+
+	transport          = flag.String("transport", "stdio", "MCP transport: 'stdio' or 'http' (Streamable HTTP for multiple networked clients)")
+	httpAddr           = flag.String("http-addr", "127.0.0.1:8080", "Listen address for the HTTP transport")
+	httpAllowedOrigins = flag.String("http-allowed-origins", "", "Comma-separated browser origins allowed to use the HTTP transport ('*' allows any; requests without an Origin header are always allowed)")
+	httpSessionTimeout = flag.Duration("http-session-timeout", time.Hour, "Drop HTTP sessions idle for this long (0 keeps them until deleted)")
+	httpAuthToken      = flag.String("http-auth-token", "", "Bearer token HTTP clients must send in the Authorization header; required unless --http-addr is a loopback address (or set TRUENAS_MCP_HTTP_AUTH_TOKEN)")
+
+	recordFixtures = flag.String("record-fixtures", "", "Record middleware request/response pairs to this fixture file on exit (for regression tests)")
+
+	dumpTools       = flag.Bool("dump-tools", false, "Print the tool catalog with input schemas (after --read-only and the tool filter) and exit; no TrueNAS connection is needed")
+	dumpToolsFormat = flag.String("dump-tools-format", "json", "Format for --dump-tools: 'json' or 'openapi'")
+
+	systemName = flag.String("system-name", "", "Name of the system at --truenas-url in tools' host argument when the config file lists further systems (default: 'default')")
+
+	configPath = flag.String("config", "", "Config file written by 'truenas-mcp setup' or by hand (.json or .toml), used for settings not given by flags or environment variables (default: user config dir/truenas-mcp/config.json)")
+
+	tlsCAFile = flag.String("tls-ca-file", "", "Verify the TrueNAS TLS certificate against the CA certificates in this PEM file instead of accepting any certificate")
+
+	taskPollInterval    = flag.Duration("task-poll-interval", 5*time.Second, "How often running TrueNAS jobs are polled for task progress")
+	taskCleanupInterval = flag.Duration("task-cleanup-interval", time.Minute, "How often expired tasks are removed")
 )
 
 const (
 	Version = "0.2.0"
+
+	// MCP protocol revisions spoken by each transport
+	stdioProtocolVersion = "2024-11-05"
+	httpProtocolVersion  = "2025-03-26"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "setup" {
+		if err := runSetup(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(0)
+			}
+			fmt.Fprintf(os.Stderr, "Setup failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	flag.Parse()
 
 	if *versionFlg {
@@ -37,6 +129,40 @@ func main() {
 		os.Exit(0)
 	}
 
+	// The config file is read before logging is set up, since it can hold
+	// the logging options; its settings apply to flags not given on the
+	// command line or by environment variables
+	if *configPath == "" {
+		*configPath = os.Getenv("TRUENAS_MCP_CONFIG")
+	}
+	customConfig := *configPath != ""
+	if !customConfig {
+		*configPath = defaultConfigPath()
+	}
+	fileCfg, err := loadConfigFile(*configPath)
+	if err != nil && (customConfig || !errors.Is(err, os.ErrNotExist)) {
+		fmt.Fprintf(os.Stderr, "Failed to load config file: %v\n", err)
+		os.Exit(1)
+	}
+	if fileCfg != nil {
+		if err := applyConfigSettings(flag.CommandLine, fileCfg.Settings); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config file %s: %v\n", *configPath, err)
+			os.Exit(1)
+		}
+	}
+
+	// Logs go to stderr or a file; stdout belongs to the MCP protocol
+	logConfig := logging.Config{Level: *logLevel, Format: *logFormat, File: *logFile}
+	if *debug {
+		logConfig.Level = "debug"
+	}
+	logs, err := logging.Setup(logConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+	defer logs.Close()
+
 	// Get configuration from flags or environment variables
 	if *truenasURL == "" {
 		*truenasURL = os.Getenv("TRUENAS_URL")
@@ -44,99 +170,467 @@ func main() {
 	if *apiKey == "" {
 		*apiKey = os.Getenv("TRUENAS_API_KEY")
 	}
+	if *username == "" {
+		*username = os.Getenv("TRUENAS_USERNAME")
+	}
+	if *password == "" {
+		*password = os.Getenv("TRUENAS_PASSWORD")
+	}
+	if *authToken == "" {
+		*authToken = os.Getenv("TRUENAS_AUTH_TOKEN")
+	}
+	if *apiKeyFile == "" {
+		*apiKeyFile = os.Getenv("TRUENAS_API_KEY_FILE")
+	}
+	if *apiKeyCommand == "" {
+		*apiKeyCommand = os.Getenv("TRUENAS_API_KEY_COMMAND")
+	}
+	if *apiKeyKeyring == "" {
+		*apiKeyKeyring = os.Getenv("TRUENAS_API_KEY_KEYRING")
+	}
+	keySource := apiKeySource{File: *apiKeyFile, Command: *apiKeyCommand, Keyring: *apiKeyKeyring}
+	credentialsGiven := *apiKey != "" || keySource.set() || *username != "" || *password != "" || *authToken != ""
+	if *dataDir == "" {
+		*dataDir = os.Getenv("TRUENAS_MCP_DATA_DIR")
+	}
+	if *httpAuthToken == "" {
+		*httpAuthToken = os.Getenv("TRUENAS_MCP_HTTP_AUTH_TOKEN")
+	}
+	if !*readOnly {
+		if value := os.Getenv("TRUENAS_MCP_READ_ONLY"); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				logging.Fatal("TRUENAS_MCP_READ_ONLY must be true or false", "value", value)
+			}
+			*readOnly = enabled
+		}
+	}
+	if *dataDir == "" {
+		*dataDir = defaultDataDir()
+	}
+
+	// Fall back to the config file for the connection
+	var systems []systemConfig
+	if fileCfg != nil {
+		if *truenasURL == "" {
+			*truenasURL = fileCfg.TrueNASURL
+		}
+		// Credentials come from one place, so a flag never mixes with the file
+		if !credentialsGiven {
+			*apiKey, *username, *password, *authToken = fileCfg.APIKey, fileCfg.Username, fileCfg.Password, fileCfg.AuthToken
+		}
+		if fileCfg.ReadOnly {
+			*readOnly = true
+		}
+		if *systemName == "" {
+			*systemName = fileCfg.SystemName
+		}
+		systems = fileCfg.Systems
+	}
+	if *systemName == "" {
+		*systemName = "default"
+	}
+	if keySource.set() {
+		if *apiKey != "" {
+			logging.Fatal("Give either --api-key or an API key source (--api-key-file, --api-key-command, or --api-key-keyring), not both")
+		}
+		if *apiKey, err = keySource.resolve(); err != nil {
+			logging.Fatal("Failed to read the TrueNAS API key", "error", err)
+		}
+	}
+	if err := resolveSystemKeys(systems); err != nil {
+		logging.Fatal("Failed to read a system's API key", "path", *configPath, "error", err)
+	}
+	if err := validateSystems(*systemName, systems); err != nil {
+		logging.Fatal("Invalid systems in config file", "path", *configPath, "error", err)
+	}
 
-	if *truenasURL == "" || *apiKey == "" {
-		log.Fatal("Both --truenas-url and --api-key are required (or set TRUENAS_URL and TRUENAS_API_KEY env vars)")
+	if *dumpTools {
+		toolFilter, err := tools.ParseToolFilter(*enableTools, *disableTools)
+		if err != nil {
+			logging.Fatal("Invalid tool filter", "error", err)
+		}
+		if err := writeToolCatalog(os.Stdout, *dumpToolsFormat, *readOnly, toolFilter); err != nil {
+			logging.Fatal("Failed to dump tools", "error", err)
+		}
+		os.Exit(0)
+	}
+
+	if *transport != "stdio" && *transport != "http" {
+		logging.Fatal("--transport must be 'stdio' or 'http'", "transport", *transport)
+	}
+	if *transport == "http" {
+		if err := checkHTTPListen(*httpAddr, *httpAuthToken); err != nil {
+			logging.Fatal("Refusing to serve HTTP without authentication", "error", err)
+		}
+	}
+
+	credentials := truenas.Credentials{APIKey: *apiKey, Username: *username, Password: *password, OTP: *otp, Token: *authToken}
+	if *truenasURL == "" || credentials.Method() == "" {
+		logging.Fatal("--truenas-url and credentials are required: --api-key, --username and --password, or --auth-token (or set TRUENAS_URL and TRUENAS_API_KEY env vars, or run 'truenas-mcp setup')")
+	}
+	if err := credentials.Validate(); err != nil {
+		logging.Fatal("Invalid TrueNAS credentials", "error", err)
 	}
 
 	// Configure TLS - accept self-signed certs by default (common for TrueNAS)
+	// unless a CA to verify against is given
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: true,
 	}
-	if *insecure {
-		log.Println("TLS certificate verification disabled (self-signed certs accepted)")
+	if *tlsCAFile != "" {
+		if *insecure {
+			logging.Fatal("--tls-ca-file and --insecure cannot be used together")
+		}
+		pool, err := loadCAFile(*tlsCAFile)
+		if err != nil {
+			logging.Fatal("Failed to load TLS CA file", "path", *tlsCAFile, "error", err)
+		}
+		tlsConfig = &tls.Config{RootCAs: pool}
+	} else if *insecure {
+		slog.Warn("TLS certificate verification disabled (self-signed certs accepted)")
 	}
 
 	// Create TrueNAS client
-	client, err := truenas.NewClient(*truenasURL, *apiKey, tlsConfig)
+	client, err := truenas.NewClientWithCredentials(*truenasURL, credentials, tlsConfig)
 	if err != nil {
-		log.Fatalf("Failed to create TrueNAS client: %v", err)
+		logging.Fatal("Failed to create TrueNAS client", "error", err)
 	}
 	defer client.Close()
 
+	// Record middleware traffic for test fixtures (logins are never recorded)
+	if *recordFixtures != "" {
+		recorder := truenas.NewRecorder()
+		client.SetRecorder(recorder)
+		slog.Info("Recording middleware interactions", "path", *recordFixtures)
+		defer func() {
+			if err := recorder.Save(*recordFixtures); err != nil {
+				slog.Error("Failed to save fixtures", "path", *recordFixtures, "error", err)
+				return
+			}
+			slog.Info("Saved recorded interactions", "count", recorder.Len(), "path", *recordFixtures)
+		}()
+	}
+
 	// Authenticate with TrueNAS middleware
 	if err := client.Authenticate(); err != nil {
-		log.Fatalf("Failed to authenticate with TrueNAS: %v", err)
+		logging.Fatal("Failed to authenticate with TrueNAS", "error", err)
+	}
+	slog.Info("Successfully authenticated with TrueNAS middleware")
+
+	// Connect to further systems. One that cannot be reached now is retried
+	// when a tool call is directed at it.
+	systemClients := make(map[string]*truenas.Client, len(systems))
+	for _, system := range systems {
+		systemClient, err := truenas.NewClientWithCredentials(system.URL, system.credentials(), tlsConfig)
+		if err != nil {
+			logging.Fatal("Failed to create TrueNAS client", "system", system.Name, "error", err)
+		}
+		defer systemClient.Close()
+		if err := systemClient.Authenticate(); err != nil {
+			slog.Warn("Failed to authenticate with TrueNAS system; retrying on first use", "system", system.Name, "error", err)
+		} else {
+			slog.Info("Successfully authenticated with TrueNAS system", "system", system.Name)
+		}
+		systemClients[system.Name] = systemClient
 	}
-	log.Println("Successfully authenticated with TrueNAS middleware")
 
-	// Create task manager
+	if *taskPollInterval <= 0 || *taskCleanupInterval <= 0 {
+		logging.Fatal("--task-poll-interval and --task-cleanup-interval must be positive")
+	}
+
+	// Create task manager (running job tasks are journaled so they can be
+	// recovered after a restart)
 	taskConfig := tasks.PollerConfig{
-		PollInterval:    5 * time.Second,
+		PollInterval:    *taskPollInterval,
 		MaxPollAttempts: 0, // Unlimited
-		CleanupInterval: 1 * time.Minute,
+		CleanupInterval: *taskCleanupInterval,
+		JournalPath:     filepath.Join(*dataDir, "job_tasks.json"),
 	}
 	taskManager := tasks.NewManager(client, taskConfig)
+	for name, systemClient := range systemClients {
+		taskManager.AddSystem(name, systemClient)
+	}
 	taskManager.Start()
 	defer taskManager.Shutdown()
 
+	// Create capacity history tracker
+	capacityTracker, err := capacity.NewTracker(client, capacity.TrackerConfig{
+		SampleInterval: *capacityInterval,
+		Retention:      *capacityRetention,
+		Path:           filepath.Join(*dataDir, "capacity_history.json"),
+		DatasetPath:    filepath.Join(*dataDir, "dataset_history.json"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to load capacity history", "error", err)
+	}
+	capacityTracker.Start()
+	defer capacityTracker.Shutdown()
+
+	// Create health digest scheduler
+	if *digestSchedule != "" && *digestSchedule != string(digest.PeriodDaily) && *digestSchedule != string(digest.PeriodWeekly) {
+		logging.Fatal("--digest-schedule must be 'daily' or 'weekly'", "digest_schedule", *digestSchedule)
+	}
+	if *digestHour < 0 || *digestHour > 23 {
+		logging.Fatal("--digest-hour must be between 0 and 23", "digest_hour", *digestHour)
+	}
+	var digestRecipients []string
+	for _, addr := range strings.Split(*digestEmail, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			digestRecipients = append(digestRecipients, addr)
+		}
+	}
+	digestScheduler := digest.NewScheduler(client, capacityTracker, digest.SchedulerConfig{
+		Period:       digest.Period(*digestSchedule),
+		Hour:         *digestHour,
+		EmailTo:      digestRecipients,
+		AlertService: strings.TrimSpace(*digestAlert),
+		Path:         filepath.Join(*dataDir, "health_digest.json"),
+	})
+	digestScheduler.Start()
+	defer digestScheduler.Shutdown()
+
+	// Load saved inventory snapshots, keeping the 100 most recent
+	inventoryStore, err := inventory.NewStore(filepath.Join(*dataDir, "inventory_snapshots.json"), 100)
+	if err != nil {
+		logging.Fatal("Failed to load inventory snapshots", "error", err)
+	}
+
+	// Load cached app catalog details, keeping the 200 most recent
+	catalogCache, err := catalog.NewCache(filepath.Join(*dataDir, "catalog_cache.json"), *catalogCacheTTL, 200)
+	if err != nil {
+		logging.Fatal("Failed to load catalog cache", "error", err)
+	}
+
+	// Load the saved compliance baseline
+	complianceStore, err := compliance.NewStore(filepath.Join(*dataDir, "compliance_baseline.json"))
+	if err != nil {
+		logging.Fatal("Failed to load compliance baseline", "error", err)
+	}
+
+	// Create deferred deletion queue (pending deletions persist across restarts)
+	if *deletionGracePeriod < 0 {
+		logging.Fatal("--deletion-grace-period must not be negative", "deletion_grace_period", *deletionGracePeriod)
+	}
+	deletionQueue, err := deletion.NewQueue(client, deletion.Config{
+		GracePeriod: *deletionGracePeriod,
+		Retention:   24 * time.Hour,
+		Path:        filepath.Join(*dataDir, "pending_deletions.json"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to load pending deletions", "error", err)
+	}
+	for name, systemClient := range systemClients {
+		deletionQueue.AddSystem(name, systemClient)
+	}
+	// Deletions queued before a restart into read-only mode stay pending
+	if !*readOnly {
+		deletionQueue.Start()
+	}
+	defer deletionQueue.Shutdown()
+
+	// Load operations queued for later or a maintenance window
+	scheduler, err := schedule.NewQueue(schedule.Config{
+		Retention: 7 * 24 * time.Hour,
+		Path:      filepath.Join(*dataDir, "scheduled_operations.json"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to load scheduled operations", "error", err)
+	}
+	defer scheduler.Shutdown()
+
+	// Resolve tool execution timeouts
+	timeouts, err := tools.ParseTimeoutOverrides(*toolTimeouts, tools.DefaultTimeoutConfig())
+	if err != nil {
+		logging.Fatal("Invalid --tool-timeouts", "error", err)
+	}
+
+	// Resolve which tools are exposed
+	toolFilter, err := tools.ParseToolFilter(*enableTools, *disableTools)
+	if err != nil {
+		logging.Fatal("Invalid tool filter", "error", err)
+	}
+
+	// Resolve apply_update preflight policy
+	preflightPolicy, err := tools.ParseUpdatePreflightPolicy(*updatePreflight)
+	if err != nil {
+		logging.Fatal("Invalid --update-preflight", "error", err)
+	}
+
+	// Create Netdata passthrough (nil when --netdata-url is not set)
+	var allowedCharts []string
+	for _, prefix := range strings.Split(*netdataCharts, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			allowedCharts = append(allowedCharts, prefix)
+		}
+	}
+	netdataClient, err := netdata.NewClient(netdata.Config{
+		URL:           *netdataURL,
+		AllowedCharts: allowedCharts,
+		TLSConfig:     tlsConfig,
+	})
+	if err != nil {
+		logging.Fatal("Invalid --netdata-url", "error", err)
+	}
+
+	// Create event watcher (subscriptions start when watch_events is called)
+	eventWatcher := events.NewWatcher(client, 500)
+
+	// Create alerts and jobs cache (subscriptions start on first use)
+	var eventCache *events.Cache
+	if *eventCacheMaxAge > 0 {
+		eventCache = events.NewCache(client, *eventCacheMaxAge)
+	}
+
+	if *readOnly {
+		slog.Info("Read-only mode: write tools are disabled")
+	}
+
 	// Create tool registry
-	registry := tools.NewRegistry(client, taskManager)
+	var registrySystems map[string]truenas.Caller
+	if len(systemClients) > 0 {
+		registrySystems = make(map[string]truenas.Caller, len(systemClients))
+		for name, systemClient := range systemClients {
+			registrySystems[name] = systemClient
+		}
+	}
+	registry := tools.NewRegistry(client, taskManager, tools.Options{
+		CapacityTracker:  capacityTracker,
+		CatalogCache:     catalogCache,
+		ComplianceStore:  complianceStore,
+		DeletionQueue:    deletionQueue,
+		DefaultSystem:    *systemName,
+		DigestScheduler:  digestScheduler,
+		EventCache:       eventCache,
+		EventWatcher:     eventWatcher,
+		InventoryStore:   inventoryStore,
+		MaxResponseBytes: *maxResponseBytes,
+		Netdata:          netdataClient,
+		ReadOnly:         *readOnly,
+		Scheduler:        scheduler,
+		ServerVersion:    Version,
+		Systems:          registrySystems,
+		Timeouts:         &timeouts,
+		ToolFilter:       toolFilter,
+		UpdatePreflight:  preflightPolicy,
+	})
+
+	// Started once the registry can run queued tool calls. Operations queued
+	// before a restart into read-only mode stay pending.
+	if !*readOnly {
+		scheduler.Start()
+	}
+
+	if *transport == "http" {
+		var origins []string
+		for _, origin := range strings.Split(*httpAllowedOrigins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				origins = append(origins, origin)
+			}
+		}
+		handler := NewHTTPHandler(registry, HTTPConfig{
+			Addr:           *httpAddr,
+			AllowedOrigins: origins,
+			SessionTimeout: *httpSessionTimeout,
+			AuthToken:      *httpAuthToken,
+		})
+		eventWatcher.SetNotifier(handler.notifyEvent)
+		if err := handler.Run(); err != nil {
+			slog.Error("HTTP handler error", "error", err)
+		}
+		return
+	}
 
 	// Start stdio handler
-	handler := NewStdioHandler(registry, *debug)
+	handler := NewStdioHandler(registry)
+	eventWatcher.SetNotifier(handler.notifyEvent)
 	if err := handler.Run(); err != nil {
-		log.Fatalf("Stdio handler error: %v", err)
+		logging.Fatal("Stdio handler error", "error", err)
 	}
 }
 
+// writeToolCatalog prints the tool catalog as the server would expose it.
+// The registry has no client, so nothing connects to TrueNAS.
+func writeToolCatalog(w io.Writer, format string, readOnly bool, filter tools.ToolFilter) error {
+	// Without a path the queue keeps nothing; it only adds the scheduling
+	// arguments write tools accept
+	scheduler, err := schedule.NewQueue(schedule.Config{})
+	if err != nil {
+		return err
+	}
+	defer scheduler.Shutdown()
+
+	registry := tools.NewRegistry(nil, nil, tools.Options{
+		ReadOnly:      readOnly,
+		Scheduler:     scheduler,
+		ServerVersion: Version,
+		ToolFilter:    filter,
+	})
+	bundle, err := registry.ToolCatalogBundle(format, nil)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// defaultDataDir returns the per-user directory used for persisted state
+func defaultDataDir() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "truenas-mcp")
+	}
+	return ".truenas-mcp"
+}
+
 // StdioHandler manages stdio communication for MCP protocol
 type StdioHandler struct {
-	registry    mcp.ToolRegistry
+	session     *Session
 	stdin       *bufio.Scanner
 	stdoutMutex sync.Mutex
-	debug       bool
 }
 
-func NewStdioHandler(registry mcp.ToolRegistry, debug bool) *StdioHandler {
-	return &StdioHandler{
-		registry: registry,
-		stdin:    bufio.NewScanner(os.Stdin),
-		debug:    debug,
+func NewStdioHandler(registry mcp.ToolRegistry) *StdioHandler {
+	h := &StdioHandler{
+		stdin: bufio.NewScanner(os.Stdin),
 	}
+	h.session = NewSession("", registry, stdioProtocolVersion, h.writeMessage)
+	return h
 }
 
 func (h *StdioHandler) Run() error {
-	if h.debug {
-		log.Println("Starting stdio handler...")
-	}
+	slog.Debug("Starting stdio handler")
+
+	// Tool calls run concurrently so that a notifications/cancelled sent
+	// while one is running is read and applied
+	var calls sync.WaitGroup
+	defer calls.Wait()
 
 	for h.stdin.Scan() {
 		line := h.stdin.Bytes()
-		if h.debug {
-			log.Printf("[STDIN] %s", string(line))
-		}
+		slog.Debug("Received message on stdin", "message", line)
 
 		var req mcp.Request
 		if err := json.Unmarshal(line, &req); err != nil {
-			if h.debug {
-				log.Printf("Parse error: %v", err)
-			}
+			slog.Debug("Parse error", "error", err)
 			h.sendError(nil, -32700, fmt.Sprintf("Parse error: %v", err))
 			continue
 		}
 
-		if h.debug {
-			log.Printf("Handling method: %s (id: %v)", req.Method, req.ID)
-		}
+		slog.Debug("Handling method", "method", req.Method, "id", req.ID)
 
-		resp := h.handleRequest(&req)
-		// Only send response if not nil (notifications don't get responses)
-		if resp != nil {
-			if err := h.sendResponse(resp); err != nil {
-				log.Printf("Failed to send response: %v", err)
-			}
+		if req.Method == "tools/call" {
+			calls.Add(1)
+			go func(req mcp.Request) {
+				defer calls.Done()
+				h.respond(h.session.handleRequest(context.Background(), &req))
+			}(req)
+			continue
 		}
+		h.respond(h.session.handleRequest(context.Background(), &req))
 	}
 
 	if err := h.stdin.Err(); err != nil {
@@ -146,137 +640,45 @@ func (h *StdioHandler) Run() error {
 	return nil
 }
 
-func (h *StdioHandler) handleRequest(req *mcp.Request) *mcp.Response {
-	switch req.Method {
-	case "initialize":
-		return h.handleInitialize(req)
-	case "notifications/initialized":
-		// This is a notification from the client after initialization
-		// Notifications don't require a response
-		return nil
-	case "tools/list":
-		return h.handleToolsList(req)
-	case "tools/call":
-		return h.handleToolsCall(req)
-	default:
-		// Only return error if this is a request (has an ID)
-		if req.ID != nil {
-			return h.createErrorResponse(req.ID, -32601, "Method not found")
-		}
-		// For notifications, no response needed
-		return nil
-	}
-}
-
-func (h *StdioHandler) handleInitialize(req *mcp.Request) *mcp.Response {
-	result := mcp.InitializeResult{
-		ProtocolVersion: "2024-11-05",
-		ServerInfo: mcp.ServerInfo{
-			Name:    "truenas-mcp",
-			Version: Version,
-		},
-		Capabilities: mcp.Capabilities{
-			Tools: map[string]interface{}{
-				"listChanged": false,
-			},
-		},
-	}
-
-	return &mcp.Response{
-		JSONRPC: "2.0",
-		ID:      req.ID,
-		Result:  result,
-	}
+// notifyEvent forwards a watched middleware event to the client
+func (h *StdioHandler) notifyEvent(ev events.Event) {
+	h.session.notifyEvent(ev)
 }
 
-func (h *StdioHandler) handleToolsList(req *mcp.Request) *mcp.Response {
-	tools := h.registry.ListTools()
-	result := mcp.ToolsListResult{
-		Tools: tools,
-	}
-
-	return &mcp.Response{
-		JSONRPC: "2.0",
-		ID:      req.ID,
-		Result:  result,
-	}
-}
-
-func (h *StdioHandler) handleToolsCall(req *mcp.Request) *mcp.Response {
-	// Extract tool call parameters
-	var params mcp.ToolCallParams
-	paramsBytes, err := json.Marshal(req.Params)
-	if err != nil {
-		return h.createErrorResponse(req.ID, -32602, fmt.Sprintf("Invalid params: %v", err))
-	}
-
-	if err := json.Unmarshal(paramsBytes, &params); err != nil {
-		return h.createErrorResponse(req.ID, -32602, fmt.Sprintf("Invalid params: %v", err))
+// respond sends resp unless it is nil (notifications and cancelled requests
+// get no response)
+func (h *StdioHandler) respond(resp *mcp.Response) {
+	if resp == nil {
+		return
 	}
-
-	// Call the tool
-	result, err := h.registry.CallTool(params.Name, params.Arguments)
-	if err != nil {
-		return &mcp.Response{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Result: mcp.ToolCallResult{
-				Content: []mcp.ContentBlock{
-					{
-						Type: "text",
-						Text: fmt.Sprintf("Error: %v", err),
-					},
-				},
-				IsError: true,
-			},
-		}
-	}
-
-	return &mcp.Response{
-		JSONRPC: "2.0",
-		ID:      req.ID,
-		Result: mcp.ToolCallResult{
-			Content: []mcp.ContentBlock{
-				{
-					Type: "text",
-					Text: result,
-				},
-			},
-		},
+	if err := h.sendResponse(resp); err != nil {
+		slog.Error("Failed to send response", "error", err)
 	}
 }
 
-func (h *StdioHandler) createErrorResponse(id interface{}, code int, message string) *mcp.Response {
-	return &mcp.Response{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error: &mcp.Error{
-			Code:    code,
-			Message: message,
-		},
-	}
+func (h *StdioHandler) sendResponse(resp *mcp.Response) error {
+	return h.writeMessage(resp)
 }
 
-func (h *StdioHandler) sendResponse(resp *mcp.Response) error {
+// writeMessage writes a single JSON-RPC message to stdout
+func (h *StdioHandler) writeMessage(msg interface{}) error {
 	h.stdoutMutex.Lock()
 	defer h.stdoutMutex.Unlock()
 
-	data, err := json.Marshal(resp)
+	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal response: %w", err)
 	}
 
-	if h.debug {
-		log.Printf("[STDOUT] %s", string(data))
-	}
+	slog.Debug("Writing message to stdout", "message", data)
 
 	fmt.Printf("%s\n", data)
 	return nil
 }
 
 func (h *StdioHandler) sendError(id interface{}, code int, message string) {
-	resp := h.createErrorResponse(id, code, message)
+	resp := errorResponse(id, code, message)
 	if err := h.sendResponse(resp); err != nil {
-		log.Printf("Failed to send error response: %v", err)
+		slog.Error("Failed to send error response", "error", err)
 	}
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/tools"
+)
+
+func TestWriteToolCatalog(t *testing.T) {
+	filter, _ := tools.ParseToolFilter("", "delete_*")
+	var out strings.Builder
+	if err := writeToolCatalog(&out, "json", false, filter); err != nil {
+		t.Fatalf("writeToolCatalog failed: %v", err)
+	}
+	var catalog tools.ToolCatalog
+	if err := json.Unmarshal([]byte(out.String()), &catalog); err != nil {
+		t.Fatalf("catalog is not JSON: %v", err)
+	}
+	if catalog.Version != Version || catalog.ToolCount == 0 {
+		t.Errorf("catalog version %s with %d tools", catalog.Version, catalog.ToolCount)
+	}
+	for _, tool := range catalog.Tools {
+		if strings.HasPrefix(tool.Name, "delete_") {
+			t.Errorf("filtered tool %s in catalog", tool.Name)
+		}
+		if tool.Name == "create_dataset" {
+			props, _ := tool.InputSchema["properties"].(map[string]interface{})
+			if _, ok := props["schedule_at"]; !ok {
+				t.Error("create_dataset schema is missing schedule_at")
+			}
+		}
+	}
+
+	if err := writeToolCatalog(&out, "xml", false, filter); err == nil {
+		t.Error("writeToolCatalog accepted format xml")
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/tools"
+)
+
+// Session holds the protocol state of one MCP client. The stdio transport
+// has a single session; the HTTP transport creates one per initialize.
+type Session struct {
+	ID              string
+	registry        mcp.ToolRegistry
+	protocolVersion string
+
+	// send delivers server-initiated messages such as notifications
+	send func(msg interface{}) error
+
+	// logLevel is the minimum level for notifications/message (set via logging/setLevel)
+	logLevel string
+	logMutex sync.Mutex
+
+	// inflight maps the request IDs of running tool calls to their cancel
+	// functions, for notifications/cancelled
+	inflight      map[string]context.CancelFunc
+	inflightMutex sync.Mutex
+}
+
+// mcpLogLevels orders MCP logging levels from least to most severe
+var mcpLogLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+func NewSession(id string, registry mcp.ToolRegistry, protocolVersion string, send func(msg interface{}) error) *Session {
+	return &Session{
+		ID:              id,
+		registry:        registry,
+		protocolVersion: protocolVersion,
+		send:            send,
+		logLevel:        "info",
+		inflight:        make(map[string]context.CancelFunc),
+	}
+}
+
+// handleRequest dispatches a JSON-RPC message. It returns nil for
+// notifications, which get no response, and for cancelled requests. ctx
+// ends when the transport can no longer deliver the response.
+func (s *Session) handleRequest(ctx context.Context, req *mcp.Request) *mcp.Response {
+	switch req.Method {
+	case "initialize":
+		return s.handleInitialize(req)
+	case "notifications/initialized":
+		// This is a notification from the client after initialization
+		// Notifications don't require a response
+		return nil
+	case "notifications/cancelled":
+		s.cancelRequest(req.Params["requestId"], req.Params["reason"])
+		return nil
+	case "tools/list":
+		return s.handleToolsList(req)
+	case "tools/call":
+		return s.handleToolsCall(ctx, req)
+	case "resources/list":
+		return s.handleResourcesList(req)
+	case "resources/templates/list":
+		return s.handleResourceTemplatesList(req)
+	case "resources/read":
+		return s.handleResourcesRead(ctx, req)
+	case "prompts/list":
+		return s.handlePromptsList(req)
+	case "prompts/get":
+		return s.handlePromptsGet(req)
+	case "logging/setLevel":
+		return s.handleSetLogLevel(req)
+	default:
+		// Only return error if this is a request (has an ID)
+		if req.ID != nil {
+			return errorResponse(req.ID, -32601, "Method not found")
+		}
+		// For notifications, no response needed
+		return nil
+	}
+}
+
+func (s *Session) handleInitialize(req *mcp.Request) *mcp.Response {
+	result := mcp.InitializeResult{
+		ProtocolVersion: s.protocolVersion,
+		ServerInfo: mcp.ServerInfo{
+			Name:    "truenas-mcp",
+			Version: Version,
+		},
+		Capabilities: mcp.Capabilities{
+			Tools: map[string]interface{}{
+				"listChanged": false,
+			},
+			Resources: map[string]interface{}{
+				"listChanged": false,
+			},
+			Prompts: map[string]interface{}{
+				"listChanged": false,
+			},
+			Logging: map[string]interface{}{},
+		},
+	}
+
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	}
+}
+
+func (s *Session) handleToolsList(req *mcp.Request) *mcp.Response {
+	tools := s.registry.ListTools()
+	result := mcp.ToolsListResult{
+		Tools: tools,
+	}
+
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	}
+}
+
+func (s *Session) handleToolsCall(ctx context.Context, req *mcp.Request) *mcp.Response {
+	// Extract tool call parameters
+	var params mcp.ToolCallParams
+	paramsBytes, err := json.Marshal(req.Params)
+	if err != nil {
+		return errorResponse(req.ID, -32602, fmt.Sprintf("Invalid params: %v", err))
+	}
+
+	if err := json.Unmarshal(paramsBytes, &params); err != nil {
+		return errorResponse(req.ID, -32602, fmt.Sprintf("Invalid params: %v", err))
+	}
+
+	// Call the tool. The correlation ID is returned in _meta and ties the
+	// response to its middleware calls and tasks in the server log.
+	correlationID := tools.NewCorrelationID()
+	meta := map[string]interface{}{"correlationId": correlationID}
+	ctx, done := s.trackRequest(ctx, req.ID)
+	defer done()
+	result, err := s.registry.CallToolInSession(ctx, s.ID, correlationID, params.Name, params.Arguments)
+	if err != nil && ctx.Err() != nil {
+		// Cancelled requests get no response
+		slog.Info("Tool cancelled by the client", "correlation_id", correlationID, "tool", params.Name)
+		return nil
+	}
+	if err != nil {
+		// Failures carry a coded payload so clients can branch on the failure type
+		toolErr := tools.ClassifyError(err)
+		toolErr.CorrelationID = correlationID
+		payload := map[string]interface{}{"error": toolErr}
+		text, marshalErr := json.MarshalIndent(payload, "", "  ")
+		if marshalErr != nil {
+			text = []byte(fmt.Sprintf("Error: %v", err))
+		}
+		return &mcp.Response{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result: mcp.ToolCallResult{
+				Content: []mcp.ContentBlock{
+					{
+						Type: "text",
+						Text: string(text),
+					},
+				},
+				StructuredContent: payload,
+				IsError:           true,
+				Meta:              meta,
+			},
+		}
+	}
+
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: mcp.ToolCallResult{
+			Content: []mcp.ContentBlock{
+				{
+					Type: "text",
+					Text: result,
+				},
+			},
+			Meta: meta,
+		},
+	}
+}
+
+func (s *Session) handleResourcesList(req *mcp.Request) *mcp.Response {
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: mcp.ResourcesListResult{
+			Resources: s.registry.ListResources(),
+		},
+	}
+}
+
+func (s *Session) handleResourceTemplatesList(req *mcp.Request) *mcp.Response {
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: mcp.ResourceTemplatesListResult{
+			ResourceTemplates: s.registry.ListResourceTemplates(),
+		},
+	}
+}
+
+func (s *Session) handleResourcesRead(ctx context.Context, req *mcp.Request) *mcp.Response {
+	uri, _ := req.Params["uri"].(string)
+	if uri == "" {
+		return errorResponse(req.ID, -32602, "Invalid params: uri is required")
+	}
+
+	ctx, done := s.trackRequest(ctx, req.ID)
+	defer done()
+	text, err := s.registry.ReadResource(ctx, uri)
+	if err != nil && ctx.Err() != nil {
+		// Cancelled requests get no response
+		slog.Info("Resource read cancelled by the client", "uri", uri)
+		return nil
+	}
+	if err != nil {
+		if tools.ClassifyError(err).Code == tools.ErrorNotFound {
+			return errorResponse(req.ID, -32002, fmt.Sprintf("Resource not found: %s", uri))
+		}
+		return errorResponse(req.ID, -32603, err.Error())
+	}
+
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: mcp.ResourceReadResult{
+			Contents: []mcp.ResourceContents{
+				{URI: uri, MimeType: "application/json", Text: text},
+			},
+		},
+	}
+}
+
+func (s *Session) handlePromptsList(req *mcp.Request) *mcp.Response {
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: mcp.PromptsListResult{
+			Prompts: s.registry.ListPrompts(),
+		},
+	}
+}
+
+func (s *Session) handlePromptsGet(req *mcp.Request) *mcp.Response {
+	name, _ := req.Params["name"].(string)
+	if name == "" {
+		return errorResponse(req.ID, -32602, "Invalid params: name is required")
+	}
+	args := map[string]string{}
+	if raw, ok := req.Params["arguments"].(map[string]interface{}); ok {
+		for key, value := range raw {
+			if str, ok := value.(string); ok {
+				args[key] = str
+			} else {
+				args[key] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	result, err := s.registry.GetPrompt(name, args)
+	if err != nil {
+		switch tools.ClassifyError(err).Code {
+		case tools.ErrorNotFound, tools.ErrorValidation:
+			return errorResponse(req.ID, -32602, fmt.Sprintf("Invalid params: %v", err))
+		}
+		return errorResponse(req.ID, -32603, err.Error())
+	}
+
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	}
+}
+
+func (s *Session) handleSetLogLevel(req *mcp.Request) *mcp.Response {
+	level, _ := req.Params["level"].(string)
+	if logLevelRank(level) < 0 {
+		return errorResponse(req.ID, -32602, fmt.Sprintf("Invalid log level: %s", level))
+	}
+
+	s.logMutex.Lock()
+	s.logLevel = level
+	s.logMutex.Unlock()
+
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  map[string]interface{}{},
+	}
+}
+
+// trackRequest derives a context for a request that notifications/cancelled
+// can cancel. done must be called when the request returns.
+func (s *Session) trackRequest(ctx context.Context, id interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	key := requestKey(id)
+	s.inflightMutex.Lock()
+	s.inflight[key] = cancel
+	s.inflightMutex.Unlock()
+	return ctx, func() {
+		s.inflightMutex.Lock()
+		delete(s.inflight, key)
+		s.inflightMutex.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest cancels a running tool call. Unknown IDs are ignored: the
+// call may already have finished.
+func (s *Session) cancelRequest(id, reason interface{}) {
+	s.inflightMutex.Lock()
+	cancel, ok := s.inflight[requestKey(id)]
+	s.inflightMutex.Unlock()
+	if !ok {
+		return
+	}
+	slog.Info("Cancelling request", "id", id, "reason", reason)
+	cancel()
+}
+
+// cancelAll cancels every running tool call (the session is closing)
+func (s *Session) cancelAll() {
+	s.inflightMutex.Lock()
+	defer s.inflightMutex.Unlock()
+	for _, cancel := range s.inflight {
+		cancel()
+	}
+}
+
+// requestKey normalizes a JSON-RPC ID; 1 and "1" are different requests
+func requestKey(id interface{}) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
+
+// notifyEvent forwards a watched middleware event as a notifications/message log notification
+func (s *Session) notifyEvent(ev events.Event) {
+	level := strings.ToLower(ev.Level)
+	if logLevelRank(level) < 0 {
+		level = "info"
+	}
+
+	s.logMutex.Lock()
+	minLevel := s.logLevel
+	s.logMutex.Unlock()
+	if logLevelRank(level) < logLevelRank(minLevel) {
+		return
+	}
+
+	notification := mcp.Notification{
+		JSONRPC: "2.0",
+		Method:  "notifications/message",
+		Params: mcp.LogMessageParams{
+			Level:  level,
+			Logger: "truenas." + string(ev.Category),
+			Data:   ev,
+		},
+	}
+	if err := s.send(notification); err != nil {
+		slog.Error("Failed to send notification", "error", err)
+	}
+}
+
+// logLevelRank returns the severity rank of an MCP log level, or -1 if unknown
+func logLevelRank(level string) int {
+	for i, l := range mcpLogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func errorResponse(id interface{}, code int, message string) *mcp.Response {
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &mcp.Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+	"github.com/truenas/truenas-mcp/toml"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// First-run setup: `truenas-mcp setup` checks the connection, writes the
+// config file, and prints MCP client configuration
+
+// fileConfig is the config file written by setup. Flags and environment
+// variables take precedence over it.
+type fileConfig struct {
+	TrueNASURL string `json:"truenas_url"`
+	APIKey     string `json:"api_key"`
+	ReadOnly   bool   `json:"read_only,omitempty"`
+
+	// Username and Password, or AuthToken, log in instead of APIKey on
+	// systems where API keys are disabled
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+
+	// SystemName names the system at TrueNASURL in the host argument when
+	// Systems lists others (default "default")
+	SystemName string `json:"system_name,omitempty"`
+
+	// Systems are further TrueNAS systems tools can be directed at
+	Systems []systemConfig `json:"systems,omitempty"`
+
+	// Settings are the remaining keys, which set command-line flags (see
+	// applyConfigSettings)
+	Settings map[string]interface{} `json:"-"`
+}
+
+// systemConfig is one further TrueNAS system in the config file
+type systemConfig struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	APIKey    string `json:"api_key,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+
+	// APIKeyFile, APIKeyCommand, or APIKeyKeyring supply the API key
+	// instead of APIKey
+	APIKeyFile    string `json:"api_key_file,omitempty"`
+	APIKeyCommand string `json:"api_key_command,omitempty"`
+	APIKeyKeyring string `json:"api_key_keyring,omitempty"`
+}
+
+// apiKeySource returns where the system's API key is read from
+func (s systemConfig) apiKeySource() apiKeySource {
+	return apiKeySource{File: s.APIKeyFile, Command: s.APIKeyCommand, Keyring: s.APIKeyKeyring}
+}
+
+// credentials returns how the client of the system logs in
+func (s systemConfig) credentials() truenas.Credentials {
+	return truenas.Credentials{APIKey: s.APIKey, Username: s.Username, Password: s.Password, Token: s.AuthToken}
+}
+
+// validateSystems checks that every further system has a URL, one kind of
+// credentials, and a name distinct from the others and from the default
+// system's
+func validateSystems(defaultName string, systems []systemConfig) error {
+	seen := map[string]bool{defaultName: true}
+	for i, system := range systems {
+		switch {
+		case system.Name == "":
+			return fmt.Errorf("system %d has no name", i+1)
+		case seen[system.Name]:
+			return fmt.Errorf("system name %q is used more than once", system.Name)
+		case system.URL == "":
+			return fmt.Errorf("system %s has no url", system.Name)
+		}
+		if err := system.credentials().Validate(); err != nil {
+			return fmt.Errorf("system %s: %w", system.Name, err)
+		}
+		seen[system.Name] = true
+	}
+	return nil
+}
+
+// defaultConfigPath returns the config file used when --config is not set
+func defaultConfigPath() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "truenas-mcp", "config.json")
+	}
+	return filepath.Join(".truenas-mcp", "config.json")
+}
+
+// loadConfigFile reads a config file written by setup, or a TOML config
+// file written by hand
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isTOMLConfig(path) {
+		doc, err := toml.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	var cfg fileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// saveConfigFile writes the config file readable only by the current user,
+// since it holds the API key
+func saveConfigFile(path string, cfg *fileConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// An interrupted write leaves the existing config in place
+	if err := atomicfile.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// diagnostic is the outcome of one connection check
+type diagnostic struct {
+	Check  string
+	OK     bool
+	Detail string
+	Hint   string
+}
+
+// diagnosticTimeout bounds each network check
+const diagnosticTimeout = 10 * time.Second
+
+// endpointAddress returns the host and port the client connects to for
+// endpoint, following the same rules as the TrueNAS client
+func endpointAddress(endpoint string) (host, port string, err error) {
+	if strings.HasPrefix(endpoint, "ws://") {
+		return "", "", fmt.Errorf("ws:// (unencrypted) connections are not allowed; TrueNAS revokes API keys used over ws://")
+	}
+	if strings.HasPrefix(endpoint, "wss://") {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Hostname() == "" {
+			return "", "", fmt.Errorf("invalid URL %q", endpoint)
+		}
+		port = u.Port()
+		if port == "" {
+			port = "443"
+		}
+		return u.Hostname(), port, nil
+	}
+	if strings.Contains(endpoint, "://") {
+		return "", "", fmt.Errorf("unsupported URL %q; use a hostname, an IP address, or a wss:// URL", endpoint)
+	}
+	host = endpoint
+	if idx := strings.LastIndex(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid hostname %q", endpoint)
+	}
+	return host, "443", nil
+}
+
+// diagnoseConnection checks each step of connecting to TrueNAS in order,
+// stopping at the first that fails
+func diagnoseConnection(endpoint, apiKey string, tlsConfig *tls.Config) []diagnostic {
+	host, port, err := endpointAddress(endpoint)
+	if err != nil {
+		return []diagnostic{{Check: "URL", Detail: err.Error(), Hint: "Use the NAS hostname or IP address (e.g., truenas.local), or a full wss:// URL"}}
+	}
+	results := []diagnostic{{Check: "URL", OK: true, Detail: fmt.Sprintf("connecting to wss://%s:%s", host, port)}}
+	if !strings.HasPrefix(endpoint, "wss://") && strings.Contains(endpoint, ":") {
+		results[0].Detail += " (a port in a hostname is ignored; pass a wss:// URL to use another port)"
+	}
+
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return append(results, diagnostic{Check: "DNS", Detail: err.Error(), Hint: "Check the hostname, or use the NAS IP address instead"})
+	}
+	results = append(results, diagnostic{Check: "DNS", OK: true, Detail: fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))})
+
+	dialer := &net.Dialer{Timeout: diagnosticTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), tlsConfig)
+	if err != nil {
+		return append(results, diagnostic{Check: "TLS", Detail: err.Error(),
+			Hint: fmt.Sprintf("Is the NAS powered on and reachable from this machine? The web interface must be served over HTTPS on port %s; check firewalls and VPNs in between", port)})
+	}
+	tlsResult := diagnostic{Check: "TLS", OK: true, Detail: fmt.Sprintf("HTTPS connection to %s:%s established", host, port)}
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates}); err != nil {
+			tlsResult.Detail += "; the certificate is self-signed or not trusted by this machine (accepted, as TrueNAS uses a self-signed certificate by default)"
+		}
+	}
+	conn.Close()
+	results = append(results, tlsResult)
+
+	client, err := truenas.NewClient(endpoint, apiKey, tlsConfig)
+	if err != nil {
+		return append(results, diagnostic{Check: "Authentication", Detail: err.Error()})
+	}
+	defer client.Close()
+	if err := client.Authenticate(); err != nil {
+		return append(results, diagnostic{Check: "Authentication", Detail: err.Error(),
+			Hint: "Check the API key (System Settings → API Keys). It must not be revoked or expired, and its user needs admin privileges for write tools"})
+	}
+	results = append(results, diagnostic{Check: "Authentication", OK: true, Detail: "API key accepted"})
+
+	result, err := client.Call("system.info")
+	if err != nil {
+		return append(results, diagnostic{Check: "API", Detail: err.Error(),
+			Hint: "The key authenticated but cannot read system information; give its user read access to the system"})
+	}
+	var info map[string]interface{}
+	if err := json.Unmarshal(result, &info); err != nil {
+		return append(results, diagnostic{Check: "API", Detail: fmt.Sprintf("unexpected system.info response: %v", err)})
+	}
+	return append(results, diagnostic{Check: "API", OK: true, Detail: fmt.Sprintf("%v running %v", info["hostname"], info["version"])})
+}
+
+// mcpClientConfig renders the configuration an MCP client needs to launch
+// truenas-mcp
+type mcpClientConfig struct {
+	Name     string
+	Label    string
+	Location string
+	Render   func(command string, args []string) string
+}
+
+// stdioServerEntry is a client's server entry for a stdio command
+func stdioServerEntry(command string, args []string) map[string]interface{} {
+	entry := map[string]interface{}{"command": command}
+	if len(args) > 0 {
+		entry["args"] = args
+	}
+	return entry
+}
+
+// renderServersJSON renders a client config file with the server under key
+func renderServersJSON(key string, entry map[string]interface{}) string {
+	data, _ := json.MarshalIndent(map[string]interface{}{
+		key: map[string]interface{}{"truenas": entry},
+	}, "", "  ")
+	return string(data)
+}
+
+// shellQuote quotes a command-line argument when needed
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'$\\") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// claudeDesktopConfigPath returns where Claude Desktop reads its configuration
+func claudeDesktopConfigPath() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "~/Library/Application Support/Claude/claude_desktop_config.json"
+	case "windows":
+		return `%APPDATA%\Claude\claude_desktop_config.json`
+	}
+	return "~/.config/Claude/claude_desktop_config.json"
+}
+
+// mcpClients are the clients setup prints configuration for
+var mcpClients = []mcpClientConfig{
+	{
+		Name:     "claude-desktop",
+		Label:    "Claude Desktop",
+		Location: "Merge into " + claudeDesktopConfigPath() + ", then quit and restart Claude Desktop",
+		Render: func(command string, args []string) string {
+			return renderServersJSON("mcpServers", stdioServerEntry(command, args))
+		},
+	},
+	{
+		Name:     "claude-code",
+		Label:    "Claude Code",
+		Location: "Run in a terminal",
+		Render: func(command string, args []string) string {
+			parts := []string{"claude", "mcp", "add", "truenas", "--", shellQuote(command)}
+			for _, arg := range args {
+				parts = append(parts, shellQuote(arg))
+			}
+			return strings.Join(parts, " ")
+		},
+	},
+	{
+		Name:     "cursor",
+		Label:    "Cursor",
+		Location: "Merge into ~/.cursor/mcp.json (or .cursor/mcp.json in a project)",
+		Render: func(command string, args []string) string {
+			return renderServersJSON("mcpServers", stdioServerEntry(command, args))
+		},
+	},
+	{
+		Name:     "vscode",
+		Label:    "VS Code",
+		Location: "Merge into .vscode/mcp.json in your workspace",
+		Render: func(command string, args []string) string {
+			entry := stdioServerEntry(command, args)
+			entry["type"] = "stdio"
+			return renderServersJSON("servers", entry)
+		},
+	},
+}
+
+// mcpClientNames lists the accepted --client values
+func mcpClientNames() []string {
+	names := []string{"all"}
+	for _, c := range mcpClients {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// prompter asks setup questions on the terminal
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask reads an answer, returning def for an empty line
+func (p *prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("setup cancelled: %w", err)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm asks a yes/no question
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := p.ask(fmt.Sprintf("%s (%s)", question, hint), "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "Please answer y or n.")
+	}
+}
+
+// maskKey shows only the end of an API key
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// runSetup implements `truenas-mcp setup`
+func runSetup(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("setup", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	setupURL := fs.String("truenas-url", "", "TrueNAS hostname or wss:// URL (default: TRUENAS_URL or the existing config file)")
+	setupKey := fs.String("api-key", "", "TrueNAS API key (default: TRUENAS_API_KEY or the existing config file)")
+	setupReadOnly := fs.Bool("read-only", false, "Save read-only mode in the config file, so no tool can change TrueNAS")
+	configFile := fs.String("config", "", "Config file to write (default: TRUENAS_MCP_CONFIG or "+defaultConfigPath()+")")
+	nonInteractive := fs.Bool("non-interactive", false, "Do not prompt; take every value from flags, environment variables, or the existing config file")
+	clientName := fs.String("client", "all", "MCP client to print configuration for: "+strings.Join(mcpClientNames(), ", "))
+	verbose := fs.Bool("debug", false, "Show client log output during the connection checks")
+	fs.Usage = func() {
+		fmt.Fprintln(stdout, "Usage: truenas-mcp setup [flags]")
+		fmt.Fprintln(stdout, "\nChecks the connection to TrueNAS, writes the config file, and prints MCP client configuration.")
+		fmt.Fprintln(stdout, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var clients []mcpClientConfig
+	for _, c := range mcpClients {
+		if *clientName == "all" || *clientName == c.Name {
+			clients = append(clients, c)
+		}
+	}
+	if len(clients) == 0 {
+		return fmt.Errorf("--client must be one of %s, got: %s", strings.Join(mcpClientNames(), ", "), *clientName)
+	}
+
+	// The TrueNAS client logs each connection step; keep the checklist readable
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("TRUENAS_MCP_CONFIG")
+	}
+	customPath := path != ""
+	if !customPath {
+		path = defaultConfigPath()
+	}
+	if isTOMLConfig(path) {
+		return fmt.Errorf("setup writes JSON config files; edit %s by hand or pass a .json --config", path)
+	}
+
+	// Start from the existing config, then environment variables, then flags
+	cfg := &fileConfig{}
+	existing, err := loadConfigFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if existing != nil {
+		*cfg = *existing
+	}
+	if env := os.Getenv("TRUENAS_URL"); env != "" {
+		cfg.TrueNASURL = env
+	}
+	if env := os.Getenv("TRUENAS_API_KEY"); env != "" {
+		cfg.APIKey = env
+	}
+	if *setupURL != "" {
+		cfg.TrueNASURL = *setupURL
+	}
+	if *setupKey != "" {
+		cfg.APIKey = *setupKey
+	}
+	if *setupReadOnly {
+		cfg.ReadOnly = true
+	}
+
+	p := &prompter{in: bufio.NewReader(stdin), out: stdout}
+	if !*nonInteractive {
+		fmt.Fprintln(stdout, "TrueNAS MCP setup")
+		fmt.Fprintln(stdout, "Create an API key in the TrueNAS web interface under System Settings → API Keys.")
+		fmt.Fprintln(stdout)
+		if cfg.TrueNASURL, err = p.ask("TrueNAS hostname, IP address, or wss:// URL", cfg.TrueNASURL); err != nil {
+			return err
+		}
+		keyDefault := ""
+		if cfg.APIKey != "" {
+			keyDefault = maskKey(cfg.APIKey)
+		}
+		key, err := p.ask("API key (input is visible)", keyDefault)
+		if err != nil {
+			return err
+		}
+		if key != keyDefault {
+			cfg.APIKey = key
+		}
+		if !*setupReadOnly {
+			if cfg.ReadOnly, err = p.confirm("Read-only mode (only query tools, nothing can be changed)?", cfg.ReadOnly); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.TrueNASURL == "" || cfg.APIKey == "" {
+		return fmt.Errorf("both a TrueNAS URL and an API key are required (--truenas-url and --api-key)")
+	}
+
+	// Check the connection the way the server will make it
+	fmt.Fprintf(stdout, "\nChecking the connection to %s...\n", cfg.TrueNASURL)
+	checks := diagnoseConnection(cfg.TrueNASURL, cfg.APIKey, &tls.Config{InsecureSkipVerify: true})
+	passed := true
+	for _, check := range checks {
+		status := "ok  "
+		if !check.OK {
+			status = "FAIL"
+			passed = false
+		}
+		fmt.Fprintf(stdout, "  [%s] %s: %s\n", status, check.Check, check.Detail)
+		if check.Hint != "" {
+			fmt.Fprintf(stdout, "         %s\n", check.Hint)
+		}
+	}
+	if !passed {
+		if *nonInteractive {
+			return fmt.Errorf("connection check failed; the config file was not written")
+		}
+		save, err := p.confirm("The connection check failed. Save the configuration anyway?", false)
+		if err != nil {
+			return err
+		}
+		if !save {
+			return fmt.Errorf("connection check failed; the config file was not written")
+		}
+	}
+
+	if existing != nil && !reflect.DeepEqual(*existing, *cfg) && !*nonInteractive {
+		overwrite, err := p.confirm(fmt.Sprintf("Replace the existing config file %s?", path), true)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return fmt.Errorf("setup cancelled; %s was not changed", path)
+		}
+	}
+	if err := saveConfigFile(path, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "\nSaved the configuration to %s (readable only by you).\n", path)
+
+	// Clients launch the binary itself; the config file supplies the connection
+	command, err := os.Executable()
+	if err != nil {
+		command = "truenas-mcp"
+	} else if resolved, err := filepath.EvalSymlinks(command); err == nil {
+		command = resolved
+	}
+	var serverArgs []string
+	if customPath {
+		serverArgs = []string{"--config", path}
+	}
+	for _, c := range clients {
+		fmt.Fprintf(stdout, "\n%s - %s:\n\n%s\n", c.Label, c.Location, c.Render(command, serverArgs))
+	}
+	fmt.Fprintln(stdout, "\nThe API key stays in the config file, so it is not part of the client configuration.")
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestSetupNonInteractive(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas1", "version": "TrueNAS-25.04.1"})
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	var out strings.Builder
+	err := runSetup([]string{
+		"--non-interactive", "--truenas-url", server.URL(), "--api-key", truenastest.DefaultAPIKey,
+		"--config", path, "--client", "claude-desktop",
+	}, strings.NewReader(""), &out)
+	if err != nil {
+		t.Fatalf("setup failed: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{"[ok  ] Authentication", "nas1 running TrueNAS-25.04.1", `"mcpServers"`, `"--config"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("setup output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Cursor") {
+		t.Errorf("--client claude-desktop printed other clients:\n%s", out.String())
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("config file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("config file mode = %v, want 0600", info.Mode().Perm())
+	}
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TrueNASURL != server.URL() || cfg.APIKey != truenastest.DefaultAPIKey {
+		t.Errorf("saved config = %+v", cfg)
+	}
+}
+
+func TestSetupRejectsBadKey(t *testing.T) {
+	server := truenastest.NewServer(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	var out strings.Builder
+	err := runSetup([]string{
+		"--non-interactive", "--truenas-url", server.URL(), "--api-key", "wrong", "--config", path,
+	}, strings.NewReader(""), &out)
+	if err == nil {
+		t.Fatalf("setup with a bad key succeeded:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[FAIL] Authentication") || !strings.Contains(out.String(), "API Keys") {
+		t.Errorf("missing authentication failure and hint:\n%s", out.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("config file written despite the failed check")
+	}
+}
+
+func TestSetupInteractive(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas1", "version": "TrueNAS-25.04.1"})
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	// URL, API key, read-only
+	input := server.URL() + "\n" + truenastest.DefaultAPIKey + "\ny\n"
+	var out strings.Builder
+	if err := runSetup([]string{"--config", path, "--client", "vscode"}, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("setup failed: %v\n%s", err, out.String())
+	}
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.ReadOnly || cfg.APIKey != truenastest.DefaultAPIKey {
+		t.Errorf("saved config = %+v", cfg)
+	}
+	start := strings.Index(out.String(), "{")
+	end := strings.LastIndex(out.String(), "}")
+	var vscode map[string]map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()[start:end+1]), &vscode); err != nil {
+		t.Fatalf("VS Code config is not JSON: %v\n%s", err, out.String())
+	}
+	if vscode["servers"]["truenas"]["type"] != "stdio" {
+		t.Errorf("unexpected VS Code config: %v", vscode)
+	}
+}
+
+func TestEndpointAddress(t *testing.T) {
+	tests := []struct {
+		endpoint   string
+		host, port string
+		wantErr    bool
+	}{
+		{endpoint: "truenas.local", host: "truenas.local", port: "443"},
+		{endpoint: "192.168.0.31:8443", host: "192.168.0.31", port: "443"},
+		{endpoint: "wss://nas:8443/websocket", host: "nas", port: "8443"},
+		{endpoint: "ws://nas/websocket", wantErr: true},
+		{endpoint: "https://nas", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, port, err := endpointAddress(tt.endpoint)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("endpointAddress(%q) error = %v, wantErr %v", tt.endpoint, err, tt.wantErr)
+			continue
+		}
+		if host != tt.host || port != tt.port {
+			t.Errorf("endpointAddress(%q) = %s, %s; want %s, %s", tt.endpoint, host, port, tt.host, tt.port)
+		}
+	}
+}
+
+func TestValidateSystems(t *testing.T) {
+	valid := []systemConfig{{Name: "nas2", URL: "nas2.local", APIKey: "1-key"}, {Name: "nas3", URL: "nas3.local", APIKey: "2-key"}}
+	if err := validateSystems("default", valid); err != nil {
+		t.Errorf("valid systems rejected: %v", err)
+	}
+	for _, systems := range [][]systemConfig{
+		{{URL: "nas2.local", APIKey: "1-key"}},
+		{{Name: "default", URL: "nas2.local", APIKey: "1-key"}},
+		{valid[0], valid[0]},
+		{{Name: "nas2", URL: "nas2.local"}},
+	} {
+		if err := validateSystems("default", systems); err == nil {
+			t.Errorf("systems %+v accepted", systems)
+		}
+	}
+}
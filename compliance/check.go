@@ -0,0 +1,398 @@
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+const (
+	// defaultScrubThreshold matches the TrueNAS default days between scrubs
+	defaultScrubThreshold = 35
+
+	hoursPerDay = 24
+)
+
+// Check validates the live system against a baseline. Rules whose data cannot
+// be read are reported in the collection notes and make the result
+// non-compliant, since they could not be verified.
+func Check(client truenas.Caller, baseline *Baseline) *Result {
+	result := &Result{
+		CheckedAt:  time.Now().UTC(),
+		Violations: []Violation{},
+	}
+
+	// Each middleware query runs at most once, however many rules need it
+	cache := map[string][]map[string]interface{}{}
+	failed := map[string]error{}
+	query := func(method string, params ...interface{}) ([]map[string]interface{}, error) {
+		if err, ok := failed[method]; ok {
+			return nil, err
+		}
+		if records, ok := cache[method]; ok {
+			return records, nil
+		}
+		records, err := queryRecords(client, method, params...)
+		if err != nil {
+			failed[method] = err
+			result.CollectionNotes = append(result.CollectionNotes, fmt.Sprintf("%s unavailable: %v", method, err))
+			return nil, err
+		}
+		cache[method] = records
+		return records, nil
+	}
+
+	for i, rule := range baseline.Rules {
+		index := i + 1
+		var violations []Violation
+
+		switch rule.Type {
+		case RuleScrubSchedule:
+			pools, err := query("pool.query")
+			if err != nil {
+				continue
+			}
+			tasks, err := query("pool.scrub.query")
+			if err != nil {
+				continue
+			}
+			violations = checkScrub(index, rule, recordNames(pools, "name"), tasks)
+
+		case RuleSMARTTests:
+			disks, err := query("disk.query")
+			if err != nil {
+				continue
+			}
+			tasks, err := query("smart.test.query")
+			if err != nil {
+				continue
+			}
+			violations = checkSMART(index, rule, disks, tasks)
+
+		case RuleSnapshotTasks:
+			datasets, err := query("pool.dataset.query", []interface{}{}, map[string]interface{}{
+				"select": []string{"id"},
+				"extra":  map[string]interface{}{"flat": true, "retrieve_children": false},
+			})
+			if err != nil {
+				continue
+			}
+			tasks, err := query("pool.snapshottask.query")
+			if err != nil {
+				continue
+			}
+			violations = checkSnapshots(index, rule, recordNames(datasets, "id"), tasks)
+
+		default:
+			result.CollectionNotes = append(result.CollectionNotes, fmt.Sprintf("rule %d: unknown rule type %s", index, rule.Type))
+			continue
+		}
+
+		result.RulesChecked++
+		result.Violations = append(result.Violations, violations...)
+	}
+
+	result.Compliant = len(result.Violations) == 0 && result.RulesChecked == len(baseline.Rules)
+	return result
+}
+
+// queryRecords calls a query method and decodes its records
+func queryRecords(client truenas.Caller, method string, params ...interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(result, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", method, err)
+	}
+	return records, nil
+}
+
+// recordNames collects a string field of every record, sorted
+func recordNames(records []map[string]interface{}, field string) []string {
+	names := make([]string, 0, len(records))
+	for _, record := range records {
+		if name, ok := record[field].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatDays renders a number of days without spurious precision
+func formatDays(days float64) string {
+	if days == math.Trunc(days) {
+		return fmt.Sprintf("%.0f days", days)
+	}
+	return fmt.Sprintf("%.1f days", days)
+}
+
+// scrubIntervalDays is the longest gap between scrubs of a task: it fires on
+// its cron schedule but skips runs until threshold days have passed
+func scrubIntervalDays(task map[string]interface{}) float64 {
+	schedule, _ := task["schedule"].(map[string]interface{})
+	cronDays := IntervalHours(schedule) / hoursPerDay
+	threshold, _ := task["threshold"].(float64)
+	if threshold <= cronDays {
+		return cronDays
+	}
+	return math.Ceil(threshold/cronDays) * cronDays
+}
+
+// scrubRemediationSchedule picks a cron schedule and threshold that scrub at
+// least every maxDays (0 = the TrueNAS defaults: weekly checks, 35-day threshold)
+func scrubRemediationSchedule(maxDays float64) (map[string]interface{}, int) {
+	weekly := map[string]interface{}{"minute": "0", "hour": "2", "dom": "*", "month": "*", "dow": "0"}
+	if maxDays <= 0 {
+		return weekly, defaultScrubThreshold
+	}
+	if maxDays >= 7 {
+		threshold := int(math.Floor(maxDays/7) * 7)
+		if threshold > defaultScrubThreshold {
+			threshold = defaultScrubThreshold
+		}
+		return weekly, threshold
+	}
+	daily := map[string]interface{}{"minute": "0", "hour": "2", "dom": "*", "month": "*", "dow": "*"}
+	return daily, int(math.Floor(maxDays))
+}
+
+// checkScrub requires an enabled scrub schedule, frequent enough, on every pool
+func checkScrub(index int, rule Rule, pools []string, tasks []map[string]interface{}) []Violation {
+	byPool := map[string]map[string]interface{}{}
+	for _, task := range tasks {
+		if name, ok := task["pool_name"].(string); ok {
+			byPool[name] = task
+		}
+	}
+
+	exists := map[string]bool{}
+	for _, pool := range pools {
+		exists[pool] = true
+	}
+	targets := pools
+	if len(rule.Pools) > 0 {
+		targets = rule.Pools
+	}
+
+	schedule, threshold := scrubRemediationSchedule(rule.MaxIntervalDays)
+	violations := []Violation{}
+	for _, pool := range targets {
+		violation := Violation{Rule: index, Type: rule.Type, Object: pool}
+		task, hasTask := byPool[pool]
+		enabled, _ := task["enabled"].(bool)
+
+		switch {
+		case !exists[pool]:
+			violation.Problem = "pool named in the baseline does not exist"
+			violation.Remediation = &Remediation{Manual: "Import or create the pool, or remove it from the baseline"}
+		case !hasTask:
+			violation.Problem = "no scrub schedule"
+			violation.Remediation = &Remediation{
+				Tool: "create_scrub_schedule",
+				Arguments: map[string]interface{}{
+					"pool":      pool,
+					"schedule":  schedule,
+					"threshold": float64(threshold),
+				},
+			}
+		case !enabled:
+			violation.Problem = "scrub schedule is disabled"
+			violation.Remediation = &Remediation{
+				Tool:      "update_scrub_schedule",
+				Arguments: map[string]interface{}{"id": task["id"], "enabled": true},
+			}
+		case rule.MaxIntervalDays > 0 && scrubIntervalDays(task) > rule.MaxIntervalDays:
+			violation.Problem = fmt.Sprintf("scrubs run up to %s apart; baseline requires at most %s", formatDays(scrubIntervalDays(task)), formatDays(rule.MaxIntervalDays))
+			violation.Remediation = &Remediation{
+				Tool: "update_scrub_schedule",
+				Arguments: map[string]interface{}{
+					"id":        task["id"],
+					"schedule":  schedule,
+					"threshold": float64(threshold),
+				},
+			}
+		default:
+			continue
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// checkSMART requires a periodic SMART test of the required type and frequency
+// on every disk
+func checkSMART(index int, rule Rule, disks []map[string]interface{}, tasks []map[string]interface{}) []Violation {
+	testType := strings.ToUpper(rule.TestType)
+	typeLabel := "SMART"
+	if testType != "" {
+		typeLabel = testType + " SMART"
+	}
+
+	violations := []Violation{}
+	for _, disk := range disks {
+		name, _ := disk["name"].(string)
+		identifier, _ := disk["identifier"].(string)
+
+		covered, anyTest := false, false
+		for _, task := range tasks {
+			allDisks, _ := task["all_disks"].(bool)
+			if !allDisks && !listContains(task["disks"], identifier) && !listContains(task["disks"], name) {
+				continue
+			}
+			anyTest = true
+			if taskType, _ := task["type"].(string); testType != "" && !strings.EqualFold(taskType, testType) {
+				continue
+			}
+			schedule, _ := task["schedule"].(map[string]interface{})
+			if rule.MaxIntervalDays > 0 && IntervalHours(schedule)/hoursPerDay > rule.MaxIntervalDays {
+				continue
+			}
+			covered = true
+			break
+		}
+		if covered {
+			continue
+		}
+
+		problem := fmt.Sprintf("no periodic %s test", typeLabel)
+		if anyTest {
+			problem = fmt.Sprintf("SMART tests are scheduled but none is a %s test", typeLabel)
+			if rule.MaxIntervalDays > 0 {
+				problem = fmt.Sprintf("SMART tests are scheduled but none is a %s test running at least every %s", typeLabel, formatDays(rule.MaxIntervalDays))
+			}
+		}
+		manual := fmt.Sprintf("Create a periodic %s test covering %s in Data Protection > Periodic S.M.A.R.T. Tests (middleware method smart.test.create)", typeLabel, name)
+		if rule.MaxIntervalDays > 0 {
+			manual += fmt.Sprintf(" that runs at least every %s", formatDays(rule.MaxIntervalDays))
+		}
+
+		violations = append(violations, Violation{
+			Rule:        index,
+			Type:        rule.Type,
+			Object:      name,
+			Problem:     problem,
+			Remediation: &Remediation{Manual: manual},
+		})
+	}
+	return violations
+}
+
+// checkSnapshots requires an enabled periodic snapshot task, frequent enough,
+// covering the rule's dataset and every dataset below it. Recursive tasks cover
+// descendants that they do not exclude.
+func checkSnapshots(index int, rule Rule, datasets []string, tasks []map[string]interface{}) []Violation {
+	root := strings.TrimSuffix(rule.Dataset, "/")
+	targets := []string{}
+	for _, dataset := range datasets {
+		if dataset == root || strings.HasPrefix(dataset, root+"/") {
+			targets = append(targets, dataset)
+		}
+	}
+	if len(targets) == 0 {
+		return []Violation{{
+			Rule:        index,
+			Type:        rule.Type,
+			Object:      root,
+			Problem:     "dataset named in the baseline does not exist",
+			Remediation: &Remediation{Manual: "Create the dataset with create_dataset, or correct the baseline"},
+		}}
+	}
+
+	manual := fmt.Sprintf("Create a recursive periodic snapshot task on %s in Data Protection > Periodic Snapshot Tasks (middleware method pool.snapshottask.create)", root)
+	if rule.MaxIntervalDays > 0 {
+		manual += fmt.Sprintf(" that runs at least every %s", formatDays(rule.MaxIntervalDays))
+	}
+
+	violations := []Violation{}
+	for _, dataset := range targets {
+		covered := false
+		disabled := []string{}
+		tooSlow := 0.0
+		for _, task := range tasks {
+			if !snapshotTaskCovers(task, dataset) {
+				continue
+			}
+			if enabled, _ := task["enabled"].(bool); !enabled {
+				disabled = append(disabled, fmt.Sprintf("%v", task["id"]))
+				continue
+			}
+			schedule, _ := task["schedule"].(map[string]interface{})
+			if days := IntervalHours(schedule) / hoursPerDay; rule.MaxIntervalDays > 0 && days > rule.MaxIntervalDays {
+				if tooSlow == 0 || days < tooSlow {
+					tooSlow = days
+				}
+				continue
+			}
+			covered = true
+			break
+		}
+		if covered {
+			continue
+		}
+
+		violation := Violation{Rule: index, Type: rule.Type, Object: dataset}
+		switch {
+		case tooSlow > 0:
+			violation.Problem = fmt.Sprintf("snapshots are taken only every %s; baseline requires at least every %s", formatDays(tooSlow), formatDays(rule.MaxIntervalDays))
+			violation.Remediation = &Remediation{Manual: manual}
+		case len(disabled) > 0:
+			violation.Problem = fmt.Sprintf("only disabled snapshot tasks cover this dataset (task %s)", strings.Join(disabled, ", "))
+			violation.Remediation = &Remediation{Manual: fmt.Sprintf("Enable periodic snapshot task %s (middleware method pool.snapshottask.update)", strings.Join(disabled, ", "))}
+		default:
+			violation.Problem = "no periodic snapshot task covers this dataset"
+			violation.Remediation = &Remediation{Manual: manual}
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// snapshotTaskCovers reports whether a periodic snapshot task snapshots dataset
+func snapshotTaskCovers(task map[string]interface{}, dataset string) bool {
+	taskDataset, _ := task["dataset"].(string)
+	if taskDataset == dataset {
+		return true
+	}
+	if recursive, _ := task["recursive"].(bool); !recursive || !strings.HasPrefix(dataset, taskDataset+"/") {
+		return false
+	}
+	for _, excluded := range listStrings(task["exclude"]) {
+		if dataset == excluded || strings.HasPrefix(dataset, excluded+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// listStrings returns the strings of a decoded JSON array
+func listStrings(raw interface{}) []string {
+	items, _ := raw.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// listContains reports whether a decoded JSON array contains value
+func listContains(raw interface{}, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range listStrings(raw) {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
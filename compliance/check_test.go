@@ -0,0 +1,129 @@
+package compliance
+
+import (
+	"testing"
+)
+
+func TestIntervalHours(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule map[string]interface{}
+		want     float64
+	}{
+		{"hourly", map[string]interface{}{"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*"}, 1},
+		{"every 15 minutes", map[string]interface{}{"minute": "*/15", "hour": "*", "dom": "*", "month": "*", "dow": "*"}, 0.25},
+		{"daily", map[string]interface{}{"minute": "0", "hour": "2", "dom": "*", "month": "*", "dow": "*"}, 24},
+		{"twice daily", map[string]interface{}{"minute": "0", "hour": "0,12", "dom": "*", "month": "*", "dow": "*"}, 12},
+		{"weekly", map[string]interface{}{"minute": "0", "hour": "2", "dom": "*", "month": "*", "dow": "0"}, 168},
+		{"weekdays", map[string]interface{}{"minute": "0", "hour": "2", "dom": "*", "month": "*", "dow": "1-5"}, 24 * 7 / 5.0},
+		{"monthly", map[string]interface{}{"minute": "0", "hour": "2", "dom": "1", "month": "*", "dow": "*"}, 24 * 31},
+		{"quarterly", map[string]interface{}{"minute": "0", "hour": "2", "dom": "1", "month": "*/3", "dow": "*"}, 24 * 366 / 4.0},
+		{"SMART schedule without minute", map[string]interface{}{"hour": "3", "dom": "*", "month": "*", "dow": "6"}, 168},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IntervalHours(tt.schedule); got != tt.want {
+				t.Errorf("IntervalHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func weeklySchedule() map[string]interface{} {
+	return map[string]interface{}{"minute": "0", "hour": "2", "dom": "*", "month": "*", "dow": "0"}
+}
+
+func TestCheckScrub(t *testing.T) {
+	tasks := []map[string]interface{}{
+		{"id": float64(1), "pool_name": "tank", "enabled": true, "threshold": float64(35), "schedule": weeklySchedule()},
+		{"id": float64(2), "pool_name": "flash", "enabled": false, "threshold": float64(35), "schedule": weeklySchedule()},
+	}
+	pools := []string{"backup", "flash", "tank"}
+
+	violations := checkScrub(1, Rule{Type: RuleScrubSchedule}, pools, tasks)
+	if len(violations) != 2 {
+		t.Fatalf("checkScrub() = %+v, want backup and flash", violations)
+	}
+	if v := violations[0]; v.Object != "backup" || v.Remediation.Tool != "create_scrub_schedule" {
+		t.Errorf("missing schedule violation = %+v", v)
+	}
+	if v := violations[1]; v.Object != "flash" || v.Remediation.Tool != "update_scrub_schedule" || v.Remediation.Arguments["enabled"] != true {
+		t.Errorf("disabled schedule violation = %+v", v)
+	}
+
+	violations = checkScrub(1, Rule{Type: RuleScrubSchedule, Pools: []string{"tank"}, MaxIntervalDays: 14}, pools, tasks)
+	if len(violations) != 1 || violations[0].Remediation.Arguments["threshold"] != float64(14) {
+		t.Errorf("interval violation = %+v, want tank with threshold 14", violations)
+	}
+}
+
+func TestCheckSMART(t *testing.T) {
+	disks := []map[string]interface{}{
+		{"name": "sda", "identifier": "{serial}A"},
+		{"name": "sdb", "identifier": "{serial}B"},
+		{"name": "sdc", "identifier": "{serial}C"},
+	}
+	tasks := []map[string]interface{}{
+		{"all_disks": false, "disks": []interface{}{"{serial}A"}, "type": "LONG", "schedule": map[string]interface{}{"hour": "3", "dom": "*", "month": "*", "dow": "6"}},
+		{"all_disks": false, "disks": []interface{}{"{serial}B"}, "type": "SHORT", "schedule": map[string]interface{}{"hour": "3", "dom": "*", "month": "*", "dow": "6"}},
+	}
+
+	violations := checkSMART(1, Rule{Type: RuleSMARTTests, TestType: "long", MaxIntervalDays: 7}, disks, tasks)
+	if len(violations) != 2 || violations[0].Object != "sdb" || violations[1].Object != "sdc" {
+		t.Fatalf("checkSMART() = %+v, want sdb and sdc", violations)
+	}
+	if violations[1].Remediation.Manual == "" {
+		t.Errorf("SMART violation has no remediation")
+	}
+
+	if violations := checkSMART(1, Rule{Type: RuleSMARTTests}, disks, append(tasks, map[string]interface{}{"all_disks": true, "type": "SHORT"})); len(violations) != 0 {
+		t.Errorf("all-disk task should cover every disk, got %+v", violations)
+	}
+}
+
+func TestCheckSnapshots(t *testing.T) {
+	datasets := []string{"tank", "tank/critical", "tank/critical/db", "tank/critical/scratch", "tank/criticality", "tank/media"}
+	tasks := []map[string]interface{}{
+		{"id": float64(1), "dataset": "tank/critical", "recursive": true, "enabled": true, "exclude": []interface{}{"tank/critical/scratch"}, "schedule": map[string]interface{}{"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*"}},
+	}
+
+	violations := checkSnapshots(1, Rule{Type: RuleSnapshotTasks, Dataset: "tank/critical"}, datasets, tasks)
+	if len(violations) != 1 || violations[0].Object != "tank/critical/scratch" {
+		t.Errorf("checkSnapshots() = %+v, want only the excluded child", violations)
+	}
+
+	violations = checkSnapshots(1, Rule{Type: RuleSnapshotTasks, Dataset: "tank/media"}, datasets, tasks)
+	if len(violations) != 1 || violations[0].Problem != "no periodic snapshot task covers this dataset" {
+		t.Errorf("uncovered dataset violations = %+v", violations)
+	}
+
+	violations = checkSnapshots(1, Rule{Type: RuleSnapshotTasks, Dataset: "tank/missing"}, datasets, tasks)
+	if len(violations) != 1 || violations[0].Object != "tank/missing" {
+		t.Errorf("missing dataset violations = %+v", violations)
+	}
+}
+
+func TestBaselineValidate(t *testing.T) {
+	valid := &Baseline{Rules: []Rule{
+		{Type: RuleScrubSchedule},
+		{Type: RuleSMARTTests, TestType: "long", MaxIntervalDays: 7},
+		{Type: RuleSnapshotTasks, Dataset: "tank/critical"},
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	invalid := []*Baseline{
+		{},
+		{Rules: []Rule{{Type: "firewall"}}},
+		{Rules: []Rule{{Type: RuleSnapshotTasks}}},
+		{Rules: []Rule{{Type: RuleSMARTTests, TestType: "quick"}}},
+		{Rules: []Rule{{Type: RuleScrubSchedule, MaxIntervalDays: -1}}},
+	}
+	for _, b := range invalid {
+		if err := b.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", b.Rules)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package compliance
+
+import (
+	"strconv"
+	"strings"
+)
+
+// cronFieldSizes is the number of possible values of each cron field
+var cronFieldSizes = map[string]int{
+	"minute": 60,
+	"hour":   24,
+	"dom":    31,
+	"month":  12,
+	"dow":    7,
+}
+
+// cronFieldCount estimates how many values a cron field matches ("*", "*/N",
+// "a-b", "a-b/N", and comma lists of those)
+func cronFieldCount(field string, value string) int {
+	size := cronFieldSizes[field]
+	if value == "" || value == "*" {
+		return size
+	}
+
+	count := 0
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			if n, err := strconv.Atoi(part[idx+1:]); err == nil && n > 0 {
+				step = n
+			}
+			part = part[:idx]
+		}
+
+		span := 1
+		switch {
+		case part == "*":
+			span = size
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			lo, errLo := strconv.Atoi(bounds[0])
+			hi, errHi := strconv.Atoi(bounds[1])
+			if errLo == nil && errHi == nil && hi >= lo {
+				span = hi - lo + 1
+			}
+		}
+		count += (span + step - 1) / step
+	}
+
+	if count < 1 {
+		return 1
+	}
+	if count > size {
+		return size
+	}
+	return count
+}
+
+// IntervalHours estimates the longest gap between runs of a cron schedule,
+// assuming matches are spread evenly across the most restrictive field. Fields
+// missing from the schedule match everything, except minute which defaults to
+// once an hour as in TrueNAS task schedules.
+func IntervalHours(schedule map[string]interface{}) float64 {
+	field := func(name string) string {
+		value, _ := schedule[name].(string)
+		return value
+	}
+
+	if month := field("month"); month != "" && month != "*" {
+		return 24 * 366 / float64(cronFieldCount("month", month))
+	}
+	dom, dow := field("dom"), field("dow")
+	if dom != "" && dom != "*" && (dow == "" || dow == "*") {
+		return 24 * 31 / float64(cronFieldCount("dom", dom))
+	}
+	if dow != "" && dow != "*" {
+		return 24 * 7 / float64(cronFieldCount("dow", dow))
+	}
+	if hour := field("hour"); hour != "" && hour != "*" {
+		return 24 / float64(cronFieldCount("hour", hour))
+	}
+	if minute := field("minute"); minute != "" && minute != "*" && strings.ContainsAny(minute, ",/-") {
+		return 1 / float64(cronFieldCount("minute", minute))
+	}
+	return 1
+}
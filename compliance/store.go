@@ -0,0 +1,79 @@
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// Store holds the saved baseline, optionally persisted to a JSON file so it
+// survives restarts
+type Store struct {
+	mu       sync.RWMutex
+	baseline *Baseline
+	path     string
+}
+
+// NewStore creates a new baseline store. If path is non-empty, an existing
+// baseline is loaded from it.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read compliance baseline: %w", err)
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse compliance baseline %s: %w", path, err)
+	}
+	s.baseline = &baseline
+
+	return s, nil
+}
+
+// Baseline returns the saved baseline, or nil if none is saved
+func (s *Store) Baseline() *Baseline {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.baseline
+}
+
+// Set replaces the saved baseline and writes it to disk (disk write is a no-op
+// for in-memory stores)
+func (s *Store) Set(baseline *Baseline) error {
+	s.mu.Lock()
+	s.baseline = baseline
+	s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal compliance baseline: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create baseline directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write compliance baseline: %w", err)
+	}
+
+	return nil
+}
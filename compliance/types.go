@@ -0,0 +1,99 @@
+package compliance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule types a baseline can require
+const (
+	RuleScrubSchedule = "scrub_schedule" // Every pool (or the listed pools) is scrubbed on a schedule
+	RuleSMARTTests    = "smart_tests"    // Every disk has a periodic SMART test
+	RuleSnapshotTasks = "snapshot_tasks" // Every dataset under a path has a periodic snapshot task
+)
+
+// smartTestTypes are the SMART test types a rule may require
+var smartTestTypes = map[string]bool{
+	"SHORT":      true,
+	"LONG":       true,
+	"CONVEYANCE": true,
+	"OFFLINE":    true,
+}
+
+// Rule is one desired-state requirement
+type Rule struct {
+	Type            string   `json:"type"`
+	Pools           []string `json:"pools,omitempty"`             // scrub_schedule: pools to check (empty = all)
+	Dataset         string   `json:"dataset,omitempty"`           // snapshot_tasks: dataset whose tree must be covered
+	TestType        string   `json:"test_type,omitempty"`         // smart_tests: required test type (empty = any)
+	MaxIntervalDays float64  `json:"max_interval_days,omitempty"` // Longest allowed gap between runs (0 = any schedule)
+}
+
+// Validate checks that the rule is well formed
+func (r Rule) Validate() error {
+	if r.MaxIntervalDays < 0 {
+		return fmt.Errorf("max_interval_days must not be negative")
+	}
+
+	switch r.Type {
+	case RuleScrubSchedule:
+	case RuleSMARTTests:
+		if r.TestType != "" && !smartTestTypes[strings.ToUpper(r.TestType)] {
+			return fmt.Errorf("test_type must be SHORT, LONG, CONVEYANCE, or OFFLINE, got: %s", r.TestType)
+		}
+	case RuleSnapshotTasks:
+		if r.Dataset == "" {
+			return fmt.Errorf("dataset is required for %s rules", RuleSnapshotTasks)
+		}
+	default:
+		return fmt.Errorf("unknown rule type: %s (must be %s, %s, or %s)", r.Type, RuleScrubSchedule, RuleSMARTTests, RuleSnapshotTasks)
+	}
+	return nil
+}
+
+// Baseline is the saved desired state of the system
+type Baseline struct {
+	Description string    `json:"description,omitempty"`
+	SavedAt     time.Time `json:"saved_at"`
+	Rules       []Rule    `json:"rules"`
+}
+
+// Validate checks every rule of the baseline
+func (b *Baseline) Validate() error {
+	if len(b.Rules) == 0 {
+		return fmt.Errorf("a baseline needs at least one rule")
+	}
+	for i, rule := range b.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Remediation is the fix for a violation: a tool call when an MCP tool can
+// make the change, otherwise manual instructions
+type Remediation struct {
+	Tool      string                 `json:"tool,omitempty"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Manual    string                 `json:"manual,omitempty"`
+}
+
+// Violation is a place where the live system differs from the baseline
+type Violation struct {
+	Rule        int          `json:"rule"` // 1-based index into the baseline's rules
+	Type        string       `json:"type"`
+	Object      string       `json:"object"`
+	Problem     string       `json:"problem"`
+	Remediation *Remediation `json:"remediation,omitempty"`
+}
+
+// Result is the outcome of checking the live system against a baseline
+type Result struct {
+	CheckedAt       time.Time   `json:"checked_at"`
+	Compliant       bool        `json:"compliant"`
+	RulesChecked    int         `json:"rules_checked"`
+	Violations      []Violation `json:"violations"`
+	CollectionNotes []string    `json:"collection_notes,omitempty"`
+}
@@ -0,0 +1,311 @@
+package deletion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Status is the lifecycle state of a deferred deletion
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusExecuting Status = "executing"
+	StatusExecuted  Status = "executed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Config configures deferred deletion
+type Config struct {
+	GracePeriod   time.Duration // Delay before a deletion executes (0 = delete immediately)
+	CheckInterval time.Duration // How often due deletions are executed
+	Retention     time.Duration // How long finished deletions stay listed
+	Path          string        // JSON file used to persist the queue ("" = in-memory only)
+}
+
+// Deletion is a delete operation waiting out its grace period. Method and
+// Params are the middleware call that performs it.
+type Deletion struct {
+	ID            string        `json:"id"`
+	Tool          string        `json:"tool"`
+	Target        string        `json:"target"`
+	Method        string        `json:"method"`
+	Params        []interface{} `json:"params"`
+	Status        Status        `json:"status"`
+	RequestedAt   time.Time     `json:"requested_at"`
+	ExecuteAt     time.Time     `json:"execute_at"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	CorrelationID string        `json:"correlation_id,omitempty"`
+	System        string        `json:"system,omitempty"` // TrueNAS system to delete on ("" = the default system)
+}
+
+// Queue holds deferred deletions and executes them once their grace period
+// has passed. The queue is persisted so pending deletions survive restarts.
+type Queue struct {
+	client *truenas.Client
+	config Config
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	deletions map[string]*Deletion
+	systems   map[string]*truenas.Client // Clients of systems other than the default
+}
+
+// NewQueue creates a new deletion queue, loading any persisted deletions
+func NewQueue(client *truenas.Client, config Config) (*Queue, error) {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		client:    client,
+		config:    config,
+		ctx:       ctx,
+		cancel:    cancel,
+		deletions: make(map[string]*Deletion),
+		systems:   make(map[string]*truenas.Client),
+	}
+
+	if config.Path == "" {
+		return q, nil
+	}
+
+	data, err := os.ReadFile(config.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		cancel()
+		return nil, fmt.Errorf("failed to read pending deletions: %w", err)
+	}
+
+	var deletions []*Deletion
+	if err := json.Unmarshal(data, &deletions); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to parse pending deletions %s: %w", config.Path, err)
+	}
+	for _, d := range deletions {
+		// A deletion interrupted by a restart is retried
+		if d.Status == StatusExecuting {
+			d.Status = StatusPending
+		}
+		q.deletions[d.ID] = d
+	}
+
+	return q, nil
+}
+
+// Start begins executing due deletions. Deletions left pending while the
+// server was stopped execute on the first check if their time has passed.
+func (q *Queue) Start() {
+	go q.run()
+}
+
+// Shutdown stops executing deletions; pending ones stay queued on disk
+func (q *Queue) Shutdown() {
+	q.cancel()
+}
+
+// AddSystem registers a TrueNAS system other than the default one, so
+// deletions scheduled on it execute through its client
+func (q *Queue) AddSystem(name string, client *truenas.Client) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.systems[name] = client
+}
+
+// Enabled reports whether deletions are deferred
+func (q *Queue) Enabled() bool {
+	return q.config.GracePeriod > 0
+}
+
+// GracePeriod returns the configured delay before deletions execute
+func (q *Queue) GracePeriod() time.Duration {
+	return q.config.GracePeriod
+}
+
+// Schedule queues a deletion of target on system ("" = the default system)
+// to run after the grace period. Only one deletion per target can be pending.
+func (q *Queue) Schedule(system, tool, target, method string, params []interface{}, correlationID string) (*Deletion, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, existing := range q.deletions {
+		active := existing.Status == StatusPending || existing.Status == StatusExecuting
+		if active && existing.System == system && existing.Tool == tool && existing.Target == target {
+			return nil, fmt.Errorf("%s is already scheduled for deletion (id: %s, executes at %s)",
+				target, existing.ID, existing.ExecuteAt.Format(time.RFC3339))
+		}
+	}
+
+	now := time.Now().UTC()
+	d := &Deletion{
+		ID:            uuid.New().String()[:8],
+		Tool:          tool,
+		Target:        target,
+		Method:        method,
+		Params:        params,
+		Status:        StatusPending,
+		RequestedAt:   now,
+		ExecuteAt:     now.Add(q.config.GracePeriod),
+		CorrelationID: correlationID,
+		System:        system,
+	}
+	q.deletions[d.ID] = d
+
+	if err := q.saveLocked(); err != nil {
+		delete(q.deletions, d.ID)
+		return nil, err
+	}
+	copied := *d
+	return &copied, nil
+}
+
+// Cancel withdraws a pending deletion
+func (q *Queue) Cancel(id string) (*Deletion, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	d, ok := q.deletions[id]
+	if !ok {
+		return nil, fmt.Errorf("pending deletion %s not found", id)
+	}
+	if d.Status != StatusPending {
+		return nil, fmt.Errorf("deletion %s can no longer be undone: it is %s", id, d.Status)
+	}
+
+	now := time.Now().UTC()
+	d.Status = StatusCancelled
+	d.FinishedAt = &now
+	if err := q.saveLocked(); err != nil {
+		return nil, err
+	}
+	copied := *d
+	return &copied, nil
+}
+
+// List returns all known deletions ordered by execution time
+func (q *Queue) List() []Deletion {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deletions := make([]Deletion, 0, len(q.deletions))
+	for _, d := range q.deletions {
+		deletions = append(deletions, *d)
+	}
+	sort.Slice(deletions, func(i, j int) bool {
+		return deletions[i].ExecuteAt.Before(deletions[j].ExecuteAt)
+	})
+	return deletions
+}
+
+// run is the main execution loop
+func (q *Queue) run() {
+	q.ExecuteDue(time.Now())
+
+	ticker := time.NewTicker(q.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.ExecuteDue(now)
+		}
+	}
+}
+
+// ExecuteDue executes every pending deletion whose grace period has passed at
+// now and drops finished deletions older than the retention period
+func (q *Queue) ExecuteDue(now time.Time) {
+	q.mu.Lock()
+	due := []*Deletion{}
+	clients := map[*Deletion]*truenas.Client{}
+	for id, d := range q.deletions {
+		switch {
+		case d.Status == StatusPending && !now.Before(d.ExecuteAt):
+			// Marked before the lock is released so it can no longer be undone
+			d.Status = StatusExecuting
+			due = append(due, d)
+			clients[d] = q.client
+			if d.System != "" {
+				clients[d] = q.systems[d.System]
+			}
+		case d.FinishedAt != nil && q.config.Retention > 0 && now.Sub(*d.FinishedAt) > q.config.Retention:
+			delete(q.deletions, id)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, d := range due {
+		// Calls run without the lock so a slow delete does not block undo of others
+		var err error
+		if client := clients[d]; client == nil {
+			err = fmt.Errorf("system %s is not configured", d.System)
+		} else {
+			_, err = client.WithCorrelationID(d.CorrelationID).Call(d.Method, d.Params...)
+		}
+
+		q.mu.Lock()
+		finished := time.Now().UTC()
+		d.FinishedAt = &finished
+		if err != nil {
+			d.Status = StatusFailed
+			d.Error = err.Error()
+			slog.Warn("Deferred deletion failed", "correlation_id", d.CorrelationID, "id", d.ID, "target", d.Target, "error", err)
+		} else {
+			d.Status = StatusExecuted
+			slog.Info("Deferred deletion executed", "correlation_id", d.CorrelationID, "id", d.ID, "target", d.Target)
+		}
+		q.mu.Unlock()
+	}
+
+	q.mu.Lock()
+	if err := q.saveLocked(); err != nil {
+		slog.Error("Failed to persist pending deletions", "error", err)
+	}
+	q.mu.Unlock()
+}
+
+// saveLocked writes the queue to disk (no-op for in-memory queues). Must be
+// called with mu held.
+func (q *Queue) saveLocked() error {
+	if q.config.Path == "" {
+		return nil
+	}
+
+	deletions := make([]*Deletion, 0, len(q.deletions))
+	for _, d := range q.deletions {
+		deletions = append(deletions, d)
+	}
+	data, err := json.Marshal(deletions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending deletions: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.config.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create deletion queue directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(q.config.Path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write pending deletions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package deletion
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestQueueExecutesAfterGracePeriod(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetResult("sharing.smb.delete", true)
+	path := filepath.Join(t.TempDir(), "pending_deletions.json")
+
+	queue, err := NewQueue(server.Client(t), Config{GracePeriod: time.Hour, Retention: 2 * time.Hour, Path: path})
+	if err != nil {
+		t.Fatalf("NewQueue failed: %v", err)
+	}
+
+	kept, err := queue.Schedule("", "delete_smb_share", "media", "sharing.smb.delete", []interface{}{float64(3)}, "abc")
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if _, err := queue.Schedule("", "delete_smb_share", "media", "sharing.smb.delete", []interface{}{float64(3)}, "def"); err == nil {
+		t.Errorf("second deletion of the same target should be refused")
+	}
+	undone, err := queue.Schedule("", "delete_smb_share", "scratch", "sharing.smb.delete", []interface{}{float64(4)}, "ghi")
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if _, err := queue.Cancel(undone.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	queue.ExecuteDue(time.Now())
+	if calls := server.Calls("sharing.smb.delete"); len(calls) != 0 {
+		t.Fatalf("deletion executed before its grace period: %v", calls)
+	}
+
+	// Pending deletions survive a restart
+	reloaded, err := NewQueue(server.Client(t), Config{GracePeriod: time.Hour, Retention: 2 * time.Hour, Path: path})
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	reloaded.ExecuteDue(kept.ExecuteAt.Add(time.Second))
+
+	calls := server.Calls("sharing.smb.delete")
+	if len(calls) != 1 || calls[0].Params[0] != float64(3) {
+		t.Fatalf("sharing.smb.delete calls = %v, want only share 3", calls)
+	}
+	statuses := map[string]Status{}
+	for _, d := range reloaded.List() {
+		statuses[d.Target] = d.Status
+	}
+	if statuses["media"] != StatusExecuted || statuses["scratch"] != StatusCancelled {
+		t.Errorf("statuses = %v, want media executed and scratch cancelled", statuses)
+	}
+	if _, err := reloaded.Cancel(kept.ID); err == nil {
+		t.Errorf("an executed deletion should not be undoable")
+	}
+
+	reloaded.ExecuteDue(time.Now().Add(4 * time.Hour))
+	if remaining := reloaded.List(); len(remaining) != 0 {
+		t.Errorf("finished deletions past retention = %v, want none", remaining)
+	}
+}
+
+func TestQueueExecutesOnSystem(t *testing.T) {
+	primary := truenastest.NewServer(t)
+	second := truenastest.NewServer(t)
+	second.SetResult("pool.snapshot.delete", true)
+
+	queue, err := NewQueue(primary.Client(t), Config{GracePeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("NewQueue failed: %v", err)
+	}
+	queue.AddSystem("nas2", second.Client(t))
+
+	pending, err := queue.Schedule("nas2", "delete_snapshot", "tank@old", "pool.snapshot.delete", []interface{}{"tank@old"}, "abc")
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	// The same target on another system is a different deletion
+	if _, err := queue.Schedule("", "delete_snapshot", "tank@old", "pool.snapshot.delete", []interface{}{"tank@old"}, "def"); err != nil {
+		t.Errorf("deletion of the same name on the default system refused: %v", err)
+	}
+	orphan, _ := queue.Schedule("nas3", "delete_snapshot", "tank@old", "pool.snapshot.delete", []interface{}{"tank@old"}, "ghi")
+
+	queue.ExecuteDue(pending.ExecuteAt.Add(time.Second))
+	if calls := second.Calls("pool.snapshot.delete"); len(calls) != 1 {
+		t.Errorf("nas2 pool.snapshot.delete calls = %v, want 1", calls)
+	}
+	for _, d := range queue.List() {
+		if d.ID == orphan.ID && (d.Status != StatusFailed || d.Error == "") {
+			t.Errorf("deletion on an unconfigured system = %+v, want failed", d)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// webhookTimeout bounds a post to a chat webhook
+const webhookTimeout = 30 * time.Second
+
+// alertService is a TrueNAS alert service (alertservice.query record)
+type alertService struct {
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Enabled    bool                   `json:"enabled"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// kind returns the service type. Newer TrueNAS versions keep it in the
+// attributes instead of on the record.
+func (a *alertService) kind() string {
+	if a.Type != "" {
+		return a.Type
+	}
+	kind, _ := a.Attributes["type"].(string)
+	return kind
+}
+
+// findAlertService looks up an alert service by name
+func findAlertService(client truenas.Caller, name string) (*alertService, error) {
+	result, err := client.Call("alertservice.query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert services: %w", err)
+	}
+	var services []alertService
+	if err := json.Unmarshal(result, &services); err != nil {
+		return nil, fmt.Errorf("failed to parse alert services: %w", err)
+	}
+
+	names := make([]string, 0, len(services))
+	for i := range services {
+		if services[i].Name == name {
+			return &services[i], nil
+		}
+		names = append(names, services[i].Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("alert service %q not found; no alert services are configured", name)
+	}
+	return nil, fmt.Errorf("alert service %q not found; configured: %s", name, strings.Join(names, ", "))
+}
+
+// usableAlertService looks up an alert service and checks that it can deliver
+// a digest. Mail services send through mail.send to the service's address;
+// Slack and Mattermost services post to their webhook. Other service types
+// only carry TrueNAS alerts, so they cannot deliver a digest.
+func usableAlertService(client truenas.Caller, name string) (*alertService, error) {
+	service, err := findAlertService(client, name)
+	if err != nil {
+		return nil, err
+	}
+	if !service.Enabled {
+		return nil, fmt.Errorf("alert service %q is disabled", name)
+	}
+	switch kind := service.kind(); kind {
+	case "Mail":
+	case "Slack", "Mattermost":
+		if webhook, _ := service.Attributes["url"].(string); webhook == "" {
+			return nil, fmt.Errorf("alert service %q has no webhook URL", name)
+		}
+	default:
+		return nil, fmt.Errorf("alert service %q is of type %s, which cannot deliver a digest; use a Mail, Slack, or Mattermost service", name, kind)
+	}
+	return service, nil
+}
+
+// CheckAlertService reports how a digest would be delivered through an alert
+// service without sending anything
+func (s *Scheduler) CheckAlertService(name string) (string, error) {
+	service, err := usableAlertService(s.client, name)
+	if err != nil {
+		return "", err
+	}
+	if service.kind() != "Mail" {
+		return fmt.Sprintf("%s webhook", service.kind()), nil
+	}
+	if email, _ := service.Attributes["email"].(string); email != "" {
+		return "mail to " + email, nil
+	}
+	return "mail to the administrators", nil
+}
+
+// sendAlertService delivers the digest through a TrueNAS alert service
+func (s *Scheduler) sendAlertService(report *Report, name string) DeliveryOutcome {
+	outcome := DeliveryOutcome{Method: "alert_service", Target: name}
+
+	service, err := usableAlertService(s.client, name)
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	if kind := service.kind(); kind == "Mail" {
+		params := map[string]interface{}{
+			"subject": report.Subject(),
+			"text":    report.Text(),
+		}
+		// Without an address the service mails the administrators
+		if email, _ := service.Attributes["email"].(string); email != "" {
+			params["to"] = []string{email}
+		}
+		if _, err := s.client.Call("mail.send", params); err != nil {
+			outcome.Error = fmt.Sprintf("failed to send email: %v", err)
+		}
+	} else {
+		webhook, _ := service.Attributes["url"].(string)
+		if err := s.postWebhook(webhook, report.Subject()+"\n\n"+report.Text()); err != nil {
+			outcome.Error = fmt.Sprintf("failed to post to %s: %v", kind, err)
+		}
+	}
+	return outcome
+}
+
+// postWebhook posts text to a Slack-compatible incoming webhook
+func (s *Scheduler) postWebhook(webhook, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return withoutURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// withoutURL drops the URL from a request error, since a webhook URL carries
+// the webhook's secret
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
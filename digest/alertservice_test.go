@@ -0,0 +1,76 @@
+package digest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestGenerateAlertServiceDelivery(t *testing.T) {
+	var posted string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		posted = body["text"]
+	}))
+	defer webhook.Close()
+
+	mock := truenastest.NewMock()
+	mock.SetResult("system.info", map[string]interface{}{"hostname": "nas", "version": "25.04"})
+	mock.SetResult("mail.send", true)
+	mock.SetRecords("alertservice.query", []map[string]interface{}{
+		{"name": "chat", "type": "Slack", "enabled": true, "attributes": map[string]interface{}{"url": webhook.URL}},
+		{"name": "ops mail", "type": "Mail", "enabled": true, "attributes": map[string]interface{}{"email": "ops@example.com"}},
+		{"name": "pager", "type": "PagerDuty", "enabled": true, "attributes": map[string]interface{}{}},
+		{"name": "old", "type": "Slack", "enabled": false, "attributes": map[string]interface{}{"url": webhook.URL}},
+	})
+
+	tracker, err := capacity.NewTracker(nil, capacity.TrackerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScheduler(mock, tracker, SchedulerConfig{})
+	defer s.Shutdown()
+
+	report := s.Generate(PeriodDaily, Delivery{AlertService: "chat"})
+	if len(report.Deliveries) != 2 || report.Deliveries[1].Error != "" {
+		t.Fatalf("deliveries = %+v, want mcp and a successful alert service post", report.Deliveries)
+	}
+	if !strings.HasPrefix(posted, "[nas] daily health digest") {
+		t.Errorf("webhook text = %q, want the digest subject first", posted)
+	}
+
+	report = s.Generate(PeriodDaily, Delivery{AlertService: "ops mail"})
+	if report.Deliveries[1].Error != "" {
+		t.Errorf("mail delivery error = %s", report.Deliveries[1].Error)
+	}
+	calls := mock.Calls("mail.send")
+	if len(calls) != 1 || !strings.Contains(string(mustJSON(t, calls[0].Params)), "ops@example.com") {
+		t.Errorf("mail.send calls = %+v, want one to ops@example.com", calls)
+	}
+
+	for service, want := range map[string]string{
+		"pager":   "cannot deliver a digest",
+		"old":     "is disabled",
+		"missing": "not found; configured: chat, ops mail, pager, old",
+	} {
+		report = s.Generate(PeriodDaily, Delivery{AlertService: service})
+		if got := report.Deliveries[1].Error; !strings.Contains(got, want) {
+			t.Errorf("%s delivery error = %q, want %q", service, got, want)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,344 @@
+package digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Period identifies how much history a digest covers
+type Period string
+
+const (
+	PeriodDaily  Period = "daily"
+	PeriodWeekly Period = "weekly"
+)
+
+// Duration returns the length of the period
+func (p Period) Duration() time.Duration {
+	if p == PeriodWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Report is a point-in-time health digest of a TrueNAS system
+type Report struct {
+	GeneratedAt     time.Time         `json:"generated_at"`
+	Period          Period            `json:"period"`
+	PeriodStart     time.Time         `json:"period_start"`
+	Hostname        string            `json:"hostname,omitempty"`
+	Version         string            `json:"version,omitempty"`
+	OverallStatus   string            `json:"overall_status"`
+	Alerts          AlertSummary      `json:"alerts"`
+	Pools           []PoolSummary     `json:"pools"`
+	SMART           SMARTSummary      `json:"smart"`
+	PendingUpdates  []string          `json:"pending_updates"`
+	CapacityDeltas  []CapacityDelta   `json:"capacity_deltas,omitempty"`
+	CollectionNotes []string          `json:"collection_notes,omitempty"`
+	Deliveries      []DeliveryOutcome `json:"deliveries,omitempty"`
+}
+
+// AlertSummary counts active (non-dismissed) alerts by level
+type AlertSummary struct {
+	Total   int            `json:"total"`
+	ByLevel map[string]int `json:"by_level"`
+	Items   []string       `json:"items,omitempty"`
+}
+
+// PoolSummary captures pool status and last scrub result
+type PoolSummary struct {
+	Name           string  `json:"name"`
+	Status         string  `json:"status"`
+	Healthy        bool    `json:"healthy"`
+	UtilizationPct float64 `json:"utilization_pct"`
+	LastScrub      string  `json:"last_scrub,omitempty"`
+	ScrubErrors    int     `json:"scrub_errors"`
+}
+
+// SMARTSummary reports disks whose most recent SMART test did not succeed
+type SMARTSummary struct {
+	DisksTested  int      `json:"disks_tested"`
+	FailingDisks []string `json:"failing_disks"`
+}
+
+// CapacityDelta reports pool usage change over the digest period
+type CapacityDelta struct {
+	Pool       string `json:"pool"`
+	Since      string `json:"since"`
+	DeltaBytes int64  `json:"delta_bytes"`
+	Delta      string `json:"delta"`
+}
+
+// ResourceURI is the MCP resource serving the latest digest
+const ResourceURI = "truenas://health-digest"
+
+// DeliveryOutcome records one way the digest was delivered
+type DeliveryOutcome struct {
+	Method string `json:"method"`
+	Target string `json:"target,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Build collects all digest sections. Individual collection failures are recorded
+// in CollectionNotes rather than failing the whole report.
+func Build(client truenas.Caller, tracker *capacity.Tracker, period Period) *Report {
+	now := time.Now()
+	report := &Report{
+		GeneratedAt:    now,
+		Period:         period,
+		PeriodStart:    now.Add(-period.Duration()),
+		Alerts:         AlertSummary{ByLevel: map[string]int{}},
+		Pools:          []PoolSummary{},
+		SMART:          SMARTSummary{FailingDisks: []string{}},
+		PendingUpdates: []string{},
+	}
+
+	// System identity
+	if result, err := client.Call("system.info"); err == nil {
+		var info map[string]interface{}
+		if err := json.Unmarshal(result, &info); err == nil {
+			report.Hostname, _ = info["hostname"].(string)
+			report.Version, _ = info["version"].(string)
+		}
+	} else {
+		report.CollectionNotes = append(report.CollectionNotes, fmt.Sprintf("system info unavailable: %v", err))
+	}
+
+	// Alerts
+	if result, err := client.Call("alert.list"); err == nil {
+		var alerts []map[string]interface{}
+		if err := json.Unmarshal(result, &alerts); err == nil {
+			for _, alert := range alerts {
+				if dismissed, _ := alert["dismissed"].(bool); dismissed {
+					continue
+				}
+				level, _ := alert["level"].(string)
+				report.Alerts.Total++
+				report.Alerts.ByLevel[level]++
+				if text, ok := alert["formatted"].(string); ok && text != "" {
+					report.Alerts.Items = append(report.Alerts.Items, fmt.Sprintf("[%s] %s", level, text))
+				}
+			}
+		}
+	} else {
+		report.CollectionNotes = append(report.CollectionNotes, fmt.Sprintf("alerts unavailable: %v", err))
+	}
+
+	// Pool health and last scrub
+	if result, err := client.Call("pool.query"); err == nil {
+		var pools []map[string]interface{}
+		if err := json.Unmarshal(result, &pools); err == nil {
+			for _, pool := range pools {
+				report.Pools = append(report.Pools, summarizePool(pool))
+			}
+		}
+	} else {
+		report.CollectionNotes = append(report.CollectionNotes, fmt.Sprintf("pools unavailable: %v", err))
+	}
+
+	// SMART test results
+	if result, err := client.Call("smart.test.results", []interface{}{}); err == nil {
+		var disks []map[string]interface{}
+		if err := json.Unmarshal(result, &disks); err == nil {
+			report.SMART = summarizeSMART(disks)
+		}
+	} else {
+		report.CollectionNotes = append(report.CollectionNotes, fmt.Sprintf("SMART results unavailable: %v", err))
+	}
+
+	// Pending updates
+	if result, err := client.Call("update.available_versions"); err == nil {
+		var versions []map[string]interface{}
+		if err := json.Unmarshal(result, &versions); err == nil {
+			for _, v := range versions {
+				if version, ok := v["version"].(map[string]interface{}); ok {
+					if name, ok := version["version"].(string); ok {
+						report.PendingUpdates = append(report.PendingUpdates, name)
+					}
+				} else if name, ok := v["version"].(string); ok {
+					report.PendingUpdates = append(report.PendingUpdates, name)
+				}
+			}
+		}
+	} else {
+		report.CollectionNotes = append(report.CollectionNotes, fmt.Sprintf("update check unavailable: %v", err))
+	}
+
+	// Capacity deltas from local history
+	if tracker != nil {
+		report.CapacityDeltas = capacityDeltas(tracker, report.PeriodStart)
+	}
+
+	report.OverallStatus = overallStatus(report)
+	return report
+}
+
+// summarizePool extracts status, utilization, and last scrub from a pool.query entry
+func summarizePool(pool map[string]interface{}) PoolSummary {
+	summary := PoolSummary{}
+	summary.Name, _ = pool["name"].(string)
+	summary.Status, _ = pool["status"].(string)
+	summary.Healthy, _ = pool["healthy"].(bool)
+
+	used, _ := pool["allocated"].(float64)
+	free, _ := pool["free"].(float64)
+	if used+free > 0 {
+		summary.UtilizationPct = used / (used + free) * 100
+	}
+
+	if scan, ok := pool["scan"].(map[string]interface{}); ok {
+		if fn, _ := scan["function"].(string); fn == "SCRUB" {
+			state, _ := scan["state"].(string)
+			errors, _ := scan["errors"].(float64)
+			summary.ScrubErrors = int(errors)
+			summary.LastScrub = state
+			if endTime, ok := scan["end_time"].(map[string]interface{}); ok {
+				if endMs, ok := endTime["$date"].(float64); ok {
+					summary.LastScrub = fmt.Sprintf("%s at %s", state, time.UnixMilli(int64(endMs)).Format(time.RFC3339))
+				}
+			}
+		}
+	}
+
+	return summary
+}
+
+// summarizeSMART finds disks whose most recent SMART test did not succeed
+func summarizeSMART(disks []map[string]interface{}) SMARTSummary {
+	summary := SMARTSummary{FailingDisks: []string{}}
+	for _, disk := range disks {
+		name, _ := disk["disk"].(string)
+		tests, ok := disk["tests"].([]interface{})
+		if !ok || len(tests) == 0 {
+			continue
+		}
+		summary.DisksTested++
+
+		// Tests are returned newest first
+		latest, ok := tests[0].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status, _ := latest["status"].(string)
+		if status != "" && status != "SUCCESS" && status != "RUNNING" {
+			summary.FailingDisks = append(summary.FailingDisks, fmt.Sprintf("%s (%s)", name, status))
+		}
+	}
+	sort.Strings(summary.FailingDisks)
+	return summary
+}
+
+// capacityDeltas compares each pool's latest sample with the first sample in the period
+func capacityDeltas(tracker *capacity.Tracker, since time.Time) []CapacityDelta {
+	deltas := []CapacityDelta{}
+	for _, pool := range tracker.Pools() {
+		history := tracker.History(pool)
+		var baseline *capacity.Sample
+		for i := range history {
+			if !history[i].Timestamp.Before(since) {
+				baseline = &history[i]
+				break
+			}
+		}
+		if baseline == nil || len(history) < 2 {
+			continue
+		}
+		latest := history[len(history)-1]
+		deltas = append(deltas, CapacityDelta{
+			Pool:       pool,
+			Since:      baseline.Timestamp.Format(time.RFC3339),
+			DeltaBytes: latest.UsedBytes - baseline.UsedBytes,
+			Delta:      units.FormatBytes(latest.UsedBytes - baseline.UsedBytes),
+		})
+	}
+	return deltas
+}
+
+// overallStatus rolls the report sections up into OK, WARNING, or CRITICAL
+func overallStatus(report *Report) string {
+	status := "OK"
+	if report.Alerts.ByLevel["CRITICAL"] > 0 || len(report.SMART.FailingDisks) > 0 {
+		return "CRITICAL"
+	}
+	for _, pool := range report.Pools {
+		if !pool.Healthy || pool.ScrubErrors > 0 {
+			return "CRITICAL"
+		}
+		if pool.UtilizationPct >= 85 {
+			status = "WARNING"
+		}
+	}
+	if report.Alerts.ByLevel["WARNING"] > 0 || report.Alerts.ByLevel["ERROR"] > 0 {
+		status = "WARNING"
+	}
+	return status
+}
+
+// Subject returns a one-line summary suitable for an email subject
+func (r *Report) Subject() string {
+	host := r.Hostname
+	if host == "" {
+		host = "TrueNAS"
+	}
+	return fmt.Sprintf("[%s] %s health digest: %s", host, r.Period, r.OverallStatus)
+}
+
+// Text renders the report as plain text for email delivery
+func (r *Report) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "TrueNAS %s health digest\n", r.Period)
+	fmt.Fprintf(&b, "Host: %s (%s)\n", r.Hostname, r.Version)
+	fmt.Fprintf(&b, "Generated: %s\n", r.GeneratedAt.Format(time.RFC1123))
+	fmt.Fprintf(&b, "Overall status: %s\n\n", r.OverallStatus)
+
+	fmt.Fprintf(&b, "Alerts: %d active\n", r.Alerts.Total)
+	for _, item := range r.Alerts.Items {
+		fmt.Fprintf(&b, "  - %s\n", item)
+	}
+
+	b.WriteString("\nPools:\n")
+	for _, pool := range r.Pools {
+		fmt.Fprintf(&b, "  - %s: %s, %.1f%% used", pool.Name, pool.Status, pool.UtilizationPct)
+		if pool.LastScrub != "" {
+			fmt.Fprintf(&b, ", last scrub %s (%d errors)", pool.LastScrub, pool.ScrubErrors)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\nSMART: %d disks tested", r.SMART.DisksTested)
+	if len(r.SMART.FailingDisks) > 0 {
+		fmt.Fprintf(&b, ", failing: %s", strings.Join(r.SMART.FailingDisks, ", "))
+	}
+	b.WriteString("\n")
+
+	if len(r.CapacityDeltas) > 0 {
+		b.WriteString("\nCapacity changes:\n")
+		for _, delta := range r.CapacityDeltas {
+			sign := "+"
+			if delta.DeltaBytes < 0 {
+				sign = ""
+			}
+			fmt.Fprintf(&b, "  - %s: %s%s since %s\n", delta.Pool, sign, units.FormatBytes(delta.DeltaBytes), delta.Since)
+		}
+	}
+
+	if len(r.PendingUpdates) > 0 {
+		fmt.Fprintf(&b, "\nPending updates: %s\n", strings.Join(r.PendingUpdates, ", "))
+	} else {
+		b.WriteString("\nPending updates: none\n")
+	}
+
+	for _, note := range r.CollectionNotes {
+		fmt.Fprintf(&b, "\nNote: %s", note)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	// Wednesday 2025-01-08 10:30 UTC
+	from := time.Date(2025, 1, 8, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		period   Period
+		hour     int
+		expected time.Time
+	}{
+		{
+			name:     "daily later today",
+			period:   PeriodDaily,
+			hour:     18,
+			expected: time.Date(2025, 1, 8, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "daily hour already passed",
+			period:   PeriodDaily,
+			hour:     7,
+			expected: time.Date(2025, 1, 9, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekly runs next Monday",
+			period:   PeriodWeekly,
+			hour:     7,
+			expected: time.Date(2025, 1, 13, 7, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NextRun(from, tt.period, tt.hour)
+			if !result.Equal(tt.expected) {
+				t.Errorf("NextRun() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSummarizeSMART(t *testing.T) {
+	disks := []map[string]interface{}{
+		{
+			"disk": "sda",
+			"tests": []interface{}{
+				map[string]interface{}{"status": "SUCCESS"},
+			},
+		},
+		{
+			"disk": "sdb",
+			"tests": []interface{}{
+				map[string]interface{}{"status": "FAILED"},
+				map[string]interface{}{"status": "SUCCESS"},
+			},
+		},
+		{
+			"disk":  "sdc",
+			"tests": []interface{}{},
+		},
+	}
+
+	summary := summarizeSMART(disks)
+	if summary.DisksTested != 2 {
+		t.Errorf("DisksTested = %d, want 2", summary.DisksTested)
+	}
+	if len(summary.FailingDisks) != 1 || summary.FailingDisks[0] != "sdb (FAILED)" {
+		t.Errorf("FailingDisks = %v, want [sdb (FAILED)]", summary.FailingDisks)
+	}
+}
+
+func TestOverallStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   *Report
+		expected string
+	}{
+		{
+			name: "healthy system",
+			report: &Report{
+				Alerts: AlertSummary{ByLevel: map[string]int{}},
+				Pools:  []PoolSummary{{Name: "tank", Healthy: true, UtilizationPct: 40}},
+			},
+			expected: "OK",
+		},
+		{
+			name: "pool nearly full",
+			report: &Report{
+				Alerts: AlertSummary{ByLevel: map[string]int{}},
+				Pools:  []PoolSummary{{Name: "tank", Healthy: true, UtilizationPct: 90}},
+			},
+			expected: "WARNING",
+		},
+		{
+			name: "critical alert",
+			report: &Report{
+				Alerts: AlertSummary{ByLevel: map[string]int{"CRITICAL": 1}},
+				Pools:  []PoolSummary{{Name: "tank", Healthy: true}},
+			},
+			expected: "CRITICAL",
+		},
+		{
+			name: "unhealthy pool",
+			report: &Report{
+				Alerts: AlertSummary{ByLevel: map[string]int{}},
+				Pools:  []PoolSummary{{Name: "tank", Healthy: false}},
+			},
+			expected: "CRITICAL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := overallStatus(tt.report); result != tt.expected {
+				t.Errorf("overallStatus() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
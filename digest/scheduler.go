@@ -0,0 +1,198 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// SchedulerConfig configures scheduled digest generation and delivery
+type SchedulerConfig struct {
+	Period       Period   // daily or weekly ("" = scheduling disabled)
+	Hour         int      // Local hour of day to generate the digest (0-23)
+	EmailTo      []string // Recipients for email delivery via mail.send (empty = no email)
+	AlertService string   // Name of a TrueNAS alert service to deliver through ("" = none)
+	Path         string   // JSON file used to persist the latest digest ("" = in-memory only)
+}
+
+// Delivery says where a generated digest is sent besides being kept for MCP
+type Delivery struct {
+	EmailTo      []string // Recipients for email via mail.send
+	AlertService string   // Name of a TrueNAS alert service
+}
+
+// Scheduler generates health digests on a schedule and keeps the latest one
+// available for retrieval through MCP
+type Scheduler struct {
+	client  truenas.Caller
+	tracker *capacity.Tracker
+	config  SchedulerConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu     sync.RWMutex
+	latest *Report
+}
+
+// NewScheduler creates a new digest scheduler, loading the last persisted digest if any
+func NewScheduler(client truenas.Caller, tracker *capacity.Tracker, config SchedulerConfig) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Scheduler{
+		client:  client,
+		tracker: tracker,
+		config:  config,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	if config.Path != "" {
+		if data, err := os.ReadFile(config.Path); err == nil {
+			var report Report
+			if err := json.Unmarshal(data, &report); err == nil {
+				s.latest = &report
+			}
+		}
+	}
+
+	return s
+}
+
+// Start begins scheduled generation. Does nothing if no period is configured.
+func (s *Scheduler) Start() {
+	if s.config.Period == "" {
+		return
+	}
+	go s.run()
+}
+
+// Shutdown stops scheduled generation
+func (s *Scheduler) Shutdown() {
+	s.cancel()
+}
+
+// Enabled reports whether scheduled generation is configured
+func (s *Scheduler) Enabled() bool {
+	return s.config.Period != ""
+}
+
+// Config returns the scheduler configuration
+func (s *Scheduler) Config() SchedulerConfig {
+	return s.config
+}
+
+// Latest returns the most recently generated digest, or nil if none exists
+func (s *Scheduler) Latest() *Report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest
+}
+
+// run waits for each scheduled time and generates the digest
+func (s *Scheduler) run() {
+	for {
+		next := NextRun(time.Now(), s.config.Period, s.config.Hour)
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			report := s.Generate(s.config.Period, Delivery{EmailTo: s.config.EmailTo, AlertService: s.config.AlertService})
+			slog.Info("Generated health digest", "period", report.Period, "status", report.OverallStatus)
+		}
+	}
+}
+
+// Generate builds a digest now, sends it as delivery says, and stores it as the latest
+func (s *Scheduler) Generate(period Period, delivery Delivery) *Report {
+	report := Build(s.client, s.tracker, period)
+
+	report.Deliveries = []DeliveryOutcome{{Method: "mcp", Target: ResourceURI}}
+	if len(delivery.EmailTo) > 0 {
+		report.Deliveries = append(report.Deliveries, s.sendEmail(report, delivery.EmailTo))
+	}
+	if delivery.AlertService != "" {
+		report.Deliveries = append(report.Deliveries, s.sendAlertService(report, delivery.AlertService))
+	}
+
+	s.mu.Lock()
+	s.latest = report
+	s.mu.Unlock()
+
+	if err := s.save(report); err != nil {
+		slog.Error("Failed to persist health digest", "error", err)
+	}
+
+	return report
+}
+
+// Preview builds a digest without delivering or storing it
+func (s *Scheduler) Preview(period Period) *Report {
+	return Build(s.client, s.tracker, period)
+}
+
+// sendEmail delivers the digest using the TrueNAS configured mail settings
+func (s *Scheduler) sendEmail(report *Report, to []string) DeliveryOutcome {
+	outcome := DeliveryOutcome{
+		Method: "email",
+		Target: strings.Join(to, ", "),
+	}
+
+	_, err := s.client.Call("mail.send", map[string]interface{}{
+		"subject": report.Subject(),
+		"text":    report.Text(),
+		"to":      to,
+	})
+	if err != nil {
+		outcome.Error = fmt.Sprintf("failed to send email: %v", err)
+	}
+
+	return outcome
+}
+
+// save persists the digest to disk (no-op when no path is configured)
+func (s *Scheduler) save(report *Report) error {
+	if s.config.Path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.config.Path), 0o700); err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(s.config.Path, data, 0o600)
+}
+
+// NextRun returns the next scheduled time after from. Daily digests run every day
+// at the given hour; weekly digests run on Mondays at that hour.
+func NextRun(from time.Time, period Period, hour int) time.Time {
+	next := time.Date(from.Year(), from.Month(), from.Day(), hour, 0, 0, 0, from.Location())
+	if !next.After(from) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	if period == PeriodWeekly {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,204 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Freshness describes how current a cached collection is
+type Freshness struct {
+	Source      string     `json:"source"` // "cache" or "live"
+	SyncedAt    time.Time  `json:"synced_at"`
+	LastEventAt *time.Time `json:"last_event_at,omitempty"`
+	AgeSeconds  int64      `json:"age_seconds"` // since the last full sync
+}
+
+// LiveFreshness describes data queried directly just now
+func LiveFreshness() Freshness {
+	return Freshness{Source: "live", SyncedAt: time.Now().UTC()}
+}
+
+// Cache keeps alert.list and core.get_jobs in memory, seeded with a full
+// query and kept current by middleware events. A collection is re-queried
+// when the connection has dropped since it was synced (events may have been
+// missed) or its last full sync is older than maxAge.
+type Cache struct {
+	client *truenas.Client
+	maxAge time.Duration
+
+	alerts *cachedCollection
+	jobs   *cachedCollection
+}
+
+// cachedCollection is one subscribed collection keyed by record ID
+type cachedCollection struct {
+	name string // subscription and query method
+
+	// syncMu serializes subscribing and full syncs
+	syncMu     sync.Mutex
+	subscribed bool
+
+	mu          sync.Mutex
+	records     map[string]map[string]interface{}
+	synced      bool
+	syncedAt    time.Time
+	lastEventAt time.Time
+	generation  uint64
+
+	// Events received during a full sync are replayed on its result, which
+	// may predate them
+	syncing bool
+	pending []truenas.Event
+}
+
+// NewCache creates a cache whose collections are fully re-queried at least
+// every maxAge
+func NewCache(client *truenas.Client, maxAge time.Duration) *Cache {
+	return &Cache{
+		client: client,
+		maxAge: maxAge,
+		alerts: &cachedCollection{name: subscriptionNames[CategoryAlert]},
+		jobs:   &cachedCollection{name: subscriptionNames[CategoryJob]},
+	}
+}
+
+// Alerts returns every alert as alert.list reports it, in no particular order
+func (c *Cache) Alerts() ([]map[string]interface{}, Freshness, error) {
+	return c.get(c.alerts)
+}
+
+// Jobs returns every job core.get_jobs reports, in no particular order
+func (c *Cache) Jobs() ([]map[string]interface{}, Freshness, error) {
+	return c.get(c.jobs)
+}
+
+func (c *Cache) get(col *cachedCollection) ([]map[string]interface{}, Freshness, error) {
+	if err := c.ensure(col); err != nil {
+		return nil, Freshness{}, err
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	records := make([]map[string]interface{}, 0, len(col.records))
+	for _, record := range col.records {
+		records = append(records, record)
+	}
+	freshness := Freshness{
+		Source:     "cache",
+		SyncedAt:   col.syncedAt.UTC(),
+		AgeSeconds: int64(time.Since(col.syncedAt).Seconds()),
+	}
+	if !col.lastEventAt.IsZero() {
+		at := col.lastEventAt.UTC()
+		freshness.LastEventAt = &at
+	}
+	return records, freshness, nil
+}
+
+// ensure subscribes to a collection and fully syncs it when it is stale
+func (c *Cache) ensure(col *cachedCollection) error {
+	col.syncMu.Lock()
+	defer col.syncMu.Unlock()
+
+	if !col.subscribed {
+		if err := c.client.Subscribe(col.name, col.handle); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", col.name, err)
+		}
+		col.subscribed = true
+	}
+
+	col.mu.Lock()
+	fresh := col.synced && col.generation == c.client.ConnectionGeneration() &&
+		(c.maxAge <= 0 || time.Since(col.syncedAt) < c.maxAge)
+	if !fresh {
+		col.syncing = true
+		col.pending = nil
+	}
+	col.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	records, err := c.query(col)
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.syncing = false
+	pending := col.pending
+	col.pending = nil
+	if err != nil {
+		return err
+	}
+	col.records = records
+	for _, ev := range pending {
+		col.apply(ev)
+	}
+	col.synced = true
+	col.syncedAt = time.Now()
+	col.generation = c.client.ConnectionGeneration()
+	return nil
+}
+
+// query fetches a collection in full
+func (c *Cache) query(col *cachedCollection) (map[string]map[string]interface{}, error) {
+	result, err := c.client.Call(col.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", col.name, err)
+	}
+	var list []map[string]interface{}
+	if err := json.Unmarshal(result, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", col.name, err)
+	}
+	records := make(map[string]map[string]interface{}, len(list))
+	for _, record := range list {
+		id := record["uuid"] // alerts
+		if id == nil {
+			id = record["id"]
+		}
+		records[recordKey(id)] = record
+	}
+	return records, nil
+}
+
+// handle applies a middleware event. It runs on the client's read loop.
+func (col *cachedCollection) handle(ev truenas.Event) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if col.syncing {
+		col.pending = append(col.pending, ev)
+	}
+	if col.records != nil {
+		col.apply(ev)
+	}
+	col.lastEventAt = time.Now()
+}
+
+// apply updates the records for one event. Must be called with mu held.
+func (col *cachedCollection) apply(ev truenas.Event) {
+	key := recordKey(ev.ID)
+	switch ev.Type {
+	case "removed":
+		delete(col.records, key)
+	case "added", "changed":
+		record := map[string]interface{}{}
+		for field, value := range col.records[key] {
+			record[field] = value
+		}
+		for field, value := range ev.Fields {
+			record[field] = value
+		}
+		col.records[key] = record
+	}
+}
+
+// recordKey normalizes record IDs, which arrive as numbers or strings
+func recordKey(id interface{}) string {
+	if f, ok := id.(float64); ok {
+		return fmt.Sprintf("%d", int64(f))
+	}
+	return fmt.Sprintf("%v", id)
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+// waitForAlert publishes an event until the cache reflects it
+func waitForAlert(t *testing.T, server *truenastest.Server, cache *Cache, msgType, id string, fields map[string]interface{}, done func([]map[string]interface{}) bool) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		server.Publish(msgType, "alert.list", id, fields)
+		alerts, _, err := cache.Alerts()
+		if err != nil {
+			t.Fatalf("Alerts failed: %v", err)
+		}
+		if done(alerts) {
+			return
+		}
+		select {
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("%s event for %s was not applied", msgType, id)
+		}
+	}
+}
+
+func findAlert(alerts []map[string]interface{}, uuid string) map[string]interface{} {
+	for _, alert := range alerts {
+		if alert["uuid"] == uuid {
+			return alert
+		}
+	}
+	return nil
+}
+
+func TestCacheAppliesAlertEvents(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetRecords("alert.list", []map[string]interface{}{
+		{"uuid": "a1", "level": "WARNING", "dismissed": false},
+	})
+	cache := NewCache(server.Client(t), time.Hour)
+
+	alerts, freshness, err := cache.Alerts()
+	if err != nil {
+		t.Fatalf("Alerts failed: %v", err)
+	}
+	if len(alerts) != 1 || freshness.Source != "cache" {
+		t.Fatalf("got %d alerts from %q, want 1 from cache", len(alerts), freshness.Source)
+	}
+
+	waitForAlert(t, server, cache, "changed", "a1", map[string]interface{}{"dismissed": true}, func(alerts []map[string]interface{}) bool {
+		alert := findAlert(alerts, "a1")
+		return alert != nil && alert["dismissed"] == true
+	})
+	if alert := findAlert(mustAlerts(t, cache), "a1"); alert["level"] != "WARNING" {
+		t.Errorf("changed event dropped unchanged fields: %v", alert)
+	}
+
+	waitForAlert(t, server, cache, "added", "a2", map[string]interface{}{"uuid": "a2", "level": "CRITICAL"}, func(alerts []map[string]interface{}) bool {
+		return findAlert(alerts, "a2") != nil
+	})
+	waitForAlert(t, server, cache, "removed", "a1", nil, func(alerts []map[string]interface{}) bool {
+		return findAlert(alerts, "a1") == nil
+	})
+
+	// Events keep the cache current, so it is not re-queried while fresh
+	if calls := len(server.Calls("alert.list")); calls != 1 {
+		t.Errorf("alert.list called %d times, want 1", calls)
+	}
+	if _, freshness, _ := cache.Alerts(); freshness.LastEventAt == nil {
+		t.Error("freshness.last_event_at not set after events")
+	}
+}
+
+func TestCacheResyncsWhenStale(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.AddJob("pool.scrub.scrub", nil, truenastest.JobSpec{})
+	cache := NewCache(server.Client(t), time.Nanosecond)
+
+	for i := 0; i < 2; i++ {
+		jobs, _, err := cache.Jobs()
+		if err != nil {
+			t.Fatalf("Jobs failed: %v", err)
+		}
+		if len(jobs) != 1 {
+			t.Fatalf("got %d jobs, want 1", len(jobs))
+		}
+	}
+	if calls := len(server.Calls("core.get_jobs")); calls != 2 {
+		t.Errorf("core.get_jobs called %d times, want 2", calls)
+	}
+}
+
+func mustAlerts(t *testing.T, cache *Cache) []map[string]interface{} {
+	t.Helper()
+	alerts, _, err := cache.Alerts()
+	if err != nil {
+		t.Fatalf("Alerts failed: %v", err)
+	}
+	return alerts
+}
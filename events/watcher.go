@@ -0,0 +1,321 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Category groups middleware events by source
+type Category string
+
+const (
+	CategoryAlert Category = "alert"
+	CategoryJob   Category = "job"
+)
+
+// subscriptionNames maps categories to middleware event names
+var subscriptionNames = map[Category]string{
+	CategoryAlert: "alert.list",
+	CategoryJob:   "core.get_jobs",
+}
+
+// alertLevels orders middleware alert levels from least to most severe
+var alertLevels = []string{"INFO", "NOTICE", "WARNING", "ERROR", "CRITICAL", "ALERT", "EMERGENCY"}
+
+// Event is a normalized, buffered middleware event
+type Event struct {
+	Sequence int64                  `json:"sequence"`
+	Time     time.Time              `json:"time"`
+	Category Category               `json:"category"`
+	Type     string                 `json:"type"`
+	Level    string                 `json:"level,omitempty"`
+	Summary  string                 `json:"summary"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier forwards events to the MCP client
+type Notifier func(Event)
+
+// WatchConfig selects which events are buffered and forwarded
+type WatchConfig struct {
+	Categories    []Category `json:"categories"`
+	MinAlertLevel string     `json:"min_alert_level"`
+	Notify        bool       `json:"notify"`
+}
+
+// Watcher subscribes to middleware alert and job events and keeps a bounded
+// buffer of recent events for retrieval
+type Watcher struct {
+	client   *truenas.Client
+	capacity int
+
+	mu         sync.Mutex
+	config     WatchConfig
+	active     map[Category]bool
+	subscribed map[Category]bool
+	buffer     []Event
+	sequence   int64
+	jobStates  map[string]string
+	notifier   Notifier
+	startedAt  time.Time
+}
+
+// NewWatcher creates a watcher that keeps up to capacity recent events
+func NewWatcher(client *truenas.Client, capacity int) *Watcher {
+	if capacity <= 0 {
+		capacity = 200
+	}
+	return &Watcher{
+		client:     client,
+		capacity:   capacity,
+		active:     make(map[Category]bool),
+		subscribed: make(map[Category]bool),
+		jobStates:  make(map[string]string),
+	}
+}
+
+// SetNotifier sets the function used to push events to the MCP client
+func (w *Watcher) SetNotifier(n Notifier) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.notifier = n
+}
+
+// Watch starts (or reconfigures) watching the given categories
+func (w *Watcher) Watch(config WatchConfig) error {
+	if len(config.Categories) == 0 {
+		config.Categories = []Category{CategoryAlert, CategoryJob}
+	}
+	if config.MinAlertLevel == "" {
+		config.MinAlertLevel = "WARNING"
+	}
+	if alertLevelRank(config.MinAlertLevel) < 0 {
+		return fmt.Errorf("invalid min_alert_level: %s (valid: %s)", config.MinAlertLevel, strings.Join(alertLevels, ", "))
+	}
+
+	for _, category := range config.Categories {
+		if _, ok := subscriptionNames[category]; !ok {
+			return fmt.Errorf("invalid event category: %s (valid: alert, job)", category)
+		}
+	}
+
+	w.mu.Lock()
+	w.config = config
+	w.active = make(map[Category]bool)
+	for _, category := range config.Categories {
+		w.active[category] = true
+	}
+	if w.startedAt.IsZero() {
+		w.startedAt = time.Now()
+	}
+	w.mu.Unlock()
+
+	// Subscriptions are never torn down; inactive categories are simply ignored
+	for _, category := range config.Categories {
+		w.mu.Lock()
+		already := w.subscribed[category]
+		w.subscribed[category] = true
+		w.mu.Unlock()
+		if already {
+			continue
+		}
+
+		category := category
+		if err := w.client.Subscribe(subscriptionNames[category], func(ev truenas.Event) {
+			w.handle(category, ev)
+		}); err != nil {
+			w.mu.Lock()
+			w.subscribed[category] = false
+			w.mu.Unlock()
+			return fmt.Errorf("failed to subscribe to %s events: %w", category, err)
+		}
+	}
+
+	return nil
+}
+
+// Stop stops buffering and forwarding events
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.active = make(map[Category]bool)
+}
+
+// Status reports the current watch configuration
+func (w *Watcher) Status() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	categories := []Category{}
+	for _, category := range []Category{CategoryAlert, CategoryJob} {
+		if w.active[category] {
+			categories = append(categories, category)
+		}
+	}
+
+	status := map[string]interface{}{
+		"watching":        len(categories) > 0,
+		"categories":      categories,
+		"min_alert_level": w.config.MinAlertLevel,
+		"notify":          w.config.Notify,
+		"buffered_events": len(w.buffer),
+		"latest_sequence": w.sequence,
+	}
+	if !w.startedAt.IsZero() {
+		status["watching_since"] = w.startedAt.Format(time.RFC3339)
+	}
+	return status
+}
+
+// Recent returns buffered events with a sequence greater than since, optionally
+// filtered by category, up to limit events (oldest first)
+func (w *Watcher) Recent(since int64, category Category, limit int) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := []Event{}
+	for _, ev := range w.buffer {
+		if ev.Sequence <= since {
+			continue
+		}
+		if category != "" && ev.Category != category {
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+// handle normalizes a middleware event, filters it, and buffers/forwards it
+func (w *Watcher) handle(category Category, raw truenas.Event) {
+	w.mu.Lock()
+
+	if !w.active[category] {
+		w.mu.Unlock()
+		return
+	}
+
+	var ev *Event
+	switch category {
+	case CategoryAlert:
+		ev = w.normalizeAlert(raw)
+	case CategoryJob:
+		ev = w.normalizeJob(raw)
+	}
+	if ev == nil {
+		w.mu.Unlock()
+		return
+	}
+
+	w.sequence++
+	ev.Sequence = w.sequence
+	ev.Time = time.Now()
+	w.buffer = append(w.buffer, *ev)
+	if len(w.buffer) > w.capacity {
+		w.buffer = w.buffer[len(w.buffer)-w.capacity:]
+	}
+
+	notifier := w.notifier
+	notify := w.config.Notify
+	w.mu.Unlock()
+
+	if notify && notifier != nil {
+		notifier(*ev)
+	}
+}
+
+// normalizeAlert converts an alert.list event, dropping alerts below the minimum level.
+// Must be called with mu held.
+func (w *Watcher) normalizeAlert(raw truenas.Event) *Event {
+	level, _ := raw.Fields["level"].(string)
+	if raw.Type != "removed" && alertLevelRank(level) < alertLevelRank(w.config.MinAlertLevel) {
+		return nil
+	}
+
+	summary, _ := raw.Fields["formatted"].(string)
+	if summary == "" {
+		summary, _ = raw.Fields["klass"].(string)
+	}
+
+	switch raw.Type {
+	case "added":
+		summary = fmt.Sprintf("New %s alert: %s", level, summary)
+	case "removed":
+		summary = fmt.Sprintf("Alert cleared: %v", raw.ID)
+	default:
+		summary = fmt.Sprintf("Alert updated: %s", summary)
+	}
+
+	return &Event{
+		Category: CategoryAlert,
+		Type:     raw.Type,
+		Level:    level,
+		Summary:  summary,
+		Data:     raw.Fields,
+	}
+}
+
+// normalizeJob converts a core.get_jobs event, keeping only state transitions so
+// progress updates do not flood the buffer. Must be called with mu held.
+func (w *Watcher) normalizeJob(raw truenas.Event) *Event {
+	jobKey := fmt.Sprintf("%v", raw.ID)
+	state, _ := raw.Fields["state"].(string)
+	method, _ := raw.Fields["method"].(string)
+
+	if raw.Type == "removed" {
+		delete(w.jobStates, jobKey)
+		return nil
+	}
+	if state == "" || w.jobStates[jobKey] == state {
+		return nil
+	}
+	if method == "" {
+		method = "job"
+	}
+	w.jobStates[jobKey] = state
+
+	level := "INFO"
+	if state == "FAILED" {
+		level = "ERROR"
+	}
+
+	summary := fmt.Sprintf("Job %s (%s) is %s", jobKey, method, state)
+	if errMsg, ok := raw.Fields["error"].(string); ok && errMsg != "" {
+		summary = fmt.Sprintf("%s: %s", summary, errMsg)
+	}
+
+	// Terminal jobs no longer need state tracking
+	if state == "SUCCESS" || state == "FAILED" || state == "ABORTED" {
+		delete(w.jobStates, jobKey)
+	}
+
+	return &Event{
+		Category: CategoryJob,
+		Type:     raw.Type,
+		Level:    level,
+		Summary:  summary,
+		Data: map[string]interface{}{
+			"id":     raw.ID,
+			"method": method,
+			"state":  state,
+		},
+	}
+}
+
+// alertLevelRank returns the severity rank of a level, or -1 if unknown
+func alertLevelRank(level string) int {
+	for i, l := range alertLevels {
+		if strings.EqualFold(l, level) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+func newActiveWatcher(categories ...Category) *Watcher {
+	w := NewWatcher(nil, 3)
+	w.config = WatchConfig{MinAlertLevel: "WARNING"}
+	for _, c := range categories {
+		w.active[c] = true
+	}
+	return w
+}
+
+func TestWatcherAlertLevelFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		buffered bool
+	}{
+		{name: "info dropped", level: "INFO", buffered: false},
+		{name: "warning kept", level: "WARNING", buffered: true},
+		{name: "critical kept", level: "CRITICAL", buffered: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newActiveWatcher(CategoryAlert)
+			w.handle(CategoryAlert, truenas.Event{
+				Type:   "added",
+				ID:     "a1",
+				Fields: map[string]interface{}{"level": tt.level, "formatted": "Pool tank is degraded"},
+			})
+			if got := len(w.Recent(0, "", 0)) == 1; got != tt.buffered {
+				t.Errorf("buffered = %v, want %v", got, tt.buffered)
+			}
+		})
+	}
+}
+
+func TestWatcherJobStateTransitions(t *testing.T) {
+	w := newActiveWatcher(CategoryJob)
+
+	for _, state := range []string{"RUNNING", "RUNNING", "RUNNING", "SUCCESS"} {
+		w.handle(CategoryJob, truenas.Event{
+			Type:   "changed",
+			ID:     float64(42),
+			Fields: map[string]interface{}{"method": "pool.scrub", "state": state},
+		})
+	}
+
+	recent := w.Recent(0, CategoryJob, 0)
+	if len(recent) != 2 {
+		t.Fatalf("got %d events, want 2 (one per state transition)", len(recent))
+	}
+	if recent[1].Data["state"] != "SUCCESS" {
+		t.Errorf("last state = %v, want SUCCESS", recent[1].Data["state"])
+	}
+	if len(w.jobStates) != 0 {
+		t.Errorf("terminal job still tracked: %v", w.jobStates)
+	}
+}
+
+func TestWatcherBufferAndSince(t *testing.T) {
+	w := newActiveWatcher(CategoryAlert)
+	for i := 0; i < 5; i++ {
+		w.handle(CategoryAlert, truenas.Event{
+			Type:   "added",
+			Fields: map[string]interface{}{"level": "ERROR"},
+		})
+	}
+
+	all := w.Recent(0, "", 0)
+	if len(all) != 3 {
+		t.Fatalf("buffer holds %d events, want capacity 3", len(all))
+	}
+	if all[0].Sequence != 3 {
+		t.Errorf("oldest sequence = %d, want 3", all[0].Sequence)
+	}
+	if newer := w.Recent(4, "", 0); len(newer) != 1 || newer[0].Sequence != 5 {
+		t.Errorf("Recent(4) = %v, want only sequence 5", newer)
+	}
+}
+
+func TestWatcherInactiveCategoryIgnored(t *testing.T) {
+	w := newActiveWatcher(CategoryAlert)
+	w.handle(CategoryJob, truenas.Event{
+		Type:   "added",
+		ID:     float64(1),
+		Fields: map[string]interface{}{"state": "RUNNING"},
+	})
+	if n := len(w.Recent(0, "", 0)); n != 0 {
+		t.Errorf("buffered %d events for inactive category, want 0", n)
+	}
+}
@@ -2,9 +2,9 @@ module github.com/truenas/truenas-mcp
 
 go 1.22
 
-require github.com/gorilla/websocket v1.5.1
-
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	golang.org/x/net v0.17.0 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.1
 )
+
+require golang.org/x/net v0.17.0 // indirect
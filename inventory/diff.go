@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"sort"
+)
+
+// Compare reports what changed between two snapshots, from older to newer
+func Compare(from, to *Snapshot) *Diff {
+	diff := &Diff{
+		From:     from.Summary(),
+		To:       to.Summary(),
+		Sections: map[string]SectionDiff{},
+	}
+
+	add := func(section string, d SectionDiff) {
+		if d.Empty() {
+			return
+		}
+		diff.Sections[section] = d
+		diff.Total += len(d.Added) + len(d.Removed) + len(d.Changed)
+	}
+
+	add("system", compareMaps(
+		map[string]string{"hostname": from.Hostname, "version": from.Version},
+		map[string]string{"hostname": to.Hostname, "version": to.Version},
+	))
+	add("pools", compareLists(from.Pools, to.Pools))
+	add("datasets", compareLists(from.Datasets, to.Datasets))
+	add("smb_shares", compareLists(from.SMBShares, to.SMBShares))
+	add("nfs_shares", compareLists(from.NFSShares, to.NFSShares))
+	add("apps", compareMaps(from.Apps, to.Apps))
+	add("vms", compareLists(from.VMs, to.VMs))
+	add("users", compareLists(from.Users, to.Users))
+	add("interfaces", compareMaps(from.Interfaces, to.Interfaces))
+
+	return diff
+}
+
+// compareLists reports names present in only one of the lists
+func compareLists(from, to []string) SectionDiff {
+	before := make(map[string]bool, len(from))
+	for _, name := range from {
+		before[name] = true
+	}
+	after := make(map[string]bool, len(to))
+	for _, name := range to {
+		after[name] = true
+	}
+
+	var d SectionDiff
+	for name := range after {
+		if !before[name] {
+			d.Added = append(d.Added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	return d
+}
+
+// compareMaps reports keys present in only one map and keys whose value changed
+func compareMaps(from, to map[string]string) SectionDiff {
+	var d SectionDiff
+	for name, value := range to {
+		old, ok := from[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, name)
+		case old != value:
+			d.Changed = append(d.Changed, Change{Name: name, From: old, To: value})
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return d.Changed[i].Name < d.Changed[j].Name
+	})
+	return d
+}
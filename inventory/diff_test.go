@@ -0,0 +1,98 @@
+package inventory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	from := &Snapshot{
+		ID:         "a",
+		TakenAt:    base,
+		Hostname:   "nas",
+		Version:    "25.04.0",
+		Pools:      []string{"tank"},
+		Datasets:   []string{"tank", "tank/media"},
+		SMBShares:  []string{"media", "scratch"},
+		NFSShares:  []string{"/mnt/tank/vms"},
+		Apps:       map[string]string{"plex": "1.40.0", "sonarr": "4.0.0"},
+		Users:      []string{"alice"},
+		Interfaces: map[string]string{"eno1": "10.0.0.5/24"},
+	}
+	to := &Snapshot{
+		ID:         "b",
+		TakenAt:    base.Add(24 * time.Hour),
+		Hostname:   "nas",
+		Version:    "25.04.1",
+		Pools:      []string{"tank"},
+		Datasets:   []string{"tank", "tank/media", "tank/backups"},
+		SMBShares:  []string{"media"},
+		NFSShares:  []string{"/mnt/tank/vms"},
+		Apps:       map[string]string{"plex": "1.41.0", "jellyfin": "10.9.0"},
+		Users:      []string{"alice"},
+		Interfaces: map[string]string{"eno1": "10.0.0.5/24"},
+	}
+
+	diff := Compare(from, to)
+
+	want := map[string]SectionDiff{
+		"system":     {Changed: []Change{{Name: "version", From: "25.04.0", To: "25.04.1"}}},
+		"datasets":   {Added: []string{"tank/backups"}},
+		"smb_shares": {Removed: []string{"scratch"}},
+		"apps": {
+			Added:   []string{"jellyfin"},
+			Removed: []string{"sonarr"},
+			Changed: []Change{{Name: "plex", From: "1.40.0", To: "1.41.0"}},
+		},
+	}
+	if !reflect.DeepEqual(diff.Sections, want) {
+		t.Errorf("Compare() sections = %+v, want %+v", diff.Sections, want)
+	}
+	if diff.Total != 6 {
+		t.Errorf("Compare() total = %d, want 6", diff.Total)
+	}
+	if diff.From.ID != "a" || diff.To.ID != "b" {
+		t.Errorf("Compare() endpoints = %s -> %s, want a -> b", diff.From.ID, diff.To.ID)
+	}
+
+	if same := Compare(to, to); same.Total != 0 || len(same.Sections) != 0 {
+		t.Errorf("Compare() of identical snapshots = %+v, want no changes", same)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.json")
+	store, err := NewStore(path, 2)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	store.Add(&Snapshot{ID: "1", Label: "baseline"})
+	store.Add(&Snapshot{ID: "2", Label: "pre-upgrade"})
+	store.Add(&Snapshot{ID: "3", Label: "pre-upgrade"})
+
+	if store.Get("1") != nil {
+		t.Errorf("snapshot 1 should have been dropped beyond the limit")
+	}
+	if got := store.Get("pre-upgrade"); got == nil || got.ID != "3" {
+		t.Errorf("Get(label) = %v, want the newest labelled snapshot", got)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, err := NewStore(path, 2)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	list := reloaded.List()
+	if len(list) != 2 || list[0].ID != "3" || list[1].ID != "2" {
+		t.Errorf("List() after reload = %+v, want 3 then 2", list)
+	}
+	if latest := reloaded.Latest(); latest == nil || latest.ID != "3" {
+		t.Errorf("Latest() = %v, want 3", latest)
+	}
+}
@@ -0,0 +1,121 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// Store provides thread-safe storage for saved snapshots, optionally persisted
+// to a JSON file so snapshots survive restarts
+type Store struct {
+	mu        sync.RWMutex
+	snapshots []*Snapshot // Oldest first
+	path      string
+	limit     int
+}
+
+// NewStore creates a new snapshot store keeping at most limit snapshots
+// (0 = unlimited). If path is non-empty, existing snapshots are loaded from it.
+func NewStore(path string, limit int) (*Store, error) {
+	s := &Store{path: path, limit: limit}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read inventory snapshots: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory snapshots %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Add stores a snapshot, dropping the oldest snapshots beyond the limit
+func (s *Store) Add(snapshot *Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshots = append(s.snapshots, snapshot)
+	if s.limit > 0 && len(s.snapshots) > s.limit {
+		s.snapshots = append([]*Snapshot(nil), s.snapshots[len(s.snapshots)-s.limit:]...)
+	}
+}
+
+// Get returns the snapshot with the given ID, or the newest snapshot with the
+// given label. Returns nil if none matches.
+func (s *Store) Get(ref string) *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, snapshot := range s.snapshots {
+		if snapshot.ID == ref {
+			return snapshot
+		}
+	}
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		if s.snapshots[i].Label == ref {
+			return s.snapshots[i]
+		}
+	}
+	return nil
+}
+
+// Latest returns the newest snapshot, or nil if none are saved
+func (s *Store) Latest() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.snapshots) == 0 {
+		return nil
+	}
+	return s.snapshots[len(s.snapshots)-1]
+}
+
+// List returns summaries of all snapshots, newest first
+func (s *Store) List() []Summary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summaries := make([]Summary, 0, len(s.snapshots))
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		summaries = append(summaries, s.snapshots[i].Summary())
+	}
+	return summaries
+}
+
+// Save writes the snapshots to disk (no-op for in-memory stores)
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	data, err := json.Marshal(s.snapshots)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory snapshots: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write inventory snapshots: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"time"
+)
+
+// Snapshot is a saved record of the system's configuration, detailed enough to
+// compare against another point in time
+type Snapshot struct {
+	ID         string            `json:"id"`
+	Label      string            `json:"label,omitempty"`
+	TakenAt    time.Time         `json:"taken_at"`
+	Hostname   string            `json:"hostname,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Pools      []string          `json:"pools"`
+	Datasets   []string          `json:"datasets"`
+	SMBShares  []string          `json:"smb_shares"`
+	NFSShares  []string          `json:"nfs_shares"`
+	Apps       map[string]string `json:"apps"` // App name to version
+	VMs        []string          `json:"vms"`
+	Users      []string          `json:"users"`
+	Interfaces map[string]string `json:"interfaces"` // Interface name to its addresses
+	Notes      []string          `json:"collection_notes,omitempty"`
+}
+
+// Summary describes a snapshot without its contents
+type Summary struct {
+	ID       string    `json:"id"`
+	Label    string    `json:"label,omitempty"`
+	TakenAt  time.Time `json:"taken_at"`
+	Hostname string    `json:"hostname,omitempty"`
+	Version  string    `json:"version,omitempty"`
+}
+
+// Summary returns the snapshot's identifying fields
+func (s *Snapshot) Summary() Summary {
+	return Summary{
+		ID:       s.ID,
+		Label:    s.Label,
+		TakenAt:  s.TakenAt,
+		Hostname: s.Hostname,
+		Version:  s.Version,
+	}
+}
+
+// Change is a value that differs between two snapshots
+type Change struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SectionDiff lists what was added, removed, or changed in one section
+type SectionDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []Change `json:"changed,omitempty"`
+}
+
+// Empty reports whether the section is unchanged
+func (d SectionDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff is the set of changes between two snapshots. Unchanged sections are omitted.
+type Diff struct {
+	From     Summary                `json:"from"`
+	To       Summary                `json:"to"`
+	Sections map[string]SectionDiff `json:"changes"`
+	Total    int                    `json:"total_changes"`
+}
@@ -0,0 +1,122 @@
+// Package logging configures the structured logger shared by the server and
+// the proxy.
+//
+// Log records go to stderr or a file, never stdout, which carries the MCP
+// protocol, and have credentials masked (see package redact). Output from
+// the standard log package is routed through the same handler at info level,
+// so packages that still call log.Printf end up in the same stream and
+// format.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/redact"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config selects where and how log records are written
+type Config struct {
+	// Level is the minimum level logged: debug, info, warn, or error
+	Level string
+
+	// Format is text (key=value pairs) or json (one object per line)
+	Format string
+
+	// File appends records to this path instead of writing to stderr
+	File string
+}
+
+// ParseLevel converts a level name to its slog level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("log level must be debug, info, warn, or error, got: %s", name)
+}
+
+// NewLogger builds a logger writing to w according to cfg
+func NewLogger(w io.Writer, cfg Config) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("log format must be text or json, got: %s", cfg.Format)
+}
+
+// redactAttr masks credentials in every record: values logged under
+// credential names, and credentials inside messages, errors, and logged
+// payloads (the JSON-RPC messages dumped at debug level, say)
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if redact.IsSensitiveKey(a.Key) && a.Value.String() != "" {
+			return slog.String(a.Key, redact.Masked)
+		}
+		a.Value = slog.StringValue(redact.JSON(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case []byte:
+			a.Value = slog.StringValue(redact.JSON(string(v)))
+		case error:
+			masked, _ := redact.String(v.Error())
+			a.Value = slog.StringValue(masked)
+		}
+	}
+	return a
+}
+
+// Setup installs the logger described by cfg as the process default. The
+// returned closer closes the log file, if any.
+func Setup(cfg Config) (io.Closer, error) {
+	var w io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w, closer = f, f
+	}
+
+	logger, err := NewLogger(w, cfg)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Fatal logs msg at error level and exits with status 1
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"", slog.LevelInfo},
+		{"INFO", slog.LevelInfo},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+	for _, tt := range tests {
+		if got, err := ParseLevel(tt.name); err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded, want an error")
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, Config{Level: "warn", Format: "json"})
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+	logger.Info("dropped")
+	logger.Warn("reconnecting", "attempt", 2)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("logged %d lines, want only the warning:\n%s", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("record is not JSON: %s", lines[0])
+	}
+	if record["msg"] != "reconnecting" || record["level"] != "WARN" || record["attempt"] != float64(2) {
+		t.Errorf("record = %v", record)
+	}
+
+	if _, err := NewLogger(&buf, Config{Format: "xml"}); err == nil {
+		t.Error("NewLogger accepted format xml")
+	}
+}
+
+func TestLoggerRedactsCredentials(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, Config{Level: "debug"})
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+	logger.Debug("Received message on stdin", "message", []byte(`{"method":"tools/call","params":{"name":"configure_directory_service","arguments":{"bindpw":"hunter2"}}}`))
+	logger.Info("Connecting", "password", "swordfish", "url", "wss://nas.local/websocket")
+	logger.Warn("Login failed for api_key=1-abcdef")
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "swordfish", "1-abcdef"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log still contains %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "configure_directory_service") || !strings.Contains(out, "wss://nas.local/websocket") {
+		t.Errorf("log lost non-credential values:\n%s", out)
+	}
+}
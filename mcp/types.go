@@ -1,5 +1,7 @@
 package mcp
 
+import "context"
+
 // JSON-RPC 2.0 message types
 
 type Request struct {
@@ -36,7 +38,24 @@ type ServerInfo struct {
 }
 
 type Capabilities struct {
-	Tools map[string]interface{} `json:"tools,omitempty"`
+	Tools     map[string]interface{} `json:"tools,omitempty"`
+	Resources map[string]interface{} `json:"resources,omitempty"`
+	Prompts   map[string]interface{} `json:"prompts,omitempty"`
+	Logging   map[string]interface{} `json:"logging,omitempty"`
+}
+
+// Notification is a server-initiated JSON-RPC message that expects no response
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// LogMessageParams is the payload of a notifications/message notification
+type LogMessageParams struct {
+	Level  string      `json:"level"`
+	Logger string      `json:"logger,omitempty"`
+	Data   interface{} `json:"data"`
 }
 
 type Tool struct {
@@ -55,8 +74,10 @@ type ToolCallParams struct {
 }
 
 type ToolCallResult struct {
-	Content []ContentBlock `json:"content"`
-	IsError bool           `json:"isError,omitempty"`
+	Content           []ContentBlock         `json:"content"`
+	StructuredContent interface{}            `json:"structuredContent,omitempty"`
+	IsError           bool                   `json:"isError,omitempty"`
+	Meta              map[string]interface{} `json:"_meta,omitempty"`
 }
 
 type ContentBlock struct {
@@ -64,8 +85,80 @@ type ContentBlock struct {
 	Text string `json:"text"`
 }
 
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+type ResourceReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
 // ToolRegistry interface for tool management
 type ToolRegistry interface {
 	ListTools() []Tool
 	CallTool(name string, args map[string]interface{}) (string, error)
+	CallToolWithCorrelationID(correlationID, name string, args map[string]interface{}) (string, error)
+	// CallToolInSession is CallToolWithCorrelationID on behalf of an MCP
+	// session, applying state kept for that session. The call is abandoned
+	// when ctx is cancelled.
+	CallToolInSession(ctx context.Context, sessionID, correlationID, name string, args map[string]interface{}) (string, error)
+	// EndSession discards state kept for a closed session
+	EndSession(sessionID string)
+	ListResources() []Resource
+	ListResourceTemplates() []ResourceTemplate
+	// ReadResource returns a resource's content. The read is abandoned
+	// when ctx is cancelled.
+	ReadResource(ctx context.Context, uri string) (string, error)
+	ListPrompts() []Prompt
+	GetPrompt(name string, args map[string]string) (*PromptGetResult, error)
 }
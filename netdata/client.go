@@ -0,0 +1,237 @@
+// Package netdata proxies selected Netdata chart queries so short-window
+// investigations can use per-second data that reporting.get_data does not keep.
+package netdata
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxWindow is the longest time window a chart query may cover. Netdata keeps
+// per-second data only for a short period, and longer ranges belong to get_metrics.
+const MaxWindow = time.Hour
+
+// DefaultAllowedCharts are the chart ID prefixes exposed when none are configured
+var DefaultAllowedCharts = []string{
+	"system.",
+	"cpu.",
+	"mem.",
+	"disk.",
+	"disk_ops.",
+	"disk_await.",
+	"disk_util.",
+	"net.",
+	"zfs.",
+	"nfsd.",
+}
+
+// groupMethods are the Netdata grouping methods accepted for downsampling
+var groupMethods = map[string]bool{"average": true, "max": true, "min": true}
+
+// Config configures the Netdata passthrough
+type Config struct {
+	URL           string        // Netdata base URL, e.g. http://truenas.local:19999 ("" = disabled)
+	AllowedCharts []string      // Chart ID prefixes that may be queried (nil = DefaultAllowedCharts)
+	Timeout       time.Duration // HTTP request timeout (0 = 10s)
+	TLSConfig     *tls.Config   // TLS settings for https URLs
+}
+
+// Chart describes one Netdata chart
+type Chart struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Units       string   `json:"units"`
+	Family      string   `json:"family,omitempty"`
+	UpdateEvery int      `json:"update_every_seconds"`
+	Dimensions  []string `json:"dimensions"`
+}
+
+// Query selects the time window and resolution of a chart query
+type Query struct {
+	Window time.Duration // How far back from now (1s to MaxWindow)
+	Points int           // Points returned (0 = one per collected sample)
+	Group  string        // How samples are combined into points: average, max, min
+}
+
+// Series is the result of a chart query. Rows are [timestamp, value1, ...],
+// oldest first, with columns named by Labels.
+type Series struct {
+	Chart  string          `json:"chart"`
+	Labels []string        `json:"labels"`
+	Data   [][]interface{} `json:"data"`
+	Window string          `json:"window"`
+	Group  string          `json:"group"`
+}
+
+// Client queries a Netdata agent's HTTP API
+type Client struct {
+	baseURL *url.URL
+	allowed []string
+	http    *http.Client
+}
+
+// NewClient creates a Netdata client. It returns nil when no URL is configured.
+func NewClient(config Config) (*Client, error) {
+	if config.URL == "" {
+		return nil, nil
+	}
+
+	baseURL, err := url.Parse(strings.TrimRight(config.URL, "/"))
+	if err != nil || baseURL.Host == "" || (baseURL.Scheme != "http" && baseURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid Netdata URL %q: must be http(s)://host[:port]", config.URL)
+	}
+
+	allowed := config.AllowedCharts
+	if allowed == nil {
+		allowed = DefaultAllowedCharts
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &Client{
+		baseURL: baseURL,
+		allowed: allowed,
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: config.TLSConfig},
+		},
+	}, nil
+}
+
+// AllowedCharts returns the configured chart ID prefixes
+func (c *Client) AllowedCharts() []string {
+	return c.allowed
+}
+
+// Allowed reports whether a chart ID matches an allowed prefix
+func (c *Client) Allowed(chart string) bool {
+	for _, prefix := range c.allowed {
+		if strings.HasPrefix(chart, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Charts lists the allowed charts the agent collects, sorted by ID
+func (c *Client) Charts() ([]Chart, error) {
+	var response struct {
+		Charts map[string]struct {
+			ID          string `json:"id"`
+			Title       string `json:"title"`
+			Units       string `json:"units"`
+			Family      string `json:"family"`
+			UpdateEvery int    `json:"update_every"`
+			Dimensions  map[string]struct {
+				Name string `json:"name"`
+			} `json:"dimensions"`
+		} `json:"charts"`
+	}
+	if err := c.get("/api/v1/charts", nil, &response); err != nil {
+		return nil, err
+	}
+
+	charts := make([]Chart, 0, len(response.Charts))
+	for id, raw := range response.Charts {
+		if !c.Allowed(id) {
+			continue
+		}
+		dimensions := make([]string, 0, len(raw.Dimensions))
+		for _, dim := range raw.Dimensions {
+			dimensions = append(dimensions, dim.Name)
+		}
+		sort.Strings(dimensions)
+		charts = append(charts, Chart{
+			ID:          id,
+			Title:       raw.Title,
+			Units:       raw.Units,
+			Family:      raw.Family,
+			UpdateEvery: raw.UpdateEvery,
+			Dimensions:  dimensions,
+		})
+	}
+
+	sort.Slice(charts, func(i, j int) bool { return charts[i].ID < charts[j].ID })
+	return charts, nil
+}
+
+// Data fetches recent samples of an allowed chart
+func (c *Client) Data(chart string, q Query) (*Series, error) {
+	if !c.Allowed(chart) {
+		return nil, fmt.Errorf("chart %q is not allowed (allowed prefixes: %s)", chart, strings.Join(c.allowed, ", "))
+	}
+	if q.Window < time.Second || q.Window > MaxWindow {
+		return nil, fmt.Errorf("window must be between 1s and %s, got %s", MaxWindow, q.Window)
+	}
+	if q.Points < 0 {
+		return nil, fmt.Errorf("points must not be negative")
+	}
+	if q.Group == "" {
+		q.Group = "average"
+	}
+	if !groupMethods[q.Group] {
+		return nil, fmt.Errorf("group must be average, max, or min, got: %s", q.Group)
+	}
+
+	params := url.Values{}
+	params.Set("chart", chart)
+	params.Set("after", strconv.Itoa(-int(q.Window/time.Second)))
+	params.Set("before", "0")
+	params.Set("group", q.Group)
+	params.Set("format", "json")
+	// Timestamps in seconds, oldest row first
+	params.Set("options", "seconds|flip")
+	if q.Points > 0 {
+		params.Set("points", strconv.Itoa(q.Points))
+	}
+
+	var response struct {
+		Labels []string        `json:"labels"`
+		Data   [][]interface{} `json:"data"`
+	}
+	if err := c.get("/api/v1/data", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &Series{
+		Chart:  chart,
+		Labels: response.Labels,
+		Data:   response.Data,
+		Window: q.Window.String(),
+		Group:  q.Group,
+	}, nil
+}
+
+// get performs a GET request against the agent and decodes the JSON response
+func (c *Client) get(path string, params url.Values, out interface{}) error {
+	endpoint := *c.baseURL
+	endpoint.Path = strings.TrimRight(endpoint.Path, "/") + path
+	endpoint.RawQuery = params.Encode()
+
+	resp, err := c.http.Get(endpoint.String())
+	if err != nil {
+		return fmt.Errorf("netdata request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("netdata returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse netdata response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package netdata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Config{URL: server.URL, AllowedCharts: []string{"system.", "disk."}})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return client
+}
+
+func TestNewClientDisabled(t *testing.T) {
+	client, err := NewClient(Config{})
+	if err != nil || client != nil {
+		t.Errorf("NewClient with no URL = %v, %v; want nil, nil", client, err)
+	}
+}
+
+func TestNewClientInvalidURL(t *testing.T) {
+	for _, raw := range []string{"truenas.local:19999", "ftp://truenas.local", "http://"} {
+		if _, err := NewClient(Config{URL: raw}); err == nil {
+			t.Errorf("NewClient(%q) expected error", raw)
+		}
+	}
+}
+
+func TestChartsFiltersAllowed(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/charts" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"charts": map[string]interface{}{
+				"system.cpu": map[string]interface{}{
+					"title": "Total CPU utilization", "units": "percentage", "update_every": 1,
+					"dimensions": map[string]interface{}{"user": map[string]string{"name": "user"}, "system": map[string]string{"name": "system"}},
+				},
+				"disk.sda":      map[string]interface{}{"title": "Disk I/O", "units": "KiB/s", "update_every": 1},
+				"apps.cpu_user": map[string]interface{}{"title": "Apps CPU", "units": "percentage", "update_every": 1},
+			},
+		})
+	})
+
+	charts, err := client.Charts()
+	if err != nil {
+		t.Fatalf("Charts failed: %v", err)
+	}
+	if len(charts) != 2 || charts[0].ID != "disk.sda" || charts[1].ID != "system.cpu" {
+		t.Fatalf("unexpected charts: %+v", charts)
+	}
+	if strings.Join(charts[1].Dimensions, ",") != "system,user" {
+		t.Errorf("dimensions = %v, want [system user]", charts[1].Dimensions)
+	}
+}
+
+func TestDataQueryParameters(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v1/data" || q.Get("chart") != "system.cpu" || q.Get("after") != "-120" ||
+			q.Get("points") != "12" || q.Get("group") != "max" || q.Get("options") != "seconds|flip" {
+			http.Error(w, "unexpected query: "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"labels": []string{"time", "user"},
+			"data":   [][]interface{}{{1700000000, 12.5}, {1700000010, 40.1}},
+		})
+	})
+
+	series, err := client.Data("system.cpu", Query{Window: 2 * time.Minute, Points: 12, Group: "max"})
+	if err != nil {
+		t.Fatalf("Data failed: %v", err)
+	}
+	if len(series.Data) != 2 || series.Labels[1] != "user" || series.Group != "max" {
+		t.Errorf("unexpected series: %+v", series)
+	}
+}
+
+func TestDataRejectsInvalidQueries(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request: %s", r.URL)
+	})
+
+	tests := []struct {
+		name  string
+		chart string
+		query Query
+	}{
+		{"chart not allowed", "apps.cpu_user", Query{Window: time.Minute}},
+		{"window too long", "system.cpu", Query{Window: 2 * time.Hour}},
+		{"window too short", "system.cpu", Query{}},
+		{"unknown group", "system.cpu", Query{Window: time.Minute, Group: "median"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Data(tt.chart, tt.query); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestDataReportsHTTPErrors(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "chart not found", http.StatusNotFound)
+	})
+
+	_, err := client.Data("system.missing", Query{Window: time.Minute})
+	if err == nil || !strings.Contains(err.Error(), "chart not found") {
+		t.Errorf("expected error carrying response body, got %v", err)
+	}
+}
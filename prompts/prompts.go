@@ -0,0 +1,168 @@
+// Package prompts provides built-in MCP prompt templates: operational
+// runbooks that walk a model through a task using the server's tools.
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+// ErrNotFound is returned for unknown prompt names
+var ErrNotFound = errors.New("prompt not found")
+
+// Prompt is a runbook template
+type Prompt struct {
+	Definition mcp.Prompt
+
+	// Tools lists the tools the runbook asks the model to use
+	Tools []string
+
+	render func(args map[string]string) string
+}
+
+// Render fills in the runbook with args, which must include every required
+// argument
+func (p *Prompt) Render(args map[string]string) (*mcp.PromptGetResult, error) {
+	for _, arg := range p.Definition.Arguments {
+		if arg.Required && strings.TrimSpace(args[arg.Name]) == "" {
+			return nil, fmt.Errorf("prompt %s requires argument %s", p.Definition.Name, arg.Name)
+		}
+	}
+	return &mcp.PromptGetResult{
+		Description: p.Definition.Description,
+		Messages: []mcp.PromptMessage{{
+			Role:    "user",
+			Content: mcp.ContentBlock{Type: "text", Text: p.render(args)},
+		}},
+	}, nil
+}
+
+// Lookup returns the prompt with the given name
+func Lookup(name string) (*Prompt, error) {
+	for i := range catalog {
+		if catalog[i].Definition.Name == name {
+			return &catalog[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// List returns every prompt definition sorted by name
+func List() []mcp.Prompt {
+	list := make([]mcp.Prompt, 0, len(catalog))
+	for _, p := range catalog {
+		list = append(list, p.Definition)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// orDefault returns args[name], or fallback when it is empty
+func orDefault(args map[string]string, name, fallback string) string {
+	if value := strings.TrimSpace(args[name]); value != "" {
+		return value
+	}
+	return fallback
+}
+
+var catalog = []Prompt{
+	{
+		Definition: mcp.Prompt{
+			Name:        "diagnose_pool_degradation",
+			Description: "Find out why a pool is degraded or unhealthy and recommend next steps",
+			Arguments: []mcp.PromptArgument{
+				{Name: "pool", Description: "Pool name (e.g., tank)", Required: true},
+			},
+		},
+		Tools: []string{"query_pools", "storage_health_report", "list_alerts", "get_scrub_status", "analyze_disk_latency", "query_jobs"},
+		render: func(args map[string]string) string {
+			pool := args["pool"]
+			return fmt.Sprintf(`Diagnose the health of TrueNAS pool %[1]q. Gather evidence before drawing conclusions, and do not make changes.
+
+1. Call query_pools and find %[1]q: note its status, healthy flag, and every vdev or disk that is not ONLINE (DEGRADED, FAULTED, UNAVAIL, REMOVED) along with its read/write/checksum error counts.
+2. Call storage_health_report for SMART test failures, disk temperatures, and pool error summaries; match failing disks to the vdevs found in step 1.
+3. Call list_alerts and pick out alerts that mention %[1]q or its disks.
+4. Call get_scrub_status for %[1]q: when did the last scrub run, did it finish, and did it repair or find errors? Is a scrub or resilver running now?
+5. Call analyze_disk_latency to spot disks that are slow but not yet failed.
+6. Call query_jobs for running or recently failed pool jobs (resilver, scrub, replace).
+
+Then report:
+- The root cause, or the most likely causes ranked with the evidence for each
+- Which disks need attention, identified by name and serial number
+- Whether data is at risk now (remaining redundancy in each affected vdev)
+- Recommended next steps in order (e.g., replace a disk, run a scrub with run_scrub, check cabling), marking any step that changes the system so it can be confirmed first`, pool)
+		},
+	},
+	{
+		Definition: mcp.Prompt{
+			Name:        "plan_app_install",
+			Description: "Plan and preview an application install with host-path storage, without installing yet",
+			Arguments: []mcp.PromptArgument{
+				{Name: "app", Description: "Catalog app to install (e.g., jellyfin)", Required: true},
+				{Name: "pool", Description: "Pool for the app's datasets (default: ask or pick the pool with the most free space)"},
+			},
+		},
+		Tools: []string{"search_app_catalog", "get_app_catalog_details", "query_apps", "query_pools", "install_app"},
+		render: func(args map[string]string) string {
+			app := args["app"]
+			pool := orDefault(args, "pool", "the pool with the most free space (confirm with me)")
+			return fmt.Sprintf(`Help me plan installing the %[1]q app on TrueNAS. Do not install anything until I approve the plan.
+
+1. Call search_app_catalog for %[1]q to confirm the catalog app name and train.
+2. Call get_app_catalog_details and review every schema group (app settings, user/group, network, storage, labels, resources).
+3. Call query_apps to make sure no installed app already uses the instance name or the ports you intend to use.
+4. Call query_pools and plan datasets on %[2]s using the layout <pool>/apps/<app>/<volume> for each persistent storage volume. Storage must be host_path, never ix_volume.
+5. Build the complete values object and call install_app with dry_run=true and create_datasets=true to preview the datasets it would create and any warnings.
+
+Present the plan: instance name, version, ports, each storage volume with its dataset path, resource limits, and the dry-run warnings. After I approve, call install_app with the same arguments and dry_run=false, then track the returned task with tasks_get. If a step fails, explain the cause and offer to resume with resume_task_id.`, app, pool)
+		},
+	},
+	{
+		Definition: mcp.Prompt{
+			Name:        "pre_update_checklist",
+			Description: "Check that the system is ready for a TrueNAS update and summarize the risks",
+		},
+		Tools: []string{"check_updates", "get_update_changelog", "update_status", "storage_health_report", "list_alerts", "query_jobs", "query_boot_environments", "plan_boot_environment_cleanup", "apply_update"},
+		render: func(args map[string]string) string {
+			return `Run a pre-update checklist for this TrueNAS system. Do not apply the update.
+
+1. Call check_updates and update_status: which version is available, and is an update already downloaded or in progress?
+2. Call get_update_changelog and summarize changes that matter for this system (apps, shares, directory services, deprecated features).
+3. Call storage_health_report and list_alerts: every pool must be healthy, and critical alerts must be understood before updating.
+4. Call query_jobs for running scrubs, resilvers, replications, or app jobs that an update reboot would interrupt.
+5. Call query_boot_environments and check the boot pool has room for a new boot environment; if it is tight, call plan_boot_environment_cleanup for candidates (do not delete anything).
+6. Call apply_update with dry_run=true to run the preflight checks.
+
+Finish with a go / no-go recommendation, each blocking issue with how to resolve it, the expected downtime, and how to roll back (activating the current boot environment) if the update goes wrong.`
+		},
+	},
+	{
+		Definition: mcp.Prompt{
+			Name:        "capacity_review",
+			Description: "Review storage capacity and growth, and flag pools and datasets that will fill up soon",
+			Arguments: []mcp.PromptArgument{
+				{Name: "pool", Description: "Limit the review to one pool (default: all pools)"},
+			},
+		},
+		Tools: []string{"analyze_capacity", "get_pool_capacity_details", "forecast_dataset_growth", "configure_capacity_alerts"},
+		render: func(args map[string]string) string {
+			scope := "every pool"
+			if pool := strings.TrimSpace(args["pool"]); pool != "" {
+				scope = fmt.Sprintf("pool %q", pool)
+			}
+			return fmt.Sprintf(`Review storage capacity for %s on this TrueNAS system. Do not make changes.
+
+1. Call get_pool_capacity_details for current usage, the per-dataset breakdown, and pool growth projections.
+2. Call analyze_capacity for historical trends.
+3. Call forecast_dataset_growth for per-dataset growth rates and when each dataset hits its quota or the pool fills. If history tracking is disabled, say so and base the review on current usage only.
+
+Report pools above 80%% used, the datasets growing fastest, and anything projected to fill within 90 days. Recommend actions such as quotas, snapshot retention changes, or expansion, and suggest quota alert thresholds that configure_capacity_alerts could set (preview them with dry_run=true first).`, scope)
+		},
+	},
+}
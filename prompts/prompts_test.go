@@ -0,0 +1,60 @@
+package prompts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	prompt, err := Lookup("diagnose_pool_degradation")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	result, err := prompt.Render(map[string]string{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].Role != "user" {
+		t.Fatalf("messages = %+v", result.Messages)
+	}
+	text := result.Messages[0].Content.Text
+	if !strings.Contains(text, `pool "tank"`) || !strings.Contains(text, "get_scrub_status") {
+		t.Errorf("rendered prompt = %q", text)
+	}
+
+	if _, err := prompt.Render(map[string]string{"pool": " "}); err == nil {
+		t.Error("Render accepted a missing required argument")
+	}
+}
+
+func TestListAndLookup(t *testing.T) {
+	list := List()
+	if len(list) != len(catalog) {
+		t.Fatalf("List returned %d prompts, want %d", len(list), len(catalog))
+	}
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Name >= list[i].Name {
+			t.Errorf("prompts not sorted: %s before %s", list[i-1].Name, list[i].Name)
+		}
+	}
+
+	// Optional arguments may be omitted
+	for _, p := range list {
+		prompt, _ := Lookup(p.Name)
+		args := map[string]string{}
+		for _, arg := range p.Arguments {
+			if arg.Required {
+				args[arg.Name] = "x"
+			}
+		}
+		if _, err := prompt.Render(args); err != nil {
+			t.Errorf("%s: Render failed: %v", p.Name, err)
+		}
+	}
+
+	if _, err := Lookup("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup error = %v, want ErrNotFound", err)
+	}
+}
@@ -5,15 +5,25 @@ import (
 	"flag"
 	"os"
 	"time"
+
+	"github.com/truenas/truenas-mcp/logging"
+)
+
+// Transports the proxy offers to local clients
+const (
+	TransportStdio = "stdio"
+	TransportSSE   = "sse"
 )
 
 // Config holds proxy configuration
 type Config struct {
-	ServerURL string
-	APIKey    string
-	Timeout   time.Duration
-	Debug     bool
-	Insecure  bool
+	ServerURL  string
+	APIKey     string
+	Timeout    time.Duration
+	Insecure   bool
+	Transport  string // Client-facing transport: stdio or sse
+	ListenAddr string // Listen address for the sse transport
+	Log        logging.Config
 }
 
 // LoadConfig loads configuration from flags and environment variables
@@ -21,11 +31,16 @@ func LoadConfig() (*Config, error) {
 	cfg := &Config{}
 
 	// Define flags
-	serverURL := flag.String("server-url", "", "TrueNAS MCP server URL (e.g., http://192.168.0.31:8089)")
-	apiKey := flag.String("api-key", "", "API key for authentication")
+	serverURL := flag.String("server-url", "", "TrueNAS MCP server URL (e.g., http://192.168.0.31:8080/mcp)")
+	apiKey := flag.String("api-key", "", "API key sent as a bearer token (for a reverse proxy that authenticates clients)")
 	timeout := flag.Duration("timeout", 30*time.Second, "Request timeout")
-	debug := flag.Bool("debug", false, "Enable debug logging")
+	debug := flag.Bool("debug", false, "Enable debug logging (same as --log-level debug)")
+	logLevel := flag.String("log-level", "info", "Minimum log level: debug, info, warn, or error")
+	logFormat := flag.String("log-format", logging.FormatText, "Log format: 'text' or 'json'")
+	logFile := flag.String("log-file", "", "Append logs to this file instead of stderr")
 	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification (not recommended)")
+	transport := flag.String("transport", TransportStdio, "Transport offered to MCP clients: 'stdio' or 'sse' (HTTP+SSE for clients without stdio support)")
+	listen := flag.String("listen", "127.0.0.1:8090", "Listen address for the sse transport")
 	version := flag.Bool("version", false, "Print version and exit")
 
 	flag.Parse()
@@ -47,17 +62,18 @@ func LoadConfig() (*Config, error) {
 	}
 
 	cfg.Timeout = *timeout
-	cfg.Debug = *debug
+	cfg.Log = logging.Config{Level: *logLevel, Format: *logFormat, File: *logFile}
+	if *debug {
+		cfg.Log.Level = "debug"
+	}
 	cfg.Insecure = *insecure
+	cfg.Transport = *transport
+	cfg.ListenAddr = *listen
 
 	// Validate required fields
 	if cfg.ServerURL == "" {
 		return nil, errors.New("server URL is required (use --server-url or TRUENAS_MCP_SERVER_URL)")
 	}
 
-	if cfg.APIKey == "" {
-		return nil, errors.New("API key is required (use --api-key or TRUENAS_MCP_API_KEY)")
-	}
-
 	return cfg, nil
 }
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+// Proxy bridges local MCP clients to a remote truenas-mcp server running
+// the Streamable HTTP transport (--transport http). Clients connect over
+// stdio or, for clients that only speak it, the HTTP+SSE transport.
+type Proxy struct {
+	cfg    *Config
+	client *http.Client
+}
+
+// NewProxy creates a proxy for the server at cfg.ServerURL
+func NewProxy(cfg *Config) (*Proxy, error) {
+	u, err := url.Parse(cfg.ServerURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("server URL must be an http:// or https:// URL, got: %s", cfg.ServerURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		// The server's default endpoint
+		u.Path = "/mcp"
+	}
+
+	switch cfg.Transport {
+	case "":
+		cfg.Transport = TransportStdio
+	case TransportStdio, TransportSSE:
+	default:
+		return nil, fmt.Errorf("transport must be '%s' or '%s', got: %s", TransportStdio, TransportSSE, cfg.Transport)
+	}
+
+	resolved := *cfg
+	resolved.ServerURL = u.String()
+
+	return &Proxy{
+		cfg: &resolved,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.Insecure},
+			},
+		},
+	}, nil
+}
+
+// Run serves clients on the configured transport until they disconnect
+// (stdio) or the process is signalled (SSE)
+func (p *Proxy) Run() error {
+	if p.cfg.Transport == TransportSSE {
+		return newSSEServer(p).Run(p.cfg.ListenAddr)
+	}
+	return p.runStdio()
+}
+
+// runStdio forwards stdin messages upstream and writes responses and
+// notifications to stdout
+func (p *Proxy) runStdio() error {
+	stdio := NewStdioHandler()
+	up := newUpstream(p.cfg, p.client, func(msg json.RawMessage) {
+		if err := stdio.WriteMessage(msg); err != nil {
+			slog.Error("Failed to write notification", "error", err)
+		}
+	})
+	defer up.Close()
+
+	// Requests run concurrently so a long tool call does not hold up others
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		req, err := stdio.ReadRequest()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, ErrInvalidRequest) {
+			stdio.WriteError(nil, -32700, err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// initialize must complete before anything else is sent upstream
+		if req.Method == "initialize" {
+			p.forward(stdio, up, req)
+			continue
+		}
+		wg.Add(1)
+		go func(req *mcp.Request) {
+			defer wg.Done()
+			p.forward(stdio, up, req)
+		}(req)
+	}
+}
+
+func (p *Proxy) forward(stdio *StdioHandler, up *upstream, req *mcp.Request) {
+	resp, err := up.Send(req)
+	if err != nil {
+		slog.Warn("Upstream request failed", "method", req.Method, "error", err)
+		if req.ID != nil {
+			stdio.WriteError(req.ID, -32603, fmt.Sprintf("Upstream error: %v", err))
+		}
+		return
+	}
+	if resp != nil {
+		if err := stdio.WriteResponse(resp); err != nil {
+			slog.Error("Failed to write response", "error", err)
+		}
+	}
+}
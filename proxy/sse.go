@@ -0,0 +1,358 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+const (
+	// sseHistory is how many recent events each session keeps for resume
+	sseHistory = 256
+
+	// sseReconnectGrace is how long a disconnected session waits for its
+	// client to reconnect before it is closed
+	sseReconnectGrace = 5 * time.Minute
+
+	// sseRetry is the reconnection delay suggested to clients
+	sseRetry = 3 * time.Second
+
+	sseKeepAlive = 30 * time.Second
+)
+
+// sseServer serves the MCP HTTP+SSE transport to local clients: each client
+// holds a GET /sse event stream and POSTs messages to the endpoint announced
+// on it. Responses and notifications are delivered as numbered events, so a
+// client that reconnects with Last-Event-ID receives what it missed.
+type sseServer struct {
+	proxy *Proxy
+
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+// sseSession is one SSE client and its upstream session
+type sseSession struct {
+	id       string
+	upstream *upstream
+
+	mu           sync.Mutex
+	seq          int64
+	history      []sseEvent    // Most recent events, oldest first
+	wake         chan struct{} // Signals the open stream that events were added
+	connected    bool
+	disconnected time.Time
+}
+
+func newSSEServer(p *Proxy) *sseServer {
+	return &sseServer{proxy: p, sessions: make(map[string]*sseSession)}
+}
+
+// Run serves until SIGINT or SIGTERM
+func (s *sseServer) Run(addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Request contexts end with ctx so open streams close on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go s.reapSessions(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Serving MCP over SSE", "url", "http://"+addr+"/sse", "upstream", s.proxy.cfg.ServerURL)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("SSE server error: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("SSE server shutdown: %w", err)
+	}
+
+	s.mu.Lock()
+	for id, session := range s.sessions {
+		session.upstream.Close()
+		delete(s.sessions, id)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *sseServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", s.handleStream)
+	mux.HandleFunc("/message", s.handleMessage)
+	return mux
+}
+
+// handleStream opens (or resumes) a session's event stream
+func (s *sseServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	session, next := s.resume(r.Header.Get("Last-Event-ID"))
+	if session == nil {
+		session = s.newSession()
+		next = 1
+	}
+
+	session.mu.Lock()
+	if session.connected {
+		session.mu.Unlock()
+		http.Error(w, "Session already has an open stream", http.StatusConflict)
+		return
+	}
+	session.connected = true
+	session.mu.Unlock()
+
+	defer func() {
+		session.mu.Lock()
+		session.connected = false
+		session.disconnected = time.Now()
+		session.mu.Unlock()
+		slog.Debug("SSE session disconnected", "session", session.id)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// The endpoint event is re-sent on every connection; it carries no ID
+	// so it does not disturb the client's resume position
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	fmt.Fprintf(w, "event: endpoint\ndata: /message?session_id=%s\n\n", session.id)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		events, wake := session.since(next)
+		for _, ev := range events {
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: message\ndata: %s\n\n", ev.ID, ev.Data); err != nil {
+				return
+			}
+			next = eventSeq(ev.ID) + 1
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleMessage accepts a client message and delivers the response on the
+// session's event stream
+func (s *sseServer) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[r.URL.Query().Get("session_id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return
+	}
+
+	var req mcp.Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, 10<<20)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON-RPC message: %v", err), http.StatusBadRequest)
+		return
+	}
+	slog.Debug("Forwarding SSE request", "method", req.Method, "id", req.ID, "session", session.id)
+
+	w.WriteHeader(http.StatusAccepted)
+
+	go func() {
+		resp, err := session.upstream.Send(&req)
+		if err != nil {
+			slog.Warn("Upstream request failed", "method", req.Method, "session", session.id, "error", err)
+			if req.ID == nil {
+				return
+			}
+			resp = errorResponse(req.ID, -32603, fmt.Sprintf("Upstream error: %v", err))
+		}
+		if resp == nil {
+			return
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			slog.Error("Failed to marshal response", "error", err)
+			return
+		}
+		session.push(data)
+	}()
+}
+
+func (s *sseServer) newSession() *sseSession {
+	session := &sseSession{
+		id:           uuid.New().String(),
+		wake:         make(chan struct{}, 1),
+		disconnected: time.Now(),
+	}
+	session.upstream = newUpstream(s.proxy.cfg, s.proxy.client, session.push)
+
+	s.mu.Lock()
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+
+	slog.Debug("Created SSE session", "session", session.id)
+	return session
+}
+
+// resume finds the session named by a Last-Event-ID and the sequence number
+// to continue from
+func (s *sseServer) resume(lastEventID string) (*sseSession, int64) {
+	if lastEventID == "" {
+		return nil, 0
+	}
+	id, _ := splitEventID(lastEventID)
+
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, 0
+	}
+	return session, eventSeq(lastEventID) + 1
+}
+
+// reapSessions closes sessions whose client has not reconnected in time
+func (s *sseServer) reapSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.expireSessions(now)
+		}
+	}
+}
+
+func (s *sseServer) expireSessions(now time.Time) {
+	s.mu.Lock()
+	var expired []*sseSession
+	for id, session := range s.sessions {
+		session.mu.Lock()
+		if !session.connected && now.Sub(session.disconnected) > sseReconnectGrace {
+			expired = append(expired, session)
+			delete(s.sessions, id)
+		}
+		session.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	for _, session := range expired {
+		slog.Debug("Closing SSE session after its client did not reconnect", "session", session.id)
+		session.upstream.Close()
+	}
+}
+
+// push records a message as the session's next event and wakes its stream
+func (s *sseSession) push(data json.RawMessage) {
+	s.mu.Lock()
+	s.seq++
+	s.history = append(s.history, sseEvent{ID: fmt.Sprintf("%s-%d", s.id, s.seq), Data: data})
+	if len(s.history) > sseHistory {
+		s.history = append([]sseEvent(nil), s.history[len(s.history)-sseHistory:]...)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// since returns the events numbered next or later along with the channel
+// that signals new ones
+func (s *sseSession) since(next int64) ([]sseEvent, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []sseEvent
+	for _, ev := range s.history {
+		if eventSeq(ev.ID) >= next {
+			events = append(events, ev)
+		}
+	}
+	return events, s.wake
+}
+
+// splitEventID splits "<session>-<seq>" event IDs
+func splitEventID(id string) (string, int64) {
+	i := strings.LastIndex(id, "-")
+	if i < 0 {
+		return "", 0
+	}
+	seq, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0
+	}
+	return id[:i], seq
+}
+
+func eventSeq(id string) int64 {
+	_, seq := splitEventID(id)
+	return seq
+}
+
+func errorResponse(id interface{}, code int, message string) *mcp.Response {
+	return &mcp.Response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &mcp.Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
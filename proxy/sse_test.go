@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+// fakeServer is a minimal Streamable HTTP MCP server
+type fakeServer struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	next     int
+	inits    int
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req mcp.Request
+	json.NewDecoder(r.Body).Decode(&req)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if req.Method == "initialize" {
+		f.next++
+		f.inits++
+		id := fmt.Sprintf("session-%d", f.next)
+		f.sessions[id] = true
+		w.Header().Set(sessionHeader, id)
+	} else if !f.sessions[r.Header.Get(sessionHeader)] {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if req.ID == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mcp.Response{JSONRPC: "2.0", ID: req.ID, Result: map[string]interface{}{"method": req.Method}})
+}
+
+// forget drops every session, as a server restart would
+func (f *fakeServer) forget() {
+	f.mu.Lock()
+	f.sessions = map[string]bool{}
+	f.mu.Unlock()
+}
+
+// openStream connects to the proxy's SSE endpoint and returns the message
+// endpoint it announces along with its events
+func openStream(t *testing.T, url, lastEventID string) (string, <-chan sseEvent, func()) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url+"/sse", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /sse = %v, %v", resp, err)
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		readEvents(resp.Body, func(ev sseEvent) bool {
+			events <- ev
+			return true
+		})
+	}()
+
+	ev := nextEvent(t, events)
+	if ev.Event != "endpoint" {
+		t.Fatalf("first event = %+v, want endpoint", ev)
+	}
+	return string(ev.Data), events, func() { resp.Body.Close() }
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed while waiting for an event")
+			}
+			if ev.Event != "" || ev.ID != "" {
+				return ev
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func postMessage(t *testing.T, url, body string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST %s = %v, %v", url, resp, err)
+	}
+	resp.Body.Close()
+}
+
+func TestSSEProxyResume(t *testing.T) {
+	upstreamServer := &fakeServer{sessions: map[string]bool{}}
+	remote := httptest.NewServer(upstreamServer)
+	defer remote.Close()
+
+	p, err := NewProxy(&Config{ServerURL: remote.URL, Timeout: 5 * time.Second, Transport: TransportSSE})
+	if err != nil {
+		t.Fatalf("NewProxy failed: %v", err)
+	}
+	local := httptest.NewServer(newSSEServer(p).handler())
+	defer local.Close()
+
+	endpoint, events, disconnect := openStream(t, local.URL, "")
+	postMessage(t, local.URL+endpoint, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	first := nextEvent(t, events)
+	if !strings.Contains(string(first.Data), `"method":"initialize"`) || first.ID == "" {
+		t.Fatalf("initialize event = %+v", first)
+	}
+	disconnect()
+
+	// Responses produced while the client is away are replayed on resume,
+	// and a forgotten upstream session is re-initialized transparently
+	upstreamServer.forget()
+	postMessage(t, local.URL+endpoint, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	time.Sleep(200 * time.Millisecond)
+
+	resumed, events, disconnect := openStream(t, local.URL, first.ID)
+	defer disconnect()
+	if resumed != endpoint {
+		t.Errorf("resumed endpoint = %s, want %s", resumed, endpoint)
+	}
+	missed := nextEvent(t, events)
+	if !strings.Contains(string(missed.Data), `"method":"tools/list"`) {
+		t.Errorf("replayed event = %s, want the tools/list response", missed.Data)
+	}
+	if upstreamServer.inits != 2 {
+		t.Errorf("upstream initialized %d times, want 2", upstreamServer.inits)
+	}
+}
+
+func TestSplitEventID(t *testing.T) {
+	session, seq := splitEventID("3f1c-aa-42")
+	if session != "3f1c-aa" || seq != 42 {
+		t.Errorf("splitEventID = %s, %d", session, seq)
+	}
+	if session, _ := splitEventID("garbage"); session != "" {
+		t.Errorf("splitEventID(garbage) session = %q, want empty", session)
+	}
+}
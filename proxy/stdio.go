@@ -3,27 +3,29 @@ package proxy
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"sync"
 
 	"github.com/truenas/truenas-mcp/mcp"
 )
 
+// ErrInvalidRequest is returned by ReadRequest for lines that are not JSON-RPC
+var ErrInvalidRequest = errors.New("failed to parse JSON-RPC request")
+
 // StdioHandler manages stdin/stdout communication
 type StdioHandler struct {
 	stdin       *bufio.Scanner
 	stdoutMutex sync.Mutex
-	debug       bool
 }
 
 // NewStdioHandler creates a new stdio handler
-func NewStdioHandler(debug bool) *StdioHandler {
+func NewStdioHandler() *StdioHandler {
 	return &StdioHandler{
 		stdin: bufio.NewScanner(os.Stdin),
-		debug: debug,
 	}
 }
 
@@ -37,13 +39,11 @@ func (h *StdioHandler) ReadRequest() (*mcp.Request, error) {
 	}
 
 	line := h.stdin.Bytes()
-	if h.debug {
-		log.Printf("[STDIN] %s", string(line))
-	}
+	slog.Debug("Received message on stdin", "message", line)
 
 	var req mcp.Request
 	if err := json.Unmarshal(line, &req); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON-RPC request: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
 	}
 
 	return &req, nil
@@ -51,17 +51,20 @@ func (h *StdioHandler) ReadRequest() (*mcp.Request, error) {
 
 // WriteResponse writes a JSON-RPC response to stdout
 func (h *StdioHandler) WriteResponse(resp *mcp.Response) error {
+	return h.WriteMessage(resp)
+}
+
+// WriteMessage writes any JSON-RPC message, such as a notification, to stdout
+func (h *StdioHandler) WriteMessage(msg interface{}) error {
 	h.stdoutMutex.Lock()
 	defer h.stdoutMutex.Unlock()
 
-	data, err := json.Marshal(resp)
+	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal response: %w", err)
 	}
 
-	if h.debug {
-		log.Printf("[STDOUT] %s", string(data))
-	}
+	slog.Debug("Writing message to stdout", "message", data)
 
 	_, err = fmt.Fprintf(os.Stdout, "%s\n", data)
 	if err != nil {
@@ -0,0 +1,345 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/mcp"
+)
+
+const (
+	sessionHeader = "Mcp-Session-Id"
+
+	// Backoff bounds for reconnecting the upstream event stream
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// errNoStream means the server does not offer a notification stream
+var errNoStream = errors.New("server does not offer an event stream")
+
+// upstream is one MCP session on the remote truenas-mcp server, spoken over
+// the Streamable HTTP transport. Server notifications arrive on a GET event
+// stream that is reconnected (resuming from the last event ID) when it drops.
+type upstream struct {
+	cfg    *Config
+	client *http.Client
+	notify func(json.RawMessage)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	sessionID   string
+	initRequest *mcp.Request // Replayed to open a new session if the server forgets ours
+	listening   bool
+	lastEventID string
+}
+
+func newUpstream(cfg *Config, client *http.Client, notify func(json.RawMessage)) *upstream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &upstream{cfg: cfg, client: client, notify: notify, ctx: ctx, cancel: cancel}
+}
+
+// Send forwards a JSON-RPC message and returns the response, or nil for
+// notifications
+func (u *upstream) Send(req *mcp.Request) (*mcp.Response, error) {
+	if req.Method == "initialize" {
+		u.mu.Lock()
+		u.initRequest = req
+		u.sessionID = ""
+		u.mu.Unlock()
+	}
+
+	resp, status, err := u.post(req)
+	if status == http.StatusNotFound && req.Method != "initialize" {
+		// The server restarted or expired the session; open a new one and retry
+		if err := u.reinitialize(); err != nil {
+			return nil, err
+		}
+		resp, _, err = u.post(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method == "initialize" {
+		u.startListening()
+	}
+	return resp, nil
+}
+
+// reinitialize replays the client's initialize to obtain a new session
+func (u *upstream) reinitialize() error {
+	u.mu.Lock()
+	init := u.initRequest
+	u.sessionID = ""
+	u.mu.Unlock()
+	if init == nil {
+		return fmt.Errorf("upstream session expired before initialize")
+	}
+
+	slog.Info("Upstream session expired, re-initializing")
+	if _, _, err := u.post(init); err != nil {
+		return fmt.Errorf("failed to re-initialize upstream session: %w", err)
+	}
+	notification := &mcp.Request{JSONRPC: "2.0", Method: "notifications/initialized"}
+	if _, _, err := u.post(notification); err != nil {
+		return fmt.Errorf("failed to re-initialize upstream session: %w", err)
+	}
+	return nil
+}
+
+// post sends one message and returns the response along with the HTTP status
+func (u *upstream) post(req *mcp.Request) (*mcp.Response, int, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(u.ctx, u.cfg.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.cfg.ServerURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	u.setHeaders(httpReq)
+
+	httpResp, err := u.client.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request to %s failed: %w", u.cfg.ServerURL, err)
+	}
+	defer httpResp.Body.Close()
+
+	if id := httpResp.Header.Get(sessionHeader); id != "" {
+		u.mu.Lock()
+		u.sessionID = id
+		u.mu.Unlock()
+	}
+
+	switch {
+	case httpResp.StatusCode == http.StatusAccepted:
+		return nil, httpResp.StatusCode, nil
+	case httpResp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+		return nil, httpResp.StatusCode, fmt.Errorf("server returned %s: %s", httpResp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	// Servers may answer with a single JSON body or an event stream that
+	// carries the response (possibly after notifications)
+	if strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream") {
+		var resp *mcp.Response
+		err := readEvents(httpResp.Body, func(ev sseEvent) bool {
+			if r := u.dispatch(ev.Data); r != nil {
+				resp = r
+				return false
+			}
+			return true
+		})
+		if resp == nil && err == nil {
+			err = fmt.Errorf("event stream ended without a response")
+		}
+		return resp, httpResp.StatusCode, err
+	}
+
+	var resp mcp.Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, httpResp.StatusCode, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &resp, httpResp.StatusCode, nil
+}
+
+// dispatch returns data as a response if it is one, otherwise it forwards it
+// as a server-initiated message
+func (u *upstream) dispatch(data []byte) *mcp.Response {
+	var probe struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(data, &probe); err == nil && probe.Method == "" {
+		var resp mcp.Response
+		if err := json.Unmarshal(data, &resp); err == nil {
+			return &resp
+		}
+	}
+	u.notify(json.RawMessage(data))
+	return nil
+}
+
+func (u *upstream) setHeaders(req *http.Request) {
+	u.mu.Lock()
+	sessionID := u.sessionID
+	u.mu.Unlock()
+
+	if sessionID != "" {
+		req.Header.Set(sessionHeader, sessionID)
+	}
+	if u.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+u.cfg.APIKey)
+	}
+}
+
+// startListening opens the notification stream once per upstream
+func (u *upstream) startListening() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.listening {
+		return
+	}
+	u.listening = true
+	go u.listen()
+}
+
+// listen keeps the GET event stream open, reconnecting with backoff
+func (u *upstream) listen() {
+	delay := minReconnectDelay
+	for {
+		connected, err := u.stream()
+		if u.ctx.Err() != nil || errors.Is(err, errNoStream) {
+			return
+		}
+		if connected {
+			delay = minReconnectDelay
+		}
+		if err != nil {
+			slog.Debug("Upstream event stream closed", "error", err, "reconnect_in", delay)
+		}
+
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// stream reads the event stream until it ends. connected reports whether the
+// server accepted the stream.
+func (u *upstream) stream() (connected bool, err error) {
+	req, err := http.NewRequestWithContext(u.ctx, http.MethodGet, u.cfg.ServerURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	u.setHeaders(req)
+	u.mu.Lock()
+	if u.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", u.lastEventID)
+	}
+	u.mu.Unlock()
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// The session is gone; open a new one before reconnecting
+		return false, u.reinitialize()
+	case http.StatusMethodNotAllowed:
+		return false, errNoStream
+	default:
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	err = readEvents(resp.Body, func(ev sseEvent) bool {
+		if ev.ID != "" {
+			u.mu.Lock()
+			u.lastEventID = ev.ID
+			u.mu.Unlock()
+		}
+		if len(ev.Data) > 0 {
+			u.notify(json.RawMessage(ev.Data))
+		}
+		return true
+	})
+	return true, err
+}
+
+// Close ends the upstream session
+func (u *upstream) Close() {
+	u.cancel()
+
+	u.mu.Lock()
+	sessionID := u.sessionID
+	u.mu.Unlock()
+	if sessionID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.cfg.ServerURL, nil)
+	if err != nil {
+		return
+	}
+	u.setHeaders(req)
+	if resp, err := u.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// sseEvent is one server-sent event
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  []byte
+}
+
+// readEvents parses a server-sent event stream, calling handle for each
+// event until it returns false or the stream ends
+func readEvents(r io.Reader, handle func(sseEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	var ev sseEvent
+	var data [][]byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 || ev.ID != "" {
+				ev.Data = bytes.Join(data, []byte("\n"))
+				if !handle(ev) {
+					return nil
+				}
+			}
+			ev, data = sseEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.ID = value
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, []byte(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,203 @@
+// Package redact masks credentials (API keys, passwords, passphrases,
+// keytabs, tokens, private keys) in anything the server writes out: tool
+// responses and errors, dry-run details, and log records.
+//
+// Credentials are found two ways: by the name of the field holding them
+// (bindpw, passphrase, kerberos_keytab, ...) and by their shape in free text
+// (PEM private keys, TrueNAS API keys, JSON web tokens). Values a caller
+// passed under credential names can also be masked wherever they are echoed
+// back, such as in a middleware error message.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Masked replaces credentials
+const Masked = "***MASKED***"
+
+// sensitiveKeyParts mark a field as a credential when they appear in its name
+var sensitiveKeyParts = []string{
+	"password",
+	"passwd",
+	"passphrase",
+	"secret",
+	"bindpw",
+	"keytab",
+	"token",
+	"api_key",
+	"apikey",
+	"private_key",
+	"privatekey",
+}
+
+// sensitiveKeys are field names that are credentials only as a whole word
+var sensitiveKeys = map[string]bool{
+	"key":        true, // dataset encryption key, API key value
+	"pass":       true,
+	"pin":        true,
+	"secret_key": true,
+	"access_key": true,
+	"community":  true, // SNMP v1/v2c community string
+	"monpwd":     true, // UPS monitor password
+}
+
+// sensitiveValuePatterns catch credentials in free text, whatever field holds them
+var sensitiveValuePatterns = []*regexp.Regexp{
+	// PEM private keys
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	// TrueNAS API keys ("<id>-<64 characters>")
+	regexp.MustCompile(`\b\d+-[A-Za-z0-9]{64}\b`),
+	// JSON web tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`),
+	// Credentials written as key=value or key: value in messages and URLs
+	regexp.MustCompile(`(?i)\b((?:[a-z_]*(?:password|passwd|passphrase|secret|bindpw|token|api_?key))\s*[=:]\s*)([^\s,;&"']+)`),
+}
+
+// minSecretLength is the shortest caller-supplied credential masked by value;
+// shorter ones would mask ordinary words and numbers in the text around them
+const minSecretLength = 4
+
+// IsSensitiveKey reports whether a field name denotes a credential
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// String masks credential patterns, and any of the given secrets, inside a
+// string
+func String(s string, secrets ...string) (string, bool) {
+	changed := false
+	for _, pattern := range sensitiveValuePatterns {
+		if !pattern.MatchString(s) {
+			continue
+		}
+		if pattern.NumSubexp() > 0 {
+			// Keep the key and separator, mask the value
+			s = pattern.ReplaceAllString(s, "${1}"+Masked)
+		} else {
+			s = pattern.ReplaceAllString(s, Masked)
+		}
+		changed = true
+	}
+	for _, secret := range secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Masked)
+			changed = true
+		}
+	}
+	return s, changed
+}
+
+// Value walks decoded JSON, masking non-empty string values of credential
+// fields, and credential patterns and the given secrets in any string.
+// Booleans and numbers under credential names (password_disabled, token_ttl)
+// are kept. Maps and slices are changed in place.
+func Value(v interface{}, secrets ...string) (interface{}, bool) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		changed := false
+		for key, item := range value {
+			if s, ok := item.(string); ok && s != "" && s != Masked && IsSensitiveKey(key) {
+				value[key] = Masked
+				changed = true
+				continue
+			}
+			if redacted, ok := Value(item, secrets...); ok {
+				value[key] = redacted
+				changed = true
+			}
+		}
+		return value, changed
+	case []interface{}:
+		changed := false
+		for i, item := range value {
+			if redacted, ok := Value(item, secrets...); ok {
+				value[i] = redacted
+				changed = true
+			}
+		}
+		return value, changed
+	case string:
+		return String(value, secrets...)
+	}
+	return v, false
+}
+
+// JSON masks credentials in text that is usually a JSON document. JSON is
+// only re-encoded when something was masked, so clean text passes through
+// byte-for-byte, and keeps its layout: indented if the input spans lines,
+// compact otherwise. Other text has the value patterns applied. Secrets are
+// masked inside strings only, never in keys or numbers.
+func JSON(text string, secrets ...string) string {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil || decoder.More() {
+		redacted, _ := String(text, secrets...)
+		return redacted
+	}
+
+	redacted, changed := Value(decoded, secrets...)
+	if !changed {
+		return text
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if strings.Contains(strings.TrimSpace(text), "\n") {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(redacted); err != nil {
+		// Never fall back to the unredacted text
+		return `{"error": "withheld: failed to redact credentials"}`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Secrets collects the string values held under credential names in args,
+// such as the bindpw passed to a tool, so they can be masked wherever they
+// are echoed back
+func Secrets(args interface{}) []string {
+	seen := map[string]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for key, item := range value {
+				if s, ok := item.(string); ok && IsSensitiveKey(key) && len(s) >= minSecretLength && s != Masked {
+					seen[s] = true
+					continue
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(args)
+
+	secrets := make([]string, 0, len(seen))
+	for s := range seen {
+		secrets = append(secrets, s)
+	}
+	// Longest first, so a secret containing another is masked whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
@@ -0,0 +1,64 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"bind failed: password=hunter2, retrying", "bind failed: password=" + Masked + ", retrying"},
+		{"ldap://host?bindpw=s3cret&base=dc", "ldap://host?bindpw=" + Masked + "&base=dc"},
+		{"password_disabled=false", "password_disabled=false"},
+		{"key 1-" + strings.Repeat("x", 64), "key " + Masked},
+	}
+	for _, tt := range tests {
+		if got, _ := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got, changed := String("Invalid credentials hunter22 for cn=admin", "hunter22"); !changed || strings.Contains(got, "hunter22") {
+		t.Errorf("secret not masked: %q", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	compact := `{"id":"1","params":[{"bindpw":"hunter2","hostname":"dc1"}],"note":"uses pass1234"}`
+	got := JSON(compact, "pass1234")
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "pass1234") || strings.Contains(got, "\n") {
+		t.Errorf("JSON(compact) = %s", got)
+	}
+	if !strings.Contains(got, `"hostname":"dc1"`) {
+		t.Errorf("JSON(compact) lost the hostname: %s", got)
+	}
+
+	// Secrets are masked inside strings only
+	numeric := `{"size":12345678,"label":"pin 5678"}`
+	if got := JSON(numeric, "5678"); !strings.Contains(got, `"size":12345678`) || strings.Contains(got, "pin 5678") {
+		t.Errorf("JSON(numeric) = %s", got)
+	}
+
+	clean := "{\n  \"name\": \"tank\"\n}"
+	if got := JSON(clean, "hunter2"); got != clean {
+		t.Errorf("clean JSON was rewritten: %s", got)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	args := map[string]interface{}{
+		"name":     "ldap",
+		"bindpw":   "hunter2",
+		"pin":      "12",
+		"password": Masked,
+		"credential": map[string]interface{}{
+			"kerberos_keytab": "BQIAAABH",
+		},
+	}
+	secrets := Secrets(args)
+	if len(secrets) != 2 || secrets[0] != "BQIAAABH" || secrets[1] != "hunter2" {
+		t.Errorf("Secrets = %q, want the keytab and bindpw, longest first", secrets)
+	}
+}
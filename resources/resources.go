@@ -0,0 +1,246 @@
+// Package resources exposes TrueNAS objects (pools, datasets, shares, VMs,
+// apps, and alerts) as MCP resources addressed by truenas:// URIs, e.g.
+// truenas://pool/tank or truenas://dataset/tank/media.
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Scheme is the URI scheme of TrueNAS resources
+const Scheme = "truenas://"
+
+// maxListedDatasets caps how many datasets resources/list returns; the
+// dataset template reaches the rest. The cap, the ordering, and the single
+// field a listing needs are passed to the middleware, so listing costs the
+// same on a system with many thousands of datasets.
+const maxListedDatasets = 200
+
+// ErrNotFound is returned for URIs that name no existing object
+var ErrNotFound = errors.New("resource not found")
+
+// kind describes one type of TrueNAS object served as a resource
+type kind struct {
+	prefix      string // URI path prefix, e.g. "pool/"
+	name        string // Human-readable type name
+	description string
+	method      string // Middleware query method
+	key         string // Field the URI identifies the object by
+	numericKey  bool   // Whether key is an integer ID
+	tool        string // Tool serving the same data; the kind is hidden when it is disabled
+}
+
+var kinds = []kind{
+	{prefix: "pool/", name: "Pool", description: "Storage pool status, topology, and capacity", method: "pool.query", key: "name", tool: "query_pools"},
+	{prefix: "dataset/", name: "Dataset", description: "Dataset properties, usage, and quotas", method: "pool.dataset.query", key: "name", tool: "query_datasets"},
+	{prefix: "share/smb/", name: "SMB share", description: "SMB share configuration", method: "sharing.smb.query", key: "name", tool: "query_shares"},
+	{prefix: "share/nfs/", name: "NFS share", description: "NFS export configuration", method: "sharing.nfs.query", key: "id", numericKey: true, tool: "query_shares"},
+	{prefix: "vm/", name: "VM", description: "Virtual machine configuration, devices, and state", method: "vm.query", key: "name", tool: "query_vms"},
+	{prefix: "app/", name: "App", description: "Installed application state and versions", method: "app.query", key: "name", tool: "query_apps"},
+}
+
+const (
+	// alertsURI is the resource holding every active alert
+	alertsURI = Scheme + "alerts"
+	// alertsTool serves the same data as alertsURI
+	alertsTool = "list_alerts"
+)
+
+// Enabled reports whether a tool is registered on this server. Resources
+// backed by a disabled tool are not served, so read-only mode and the tool
+// filter cover them too. A nil Enabled allows every tool.
+type Enabled func(tool string) bool
+
+func (e Enabled) allows(tool string) bool {
+	return e == nil || e(tool)
+}
+
+// Templates returns URI templates for reading objects that are not listed
+func Templates(enabled Enabled) []mcp.ResourceTemplate {
+	templates := make([]mcp.ResourceTemplate, 0, len(kinds))
+	for _, k := range kinds {
+		if !enabled.allows(k.tool) {
+			continue
+		}
+		templates = append(templates, mcp.ResourceTemplate{
+			URITemplate: Scheme + k.prefix + "{" + k.key + "}",
+			Name:        k.name,
+			Description: k.description,
+			MimeType:    "application/json",
+		})
+	}
+	return templates
+}
+
+// List returns a resource for every pool, dataset (up to a limit), share,
+// VM, and app, plus the active alerts. Object types that cannot be queried
+// (e.g. no VM support) are skipped; the first such error is returned with
+// the resources that could be listed.
+func List(client truenas.Caller, enabled Enabled) ([]mcp.Resource, error) {
+	resources := []mcp.Resource{}
+	if enabled.allows(alertsTool) {
+		resources = append(resources, mcp.Resource{
+			URI:         alertsURI,
+			Name:        "Active alerts",
+			Description: "Every active TrueNAS alert with its level and message",
+			MimeType:    "application/json",
+		})
+	}
+
+	var firstErr error
+	for _, k := range kinds {
+		if !enabled.allows(k.tool) {
+			continue
+		}
+		listOptions := map[string]interface{}{
+			"select":   []string{k.key},
+			"order_by": []string{k.key},
+		}
+		if k.prefix == "dataset/" {
+			listOptions["limit"] = maxListedDatasets
+		}
+		objects, err := query(client, k, nil, listOptions)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to list %ss: %w", strings.ToLower(k.name), err)
+			}
+			continue
+		}
+
+		for _, object := range objects {
+			id := objectID(object, k.key)
+			if id == "" {
+				continue
+			}
+			resources = append(resources, mcp.Resource{
+				URI:         URI(k.prefix, id),
+				Name:        fmt.Sprintf("%s %s", k.name, id),
+				Description: k.description,
+				MimeType:    "application/json",
+			})
+		}
+	}
+	return resources, firstErr
+}
+
+// Read returns the current state of the object a URI names as JSON. URIs
+// of kinds whose tool is disabled are not found.
+func Read(client truenas.Caller, uri string, enabled Enabled) (string, error) {
+	if uri == alertsURI && enabled.allows(alertsTool) {
+		result, err := client.Call("alert.list")
+		if err != nil {
+			return "", err
+		}
+		return indent(result)
+	}
+
+	k, id, err := parseURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if !enabled.allows(k.tool) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, uri)
+	}
+
+	var value interface{} = id
+	if k.numericKey {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, uri)
+		}
+		value = n
+	}
+
+	objects, err := query(client, k, []interface{}{[]interface{}{k.key, "=", value}}, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(objects) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, uri)
+	}
+
+	data, err := json.MarshalIndent(objects[0], "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// URI builds a resource URI, escaping each path segment of id
+func URI(prefix, id string) string {
+	segments := strings.Split(id, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return Scheme + prefix + strings.Join(segments, "/")
+}
+
+// parseURI splits a resource URI into its object type and ID
+func parseURI(uri string) (kind, string, error) {
+	rest, ok := strings.CutPrefix(uri, Scheme)
+	if !ok {
+		return kind{}, "", fmt.Errorf("%w: %s", ErrNotFound, uri)
+	}
+	for _, k := range kinds {
+		if escaped, ok := strings.CutPrefix(rest, k.prefix); ok && escaped != "" {
+			id, err := url.PathUnescape(escaped)
+			if err != nil {
+				return kind{}, "", fmt.Errorf("%w: %s", ErrNotFound, uri)
+			}
+			return k, id, nil
+		}
+	}
+	return kind{}, "", fmt.Errorf("%w: %s", ErrNotFound, uri)
+}
+
+// query runs the kind's query method with filters and the given
+// query-options added to the method's own
+func query(client truenas.Caller, k kind, filters []interface{}, add map[string]interface{}) ([]map[string]interface{}, error) {
+	if filters == nil {
+		filters = []interface{}{}
+	}
+	options := map[string]interface{}{}
+	if k.method == "pool.dataset.query" {
+		options["extra"] = map[string]interface{}{"flat": true, "retrieve_children": false}
+	}
+
+	result, err := client.Call(k.method, filters, truenas.WithQueryOptions(options, add))
+	if err != nil {
+		return nil, err
+	}
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(result, &objects); err != nil {
+		return nil, fmt.Errorf("failed to parse %s result: %w", k.method, err)
+	}
+	return objects, nil
+}
+
+func objectID(object map[string]interface{}, key string) string {
+	switch v := object[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return ""
+}
+
+func indent(raw json.RawMessage) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,107 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestListAndRead(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetRecords("pool.query", []map[string]interface{}{{"name": "tank", "status": "ONLINE"}})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank", "name": "tank"},
+		{"id": "tank/media", "name": "tank/media"},
+	})
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{{"name": "Family Photos", "path": "/mnt/tank/photos"}})
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{{"id": float64(3), "path": "/mnt/tank/media"}})
+	server.SetError("vm.query", 22, "virtualization is not supported")
+	server.SetRecords("app.query", []map[string]interface{}{{"name": "plex", "state": "RUNNING"}})
+	server.SetResult("alert.list", []interface{}{map[string]interface{}{"level": "WARNING", "formatted": "Pool tank is 85% full"}})
+	client := server.Client(t)
+
+	listed, err := List(client, nil)
+	if err == nil || !strings.Contains(err.Error(), "vms") {
+		t.Errorf("List error = %v, want the failed VM query reported", err)
+	}
+	uris := map[string]bool{}
+	for _, resource := range listed {
+		uris[resource.URI] = true
+	}
+	for _, uri := range []string{
+		"truenas://alerts",
+		"truenas://pool/tank",
+		"truenas://dataset/tank/media",
+		"truenas://share/smb/Family%20Photos",
+		"truenas://share/nfs/3",
+		"truenas://app/plex",
+	} {
+		if !uris[uri] {
+			t.Errorf("List is missing %s (got %v)", uri, uris)
+		}
+	}
+
+	options := server.Calls("pool.dataset.query")[0].Params[1].(map[string]interface{})
+	if options["limit"] != float64(maxListedDatasets) || fmt.Sprint(options["select"]) != "[name]" {
+		t.Errorf("dataset list options = %v, want the limit and selected field pushed down", options)
+	}
+
+	reads := map[string]string{
+		"truenas://dataset/tank/media":        `"id": "tank/media"`,
+		"truenas://share/smb/Family%20Photos": `"path": "/mnt/tank/photos"`,
+		"truenas://share/nfs/3":               `"path": "/mnt/tank/media"`,
+		"truenas://alerts":                    "85% full",
+	}
+	for uri, want := range reads {
+		text, err := Read(client, uri, nil)
+		if err != nil {
+			t.Errorf("Read(%s) failed: %v", uri, err)
+			continue
+		}
+		if !strings.Contains(text, want) {
+			t.Errorf("Read(%s) = %s, want it to contain %s", uri, text, want)
+		}
+	}
+
+	for _, uri := range []string{"truenas://pool/missing", "truenas://share/nfs/abc", "truenas://widget/x", "https://example.com"} {
+		if _, err := Read(client, uri, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Read(%s) error = %v, want ErrNotFound", uri, err)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	for _, template := range Templates(nil) {
+		if template.URITemplate == "truenas://dataset/{name}" {
+			return
+		}
+	}
+	t.Errorf("Templates(nil) = %v, want a dataset template", Templates(nil))
+}
+
+func TestDisabledTools(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetRecords("pool.query", []map[string]interface{}{{"name": "tank"}})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank", "name": "tank"}})
+	client := server.Client(t)
+	enabled := func(tool string) bool { return tool == "query_pools" }
+
+	listed, _ := List(client, enabled)
+	if len(listed) != 1 || listed[0].URI != "truenas://pool/tank" {
+		t.Errorf("List = %v, want only the pool", listed)
+	}
+	if calls := server.Calls("pool.dataset.query"); len(calls) != 0 {
+		t.Errorf("List queried datasets although query_datasets is disabled")
+	}
+	for _, uri := range []string{"truenas://dataset/tank", "truenas://alerts"} {
+		if _, err := Read(client, uri, enabled); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Read(%s) error = %v, want ErrNotFound", uri, err)
+		}
+	}
+	if templates := Templates(enabled); len(templates) != 1 || templates[0].URITemplate != "truenas://pool/{name}" {
+		t.Errorf("Templates = %v, want only the pool template", templates)
+	}
+}
@@ -0,0 +1,316 @@
+// Package schedule queues write tool calls to run at a later time, such as an
+// update applied during a weekend maintenance window. Queued operations are
+// persisted so they survive restarts.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// Status is the lifecycle state of a scheduled operation
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+	StatusMissed    Status = "missed" // The server was not running during its window
+)
+
+// maxResultLength caps how much tool output is kept per operation
+const maxResultLength = 4000
+
+// Config configures the scheduler
+type Config struct {
+	CheckInterval time.Duration // How often due operations are started
+	Retention     time.Duration // How long finished operations stay listed
+	Path          string        // JSON file used to persist the queue ("" = in-memory only)
+}
+
+// Operation is a tool call waiting for its scheduled time. It runs between
+// ExecuteAt and Deadline; if the server is not running then, it is missed
+// rather than run late.
+type Operation struct {
+	ID                string                 `json:"id"`
+	Tool              string                 `json:"tool"`
+	Arguments         map[string]interface{} `json:"arguments"`
+	MaintenanceWindow string                 `json:"maintenance_window,omitempty"`
+	Status            Status                 `json:"status"`
+	RequestedAt       time.Time              `json:"requested_at"`
+	ExecuteAt         time.Time              `json:"execute_at"`
+	Deadline          time.Time              `json:"deadline"`
+	StartedAt         *time.Time             `json:"started_at,omitempty"`
+	FinishedAt        *time.Time             `json:"finished_at,omitempty"`
+	Result            string                 `json:"result,omitempty"`
+	Error             string                 `json:"error,omitempty"`
+	CorrelationID     string                 `json:"correlation_id,omitempty"`
+}
+
+// Executor runs a due operation's tool call and returns its output
+type Executor func(op Operation) (string, error)
+
+// Queue holds scheduled operations and runs them when they are due
+type Queue struct {
+	config Config
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	execute    Executor
+	operations map[string]*Operation
+}
+
+// NewQueue creates a new scheduler queue, loading any persisted operations
+func NewQueue(config Config) (*Queue, error) {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		config:     config,
+		ctx:        ctx,
+		cancel:     cancel,
+		operations: make(map[string]*Operation),
+	}
+
+	if config.Path == "" {
+		return q, nil
+	}
+
+	data, err := os.ReadFile(config.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		cancel()
+		return nil, fmt.Errorf("failed to read scheduled operations: %w", err)
+	}
+
+	var operations []*Operation
+	if err := json.Unmarshal(data, &operations); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to parse scheduled operations %s: %w", config.Path, err)
+	}
+	for _, op := range operations {
+		// An operation interrupted by a restart may have partly run, so it is
+		// not retried
+		if op.Status == StatusRunning {
+			now := time.Now().UTC()
+			op.Status = StatusFailed
+			op.Error = "the server stopped while the operation was running; check its outcome before rescheduling"
+			op.FinishedAt = &now
+		}
+		q.operations[op.ID] = op
+	}
+
+	return q, nil
+}
+
+// SetExecutor sets the function that runs due operations
+func (q *Queue) SetExecutor(execute Executor) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.execute = execute
+}
+
+// Start begins running due operations
+func (q *Queue) Start() {
+	go q.run()
+}
+
+// Shutdown stops running operations; pending ones stay queued on disk
+func (q *Queue) Shutdown() {
+	q.cancel()
+}
+
+// Schedule queues a tool call to run between executeAt and deadline
+func (q *Queue) Schedule(tool string, args map[string]interface{}, executeAt, deadline time.Time, window, correlationID string) (*Operation, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	op := &Operation{
+		ID:                uuid.New().String()[:8],
+		Tool:              tool,
+		Arguments:         args,
+		MaintenanceWindow: window,
+		Status:            StatusPending,
+		RequestedAt:       time.Now().UTC(),
+		ExecuteAt:         executeAt,
+		Deadline:          deadline,
+		CorrelationID:     correlationID,
+	}
+	q.operations[op.ID] = op
+
+	if err := q.saveLocked(); err != nil {
+		delete(q.operations, op.ID)
+		return nil, err
+	}
+	copied := *op
+	return &copied, nil
+}
+
+// Cancel withdraws a pending operation
+func (q *Queue) Cancel(id string) (*Operation, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	op, ok := q.operations[id]
+	if !ok {
+		return nil, fmt.Errorf("scheduled operation %s not found", id)
+	}
+	if op.Status != StatusPending {
+		return nil, fmt.Errorf("scheduled operation %s can no longer be cancelled: it is %s", id, op.Status)
+	}
+
+	now := time.Now().UTC()
+	op.Status = StatusCancelled
+	op.FinishedAt = &now
+	if err := q.saveLocked(); err != nil {
+		return nil, err
+	}
+	copied := *op
+	return &copied, nil
+}
+
+// List returns all known operations ordered by execution time
+func (q *Queue) List() []Operation {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	operations := make([]Operation, 0, len(q.operations))
+	for _, op := range q.operations {
+		operations = append(operations, *op)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].ExecuteAt.Before(operations[j].ExecuteAt)
+	})
+	return operations
+}
+
+// run is the main execution loop
+func (q *Queue) run() {
+	q.ExecuteDue(time.Now())
+
+	ticker := time.NewTicker(q.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.ExecuteDue(now)
+		}
+	}
+}
+
+// ExecuteDue runs every pending operation whose time has come at now, marks
+// operations whose deadline passed as missed, and drops finished operations
+// older than the retention period. Operations run one at a time in schedule
+// order.
+func (q *Queue) ExecuteDue(now time.Time) {
+	q.mu.Lock()
+	execute := q.execute
+	due := []*Operation{}
+	for id, op := range q.operations {
+		switch {
+		case op.Status == StatusPending && now.After(op.Deadline):
+			finished := now.UTC()
+			op.Status = StatusMissed
+			op.FinishedAt = &finished
+			slog.Warn("Scheduled operation missed its window", "correlation_id", op.CorrelationID, "tool", op.Tool, "id", op.ID, "deadline", op.Deadline.Format(time.RFC3339))
+		case op.Status == StatusPending && !now.Before(op.ExecuteAt) && execute != nil:
+			// Marked before the lock is released so it can no longer be cancelled
+			op.Status = StatusRunning
+			started := now.UTC()
+			op.StartedAt = &started
+			due = append(due, op)
+		case op.FinishedAt != nil && q.config.Retention > 0 && now.Sub(*op.FinishedAt) > q.config.Retention:
+			delete(q.operations, id)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].ExecuteAt.Before(due[j].ExecuteAt)
+	})
+	if len(due) > 0 {
+		// Persisted before running so a crash mid-operation is not retried
+		if err := q.saveLocked(); err != nil {
+			slog.Error("Failed to persist scheduled operations", "error", err)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, op := range due {
+		q.mu.Lock()
+		snapshot := *op
+		q.mu.Unlock()
+
+		// Runs without the lock so pending operations can still be listed and cancelled
+		slog.Info("Running scheduled operation", "correlation_id", op.CorrelationID, "tool", op.Tool, "id", op.ID)
+		result, err := execute(snapshot)
+
+		q.mu.Lock()
+		finished := time.Now().UTC()
+		op.FinishedAt = &finished
+		if len(result) > maxResultLength {
+			result = result[:maxResultLength] + "\n... (truncated)"
+		}
+		op.Result = result
+		if err != nil {
+			op.Status = StatusFailed
+			op.Error = err.Error()
+			slog.Warn("Scheduled operation failed", "correlation_id", op.CorrelationID, "tool", op.Tool, "id", op.ID, "error", err)
+		} else {
+			op.Status = StatusCompleted
+			slog.Info("Scheduled operation completed", "correlation_id", op.CorrelationID, "tool", op.Tool, "id", op.ID)
+		}
+		q.mu.Unlock()
+	}
+
+	q.mu.Lock()
+	if err := q.saveLocked(); err != nil {
+		slog.Error("Failed to persist scheduled operations", "error", err)
+	}
+	q.mu.Unlock()
+}
+
+// saveLocked writes the queue to disk (no-op for in-memory queues). Must be
+// called with mu held.
+func (q *Queue) saveLocked() error {
+	if q.config.Path == "" {
+		return nil
+	}
+
+	operations := make([]*Operation, 0, len(q.operations))
+	for _, op := range q.operations {
+		operations = append(operations, op)
+	}
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled operations: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.config.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create scheduler directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(q.config.Path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write scheduled operations: %w", err)
+	}
+
+	return nil
+}
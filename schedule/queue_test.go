@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueueRunsDueOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduled_operations.json")
+	queue, err := NewQueue(Config{Retention: 2 * time.Hour, Path: path})
+	if err != nil {
+		t.Fatalf("NewQueue failed: %v", err)
+	}
+
+	start := time.Now().Add(time.Hour)
+	update, err := queue.Schedule("apply_update", map[string]interface{}{"reboot": true}, start, start.Add(time.Hour), "sat 02:00-03:00", "abc")
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	reboot, _ := queue.Schedule("system_reboot", map[string]interface{}{}, start, start.Add(time.Hour), "", "def")
+	missed, _ := queue.Schedule("run_scrub", map[string]interface{}{"pool": "tank"}, start.Add(-time.Minute), start.Add(-time.Minute), "", "ghi")
+	if _, err := queue.Cancel(reboot.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	// Pending operations survive a restart
+	reloaded, err := NewQueue(Config{Retention: 2 * time.Hour, Path: path})
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	var ran []Operation
+	reloaded.SetExecutor(func(op Operation) (string, error) {
+		ran = append(ran, op)
+		if op.Tool == "run_scrub" {
+			return "", fmt.Errorf("scrub already running")
+		}
+		return `{"status": "ok"}`, nil
+	})
+
+	reloaded.ExecuteDue(time.Now())
+	if len(ran) != 0 {
+		t.Fatalf("operations ran before their time: %v", ran)
+	}
+
+	reloaded.ExecuteDue(start.Add(time.Second))
+	if len(ran) != 1 || ran[0].ID != update.ID || ran[0].Arguments["reboot"] != true {
+		t.Fatalf("ran %v, want only the update", ran)
+	}
+	statuses := map[string]Status{}
+	for _, op := range reloaded.List() {
+		statuses[op.ID] = op.Status
+		if op.ID == update.ID && op.Result != `{"status": "ok"}` {
+			t.Errorf("update result = %q", op.Result)
+		}
+	}
+	if statuses[update.ID] != StatusCompleted || statuses[reboot.ID] != StatusCancelled || statuses[missed.ID] != StatusMissed {
+		t.Errorf("statuses = %v", statuses)
+	}
+	if _, err := reloaded.Cancel(update.ID); err == nil {
+		t.Error("a completed operation should not be cancellable")
+	}
+
+	reloaded.ExecuteDue(start.Add(4 * time.Hour))
+	if remaining := reloaded.List(); len(remaining) != 0 {
+		t.Errorf("finished operations past retention = %v, want none", remaining)
+	}
+}
+
+func TestQueueDoesNotRetryInterruptedOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduled_operations.json")
+	queue, _ := NewQueue(Config{Path: path})
+	start := time.Now().Add(-time.Minute)
+	op, _ := queue.Schedule("apply_update", map[string]interface{}{}, start, start.Add(time.Hour), "", "abc")
+
+	// The executor "crashes" the server by reloading mid-operation
+	var reloaded *Queue
+	queue.SetExecutor(func(Operation) (string, error) {
+		reloaded, _ = NewQueue(Config{Path: path})
+		return "", nil
+	})
+	queue.ExecuteDue(time.Now())
+
+	list := reloaded.List()
+	if len(list) != 1 || list[0].ID != op.ID || list[0].Status != StatusFailed {
+		t.Errorf("after restart = %+v, want the interrupted operation failed", list)
+	}
+}
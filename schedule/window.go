@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultWindowLength is how long a window lasts when its spec has no end time
+const DefaultWindowLength = time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Window is a recurring maintenance window in server local time
+type Window struct {
+	Days   []time.Weekday // Days the window opens on (empty = every day)
+	Start  time.Duration  // Offset from midnight the window opens at
+	Length time.Duration
+}
+
+// ParseWindow parses a window spec: optional comma-separated days ("sat",
+// "sat,sun", "daily") followed by a start time and optional end time, e.g.
+// "sat 02:00-04:00", "daily 01:30", or "23:00-01:00". Without an end time the
+// window lasts DefaultWindowLength.
+func ParseWindow(spec string) (Window, error) {
+	var w Window
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid maintenance window %q: want e.g. \"sat 02:00-04:00\"", spec)
+	}
+
+	times := fields[len(fields)-1]
+	if len(fields) == 2 && fields[0] != "daily" {
+		for _, day := range strings.Split(fields[0], ",") {
+			weekday, ok := weekdays[day]
+			if !ok {
+				return w, fmt.Errorf("invalid maintenance window %q: unknown day %q", spec, day)
+			}
+			w.Days = append(w.Days, weekday)
+		}
+	}
+
+	startSpec, endSpec, hasEnd := strings.Cut(times, "-")
+	start, err := parseClock(startSpec)
+	if err != nil {
+		return w, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+	}
+	w.Start = start
+	w.Length = DefaultWindowLength
+	if hasEnd {
+		end, err := parseClock(endSpec)
+		if err != nil {
+			return w, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+		}
+		// An end at or before the start crosses midnight
+		if end <= start {
+			end += 24 * time.Hour
+		}
+		w.Length = end - start
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	hour, minute, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("time %q must be HH:MM", s)
+	}
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("time %q must be HH:MM", s)
+	}
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("time %q must be HH:MM", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Next returns the start and end of the next window opening after now, in
+// now's location
+func (w Window) Next(now time.Time) (time.Time, time.Time) {
+	hour, minute := int(w.Start/time.Hour), int(w.Start%time.Hour/time.Minute)
+	for i := 0; i <= 7; i++ {
+		// Built from the wall clock so the window keeps its local time across DST changes
+		start := time.Date(now.Year(), now.Month(), now.Day()+i, hour, minute, 0, 0, now.Location())
+		if start.After(now) && w.opensOn(start.Weekday()) {
+			return start, start.Add(w.Length)
+		}
+	}
+	// Unreachable: every window opens at least once a week
+	return now, now.Add(w.Length)
+}
+
+func (w Window) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTime parses a schedule_at value: RFC 3339 ("2025-06-07T02:00:00Z") or
+// local time without a zone ("2025-06-07 02:00")
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339 (e.g. 2025-06-07T02:00:00Z) or local \"2025-06-07 02:00\"", s)
+}
@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	// Thursday
+	now := time.Date(2025, 6, 5, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec  string
+		start time.Time
+		end   time.Time
+	}{
+		{"sat 02:00-04:00", time.Date(2025, 6, 7, 2, 0, 0, 0, time.UTC), time.Date(2025, 6, 7, 4, 0, 0, 0, time.UTC)},
+		{"Saturday 02:00", time.Date(2025, 6, 7, 2, 0, 0, 0, time.UTC), time.Date(2025, 6, 7, 3, 0, 0, 0, time.UTC)},
+		{"daily 09:00", time.Date(2025, 6, 6, 9, 0, 0, 0, time.UTC), time.Date(2025, 6, 6, 10, 0, 0, 0, time.UTC)},
+		{"23:00-01:00", time.Date(2025, 6, 5, 23, 0, 0, 0, time.UTC), time.Date(2025, 6, 6, 1, 0, 0, 0, time.UTC)},
+		{"thu 10:00", time.Date(2025, 6, 12, 10, 0, 0, 0, time.UTC), time.Date(2025, 6, 12, 11, 0, 0, 0, time.UTC)},
+		{"mon,fri 03:30", time.Date(2025, 6, 6, 3, 30, 0, 0, time.UTC), time.Date(2025, 6, 6, 4, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		window, err := ParseWindow(tt.spec)
+		if err != nil {
+			t.Errorf("ParseWindow(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		start, end := window.Next(now)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%q: next window = %s - %s, want %s - %s", tt.spec, start, end, tt.start, tt.end)
+		}
+	}
+
+	for _, spec := range []string{"", "someday 02:00", "sat 2am", "sat 25:00", "sat 02:00-04:60", "sat sun 02:00"} {
+		if _, err := ParseWindow(spec); err == nil {
+			t.Errorf("ParseWindow(%q) accepted an invalid spec", spec)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	at, err := ParseTime("2025-06-07T02:00:00+02:00")
+	if err != nil || !at.Equal(time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseTime(RFC 3339) = %s, %v", at, err)
+	}
+	at, err = ParseTime("2025-06-07 02:00")
+	if err != nil || at.Location() != time.Local || at.Hour() != 2 {
+		t.Errorf("ParseTime(local) = %s, %v", at, err)
+	}
+	if _, err := ParseTime("saturday"); err == nil {
+		t.Error("ParseTime accepted an invalid time")
+	}
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// journalEntry records a job task so it can be recovered after a restart
+type journalEntry struct {
+	TaskID        string    `json:"task_id"`
+	JobID         int       `json:"job_id"`
+	ToolName      string    `json:"tool"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	System        string    `json:"system,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	TTL           int64     `json:"ttl"`
+}
+
+// expired reports whether the entry's task would have expired by now
+func (e journalEntry) expired(now time.Time) bool {
+	return now.After(e.CreatedAt.Add(time.Duration(e.TTL) * time.Second))
+}
+
+// journal persists the running job tasks. Tasks live in memory, so without
+// it a crash loses track of jobs the server started.
+type journal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// load reads the journal; a missing file is an empty journal
+func (j *journal) load() ([]journalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read task journal: %w", err)
+	}
+
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse task journal %s: %w", j.path, err)
+	}
+	return entries, nil
+}
+
+// save replaces the journal with the given entries
+func (j *journal) save(entries []journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if entries == nil {
+		entries = []journalEntry{}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task journal: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create task journal directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(j.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write task journal: %w", err)
+	}
+	return nil
+}
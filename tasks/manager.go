@@ -3,6 +3,8 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +19,18 @@ type Manager struct {
 	config PollerConfig
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	journal *journal // nil when job tasks are not persisted
+
+	mu            sync.Mutex
+	lastReconcile ReconcileReport
+	localTasks    map[string]localTask // Contexts of running local tasks
+}
+
+// localTask is the context a local task's work runs under
+type localTask struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewManager creates a new task manager
@@ -26,18 +40,43 @@ func NewManager(client *truenas.Client, config PollerConfig) *Manager {
 	store := NewTaskStore()
 	poller := NewPoller(client, store, config)
 
-	return &Manager{
+	m := &Manager{
 		client: client,
 		store:  store,
 		poller: poller,
 		config: config,
 		ctx:    ctx,
 		cancel: cancel,
+
+		localTasks: make(map[string]localTask),
+	}
+	if config.JournalPath != "" {
+		m.journal = &journal{path: config.JournalPath}
 	}
+	return m
+}
+
+// AddSystem registers a TrueNAS system other than the default one. Tasks
+// created by tool calls with a host argument naming it are polled, cancelled,
+// and reconciled through its client.
+func (m *Manager) AddSystem(name string, client *truenas.Client) {
+	m.poller.addSystem(name, client)
+}
+
+// taskSystem returns the system a tool call was directed at with its host
+// argument ("" = the default system)
+func taskSystem(args map[string]interface{}) string {
+	system, _ := args["host"].(string)
+	return system
 }
 
-// Start begins background polling and cleanup
+// Start reconciles tasks with the middleware's jobs, then begins background
+// polling and cleanup
 func (m *Manager) Start() {
+	if _, err := m.Reconcile(); err != nil {
+		slog.Warn("Task reconciliation failed", "error", err)
+	}
+
 	// Start the poller
 	go m.poller.Run(m.ctx)
 
@@ -61,12 +100,14 @@ func (m *Manager) cleanupLoop() {
 			return
 		case <-ticker.C:
 			m.store.CleanExpired()
+			m.saveJournal()
 		}
 	}
 }
 
-// CreateJobTask creates a task for a job-based operation
-func (m *Manager) CreateJobTask(toolName string, args map[string]interface{}, jobID int, ttl time.Duration) (*Task, error) {
+// CreateJobTask creates a task for a job-based operation. correlationID links
+// the task to the tool call that started it.
+func (m *Manager) CreateJobTask(toolName string, args map[string]interface{}, jobID int, ttl time.Duration, correlationID string) (*Task, error) {
 	task := &Task{
 		TaskID:        uuid.New().String(),
 		Status:        TaskStatusWorking,
@@ -74,21 +115,24 @@ func (m *Manager) CreateJobTask(toolName string, args map[string]interface{}, jo
 		LastUpdatedAt: time.Now(),
 		TTL:           int64(ttl.Seconds()),
 		PollInterval:  int64(m.config.PollInterval.Seconds()),
+		CorrelationID: correlationID,
 		OperationType: OperationTypeJob,
 		JobID:         &jobID,
 		ToolName:      toolName,
 		Arguments:     args,
+		System:        taskSystem(args),
 	}
 
 	if err := m.store.Add(task); err != nil {
 		return nil, fmt.Errorf("failed to store task: %w", err)
 	}
+	m.saveJournal()
 
 	return task, nil
 }
 
 // CreateStatusTask creates a task for a status-based operation
-func (m *Manager) CreateStatusTask(toolName string, args map[string]interface{}, statusMethod string, ttl time.Duration) (*Task, error) {
+func (m *Manager) CreateStatusTask(toolName string, args map[string]interface{}, statusMethod string, ttl time.Duration, correlationID string) (*Task, error) {
 	task := &Task{
 		TaskID:        uuid.New().String(),
 		Status:        TaskStatusWorking,
@@ -96,24 +140,125 @@ func (m *Manager) CreateStatusTask(toolName string, args map[string]interface{},
 		LastUpdatedAt: time.Now(),
 		TTL:           int64(ttl.Seconds()),
 		PollInterval:  int64(m.config.PollInterval.Seconds()),
+		CorrelationID: correlationID,
 		OperationType: OperationTypeStatus,
 		StatusMethod:  statusMethod,
 		ToolName:      toolName,
 		Arguments:     args,
+		System:        taskSystem(args),
+	}
+
+	if err := m.store.Add(task); err != nil {
+		return nil, fmt.Errorf("failed to store task: %w", err)
+	}
+
+	return task, nil
+}
+
+// CreateLocalTask creates a task for work the server runs itself. The caller
+// reports progress and completion with UpdateTask, and stops when the task's
+// Context is done.
+func (m *Manager) CreateLocalTask(toolName string, args map[string]interface{}, ttl time.Duration, correlationID string) (*Task, error) {
+	task := &Task{
+		TaskID:        uuid.New().String(),
+		Status:        TaskStatusWorking,
+		CreatedAt:     time.Now(),
+		LastUpdatedAt: time.Now(),
+		TTL:           int64(ttl.Seconds()),
+		PollInterval:  int64(m.config.PollInterval.Seconds()),
+		CorrelationID: correlationID,
+		OperationType: OperationTypeLocal,
+		ToolName:      toolName,
+		Arguments:     args,
+		System:        taskSystem(args),
 	}
 
 	if err := m.store.Add(task); err != nil {
 		return nil, fmt.Errorf("failed to store task: %w", err)
 	}
 
+	m.startLocal(task.TaskID)
+
 	return task, nil
 }
 
+// UpdateTask sets the status, message, and result of a local task. Cancelled
+// tasks are left unchanged.
+func (m *Manager) UpdateTask(taskID string, status TaskStatus, message string, result interface{}) error {
+	_, _, err := m.store.UpdateIf(taskID, func(task *Task) bool {
+		if task.Status == TaskStatusCancelled {
+			return false
+		}
+		task.Status = status
+		task.StatusMessage = message
+		task.Result = result
+
+		// The context follows the status under the store's lock, so a
+		// concurrent Cancel cannot leave a cancelled task running
+		switch {
+		case !status.active():
+			m.endLocal(taskID)
+		case task.OperationType == OperationTypeLocal:
+			// A resumed task runs under a fresh context
+			m.startLocal(taskID)
+		}
+		return true
+	})
+	return err
+}
+
+// Context returns the context a local task's work runs under. It is done once
+// the task is cancelled or finished, or the manager shuts down.
+func (m *Manager) Context(taskID string) context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if local, ok := m.localTasks[taskID]; ok {
+		return local.ctx
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	cancel()
+	return ctx
+}
+
+// startLocal gives a local task a context unless it already has one
+func (m *Manager) startLocal(taskID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.localTasks[taskID]; !ok {
+		ctx, cancel := context.WithCancel(m.ctx)
+		m.localTasks[taskID] = localTask{ctx: ctx, cancel: cancel}
+	}
+}
+
+// endLocal cancels a local task's context (no-op for other tasks)
+func (m *Manager) endLocal(taskID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if local, ok := m.localTasks[taskID]; ok {
+		local.cancel()
+		delete(m.localTasks, taskID)
+	}
+}
+
 // Get retrieves a task by ID
 func (m *Manager) Get(taskID string) (*Task, error) {
 	return m.store.Get(taskID)
 }
 
+// ActiveForCall returns the tasks started by a tool call that are still running
+func (m *Manager) ActiveForCall(correlationID string) []*Task {
+	return m.store.GetActiveByCorrelationID(correlationID)
+}
+
+// IsActive reports whether a task exists and has not reached a terminal state
+func (m *Manager) IsActive(taskID string) bool {
+	task, err := m.store.Get(taskID)
+	if err != nil {
+		return false
+	}
+	return task.Status.active()
+}
+
 // List returns tasks with pagination
 func (m *Manager) List(cursor string, limit int) ([]*Task, string, error) {
 	return m.store.List(cursor, limit)
@@ -127,24 +272,152 @@ func (m *Manager) Cancel(taskID string) (*Task, error) {
 	}
 
 	// Only cancel non-terminal tasks
-	if task.Status == TaskStatusCompleted || task.Status == TaskStatusFailed || task.Status == TaskStatusCancelled {
+	if !task.Status.active() {
 		return nil, fmt.Errorf("task is already in terminal state: %s", task.Status)
 	}
 
 	// For job-based tasks, try to abort the job
-	if task.OperationType == OperationTypeJob && task.JobID != nil {
-		_, err := m.client.Call("core.job_abort", *task.JobID)
+	if client, ok := m.poller.clientFor(task.System); ok && task.OperationType == OperationTypeJob && task.JobID != nil {
+		_, err := client.WithCorrelationID(task.CorrelationID).Call("core.job_abort", *task.JobID)
 		if err != nil {
 			// Log but don't fail - job might already be done
 		}
 	}
 
-	// Update task status
-	task.Status = TaskStatusCancelled
-	task.StatusMessage = "Cancelled by user"
-	if err := m.store.Update(task); err != nil {
+	// The task may have finished while the job was aborted
+	task, cancelled, err := m.store.UpdateIf(taskID, func(task *Task) bool {
+		if !task.Status.active() {
+			return false
+		}
+		task.Status = TaskStatusCancelled
+		task.StatusMessage = "Cancelled by user"
+		m.endLocal(taskID)
+		return true
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to update task: %w", err)
 	}
+	if !cancelled {
+		return nil, fmt.Errorf("task is already in terminal state: %s", task.Status)
+	}
 
 	return task, nil
 }
+
+// Reconcile brings the task list in line with the middleware's jobs. Job
+// tasks recorded in the journal but missing from memory (e.g. after a crash)
+// are recreated with their original task IDs, and running tasks whose job no
+// longer exists are failed instead of staying "working" until they expire.
+func (m *Manager) Reconcile() (ReconcileReport, error) {
+	report := ReconcileReport{CheckedAt: time.Now().UTC()}
+
+	var entries []journalEntry
+	if m.journal != nil {
+		var err error
+		if entries, err = m.journal.load(); err != nil {
+			return report, err
+		}
+	}
+
+	recovered := map[string]bool{}
+	for _, entry := range entries {
+		if entry.expired(report.CheckedAt) {
+			continue
+		}
+		if _, err := m.store.Get(entry.TaskID); err == nil {
+			continue
+		}
+
+		job, found, err := m.poller.fetchJob(entry.System, entry.CorrelationID, entry.JobID)
+		if err != nil {
+			// Leave the journal as it is so the next pass can retry
+			return report, fmt.Errorf("failed to look up job %d of task %s: %w", entry.JobID, entry.TaskID, err)
+		}
+		jobID := entry.JobID
+		task := &Task{
+			TaskID:        entry.TaskID,
+			Status:        TaskStatusWorking,
+			StatusMessage: "Recovered after a server restart",
+			CreatedAt:     entry.CreatedAt,
+			LastUpdatedAt: time.Now(),
+			TTL:           entry.TTL,
+			PollInterval:  int64(m.config.PollInterval.Seconds()),
+			CorrelationID: entry.CorrelationID,
+			Reconciled:    ReconciledRecovered,
+			System:        entry.System,
+			OperationType: OperationTypeJob,
+			JobID:         &jobID,
+			ToolName:      entry.ToolName,
+		}
+		if !found {
+			failJobVanished(task)
+			report.Vanished = append(report.Vanished, task.TaskID)
+		} else {
+			report.Recovered = append(report.Recovered, task.TaskID)
+		}
+		if err := m.store.Add(task); err != nil {
+			return report, fmt.Errorf("failed to store recovered task: %w", err)
+		}
+		if found {
+			m.poller.updateTaskFromJob(task, job)
+		}
+		recovered[task.TaskID] = true
+	}
+
+	for _, task := range m.store.GetActive() {
+		if task.OperationType != OperationTypeJob || task.JobID == nil || recovered[task.TaskID] {
+			continue
+		}
+		_, found, err := m.poller.fetchJob(task.System, task.CorrelationID, *task.JobID)
+		if err != nil {
+			return report, fmt.Errorf("failed to look up job %d of task %s: %w", *task.JobID, task.TaskID, err)
+		}
+		if !found {
+			m.poller.markJobVanished(task.TaskID)
+			report.Vanished = append(report.Vanished, task.TaskID)
+		}
+	}
+
+	m.saveJournal()
+	if len(report.Recovered) > 0 || len(report.Vanished) > 0 {
+		slog.Info("Task reconciliation finished", "recovered", len(report.Recovered), "vanished", len(report.Vanished))
+	}
+
+	m.mu.Lock()
+	m.lastReconcile = report
+	m.mu.Unlock()
+	return report, nil
+}
+
+// LastReconcile returns the report of the most recent reconciliation pass
+func (m *Manager) LastReconcile() ReconcileReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastReconcile
+}
+
+// saveJournal records the running job tasks (no-op without a journal)
+func (m *Manager) saveJournal() {
+	if m.journal == nil {
+		return
+	}
+
+	var entries []journalEntry
+	for _, task := range m.store.GetActive() {
+		if task.OperationType != OperationTypeJob || task.JobID == nil {
+			continue
+		}
+		entries = append(entries, journalEntry{
+			TaskID:        task.TaskID,
+			JobID:         *task.JobID,
+			ToolName:      task.ToolName,
+			CorrelationID: task.CorrelationID,
+			System:        task.System,
+			CreatedAt:     task.CreatedAt,
+			TTL:           task.TTL,
+		})
+	}
+	if err := m.journal.save(entries); err != nil {
+		slog.Error("Failed to persist task journal", "error", err)
+	}
+}
@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+// Run with -race: progress updates and polls racing a cancel must never
+// bring the cancelled task back.
+
+func TestCancelRacesLocalUpdates(t *testing.T) {
+	m := NewManager(nil, PollerConfig{PollInterval: time.Second})
+	defer m.Shutdown()
+
+	for i := 0; i < 20; i++ {
+		task, err := m.CreateLocalTask("install_app", nil, time.Hour, "call")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for step := 0; step < 50; step++ {
+					m.UpdateTask(task.TaskID, TaskStatusWorking, fmt.Sprintf("worker %d step %d", w, step), nil)
+				}
+			}(w)
+		}
+		if _, err := m.Cancel(task.TaskID); err != nil {
+			t.Fatalf("Cancel failed: %v", err)
+		}
+		wg.Wait()
+
+		if got, _ := m.Get(task.TaskID); got.Status != TaskStatusCancelled {
+			t.Fatalf("task status after cancel = %s (%s), want cancelled", got.Status, got.StatusMessage)
+		}
+		if m.Context(task.TaskID).Err() == nil {
+			t.Fatal("cancelled task's context is not done")
+		}
+	}
+}
+
+func TestCancelRacesJobPolls(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetResult("core.job_abort", nil)
+	m := NewManager(server.Client(t), PollerConfig{PollInterval: time.Second})
+	defer m.Shutdown()
+
+	task, err := m.CreateJobTask("run_scrub", nil, 7, time.Hour, "call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The poller works from a snapshot taken before the cancel
+	snapshot := m.store.GetActive()[0]
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for percent := 0; percent < 200; percent++ {
+			m.poller.updateTaskFromJob(snapshot, map[string]interface{}{
+				"state":    "RUNNING",
+				"progress": map[string]interface{}{"percent": float64(percent) / 2},
+			})
+		}
+	}()
+	if _, err := m.Cancel(task.TaskID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	wg.Wait()
+
+	m.poller.updateTaskFromJob(snapshot, map[string]interface{}{"state": "SUCCESS"})
+	m.poller.markJobVanished(task.TaskID)
+	if got, _ := m.Get(task.TaskID); got.Status != TaskStatusCancelled {
+		t.Errorf("task status after cancel = %s (%s), want cancelled", got.Status, got.StatusMessage)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/truenas/truenas-mcp/truenas"
@@ -14,15 +15,43 @@ type Poller struct {
 	client *truenas.Client
 	store  *TaskStore
 	config PollerConfig
+
+	mu      sync.Mutex
+	systems map[string]*truenas.Client // Clients of systems other than the default
+
+	// missedPolls counts consecutive polls that did not find a task's job.
+	// Only the polling loop uses it.
+	missedPolls map[string]int
 }
 
 // NewPoller creates a new poller
 func NewPoller(client *truenas.Client, store *TaskStore, config PollerConfig) *Poller {
 	return &Poller{
-		client: client,
-		store:  store,
-		config: config,
+		client:      client,
+		store:       store,
+		config:      config,
+		systems:     make(map[string]*truenas.Client),
+		missedPolls: make(map[string]int),
+	}
+}
+
+// addSystem registers the client of a named system whose tasks are polled
+func (p *Poller) addSystem(name string, client *truenas.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.systems[name] = client
+}
+
+// clientFor returns the client of a task's system; ok is false for a system
+// that is not configured
+func (p *Poller) clientFor(system string) (client *truenas.Client, ok bool) {
+	if system == "" {
+		return p.client, true
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	client, ok = p.systems[system]
+	return client, ok
 }
 
 // Run is the main polling loop
@@ -44,7 +73,9 @@ func (p *Poller) Run(ctx context.Context) {
 func (p *Poller) pollAllTasks() {
 	activeTasks := p.store.GetActive()
 
+	active := make(map[string]bool, len(activeTasks))
 	for _, task := range activeTasks {
+		active[task.TaskID] = true
 		switch task.OperationType {
 		case OperationTypeJob:
 			p.pollJobTask(task)
@@ -52,6 +83,42 @@ func (p *Poller) pollAllTasks() {
 			p.pollStatusTask(task)
 		}
 	}
+
+	// Forget counts of tasks that finished or expired in between
+	for taskID := range p.missedPolls {
+		if !active[taskID] {
+			delete(p.missedPolls, taskID)
+		}
+	}
+}
+
+// vanishedJobPolls is how many consecutive polls must miss a job before its
+// task is failed, so a briefly inconsistent job list does not fail it
+const vanishedJobPolls = 3
+
+// fetchJob looks up a job on a system with core.get_jobs; found is false
+// when the middleware no longer knows the job, or the system is no longer
+// configured so the job cannot be followed
+func (p *Poller) fetchJob(system, correlationID string, jobID int) (job map[string]interface{}, found bool, err error) {
+	client, ok := p.clientFor(system)
+	if !ok {
+		return nil, false, nil
+	}
+	result, err := client.WithCorrelationID(correlationID).Call("core.get_jobs", []interface{}{
+		[]interface{}{"id", "=", jobID},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	var jobs []map[string]interface{}
+	if err := json.Unmarshal(result, &jobs); err != nil {
+		return nil, false, fmt.Errorf("failed to parse job %d: %w", jobID, err)
+	}
+	if len(jobs) == 0 {
+		return nil, false, nil
+	}
+	return jobs[0], true, nil
 }
 
 // pollJobTask polls a job-based task using core.get_jobs
@@ -60,25 +127,44 @@ func (p *Poller) pollJobTask(task *Task) {
 		return
 	}
 
-	// Query job status
-	result, err := p.client.Call("core.get_jobs", []interface{}{
-		[]interface{}{"id", "=", *task.JobID},
-	})
+	job, found, err := p.fetchJob(task.System, task.CorrelationID, *task.JobID)
 	if err != nil {
 		// Don't fail the task on network errors, just skip this poll
 		return
 	}
 
-	var jobs []map[string]interface{}
-	if err := json.Unmarshal(result, &jobs); err != nil {
+	// Jobs disappear when the middleware restarts; the task would otherwise
+	// stay working until it expires
+	if !found {
+		p.missedPolls[task.TaskID]++
+		if p.missedPolls[task.TaskID] >= vanishedJobPolls {
+			delete(p.missedPolls, task.TaskID)
+			p.markJobVanished(task.TaskID)
+		}
 		return
 	}
+	delete(p.missedPolls, task.TaskID)
 
-	if len(jobs) == 0 {
-		return
-	}
+	p.updateTaskFromJob(task, job)
+}
+
+// failJobVanished fails a task because the middleware no longer knows its job
+func failJobVanished(task *Task) {
+	task.Status = TaskStatusFailed
+	task.StatusMessage = fmt.Sprintf("Job %d no longer exists in the middleware (it may have restarted); the outcome is unknown, so check the affected resource", *task.JobID)
+	task.Reconciled = ReconciledJobVanished
+}
 
-	p.updateTaskFromJob(task, jobs[0])
+// markJobVanished fails a stored task whose job vanished, unless it was
+// cancelled or finished meanwhile
+func (p *Poller) markJobVanished(taskID string) {
+	p.store.UpdateIf(taskID, func(task *Task) bool {
+		if !task.Status.active() {
+			return false
+		}
+		failJobVanished(task)
+		return true
+	})
 }
 
 // pollStatusTask polls a status-based task using custom status endpoint
@@ -87,8 +173,13 @@ func (p *Poller) pollStatusTask(task *Task) {
 		return
 	}
 
+	client, ok := p.clientFor(task.System)
+	if !ok {
+		return
+	}
+
 	// Call the status method
-	result, err := p.client.Call(task.StatusMethod)
+	result, err := client.WithCorrelationID(task.CorrelationID).Call(task.StatusMethod)
 	if err != nil {
 		return
 	}
@@ -126,9 +217,6 @@ func (p *Poller) updateTaskFromJob(task *Task, job map[string]interface{}) {
 	case "SUCCESS":
 		newStatus = TaskStatusCompleted
 		statusMessage = "Job completed successfully"
-		if result, ok := job["result"]; ok {
-			task.Result = result
-		}
 
 	case "FAILED":
 		newStatus = TaskStatusFailed
@@ -146,12 +234,19 @@ func (p *Poller) updateTaskFromJob(task *Task, job map[string]interface{}) {
 		return // Unknown state, don't update
 	}
 
-	// Update task if state changed
-	if task.Status != newStatus || task.StatusMessage != statusMessage {
-		task.Status = newStatus
-		task.StatusMessage = statusMessage
-		p.store.Update(task)
-	}
+	// Update the stored task if its state changed. task is a snapshot taken
+	// before the poll; a task cancelled or finished meanwhile is left alone.
+	p.store.UpdateIf(task.TaskID, func(stored *Task) bool {
+		if !stored.Status.active() || (stored.Status == newStatus && stored.StatusMessage == statusMessage) {
+			return false
+		}
+		stored.Status = newStatus
+		stored.StatusMessage = statusMessage
+		if result, ok := job["result"]; ok && newStatus == TaskStatusCompleted {
+			stored.Result = result
+		}
+		return true
+	})
 }
 
 // updateTaskFromStatus updates task state based on custom status endpoint
@@ -197,11 +292,15 @@ func (p *Poller) updateTaskFromStatus(task *Task, status map[string]interface{})
 		statusMessage = desc
 	}
 
-	// Update task if state changed
-	if task.Status != newStatus || task.StatusMessage != statusMessage {
-		task.Status = newStatus
-		task.StatusMessage = statusMessage
-		task.Result = status
-		p.store.Update(task)
-	}
+	// Update the stored task if its state changed, unless it was cancelled
+	// or finished meanwhile
+	p.store.UpdateIf(task.TaskID, func(stored *Task) bool {
+		if !stored.Status.active() || (stored.Status == newStatus && stored.StatusMessage == statusMessage) {
+			return false
+		}
+		stored.Status = newStatus
+		stored.StatusMessage = statusMessage
+		stored.Result = status
+		return true
+	})
 }
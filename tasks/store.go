@@ -7,7 +7,9 @@ import (
 	"time"
 )
 
-// TaskStore provides thread-safe storage for tasks with TTL-based expiry
+// TaskStore provides thread-safe storage for tasks with TTL-based expiry.
+// Tasks are copied in and out, so the poller, tool handlers, and readers
+// never share a Task; changes take effect only through Update.
 type TaskStore struct {
 	mu     sync.RWMutex
 	tasks  map[string]*Task
@@ -31,8 +33,10 @@ func (s *TaskStore) Add(task *Task) error {
 		return fmt.Errorf("task ID cannot be empty")
 	}
 
-	s.tasks[task.TaskID] = task
-	s.expiry[task.TaskID] = time.Now().Add(time.Duration(task.TTL) * time.Second)
+	// Expiry counts from creation so recovered tasks keep their original lifetime
+	stored := *task
+	s.tasks[task.TaskID] = &stored
+	s.expiry[task.TaskID] = task.CreatedAt.Add(time.Duration(task.TTL) * time.Second)
 
 	return nil
 }
@@ -52,7 +56,8 @@ func (s *TaskStore) Get(taskID string) (*Task, error) {
 		return nil, fmt.Errorf("task expired: %s", taskID)
 	}
 
-	return task, nil
+	copied := *task
+	return &copied, nil
 }
 
 // Update modifies an existing task
@@ -65,11 +70,38 @@ func (s *TaskStore) Update(task *Task) error {
 	}
 
 	task.LastUpdatedAt = time.Now()
-	s.tasks[task.TaskID] = task
+	stored := *task
+	s.tasks[task.TaskID] = &stored
 
 	return nil
 }
 
+// UpdateIf applies change to a copy of a stored task and stores the copy if
+// change returns true. Both happen under the store's lock, so the state change
+// checks cannot be overtaken by another update (a cancelled task is never
+// revived by a stale poll). change must not call the store. The task is
+// returned as stored after the call, with whether it was updated.
+func (s *TaskStore) UpdateIf(taskID string, change func(task *Task) bool) (*Task, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.tasks[taskID]
+	if !exists {
+		return nil, false, fmt.Errorf("task not found: %s", taskID)
+	}
+
+	updated := *stored
+	if !change(&updated) {
+		copied := *stored
+		return &copied, false, nil
+	}
+	updated.LastUpdatedAt = time.Now()
+	s.tasks[taskID] = &updated
+
+	copied := updated
+	return &copied, true, nil
+}
+
 // List returns tasks with pagination support
 func (s *TaskStore) List(cursor string, limit int) ([]*Task, string, error) {
 	s.mu.RLock()
@@ -82,7 +114,8 @@ func (s *TaskStore) List(cursor string, limit int) ([]*Task, string, error) {
 		if expiry, ok := s.expiry[taskID]; ok && now.After(expiry) {
 			continue // Skip expired
 		}
-		validTasks = append(validTasks, task)
+		copied := *task
+		validTasks = append(validTasks, &copied)
 	}
 
 	// Sort by creation time (newest first)
@@ -137,14 +170,26 @@ func (s *TaskStore) GetActive() []*Task {
 		}
 
 		// Include only non-terminal states
-		if task.Status == TaskStatusWorking || task.Status == TaskStatusInputRequired {
-			active = append(active, task)
+		if task.Status.active() {
+			copied := *task
+			active = append(active, &copied)
 		}
 	}
 
 	return active
 }
 
+// GetActiveByCorrelationID returns the non-terminal tasks created by one tool call
+func (s *TaskStore) GetActiveByCorrelationID(correlationID string) []*Task {
+	var matched []*Task
+	for _, task := range s.GetActive() {
+		if task.CorrelationID == correlationID {
+			matched = append(matched, task)
+		}
+	}
+	return matched
+}
+
 // CleanExpired removes expired tasks from storage
 func (s *TaskStore) CleanExpired() {
 	s.mu.Lock()
@@ -15,12 +15,18 @@ const (
 	TaskStatusCancelled     TaskStatus = "cancelled"
 )
 
+// active reports whether a task in this status can still change
+func (s TaskStatus) active() bool {
+	return s == TaskStatusWorking || s == TaskStatusInputRequired
+}
+
 // OperationType indicates how to poll for task updates
 type OperationType string
 
 const (
 	OperationTypeJob    OperationType = "job"    // Poll core.get_jobs
 	OperationTypeStatus OperationType = "status" // Poll custom status endpoint
+	OperationTypeLocal  OperationType = "local"  // Run by the server itself, not polled
 )
 
 // Task represents a long-running operation
@@ -30,8 +36,11 @@ type Task struct {
 	StatusMessage string     `json:"statusMessage,omitempty"`
 	CreatedAt     time.Time  `json:"createdAt"`
 	LastUpdatedAt time.Time  `json:"lastUpdatedAt"`
-	TTL           int64      `json:"ttl"`          // Seconds until expiry
-	PollInterval  int64      `json:"pollInterval"` // Seconds between polls
+	TTL           int64      `json:"ttl"`                     // Seconds until expiry
+	PollInterval  int64      `json:"pollInterval"`            // Seconds between polls
+	CorrelationID string     `json:"correlationId,omitempty"` // Tool call that created the task
+	Reconciled    string     `json:"reconciled,omitempty"`    // Set by reconciliation: "recovered" or "job_vanished"
+	System        string     `json:"system,omitempty"`        // TrueNAS system the task runs on ("" = the default system)
 
 	// Internal fields (not exposed in JSON)
 	OperationType OperationType          `json:"-"`
@@ -43,9 +52,23 @@ type Task struct {
 	Error         error                  `json:"-"`
 }
 
+// Reconciliation flags
+const (
+	ReconciledRecovered   = "recovered"    // Task recreated from the journal after a restart
+	ReconciledJobVanished = "job_vanished" // The task's job no longer exists in the middleware
+)
+
 // PollerConfig configures the background polling behavior
 type PollerConfig struct {
 	PollInterval    time.Duration // How often to poll TrueNAS
 	MaxPollAttempts int           // 0 = unlimited
 	CleanupInterval time.Duration // How often to clean expired tasks
+	JournalPath     string        // JSON file recording running job tasks for recovery after a restart ("" = disabled)
+}
+
+// ReconcileReport describes what a reconciliation pass changed
+type ReconcileReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Recovered []string  `json:"recovered,omitempty"` // Tasks recreated for jobs still known to the middleware
+	Vanished  []string  `json:"vanished,omitempty"`  // Tasks whose job no longer exists
 }
@@ -0,0 +1,416 @@
+// Package toml parses the subset of TOML used by configuration files: key =
+// value pairs, [tables], [[arrays of tables]], strings, integers, floats,
+// booleans, arrays, and inline tables, with # comments. Multi-line strings
+// and dates are not supported.
+//
+// Documents decode to map[string]interface{} with the same value types as
+// encoding/json (string, float64, bool, []interface{}, map[string]interface{}),
+// so they can be re-encoded as JSON and decoded into structs.
+package toml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse decodes a TOML document
+func Parse(data []byte) (map[string]interface{}, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("document is not valid UTF-8")
+	}
+	p := &parser{text: string(data), line: 1}
+	doc := map[string]interface{}{}
+	current := doc
+	for {
+		p.skipSpaceAndComments()
+		if p.done() {
+			return doc, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(doc)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+		if err := p.endLine(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+	}
+}
+
+type parser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+// skipSpace skips spaces and tabs on the current line
+func (p *parser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipSpaceAndComments skips whitespace, newlines, and comments
+func (p *parser) skipSpaceAndComments() {
+	for !p.done() {
+		switch p.peek() {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			for !p.done() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endLine requires the rest of the line to be blank or a comment
+func (p *parser) endLine() error {
+	p.skipSpace()
+	if p.peek() == '#' {
+		for !p.done() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if !p.done() && p.peek() != '\n' {
+		return fmt.Errorf("unexpected %q after value", p.peek())
+	}
+	return nil
+}
+
+// parseHeader reads [table] or [[array]] and returns the table that
+// following keys go into
+func (p *parser) parseHeader(doc map[string]interface{}) (map[string]interface{}, error) {
+	p.pos++
+	array := p.peek() == '['
+	if array {
+		p.pos++
+	}
+	p.skipSpace()
+	path, err := p.parseKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.text[p.pos:], closing) {
+		return nil, fmt.Errorf("expected %s after table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := tableAt(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	if array {
+		table := map[string]interface{}{}
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = []interface{}{table}
+		case []interface{}:
+			parent[last] = append(existing, table)
+		default:
+			return nil, fmt.Errorf("%s is already defined as a value", strings.Join(path, "."))
+		}
+		return table, nil
+	}
+	switch existing := parent[last].(type) {
+	case nil:
+		table := map[string]interface{}{}
+		parent[last] = table
+		return table, nil
+	case map[string]interface{}:
+		return existing, nil
+	}
+	return nil, fmt.Errorf("%s is already defined as a value", strings.Join(path, "."))
+}
+
+// tableAt returns the table at path, creating tables as needed. A path
+// through an array of tables refers to its last table.
+func tableAt(table map[string]interface{}, path []string) (map[string]interface{}, error) {
+	for i, key := range path {
+		switch next := table[key].(type) {
+		case nil:
+			created := map[string]interface{}{}
+			table[key] = created
+			table = created
+		case map[string]interface{}:
+			table = next
+		case []interface{}:
+			last, ok := next[len(next)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+// parseKeyValue reads key = value into table
+func (p *parser) parseKeyValue(table map[string]interface{}) error {
+	path, err := p.parseKeyPath()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.peek() != '=' {
+		return fmt.Errorf("expected = after key %s", strings.Join(path, "."))
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := tableAt(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	key := path[len(path)-1]
+	if _, exists := parent[key]; exists {
+		return fmt.Errorf("%s is defined more than once", strings.Join(path, "."))
+	}
+	parent[key] = value
+	return nil
+}
+
+// parseKeyPath reads a bare, quoted, or dotted key
+func (p *parser) parseKeyPath() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpace()
+		var key string
+		switch p.peek() {
+		case '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.done() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("expected a key, found %q", p.peek())
+			}
+			key = p.text[start:p.pos]
+		}
+		path = append(path, key)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue reads a string, number, boolean, array, or inline table
+func (p *parser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		if strings.HasPrefix(p.text[p.pos:], `"""`) {
+			return nil, fmt.Errorf("multi-line strings are not supported")
+		}
+		return p.parseBasicString()
+	case c == '\'':
+		if strings.HasPrefix(p.text[p.pos:], "'''") {
+			return nil, fmt.Errorf("multi-line strings are not supported")
+		}
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t\r\n,]}#", p.peek()) < 0 {
+		p.pos++
+	}
+	word := p.text[start:p.pos]
+	switch word {
+	case "":
+		return nil, fmt.Errorf("missing value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	number := strings.ReplaceAll(word, "_", "")
+	if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return float64(n), nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil && !strings.ContainsAny(number, "xXpP") {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q (strings must be quoted)", word)
+}
+
+// parseBasicString reads a "double-quoted" string with escapes
+func (p *parser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.done() || p.peek() == '\n' {
+			return "", fmt.Errorf("unterminated string")
+		}
+		c := p.peek()
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.done() {
+				return "", fmt.Errorf("unterminated string")
+			}
+			escape := p.peek()
+			p.pos++
+			switch escape {
+			case '"', '\\':
+				b.WriteByte(escape)
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u', 'U':
+				size := 4
+				if escape == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.text) {
+					return "", fmt.Errorf("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(p.text[p.pos:p.pos+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return "", fmt.Errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				p.pos += size
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", escape)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// parseLiteralString reads a 'single-quoted' string, taken as written
+func (p *parser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.text[p.pos:], "'\n")
+	if end < 0 || p.text[p.pos+end] != '\'' {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := p.text[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// parseArray reads [values], which may span lines and end with a comma
+func (p *parser) parseArray() ([]interface{}, error) {
+	p.pos++
+	values := []interface{}{}
+	for {
+		p.skipSpaceAndComments()
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+		if p.done() {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		p.skipSpaceAndComments()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+// parseInlineTable reads {key = value, ...} on one line
+func (p *parser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++
+	table := map[string]interface{}{}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
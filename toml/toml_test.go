@@ -0,0 +1,82 @@
+package toml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse([]byte(`
+# Connection
+truenas_url = "nas.local"   # trailing comment
+api_key = 'C:\keys\not-an-escape'
+read_only = true
+max_response_bytes = 65_536
+ratio = 0.5
+enable_tools = [
+  "query_*",
+  "get_*",   # trailing comma allowed
+]
+greeting = "tab\there \"quoted\" \u00e9"
+
+[tool_timeouts]
+query = "45s"
+"analyze_capacity" = "2m"
+
+[[systems]]
+name = "nas2"
+url = "nas2.local"
+
+[[systems]]
+name = "backup"
+auth = { username = "admin", password = "pw" }
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	checks := map[string]interface{}{
+		"truenas_url":        "nas.local",
+		"api_key":            `C:\keys\not-an-escape`,
+		"read_only":          true,
+		"max_response_bytes": float64(65536),
+		"ratio":              0.5,
+		"enable_tools":       "[query_* get_*]",
+		"greeting":           "tab\there \"quoted\" é",
+		"tool_timeouts":      "map[analyze_capacity:2m query:45s]",
+	}
+	for key, want := range checks {
+		got := doc[key]
+		if _, isString := want.(string); isString {
+			got = fmt.Sprint(got)
+		}
+		if got != want {
+			t.Errorf("%s = %#v, want %#v", key, got, want)
+		}
+	}
+	systems, _ := doc["systems"].([]interface{})
+	if len(systems) != 2 {
+		t.Fatalf("systems = %v, want 2 tables", doc["systems"])
+	}
+	if backup := systems[1].(map[string]interface{}); fmt.Sprint(backup["auth"]) != "map[password:pw username:admin]" {
+		t.Errorf("second system = %v", backup)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		`url = nas.local`,
+		`url = "unterminated`,
+		"a = 1\na = 2",
+		`a = 1 b = 2`,
+		`[table`,
+		`list = [1, 2`,
+		`text = """multi"""`,
+		"a = 1\n[a]",
+		`a = "\q"`,
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", doc)
+		}
+	}
+}
@@ -0,0 +1,767 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Filesystem ACLs, ownership, and modes
+
+// aclJobTimeout bounds recursive permission jobs, which walk every file
+const aclJobTimeout = 6 * time.Hour
+
+// Middleware ACL types
+const (
+	aclTypeNFS4  = "NFS4"
+	aclTypePOSIX = "POSIX1E"
+)
+
+// nfs4BasicPerms are the NFSv4 permission sets accepted in set_acl entries
+var nfs4BasicPerms = []string{"FULL_CONTROL", "MODIFY", "READ", "TRAVERSE"}
+
+// aclSpecialTags are entries that do not name a user or group
+var aclSpecialTags = map[string]bool{
+	"owner@": true, "group@": true, "everyone@": true,
+	"USER_OBJ": true, "GROUP_OBJ": true, "OTHER": true, "MASK": true,
+}
+
+// posixPermsPattern matches POSIX permissions written as rwx
+var posixPermsPattern = regexp.MustCompile(`^[r-][w-][x-]$`)
+
+// aclEntry is an ACL entry in the simplified form get_acl returns and set_acl
+// accepts. NFSv4 entries use Type and Inherit; POSIX entries use Default.
+type aclEntry struct {
+	Tag     string `json:"tag"`
+	Who     string `json:"who,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Perms   string `json:"perms"`
+	Inherit bool   `json:"inherit,omitempty"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// String describes the entry in one line, which also identifies it in diffs
+func (e aclEntry) String() string {
+	subject := e.Tag
+	if e.Who != "" {
+		subject = strings.ToLower(e.Tag) + ":" + e.Who
+	}
+	if e.Type != "" {
+		s := fmt.Sprintf("%s %s %s", e.Type, subject, e.Perms)
+		if e.Inherit {
+			s += " (inherited)"
+		}
+		return s
+	}
+	s := fmt.Sprintf("%s %s", subject, e.Perms)
+	if e.Default {
+		s = "default " + s
+	}
+	return s
+}
+
+// aclTemplate is a named set of entries for a common case. "{group}" in Who
+// is replaced with the group argument.
+type aclTemplate struct {
+	ACLType     string
+	NeedsGroup  bool
+	Description string
+	Entries     []aclEntry
+}
+
+// aclTemplates are the set_acl templates
+var aclTemplates = map[string]aclTemplate{
+	"smb_group_full_control": {
+		ACLType: aclTypeNFS4, NeedsGroup: true,
+		Description: "Owner and the group have full control; nobody else has access",
+		Entries: []aclEntry{
+			{Tag: "owner@", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+			{Tag: "GROUP", Who: "{group}", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+		},
+	},
+	"smb_group_modify": {
+		ACLType: aclTypeNFS4, NeedsGroup: true,
+		Description: "Owner has full control; the group can read, write, and delete but not change permissions",
+		Entries: []aclEntry{
+			{Tag: "owner@", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+			{Tag: "GROUP", Who: "{group}", Type: "ALLOW", Perms: "MODIFY", Inherit: true},
+		},
+	},
+	"smb_group_read_only": {
+		ACLType: aclTypeNFS4, NeedsGroup: true,
+		Description: "Owner has full control; the group can only read",
+		Entries: []aclEntry{
+			{Tag: "owner@", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+			{Tag: "GROUP", Who: "{group}", Type: "ALLOW", Perms: "READ", Inherit: true},
+		},
+	},
+	"smb_owner_only": {
+		ACLType:     aclTypeNFS4,
+		Description: "Only the owner has access",
+		Entries: []aclEntry{
+			{Tag: "owner@", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+		},
+	},
+	"smb_everyone_modify": {
+		ACLType:     aclTypeNFS4,
+		Description: "Owner and owning group have full control; everyone else can read, write, and delete",
+		Entries: []aclEntry{
+			{Tag: "owner@", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+			{Tag: "group@", Type: "ALLOW", Perms: "FULL_CONTROL", Inherit: true},
+			{Tag: "everyone@", Type: "ALLOW", Perms: "MODIFY", Inherit: true},
+		},
+	},
+	"posix_group_rwx": {
+		ACLType: aclTypePOSIX, NeedsGroup: true,
+		Description: "Owner and the group can read and write (like mode 770 plus a named group), also for new files",
+		Entries: posixEntriesWithDefaults([]aclEntry{
+			{Tag: "USER_OBJ", Perms: "rwx"},
+			{Tag: "GROUP_OBJ", Perms: "rwx"},
+			{Tag: "GROUP", Who: "{group}", Perms: "rwx"},
+			{Tag: "MASK", Perms: "rwx"},
+			{Tag: "OTHER", Perms: "---"},
+		}),
+	},
+	"posix_group_read_only": {
+		ACLType: aclTypePOSIX, NeedsGroup: true,
+		Description: "Owner can read and write; the group can only read, also for new files",
+		Entries: posixEntriesWithDefaults([]aclEntry{
+			{Tag: "USER_OBJ", Perms: "rwx"},
+			{Tag: "GROUP_OBJ", Perms: "r-x"},
+			{Tag: "GROUP", Who: "{group}", Perms: "r-x"},
+			{Tag: "MASK", Perms: "r-x"},
+			{Tag: "OTHER", Perms: "---"},
+		}),
+	},
+}
+
+// posixEntriesWithDefaults adds default entries so new files get the same ACL
+func posixEntriesWithDefaults(entries []aclEntry) []aclEntry {
+	all := append([]aclEntry{}, entries...)
+	for _, e := range entries {
+		e.Default = true
+		all = append(all, e)
+	}
+	return all
+}
+
+// aclTemplateNames returns the template names in order
+func aclTemplateNames() []string {
+	names := make([]string, 0, len(aclTemplates))
+	for name := range aclTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateACLPath cleans a path and requires it to be inside a pool
+func validateACLPath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	cleaned := path.Clean(p)
+	if !strings.HasPrefix(cleaned, "/mnt/") {
+		return "", newToolError(ErrorValidation, "path must be inside a pool under /mnt (got: %s)", p)
+	}
+	return cleaned, nil
+}
+
+// trueKeys returns the sorted keys of a map whose values are true
+func trueKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for k, v := range m {
+		if b, _ := v.(bool); b {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseACLEntries converts middleware ACL entries to the simplified form
+func parseACLEntries(acltype string, raw []interface{}) []aclEntry {
+	entries := make([]aclEntry, 0, len(raw))
+	for _, r := range raw {
+		ace, _ := r.(map[string]interface{})
+		e := aclEntry{}
+		e.Tag, _ = ace["tag"].(string)
+		if who, ok := ace["who"].(string); ok && who != "" && !aclSpecialTags[e.Tag] {
+			e.Who = who
+		} else if id, ok := ace["id"].(float64); ok && id >= 0 && !aclSpecialTags[e.Tag] {
+			e.Who = strconv.Itoa(int(id))
+		}
+		perms, _ := ace["perms"].(map[string]interface{})
+
+		if acltype == aclTypePOSIX {
+			bits := []byte("---")
+			for i, p := range []string{"READ", "WRITE", "EXECUTE"} {
+				if on, _ := perms[p].(bool); on {
+					bits[i] = "rwx"[i]
+				}
+			}
+			e.Perms = string(bits)
+			e.Default, _ = ace["default"].(bool)
+		} else {
+			e.Type, _ = ace["type"].(string)
+			if basic, ok := perms["BASIC"].(string); ok {
+				e.Perms = basic
+			} else {
+				e.Perms = strings.Join(trueKeys(perms), ",")
+			}
+			flags, _ := ace["flags"].(map[string]interface{})
+			if basic, ok := flags["BASIC"].(string); ok {
+				e.Inherit = basic == "INHERIT"
+			} else {
+				file, _ := flags["FILE_INHERIT"].(bool)
+				dir, _ := flags["DIRECTORY_INHERIT"].(bool)
+				e.Inherit = file || dir
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// aclEntryPayload converts a simplified entry to the middleware form
+func aclEntryPayload(acltype string, e aclEntry) map[string]interface{} {
+	ace := map[string]interface{}{"tag": e.Tag, "id": -1}
+	if e.Who != "" {
+		ace["id"] = nil
+		ace["who"] = e.Who
+	}
+	if acltype == aclTypePOSIX {
+		ace["perms"] = map[string]interface{}{
+			"READ":    e.Perms[0] == 'r',
+			"WRITE":   e.Perms[1] == 'w',
+			"EXECUTE": e.Perms[2] == 'x',
+		}
+		ace["default"] = e.Default
+		return ace
+	}
+	ace["type"] = e.Type
+	ace["perms"] = map[string]interface{}{"BASIC": e.Perms}
+	flags := "NOINHERIT"
+	if e.Inherit {
+		flags = "INHERIT"
+	}
+	ace["flags"] = map[string]interface{}{"BASIC": flags}
+	return ace
+}
+
+// validateACLEntry checks an entry given to set_acl for the path's ACL type
+func validateACLEntry(acltype string, e aclEntry) error {
+	named := e.Tag == "USER" || e.Tag == "GROUP"
+	if !named && !aclSpecialTags[e.Tag] {
+		return newToolError(ErrorValidation, "unknown ACL tag %q", e.Tag)
+	}
+	if named && e.Who == "" {
+		return newToolError(ErrorValidation, "%s entries need who (a user or group name)", e.Tag)
+	}
+	if acltype == aclTypePOSIX {
+		if strings.HasSuffix(e.Tag, "@") {
+			return newToolError(ErrorValidation, "%s is an NFSv4 tag; POSIX ACLs use USER_OBJ, GROUP_OBJ, OTHER, MASK, USER, and GROUP", e.Tag)
+		}
+		if !posixPermsPattern.MatchString(e.Perms) {
+			return newToolError(ErrorValidation, "POSIX perms must be written like rwx or r-x (got: %q)", e.Perms)
+		}
+		return nil
+	}
+	if !named && !strings.HasSuffix(e.Tag, "@") {
+		return newToolError(ErrorValidation, "%s is a POSIX tag; NFSv4 ACLs use owner@, group@, everyone@, USER, and GROUP", e.Tag)
+	}
+	if e.Type != "ALLOW" && e.Type != "DENY" {
+		return newToolError(ErrorValidation, "NFSv4 entry type must be ALLOW or DENY (got: %q)", e.Type)
+	}
+	if !containsString(nfs4BasicPerms, e.Perms) {
+		return newToolError(ErrorValidation, "NFSv4 perms must be one of %s (got: %q)", strings.Join(nfs4BasicPerms, ", "), e.Perms)
+	}
+	return nil
+}
+
+// getPathACL reads the ACL of a path with user and group names resolved
+func getPathACL(client truenas.Caller, p string) (map[string]interface{}, error) {
+	result, err := client.Call("filesystem.getacl", p, true, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACL of %s: %w", p, err)
+	}
+	var acl map[string]interface{}
+	if err := json.Unmarshal(result, &acl); err != nil {
+		return nil, fmt.Errorf("failed to parse ACL: %w", err)
+	}
+	return acl, nil
+}
+
+// pathOwnership returns the owner and group of a path from its ACL or stat
+// result, preferring names over IDs
+func pathOwnership(info map[string]interface{}) (string, string) {
+	owner, _ := info["user"].(string)
+	if owner == "" && info["uid"] != nil {
+		owner = fmt.Sprintf("%v", info["uid"])
+	}
+	group, _ := info["group"].(string)
+	if group == "" && info["gid"] != nil {
+		group = fmt.Sprintf("%v", info["gid"])
+	}
+	return owner, group
+}
+
+// simplifyACL describes a path's ACL
+func simplifyACL(p string, acl map[string]interface{}) map[string]interface{} {
+	acltype, _ := acl["acltype"].(string)
+	raw, _ := acl["acl"].([]interface{})
+	entries := parseACLEntries(acltype, raw)
+	owner, group := pathOwnership(acl)
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, e.String())
+	}
+	return map[string]interface{}{
+		"path":    p,
+		"acltype": acltype,
+		"trivial": acl["trivial"],
+		"owner":   owner,
+		"group":   group,
+		"entries": entries,
+		"summary": lines,
+	}
+}
+
+func handleGetACL(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	p, _ := args["path"].(string)
+	p, err := validateACLPath(p)
+	if err != nil {
+		return "", err
+	}
+	acl, err := getPathACL(client, p)
+	if err != nil {
+		return "", err
+	}
+
+	response := simplifyACL(p, acl)
+	switch {
+	case response["acltype"] == "DISABLED":
+		response["note"] = "ACLs are disabled on this dataset; access is controlled by the mode (see set_permissions)"
+	case response["trivial"] == true:
+		response["note"] = "The ACL only mirrors the owner/group/other mode bits"
+	}
+	return marshalJSON(response)
+}
+
+// ownershipChange holds validated owner and group arguments
+type ownershipChange struct {
+	owner string
+	group string
+}
+
+func parseOwnershipChange(client truenas.Caller, args map[string]interface{}) (*ownershipChange, error) {
+	owner, _ := args["owner"].(string)
+	group, _ := args["group"].(string)
+	change := &ownershipChange{owner: strings.TrimSpace(owner), group: strings.TrimSpace(group)}
+	if change.owner != "" {
+		if _, err := getUser(client, change.owner); err != nil {
+			return nil, err
+		}
+	}
+	if change.group != "" {
+		if _, err := getGroup(client, change.group); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+// apply adds the ownership change to a setacl/setperm/chown payload and
+// lists the differences from the current owner and group
+func (o *ownershipChange) apply(payload map[string]interface{}, currentOwner, currentGroup string) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if o.owner != "" {
+		payload["user"] = o.owner
+		if o.owner != currentOwner {
+			changes["owner"] = map[string]interface{}{"from": currentOwner, "to": o.owner}
+		}
+	}
+	if o.group != "" {
+		payload["group"] = o.group
+		if o.group != currentGroup {
+			changes["group"] = map[string]interface{}{"from": currentGroup, "to": o.group}
+		}
+	}
+	return changes
+}
+
+// permissionOptions reads the recursive and traverse options
+func permissionOptions(args map[string]interface{}) map[string]interface{} {
+	recursive, _ := args["recursive"].(bool)
+	traverse, _ := args["traverse"].(bool)
+	return map[string]interface{}{"recursive": recursive, "traverse": traverse}
+}
+
+// aclChange is a validated set_acl request
+type aclChange struct {
+	path     string
+	acltype  string
+	current  []aclEntry
+	entries  []aclEntry
+	template string
+	payload  map[string]interface{}
+	diff     map[string]interface{}
+	warnings []string
+}
+
+func planACLChange(client truenas.Caller, args map[string]interface{}) (*aclChange, error) {
+	p, _ := args["path"].(string)
+	p, err := validateACLPath(p)
+	if err != nil {
+		return nil, err
+	}
+	acl, err := getPathACL(client, p)
+	if err != nil {
+		return nil, err
+	}
+	acltype, _ := acl["acltype"].(string)
+	if acltype != aclTypeNFS4 && acltype != aclTypePOSIX {
+		return nil, newToolError(ErrorPrecondition, "ACLs are disabled on %s (acltype %s); set acltype NFSV4 or POSIX with update_dataset, or use set_permissions for a mode", p, acltype)
+	}
+	ownership, err := parseOwnershipChange(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &aclChange{path: p, acltype: acltype}
+	raw, _ := acl["acl"].([]interface{})
+	change.current = parseACLEntries(acltype, raw)
+
+	// Entries come from a template or are given explicitly
+	templateName, _ := args["template"].(string)
+	rawEntries, hasEntries := args["entries"].([]interface{})
+	switch {
+	case templateName != "" && hasEntries:
+		return nil, newToolError(ErrorValidation, "pass either template or entries, not both")
+	case templateName != "":
+		template, ok := aclTemplates[templateName]
+		if !ok {
+			return nil, newToolError(ErrorValidation, "unknown template %q (available: %s)", templateName, strings.Join(aclTemplateNames(), ", "))
+		}
+		if template.ACLType != acltype {
+			return nil, newToolError(ErrorPrecondition, "template %s is for %s ACLs but %s uses %s ACLs", templateName, template.ACLType, p, acltype)
+		}
+		if template.NeedsGroup && ownership.group == "" {
+			return nil, newToolError(ErrorValidation, "template %s needs group: the group that gets access", templateName)
+		}
+		for _, e := range template.Entries {
+			if e.Who == "{group}" {
+				e.Who = ownership.group
+			}
+			change.entries = append(change.entries, e)
+		}
+		change.template = templateName
+	case hasEntries:
+		for i, r := range rawEntries {
+			m, _ := r.(map[string]interface{})
+			e := aclEntry{}
+			e.Tag, _ = m["tag"].(string)
+			e.Who, _ = m["who"].(string)
+			e.Type, _ = m["type"].(string)
+			e.Perms, _ = m["perms"].(string)
+			e.Inherit, _ = m["inherit"].(bool)
+			e.Default, _ = m["default"].(bool)
+			e.Type = strings.ToUpper(e.Type)
+			if acltype == aclTypeNFS4 {
+				e.Perms = strings.ToUpper(e.Perms)
+				if e.Type == "" {
+					e.Type = "ALLOW"
+				}
+			}
+			if err := validateACLEntry(acltype, e); err != nil {
+				return nil, newToolError(ErrorValidation, "entry %d: %v", i+1, err)
+			}
+			change.entries = append(change.entries, e)
+		}
+		if len(change.entries) == 0 {
+			return nil, newToolError(ErrorValidation, "entries must not be empty")
+		}
+	default:
+		return nil, newToolError(ErrorValidation, "pass template (%s) or entries", strings.Join(aclTemplateNames(), ", "))
+	}
+
+	// Named users and groups must exist
+	for _, e := range change.entries {
+		switch e.Tag {
+		case "USER":
+			if _, err := getUser(client, e.Who); err != nil {
+				return nil, err
+			}
+		case "GROUP":
+			if _, err := getGroup(client, e.Who); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dacl := make([]interface{}, 0, len(change.entries))
+	for _, e := range change.entries {
+		dacl = append(dacl, aclEntryPayload(acltype, e))
+	}
+	options := permissionOptions(args)
+	options["validate_effective_acl"] = true
+	change.payload = map[string]interface{}{
+		"path":    p,
+		"acltype": acltype,
+		"dacl":    dacl,
+		"options": options,
+	}
+	currentOwner, currentGroup := pathOwnership(acl)
+	change.diff = ownership.apply(change.payload, currentOwner, currentGroup)
+
+	// Entries are compared by their one-line description
+	before := map[string]bool{}
+	for _, e := range change.current {
+		before[e.String()] = true
+	}
+	after := map[string]bool{}
+	for _, e := range change.entries {
+		after[e.String()] = true
+	}
+	added, removed, kept := []string{}, []string{}, []string{}
+	for _, e := range change.entries {
+		if before[e.String()] {
+			kept = append(kept, e.String())
+		} else {
+			added = append(added, e.String())
+		}
+	}
+	for _, e := range change.current {
+		if !after[e.String()] {
+			removed = append(removed, e.String())
+		}
+	}
+	change.diff["entries"] = map[string]interface{}{"added": added, "removed": removed, "unchanged": kept}
+
+	for _, e := range change.entries {
+		if e.Tag == "everyone@" && e.Type == "ALLOW" && (e.Perms == "FULL_CONTROL" || e.Perms == "MODIFY") {
+			change.warnings = append(change.warnings, "everyone@ can write: every user who can reach the share can change and delete files")
+		}
+		if e.Tag == "OTHER" && strings.Contains(e.Perms, "w") {
+			change.warnings = append(change.warnings, "OTHER can write: every user can change and delete files")
+		}
+	}
+	if options["recursive"] == true {
+		change.warnings = append(change.warnings, fmt.Sprintf("The ACL replaces the ACL of every file and directory below %s; this can take a long time on large trees", p))
+	} else {
+		change.warnings = append(change.warnings, "Only the path itself changes; existing files below keep their ACLs (set recursive to apply to them, new files inherit inheritable entries)")
+	}
+	return change, nil
+}
+
+// startPermissionJob runs a filesystem permission method and tracks its job
+func (r *Registry) startPermissionJob(client truenas.Caller, tool, method string, payload map[string]interface{}, args map[string]interface{}, response map[string]interface{}) (string, error) {
+	result, err := client.Call(method, payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to change permissions of %s: %w", payload["path"], err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+	task, err := r.taskManager.CreateJobTask(tool, args, jobID, aclJobTimeout, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	response["job_id"] = jobID
+	response["task_id"] = task.TaskID
+	response["task_status"] = task.Status
+	response["poll_interval"] = task.PollInterval
+	response["message"] = fmt.Sprintf("Permission change of %s started. Track progress with tasks_get using task_id: %s", payload["path"], task.TaskID)
+	return marshalJSON(response)
+}
+
+func (r *Registry) handleSetACL(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	change, err := planACLChange(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	response := map[string]interface{}{
+		"path":    change.path,
+		"acltype": change.acltype,
+		"changes": change.diff,
+	}
+	if change.template != "" {
+		response["template"] = change.template
+	}
+	return r.startPermissionJob(client, "set_acl", "filesystem.setacl", change.payload, args, response)
+}
+
+// modePattern matches octal modes such as 770 or 0750
+var modePattern = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// permissionsChange is a validated set_permissions request
+type permissionsChange struct {
+	path     string
+	method   string
+	payload  map[string]interface{}
+	current  map[string]interface{}
+	diff     map[string]interface{}
+	warnings []string
+}
+
+func planPermissionsChange(client truenas.Caller, args map[string]interface{}) (*permissionsChange, error) {
+	p, _ := args["path"].(string)
+	p, err := validateACLPath(p)
+	if err != nil {
+		return nil, err
+	}
+	mode, _ := args["mode"].(string)
+	ownership, err := parseOwnershipChange(client, args)
+	if err != nil {
+		return nil, err
+	}
+	if mode == "" && ownership.owner == "" && ownership.group == "" {
+		return nil, newToolError(ErrorValidation, "pass at least one of owner, group, or mode")
+	}
+
+	result, err := client.Call("filesystem.stat", p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	var stat map[string]interface{}
+	if err := json.Unmarshal(result, &stat); err != nil {
+		return nil, fmt.Errorf("failed to parse stat result: %w", err)
+	}
+	currentOwner, currentGroup := pathOwnership(stat)
+	rawMode, _ := stat["mode"].(float64)
+	currentMode := fmt.Sprintf("%03o", int(rawMode)&0o777)
+	hasACL, _ := stat["acl"].(bool)
+
+	change := &permissionsChange{
+		path:    p,
+		method:  "filesystem.chown",
+		current: map[string]interface{}{"owner": currentOwner, "group": currentGroup, "mode": currentMode, "acl": hasACL},
+	}
+	change.payload = map[string]interface{}{"path": p, "options": permissionOptions(args)}
+	change.diff = ownership.apply(change.payload, currentOwner, currentGroup)
+
+	if mode != "" {
+		if !modePattern.MatchString(mode) {
+			return nil, newToolError(ErrorValidation, "mode must be an octal mode like 770 or 0755 (got: %q)", mode)
+		}
+		bits, _ := strconv.ParseInt(mode, 8, 32)
+		mode = fmt.Sprintf("%03o", bits)
+		stripACL, _ := args["strip_acl"].(bool)
+		if hasACL && !stripACL {
+			return nil, newToolError(ErrorPrecondition, "%s has an ACL, which a mode would not control; set strip_acl=true to remove the ACL, or edit it with set_acl", p)
+		}
+		change.method = "filesystem.setperm"
+		change.payload["mode"] = mode
+		change.payload["options"].(map[string]interface{})["stripacl"] = stripACL
+		if mode != currentMode {
+			change.diff["mode"] = map[string]interface{}{"from": currentMode, "to": mode}
+		}
+		if hasACL {
+			change.diff["acl"] = map[string]interface{}{"from": "ACL present", "to": "removed"}
+			change.warnings = append(change.warnings, "The ACL is removed; SMB clients lose any per-user or per-group access it granted")
+		}
+		if bits&0o002 != 0 {
+			change.warnings = append(change.warnings, fmt.Sprintf("Mode %s is world-writable: every user can change and delete files", mode))
+		}
+		if recursive, _ := args["recursive"].(bool); recursive && bits&0o111 != 0 {
+			change.warnings = append(change.warnings, fmt.Sprintf("A recursive mode also applies to files, so %s makes every file executable", mode))
+		}
+	}
+	if len(change.diff) == 0 {
+		change.warnings = append(change.warnings, fmt.Sprintf("%s already has the requested owner, group, and mode", p))
+	}
+	return change, nil
+}
+
+func (r *Registry) handleSetPermissions(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	change, err := planPermissionsChange(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	response := map[string]interface{}{
+		"path":    change.path,
+		"method":  change.method,
+		"changes": change.diff,
+	}
+	return r.startPermissionJob(client, "set_permissions", change.method, change.payload, args, response)
+}
+
+// Dry-run wrappers
+
+func (r *Registry) handleSetACLWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &setACLDryRun{}, r.handleSetACL)
+}
+
+func (r *Registry) handleSetPermissionsWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &setPermissionsDryRun{}, r.handleSetPermissions)
+}
+
+// Dry-run implementations
+
+type setACLDryRun struct{}
+
+func (d *setACLDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	change, err := planACLChange(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Replace the %s ACL of %s with %d entries", change.acltype, change.path, len(change.entries))
+	if change.template != "" {
+		description += fmt.Sprintf(" from template %s (%s)", change.template, aclTemplates[change.template].Description)
+	}
+	return &DryRunResult{
+		Tool: "set_acl",
+		CurrentState: map[string]interface{}{
+			"path":    change.path,
+			"acltype": change.acltype,
+			"entries": change.current,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: description,
+				Operation:   "update",
+				Target:      change.path,
+				Details:     change.diff,
+			},
+		},
+		Warnings: change.warnings,
+	}, nil
+}
+
+type setPermissionsDryRun struct{}
+
+func (d *setPermissionsDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	change, err := planPermissionsChange(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DryRunResult{
+		Tool:         "set_permissions",
+		CurrentState: change.current,
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Change ownership and mode of %s with %s", change.path, change.method),
+				Operation:   "update",
+				Target:      change.path,
+				Details:     change.diff,
+			},
+		},
+		Warnings: change.warnings,
+	}, nil
+}
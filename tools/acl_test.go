@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationFilesystemACL(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("user.query", []map[string]interface{}{{"username": "alice", "uid": float64(1000)}})
+	server.SetRecords("group.query", []map[string]interface{}{{"group": "staff", "gid": float64(1000)}})
+	server.SetResult("filesystem.getacl", map[string]interface{}{
+		"path": "/mnt/tank/team", "acltype": "NFS4", "trivial": false, "uid": float64(0), "gid": float64(0), "user": "root", "group": "wheel",
+		"acl": []interface{}{
+			map[string]interface{}{"tag": "owner@", "id": float64(-1), "type": "ALLOW", "perms": map[string]interface{}{"BASIC": "FULL_CONTROL"}, "flags": map[string]interface{}{"BASIC": "INHERIT"}},
+			map[string]interface{}{"tag": "everyone@", "id": float64(-1), "type": "ALLOW", "perms": map[string]interface{}{"READ_DATA": true, "EXECUTE": true}, "flags": map[string]interface{}{"FILE_INHERIT": true}},
+		},
+	})
+	server.SetResult("filesystem.setacl", float64(61))
+	server.SetResult("filesystem.stat", map[string]interface{}{"mode": float64(0o40755), "uid": float64(0), "gid": float64(0), "user": "root", "group": "wheel", "acl": true})
+	server.SetResult("filesystem.setperm", float64(62))
+
+	result, err := registry.CallTool("get_acl", map[string]interface{}{"path": "/mnt/tank/team/"})
+	if err != nil {
+		t.Fatalf("get_acl failed: %v", err)
+	}
+	if !strings.Contains(result, "ALLOW everyone@ EXECUTE,READ_DATA (inherited)") {
+		t.Errorf("missing simplified everyone@ entry:\n%s", result)
+	}
+
+	args := map[string]interface{}{"path": "/mnt/tank/team", "template": "smb_group_full_control", "group": "staff", "dry_run": true}
+	result, err = registry.CallTool("set_acl", args)
+	if err != nil {
+		t.Fatalf("set_acl dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "ALLOW group:staff FULL_CONTROL (inherited)") || !strings.Contains(result, `"to": "staff"`) {
+		t.Errorf("dry run missing added entry or group change:\n%s", result)
+	}
+	if n := len(server.Calls("filesystem.setacl")); n != 0 {
+		t.Errorf("dry run called filesystem.setacl %d times", n)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"path": "/etc", "template": "smb_owner_only"},
+		{"path": "/mnt/tank/team", "template": "smb_group_read_only"},
+		{"path": "/mnt/tank/team", "entries": []interface{}{map[string]interface{}{"tag": "USER_OBJ", "perms": "rwx"}}},
+		{"path": "/mnt/tank/team", "entries": []interface{}{map[string]interface{}{"tag": "everyone@", "perms": "WRITE_EVERYTHING"}}},
+	} {
+		if _, err := registry.CallTool("set_acl", bad); err == nil || ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("set_acl(%v) error = %v, want VALIDATION", bad, err)
+		}
+	}
+	if _, err := registry.CallTool("set_acl", map[string]interface{}{"path": "/mnt/tank/team", "template": "posix_group_rwx", "group": "staff"}); err == nil || ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("posix template on NFS4 ACL error = %v, want PRECONDITION", err)
+	}
+
+	args["dry_run"] = false
+	result, err = registry.CallTool("set_acl", args)
+	if err != nil {
+		t.Fatalf("set_acl failed: %v", err)
+	}
+	if decodeResult(t, result)["job_id"] != float64(61) {
+		t.Errorf("set_acl did not report job 61:\n%s", result)
+	}
+	payload, _ := server.Calls("filesystem.setacl")[0].Params[0].(map[string]interface{})
+	dacl, _ := payload["dacl"].([]interface{})
+	if payload["group"] != "staff" || len(dacl) != 2 {
+		t.Fatalf("unexpected setacl payload: %v", payload)
+	}
+	if ace, _ := dacl[1].(map[string]interface{}); ace["who"] != "staff" || ace["tag"] != "GROUP" {
+		t.Errorf("unexpected group entry: %v", ace)
+	}
+
+	// A mode on a path with an ACL requires strip_acl
+	if _, err := registry.CallTool("set_permissions", map[string]interface{}{"path": "/mnt/tank/apps", "mode": "770"}); err == nil || ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("set_permissions without strip_acl error = %v, want PRECONDITION", err)
+	}
+	result, err = registry.CallTool("set_permissions", map[string]interface{}{"path": "/mnt/tank/apps", "mode": "0770", "owner": "alice", "strip_acl": true})
+	if err != nil {
+		t.Fatalf("set_permissions failed: %v", err)
+	}
+	if decodeResult(t, result)["method"] != "filesystem.setperm" {
+		t.Errorf("set_permissions did not use filesystem.setperm:\n%s", result)
+	}
+	payload, _ = server.Calls("filesystem.setperm")[0].Params[0].(map[string]interface{})
+	options, _ := payload["options"].(map[string]interface{})
+	if payload["mode"] != "770" || payload["user"] != "alice" || options["stripacl"] != true {
+		t.Errorf("unexpected setperm payload: %v", payload)
+	}
+	if _, err := registry.CallTool("set_permissions", map[string]interface{}{"path": "/mnt/tank/media", "owner": "mallory"}); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown owner error = %v, want NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,772 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// ACME certificates. TrueNAS proves control of each domain with a DNS-01
+// challenge through a DNS authenticator (a DNS provider account), issues the
+// certificate from a CSR, and renews it by itself once it is within
+// renew_days of expiring.
+
+const (
+	letsEncryptDirectory        = "https://acme-v02.api.letsencrypt.org/directory"
+	letsEncryptStagingDirectory = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+	// defaultACMERenewDays is the TrueNAS default for renewing before expiry
+	defaultACMERenewDays = 10
+
+	// acmeTaskTTL is how long an issue_acme_certificate task is kept
+	acmeTaskTTL = 24 * time.Hour
+)
+
+// acmeJobPollInterval is how often the certificate.create jobs are checked
+var acmeJobPollInterval = 5 * time.Second
+
+var (
+	// certificateNamePattern is what TrueNAS accepts as a certificate name
+	certificateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	// acmeDomainPattern matches a DNS name, optionally a *. wildcard
+	acmeDomainPattern = regexp.MustCompile(`^(\*\.)?([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)+[A-Za-z][A-Za-z0-9-]{0,62}$`)
+)
+
+// acmeAuthenticator is a configured DNS authenticator
+type acmeAuthenticator struct {
+	ID         int
+	Name       string
+	Provider   string
+	Attributes map[string]interface{}
+}
+
+// acmeProviderField is one attribute a DNS provider takes
+type acmeProviderField struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// acmeProvider is a DNS provider TrueNAS can answer challenges with
+type acmeProvider struct {
+	Provider string              `json:"provider"`
+	Fields   []acmeProviderField `json:"fields"`
+}
+
+// queryACMEAuthenticators returns the configured DNS authenticators
+func queryACMEAuthenticators(client truenas.Caller) ([]acmeAuthenticator, error) {
+	result, err := client.Call("acme.dns.authenticator.query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DNS authenticators: %w", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(result, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse DNS authenticators: %w", err)
+	}
+
+	authenticators := make([]acmeAuthenticator, 0, len(records))
+	for _, record := range records {
+		id, _ := record["id"].(float64)
+		name, _ := record["name"].(string)
+		attributes, _ := record["attributes"].(map[string]interface{})
+		// The provider moved into attributes in 24.10
+		provider, _ := record["authenticator"].(string)
+		if p, ok := attributes["authenticator"].(string); ok {
+			provider = p
+		}
+		authenticators = append(authenticators, acmeAuthenticator{ID: int(id), Name: name, Provider: provider, Attributes: attributes})
+	}
+	return authenticators, nil
+}
+
+// findACMEAuthenticator resolves an authenticator by name or ID. With no
+// reference, the only configured authenticator is used.
+func findACMEAuthenticator(authenticators []acmeAuthenticator, ref string) (*acmeAuthenticator, error) {
+	if ref == "" {
+		if len(authenticators) == 1 {
+			return &authenticators[0], nil
+		}
+		names := make([]string, 0, len(authenticators))
+		for _, a := range authenticators {
+			names = append(names, a.Name)
+		}
+		if len(names) == 0 {
+			return nil, newToolError(ErrorPrecondition, "no DNS authenticator is configured; create one with create_acme_dns_authenticator (list_acme_dns_authenticators shows what each provider needs)")
+		}
+		return nil, newToolError(ErrorValidation, "authenticator is required when several DNS authenticators exist: %s", strings.Join(names, ", "))
+	}
+	id, idErr := strconv.Atoi(ref)
+	for i, a := range authenticators {
+		if a.Name == ref || idErr == nil && a.ID == id {
+			return &authenticators[i], nil
+		}
+	}
+	return nil, newToolError(ErrorNotFound, "DNS authenticator %s not found", ref)
+}
+
+// queryACMEProviders returns the DNS providers and the attributes each takes
+func queryACMEProviders(client truenas.Caller) ([]acmeProvider, error) {
+	result, err := client.Call("acme.dns.authenticator.authenticator_schemas")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS authenticator schemas: %w", err)
+	}
+	var schemas []map[string]interface{}
+	if err := json.Unmarshal(result, &schemas); err != nil {
+		return nil, fmt.Errorf("failed to parse DNS authenticator schemas: %w", err)
+	}
+
+	providers := make([]acmeProvider, 0, len(schemas))
+	for _, schema := range schemas {
+		key, _ := schema["key"].(string)
+		if key == "" {
+			continue
+		}
+		providers = append(providers, acmeProvider{Provider: key, Fields: acmeSchemaFields(schema["schema"])})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Provider < providers[j].Provider })
+	return providers, nil
+}
+
+// acmeSchemaFields reads a provider's attributes from its schema: a JSON
+// schema object since 24.10, a list of attributes before
+func acmeSchemaFields(schema interface{}) []acmeProviderField {
+	fields := []acmeProviderField{}
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		required := map[string]bool{}
+		if list, ok := s["required"].([]interface{}); ok {
+			for _, name := range list {
+				if name, ok := name.(string); ok {
+					required[name] = true
+				}
+			}
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		for name, prop := range props {
+			if name == "authenticator" {
+				continue
+			}
+			prop, _ := prop.(map[string]interface{})
+			description, _ := prop["title"].(string)
+			if d, ok := prop["description"].(string); ok && d != "" {
+				description = d
+			}
+			fields = append(fields, acmeProviderField{Name: name, Required: required[name], Description: description})
+		}
+	case []interface{}:
+		for _, attr := range s {
+			attr, _ := attr.(map[string]interface{})
+			name, _ := attr["_name_"].(string)
+			if name == "" || name == "authenticator" {
+				continue
+			}
+			required, _ := attr["_required_"].(bool)
+			description, _ := attr["title"].(string)
+			fields = append(fields, acmeProviderField{Name: name, Required: required, Description: description})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Required != fields[j].Required {
+			return fields[i].Required
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// findACMEProvider looks up a provider by key
+func findACMEProvider(providers []acmeProvider, key string) (*acmeProvider, error) {
+	keys := make([]string, 0, len(providers))
+	for i, p := range providers {
+		if p.Provider == key {
+			return &providers[i], nil
+		}
+		keys = append(keys, p.Provider)
+	}
+	return nil, newToolError(ErrorValidation, "unknown DNS provider %q; supported providers: %s", key, strings.Join(keys, ", "))
+}
+
+// simplifyACMEAuthenticator lists which attributes are set, never their
+// values, since most are API tokens and secrets
+func simplifyACMEAuthenticator(a acmeAuthenticator) map[string]interface{} {
+	configured := []string{}
+	for name, value := range a.Attributes {
+		if name != "authenticator" && value != nil && value != "" {
+			configured = append(configured, name)
+		}
+	}
+	sort.Strings(configured)
+	return map[string]interface{}{
+		"id":                a.ID,
+		"name":              a.Name,
+		"provider":          a.Provider,
+		"configured_fields": configured,
+	}
+}
+
+func handleListACMEDNSAuthenticators(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	authenticators, err := queryACMEAuthenticators(client)
+	if err != nil {
+		return "", err
+	}
+	providers, err := queryACMEProviders(client)
+	if err != nil {
+		return "", err
+	}
+	if key, ok := args["provider"].(string); ok && key != "" {
+		provider, err := findACMEProvider(providers, key)
+		if err != nil {
+			return "", err
+		}
+		providers = []acmeProvider{*provider}
+	}
+
+	simplified := make([]map[string]interface{}, 0, len(authenticators))
+	for _, a := range authenticators {
+		simplified = append(simplified, simplifyACMEAuthenticator(a))
+	}
+	response := map[string]interface{}{
+		"authenticators": simplified,
+		"count":          len(simplified),
+		"providers":      providers,
+	}
+	if len(authenticators) == 0 {
+		response["note"] = "No DNS authenticator is configured. Issuing an ACME certificate needs one for the DNS provider hosting the domain; create it with create_acme_dns_authenticator."
+	}
+	return marshalJSON(response)
+}
+
+// acmeAuthenticatorCreate is a validated acme.dns.authenticator.create request
+type acmeAuthenticatorCreate struct {
+	name       string
+	provider   *acmeProvider
+	attributes map[string]interface{}
+}
+
+// payload returns the acme.dns.authenticator.create argument
+func (c *acmeAuthenticatorCreate) payload() map[string]interface{} {
+	attributes := map[string]interface{}{"authenticator": c.provider.Provider}
+	for name, value := range c.attributes {
+		attributes[name] = value
+	}
+	return map[string]interface{}{"name": c.name, "attributes": attributes}
+}
+
+// parseACMEAuthenticatorCreate checks the name, provider, and attributes
+// against the provider's schema
+func parseACMEAuthenticatorCreate(client truenas.Caller, args map[string]interface{}) (*acmeAuthenticatorCreate, error) {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return nil, newToolError(ErrorValidation, "name is required")
+	}
+	key, _ := args["provider"].(string)
+	if key == "" {
+		return nil, newToolError(ErrorValidation, "provider is required (list_acme_dns_authenticators lists the supported providers)")
+	}
+
+	authenticators, err := queryACMEAuthenticators(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range authenticators {
+		if a.Name == name {
+			return nil, newToolError(ErrorValidation, "a DNS authenticator named %s already exists", name)
+		}
+	}
+	providers, err := queryACMEProviders(client)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := findACMEProvider(providers, key)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, _ := args["attributes"].(map[string]interface{})
+	known := map[string]bool{}
+	var missing []string
+	for _, field := range provider.Fields {
+		known[field.Name] = true
+		if value, ok := attributes[field.Name]; field.Required && (!ok || value == nil || value == "") {
+			missing = append(missing, field.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, newToolError(ErrorValidation, "the %s provider requires %s", provider.Provider, strings.Join(missing, ", "))
+	}
+	for field := range attributes {
+		if !known[field] {
+			return nil, newToolError(ErrorValidation, "the %s provider has no attribute %s", provider.Provider, field)
+		}
+	}
+	return &acmeAuthenticatorCreate{name: name, provider: provider, attributes: attributes}, nil
+}
+
+func handleCreateACMEDNSAuthenticator(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := parseACMEAuthenticatorCreate(client, args)
+	if err != nil {
+		return "", err
+	}
+	result, err := client.Call("acme.dns.authenticator.create", create.payload())
+	if err != nil {
+		return "", fmt.Errorf("failed to create DNS authenticator: %w", err)
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+	id, _ := created["id"].(float64)
+	return marshalJSON(map[string]interface{}{
+		"id":       int(id),
+		"name":     create.name,
+		"provider": create.provider.Provider,
+		"created":  true,
+		"message":  fmt.Sprintf("DNS authenticator %s created. Use it with issue_acme_certificate (authenticator=%s).", create.name, create.name),
+	})
+}
+
+func handleCreateACMEDNSAuthenticatorWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createACMEDNSAuthenticatorDryRun{}, handleCreateACMEDNSAuthenticator)
+}
+
+type createACMEDNSAuthenticatorDryRun struct{}
+
+func (c *createACMEDNSAuthenticatorDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := parseACMEAuthenticatorCreate(client, args)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(create.attributes))
+	for name := range create.attributes {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+
+	return &DryRunResult{
+		Tool:         "create_acme_dns_authenticator",
+		CurrentState: map[string]interface{}{"provider": create.provider},
+		PlannedActions: []PlannedAction{{
+			Step:        1,
+			Description: fmt.Sprintf("Create DNS authenticator %s for %s", create.name, create.provider.Provider),
+			Operation:   "create",
+			Target:      "acme_dns_authenticator:" + create.name,
+			Details:     map[string]interface{}{"provider": create.provider.Provider, "fields": fields},
+		}},
+		Warnings: []string{
+			"TrueNAS stores the provider credentials; give them only the DNS permissions needed to create TXT records for the domains to be certified",
+		},
+	}, nil
+}
+
+// acmeIssue is the plan and result of an issue_acme_certificate task
+type acmeIssue struct {
+	Name             string   `json:"name"`
+	Domains          []string `json:"domains"`
+	Authenticator    string   `json:"authenticator"`
+	Directory        string   `json:"acme_directory_uri"`
+	RenewDays        int      `json:"renew_days"`
+	KeyType          string   `json:"key_type"`
+	SetUICertificate bool     `json:"set_as_ui_certificate"`
+	CSRID            *int     `json:"csr_id,omitempty"`
+	CertificateID    *int     `json:"certificate_id,omitempty"`
+	UICertificateSet bool     `json:"ui_certificate_set,omitempty"`
+
+	authenticatorID int
+}
+
+// csrName is the name of the CSR the certificate is issued from
+func (p *acmeIssue) csrName() string {
+	return p.Name + "_csr"
+}
+
+// csrPayload returns the certificate.create argument for the CSR
+func (p *acmeIssue) csrPayload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"name":             p.csrName(),
+		"create_type":      "CERTIFICATE_CREATE_CSR",
+		"key_type":         p.KeyType,
+		"digest_algorithm": "SHA256",
+		"common":           strings.TrimPrefix(p.Domains[0], "*."),
+		"san":              p.Domains,
+	}
+	if p.KeyType == "EC" {
+		payload["ec_curve"] = "SECP384R1"
+	} else {
+		payload["key_length"] = 2048
+	}
+	return payload
+}
+
+// acmePayload returns the certificate.create argument that issues the
+// certificate, answering every domain's challenge with the authenticator
+func (p *acmeIssue) acmePayload(csrID int) map[string]interface{} {
+	mapping := map[string]interface{}{}
+	for _, domain := range p.Domains {
+		mapping[domain] = p.authenticatorID
+	}
+	return map[string]interface{}{
+		"name":               p.Name,
+		"create_type":        "CERTIFICATE_CREATE_ACME",
+		"csr_id":             csrID,
+		"tos":                true,
+		"acme_directory_uri": p.Directory,
+		"renew_days":         p.RenewDays,
+		"dns_mapping":        mapping,
+	}
+}
+
+// parseACMEIssue checks the certificate request. With requireAuthenticator
+// false a missing authenticator is left for the dry run to report.
+func parseACMEIssue(client truenas.Caller, args map[string]interface{}, requireAuthenticator bool) (*acmeIssue, []acmeAuthenticator, error) {
+	plan := &acmeIssue{RenewDays: defaultACMERenewDays, KeyType: "RSA", Directory: letsEncryptDirectory}
+
+	plan.Name, _ = args["name"].(string)
+	if !certificateNamePattern.MatchString(plan.Name) {
+		return nil, nil, newToolError(ErrorValidation, "name is required and may contain only letters, digits, _ and -")
+	}
+	domains, _ := args["domains"].([]interface{})
+	seen := map[string]bool{}
+	for _, d := range domains {
+		domain, _ := d.(string)
+		domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+		if !acmeDomainPattern.MatchString(domain) {
+			return nil, nil, newToolError(ErrorValidation, "invalid domain %q: give fully qualified names such as nas.example.com or *.example.com", d)
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			plan.Domains = append(plan.Domains, domain)
+		}
+	}
+	if len(plan.Domains) == 0 {
+		return nil, nil, newToolError(ErrorValidation, "domains is required")
+	}
+
+	switch directory, _ := args["directory"].(string); directory {
+	case "", "production":
+	case "staging":
+		plan.Directory = letsEncryptStagingDirectory
+	default:
+		if !strings.HasPrefix(directory, "https://") {
+			return nil, nil, newToolError(ErrorValidation, "directory must be production, staging, or an https:// ACME directory URL")
+		}
+		plan.Directory = directory
+	}
+	if days, ok := args["renew_days"].(float64); ok {
+		if days < 1 || days > 30 || days != float64(int(days)) {
+			return nil, nil, newToolError(ErrorValidation, "renew_days must be a whole number from 1 to 30")
+		}
+		plan.RenewDays = int(days)
+	}
+	if keyType, ok := args["key_type"].(string); ok && keyType != "" {
+		plan.KeyType = strings.ToUpper(keyType)
+		if plan.KeyType != "RSA" && plan.KeyType != "EC" {
+			return nil, nil, newToolError(ErrorValidation, "key_type must be RSA or EC")
+		}
+	}
+	plan.SetUICertificate, _ = args["set_as_ui_certificate"].(bool)
+
+	certs, err := queryCertificateNames(client)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range []string{plan.Name, plan.csrName()} {
+		if certs[name] {
+			return nil, nil, newToolError(ErrorValidation, "a certificate or CSR named %s already exists; choose another name", name)
+		}
+	}
+
+	authenticators, err := queryACMEAuthenticators(client)
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, _ := args["authenticator"].(string)
+	authenticator, err := findACMEAuthenticator(authenticators, ref)
+	if err != nil {
+		if requireAuthenticator {
+			return nil, nil, err
+		}
+		return plan, authenticators, nil
+	}
+	plan.Authenticator = authenticator.Name
+	plan.authenticatorID = authenticator.ID
+	return plan, authenticators, nil
+}
+
+// queryCertificateNames returns the names of certificates and CSRs
+func queryCertificateNames(client truenas.Caller) (map[string]bool, error) {
+	result, err := client.Call("certificate.query", []interface{}{}, map[string]interface{}{"select": []string{"id", "name"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificates: %w", err)
+	}
+	var certs []map[string]interface{}
+	if err := json.Unmarshal(result, &certs); err != nil {
+		return nil, fmt.Errorf("failed to parse certificates: %w", err)
+	}
+	names := make(map[string]bool, len(certs))
+	for _, cert := range certs {
+		if name, ok := cert["name"].(string); ok {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// currentUICertificate returns the ID and name of the web UI certificate
+func currentUICertificate(client truenas.Caller) (map[string]interface{}, error) {
+	result, err := client.Call("system.general.config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get general settings: %w", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse general settings: %w", err)
+	}
+	// Expanded to the certificate object on current releases
+	switch cert := config["ui_certificate"].(type) {
+	case map[string]interface{}:
+		return map[string]interface{}{"id": cert["id"], "name": cert["name"], "until": cert["until"]}, nil
+	case float64:
+		return map[string]interface{}{"id": int(cert)}, nil
+	}
+	return nil, nil
+}
+
+func (r *Registry) handleIssueACMECertificate(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.taskManager == nil {
+		return "", fmt.Errorf("task tracking is not available")
+	}
+	if accepted, _ := args["accept_tos"].(bool); !accepted {
+		return "", newToolError(ErrorValidation, "accept_tos=true is required: the ACME provider's terms of service must be accepted to issue a certificate (review them with the user first)")
+	}
+	plan, _, err := parseACMEIssue(client, args, true)
+	if err != nil {
+		return "", err
+	}
+
+	task, err := r.taskManager.CreateLocalTask("issue_acme_certificate", args, acmeTaskTTL, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+	r.taskManager.UpdateTask(task.TaskID, tasks.TaskStatusWorking, "Creating the certificate signing request", *plan)
+
+	// Issuing outlives this call, so it runs under the task's context rather
+	// than the call's deadline
+	taskCtx := r.taskManager.Context(task.TaskID)
+	go r.runACMEIssue(taskCtx, task.TaskID, client.WithContext(taskCtx), plan)
+
+	return marshalJSON(map[string]interface{}{
+		"name":          plan.Name,
+		"domains":       plan.Domains,
+		"authenticator": plan.Authenticator,
+		"task_id":       task.TaskID,
+		"task_status":   task.Status,
+		"poll_interval": task.PollInterval,
+		"message": fmt.Sprintf("Certificate issuance started. DNS validation usually takes 1-5 minutes; track progress with tasks_get using task_id: %s. TrueNAS renews the certificate %d days before it expires.",
+			task.TaskID, plan.RenewDays),
+	})
+}
+
+// runACMEIssue creates the CSR, issues the certificate, and optionally makes
+// it the web UI certificate, recording progress on the task
+func (r *Registry) runACMEIssue(ctx context.Context, taskID string, client truenas.Caller, plan *acmeIssue) {
+	fail := func(format string, a ...interface{}) {
+		if !r.taskManager.IsActive(taskID) {
+			return // cancelled
+		}
+		message := fmt.Sprintf(format, a...)
+		if plan.CSRID != nil && plan.CertificateID == nil {
+			message += fmt.Sprintf(". The CSR %s (id %d) was kept; delete it before retrying with the same name.", plan.csrName(), *plan.CSRID)
+		}
+		if err := r.taskManager.UpdateTask(taskID, tasks.TaskStatusFailed, message, *plan); err != nil {
+			slog.Error("Failed to record certificate failure", "task_id", taskID, "error", err)
+		}
+	}
+
+	csrID, err := r.runACMEJob(ctx, taskID, client, plan.csrPayload())
+	if err != nil {
+		fail("Creating the CSR failed: %v", err)
+		return
+	}
+	plan.CSRID = &csrID
+	if !r.taskManager.IsActive(taskID) {
+		return
+	}
+	r.taskManager.UpdateTask(taskID, tasks.TaskStatusWorking,
+		fmt.Sprintf("Requesting the certificate for %s (answering DNS challenges with %s)", strings.Join(plan.Domains, ", "), plan.Authenticator), *plan)
+
+	certID, err := r.runACMEJob(ctx, taskID, client, plan.acmePayload(csrID))
+	if err != nil {
+		fail("Issuing the certificate failed: %v", err)
+		return
+	}
+	plan.CertificateID = &certID
+
+	message := fmt.Sprintf("Certificate %s issued for %s", plan.Name, strings.Join(plan.Domains, ", "))
+	if plan.SetUICertificate {
+		if _, err := client.Call("system.general.update", map[string]interface{}{"ui_certificate": certID}); err != nil {
+			fail("Certificate %s was issued but could not be set as the web UI certificate: %v", plan.Name, err)
+			return
+		}
+		plan.UICertificateSet = true
+		// The web server restarts after a short delay, dropping connections
+		// through it; the client reconnects
+		if _, err := client.Call("system.general.ui_restart"); err != nil {
+			message += fmt.Sprintf(" and set as the web UI certificate, but restarting the web UI failed (%v); it takes effect on the next restart", err)
+		} else {
+			message += " and set as the web UI certificate; the web UI is restarting"
+		}
+	}
+	if err := r.taskManager.UpdateTask(taskID, tasks.TaskStatusCompleted, message, *plan); err != nil {
+		slog.Error("Failed to record certificate result", "task_id", taskID, "error", err)
+	}
+}
+
+// runACMEJob runs certificate.create and returns the ID of the certificate
+// it created. Cancelling the task aborts the job.
+func (r *Registry) runACMEJob(ctx context.Context, taskID string, client truenas.Caller, payload map[string]interface{}) (int, error) {
+	result, err := client.Call("certificate.create", payload)
+	if err != nil {
+		return 0, err
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return 0, err
+	}
+	job, err := waitForJob(ctx, client, jobID, acmeJobPollInterval)
+	if ctx.Err() != nil && !r.taskManager.IsActive(taskID) {
+		client.WithContext(context.Background()).Call("core.job_abort", jobID)
+		return 0, fmt.Errorf("cancelled")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("certificate.create %w", err)
+	}
+	cert, _ := job["result"].(map[string]interface{})
+	id, ok := cert["id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("certificate.create job %d returned no certificate", jobID)
+	}
+	return int(id), nil
+}
+
+func (r *Registry) handleIssueACMECertificateWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &issueACMECertificateDryRun{}, r.handleIssueACMECertificate)
+}
+
+type issueACMECertificateDryRun struct{}
+
+func (d *issueACMECertificateDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, authenticators, err := parseACMEIssue(client, args, false)
+	if err != nil {
+		return nil, err
+	}
+	uiCert, err := currentUICertificate(client)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := make([]map[string]interface{}, 0, len(authenticators))
+	for _, a := range authenticators {
+		configured = append(configured, simplifyACMEAuthenticator(a))
+	}
+	requirements := &Requirements{}
+	warnings := []string{}
+	if accepted, _ := args["accept_tos"].(bool); !accepted {
+		requirements.Conditions = append(requirements.Conditions, "The ACME provider's terms of service must be accepted with accept_tos=true")
+	}
+
+	// Without a usable authenticator the preview says what one needs
+	if plan.Authenticator == "" {
+		providers, err := queryACMEProviders(client)
+		if err != nil {
+			return nil, err
+		}
+		if ref, _ := args["authenticator"].(string); ref != "" {
+			warnings = append(warnings, fmt.Sprintf("DNS authenticator %s does not exist", ref))
+		} else if len(authenticators) > 1 {
+			warnings = append(warnings, "Several DNS authenticators exist; choose one with authenticator")
+		}
+		requirements.Conditions = append(requirements.Conditions,
+			"A DNS authenticator for the provider hosting "+strings.Join(plan.Domains, ", ")+" (create one with create_acme_dns_authenticator)")
+		for _, provider := range providers {
+			var required []string
+			for _, field := range provider.Fields {
+				if field.Required {
+					required = append(required, field.Name)
+				}
+			}
+			if len(required) == 0 {
+				required = []string{"no required fields"}
+			}
+			requirements.Conditions = append(requirements.Conditions, fmt.Sprintf("%s authenticator: %s", provider.Provider, strings.Join(required, ", ")))
+		}
+	} else {
+		for _, domain := range plan.Domains {
+			requirements.Conditions = append(requirements.Conditions,
+				fmt.Sprintf("DNS authenticator %s can create TXT records for _acme-challenge.%s", plan.Authenticator, strings.TrimPrefix(domain, "*.")))
+		}
+	}
+	if plan.Directory == letsEncryptStagingDirectory {
+		warnings = append(warnings, "Let's Encrypt staging certificates are not trusted by browsers; use them to test the DNS setup, then issue from production")
+	}
+	if plan.SetUICertificate {
+		warnings = append(warnings, "The web UI restarts with the new certificate, briefly dropping web UI and API connections; the certificate only covers "+strings.Join(plan.Domains, ", ")+", so browse to the UI by one of those names")
+	}
+
+	actions := []PlannedAction{
+		{
+			Step:        1,
+			Description: fmt.Sprintf("Create certificate signing request %s (%s key) for %s", plan.csrName(), plan.KeyType, strings.Join(plan.Domains, ", ")),
+			Operation:   "create",
+			Target:      "certificate_csr:" + plan.csrName(),
+		},
+		{
+			Step:        2,
+			Description: "Issue the certificate through ACME DNS-01 validation",
+			Operation:   "create",
+			Target:      "certificate:" + plan.Name,
+			Details: map[string]interface{}{
+				"acme_directory_uri": plan.Directory,
+				"authenticator":      plan.Authenticator,
+				"domains":            plan.Domains,
+				"renew_days":         plan.RenewDays,
+				"auto_renew":         fmt.Sprintf("TrueNAS renews the certificate %d days before it expires", plan.RenewDays),
+			},
+		},
+	}
+	if plan.SetUICertificate {
+		actions = append(actions, PlannedAction{
+			Step:        3,
+			Description: "Set the certificate as the web UI certificate and restart the web UI",
+			Operation:   "update",
+			Target:      "system.general:ui_certificate",
+			Details:     map[string]interface{}{"old": uiCert, "new": plan.Name},
+		})
+	}
+
+	return &DryRunResult{
+		Tool: "issue_acme_certificate",
+		CurrentState: map[string]interface{}{
+			"ui_certificate":      uiCert,
+			"dns_authenticators":  configured,
+			"authenticator_count": len(configured),
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+		Requirements:   requirements,
+		EstimatedTime: &EstimatedTime{
+			MinSeconds: 30,
+			MaxSeconds: 600,
+			Note:       "Mostly waiting for the TXT records to propagate to the provider's DNS servers",
+		},
+	}, nil
+}
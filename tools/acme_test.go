@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationACMECertificate(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	defer func(interval time.Duration) { acmeJobPollInterval = interval }(acmeJobPollInterval)
+	acmeJobPollInterval = 10 * time.Millisecond
+
+	server.SetRecords("acme.dns.authenticator.query", []map[string]interface{}{
+		{"id": float64(1), "name": "cf", "attributes": map[string]interface{}{"authenticator": "cloudflare", "api_token": "cf-token-value"}},
+	})
+	server.SetResult("acme.dns.authenticator.authenticator_schemas", []map[string]interface{}{
+		{"key": "cloudflare", "schema": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"authenticator":    map[string]interface{}{"type": "string"},
+				"api_token":        map[string]interface{}{"title": "API Token"},
+				"cloudflare_email": map[string]interface{}{"title": "Cloudflare Email"},
+			},
+			"required": []interface{}{"authenticator", "api_token"},
+		}},
+		{"key": "route53", "schema": []interface{}{
+			map[string]interface{}{"_name_": "access_key_id", "_required_": true},
+			map[string]interface{}{"_name_": "secret_access_key", "_required_": true},
+		}},
+	})
+	server.SetRecords("certificate.query", []map[string]interface{}{{"id": float64(1), "name": "truenas_default"}})
+	server.SetResult("system.general.config", map[string]interface{}{"ui_certificate": map[string]interface{}{"id": float64(1), "name": "truenas_default"}})
+	server.SetResult("system.general.update", map[string]interface{}{"ui_certificate": float64(3)})
+	server.SetResult("system.general.ui_restart", nil)
+	server.SetResult("acme.dns.authenticator.create", map[string]interface{}{"id": float64(2), "name": "r53"})
+	nextCert := 1
+	server.Handle("certificate.create", func(params []interface{}) (interface{}, error) {
+		nextCert++
+		return server.AddJob("certificate.create", params, truenastest.JobSpec{Steps: 1, Result: map[string]interface{}{"id": float64(nextCert)}}), nil
+	})
+
+	result, err := registry.CallTool("list_acme_dns_authenticators", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list_acme_dns_authenticators failed: %v", err)
+	}
+	if strings.Contains(result, "cf-token-value") || !strings.Contains(result, `"secret_access_key"`) {
+		t.Errorf("list should hide credentials and describe every provider:\n%s", result)
+	}
+
+	if _, err := registry.CallTool("create_acme_dns_authenticator", map[string]interface{}{"name": "r53", "provider": "route53", "attributes": map[string]interface{}{"access_key_id": "AKIA"}}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("missing secret_access_key error = %v, want VALIDATION", err)
+	}
+	createArgs := map[string]interface{}{"name": "r53", "provider": "route53", "attributes": map[string]interface{}{"access_key_id": "AKIA", "secret_access_key": "s3cret"}}
+	if _, err := registry.CallTool("create_acme_dns_authenticator", createArgs); err != nil {
+		t.Fatalf("create_acme_dns_authenticator failed: %v", err)
+	}
+	created := server.Calls("acme.dns.authenticator.create")[0].Params[0].(map[string]interface{})
+	if created["attributes"].(map[string]interface{})["authenticator"] != "route53" {
+		t.Errorf("acme.dns.authenticator.create payload = %v", created)
+	}
+
+	// A dry run without a usable authenticator lists what each provider needs
+	args := map[string]interface{}{"name": "nas_cert", "domains": []interface{}{"NAS.example.com"}, "authenticator": "missing", "dry_run": true}
+	result, err = registry.CallTool("issue_acme_certificate", args)
+	if err != nil {
+		t.Fatalf("issue_acme_certificate dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "route53 authenticator: access_key_id, secret_access_key") || !strings.Contains(result, "accept_tos") {
+		t.Errorf("dry run should list authenticator requirements:\n%s", result)
+	}
+
+	args = map[string]interface{}{"name": "nas_cert", "domains": []interface{}{"nas.example.com"}, "authenticator": "cf", "set_as_ui_certificate": true}
+	if _, err := registry.CallTool("issue_acme_certificate", args); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("issue without accept_tos error = %v, want VALIDATION", err)
+	}
+	args["accept_tos"] = true
+	result, err = registry.CallTool("issue_acme_certificate", args)
+	if err != nil {
+		t.Fatalf("issue_acme_certificate failed: %v", err)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := registry.CallTool("tasks_get", map[string]interface{}{"task_id": taskID})
+		if err != nil {
+			t.Fatalf("tasks_get failed: %v", err)
+		}
+		task := decodeResult(t, result)
+		if task["status"] == string(tasks.TaskStatusCompleted) {
+			break
+		}
+		if task["status"] != string(tasks.TaskStatusWorking) || time.Now().After(deadline) {
+			t.Fatalf("certificate task did not complete:\n%s", result)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	creates := server.Calls("certificate.create")
+	if len(creates) != 2 {
+		t.Fatalf("certificate.create calls = %v, want CSR then ACME", creates)
+	}
+	if csr := creates[0].Params[0].(map[string]interface{}); csr["create_type"] != "CERTIFICATE_CREATE_CSR" || csr["name"] != "nas_cert_csr" {
+		t.Errorf("CSR payload = %v", csr)
+	}
+	acme := creates[1].Params[0].(map[string]interface{})
+	if acme["csr_id"] != float64(2) || acme["dns_mapping"].(map[string]interface{})["nas.example.com"] != float64(1) || acme["acme_directory_uri"] != letsEncryptDirectory {
+		t.Errorf("ACME payload = %v", acme)
+	}
+	update := server.Calls("system.general.update")
+	if len(update) != 1 || update[0].Params[0].(map[string]interface{})["ui_certificate"] != float64(3) {
+		t.Errorf("system.general.update calls = %v, want the new certificate", update)
+	}
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Advanced system settings (system.advanced): swap size, syslog level, and
+// console and kernel options
+
+// syslogLevels are the system.advanced sysloglevel values without their F_
+// prefix, most severe first
+var syslogLevels = []string{"EMERG", "ALERT", "CRIT", "ERR", "WARNING", "NOTICE", "INFO", "DEBUG"}
+
+// serialSpeeds are the baud rates the serial console supports
+var serialSpeeds = []string{"9600", "19200", "38400", "57600", "115200"}
+
+// rebootSettings are the system.advanced fields that take effect only at
+// the next boot, keyed to their argument names
+var rebootSettings = map[string]string{
+	"serialconsole":        "serial_console",
+	"serialport":           "serial_port",
+	"serialspeed":          "serial_speed",
+	"kernel_extra_options": "kernel_extra_options",
+	"debugkern":            "kernel_debug",
+}
+
+// simplifyAdvancedSettings summarizes the swap, syslog, console, and kernel
+// settings of system.advanced.config
+func simplifyAdvancedSettings(config map[string]interface{}) map[string]interface{} {
+	level, _ := config["sysloglevel"].(string)
+	summary := map[string]interface{}{
+		"syslog": map[string]interface{}{
+			"level":     strings.TrimPrefix(level, "F_"),
+			"server":    config["syslogserver"],
+			"transport": config["syslog_transport"],
+		},
+		"console": map[string]interface{}{
+			"console_menu":   config["consolemenu"],
+			"serial_console": config["serialconsole"],
+			"serial_port":    config["serialport"],
+			"serial_speed":   config["serialspeed"],
+		},
+		"kernel": map[string]interface{}{
+			"extra_options": config["kernel_extra_options"],
+			"debug":         config["debugkern"],
+		},
+	}
+	if swap, ok := config["swapondrive"]; ok {
+		summary["swap"] = map[string]interface{}{
+			"size_gib": swap,
+			"note":     "Swap partition size created on each disk when it is added to a pool",
+		}
+	} else {
+		summary["swap"] = map[string]interface{}{
+			"supported": false,
+			"note":      "This TrueNAS version has no swap size setting",
+		}
+	}
+	return summary
+}
+
+// serialPortChoices returns the ports the serial console can use
+func serialPortChoices(client truenas.Caller) ([]string, error) {
+	result, err := client.Call("system.advanced.serial_port_choices")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get serial port choices: %w", err)
+	}
+	var choices map[string]interface{}
+	if err := json.Unmarshal(result, &choices); err != nil {
+		return nil, fmt.Errorf("failed to parse serial port choices: %w", err)
+	}
+	ports := make([]string, 0, len(choices))
+	for port := range choices {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	return ports, nil
+}
+
+// advancedSettingsUpdate builds the system.advanced.update payload from args
+func advancedSettingsUpdate(client truenas.Caller, config map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if raw, ok := args["swap_size_gib"]; ok {
+		if _, supported := config["swapondrive"]; !supported {
+			return nil, newToolError(ErrorPrecondition, "this TrueNAS version has no swap size setting")
+		}
+		size, ok := raw.(float64)
+		if !ok || size < 0 || size != float64(int(size)) {
+			return nil, newToolError(ErrorValidation, "swap_size_gib must be a whole number of GiB, 0 or more (got: %v)", raw)
+		}
+		update["swapondrive"] = int(size)
+	}
+	if level, ok := args["syslog_level"].(string); ok {
+		level = strings.TrimPrefix(strings.ToUpper(level), "F_")
+		if !containsString(syslogLevels, level) {
+			return nil, newToolError(ErrorValidation, "syslog_level must be one of %s (got: %s)", strings.Join(syslogLevels, ", "), args["syslog_level"])
+		}
+		update["sysloglevel"] = "F_" + level
+	}
+	if menu, ok := args["console_menu"].(bool); ok {
+		update["consolemenu"] = menu
+	}
+	if serial, ok := args["serial_console"].(bool); ok {
+		update["serialconsole"] = serial
+	}
+	if port, ok := args["serial_port"].(string); ok {
+		choices, err := serialPortChoices(client)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(choices, port) {
+			return nil, newToolError(ErrorValidation, "serial port %s not found; available: %s", port, strings.Join(choices, ", "))
+		}
+		update["serialport"] = port
+	}
+	if raw, ok := args["serial_speed"]; ok {
+		speed := fmt.Sprint(raw)
+		if f, isNumber := raw.(float64); isNumber {
+			speed = fmt.Sprint(int(f))
+		}
+		if !containsString(serialSpeeds, speed) {
+			return nil, newToolError(ErrorValidation, "serial_speed must be one of %s (got: %v)", strings.Join(serialSpeeds, ", "), raw)
+		}
+		update["serialspeed"] = speed
+	}
+	if options, ok := args["kernel_extra_options"].(string); ok {
+		update["kernel_extra_options"] = strings.TrimSpace(options)
+	}
+	if debug, ok := args["kernel_debug"].(bool); ok {
+		update["debugkern"] = debug
+	}
+
+	if len(update) == 0 {
+		return nil, newToolError(ErrorValidation, "nothing to update: provide swap_size_gib, syslog_level, console_menu, serial_console, serial_port, serial_speed, kernel_extra_options, or kernel_debug")
+	}
+	return update, nil
+}
+
+// advancedSettingsWarnings explains when changes take effect and which ones
+// can make the NAS hard to reach or boot
+func advancedSettingsWarnings(changes map[string]interface{}) []string {
+	warnings := []string{}
+	newValue := func(field string) interface{} {
+		return changes[field].(map[string]interface{})["new"]
+	}
+
+	reboot := []string{}
+	for field, arg := range rebootSettings {
+		if _, ok := changes[field]; ok {
+			reboot = append(reboot, arg)
+		}
+	}
+	if len(reboot) > 0 {
+		sort.Strings(reboot)
+		warnings = append(warnings, fmt.Sprintf("%s take effect at the next reboot", strings.Join(reboot, ", ")))
+	}
+	if _, ok := changes["kernel_extra_options"]; ok && newValue("kernel_extra_options") != "" {
+		warnings = append(warnings, "Wrong kernel options can stop the NAS from booting; have console or IPMI access ready and boot the previous boot environment to recover")
+	}
+	if _, ok := changes["serialconsole"]; ok && newValue("serialconsole") == true {
+		warnings = append(warnings, "Check that serial_port and serial_speed match the terminal or IPMI serial-over-LAN settings")
+	}
+	if _, ok := changes["consolemenu"]; ok && newValue("consolemenu") == false {
+		warnings = append(warnings, "The local console shows a login prompt instead of the setup menu, which is the usual way to fix network settings without the web UI")
+	}
+	if _, ok := changes["debugkern"]; ok && newValue("debugkern") == true {
+		warnings = append(warnings, "Kernel debugging slows the system; turn it off when troubleshooting is done")
+	}
+	if _, ok := changes["sysloglevel"]; ok {
+		switch level := strings.TrimPrefix(fmt.Sprint(newValue("sysloglevel")), "F_"); {
+		case level == "DEBUG":
+			warnings = append(warnings, "Debug logging writes much more to the system dataset; lower the level when troubleshooting is done")
+		case containsString(syslogLevels[:4], level):
+			warnings = append(warnings, fmt.Sprintf("Only %s and more severe messages are logged; warnings that give early notice of problems are dropped", level))
+		}
+	}
+	if _, ok := changes["swapondrive"]; ok {
+		warnings = append(warnings, "The swap size applies only to disks partitioned afterwards (new pools, added or replaced disks); existing swap partitions keep their size")
+		if fmt.Sprint(newValue("swapondrive")) == "0" {
+			warnings = append(warnings, "Disks added from now on get no swap partition")
+		}
+	}
+	return warnings
+}
+
+func handleGetAdvancedSettings(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(simplifyAdvancedSettings(config))
+}
+
+func handleUpdateAdvancedSettings(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return "", err
+	}
+	update, err := advancedSettingsUpdate(client, config, args)
+	if err != nil {
+		return "", err
+	}
+	changes := configChanges(config, update)
+	if len(changes) == 0 {
+		response := simplifyAdvancedSettings(config)
+		response["updated"] = false
+		response["message"] = "The advanced settings already have these values"
+		return marshalJSON(response)
+	}
+
+	result, err := client.Call("system.advanced.update", update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update advanced settings: %w", err)
+	}
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	response := simplifyAdvancedSettings(updated)
+	response["updated"] = true
+	response["changes"] = changes
+	if warnings := advancedSettingsWarnings(changes); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	return marshalJSON(response)
+}
+
+func handleUpdateAdvancedSettingsWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateAdvancedSettingsDryRun{}, handleUpdateAdvancedSettings)
+}
+
+type updateAdvancedSettingsDryRun struct{}
+
+func (u *updateAdvancedSettingsDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	update, err := advancedSettingsUpdate(client, config, args)
+	if err != nil {
+		return nil, err
+	}
+	changes := configChanges(config, update)
+
+	actions := []PlannedAction{}
+	if len(changes) > 0 {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: "Update the advanced system settings",
+			Operation:   "update",
+			Target:      "system.advanced",
+			Details:     changes,
+		})
+	}
+	return &DryRunResult{
+		Tool:           "update_advanced_settings",
+		CurrentState:   simplifyAdvancedSettings(config),
+		PlannedActions: actions,
+		Warnings:       advancedSettingsWarnings(changes),
+	}, nil
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationAdvancedSettings(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.advanced.config", map[string]interface{}{
+		"id": float64(1), "swapondrive": float64(2), "sysloglevel": "F_INFO", "syslogserver": "", "syslog_transport": "UDP",
+		"consolemenu": true, "serialconsole": false, "serialport": "ttyS0", "serialspeed": "9600",
+		"kernel_extra_options": "", "debugkern": false,
+	})
+	server.SetResult("system.advanced.serial_port_choices", map[string]interface{}{"ttyS0": "ttyS0", "ttyS1": "ttyS1"})
+	server.Handle("system.advanced.update", func(params []interface{}) (interface{}, error) {
+		updated := map[string]interface{}{"sysloglevel": "F_INFO"}
+		for k, v := range params[0].(map[string]interface{}) {
+			updated[k] = v
+		}
+		return updated, nil
+	})
+
+	result, err := registry.CallTool("get_advanced_settings", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_advanced_settings failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["syslog"].(map[string]interface{})["level"] != "INFO" || response["swap"].(map[string]interface{})["size_gib"] != float64(2) {
+		t.Errorf("get_advanced_settings = %v", response)
+	}
+
+	update := map[string]interface{}{
+		"serial_console":       true,
+		"serial_port":          "ttyS1",
+		"serial_speed":         float64(115200),
+		"kernel_extra_options": "intel_iommu=on",
+		"swap_size_gib":        float64(2),
+		"dry_run":              true,
+	}
+	result, err = registry.CallTool("update_advanced_settings", update)
+	if err != nil {
+		t.Fatalf("update_advanced_settings dry run failed: %v", err)
+	}
+	for _, want := range []string{"take effect at the next reboot", "stop the NAS from booting", "serial-over-LAN"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("dry run lacks %q: %s", want, result)
+		}
+	}
+	if strings.Contains(result, "swapondrive") || len(server.Calls("system.advanced.update")) != 0 {
+		t.Errorf("dry run lists the unchanged swap size or updated settings: %s", result)
+	}
+
+	delete(update, "dry_run")
+	update["syslog_level"] = "debug"
+	if _, err := registry.CallTool("update_advanced_settings", update); err != nil {
+		t.Fatalf("update_advanced_settings failed: %v", err)
+	}
+	payload := server.Calls("system.advanced.update")[0].Params[0].(map[string]interface{})
+	if payload["serialspeed"] != "115200" || payload["sysloglevel"] != "F_DEBUG" || payload["serialport"] != "ttyS1" || payload["swapondrive"] != float64(2) {
+		t.Errorf("system.advanced.update payload = %v", payload)
+	}
+
+	for name, args := range map[string]map[string]interface{}{
+		"serial port":  {"serial_port": "ttyUSB0"},
+		"syslog level": {"syslog_level": "LOUD"},
+		"swap size":    {"swap_size_gib": float64(1.5)},
+		"nothing":      {},
+	} {
+		if _, err := registry.CallTool("update_advanced_settings", args); err == nil || ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("invalid %s error = %v, want VALIDATION", name, err)
+		}
+	}
+
+	// Releases without the swap setting
+	server.SetResult("system.advanced.config", map[string]interface{}{"sysloglevel": "F_INFO"})
+	if _, err := registry.CallTool("update_advanced_settings", map[string]interface{}{"swap_size_gib": float64(4)}); err == nil || ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("unsupported swap error = %v, want PRECONDITION_FAILED", err)
+	}
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Middleware API introspection
+
+// apiMethodName matches middleware method and service names
+var apiMethodName = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// maxSimilarMethods bounds the sibling methods suggested for an unknown method
+const maxSimilarMethods = 20
+
+// getAPIMethods fetches the method descriptions of one service
+func getAPIMethods(client truenas.Caller, service string) (map[string]map[string]interface{}, error) {
+	result, err := client.Call("core.get_methods", service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch API methods: %w", err)
+	}
+	var methods map[string]map[string]interface{}
+	if err := json.Unmarshal(result, &methods); err != nil {
+		return nil, fmt.Errorf("failed to parse API methods: %w", err)
+	}
+	return methods, nil
+}
+
+// summarizeAPIParameter describes one accepted parameter from its JSON schema
+func summarizeAPIParameter(index int, schema map[string]interface{}) map[string]interface{} {
+	name, _ := schema["_name_"].(string)
+	if name == "" {
+		name, _ = schema["title"].(string)
+	}
+	if name == "" {
+		name = fmt.Sprintf("arg%d", index+1)
+	}
+	param := map[string]interface{}{
+		"position": index + 1,
+		"name":     name,
+		"type":     schema["type"],
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && schema["type"] == nil {
+		types := []string{}
+		for _, raw := range anyOf {
+			option, _ := raw.(map[string]interface{})
+			if t, ok := option["type"].(string); ok {
+				types = append(types, t)
+			}
+		}
+		param["type"] = strings.Join(types, "|")
+	}
+	if required, ok := schema["_required_"].(bool); ok {
+		param["required"] = required
+	}
+	for _, key := range []string{"description", "default", "enum"} {
+		if v, ok := schema[key]; ok {
+			param[key] = v
+		}
+	}
+
+	// Object parameters list their fields, marking the required ones
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		required := map[string]bool{}
+		if list, ok := schema["required"].([]interface{}); ok {
+			for _, r := range list {
+				if s, ok := r.(string); ok {
+					required[s] = true
+				}
+			}
+		}
+		names := make([]string, 0, len(properties))
+		for field := range properties {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		fields := []string{}
+		for _, field := range names {
+			prop, _ := properties[field].(map[string]interface{})
+			if r, _ := prop["_required_"].(bool); r {
+				required[field] = true
+			}
+			if required[field] {
+				field += " (required)"
+			}
+			fields = append(fields, field)
+		}
+		param["fields"] = fields
+	}
+	return param
+}
+
+func handleDescribeAPIMethod(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	method, _ := args["method"].(string)
+	method = strings.TrimSpace(method)
+	if method == "" {
+		return "", fmt.Errorf("method is required")
+	}
+	if !apiMethodName.MatchString(method) {
+		return "", newToolError(ErrorValidation, "method must be a name like pool.dataset.query (got: %q)", method)
+	}
+	fullSchema, _ := args["full_schema"].(bool)
+
+	// A service name lists its methods instead
+	service := method
+	if i := strings.LastIndex(method, "."); i > 0 {
+		service = method[:i]
+	}
+	methods, err := getAPIMethods(client, service)
+	if err != nil {
+		return "", err
+	}
+	info, found := methods[method]
+	if !found {
+		serviceMethods, err := getAPIMethods(client, method)
+		if err != nil {
+			return "", err
+		}
+		if len(serviceMethods) > 0 {
+			names := make([]string, 0, len(serviceMethods))
+			for name := range serviceMethods {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return marshalJSON(map[string]interface{}{
+				"service":      method,
+				"methods":      names,
+				"method_count": len(names),
+				"note":         "This is a service; pass one of its methods to see parameters",
+			})
+		}
+
+		siblings := []string{}
+		for name := range methods {
+			siblings = append(siblings, name)
+		}
+		sort.Strings(siblings)
+		if len(siblings) == 0 {
+			return "", newToolError(ErrorNotFound, "API method %s not found and service %s has no methods", method, service)
+		}
+		return "", newToolError(ErrorNotFound, "API method %s not found; methods of %s: %s", method, service, strings.Join(firstN(siblings, maxSimilarMethods), ", "))
+	}
+
+	response := map[string]interface{}{
+		"method":      method,
+		"description": info["description"],
+		"job":         info["job"] == true,
+	}
+	accepts, _ := info["accepts"].([]interface{})
+	params := make([]map[string]interface{}, 0, len(accepts))
+	for i, raw := range accepts {
+		schema, _ := raw.(map[string]interface{})
+		params = append(params, summarizeAPIParameter(i, schema))
+	}
+	response["parameters"] = params
+	for _, key := range []string{"roles", "downloadable", "uploadable"} {
+		if v, ok := info[key]; ok && v != nil && v != false {
+			response[key] = v
+		}
+	}
+	if examples, ok := info["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		response["examples"] = examples
+	}
+	if fullSchema {
+		response["accepts"] = info["accepts"]
+		response["returns"] = info["returns"]
+	} else {
+		response["note"] = "Parameters are positional. Pass full_schema=true for the complete accepts/returns JSON schema."
+	}
+	if info["job"] == true {
+		response["job_note"] = "Returns a job ID; poll it with core.get_jobs or track it with tasks_get when a tool starts it"
+	}
+	return marshalJSON(response)
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationDescribeAPIMethod(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.Handle("core.get_methods", func(params []interface{}) (interface{}, error) {
+		if params[0] != "sharing.smb" {
+			return map[string]interface{}{}, nil
+		}
+		return map[string]interface{}{
+			"sharing.smb.create": map[string]interface{}{
+				"description": "Create a SMB Share.",
+				"job":         false,
+				"roles":       []interface{}{"SHARING_SMB_WRITE"},
+				"accepts": []interface{}{
+					map[string]interface{}{
+						"_name_": "sharingsmb_create", "type": "object", "required": []interface{}{"path"},
+						"properties": map[string]interface{}{
+							"path": map[string]interface{}{"type": "string"},
+							"name": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"returns": []interface{}{map[string]interface{}{"type": "object"}},
+			},
+			"sharing.smb.query": map[string]interface{}{"description": "Query shares.", "accepts": []interface{}{}},
+		}, nil
+	})
+
+	result, err := registry.CallTool("describe_api_method", map[string]interface{}{"method": "sharing.smb.create"})
+	if err != nil {
+		t.Fatalf("describe_api_method failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	params, _ := response["parameters"].([]interface{})
+	if len(params) != 1 {
+		t.Fatalf("parameters = %v, want 1", response["parameters"])
+	}
+	fields := params[0].(map[string]interface{})["fields"]
+	if fmt.Sprint(fields) != "[name path (required)]" {
+		t.Errorf("fields = %v", fields)
+	}
+	if _, ok := response["returns"]; ok {
+		t.Error("returns schema included without full_schema")
+	}
+
+	result, err = registry.CallTool("describe_api_method", map[string]interface{}{"method": "sharing.smb"})
+	if err != nil {
+		t.Fatalf("describe_api_method for a service failed: %v", err)
+	}
+	if decodeResult(t, result)["method_count"] != float64(2) {
+		t.Errorf("service listing = %s", result)
+	}
+
+	_, err = registry.CallTool("describe_api_method", map[string]interface{}{"method": "sharing.smb.crate"})
+	if err == nil || ClassifyError(err).Code != ErrorNotFound || !strings.Contains(err.Error(), "sharing.smb.create") {
+		t.Errorf("unknown method error = %v, want NOT_FOUND suggesting sharing.smb.create", err)
+	}
+	if _, err := registry.CallTool("describe_api_method", map[string]interface{}{"method": "rm -rf"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("invalid name error = %v, want VALIDATION", err)
+	}
+}
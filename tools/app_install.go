@@ -0,0 +1,439 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Step-wise app installation. install_app runs as a task whose result is the
+// installation plan; when a step fails, install_app with resume_task_id
+// re-checks which steps are already done and continues from there.
+
+const (
+	// installTaskTTL is how long an install task (and its plan) can be resumed
+	installTaskTTL = 24 * time.Hour
+
+	installActionCreateDataset = "create_dataset"
+	installActionCreateApp     = "create_app"
+
+	installStepPending   = "pending"
+	installStepRunning   = "running"
+	installStepCompleted = "completed"
+	installStepFailed    = "failed"
+)
+
+// installJobPollInterval is how often the app.create job is checked
+var installJobPollInterval = 5 * time.Second
+
+// errInstallCancelled stops an install whose task was cancelled
+var errInstallCancelled = errors.New("installation cancelled")
+
+// installStep is one step of an installation plan
+type installStep struct {
+	Step   int    `json:"step"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	JobID  *int   `json:"job_id,omitempty"`
+}
+
+// installPlan is the result of an install_app task. Values are kept for
+// resume but not shown, since they are large and may hold app secrets.
+type installPlan struct {
+	AppName    string                 `json:"app_name"`
+	CatalogApp string                 `json:"catalog_app"`
+	Train      string                 `json:"train"`
+	Version    string                 `json:"version"`
+	Values     map[string]interface{} `json:"-"`
+	Attempts   int                    `json:"attempts"`
+	Steps      []installStep          `json:"steps"`
+}
+
+// clone copies the plan so a task never shares steps that are still changing
+func (p *installPlan) clone() *installPlan {
+	copied := *p
+	copied.Steps = append([]installStep(nil), p.Steps...)
+	return &copied
+}
+
+// pending counts the steps that still have to run
+func (p *installPlan) pending() int {
+	count := 0
+	for _, step := range p.Steps {
+		if step.Status != installStepCompleted {
+			count++
+		}
+	}
+	return count
+}
+
+// datasetExists reports whether a dataset exists
+func datasetExists(client truenas.Caller, name string) (bool, error) {
+	result, err := client.Call("pool.dataset.query",
+		[]interface{}{
+			[]interface{}{"name", "=", name},
+		},
+		map[string]interface{}{},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to query dataset %s: %w", name, err)
+	}
+
+	var datasets []interface{}
+	if err := json.Unmarshal(result, &datasets); err != nil {
+		return false, fmt.Errorf("failed to parse dataset query: %w", err)
+	}
+	return len(datasets) > 0, nil
+}
+
+// appExists reports whether an app instance with the given name exists
+func appExists(client truenas.Caller, name string) (bool, error) {
+	result, err := client.Call("app.query", []interface{}{
+		[]interface{}{"name", "=", name},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to query apps: %w", err)
+	}
+
+	var apps []interface{}
+	if err := json.Unmarshal(result, &apps); err != nil {
+		return false, fmt.Errorf("failed to parse app query: %w", err)
+	}
+	return len(apps) > 0, nil
+}
+
+// missingDatasetChain returns the missing datasets needed for dataset,
+// parents first (e.g. tank/apps, tank/apps/plex, tank/apps/plex/config)
+func missingDatasetChain(client truenas.Caller, dataset string) ([]string, error) {
+	parts := strings.Split(dataset, "/")
+	var missing []string
+	for i := len(parts); i > 1; i-- {
+		name := strings.Join(parts[:i], "/")
+		exists, err := datasetExists(client, name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			break
+		}
+		missing = append([]string{name}, missing...)
+	}
+	return missing, nil
+}
+
+// buildInstallPlan validates install_app arguments and plans the steps. Missing
+// storage datasets are planned for creation when create_datasets is set.
+func buildInstallPlan(client truenas.Caller, args map[string]interface{}) (*installPlan, error) {
+	appName, ok := args["app_name"].(string)
+	if !ok || appName == "" {
+		return nil, fmt.Errorf("app_name is required")
+	}
+
+	catalogApp, ok := args["catalog_app"].(string)
+	if !ok || catalogApp == "" {
+		return nil, fmt.Errorf("catalog_app is required")
+	}
+
+	plan := &installPlan{AppName: appName, CatalogApp: catalogApp, Train: "stable", Version: "latest"}
+	if t, ok := args["train"].(string); ok && t != "" {
+		plan.Train = t
+	}
+	if v, ok := args["version"].(string); ok && v != "" {
+		plan.Version = v
+	}
+
+	// Validate app name
+	if err := validateAppName(appName); err != nil {
+		return nil, fmt.Errorf("invalid app_name: %v", err)
+	}
+
+	// Extract values parameter (required)
+	values, ok := args["values"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("values parameter is required. Use get_app_catalog_details to see the schema and build the configuration")
+	}
+
+	// CRITICAL SECURITY: Enforce host-path-only storage
+	if err := enforceHostPathStorage(values); err != nil {
+		return nil, fmt.Errorf("storage validation failed: %v", err)
+	}
+	plan.Values = values
+
+	// Verify datasets exist
+	storagePaths := extractStoragePathsFromValues(values)
+	var missing []string
+	if len(storagePaths) > 0 {
+		var err error
+		missing, err = verifyDatasetPathsExist(client, storagePaths)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify datasets: %v", err)
+		}
+	}
+
+	createDatasets, _ := args["create_datasets"].(bool)
+	if len(missing) > 0 && !createDatasets {
+		return nil, fmt.Errorf("datasets must exist before installation. Missing:\n  - %s\n\nUse create_dataset tool first, or set create_datasets to have install_app create them.",
+			strings.Join(missing, "\n  - "))
+	}
+
+	sort.Strings(missing)
+	planned := map[string]bool{}
+	for _, dataset := range missing {
+		chain, err := missingDatasetChain(client, dataset)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range chain {
+			if planned[name] {
+				continue
+			}
+			planned[name] = true
+			plan.Steps = append(plan.Steps, installStep{Action: installActionCreateDataset, Target: name, Status: installStepPending})
+		}
+	}
+	plan.Steps = append(plan.Steps, installStep{Action: installActionCreateApp, Target: appName, Status: installStepPending})
+	for i := range plan.Steps {
+		plan.Steps[i].Step = i + 1
+	}
+
+	return plan, nil
+}
+
+// handleInstallApp installs an app from the catalog, or resumes a failed
+// installation when resume_task_id is given
+func (r *Registry) handleInstallApp(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.taskManager == nil {
+		return "", fmt.Errorf("task tracking is not available")
+	}
+	if taskID, ok := args["resume_task_id"].(string); ok && taskID != "" {
+		return r.handleResumeInstall(client, taskID, args)
+	}
+
+	plan, err := buildInstallPlan(client, args)
+	if err != nil {
+		return "", err
+	}
+	plan.Attempts = 1
+
+	task, err := r.taskManager.CreateLocalTask("install_app", args, installTaskTTL, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+	planned := plan.clone()
+	r.taskManager.UpdateTask(task.TaskID, tasks.TaskStatusWorking, "Installation planned", planned)
+
+	// The install outlives this call, so it runs under the task's context
+	// rather than the call's deadline
+	taskCtx := r.taskManager.Context(task.TaskID)
+	go r.runInstall(taskCtx, task.TaskID, client.WithContext(taskCtx), plan)
+
+	return marshalJSON(map[string]interface{}{
+		"app_name":      plan.AppName,
+		"catalog_app":   plan.CatalogApp,
+		"train":         plan.Train,
+		"version":       plan.Version,
+		"task_id":       task.TaskID,
+		"task_status":   task.Status,
+		"poll_interval": task.PollInterval,
+		"steps":         planned.Steps,
+		"message":       fmt.Sprintf("Installation started (%d steps). Track progress with tasks_get using task_id: %s. If a step fails, fix the cause and call install_app with resume_task_id to continue.", len(plan.Steps), task.TaskID),
+	})
+}
+
+// resumableInstall returns the plan of a failed install task with each
+// step's status re-detected from the system. New values, if given, replace
+// the app configuration.
+func (r *Registry) resumableInstall(client truenas.Caller, taskID string, args map[string]interface{}) (*installPlan, error) {
+	if r.taskManager == nil {
+		return nil, fmt.Errorf("task tracking is not available")
+	}
+	task, err := r.taskManager.Get(taskID)
+	if err != nil {
+		return nil, newToolError(ErrorNotFound, "install task %s not found; tasks can be resumed for %s after they start", taskID, installTaskTTL)
+	}
+	if task.ToolName != "install_app" {
+		return nil, newToolError(ErrorValidation, "task %s is a %s task, not an app installation", taskID, task.ToolName)
+	}
+	if r.taskManager.IsActive(taskID) {
+		return nil, newToolError(ErrorInProgress, "install task %s is still running; check tasks_get", taskID)
+	}
+	if task.Status == tasks.TaskStatusCompleted {
+		return nil, newToolError(ErrorValidation, "install task %s already completed", taskID)
+	}
+	previous, ok := task.Result.(*installPlan)
+	if !ok {
+		return nil, fmt.Errorf("install task %s has no installation plan", taskID)
+	}
+
+	plan := previous.clone()
+	if values, ok := args["values"].(map[string]interface{}); ok {
+		if err := enforceHostPathStorage(values); err != nil {
+			return nil, fmt.Errorf("storage validation failed: %v", err)
+		}
+		plan.Values = values
+	}
+
+	// Steps are checked against the system rather than trusted from the
+	// plan: a failed step may have partly succeeded, or a created dataset
+	// may have been removed since
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		var done bool
+		switch step.Action {
+		case installActionCreateDataset:
+			done, err = datasetExists(client, step.Target)
+		case installActionCreateApp:
+			done, err = appExists(client, step.Target)
+		}
+		if err != nil {
+			return nil, err
+		}
+		step.JobID = nil
+		if done {
+			step.Status = installStepCompleted
+			step.Detail = "already present"
+		} else {
+			step.Status = installStepPending
+			step.Detail = ""
+		}
+	}
+	return plan, nil
+}
+
+func (r *Registry) handleResumeInstall(client truenas.Caller, taskID string, args map[string]interface{}) (string, error) {
+	plan, err := r.resumableInstall(client, taskID, args)
+	if err != nil {
+		return "", err
+	}
+	plan.Attempts++
+
+	remaining := plan.pending()
+	message := fmt.Sprintf("Resuming installation (attempt %d): %d of %d steps already done", plan.Attempts, len(plan.Steps)-remaining, len(plan.Steps))
+	planned := plan.clone()
+	r.taskManager.UpdateTask(taskID, tasks.TaskStatusWorking, message, planned)
+
+	taskCtx := r.taskManager.Context(taskID)
+	go r.runInstall(taskCtx, taskID, client.WithContext(taskCtx), plan)
+
+	return marshalJSON(map[string]interface{}{
+		"app_name": planned.AppName,
+		"task_id":  taskID,
+		"resumed":  true,
+		"attempt":  planned.Attempts,
+		"steps":    planned.Steps,
+		"message":  fmt.Sprintf("%s. Track progress with tasks_get using task_id: %s", message, taskID),
+	})
+}
+
+// runInstall executes the plan's pending steps in order, recording progress
+// on the task
+func (r *Registry) runInstall(ctx context.Context, taskID string, client truenas.Caller, plan *installPlan) {
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		if step.Status == installStepCompleted {
+			continue
+		}
+		if !r.taskManager.IsActive(taskID) {
+			return
+		}
+
+		step.Status = installStepRunning
+		r.taskManager.UpdateTask(taskID, tasks.TaskStatusWorking,
+			fmt.Sprintf("Step %d of %d: %s %s", step.Step, len(plan.Steps), step.Action, step.Target), plan.clone())
+
+		if err := r.runInstallStep(ctx, taskID, client, plan, step); err != nil {
+			if errors.Is(err, errInstallCancelled) {
+				return
+			}
+			step.Status = installStepFailed
+			step.Detail = err.Error()
+			message := fmt.Sprintf("Step %d (%s %s) failed: %v. Fix the cause and call install_app with resume_task_id %s to continue from this step.",
+				step.Step, step.Action, step.Target, err, taskID)
+			if err := r.taskManager.UpdateTask(taskID, tasks.TaskStatusFailed, message, plan.clone()); err != nil {
+				slog.Error("Failed to record install failure", "task_id", taskID, "error", err)
+			}
+			return
+		}
+		step.Status = installStepCompleted
+	}
+
+	if err := r.taskManager.UpdateTask(taskID, tasks.TaskStatusCompleted,
+		fmt.Sprintf("App %s installed", plan.AppName), plan.clone()); err != nil {
+		slog.Error("Failed to record install result", "task_id", taskID, "error", err)
+	}
+}
+
+func (r *Registry) runInstallStep(ctx context.Context, taskID string, client truenas.Caller, plan *installPlan, step *installStep) error {
+	switch step.Action {
+	case installActionCreateDataset:
+		if _, err := client.Call("pool.dataset.create", map[string]interface{}{
+			"name":       step.Target,
+			"type":       "FILESYSTEM",
+			"share_type": "APPS",
+		}); err != nil {
+			return fmt.Errorf("failed to create dataset: %w", err)
+		}
+		return nil
+
+	case installActionCreateApp:
+		result, err := client.Call("app.create", map[string]interface{}{
+			"app_name":    plan.AppName,
+			"catalog_app": plan.CatalogApp,
+			"train":       plan.Train,
+			"version":     plan.Version,
+			"values":      plan.Values,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to install app: %v", err)
+		}
+		jobID, err := parseJobID(result)
+		if err != nil {
+			return err
+		}
+		step.JobID = &jobID
+		r.taskManager.UpdateTask(taskID, tasks.TaskStatusWorking,
+			fmt.Sprintf("Step %d of %d: waiting for app.create job %d", step.Step, len(plan.Steps), jobID), plan.clone())
+		return r.waitInstallJob(ctx, taskID, client, jobID)
+	}
+	return fmt.Errorf("unknown install step: %s", step.Action)
+}
+
+// waitInstallJob waits for the app.create job. Cancelling the task aborts
+// the job.
+func (r *Registry) waitInstallJob(ctx context.Context, taskID string, client truenas.Caller, jobID int) error {
+	_, err := waitForJob(ctx, client, jobID, installJobPollInterval)
+	if ctx.Err() != nil && !r.taskManager.IsActive(taskID) {
+		client.WithContext(context.Background()).Call("core.job_abort", jobID)
+		return errInstallCancelled
+	}
+	if err != nil {
+		return fmt.Errorf("app.create %w", err)
+	}
+	return nil
+}
+
+// parseJobID reads a job ID returned as a number or a one-element array
+func parseJobID(result json.RawMessage) (int, error) {
+	var jobID int
+	if err := json.Unmarshal(result, &jobID); err != nil {
+		var jobIDArray []int
+		if err2 := json.Unmarshal(result, &jobIDArray); err2 != nil {
+			return 0, fmt.Errorf("failed to parse job ID as int or array: int error: %v, array error: %v", err, err2)
+		}
+		if len(jobIDArray) == 0 {
+			return 0, fmt.Errorf("app.create returned empty job ID array")
+		}
+		jobID = jobIDArray[0]
+	}
+	return jobID, nil
+}
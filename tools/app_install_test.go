@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationInstallAppResume(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	defer func(interval time.Duration) { installJobPollInterval = interval }(installJobPollInterval)
+	installJobPollInterval = 10 * time.Millisecond
+
+	var datasets []map[string]interface{}
+	setDatasets := func(names ...string) {
+		for _, name := range names {
+			datasets = append(datasets, map[string]interface{}{"id": name, "name": name})
+		}
+		server.SetRecords("pool.dataset.query", datasets)
+	}
+	setDatasets("tank")
+	server.Handle("pool.dataset.create", func(params []interface{}) (interface{}, error) {
+		name := params[0].(map[string]interface{})["name"].(string)
+		setDatasets(name)
+		return map[string]interface{}{"id": name}, nil
+	})
+	server.SetRecords("app.query", nil)
+	creates := 0
+	server.Handle("app.create", func(params []interface{}) (interface{}, error) {
+		creates++
+		if creates == 1 {
+			return server.AddJob("app.create", params, truenastest.JobSpec{Error: "port 8096 is already in use"}), nil
+		}
+		server.SetRecords("app.query", []map[string]interface{}{{"name": "jellyfin"}})
+		return server.AddJob("app.create", params, truenastest.JobSpec{Steps: 1}), nil
+	})
+
+	args := map[string]interface{}{
+		"app_name":        "jellyfin",
+		"catalog_app":     "jellyfin",
+		"create_datasets": true,
+		"values": map[string]interface{}{
+			"storage": map[string]interface{}{
+				"config": map[string]interface{}{
+					"type":             "host_path",
+					"host_path_config": map[string]interface{}{"path": "/mnt/tank/apps/jellyfin/config"},
+				},
+			},
+		},
+	}
+	result, err := registry.CallTool("install_app", args)
+	if err != nil {
+		t.Fatalf("install_app failed: %v", err)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+
+	waitTask := func() map[string]interface{} {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			result, err := registry.CallTool("tasks_get", map[string]interface{}{"task_id": taskID})
+			if err != nil {
+				t.Fatalf("tasks_get failed: %v", err)
+			}
+			task := decodeResult(t, result)
+			if task["status"] != string(tasks.TaskStatusWorking) {
+				return task
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("install did not finish:\n%s", result)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// Parent datasets are created, then app.create fails
+	task := waitTask()
+	if task["status"] != string(tasks.TaskStatusFailed) || !strings.Contains(fmt.Sprint(task["statusMessage"]), "resume_task_id") {
+		t.Fatalf("first attempt = %v, want failed with a resume hint", task)
+	}
+	if calls := server.Calls("pool.dataset.create"); len(calls) != 3 {
+		t.Fatalf("pool.dataset.create called %d times, want 3 (apps, jellyfin, config)", len(calls))
+	}
+
+	preview, err := registry.CallTool("install_app", map[string]interface{}{"resume_task_id": taskID, "dry_run": true})
+	if err != nil {
+		t.Fatalf("resume dry run failed: %v", err)
+	}
+	if actions := decodeResult(t, preview)["planned_actions"].([]interface{}); len(actions) != 1 {
+		t.Errorf("resume dry run plans %d actions, want only app.create:\n%s", len(actions), preview)
+	}
+
+	// Resuming skips the datasets and retries app.create
+	if _, err := registry.CallTool("install_app", map[string]interface{}{"resume_task_id": taskID}); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	task = waitTask()
+	if task["status"] != string(tasks.TaskStatusCompleted) {
+		t.Fatalf("resumed install = %v, want completed", task)
+	}
+	if calls := server.Calls("pool.dataset.create"); len(calls) != 3 {
+		t.Errorf("resume recreated datasets: %d pool.dataset.create calls", len(calls))
+	}
+	if plan := task["result"].(map[string]interface{}); plan["attempts"] != float64(2) {
+		t.Errorf("attempts = %v, want 2", plan["attempts"])
+	}
+
+	if _, err := registry.CallTool("install_app", map[string]interface{}{"resume_task_id": taskID}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("resuming a completed install error = %v, want VALIDATION", err)
+	}
+}
+
+func TestIntegrationInstallAppCancel(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	// Cancelling must not wait for the next poll
+	defer func(interval time.Duration) { installJobPollInterval = interval }(installJobPollInterval)
+	installJobPollInterval = time.Hour
+
+	server.SetRecords("app.query", nil)
+	server.HandleJob("app.create", truenastest.JobSpec{Steps: 1000})
+
+	result, err := registry.CallTool("install_app", map[string]interface{}{"app_name": "jellyfin", "catalog_app": "jellyfin", "values": map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("install_app failed: %v", err)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Calls("app.create")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("app.create was never called")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := registry.taskManager.Cancel(taskID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	for len(server.Calls("core.job_abort")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("cancelling the task did not abort the app.create job")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if task, _ := registry.taskManager.Get(taskID); task.Status != tasks.TaskStatusCancelled {
+		t.Errorf("task status = %s, want cancelled", task.Status)
+	}
+}
@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// App rollback and redeploy
+
+// getApp returns the installed app with the given name
+func getApp(client truenas.Caller, name string) (map[string]interface{}, error) {
+	apps, err := inventoryQuery(client, "app.query", []interface{}{[]interface{}{"name", "=", name}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query apps: %w", err)
+	}
+	if len(apps) == 0 {
+		return nil, newToolError(ErrorNotFound, "app %s not found", name)
+	}
+	return apps[0], nil
+}
+
+// compareAppVersions orders dotted version strings numerically where
+// possible, e.g. 1.10.0 after 1.9.2
+func compareAppVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// appRollback is a validated rollback_app request
+type appRollback struct {
+	app      map[string]interface{}
+	name     string
+	version  string
+	versions []string
+	snapshot bool
+}
+
+func planAppRollback(client truenas.Caller, args map[string]interface{}) (*appRollback, error) {
+	name, _ := args["app_name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("app_name is required")
+	}
+	app, err := getApp(client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.Call("app.rollback_versions", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollback versions: %w", err)
+	}
+	var versions []string
+	if err := json.Unmarshal(result, &versions); err != nil {
+		return nil, fmt.Errorf("failed to parse rollback versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, newToolError(ErrorPrecondition, "app %s has no earlier version to roll back to; only versions it was upgraded from are kept", name)
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareAppVersions(versions[i], versions[j]) > 0 })
+
+	plan := &appRollback{app: app, name: name, versions: versions, snapshot: true}
+	if s, ok := args["rollback_snapshot"].(bool); ok {
+		plan.snapshot = s
+	}
+	plan.version, _ = args["version"].(string)
+	if plan.version == "" {
+		plan.version = versions[0]
+	} else if !containsString(versions, plan.version) {
+		return nil, newToolError(ErrorValidation, "app %s cannot roll back to %s; available versions: %s", name, plan.version, strings.Join(versions, ", "))
+	}
+	return plan, nil
+}
+
+func (r *Registry) handleRollbackApp(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planAppRollback(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("app.rollback", plan.name, map[string]interface{}{
+		"app_version":       plan.version,
+		"rollback_snapshot": plan.snapshot,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to roll back app: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+	task, err := r.taskManager.CreateJobTask("rollback_app", args, jobID, 1*time.Hour, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"app_name":          plan.name,
+		"from_version":      plan.app["version"],
+		"to_version":        plan.version,
+		"rollback_snapshot": plan.snapshot,
+		"task_id":           task.TaskID,
+		"task_status":       task.Status,
+		"poll_interval":     task.PollInterval,
+		"job_id":            jobID,
+		"message":           fmt.Sprintf("Rollback started. Track progress with tasks_get using task_id: %s", task.TaskID),
+	})
+}
+
+func (r *Registry) handleRedeployApp(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	name, _ := args["app_name"].(string)
+	if name == "" {
+		return "", fmt.Errorf("app_name is required")
+	}
+	app, err := getApp(client, name)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("app.redeploy", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to redeploy app: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+	task, err := r.taskManager.CreateJobTask("redeploy_app", args, jobID, 10*time.Minute, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"app_name":       name,
+		"previous_state": app["state"],
+		"task_id":        task.TaskID,
+		"task_status":    task.Status,
+		"poll_interval":  task.PollInterval,
+		"job_id":         jobID,
+		"message":        fmt.Sprintf("Redeploy started. Track progress with tasks_get using task_id: %s", task.TaskID),
+	})
+}
+
+func (r *Registry) handleRollbackAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &rollbackAppDryRun{}, r.handleRollbackApp)
+}
+
+func (r *Registry) handleRedeployAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &redeployAppDryRun{}, r.handleRedeployApp)
+}
+
+type rollbackAppDryRun struct{}
+
+func (d *rollbackAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planAppRollback(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{
+		{
+			Step:        1,
+			Description: "Stop application containers",
+			Operation:   "stop",
+			Target:      plan.name,
+		},
+		{
+			Step:        2,
+			Description: fmt.Sprintf("Roll back from %v to %s", plan.app["version"], plan.version),
+			Operation:   "rollback",
+			Target:      "app.rollback",
+			Details:     map[string]interface{}{"app_version": plan.version, "rollback_snapshot": plan.snapshot},
+		},
+	}
+	warnings := []string{}
+	if plan.snapshot {
+		actions = append(actions, PlannedAction{
+			Step:        3,
+			Description: "Restore the snapshot of the app's data taken when it was upgraded",
+			Operation:   "restore",
+			Target:      plan.name,
+		})
+		warnings = append(warnings, "Restoring the snapshot discards changes to the app's data made since the upgrade")
+	} else {
+		warnings = append(warnings, "App data is kept as is; data already migrated by the newer version may not work with the older one")
+	}
+	actions = append(actions, PlannedAction{
+		Step:        len(actions) + 1,
+		Description: fmt.Sprintf("Start application at version %s", plan.version),
+		Operation:   "start",
+		Target:      plan.name,
+	})
+
+	return &DryRunResult{
+		Tool: "rollback_app",
+		CurrentState: map[string]interface{}{
+			"name":               plan.name,
+			"version":            plan.app["version"],
+			"human_version":      plan.app["human_version"],
+			"state":              plan.app["state"],
+			"available_versions": plan.versions,
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+		EstimatedTime:  &EstimatedTime{MinSeconds: 30, MaxSeconds: 300, Note: "Time varies based on image size and network speed"},
+	}, nil
+}
+
+type redeployAppDryRun struct{}
+
+func (d *redeployAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	name, _ := args["app_name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("app_name is required")
+	}
+	app, err := getApp(client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []string{fmt.Sprintf("App '%s' is unavailable while its containers are re-created", name)}
+	if app["state"] == "STOPPED" {
+		warnings = append(warnings, "The app is stopped; redeploying starts it")
+	}
+	return &DryRunResult{
+		Tool: "redeploy_app",
+		CurrentState: map[string]interface{}{
+			"name":    name,
+			"version": app["human_version"],
+			"state":   app["state"],
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: "Re-create the application containers from the current configuration, keeping app data",
+				Operation:   "redeploy",
+				Target:      "app.redeploy",
+				Details:     map[string]interface{}{"app_name": name},
+			},
+		},
+		Warnings:      warnings,
+		EstimatedTime: &EstimatedTime{MinSeconds: 10, MaxSeconds: 180, Note: "Depends on app startup time"},
+	}, nil
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationAppRollbackRedeploy(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("app.query", []map[string]interface{}{
+		{"name": "plex", "state": "RUNNING", "version": "1.11.0", "human_version": "1.41.0_1.11.0"},
+		{"name": "nginx", "state": "CRASHED", "version": "1.2.0", "human_version": "1.27_1.2.0"},
+		{"name": "fresh", "state": "RUNNING", "version": "1.0.0"},
+	})
+	server.Handle("app.rollback_versions", func(params []interface{}) (interface{}, error) {
+		if params[0] == "plex" {
+			return []string{"1.9.2", "1.10.0"}, nil
+		}
+		return []string{}, nil
+	})
+
+	result, err := registry.CallTool("rollback_app", map[string]interface{}{"app_name": "plex", "dry_run": true})
+	if err != nil {
+		t.Fatalf("rollback_app dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Roll back from 1.11.0 to 1.10.0") || !strings.Contains(result, "discards changes") {
+		t.Errorf("dry run should default to 1.10.0 with a snapshot warning:\n%s", result)
+	}
+	if _, err := registry.CallTool("rollback_app", map[string]interface{}{"app_name": "plex", "version": "1.0.0"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("unknown version error = %v, want VALIDATION", err)
+	}
+	if _, err := registry.CallTool("rollback_app", map[string]interface{}{"app_name": "fresh"}); err == nil || ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("no earlier versions error = %v, want PRECONDITION", err)
+	}
+
+	server.HandleJob("app.rollback", truenastest.JobSpec{})
+	result, err = registry.CallTool("rollback_app", map[string]interface{}{"app_name": "plex", "version": "1.9.2", "rollback_snapshot": false})
+	if err != nil {
+		t.Fatalf("rollback_app failed: %v", err)
+	}
+	if decodeResult(t, result)["task_id"] == nil {
+		t.Errorf("rollback_app should track its job:\n%s", result)
+	}
+	calls := server.Calls("app.rollback")
+	if len(calls) != 1 || calls[0].Params[0] != "plex" || fmt.Sprint(calls[0].Params[1]) != "map[app_version:1.9.2 rollback_snapshot:false]" {
+		t.Errorf("unexpected app.rollback calls: %v", calls)
+	}
+
+	server.HandleJob("app.redeploy", truenastest.JobSpec{})
+	result, err = registry.CallTool("redeploy_app", map[string]interface{}{"app_name": "nginx"})
+	if err != nil {
+		t.Fatalf("redeploy_app failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["task_id"] == nil || response["previous_state"] != "CRASHED" {
+		t.Errorf("redeploy_app result:\n%s", result)
+	}
+	if _, err := registry.CallTool("redeploy_app", map[string]interface{}{"app_name": "ghost"}); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown app error = %v, want NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,427 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// App installation templates. A template is a catalog app with a values
+// skeleton in which {{name}} placeholders are filled from its parameters;
+// install_from_template renders it and hands it to the install_app pipeline.
+// Values the template leaves out get the catalog defaults from app.create.
+
+// Template parameter types
+const (
+	appTemplateString  = "string"
+	appTemplateInteger = "integer"
+	appTemplatePath    = "path"   // Existing directory under /mnt/
+	appTemplateSecret  = "secret" // Random when not given
+)
+
+// appTemplateParam is a value a template needs from the caller
+type appTemplateParam struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// appTemplate is a parameterized install_app configuration
+type appTemplate struct {
+	Description string
+	CatalogApp  string
+	Train       string
+	Params      []appTemplateParam
+	Values      map[string]interface{}
+	Notes       []string
+}
+
+// appTemplateHostPath is a host path volume under the app's own datasets,
+// which install_from_template creates
+func appTemplateHostPath(volume string, autoPermissions bool) map[string]interface{} {
+	config := map[string]interface{}{
+		"path":       "/mnt/{{pool}}/apps/{{app_name}}/" + volume,
+		"acl_enable": false,
+	}
+	if autoPermissions {
+		config["auto_permissions"] = true
+	}
+	return map[string]interface{}{"type": "host_path", "host_path_config": config}
+}
+
+// appTemplateWebPort is the published web UI port
+func appTemplateWebPort() map[string]interface{} {
+	return map[string]interface{}{
+		"bind_mode":   "published",
+		"port_number": "{{web_port}}",
+		"host_ips":    []interface{}{},
+	}
+}
+
+var appTemplateTimezone = appTemplateParam{Name: "timezone", Type: appTemplateString, Description: "Timezone (e.g., Europe/Berlin)", Default: "Etc/UTC"}
+
+var appTemplates = map[string]appTemplate{
+	"jellyfin": {
+		Description: "Jellyfin media server with a read-only media library mount",
+		CatalogApp:  "jellyfin",
+		Train:       "community",
+		Params: []appTemplateParam{
+			{Name: "media_path", Type: appTemplatePath, Description: "Existing media directory, mounted read-only at /media (e.g., /mnt/tank/media)", Required: true},
+			{Name: "web_port", Type: appTemplateInteger, Description: "Web UI port", Default: 30013},
+			appTemplateTimezone,
+		},
+		Values: map[string]interface{}{
+			"TZ":       "{{timezone}}",
+			"jellyfin": map[string]interface{}{"additional_envs": []interface{}{}},
+			"run_as":   map[string]interface{}{"user": 568, "group": 568},
+			"network": map[string]interface{}{
+				"web_port":     appTemplateWebPort(),
+				"host_network": false,
+			},
+			"storage": map[string]interface{}{
+				"config":     appTemplateHostPath("config", false),
+				"cache":      appTemplateHostPath("cache", false),
+				"transcodes": map[string]interface{}{"type": "temporary"},
+				"additional_storage": []interface{}{
+					map[string]interface{}{
+						"type":             "host_path",
+						"mount_path":       "/media",
+						"read_only":        true,
+						"host_path_config": map[string]interface{}{"path": "{{media_path}}", "acl_enable": false},
+					},
+				},
+			},
+			"labels":    []interface{}{},
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpus": 2, "memory": 4096}},
+		},
+		Notes: []string{
+			"The apps user (568) needs read access to the media directory",
+			"Add libraries in the Jellyfin setup wizard using the /media folder",
+		},
+	},
+	"nextcloud": {
+		Description: "Nextcloud with its bundled PostgreSQL and Redis; the database and Redis passwords are generated",
+		CatalogApp:  "nextcloud",
+		Train:       "community",
+		Params: []appTemplateParam{
+			{Name: "admin_user", Type: appTemplateString, Description: "Initial admin user name", Default: "admin"},
+			{Name: "admin_password", Type: appTemplateSecret, Description: "Initial admin password", Required: true},
+			{Name: "host", Type: appTemplateString, Description: "Host name or IP clients use, added to trusted domains (e.g., cloud.example.com)", Required: true},
+			{Name: "web_port", Type: appTemplateInteger, Description: "Web UI port", Default: 30027},
+			{Name: "db_password", Type: appTemplateSecret, Description: "PostgreSQL password (generated when omitted)"},
+			{Name: "redis_password", Type: appTemplateSecret, Description: "Redis password (generated when omitted)"},
+			appTemplateTimezone,
+		},
+		Values: map[string]interface{}{
+			"TZ": "{{timezone}}",
+			"nextcloud": map[string]interface{}{
+				"admin_user":      "{{admin_user}}",
+				"admin_password":  "{{admin_password}}",
+				"host":            "{{host}}",
+				"db_password":     "{{db_password}}",
+				"redis_password":  "{{redis_password}}",
+				"data_dir_path":   "/var/www/html/data",
+				"additional_envs": []interface{}{},
+			},
+			"network": map[string]interface{}{
+				"web_port": appTemplateWebPort(),
+			},
+			"storage": map[string]interface{}{
+				"html":               appTemplateHostPath("html", false),
+				"data":               appTemplateHostPath("data", false),
+				"postgres_data":      appTemplateHostPath("postgres_data", true),
+				"additional_storage": []interface{}{},
+			},
+			"labels":    []interface{}{},
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpus": 2, "memory": 4096}},
+		},
+		Notes: []string{
+			"Put Nextcloud behind a reverse proxy with TLS before exposing it to the internet",
+			"Back up the postgres_data dataset together with data; restoring one without the other loses file metadata",
+		},
+	},
+	"home-assistant": {
+		Description: "Home Assistant with its bundled PostgreSQL recorder database; the database password is generated",
+		CatalogApp:  "home-assistant",
+		Train:       "community",
+		Params: []appTemplateParam{
+			{Name: "web_port", Type: appTemplateInteger, Description: "Web UI port", Default: 30103},
+			{Name: "db_password", Type: appTemplateSecret, Description: "PostgreSQL password (generated when omitted)"},
+			appTemplateTimezone,
+		},
+		Values: map[string]interface{}{
+			"TZ": "{{timezone}}",
+			"home_assistant": map[string]interface{}{
+				"db_password":     "{{db_password}}",
+				"additional_envs": []interface{}{},
+			},
+			"network": map[string]interface{}{
+				"web_port":     appTemplateWebPort(),
+				"host_network": false,
+			},
+			"storage": map[string]interface{}{
+				"config":             appTemplateHostPath("config", false),
+				"media":              appTemplateHostPath("media", false),
+				"postgres_data":      appTemplateHostPath("postgres_data", true),
+				"additional_storage": []interface{}{},
+			},
+			"labels":    []interface{}{},
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpus": 2, "memory": 4096}},
+		},
+		Notes: []string{
+			"Device discovery (mDNS, DHCP) needs host networking; enable it in the app's network settings if integrations are not found",
+		},
+	},
+}
+
+// appTemplateNames returns the template names in sorted order
+func appTemplateNames() []string {
+	names := make([]string, 0, len(appTemplates))
+	for name := range appTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// generateAppSecret returns a random 32-character hex secret
+func generateAppSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// resolveAppTemplateParams validates the caller's parameters against the
+// template and fills defaults and generated secrets. The second result names
+// the generated secrets.
+func resolveAppTemplateParams(client truenas.Caller, tmpl appTemplate, given map[string]interface{}) (map[string]interface{}, []string, error) {
+	known := map[string]bool{}
+	for _, param := range tmpl.Params {
+		known[param.Name] = true
+	}
+	for name := range given {
+		if !known[name] {
+			return nil, nil, newToolError(ErrorValidation, "unknown template parameter %q (see list_app_templates)", name)
+		}
+	}
+
+	resolved := map[string]interface{}{}
+	generated := []string{}
+	for _, param := range tmpl.Params {
+		value, ok := given[param.Name]
+		if !ok || value == nil || value == "" {
+			switch {
+			case param.Required:
+				return nil, nil, newToolError(ErrorValidation, "template parameter %s is required: %s", param.Name, param.Description)
+			case param.Type == appTemplateSecret:
+				secret, err := generateAppSecret()
+				if err != nil {
+					return nil, nil, err
+				}
+				resolved[param.Name] = secret
+				generated = append(generated, param.Name)
+			default:
+				resolved[param.Name] = param.Default
+			}
+			continue
+		}
+
+		switch param.Type {
+		case appTemplateInteger:
+			n, ok := value.(float64)
+			if !ok || n != float64(int(n)) {
+				return nil, nil, newToolError(ErrorValidation, "template parameter %s must be an integer", param.Name)
+			}
+			if param.Name == "web_port" && (n < 1 || n > 65535) {
+				return nil, nil, newToolError(ErrorValidation, "web_port must be between 1 and 65535")
+			}
+			resolved[param.Name] = int(n)
+		case appTemplatePath:
+			path, ok := value.(string)
+			if !ok {
+				return nil, nil, newToolError(ErrorValidation, "template parameter %s must be a path", param.Name)
+			}
+			path = strings.TrimSuffix(path, "/")
+			if _, _, err := parseStoragePath(path); err != nil {
+				return nil, nil, newToolError(ErrorValidation, "template parameter %s: %v", param.Name, err)
+			}
+			// Only the app's own datasets are created; data it mounts must exist
+			missing, err := verifyDatasetPathsExist(client, []string{path})
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(missing) > 0 {
+				return nil, nil, newToolError(ErrorNotFound, "template parameter %s: dataset %s does not exist", param.Name, missing[0])
+			}
+			resolved[param.Name] = path
+		default:
+			s, ok := value.(string)
+			if !ok {
+				return nil, nil, newToolError(ErrorValidation, "template parameter %s must be a string", param.Name)
+			}
+			resolved[param.Name] = s
+		}
+	}
+	return resolved, generated, nil
+}
+
+// renderAppTemplate returns a copy of v with {{name}} placeholders replaced.
+// A string that is exactly one placeholder takes the parameter's type.
+func renderAppTemplate(v interface{}, params map[string]interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			rendered[k] = renderAppTemplate(item, params)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(value))
+		for i, item := range value {
+			rendered[i] = renderAppTemplate(item, params)
+		}
+		return rendered
+	case string:
+		if strings.HasPrefix(value, "{{") && strings.HasSuffix(value, "}}") && strings.Count(value, "{{") == 1 {
+			if param, ok := params[strings.TrimSuffix(strings.TrimPrefix(value, "{{"), "}}")]; ok {
+				return param
+			}
+		}
+		for name, param := range params {
+			value = strings.ReplaceAll(value, "{{"+name+"}}", fmt.Sprint(param))
+		}
+		return value
+	}
+	return v
+}
+
+func handleListAppTemplates(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	templates := []map[string]interface{}{}
+	for _, name := range appTemplateNames() {
+		tmpl := appTemplates[name]
+		templates = append(templates, map[string]interface{}{
+			"template":    name,
+			"description": tmpl.Description,
+			"catalog_app": tmpl.CatalogApp,
+			"train":       tmpl.Train,
+			"parameters":  tmpl.Params,
+			"storage":     fmt.Sprintf("Datasets under <pool>/apps/<app_name> (default app_name: %s), created during install", name),
+			"notes":       tmpl.Notes,
+		})
+	}
+	return marshalJSON(map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// appTemplateInstallArgs renders a template into install_app arguments
+func appTemplateInstallArgs(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	name, _ := args["template"].(string)
+	tmpl, ok := appTemplates[name]
+	if !ok {
+		return nil, nil, newToolError(ErrorValidation, "unknown template %q (valid: %s)", name, strings.Join(appTemplateNames(), ", "))
+	}
+
+	pool, _ := args["pool"].(string)
+	if pool == "" {
+		return nil, nil, fmt.Errorf("pool is required")
+	}
+	if strings.Contains(pool, "/") {
+		return nil, nil, newToolError(ErrorValidation, "pool must be a pool name, not a dataset (got: %s)", pool)
+	}
+	exists, err := datasetExists(client, pool)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, newToolError(ErrorNotFound, "pool %s not found", pool)
+	}
+
+	appName, _ := args["app_name"].(string)
+	if appName == "" {
+		appName = name
+	}
+	if err := validateAppName(appName); err != nil {
+		return nil, nil, newToolError(ErrorValidation, "invalid app_name: %v", err)
+	}
+	installed, err := appExists(client, appName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if installed {
+		return nil, nil, newToolError(ErrorValidation, "an app named %s is already installed; pass a different app_name", appName)
+	}
+
+	given, _ := args["parameters"].(map[string]interface{})
+	params, generated, err := resolveAppTemplateParams(client, tmpl, given)
+	if err != nil {
+		return nil, nil, err
+	}
+	params["pool"] = pool
+	params["app_name"] = appName
+
+	installArgs := map[string]interface{}{
+		"app_name":        appName,
+		"catalog_app":     tmpl.CatalogApp,
+		"train":           tmpl.Train,
+		"values":          renderAppTemplate(tmpl.Values, params),
+		"create_datasets": true,
+	}
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		installArgs["dry_run"] = true
+	}
+
+	// Secrets are reported by name only
+	shown := map[string]interface{}{}
+	for _, param := range tmpl.Params {
+		if param.Type == appTemplateSecret {
+			continue
+		}
+		shown[param.Name] = params[param.Name]
+	}
+	summary := map[string]interface{}{
+		"template":   name,
+		"parameters": shown,
+	}
+	if len(generated) > 0 {
+		summary["generated_secrets"] = generated
+	}
+	if len(tmpl.Notes) > 0 {
+		summary["notes"] = tmpl.Notes
+	}
+	return installArgs, summary, nil
+}
+
+// handleInstallFromTemplate renders a template and runs install_app with it,
+// including its dry run
+func (r *Registry) handleInstallFromTemplate(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	installArgs, summary, err := appTemplateInstallArgs(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := r.handleInstallAppWithDryRun(ctx, client, installArgs)
+	if err != nil {
+		return "", err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(result), &response); err != nil {
+		return "", fmt.Errorf("failed to parse install result: %w", err)
+	}
+	for k, v := range summary {
+		response[k] = v
+	}
+	return marshalJSON(response)
+}
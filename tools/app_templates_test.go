@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationInstallFromTemplate(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	defer func(interval time.Duration) { installJobPollInterval = interval }(installJobPollInterval)
+	installJobPollInterval = 10 * time.Millisecond
+
+	datasets := []map[string]interface{}{}
+	setDatasets := func(names ...string) {
+		for _, name := range names {
+			datasets = append(datasets, map[string]interface{}{"id": name, "name": name})
+		}
+		server.SetRecords("pool.dataset.query", datasets)
+	}
+	setDatasets("tank", "tank/media")
+	server.Handle("pool.dataset.create", func(params []interface{}) (interface{}, error) {
+		name := params[0].(map[string]interface{})["name"].(string)
+		setDatasets(name)
+		return map[string]interface{}{"id": name}, nil
+	})
+	server.SetRecords("app.query", []map[string]interface{}{{"name": "home-assistant"}})
+	created := make(chan map[string]interface{}, 1)
+	server.Handle("app.create", func(params []interface{}) (interface{}, error) {
+		created <- params[0].(map[string]interface{})
+		return server.AddJob("app.create", params, truenastest.JobSpec{Steps: 1}), nil
+	})
+
+	result, err := registry.CallTool("list_app_templates", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list_app_templates failed: %v", err)
+	}
+	if decodeResult(t, result)["count"] != float64(len(appTemplates)) {
+		t.Errorf("list_app_templates = %s", result)
+	}
+
+	jellyfin := map[string]interface{}{
+		"template":   "jellyfin",
+		"pool":       "tank",
+		"parameters": map[string]interface{}{"media_path": "/mnt/tank/media/", "timezone": "Europe/Berlin"},
+		"dry_run":    true,
+	}
+	result, err = registry.CallTool("install_from_template", jellyfin)
+	if err != nil {
+		t.Fatalf("install_from_template dry run failed: %v", err)
+	}
+	preview := decodeResult(t, result)
+	if preview["template"] != "jellyfin" || len(preview["planned_actions"].([]interface{})) != 3 {
+		t.Errorf("dry run = %s, want config and cache datasets then app.create", result)
+	}
+
+	delete(jellyfin, "dry_run")
+	result, err = registry.CallTool("install_from_template", jellyfin)
+	if err != nil {
+		t.Fatalf("install_from_template failed: %v", err)
+	}
+	if decodeResult(t, result)["task_id"] == nil {
+		t.Errorf("install_from_template returned no task_id:\n%s", result)
+	}
+	var install map[string]interface{}
+	select {
+	case install = <-created:
+	case <-time.After(5 * time.Second):
+		t.Fatal("app.create was not called")
+	}
+	values := install["values"].(map[string]interface{})
+	network := values["network"].(map[string]interface{})["web_port"].(map[string]interface{})
+	storage := values["storage"].(map[string]interface{})
+	config := storage["config"].(map[string]interface{})["host_path_config"].(map[string]interface{})
+	media := storage["additional_storage"].([]interface{})[0].(map[string]interface{})["host_path_config"].(map[string]interface{})
+	if install["catalog_app"] != "jellyfin" || values["TZ"] != "Europe/Berlin" || network["port_number"] != float64(30013) ||
+		config["path"] != "/mnt/tank/apps/jellyfin/config" || media["path"] != "/mnt/tank/media" {
+		t.Errorf("app.create = %v", install)
+	}
+
+	// Generated secrets reach app.create but not the output
+	result, err = registry.CallTool("install_from_template", map[string]interface{}{
+		"template": "home-assistant", "pool": "tank", "app_name": "hass", "dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("home-assistant dry run failed: %v", err)
+	}
+	if fmt.Sprint(decodeResult(t, result)["generated_secrets"]) != "[db_password]" {
+		t.Errorf("home-assistant dry run = %s", result)
+	}
+
+	for _, tc := range []struct {
+		args map[string]interface{}
+		code ErrorCode
+	}{
+		{map[string]interface{}{"template": "plex", "pool": "tank"}, ErrorValidation},
+		{map[string]interface{}{"template": "home-assistant", "pool": "tank"}, ErrorValidation},
+		{map[string]interface{}{"template": "nextcloud", "pool": "tank", "parameters": map[string]interface{}{"host": "cloud.lan"}}, ErrorValidation},
+		{map[string]interface{}{"template": "jellyfin", "pool": "tank", "app_name": "jf", "parameters": map[string]interface{}{"media_path": "/mnt/tank/movies"}}, ErrorNotFound},
+		{map[string]interface{}{"template": "jellyfin", "pool": "tank", "app_name": "jf", "parameters": map[string]interface{}{"media_path": "/mnt/tank/media", "port": float64(80)}}, ErrorValidation},
+		{map[string]interface{}{"template": "jellyfin", "pool": "tank", "app_name": "jf", "parameters": map[string]interface{}{"media_path": "/mnt/tank/media", "web_port": float64(70000)}}, ErrorValidation},
+		{map[string]interface{}{"template": "jellyfin", "pool": "ssd", "parameters": map[string]interface{}{"media_path": "/mnt/tank/media"}}, ErrorNotFound},
+	} {
+		tc.args["dry_run"] = true
+		if _, err := registry.CallTool("install_from_template", tc.args); ClassifyError(err).Code != tc.code {
+			t.Errorf("install_from_template(%v) error = %v, want %s", tc.args, err, tc.code)
+		}
+	}
+}
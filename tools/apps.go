@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -22,7 +23,7 @@ type StorageVolume struct {
 // ============================================================================
 
 // handleSearchAppCatalog searches the TrueNAS app catalog
-func handleSearchAppCatalog(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleSearchAppCatalog(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Extract parameters
 	query := ""
 	if q, ok := args["query"].(string); ok {
@@ -134,7 +135,7 @@ func formatAppSearchResults(apps []interface{}) string {
 }
 
 // handleGetAppCatalogDetails retrieves detailed information about a specific app
-func handleGetAppCatalogDetails(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleGetAppCatalogDetails(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Extract parameters
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
@@ -146,18 +147,10 @@ func handleGetAppCatalogDetails(client *truenas.Client, args map[string]interfac
 		train = t
 	}
 
-	// Call catalog.get_app_details API
-	result, err := client.Call("catalog.get_app_details", appName, map[string]interface{}{
-		"train": train,
-	})
+	// Call catalog.get_app_details API (or reuse a cached result)
+	appDetails, cacheInfo, err := r.catalogAppDetails(client, appName, train)
 	if err != nil {
-		return "", fmt.Errorf("failed to get app details: %w", err)
-	}
-
-	// Parse result
-	var appDetails map[string]interface{}
-	if err := json.Unmarshal(result, &appDetails); err != nil {
-		return "", fmt.Errorf("failed to parse app details: %w", err)
+		return "", err
 	}
 
 	// Parse README for storage hints
@@ -170,7 +163,7 @@ func handleGetAppCatalogDetails(client *truenas.Client, args map[string]interfac
 	schema := extractAppSchema(appDetails)
 
 	// Format output
-	formatted := formatAppDetails(appDetails, storageHints, schema)
+	formatted := formatAppDetails(appDetails, storageHints, schema, cacheInfo)
 
 	return formatted, nil
 }
@@ -213,7 +206,7 @@ func parseAppREADMEForStorageHints(readme string) []string {
 }
 
 // formatAppDetails formats app details for display
-func formatAppDetails(details map[string]interface{}, storageHints []string, schema map[string]interface{}) string {
+func formatAppDetails(details map[string]interface{}, storageHints []string, schema map[string]interface{}, cacheInfo map[string]interface{}) string {
 	output := map[string]interface{}{
 		"name":           details["name"],
 		"title":          details["title"],
@@ -222,6 +215,9 @@ func formatAppDetails(details map[string]interface{}, storageHints []string, sch
 		"categories":     details["categories"],
 		"maintainers":    details["maintainers"],
 	}
+	if cacheInfo != nil {
+		output["catalog_cache"] = cacheInfo
+	}
 
 	if len(storageHints) > 0 {
 		output["storage_hints"] = map[string]interface{}{
@@ -449,127 +445,22 @@ func generateWizardGuidance(schema map[string]interface{}) map[string]interface{
 // Section 2: Installation Handler and Dry-Run
 // ============================================================================
 
-// handleInstallApp installs an app from the catalog
-func handleInstallApp(client *truenas.Client, args map[string]interface{}, taskManager *tasks.Manager) (string, error) {
-	// Extract parameters
-	appName, ok := args["app_name"].(string)
-	if !ok || appName == "" {
-		return "", fmt.Errorf("app_name is required")
-	}
-
-	catalogApp, ok := args["catalog_app"].(string)
-	if !ok || catalogApp == "" {
-		return "", fmt.Errorf("catalog_app is required")
-	}
-
-	train := "stable"
-	if t, ok := args["train"].(string); ok && t != "" {
-		train = t
-	}
-
-	version := "latest"
-	if v, ok := args["version"].(string); ok && v != "" {
-		version = v
-	}
-
-	// Validate app name
-	if err := validateAppName(appName); err != nil {
-		return "", fmt.Errorf("invalid app_name: %v", err)
-	}
-
-	// Extract values parameter (required)
-	values, ok := args["values"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("values parameter is required. Use get_app_catalog_details to see the schema and build the configuration")
-	}
-
-	// CRITICAL SECURITY: Enforce host-path-only storage
-	if err := enforceHostPathStorage(values); err != nil {
-		return "", fmt.Errorf("storage validation failed: %v", err)
-	}
-
-	// Extract storage paths for dataset verification
-	storagePaths := extractStoragePathsFromValues(values)
-
-	// Verify datasets exist
-	if len(storagePaths) > 0 {
-		missing, err := verifyDatasetPathsExist(client, storagePaths)
-		if err != nil {
-			return "", fmt.Errorf("failed to verify datasets: %v", err)
-		}
-		if len(missing) > 0 {
-			return "", fmt.Errorf("datasets must exist before installation. Missing:\n%s\n\nUse create_dataset tool first.",
-				strings.Join(missing, "\n  - "))
-		}
-	}
-
-	// Call app.create API
-	params := map[string]interface{}{
-		"app_name":    appName,
-		"catalog_app": catalogApp,
-		"train":       train,
-		"version":     version,
-		"values":      values,
-	}
-
-	result, err := client.Call("app.create", params)
-	if err != nil {
-		return "", fmt.Errorf("failed to install app: %v", err)
-	}
-
-	// Parse job ID (app.create may return an array [job_id] or just job_id)
-	var jobID int
-	// First try to parse as an integer
-	if err := json.Unmarshal(result, &jobID); err != nil {
-		// If that fails, try parsing as an array and extract the first element
-		var jobIDArray []int
-		if err2 := json.Unmarshal(result, &jobIDArray); err2 != nil {
-			return "", fmt.Errorf("failed to parse job ID as int or array: int error: %v, array error: %v", err, err2)
-		}
-		if len(jobIDArray) == 0 {
-			return "", fmt.Errorf("app.create returned empty job ID array")
-		}
-		jobID = jobIDArray[0]
-	}
-
-	// Create task for tracking
-	task, err := taskManager.CreateJobTask(
-		"install_app",
-		args,
-		jobID,
-		1*time.Hour, // 1 hour TTL
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create task: %w", err)
-	}
-
-	response := map[string]interface{}{
-		"app_name":      appName,
-		"catalog_app":   catalogApp,
-		"train":         train,
-		"version":       version,
-		"task_id":       task.TaskID,
-		"task_status":   task.Status,
-		"poll_interval": task.PollInterval,
-		"job_id":        jobID,
-		"message":       fmt.Sprintf("Installation started. Track progress with tasks_get using task_id: %s", task.TaskID),
-	}
-
-	formatted, err := json.MarshalIndent(response, "", "  ")
-	if err != nil {
-		return "", err
-	}
-
-	return string(formatted), nil
+// installAppDryRun implements dry-run for app installation
+type installAppDryRun struct {
+	registry *Registry
 }
 
-// installAppDryRun implements dry-run for app installation
-type installAppDryRun struct{}
+func (d *installAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	if taskID, ok := args["resume_task_id"].(string); ok && taskID != "" {
+		return d.resumeDryRun(client, taskID, args)
+	}
 
-func (d *installAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
 	// Extract parameters
-	appName := args["app_name"].(string)
-	catalogApp := args["catalog_app"].(string)
+	appName, _ := args["app_name"].(string)
+	catalogApp, _ := args["catalog_app"].(string)
+	if appName == "" || catalogApp == "" {
+		return nil, fmt.Errorf("app_name and catalog_app are required")
+	}
 	train := "stable"
 	if t, ok := args["train"].(string); ok && t != "" {
 		train = t
@@ -621,13 +512,10 @@ func (d *installAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string
 	appExists := len(apps) > 0
 
 	// Get app details for version info
-	appDetails, err := client.Call("catalog.get_app_details", catalogApp, map[string]interface{}{
-		"train": train,
-	})
+	detailsMap, _, err := d.registry.catalogAppDetails(client, catalogApp, train)
 
-	var detailsMap map[string]interface{}
 	latestVersion := "unknown"
-	if err == nil && json.Unmarshal(appDetails, &detailsMap) == nil {
+	if err == nil {
 		if v, ok := detailsMap["latest_version"].(string); ok {
 			latestVersion = v
 		}
@@ -637,14 +525,26 @@ func (d *installAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string
 	actions := []PlannedAction{}
 	step := 1
 
-	// Add warnings for missing datasets
+	// Missing datasets are created first with create_datasets, otherwise
+	// they block the installation
+	createDatasets, _ := args["create_datasets"].(bool)
 	for _, dataset := range missing {
-		actions = append(actions, PlannedAction{
-			Step:        step,
-			Description: fmt.Sprintf("WARNING: Dataset %s does not exist. Create it first with create_dataset.", dataset),
-			Operation:   "verify",
-			Target:      "pool.dataset.query",
-		})
+		if createDatasets {
+			actions = append(actions, PlannedAction{
+				Step:        step,
+				Description: fmt.Sprintf("Create dataset %s (and any missing parents)", dataset),
+				Operation:   "create",
+				Target:      "pool.dataset.create",
+				Details:     map[string]interface{}{"name": dataset, "share_type": "APPS"},
+			})
+		} else {
+			actions = append(actions, PlannedAction{
+				Step:        step,
+				Description: fmt.Sprintf("WARNING: Dataset %s does not exist. Create it first with create_dataset.", dataset),
+				Operation:   "verify",
+				Target:      "pool.dataset.query",
+			})
+		}
 		step++
 	}
 
@@ -667,8 +567,8 @@ func (d *installAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string
 	if appExists {
 		warnings = append(warnings, fmt.Sprintf("WARNING: App instance '%s' already exists. Installation will fail.", appName))
 	}
-	if len(missing) > 0 {
-		warnings = append(warnings, "CRITICAL: The following datasets must exist before installation. Use create_dataset tool:")
+	if len(missing) > 0 && !createDatasets {
+		warnings = append(warnings, "CRITICAL: The following datasets must exist before installation. Use create_dataset tool or set create_datasets:")
 		for _, ds := range missing {
 			warnings = append(warnings, fmt.Sprintf("  - %s", ds))
 		}
@@ -702,12 +602,50 @@ func (d *installAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string
 	return result, nil
 }
 
+// resumeDryRun previews the steps a resumed installation would run
+func (d *installAppDryRun) resumeDryRun(client truenas.Caller, taskID string, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := d.registry.resumableInstall(client, taskID, args)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{}
+	for _, step := range plan.Steps {
+		if step.Status == installStepCompleted {
+			continue
+		}
+		action := PlannedAction{Step: step.Step, Target: step.Target, Operation: "create"}
+		switch step.Action {
+		case installActionCreateDataset:
+			action.Description = fmt.Sprintf("Create dataset %s", step.Target)
+		case installActionCreateApp:
+			action.Description = fmt.Sprintf("Install %s app version %s as %s", plan.CatalogApp, plan.Version, plan.AppName)
+		}
+		actions = append(actions, action)
+	}
+
+	return &DryRunResult{
+		Tool: "install_app",
+		CurrentState: map[string]interface{}{
+			"resume_task_id": taskID,
+			"app_name":       plan.AppName,
+			"attempts":       plan.Attempts,
+			"steps":          plan.Steps,
+		},
+		PlannedActions: actions,
+		Warnings:       []string{fmt.Sprintf("%d of %d steps are already done and will be skipped.", len(plan.Steps)-len(actions), len(plan.Steps))},
+		EstimatedTime: &EstimatedTime{
+			MinSeconds: 30,
+			MaxSeconds: 300,
+			Note:       "Time varies based on container image size and network speed",
+		},
+	}, nil
+}
+
 // handleInstallAppWithDryRun wraps handleInstallApp with dry-run support
-func (r *Registry) handleInstallAppWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	dryRun := &installAppDryRun{}
-	return ExecuteWithDryRun(client, args, dryRun, func(c *truenas.Client, a map[string]interface{}) (string, error) {
-		return handleInstallApp(c, a, r.taskManager)
-	})
+func (r *Registry) handleInstallAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	dryRun := &installAppDryRun{registry: r}
+	return ExecuteWithDryRun(ctx, client, args, dryRun, r.handleInstallApp)
 }
 
 // ============================================================================
@@ -715,7 +653,7 @@ func (r *Registry) handleInstallAppWithDryRun(client *truenas.Client, args map[s
 // ============================================================================
 
 // handleDeleteApp deletes an installed app
-func handleDeleteApp(client *truenas.Client, args map[string]interface{}, taskManager *tasks.Manager) (string, error) {
+func handleDeleteApp(client truenas.Caller, args map[string]interface{}, taskManager *tasks.Manager) (string, error) {
 	// Extract parameters
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
@@ -758,6 +696,7 @@ func handleDeleteApp(client *truenas.Client, args map[string]interface{}, taskMa
 		args,
 		jobID,
 		30*time.Minute, // 30 minute TTL
+		client.CorrelationID(),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
@@ -784,7 +723,7 @@ func handleDeleteApp(client *truenas.Client, args map[string]interface{}, taskMa
 // deleteAppDryRun implements dry-run for app deletion
 type deleteAppDryRun struct{}
 
-func (d *deleteAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (d *deleteAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	// Extract parameters
 	appName := args["app_name"].(string)
 
@@ -875,9 +814,9 @@ func (d *deleteAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]
 }
 
 // handleDeleteAppWithDryRun wraps handleDeleteApp with dry-run support
-func (r *Registry) handleDeleteAppWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleDeleteAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	dryRun := &deleteAppDryRun{}
-	return ExecuteWithDryRun(client, args, dryRun, func(c *truenas.Client, a map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, dryRun, func(ctx context.Context, c truenas.Caller, a map[string]interface{}) (string, error) {
 		return handleDeleteApp(c, a, r.taskManager)
 	})
 }
@@ -974,7 +913,7 @@ func parseStoragePath(path string) (pool string, dataset string, err error) {
 }
 
 // verifyDatasetsExist checks if datasets exist for all storage volumes
-func verifyDatasetsExist(client *truenas.Client, volumes []StorageVolume) ([]string, error) {
+func verifyDatasetsExist(client truenas.Caller, volumes []StorageVolume) ([]string, error) {
 	var missing []string
 
 	for _, vol := range volumes {
@@ -1191,7 +1130,7 @@ func collectPaths(obj map[string]interface{}, paths *[]string) {
 }
 
 // verifyDatasetPathsExist checks if datasets exist for all paths
-func verifyDatasetPathsExist(client *truenas.Client, paths []string) ([]string, error) {
+func verifyDatasetPathsExist(client truenas.Caller, paths []string) ([]string, error) {
 	missing := []string{}
 
 	for _, path := range paths {
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// maxLoginBannerLength is the middleware's limit on system.advanced login_banner
+const maxLoginBannerLength = 4096
+
+// getAdvancedConfig returns system.advanced.config
+func getAdvancedConfig(client truenas.Caller) (map[string]interface{}, error) {
+	result, err := client.Call("system.advanced.config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get advanced settings: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse advanced settings: %w", err)
+	}
+	return config, nil
+}
+
+// banners summarizes the MOTD and login banner from system.advanced.config
+func banners(config map[string]interface{}) map[string]interface{} {
+	motd, _ := config["motd"].(string)
+	summary := map[string]interface{}{
+		"motd": map[string]interface{}{
+			"text":        motd,
+			"configured":  motd != "",
+			"description": "Message of the day, shown after logging in over SSH or the console",
+		},
+	}
+	if banner, ok := config["login_banner"].(string); ok {
+		summary["login_banner"] = map[string]interface{}{
+			"text":        banner,
+			"configured":  banner != "",
+			"description": "Shown before login on the web UI login page (users must click Continue to acknowledge it) and for SSH",
+		}
+	} else {
+		summary["login_banner"] = map[string]interface{}{
+			"supported": false,
+			"note":      "This TrueNAS version has no login banner setting (added in TrueNAS SCALE 24.04)",
+		}
+	}
+	return summary
+}
+
+// bannerUpdate builds the system.advanced.update payload from args. An empty
+// string clears a message.
+func bannerUpdate(config map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if motd, ok := args["motd"].(string); ok {
+		update["motd"] = motd
+	}
+	if banner, ok := args["login_banner"].(string); ok {
+		if _, supported := config["login_banner"]; !supported {
+			return nil, newToolError(ErrorPrecondition, "this TrueNAS version has no login banner setting (added in TrueNAS SCALE 24.04)")
+		}
+		if utf8.RuneCountInString(banner) > maxLoginBannerLength {
+			return nil, newToolError(ErrorValidation, "login_banner is %d characters; the limit is %d", utf8.RuneCountInString(banner), maxLoginBannerLength)
+		}
+		update["login_banner"] = banner
+	}
+
+	if len(update) == 0 {
+		return nil, fmt.Errorf("nothing to update: provide motd, login_banner, or both")
+	}
+	return update, nil
+}
+
+func handleGetSystemBanners(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(banners(config))
+}
+
+func handleSetSystemBanners(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return "", err
+	}
+
+	update, err := bannerUpdate(config, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("system.advanced.update", update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update banners: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	response := banners(updated)
+	response["updated"] = true
+	response["message"] = "Banners updated. New SSH and web UI sessions show them; existing sessions are unaffected."
+	return marshalJSON(response)
+}
+
+func handleSetSystemBannersWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &setSystemBannersDryRun{}, handleSetSystemBanners)
+}
+
+type setSystemBannersDryRun struct{}
+
+func (s *setSystemBannersDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := bannerUpdate(config, args)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	warnings := []string{}
+	for _, field := range []string{"motd", "login_banner"} {
+		value, ok := update[field].(string)
+		if !ok {
+			continue
+		}
+		old, _ := config[field].(string)
+		if old == value {
+			warnings = append(warnings, fmt.Sprintf("%s is unchanged", field))
+			continue
+		}
+		changes[field] = map[string]interface{}{"old": old, "new": value}
+	}
+	if banner, ok := update["login_banner"].(string); ok {
+		old, _ := config["login_banner"].(string)
+		if banner != "" && old == "" {
+			warnings = append(warnings, "Web UI users will have to acknowledge the login banner before they can log in")
+		}
+	}
+
+	return &DryRunResult{
+		Tool:         "set_system_banners",
+		CurrentState: banners(config),
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: "Update the system banners",
+				Operation:   "update",
+				Target:      "system.advanced",
+				Details:     changes,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationSystemBanners(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.advanced.config", map[string]interface{}{"motd": "Welcome to TrueNAS", "login_banner": ""})
+	server.SetResult("system.advanced.update", map[string]interface{}{"motd": "Welcome to TrueNAS", "login_banner": "Authorized use only"})
+
+	result, err := registry.CallTool("get_system_banners", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_system_banners failed: %v", err)
+	}
+	motd := decodeResult(t, result)["motd"].(map[string]interface{})
+	if motd["text"] != "Welcome to TrueNAS" || motd["configured"] != true {
+		t.Errorf("motd = %v", motd)
+	}
+
+	result, err = registry.CallTool("set_system_banners", map[string]interface{}{"login_banner": "Authorized use only", "dry_run": true})
+	if err != nil {
+		t.Fatalf("set_system_banners dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "acknowledge the login banner") {
+		t.Errorf("dry run lacks the acknowledgement warning: %s", result)
+	}
+	if calls := server.Calls("system.advanced.update"); len(calls) != 0 {
+		t.Fatalf("dry run called system.advanced.update: %v", calls)
+	}
+
+	if _, err := registry.CallTool("set_system_banners", map[string]interface{}{"login_banner": "Authorized use only"}); err != nil {
+		t.Fatalf("set_system_banners failed: %v", err)
+	}
+	calls := server.Calls("system.advanced.update")
+	if len(calls) != 1 {
+		t.Fatalf("system.advanced.update calls = %v, want one", calls)
+	}
+	payload := calls[0].Params[0].(map[string]interface{})
+	if _, ok := payload["motd"]; ok || payload["login_banner"] != "Authorized use only" {
+		t.Errorf("update payload = %v, want only login_banner", payload)
+	}
+
+	if _, err := registry.CallTool("set_system_banners", map[string]interface{}{"login_banner": strings.Repeat("x", 4097)}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("oversized banner error = %v, want VALIDATION", err)
+	}
+
+	// Releases before 24.04 have no login banner
+	server.SetResult("system.advanced.config", map[string]interface{}{"motd": ""})
+	if _, err := registry.CallTool("set_system_banners", map[string]interface{}{"login_banner": "Authorized use only"}); ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("unsupported banner error = %v, want PRECONDITION_FAILED", err)
+	}
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Boot environment cleanup planning
+
+// defaultBootEnvironmentsToKeep follows the 2-3 boot environment recovery
+// recommendation given with apply_update and delete_boot_environment
+const defaultBootEnvironmentsToKeep = 3
+
+// bootEnvironmentCleanup is the outcome of selecting boot environments to delete
+type bootEnvironmentCleanup struct {
+	Delete   []map[string]interface{} // oldest first
+	Retain   []map[string]interface{}
+	Freed    int64
+	Complete bool // keep count or space target reached
+}
+
+// planBootEnvironmentCleanup selects deletable boot environments oldest first.
+// Without a space target it deletes until only keep remain; with one it stops
+// as soon as freeTarget bytes are covered. It never leaves fewer than keep.
+func planBootEnvironmentCleanup(envs []map[string]interface{}, keep int, freeTarget int64) bootEnvironmentCleanup {
+	sorted := make([]map[string]interface{}, len(envs))
+	copy(sorted, envs)
+	sortBootEnvironments(sorted, "created")
+
+	plan := bootEnvironmentCleanup{}
+	remaining := len(sorted)
+	selected := make(map[string]bool)
+
+	// sorted is newest first, so walk it backwards
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if freeTarget > 0 && plan.Freed >= freeTarget {
+			break
+		}
+		if remaining <= keep {
+			break
+		}
+		env := sorted[i]
+		if deletable, _ := env["deletable"].(bool); !deletable {
+			continue
+		}
+		id, _ := env["id"].(string)
+		size, _ := env["size_bytes"].(int64)
+		selected[id] = true
+		plan.Delete = append(plan.Delete, env)
+		plan.Freed += size
+		remaining--
+	}
+
+	for _, env := range sorted {
+		if id, _ := env["id"].(string); !selected[id] {
+			plan.Retain = append(plan.Retain, env)
+		}
+	}
+
+	if freeTarget > 0 {
+		plan.Complete = plan.Freed >= freeTarget
+	} else {
+		plan.Complete = remaining <= keep
+	}
+	return plan
+}
+
+// bootEnvironmentRetainReason explains why a boot environment stays
+func bootEnvironmentRetainReason(env map[string]interface{}) string {
+	if blockers, ok := env["deletion_blockers"].([]string); ok && len(blockers) > 0 {
+		return blockers[0]
+	}
+	return "within keep count"
+}
+
+func handlePlanBootEnvironmentCleanup(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	keep := defaultBootEnvironmentsToKeep
+	if k, ok := args["keep"].(float64); ok {
+		if k < 1 {
+			return "", fmt.Errorf("keep must be at least 1")
+		}
+		keep = int(k)
+	}
+
+	var freeTarget int64
+	if raw, ok := args["free_space"]; ok {
+		size, err := units.ParseSizeValue(raw)
+		if err != nil {
+			return "", fmt.Errorf("free_space: %w", err)
+		}
+		freeTarget = size
+	}
+
+	result, err := client.Call("boot.environment.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query boot environments: %w", err)
+	}
+
+	var bootEnvs []map[string]interface{}
+	if err := json.Unmarshal(result, &bootEnvs); err != nil {
+		return "", fmt.Errorf("failed to parse boot environments: %w", err)
+	}
+
+	simplified := make([]map[string]interface{}, 0, len(bootEnvs))
+	for _, env := range bootEnvs {
+		simplified = append(simplified, simplifyBootEnvironment(env))
+	}
+
+	plan := planBootEnvironmentCleanup(simplified, keep, freeTarget)
+
+	steps := make([]map[string]interface{}, 0, len(plan.Delete))
+	for i, env := range plan.Delete {
+		steps = append(steps, map[string]interface{}{
+			"step":      i + 1,
+			"tool":      "delete_boot_environment",
+			"arguments": map[string]interface{}{"id": env["id"]},
+			"created":   env["created"],
+			"size":      units.NewSize(env["size_bytes"].(int64)),
+		})
+	}
+
+	retained := make([]map[string]interface{}, 0, len(plan.Retain))
+	for _, env := range plan.Retain {
+		retained = append(retained, map[string]interface{}{
+			"id":      env["id"],
+			"created": env["created"],
+			"reason":  bootEnvironmentRetainReason(env),
+		})
+	}
+
+	response := map[string]interface{}{
+		"total":       len(simplified),
+		"keep":        keep,
+		"plan":        steps,
+		"space_freed": units.NewSize(plan.Freed),
+		"retained":    retained,
+		"target_met":  plan.Complete,
+		"note":        "Run each step with delete_boot_environment in order (dry_run first if unsure). Space freed is an estimate; blocks shared with other boot environments are released only when the last one referencing them is deleted.",
+	}
+	if freeTarget > 0 {
+		response["free_space_target"] = units.NewSize(freeTarget)
+	}
+
+	warnings := []string{}
+	if !plan.Complete {
+		if freeTarget > 0 {
+			warnings = append(warnings, fmt.Sprintf("Deleting every safe candidate while keeping %d boot environments frees only %s of the requested %s", keep, units.FormatBytes(plan.Freed), units.FormatBytes(freeTarget)))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("More than %d boot environments remain because the rest are active, activated, or protected", keep))
+		}
+	}
+	if keep < 2 {
+		warnings = append(warnings, "Keeping fewer than 2 boot environments leaves no rollback target after the next update")
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+
+	return marshalJSON(response)
+}
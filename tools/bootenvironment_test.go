@@ -231,69 +231,6 @@ func TestSortBootEnvironments(t *testing.T) {
 	}
 }
 
-func TestFormatBytes(t *testing.T) {
-	tests := []struct {
-		name     string
-		bytes    int64
-		expected string
-	}{
-		{
-			name:     "bytes",
-			bytes:    500,
-			expected: "500 B",
-		},
-		{
-			name:     "kilobytes",
-			bytes:    2048,
-			expected: "2.00 KiB",
-		},
-		{
-			name:     "megabytes",
-			bytes:    5242880, // 5 MB
-			expected: "5.00 MiB",
-		},
-		{
-			name:     "gigabytes",
-			bytes:    1234567890,
-			expected: "1.15 GiB",
-		},
-		{
-			name:     "terabytes",
-			bytes:    2199023255552, // 2 TB
-			expected: "2.00 TiB",
-		},
-		{
-			name:     "zero bytes",
-			bytes:    0,
-			expected: "0 B",
-		},
-		{
-			name:     "exactly 1 KiB",
-			bytes:    1024,
-			expected: "1.00 KiB",
-		},
-		{
-			name:     "exactly 1 MiB",
-			bytes:    1048576,
-			expected: "1.00 MiB",
-		},
-		{
-			name:     "exactly 1 GiB",
-			bytes:    1073741824,
-			expected: "1.00 GiB",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatBytes(tt.bytes)
-			if result != tt.expected {
-				t.Errorf("formatBytes(%d) = %v, want %v", tt.bytes, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestDeletableComputation(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -384,3 +321,69 @@ func TestDeletableComputation(t *testing.T) {
 		})
 	}
 }
+
+func TestPlanBootEnvironmentCleanup(t *testing.T) {
+	env := func(id, created string, size int64, active, keep bool) map[string]interface{} {
+		return simplifyBootEnvironment(map[string]interface{}{
+			"id":         id,
+			"created":    created,
+			"used_bytes": float64(size),
+			"active":     active,
+			"activated":  active,
+			"keep":       keep,
+		})
+	}
+	envs := []map[string]interface{}{
+		env("25.04.2", "2025-08-01T00:00:00Z", 3<<30, true, false),
+		env("25.04.1", "2025-06-01T00:00:00Z", 2<<30, false, false),
+		env("25.04.0", "2025-04-01T00:00:00Z", 2<<30, false, false),
+		env("24.10.2", "2025-02-01T00:00:00Z", 1<<30, false, true),
+		env("24.10.1", "2024-12-01T00:00:00Z", 1<<30, false, false),
+	}
+
+	ids := func(list []map[string]interface{}) []string {
+		out := []string{}
+		for _, e := range list {
+			out = append(out, e["id"].(string))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name       string
+		keep       int
+		freeTarget int64
+		wantDelete []string
+		wantFreed  int64
+		complete   bool
+	}{
+		{name: "keep three", keep: 3, wantDelete: []string{"24.10.1", "25.04.0"}, wantFreed: 3 << 30, complete: true},
+		{name: "keep one skips protected", keep: 1, wantDelete: []string{"24.10.1", "25.04.0", "25.04.1"}, wantFreed: 5 << 30, complete: false},
+		{name: "free space stops early", keep: 1, freeTarget: 2 << 30, wantDelete: []string{"24.10.1", "25.04.0"}, wantFreed: 3 << 30, complete: true},
+		{name: "free space limited by keep", keep: 4, freeTarget: 10 << 30, wantDelete: []string{"24.10.1"}, wantFreed: 1 << 30, complete: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planBootEnvironmentCleanup(envs, tt.keep, tt.freeTarget)
+			got := ids(plan.Delete)
+			if len(got) != len(tt.wantDelete) {
+				t.Fatalf("delete = %v, want %v", got, tt.wantDelete)
+			}
+			for i := range got {
+				if got[i] != tt.wantDelete[i] {
+					t.Fatalf("delete = %v, want %v", got, tt.wantDelete)
+				}
+			}
+			if plan.Freed != tt.wantFreed {
+				t.Errorf("freed = %d, want %d", plan.Freed, tt.wantFreed)
+			}
+			if plan.Complete != tt.complete {
+				t.Errorf("complete = %v, want %v", plan.Complete, tt.complete)
+			}
+			if len(plan.Retain)+len(plan.Delete) != len(envs) {
+				t.Errorf("retain %d + delete %d != %d", len(plan.Retain), len(plan.Delete), len(envs))
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Capacity alert threshold handlers
+
+// capacityAlertProperties are the dataset properties holding quota alert
+// thresholds, as percentages of quota/refquota
+var capacityAlertProperties = []string{"quota_warning", "quota_critical", "refquota_warning", "refquota_critical"}
+
+// capacityAlertUpdate is a validated threshold change for one dataset
+type capacityAlertUpdate struct {
+	dataset string
+	payload map[string]interface{}
+}
+
+// parseCapacityAlertUpdate validates tool arguments into a pool.dataset.update payload
+func parseCapacityAlertUpdate(args map[string]interface{}) (*capacityAlertUpdate, error) {
+	dataset, ok := args["dataset"].(string)
+	if !ok || dataset == "" {
+		return nil, fmt.Errorf("dataset is required (use the pool name to set thresholds on the pool's root dataset)")
+	}
+
+	payload := map[string]interface{}{}
+	for _, prop := range capacityAlertProperties {
+		raw, ok := args[prop]
+		if !ok {
+			continue
+		}
+		switch v := raw.(type) {
+		case string:
+			if v != "INHERIT" {
+				return nil, fmt.Errorf("%s must be a percentage (0-100) or \"INHERIT\", got: %s", prop, v)
+			}
+			payload[prop] = "INHERIT"
+		case float64:
+			if v < 0 || v > 100 || v != float64(int(v)) {
+				return nil, fmt.Errorf("%s must be a whole percentage between 0 and 100, got: %v", prop, v)
+			}
+			payload[prop] = int(v)
+		default:
+			return nil, fmt.Errorf("%s must be a percentage (0-100) or \"INHERIT\"", prop)
+		}
+	}
+
+	for _, pair := range [][2]string{{"quota_warning", "quota_critical"}, {"refquota_warning", "refquota_critical"}} {
+		warning, wOk := payload[pair[0]].(int)
+		critical, cOk := payload[pair[1]].(int)
+		if wOk && cOk && warning > 0 && critical > 0 && warning >= critical {
+			return nil, fmt.Errorf("%s (%d%%) must be lower than %s (%d%%)", pair[0], warning, pair[1], critical)
+		}
+	}
+
+	// Thresholds only fire against a quota, so one can be set in the same call
+	for _, key := range []string{"quota", "refquota"} {
+		raw, ok := args[key]
+		if !ok {
+			continue
+		}
+		size, err := units.ParseSizeValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		payload[key] = size
+	}
+
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("at least one of quota_warning, quota_critical, refquota_warning, refquota_critical, quota, or refquota is required")
+	}
+
+	return &capacityAlertUpdate{dataset: dataset, payload: payload}, nil
+}
+
+// getDatasetByName returns a single dataset without its children
+func getDatasetByName(client truenas.Caller, name string) (map[string]interface{}, error) {
+	result, err := client.Call("pool.dataset.query",
+		[]interface{}{
+			[]interface{}{"id", "=", name},
+		},
+		map[string]interface{}{"extra": map[string]interface{}{"retrieve_children": false}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dataset: %w", err)
+	}
+
+	var datasets []map[string]interface{}
+	if err := json.Unmarshal(result, &datasets); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset: %w", err)
+	}
+	if len(datasets) == 0 {
+		return nil, fmt.Errorf("dataset not found: %s", name)
+	}
+	return datasets[0], nil
+}
+
+// capacityAlertState extracts the current thresholds and quotas of a dataset
+func capacityAlertState(ds map[string]interface{}) map[string]interface{} {
+	state := map[string]interface{}{}
+	for _, prop := range append([]string{"quota", "refquota"}, capacityAlertProperties...) {
+		propMap, ok := ds[prop].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := map[string]interface{}{
+			"value":  propMap["parsed"],
+			"source": propMap["source"],
+		}
+		if prop == "quota" || prop == "refquota" {
+			if bytes, ok := propMap["parsed"].(float64); ok && bytes > 0 {
+				entry["value"] = units.NewSize(int64(bytes))
+			} else {
+				entry["value"] = nil
+			}
+		}
+		state[prop] = entry
+	}
+	return state
+}
+
+// capacityAlertWarnings explains when the configured thresholds cannot fire
+func capacityAlertWarnings(ds map[string]interface{}, payload map[string]interface{}) []string {
+	hasQuota := func(key string) bool {
+		if size, ok := payload[key].(int64); ok {
+			return size > 0
+		}
+		if propMap, ok := ds[key].(map[string]interface{}); ok {
+			bytes, _ := propMap["parsed"].(float64)
+			return bytes > 0
+		}
+		return false
+	}
+
+	warnings := []string{}
+	if (payload["quota_warning"] != nil || payload["quota_critical"] != nil) && !hasQuota("quota") {
+		warnings = append(warnings, "Dataset has no quota; quota_warning/quota_critical alerts will not fire until a quota is set")
+	}
+	if (payload["refquota_warning"] != nil || payload["refquota_critical"] != nil) && !hasQuota("refquota") {
+		warnings = append(warnings, "Dataset has no refquota; refquota_warning/refquota_critical alerts will not fire until a refquota is set")
+	}
+	return warnings
+}
+
+func handleConfigureCapacityAlerts(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	update, err := parseCapacityAlertUpdate(args)
+	if err != nil {
+		return "", err
+	}
+
+	ds, err := getDatasetByName(client, update.dataset)
+	if err != nil {
+		return "", err
+	}
+	previous := capacityAlertState(ds)
+
+	result, err := client.Call("pool.dataset.update", update.dataset, update.payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to update capacity alert thresholds: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse updated dataset: %w", err)
+	}
+
+	response := map[string]interface{}{
+		"success":  true,
+		"dataset":  update.dataset,
+		"previous": previous,
+		"current":  capacityAlertState(updated),
+		"note":     "Child datasets inherit these thresholds unless they set their own. Pool-wide usage alerts (80% warning, 90% critical) are built into TrueNAS and always active.",
+	}
+	if warnings := capacityAlertWarnings(updated, update.payload); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+
+	return marshalJSON(response)
+}
+
+func (r *Registry) handleConfigureCapacityAlertsWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &configureCapacityAlertsDryRun{}, handleConfigureCapacityAlerts)
+}
+
+type configureCapacityAlertsDryRun struct{}
+
+func (c *configureCapacityAlertsDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	update, err := parseCapacityAlertUpdate(args)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := getDatasetByName(client, update.dataset)
+	if err != nil {
+		return nil, err
+	}
+	current := capacityAlertState(ds)
+
+	changes := map[string]interface{}{}
+	for key, value := range update.payload {
+		from := interface{}(nil)
+		if entry, ok := current[key].(map[string]interface{}); ok {
+			from = entry["value"]
+		}
+		to := value
+		if size, ok := value.(int64); ok {
+			to = units.NewSize(size)
+		}
+		changes[key] = map[string]interface{}{"from": from, "to": to}
+	}
+
+	return &DryRunResult{
+		Tool: "configure_capacity_alerts",
+		CurrentState: map[string]interface{}{
+			"dataset":    update.dataset,
+			"thresholds": current,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Update capacity alert thresholds on '%s'", update.dataset),
+				Operation:   "update",
+				Target:      update.dataset,
+				Details:     changes,
+			},
+		},
+		Warnings: capacityAlertWarnings(ds, update.payload),
+	}, nil
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCapacityAlertUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "thresholds",
+			args: map[string]interface{}{"dataset": "tank", "quota_warning": float64(80), "quota_critical": float64(95)},
+			want: map[string]interface{}{"quota_warning": 80, "quota_critical": 95},
+		},
+		{
+			name: "inherit and quota",
+			args: map[string]interface{}{"dataset": "tank/a", "refquota_warning": "INHERIT", "refquota": "1T"},
+			want: map[string]interface{}{"refquota_warning": "INHERIT", "refquota": int64(1 << 40)},
+		},
+		{
+			name: "zero disables without ordering check",
+			args: map[string]interface{}{"dataset": "tank", "quota_warning": float64(0), "quota_critical": float64(50)},
+			want: map[string]interface{}{"quota_warning": 0, "quota_critical": 50},
+		},
+		{name: "missing dataset", args: map[string]interface{}{"quota_warning": float64(80)}, wantErr: "dataset is required"},
+		{name: "nothing to change", args: map[string]interface{}{"dataset": "tank"}, wantErr: "at least one"},
+		{name: "out of range", args: map[string]interface{}{"dataset": "tank", "quota_warning": float64(120)}, wantErr: "between 0 and 100"},
+		{name: "bad string", args: map[string]interface{}{"dataset": "tank", "quota_warning": "high"}, wantErr: "INHERIT"},
+		{
+			name:    "warning above critical",
+			args:    map[string]interface{}{"dataset": "tank", "refquota_warning": float64(90), "refquota_critical": float64(85)},
+			wantErr: "must be lower than",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := parseCapacityAlertUpdate(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(update.payload) != len(tt.want) {
+				t.Fatalf("payload = %v, want %v", update.payload, tt.want)
+			}
+			for key, want := range tt.want {
+				if update.payload[key] != want {
+					t.Errorf("payload[%s] = %v (%T), want %v (%T)", key, update.payload[key], update.payload[key], want, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationConfigureCapacityAlerts(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{
+			"id":             "tank/shares",
+			"quota":          map[string]interface{}{"parsed": float64(0), "source": "DEFAULT"},
+			"quota_warning":  map[string]interface{}{"parsed": float64(80), "source": "DEFAULT"},
+			"quota_critical": map[string]interface{}{"parsed": float64(95), "source": "DEFAULT"},
+		},
+	})
+	server.SetResult("pool.dataset.update", map[string]interface{}{
+		"id":             "tank/shares",
+		"quota":          map[string]interface{}{"parsed": float64(500 << 30), "source": "LOCAL"},
+		"quota_warning":  map[string]interface{}{"parsed": float64(75), "source": "LOCAL"},
+		"quota_critical": map[string]interface{}{"parsed": float64(90), "source": "LOCAL"},
+	})
+
+	args := map[string]interface{}{
+		"dataset":        "tank/shares",
+		"quota_warning":  float64(75),
+		"quota_critical": float64(90),
+		"dry_run":        true,
+	}
+	result, err := registry.CallTool("configure_capacity_alerts", args)
+	if err != nil {
+		t.Fatalf("configure_capacity_alerts dry run failed: %v", err)
+	}
+	if n := len(server.Calls("pool.dataset.update")); n != 0 {
+		t.Fatalf("dry run updated the dataset (%d calls)", n)
+	}
+	preview := decodeResult(t, result)
+	warnings, _ := preview["warnings"].([]interface{})
+	if len(warnings) != 1 || !strings.Contains(warnings[0].(string), "no quota") {
+		t.Errorf("warnings = %v, want a missing quota warning", preview["warnings"])
+	}
+
+	delete(args, "dry_run")
+	args["quota"] = "500G"
+	result, err = registry.CallTool("configure_capacity_alerts", args)
+	if err != nil {
+		t.Fatalf("configure_capacity_alerts failed: %v", err)
+	}
+
+	calls := server.Calls("pool.dataset.update")
+	if len(calls) != 1 {
+		t.Fatalf("pool.dataset.update called %d times, want 1", len(calls))
+	}
+	if calls[0].Params[0] != "tank/shares" {
+		t.Errorf("updated dataset = %v, want tank/shares", calls[0].Params[0])
+	}
+	payload, _ := calls[0].Params[1].(map[string]interface{})
+	if payload["quota_warning"] != float64(75) || payload["quota_critical"] != float64(90) {
+		t.Errorf("payload = %v, want quota_warning 75 and quota_critical 90", payload)
+	}
+	if payload["quota"] != float64(500<<30) {
+		t.Errorf("quota = %v, want %d", payload["quota"], int64(500<<30))
+	}
+
+	response := decodeResult(t, result)
+	if _, ok := response["warnings"]; ok {
+		t.Errorf("unexpected warnings with quota set: %v", response["warnings"])
+	}
+}
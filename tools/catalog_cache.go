@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/truenas/truenas-mcp/catalog"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// fetchCatalogAppDetails calls catalog.get_app_details
+func fetchCatalogAppDetails(client truenas.Caller, app, train string) (json.RawMessage, error) {
+	result, err := client.Call("catalog.get_app_details", app, map[string]interface{}{
+		"train": train,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app details: %w", err)
+	}
+	return result, nil
+}
+
+// systemVersion returns the TrueNAS version that keys cached catalog entries
+func systemVersion(client truenas.Caller) (string, error) {
+	result, err := client.Call("system.info")
+	if err != nil {
+		return "", err
+	}
+	var info map[string]interface{}
+	if err := json.Unmarshal(result, &info); err != nil {
+		return "", err
+	}
+	version, _ := info["version"].(string)
+	return version, nil
+}
+
+// catalogAppDetails returns the catalog details of app on train, served from
+// the catalog cache while fresh. When TrueNAS cannot provide them, a stale
+// cached copy is returned instead. The second result describes the cache
+// entry used (nil when caching is disabled).
+func (r *Registry) catalogAppDetails(client truenas.Caller, app, train string) (map[string]interface{}, map[string]interface{}, error) {
+	if r.catalogCache == nil || !r.catalogCache.Enabled() {
+		result, err := fetchCatalogAppDetails(client, app, train)
+		if err != nil {
+			return nil, nil, err
+		}
+		details, err := parseCatalogDetails(result)
+		return details, nil, err
+	}
+
+	now := time.Now()
+	version, versionErr := systemVersion(client)
+	if versionErr == nil {
+		if entry := r.catalogCache.Get(version, train, app); entry != nil && r.catalogCache.Fresh(entry, now) {
+			return cachedCatalogDetails(entry, "cache", now)
+		}
+	}
+
+	result, err := fetchCatalogAppDetails(client, app, train)
+	if err != nil {
+		// Fall back to the newest copy from any release rather than failing
+		if entry := r.catalogCache.Newest(train, app); entry != nil {
+			details, info, cacheErr := cachedCatalogDetails(entry, "stale_cache", now)
+			if cacheErr == nil {
+				info["note"] = fmt.Sprintf("TrueNAS could not provide fresh details (%v); this copy may be out of date", err)
+			}
+			return details, info, cacheErr
+		}
+		return nil, nil, err
+	}
+
+	details, err := parseCatalogDetails(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	if versionErr != nil {
+		// Without a version key the result cannot be cached safely
+		return details, map[string]interface{}{"source": "truenas"}, nil
+	}
+
+	entry := &catalog.Entry{
+		App:           app,
+		Train:         train,
+		SystemVersion: version,
+		FetchedAt:     now.UTC(),
+		Details:       result,
+	}
+	entry.AppVersion, _ = details["latest_version"].(string)
+	info := map[string]interface{}{"source": "truenas", "fetched_at": entry.FetchedAt}
+	if err := r.catalogCache.Put(entry); err != nil {
+		info["note"] = fmt.Sprintf("failed to cache details: %v", err)
+	}
+	return details, info, nil
+}
+
+func parseCatalogDetails(result json.RawMessage) (map[string]interface{}, error) {
+	var details map[string]interface{}
+	if err := json.Unmarshal(result, &details); err != nil {
+		return nil, fmt.Errorf("failed to parse app details: %w", err)
+	}
+	return details, nil
+}
+
+func cachedCatalogDetails(entry *catalog.Entry, source string, now time.Time) (map[string]interface{}, map[string]interface{}, error) {
+	details, err := parseCatalogDetails(entry.Details)
+	if err != nil {
+		return nil, nil, err
+	}
+	return details, map[string]interface{}{
+		"source":         source,
+		"fetched_at":     entry.FetchedAt,
+		"age":            now.Sub(entry.FetchedAt).Round(time.Second).String(),
+		"system_version": entry.SystemVersion,
+	}, nil
+}
+
+func (r *Registry) handleRefreshCatalogCache(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.catalogCache == nil {
+		return "", fmt.Errorf("the catalog cache is not available on this server")
+	}
+
+	appName, _ := args["app_name"].(string)
+	train, _ := args["train"].(string)
+
+	removed, err := r.catalogCache.Invalidate(train, appName)
+	if err != nil {
+		return "", err
+	}
+	response := map[string]interface{}{
+		"removed": removed,
+		"enabled": r.catalogCache.Enabled(),
+		"ttl":     r.catalogCache.TTL().String(),
+	}
+
+	// Refetch a named app right away so the next install step is fast
+	if appName != "" && r.catalogCache.Enabled() {
+		if train == "" {
+			train = "stable"
+		}
+		details, info, err := r.catalogAppDetails(client, appName, train)
+		if err != nil {
+			return "", err
+		}
+		response["refreshed"] = map[string]interface{}{
+			"app_name":       appName,
+			"train":          train,
+			"latest_version": details["latest_version"],
+			"catalog_cache":  info,
+		}
+	}
+
+	response["cached"] = r.catalogCache.List()
+	return marshalJSON(response)
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/catalog"
+)
+
+func TestIntegrationCatalogCache(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	cache, err := catalog.NewCache("", time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	registry.catalogCache = cache
+
+	server.SetResult("system.info", map[string]interface{}{"version": "25.04.1"})
+	server.SetResult("catalog.get_app_details", map[string]interface{}{
+		"name":           "plex",
+		"title":          "Plex",
+		"latest_version": "1.2.0",
+	})
+
+	args := map[string]interface{}{"app_name": "plex"}
+	if _, err := registry.CallTool("get_app_catalog_details", args); err != nil {
+		t.Fatalf("get_app_catalog_details failed: %v", err)
+	}
+	result, err := registry.CallTool("get_app_catalog_details", args)
+	if err != nil {
+		t.Fatalf("second get_app_catalog_details failed: %v", err)
+	}
+	if calls := server.Calls("catalog.get_app_details"); len(calls) != 1 {
+		t.Errorf("catalog.get_app_details called %d times, want 1", len(calls))
+	}
+	if info, _ := decodeResult(t, result)["catalog_cache"].(map[string]interface{}); info["source"] != "cache" {
+		t.Errorf("catalog_cache = %v, want source cache", info)
+	}
+
+	// A new TrueNAS release misses the cache; an unreachable catalog falls back to the old copy
+	server.SetResult("system.info", map[string]interface{}{"version": "25.10.0"})
+	server.SetError("catalog.get_app_details", 14, "catalog unavailable")
+	result, err = registry.CallTool("get_app_catalog_details", args)
+	if err != nil {
+		t.Fatalf("get_app_catalog_details with the catalog down failed: %v", err)
+	}
+	info, _ := decodeResult(t, result)["catalog_cache"].(map[string]interface{})
+	if info["source"] != "stale_cache" || info["system_version"] != "25.04.1" {
+		t.Errorf("catalog_cache = %v, want the stale 25.04.1 copy", info)
+	}
+
+	result, err = registry.CallTool("refresh_catalog_cache", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("refresh_catalog_cache failed: %v", err)
+	}
+	if removed := decodeResult(t, result)["removed"]; removed != float64(1) {
+		t.Errorf("removed = %v, want 1", removed)
+	}
+	if _, err := registry.CallTool("get_app_catalog_details", args); err == nil {
+		t.Errorf("get_app_catalog_details succeeded with an empty cache and the catalog down")
+	}
+}
@@ -0,0 +1,558 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Cloud sync task management (cloudsync.*)
+
+var (
+	cloudSyncDirections    = map[string]bool{"PUSH": true, "PULL": true}
+	cloudSyncTransferModes = map[string]bool{"SYNC": true, "COPY": true, "MOVE": true}
+)
+
+// cloudCredentialPublicFields are credential attributes shown as-is; all
+// others are reported by name only
+var cloudCredentialPublicFields = map[string]bool{
+	"endpoint": true,
+	"region":   true,
+}
+
+// cloudCredentialProvider returns a credential's provider and attributes. The
+// provider is a string with separate attributes before TrueNAS 24.10 and an
+// object holding both after.
+func cloudCredentialProvider(cred map[string]interface{}) (string, map[string]interface{}) {
+	if provider, ok := cred["provider"].(map[string]interface{}); ok {
+		attributes := map[string]interface{}{}
+		for k, v := range provider {
+			if k != "type" {
+				attributes[k] = v
+			}
+		}
+		name, _ := provider["type"].(string)
+		return name, attributes
+	}
+	name, _ := cred["provider"].(string)
+	attributes, _ := cred["attributes"].(map[string]interface{})
+	return name, attributes
+}
+
+// simplifyCloudCredential summarizes a credential without its secrets
+func simplifyCloudCredential(cred map[string]interface{}) map[string]interface{} {
+	provider, attributes := cloudCredentialProvider(cred)
+	configured := []string{}
+	summary := map[string]interface{}{
+		"id":       cred["id"],
+		"name":     cred["name"],
+		"provider": provider,
+	}
+	for field, value := range attributes {
+		if cloudCredentialPublicFields[field] {
+			summary[field] = value
+		}
+		configured = append(configured, field)
+	}
+	sort.Strings(configured)
+	summary["configured_fields"] = configured
+	return summary
+}
+
+// getCloudCredential returns a cloud credential by ID
+func getCloudCredential(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("cloudsync.credentials.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cloud credentials: %w", err)
+	}
+
+	var creds []map[string]interface{}
+	if err := json.Unmarshal(result, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse cloud credentials: %w", err)
+	}
+	if len(creds) == 0 {
+		return nil, newToolError(ErrorNotFound, "cloud credential with id %d not found", id)
+	}
+	return creds[0], nil
+}
+
+// getCloudSyncTask returns a cloud sync task by ID
+func getCloudSyncTask(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("cloudsync.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cloud sync task: %w", err)
+	}
+
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse cloud sync tasks: %w", err)
+	}
+	if len(tasks) == 0 {
+		return nil, newToolError(ErrorNotFound, "cloud sync task with id %d not found", id)
+	}
+	return tasks[0], nil
+}
+
+// cloudSyncLastRun summarizes the task's most recent job
+func cloudSyncLastRun(task map[string]interface{}, clock nasClock) map[string]interface{} {
+	job, ok := task["job"].(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"status": "NEVER_RUN"}
+	}
+
+	lastRun := map[string]interface{}{
+		"status": job["state"],
+		"job_id": job["id"],
+	}
+	if started, ok := middlewareTime(job["time_started"]); ok {
+		lastRun["started"] = clock.format(started)
+	}
+	if finished, ok := middlewareTime(job["time_finished"]); ok {
+		lastRun["finished"] = clock.format(finished)
+	}
+	if msg, ok := job["error"].(string); ok && msg != "" {
+		lastRun["error"] = msg
+	}
+	if job["state"] == "RUNNING" {
+		if progress, ok := job["progress"].(map[string]interface{}); ok {
+			lastRun["progress"] = progress["percent"]
+			lastRun["progress_description"] = progress["description"]
+		}
+	}
+	return lastRun
+}
+
+// cloudSyncTransferHuman explains what a direction and transfer mode do
+func cloudSyncTransferHuman(direction, mode string) string {
+	from, to := "the local path", "the remote"
+	if direction == "PULL" {
+		from, to = "the remote", "the local path"
+	}
+	switch mode {
+	case "SYNC":
+		return fmt.Sprintf("Make %s match %s, deleting files in %s that are not in %s", to, from, to, from)
+	case "COPY":
+		return fmt.Sprintf("Copy new and changed files from %s to %s; nothing is deleted", from, to)
+	case "MOVE":
+		return fmt.Sprintf("Copy files from %s to %s, then delete them from %s", from, to, from)
+	}
+	return mode
+}
+
+func simplifyCloudSyncTask(task map[string]interface{}, clock nasClock) map[string]interface{} {
+	direction, _ := task["direction"].(string)
+	mode, _ := task["transfer_mode"].(string)
+	scheduleObj, _ := task["schedule"].(map[string]interface{})
+	enabled, _ := task["enabled"].(bool)
+	attributes, _ := task["attributes"].(map[string]interface{})
+
+	nextRun := "disabled"
+	if enabled && scheduleObj != nil {
+		nextRun = calculateNextRun(scheduleObj, clock.now())
+	}
+
+	simplified := map[string]interface{}{
+		"id":             task["id"],
+		"description":    task["description"],
+		"enabled":        enabled,
+		"path":           task["path"],
+		"direction":      direction,
+		"transfer_mode":  mode,
+		"transfer":       cloudSyncTransferHuman(direction, mode),
+		"bucket":         attributes["bucket"],
+		"folder":         attributes["folder"],
+		"schedule_human": formatCronSchedule(scheduleObj),
+		"next_run":       nextRun,
+		"snapshot":       task["snapshot"],
+		"encryption":     task["encryption"],
+		"last_run":       cloudSyncLastRun(task, clock),
+	}
+	switch creds := task["credentials"].(type) {
+	case map[string]interface{}:
+		provider, _ := cloudCredentialProvider(creds)
+		simplified["credentials"] = map[string]interface{}{"id": creds["id"], "name": creds["name"], "provider": provider}
+	default:
+		simplified["credentials"] = creds
+	}
+	if locked, _ := task["locked"].(bool); locked {
+		simplified["locked"] = true
+	}
+	return simplified
+}
+
+func handleListCloudCredentials(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	result, err := client.Call("cloudsync.credentials.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query cloud credentials: %w", err)
+	}
+
+	var creds []map[string]interface{}
+	if err := json.Unmarshal(result, &creds); err != nil {
+		return "", fmt.Errorf("failed to parse cloud credentials: %w", err)
+	}
+
+	providerFilter, _ := args["provider"].(string)
+	summaries := []map[string]interface{}{}
+	for _, cred := range creds {
+		summary := simplifyCloudCredential(cred)
+		if providerFilter != "" && !strings.EqualFold(summary["provider"].(string), providerFilter) {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"credentials": summaries,
+		"count":       len(summaries),
+		"note":        "Secrets are never returned; configured_fields lists which attributes are set",
+	})
+}
+
+func handleQueryCloudSyncTasks(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	result, err := client.Call("cloudsync.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query cloud sync tasks: %w", err)
+	}
+
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return "", fmt.Errorf("failed to parse cloud sync tasks: %w", err)
+	}
+
+	enabledOnly, _ := args["enabled_only"].(bool)
+	failedOnly, _ := args["failed_only"].(bool)
+
+	filtered := []map[string]interface{}{}
+	failed := 0
+	clock := getNASClock(client)
+	for _, task := range tasks {
+		simplified := simplifyCloudSyncTask(task, clock)
+		isFailed := simplified["last_run"].(map[string]interface{})["status"] == "FAILED"
+		if isFailed {
+			failed++
+		}
+		if enabledOnly && simplified["enabled"] != true {
+			continue
+		}
+		if failedOnly && !isFailed {
+			continue
+		}
+		filtered = append(filtered, simplified)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"cloud_sync_tasks": filtered,
+		"count":            len(filtered),
+		"failed_last_run":  failed,
+		"timezone":         clock.info(),
+	})
+}
+
+// cloudSyncTaskCreate builds the cloudsync.create payload from args
+func cloudSyncTaskCreate(args map[string]interface{}) (map[string]interface{}, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if !strings.HasPrefix(path, "/mnt/") || strings.Contains(path, "//") {
+		return nil, newToolError(ErrorValidation, "path must be a directory under /mnt/ (got: %s)", path)
+	}
+	credID, ok := args["credentials"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("credentials is required (from list_cloud_credentials)")
+	}
+	bucket, _ := args["bucket"].(string)
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+
+	direction := "PUSH"
+	if d, ok := args["direction"].(string); ok && d != "" {
+		direction = strings.ToUpper(d)
+		if !cloudSyncDirections[direction] {
+			return nil, newToolError(ErrorValidation, "direction must be PUSH or PULL")
+		}
+	}
+	mode := "COPY"
+	if m, ok := args["transfer_mode"].(string); ok && m != "" {
+		mode = strings.ToUpper(m)
+		if !cloudSyncTransferModes[mode] {
+			return nil, newToolError(ErrorValidation, "transfer_mode must be SYNC, COPY, or MOVE")
+		}
+	}
+
+	folder, _ := args["folder"].(string)
+	description, _ := args["description"].(string)
+	if description == "" {
+		description = fmt.Sprintf("%s %s to %s", strings.ToLower(mode), path, bucket)
+		if direction == "PULL" {
+			description = fmt.Sprintf("%s %s to %s", strings.ToLower(mode), bucket, path)
+		}
+	}
+
+	create := map[string]interface{}{
+		"description":   description,
+		"path":          path,
+		"credentials":   int(credID),
+		"direction":     direction,
+		"transfer_mode": mode,
+		"attributes":    map[string]interface{}{"bucket": bucket, "folder": folder},
+		"schedule": snapshotTaskSchedule(map[string]interface{}{
+			"minute": "0", "hour": "0", "dom": "*", "month": "*", "dow": "*",
+		}, map[string]interface{}{}),
+		"enabled": true,
+	}
+	if scheduleArg, ok := args["schedule"].(map[string]interface{}); ok {
+		create["schedule"] = snapshotTaskSchedule(create["schedule"].(map[string]interface{}), scheduleArg)
+	}
+	if enabled, ok := args["enabled"].(bool); ok {
+		create["enabled"] = enabled
+	}
+	if snapshot, ok := args["snapshot"].(bool); ok && snapshot {
+		if direction != "PUSH" {
+			return nil, newToolError(ErrorValidation, "snapshot only applies to PUSH tasks")
+		}
+		create["snapshot"] = true
+	}
+	if password, ok := args["encryption_password"].(string); ok && password != "" {
+		create["encryption"] = true
+		create["encryption_password"] = password
+		create["filename_encryption"] = true
+	}
+	return create, nil
+}
+
+// checkCloudSyncPath confirms the local path of a task exists
+func checkCloudSyncPath(client truenas.Caller, path string) error {
+	if _, err := client.Call("filesystem.stat", path); err != nil {
+		return newToolError(ErrorNotFound, "local path %s not found: %v", path, err)
+	}
+	return nil
+}
+
+func handleCreateCloudSyncTask(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := cloudSyncTaskCreate(args)
+	if err != nil {
+		return "", err
+	}
+	if _, err := getCloudCredential(client, create["credentials"].(int)); err != nil {
+		return "", err
+	}
+	if err := checkCloudSyncPath(client, create["path"].(string)); err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("cloudsync.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cloud sync task: %w", err)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	clock := getNASClock(client)
+	task := simplifyCloudSyncTask(created, clock)
+	response := map[string]interface{}{
+		"created": true,
+		"task":    task,
+		"message": fmt.Sprintf("Cloud sync task '%v' created (%v). Next run: %v. Use run_cloud_sync to run it now.", task["description"], task["schedule_human"], task["next_run"]),
+	}
+	if create["encryption"] == true {
+		response["encryption_warning"] = "IMPORTANT: Store the encryption password safely; the remote data cannot be decrypted without it"
+	}
+	return marshalJSON(response)
+}
+
+func (r *Registry) handleRunCloudSync(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	task, err := getCloudSyncTask(client, id)
+	if err != nil {
+		return "", err
+	}
+	if err := checkCloudSyncRunnable(task); err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("cloudsync.sync", id)
+	if err != nil {
+		return "", fmt.Errorf("failed to start cloud sync: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+
+	tracked, err := r.taskManager.CreateJobTask("run_cloud_sync", args, jobID, 24*time.Hour, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"cloud_sync":    task["description"],
+		"job_id":        jobID,
+		"task_id":       tracked.TaskID,
+		"task_status":   tracked.Status,
+		"poll_interval": tracked.PollInterval,
+		"message":       fmt.Sprintf("Cloud sync '%v' started. Track progress with tasks_get using task_id: %s", task["description"], tracked.TaskID),
+	})
+}
+
+// checkCloudSyncRunnable refuses to start a task that is already running or
+// whose local dataset is locked
+func checkCloudSyncRunnable(task map[string]interface{}) error {
+	if job, ok := task["job"].(map[string]interface{}); ok && (job["state"] == "RUNNING" || job["state"] == "WAITING") {
+		return newToolError(ErrorInProgress, "cloud sync task '%v' is already running (job %v)", task["description"], job["id"])
+	}
+	if locked, _ := task["locked"].(bool); locked {
+		return newToolError(ErrorPrecondition, "the dataset of cloud sync task '%v' is locked; unlock it before running", task["description"])
+	}
+	return nil
+}
+
+// Dry-run wrappers
+
+func handleCreateCloudSyncTaskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createCloudSyncTaskDryRun{}, handleCreateCloudSyncTask)
+}
+
+func (r *Registry) handleRunCloudSyncWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &runCloudSyncDryRun{}, r.handleRunCloudSync)
+}
+
+// Dry-run implementations
+
+type createCloudSyncTaskDryRun struct{}
+
+func (c *createCloudSyncTaskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := cloudSyncTaskCreate(args)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := getCloudCredential(client, create["credentials"].(int))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCloudSyncPath(client, create["path"].(string)); err != nil {
+		return nil, err
+	}
+
+	direction := create["direction"].(string)
+	mode := create["transfer_mode"].(string)
+	bucket := create["attributes"].(map[string]interface{})["bucket"].(string)
+
+	warnings := []string{}
+	switch {
+	case mode == "SYNC" && direction == "PULL":
+		warnings = append(warnings, fmt.Sprintf("SYNC deletes local files in %s that are not in the bucket", create["path"]))
+	case mode == "SYNC":
+		warnings = append(warnings, fmt.Sprintf("SYNC deletes files in %s that are not in %s, so local deletions propagate; use COPY or bucket versioning to keep them", bucket, create["path"]))
+	case mode == "MOVE":
+		warnings = append(warnings, "MOVE deletes each file from the source once it is transferred")
+	}
+	if create["encryption"] == true {
+		warnings = append(warnings, "The remote data is encrypted; without the encryption password it cannot be restored")
+	}
+
+	// Listing buckets verifies the credential works; failures are reported
+	// rather than fatal since some providers do not support it
+	if result, err := client.Call("cloudsync.list_buckets", create["credentials"]); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Could not list buckets with this credential: %v", err))
+	} else {
+		var buckets []map[string]interface{}
+		if json.Unmarshal(result, &buckets) == nil {
+			found := false
+			for _, b := range buckets {
+				if b["Name"] == bucket || b["Path"] == bucket {
+					found = true
+				}
+			}
+			if !found {
+				warnings = append(warnings, fmt.Sprintf("Bucket %s was not found with this credential", bucket))
+			}
+		}
+	}
+
+	clock := getNASClock(client)
+	preview := simplifyCloudSyncTask(create, clock)
+	delete(preview, "id")
+	delete(preview, "last_run")
+	preview["credentials"] = simplifyCloudCredential(cred)
+
+	return &DryRunResult{
+		Tool: "create_cloud_sync_task",
+		CurrentState: map[string]interface{}{
+			"credentials": simplifyCloudCredential(cred),
+			"path":        create["path"],
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create cloud sync task '%s'", create["description"]),
+				Operation:   "create",
+				Target:      bucket,
+				Details:     preview,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type runCloudSyncDryRun struct{}
+
+func (d *runCloudSyncDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	task, err := getCloudSyncTask(client, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCloudSyncRunnable(task); err != nil {
+		return nil, err
+	}
+
+	clock := getNASClock(client)
+	simplified := simplifyCloudSyncTask(task, clock)
+	warnings := []string{}
+	if mode, _ := task["transfer_mode"].(string); mode != "COPY" {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", mode, simplified["transfer"]))
+	}
+	if enabled, _ := task["enabled"].(bool); !enabled {
+		warnings = append(warnings, "The task is disabled; it runs now but not on its schedule")
+	}
+
+	return &DryRunResult{
+		Tool: "run_cloud_sync",
+		CurrentState: map[string]interface{}{
+			"task": simplified,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Run cloud sync '%v' now", task["description"]),
+				Operation:   "run",
+				Target:      fmt.Sprintf("%v", task["path"]),
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
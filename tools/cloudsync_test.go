@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationCloudSync(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("cloudsync.credentials.query", []map[string]interface{}{
+		{"id": float64(1), "name": "b2", "provider": map[string]interface{}{"type": "B2", "account": "acct", "key": "b2-application-key"}},
+		{"id": float64(2), "name": "s3", "provider": "S3", "attributes": map[string]interface{}{
+			"access_key_id": "AKIA", "secret_access_key": "s3-secret-value", "endpoint": "s3.example.com"}},
+	})
+	server.SetRecords("cloudsync.query", []map[string]interface{}{
+		{
+			"id": float64(4), "description": "photos", "enabled": true, "path": "/mnt/tank/photos",
+			"direction": "PUSH", "transfer_mode": "SYNC", "encryption": true, "encryption_password": "enc-secret-value",
+			"credentials": map[string]interface{}{"id": float64(1), "name": "b2", "provider": map[string]interface{}{"type": "B2", "key": "b2-application-key"}},
+			"attributes":  map[string]interface{}{"bucket": "offsite", "folder": "photos"},
+			"schedule":    map[string]interface{}{"minute": "0", "hour": "3", "dom": "*", "month": "*", "dow": "*"},
+			"job":         map[string]interface{}{"id": float64(50), "state": "FAILED", "error": "bucket not found"},
+		},
+		{
+			"id": float64(5), "description": "running", "enabled": true, "path": "/mnt/tank/docs",
+			"direction": "PUSH", "transfer_mode": "COPY", "credentials": float64(2),
+			"job": map[string]interface{}{"id": float64(51), "state": "RUNNING"},
+		},
+	})
+	server.SetResult("filesystem.stat", map[string]interface{}{"type": "DIRECTORY"})
+	server.SetResult("cloudsync.list_buckets", []map[string]interface{}{{"Name": "offsite", "Path": "offsite"}})
+	server.HandleJob("cloudsync.sync", truenastest.JobSpec{Steps: 1})
+	server.Handle("cloudsync.create", func(params []interface{}) (interface{}, error) {
+		created, _ := params[0].(map[string]interface{})
+		created["id"] = float64(6)
+		return created, nil
+	})
+
+	result, err := registry.CallTool("list_cloud_credentials", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list_cloud_credentials failed: %v", err)
+	}
+	for _, secret := range []string{"b2-application-key", "s3-secret-value", "AKIA"} {
+		if strings.Contains(result, secret) {
+			t.Errorf("list_cloud_credentials leaked %q: %s", secret, result)
+		}
+	}
+	creds := decodeResult(t, result)["credentials"].([]interface{})
+	s3 := creds[1].(map[string]interface{})
+	if s3["provider"] != "S3" || s3["endpoint"] != "s3.example.com" || !strings.Contains(fmt.Sprint(s3["configured_fields"]), "secret_access_key") {
+		t.Errorf("s3 credential = %v", s3)
+	}
+
+	result, err = registry.CallTool("query_cloud_sync_tasks", map[string]interface{}{"failed_only": true})
+	if err != nil {
+		t.Fatalf("query_cloud_sync_tasks failed: %v", err)
+	}
+	if strings.Contains(result, "enc-secret-value") || strings.Contains(result, "b2-application-key") {
+		t.Errorf("query_cloud_sync_tasks leaked a secret: %s", result)
+	}
+	tasks := decodeResult(t, result)["cloud_sync_tasks"].([]interface{})
+	if len(tasks) != 1 {
+		t.Fatalf("cloud_sync_tasks = %v, want only the failed task", tasks)
+	}
+	photos := tasks[0].(map[string]interface{})
+	lastRun := photos["last_run"].(map[string]interface{})
+	if photos["bucket"] != "offsite" || lastRun["status"] != "FAILED" || lastRun["error"] != "bucket not found" {
+		t.Errorf("photos = %v", photos)
+	}
+
+	create := map[string]interface{}{
+		"path":          "/mnt/tank/photos",
+		"credentials":   float64(1),
+		"bucket":        "offsite",
+		"transfer_mode": "sync",
+		"dry_run":       true,
+	}
+	result, err = registry.CallTool("create_cloud_sync_task", create)
+	if err != nil {
+		t.Fatalf("create_cloud_sync_task dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "SYNC deletes files in offsite") || len(server.Calls("cloudsync.create")) != 0 {
+		t.Errorf("create dry run = %s", result)
+	}
+	delete(create, "dry_run")
+	if _, err := registry.CallTool("create_cloud_sync_task", create); err != nil {
+		t.Fatalf("create_cloud_sync_task failed: %v", err)
+	}
+	payload := server.Calls("cloudsync.create")[0].Params[0].(map[string]interface{})
+	if payload["transfer_mode"] != "SYNC" || payload["direction"] != "PUSH" || fmt.Sprint(payload["attributes"]) != "map[bucket:offsite folder:]" {
+		t.Errorf("cloudsync.create payload = %v", payload)
+	}
+
+	if _, err := registry.CallTool("create_cloud_sync_task", map[string]interface{}{
+		"path": "/etc", "credentials": float64(1), "bucket": "offsite",
+	}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("path outside /mnt error = %v, want VALIDATION", err)
+	}
+	if _, err := registry.CallTool("create_cloud_sync_task", map[string]interface{}{
+		"path": "/mnt/tank/photos", "credentials": float64(9), "bucket": "offsite",
+	}); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown credential error = %v, want NOT_FOUND", err)
+	}
+
+	if _, err := registry.CallTool("run_cloud_sync", map[string]interface{}{"id": float64(5)}); ClassifyError(err).Code != ErrorInProgress {
+		t.Errorf("running task error = %v, want IN_PROGRESS", err)
+	}
+	result, err = registry.CallTool("run_cloud_sync", map[string]interface{}{"id": float64(4)})
+	if err != nil {
+		t.Fatalf("run_cloud_sync failed: %v", err)
+	}
+	run := decodeResult(t, result)
+	if run["task_id"] == nil || run["job_id"] == nil {
+		t.Errorf("run_cloud_sync = %v", run)
+	}
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/truenas/truenas-mcp/compliance"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Desired-state baseline and compliance checking
+
+// parseBaselineRules decodes the rules argument into baseline rules
+func parseBaselineRules(args map[string]interface{}) ([]compliance.Rule, error) {
+	raw, ok := args["rules"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("rules is required and must be a non-empty array")
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var rules []compliance.Rule
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *Registry) handleSaveComplianceBaseline(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.complianceStore == nil {
+		return "", fmt.Errorf("compliance baselines are not enabled on this server")
+	}
+
+	rules, err := parseBaselineRules(args)
+	if err != nil {
+		return "", err
+	}
+	baseline := &compliance.Baseline{Rules: rules, SavedAt: time.Now().UTC()}
+	baseline.Description, _ = args["description"].(string)
+	if err := baseline.Validate(); err != nil {
+		return "", err
+	}
+
+	replaced := r.complianceStore.Baseline() != nil
+	if err := r.complianceStore.Set(baseline); err != nil {
+		return "", fmt.Errorf("baseline saved for this session but not persisted: %w", err)
+	}
+
+	message := fmt.Sprintf("Saved baseline with %d rules. Run check_compliance to validate the system against it.", len(rules))
+	if replaced {
+		message = fmt.Sprintf("Replaced the previous baseline with %d rules. Run check_compliance to validate the system against it.", len(rules))
+	}
+	return marshalJSON(map[string]interface{}{
+		"baseline": baseline,
+		"message":  message,
+	})
+}
+
+func (r *Registry) handleGetComplianceBaseline(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.complianceStore == nil {
+		return "", fmt.Errorf("compliance baselines are not enabled on this server")
+	}
+
+	baseline := r.complianceStore.Baseline()
+	if baseline == nil {
+		return marshalJSON(map[string]interface{}{
+			"baseline": nil,
+			"message":  "No baseline saved yet. Define one with save_compliance_baseline.",
+		})
+	}
+	return marshalJSON(map[string]interface{}{"baseline": baseline})
+}
+
+func (r *Registry) handleCheckCompliance(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.complianceStore == nil {
+		return "", fmt.Errorf("compliance baselines are not enabled on this server")
+	}
+
+	baseline := r.complianceStore.Baseline()
+	if baseline == nil {
+		return "", fmt.Errorf("no baseline saved yet; define one with save_compliance_baseline")
+	}
+
+	result := compliance.Check(client, baseline)
+	response := map[string]interface{}{
+		"checked_at":      result.CheckedAt,
+		"compliant":       result.Compliant,
+		"rules_checked":   result.RulesChecked,
+		"rules_total":     len(baseline.Rules),
+		"violation_count": len(result.Violations),
+		"violations":      result.Violations,
+	}
+	if len(result.CollectionNotes) > 0 {
+		response["collection_notes"] = result.CollectionNotes
+	}
+
+	// Violations fixable through MCP tools form a plan for execute_plan
+	steps := []map[string]interface{}{}
+	for _, violation := range result.Violations {
+		if violation.Remediation != nil && violation.Remediation.Tool != "" {
+			steps = append(steps, map[string]interface{}{
+				"tool":       violation.Remediation.Tool,
+				"arguments":  violation.Remediation.Arguments,
+				"on_failure": planOnFailureContinue,
+			})
+		}
+	}
+	if len(steps) > 0 {
+		response["remediation_plan"] = steps
+		response["next_step"] = "Review remediation_plan with the user, preview it with execute_plan dry_run=true, then run it with execute_plan. Violations with manual remediation must be fixed outside these tools."
+	} else if result.Compliant {
+		response["message"] = "The system meets every rule of the baseline"
+	}
+
+	return marshalJSON(response)
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/compliance"
+)
+
+func TestIntegrationCheckCompliance(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	store, err := compliance.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	registry.complianceStore = store
+
+	if _, err := registry.CallTool("check_compliance", map[string]interface{}{}); err == nil {
+		t.Errorf("check_compliance without a baseline should fail")
+	}
+	if _, err := registry.CallTool("save_compliance_baseline", map[string]interface{}{
+		"rules": []interface{}{map[string]interface{}{"type": "snapshot_tasks"}},
+	}); err == nil || !strings.Contains(err.Error(), "dataset is required") {
+		t.Errorf("invalid baseline error = %v, want dataset is required", err)
+	}
+
+	if _, err := registry.CallTool("save_compliance_baseline", map[string]interface{}{
+		"description": "production",
+		"rules": []interface{}{
+			map[string]interface{}{"type": "scrub_schedule"},
+			map[string]interface{}{"type": "snapshot_tasks", "dataset": "tank/critical"},
+			map[string]interface{}{"type": "smart_tests", "test_type": "LONG", "max_interval_days": float64(7)},
+		},
+	}); err != nil {
+		t.Fatalf("save_compliance_baseline failed: %v", err)
+	}
+
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.SetRecords("pool.scrub.query", []map[string]interface{}{})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank"}, {"id": "tank/critical"}, {"id": "tank/critical/db"}})
+	server.SetRecords("pool.snapshottask.query", []map[string]interface{}{{
+		"id": float64(1), "dataset": "tank/critical", "recursive": false, "enabled": true,
+		"schedule": map[string]interface{}{"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*"},
+	}})
+	server.SetRecords("disk.query", []map[string]interface{}{{"name": "sda", "identifier": "{serial}A"}})
+	server.SetError("smart.test.query", 22, "SMART test scheduling is not supported")
+
+	result, err := registry.CallTool("check_compliance", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("check_compliance failed: %v", err)
+	}
+	report := decodeResult(t, result)
+
+	if report["compliant"] != false || report["rules_checked"] != float64(2) {
+		t.Errorf("compliant = %v, rules_checked = %v; want false and 2", report["compliant"], report["rules_checked"])
+	}
+	violations, _ := report["violations"].([]interface{})
+	objects := []string{}
+	for _, v := range violations {
+		objects = append(objects, v.(map[string]interface{})["object"].(string))
+	}
+	if strings.Join(objects, ",") != "tank,tank/critical/db" {
+		t.Errorf("violations on %v, want tank and tank/critical/db", objects)
+	}
+	plan, _ := report["remediation_plan"].([]interface{})
+	if len(plan) != 1 || plan[0].(map[string]interface{})["tool"] != "create_scrub_schedule" {
+		t.Errorf("remediation_plan = %v, want one create_scrub_schedule step", plan)
+	}
+	if notes, _ := report["collection_notes"].([]interface{}); len(notes) != 1 {
+		t.Errorf("collection_notes = %v, want the SMART query failure", notes)
+	}
+
+	// The plan runs as-is through execute_plan
+	server.SetResult("pool.scrub.create", map[string]interface{}{"id": float64(1)})
+	planResult, err := registry.CallTool("execute_plan", map[string]interface{}{"steps": plan, "dry_run": true})
+	if err != nil {
+		t.Fatalf("execute_plan dry run failed: %v", err)
+	}
+	if summary := decodeResult(t, planResult)["summary"].(map[string]interface{}); summary["succeeded"] != float64(1) {
+		t.Errorf("remediation plan dry run = %s", planResult)
+	}
+}
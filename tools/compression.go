@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Compression effectiveness report
+
+const (
+	// defaultCompressionMinSize skips datasets too small for compression to matter
+	defaultCompressionMinSize = units.GiB
+
+	// defaultCompressionReportLimit caps the ranked dataset list
+	defaultCompressionReportLimit = 20
+
+	// compressibleRatio is the ratio above which data is worth stronger compression
+	compressibleRatio = 1.2
+
+	// incompressibleRatio is the ratio below which data barely compresses
+	incompressibleRatio = 1.05
+)
+
+// compressionRatio converts a compression_ratio from simplifyDataset
+// ("1.45", "1.45x", or a number) to a float
+func compressionRatio(v interface{}) float64 {
+	switch ratio := v.(type) {
+	case float64:
+		return ratio
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSuffix(ratio, "x"), 64)
+		if err == nil {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// compressionSavedBytes estimates the space compression saves for data that
+// takes usedBytes on disk at the given ratio
+func compressionSavedBytes(usedBytes int64, ratio float64) int64 {
+	if ratio <= 1 {
+		return 0
+	}
+	return int64(float64(usedBytes) * (ratio - 1))
+}
+
+// compressionSuggestion recommends a compression change for a dataset, or ""
+func compressionSuggestion(algorithm string, ratio float64) string {
+	algorithm = strings.ToUpper(algorithm)
+	switch {
+	case algorithm == "OFF":
+		return "Enable LZ4 compression: it is nearly free on CPU and skips incompressible data automatically"
+	case strings.HasPrefix(algorithm, "LZ4") && ratio >= compressibleRatio:
+		return "Data compresses well; ZSTD typically stores it 10-20% smaller than LZ4 at a modest CPU cost"
+	case strings.HasPrefix(algorithm, "ZSTD") || strings.HasPrefix(algorithm, "GZIP"):
+		if ratio > 0 && ratio < incompressibleRatio {
+			return "Data is effectively incompressible (media, archives, or encrypted files); LZ4 gives the same result with less CPU"
+		}
+	}
+	return ""
+}
+
+func handleCompressionReport(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filters := []interface{}{}
+	if pool, ok := args["pool"].(string); ok && pool != "" {
+		filters = []interface{}{
+			[]interface{}{"name", "^", pool},
+		}
+	}
+
+	limit := defaultCompressionReportLimit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	minSize := int64(defaultCompressionMinSize)
+	if raw, ok := args["min_size"]; ok {
+		size, err := units.ParseSizeValue(raw)
+		if err != nil {
+			return "", fmt.Errorf("min_size: %w", err)
+		}
+		minSize = size
+	}
+
+	result, err := client.Call("pool.dataset.query", filters, map[string]interface{}{
+		"extra": map[string]interface{}{"flat": true, "retrieve_children": false},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to query datasets: %w", err)
+	}
+
+	var datasets []map[string]interface{}
+	if err := json.Unmarshal(result, &datasets); err != nil {
+		return "", fmt.Errorf("failed to parse datasets: %w", err)
+	}
+
+	ranked := []map[string]interface{}{}
+	suggestions := []map[string]interface{}{}
+	poolTotals := map[string]map[string]int64{}
+	for _, ds := range datasets {
+		summary := simplifyDataset(ds)
+		name, _ := summary["name"].(string)
+		used, _ := summary["used_bytes"].(float64)
+		algorithm, _ := summary["compression"].(string)
+		ratio := compressionRatio(summary["compression_ratio"])
+		saved := compressionSavedBytes(int64(used), ratio)
+
+		// Root datasets hold the pool-wide totals, including every child
+		if !strings.Contains(name, "/") {
+			poolTotals[name] = map[string]int64{"used": int64(used), "saved": saved}
+		}
+
+		if int64(used) < minSize {
+			continue
+		}
+
+		entry := map[string]interface{}{
+			"name":              name,
+			"compression":       algorithm,
+			"compression_ratio": ratio,
+			"used":              units.NewSize(int64(used)),
+			"space_saved":       units.NewSize(saved),
+		}
+		ranked = append(ranked, entry)
+
+		if suggestion := compressionSuggestion(algorithm, ratio); suggestion != "" {
+			suggestions = append(suggestions, map[string]interface{}{
+				"dataset":           name,
+				"compression":       algorithm,
+				"compression_ratio": ratio,
+				"used":              units.NewSize(int64(used)),
+				"suggestion":        suggestion,
+			})
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i]["compression_ratio"].(float64) > ranked[j]["compression_ratio"].(float64)
+	})
+	total := len(ranked)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	pools := make([]map[string]interface{}, 0, len(poolTotals))
+	for name, totals := range poolTotals {
+		pools = append(pools, map[string]interface{}{
+			"pool":        name,
+			"used":        units.NewSize(totals["used"]),
+			"space_saved": units.NewSize(totals["saved"]),
+		})
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i]["pool"].(string) < pools[j]["pool"].(string)
+	})
+
+	response := map[string]interface{}{
+		"pools":       pools,
+		"datasets":    ranked,
+		"suggestions": suggestions,
+		"note":        "Space saved is estimated from used space and compression ratio and includes child datasets. Changing the compression property affects only data written afterwards.",
+	}
+	if total > len(ranked) {
+		response["datasets_note"] = fmt.Sprintf("Showing %d of %d datasets of at least %s", len(ranked), total, units.FormatBytes(minSize))
+	}
+
+	return marshalJSON(response)
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressionRatio(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want float64
+	}{
+		{in: "1.45", want: 1.45},
+		{in: "2.10x", want: 2.10},
+		{in: float64(1.3), want: 1.3},
+		{in: nil, want: 0},
+		{in: "n/a", want: 0},
+	}
+	for _, tt := range tests {
+		if got := compressionRatio(tt.in); got != tt.want {
+			t.Errorf("compressionRatio(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompressionSuggestion(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		ratio     float64
+		want      string
+	}{
+		{algorithm: "OFF", ratio: 1.0, want: "Enable LZ4"},
+		{algorithm: "LZ4", ratio: 1.8, want: "ZSTD"},
+		{algorithm: "LZ4", ratio: 1.01, want: ""},
+		{algorithm: "ZSTD", ratio: 1.01, want: "incompressible"},
+		{algorithm: "ZSTD", ratio: 2.0, want: ""},
+	}
+	for _, tt := range tests {
+		got := compressionSuggestion(tt.algorithm, tt.ratio)
+		if tt.want == "" && got != "" {
+			t.Errorf("compressionSuggestion(%s, %v) = %q, want none", tt.algorithm, tt.ratio, got)
+		}
+		if tt.want != "" && !strings.Contains(got, tt.want) {
+			t.Errorf("compressionSuggestion(%s, %v) = %q, want containing %q", tt.algorithm, tt.ratio, got, tt.want)
+		}
+	}
+
+	if saved := compressionSavedBytes(1000, 1.5); saved != 500 {
+		t.Errorf("compressionSavedBytes(1000, 1.5) = %d, want 500", saved)
+	}
+}
+
+func TestIntegrationCompressionReport(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	dataset := func(name, compression, ratio string, used float64) map[string]interface{} {
+		return map[string]interface{}{
+			"id":            name,
+			"name":          name,
+			"pool":          strings.Split(name, "/")[0],
+			"type":          "FILESYSTEM",
+			"compression":   map[string]interface{}{"parsed": compression, "value": compression},
+			"compressratio": map[string]interface{}{"parsed": ratio, "value": ratio + "x"},
+			"used":          map[string]interface{}{"parsed": used, "value": ""},
+		}
+	}
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		dataset("tank", "LZ4", "1.50", float64(100<<30)),
+		dataset("tank/logs", "LZ4", "3.00", float64(10<<30)),
+		dataset("tank/media", "ZSTD", "1.01", float64(80<<30)),
+		dataset("tank/raw", "OFF", "1.00", float64(5<<30)),
+		dataset("tank/tiny", "OFF", "1.00", float64(1<<20)),
+	})
+
+	result, err := registry.CallTool("compression_report", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("compression_report failed: %v", err)
+	}
+	response := decodeResult(t, result)
+
+	datasets, _ := response["datasets"].([]interface{})
+	if len(datasets) != 4 {
+		t.Fatalf("datasets = %d entries, want 4 (tiny skipped)", len(datasets))
+	}
+	if first := datasets[0].(map[string]interface{}); first["name"] != "tank/logs" {
+		t.Errorf("top dataset = %v, want tank/logs", first["name"])
+	}
+
+	suggested := map[string]bool{}
+	suggestions, _ := response["suggestions"].([]interface{})
+	for _, s := range suggestions {
+		suggested[s.(map[string]interface{})["dataset"].(string)] = true
+	}
+	for _, name := range []string{"tank", "tank/logs", "tank/media", "tank/raw"} {
+		if !suggested[name] {
+			t.Errorf("missing suggestion for %s (got %v)", name, suggested)
+		}
+	}
+
+	pools, _ := response["pools"].([]interface{})
+	if len(pools) != 1 {
+		t.Fatalf("pools = %v, want tank", response["pools"])
+	}
+	saved, _ := pools[0].(map[string]interface{})["space_saved"].(map[string]interface{})
+	if saved["bytes"] != float64(50<<30) {
+		t.Errorf("tank space_saved = %v, want %d", saved["bytes"], int64(50<<30))
+	}
+}
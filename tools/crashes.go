@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Core dump and crash reporting
+
+// coredumpSignals names the signals that typically produce core dumps
+var coredumpSignals = map[int]string{
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	11: "SIGSEGV",
+	31: "SIGSYS",
+}
+
+// coredumpTime converts coredumpctl's microsecond timestamp
+func coredumpTime(v interface{}) time.Time {
+	usec, ok := v.(float64)
+	if !ok || usec <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(int64(usec))
+}
+
+// isMiddlewareCrash reports whether a core dump came from middlewared, which
+// runs under Python
+func isMiddlewareCrash(dump map[string]interface{}) bool {
+	exe, _ := dump["exe"].(string)
+	unit, _ := dump["unit"].(string)
+	return strings.Contains(exe, "middlewared") || strings.HasPrefix(unit, "middlewared")
+}
+
+func handleGetCrashReport(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	days := 7
+	if d, ok := args["days"].(float64); ok && d > 0 {
+		days = int(d)
+	}
+	limit := 20
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	result, err := client.Call("system.coredumps")
+	if err != nil {
+		return "", fmt.Errorf("failed to query core dumps: %w", err)
+	}
+
+	var dumps []map[string]interface{}
+	if err := json.Unmarshal(result, &dumps); err != nil {
+		return "", fmt.Errorf("failed to parse core dumps: %w", err)
+	}
+
+	recent := []map[string]interface{}{}
+	byProcess := map[string]int{}
+	middlewareCrashes := 0
+	for _, dump := range dumps {
+		when := coredumpTime(dump["time"])
+		if when.Before(since) {
+			continue
+		}
+
+		exe, _ := dump["exe"].(string)
+		process := path.Base(exe)
+		byProcess[process]++
+		if isMiddlewareCrash(dump) {
+			middlewareCrashes++
+		}
+
+		entry := map[string]interface{}{
+			"time":    when.UTC().Format(time.RFC3339),
+			"process": process,
+			"exe":     exe,
+			"pid":     dump["pid"],
+		}
+		if sig, ok := dump["sig"].(float64); ok {
+			entry["signal"] = int(sig)
+			if name, ok := coredumpSignals[int(sig)]; ok {
+				entry["signal"] = name
+			}
+		}
+		if corefile, ok := dump["corefile"].(string); ok {
+			entry["corefile"] = corefile
+		}
+		if unit, ok := dump["unit"].(string); ok && unit != "" {
+			entry["unit"] = unit
+		}
+		recent = append(recent, entry)
+	}
+
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i]["time"].(string) > recent[j]["time"].(string)
+	})
+	total := len(recent)
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+
+	response := map[string]interface{}{
+		"period_days":        days,
+		"crash_count":        total,
+		"crashes_by_process": byProcess,
+		"middleware_crashes": middlewareCrashes,
+		"crashes":            recent,
+	}
+
+	// Boot time tells whether crashes preceded or followed the last restart
+	if infoResult, err := client.Call("system.info"); err == nil {
+		var info map[string]interface{}
+		if err := json.Unmarshal(infoResult, &info); err == nil {
+			if uptime, ok := info["uptime_seconds"].(float64); ok {
+				response["uptime_seconds"] = int64(uptime)
+				response["last_boot"] = time.Now().Add(-time.Duration(uptime) * time.Second).UTC().Format(time.RFC3339)
+			}
+		}
+	}
+
+	switch {
+	case total == 0:
+		response["assessment"] = fmt.Sprintf("No crashes recorded in the last %d days", days)
+	case middlewareCrashes > 0:
+		response["assessment"] = fmt.Sprintf("middlewared crashed %d times in the last %d days; the API and web UI were unavailable while it restarted. Check list_alerts and consider filing a bug with a debug file", middlewareCrashes, days)
+	default:
+		response["assessment"] = fmt.Sprintf("%d process crashes in the last %d days; repeated crashes of the same process point to a bug or hardware fault (memory errors often show as SIGSEGV/SIGBUS across unrelated processes)", total, days)
+	}
+	if total > len(recent) {
+		response["note"] = fmt.Sprintf("Showing %d most recent of %d crashes", len(recent), total)
+	}
+
+	return marshalJSON(response)
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntegrationGetCrashReport(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	now := time.Now()
+	usec := func(ago time.Duration) float64 { return float64(now.Add(-ago).UnixMicro()) }
+	server.SetResult("system.coredumps", []interface{}{
+		map[string]interface{}{"time": usec(2 * time.Hour), "pid": float64(4100), "sig": float64(11), "exe": "/usr/bin/python3.11", "unit": "middlewared.service", "corefile": "present"},
+		map[string]interface{}{"time": usec(26 * time.Hour), "pid": float64(2200), "sig": float64(6), "exe": "/usr/sbin/smbd", "corefile": "missing"},
+		map[string]interface{}{"time": usec(30 * 24 * time.Hour), "pid": float64(900), "sig": float64(11), "exe": "/usr/sbin/smbd"},
+	})
+	server.SetResult("system.info", map[string]interface{}{"uptime_seconds": float64(3600)})
+
+	result, err := registry.CallTool("get_crash_report", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_crash_report failed: %v", err)
+	}
+	response := decodeResult(t, result)
+
+	if response["crash_count"] != float64(2) || response["middleware_crashes"] != float64(1) {
+		t.Errorf("crash_count = %v, middleware_crashes = %v, want 2 and 1", response["crash_count"], response["middleware_crashes"])
+	}
+	crashes, _ := response["crashes"].([]interface{})
+	if len(crashes) != 2 {
+		t.Fatalf("crashes = %v, want 2 within 7 days", response["crashes"])
+	}
+	if first := crashes[0].(map[string]interface{}); first["signal"] != "SIGSEGV" || first["unit"] != "middlewared.service" {
+		t.Errorf("most recent crash = %v, want middlewared SIGSEGV", first)
+	}
+	if _, ok := response["last_boot"]; !ok {
+		t.Error("missing last_boot")
+	}
+}
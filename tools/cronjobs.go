@@ -0,0 +1,984 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Cron jobs (cronjob) and init/shutdown scripts (initshutdownscript)
+
+// initShutdownWhen lists when an init/shutdown script runs
+var initShutdownWhen = map[string]string{
+	"PREINIT":  "early in boot, before services start",
+	"POSTINIT": "at the end of boot, after services start",
+	"SHUTDOWN": "at shutdown, before services stop",
+}
+
+// getCronJob returns a cron job by ID
+func getCronJob(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("cronjob.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cron job: %w", err)
+	}
+
+	var jobs []map[string]interface{}
+	if err := json.Unmarshal(result, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse cron jobs: %w", err)
+	}
+
+	if len(jobs) == 0 {
+		return nil, newToolError(ErrorNotFound, "cron job with id %d not found", id)
+	}
+
+	return jobs[0], nil
+}
+
+// cronNextRun returns when an enabled schedule runs next. Schedules using
+// steps, lists, or ranges are left to the NAS's cron.
+func cronNextRun(schedule map[string]interface{}, enabled bool, clock nasClock) string {
+	if !enabled {
+		return "disabled"
+	}
+	if !simpleCronSchedule(schedule) {
+		return "see schedule"
+	}
+	return calculateNextRun(schedule, clock.now())
+}
+
+func simplifyCronJob(job map[string]interface{}, clock nasClock) map[string]interface{} {
+	scheduleObj, _ := job["schedule"].(map[string]interface{})
+	enabled, _ := job["enabled"].(bool)
+
+	return map[string]interface{}{
+		"id":             job["id"],
+		"description":    job["description"],
+		"command":        job["command"],
+		"user":           job["user"],
+		"enabled":        enabled,
+		"hide_stdout":    job["stdout"],
+		"hide_stderr":    job["stderr"],
+		"schedule":       scheduleObj,
+		"schedule_human": formatCronSchedule(scheduleObj),
+		"next_run":       cronNextRun(scheduleObj, enabled, clock),
+	}
+}
+
+// cronJobFields validates the settings shared by create and update, copying
+// those present in args into payload
+func cronJobFields(payload map[string]interface{}, args map[string]interface{}) error {
+	if command, ok := args["command"].(string); ok {
+		if strings.TrimSpace(command) == "" {
+			return newToolError(ErrorValidation, "command must not be empty")
+		}
+		payload["command"] = command
+	}
+	if user, ok := args["user"].(string); ok {
+		if user == "" {
+			return newToolError(ErrorValidation, "user must not be empty")
+		}
+		payload["user"] = user
+	}
+	if d, ok := args["description"].(string); ok {
+		payload["description"] = d
+	}
+	if e, ok := args["enabled"].(bool); ok {
+		payload["enabled"] = e
+	}
+	// The middleware's stdout and stderr flags hide the stream rather than
+	// mail it to the user
+	if v, ok := args["hide_stdout"].(bool); ok {
+		payload["stdout"] = v
+	}
+	if v, ok := args["hide_stderr"].(bool); ok {
+		payload["stderr"] = v
+	}
+	return nil
+}
+
+// cronJobSchedule merges cron fields from args over base
+func cronJobSchedule(base map[string]interface{}, scheduleArg map[string]interface{}) map[string]interface{} {
+	schedule := map[string]interface{}{}
+	for k, v := range base {
+		schedule[k] = v
+	}
+	for _, field := range []string{"minute", "hour", "dom", "month", "dow"} {
+		if v, ok := scheduleArg[field].(string); ok && v != "" {
+			schedule[field] = v
+		}
+	}
+	return schedule
+}
+
+// cronJobCreate builds the cronjob.create payload from args
+func cronJobCreate(args map[string]interface{}) (map[string]interface{}, error) {
+	if command, _ := args["command"].(string); command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	if user, _ := args["user"].(string); user == "" {
+		return nil, fmt.Errorf("user is required")
+	}
+	scheduleArg, ok := args["schedule"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schedule is required")
+	}
+
+	create := map[string]interface{}{
+		"description": "",
+		"enabled":     true,
+		"stdout":      true,
+		"stderr":      false,
+		"schedule": cronJobSchedule(map[string]interface{}{
+			"minute": "0", "hour": "0", "dom": "*", "month": "*", "dow": "*",
+		}, scheduleArg),
+	}
+	if err := cronJobFields(create, args); err != nil {
+		return nil, err
+	}
+	return create, nil
+}
+
+// cronJobUpdate builds the cronjob.update payload from the fields present in
+// args. Cron fields not given keep their current values.
+func cronJobUpdate(existing map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if scheduleArg, ok := args["schedule"].(map[string]interface{}); ok {
+		current, _ := existing["schedule"].(map[string]interface{})
+		update["schedule"] = cronJobSchedule(current, scheduleArg)
+	}
+	if err := cronJobFields(update, args); err != nil {
+		return nil, err
+	}
+
+	if len(update) == 0 {
+		return nil, fmt.Errorf("nothing to update: provide command, user, schedule, description, enabled, hide_stdout, or hide_stderr")
+	}
+	return update, nil
+}
+
+// cronJobWarnings flags settings likely to surprise the user
+func cronJobWarnings(job map[string]interface{}) []string {
+	warnings := []string{}
+	scheduleObj, _ := job["schedule"].(map[string]interface{})
+	if minute, _ := scheduleObj["minute"].(string); minute == "*" {
+		warnings = append(warnings, "The schedule runs the command every minute of the hours it matches")
+	}
+	if job["user"] == "root" {
+		warnings = append(warnings, "The command runs as root with full access to the system")
+	}
+	if hideStderr, _ := job["stderr"].(bool); !hideStderr {
+		warnings = append(warnings, fmt.Sprintf("Error output is mailed to %v's email address; set hide_stderr to discard it", job["user"]))
+	}
+	return warnings
+}
+
+func handleQueryCronJobs(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	result, err := client.Call("cronjob.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query cron jobs: %w", err)
+	}
+
+	var jobs []map[string]interface{}
+	if err := json.Unmarshal(result, &jobs); err != nil {
+		return "", fmt.Errorf("failed to parse cron jobs: %w", err)
+	}
+
+	userFilter, _ := args["user"].(string)
+	enabledOnly, _ := args["enabled_only"].(bool)
+
+	filtered := []map[string]interface{}{}
+	clock := getNASClock(client)
+	for _, job := range jobs {
+		enabled, _ := job["enabled"].(bool)
+		if userFilter != "" && job["user"] != userFilter {
+			continue
+		}
+		if enabledOnly && !enabled {
+			continue
+		}
+		filtered = append(filtered, simplifyCronJob(job, clock))
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"cron_jobs": filtered,
+		"count":     len(filtered),
+		"timezone":  clock.info(),
+	})
+}
+
+func handleCreateCronJob(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := cronJobCreate(args)
+	if err != nil {
+		return "", err
+	}
+	if _, err := getUser(client, create["user"].(string)); err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("cronjob.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cron job: %w", err)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	clock := getNASClock(client)
+	job := simplifyCronJob(created, clock)
+	return marshalJSON(map[string]interface{}{
+		"created":  true,
+		"cron_job": job,
+		"timezone": clock.info(),
+		"message":  fmt.Sprintf("Cron job %v created, running as %v (%s). Next run: %s", created["id"], create["user"], job["schedule_human"], job["next_run"]),
+	})
+}
+
+func handleUpdateCronJob(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	existing, err := getCronJob(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	update, err := cronJobUpdate(existing, args)
+	if err != nil {
+		return "", err
+	}
+	if user, ok := update["user"].(string); ok {
+		if _, err := getUser(client, user); err != nil {
+			return "", err
+		}
+	}
+
+	result, err := client.Call("cronjob.update", id, update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update cron job: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	clock := getNASClock(client)
+	job := simplifyCronJob(updated, clock)
+	return marshalJSON(map[string]interface{}{
+		"updated":  true,
+		"cron_job": job,
+		"timezone": clock.info(),
+		"message":  fmt.Sprintf("Cron job %d updated. Next run: %s", id, job["next_run"]),
+	})
+}
+
+func handleDeleteCronJob(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	existing, err := getCronJob(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("cronjob.delete", id); err != nil {
+		return "", fmt.Errorf("failed to delete cron job: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"deleted": true,
+		"id":      id,
+		"command": existing["command"],
+		"message": fmt.Sprintf("Cron job %d deleted. Its command no longer runs on a schedule.", id),
+	})
+}
+
+func (r *Registry) handleRunCronJob(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	job, err := getCronJob(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	// skip_disabled=false: running on request works for disabled jobs too
+	result, err := client.Call("cronjob.run", id, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to run cron job: %w", err)
+	}
+	truenasJobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+
+	tracked, err := r.taskManager.CreateJobTask("run_cron_job", args, truenasJobID, time.Hour, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"cron_job":      id,
+		"command":       job["command"],
+		"job_id":        truenasJobID,
+		"task_id":       tracked.TaskID,
+		"task_status":   tracked.Status,
+		"poll_interval": tracked.PollInterval,
+		"message":       fmt.Sprintf("Cron job %d started as %v. Track progress with tasks_get using task_id: %s", id, job["user"], tracked.TaskID),
+	})
+}
+
+// Init/shutdown scripts
+
+// getInitShutdownScript returns an init/shutdown script by ID
+func getInitShutdownScript(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("initshutdownscript.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query init/shutdown script: %w", err)
+	}
+
+	var scripts []map[string]interface{}
+	if err := json.Unmarshal(result, &scripts); err != nil {
+		return nil, fmt.Errorf("failed to parse init/shutdown scripts: %w", err)
+	}
+
+	if len(scripts) == 0 {
+		return nil, newToolError(ErrorNotFound, "init/shutdown script with id %d not found", id)
+	}
+
+	return scripts[0], nil
+}
+
+func simplifyInitShutdownScript(script map[string]interface{}) map[string]interface{} {
+	when, _ := script["when"].(string)
+	simplified := map[string]interface{}{
+		"id":         script["id"],
+		"type":       script["type"],
+		"when":       when,
+		"when_human": initShutdownWhen[when],
+		"enabled":    script["enabled"],
+		"timeout":    script["timeout"],
+		"comment":    script["comment"],
+	}
+	if script["type"] == "SCRIPT" {
+		simplified["script"] = script["script"]
+	} else {
+		simplified["command"] = script["command"]
+	}
+	return simplified
+}
+
+// initShutdownScriptFields validates the settings shared by create and
+// update, copying those present in args into payload
+func initShutdownScriptFields(payload map[string]interface{}, args map[string]interface{}) error {
+	if t, ok := args["type"].(string); ok {
+		t = strings.ToUpper(t)
+		if t != "COMMAND" && t != "SCRIPT" {
+			return newToolError(ErrorValidation, "type must be COMMAND or SCRIPT")
+		}
+		payload["type"] = t
+	}
+	if command, ok := args["command"].(string); ok {
+		payload["command"] = command
+	}
+	if script, ok := args["script"].(string); ok {
+		if script != "" && !path.IsAbs(script) {
+			return newToolError(ErrorValidation, "script must be an absolute path, got %q", script)
+		}
+		payload["script"] = script
+	}
+	if when, ok := args["when"].(string); ok {
+		when = strings.ToUpper(when)
+		if _, known := initShutdownWhen[when]; !known {
+			return newToolError(ErrorValidation, "when must be PREINIT, POSTINIT, or SHUTDOWN")
+		}
+		payload["when"] = when
+	}
+	if t, ok := args["timeout"].(float64); ok {
+		if t < 1 {
+			return newToolError(ErrorValidation, "timeout must be at least 1 second")
+		}
+		payload["timeout"] = int(t)
+	}
+	if e, ok := args["enabled"].(bool); ok {
+		payload["enabled"] = e
+	}
+	if c, ok := args["comment"].(string); ok {
+		payload["comment"] = c
+	}
+	return nil
+}
+
+// checkInitShutdownScript requires the command or script path the script's
+// type runs
+func checkInitShutdownScript(script map[string]interface{}) error {
+	if script["type"] == "SCRIPT" {
+		if s, _ := script["script"].(string); s == "" {
+			return newToolError(ErrorValidation, "script (an absolute path) is required for type SCRIPT")
+		}
+		return nil
+	}
+	if c, _ := script["command"].(string); strings.TrimSpace(c) == "" {
+		return newToolError(ErrorValidation, "command is required for type COMMAND")
+	}
+	return nil
+}
+
+// initShutdownScriptCreate builds the initshutdownscript.create payload
+func initShutdownScriptCreate(args map[string]interface{}) (map[string]interface{}, error) {
+	if when, _ := args["when"].(string); when == "" {
+		return nil, fmt.Errorf("when is required")
+	}
+	create := map[string]interface{}{
+		"type":    "COMMAND",
+		"command": "",
+		"script":  "",
+		"enabled": true,
+		"timeout": 10,
+		"comment": "",
+	}
+	if _, ok := args["type"]; !ok {
+		if s, _ := args["script"].(string); s != "" {
+			create["type"] = "SCRIPT"
+		}
+	}
+	if err := initShutdownScriptFields(create, args); err != nil {
+		return nil, err
+	}
+	if err := checkInitShutdownScript(create); err != nil {
+		return nil, err
+	}
+	return create, nil
+}
+
+// initShutdownScriptUpdate builds the initshutdownscript.update payload from
+// the fields present in args
+func initShutdownScriptUpdate(existing map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+	if err := initShutdownScriptFields(update, args); err != nil {
+		return nil, err
+	}
+	if len(update) == 0 {
+		return nil, fmt.Errorf("nothing to update: provide type, command, script, when, timeout, enabled, or comment")
+	}
+
+	merged := map[string]interface{}{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	if err := checkInitShutdownScript(merged); err != nil {
+		return nil, err
+	}
+	return update, nil
+}
+
+// initShutdownScriptWarnings flags settings likely to surprise the user
+func initShutdownScriptWarnings(script map[string]interface{}) []string {
+	warnings := []string{}
+	timeout, _ := script["timeout"].(int)
+	if f, ok := script["timeout"].(float64); ok {
+		timeout = int(f)
+	}
+	switch script["when"] {
+	case "PREINIT":
+		warnings = append(warnings, "PREINIT runs before pools are fully available and services start; a script that hangs delays boot by up to its timeout")
+	case "SHUTDOWN":
+		if timeout > 60 {
+			warnings = append(warnings, fmt.Sprintf("Shutdown and reboot can wait up to %d seconds for this script", timeout))
+		}
+	}
+	return warnings
+}
+
+// checkScriptPath warns when a SCRIPT's path does not exist on the NAS
+func checkScriptPath(client truenas.Caller, script map[string]interface{}) ([]string, error) {
+	p, _ := script["script"].(string)
+	if script["type"] != "SCRIPT" || p == "" {
+		return nil, nil
+	}
+	stat, err := statPath(client, p)
+	if err != nil {
+		return nil, err
+	}
+	if stat == nil {
+		return []string{fmt.Sprintf("%s does not exist on the NAS; the script fails until it is created", p)}, nil
+	}
+	return nil, nil
+}
+
+func handleQueryInitShutdownScripts(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	result, err := client.Call("initshutdownscript.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query init/shutdown scripts: %w", err)
+	}
+
+	var scripts []map[string]interface{}
+	if err := json.Unmarshal(result, &scripts); err != nil {
+		return "", fmt.Errorf("failed to parse init/shutdown scripts: %w", err)
+	}
+
+	whenFilter, _ := args["when"].(string)
+	filtered := []map[string]interface{}{}
+	for _, script := range scripts {
+		if whenFilter != "" && !strings.EqualFold(fmt.Sprint(script["when"]), whenFilter) {
+			continue
+		}
+		filtered = append(filtered, simplifyInitShutdownScript(script))
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"init_shutdown_scripts": filtered,
+		"count":                 len(filtered),
+	})
+}
+
+func handleCreateInitShutdownScript(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := initShutdownScriptCreate(args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("initshutdownscript.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create init/shutdown script: %w", err)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"created": true,
+		"script":  simplifyInitShutdownScript(created),
+		"message": fmt.Sprintf("Init/shutdown script %v created; it runs %s", created["id"], initShutdownWhen[create["when"].(string)]),
+	})
+}
+
+func handleUpdateInitShutdownScript(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	scriptID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(scriptID)
+
+	existing, err := getInitShutdownScript(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	update, err := initShutdownScriptUpdate(existing, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("initshutdownscript.update", id, update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update init/shutdown script: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"updated": true,
+		"script":  simplifyInitShutdownScript(updated),
+		"message": fmt.Sprintf("Init/shutdown script %d updated", id),
+	})
+}
+
+func handleDeleteInitShutdownScript(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	scriptID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(scriptID)
+
+	if _, err := getInitShutdownScript(client, id); err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("initshutdownscript.delete", id); err != nil {
+		return "", fmt.Errorf("failed to delete init/shutdown script: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"deleted": true,
+		"id":      id,
+		"message": fmt.Sprintf("Init/shutdown script %d deleted", id),
+	})
+}
+
+// Dry-run wrappers
+
+func handleCreateCronJobWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createCronJobDryRun{}, handleCreateCronJob)
+}
+
+func handleUpdateCronJobWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateCronJobDryRun{}, handleUpdateCronJob)
+}
+
+func handleDeleteCronJobWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteCronJobDryRun{}, handleDeleteCronJob)
+}
+
+func (r *Registry) handleRunCronJobWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &runCronJobDryRun{}, r.handleRunCronJob)
+}
+
+func handleCreateInitShutdownScriptWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createInitShutdownScriptDryRun{}, handleCreateInitShutdownScript)
+}
+
+func handleUpdateInitShutdownScriptWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateInitShutdownScriptDryRun{}, handleUpdateInitShutdownScript)
+}
+
+func handleDeleteInitShutdownScriptWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteInitShutdownScriptDryRun{}, handleDeleteInitShutdownScript)
+}
+
+// Dry-run implementations
+
+type createCronJobDryRun struct{}
+
+func (c *createCronJobDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := cronJobCreate(args)
+	if err != nil {
+		return nil, err
+	}
+	user := create["user"].(string)
+	if _, err := getUser(client, user); err != nil {
+		return nil, err
+	}
+
+	clock := getNASClock(client)
+	preview := simplifyCronJob(create, clock)
+	delete(preview, "id")
+
+	return &DryRunResult{
+		Tool: "create_cron_job",
+		CurrentState: map[string]interface{}{
+			"user":     user,
+			"timezone": clock.info(),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create cron job running as %s", user),
+				Operation:   "create",
+				Target:      create["command"].(string),
+				Details:     preview,
+			},
+		},
+		Warnings: cronJobWarnings(create),
+	}, nil
+}
+
+type updateCronJobDryRun struct{}
+
+func (u *updateCronJobDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	existing, err := getCronJob(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := cronJobUpdate(existing, args)
+	if err != nil {
+		return nil, err
+	}
+	if user, ok := update["user"].(string); ok {
+		if _, err := getUser(client, user); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := map[string]interface{}{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	clock := getNASClock(client)
+	before := simplifyCronJob(existing, clock)
+	after := simplifyCronJob(merged, clock)
+
+	changes := map[string]interface{}{}
+	for _, field := range []string{"command", "user", "description", "enabled"} {
+		if v, ok := update[field]; ok {
+			changes[field] = map[string]interface{}{"old": existing[field], "new": v}
+		}
+	}
+	for field, name := range map[string]string{"stdout": "hide_stdout", "stderr": "hide_stderr"} {
+		if v, ok := update[field]; ok {
+			changes[name] = map[string]interface{}{"old": existing[field], "new": v}
+		}
+	}
+	if _, ok := update["schedule"]; ok {
+		changes["schedule"] = map[string]interface{}{"old": before["schedule_human"], "new": after["schedule_human"]}
+	}
+	changes["next_run"] = map[string]interface{}{
+		"old":      before["next_run"],
+		"new":      after["next_run"],
+		"timezone": clock.loc.String(),
+	}
+
+	warnings := cronJobWarnings(merged)
+	oldEnabled, _ := existing["enabled"].(bool)
+	if enabled, ok := update["enabled"].(bool); ok && oldEnabled && !enabled {
+		warnings = append(warnings, "The command does not run on its schedule while the job is disabled")
+	}
+
+	return &DryRunResult{
+		Tool: "update_cron_job",
+		CurrentState: map[string]interface{}{
+			"cron_job": before,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Update cron job %d", id),
+				Operation:   "update",
+				Target:      fmt.Sprintf("%v", existing["command"]),
+				Details:     changes,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type deleteCronJobDryRun struct{}
+
+func (d *deleteCronJobDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	existing, err := getCronJob(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := getNASClock(client)
+	return &DryRunResult{
+		Tool: "delete_cron_job",
+		CurrentState: map[string]interface{}{
+			"cron_job": simplifyCronJob(existing, clock),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Delete cron job %d", id),
+				Operation:   "delete",
+				Target:      fmt.Sprintf("%v", existing["command"]),
+			},
+		},
+		Warnings: []string{
+			"The command no longer runs on its schedule; consider disabling the job with update_cron_job instead",
+		},
+	}, nil
+}
+
+type runCronJobDryRun struct{}
+
+func (d *runCronJobDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	existing, err := getCronJob(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := getNASClock(client)
+	warnings := []string{
+		fmt.Sprintf("Runs the command now as %v, in addition to its scheduled runs", existing["user"]),
+	}
+	if enabled, _ := existing["enabled"].(bool); !enabled {
+		warnings = append(warnings, "The job is disabled; it runs once now but stays disabled")
+	}
+
+	return &DryRunResult{
+		Tool: "run_cron_job",
+		CurrentState: map[string]interface{}{
+			"cron_job": simplifyCronJob(existing, clock),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Run cron job %d now", id),
+				Operation:   "run",
+				Target:      fmt.Sprintf("%v", existing["command"]),
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type createInitShutdownScriptDryRun struct{}
+
+func (c *createInitShutdownScriptDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := initShutdownScriptCreate(args)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := initShutdownScriptWarnings(create)
+	pathWarnings, err := checkScriptPath(client, create)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, pathWarnings...)
+
+	preview := simplifyInitShutdownScript(create)
+	delete(preview, "id")
+	target, _ := create["command"].(string)
+	if create["type"] == "SCRIPT" {
+		target = create["script"].(string)
+	}
+
+	return &DryRunResult{
+		Tool:         "create_init_shutdown_script",
+		CurrentState: map[string]interface{}{},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create %s script that runs %s", create["when"], initShutdownWhen[create["when"].(string)]),
+				Operation:   "create",
+				Target:      target,
+				Details:     preview,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type updateInitShutdownScriptDryRun struct{}
+
+func (u *updateInitShutdownScriptDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	scriptID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(scriptID)
+
+	existing, err := getInitShutdownScript(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := initShutdownScriptUpdate(existing, args)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]interface{}{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+
+	changes := map[string]interface{}{}
+	for field, v := range update {
+		changes[field] = map[string]interface{}{"old": existing[field], "new": v}
+	}
+
+	warnings := initShutdownScriptWarnings(merged)
+	pathWarnings, err := checkScriptPath(client, merged)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, pathWarnings...)
+
+	return &DryRunResult{
+		Tool: "update_init_shutdown_script",
+		CurrentState: map[string]interface{}{
+			"script": simplifyInitShutdownScript(existing),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Update init/shutdown script %d", id),
+				Operation:   "update",
+				Target:      fmt.Sprintf("%d", id),
+				Details:     changes,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type deleteInitShutdownScriptDryRun struct{}
+
+func (d *deleteInitShutdownScriptDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	scriptID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(scriptID)
+
+	existing, err := getInitShutdownScript(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	when, _ := existing["when"].(string)
+	return &DryRunResult{
+		Tool: "delete_init_shutdown_script",
+		CurrentState: map[string]interface{}{
+			"script": simplifyInitShutdownScript(existing),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Delete init/shutdown script %d", id),
+				Operation:   "delete",
+				Target:      fmt.Sprintf("%d", id),
+			},
+		},
+		Warnings: []string{
+			fmt.Sprintf("It no longer runs %s; consider disabling it with update_init_shutdown_script instead", initShutdownWhen[when]),
+		},
+	}, nil
+}
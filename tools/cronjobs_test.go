@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationCronJobs(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("cronjob.query", []map[string]interface{}{
+		{"id": float64(1), "command": "/mnt/tank/scripts/backup.sh", "user": "root", "enabled": true, "stdout": true, "stderr": false,
+			"schedule": map[string]interface{}{"minute": "30", "hour": "2", "dom": "*", "month": "*", "dow": "*"}},
+		{"id": float64(2), "command": "touch /tmp/heartbeat", "user": "alice", "enabled": true, "stdout": true, "stderr": true,
+			"schedule": map[string]interface{}{"minute": "*/5", "hour": "*", "dom": "*", "month": "*", "dow": "*"}},
+	})
+	server.SetRecords("user.query", []map[string]interface{}{{"id": float64(70), "username": "alice"}})
+	server.SetRecords("initshutdownscript.query", []map[string]interface{}{
+		{"id": float64(3), "type": "COMMAND", "command": "zpool import -a", "script": "", "when": "POSTINIT", "enabled": true, "timeout": float64(10), "comment": ""},
+	})
+	server.Handle("filesystem.stat", func(params []interface{}) (interface{}, error) {
+		return nil, &truenastest.Error{Code: 2, Message: "not found", ErrName: "ENOENT"}
+	})
+	server.HandleJob("cronjob.run", truenastest.JobSpec{Steps: 1})
+	for _, method := range []string{"cronjob.create", "initshutdownscript.create"} {
+		server.Handle(method, func(params []interface{}) (interface{}, error) {
+			created, _ := params[0].(map[string]interface{})
+			created["id"] = float64(9)
+			return created, nil
+		})
+	}
+	server.Handle("cronjob.update", func(params []interface{}) (interface{}, error) {
+		updated := map[string]interface{}{"id": params[0]}
+		for k, v := range params[1].(map[string]interface{}) {
+			updated[k] = v
+		}
+		return updated, nil
+	})
+
+	result, err := registry.CallTool("query_cron_jobs", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_cron_jobs failed: %v", err)
+	}
+	jobs := decodeResult(t, result)["cron_jobs"].([]interface{})
+	backup, heartbeat := jobs[0].(map[string]interface{}), jobs[1].(map[string]interface{})
+	if backup["schedule_human"] != "Daily at 2:30" || !strings.Contains(fmt.Sprint(backup["next_run"]), "T02:30:00") {
+		t.Errorf("backup job = %v", backup)
+	}
+	if heartbeat["schedule_human"] != "Custom: */5 * * * *" || heartbeat["next_run"] != "see schedule" {
+		t.Errorf("heartbeat job = %v", heartbeat)
+	}
+
+	create := map[string]interface{}{
+		"command":  "/mnt/tank/scripts/report.sh",
+		"user":     "alice",
+		"schedule": map[string]interface{}{"hour": "6", "dow": "1"},
+		"dry_run":  true,
+	}
+	result, err = registry.CallTool("create_cron_job", create)
+	if err != nil {
+		t.Fatalf("create_cron_job dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Weekly on Monday at 6:0") || len(server.Calls("cronjob.create")) != 0 {
+		t.Errorf("create dry run = %s", result)
+	}
+	delete(create, "dry_run")
+	if _, err := registry.CallTool("create_cron_job", create); err != nil {
+		t.Fatalf("create_cron_job failed: %v", err)
+	}
+	payload := server.Calls("cronjob.create")[0].Params[0].(map[string]interface{})
+	if payload["stdout"] != true || payload["stderr"] != false || fmt.Sprint(payload["schedule"]) != "map[dom:* dow:1 hour:6 minute:0 month:*]" {
+		t.Errorf("cronjob.create payload = %v", payload)
+	}
+	create["user"] = "mallory"
+	if _, err := registry.CallTool("create_cron_job", create); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown user error = %v, want NOT_FOUND", err)
+	}
+
+	result, err = registry.CallTool("update_cron_job", map[string]interface{}{"id": float64(1), "schedule": map[string]interface{}{"hour": "4"}, "enabled": false, "dry_run": true})
+	if err != nil {
+		t.Fatalf("update_cron_job dry run failed: %v", err)
+	}
+	details := decodeResult(t, result)["planned_actions"].([]interface{})[0].(map[string]interface{})["details"].(map[string]interface{})
+	schedule := details["schedule"].(map[string]interface{})
+	if schedule["old"] != "Daily at 2:30" || schedule["new"] != "Daily at 4:30" || details["next_run"].(map[string]interface{})["new"] != "disabled" {
+		t.Errorf("update dry run diff = %v", details)
+	}
+	if _, err := registry.CallTool("update_cron_job", map[string]interface{}{"id": float64(1), "hide_stderr": true}); err != nil {
+		t.Fatalf("update_cron_job failed: %v", err)
+	}
+	if update := server.Calls("cronjob.update")[0].Params[1].(map[string]interface{}); len(update) != 1 || update["stderr"] != true {
+		t.Errorf("cronjob.update payload = %v", update)
+	}
+
+	result, err = registry.CallTool("run_cron_job", map[string]interface{}{"id": float64(1)})
+	if err != nil {
+		t.Fatalf("run_cron_job failed: %v", err)
+	}
+	if run := decodeResult(t, result); run["task_id"] == nil || run["job_id"] == nil {
+		t.Errorf("run_cron_job = %v", run)
+	}
+	if calls := server.Calls("cronjob.run"); len(calls) != 1 || fmt.Sprint(calls[0].Params) != "[1 false]" {
+		t.Errorf("cronjob.run calls = %v", calls)
+	}
+
+	if _, err := registry.CallTool("create_init_shutdown_script", map[string]interface{}{"when": "POSTINIT"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("missing command error = %v, want VALIDATION", err)
+	}
+	if _, err := registry.CallTool("create_init_shutdown_script", map[string]interface{}{"when": "POSTINIT", "script": "scripts/up.sh"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("relative script path error = %v, want VALIDATION", err)
+	}
+	script := map[string]interface{}{"when": "shutdown", "script": "/mnt/tank/scripts/down.sh", "timeout": float64(300), "dry_run": true}
+	result, err = registry.CallTool("create_init_shutdown_script", script)
+	if err != nil {
+		t.Fatalf("create_init_shutdown_script dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "does not exist on the NAS") || !strings.Contains(result, "300 seconds") {
+		t.Errorf("create script dry run = %s", result)
+	}
+	delete(script, "dry_run")
+	if _, err := registry.CallTool("create_init_shutdown_script", script); err != nil {
+		t.Fatalf("create_init_shutdown_script failed: %v", err)
+	}
+	if payload := server.Calls("initshutdownscript.create")[0].Params[0].(map[string]interface{}); payload["type"] != "SCRIPT" || payload["when"] != "SHUTDOWN" {
+		t.Errorf("initshutdownscript.create payload = %v", payload)
+	}
+	if _, err := registry.CallTool("update_init_shutdown_script", map[string]interface{}{"id": float64(3), "command": " "}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("blank command error = %v, want VALIDATION", err)
+	}
+}
@@ -1,27 +1,44 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
 )
 
 // handleCreateDataset creates a new ZFS dataset (filesystem or volume)
-func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleCreateDataset(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Extract required parameters
 	name, ok := args["name"].(string)
 	if !ok || name == "" {
 		return "", fmt.Errorf("name is required")
 	}
 
+	// Presets fill recommended properties; explicit arguments take precedence
+	var presetKeys []string
+	if preset, ok := args["preset"].(string); ok && preset != "" {
+		var err error
+		args, presetKeys, err = applyDatasetPreset(args, preset)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	dsType, ok := args["type"].(string)
 	if !ok || dsType == "" {
 		dsType = "FILESYSTEM" // Default to filesystem
 	}
 
+	perms, err := parseDatasetACLPreset(args, dsType)
+	if err != nil {
+		return "", err
+	}
+
 	// Validate dataset name
 	if err := validateDatasetName(name); err != nil {
 		return "", err
@@ -40,11 +57,18 @@ func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (s
 
 	// Handle VOLUME-specific required parameter
 	if dsType == "VOLUME" {
-		volsize, ok := args["volsize"].(float64)
-		if !ok || volsize <= 0 {
-			return "", fmt.Errorf("volsize (in bytes) is required for VOLUME type")
+		rawVolsize, ok := args["volsize"]
+		if !ok {
+			return "", fmt.Errorf("volsize is required for VOLUME type")
 		}
-		payload["volsize"] = int64(volsize)
+		volsize, err := units.ParseSizeValue(rawVolsize)
+		if err != nil {
+			return "", fmt.Errorf("volsize: %w", err)
+		}
+		if volsize <= 0 {
+			return "", fmt.Errorf("volsize must be greater than zero")
+		}
+		payload["volsize"] = volsize
 
 		// Optional volblocksize
 		if volblocksize, ok := args["volblocksize"].(string); ok && volblocksize != "" {
@@ -66,12 +90,18 @@ func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (s
 	}
 
 	// Quota parameters
-	if quota, ok := args["quota"].(float64); ok && quota > 0 {
-		payload["quota"] = int64(quota)
-	}
-
-	if refquota, ok := args["refquota"].(float64); ok && refquota > 0 {
-		payload["refquota"] = int64(refquota)
+	for _, key := range []string{"quota", "refquota"} {
+		raw, ok := args[key]
+		if !ok {
+			continue
+		}
+		size, err := units.ParseSizeValue(raw)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		if size > 0 {
+			payload[key] = size
+		}
 	}
 
 	// Boolean parameters - create_ancestors defaults to true
@@ -102,6 +132,10 @@ func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (s
 		payload["atime"] = atime
 	}
 
+	if recordsize, ok := args["recordsize"].(string); ok && recordsize != "" {
+		payload["recordsize"] = recordsize
+	}
+
 	// Encryption options
 	if encOpts, ok := args["encryption_options"].(map[string]interface{}); ok && len(encOpts) > 0 {
 		if err := validateEncryptionOptions(encOpts); err != nil {
@@ -130,6 +164,16 @@ func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (s
 			"note":           "This is a preview. No dataset has been created.",
 			"next_step":      "Remove dry_run parameter or set to false to execute",
 			"estimated_path": fmt.Sprintf("/mnt/%s", name),
+			"properties":     previewDatasetInheritance(client, name, payload, presetKeys),
+		}
+		if preset, ok := args["preset"].(string); ok && preset != "" {
+			preview["preset"] = preset
+		}
+		if dedup, ok := payload["deduplication"].(string); ok && !strings.EqualFold(dedup, "OFF") {
+			preview["deduplication"] = dedupCreatePreview(client, payload)
+		}
+		if perms != nil {
+			preview["permissions"] = perms.describe(fmt.Sprintf("/mnt/%s", name))
 		}
 
 		formatted, err := json.MarshalIndent(preview, "", "  ")
@@ -172,6 +216,20 @@ func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (s
 		response["encryption_warning"] = "IMPORTANT: Back up your encryption key from Storage > Pools"
 	}
 
+	// The dataset exists at this point, so a permission failure is reported rather than returned
+	if perms != nil {
+		path, _ := dataset["mountpoint"].(string)
+		if path == "" {
+			path = fmt.Sprintf("/mnt/%s", name)
+		}
+		if applied, err := applySharePermissions(client, path, perms); err != nil {
+			response["permissions_error"] = err.Error()
+		} else {
+			response["permissions"] = applied
+			response["note"] = "The ACL is being applied by the permissions job; the dataset is ready to share once it finishes."
+		}
+	}
+
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
 		return "", err
@@ -180,6 +238,45 @@ func handleCreateDataset(client *truenas.Client, args map[string]interface{}) (s
 	return string(formatted), nil
 }
 
+// datasetACLPresets maps apply_acl_preset values to the NFSv4 ACL templates
+// that SMB datasets use
+var datasetACLPresets = map[string]string{
+	"OPEN":       "NFS4_OPEN",
+	"RESTRICTED": "NFS4_RESTRICTED",
+	"HOME":       "NFS4_HOME",
+}
+
+// parseDatasetACLPreset reads apply_acl_preset with the optional owner and
+// group. It returns nil when no preset was requested.
+func parseDatasetACLPreset(args map[string]interface{}, dsType string) (*sharePermissions, error) {
+	preset, _ := args["apply_acl_preset"].(string)
+	if preset == "" {
+		return nil, nil
+	}
+	template, ok := datasetACLPresets[strings.ToUpper(preset)]
+	if !ok {
+		return nil, newToolError(ErrorValidation, "apply_acl_preset must be OPEN, RESTRICTED, or HOME (got: %s)", preset)
+	}
+	if dsType != "FILESYSTEM" {
+		return nil, newToolError(ErrorValidation, "apply_acl_preset only applies to FILESYSTEM datasets")
+	}
+	shareType, _ := args["share_type"].(string)
+	if shareType != "SMB" && shareType != "MULTIPROTOCOL" {
+		return nil, newToolError(ErrorValidation, "apply_acl_preset requires share_type SMB or MULTIPROTOCOL (got: %q); NFS datasets use POSIX permissions", shareType)
+	}
+	if acltype, _ := args["acltype"].(string); acltype != "" && acltype != "NFSV4" && acltype != "INHERIT" {
+		return nil, newToolError(ErrorValidation, "apply_acl_preset sets an NFSv4 ACL and cannot be combined with acltype %s", acltype)
+	}
+
+	owner, _ := args["owner"].(string)
+	group, _ := args["group"].(string)
+	return &sharePermissions{
+		Owner:     strings.TrimSpace(owner),
+		Group:     strings.TrimSpace(group),
+		ACLPreset: template,
+	}, nil
+}
+
 // validateDatasetName validates the dataset name format
 func validateDatasetName(name string) error {
 	if name == "" {
@@ -0,0 +1,330 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Reverse lookup of what uses a dataset or path
+
+// How a consumer's path relates to the one looked up
+const (
+	consumerRelationSelf     = "self"     // Exactly the dataset or path
+	consumerRelationInside   = "inside"   // Within it or a child dataset
+	consumerRelationAncestor = "ancestor" // A parent, so it also covers the target
+)
+
+// datasetConsumerPath returns the /mnt path a dataset name stands for, so
+// dataset-based consumers (zvols, tasks) compare like path-based ones
+func datasetConsumerPath(name string) string {
+	return "/mnt/" + strings.Trim(name, "/")
+}
+
+// consumerRelation relates a consumer's path to the target path, or returns
+// "" when they are unrelated
+func consumerRelation(consumer, target string) string {
+	consumer = strings.TrimSuffix(consumer, "/")
+	target = strings.TrimSuffix(target, "/")
+	switch {
+	case consumer == "":
+		return ""
+	case consumer == target:
+		return consumerRelationSelf
+	case strings.HasPrefix(consumer, target+"/"):
+		return consumerRelationInside
+	case strings.HasPrefix(target, consumer+"/"):
+		return consumerRelationAncestor
+	}
+	return ""
+}
+
+// zvolDatasetName converts a zvol device path ("/dev/zvol/tank/vm",
+// "zvol/tank/vm") to its dataset name, or "" for other paths
+func zvolDatasetName(path string) string {
+	path = strings.TrimPrefix(path, "/dev/")
+	if !strings.HasPrefix(path, "zvol/") {
+		return ""
+	}
+	return strings.TrimPrefix(path, "zvol/")
+}
+
+// consumerFinder collects the consumers of one target
+type consumerFinder struct {
+	client    truenas.Caller
+	target    string // /mnt path looked up
+	dataset   string // Dataset containing the target
+	consumers []map[string]interface{}
+	notes     []string
+}
+
+// add records a consumer at path when it relates to the target. Tasks on an
+// ancestor dataset only cover the target when they are recursive or the
+// target lies in that same dataset.
+func (f *consumerFinder) add(kind, path string, recursive bool, details map[string]interface{}) {
+	relation := consumerRelation(path, f.target)
+	if relation == "" {
+		return
+	}
+	if relation == consumerRelationAncestor && !recursive && path != datasetConsumerPath(f.dataset) {
+		return
+	}
+	consumer := map[string]interface{}{
+		"type":     kind,
+		"path":     path,
+		"relation": relation,
+	}
+	for k, v := range details {
+		consumer[k] = v
+	}
+	f.consumers = append(f.consumers, consumer)
+}
+
+// query runs a best-effort query; sources that cannot be read become notes
+func (f *consumerFinder) query(section, method string, params ...interface{}) []map[string]interface{} {
+	records, err := inventoryQuery(f.client, method, params...)
+	if err != nil {
+		f.notes = append(f.notes, fmt.Sprintf("%s not checked: %v", section, err))
+		return nil
+	}
+	return records
+}
+
+func (f *consumerFinder) findShares() {
+	for _, share := range f.query("SMB shares", "sharing.smb.query", []interface{}{}) {
+		path, _ := share["path"].(string)
+		f.add("smb_share", path, true, map[string]interface{}{"id": share["id"], "name": share["name"], "enabled": share["enabled"]})
+	}
+	for _, share := range f.query("NFS shares", "sharing.nfs.query", []interface{}{}) {
+		// Older versions export a list of paths
+		paths := stringList(share["paths"])
+		if path, ok := share["path"].(string); ok && path != "" {
+			paths = append(paths, path)
+		}
+		for _, path := range paths {
+			f.add("nfs_share", path, true, map[string]interface{}{"id": share["id"], "enabled": share["enabled"]})
+		}
+	}
+	for _, extent := range f.query("iSCSI extents", "iscsi.extent.query", []interface{}{}) {
+		path, _ := extent["path"].(string)
+		if extent["type"] == "DISK" {
+			disk, _ := extent["disk"].(string)
+			if name := zvolDatasetName(disk); name != "" {
+				path = datasetConsumerPath(name)
+			}
+		}
+		f.add("iscsi_extent", path, false, map[string]interface{}{"id": extent["id"], "name": extent["name"], "enabled": extent["enabled"]})
+	}
+}
+
+func (f *consumerFinder) findApps() {
+	apps := f.query("Apps", "app.query", []interface{}{}, map[string]interface{}{
+		"extra": map[string]interface{}{"retrieve_config": true},
+	})
+	for _, app := range apps {
+		// Configured host paths, plus mounts of running containers
+		paths := map[string]bool{}
+		if config, ok := app["config"].(map[string]interface{}); ok {
+			for _, path := range extractStoragePathsFromValues(config) {
+				paths[path] = true
+			}
+		}
+		if workloads, ok := app["active_workloads"].(map[string]interface{}); ok {
+			volumes, _ := workloads["volumes"].([]interface{})
+			for _, raw := range volumes {
+				volume, _ := raw.(map[string]interface{})
+				if source, ok := volume["source"].(string); ok && strings.HasPrefix(source, "/mnt/") {
+					paths[source] = true
+				}
+			}
+		}
+		for _, path := range mapKeys(paths) {
+			f.add("app", path, true, map[string]interface{}{"name": app["name"], "state": app["state"]})
+		}
+	}
+}
+
+func (f *consumerFinder) findVMs() {
+	for _, vm := range f.query("VMs", "vm.query", []interface{}{}) {
+		devices, _ := vm["devices"].([]interface{})
+		for _, raw := range devices {
+			device, _ := raw.(map[string]interface{})
+			attributes, _ := device["attributes"].(map[string]interface{})
+			// dtype moved into attributes in newer versions
+			dtype, _ := device["dtype"].(string)
+			if dtype == "" {
+				dtype, _ = attributes["dtype"].(string)
+			}
+			path, _ := attributes["path"].(string)
+			if name := zvolDatasetName(path); name != "" {
+				path = datasetConsumerPath(name)
+			}
+			f.add("vm_device", path, false, map[string]interface{}{"vm": vm["name"], "vm_id": vm["id"], "device": dtype})
+		}
+	}
+}
+
+func (f *consumerFinder) findTasks() {
+	for _, task := range f.query("Periodic snapshot tasks", "pool.snapshottask.query", []interface{}{}) {
+		dataset, _ := task["dataset"].(string)
+		recursive, _ := task["recursive"].(bool)
+		f.add("snapshot_task", datasetConsumerPath(dataset), recursive, map[string]interface{}{"id": task["id"], "enabled": task["enabled"]})
+	}
+	for _, task := range f.query("Replication tasks", "replication.query", []interface{}{}) {
+		recursive, _ := task["recursive"].(bool)
+		direction, _ := task["direction"].(string)
+		details := map[string]interface{}{"id": task["id"], "name": task["name"], "enabled": task["enabled"]}
+		// Sources of a pull and the target of a push over SSH are remote
+		if direction != "PULL" {
+			for _, source := range stringList(task["source_datasets"]) {
+				f.add("replication_source", datasetConsumerPath(source), recursive, details)
+			}
+		}
+		if target, ok := task["target_dataset"].(string); ok && (direction == "PULL" || task["transport"] == "LOCAL") {
+			f.add("replication_target", datasetConsumerPath(target), true, details)
+		}
+	}
+	for _, task := range f.query("Cloud sync tasks", "cloudsync.query", []interface{}{}) {
+		path, _ := task["path"].(string)
+		f.add("cloud_sync_task", path, true, map[string]interface{}{"id": task["id"], "description": task["description"], "enabled": task["enabled"]})
+	}
+	for _, task := range f.query("Rsync tasks", "rsynctask.query", []interface{}{}) {
+		path, _ := task["path"].(string)
+		f.add("rsync_task", path, true, map[string]interface{}{"id": task["id"], "description": task["desc"], "enabled": task["enabled"]})
+	}
+}
+
+// findSystemDatasets reports the system dataset and the apps dataset, which
+// live on a pool rather than being configured by path
+func (f *consumerFinder) findSystemDatasets() {
+	if result, err := f.client.Call("systemdataset.config"); err == nil {
+		var config map[string]interface{}
+		if json.Unmarshal(result, &config) == nil {
+			if basename, _ := config["basename"].(string); basename != "" {
+				f.add("system_dataset", datasetConsumerPath(basename), true, map[string]interface{}{"pool": config["pool"]})
+			}
+		}
+	} else {
+		f.notes = append(f.notes, fmt.Sprintf("System dataset not checked: %v", err))
+	}
+	if result, err := f.client.Call("docker.config"); err == nil {
+		var config map[string]interface{}
+		if json.Unmarshal(result, &config) == nil {
+			if dataset, _ := config["dataset"].(string); dataset != "" {
+				f.add("apps_dataset", datasetConsumerPath(dataset), true, map[string]interface{}{"pool": config["pool"]})
+			}
+		}
+	}
+}
+
+// findDatasetConsumers looks up everything using targetPath, a path in
+// dataset, sorted by type and path
+func findDatasetConsumers(client truenas.Caller, dataset, targetPath string) *consumerFinder {
+	finder := &consumerFinder{client: client, target: targetPath, dataset: dataset}
+	finder.findShares()
+	finder.findApps()
+	finder.findVMs()
+	finder.findTasks()
+	finder.findSystemDatasets()
+
+	consumers := finder.consumers
+	sort.SliceStable(consumers, func(i, j int) bool {
+		if consumers[i]["type"] != consumers[j]["type"] {
+			return consumers[i]["type"].(string) < consumers[j]["type"].(string)
+		}
+		return consumers[i]["path"].(string) < consumers[j]["path"].(string)
+	})
+	return finder
+}
+
+// blocking describes the consumers of the target itself or something inside
+// it, which break when it is deleted or renamed
+func (f *consumerFinder) blocking() []string {
+	blocking := []string{}
+	for _, c := range f.consumers {
+		if c["relation"] != consumerRelationAncestor {
+			blocking = append(blocking, consumerSummary(c))
+		}
+	}
+	return blocking
+}
+
+// consumerSummary describes a consumer in a sentence
+func consumerSummary(c map[string]interface{}) string {
+	label := strings.ReplaceAll(c["type"].(string), "_", " ")
+	for _, field := range []string{"name", "vm", "description", "id"} {
+		if v, ok := c[field]; ok && v != nil && v != "" {
+			label = fmt.Sprintf("%s %v", label, v)
+			break
+		}
+	}
+	return fmt.Sprintf("%s (%s)", label, c["path"])
+}
+
+func handleWhatUsesThisDataset(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	target, _ := args["dataset"].(string)
+	if path, ok := args["path"].(string); ok && path != "" {
+		target = path
+	}
+	if target == "" {
+		return "", fmt.Errorf("dataset or path is required")
+	}
+
+	var name, targetPath string
+	if strings.HasPrefix(target, "/") {
+		if !strings.HasPrefix(target, "/mnt/") {
+			return "", newToolError(ErrorValidation, "path must be under /mnt/ (got: %s)", target)
+		}
+		targetPath = strings.TrimSuffix(target, "/")
+		name = strings.TrimPrefix(targetPath, "/mnt/")
+	} else {
+		name = strings.Trim(target, "/")
+		targetPath = datasetConsumerPath(name)
+	}
+
+	// A path inside a dataset is looked up in its nearest dataset
+	ds, err := findDatasetOrAncestor(client, name)
+	if err != nil {
+		return "", err
+	}
+	if ds == nil || (!strings.HasPrefix(target, "/") && ds["name"] != name) {
+		return "", newToolError(ErrorNotFound, "dataset %s not found", name)
+	}
+	dataset, _ := ds["name"].(string)
+
+	finder := findDatasetConsumers(client, dataset, targetPath)
+	consumers := finder.consumers
+
+	byType := map[string]int{}
+	for _, c := range consumers {
+		byType[c["type"].(string)]++
+	}
+	blocking := finder.blocking()
+
+	response := map[string]interface{}{
+		"target":     targetPath,
+		"dataset":    dataset,
+		"is_dataset": dataset == name,
+		"type":       ds["type"],
+		"consumers":  consumers,
+		"count":      len(consumers),
+		"by_type":    byType,
+	}
+	switch {
+	case len(blocking) > 0:
+		response["summary"] = fmt.Sprintf("%d consumer(s) use %s or something inside it; deleting, renaming, or moving it breaks them: %s",
+			len(blocking), targetPath, strings.Join(blocking, "; "))
+	case len(consumers) > 0:
+		response["summary"] = fmt.Sprintf("Nothing uses %s directly; it is covered by %d consumer(s) of a parent path", targetPath, len(consumers))
+	default:
+		response["summary"] = fmt.Sprintf("Nothing found using %s", targetPath)
+	}
+	if len(finder.notes) > 0 {
+		response["notes"] = finder.notes
+	}
+	return marshalJSON(response)
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationWhatUsesThisDataset(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank", "name": "tank", "type": "FILESYSTEM"},
+		{"id": "tank/media", "name": "tank/media", "type": "FILESYSTEM", "mountpoint": "/mnt/tank/media"},
+		{"id": "tank/media/movies", "name": "tank/media/movies", "type": "FILESYSTEM"},
+		{"id": "tank/vm-disk", "name": "tank/vm-disk", "type": "VOLUME"},
+	})
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{
+		{"id": float64(1), "name": "media", "path": "/mnt/tank/media", "enabled": true},
+		{"id": float64(2), "name": "mediaextra", "path": "/mnt/tank/mediaextra", "enabled": true},
+	})
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{
+		{"id": float64(1), "path": "/mnt/tank/media/movies", "enabled": true},
+	})
+	server.SetRecords("iscsi.extent.query", []map[string]interface{}{
+		{"id": float64(1), "name": "lun0", "type": "DISK", "disk": "zvol/tank/vm-disk", "enabled": true},
+	})
+	server.SetRecords("app.query", []map[string]interface{}{
+		{"name": "jellyfin", "state": "RUNNING", "config": map[string]interface{}{
+			"storage": map[string]interface{}{"media": map[string]interface{}{
+				"type": "host_path", "host_path_config": map[string]interface{}{"path": "/mnt/tank/media/movies"},
+			}},
+		}},
+	})
+	server.SetRecords("vm.query", []map[string]interface{}{
+		{"id": float64(1), "name": "win", "devices": []interface{}{
+			map[string]interface{}{"attributes": map[string]interface{}{"dtype": "DISK", "path": "/dev/zvol/tank/vm-disk"}},
+		}},
+	})
+	server.SetRecords("pool.snapshottask.query", []map[string]interface{}{
+		{"id": float64(1), "dataset": "tank", "recursive": true, "enabled": true},
+		{"id": float64(2), "dataset": "tank", "recursive": false, "enabled": true},
+	})
+	server.SetRecords("replication.query", []map[string]interface{}{
+		{"id": float64(1), "name": "offsite", "direction": "PUSH", "transport": "SSH", "recursive": false,
+			"source_datasets": []interface{}{"tank/media"}, "target_dataset": "backup/media", "enabled": true},
+	})
+	server.SetRecords("cloudsync.query", []map[string]interface{}{})
+	server.SetRecords("rsynctask.query", []map[string]interface{}{})
+	server.SetResult("systemdataset.config", map[string]interface{}{"pool": "boot-pool", "basename": "boot-pool/.system"})
+
+	result, err := registry.CallTool("what_uses_this_dataset", map[string]interface{}{"dataset": "tank/media"})
+	if err != nil {
+		t.Fatalf("what_uses_this_dataset failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	byType := response["by_type"].(map[string]interface{})
+	want := map[string]float64{"smb_share": 1, "nfs_share": 1, "app": 1, "snapshot_task": 1, "replication_source": 1}
+	for kind, count := range want {
+		if byType[kind] != count {
+			t.Errorf("by_type[%s] = %v, want %v (%v)", kind, byType[kind], count, byType)
+		}
+	}
+	if len(byType) != len(want) {
+		t.Errorf("by_type = %v, want only %v", byType, want)
+	}
+	for _, raw := range response["consumers"].([]interface{}) {
+		consumer := raw.(map[string]interface{})
+		if consumer["type"] == "snapshot_task" && (consumer["relation"] != "ancestor" || consumer["id"] != float64(1)) {
+			t.Errorf("snapshot consumer = %v, want recursive task 1 on the parent", consumer)
+		}
+	}
+	if summary := response["summary"].(string); !strings.Contains(summary, "4 consumer(s)") {
+		t.Errorf("summary = %q", summary)
+	}
+	if notes, _ := response["notes"].([]interface{}); len(notes) != 0 {
+		t.Errorf("notes = %v, want none", notes)
+	}
+
+	result, err = registry.CallTool("what_uses_this_dataset", map[string]interface{}{"dataset": "tank/vm-disk"})
+	if err != nil {
+		t.Fatalf("what_uses_this_dataset on zvol failed: %v", err)
+	}
+	byType = decodeResult(t, result)["by_type"].(map[string]interface{})
+	if byType["iscsi_extent"] != float64(1) || byType["vm_device"] != float64(1) {
+		t.Errorf("zvol by_type = %v, want iSCSI extent and VM disk", byType)
+	}
+
+	result, err = registry.CallTool("what_uses_this_dataset", map[string]interface{}{"path": "/mnt/tank/media/movies/2020"})
+	if err != nil {
+		t.Fatalf("what_uses_this_dataset on path failed: %v", err)
+	}
+	response = decodeResult(t, result)
+	if response["dataset"] != "tank/media/movies" || response["is_dataset"] != false {
+		t.Errorf("path lookup resolved to %v", response)
+	}
+
+	_, err = registry.CallTool("what_uses_this_dataset", map[string]interface{}{"dataset": "tank/missing"})
+	if err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("missing dataset error = %v, want NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Days until a dataset fills up at which its forecast is flagged
+const (
+	forecastCriticalDays = 30
+	forecastWarningDays  = 90
+)
+
+// handleForecastDatasetGrowth projects when datasets run out of space from
+// the locally recorded dataset usage history
+func (r *Registry) handleForecastDatasetGrowth(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.capacityTracker == nil || !r.capacityTracker.Enabled() {
+		return "", newToolError(ErrorPrecondition, "capacity history tracking is disabled; start the server with --capacity-sample-interval to record dataset usage over time")
+	}
+
+	dataset, _ := args["dataset"].(string)
+	dataset = strings.Trim(dataset, "/")
+	pool, _ := args["pool"].(string)
+	limit := 25
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	forecasts := []map[string]interface{}{}
+	insufficient := []string{}
+	for _, name := range r.capacityTracker.Datasets() {
+		if dataset != "" && name != dataset && !strings.HasPrefix(name, dataset+"/") {
+			continue
+		}
+		if pool != "" && name != pool && !strings.HasPrefix(name, pool+"/") {
+			continue
+		}
+
+		history := r.capacityTracker.DatasetHistory(name)
+		growth := r.capacityTracker.DatasetGrowth(name)
+		if len(history) == 0 || growth == nil {
+			insufficient = append(insufficient, name)
+			continue
+		}
+		forecasts = append(forecasts, datasetForecast(name, history[len(history)-1], growth))
+	}
+
+	// Soonest to fill first; datasets that are not growing go last by name
+	sort.SliceStable(forecasts, func(i, j int) bool {
+		di, iok := forecasts[i]["days_until_full"].(float64)
+		dj, jok := forecasts[j]["days_until_full"].(float64)
+		if iok != jok {
+			return iok
+		}
+		if iok && di != dj {
+			return di < dj
+		}
+		return forecasts[i]["dataset"].(string) < forecasts[j]["dataset"].(string)
+	})
+
+	total := len(forecasts)
+	if len(forecasts) > limit {
+		forecasts = forecasts[:limit]
+	}
+
+	response := map[string]interface{}{
+		"datasets":        forecasts,
+		"dataset_count":   len(forecasts),
+		"total_datasets":  total,
+		"sample_interval": r.capacityTracker.SampleInterval().String(),
+	}
+	if len(insufficient) > 0 {
+		response["insufficient_history"] = insufficient
+		response["note"] = "Datasets need at least two samples spanning an hour before growth can be projected"
+	}
+	return marshalJSON(response)
+}
+
+// datasetForecast summarizes one dataset's growth and when it fills up. The
+// limit is its quota when that is closer than the pool's free space.
+func datasetForecast(name string, latest capacity.Sample, growth *capacity.Growth) map[string]interface{} {
+	forecast := map[string]interface{}{
+		"dataset":              name,
+		"used":                 units.FormatBytes(latest.UsedBytes),
+		"used_bytes":           latest.UsedBytes,
+		"available":            units.FormatBytes(latest.AvailableBytes),
+		"available_bytes":      latest.AvailableBytes,
+		"limited_by":           "pool",
+		"growth_bytes_per_day": growth.GrowthBytesPerDay,
+		"growth_per_day":       units.FormatBytes(int64(growth.GrowthBytesPerDay)),
+		"sample_count":         growth.SampleCount,
+		"observed_days":        growth.ObservedDays,
+		"status":               "healthy",
+	}
+	if latest.QuotaBytes > 0 {
+		forecast["quota"] = units.FormatBytes(latest.QuotaBytes)
+		forecast["quota_bytes"] = latest.QuotaBytes
+		if latest.QuotaBytes-latest.UsedBytes <= latest.AvailableBytes {
+			forecast["limited_by"] = "quota"
+		}
+	}
+
+	if growth.DaysUntilFull == nil {
+		switch {
+		case growth.GrowthBytesPerDay > 0 && latest.AvailableBytes > 0:
+			forecast["projection"] = "Usage is growing too slowly to project (full in more than 100 years)"
+		case growth.GrowthBytesPerDay > 0:
+			forecast["projection"] = "No space left"
+		default:
+			forecast["projection"] = "Usage is not growing"
+		}
+		return forecast
+	}
+
+	days := *growth.DaysUntilFull
+	forecast["days_until_full"] = days
+	forecast["projected_full_date"] = growth.ProjectedFullDate.Format("2006-01-02")
+	if forecast["limited_by"] == "quota" {
+		forecast["projection"] = fmt.Sprintf("Quota exhausted in ~%.0f days at current growth rate", days)
+	} else {
+		forecast["projection"] = fmt.Sprintf("Pool space exhausted in ~%.0f days at current growth rate", days)
+	}
+	switch {
+	case days < forecastCriticalDays:
+		forecast["status"] = "critical"
+	case days < forecastWarningDays:
+		forecast["status"] = "warning"
+	}
+	return forecast
+}
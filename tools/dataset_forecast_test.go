@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/capacity"
+)
+
+func TestIntegrationForecastDatasetGrowth(t *testing.T) {
+	registry, _ := newTestRegistry(t)
+
+	if _, err := registry.CallTool("forecast_dataset_growth", map[string]interface{}{}); ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("forecast without tracking error = %v, want PRECONDITION_FAILED", err)
+	}
+
+	// Three days of history: media grows 1 GiB/day toward its quota, backups
+	// grows 1 GiB/day into the pool, and docs is flat
+	const gib = int64(1 << 30)
+	now := time.Now()
+	series := map[string][]capacity.Sample{}
+	for day := int64(0); day < 3; day++ {
+		at := now.Add(time.Duration(day-2) * 24 * time.Hour)
+		series["tank/media"] = append(series["tank/media"], capacity.Sample{Timestamp: at, UsedBytes: (40 + day) * gib, AvailableBytes: (10 - day) * gib, QuotaBytes: 50 * gib})
+		series["tank/backups"] = append(series["tank/backups"], capacity.Sample{Timestamp: at, UsedBytes: (100 + day) * gib, AvailableBytes: (200 - day) * gib})
+		series["tank/docs"] = append(series["tank/docs"], capacity.Sample{Timestamp: at, UsedBytes: 5 * gib, AvailableBytes: 200 * gib})
+	}
+	series["tank/new"] = []capacity.Sample{{Timestamp: now, UsedBytes: gib, AvailableBytes: 200 * gib}}
+
+	dir := t.TempDir()
+	data, _ := json.Marshal(series)
+	if err := os.WriteFile(filepath.Join(dir, "datasets.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := capacity.NewTracker(nil, capacity.TrackerConfig{
+		SampleInterval: time.Hour,
+		DatasetPath:    filepath.Join(dir, "datasets.json"),
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	registry.capacityTracker = tracker
+
+	result, err := registry.CallTool("forecast_dataset_growth", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("forecast_dataset_growth failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	datasets := response["datasets"].([]interface{})
+	if len(datasets) != 3 {
+		t.Fatalf("forecasts = %v, want 3 datasets", datasets)
+	}
+	media := datasets[0].(map[string]interface{})
+	if media["dataset"] != "tank/media" || media["limited_by"] != "quota" || media["status"] != "critical" {
+		t.Errorf("first forecast = %v, want tank/media limited by its quota", media)
+	}
+	if days := media["days_until_full"].(float64); days < 7.9 || days > 8.1 {
+		t.Errorf("tank/media days until full = %v, want 8", days)
+	}
+	if backups := datasets[1].(map[string]interface{}); backups["dataset"] != "tank/backups" || backups["limited_by"] != "pool" {
+		t.Errorf("second forecast = %v, want tank/backups limited by the pool", backups)
+	}
+	if docs := datasets[2].(map[string]interface{}); docs["dataset"] != "tank/docs" || docs["days_until_full"] != nil {
+		t.Errorf("last forecast = %v, want the flat tank/docs without a projection", docs)
+	}
+	if pending := response["insufficient_history"].([]interface{}); len(pending) != 1 || pending[0] != "tank/new" {
+		t.Errorf("insufficient_history = %v, want [tank/new]", pending)
+	}
+}
@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Dataset creation presets and inheritance preview
+
+// datasetPreset is a named set of recommended create_dataset arguments
+type datasetPreset struct {
+	Description string
+	Properties  map[string]interface{}
+}
+
+var datasetPresets = map[string]datasetPreset{
+	"smb-share": {
+		Description: "Windows/macOS file share: SMB share type with NFSv4 ACLs and LZ4 compression",
+		Properties: map[string]interface{}{
+			"type":        "FILESYSTEM",
+			"share_type":  "SMB",
+			"acltype":     "NFSV4",
+			"compression": "LZ4",
+		},
+	},
+	"nfs-export": {
+		Description: "Unix/Linux NFS export: NFS share type with POSIX ACLs and LZ4 compression",
+		Properties: map[string]interface{}{
+			"type":        "FILESYSTEM",
+			"share_type":  "NFS",
+			"acltype":     "POSIX",
+			"compression": "LZ4",
+		},
+	},
+	"app-config": {
+		Description: "Application configuration and databases: APPS share type, LZ4 compression, small records, atime off",
+		Properties: map[string]interface{}{
+			"type":        "FILESYSTEM",
+			"share_type":  "APPS",
+			"compression": "LZ4",
+			"recordsize":  "16K",
+			"atime":       "OFF",
+		},
+	},
+	"vm-zvol": {
+		Description: "Block device for VMs or iSCSI: VOLUME type with 16K blocks and LZ4 compression (volsize still required)",
+		Properties: map[string]interface{}{
+			"type":         "VOLUME",
+			"volblocksize": "16K",
+			"compression":  "LZ4",
+		},
+	},
+	"media-library": {
+		Description: "Large media files shared over SMB: 1M records, LZ4 compression, atime off",
+		Properties: map[string]interface{}{
+			"type":        "FILESYSTEM",
+			"share_type":  "SMB",
+			"acltype":     "NFSV4",
+			"compression": "LZ4",
+			"recordsize":  "1M",
+			"atime":       "OFF",
+		},
+	},
+}
+
+// inheritableDatasetProperties are the ZFS properties a new dataset takes from
+// its parent unless set explicitly
+var inheritableDatasetProperties = []string{
+	"compression",
+	"acltype",
+	"atime",
+	"recordsize",
+	"deduplication",
+	"checksum",
+	"snapdir",
+	"readonly",
+}
+
+// datasetPresetNames returns the preset names in sorted order
+func datasetPresetNames() []string {
+	names := make([]string, 0, len(datasetPresets))
+	for name := range datasetPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyDatasetPreset returns a copy of args with preset properties filled in
+// where the caller did not set them, plus the keys the preset supplied
+func applyDatasetPreset(args map[string]interface{}, presetName string) (map[string]interface{}, []string, error) {
+	preset, ok := datasetPresets[presetName]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown preset %q (valid: %s)", presetName, strings.Join(datasetPresetNames(), ", "))
+	}
+
+	merged := make(map[string]interface{}, len(args)+len(preset.Properties))
+	for key, value := range args {
+		merged[key] = value
+	}
+
+	applied := []string{}
+	for key, value := range preset.Properties {
+		if _, set := args[key]; set {
+			continue
+		}
+		merged[key] = value
+		applied = append(applied, key)
+	}
+	sort.Strings(applied)
+
+	return merged, applied, nil
+}
+
+// datasetAncestors returns the ancestors of a dataset name, nearest first
+func datasetAncestors(name string) []string {
+	ancestors := []string{}
+	for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name, "/") {
+		name = name[:i]
+		ancestors = append(ancestors, name)
+	}
+	return ancestors
+}
+
+// previewDatasetInheritance reports, for each inheritable property, whether the
+// new dataset will set it explicitly (from an argument or the preset) or inherit
+// it from the nearest existing ancestor, and what that inherited value is
+func previewDatasetInheritance(client truenas.Caller, name string, payload map[string]interface{}, presetKeys []string) map[string]interface{} {
+	fromPreset := map[string]bool{}
+	for _, key := range presetKeys {
+		fromPreset[key] = true
+	}
+
+	preview := map[string]interface{}{}
+	ancestors := datasetAncestors(name)
+
+	var parent map[string]interface{}
+	result, err := client.Call("pool.dataset.query",
+		[]interface{}{
+			[]interface{}{"id", "in", ancestors},
+		},
+		map[string]interface{}{"extra": map[string]interface{}{"retrieve_children": false}},
+	)
+	var found []map[string]interface{}
+	if err != nil {
+		preview["note"] = fmt.Sprintf("Could not look up parent dataset: %v", err)
+	} else if err := json.Unmarshal(result, &found); err != nil {
+		preview["note"] = fmt.Sprintf("Could not parse parent dataset: %v", err)
+	} else {
+		// The nearest existing ancestor is the one the new dataset inherits from
+		byID := map[string]map[string]interface{}{}
+		for _, ds := range found {
+			if id, ok := ds["id"].(string); ok {
+				byID[id] = ds
+			}
+		}
+		for i, ancestor := range ancestors {
+			if ds, ok := byID[ancestor]; ok {
+				parent = ds
+				preview["inherits_from"] = ancestor
+				if i > 0 {
+					preview["ancestors_to_create"] = ancestors[:i]
+				}
+				break
+			}
+		}
+		if parent == nil {
+			preview["note"] = fmt.Sprintf("No existing ancestor found for %s; check the pool name", name)
+		}
+	}
+
+	explicit := map[string]interface{}{}
+	inherited := map[string]interface{}{}
+	for _, prop := range inheritableDatasetProperties {
+		if value, ok := payload[prop]; ok && value != "INHERIT" {
+			source := "argument"
+			if fromPreset[prop] {
+				source = "preset"
+			}
+			explicit[prop] = map[string]interface{}{"value": value, "source": source}
+			continue
+		}
+
+		entry := map[string]interface{}{}
+		if parent != nil {
+			entry["inherited_from"] = parent["id"]
+			if propMap, ok := parent[prop].(map[string]interface{}); ok {
+				if value := propMap["value"]; value != nil {
+					entry["value"] = value
+				} else {
+					entry["value"] = propMap["parsed"]
+				}
+			}
+		}
+		inherited[prop] = entry
+	}
+
+	preview["explicit"] = explicit
+	preview["inherited"] = inherited
+	return preview
+}
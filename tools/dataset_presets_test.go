@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyDatasetPreset(t *testing.T) {
+	args := map[string]interface{}{
+		"name":        "tank/media",
+		"compression": "ZSTD",
+	}
+
+	merged, applied, err := applyDatasetPreset(args, "media-library")
+	if err != nil {
+		t.Fatalf("applyDatasetPreset failed: %v", err)
+	}
+
+	if merged["compression"] != "ZSTD" {
+		t.Errorf("explicit compression overridden: got %v", merged["compression"])
+	}
+	if merged["recordsize"] != "1M" {
+		t.Errorf("recordsize = %v, want 1M", merged["recordsize"])
+	}
+	if _, ok := args["recordsize"]; ok {
+		t.Error("applyDatasetPreset modified the caller's args")
+	}
+
+	want := []string{"acltype", "atime", "recordsize", "share_type", "type"}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+}
+
+func TestApplyDatasetPresetUnknown(t *testing.T) {
+	if _, _, err := applyDatasetPreset(map[string]interface{}{}, "bogus"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestDatasetAncestors(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"tank/a/b/c", []string{"tank/a/b", "tank/a", "tank"}},
+		{"tank/a", []string{"tank"}},
+		{"tank", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := datasetAncestors(tt.name); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("datasetAncestors(%q) = %v, want %v", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
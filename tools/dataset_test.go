@@ -258,3 +258,117 @@ func TestValidateEncryptionOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegrationCreateDatasetHumanSizes(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("pool.dataset.create", map[string]interface{}{"id": "tank/vm"})
+
+	_, err := registry.CallTool("create_dataset", map[string]interface{}{
+		"name":    "tank/vm",
+		"type":    "VOLUME",
+		"volsize": "500G",
+		"quota":   "1TiB",
+	})
+	if err != nil {
+		t.Fatalf("create_dataset failed: %v", err)
+	}
+
+	calls := server.Calls("pool.dataset.create")
+	if len(calls) != 1 {
+		t.Fatalf("pool.dataset.create called %d times, want 1", len(calls))
+	}
+	payload, _ := calls[0].Params[0].(map[string]interface{})
+	if payload["volsize"] != float64(500<<30) {
+		t.Errorf("volsize = %v, want %d", payload["volsize"], int64(500<<30))
+	}
+	if payload["quota"] != float64(1<<40) {
+		t.Errorf("quota = %v, want %d", payload["quota"], int64(1<<40))
+	}
+}
+
+func TestIntegrationCreateDatasetPresetInheritancePreview(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{
+			"id":          "tank",
+			"compression": map[string]interface{}{"value": "LZ4", "source": "LOCAL"},
+			"checksum":    map[string]interface{}{"value": "ON", "source": "DEFAULT"},
+		},
+	})
+
+	result, err := registry.CallTool("create_dataset", map[string]interface{}{
+		"name":        "tank/apps/db",
+		"preset":      "app-config",
+		"compression": "ZSTD",
+		"dry_run":     true,
+	})
+	if err != nil {
+		t.Fatalf("create_dataset dry run failed: %v", err)
+	}
+	if n := len(server.Calls("pool.dataset.create")); n != 0 {
+		t.Fatalf("dry run created a dataset (%d calls)", n)
+	}
+
+	preview := decodeResult(t, result)
+	props, _ := preview["properties"].(map[string]interface{})
+	if props["inherits_from"] != "tank" {
+		t.Errorf("inherits_from = %v, want tank", props["inherits_from"])
+	}
+
+	explicit, _ := props["explicit"].(map[string]interface{})
+	if compression, _ := explicit["compression"].(map[string]interface{}); compression["source"] != "argument" || compression["value"] != "ZSTD" {
+		t.Errorf("compression = %v, want ZSTD from argument", explicit["compression"])
+	}
+	if recordsize, _ := explicit["recordsize"].(map[string]interface{}); recordsize["source"] != "preset" {
+		t.Errorf("recordsize = %v, want preset source", explicit["recordsize"])
+	}
+
+	inherited, _ := props["inherited"].(map[string]interface{})
+	if checksum, _ := inherited["checksum"].(map[string]interface{}); checksum["value"] != "ON" {
+		t.Errorf("checksum = %v, want inherited ON", inherited["checksum"])
+	}
+}
+
+func TestIntegrationCreateDatasetAppliesACLPreset(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("pool.dataset.create", map[string]interface{}{
+		"id": "tank/team", "name": "tank/team", "type": "FILESYSTEM", "pool": "tank", "mountpoint": "/mnt/tank/team",
+	})
+	server.SetResult("filesystem.acltemplate.by_path", []map[string]interface{}{
+		{"name": "NFS4_OPEN", "acltype": "NFS4", "acl": []interface{}{}},
+		{"name": "NFS4_RESTRICTED", "acltype": "NFS4", "acl": []interface{}{map[string]interface{}{"tag": "owner@"}}},
+	})
+	server.SetResult("filesystem.setacl", float64(51))
+
+	result, err := registry.CallTool("create_dataset", map[string]interface{}{
+		"name": "tank/team", "share_type": "SMB", "apply_acl_preset": "restricted", "owner": "alice", "group": "staff",
+	})
+	if err != nil {
+		t.Fatalf("create_dataset failed: %v", err)
+	}
+	perms, _ := decodeResult(t, result)["permissions"].(map[string]interface{})
+	if perms["job_id"] != float64(51) || perms["acl_preset"] != "NFS4_RESTRICTED" {
+		t.Errorf("permissions = %v, want NFS4_RESTRICTED applied by job 51", perms)
+	}
+	calls := server.Calls("filesystem.setacl")
+	if len(calls) != 1 {
+		t.Fatalf("filesystem.setacl called %d times, want 1", len(calls))
+	}
+	payload, _ := calls[0].Params[0].(map[string]interface{})
+	if payload["path"] != "/mnt/tank/team" || payload["user"] != "alice" || payload["acltype"] != "NFS4" {
+		t.Errorf("unexpected setacl payload: %v", payload)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"name": "tank/exports", "share_type": "NFS", "apply_acl_preset": "OPEN"},
+		{"name": "tank/team2", "share_type": "SMB", "apply_acl_preset": "EVERYONE"},
+		{"name": "tank/team3", "share_type": "SMB", "apply_acl_preset": "OPEN", "acltype": "POSIX"},
+	} {
+		if _, err := registry.CallTool("create_dataset", args); err == nil || ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("create_dataset(%v) error = %v, want VALIDATION", args, err)
+		}
+	}
+	if n := len(server.Calls("pool.dataset.create")); n != 1 {
+		t.Errorf("pool.dataset.create called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,506 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Dataset update and delete
+
+// datasetUpdateEnums are the values update_dataset accepts for each
+// enumerated property, besides INHERIT
+var datasetUpdateEnums = map[string][]string{
+	"atime":         {"ON", "OFF"},
+	"readonly":      {"ON", "OFF"},
+	"exec":          {"ON", "OFF"},
+	"sync":          {"STANDARD", "ALWAYS", "DISABLED"},
+	"snapdir":       {"VISIBLE", "HIDDEN"},
+	"deduplication": {"ON", "OFF", "VERIFY"},
+	"acltype":       {"NFSV4", "POSIX", "OFF"},
+	"checksum":      {"ON", "FLETCHER2", "FLETCHER4", "SHA256", "SHA512", "SKEIN", "EDONR", "BLAKE3"},
+	"recordsize":    {"512", "1K", "2K", "4K", "8K", "16K", "32K", "64K", "128K", "256K", "512K", "1M", "2M", "4M", "8M", "16M"},
+	"copies":        {"1", "2", "3"},
+}
+
+// compressionPattern matches the ZFS compression algorithms and levels
+var compressionPattern = regexp.MustCompile(`^(ON|OFF|LZ4|LZJB|ZLE|GZIP(-[1-9])?|ZSTD(-([1-9]|1[0-9]))?|ZSTD-FAST(-[0-9]+)?)$`)
+
+// datasetSizeProperties are set in bytes and cannot be inherited; 0 removes them
+var datasetSizeProperties = []string{"quota", "refquota", "reservation", "refreservation"}
+
+// filesystemOnlyProperties do not apply to volumes
+var filesystemOnlyProperties = map[string]bool{
+	"atime": true, "exec": true, "snapdir": true, "acltype": true, "recordsize": true, "quota": true, "refquota": true,
+}
+
+// newWritesOnlyProperties only change how data written afterwards is stored
+var newWritesOnlyProperties = map[string]bool{
+	"compression": true, "recordsize": true, "checksum": true, "deduplication": true, "copies": true,
+}
+
+// datasetPropertyValue returns a dataset property's value and source
+func datasetPropertyValue(ds map[string]interface{}, prop string) (string, string) {
+	propMap, ok := ds[prop].(map[string]interface{})
+	if !ok {
+		return "", ""
+	}
+	value, _ := propMap["value"].(string)
+	if value == "" && propMap["parsed"] != nil {
+		value = fmt.Sprintf("%v", propMap["parsed"])
+	}
+	source, _ := propMap["source"].(string)
+	return value, source
+}
+
+// datasetUpdate is a validated update_dataset request
+type datasetUpdate struct {
+	dataset   map[string]interface{}
+	name      string
+	payload   map[string]interface{}
+	changes   map[string]interface{}
+	unchanged []string
+	warnings  []string
+}
+
+func planDatasetUpdate(client truenas.Caller, args map[string]interface{}) (*datasetUpdate, error) {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	ds, err := findDatasetOrAncestor(client, name)
+	if err != nil {
+		return nil, err
+	}
+	if ds == nil || ds["name"] != name {
+		return nil, newToolError(ErrorNotFound, "dataset %s not found", name)
+	}
+	isVolume := ds["type"] == "VOLUME"
+
+	// Requested values, normalized to what the middleware takes
+	requested := map[string]interface{}{}
+	for prop, allowed := range datasetUpdateEnums {
+		raw, ok := args[prop]
+		if !ok {
+			continue
+		}
+		value := strings.ToUpper(fmt.Sprintf("%v", raw))
+		if b, ok := raw.(bool); ok {
+			value = map[bool]string{true: "ON", false: "OFF"}[b]
+		}
+		if value != "INHERIT" && !containsString(allowed, value) {
+			return nil, newToolError(ErrorValidation, "%s must be one of %s or INHERIT (got: %v)", prop, strings.Join(allowed, ", "), raw)
+		}
+		requested[prop] = value
+	}
+	if raw, ok := args["compression"]; ok {
+		value := strings.ToUpper(fmt.Sprintf("%v", raw))
+		if value != "INHERIT" && !compressionPattern.MatchString(value) {
+			return nil, newToolError(ErrorValidation, "compression must be LZ4, ZSTD, ZSTD-1 to ZSTD-19, GZIP, GZIP-1 to GZIP-9, ZLE, OFF, or INHERIT (got: %v)", raw)
+		}
+		requested["compression"] = value
+	}
+	for _, prop := range datasetSizeProperties {
+		raw, ok := args[prop]
+		if !ok {
+			continue
+		}
+		if s, ok := raw.(string); ok && strings.EqualFold(s, "none") {
+			raw = float64(0)
+		}
+		if s, ok := raw.(string); ok && strings.EqualFold(s, "INHERIT") {
+			return nil, newToolError(ErrorValidation, "%s cannot be inherited; use 0 or \"none\" to remove it", prop)
+		}
+		size, err := units.ParseSizeValue(raw)
+		if err != nil {
+			return nil, newToolError(ErrorValidation, "%s: %v", prop, err)
+		}
+		requested[prop] = size
+	}
+	if comments, ok := args["comments"].(string); ok {
+		requested["comments"] = comments
+	}
+	if raw, ok := args["volsize"]; ok {
+		if !isVolume {
+			return nil, newToolError(ErrorValidation, "volsize only applies to volumes (zvols); %s is a filesystem", name)
+		}
+		size, err := units.ParseSizeValue(raw)
+		if err != nil {
+			return nil, newToolError(ErrorValidation, "volsize: %v", err)
+		}
+		if current := datasetParsedBytes(ds, "volsize"); size < current {
+			return nil, newToolError(ErrorPrecondition, "shrinking %s from %s to %s would destroy the data at its end; volumes can only grow",
+				name, units.FormatBytes(current), units.FormatBytes(size))
+		}
+		requested["volsize"] = size
+	}
+	if len(requested) == 0 {
+		return nil, newToolError(ErrorValidation, "no properties to change; pass at least one property such as compression, quota, atime, or readonly")
+	}
+
+	plan := &datasetUpdate{dataset: ds, name: name, payload: map[string]interface{}{}, changes: map[string]interface{}{}}
+	var parent map[string]interface{}
+	props := make([]string, 0, len(requested))
+	for prop := range requested {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+
+	newWritesOnly := []string{}
+	for _, prop := range props {
+		value := requested[prop]
+		if isVolume && filesystemOnlyProperties[prop] {
+			return nil, newToolError(ErrorValidation, "%s does not apply to volumes", prop)
+		}
+		current, source := datasetPropertyValue(ds, prop)
+		change := map[string]interface{}{"from": current, "from_source": source, "to": value}
+
+		switch v := value.(type) {
+		case int64:
+			if datasetParsedBytes(ds, prop) == v {
+				plan.unchanged = append(plan.unchanged, prop)
+				continue
+			}
+			change["from"] = units.FormatBytes(datasetParsedBytes(ds, prop))
+			change["to"] = units.FormatBytes(v)
+			if v == 0 {
+				change["to"] = "none"
+			}
+		case string:
+			if v == "INHERIT" {
+				if !strings.Contains(name, "/") {
+					return nil, newToolError(ErrorValidation, "%s is a pool's root dataset and has no parent to inherit %s from", name, prop)
+				}
+				if source == "INHERITED" || source == "DEFAULT" {
+					plan.unchanged = append(plan.unchanged, prop)
+					continue
+				}
+				if parent == nil {
+					parent, _ = findDatasetOrAncestor(client, name[:strings.LastIndex(name, "/")])
+				}
+				if parent != nil {
+					inherited, _ := datasetPropertyValue(parent, prop)
+					change["to"] = fmt.Sprintf("INHERIT (%s from %v)", inherited, parent["name"])
+				}
+			} else if strings.EqualFold(current, v) && source != "INHERITED" && source != "DEFAULT" {
+				plan.unchanged = append(plan.unchanged, prop)
+				continue
+			}
+		}
+
+		// copies is numeric in the middleware schema
+		if prop == "copies" && value != "INHERIT" {
+			value, _ = strconv.Atoi(value.(string))
+		}
+		plan.payload[prop] = value
+		plan.changes[prop] = change
+	}
+
+	for _, prop := range props {
+		if _, changed := plan.changes[prop]; changed && newWritesOnlyProperties[prop] {
+			newWritesOnly = append(newWritesOnly, prop)
+		}
+	}
+	if len(newWritesOnly) > 0 {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("%s only apply to data written from now on; existing data keeps its old settings until it is rewritten", strings.Join(newWritesOnly, ", ")))
+	}
+	if v, _ := plan.payload["deduplication"].(string); v == "ON" || v == "VERIFY" {
+		plan.warnings = append(plan.warnings, "Deduplication keeps its table in RAM (several GB per TB of unique data) and cannot be undone for data already written; see estimate_dedup_impact")
+	}
+	if plan.payload["readonly"] == "ON" {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("Apps, shares, and tasks writing to %s or its children fail while it is read-only", name))
+	}
+	if plan.payload["sync"] == "DISABLED" {
+		plan.warnings = append(plan.warnings, "sync=DISABLED acknowledges writes before they are on disk; a power loss or crash can lose the last seconds of writes that clients believe are saved")
+	}
+	if _, ok := plan.payload["acltype"]; ok {
+		plan.warnings = append(plan.warnings, "Changing acltype does not convert existing permissions; files with ACLs of the old type may become inaccessible until their permissions are reset")
+	}
+	used := datasetParsedBytes(ds, "used")
+	for _, prop := range []string{"quota", "refquota"} {
+		limit, ok := plan.payload[prop].(int64)
+		if !ok || limit == 0 {
+			continue
+		}
+		inUse := used
+		if prop == "refquota" {
+			inUse = datasetParsedBytes(ds, "referenced")
+		}
+		if limit < inUse {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s of %s is below the %s already used; writes fail until space is freed", prop, units.FormatBytes(limit), units.FormatBytes(inUse)))
+		}
+	}
+	for _, prop := range []string{"reservation", "refreservation"} {
+		if size, ok := plan.payload[prop].(int64); ok && size > datasetParsedBytes(ds, "available")+datasetParsedBytes(ds, prop) {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s of %s is more than the space available to %s", prop, units.FormatBytes(size), name))
+		}
+	}
+	return plan, nil
+}
+
+func handleUpdateDataset(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planDatasetUpdate(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	response := map[string]interface{}{
+		"dataset": plan.name,
+		"changes": plan.changes,
+	}
+	if len(plan.unchanged) > 0 {
+		response["unchanged"] = plan.unchanged
+	}
+	if len(plan.payload) == 0 {
+		response["changed"] = false
+		response["message"] = fmt.Sprintf("%s already has the requested settings; nothing changed", plan.name)
+		return marshalJSON(response)
+	}
+
+	if _, err := client.Call("pool.dataset.update", plan.name, plan.payload); err != nil {
+		return "", fmt.Errorf("failed to update dataset: %w", err)
+	}
+	response["changed"] = true
+	response["message"] = fmt.Sprintf("Updated %d propert(ies) of %s", len(plan.payload), plan.name)
+	if len(plan.warnings) > 0 {
+		response["warnings"] = plan.warnings
+	}
+	return marshalJSON(response)
+}
+
+// datasetDeletion is a validated delete_dataset request
+type datasetDeletion struct {
+	dataset   map[string]interface{}
+	name      string
+	recursive bool
+	children  []string
+	snapshots []string
+	consumers *consumerFinder
+	warnings  []string
+}
+
+func planDatasetDeletion(client truenas.Caller, args map[string]interface{}) (*datasetDeletion, error) {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if !strings.Contains(name, "/") {
+		return nil, newToolError(ErrorValidation, "%s is a pool's root dataset; it cannot be deleted (export or destroy the pool instead)", name)
+	}
+	ds, err := findDatasetOrAncestor(client, name)
+	if err != nil {
+		return nil, err
+	}
+	if ds == nil || ds["name"] != name {
+		return nil, newToolError(ErrorNotFound, "dataset %s not found", name)
+	}
+	recursive, _ := args["recursive"].(bool)
+	plan := &datasetDeletion{dataset: ds, name: name, recursive: recursive}
+	pool := name[:strings.Index(name, "/")]
+	inTree := func(n string) bool {
+		return n == name || strings.HasPrefix(n, name+"/")
+	}
+
+	// Children and zvols below the dataset
+	datasets, err := inventoryQuery(client, "pool.dataset.query", []interface{}{
+		[]interface{}{"pool", "=", pool},
+	}, map[string]interface{}{"extra": map[string]interface{}{"retrieve_children": false}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list child datasets: %w", err)
+	}
+	for _, child := range datasets {
+		if n, _ := child["name"].(string); n != name && inTree(n) {
+			plan.children = append(plan.children, n)
+		}
+	}
+	sort.Strings(plan.children)
+	if len(plan.children) > 0 && !recursive {
+		return nil, newToolError(ErrorPrecondition, "%s has %d child dataset(s) (%s); set recursive to delete them too",
+			name, len(plan.children), strings.Join(firstN(plan.children, 5), ", "))
+	}
+
+	// Held snapshots and snapshots with clones elsewhere block the destroy
+	snapshots, err := querySnapshots(client, []interface{}{
+		[]interface{}{"pool", "=", pool},
+	})
+	if err != nil {
+		return nil, err
+	}
+	held := []string{}
+	cloned := []string{}
+	for _, snap := range snapshots {
+		dataset, _ := snap["dataset"].(string)
+		if !inTree(dataset) {
+			continue
+		}
+		id, _ := snap["id"].(string)
+		plan.snapshots = append(plan.snapshots, id)
+		if len(snapshotHolds(snap)) > 0 {
+			held = append(held, id)
+		}
+		for _, clone := range snapshotClones(snap) {
+			if !inTree(clone) {
+				cloned = append(cloned, fmt.Sprintf("%s (clone %s)", id, clone))
+			}
+		}
+	}
+	sort.Strings(plan.snapshots)
+	if len(held) > 0 {
+		return nil, newToolError(ErrorPrecondition, "%d snapshot(s) are held and cannot be destroyed (%s); release the holds first", len(held), strings.Join(firstN(held, 5), ", "))
+	}
+	if len(cloned) > 0 {
+		return nil, newToolError(ErrorPrecondition, "snapshots have clones outside %s that depend on them: %s; promote or delete the clones first", name, strings.Join(firstN(cloned, 5), ", "))
+	}
+
+	// Whatever uses the data breaks; the system and apps datasets must be moved first
+	path, _ := ds["mountpoint"].(string)
+	if path == "" {
+		path = datasetConsumerPath(name)
+	}
+	plan.consumers = findDatasetConsumers(client, name, path)
+	ignore, _ := args["ignore_consumers"].(bool)
+	blocking := plan.consumers.blocking()
+	for _, c := range plan.consumers.consumers {
+		if kind := c["type"]; (kind == "system_dataset" || kind == "apps_dataset") && c["relation"] != consumerRelationAncestor {
+			return nil, newToolError(ErrorPrecondition, "%s holds the %s; move it to another pool before deleting", name, strings.ReplaceAll(kind.(string), "_", " "))
+		}
+	}
+	if len(blocking) > 0 && !ignore {
+		return nil, newToolError(ErrorPrecondition, "%d consumer(s) use %s: %s; remove them first or set ignore_consumers", len(blocking), name, strings.Join(blocking, "; "))
+	}
+	if len(blocking) > 0 {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("These break when the data is gone: %s", strings.Join(blocking, "; ")))
+	}
+	plan.warnings = append(plan.warnings, plan.consumers.notes...)
+
+	plan.warnings = append(plan.warnings, fmt.Sprintf("All data in %s (%s used) is destroyed and cannot be recovered", name, units.FormatBytes(datasetParsedBytes(ds, "used"))))
+	if len(plan.snapshots) > 0 {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("%d snapshot(s) are destroyed with it, so no rollback is possible", len(plan.snapshots)))
+	}
+	return plan, nil
+}
+
+// firstN returns up to n items, noting how many more there are
+func firstN(items []string, n int) []string {
+	if len(items) <= n {
+		return items
+	}
+	return append(append([]string{}, items[:n]...), fmt.Sprintf("and %d more", len(items)-n))
+}
+
+func (r *Registry) handleDeleteDataset(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planDatasetDeletion(client, args)
+	if err != nil {
+		return "", err
+	}
+	force, _ := args["force"].(bool)
+
+	response := map[string]interface{}{
+		"dataset":   plan.name,
+		"recursive": plan.recursive,
+		"children":  plan.children,
+		"snapshots": len(plan.snapshots),
+		"freed":     units.FormatBytes(datasetParsedBytes(plan.dataset, "used")),
+		"message":   fmt.Sprintf("Deleted %s with %d child dataset(s) and %d snapshot(s)", plan.name, len(plan.children), len(plan.snapshots)),
+	}
+	return r.deleteOrDefer(client, "delete_dataset", plan.name, "pool.dataset.delete",
+		[]interface{}{plan.name, map[string]interface{}{"recursive": plan.recursive, "force": force}}, response)
+}
+
+// Dry-run wrappers
+
+func handleUpdateDatasetWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateDatasetDryRun{}, handleUpdateDataset)
+}
+
+func (r *Registry) handleDeleteDatasetWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteDatasetDryRun{registry: r}, r.handleDeleteDataset)
+}
+
+// Dry-run implementations
+
+type updateDatasetDryRun struct{}
+
+func (d *updateDatasetDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planDatasetUpdate(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]interface{}{}
+	for prop := range plan.changes {
+		value, source := datasetPropertyValue(plan.dataset, prop)
+		current[prop] = map[string]interface{}{"value": value, "source": source}
+	}
+	actions := []PlannedAction{}
+	if len(plan.payload) > 0 {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: fmt.Sprintf("Update %d propert(ies) of %s", len(plan.payload), plan.name),
+			Operation:   "update",
+			Target:      plan.name,
+			Details:     plan.changes,
+		})
+	}
+	warnings := plan.warnings
+	if len(plan.unchanged) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Already set as requested: %s", strings.Join(plan.unchanged, ", ")))
+	}
+
+	return &DryRunResult{
+		Tool: "update_dataset",
+		CurrentState: map[string]interface{}{
+			"dataset":    plan.name,
+			"type":       plan.dataset["type"],
+			"properties": current,
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
+
+type deleteDatasetDryRun struct {
+	registry *Registry
+}
+
+func (d *deleteDatasetDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planDatasetDeletion(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Delete dataset %s", plan.name)
+	if len(plan.children) > 0 {
+		description = fmt.Sprintf("Delete dataset %s with %d child dataset(s)", plan.name, len(plan.children))
+	}
+	if len(plan.snapshots) > 0 {
+		description += fmt.Sprintf(" and %d snapshot(s)", len(plan.snapshots))
+	}
+	encrypted, _ := plan.dataset["encrypted"].(bool)
+
+	return &DryRunResult{
+		Tool: "delete_dataset",
+		CurrentState: map[string]interface{}{
+			"dataset": map[string]interface{}{
+				"name":       plan.name,
+				"type":       plan.dataset["type"],
+				"used":       units.FormatBytes(datasetParsedBytes(plan.dataset, "used")),
+				"mountpoint": plan.dataset["mountpoint"],
+				"encrypted":  encrypted,
+			},
+			"children":  plan.children,
+			"snapshots": firstN(plan.snapshots, 20),
+			"consumers": plan.consumers.consumers,
+		},
+		PlannedActions: []PlannedAction{
+			d.registry.deletionPlan(description, plan.name),
+		},
+		Warnings: plan.warnings,
+	}, nil
+}
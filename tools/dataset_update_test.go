@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationUpdateAndDeleteDataset(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	prop := func(value, source string, parsed interface{}) map[string]interface{} {
+		return map[string]interface{}{"value": value, "rawvalue": value, "parsed": parsed, "source": source}
+	}
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank", "name": "tank", "pool": "tank", "type": "FILESYSTEM",
+			"compression": prop("ZSTD", "LOCAL", "ZSTD")},
+		{"id": "tank/media", "name": "tank/media", "pool": "tank", "type": "FILESYSTEM", "mountpoint": "/mnt/tank/media",
+			"compression": prop("LZ4", "LOCAL", "LZ4"),
+			"atime":       prop("ON", "INHERITED", "ON"),
+			"readonly":    prop("OFF", "DEFAULT", false),
+			"quota":       prop("0", "DEFAULT", float64(0)),
+			"used":        prop("2T", "NONE", float64(2<<40))},
+		{"id": "tank/old", "name": "tank/old", "pool": "tank", "type": "FILESYSTEM", "mountpoint": "/mnt/tank/old",
+			"used": prop("1G", "NONE", float64(1<<30))},
+		{"id": "tank/old/sub", "name": "tank/old/sub", "pool": "tank", "type": "FILESYSTEM"},
+		{"id": "tank/held", "name": "tank/held", "pool": "tank", "type": "FILESYSTEM"},
+	})
+	server.SetRecords("pool.snapshot.query", []map[string]interface{}{
+		{"id": "tank/old@a", "name": "tank/old@a", "dataset": "tank/old", "pool": "tank", "holds": map[string]interface{}{}},
+		{"id": "tank/old/sub@b", "name": "tank/old/sub@b", "dataset": "tank/old/sub", "pool": "tank", "holds": map[string]interface{}{}},
+		{"id": "tank/held@keep", "name": "tank/held@keep", "dataset": "tank/held", "pool": "tank",
+			"holds": map[string]interface{}{"replication": "1"}},
+	})
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{
+		{"id": float64(1), "name": "old", "path": "/mnt/tank/old", "enabled": true},
+	})
+	for _, method := range []string{"sharing.nfs.query", "iscsi.extent.query", "app.query", "vm.query",
+		"pool.snapshottask.query", "replication.query", "cloudsync.query", "rsynctask.query"} {
+		server.SetRecords(method, []map[string]interface{}{})
+	}
+	server.SetResult("systemdataset.config", map[string]interface{}{"pool": "boot-pool", "basename": "boot-pool/.system"})
+	server.SetResult("pool.dataset.update", map[string]interface{}{"id": "tank/media"})
+	server.SetResult("pool.dataset.delete", true)
+
+	// Dry run shows the inherited value and skips settings already in place
+	result, err := registry.CallTool("update_dataset", map[string]interface{}{
+		"name": "tank/media", "compression": "INHERIT", "atime": "INHERIT", "quota": "1T", "readonly": true, "dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("update_dataset dry run failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	changes := response["planned_actions"].([]interface{})[0].(map[string]interface{})["details"].(map[string]interface{})
+	if to := changes["compression"].(map[string]interface{})["to"]; to != "INHERIT (ZSTD from tank)" {
+		t.Errorf("compression change to = %v", to)
+	}
+	if _, ok := changes["atime"]; ok {
+		t.Errorf("atime is already inherited and should not change: %v", changes)
+	}
+	warnings := fmt.Sprint(response["warnings"])
+	if !strings.Contains(warnings, "below the") || !strings.Contains(warnings, "read-only") {
+		t.Errorf("warnings = %s, want quota and read-only warnings", warnings)
+	}
+	if len(server.Calls("pool.dataset.update")) != 0 {
+		t.Fatal("dry run called pool.dataset.update")
+	}
+
+	result, err = registry.CallTool("update_dataset", map[string]interface{}{"name": "tank/media", "compression": "zstd", "atime": "OFF"})
+	if err != nil {
+		t.Fatalf("update_dataset failed: %v", err)
+	}
+	if decodeResult(t, result)["changed"] != true {
+		t.Errorf("update_dataset did not report a change: %s", result)
+	}
+	calls := server.Calls("pool.dataset.update")
+	if len(calls) != 1 {
+		t.Fatalf("pool.dataset.update called %d times, want 1", len(calls))
+	}
+	payload := calls[0].Params[1].(map[string]interface{})
+	if payload["compression"] != "ZSTD" || payload["atime"] != "OFF" || len(payload) != 2 {
+		t.Errorf("update payload = %v", payload)
+	}
+
+	result, err = registry.CallTool("update_dataset", map[string]interface{}{"name": "tank/media", "compression": "LZ4"})
+	if err != nil {
+		t.Fatalf("no-op update_dataset failed: %v", err)
+	}
+	if decodeResult(t, result)["changed"] != false || len(server.Calls("pool.dataset.update")) != 1 {
+		t.Errorf("no-op update was sent: %s", result)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"name": "tank/media", "quota": "INHERIT"},
+		{"name": "tank/media", "volsize": "10G"},
+		{"name": "tank/media", "sync": "sometimes"},
+		{"name": "tank", "compression": "INHERIT"},
+	} {
+		if _, err := registry.CallTool("update_dataset", args); err == nil || ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("update_dataset(%v) error = %v, want VALIDATION", args, err)
+		}
+	}
+
+	// Deleting requires recursive for children and no consumers
+	_, err = registry.CallTool("delete_dataset", map[string]interface{}{"name": "tank/old"})
+	if err == nil || ClassifyError(err).Code != ErrorPrecondition || !strings.Contains(err.Error(), "tank/old/sub") {
+		t.Errorf("delete with children error = %v, want PRECONDITION naming the child", err)
+	}
+	_, err = registry.CallTool("delete_dataset", map[string]interface{}{"name": "tank/old", "recursive": true})
+	if err == nil || ClassifyError(err).Code != ErrorPrecondition || !strings.Contains(err.Error(), "smb share old") {
+		t.Errorf("delete with share error = %v, want PRECONDITION naming the share", err)
+	}
+
+	result, err = registry.CallTool("delete_dataset", map[string]interface{}{
+		"name": "tank/old", "recursive": true, "ignore_consumers": true, "dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("delete_dataset dry run failed: %v", err)
+	}
+	state := decodeResult(t, result)["current_state"].(map[string]interface{})
+	if len(state["children"].([]interface{})) != 1 || len(state["snapshots"].([]interface{})) != 2 {
+		t.Errorf("dry run state = %v, want 1 child and 2 snapshots", state)
+	}
+	if len(server.Calls("pool.dataset.delete")) != 0 {
+		t.Fatal("dry run called pool.dataset.delete")
+	}
+
+	result, err = registry.CallTool("delete_dataset", map[string]interface{}{"name": "tank/old", "recursive": true, "ignore_consumers": true})
+	if err != nil {
+		t.Fatalf("delete_dataset failed: %v", err)
+	}
+	if decodeResult(t, result)["deleted"] != true {
+		t.Errorf("delete_dataset response = %s", result)
+	}
+	deletes := server.Calls("pool.dataset.delete")
+	if len(deletes) != 1 || deletes[0].Params[0] != "tank/old" || deletes[0].Params[1].(map[string]interface{})["recursive"] != true {
+		t.Errorf("pool.dataset.delete calls = %v", deletes)
+	}
+
+	_, err = registry.CallTool("delete_dataset", map[string]interface{}{"name": "tank/held"})
+	if err == nil || ClassifyError(err).Code != ErrorPrecondition || !strings.Contains(err.Error(), "held") {
+		t.Errorf("delete with held snapshot error = %v, want PRECONDITION", err)
+	}
+	_, err = registry.CallTool("delete_dataset", map[string]interface{}{"name": "tank"})
+	if err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("delete pool root error = %v, want VALIDATION", err)
+	}
+}
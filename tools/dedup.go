@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Deduplication impact estimation
+
+const (
+	// dedupTableEntryBytes is the in-core size of one dedup table entry, per
+	// unique block (the commonly cited ~320 bytes)
+	dedupTableEntryBytes = 320
+
+	// defaultDedupBlockSize is used when a dataset's record size is unknown
+	defaultDedupBlockSize = 128 * units.KiB
+)
+
+// estimateDedupTableBytes returns the dedup table size for dataBytes of unique
+// data stored in blockSize blocks. It assumes no duplicates, the worst case.
+func estimateDedupTableBytes(dataBytes, blockSize int64) int64 {
+	if dataBytes <= 0 {
+		return 0
+	}
+	if blockSize <= 0 {
+		blockSize = defaultDedupBlockSize
+	}
+	blocks := (dataBytes + blockSize - 1) / blockSize
+	return blocks * dedupTableEntryBytes
+}
+
+// dedupMemoryImpact rates a dedup table size against system memory
+func dedupMemoryImpact(tableBytes, memoryBytes int64) map[string]interface{} {
+	impact := map[string]interface{}{
+		"table_size": units.NewSize(tableBytes),
+	}
+	if memoryBytes <= 0 {
+		impact["level"] = "unknown"
+		return impact
+	}
+
+	pct := float64(tableBytes) / float64(memoryBytes) * 100
+	impact["percent_of_ram"] = pct
+	switch {
+	case pct < 5:
+		impact["level"] = "low"
+	case pct < 20:
+		impact["level"] = "moderate"
+	default:
+		impact["level"] = "high"
+	}
+	return impact
+}
+
+// systemMemoryBytes returns physical memory from system.info, or 0 if unavailable
+func systemMemoryBytes(client truenas.Caller) int64 {
+	result, err := client.Call("system.info")
+	if err != nil {
+		return 0
+	}
+	var info map[string]interface{}
+	if err := json.Unmarshal(result, &info); err != nil {
+		return 0
+	}
+	physMem, _ := info["physmem"].(float64)
+	return int64(physMem)
+}
+
+// datasetParsedBytes returns a numeric dataset property's parsed value
+func datasetParsedBytes(ds map[string]interface{}, prop string) int64 {
+	propMap, ok := ds[prop].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch v := propMap["parsed"].(type) {
+	case float64:
+		return int64(v)
+	case string:
+		if bytes, err := units.ParseBytes(v); err == nil {
+			return bytes
+		}
+	}
+	if value, ok := propMap["value"].(string); ok {
+		if bytes, err := units.ParseBytes(value); err == nil {
+			return bytes
+		}
+	}
+	return 0
+}
+
+// datasetDedupEnabled reports whether a dataset has deduplication on
+func datasetDedupEnabled(ds map[string]interface{}) bool {
+	propMap, ok := ds["deduplication"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	value, _ := propMap["value"].(string)
+	return value != "" && !strings.EqualFold(value, "OFF")
+}
+
+func handleEstimateDedupImpact(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	poolFilter, _ := args["pool"].(string)
+	candidate, _ := args["dataset"].(string)
+
+	memory := systemMemoryBytes(client)
+
+	poolFilters := []interface{}{}
+	if poolFilter != "" {
+		poolFilters = append(poolFilters, []interface{}{"name", "=", poolFilter})
+	}
+	poolResult, err := client.Call("pool.query", poolFilters)
+	if err != nil {
+		return "", fmt.Errorf("failed to query pools: %w", err)
+	}
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(poolResult, &pools); err != nil {
+		return "", fmt.Errorf("failed to parse pools: %w", err)
+	}
+	if poolFilter != "" && len(pools) == 0 {
+		return "", fmt.Errorf("pool '%s' not found", poolFilter)
+	}
+
+	datasetResult, err := client.Call("pool.dataset.query", []interface{}{},
+		map[string]interface{}{"extra": map[string]interface{}{"flat": true, "retrieve_children": false}})
+	if err != nil {
+		return "", fmt.Errorf("failed to query datasets: %w", err)
+	}
+	var datasets []map[string]interface{}
+	if err := json.Unmarshal(datasetResult, &datasets); err != nil {
+		return "", fmt.Errorf("failed to parse datasets: %w", err)
+	}
+
+	dedupByPool := map[string][]string{}
+	for _, ds := range datasets {
+		if datasetDedupEnabled(ds) {
+			poolName, _ := ds["pool"].(string)
+			name, _ := ds["name"].(string)
+			dedupByPool[poolName] = append(dedupByPool[poolName], name)
+		}
+	}
+
+	poolReports := []map[string]interface{}{}
+	var totalTable int64
+	for _, pool := range pools {
+		name, _ := pool["name"].(string)
+		tableSize, hasTable := pool["dedup_table_size"].(float64)
+		if len(dedupByPool[name]) == 0 && tableSize == 0 {
+			continue
+		}
+
+		report := map[string]interface{}{
+			"pool":           name,
+			"dedup_datasets": dedupByPool[name],
+		}
+		if hasTable {
+			report["memory"] = dedupMemoryImpact(int64(tableSize), memory)
+			totalTable += int64(tableSize)
+		} else {
+			report["note"] = "Dedup table size is not reported by this TrueNAS version"
+		}
+		if quota, ok := pool["dedup_table_quota"].(string); ok && quota != "" {
+			report["dedup_table_quota"] = quota
+		}
+		poolReports = append(poolReports, report)
+	}
+
+	response := map[string]interface{}{
+		"pools": poolReports,
+	}
+	if memory > 0 {
+		response["system_memory"] = units.NewSize(memory)
+	}
+	if len(poolReports) > 0 {
+		response["total_memory"] = dedupMemoryImpact(totalTable, memory)
+	} else {
+		response["message"] = "Deduplication is not enabled on any dataset"
+	}
+
+	// Advisory for a dataset being considered for dedup, assuming every block is unique
+	if candidate != "" {
+		ds, err := getDatasetByName(client, candidate)
+		if err != nil {
+			return "", err
+		}
+
+		used := datasetParsedBytes(ds, "used")
+		blockSize := datasetParsedBytes(ds, "recordsize")
+		if dsType, _ := ds["type"].(string); dsType == "VOLUME" {
+			blockSize = datasetParsedBytes(ds, "volblocksize")
+		}
+		if blockSize <= 0 {
+			blockSize = defaultDedupBlockSize
+		}
+
+		estimate := estimateDedupTableBytes(used, blockSize)
+		advisory := map[string]interface{}{
+			"dataset":          candidate,
+			"already_enabled":  datasetDedupEnabled(ds),
+			"data":             units.NewSize(used),
+			"block_size":       units.NewSize(blockSize),
+			"estimated_memory": dedupMemoryImpact(estimate, memory),
+			"per_tib":          units.NewSize(estimateDedupTableBytes(units.TiB, blockSize)),
+			"assumption":       fmt.Sprintf("Worst case: every %s block is unique, %d bytes of RAM per table entry", units.FormatBytes(blockSize), dedupTableEntryBytes),
+		}
+
+		impact := advisory["estimated_memory"].(map[string]interface{})
+		switch impact["level"] {
+		case "high":
+			advisory["recommendation"] = "Not recommended: the dedup table would take a large share of RAM and slow every write once it no longer fits in ARC"
+		case "moderate":
+			advisory["recommendation"] = "Only enable if the data is known to be highly duplicated (e.g. many similar VM images); compression is usually the better choice"
+		case "unknown":
+			advisory["recommendation"] = "System memory could not be determined; compare the estimated table size with installed RAM before enabling"
+		default:
+			advisory["recommendation"] = "Memory impact is small at the current size, but the table grows with the data; only enable for highly duplicated data"
+		}
+		advisory["note"] = "Deduplication applies only to data written after it is enabled"
+
+		response["candidate"] = advisory
+	}
+
+	return marshalJSON(response)
+}
+
+// dedupCreatePreview backs the create_dataset dry run's dedup warning with the
+// per-TiB table cost at the dataset's block size and this system's memory
+func dedupCreatePreview(client truenas.Caller, payload map[string]interface{}) map[string]interface{} {
+	blockSize := int64(defaultDedupBlockSize)
+	for _, key := range []string{"recordsize", "volblocksize"} {
+		if size, ok := payload[key].(string); ok && size != "" {
+			if bytes, err := units.ParseBytes(size); err == nil {
+				blockSize = bytes
+			}
+		}
+	}
+
+	perTiB := estimateDedupTableBytes(units.TiB, blockSize)
+	return map[string]interface{}{
+		"warning":        "Deduplication keeps a table of every unique block in RAM; performance degrades sharply when it no longer fits",
+		"per_tib":        units.NewSize(perTiB),
+		"memory_per_tib": dedupMemoryImpact(perTiB, systemMemoryBytes(client)),
+		"next_step":      "Use estimate_dedup_impact to see existing dedup tables on this pool",
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/truenas/truenas-mcp/units"
+)
+
+func TestEstimateDedupTableBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      int64
+		blockSize int64
+		want      int64
+	}{
+		{name: "1 TiB at 128K", data: units.TiB, blockSize: 128 * units.KiB, want: 8 * units.MiB * dedupTableEntryBytes},
+		{name: "1 TiB at 64K", data: units.TiB, blockSize: 64 * units.KiB, want: 16 * units.MiB * dedupTableEntryBytes},
+		{name: "partial block rounds up", data: 1, blockSize: 128 * units.KiB, want: dedupTableEntryBytes},
+		{name: "unknown block size", data: 128 * units.KiB, blockSize: 0, want: dedupTableEntryBytes},
+		{name: "empty", data: 0, blockSize: 128 * units.KiB, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateDedupTableBytes(tt.data, tt.blockSize); got != tt.want {
+				t.Errorf("estimateDedupTableBytes(%d, %d) = %d, want %d", tt.data, tt.blockSize, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupMemoryImpact(t *testing.T) {
+	tests := []struct {
+		table  int64
+		memory int64
+		want   string
+	}{
+		{table: 512 * units.MiB, memory: 32 * units.GiB, want: "low"},
+		{table: 4 * units.GiB, memory: 32 * units.GiB, want: "moderate"},
+		{table: 10 * units.GiB, memory: 32 * units.GiB, want: "high"},
+		{table: 10 * units.GiB, memory: 0, want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := dedupMemoryImpact(tt.table, tt.memory)["level"]; got != tt.want {
+			t.Errorf("dedupMemoryImpact(%d, %d) level = %v, want %s", tt.table, tt.memory, got, tt.want)
+		}
+	}
+}
+
+func TestIntegrationEstimateDedupImpact(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.info", map[string]interface{}{"physmem": float64(32 << 30)})
+	server.SetRecords("pool.query", []map[string]interface{}{
+		{"id": float64(1), "name": "tank", "dedup_table_size": float64(2 << 30)},
+		{"id": float64(2), "name": "backup", "dedup_table_size": float64(0)},
+	})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank/vms", "name": "tank/vms", "pool": "tank", "type": "FILESYSTEM",
+			"deduplication": map[string]interface{}{"value": "ON"}},
+		{"id": "backup/media", "name": "backup/media", "pool": "backup", "type": "FILESYSTEM",
+			"deduplication": map[string]interface{}{"value": "OFF"},
+			"used":          map[string]interface{}{"parsed": float64(4 << 40)},
+			"recordsize":    map[string]interface{}{"parsed": "128K", "value": "128K"}},
+	})
+
+	result, err := registry.CallTool("estimate_dedup_impact", map[string]interface{}{"dataset": "backup/media"})
+	if err != nil {
+		t.Fatalf("estimate_dedup_impact failed: %v", err)
+	}
+	response := decodeResult(t, result)
+
+	pools, _ := response["pools"].([]interface{})
+	if len(pools) != 1 || pools[0].(map[string]interface{})["pool"] != "tank" {
+		t.Fatalf("pools = %v, want only tank", response["pools"])
+	}
+	memory, _ := pools[0].(map[string]interface{})["memory"].(map[string]interface{})
+	if memory["level"] != "moderate" {
+		t.Errorf("tank memory level = %v, want moderate", memory["level"])
+	}
+
+	candidate, _ := response["candidate"].(map[string]interface{})
+	estimate, _ := candidate["estimated_memory"].(map[string]interface{})
+	tableSize, _ := estimate["table_size"].(map[string]interface{})
+	if tableSize["bytes"] != float64(10<<30) || estimate["level"] != "high" {
+		t.Errorf("candidate estimate = %v, want 10 GiB high", estimate)
+	}
+}
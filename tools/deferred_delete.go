@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/truenas/truenas-mcp/deletion"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Deferred ("recycle bin") deletion for destructive tools
+
+// deferredDeletion reports whether delete tools queue their deletions
+func (r *Registry) deferredDeletion() bool {
+	return r.deletionQueue != nil && r.deletionQueue.Enabled()
+}
+
+// deleteOrDefer performs a deletion through the middleware call method(params),
+// or queues it to run after the grace period when deferred deletion is enabled.
+// response describes the deleted object and is extended with the outcome.
+func (r *Registry) deleteOrDefer(client truenas.Caller, tool, target, method string, params []interface{}, response map[string]interface{}) (string, error) {
+	if r.deferredDeletion() {
+		pending, err := r.deletionQueue.Schedule(r.system, tool, target, method, params, client.CorrelationID())
+		if err != nil {
+			return "", err
+		}
+		response["deleted"] = false
+		response["pending_deletion"] = pending
+		response["message"] = fmt.Sprintf("Deletion of %s is scheduled for %s (grace period %s). Nothing has been deleted yet. Use undo_pending_deletion with id %s to cancel it before then.",
+			target, pending.ExecuteAt.Format(time.RFC3339), r.deletionQueue.GracePeriod(), pending.ID)
+		return marshalJSON(response)
+	}
+
+	if _, err := client.Call(method, params...); err != nil {
+		return "", fmt.Errorf("failed to delete %s: %w", target, err)
+	}
+	response["deleted"] = true
+	return marshalJSON(response)
+}
+
+// deletionPlan describes a delete for dry runs, including when it would happen
+func (r *Registry) deletionPlan(description, target string) PlannedAction {
+	action := PlannedAction{
+		Step:        1,
+		Description: description,
+		Operation:   "delete",
+		Target:      target,
+	}
+	if r.deferredDeletion() {
+		action.Description = fmt.Sprintf("%s after a %s grace period (can be undone with undo_pending_deletion until then)", description, r.deletionQueue.GracePeriod())
+		action.Operation = "schedule_delete"
+	}
+	return action
+}
+
+func (r *Registry) handleListPendingDeletions(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.deletionQueue == nil {
+		return "", fmt.Errorf("deferred deletion is not available on this server")
+	}
+
+	includeFinished, _ := args["include_finished"].(bool)
+	deletions := []deletion.Deletion{}
+	for _, d := range r.deletionQueue.List() {
+		if includeFinished || d.Status == deletion.StatusPending || d.Status == deletion.StatusExecuting {
+			deletions = append(deletions, d)
+		}
+	}
+
+	response := map[string]interface{}{
+		"deferred_deletion": r.deletionQueue.Enabled(),
+		"deletions":         deletions,
+		"count":             len(deletions),
+	}
+	if r.deletionQueue.Enabled() {
+		response["grace_period"] = r.deletionQueue.GracePeriod().String()
+	} else {
+		response["note"] = "Deferred deletion is disabled (--deletion-grace-period is not set); delete tools act immediately"
+	}
+	return marshalJSON(response)
+}
+
+func (r *Registry) handleUndoPendingDeletion(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.deletionQueue == nil {
+		return "", fmt.Errorf("deferred deletion is not available on this server")
+	}
+
+	id, _ := args["id"].(string)
+	if id == "" {
+		return "", fmt.Errorf("id is required")
+	}
+
+	cancelled, err := r.deletionQueue.Cancel(id)
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(map[string]interface{}{
+		"deletion": cancelled,
+		"message":  fmt.Sprintf("Deletion of %s was cancelled; it will not be deleted.", cancelled.Target),
+	})
+}
+
+// findSMBShare returns the SMB share with the given name
+func findSMBShare(client truenas.Caller, name string) (map[string]interface{}, error) {
+	result, err := client.Call("sharing.smb.query", []interface{}{
+		[]interface{}{"name", "=", name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SMB shares: %w", err)
+	}
+
+	var shares []map[string]interface{}
+	if err := json.Unmarshal(result, &shares); err != nil {
+		return nil, fmt.Errorf("failed to parse SMB shares: %w", err)
+	}
+	if len(shares) == 0 {
+		return nil, newToolError(ErrorNotFound, "SMB share '%s' not found", name)
+	}
+	return shares[0], nil
+}
+
+func (r *Registry) handleDeleteSMBShare(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+
+	share, err := findSMBShare(client, name)
+	if err != nil {
+		return "", err
+	}
+
+	return r.deleteOrDefer(client, "delete_smb_share", name, "sharing.smb.delete", []interface{}{share["id"]}, map[string]interface{}{
+		"id":   share["id"],
+		"name": name,
+		"path": share["path"],
+		"note": "Only the share is removed; the dataset and its files are untouched.",
+	})
+}
+
+func (r *Registry) handleDeleteSMBShareWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteSMBShareDryRun{registry: r}, r.handleDeleteSMBShare)
+}
+
+type deleteSMBShareDryRun struct {
+	registry *Registry
+}
+
+func (d *deleteSMBShareDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	share, err := findSMBShare(client, name)
+	if err != nil {
+		return nil, err
+	}
+	path, _ := share["path"].(string)
+
+	warnings := []string{
+		fmt.Sprintf("Clients using \\\\truenas\\%s will lose access immediately once the share is removed", name),
+		fmt.Sprintf("The files under %s are NOT deleted", path),
+	}
+	if enabled, _ := share["enabled"].(bool); !enabled {
+		warnings = append(warnings, "The share is already disabled")
+	}
+
+	return &DryRunResult{
+		Tool: "delete_smb_share",
+		CurrentState: map[string]interface{}{
+			"share": map[string]interface{}{
+				"id":       share["id"],
+				"name":     name,
+				"path":     path,
+				"enabled":  share["enabled"],
+				"readonly": share["readonly"],
+				"comment":  share["comment"],
+			},
+		},
+		PlannedActions: []PlannedAction{
+			d.registry.deletionPlan(fmt.Sprintf("Delete SMB share '%s'", name), name),
+		},
+		Warnings: warnings,
+	}, nil
+}
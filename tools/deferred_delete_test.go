@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/deletion"
+)
+
+func TestIntegrationDeferredDeletion(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{
+		{"id": float64(3), "name": "media", "path": "/mnt/tank/media", "enabled": true},
+		{"id": float64(4), "name": "scratch", "path": "/mnt/tank/scratch", "enabled": true},
+	})
+	server.SetResult("sharing.smb.delete", true)
+
+	// Without a grace period the share is deleted immediately
+	result, err := registry.CallTool("delete_smb_share", map[string]interface{}{"name": "scratch"})
+	if err != nil {
+		t.Fatalf("delete_smb_share failed: %v", err)
+	}
+	if decodeResult(t, result)["deleted"] != true || len(server.Calls("sharing.smb.delete")) != 1 {
+		t.Fatalf("immediate deletion did not run:\n%s", result)
+	}
+
+	queue, err := deletion.NewQueue(server.Client(t), deletion.Config{GracePeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("NewQueue failed: %v", err)
+	}
+	registry.deletionQueue = queue
+
+	preview, err := registry.CallTool("delete_smb_share", map[string]interface{}{"name": "media", "dry_run": true})
+	if err != nil {
+		t.Fatalf("delete_smb_share dry run failed: %v", err)
+	}
+	if !strings.Contains(preview, `"operation": "schedule_delete"`) {
+		t.Errorf("dry run does not mention the grace period:\n%s", preview)
+	}
+
+	result, err = registry.CallTool("delete_smb_share", map[string]interface{}{"name": "media"})
+	if err != nil {
+		t.Fatalf("deferred delete_smb_share failed: %v", err)
+	}
+	pending := decodeResult(t, result)["pending_deletion"].(map[string]interface{})
+	if calls := server.Calls("sharing.smb.delete"); len(calls) != 1 {
+		t.Errorf("deferred deletion ran immediately: %v", calls)
+	}
+
+	listed, err := registry.CallTool("list_pending_deletions", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list_pending_deletions failed: %v", err)
+	}
+	if decodeResult(t, listed)["count"] != float64(1) {
+		t.Errorf("pending deletions:\n%s", listed)
+	}
+
+	if _, err := registry.CallTool("undo_pending_deletion", map[string]interface{}{"id": pending["id"]}); err != nil {
+		t.Fatalf("undo_pending_deletion failed: %v", err)
+	}
+	queue.ExecuteDue(time.Now().Add(2 * time.Hour))
+	if calls := server.Calls("sharing.smb.delete"); len(calls) != 1 {
+		t.Errorf("undone deletion executed: %v", calls)
+	}
+
+	if _, err := registry.CallTool("delete_smb_share", map[string]interface{}{"name": "nope"}); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown share error = %v, want NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/digest"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Health digest handlers
+
+// healthDigestArgs reads the period and delivery targets of generate_health_digest
+func healthDigestArgs(args map[string]interface{}) (digest.Period, digest.Delivery, error) {
+	period := digest.PeriodDaily
+	if p, ok := args["period"].(string); ok && p != "" {
+		switch digest.Period(p) {
+		case digest.PeriodDaily, digest.PeriodWeekly:
+			period = digest.Period(p)
+		default:
+			return "", digest.Delivery{}, fmt.Errorf("period must be 'daily' or 'weekly', got: %s", p)
+		}
+	}
+
+	var delivery digest.Delivery
+	if recipients, ok := args["email_to"].([]interface{}); ok {
+		for _, rcpt := range recipients {
+			addr, ok := rcpt.(string)
+			if !ok || addr == "" {
+				continue
+			}
+			if !strings.Contains(addr, "@") {
+				return "", digest.Delivery{}, fmt.Errorf("invalid email address: %s", addr)
+			}
+			delivery.EmailTo = append(delivery.EmailTo, addr)
+		}
+	}
+	delivery.AlertService, _ = args["alert_service"].(string)
+	return period, delivery, nil
+}
+
+func (r *Registry) handleGenerateHealthDigest(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.digestScheduler == nil {
+		return "", fmt.Errorf("health digest subsystem is not configured")
+	}
+	period, delivery, err := healthDigestArgs(args)
+	if err != nil {
+		return "", err
+	}
+
+	report := r.digestScheduler.Generate(period, delivery)
+
+	formatted, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(formatted), nil
+}
+
+func (r *Registry) handleGenerateHealthDigestWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &generateHealthDigestDryRun{scheduler: r.digestScheduler}, r.handleGenerateHealthDigest)
+}
+
+type generateHealthDigestDryRun struct {
+	scheduler *digest.Scheduler
+}
+
+// ExecuteDryRun builds the digest without storing it and checks where it
+// would be delivered, sending nothing
+func (g *generateHealthDigestDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	if g.scheduler == nil {
+		return nil, fmt.Errorf("health digest subsystem is not configured")
+	}
+	period, delivery, err := healthDigestArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{{
+		Step:        1,
+		Description: "Store the digest as the latest for get_health_digest",
+		Operation:   "store",
+		Target:      digest.ResourceURI,
+	}}
+	warnings := []string{}
+	if len(delivery.EmailTo) > 0 {
+		actions = append(actions, PlannedAction{
+			Step:        len(actions) + 1,
+			Description: "Email the digest through TrueNAS mail.send",
+			Operation:   "send",
+			Target:      strings.Join(delivery.EmailTo, ", "),
+		})
+	}
+	if delivery.AlertService != "" {
+		target, err := g.scheduler.CheckAlertService(delivery.AlertService)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("The digest cannot be delivered: %v", err))
+		} else {
+			actions = append(actions, PlannedAction{
+				Step:        len(actions) + 1,
+				Description: fmt.Sprintf("Send the digest through alert service %s (%s)", delivery.AlertService, target),
+				Operation:   "send",
+				Target:      delivery.AlertService,
+			})
+		}
+	}
+
+	return &DryRunResult{
+		Tool:           "generate_health_digest",
+		CurrentState:   g.scheduler.Preview(period),
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
+
+func (r *Registry) handleGetHealthDigest(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.digestScheduler == nil {
+		return "", fmt.Errorf("health digest subsystem is not configured")
+	}
+
+	report := r.digestScheduler.Latest()
+	if report == nil {
+		response := map[string]interface{}{
+			"digest":  nil,
+			"message": "No health digest has been generated yet. Use generate_health_digest to create one now.",
+		}
+		if cfg := r.digestScheduler.Config(); cfg.Period != "" {
+			response["next_scheduled"] = digest.NextRun(time.Now(), cfg.Period, cfg.Hour).Format(time.RFC3339)
+		}
+		return marshalJSON(response)
+	}
+
+	format := "json"
+	if f, ok := args["format"].(string); ok && f != "" {
+		format = f
+	}
+	if format == "text" {
+		return report.Text(), nil
+	}
+
+	response := map[string]interface{}{
+		"digest": report,
+	}
+	if cfg := r.digestScheduler.Config(); cfg.Period != "" {
+		response["next_scheduled"] = digest.NextRun(time.Now(), cfg.Period, cfg.Hour).Format(time.RFC3339)
+	}
+
+	return marshalJSON(response)
+}
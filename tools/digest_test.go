@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/digest"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationHealthDigestResource(t *testing.T) {
+	server := truenastest.NewServer(t)
+	client := server.Client(t)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas", "version": "25.04"})
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+
+	tracker, err := capacity.NewTracker(nil, capacity.TrackerConfig{})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	scheduler := digest.NewScheduler(client, tracker, digest.SchedulerConfig{})
+	t.Cleanup(scheduler.Shutdown)
+	registry := NewRegistry(client, nil, Options{DigestScheduler: scheduler})
+
+	resource, err := registry.ReadResource(context.Background(), digest.ResourceURI)
+	if err != nil {
+		t.Fatalf("ReadResource before generation failed: %v", err)
+	}
+	if !strings.Contains(resource, "No health digest has been generated yet") {
+		t.Errorf("resource before generation = %s", resource)
+	}
+
+	server.SetRecords("alertservice.query", []map[string]interface{}{
+		{"name": "chat", "type": "Slack", "enabled": true, "attributes": map[string]interface{}{"url": "http://127.0.0.1:1/hook"}},
+	})
+	preview, err := registry.CallTool("generate_health_digest", map[string]interface{}{"alert_service": "chat", "email_to": []interface{}{"ops@example.com"}, "dry_run": true})
+	if err != nil {
+		t.Fatalf("generate_health_digest dry run failed: %v", err)
+	}
+	if actions := decodeResult(t, preview)["planned_actions"].([]interface{}); len(actions) != 3 || !strings.Contains(preview, "Slack webhook") {
+		t.Errorf("dry run planned actions = %v, want store, email, and the Slack webhook", actions)
+	}
+	if calls := server.Calls("mail.send"); len(calls) != 0 {
+		t.Errorf("dry run sent mail: %v", calls)
+	}
+	if scheduler.Latest() != nil {
+		t.Error("dry run stored the digest")
+	}
+	server.SetRecords("alertservice.query", nil)
+
+	if _, err := registry.CallTool("generate_health_digest", map[string]interface{}{"alert_service": "chat"}); err != nil {
+		t.Fatalf("generate_health_digest failed: %v", err)
+	}
+	resource, err = registry.ReadResource(context.Background(), digest.ResourceURI)
+	if err != nil {
+		t.Fatalf("ReadResource failed: %v", err)
+	}
+	response := decodeResult(t, resource)
+	report := response["digest"].(map[string]interface{})
+	if report["hostname"] != "nas" {
+		t.Errorf("digest hostname = %v, want nas", report["hostname"])
+	}
+	deliveries := report["deliveries"].([]interface{})
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %v, want mcp and the alert service", deliveries)
+	}
+	if failed := deliveries[1].(map[string]interface{}); failed["method"] != "alert_service" || failed["error"] == nil {
+		t.Errorf("alert service delivery = %v, want an error for the unknown service", failed)
+	}
+}
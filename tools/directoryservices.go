@@ -45,7 +45,7 @@ type SimplifiedDirectoryConfig struct {
 
 // Helper functions
 
-func getDirectoryServiceStatus(ctx context.Context, client *truenas.Client) (*DirectoryServiceStatus, error) {
+func getDirectoryServiceStatus(ctx context.Context, client truenas.Caller) (*DirectoryServiceStatus, error) {
 	// Use the unified directoryservices.status API
 	result, err := client.Call("directoryservices.status")
 	if err != nil {
@@ -96,7 +96,7 @@ func maskCredentials(config map[string]interface{}) map[string]interface{} {
 	masked := make(map[string]interface{})
 	for k, v := range config {
 		// Mask sensitive fields
-		if k == "bindpw" || k == "password" || k == "secret" {
+		if isSensitiveKey(k) {
 			if v != nil && v != "" {
 				masked[k] = "***MASKED***"
 			}
@@ -187,7 +187,7 @@ func validateDirectoryCredentials(args map[string]interface{}, dsType string) er
 	return nil
 }
 
-func checkDirectoryServiceForShareWarnings(ctx context.Context, client *truenas.Client) []string {
+func checkDirectoryServiceForShareWarnings(ctx context.Context, client truenas.Caller) []string {
 	warnings := []string{}
 
 	status, err := getDirectoryServiceStatus(ctx, client)
@@ -215,7 +215,7 @@ func checkDirectoryServiceForShareWarnings(ctx context.Context, client *truenas.
 }
 
 // GetTrueNASClient returns a TrueNAS client (stub for share handlers)
-func GetTrueNASClient() (*truenas.Client, error) {
+func GetTrueNASClient() (truenas.Caller, error) {
 	// This is called from share handlers but they already have a client
 	// Return error as this shouldn't be used directly
 	return nil, fmt.Errorf("client should be passed directly")
@@ -223,8 +223,7 @@ func GetTrueNASClient() (*truenas.Client, error) {
 
 // Read-only handlers
 
-func handleGetDirectoryServiceStatus(client *truenas.Client, args map[string]interface{}) (string, error) {
-	ctx := context.Background()
+func handleGetDirectoryServiceStatus(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	status, err := getDirectoryServiceStatus(ctx, client)
 	if err != nil {
 		return "", err
@@ -248,9 +247,7 @@ func handleGetDirectoryServiceStatus(client *truenas.Client, args map[string]int
 	return string(formatted), nil
 }
 
-func handleQueryDirectoryServices(client *truenas.Client, args map[string]interface{}) (string, error) {
-	ctx := context.Background()
-
+func handleQueryDirectoryServices(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Use the unified directoryservices.config API
 	result, err := client.Call("directoryservices.config")
 	if err != nil {
@@ -287,7 +284,7 @@ func handleQueryDirectoryServices(client *truenas.Client, args map[string]interf
 	return string(formatted), nil
 }
 
-func handleListDirectoryCertificates(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleListDirectoryCertificates(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Query all certificates
 	result, err := client.Call("certificate.query")
 	if err != nil {
@@ -329,9 +326,7 @@ func handleListDirectoryCertificates(client *truenas.Client, args map[string]int
 	return string(formatted), nil
 }
 
-func handleRefreshDirectoryCache(client *truenas.Client, args map[string]interface{}) (string, error) {
-	ctx := context.Background()
-
+func handleRefreshDirectoryCache(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Check which directory service is enabled
 	status, err := getDirectoryServiceStatus(ctx, client)
 	if err != nil {
@@ -369,7 +364,7 @@ func handleRefreshDirectoryCache(client *truenas.Client, args map[string]interfa
 
 // Registry write handlers
 
-func (r *Registry) handleConfigureDirectoryService(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleConfigureDirectoryService(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	dsType, ok := args["type"].(string)
 	if !ok || (dsType != "activedirectory" && dsType != "ldap") {
 		return "", fmt.Errorf("type must be 'activedirectory' or 'ldap'")
@@ -468,6 +463,7 @@ func (r *Registry) handleConfigureDirectoryService(client *truenas.Client, args
 			args,
 			jobID,
 			10*60*1000, // 10 minutes
+			client.CorrelationID(),
 		)
 		if err != nil {
 			return "", fmt.Errorf("failed to create task: %w", err)
@@ -495,9 +491,7 @@ func (r *Registry) handleConfigureDirectoryService(client *truenas.Client, args
 	return string(formatted), nil
 }
 
-func (r *Registry) handleLeaveDirectoryService(client *truenas.Client, args map[string]interface{}) (string, error) {
-	ctx := context.Background()
-
+func (r *Registry) handleLeaveDirectoryService(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Check current status
 	status, err := getDirectoryServiceStatus(ctx, client)
 	if err != nil {
@@ -533,6 +527,7 @@ func (r *Registry) handleLeaveDirectoryService(client *truenas.Client, args map[
 			args,
 			jobID,
 			5*60*1000, // 5 minutes
+			client.CorrelationID(),
 		)
 		if err != nil {
 			return "", fmt.Errorf("failed to create task: %w", err)
@@ -561,7 +556,7 @@ func (r *Registry) handleLeaveDirectoryService(client *truenas.Client, args map[
 
 // Helper function to find recent job
 
-func findRecentJob(client *truenas.Client, method string) (int, error) {
+func findRecentJob(client truenas.Caller, method string) (int, error) {
 	result, err := client.Call("core.get_jobs", []interface{}{
 		[]interface{}{"method", "=", method},
 	})
@@ -598,7 +593,7 @@ func findRecentJob(client *truenas.Client, method string) (int, error) {
 
 type configureDirectoryServiceDryRun struct{}
 
-func (d *configureDirectoryServiceDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (d *configureDirectoryServiceDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	ctx := context.Background()
 
 	dsType, ok := args["type"].(string)
@@ -697,7 +692,7 @@ func (d *configureDirectoryServiceDryRun) ExecuteDryRun(client *truenas.Client,
 
 type leaveDirectoryServiceDryRun struct{}
 
-func (d *leaveDirectoryServiceDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (d *leaveDirectoryServiceDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	ctx := context.Background()
 
 	// Get current status
@@ -787,12 +782,12 @@ func (d *leaveDirectoryServiceDryRun) ExecuteDryRun(client *truenas.Client, args
 
 // WithDryRun wrappers
 
-func (r *Registry) handleConfigureDirectoryServiceWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &configureDirectoryServiceDryRun{}, r.handleConfigureDirectoryService)
+func (r *Registry) handleConfigureDirectoryServiceWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &configureDirectoryServiceDryRun{}, r.handleConfigureDirectoryService)
 }
 
-func (r *Registry) handleLeaveDirectoryServiceWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &leaveDirectoryServiceDryRun{}, r.handleLeaveDirectoryService)
+func (r *Registry) handleLeaveDirectoryServiceWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &leaveDirectoryServiceDryRun{}, r.handleLeaveDirectoryService)
 }
 
 // Helper functions for dry-run
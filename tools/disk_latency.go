@@ -0,0 +1,268 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Disk latency outlier detection
+
+const (
+	// defaultLatencyOutlierRatio is how many times slower than its peers' median
+	// a disk must be to count as an outlier
+	defaultLatencyOutlierRatio = 2.0
+
+	// minLatencyOutlierDelta ignores outliers whose absolute difference from
+	// peers is negligible (an idle pool's 0.2ms vs 0.05ms)
+	minLatencyOutlierDelta = 1.0
+)
+
+// diskLatencyGraphs are the per-disk reporting graphs compared across pool
+// peers: time per I/O request and percentage of time busy
+var diskLatencyGraphs = []string{"disk_await", "disk_busy"}
+
+// reportingDiskName extracts the disk name from a reporting identifier
+// ("sda | Type: SSD | Model: ...")
+func reportingDiskName(identifier string) string {
+	if idx := strings.Index(identifier, " |"); idx != -1 {
+		return identifier[:idx]
+	}
+	return identifier
+}
+
+// seriesMeans averages each legend column of a reporting series, skipping nulls
+func seriesMeans(summary map[string]interface{}) map[string]float64 {
+	legend, _ := summary["legend"].([]interface{})
+	rows, _ := summary["data"].([]interface{})
+
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for _, raw := range rows {
+		row, ok := raw.([]interface{})
+		if !ok {
+			continue
+		}
+		for col := 1; col < len(row) && col < len(legend); col++ {
+			name, _ := legend[col].(string)
+			if v, ok := row[col].(float64); ok {
+				sums[name] += v
+				counts[name]++
+			}
+		}
+	}
+
+	means := make(map[string]float64, len(sums))
+	for name, sum := range sums {
+		means[name] = sum / float64(counts[name])
+	}
+	return means
+}
+
+// median returns the median of values, or 0 for none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// latencyOutliers compares each disk with the median of its peers (excluding
+// itself, so two-disk mirrors still compare) and returns the peer median of
+// every disk at least ratio times slower
+func latencyOutliers(values map[string]float64, ratio float64) map[string]float64 {
+	outliers := map[string]float64{}
+	if len(values) < 2 {
+		return outliers
+	}
+
+	for disk, value := range values {
+		peers := make([]float64, 0, len(values)-1)
+		for other, v := range values {
+			if other != disk {
+				peers = append(peers, v)
+			}
+		}
+		peerMedian := median(peers)
+		if value-peerMedian < minLatencyOutlierDelta {
+			continue
+		}
+		if peerMedian <= 0 || value/peerMedian >= ratio {
+			outliers[disk] = peerMedian
+		}
+	}
+	return outliers
+}
+
+func handleAnalyzeDiskLatency(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	unit := "DAY"
+	if u, ok := args["unit"].(string); ok && u != "" {
+		unit = u
+	}
+	ratio := defaultLatencyOutlierRatio
+	if r, ok := args["ratio"].(float64); ok {
+		if r <= 1 {
+			return "", fmt.Errorf("ratio must be greater than 1")
+		}
+		ratio = r
+	}
+	poolFilter, _ := args["pool"].(string)
+
+	filters := []interface{}{}
+	if poolFilter != "" {
+		filters = append(filters, []interface{}{"name", "=", poolFilter})
+	}
+	result, err := client.Call("pool.query", filters)
+	if err != nil {
+		return "", fmt.Errorf("failed to query pools: %w", err)
+	}
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil {
+		return "", fmt.Errorf("failed to parse pools: %w", err)
+	}
+	if poolFilter != "" && len(pools) == 0 {
+		return "", fmt.Errorf("pool '%s' not found", poolFilter)
+	}
+
+	identifiers, err := reportingIdentifiers(client, diskLatencyGraphs...)
+	if err != nil {
+		return "", fmt.Errorf("failed to query reporting graphs: %w", err)
+	}
+
+	// Per-disk mean of every graph column, keyed "graph.legend"
+	q := metricsQuery{Unit: unit, MaxPoints: math.MaxInt32, Factor: 1, Aggregation: "mean"}
+	metrics := map[string]map[string]float64{}
+	notes := []string{}
+	for _, graph := range diskLatencyGraphs {
+		if len(identifiers[graph]) == 0 {
+			notes = append(notes, fmt.Sprintf("Reporting graph '%s' is not available on this system", graph))
+			continue
+		}
+		for _, identifier := range identifiers[graph] {
+			summaries, err := fetchReportingGraph(client, graph, identifier, q)
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("%s for %s unavailable: %v", graph, reportingDiskName(identifier), err))
+				continue
+			}
+			disk := reportingDiskName(identifier)
+			for _, summary := range summaries {
+				for legend, mean := range seriesMeans(summary) {
+					if metrics[disk] == nil {
+						metrics[disk] = map[string]float64{}
+					}
+					metrics[disk][graph+"."+legend] = mean
+				}
+			}
+		}
+	}
+	if len(metrics) == 0 {
+		return marshalJSON(map[string]interface{}{
+			"pools":            []interface{}{},
+			"message":          "No per-disk latency data is available",
+			"collection_notes": notes,
+		})
+	}
+
+	poolReports := []map[string]interface{}{}
+	suspects := []map[string]interface{}{}
+	for _, pool := range pools {
+		poolName, _ := pool["name"].(string)
+
+		// Peers share a pool and vdev role; cache and log devices behave differently
+		groups := map[string][]map[string]interface{}{}
+		for _, disk := range collectPoolDisks(pool) {
+			role, _ := disk["role"].(string)
+			groups[role] = append(groups[role], disk)
+		}
+
+		roles := make([]string, 0, len(groups))
+		for role := range groups {
+			roles = append(roles, role)
+		}
+		sort.Strings(roles)
+
+		disks := []map[string]interface{}{}
+		for _, role := range roles {
+			byMetric := map[string]map[string]float64{}
+			for _, disk := range groups[role] {
+				name, _ := disk["disk"].(string)
+				for metric, value := range metrics[name] {
+					if byMetric[metric] == nil {
+						byMetric[metric] = map[string]float64{}
+					}
+					byMetric[metric][name] = value
+				}
+			}
+
+			flagged := map[string][]string{}
+			for metric, values := range byMetric {
+				outliers := latencyOutliers(values, ratio)
+				for disk, peerMedian := range outliers {
+					flagged[disk] = append(flagged[disk], fmt.Sprintf("%s %.1f vs peer median %.1f", metric, values[disk], peerMedian))
+				}
+			}
+
+			for _, disk := range groups[role] {
+				name, _ := disk["disk"].(string)
+				entry := map[string]interface{}{
+					"disk":    name,
+					"role":    role,
+					"metrics": metrics[name],
+				}
+				if metrics[name] == nil {
+					entry["note"] = "No reporting data for this disk"
+				}
+				if reasons, ok := flagged[name]; ok {
+					sort.Strings(reasons)
+					entry["outlier"] = true
+					entry["reasons"] = reasons
+					suspect := map[string]interface{}{
+						"pool":    poolName,
+						"disk":    name,
+						"reasons": reasons,
+					}
+					if errs, _ := disk["errors"].(int); errs > 0 {
+						suspect["zfs_errors"] = errs
+					}
+					suspects = append(suspects, suspect)
+				}
+				disks = append(disks, entry)
+			}
+		}
+
+		poolReports = append(poolReports, map[string]interface{}{
+			"pool":  poolName,
+			"disks": disks,
+		})
+	}
+
+	response := map[string]interface{}{
+		"unit":     unit,
+		"ratio":    ratio,
+		"pools":    poolReports,
+		"outliers": suspects,
+		"method":   fmt.Sprintf("A disk is an outlier when its mean is at least %.1fx the median of its peers in the same pool and vdev role", ratio),
+		"graphs":   diskLatencyGraphs,
+	}
+	if len(suspects) > 0 {
+		response["recommendation"] = "A disk consistently slower than identical peers is often failing before SMART reports it. Check its SMART results and error counters with storage_health_report, run a long SMART test, and plan a replacement if errors appear."
+	} else {
+		response["message"] = "No disk is a latency outlier versus its pool peers"
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+
+	return marshalJSON(response)
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"testing"
+)
+
+func TestLatencyOutliers(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]float64
+		want   []string
+	}{
+		{name: "uniform", values: map[string]float64{"sda": 8, "sdb": 9, "sdc": 8.5, "sdd": 7.5}},
+		{name: "one slow disk", values: map[string]float64{"sda": 8, "sdb": 9, "sdc": 40, "sdd": 7.5}, want: []string{"sdc"}},
+		{name: "mirror", values: map[string]float64{"sda": 4, "sdb": 12}, want: []string{"sdb"}},
+		{name: "negligible delta", values: map[string]float64{"sda": 0.1, "sdb": 0.1, "sdc": 0.6}},
+		{name: "single disk", values: map[string]float64{"sda": 50}},
+	}
+	for _, tt := range tests {
+		got := latencyOutliers(tt.values, defaultLatencyOutlierRatio)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: outliers = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for _, disk := range tt.want {
+			if _, ok := got[disk]; !ok {
+				t.Errorf("%s: %s not flagged in %v", tt.name, disk, got)
+			}
+		}
+	}
+}
+
+func TestSeriesMeans(t *testing.T) {
+	summary := map[string]interface{}{
+		"legend": []interface{}{"time", "reads", "writes"},
+		"data": []interface{}{
+			[]interface{}{float64(1), float64(2), float64(10)},
+			[]interface{}{float64(2), float64(4), nil},
+		},
+	}
+	means := seriesMeans(summary)
+	if means["reads"] != 3 || means["writes"] != 10 {
+		t.Errorf("seriesMeans = %v, want reads 3 and writes 10", means)
+	}
+}
+
+func TestIntegrationAnalyzeDiskLatency(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("pool.query", []interface{}{
+		map[string]interface{}{
+			"name": "tank",
+			"topology": map[string]interface{}{
+				"data": []interface{}{
+					map[string]interface{}{"children": []interface{}{
+						map[string]interface{}{"disk": "sda", "status": "ONLINE"},
+						map[string]interface{}{"disk": "sdb", "status": "ONLINE"},
+						map[string]interface{}{"disk": "sdc", "status": "ONLINE", "stats": map[string]interface{}{"read_errors": float64(2)}},
+					}},
+				},
+			},
+		},
+	})
+	server.SetResult("reporting.graphs", []interface{}{
+		map[string]interface{}{"name": "disk_await", "identifiers": []interface{}{"sda | Type: HDD", "sdb | Type: HDD", "sdc | Type: HDD"}},
+	})
+	await := map[string]float64{"sda": 8, "sdb": 9, "sdc": 45}
+	server.Handle("reporting.get_data", func(params []interface{}) (interface{}, error) {
+		query := params[0].([]interface{})[0].(map[string]interface{})
+		disk := reportingDiskName(query["identifier"].(string))
+		return []interface{}{map[string]interface{}{
+			"name":   "disk_await",
+			"legend": []interface{}{"time", "await"},
+			"data":   []interface{}{[]interface{}{float64(1), await[disk]}, []interface{}{float64(2), await[disk]}},
+		}}, nil
+	})
+
+	result, err := registry.CallTool("analyze_disk_latency", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("analyze_disk_latency failed: %v", err)
+	}
+	response := decodeResult(t, result)
+
+	outliers, _ := response["outliers"].([]interface{})
+	if len(outliers) != 1 {
+		t.Fatalf("outliers = %v, want only sdc", response["outliers"])
+	}
+	suspect := outliers[0].(map[string]interface{})
+	if suspect["disk"] != "sdc" || suspect["zfs_errors"] != float64(2) {
+		t.Errorf("outlier = %v, want sdc with 2 ZFS errors", suspect)
+	}
+	notes, _ := response["collection_notes"].([]interface{})
+	if len(notes) != 1 {
+		t.Errorf("collection_notes = %v, want missing disk_busy graph noted", notes)
+	}
+}
@@ -0,0 +1,568 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Disk inventory and SMART health
+
+// smartTestTypes are the self-tests run_smart_test can start
+var smartTestTypes = map[string]bool{"SHORT": true, "LONG": true, "CONVEYANCE": true}
+
+// Drive temperatures (Celsius) worth flagging; most drives are rated to 60
+const (
+	diskTempWarning  = 50
+	diskTempCritical = 60
+)
+
+// SMART verdicts, from best to worst
+const (
+	smartPass    = "PASS"
+	smartUnknown = "UNKNOWN"
+	smartWarning = "WARNING"
+	smartFail    = "FAIL"
+)
+
+var smartVerdictRank = map[string]int{smartPass: 0, smartUnknown: 1, smartWarning: 2, smartFail: 3}
+
+func worseSMARTVerdict(a, b string) string {
+	if smartVerdictRank[b] > smartVerdictRank[a] {
+		return b
+	}
+	return a
+}
+
+// queryDisks returns disk.query with the pool each disk belongs to
+func queryDisks(client truenas.Caller, filters []interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call("disk.query", filters, map[string]interface{}{
+		"extra": map[string]interface{}{"pools": true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disks: %w", err)
+	}
+
+	var disks []map[string]interface{}
+	if err := json.Unmarshal(result, &disks); err != nil {
+		return nil, fmt.Errorf("failed to parse disks: %w", err)
+	}
+	return disks, nil
+}
+
+// smartResultsByDisk returns smart.test.results keyed by disk name
+func smartResultsByDisk(client truenas.Caller) (map[string]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	if err := queryInto(client, "smart.test.results", &results); err != nil {
+		return nil, err
+	}
+	byDisk := map[string]map[string]interface{}{}
+	for _, result := range results {
+		if name, ok := result["disk"].(string); ok {
+			byDisk[name] = result
+		}
+	}
+	return byDisk, nil
+}
+
+// diskTemperatures returns the current temperature of each named disk. Disks
+// that do not report one (or are spun down) are absent.
+func diskTemperatures(client truenas.Caller, names []string) (map[string]float64, error) {
+	result, err := client.Call("disk.temperatures", names)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse disk temperatures: %w", err)
+	}
+	temps := map[string]float64{}
+	for name, value := range raw {
+		if temp, ok := value.(float64); ok {
+			temps[name] = temp
+		}
+	}
+	return temps, nil
+}
+
+// simplifySMARTTest summarizes one self-test log entry
+func simplifySMARTTest(test map[string]interface{}) map[string]interface{} {
+	simplified := map[string]interface{}{
+		"type":   test["description"],
+		"status": test["status"],
+	}
+	if verbose, ok := test["status_verbose"].(string); ok && verbose != "" {
+		simplified["status_detail"] = verbose
+	}
+	if hours, ok := test["lifetime"].(float64); ok {
+		simplified["power_on_hours"] = hours
+	}
+	if lba, ok := test["lba_of_first_error"]; ok && lba != nil {
+		simplified["first_error_lba"] = lba
+	}
+	return simplified
+}
+
+// smartTestSummary describes the latest self-test of a disk and any test in
+// progress
+func smartTestSummary(result map[string]interface{}, limit int) map[string]interface{} {
+	summary := map[string]interface{}{"tested": false}
+	tests, _ := result["tests"].([]interface{})
+	history := []map[string]interface{}{}
+	for i, raw := range tests {
+		if i >= limit {
+			break
+		}
+		if test, ok := raw.(map[string]interface{}); ok {
+			history = append(history, simplifySMARTTest(test))
+		}
+	}
+	// Tests are returned newest first
+	if len(history) > 0 {
+		summary["tested"] = true
+		summary["last_type"] = history[0]["type"]
+		summary["last_status"] = history[0]["status"]
+		summary["history"] = history
+	}
+	if current, ok := result["current_test"].(map[string]interface{}); ok {
+		summary["running"] = true
+		summary["progress_percent"] = current["progress"]
+	}
+	return summary
+}
+
+// smartAttributeNames are the ATA attributes that predict failure, by ID
+var smartAttributeNames = map[int]string{
+	5:   "reallocated_sectors",
+	9:   "power_on_hours",
+	187: "reported_uncorrectable",
+	188: "command_timeouts",
+	194: "temperature",
+	197: "pending_sectors",
+	198: "offline_uncorrectable",
+	199: "udma_crc_errors",
+}
+
+// smartCountersThatWarn are the counters where any nonzero value is a sign
+// of a failing disk (CRC errors usually mean a bad cable instead)
+var smartCountersThatWarn = []string{"reallocated_sectors", "pending_sectors", "offline_uncorrectable", "reported_uncorrectable", "media_errors"}
+
+// simplifySMARTAttributes reduces disk.smart_attributes output to the
+// counters that matter and the attributes that crossed their threshold. ATA
+// disks report a list of attributes, NVMe disks the health information log.
+func simplifySMARTAttributes(raw interface{}) (map[string]interface{}, []string) {
+	simplified := map[string]interface{}{}
+	failing := []string{}
+
+	switch attrs := raw.(type) {
+	case []interface{}:
+		for _, item := range attrs {
+			attr, _ := item.(map[string]interface{})
+			id, _ := attr["id"].(float64)
+			rawValue := attr["raw"]
+			if r, ok := rawValue.(map[string]interface{}); ok {
+				rawValue = r["value"]
+			}
+			if name, ok := smartAttributeNames[int(id)]; ok {
+				simplified[name] = rawValue
+			}
+			// A normalized value at or below a nonzero threshold is the
+			// drive's own failure prediction
+			value, _ := attr["value"].(float64)
+			thresh, _ := attr["thresh"].(float64)
+			if thresh > 0 && value <= thresh {
+				failing = append(failing, fmt.Sprintf("%v (value %.0f, threshold %.0f)", attr["name"], value, thresh))
+			}
+		}
+	case map[string]interface{}:
+		for _, field := range []string{"critical_warning", "temperature", "available_spare", "percentage_used", "media_errors", "power_on_hours", "unsafe_shutdowns"} {
+			if v, ok := attrs[field]; ok {
+				simplified[field] = v
+			}
+		}
+		if warning, _ := attrs["critical_warning"].(float64); warning != 0 {
+			failing = append(failing, fmt.Sprintf("NVMe critical warning %#x", int(warning)))
+		}
+	}
+	return simplified, failing
+}
+
+// smartVerdict rates a disk from its latest self-test, attributes, and
+// temperature, with the reasons for anything short of PASS
+func smartVerdict(tests map[string]interface{}, attributes map[string]interface{}, failing []string, temp float64, hasTemp bool) (string, []string) {
+	verdict := smartUnknown
+	reasons := []string{}
+	flag := func(level, reason string) {
+		verdict = worseSMARTVerdict(verdict, level)
+		reasons = append(reasons, reason)
+	}
+
+	if tests["tested"] == true {
+		status, _ := tests["last_status"].(string)
+		if status == "SUCCESS" || status == "RUNNING" {
+			verdict = smartPass
+		} else if status != "" {
+			flag(smartFail, fmt.Sprintf("Latest %v self-test reported %s", tests["last_type"], status))
+		}
+	} else {
+		reasons = append(reasons, "No self-test has been run")
+	}
+
+	for _, attr := range failing {
+		flag(smartFail, fmt.Sprintf("Attribute below its failure threshold: %s", attr))
+	}
+	for _, counter := range smartCountersThatWarn {
+		if count, _ := attributes[counter].(float64); count > 0 {
+			flag(smartWarning, fmt.Sprintf("%s is %.0f", strings.ReplaceAll(counter, "_", " "), count))
+		}
+	}
+	if hasTemp {
+		switch {
+		case temp >= diskTempCritical:
+			flag(smartFail, fmt.Sprintf("Temperature %.0f°C is at or above %d°C", temp, diskTempCritical))
+		case temp >= diskTempWarning:
+			flag(smartWarning, fmt.Sprintf("Temperature %.0f°C is at or above %d°C", temp, diskTempWarning))
+		}
+	}
+	return verdict, reasons
+}
+
+func handleQueryDisks(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filters := []interface{}{}
+	if pool, ok := args["pool"].(string); ok && pool != "" {
+		filters = append(filters, []interface{}{"pool", "=", pool})
+	}
+	disks, err := queryDisks(client, filters)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		a, _ := disks[i]["name"].(string)
+		b, _ := disks[j]["name"].(string)
+		return a < b
+	})
+
+	// SMART results and temperatures are best-effort
+	notes := []string{}
+	smartByDisk, err := smartResultsByDisk(client)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("SMART results unavailable: %v", err))
+	}
+	names := make([]string, 0, len(disks))
+	for _, disk := range disks {
+		if name, ok := disk["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	temps, err := diskTemperatures(client, names)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("Temperatures unavailable: %v", err))
+	}
+
+	simplified := make([]map[string]interface{}, 0, len(disks))
+	unassigned := 0
+	for _, disk := range disks {
+		name, _ := disk["name"].(string)
+		size, _ := disk["size"].(float64)
+		entry := map[string]interface{}{
+			"name":          name,
+			"serial":        disk["serial"],
+			"model":         disk["model"],
+			"type":          disk["type"],
+			"size":          units.FormatBytes(int64(size)),
+			"size_bytes":    int64(size),
+			"pool":          disk["pool"],
+			"smart_enabled": disk["togglesmart"],
+		}
+		if rpm, ok := disk["rotationrate"].(float64); ok && rpm > 0 {
+			entry["rpm"] = rpm
+		}
+		if pool, _ := disk["pool"].(string); pool == "" {
+			unassigned++
+		}
+		temp, hasTemp := temps[name]
+		if hasTemp {
+			entry["temperature_c"] = temp
+		}
+		tests := smartTestSummary(smartByDisk[name], 1)
+		delete(tests, "history")
+		entry["smart"] = tests
+		verdict, reasons := smartVerdict(tests, nil, nil, temp, hasTemp)
+		entry["health"] = verdict
+		if len(reasons) > 0 {
+			entry["health_reasons"] = reasons
+		}
+		simplified = append(simplified, entry)
+	}
+
+	response := map[string]interface{}{
+		"disks":      simplified,
+		"count":      len(simplified),
+		"unassigned": unassigned,
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+	return marshalJSON(response)
+}
+
+func handleGetSMARTResults(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	limit := 5
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	filters := []interface{}{}
+	if name, ok := args["disk"].(string); ok && name != "" {
+		filters = append(filters, []interface{}{"name", "=", name})
+	}
+	disks, err := queryDisks(client, filters)
+	if err != nil {
+		return "", err
+	}
+	if len(filters) > 0 && len(disks) == 0 {
+		return "", newToolError(ErrorNotFound, "disk %v not found", args["disk"])
+	}
+
+	smartByDisk, err := smartResultsByDisk(client)
+	if err != nil {
+		return "", fmt.Errorf("failed to get SMART results: %w", err)
+	}
+	names := make([]string, 0, len(disks))
+	for _, disk := range disks {
+		if name, ok := disk["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	notes := []string{}
+	temps, err := diskTemperatures(client, names)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("Temperatures unavailable: %v", err))
+	}
+
+	results := make([]map[string]interface{}, 0, len(names))
+	counts := map[string]int{}
+	for _, name := range names {
+		tests := smartTestSummary(smartByDisk[name], limit)
+		entry := map[string]interface{}{
+			"disk":  name,
+			"tests": tests,
+		}
+
+		var attributes map[string]interface{}
+		var failing []string
+		if result, err := client.Call("disk.smart_attributes", name); err == nil {
+			var raw interface{}
+			if err := json.Unmarshal(result, &raw); err == nil {
+				attributes, failing = simplifySMARTAttributes(raw)
+				entry["attributes"] = attributes
+			}
+		} else {
+			notes = append(notes, fmt.Sprintf("SMART attributes of %s unavailable: %v", name, err))
+		}
+
+		temp, hasTemp := temps[name]
+		if !hasTemp {
+			if t, ok := attributes["temperature"].(float64); ok {
+				temp, hasTemp = t, true
+			}
+		}
+		if hasTemp {
+			entry["temperature_c"] = temp
+		}
+
+		verdict, reasons := smartVerdict(tests, attributes, failing, temp, hasTemp)
+		entry["verdict"] = verdict
+		if len(reasons) > 0 {
+			entry["reasons"] = reasons
+		}
+		counts[verdict]++
+		results = append(results, entry)
+	}
+
+	response := map[string]interface{}{
+		"disks":   results,
+		"count":   len(results),
+		"summary": counts,
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+	return marshalJSON(response)
+}
+
+// smartTestRequest validates run_smart_test arguments and returns the test
+// type and disk names
+func smartTestRequest(client truenas.Caller, args map[string]interface{}) (string, []map[string]interface{}, error) {
+	testType, _ := args["type"].(string)
+	testType = strings.ToUpper(testType)
+	if !smartTestTypes[testType] {
+		return "", nil, newToolError(ErrorValidation, "type must be SHORT, LONG, or CONVEYANCE (got: %v)", args["type"])
+	}
+	names := stringList(args["disks"])
+	if len(names) == 0 {
+		return "", nil, fmt.Errorf("disks is required")
+	}
+
+	disks, err := queryDisks(client, []interface{}{})
+	if err != nil {
+		return "", nil, err
+	}
+	byName := map[string]map[string]interface{}{}
+	for _, disk := range disks {
+		if name, ok := disk["name"].(string); ok {
+			byName[name] = disk
+		}
+	}
+
+	selected := []map[string]interface{}{}
+	for _, name := range names {
+		disk, ok := byName[name]
+		if !ok {
+			return "", nil, newToolError(ErrorNotFound, "disk %s not found", name)
+		}
+		selected = append(selected, disk)
+	}
+
+	// A disk runs one self-test at a time
+	if smartByDisk, err := smartResultsByDisk(client); err == nil {
+		for _, name := range names {
+			if current, ok := smartByDisk[name]["current_test"].(map[string]interface{}); ok {
+				return "", nil, newToolError(ErrorInProgress, "a SMART test is already running on %s (%v%% done)", name, current["progress"])
+			}
+		}
+	}
+	return testType, selected, nil
+}
+
+// smartTestTimeout bounds how long a test of the type is tracked; long tests
+// of large disks take most of a day
+func smartTestTimeout(testType string) time.Duration {
+	if testType == "LONG" {
+		return 48 * time.Hour
+	}
+	return time.Hour
+}
+
+func (r *Registry) handleRunSMARTTest(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	testType, disks, err := smartTestRequest(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	tests := make([]interface{}, 0, len(disks))
+	for _, disk := range disks {
+		tests = append(tests, map[string]interface{}{"identifier": disk["identifier"], "type": testType})
+	}
+	result, err := client.Call("smart.test.manual_test", tests)
+	if err != nil {
+		return "", fmt.Errorf("failed to start SMART test: %w", err)
+	}
+	var started []map[string]interface{}
+	if err := json.Unmarshal(result, &started); err != nil {
+		return "", fmt.Errorf("failed to parse SMART test result: %w", err)
+	}
+
+	// Newer versions return a job per disk that finishes with the test
+	entries := make([]map[string]interface{}, 0, len(started))
+	failed := []string{}
+	tracked := 0
+	for _, s := range started {
+		entry := map[string]interface{}{
+			"disk":                 s["disk"],
+			"expected_result_time": s["expected_result_time"],
+		}
+		if errMsg, ok := s["error"].(string); ok && errMsg != "" {
+			entry["error"] = errMsg
+			failed = append(failed, fmt.Sprintf("%v: %s", s["disk"], errMsg))
+		} else if jobID, ok := s["job"].(float64); ok {
+			task, err := r.taskManager.CreateJobTask("run_smart_test", args, int(jobID), smartTestTimeout(testType), client.CorrelationID())
+			if err != nil {
+				return "", fmt.Errorf("failed to create task: %w", err)
+			}
+			entry["job_id"] = int(jobID)
+			entry["task_id"] = task.TaskID
+			tracked++
+		}
+		entries = append(entries, entry)
+	}
+	if len(failed) == len(started) {
+		return "", newToolError(ErrorPrecondition, "SMART test did not start: %s", strings.Join(failed, "; "))
+	}
+
+	message := fmt.Sprintf("%s SMART test started on %d disk(s).", testType, len(started)-len(failed))
+	if tracked > 0 {
+		message += " Track each with tasks_get using its task_id, then read the outcome with get_smart_results."
+	} else {
+		message += " Check get_smart_results after the expected result time."
+	}
+	response := map[string]interface{}{
+		"type":    testType,
+		"tests":   entries,
+		"message": message,
+	}
+	if len(failed) > 0 {
+		response["failed"] = failed
+	}
+	return marshalJSON(response)
+}
+
+// Dry-run wrappers
+
+func (r *Registry) handleRunSMARTTestWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &runSMARTTestDryRun{}, r.handleRunSMARTTest)
+}
+
+// Dry-run implementations
+
+type runSMARTTestDryRun struct{}
+
+func (d *runSMARTTestDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	testType, disks, err := smartTestRequest(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]PlannedAction, 0, len(disks))
+	pools := map[string]bool{}
+	for i, disk := range disks {
+		actions = append(actions, PlannedAction{
+			Step:        i + 1,
+			Description: fmt.Sprintf("Start a %s SMART self-test on %v (%v)", testType, disk["name"], disk["serial"]),
+			Operation:   "run",
+			Target:      fmt.Sprintf("%v", disk["name"]),
+		})
+		if pool, _ := disk["pool"].(string); pool != "" {
+			pools[pool] = true
+		}
+	}
+
+	warnings := []string{}
+	switch testType {
+	case "LONG":
+		warnings = append(warnings, "A long test reads the whole disk and can take many hours; pool I/O is slower meanwhile")
+	case "CONVEYANCE":
+		warnings = append(warnings, "Conveyance tests are only supported by some ATA disks; others reject them")
+	}
+	if testType == "LONG" && len(pools) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Avoid overlapping with a scrub of %s", strings.Join(mapKeys(pools), ", ")))
+	}
+
+	return &DryRunResult{
+		Tool: "run_smart_test",
+		CurrentState: map[string]interface{}{
+			"type":  testType,
+			"disks": len(disks),
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationSMART(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("disk.query", []map[string]interface{}{
+		{"name": "sda", "identifier": "{serial}A1", "serial": "A1", "model": "WD Red", "type": "HDD", "size": float64(4000787030016), "pool": "tank", "togglesmart": true, "rotationrate": float64(5400)},
+		{"name": "sdb", "identifier": "{serial}B2", "serial": "B2", "model": "WD Red", "type": "HDD", "size": float64(4000787030016), "pool": "tank", "togglesmart": true},
+		{"name": "nvme0n1", "identifier": "{serial}N3", "serial": "N3", "model": "Samsung 980", "type": "SSD", "size": float64(1000204886016), "pool": nil, "togglesmart": true},
+	})
+	server.SetResult("smart.test.results", []map[string]interface{}{
+		{"disk": "sda", "tests": []interface{}{
+			map[string]interface{}{"num": float64(1), "description": "Short offline", "status": "SUCCESS", "lifetime": float64(20000)},
+		}},
+		{"disk": "sdb", "tests": []interface{}{
+			map[string]interface{}{"num": float64(1), "description": "Extended offline", "status": "FAILED", "status_verbose": "Completed: read failure", "lba_of_first_error": float64(123456)},
+		}},
+	})
+	server.SetResult("disk.temperatures", map[string]interface{}{"sda": float64(34), "sdb": float64(52), "nvme0n1": nil})
+	server.Handle("disk.smart_attributes", func(params []interface{}) (interface{}, error) {
+		switch params[0] {
+		case "sda":
+			return []interface{}{
+				map[string]interface{}{"id": float64(5), "name": "Reallocated_Sector_Ct", "value": float64(200), "thresh": float64(140), "raw": map[string]interface{}{"value": float64(0)}},
+				map[string]interface{}{"id": float64(197), "name": "Current_Pending_Sector", "value": float64(200), "thresh": float64(0), "raw": map[string]interface{}{"value": float64(0)}},
+			}, nil
+		case "sdb":
+			return []interface{}{
+				map[string]interface{}{"id": float64(5), "name": "Reallocated_Sector_Ct", "value": float64(100), "thresh": float64(140), "raw": map[string]interface{}{"value": float64(1840)}},
+			}, nil
+		}
+		return map[string]interface{}{"critical_warning": float64(0), "temperature": float64(41), "percentage_used": float64(3), "media_errors": float64(0)}, nil
+	})
+
+	result, err := registry.CallTool("query_disks", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_disks failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	disks := response["disks"].([]interface{})
+	if len(disks) != 3 || response["unassigned"] != float64(1) {
+		t.Fatalf("query_disks = %v", response)
+	}
+	health := map[string]interface{}{}
+	for _, raw := range disks {
+		disk := raw.(map[string]interface{})
+		health[disk["name"].(string)] = disk["health"]
+	}
+	if health["sda"] != "PASS" || health["sdb"] != "FAIL" || health["nvme0n1"] != "UNKNOWN" {
+		t.Errorf("query_disks health = %v", health)
+	}
+
+	result, err = registry.CallTool("get_smart_results", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_smart_results failed: %v", err)
+	}
+	response = decodeResult(t, result)
+	verdicts := map[string]map[string]interface{}{}
+	for _, raw := range response["disks"].([]interface{}) {
+		disk := raw.(map[string]interface{})
+		verdicts[disk["disk"].(string)] = disk
+	}
+	if verdicts["sda"]["verdict"] != "PASS" {
+		t.Errorf("sda = %v, want PASS", verdicts["sda"])
+	}
+	sdb := verdicts["sdb"]
+	if sdb["verdict"] != "FAIL" || !strings.Contains(fmt.Sprint(sdb["reasons"]), "Reallocated_Sector_Ct") || !strings.Contains(fmt.Sprint(sdb["reasons"]), "Temperature 52") {
+		t.Errorf("sdb = %v, want FAIL with the attribute threshold and temperature", sdb)
+	}
+	if sdb["attributes"].(map[string]interface{})["reallocated_sectors"] != float64(1840) {
+		t.Errorf("sdb attributes = %v", sdb["attributes"])
+	}
+	if nvme := verdicts["nvme0n1"]; nvme["temperature_c"] != float64(41) || nvme["attributes"].(map[string]interface{})["percentage_used"] != float64(3) {
+		t.Errorf("nvme0n1 = %v, want the NVMe health log", nvme)
+	}
+
+	if _, err := registry.CallTool("get_smart_results", map[string]interface{}{"disk": "sdz"}); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown disk error = %v, want NOT_FOUND", err)
+	}
+
+	// Dry run, then a long test tracked as a job
+	result, err = registry.CallTool("run_smart_test", map[string]interface{}{"disks": []interface{}{"sda"}, "type": "long", "dry_run": true})
+	if err != nil {
+		t.Fatalf("run_smart_test dry run failed: %v", err)
+	}
+	if len(server.Calls("smart.test.manual_test")) != 0 || !strings.Contains(result, "many hours") {
+		t.Errorf("dry run started a test or lacks the long test warning: %s", result)
+	}
+
+	jobID := server.AddJob("smart.test.wait", []interface{}{"sda"}, truenastest.JobSpec{Steps: 100})
+	server.SetResult("smart.test.manual_test", []interface{}{
+		map[string]interface{}{"disk": "sda", "identifier": "{serial}A1", "expected_result_time": "2026-10-16T12:00:00", "job": float64(jobID)},
+	})
+	result, err = registry.CallTool("run_smart_test", map[string]interface{}{"disks": []interface{}{"sda"}, "type": "LONG"})
+	if err != nil {
+		t.Fatalf("run_smart_test failed: %v", err)
+	}
+	started := decodeResult(t, result)["tests"].([]interface{})[0].(map[string]interface{})
+	if started["task_id"] == nil || started["job_id"] != float64(jobID) {
+		t.Errorf("started test = %v, want a tracked job", started)
+	}
+	request := server.Calls("smart.test.manual_test")[0].Params[0].([]interface{})[0].(map[string]interface{})
+	if request["identifier"] != "{serial}A1" || request["type"] != "LONG" {
+		t.Errorf("manual_test request = %v", request)
+	}
+
+	// Invalid requests and disks already under test
+	if _, err := registry.CallTool("run_smart_test", map[string]interface{}{"disks": []interface{}{"sdb"}, "type": "OFFLINE"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("invalid type error = %v, want VALIDATION", err)
+	}
+	server.SetResult("smart.test.results", []map[string]interface{}{
+		{"disk": "sdb", "tests": []interface{}{}, "current_test": map[string]interface{}{"progress": float64(40)}},
+	})
+	if _, err := registry.CallTool("run_smart_test", map[string]interface{}{"disks": []interface{}{"sdb"}, "type": "SHORT"}); err == nil || ClassifyError(err).Code != ErrorInProgress {
+		t.Errorf("busy disk error = %v, want IN_PROGRESS", err)
+	}
+}
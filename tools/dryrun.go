@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/truenas/truenas-mcp/truenas"
@@ -8,7 +9,7 @@ import (
 
 // DryRunnable is implemented by tools that support dry-run mode
 type DryRunnable interface {
-	ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error)
+	ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error)
 }
 
 // DryRunResult represents the preview of changes that would be made
@@ -48,16 +49,17 @@ type EstimatedTime struct {
 // ExecuteWithDryRun wraps a handler to support dry-run mode
 // If dry_run is true, calls ExecuteDryRun; otherwise calls normalHandler
 func ExecuteWithDryRun(
-	client *truenas.Client,
+	ctx context.Context,
+	client truenas.Caller,
 	args map[string]interface{},
 	dryRunnable DryRunnable,
-	normalHandler func(*truenas.Client, map[string]interface{}) (string, error),
+	normalHandler func(context.Context, truenas.Caller, map[string]interface{}) (string, error),
 ) (string, error) {
 	// Check if dry_run is requested
 	dryRun, ok := args["dry_run"].(bool)
 	if !ok || !dryRun {
 		// Normal execution
-		return normalHandler(client, args)
+		return normalHandler(ctx, client, args)
 	}
 
 	// Dry-run execution
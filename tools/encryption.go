@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Dataset encryption audit
+
+// Where the key of an encryption root is kept
+const (
+	keyStoragePassphrase = "passphrase" // Not stored; entered at unlock
+	keyStorageDatabase   = "system_database"
+	keyStorageKMIP       = "kmip"
+)
+
+// datasetStringProperty returns a ZFS property's parsed value as a lowercase
+// string (e.g. key_format "hex"), or "" when unset
+func datasetStringProperty(ds map[string]interface{}, prop string) string {
+	propMap, ok := ds[prop].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if parsed, ok := propMap["parsed"].(string); ok && parsed != "" {
+		return strings.ToLower(parsed)
+	}
+	value, _ := propMap["value"].(string)
+	return strings.ToLower(value)
+}
+
+// getKMIPConfig returns kmip.config, or nil when KMIP is unavailable
+func getKMIPConfig(client truenas.Caller) map[string]interface{} {
+	result, err := client.Call("kmip.config")
+	if err != nil {
+		return nil
+	}
+	var config map[string]interface{}
+	if json.Unmarshal(result, &config) != nil {
+		return nil
+	}
+	return config
+}
+
+// kmipManagesZFSKeys reports whether dataset keys are kept on a KMIP server
+func kmipManagesZFSKeys(config map[string]interface{}) bool {
+	enabled, _ := config["enabled"].(bool)
+	manage, _ := config["manage_zfs_keys"].(bool)
+	return enabled && manage
+}
+
+// encryptionRootAudit decides where a root's key lives and whether the root
+// unlocks by itself after a reboot
+func encryptionRootAudit(root map[string]interface{}, kmip map[string]interface{}) map[string]interface{} {
+	keyFormat := datasetStringProperty(root, "key_format")
+	locked, _ := root["locked"].(bool)
+
+	audit := map[string]interface{}{
+		"key_format": keyFormat,
+		"locked":     locked,
+	}
+	switch {
+	case keyFormat == "passphrase":
+		audit["key_storage"] = keyStoragePassphrase
+		audit["auto_unlock"] = false
+		audit["reason"] = "Passphrase-encrypted: it stays locked after every reboot until the passphrase is entered"
+	case kmipManagesZFSKeys(kmip):
+		audit["key_storage"] = keyStorageKMIP
+		audit["auto_unlock"] = true
+		audit["reason"] = fmt.Sprintf("Unlocks at boot only if the KMIP server %v is reachable", kmip["server"])
+	case locked:
+		// Key-encrypted roots whose key is in the database are unlocked at
+		// boot, so a locked one most likely has no stored key
+		audit["key_storage"] = keyStorageDatabase
+		audit["auto_unlock"] = false
+		audit["reason"] = "Locked although it uses a key: the key is probably missing from the system database (e.g. an imported pool). Unlock it with its key file, then export the keys."
+	default:
+		audit["key_storage"] = keyStorageDatabase
+		audit["auto_unlock"] = true
+	}
+	return audit
+}
+
+func handleAuditEncryption(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filters := []interface{}{
+		[]interface{}{"encrypted", "=", true},
+	}
+	poolFilter, _ := args["pool"].(string)
+	if poolFilter != "" {
+		filters = append(filters, []interface{}{"pool", "=", poolFilter})
+	}
+
+	result, err := client.Call("pool.dataset.query", filters)
+	if err != nil {
+		return "", fmt.Errorf("failed to query datasets: %w", err)
+	}
+
+	var datasets []map[string]interface{}
+	if err := json.Unmarshal(result, &datasets); err != nil {
+		return "", fmt.Errorf("failed to parse datasets: %w", err)
+	}
+	sort.Slice(datasets, func(i, j int) bool {
+		a, _ := datasets[i]["name"].(string)
+		b, _ := datasets[j]["name"].(string)
+		return a < b
+	})
+
+	kmip := getKMIPConfig(client)
+
+	roots := map[string]map[string]interface{}{}
+	for _, ds := range datasets {
+		name, _ := ds["name"].(string)
+		if root, _ := ds["encryption_root"].(string); root == name {
+			roots[name] = encryptionRootAudit(ds, kmip)
+		}
+	}
+
+	entries := []map[string]interface{}{}
+	// Datasets are sorted by name, so pools are met in order
+	pools := map[string]map[string]interface{}{}
+	poolSummaries := []map[string]interface{}{}
+	notAutoUnlocking := []string{}
+	keyEncrypted := false
+	for _, ds := range datasets {
+		name, _ := ds["name"].(string)
+		rootName, _ := ds["encryption_root"].(string)
+		locked, _ := ds["locked"].(bool)
+
+		entry := map[string]interface{}{
+			"name":            name,
+			"encryption_root": rootName,
+			"is_root":         rootName == name,
+			"algorithm":       datasetStringProperty(ds, "encryption_algorithm"),
+			"locked":          locked,
+		}
+		// Children share their root's key, so they unlock with it
+		if root, ok := roots[rootName]; ok {
+			for _, field := range []string{"key_format", "key_storage", "auto_unlock", "reason"} {
+				if v, ok := root[field]; ok {
+					entry[field] = v
+				}
+			}
+		} else {
+			entry["key_format"] = datasetStringProperty(ds, "key_format")
+		}
+		if entry["key_format"] != "passphrase" {
+			keyEncrypted = true
+		}
+		if entry["auto_unlock"] == false {
+			notAutoUnlocking = append(notAutoUnlocking, name)
+		}
+		entries = append(entries, entry)
+
+		poolName, _ := ds["pool"].(string)
+		summary, ok := pools[poolName]
+		if !ok {
+			summary = map[string]interface{}{"pool": poolName, "encrypted_datasets": 0, "encryption_roots": 0, "locked": 0, "not_auto_unlocking": 0}
+			pools[poolName] = summary
+			poolSummaries = append(poolSummaries, summary)
+		}
+		summary["encrypted_datasets"] = summary["encrypted_datasets"].(int) + 1
+		if rootName == name {
+			summary["encryption_roots"] = summary["encryption_roots"].(int) + 1
+		}
+		if locked {
+			summary["locked"] = summary["locked"].(int) + 1
+		}
+		if entry["auto_unlock"] == false {
+			summary["not_auto_unlocking"] = summary["not_auto_unlocking"].(int) + 1
+		}
+	}
+
+	response := map[string]interface{}{
+		"pools":              poolSummaries,
+		"datasets":           entries,
+		"count":              len(entries),
+		"not_auto_unlocking": notAutoUnlocking,
+	}
+	if kmip != nil {
+		response["kmip"] = map[string]interface{}{
+			"enabled":          kmip["enabled"],
+			"manage_zfs_keys":  kmip["manage_zfs_keys"],
+			"manage_sed_disks": kmip["manage_sed_disks"],
+			"server":           kmip["server"],
+		}
+	}
+
+	recommendations := []string{}
+	if len(notAutoUnlocking) > 0 {
+		recommendations = append(recommendations, fmt.Sprintf("%d encrypted dataset(s) stay locked after a reboot; apps, shares, and VMs on them are unavailable until they are unlocked", len(notAutoUnlocking)))
+	}
+	if keyEncrypted {
+		recommendations = append(recommendations, "Export and back up the dataset keys off the server (Storage > Export Keys); losing the system database without them loses the data")
+	}
+	if len(recommendations) > 0 {
+		response["recommendations"] = recommendations
+	}
+	if len(entries) == 0 {
+		response["message"] = "No encrypted datasets found"
+	}
+	return marshalJSON(response)
+}
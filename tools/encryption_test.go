@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationAuditEncryption(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	keyFormat := func(format string) map[string]interface{} {
+		return map[string]interface{}{"parsed": format, "value": strings.ToUpper(format)}
+	}
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"name": "tank/plain", "pool": "tank", "encrypted": false},
+		{"name": "tank/secure", "pool": "tank", "encrypted": true, "encryption_root": "tank/secure", "key_format": keyFormat("hex"), "locked": false},
+		{"name": "tank/secure/db", "pool": "tank", "encrypted": true, "encryption_root": "tank/secure", "key_format": keyFormat("hex"), "locked": false},
+		{"name": "tank/vault", "pool": "tank", "encrypted": true, "encryption_root": "tank/vault", "key_format": keyFormat("passphrase"), "locked": true},
+		{"name": "backup/imported", "pool": "backup", "encrypted": true, "encryption_root": "backup/imported", "key_format": keyFormat("hex"), "locked": true},
+	})
+	server.SetResult("kmip.config", map[string]interface{}{"enabled": false, "manage_zfs_keys": false})
+
+	result, err := registry.CallTool("audit_encryption", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("audit_encryption failed: %v", err)
+	}
+	audit := decodeResult(t, result)
+	if audit["count"] != float64(4) {
+		t.Errorf("count = %v, want 4 encrypted datasets", audit["count"])
+	}
+	if got := fmt.Sprint(audit["not_auto_unlocking"]); got != "[backup/imported tank/vault]" {
+		t.Errorf("not_auto_unlocking = %s", got)
+	}
+	for _, d := range audit["datasets"].([]interface{}) {
+		ds := d.(map[string]interface{})
+		if ds["name"] == "tank/secure/db" && (ds["key_storage"] != "system_database" || ds["auto_unlock"] != true || ds["is_root"] != false) {
+			t.Errorf("child dataset = %v, want it to follow its key-encrypted root", ds)
+		}
+	}
+	pools := audit["pools"].([]interface{})
+	if len(pools) != 2 || pools[1].(map[string]interface{})["encryption_roots"] != float64(2) {
+		t.Errorf("pools = %v", pools)
+	}
+
+	// With KMIP managing keys, key-encrypted roots depend on the server
+	server.SetResult("kmip.config", map[string]interface{}{"enabled": true, "manage_zfs_keys": true, "server": "kmip.example.com"})
+	result, err = registry.CallTool("audit_encryption", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("audit_encryption failed: %v", err)
+	}
+	if !strings.Contains(result, `"key_storage": "kmip"`) || strings.Contains(result, "backup/imported") {
+		t.Errorf("audit with KMIP for tank = %s", result)
+	}
+}
@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// ErrorCode classifies a tool failure so agents can branch on the failure type
+type ErrorCode string
+
+const (
+	ErrorNotFound         ErrorCode = "NOT_FOUND"
+	ErrorPermissionDenied ErrorCode = "PERMISSION_DENIED"
+	ErrorValidation       ErrorCode = "VALIDATION"
+	ErrorMiddleware       ErrorCode = "MIDDLEWARE_ERROR"
+	ErrorTimeout          ErrorCode = "TIMEOUT"
+	ErrorConnectionLost   ErrorCode = "CONNECTION_LOST"
+	ErrorReconnecting     ErrorCode = "RECONNECTING"
+	ErrorCancelled        ErrorCode = "CANCELLED"
+	ErrorInProgress       ErrorCode = "OPERATION_IN_PROGRESS"
+	ErrorPrecondition     ErrorCode = "PRECONDITION_FAILED"
+	ErrorInternal         ErrorCode = "INTERNAL"
+)
+
+// ToolError is the structured error payload returned for failed tool calls
+type ToolError struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Retryable bool        `json:"retryable"`
+	Method    string      `json:"middleware_method,omitempty"`
+	Errno     string      `json:"errno,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+
+	// CorrelationID identifies the tool call in server logs and task records
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	err error
+}
+
+func (e *ToolError) Error() string {
+	return e.Message
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.err
+}
+
+// newToolError creates a coded error for handlers that know the failure type
+func newToolError(code ErrorCode, format string, args ...interface{}) *ToolError {
+	msg := fmt.Sprintf(format, args...)
+	return &ToolError{Code: code, Message: msg, Retryable: isRetryable(code)}
+}
+
+// errnoCodes maps middleware errno names to error codes
+var errnoCodes = map[string]ErrorCode{
+	"ENOENT":    ErrorNotFound,
+	"EPERM":     ErrorPermissionDenied,
+	"EACCES":    ErrorPermissionDenied,
+	"EINVAL":    ErrorValidation,
+	"EEXIST":    ErrorValidation,
+	"ENOTEMPTY": ErrorValidation,
+	"ETIMEDOUT": ErrorTimeout,
+}
+
+// errnoNumbers maps middleware numeric error codes (Linux errno) to errno names
+var errnoNumbers = map[int]string{
+	1:   "EPERM",
+	2:   "ENOENT",
+	13:  "EACCES",
+	17:  "EEXIST",
+	22:  "EINVAL",
+	39:  "ENOTEMPTY",
+	110: "ETIMEDOUT",
+}
+
+// middlewareCodePattern extracts the code from middleware errors formatted with %v
+var middlewareCodePattern = regexp.MustCompile(`API error: .*\(code (\d+)\)`)
+
+// ClassifyError converts any handler error into a ToolError. Typed client errors
+// are classified exactly; other errors are classified from their message.
+func ClassifyError(err error) *ToolError {
+	if err == nil {
+		return nil
+	}
+
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		if toolErr.Message != err.Error() {
+			// Preserve context added by wrapping
+			classified := *toolErr
+			classified.Message = err.Error()
+			return &classified
+		}
+		return toolErr
+	}
+
+	result := &ToolError{Message: err.Error(), err: err}
+
+	var mwErr *truenas.MiddlewareError
+	var reconnErr *truenas.ReconnectingError
+	switch {
+	case errors.Is(err, truenas.ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		result.Code = ErrorTimeout
+	case errors.Is(err, truenas.ErrCancelled) || errors.Is(err, context.Canceled):
+		result.Code = ErrorCancelled
+	case errors.As(err, &reconnErr):
+		// Checked before ErrConnectionLost, which it also matches: the write
+		// may have been applied, so it is not blindly retryable
+		result.Code = ErrorReconnecting
+		result.Method = reconnErr.Method
+	case errors.Is(err, truenas.ErrConnectionLost):
+		result.Code = ErrorConnectionLost
+	case errors.As(err, &mwErr):
+		result.Method = mwErr.Method
+		result.Errno = mwErr.ErrName
+		if result.Errno == "" {
+			result.Errno = errnoNumbers[mwErr.Code]
+		}
+		result.Code = classifyMiddleware(result.Errno, mwErr.Message)
+		if mwErr.Extra != nil {
+			result.Details = mwErr.Extra
+		}
+	default:
+		result.Code = classifyMessage(err.Error())
+	}
+
+	result.Retryable = isRetryable(result.Code)
+	return result
+}
+
+// classifyMiddleware maps a middleware error to a code using its errno
+func classifyMiddleware(errno, message string) ErrorCode {
+	if code, ok := errnoCodes[errno]; ok {
+		return code
+	}
+	lower := strings.ToLower(message)
+	if strings.Contains(lower, "not authorized") || strings.Contains(lower, "not authenticated") {
+		return ErrorPermissionDenied
+	}
+	return ErrorMiddleware
+}
+
+// classifyMessage infers a code from an untyped error message
+func classifyMessage(message string) ErrorCode {
+	lower := strings.ToLower(message)
+
+	// Middleware errors that lost their type through %v wrapping
+	if m := middlewareCodePattern.FindStringSubmatch(message); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return classifyMiddleware(errnoNumbers[code], message)
+	}
+
+	switch {
+	case strings.Contains(lower, "may or may not have been applied"):
+		return ErrorReconnecting
+	case strings.Contains(lower, "timed out") || strings.Contains(lower, "timeout"):
+		return ErrorTimeout
+	case strings.Contains(lower, "connection") && (strings.Contains(lower, "failed") || strings.Contains(lower, "lost") || strings.Contains(lower, "refused")):
+		return ErrorConnectionLost
+	case strings.Contains(lower, "permission denied") || strings.Contains(lower, "not authorized") || strings.Contains(lower, "read-only mode"):
+		return ErrorPermissionDenied
+	case strings.Contains(lower, "not found") || strings.Contains(lower, "does not exist") || strings.Contains(lower, "unknown tool"):
+		return ErrorNotFound
+	case strings.Contains(lower, "is required") || strings.Contains(lower, "must be") ||
+		strings.Contains(lower, "invalid") || strings.Contains(lower, "cannot be") ||
+		strings.Contains(lower, "not allowed") || strings.Contains(lower, "already"):
+		return ErrorValidation
+	}
+	return ErrorInternal
+}
+
+// isRetryable reports whether retrying the same call may succeed
+func isRetryable(code ErrorCode) bool {
+	return code == ErrorTimeout || code == ErrorConnectionLost
+}
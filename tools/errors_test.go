@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      ErrorCode
+		retryable bool
+	}{
+		{
+			name: "middleware ENOENT by errname",
+			err: fmt.Errorf("failed to query pool: %w", &truenas.MiddlewareError{
+				Method:   "pool.get_instance",
+				APIError: truenas.APIError{Code: 2, Message: "tank not found", ErrName: "ENOENT"},
+			}),
+			code: ErrorNotFound,
+		},
+		{
+			name: "middleware EACCES by number",
+			err:  &truenas.MiddlewareError{APIError: truenas.APIError{Code: 13, Message: "Access denied"}},
+			code: ErrorPermissionDenied,
+		},
+		{
+			name: "middleware unknown errno",
+			err:  &truenas.MiddlewareError{APIError: truenas.APIError{Code: 14, Message: "Something broke"}},
+			code: ErrorMiddleware,
+		},
+		{
+			name: "middleware error formatted with %v",
+			err:  fmt.Errorf("failed to delete app: %v", errors.New("API error: [EINVAL] app.delete: bad (code 22)")),
+			code: ErrorValidation,
+		},
+		{
+			name:      "timeout",
+			err:       fmt.Errorf("wrapped: %w", truenas.ErrTimeout),
+			code:      ErrorTimeout,
+			retryable: true,
+		},
+		{
+			name:      "connection lost",
+			err:       fmt.Errorf("re-authentication failed: %w", truenas.ErrConnectionLost),
+			code:      ErrorConnectionLost,
+			retryable: true,
+		},
+		{
+			name: "write interrupted by reconnect",
+			err: fmt.Errorf("failed to create snapshot: %w", &truenas.ReconnectingError{
+				Method: "pool.snapshot.create",
+				Err:    fmt.Errorf("failed to read response: %w", truenas.ErrConnectionLost),
+			}),
+			code: ErrorReconnecting,
+		},
+		{
+			name: "required argument",
+			err:  fmt.Errorf("pool is required"),
+			code: ErrorValidation,
+		},
+		{
+			name: "handler not found",
+			err:  fmt.Errorf("pool 'tank' not found"),
+			code: ErrorNotFound,
+		},
+		{
+			name: "coded error wrapped",
+			err:  fmt.Errorf("lookup: %w", newToolError(ErrorPermissionDenied, "no access")),
+			code: ErrorPermissionDenied,
+		},
+		{
+			name: "unclassified",
+			err:  fmt.Errorf("failed to parse response"),
+			code: ErrorInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ClassifyError(tt.err)
+			if result.Code != tt.code {
+				t.Errorf("code = %s, want %s", result.Code, tt.code)
+			}
+			if result.Retryable != tt.retryable {
+				t.Errorf("retryable = %v, want %v", result.Retryable, tt.retryable)
+			}
+			if result.Message != tt.err.Error() {
+				t.Errorf("message = %q, want %q", result.Message, tt.err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/events"
+)
+
+// Alerts and jobs served from the event cache
+
+// cachedAlerts returns every alert from the event cache. ok is false when the
+// cache is disabled, bypassed with live=true, or cannot be synced.
+func (r *Registry) cachedAlerts(args map[string]interface{}) ([]map[string]interface{}, events.Freshness, bool) {
+	if r.eventCache == nil {
+		return nil, events.Freshness{}, false
+	}
+	if live, _ := args["live"].(bool); live {
+		return nil, events.Freshness{}, false
+	}
+	alerts, freshness, err := r.eventCache.Alerts()
+	if err != nil {
+		slog.Warn("Event cache unavailable, querying alerts directly", "error", err)
+		return nil, events.Freshness{}, false
+	}
+	return alerts, freshness, true
+}
+
+// cachedJobs returns jobs from the event cache matching the query_jobs
+// filters, most recently started first
+func (r *Registry) cachedJobs(args map[string]interface{}, state, prefix string, after, before time.Time, limit int) ([]map[string]interface{}, events.Freshness, bool) {
+	if r.eventCache == nil {
+		return nil, events.Freshness{}, false
+	}
+	if live, _ := args["live"].(bool); live {
+		return nil, events.Freshness{}, false
+	}
+	all, freshness, err := r.eventCache.Jobs()
+	if err != nil {
+		slog.Warn("Event cache unavailable, querying jobs directly", "error", err)
+		return nil, events.Freshness{}, false
+	}
+
+	jobs := []map[string]interface{}{}
+	for _, job := range all {
+		if state != "all" && job["state"] != state {
+			continue
+		}
+		if method, _ := job["method"].(string); prefix != "" && !strings.HasPrefix(method, prefix) {
+			continue
+		}
+		started, _ := middlewareTime(job["time_started"])
+		if !after.IsZero() && started.Before(after) {
+			continue
+		}
+		if !before.IsZero() && !started.Before(before) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		a, _ := middlewareTime(jobs[i]["time_started"])
+		b, _ := middlewareTime(jobs[j]["time_started"])
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		ai, _ := jobs[i]["id"].(float64)
+		bi, _ := jobs[j]["id"].(float64)
+		return ai > bi
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, freshness, true
+}
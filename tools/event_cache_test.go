@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationEventCacheFreshness(t *testing.T) {
+	server := truenastest.NewServer(t)
+	client := server.Client(t)
+	server.SetRecords("alert.list", []map[string]interface{}{
+		{"uuid": "a1", "level": "WARNING", "dismissed": false},
+		{"uuid": "a2", "level": "INFO", "dismissed": true},
+	})
+	server.AddJob("pool.scrub.scrub", []interface{}{"tank"}, truenastest.JobSpec{Steps: 100})
+	registry := NewRegistry(client, nil, Options{EventCache: events.NewCache(client, time.Hour)})
+
+	for i := 0; i < 2; i++ {
+		result, err := registry.CallTool("list_alerts", map[string]interface{}{"dismissed": false})
+		if err != nil {
+			t.Fatalf("list_alerts failed: %v", err)
+		}
+		response := decodeResult(t, result)
+		if response["alert_count"] != float64(1) {
+			t.Errorf("alert_count = %v, want 1 active alert", response["alert_count"])
+		}
+		if freshness, _ := response["freshness"].(map[string]interface{}); freshness["source"] != "cache" {
+			t.Errorf("freshness = %v, want source cache", response["freshness"])
+		}
+	}
+	if calls := len(server.Calls("alert.list")); calls != 1 {
+		t.Errorf("alert.list called %d times, want 1 (second call served from cache)", calls)
+	}
+
+	result, err := registry.CallTool("query_jobs", map[string]interface{}{"state": "RUNNING", "method": "pool.scrub.*"})
+	if err != nil {
+		t.Fatalf("query_jobs failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["job_count"] != float64(1) {
+		t.Errorf("job_count = %v, want the running scrub", response["job_count"])
+	}
+	if freshness, _ := response["freshness"].(map[string]interface{}); freshness["source"] != "cache" {
+		t.Errorf("freshness = %v, want source cache", response["freshness"])
+	}
+
+	result, err = registry.CallTool("query_jobs", map[string]interface{}{"live": true})
+	if err != nil {
+		t.Fatalf("query_jobs live failed: %v", err)
+	}
+	if freshness, _ := decodeResult(t, result)["freshness"].(map[string]interface{}); freshness["source"] != "live" {
+		t.Errorf("freshness = %v, want source live", freshness)
+	}
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Event watch handlers
+
+func (r *Registry) handleWatchEvents(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.eventWatcher == nil {
+		return "", fmt.Errorf("event watching is not configured")
+	}
+
+	action := "start"
+	if a, ok := args["action"].(string); ok && a != "" {
+		action = a
+	}
+
+	switch action {
+	case "start":
+		config := events.WatchConfig{
+			Notify: getOptionalBool(args, "notify", true),
+		}
+		if level, ok := args["min_alert_level"].(string); ok {
+			config.MinAlertLevel = level
+		}
+		if categories, ok := args["categories"].([]interface{}); ok {
+			for _, c := range categories {
+				if category, ok := c.(string); ok && category != "" {
+					config.Categories = append(config.Categories, events.Category(category))
+				}
+			}
+		}
+
+		if err := r.eventWatcher.Watch(config); err != nil {
+			return "", err
+		}
+	case "stop":
+		r.eventWatcher.Stop()
+	case "status":
+	default:
+		return "", fmt.Errorf("action must be 'start', 'stop', or 'status', got: %s", action)
+	}
+
+	return marshalJSON(r.eventWatcher.Status())
+}
+
+func (r *Registry) handleGetRecentEvents(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.eventWatcher == nil {
+		return "", fmt.Errorf("event watching is not configured")
+	}
+
+	var since int64
+	if s, ok := args["since"].(float64); ok && s > 0 {
+		since = int64(s)
+	}
+
+	limit := getOptionalInt(args, "limit", 50)
+	if limit < 1 || limit > 500 {
+		return "", fmt.Errorf("limit must be between 1 and 500")
+	}
+
+	var category events.Category
+	if c, ok := args["category"].(string); ok && c != "" {
+		if c != string(events.CategoryAlert) && c != string(events.CategoryJob) {
+			return "", fmt.Errorf("category must be 'alert' or 'job', got: %s", c)
+		}
+		category = events.Category(c)
+	}
+
+	recent := r.eventWatcher.Recent(since, category, limit)
+	status := r.eventWatcher.Status()
+
+	response := map[string]interface{}{
+		"events":          recent,
+		"count":           len(recent),
+		"latest_sequence": status["latest_sequence"],
+		"watching":        status["watching"],
+	}
+	if len(recent) > 0 {
+		response["next_since"] = recent[len(recent)-1].Sequence
+	}
+	if watching, _ := status["watching"].(bool); !watching {
+		response["note"] = "Event watching is not active. Use watch_events to start collecting alert and job events."
+	}
+
+	return marshalJSON(response)
+}
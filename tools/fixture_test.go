@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Regression tests replaying recorded middleware traffic (see truenastest.Server.Replay).
+// Fixtures can be refreshed against a real system with --record-fixtures.
+
+func TestReplayAnalyzeCapacity(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.ReplayFile(t, filepath.Join("testdata", "fixtures", "analyze_capacity.json"))
+
+	result, err := registry.CallTool("analyze_capacity", map[string]interface{}{
+		"time_range": "WEEK",
+		"metrics":    []interface{}{"cpu", "memory"},
+	})
+	if err != nil {
+		t.Fatalf("analyze_capacity failed: %v", err)
+	}
+
+	analysis := decodeResult(t, result)
+
+	cpu, _ := analysis["cpu"].(map[string]interface{})
+	if cpu["current_utilization_pct"] != "72.00" {
+		t.Errorf("cpu current = %v, want 72.00", cpu["current_utilization_pct"])
+	}
+	if cpu["trend"] != "increasing" || cpu["capacity_status"] != "warning" {
+		t.Errorf("cpu trend/status = %v/%v, want increasing/warning", cpu["trend"], cpu["capacity_status"])
+	}
+
+	memory, _ := analysis["memory"].(map[string]interface{})
+	if memory["capacity_status"] != "healthy" {
+		t.Errorf("memory status = %v, want healthy", memory["capacity_status"])
+	}
+	if _, ok := analysis["summary"]; !ok {
+		t.Error("analysis has no summary")
+	}
+}
+
+func TestReplayInstallAppDryRun(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.ReplayFile(t, filepath.Join("testdata", "fixtures", "install_app_dry_run.json"))
+
+	result, err := registry.CallTool("install_app", map[string]interface{}{
+		"app_name":    "jellyfin",
+		"catalog_app": "jellyfin",
+		"dry_run":     true,
+		"values": map[string]interface{}{
+			"storage": map[string]interface{}{
+				"config": map[string]interface{}{
+					"type":             "host_path",
+					"host_path_config": map[string]interface{}{"path": "/mnt/tank/apps/jellyfin-config"},
+				},
+				"media": map[string]interface{}{
+					"type":             "host_path",
+					"host_path_config": map[string]interface{}{"path": "/mnt/tank/media"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("install_app dry run failed: %v", err)
+	}
+
+	if !strings.Contains(result, "Install jellyfin app version 1.2.3") {
+		t.Errorf("dry run does not plan the catalog version:\n%s", result)
+	}
+	if !strings.Contains(result, "Dataset tank/apps/jellyfin-config does not exist") {
+		t.Errorf("dry run does not flag the missing dataset:\n%s", result)
+	}
+	if strings.Contains(result, "Dataset tank/media does not exist") {
+		t.Errorf("dry run flags an existing dataset as missing:\n%s", result)
+	}
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+// Handlers depend on truenas.Caller, so they can be exercised against a
+// scripted truenastest.Mock without a middleware connection
+
+func TestHandleQueryPoolsWithMock(t *testing.T) {
+	mock := truenastest.NewMock()
+	mock.SetRecords("pool.query", []map[string]interface{}{
+		testPool("tank", 40, 60), testPool("backup", 10, 90), testPool("scratch", 5, 5),
+	})
+
+	result, err := handleQueryPools(context.Background(), mock, map[string]interface{}{"limit": float64(2)})
+	if err != nil {
+		t.Fatalf("handleQueryPools failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	pools := response["pools"].([]interface{})
+	if len(pools) != 2 || response["total_pools"] != float64(3) || response["next_cursor"] == nil {
+		t.Errorf("response = %v, want 2 of 3 pools and a next_cursor", response)
+	}
+	if first := pools[0].(map[string]interface{}); first["name"] != "backup" {
+		t.Errorf("first pool = %v, want backup (ordered by name)", first["name"])
+	}
+
+	// The page and the count are separate middleware queries
+	if calls := mock.Calls("pool.query"); len(calls) != 2 {
+		t.Errorf("pool.query calls = %d, want page and count", len(calls))
+	}
+}
+
+func TestHandlerErrorsWithMock(t *testing.T) {
+	mock := truenastest.NewMock()
+	mock.Respond("system.info", nil, &truenastest.Error{Code: 13, Message: "Not authorized", ErrName: "EACCES"})
+
+	_, err := handleSystemInfo(context.Background(), mock, map[string]interface{}{})
+	if code := ClassifyError(err).Code; code != ErrorPermissionDenied {
+		t.Errorf("error code = %s, want %s", code, ErrorPermissionDenied)
+	}
+
+	// The queued error is used once; the registry runs tools on the mock too
+	mock.SetResult("system.info", map[string]interface{}{"hostname": "nas"})
+	registry := NewRegistry(mock, nil, Options{})
+	result, err := registry.CallTool("system_info", map[string]interface{}{})
+	if err != nil || decodeResult(t, result)["hostname"] != "nas" {
+		t.Errorf("system_info = %s, %v", result, err)
+	}
+	if calls := mock.Calls("system.info"); len(calls) != 2 {
+		t.Errorf("system.info calls = %d, want 2", len(calls))
+	}
+}
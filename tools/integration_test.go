@@ -0,0 +1,368 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+// newTestRegistry returns a registry wired to a fake middleware server
+func newTestRegistry(t *testing.T) (*Registry, *truenastest.Server) {
+	t.Helper()
+
+	server := truenastest.NewServer(t)
+	client := server.Client(t)
+
+	taskManager := tasks.NewManager(client, tasks.PollerConfig{
+		PollInterval:    20 * time.Millisecond,
+		CleanupInterval: time.Minute,
+	})
+	taskManager.Start()
+	t.Cleanup(taskManager.Shutdown)
+
+	return NewRegistry(client, taskManager, Options{}), server
+}
+
+func testPool(name string, allocated, free float64) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        float64(1),
+		"name":      name,
+		"status":    "ONLINE",
+		"healthy":   true,
+		"size":      allocated + free,
+		"allocated": allocated,
+		"free":      free,
+		"scan": map[string]interface{}{
+			"function": "SCRUB",
+			"state":    "FINISHED",
+			"errors":   float64(0),
+			"end_time": map[string]interface{}{"$date": float64(time.Now().Add(-48 * time.Hour).UnixMilli())},
+		},
+		"topology": map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{
+					"type": "MIRROR",
+					"children": []interface{}{
+						map[string]interface{}{"disk": "sda", "status": "ONLINE"},
+						map[string]interface{}{"disk": "sdb", "status": "ONLINE"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func decodeResult(t *testing.T, result string) map[string]interface{} {
+	t.Helper()
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(result), &decoded); err != nil {
+		t.Fatalf("result is not a JSON object: %v\n%s", err, result)
+	}
+	return decoded
+}
+
+func TestIntegrationQueryPools(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+
+	result, err := registry.CallTool("query_pools", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_pools failed: %v", err)
+	}
+	if !strings.Contains(result, `"tank"`) {
+		t.Errorf("result does not include pool tank:\n%s", result)
+	}
+	if n := len(server.Calls("auth.login_with_api_key")); n != 1 {
+		t.Errorf("authenticated %d times, want 1", n)
+	}
+
+	result, err = registry.CallTool("query_pools", map[string]interface{}{"fields": []interface{}{"name"}})
+	if err != nil {
+		t.Fatalf("query_pools with fields failed: %v", err)
+	}
+	if pool := decodeResult(t, result)["pools"].([]interface{})[0].(map[string]interface{}); len(pool) != 1 || pool["name"] != "tank" {
+		t.Errorf("projected pool = %v, want only its name", pool)
+	}
+}
+
+func TestIntegrationMiddlewareError(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetError("pool.query", 13, "Not authorized")
+
+	_, err := registry.CallTool("query_pools", map[string]interface{}{})
+	if err == nil {
+		t.Fatal("expected error from query_pools")
+	}
+	if !strings.Contains(err.Error(), "Not authorized") {
+		t.Errorf("error does not carry middleware message: %v", err)
+	}
+}
+
+func TestIntegrationErrorClassification(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.Handle("pool.query", func(params []interface{}) (interface{}, error) {
+		return nil, &truenastest.Error{Code: 2, Message: "pool does not exist", ErrName: "ENOENT"}
+	})
+
+	_, err := registry.CallTool("query_pools", map[string]interface{}{})
+	classified := ClassifyError(err)
+	if classified.Code != ErrorNotFound || classified.Method != "pool.query" || classified.Errno != "ENOENT" {
+		t.Errorf("classified = %+v, want NOT_FOUND from pool.query (ENOENT)", classified)
+	}
+}
+
+func TestIntegrationNASTimezone(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.general.config", map[string]interface{}{"timezone": "Asia/Kolkata"})
+	server.SetRecords("pool.scrub.query", []map[string]interface{}{
+		{"id": float64(1), "pool": float64(1), "pool_name": "tank", "enabled": true, "threshold": float64(35),
+			"schedule": map[string]interface{}{"minute": "0", "hour": "3", "dom": "*", "month": "*", "dow": "0"}},
+	})
+	server.SetRecords("pool.query", []map[string]interface{}{{"id": float64(1), "name": "tank"}})
+
+	result, err := registry.CallTool("query_scrub_schedules", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_scrub_schedules failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	timezone, _ := response["timezone"].(map[string]interface{})
+	if timezone["name"] != "Asia/Kolkata" || timezone["utc_offset"] != "+05:30" || timezone["source"] != "nas" {
+		t.Errorf("timezone = %v, want Asia/Kolkata +05:30 from the NAS", timezone)
+	}
+	schedule := response["scrub_schedules"].([]interface{})[0].(map[string]interface{})
+	if nextRun, _ := schedule["next_run"].(string); !strings.HasSuffix(nextRun, "T03:00:00+05:30") {
+		t.Errorf("next_run = %q, want 03:00 NAS time with its offset", nextRun)
+	}
+
+	server.SetResult("reporting.get_data", []map[string]interface{}{
+		{"name": "cpu", "start": float64(1767225600), "end": float64(1767229200), "data": []interface{}{}},
+	})
+	result, err = registry.CallTool("get_metrics", map[string]interface{}{"graph": "cpu", "start": float64(1767225600)})
+	if err != nil {
+		t.Fatalf("get_metrics failed: %v", err)
+	}
+	response = decodeResult(t, result)
+	if response["start_time"] != "2026-01-01T05:30:00+05:30" {
+		t.Errorf("start_time = %v, want 2026-01-01T05:30:00+05:30", response["start_time"])
+	}
+	series, _ := response["series"].(map[string]interface{})
+	if cpu, _ := series["default"].(map[string]interface{}); cpu["end_time"] != "2026-01-01T06:30:00+05:30" {
+		t.Errorf("series end_time = %v", cpu["end_time"])
+	}
+}
+
+func TestIntegrationSystemHealth(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("alert.list", []map[string]interface{}{
+		{"uuid": "a1", "level": "WARNING", "formatted": "Pool tank is degraded"},
+	})
+	server.AddJob("pool.scrub.scrub", []interface{}{"tank"}, truenastest.JobSpec{Steps: 100})
+	server.SetResult("directoryservices.status", map[string]interface{}{
+		"type": "ACTIVEDIRECTORY", "status": "FAULTED", "status_msg": "DC unreachable",
+	})
+
+	// reporting, system.info, and pool.query are unhandled; their checks are skipped
+	result, err := registry.CallTool("system_health", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("system_health failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["alert_count"] != float64(1) || response["job_count"] != float64(1) {
+		t.Errorf("alert_count = %v, job_count = %v; want 1 and 1", response["alert_count"], response["job_count"])
+	}
+	warnings, _ := response["capacity_warnings"].([]interface{})
+	if len(warnings) != 1 || !strings.Contains(warnings[0].(string), "DC unreachable") {
+		t.Errorf("capacity_warnings = %v, want the faulted directory service", warnings)
+	}
+}
+
+func TestIntegrationSystemHealthDegrades(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetError("alert.list", 14, "alert service unavailable")
+
+	// Only core.get_jobs answers; the report still comes back
+	result, err := registry.CallTool("system_health", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("system_health failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["health_check"] != "INCOMPLETE" {
+		t.Errorf("health_check = %v, want INCOMPLETE", response["health_check"])
+	}
+	unavailable, _ := response["unavailable_checks"].([]interface{})
+	if len(unavailable) != 6 || unavailable[0].(map[string]interface{})["check"] != "alerts" {
+		t.Errorf("unavailable_checks = %v, want alerts and the five unhandled checks", unavailable)
+	}
+}
+
+func TestIntegrationAnalyzeCapacityPerInterfaceErrors(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("interface.query", []interface{}{
+		map[string]interface{}{"name": "eno1", "state": map[string]interface{}{"link_speed": float64(1000)}},
+		map[string]interface{}{"name": "eno2"},
+	})
+	server.Handle("reporting.get_data", func(params []interface{}) (interface{}, error) {
+		query := params[0].([]interface{})[0].(map[string]interface{})
+		if query["identifier"] == "eno2" {
+			return nil, &truenastest.Error{Code: 14, Message: "no data for eno2"}
+		}
+		return []interface{}{map[string]interface{}{
+			"legend": "received",
+			"data":   []interface{}{[]interface{}{float64(1), float64(100e6)}, []interface{}{float64(2), float64(200e6)}},
+		}}, nil
+	})
+
+	result, err := registry.CallTool("analyze_capacity", map[string]interface{}{"metrics": []interface{}{"network", "cpu"}})
+	if err != nil {
+		t.Fatalf("analyze_capacity failed: %v", err)
+	}
+	network, _ := decodeResult(t, result)["network"].(map[string]interface{})
+	if eno1, _ := network["eno1"].(map[string]interface{}); eno1["link_speed_mbps"] != float64(1000) {
+		t.Errorf("eno1 = %v, want its analysis", network["eno1"])
+	}
+	if eno2, _ := network["eno2"].(map[string]interface{}); !strings.Contains(fmt.Sprint(eno2["error"]), "no data for eno2") {
+		t.Errorf("eno2 = %v, want its error", network["eno2"])
+	}
+}
+
+func TestIntegrationCorrelationID(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.Handle("pool.scrub.run", func(params []interface{}) (interface{}, error) {
+		server.AddJob("pool.scrub.scrub", params, truenastest.JobSpec{Steps: 2})
+		return nil, nil
+	})
+
+	result, err := registry.CallToolWithCorrelationID("corr-scrub", "run_scrub", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("run_scrub failed: %v", err)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+
+	result, err = registry.CallTool("tasks_get", map[string]interface{}{"task_id": taskID})
+	if err != nil {
+		t.Fatalf("tasks_get failed: %v", err)
+	}
+	if got := decodeResult(t, result)["correlationId"]; got != "corr-scrub" {
+		t.Errorf("task correlationId = %v, want corr-scrub", got)
+	}
+
+	server.Handle("pool.query", func(params []interface{}) (interface{}, error) {
+		return nil, &truenastest.Error{Code: 2, Message: "pool does not exist", ErrName: "ENOENT"}
+	})
+	_, err = registry.CallToolWithCorrelationID("corr-fail", "query_pools", map[string]interface{}{})
+	if classified := ClassifyError(err); classified.CorrelationID != "corr-fail" || classified.Code != ErrorNotFound {
+		t.Errorf("classified = %+v, want NOT_FOUND with correlation ID corr-fail", classified)
+	}
+}
+
+func TestAnalyzeStorageGrowthProjection(t *testing.T) {
+	// Three days of pool history: fast fills in days, slow grows a few bytes a
+	// day and would take centuries, and flat does not grow
+	const gib = int64(1 << 30)
+	now := time.Now()
+	series := map[string][]capacity.Sample{}
+	for day := int64(0); day < 3; day++ {
+		at := now.Add(time.Duration(day-2) * 24 * time.Hour)
+		series["fast"] = append(series["fast"], capacity.Sample{Timestamp: at, UsedBytes: (10 + day) * gib, AvailableBytes: (10 - day) * gib})
+		series["slow"] = append(series["slow"], capacity.Sample{Timestamp: at, UsedBytes: 10*gib + day, AvailableBytes: 1000*gib - day})
+		series["flat"] = append(series["flat"], capacity.Sample{Timestamp: at, UsedBytes: 10 * gib, AvailableBytes: 10 * gib})
+	}
+
+	dir := t.TempDir()
+	data, _ := json.Marshal(series)
+	if err := os.WriteFile(filepath.Join(dir, "pools.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := capacity.NewTracker(nil, capacity.TrackerConfig{
+		SampleInterval: time.Hour,
+		Path:           filepath.Join(dir, "pools.json"),
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	registry := &Registry{capacityTracker: tracker}
+
+	pools := registry.analyzeStorageGrowth()["pools"].(map[string]interface{})
+	for pool, want := range map[string]string{
+		"fast": "Pool full in ~8 days",
+		"slow": "growing too slowly to project",
+		"flat": "Pool usage is not growing",
+	} {
+		projection, _ := pools[pool].(map[string]interface{})["projection"].(string)
+		if !strings.Contains(projection, want) {
+			t.Errorf("%s projection = %q, want %q", pool, projection, want)
+		}
+	}
+}
+
+func TestIntegrationTaskReconciliation(t *testing.T) {
+	server := truenastest.NewServer(t)
+	client := server.Client(t)
+	config := tasks.PollerConfig{
+		PollInterval:    20 * time.Millisecond,
+		CleanupInterval: time.Minute,
+		JournalPath:     filepath.Join(t.TempDir(), "job_tasks.json"),
+	}
+
+	// Tasks created before a crash are only in the journal
+	before := tasks.NewManager(client, config)
+	running := server.AddJob("pool.scrub.run", []interface{}{"tank"}, truenastest.JobSpec{Steps: 1000})
+	kept, err := before.CreateJobTask("run_scrub", nil, running, time.Hour, "call-1")
+	if err != nil {
+		t.Fatalf("CreateJobTask failed: %v", err)
+	}
+	lost, err := before.CreateJobTask("run_scrub", nil, 4242, time.Hour, "call-2")
+	if err != nil {
+		t.Fatalf("CreateJobTask failed: %v", err)
+	}
+
+	after := tasks.NewManager(client, config)
+	report, err := after.Reconcile()
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(report.Recovered) != 1 || report.Recovered[0] != kept.TaskID {
+		t.Errorf("recovered = %v, want %s", report.Recovered, kept.TaskID)
+	}
+	if len(report.Vanished) != 1 || report.Vanished[0] != lost.TaskID {
+		t.Errorf("vanished = %v, want %s", report.Vanished, lost.TaskID)
+	}
+	if task, err := after.Get(kept.TaskID); err != nil || task.Status != tasks.TaskStatusWorking || task.Reconciled != tasks.ReconciledRecovered {
+		t.Errorf("recovered task = %+v (%v), want working and flagged recovered", task, err)
+	}
+	if task, err := after.Get(lost.TaskID); err != nil || task.Status != tasks.TaskStatusFailed || task.Reconciled != tasks.ReconciledJobVanished {
+		t.Errorf("lost task = %+v (%v), want failed and flagged job_vanished", task, err)
+	}
+
+	registry := NewRegistry(client, after, Options{})
+	result, err := registry.CallTool("tasks_list", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("tasks_list failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["warning"] == nil || response["reconciliation"] == nil {
+		t.Errorf("tasks_list does not report the reconciliation: %v", response)
+	}
+
+	// A running task whose job disappears is failed by the poller
+	registry, _ = newTestRegistry(t)
+	task, err := registry.taskManager.CreateJobTask("run_scrub", nil, 777, time.Hour, "call-3")
+	if err != nil {
+		t.Fatalf("CreateJobTask failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for registry.taskManager.IsActive(task.TaskID) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if polled, _ := registry.taskManager.Get(task.TaskID); polled.Status != tasks.TaskStatusFailed || polled.Reconciled != tasks.ReconciledJobVanished {
+		t.Errorf("task with vanished job = %+v, want failed", polled)
+	}
+}
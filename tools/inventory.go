@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Compact system inventory for pinning into conversation context
+
+// inventoryResourceURI is the MCP resource serving the inventory
+const inventoryResourceURI = "truenas://inventory"
+
+// inventoryQuery calls a query method and decodes its records
+func inventoryQuery(client truenas.Caller, method string, params ...interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(result, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", method, err)
+	}
+	return records, nil
+}
+
+// inventoryNames collects a string field of every record, sorted
+func inventoryNames(records []map[string]interface{}, field string) []string {
+	names := make([]string, 0, len(records))
+	for _, record := range records {
+		if name, ok := record[field].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collectInventory gathers the inventory sections. Sections that cannot be
+// read are reported in collection_notes rather than failing the snapshot.
+func collectInventory(client truenas.Caller) map[string]interface{} {
+	inventory := map[string]interface{}{}
+	notes := []string{}
+	note := func(section string, err error) {
+		notes = append(notes, fmt.Sprintf("%s unavailable: %v", section, err))
+	}
+
+	if result, err := client.Call("system.info"); err == nil {
+		var info map[string]interface{}
+		if err := json.Unmarshal(result, &info); err == nil {
+			system := map[string]interface{}{
+				"hostname": info["hostname"],
+				"version":  info["version"],
+			}
+			if model, ok := info["model"].(string); ok && model != "" {
+				system["cpu"] = model
+			}
+			if cores, ok := info["cores"].(float64); ok {
+				system["cores"] = int(cores)
+			}
+			if physmem, ok := info["physmem"].(float64); ok {
+				system["memory"] = units.FormatBytes(int64(physmem))
+			}
+			if uptime, ok := info["uptime_seconds"].(float64); ok {
+				system["uptime_days"] = math.Round(uptime/86400*10) / 10
+			}
+			inventory["system"] = system
+		}
+	} else {
+		note("system", err)
+	}
+
+	if pools, err := inventoryQuery(client, "pool.query"); err == nil {
+		summaries := make([]map[string]interface{}, 0, len(pools))
+		for _, pool := range pools {
+			summary := map[string]interface{}{
+				"name":   pool["name"],
+				"status": pool["status"],
+			}
+			size, _ := pool["size"].(float64)
+			allocated, _ := pool["allocated"].(float64)
+			if size > 0 {
+				summary["size"] = units.FormatBytes(int64(size))
+				summary["used_percent"] = math.Round(allocated/size*1000) / 10
+			}
+			summaries = append(summaries, summary)
+		}
+		inventory["pools"] = summaries
+	} else {
+		note("pools", err)
+	}
+
+	if result, err := client.Call("pool.dataset.query", []interface{}{}, map[string]interface{}{
+		"count": true,
+		"extra": map[string]interface{}{"flat": true, "retrieve_children": false},
+	}); err == nil {
+		var count int
+		if err := json.Unmarshal(result, &count); err == nil {
+			inventory["dataset_count"] = count
+		}
+	} else {
+		note("datasets", err)
+	}
+
+	shares := map[string]interface{}{}
+	if smb, err := inventoryQuery(client, "sharing.smb.query"); err == nil {
+		shares["smb"] = inventoryNames(smb, "name")
+	} else {
+		note("SMB shares", err)
+	}
+	if nfs, err := inventoryQuery(client, "sharing.nfs.query"); err == nil {
+		shares["nfs"] = inventoryNames(nfs, "path")
+	} else {
+		note("NFS shares", err)
+	}
+	inventory["shares"] = shares
+
+	if apps, err := inventoryQuery(client, "app.query"); err == nil {
+		summaries := make([]string, 0, len(apps))
+		for _, app := range apps {
+			name, _ := app["name"].(string)
+			state, _ := app["state"].(string)
+			summaries = append(summaries, fmt.Sprintf("%s (%s)", name, strings.ToLower(state)))
+		}
+		sort.Strings(summaries)
+		inventory["apps"] = summaries
+	} else {
+		note("apps", err)
+	}
+
+	if vms, err := inventoryQuery(client, "vm.query"); err == nil {
+		summaries := make([]string, 0, len(vms))
+		for _, vm := range vms {
+			name, _ := vm["name"].(string)
+			state := "unknown"
+			if status, ok := vm["status"].(map[string]interface{}); ok {
+				if s, ok := status["state"].(string); ok {
+					state = strings.ToLower(s)
+				}
+			}
+			summaries = append(summaries, fmt.Sprintf("%s (%s)", name, state))
+		}
+		sort.Strings(summaries)
+		inventory["vms"] = summaries
+	} else {
+		note("VMs", err)
+	}
+
+	if users, err := inventoryQuery(client, "user.query", []interface{}{
+		[]interface{}{"builtin", "=", false},
+	}); err == nil {
+		inventory["users"] = inventoryNames(users, "username")
+	} else {
+		note("users", err)
+	}
+
+	if interfaces, err := inventoryQuery(client, "interface.query"); err == nil {
+		summaries := make([]map[string]interface{}, 0, len(interfaces))
+		for _, iface := range interfaces {
+			summary := map[string]interface{}{"name": iface["name"]}
+			if state, ok := iface["state"].(map[string]interface{}); ok {
+				if link, ok := state["link_state"].(string); ok {
+					summary["link"] = strings.TrimPrefix(link, "LINK_STATE_")
+				}
+			}
+			addresses := []string{}
+			aliases, _ := iface["aliases"].([]interface{})
+			for _, raw := range aliases {
+				alias, _ := raw.(map[string]interface{})
+				if address, ok := alias["address"].(string); ok {
+					addresses = append(addresses, fmt.Sprintf("%s/%v", address, alias["netmask"]))
+				}
+			}
+			if len(addresses) > 0 {
+				summary["addresses"] = addresses
+			}
+			summaries = append(summaries, summary)
+		}
+		inventory["interfaces"] = summaries
+	} else {
+		note("interfaces", err)
+	}
+
+	if len(notes) > 0 {
+		inventory["collection_notes"] = notes
+	}
+	return inventory
+}
+
+func handleGetInventory(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return marshalJSON(collectInventory(client))
+}
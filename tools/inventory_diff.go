@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/inventory"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Saved inventory snapshots and configuration drift between them
+
+// liveSnapshotRef selects the current system state in diff_inventory
+const liveSnapshotRef = "live"
+
+// collectSnapshot records the system's configuration. Sections that cannot be
+// read are noted and left empty, so they diff as unchanged only against other
+// snapshots with the same gap.
+func collectSnapshot(client truenas.Caller) *inventory.Snapshot {
+	snapshot := &inventory.Snapshot{
+		ID:         uuid.New().String()[:8],
+		TakenAt:    time.Now().UTC(),
+		Pools:      []string{},
+		Datasets:   []string{},
+		SMBShares:  []string{},
+		NFSShares:  []string{},
+		Apps:       map[string]string{},
+		VMs:        []string{},
+		Users:      []string{},
+		Interfaces: map[string]string{},
+	}
+	note := func(section string, err error) {
+		snapshot.Notes = append(snapshot.Notes, fmt.Sprintf("%s unavailable: %v", section, err))
+	}
+
+	if result, err := client.Call("system.info"); err == nil {
+		var info map[string]interface{}
+		if err := json.Unmarshal(result, &info); err == nil {
+			snapshot.Hostname, _ = info["hostname"].(string)
+			snapshot.Version, _ = info["version"].(string)
+		}
+	} else {
+		note("system", err)
+	}
+
+	if pools, err := inventoryQuery(client, "pool.query"); err == nil {
+		snapshot.Pools = inventoryNames(pools, "name")
+	} else {
+		note("pools", err)
+	}
+
+	if datasets, err := inventoryQuery(client, "pool.dataset.query", []interface{}{}, map[string]interface{}{
+		"select": []string{"id"},
+		"extra":  map[string]interface{}{"flat": true, "retrieve_children": false},
+	}); err == nil {
+		snapshot.Datasets = inventoryNames(datasets, "id")
+	} else {
+		note("datasets", err)
+	}
+
+	if smb, err := inventoryQuery(client, "sharing.smb.query"); err == nil {
+		snapshot.SMBShares = inventoryNames(smb, "name")
+	} else {
+		note("SMB shares", err)
+	}
+	if nfs, err := inventoryQuery(client, "sharing.nfs.query"); err == nil {
+		snapshot.NFSShares = inventoryNames(nfs, "path")
+	} else {
+		note("NFS shares", err)
+	}
+
+	if apps, err := inventoryQuery(client, "app.query"); err == nil {
+		for _, app := range apps {
+			name, _ := app["name"].(string)
+			if name == "" {
+				continue
+			}
+			version, _ := app["human_version"].(string)
+			if version == "" {
+				version, _ = app["version"].(string)
+			}
+			snapshot.Apps[name] = version
+		}
+	} else {
+		note("apps", err)
+	}
+
+	if vms, err := inventoryQuery(client, "vm.query"); err == nil {
+		snapshot.VMs = inventoryNames(vms, "name")
+	} else {
+		note("VMs", err)
+	}
+
+	if users, err := inventoryQuery(client, "user.query", []interface{}{
+		[]interface{}{"builtin", "=", false},
+	}); err == nil {
+		snapshot.Users = inventoryNames(users, "username")
+	} else {
+		note("users", err)
+	}
+
+	if interfaces, err := inventoryQuery(client, "interface.query"); err == nil {
+		for _, iface := range interfaces {
+			name, _ := iface["name"].(string)
+			if name == "" {
+				continue
+			}
+			addresses := []string{}
+			aliases, _ := iface["aliases"].([]interface{})
+			for _, raw := range aliases {
+				alias, _ := raw.(map[string]interface{})
+				if address, ok := alias["address"].(string); ok {
+					addresses = append(addresses, fmt.Sprintf("%s/%v", address, alias["netmask"]))
+				}
+			}
+			sort.Strings(addresses)
+			snapshot.Interfaces[name] = strings.Join(addresses, ", ")
+		}
+	} else {
+		note("interfaces", err)
+	}
+
+	return snapshot
+}
+
+// snapshotCounts sizes each section of a snapshot
+func snapshotCounts(snapshot *inventory.Snapshot) map[string]int {
+	return map[string]int{
+		"pools":      len(snapshot.Pools),
+		"datasets":   len(snapshot.Datasets),
+		"smb_shares": len(snapshot.SMBShares),
+		"nfs_shares": len(snapshot.NFSShares),
+		"apps":       len(snapshot.Apps),
+		"vms":        len(snapshot.VMs),
+		"users":      len(snapshot.Users),
+		"interfaces": len(snapshot.Interfaces),
+	}
+}
+
+func (r *Registry) handleSaveInventorySnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.inventoryStore == nil {
+		return "", fmt.Errorf("inventory snapshots are not enabled on this server")
+	}
+
+	snapshot := collectSnapshot(client)
+	snapshot.Label, _ = args["label"].(string)
+	if snapshot.Label == liveSnapshotRef {
+		return "", fmt.Errorf("label '%s' is reserved for the current system state", liveSnapshotRef)
+	}
+
+	r.inventoryStore.Add(snapshot)
+	if err := r.inventoryStore.Save(); err != nil {
+		return "", fmt.Errorf("snapshot taken but not persisted: %w", err)
+	}
+
+	response := map[string]interface{}{
+		"snapshot": snapshot.Summary(),
+		"counts":   snapshotCounts(snapshot),
+		"message":  fmt.Sprintf("Saved inventory snapshot %s. Compare it later with diff_inventory from=%s.", snapshot.ID, snapshot.ID),
+	}
+	if len(snapshot.Notes) > 0 {
+		response["collection_notes"] = snapshot.Notes
+	}
+	return marshalJSON(response)
+}
+
+func (r *Registry) handleListInventorySnapshots(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.inventoryStore == nil {
+		return "", fmt.Errorf("inventory snapshots are not enabled on this server")
+	}
+
+	snapshots := r.inventoryStore.List()
+	return marshalJSON(map[string]interface{}{
+		"snapshots": snapshots,
+		"count":     len(snapshots),
+	})
+}
+
+// resolveSnapshot finds a saved snapshot by ID or label, or collects the live state
+func (r *Registry) resolveSnapshot(client truenas.Caller, ref string) (*inventory.Snapshot, error) {
+	if ref == liveSnapshotRef {
+		snapshot := collectSnapshot(client)
+		snapshot.ID = liveSnapshotRef
+		return snapshot, nil
+	}
+	if snapshot := r.inventoryStore.Get(ref); snapshot != nil {
+		return snapshot, nil
+	}
+	return nil, newToolError(ErrorNotFound, "inventory snapshot '%s' not found; use list_inventory_snapshots to see saved snapshots", ref)
+}
+
+func (r *Registry) handleDiffInventory(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.inventoryStore == nil {
+		return "", fmt.Errorf("inventory snapshots are not enabled on this server")
+	}
+
+	fromRef, _ := args["from"].(string)
+	if fromRef == "" {
+		latest := r.inventoryStore.Latest()
+		if latest == nil {
+			return "", fmt.Errorf("no inventory snapshots saved yet; take one with save_inventory_snapshot")
+		}
+		fromRef = latest.ID
+	}
+	toRef, _ := args["to"].(string)
+	if toRef == "" {
+		toRef = liveSnapshotRef
+	}
+
+	from, err := r.resolveSnapshot(client, fromRef)
+	if err != nil {
+		return "", err
+	}
+	to, err := r.resolveSnapshot(client, toRef)
+	if err != nil {
+		return "", err
+	}
+
+	diff := inventory.Compare(from, to)
+	response := map[string]interface{}{
+		"from":          diff.From,
+		"to":            diff.To,
+		"changes":       diff.Sections,
+		"total_changes": diff.Total,
+	}
+	if diff.Total == 0 {
+		response["message"] = "No configuration changes between the two snapshots"
+	}
+
+	// Sections missing from either side would otherwise show up as mass additions or removals
+	notes := []string{}
+	for _, n := range from.Notes {
+		notes = append(notes, "from: "+n)
+	}
+	for _, n := range to.Notes {
+		notes = append(notes, "to: "+n)
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+
+	return marshalJSON(response)
+}
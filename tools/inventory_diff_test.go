@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/inventory"
+)
+
+func TestIntegrationDiffInventory(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	store, err := inventory.NewStore("", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	registry.inventoryStore = store
+
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas", "version": "25.04.0"})
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank"}, {"id": "tank/media"}})
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{{"name": "media"}, {"name": "scratch"}})
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{})
+	server.SetRecords("app.query", []map[string]interface{}{{"name": "plex", "human_version": "1.40.0_1.0.0"}})
+	server.SetRecords("vm.query", []map[string]interface{}{})
+	server.SetRecords("user.query", []map[string]interface{}{})
+	server.SetRecords("interface.query", []map[string]interface{}{})
+
+	if _, err := registry.CallTool("diff_inventory", map[string]interface{}{}); err == nil {
+		t.Errorf("diff_inventory with no snapshots should fail")
+	}
+
+	saved, err := registry.CallTool("save_inventory_snapshot", map[string]interface{}{"label": "baseline"})
+	if err != nil {
+		t.Fatalf("save_inventory_snapshot failed: %v", err)
+	}
+	if counts := decodeResult(t, saved)["counts"].(map[string]interface{}); counts["datasets"] != float64(2) {
+		t.Errorf("snapshot counts = %v, want 2 datasets", counts)
+	}
+
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank"}, {"id": "tank/media"}, {"id": "tank/backups"}})
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{{"name": "media"}})
+	server.SetRecords("app.query", []map[string]interface{}{{"name": "plex", "human_version": "1.41.0_1.0.1"}})
+
+	result, err := registry.CallTool("diff_inventory", map[string]interface{}{"from": "baseline"})
+	if err != nil {
+		t.Fatalf("diff_inventory failed: %v", err)
+	}
+	diff := decodeResult(t, result)
+	if diff["total_changes"] != float64(3) {
+		t.Errorf("total_changes = %v, want 3:\n%s", diff["total_changes"], result)
+	}
+	changes := diff["changes"].(map[string]interface{})
+	if added := changes["datasets"].(map[string]interface{})["added"].([]interface{}); len(added) != 1 || added[0] != "tank/backups" {
+		t.Errorf("datasets added = %v, want tank/backups", added)
+	}
+	if removed := changes["smb_shares"].(map[string]interface{})["removed"].([]interface{}); len(removed) != 1 || removed[0] != "scratch" {
+		t.Errorf("smb shares removed = %v, want scratch", removed)
+	}
+	if !strings.Contains(result, `"to": "1.41.0_1.0.1"`) {
+		t.Errorf("app version change missing:\n%s", result)
+	}
+
+	if _, err := registry.CallTool("diff_inventory", map[string]interface{}{"from": "missing"}); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown snapshot error = %v, want NOT_FOUND", err)
+	}
+}
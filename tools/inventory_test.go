@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationGetInventory(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas", "version": "25.04.0", "cores": float64(8), "physmem": float64(32 * 1024 * 1024 * 1024)})
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.SetResult("pool.dataset.query", float64(12))
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{{"name": "media"}, {"name": "docs"}})
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{{"path": "/mnt/tank/vms"}})
+	server.SetRecords("app.query", []map[string]interface{}{{"name": "plex", "state": "RUNNING"}})
+	server.SetError("vm.query", 22, "virtualization is not supported")
+	server.SetRecords("user.query", []map[string]interface{}{
+		{"username": "root", "builtin": true},
+		{"username": "alice", "builtin": false},
+	})
+	server.SetRecords("interface.query", []map[string]interface{}{{
+		"name":    "eno1",
+		"state":   map[string]interface{}{"link_state": "LINK_STATE_UP"},
+		"aliases": []interface{}{map[string]interface{}{"address": "10.0.0.5", "netmask": float64(24)}},
+	}})
+
+	result, err := registry.CallTool("get_inventory", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_inventory failed: %v", err)
+	}
+	inventory := decodeResult(t, result)
+
+	if system := inventory["system"].(map[string]interface{}); system["hostname"] != "nas" || system["memory"] != "32.00 GiB" {
+		t.Errorf("system = %v", system)
+	}
+	if inventory["dataset_count"] != float64(12) {
+		t.Errorf("dataset_count = %v, want 12", inventory["dataset_count"])
+	}
+	if users := inventory["users"].([]interface{}); len(users) != 1 || users[0] != "alice" {
+		t.Errorf("users = %v, want only alice", users)
+	}
+	if !strings.Contains(result, "plex (running)") || !strings.Contains(result, "10.0.0.5/24") {
+		t.Errorf("inventory missing apps or interfaces:\n%s", result)
+	}
+	if notes, _ := inventory["collection_notes"].([]interface{}); len(notes) != 1 {
+		t.Errorf("collection_notes = %v, want the VM failure noted", notes)
+	}
+
+	resource, err := registry.ReadResource(context.Background(), "truenas://inventory")
+	if err != nil {
+		t.Fatalf("ReadResource failed: %v", err)
+	}
+	if !strings.Contains(resource, `"dataset_count": 12`) {
+		t.Errorf("inventory resource differs from tool:\n%s", resource)
+	}
+	if _, err := registry.ReadResource(context.Background(), "truenas://nope"); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown resource error = %v, want NOT_FOUND", err)
+	}
+}
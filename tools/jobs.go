@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Job filtering helpers and abort handler
+
+// jobMethodPrefix turns a method pattern such as "replication.*" or
+// "pool.scrub" into the prefix used for the "^" query filter
+func jobMethodPrefix(pattern string) string {
+	return strings.TrimSuffix(strings.TrimSpace(pattern), "*")
+}
+
+// parseJobTime reads a timestamp argument given as Unix seconds, RFC3339, or a
+// YYYY-MM-DD date (midnight UTC)
+func parseJobTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case float64:
+		return time.Unix(int64(t), 0), nil
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			return parsed, nil
+		}
+		if parsed, err := time.Parse("2006-01-02", t); err == nil {
+			return parsed, nil
+		}
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: use Unix seconds, RFC3339, or YYYY-MM-DD", t)
+	}
+	return time.Time{}, fmt.Errorf("timestamp must be Unix seconds or a date string")
+}
+
+// jobTimeFilter builds a time_started query filter in the middleware's date encoding
+func jobTimeFilter(op string, t time.Time) []interface{} {
+	return []interface{}{"time_started", op, map[string]interface{}{"$date": t.UnixMilli()}}
+}
+
+// getJob returns a job by ID
+func getJob(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("core.get_jobs", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job: %w", err)
+	}
+
+	var jobs []map[string]interface{}
+	if err := json.Unmarshal(result, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse job: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("job %d not found", id)
+	}
+	return jobs[0], nil
+}
+
+// jobFailedError reports a job that ended FAILED or ABORTED
+type jobFailedError struct {
+	ID      int
+	State   string
+	Message string
+}
+
+func (e *jobFailedError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("job %d %s", e.ID, strings.ToLower(e.State))
+	}
+	return fmt.Sprintf("job %d %s: %s", e.ID, strings.ToLower(e.State), e.Message)
+}
+
+// waitForJob polls a job every interval until it finishes and returns the
+// finished job. A FAILED or ABORTED job returns a *jobFailedError. Query
+// errors are retried; when ctx ends first, its error is returned with the
+// last query error, if any.
+func waitForJob(ctx context.Context, client truenas.Caller, jobID int, interval time.Duration) (map[string]interface{}, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var queryErr error
+	for {
+		job, err := getJob(client, jobID)
+		if err == nil {
+			switch state, _ := job["state"].(string); state {
+			case "SUCCESS":
+				return job, nil
+			case "FAILED", "ABORTED":
+				message, _ := job["error"].(string)
+				return job, &jobFailedError{ID: jobID, State: state, Message: message}
+			}
+		}
+		queryErr = err
+
+		select {
+		case <-ctx.Done():
+			if queryErr != nil {
+				return nil, fmt.Errorf("%w (last query error: %v)", ctx.Err(), queryErr)
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForJobWithin is waitForJob bounded by timeout, which is reported as a
+// TIMEOUT error
+func waitForJobWithin(ctx context.Context, client truenas.Caller, jobID int, interval, timeout time.Duration) (map[string]interface{}, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	job, err := waitForJob(waitCtx, client, jobID, interval)
+	if err != nil && waitCtx.Err() != nil && ctx.Err() == nil {
+		return nil, newToolError(ErrorTimeout, "job %d did not finish within %s", jobID, timeout)
+	}
+	return job, err
+}
+
+// checkJobAbortable explains why a job cannot be aborted, or returns nil
+func checkJobAbortable(job map[string]interface{}) error {
+	state, _ := job["state"].(string)
+	if state != "RUNNING" && state != "WAITING" {
+		return fmt.Errorf("job %v is %s; only RUNNING or WAITING jobs can be aborted", job["id"], state)
+	}
+	if abortable, ok := job["abortable"].(bool); ok && !abortable {
+		return fmt.Errorf("job %v (%v) does not support aborting", job["id"], job["method"])
+	}
+	return nil
+}
+
+func handleAbortJob(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	job, err := getJob(client, id)
+	if err != nil {
+		return "", err
+	}
+	if err := checkJobAbortable(job); err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("core.job_abort", id); err != nil {
+		return "", fmt.Errorf("failed to abort job: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"id":      id,
+		"method":  job["method"],
+		"aborted": true,
+		"message": fmt.Sprintf("Abort requested for job %d (%v). Use query_jobs to confirm it reaches ABORTED", id, job["method"]),
+	})
+}
+
+func (r *Registry) handleAbortJobWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &abortJobDryRun{}, handleAbortJob)
+}
+
+type abortJobDryRun struct{}
+
+func (a *abortJobDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	jobID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(jobID)
+
+	job, err := getJob(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{}
+	warnings := []string{}
+	if err := checkJobAbortable(job); err != nil {
+		warnings = append(warnings, "BLOCKED: "+err.Error())
+	} else {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: fmt.Sprintf("Abort job %d (%v)", id, job["method"]),
+			Operation:   "abort",
+			Target:      fmt.Sprintf("job %d", id),
+		})
+		warnings = append(warnings, "Work done so far is not rolled back; an aborted operation may leave partial results")
+	}
+
+	return &DryRunResult{
+		Tool: "abort_job",
+		CurrentState: map[string]interface{}{
+			"id":          id,
+			"method":      job["method"],
+			"state":       job["state"],
+			"description": job["description"],
+			"progress":    job["progress"],
+			"abortable":   job["abortable"],
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
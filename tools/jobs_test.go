@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func startMockJob(t *testing.T, mock *truenastest.Mock, method string, spec truenastest.JobSpec) int {
+	t.Helper()
+	mock.HandleJob(method, spec)
+	result, err := mock.Call(method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	if err := json.Unmarshal(result, &id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestWaitForJob(t *testing.T) {
+	mock := truenastest.NewMock()
+	ctx := context.Background()
+
+	id := startMockJob(t, mock, "pool.scrub", truenastest.JobSpec{Steps: 2, Result: true})
+	job, err := waitForJob(ctx, mock, id, time.Millisecond)
+	if err != nil || job["result"] != true {
+		t.Errorf("successful job = %v, %v; want its result", job, err)
+	}
+
+	id = startMockJob(t, mock, "pool.export", truenastest.JobSpec{Error: "pool is busy"})
+	_, err = waitForJob(ctx, mock, id, time.Millisecond)
+	var failed *jobFailedError
+	if !errors.As(err, &failed) || failed.State != "FAILED" || failed.Message != "pool is busy" {
+		t.Errorf("failed job error = %v, want a jobFailedError with the job's message", err)
+	}
+
+	// A cancelled context stops the wait without waiting for the next poll
+	id = startMockJob(t, mock, "app.create", truenastest.JobSpec{Steps: 1000})
+	cancelled, cancel := context.WithCancel(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := waitForJob(cancelled, mock, id, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled wait error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled wait took %s", elapsed)
+	}
+
+	// The caller's own timeout is a TIMEOUT error; the context's end is not
+	_, err = waitForJobWithin(ctx, mock, id, time.Millisecond, 20*time.Millisecond)
+	if code := ClassifyError(err).Code; code != ErrorTimeout {
+		t.Errorf("timed out wait code = %s (%v), want %s", code, err, ErrorTimeout)
+	}
+	if _, err := waitForJobWithin(cancelled, mock, id, time.Millisecond, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled bounded wait error = %v, want context.Canceled", err)
+	}
+}
+
+func TestIntegrationQueryJobsFiltersAndAbort(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	scrubID := server.AddJob("pool.scrub.scrub", []interface{}{"tank"}, truenastest.JobSpec{Steps: 100})
+	server.AddJob("replication.run", []interface{}{float64(1)}, truenastest.JobSpec{Steps: 100})
+
+	result, err := registry.CallTool("query_jobs", map[string]interface{}{
+		"state":  "all",
+		"method": "pool.scrub.*",
+	})
+	if err != nil {
+		t.Fatalf("query_jobs failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	jobs, _ := response["jobs"].([]interface{})
+	if len(jobs) != 1 || jobs[0].(map[string]interface{})["method"] != "pool.scrub.scrub" {
+		t.Fatalf("jobs = %v, want only the scrub job", response["jobs"])
+	}
+
+	result, err = registry.CallTool("query_jobs", map[string]interface{}{
+		"state":         "all",
+		"started_after": float64(time.Now().Add(time.Hour).Unix()),
+	})
+	if err != nil {
+		t.Fatalf("query_jobs with started_after failed: %v", err)
+	}
+	if n := decodeResult(t, result)["job_count"]; n != float64(0) {
+		t.Errorf("job_count = %v, want 0 for jobs started in the future", n)
+	}
+
+	if _, err := registry.CallTool("abort_job", map[string]interface{}{"id": float64(scrubID)}); err != nil {
+		t.Fatalf("abort_job failed: %v", err)
+	}
+	if calls := server.Calls("core.job_abort"); len(calls) != 1 || calls[0].Params[0] != float64(scrubID) {
+		t.Fatalf("core.job_abort calls = %v, want one for job %d", calls, scrubID)
+	}
+
+	if _, err := registry.CallTool("abort_job", map[string]interface{}{"id": float64(scrubID)}); err == nil || !strings.Contains(err.Error(), "ABORTED") {
+		t.Errorf("aborting an aborted job: err = %v, want state error", err)
+	}
+}
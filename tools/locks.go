@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Operation locking for write tools
+
+// lockSpec names the object a write tool mutates: a resource type and the
+// argument identifying the instance ("" when the resource is system-wide)
+type lockSpec struct {
+	Resource string
+	Arg      string
+}
+
+// operationLockSpecs groups write tools that conflict on the same object.
+// Unlisted write tools lock on their own name and first required argument.
+var operationLockSpecs = map[string]lockSpec{
+	"download_update":             {Resource: "system_update"},
+	"apply_update":                {Resource: "system_update"},
+	"delete_boot_environment":     {Resource: "boot_environment", Arg: "id"},
+	"update_system_dataset":       {Resource: "system_dataset"},
+	"configure_directory_service": {Resource: "directory_service"},
+	"leave_directory_service":     {Resource: "directory_service"},
+	"install_app":                 {Resource: "app", Arg: "app_name"},
+	"install_from_template":       {Resource: "app", Arg: "app_name"},
+	"upgrade_app":                 {Resource: "app", Arg: "app_name"},
+	"start_app":                   {Resource: "app", Arg: "app_name"},
+	"stop_app":                    {Resource: "app", Arg: "app_name"},
+	"rollback_app":                {Resource: "app", Arg: "app_name"},
+	"redeploy_app":                {Resource: "app", Arg: "app_name"},
+	"delete_app":                  {Resource: "app", Arg: "app_name"},
+	"run_scrub":                   {Resource: "scrub", Arg: "pool"},
+	"upgrade_pool":                {Resource: "pool_upgrade", Arg: "pool"},
+	"replace_disk":                {Resource: "pool_topology", Arg: "pool"},
+	"attach_disk":                 {Resource: "pool_topology", Arg: "pool"},
+	"detach_disk":                 {Resource: "pool_topology", Arg: "pool"},
+	"add_vdevs":                   {Resource: "pool_topology", Arg: "pool"},
+	"create_dataset":              {Resource: "dataset", Arg: "name"},
+	"update_dataset":              {Resource: "dataset", Arg: "name"},
+	"delete_dataset":              {Resource: "dataset", Arg: "name"},
+	"set_acl":                     {Resource: "path", Arg: "path"},
+	"set_permissions":             {Resource: "path", Arg: "path"},
+	"restore_file_from_snapshot":  {Resource: "snapshot_restore", Arg: "path"},
+	"create_smb_share":            {Resource: "smb_share", Arg: "name"},
+	"delete_smb_share":            {Resource: "smb_share", Arg: "name"},
+	"configure_capacity_alerts":   {Resource: "dataset", Arg: "dataset"},
+	"update_scrub_schedule":       {Resource: "scrub_schedule", Arg: "id"},
+	"delete_scrub_schedule":       {Resource: "scrub_schedule", Arg: "id"},
+	"abort_job":                   {Resource: "job", Arg: "id"},
+	"update_cron_job":             {Resource: "cron_job", Arg: "id"},
+	"delete_cron_job":             {Resource: "cron_job", Arg: "id"},
+	"run_cron_job":                {Resource: "cron_job", Arg: "id"},
+	"update_init_shutdown_script": {Resource: "init_shutdown_script", Arg: "id"},
+	"delete_init_shutdown_script": {Resource: "init_shutdown_script", Arg: "id"},
+	"update_static_route":         {Resource: "static_route", Arg: "id"},
+	"delete_static_route":         {Resource: "static_route", Arg: "id"},
+	"run_replication":             {Resource: "replication", Arg: "id"},
+	"run_cloud_sync":              {Resource: "cloud_sync", Arg: "id"},
+	"run_smart_test":              {Resource: "smart_test", Arg: "disks"},
+	"delete_smart_test_schedule":  {Resource: "smart_test_schedule", Arg: "id"},
+	"start_service":               {Resource: "service", Arg: "service"},
+	"stop_service":                {Resource: "service", Arg: "service"},
+	"restart_service":             {Resource: "service", Arg: "service"},
+	"set_service_autostart":       {Resource: "service", Arg: "service"},
+	"create_vm":                   {Resource: "vm", Arg: "name"},
+	"start_vm":                    {Resource: "vm", Arg: "vm"},
+	"stop_vm":                     {Resource: "vm", Arg: "vm"},
+	"restart_vm":                  {Resource: "vm", Arg: "vm"},
+	"clone_vm":                    {Resource: "vm", Arg: "vm"},
+	"delete_vm":                   {Resource: "vm", Arg: "vm"},
+	"create_user":                 {Resource: "user", Arg: "username"},
+	"update_user":                 {Resource: "user", Arg: "username"},
+	"delete_user":                 {Resource: "user", Arg: "username"},
+
+	// Plans lock per step
+	"execute_plan": {},
+}
+
+// operationLock is a held lock. Calls that start a task keep the lock until
+// the task finishes.
+type operationLock struct {
+	Key           string
+	Tool          string
+	CorrelationID string
+	StartedAt     time.Time
+	TaskID        string
+	running       bool
+}
+
+// operationLocks tracks the objects currently being mutated
+type operationLocks struct {
+	mu   sync.Mutex
+	held map[string]*operationLock
+}
+
+func newOperationLocks() *operationLocks {
+	return &operationLocks{held: make(map[string]*operationLock)}
+}
+
+// operationLockKey returns the lock key for a call, or "" for calls that do not
+// mutate anything (read-only tools and dry runs)
+func operationLockKey(name string, tool Tool, args map[string]interface{}) string {
+	if timeoutCategory(tool, args) != TimeoutCategoryJob {
+		return ""
+	}
+
+	spec, ok := operationLockSpecs[name]
+	if !ok {
+		spec = lockSpec{Resource: name}
+		if required, ok := tool.Definition.InputSchema["required"].([]string); ok && len(required) > 0 {
+			spec.Arg = required[0]
+		}
+	}
+	if spec.Resource == "" {
+		return ""
+	}
+	if spec.Arg == "" {
+		return spec.Resource
+	}
+	if value, ok := args[spec.Arg]; ok && value != nil {
+		return fmt.Sprintf("%s:%v", spec.Resource, value)
+	}
+	return spec.Resource
+}
+
+// acquire takes the lock for key. A lock whose handler has returned and whose
+// task is no longer active is stale and is replaced.
+func (l *operationLocks) acquire(key, tool, correlationID string, taskActive func(string) bool) (*operationLock, *ToolError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if existing, ok := l.held[key]; ok {
+		if existing.running || (existing.TaskID != "" && taskActive(existing.TaskID)) {
+			return nil, operationInProgressError(existing)
+		}
+	}
+
+	lock := &operationLock{
+		Key:           key,
+		Tool:          tool,
+		CorrelationID: correlationID,
+		StartedAt:     time.Now(),
+		running:       true,
+	}
+	l.held[key] = lock
+	return lock, nil
+}
+
+// finish records that the lock's handler returned. The lock stays held while
+// taskID (if any) is active; otherwise it is released.
+func (l *operationLocks) finish(lock *operationLock, taskID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held[lock.Key] != lock {
+		return
+	}
+	lock.running = false
+	if taskID == "" {
+		delete(l.held, lock.Key)
+		return
+	}
+	lock.TaskID = taskID
+}
+
+// operationInProgressError describes the call holding a lock
+func operationInProgressError(existing *operationLock) *ToolError {
+	elapsed := time.Since(existing.StartedAt).Round(time.Second)
+	details := map[string]interface{}{
+		"resource":       existing.Key,
+		"tool":           existing.Tool,
+		"started_at":     existing.StartedAt.UTC().Format(time.RFC3339),
+		"correlation_id": existing.CorrelationID,
+	}
+
+	message := fmt.Sprintf("operation in progress: %s on %s started %s ago", existing.Tool, existing.Key, elapsed)
+	if existing.TaskID != "" {
+		details["task_id"] = existing.TaskID
+		message += fmt.Sprintf(" and is still running as task %s; check tasks_get before retrying", existing.TaskID)
+	} else {
+		message += " and has not returned yet; wait for it before retrying"
+	}
+
+	return &ToolError{Code: ErrorInProgress, Message: message, Details: details}
+}
+
+// lockedHandler runs handler under lock, keeping the lock for any task the
+// call starts. It is released when the handler returns, even after the caller
+// has timed out.
+func (r *Registry) lockedHandler(lock *operationLock, handler func(context.Context, truenas.Caller, map[string]interface{}) (string, error)) func(context.Context, truenas.Caller, map[string]interface{}) (string, error) {
+	return func(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+		taskID := ""
+		defer func() {
+			r.locks.finish(lock, taskID)
+		}()
+
+		result, err := handler(ctx, client, args)
+		if r.taskManager != nil {
+			if started := r.taskManager.ActiveForCall(lock.CorrelationID); len(started) > 0 {
+				taskID = started[0].TaskID
+			}
+		}
+		return result, err
+	}
+}
+
+// taskActive reports whether a task started by a locked call is still running
+func (r *Registry) taskActive(taskID string) bool {
+	return r.taskManager != nil && r.taskManager.IsActive(taskID)
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestOperationLockKey(t *testing.T) {
+	write := Tool{Definition: mcp.Tool{InputSchema: map[string]interface{}{
+		"properties": map[string]interface{}{"dry_run": map[string]interface{}{}},
+		"required":   []string{"name"},
+	}}}
+	read := Tool{Definition: mcp.Tool{InputSchema: map[string]interface{}{}}}
+
+	tests := []struct {
+		name string
+		tool Tool
+		args map[string]interface{}
+		want string
+	}{
+		{name: "apply_update", tool: write, args: map[string]interface{}{}, want: "system_update"},
+		{name: "delete_boot_environment", tool: write, args: map[string]interface{}{"id": "24.04.1"}, want: "boot_environment:24.04.1"},
+		{name: "delete_boot_environment", tool: write, args: map[string]interface{}{"id": "24.04.1", "dry_run": true}, want: ""},
+		{name: "create_widget", tool: write, args: map[string]interface{}{"name": "w1"}, want: "create_widget:w1"},
+		{name: "query_pools", tool: read, args: map[string]interface{}{}, want: ""},
+	}
+	for _, tt := range tests {
+		if got := operationLockKey(tt.name, tt.tool, tt.args); got != tt.want {
+			t.Errorf("operationLockKey(%s, %v) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestOperationLocks(t *testing.T) {
+	locks := newOperationLocks()
+	active := map[string]bool{}
+	taskActive := func(id string) bool { return active[id] }
+
+	first, err := locks.acquire("system_update", "apply_update", "corr-1", taskActive)
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	if _, err := locks.acquire("system_update", "apply_update", "corr-2", taskActive); err == nil || err.Code != ErrorInProgress {
+		t.Fatalf("second acquire while running = %v, want OPERATION_IN_PROGRESS", err)
+	}
+
+	// The call returned but its task is still running
+	active["task-1"] = true
+	locks.finish(first, "task-1")
+	_, err = locks.acquire("system_update", "download_update", "corr-3", taskActive)
+	if err == nil {
+		t.Fatal("acquire while task runs succeeded, want OPERATION_IN_PROGRESS")
+	}
+	if details, _ := err.Details.(map[string]interface{}); details["task_id"] != "task-1" {
+		t.Errorf("details = %v, want task_id task-1", err.Details)
+	}
+
+	// Once the task finishes the lock is stale
+	active["task-1"] = false
+	second, err := locks.acquire("system_update", "apply_update", "corr-4", taskActive)
+	if err != nil {
+		t.Fatalf("acquire after task finished failed: %v", err)
+	}
+	locks.finish(second, "")
+	if _, err := locks.acquire("system_update", "apply_update", "corr-5", taskActive); err != nil {
+		t.Errorf("acquire after release failed: %v", err)
+	}
+}
+
+func TestIntegrationOperationLock(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.Handle("pool.scrub.run", func(params []interface{}) (interface{}, error) {
+		server.AddJob("pool.scrub.scrub", params, truenastest.JobSpec{Steps: 1000})
+		return nil, nil
+	})
+
+	result, err := registry.CallTool("run_scrub", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("run_scrub failed: %v", err)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+
+	_, err = registry.CallTool("run_scrub", map[string]interface{}{"pool": "tank"})
+	classified := ClassifyError(err)
+	if classified == nil || classified.Code != ErrorInProgress {
+		t.Fatalf("second run_scrub error = %v, want OPERATION_IN_PROGRESS", err)
+	}
+	if details, _ := classified.Details.(map[string]interface{}); details["task_id"] != taskID {
+		t.Errorf("details = %v, want task_id %s", classified.Details, taskID)
+	}
+	if !strings.Contains(classified.Message, taskID) {
+		t.Errorf("message %q does not reference task %s", classified.Message, taskID)
+	}
+}
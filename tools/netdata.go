@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/truenas/truenas-mcp/netdata"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Netdata passthrough handlers
+
+const netdataDisabledMessage = "netdata passthrough is not configured (start the server with --netdata-url)"
+
+func (r *Registry) handleListNetdataCharts(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.netdata == nil {
+		return "", fmt.Errorf(netdataDisabledMessage)
+	}
+
+	charts, err := r.netdata.Charts()
+	if err != nil {
+		return "", err
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"charts":           charts,
+		"count":            len(charts),
+		"allowed_prefixes": r.netdata.AllowedCharts(),
+	})
+}
+
+func (r *Registry) handleGetNetdataChart(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.netdata == nil {
+		return "", fmt.Errorf(netdataDisabledMessage)
+	}
+
+	chart, ok := args["chart"].(string)
+	if !ok || chart == "" {
+		return "", fmt.Errorf("chart is required (use list_netdata_charts to discover chart IDs)")
+	}
+
+	query := netdata.Query{Window: 60 * time.Second}
+	if seconds, ok := args["seconds"].(float64); ok {
+		query.Window = time.Duration(seconds) * time.Second
+	}
+	if points, ok := args["points"].(float64); ok {
+		query.Points = int(points)
+	}
+	if group, ok := args["group"].(string); ok {
+		query.Group = group
+	}
+
+	series, err := r.netdata.Data(chart, query)
+	if err != nil {
+		return "", err
+	}
+
+	return marshalJSON(series)
+}
@@ -0,0 +1,15 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationNetdataDisabled(t *testing.T) {
+	registry, _ := newTestRegistry(t)
+
+	_, err := registry.CallTool("get_netdata_chart", map[string]interface{}{"chart": "system.cpu"})
+	if err == nil || !strings.Contains(err.Error(), "--netdata-url") {
+		t.Errorf("expected not-configured error, got %v", err)
+	}
+}
@@ -0,0 +1,601 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Global network settings (hostname, domain, DNS, default gateways) and
+// static routes
+
+// maxNameservers is how many nameservers TrueNAS configures
+const maxNameservers = 3
+
+// hostnamePattern matches a single DNS label
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// domainNamePattern matches a dotted DNS name
+var domainNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+
+// getNetworkConfig returns network.configuration.config
+func getNetworkConfig(client truenas.Caller) (map[string]interface{}, error) {
+	result, err := client.Call("network.configuration.config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network configuration: %w", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse network configuration: %w", err)
+	}
+	return config, nil
+}
+
+// configuredNameservers returns nameserver1-3 without the empty ones
+func configuredNameservers(config map[string]interface{}) []string {
+	nameservers := []string{}
+	for i := 1; i <= maxNameservers; i++ {
+		if ns, _ := config[fmt.Sprintf("nameserver%d", i)].(string); ns != "" {
+			nameservers = append(nameservers, ns)
+		}
+	}
+	return nameservers
+}
+
+// simplifyNetworkConfig summarizes the global network settings. state holds
+// the values in effect, which come from DHCP when none are configured.
+func simplifyNetworkConfig(config map[string]interface{}) map[string]interface{} {
+	summary := map[string]interface{}{
+		"hostname":       config["hostname"],
+		"domain":         config["domain"],
+		"search_domains": config["domains"],
+		"nameservers":    configuredNameservers(config),
+		"ipv4_gateway":   config["ipv4gateway"],
+		"ipv6_gateway":   config["ipv6gateway"],
+		"hosts":          config["hosts"],
+	}
+	if proxy, _ := config["httpproxy"].(string); proxy != "" {
+		summary["http_proxy"] = proxy
+	}
+	if state, ok := config["state"].(map[string]interface{}); ok {
+		summary["in_effect"] = map[string]interface{}{
+			"nameservers":  configuredNameservers(state),
+			"ipv4_gateway": state["ipv4gateway"],
+			"ipv6_gateway": state["ipv6gateway"],
+		}
+	}
+	return summary
+}
+
+// queryStaticRoutes returns staticroute.query
+func queryStaticRoutes(client truenas.Caller) ([]map[string]interface{}, error) {
+	result, err := client.Call("staticroute.query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query static routes: %w", err)
+	}
+	var routes []map[string]interface{}
+	if err := json.Unmarshal(result, &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse static routes: %w", err)
+	}
+	return routes, nil
+}
+
+// simplifyStaticRoute returns the fields of a static route
+func simplifyStaticRoute(route map[string]interface{}) map[string]interface{} {
+	id, _ := route["id"].(float64)
+	return map[string]interface{}{
+		"id":          int(id),
+		"destination": route["destination"],
+		"gateway":     route["gateway"],
+		"description": route["description"],
+	}
+}
+
+// findStaticRoute looks up a static route by ID
+func findStaticRoute(client truenas.Caller, id int) (map[string]interface{}, error) {
+	routes, err := queryStaticRoutes(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		if routeID, _ := route["id"].(float64); int(routeID) == id {
+			return route, nil
+		}
+	}
+	return nil, newToolError(ErrorNotFound, "static route %d not found", id)
+}
+
+// dhcpInterfaces returns the interfaces configured for IPv4 DHCP, which
+// supply the gateway and nameservers unless they are set globally
+func dhcpInterfaces(client truenas.Caller) []string {
+	interfaces, err := inventoryQuery(client, "interface.query")
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, iface := range interfaces {
+		if dhcp, _ := iface["ipv4_dhcp"].(bool); dhcp {
+			name, _ := iface["name"].(string)
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func handleGetNetworkConfig(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getNetworkConfig(client)
+	if err != nil {
+		return "", err
+	}
+	routes, err := queryStaticRoutes(client)
+	if err != nil {
+		return "", err
+	}
+
+	response := simplifyNetworkConfig(config)
+	simplified := make([]map[string]interface{}, 0, len(routes))
+	for _, route := range routes {
+		simplified = append(simplified, simplifyStaticRoute(route))
+	}
+	response["static_routes"] = simplified
+	if dhcp := dhcpInterfaces(client); len(dhcp) > 0 {
+		response["dhcp_interfaces"] = dhcp
+		response["note"] = "Interfaces using DHCP supply the gateway and nameservers in_effect when none are configured here"
+	}
+	return marshalJSON(response)
+}
+
+// parseIPArg reads an IP address argument of the given family ("" clears it)
+func parseIPArg(args map[string]interface{}, key string, v6 bool) (string, bool, error) {
+	value, ok := args[key].(string)
+	if !ok {
+		return "", false, nil
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", true, nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil || addr.Is6() != v6 || addr.Is4In6() {
+		family := "IPv4"
+		if v6 {
+			family = "IPv6"
+		}
+		return "", false, newToolError(ErrorValidation, "%s must be an %s address, got %q", key, family, value)
+	}
+	return addr.String(), true, nil
+}
+
+// networkConfigUpdate builds the network.configuration.update payload
+func networkConfigUpdate(args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if hostname, ok := args["hostname"].(string); ok {
+		if !hostnamePattern.MatchString(hostname) {
+			return nil, newToolError(ErrorValidation, "hostname must be a single DNS label of letters, digits, and hyphens (at most 63 characters), got %q", hostname)
+		}
+		update["hostname"] = hostname
+	}
+	if domain, ok := args["domain"].(string); ok {
+		if domain != "" && !domainNamePattern.MatchString(domain) {
+			return nil, newToolError(ErrorValidation, "invalid domain %q", domain)
+		}
+		update["domain"] = domain
+	}
+	if list, ok := args["search_domains"].([]interface{}); ok {
+		domains := []string{}
+		for _, d := range list {
+			domain, _ := d.(string)
+			if !domainNamePattern.MatchString(domain) {
+				return nil, newToolError(ErrorValidation, "invalid search domain %q", d)
+			}
+			domains = append(domains, domain)
+		}
+		update["domains"] = domains
+	}
+	if list, ok := args["nameservers"].([]interface{}); ok {
+		if len(list) > maxNameservers {
+			return nil, newToolError(ErrorValidation, "at most %d nameservers can be set", maxNameservers)
+		}
+		for i := 1; i <= maxNameservers; i++ {
+			update[fmt.Sprintf("nameserver%d", i)] = ""
+		}
+		for i, ns := range list {
+			s, _ := ns.(string)
+			addr, err := netip.ParseAddr(strings.TrimSpace(s))
+			if err != nil {
+				return nil, newToolError(ErrorValidation, "nameserver %q is not an IP address", ns)
+			}
+			update[fmt.Sprintf("nameserver%d", i+1)] = addr.String()
+		}
+	}
+	if gw, ok, err := parseIPArg(args, "ipv4_gateway", false); err != nil {
+		return nil, err
+	} else if ok {
+		update["ipv4gateway"] = gw
+	}
+	if gw, ok, err := parseIPArg(args, "ipv6_gateway", true); err != nil {
+		return nil, err
+	} else if ok {
+		update["ipv6gateway"] = gw
+	}
+
+	if len(update) == 0 {
+		return nil, newToolError(ErrorValidation, "nothing to update: provide hostname, domain, search_domains, nameservers, ipv4_gateway, or ipv6_gateway")
+	}
+	return update, nil
+}
+
+// configChanges pairs each updated field's old and new value,
+// leaving out fields that would not change
+func configChanges(config, update map[string]interface{}) map[string]interface{} {
+	changes := map[string]interface{}{}
+	for field, value := range update {
+		old := config[field]
+		if fmt.Sprint(old) == fmt.Sprint(value) || old == nil && value == "" {
+			continue
+		}
+		changes[field] = map[string]interface{}{"old": old, "new": value}
+	}
+	return changes
+}
+
+// networkConfigWarnings flags changes that can cut off access to the NAS
+func networkConfigWarnings(changes map[string]interface{}, dhcp []string) []string {
+	warnings := []string{}
+	if _, ok := changes["ipv4gateway"]; ok {
+		warnings = append(warnings, "Changing the IPv4 default gateway can cut off clients (including this server) that reach the NAS from another network; have console or IPMI access ready")
+		if len(dhcp) > 0 {
+			warnings = append(warnings, fmt.Sprintf("Interfaces %s use DHCP; a configured gateway overrides the one DHCP supplies", strings.Join(dhcp, ", ")))
+		}
+	}
+	if _, ok := changes["ipv6gateway"]; ok {
+		warnings = append(warnings, "Changing the IPv6 default gateway can cut off clients that reach the NAS over IPv6 from another network")
+	}
+	for i := 1; i <= maxNameservers; i++ {
+		if _, ok := changes[fmt.Sprintf("nameserver%d", i)]; ok {
+			warnings = append(warnings, "Name resolution changes affect updates, the app catalog, cloud sync, email alerts, and directory services; make sure the new nameservers answer from the NAS")
+			break
+		}
+	}
+	if _, ok := changes["hostname"]; ok {
+		warnings = append(warnings, "Clients that connect by the old hostname (SMB, NFS mounts, bookmarks) must be updated; Active Directory members must be re-joined after a rename")
+	}
+	if _, ok := changes["domain"]; ok {
+		if _, renamed := changes["hostname"]; !renamed {
+			warnings = append(warnings, "Clients that connect by the fully qualified name must be updated")
+		}
+	}
+	return warnings
+}
+
+func handleUpdateNetworkConfig(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	update, err := networkConfigUpdate(args)
+	if err != nil {
+		return "", err
+	}
+	config, err := getNetworkConfig(client)
+	if err != nil {
+		return "", err
+	}
+	changes := configChanges(config, update)
+	if len(changes) == 0 {
+		response := simplifyNetworkConfig(config)
+		response["updated"] = false
+		response["message"] = "The network configuration already has these settings"
+		return marshalJSON(response)
+	}
+
+	result, err := client.Call("network.configuration.update", update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update network configuration: %w", err)
+	}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+	response := simplifyNetworkConfig(config)
+	response["updated"] = true
+	response["changes"] = changes
+	return marshalJSON(response)
+}
+
+func handleUpdateNetworkConfigWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateNetworkConfigDryRun{}, handleUpdateNetworkConfig)
+}
+
+type updateNetworkConfigDryRun struct{}
+
+func (u *updateNetworkConfigDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	update, err := networkConfigUpdate(args)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getNetworkConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	changes := configChanges(config, update)
+
+	actions := []PlannedAction{}
+	if len(changes) > 0 {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: "Update the global network configuration",
+			Operation:   "update",
+			Target:      "network.configuration",
+			Details:     changes,
+		})
+	}
+	return &DryRunResult{
+		Tool:           "update_network_config",
+		CurrentState:   simplifyNetworkConfig(config),
+		PlannedActions: actions,
+		Warnings:       networkConfigWarnings(changes, dhcpInterfaces(client)),
+	}, nil
+}
+
+// staticRouteArgs validates a static route's destination and gateway. When
+// partial, either may be omitted (for updates).
+func staticRouteArgs(args map[string]interface{}, partial bool) (map[string]interface{}, error) {
+	route := map[string]interface{}{}
+	var prefix netip.Prefix
+	if destination, ok := args["destination"].(string); ok || !partial {
+		p, err := netip.ParsePrefix(strings.TrimSpace(destination))
+		if err != nil {
+			return nil, newToolError(ErrorValidation, "destination must be a network in CIDR notation such as 10.20.0.0/16, got %q", destination)
+		}
+		if p.Masked() != p {
+			return nil, newToolError(ErrorValidation, "destination %s has host bits set; did you mean %s?", p, p.Masked())
+		}
+		prefix = p
+		route["destination"] = p.String()
+	}
+	if gateway, ok := args["gateway"].(string); ok || !partial {
+		addr, err := netip.ParseAddr(strings.TrimSpace(gateway))
+		if err != nil {
+			return nil, newToolError(ErrorValidation, "gateway must be an IP address, got %q", gateway)
+		}
+		if prefix.IsValid() && prefix.Addr().Is4() != addr.Is4() {
+			return nil, newToolError(ErrorValidation, "gateway %s and destination %s are different address families", addr, prefix)
+		}
+		route["gateway"] = addr.String()
+	}
+	if description, ok := args["description"].(string); ok {
+		route["description"] = description
+	}
+	return route, nil
+}
+
+// staticRouteWarnings flags routes that overlap existing ones or have a
+// gateway on no local subnet
+func staticRouteWarnings(client truenas.Caller, route map[string]interface{}, skipID int) []string {
+	warnings := []string{}
+	destination, _ := route["destination"].(string)
+	if prefix, err := netip.ParsePrefix(destination); err == nil {
+		if routes, err := queryStaticRoutes(client); err == nil {
+			for _, existing := range routes {
+				if id, _ := existing["id"].(float64); int(id) == skipID {
+					continue
+				}
+				other, err := netip.ParsePrefix(fmt.Sprint(existing["destination"]))
+				if err == nil && other.Overlaps(prefix) {
+					warnings = append(warnings, fmt.Sprintf("Overlaps static route %v (%s via %v); the more specific route wins", existing["id"], other, existing["gateway"]))
+				}
+			}
+		}
+	}
+	if gateway, _ := route["gateway"].(string); gateway != "" && !onLocalSubnet(client, gateway) {
+		warnings = append(warnings, fmt.Sprintf("Gateway %s is not on a subnet of any configured interface, so the route may not be usable", gateway))
+	}
+	return warnings
+}
+
+// onLocalSubnet reports whether an address is on a subnet of an interface.
+// It is also true when the interfaces cannot be read, to avoid false alarms.
+func onLocalSubnet(client truenas.Caller, address string) bool {
+	ip := net.ParseIP(address)
+	interfaces, err := inventoryQuery(client, "interface.query")
+	if err != nil || ip == nil {
+		return true
+	}
+	for _, iface := range interfaces {
+		state, _ := iface["state"].(map[string]interface{})
+		aliases, _ := state["aliases"].([]interface{})
+		if configured, ok := iface["aliases"].([]interface{}); ok {
+			aliases = append(aliases, configured...)
+		}
+		for _, a := range aliases {
+			alias, _ := a.(map[string]interface{})
+			addr, _ := alias["address"].(string)
+			netmask, _ := alias["netmask"].(float64)
+			if _, network, err := net.ParseCIDR(fmt.Sprintf("%s/%d", addr, int(netmask))); err == nil && network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func handleCreateStaticRoute(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	route, err := staticRouteArgs(args, false)
+	if err != nil {
+		return "", err
+	}
+	result, err := client.Call("staticroute.create", route)
+	if err != nil {
+		return "", fmt.Errorf("failed to create static route: %w", err)
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+	response := simplifyStaticRoute(created)
+	response["created"] = true
+	return marshalJSON(response)
+}
+
+func handleCreateStaticRouteWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createStaticRouteDryRun{}, handleCreateStaticRoute)
+}
+
+type createStaticRouteDryRun struct{}
+
+func (c *createStaticRouteDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	route, err := staticRouteArgs(args, false)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunResult{
+		Tool: "create_static_route",
+		PlannedActions: []PlannedAction{{
+			Step:        1,
+			Description: fmt.Sprintf("Route %s via %s", route["destination"], route["gateway"]),
+			Operation:   "create",
+			Target:      "staticroute",
+			Details:     route,
+		}},
+		Warnings: staticRouteWarnings(client, route, 0),
+	}, nil
+}
+
+// staticRouteID reads the id argument
+func staticRouteID(args map[string]interface{}) (int, error) {
+	id, ok := args["id"].(float64)
+	if !ok || id < 1 || id != float64(int(id)) {
+		return 0, newToolError(ErrorValidation, "id is required (see get_network_config)")
+	}
+	return int(id), nil
+}
+
+func handleUpdateStaticRoute(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	id, err := staticRouteID(args)
+	if err != nil {
+		return "", err
+	}
+	update, err := staticRouteArgs(args, true)
+	if err != nil {
+		return "", err
+	}
+	if len(update) == 0 {
+		return "", newToolError(ErrorValidation, "nothing to update: provide destination, gateway, or description")
+	}
+	if _, err := findStaticRoute(client, id); err != nil {
+		return "", err
+	}
+	result, err := client.Call("staticroute.update", id, update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update static route: %w", err)
+	}
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+	response := simplifyStaticRoute(updated)
+	response["updated"] = true
+	return marshalJSON(response)
+}
+
+func handleUpdateStaticRouteWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateStaticRouteDryRun{}, handleUpdateStaticRoute)
+}
+
+type updateStaticRouteDryRun struct{}
+
+func (u *updateStaticRouteDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	id, err := staticRouteID(args)
+	if err != nil {
+		return nil, err
+	}
+	update, err := staticRouteArgs(args, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(update) == 0 {
+		return nil, newToolError(ErrorValidation, "nothing to update: provide destination, gateway, or description")
+	}
+	route, err := findStaticRoute(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	merged := map[string]interface{}{}
+	for field, value := range route {
+		merged[field] = value
+	}
+	for field, value := range update {
+		if route[field] != value {
+			changes[field] = map[string]interface{}{"old": route[field], "new": value}
+		}
+		merged[field] = value
+	}
+	actions := []PlannedAction{}
+	if len(changes) > 0 {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: fmt.Sprintf("Update static route %d", id),
+			Operation:   "update",
+			Target:      fmt.Sprintf("staticroute:%d", id),
+			Details:     changes,
+		})
+	}
+	return &DryRunResult{
+		Tool:           "update_static_route",
+		CurrentState:   simplifyStaticRoute(route),
+		PlannedActions: actions,
+		Warnings:       staticRouteWarnings(client, merged, id),
+	}, nil
+}
+
+func handleDeleteStaticRoute(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	id, err := staticRouteID(args)
+	if err != nil {
+		return "", err
+	}
+	route, err := findStaticRoute(client, id)
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.Call("staticroute.delete", id); err != nil {
+		return "", fmt.Errorf("failed to delete static route: %w", err)
+	}
+	response := simplifyStaticRoute(route)
+	response["deleted"] = true
+	return marshalJSON(response)
+}
+
+func handleDeleteStaticRouteWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteStaticRouteDryRun{}, handleDeleteStaticRoute)
+}
+
+type deleteStaticRouteDryRun struct{}
+
+func (d *deleteStaticRouteDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	id, err := staticRouteID(args)
+	if err != nil {
+		return nil, err
+	}
+	route, err := findStaticRoute(client, id)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunResult{
+		Tool:         "delete_static_route",
+		CurrentState: simplifyStaticRoute(route),
+		PlannedActions: []PlannedAction{{
+			Step:        1,
+			Description: fmt.Sprintf("Delete the route to %v via %v", route["destination"], route["gateway"]),
+			Operation:   "delete",
+			Target:      fmt.Sprintf("staticroute:%d", id),
+		}},
+		Warnings: []string{fmt.Sprintf("Traffic to %v falls back to the default gateway once the route is removed", route["destination"])},
+	}, nil
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationNetworkConfig(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("network.configuration.config", map[string]interface{}{
+		"id": float64(1), "hostname": "truenas", "domain": "local", "domains": []interface{}{},
+		"nameserver1": "", "nameserver2": "", "nameserver3": "", "ipv4gateway": "", "ipv6gateway": "",
+		"state": map[string]interface{}{"nameserver1": "192.168.1.1", "ipv4gateway": "192.168.1.1"},
+	})
+	server.SetRecords("interface.query", []map[string]interface{}{{
+		"name": "enp1s0", "ipv4_dhcp": true,
+		"state": map[string]interface{}{"aliases": []interface{}{map[string]interface{}{"type": "INET", "address": "192.168.1.20", "netmask": float64(24)}}},
+	}})
+	server.SetRecords("staticroute.query", []map[string]interface{}{
+		{"id": float64(1), "destination": "10.20.0.0/16", "gateway": "192.168.1.254", "description": "lab"},
+	})
+	server.Handle("network.configuration.update", func(params []interface{}) (interface{}, error) {
+		return params[0], nil
+	})
+	server.SetResult("staticroute.delete", true)
+	server.Handle("staticroute.create", func(params []interface{}) (interface{}, error) {
+		route := params[0].(map[string]interface{})
+		route["id"] = float64(2)
+		return route, nil
+	})
+
+	result, err := registry.CallTool("get_network_config", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_network_config failed: %v", err)
+	}
+	config := decodeResult(t, result)
+	inEffect := config["in_effect"].(map[string]interface{})
+	if inEffect["ipv4_gateway"] != "192.168.1.1" || len(config["static_routes"].([]interface{})) != 1 || config["dhcp_interfaces"] == nil {
+		t.Errorf("get_network_config result:\n%s", result)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"hostname": "nas.example"},
+		{"nameservers": []interface{}{"1.1.1.1", "8.8.8.8", "9.9.9.9", "8.8.4.4"}},
+		{"ipv4_gateway": "fe80::1"},
+		{},
+	} {
+		if _, err := registry.CallTool("update_network_config", args); ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("update_network_config(%v) error = %v, want VALIDATION", args, err)
+		}
+	}
+
+	args := map[string]interface{}{"hostname": "nas", "nameservers": []interface{}{"1.1.1.1"}, "ipv4_gateway": "192.168.1.1", "dry_run": true}
+	result, err = registry.CallTool("update_network_config", args)
+	if err != nil {
+		t.Fatalf("update_network_config dry run failed: %v", err)
+	}
+	details := decodeResult(t, result)["planned_actions"].([]interface{})[0].(map[string]interface{})["details"].(map[string]interface{})
+	if details["hostname"].(map[string]interface{})["old"] != "truenas" || details["nameserver1"].(map[string]interface{})["new"] != "1.1.1.1" {
+		t.Errorf("dry run diff = %v", details)
+	}
+	if _, unchanged := details["nameserver2"]; unchanged || !strings.Contains(result, "DHCP") {
+		t.Errorf("dry run should leave out unchanged fields and warn about DHCP:\n%s", result)
+	}
+	if calls := server.Calls("network.configuration.update"); len(calls) != 0 {
+		t.Fatalf("dry run called network.configuration.update: %v", calls)
+	}
+	delete(args, "dry_run")
+	if _, err := registry.CallTool("update_network_config", args); err != nil {
+		t.Fatalf("update_network_config failed: %v", err)
+	}
+	if update := server.Calls("network.configuration.update")[0].Params[0].(map[string]interface{}); update["ipv4gateway"] != "192.168.1.1" || update["nameserver2"] != "" {
+		t.Errorf("network.configuration.update payload = %v", update)
+	}
+
+	if _, err := registry.CallTool("create_static_route", map[string]interface{}{"destination": "10.30.0.1/16", "gateway": "192.168.1.254"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("host bits in destination error = %v, want VALIDATION", err)
+	}
+	result, err = registry.CallTool("create_static_route", map[string]interface{}{"destination": "10.20.5.0/24", "gateway": "172.16.0.1", "dry_run": true})
+	if err != nil {
+		t.Fatalf("create_static_route dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Overlaps static route 1") || !strings.Contains(result, "not on a subnet") {
+		t.Errorf("dry run should warn about the overlap and the off-subnet gateway:\n%s", result)
+	}
+	if _, err := registry.CallTool("create_static_route", map[string]interface{}{"destination": "10.30.0.0/16", "gateway": "192.168.1.254"}); err != nil {
+		t.Fatalf("create_static_route failed: %v", err)
+	}
+
+	if _, err := registry.CallTool("delete_static_route", map[string]interface{}{"id": float64(9)}); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown route error = %v, want NOT_FOUND", err)
+	}
+	if _, err := registry.CallTool("delete_static_route", map[string]interface{}{"id": float64(1)}); err != nil {
+		t.Fatalf("delete_static_route failed: %v", err)
+	}
+	if calls := server.Calls("staticroute.delete"); len(calls) != 1 || calls[0].Params[0] != float64(1) {
+		t.Errorf("staticroute.delete calls = %v", calls)
+	}
+}
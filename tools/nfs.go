@@ -11,7 +11,7 @@ import (
 )
 
 // handleCreateNFSShare creates a new NFS share
-func handleCreateNFSShare(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleCreateNFSShare(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Extract required parameter
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
@@ -90,6 +90,9 @@ func handleCreateNFSShare(client *truenas.Client, args map[string]interface{}) (
 		payload["security"] = security
 	}
 
+	// Ownership and ACL to apply after creation
+	perms := parseSharePermissions(args)
+
 	// Check if this is a dry run
 	if dryRun, ok := args["dry_run"].(bool); ok && dryRun {
 		// Return preview of what would be created
@@ -122,6 +125,10 @@ func handleCreateNFSShare(client *truenas.Client, args map[string]interface{}) (
 			warnings = append(warnings, dirWarnings...)
 		}
 
+		if perms != nil {
+			preview["permissions"] = perms.describe(path)
+		}
+
 		if len(warnings) > 0 {
 			preview["security_warnings"] = warnings
 		}
@@ -162,7 +169,19 @@ func handleCreateNFSShare(client *truenas.Client, args map[string]interface{}) (
 
 	// Add mount instructions
 	response["mount_example"] = fmt.Sprintf("mount -t nfs truenas:%s /mnt/point", path)
-	response["note"] = "NFS share is now accessible. Ensure NFS service is running and firewall allows NFS traffic."
+	response["note"] = "NFS share is now accessible. Run verify_nfs_export to confirm the NFS service is running and get client mount commands."
+	if service, err := getService(client, "nfs"); err == nil && service["state"] != "RUNNING" {
+		response["service_warning"] = fmt.Sprintf("The NFS service is %v, so clients cannot mount the share yet. Start it with start_service (service=nfs) and keep it running after reboots with set_service_autostart.", service["state"])
+	}
+
+	// The share exists at this point, so a permission failure is reported rather than returned
+	if perms != nil {
+		if applied, err := applySharePermissions(client, path, perms); err != nil {
+			response["permissions_error"] = err.Error()
+		} else {
+			response["permissions"] = applied
+		}
+	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
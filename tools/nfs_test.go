@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -236,3 +237,72 @@ func TestValidateNFSHost(t *testing.T) {
 		})
 	}
 }
+
+func TestNFSMountCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocols []string
+		linux     string
+		macos     string
+	}{
+		{
+			name:      "both versions",
+			protocols: []string{"NFSV3", "NFSV4"},
+			linux:     "sudo mount -t nfs -o vers=4 nas.lan:/mnt/tank/data /mnt/data",
+			macos:     "sudo mount -t nfs -o resvport,vers=3 nas.lan:/mnt/tank/data ~/data",
+		},
+		{
+			name:      "v3 only",
+			protocols: []string{"NFSV3"},
+			linux:     "sudo mount -t nfs -o vers=3 nas.lan:/mnt/tank/data /mnt/data",
+			macos:     "sudo mount -t nfs -o resvport,vers=3 nas.lan:/mnt/tank/data ~/data",
+		},
+		{
+			name:      "v4 only",
+			protocols: []string{"NFSV4"},
+			linux:     "sudo mount -t nfs -o vers=4 nas.lan:/mnt/tank/data /mnt/data",
+			macos:     "sudo mount -t nfs -o resvport,vers=4 nas.lan:/mnt/tank/data ~/data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := nfsMountCommands("nas.lan", "/mnt/tank/data", tt.protocols)
+			if linux := commands["linux"].([]string); linux[1] != tt.linux {
+				t.Errorf("linux mount = %q, want %q", linux[1], tt.linux)
+			}
+			if macos := commands["macos"].([]string); macos[1] != tt.macos {
+				t.Errorf("macos mount = %q, want %q", macos[1], tt.macos)
+			}
+		})
+	}
+}
+
+func TestIntegrationCreateNFSShareUnknownACLPreset(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("sharing.nfs.create", map[string]interface{}{
+		"id": float64(4), "path": "/mnt/tank/exports", "enabled": true,
+	})
+	server.SetResult("filesystem.acltemplate.by_path", []map[string]interface{}{
+		{"name": "POSIX_OPEN", "acltype": "POSIX1E", "acl": []interface{}{}},
+	})
+
+	result, err := registry.CallTool("create_nfs_share", map[string]interface{}{
+		"path":       "/mnt/tank/exports",
+		"acl_preset": "NFS4_HOME",
+	})
+	if err != nil {
+		t.Fatalf("create_nfs_share failed: %v", err)
+	}
+
+	response := decodeResult(t, result)
+	if response["success"] != true {
+		t.Errorf("share creation should still succeed: %v", response)
+	}
+	if msg, _ := response["permissions_error"].(string); !strings.Contains(msg, "POSIX_OPEN") {
+		t.Errorf("permissions_error should list available presets, got %q", msg)
+	}
+	if n := len(server.Calls("filesystem.setacl")); n != 0 {
+		t.Errorf("filesystem.setacl called %d times, want 0", n)
+	}
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// NFS export verification handler
+
+// handleVerifyNFSExport confirms an NFS share is being exported and returns
+// ready-to-paste client mount commands
+func handleVerifyNFSExport(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	var filters []interface{}
+	if id, ok := args["id"].(float64); ok {
+		filters = []interface{}{[]interface{}{"id", "=", int(id)}}
+	} else if sharePath, ok := args["path"].(string); ok && sharePath != "" {
+		filters = []interface{}{[]interface{}{"path", "=", sharePath}}
+	} else {
+		return "", fmt.Errorf("path or id is required")
+	}
+
+	result, err := client.Call("sharing.nfs.query", filters)
+	if err != nil {
+		return "", fmt.Errorf("failed to query NFS shares: %w", err)
+	}
+
+	var shares []map[string]interface{}
+	if err := json.Unmarshal(result, &shares); err != nil {
+		return "", fmt.Errorf("failed to parse NFS shares: %w", err)
+	}
+	if len(shares) == 0 {
+		return "", fmt.Errorf("no NFS share found matching %v", filters[0].([]interface{})[2])
+	}
+	share := shares[0]
+	sharePath, _ := share["path"].(string)
+
+	checks := []map[string]interface{}{}
+	ready := true
+	check := func(name string, passed bool, detail string) {
+		status := "PASS"
+		if !passed {
+			status = "FAIL"
+			ready = false
+		}
+		checks = append(checks, map[string]interface{}{
+			"check":  name,
+			"status": status,
+			"detail": detail,
+		})
+	}
+
+	// Export state: the middleware only exports enabled shares on unlocked datasets
+	enabled, _ := share["enabled"].(bool)
+	if enabled {
+		check("share_enabled", true, "Share is enabled")
+	} else {
+		check("share_enabled", false, "Share is disabled; enable it to export the path")
+	}
+	if locked, _ := share["locked"].(bool); locked {
+		check("dataset_unlocked", false, "Dataset is locked (encrypted); unlock it to export the path")
+	} else {
+		check("dataset_unlocked", true, "Dataset is unlocked")
+	}
+
+	// NFS service state
+	serviceResult, err := client.Call("service.query", []interface{}{
+		[]interface{}{"service", "=", "nfs"},
+	})
+	if err != nil {
+		check("service_running", false, fmt.Sprintf("Could not query NFS service: %v", err))
+	} else {
+		var services []map[string]interface{}
+		if err := json.Unmarshal(serviceResult, &services); err != nil || len(services) == 0 {
+			check("service_running", false, "NFS service not found")
+		} else {
+			state, _ := services[0]["state"].(string)
+			if state == "RUNNING" {
+				check("service_running", true, "NFS service is running")
+			} else {
+				check("service_running", false, fmt.Sprintf("NFS service state is %s; start it with start_service (service=nfs)", state))
+			}
+			if enable, _ := services[0]["enable"].(bool); !enable {
+				checks = append(checks, map[string]interface{}{
+					"check":  "service_autostart",
+					"status": "WARN",
+					"detail": "NFS service is not set to start on boot; enable it with set_service_autostart",
+				})
+			}
+		}
+	}
+
+	// Protocol versions and bind addresses shape the mount commands
+	protocols := []string{"NFSV3", "NFSV4"}
+	host := ""
+	if configResult, err := client.Call("nfs.config"); err == nil {
+		var config map[string]interface{}
+		if err := json.Unmarshal(configResult, &config); err == nil {
+			if list, ok := config["protocols"].([]interface{}); ok && len(list) > 0 {
+				protocols = protocols[:0]
+				for _, p := range list {
+					if s, ok := p.(string); ok {
+						protocols = append(protocols, s)
+					}
+				}
+			}
+			if bindIPs, ok := config["bindip"].([]interface{}); ok && len(bindIPs) > 0 {
+				host, _ = bindIPs[0].(string)
+			}
+		}
+	}
+	if host == "" {
+		host = nfsServerHostname(client)
+	}
+
+	response := map[string]interface{}{
+		"share_id":       share["id"],
+		"path":           sharePath,
+		"ready":          ready,
+		"checks":         checks,
+		"protocols":      protocols,
+		"mount_commands": nfsMountCommands(host, sharePath, protocols),
+	}
+
+	// Client restrictions decide who can actually mount
+	restrictions := []string{}
+	for _, key := range []string{"networks", "hosts"} {
+		if list, ok := share[key].([]interface{}); ok {
+			for _, entry := range list {
+				if s, ok := entry.(string); ok {
+					restrictions = append(restrictions, s)
+				}
+			}
+		}
+	}
+	if len(restrictions) > 0 {
+		response["allowed_clients"] = restrictions
+		response["note"] = "Only the listed networks/hosts can mount this export"
+	}
+	if ro, ok := share["ro"].(bool); ok {
+		response["read_only"] = ro
+	}
+
+	return marshalJSON(response)
+}
+
+// nfsServerHostname returns the server's network hostname for mount commands,
+// falling back to "truenas" if it cannot be determined
+func nfsServerHostname(client truenas.Caller) string {
+	result, err := client.Call("network.configuration.config")
+	if err != nil {
+		return "truenas"
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return "truenas"
+	}
+
+	hostname, _ := config["hostname"].(string)
+	if hostname == "" {
+		return "truenas"
+	}
+	if domain, _ := config["domain"].(string); domain != "" && domain != "local" {
+		return hostname + "." + domain
+	}
+	return hostname
+}
+
+// nfsMountCommands builds Linux and macOS mount commands for an export,
+// preferring NFSv4 on Linux and NFSv3 on macOS when both are enabled
+func nfsMountCommands(host, sharePath string, protocols []string) map[string]interface{} {
+	hasV3, hasV4 := false, false
+	for _, p := range protocols {
+		switch strings.ToUpper(p) {
+		case "NFSV3":
+			hasV3 = true
+		case "NFSV4":
+			hasV4 = true
+		}
+	}
+
+	linuxVers, macVers := "4", "3"
+	if !hasV4 {
+		linuxVers = "3"
+	}
+	if !hasV3 {
+		macVers = "4"
+	}
+
+	name := path.Base(sharePath)
+	source := fmt.Sprintf("%s:%s", host, sharePath)
+
+	return map[string]interface{}{
+		"linux": []string{
+			fmt.Sprintf("sudo mkdir -p /mnt/%s", name),
+			fmt.Sprintf("sudo mount -t nfs -o vers=%s %s /mnt/%s", linuxVers, source, name),
+		},
+		"linux_fstab": fmt.Sprintf("%s /mnt/%s nfs vers=%s,_netdev 0 0", source, name, linuxVers),
+		"macos": []string{
+			fmt.Sprintf("mkdir -p ~/%s", name),
+			fmt.Sprintf("sudo mount -t nfs -o resvport,vers=%s %s ~/%s", macVers, source, name),
+		},
+	}
+}
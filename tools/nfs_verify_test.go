@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationVerifyNFSExport(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{
+		{"id": float64(1), "path": "/mnt/tank/exports", "enabled": true, "locked": false, "networks": []interface{}{"10.0.0.0/24"}},
+	})
+	server.SetRecords("service.query", []map[string]interface{}{
+		{"service": "nfs", "state": "STOPPED", "enable": false},
+	})
+	server.SetResult("nfs.config", map[string]interface{}{"protocols": []interface{}{"NFSV4"}, "bindip": []interface{}{}})
+	server.SetResult("network.configuration.config", map[string]interface{}{"hostname": "nas", "domain": "example.com"})
+
+	result, err := registry.CallTool("verify_nfs_export", map[string]interface{}{"path": "/mnt/tank/exports"})
+	if err != nil {
+		t.Fatalf("verify_nfs_export failed: %v", err)
+	}
+
+	response := decodeResult(t, result)
+	if response["ready"] != false {
+		t.Errorf("ready = %v, want false with the NFS service stopped", response["ready"])
+	}
+	if !strings.Contains(result, "sudo mount -t nfs -o vers=4 nas.example.com:/mnt/tank/exports /mnt/exports") {
+		t.Errorf("missing Linux mount command:\n%s", result)
+	}
+	if !strings.Contains(result, "10.0.0.0/24") {
+		t.Errorf("missing allowed clients:\n%s", result)
+	}
+
+	if _, err := registry.CallTool("verify_nfs_export", map[string]interface{}{"path": "/mnt/tank/missing"}); err == nil {
+		t.Error("expected error for unknown export path")
+	}
+}
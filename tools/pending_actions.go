@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Pending reboot, service restart, and pool upgrade detection
+
+// handleGetPendingActions reports follow-up actions the system is waiting on:
+// a reboot, service restarts or starts, and pool feature flag upgrades
+func handleGetPendingActions(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	notes := []string{}
+	recommendations := []string{}
+
+	// Reboot: the middleware records why a reboot is needed (e.g. an applied update)
+	rebootRequired := false
+	rebootReasons := []string{}
+	if result, err := client.Call("system.reboot.info"); err == nil {
+		var info map[string]interface{}
+		if err := json.Unmarshal(result, &info); err == nil {
+			if reasons, ok := info["reboot_required_reasons"].([]interface{}); ok {
+				for _, raw := range reasons {
+					reason, _ := raw.(map[string]interface{})
+					if text, ok := reason["reason"].(string); ok && text != "" {
+						rebootReasons = append(rebootReasons, text)
+					} else if code, ok := reason["code"].(string); ok {
+						rebootReasons = append(rebootReasons, code)
+					}
+				}
+			}
+			rebootRequired = len(rebootReasons) > 0
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("reboot status unavailable: %v", err))
+	}
+	if rebootRequired {
+		recommendations = append(recommendations, "Schedule a reboot with system_reboot to finish applying pending changes")
+	}
+
+	// Services: restart requests surface as alerts; enabled services that are
+	// stopped need a start
+	services := []map[string]interface{}{}
+	if result, err := client.Call("alert.list"); err == nil {
+		var alerts []map[string]interface{}
+		if err := json.Unmarshal(result, &alerts); err == nil {
+			for _, alert := range alerts {
+				if dismissed, _ := alert["dismissed"].(bool); dismissed {
+					continue
+				}
+				klass, _ := alert["klass"].(string)
+				text, _ := alert["formatted"].(string)
+				if !strings.Contains(strings.ToLower(klass), "restart") && !strings.Contains(strings.ToLower(text), "restart") {
+					continue
+				}
+				services = append(services, map[string]interface{}{
+					"source": "alert",
+					"alert":  klass,
+					"action": "restart",
+					"reason": text,
+				})
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("alerts unavailable: %v", err))
+	}
+
+	if result, err := client.Call("service.query"); err == nil {
+		var list []map[string]interface{}
+		if err := json.Unmarshal(result, &list); err == nil {
+			for _, svc := range list {
+				enable, _ := svc["enable"].(bool)
+				state, _ := svc["state"].(string)
+				if enable && state != "RUNNING" {
+					services = append(services, map[string]interface{}{
+						"source":  "service",
+						"service": svc["service"],
+						"action":  "start",
+						"reason":  fmt.Sprintf("Set to start on boot but currently %s", state),
+					})
+				}
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("services unavailable: %v", err))
+	}
+	if len(services) > 0 {
+		recommendations = append(recommendations, "Restart or start the listed services so configuration changes take effect")
+	}
+
+	// Pool feature flags: pools created on older releases need an upgrade to use new features
+	poolUpgrades := []map[string]interface{}{}
+	if statuses, err := queryPoolUpgradeStatus(client, ""); err == nil {
+		for _, status := range statuses {
+			if status.Err != nil {
+				notes = append(notes, fmt.Sprintf("upgrade status unavailable for pool %s: %v", status.Name, status.Err))
+				continue
+			}
+			if !status.Upgraded {
+				poolUpgrades = append(poolUpgrades, map[string]interface{}{
+					"pool":   status.Name,
+					"reason": "New ZFS feature flags are available",
+				})
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("pools unavailable: %v", err))
+	}
+	sort.Slice(poolUpgrades, func(i, j int) bool {
+		return poolUpgrades[i]["pool"].(string) < poolUpgrades[j]["pool"].(string)
+	})
+	if len(poolUpgrades) > 0 {
+		recommendations = append(recommendations, "Upgrade pool feature flags with upgrade_pool only once you will not roll back. "+poolUpgradeWarning)
+	}
+
+	response := map[string]interface{}{
+		"reboot_required": rebootRequired,
+		"reboot_reasons":  rebootReasons,
+		"services":        services,
+		"pool_upgrades":   poolUpgrades,
+		"actions_pending": rebootRequired || len(services) > 0 || len(poolUpgrades) > 0,
+		"recommendations": recommendations,
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+
+	return marshalJSON(response)
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"testing"
+)
+
+func TestIntegrationGetPendingActions(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.reboot.info", map[string]interface{}{
+		"boot_id": "abc",
+		"reboot_required_reasons": []interface{}{
+			map[string]interface{}{"code": "UPDATE", "reason": "System update was applied"},
+		},
+	})
+	server.SetResult("alert.list", []interface{}{
+		map[string]interface{}{"klass": "SMBServiceRestartRequired", "formatted": "Restart SMB to apply settings", "dismissed": false},
+		map[string]interface{}{"klass": "ZpoolCapacityWarning", "formatted": "Pool tank is 85% full", "dismissed": false},
+	})
+	server.SetRecords("service.query", []map[string]interface{}{
+		{"service": "nfs", "enable": true, "state": "STOPPED"},
+		{"service": "ssh", "enable": false, "state": "STOPPED"},
+		{"service": "cifs", "enable": true, "state": "RUNNING"},
+	})
+	server.SetRecords("pool.query", []map[string]interface{}{
+		{"id": float64(1), "name": "tank"},
+		{"id": float64(2), "name": "backup"},
+	})
+	server.Handle("pool.is_upgraded", func(params []interface{}) (interface{}, error) {
+		return params[0] == float64(1), nil
+	})
+
+	result, err := registry.CallTool("get_pending_actions", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_pending_actions failed: %v", err)
+	}
+	response := decodeResult(t, result)
+
+	if response["reboot_required"] != true {
+		t.Errorf("reboot_required = %v, want true", response["reboot_required"])
+	}
+	services, _ := response["services"].([]interface{})
+	if len(services) != 2 {
+		t.Fatalf("services = %v, want restart alert and stopped nfs", response["services"])
+	}
+	upgrades, _ := response["pool_upgrades"].([]interface{})
+	if len(upgrades) != 1 || upgrades[0].(map[string]interface{})["pool"] != "backup" {
+		t.Errorf("pool_upgrades = %v, want backup", response["pool_upgrades"])
+	}
+	if _, ok := response["collection_notes"]; ok {
+		t.Errorf("unexpected collection_notes: %v", response["collection_notes"])
+	}
+}
@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Server-side execution of multi-step tool plans
+
+const (
+	// maxPlanSteps bounds a plan's length
+	maxPlanSteps = 50
+
+	// planTaskTTL is how long a plan's task and results are kept
+	planTaskTTL = time.Hour
+)
+
+// Step failure policies
+const (
+	planOnFailureAbort    = "abort"
+	planOnFailureContinue = "continue"
+)
+
+// planStep is one tool invocation of a plan
+type planStep struct {
+	Tool      string
+	Arguments map[string]interface{}
+	OnFailure string
+}
+
+// parsePlanSteps validates the steps argument against the registered tools
+func (r *Registry) parsePlanSteps(args map[string]interface{}) ([]planStep, error) {
+	raw, ok := args["steps"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("steps is required and must be a non-empty array")
+	}
+	if len(raw) > maxPlanSteps {
+		return nil, fmt.Errorf("plan has %d steps; at most %d are allowed", len(raw), maxPlanSteps)
+	}
+
+	steps := make([]planStep, 0, len(raw))
+	for i, item := range raw {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("step %d must be an object", i+1)
+		}
+
+		step := planStep{OnFailure: planOnFailureAbort, Arguments: map[string]interface{}{}}
+		step.Tool, _ = entry["tool"].(string)
+		if step.Tool == "" {
+			return nil, fmt.Errorf("step %d: tool is required", i+1)
+		}
+		if step.Tool == "execute_plan" {
+			return nil, fmt.Errorf("step %d: plans cannot be nested", i+1)
+		}
+		if reason, disabled := r.disabledTools[step.Tool]; disabled {
+			return nil, newToolError(ErrorPermissionDenied, "step %d: %s is disabled: %s", i+1, step.Tool, reason)
+		}
+		if _, exists := r.tools[step.Tool]; !exists {
+			return nil, fmt.Errorf("step %d: unknown tool: %s", i+1, step.Tool)
+		}
+
+		if arguments, ok := entry["arguments"]; ok && arguments != nil {
+			argMap, ok := arguments.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("step %d: arguments must be an object", i+1)
+			}
+			step.Arguments = argMap
+		}
+
+		if onFailure, ok := entry["on_failure"].(string); ok && onFailure != "" {
+			if onFailure != planOnFailureAbort && onFailure != planOnFailureContinue {
+				return nil, fmt.Errorf("step %d: on_failure must be abort or continue, got: %s", i+1, onFailure)
+			}
+			step.OnFailure = onFailure
+		}
+
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// runPlanStep calls one step's tool and returns its result entry. Steps run
+// through callTool, so locking, timeouts, and redaction apply; ctx cancels
+// the step's middleware calls.
+func (r *Registry) runPlanStep(ctx context.Context, index int, step planStep, correlationID string, dryRun bool) (map[string]interface{}, bool) {
+	arguments := step.Arguments
+	if dryRun {
+		arguments = make(map[string]interface{}, len(step.Arguments)+1)
+		for k, v := range step.Arguments {
+			arguments[k] = v
+		}
+		arguments["dry_run"] = true
+	}
+
+	entry := map[string]interface{}{
+		"step":           index + 1,
+		"tool":           step.Tool,
+		"correlation_id": correlationID,
+	}
+
+	started := time.Now()
+	output, err := r.callTool(ctx, "", correlationID, step.Tool, arguments)
+	entry["duration_ms"] = time.Since(started).Milliseconds()
+	if err != nil {
+		entry["status"] = "failed"
+		entry["error"] = ClassifyError(err)
+		return entry, false
+	}
+
+	entry["status"] = "succeeded"
+	var decoded interface{}
+	if json.Unmarshal([]byte(output), &decoded) == nil {
+		entry["result"] = decoded
+	} else {
+		entry["result"] = output
+	}
+	return entry, true
+}
+
+// planSummary counts step outcomes
+func planSummary(results []map[string]interface{}) map[string]int {
+	summary := map[string]int{"succeeded": 0, "failed": 0, "skipped": 0}
+	for _, result := range results {
+		status, _ := result["status"].(string)
+		summary[status]++
+	}
+	return summary
+}
+
+// copyPlanResults snapshots results so a task never shares a slice that is still
+// being appended to
+func copyPlanResults(results []map[string]interface{}) []map[string]interface{} {
+	return append([]map[string]interface{}(nil), results...)
+}
+
+func (r *Registry) handleExecutePlan(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	steps, err := r.parsePlanSteps(args)
+	if err != nil {
+		return "", err
+	}
+	dryRun, _ := args["dry_run"].(bool)
+	parentID := client.CorrelationID()
+	if parentID == "" {
+		parentID = NewCorrelationID()
+	}
+	stepID := func(i int) string { return fmt.Sprintf("%s.%d", parentID, i+1) }
+
+	// Dry runs preview every step in place; tools without dry_run support are
+	// read-only and run as-is
+	if dryRun {
+		results := make([]map[string]interface{}, 0, len(steps))
+		for i, step := range steps {
+			tool := r.tools[step.Tool]
+			props, _ := tool.Definition.InputSchema["properties"].(map[string]interface{})
+			_, supportsDryRun := props["dry_run"]
+			result, _ := r.runPlanStep(client.Context(), i, step, stepID(i), supportsDryRun)
+			result["preview"] = supportsDryRun
+			results = append(results, result)
+		}
+		return marshalJSON(map[string]interface{}{
+			"dry_run": true,
+			"steps":   results,
+			"summary": planSummary(results),
+			"note":    "Each write step was previewed with dry_run=true. Previews are independent: later steps do not see changes earlier steps would make.",
+		})
+	}
+
+	if r.taskManager == nil {
+		return "", fmt.Errorf("task tracking is not available")
+	}
+	task, err := r.taskManager.CreateLocalTask("execute_plan", args, planTaskTTL, parentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	go r.runPlan(task.TaskID, steps, stepID)
+
+	tools := make([]string, len(steps))
+	for i, step := range steps {
+		tools[i] = step.Tool
+	}
+	return marshalJSON(map[string]interface{}{
+		"task_id":     task.TaskID,
+		"task_status": task.Status,
+		"steps":       tools,
+		"message":     fmt.Sprintf("Executing %d steps. Use tasks_get with task_id to follow progress and read each step's result.", len(steps)),
+	})
+}
+
+// runPlan executes a plan's steps in order, recording progress on its task
+func (r *Registry) runPlan(taskID string, steps []planStep, stepID func(int) string) {
+	results := make([]map[string]interface{}, 0, len(steps))
+	aborted := false
+
+	for i, step := range steps {
+		if aborted || !r.taskManager.IsActive(taskID) {
+			results = append(results, map[string]interface{}{
+				"step":   i + 1,
+				"tool":   step.Tool,
+				"status": "skipped",
+			})
+			continue
+		}
+
+		r.taskManager.UpdateTask(taskID, tasks.TaskStatusWorking,
+			fmt.Sprintf("Step %d of %d: %s", i+1, len(steps), step.Tool),
+			map[string]interface{}{"steps": copyPlanResults(results)})
+
+		// The plan outlives the call that started it; tasks_cancel stops it
+		result, ok := r.runPlanStep(context.Background(), i, step, stepID(i), false)
+		if !ok {
+			result["on_failure"] = step.OnFailure
+			aborted = step.OnFailure == planOnFailureAbort
+		}
+		results = append(results, result)
+	}
+
+	summary := planSummary(results)
+	status := tasks.TaskStatusCompleted
+	message := fmt.Sprintf("All %d steps succeeded", len(steps))
+	switch {
+	case aborted:
+		status = tasks.TaskStatusFailed
+		message = fmt.Sprintf("Plan aborted: %d succeeded, %d failed, %d skipped", summary["succeeded"], summary["failed"], summary["skipped"])
+	case summary["failed"] > 0 || summary["skipped"] > 0:
+		message = fmt.Sprintf("Plan finished: %d succeeded, %d failed, %d skipped", summary["succeeded"], summary["failed"], summary["skipped"])
+	}
+
+	if err := r.taskManager.UpdateTask(taskID, status, message, map[string]interface{}{
+		"steps":   results,
+		"summary": summary,
+	}); err != nil {
+		slog.Error("Failed to record plan results", "task_id", taskID, "error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+)
+
+func TestIntegrationExecutePlan(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.SetResult("pool.is_upgraded", false)
+	server.SetResult("pool.upgrade", true)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas"})
+
+	steps := []interface{}{
+		map[string]interface{}{"tool": "system_info"},
+		map[string]interface{}{"tool": "upgrade_pool", "arguments": map[string]interface{}{"pool": "missing"}, "on_failure": "continue"},
+		map[string]interface{}{"tool": "upgrade_pool", "arguments": map[string]interface{}{"pool": "tank"}},
+		map[string]interface{}{"tool": "upgrade_pool", "arguments": map[string]interface{}{"pool": "missing"}},
+		map[string]interface{}{"tool": "system_info"},
+	}
+
+	// Dry run previews the write steps without calling pool.upgrade
+	result, err := registry.CallTool("execute_plan", map[string]interface{}{"steps": steps, "dry_run": true})
+	if err != nil {
+		t.Fatalf("execute_plan dry run failed: %v", err)
+	}
+	preview := decodeResult(t, result)
+	if summary := preview["summary"].(map[string]interface{}); summary["succeeded"] != float64(3) || summary["failed"] != float64(2) {
+		t.Errorf("dry run summary = %v, want 3 succeeded and 2 failed", summary)
+	}
+	if calls := server.Calls("pool.upgrade"); len(calls) != 0 {
+		t.Fatalf("dry run called pool.upgrade %d times", len(calls))
+	}
+
+	result, err = registry.CallTool("execute_plan", map[string]interface{}{"steps": steps})
+	if err != nil {
+		t.Fatalf("execute_plan failed: %v", err)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+
+	var task map[string]interface{}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := registry.CallTool("tasks_get", map[string]interface{}{"task_id": taskID})
+		if err != nil {
+			t.Fatalf("tasks_get failed: %v", err)
+		}
+		task = decodeResult(t, result)
+		if task["status"] != string(tasks.TaskStatusWorking) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("plan did not finish:\n%s", result)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if task["status"] != string(tasks.TaskStatusFailed) {
+		t.Errorf("task status = %v, want failed after the aborting step", task["status"])
+	}
+	planResult, _ := task["result"].(map[string]interface{})
+	stepResults, _ := planResult["steps"].([]interface{})
+	want := []string{"succeeded", "failed", "succeeded", "failed", "skipped"}
+	if len(stepResults) != len(want) {
+		t.Fatalf("step results = %v, want %d steps", stepResults, len(want))
+	}
+	for i, status := range want {
+		if got := stepResults[i].(map[string]interface{})["status"]; got != status {
+			t.Errorf("step %d status = %v, want %s", i+1, got, status)
+		}
+	}
+	if calls := server.Calls("pool.upgrade"); len(calls) != 1 {
+		t.Errorf("pool.upgrade called %d times, want 1", len(calls))
+	}
+
+	if _, err := registry.CallTool("execute_plan", map[string]interface{}{
+		"steps": []interface{}{map[string]interface{}{"tool": "no_such_tool"}},
+	}); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("plan with unknown tool error = %v, want unknown tool", err)
+	}
+}
@@ -0,0 +1,953 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Pool topology and vdev management
+
+// poolTopologyRoles are the topology groups in display order. Spares are
+// "spare" in pool.query but "spares" in pool.update.
+var poolTopologyRoles = []string{"data", "special", "dedup", "log", "cache", "spare"}
+
+// vdevMinDisks is the fewest disks each vdev type can be created with
+var vdevMinDisks = map[string]int{"STRIPE": 1, "MIRROR": 2, "RAIDZ1": 3, "RAIDZ2": 4, "RAIDZ3": 5}
+
+// vdevNode is a vdev or disk in a pool's topology with its place in the tree
+type vdevNode struct {
+	vdev   map[string]interface{}
+	parent map[string]interface{} // Nil for top-level vdevs
+	top    map[string]interface{} // Top-level vdev it belongs to
+	role   string
+}
+
+func (n vdevNode) guid() string {
+	return vdevGUID(n.vdev)
+}
+
+func (n vdevNode) status() string {
+	status, _ := n.vdev["status"].(string)
+	return status
+}
+
+func (n vdevNode) parentKind() string {
+	kind, _ := n.parent["type"].(string)
+	return kind
+}
+
+// vdevGUID returns a vdev's GUID as a string; it is a number too large for
+// float64 on older versions
+func vdevGUID(vdev map[string]interface{}) string {
+	switch guid := vdev["guid"].(type) {
+	case string:
+		return guid
+	case float64:
+		return strconv.FormatFloat(guid, 'f', 0, 64)
+	}
+	return ""
+}
+
+// vdevDiskName returns the disk a leaf vdev is on, or its device name
+func vdevDiskName(vdev map[string]interface{}) string {
+	if disk, ok := vdev["disk"].(string); ok && disk != "" {
+		return disk
+	}
+	name, _ := vdev["name"].(string)
+	return name
+}
+
+func vdevChildren(vdev map[string]interface{}) []map[string]interface{} {
+	raw, _ := vdev["children"].([]interface{})
+	children := make([]map[string]interface{}, 0, len(raw))
+	for _, c := range raw {
+		if child, ok := c.(map[string]interface{}); ok {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// vdevErrors sums the read, write, and checksum errors of a vdev
+func vdevErrors(vdev map[string]interface{}) map[string]interface{} {
+	counts := map[string]interface{}{}
+	stats, _ := vdev["stats"].(map[string]interface{})
+	for _, key := range []string{"read_errors", "write_errors", "checksum_errors"} {
+		n, _ := stats[key].(float64)
+		counts[strings.TrimSuffix(key, "_errors")] = int(n)
+	}
+	return counts
+}
+
+// vdevParity is how many member disks a top-level vdev can lose
+func vdevParity(vdev map[string]interface{}) int {
+	kind, _ := vdev["type"].(string)
+	switch {
+	case kind == "MIRROR":
+		return len(vdevChildren(vdev)) - 1
+	case strings.HasPrefix(kind, "RAIDZ") || strings.HasPrefix(kind, "DRAID"):
+		n, _ := strconv.Atoi(kind[len(kind)-1:])
+		return n
+	}
+	return 0
+}
+
+// vdevRedundancyLeft is how many more member disks a top-level vdev can lose,
+// counting members that are already not ONLINE
+func vdevRedundancyLeft(vdev map[string]interface{}) int {
+	left := vdevParity(vdev)
+	for _, child := range vdevChildren(vdev) {
+		if status, _ := child["status"].(string); status != "ONLINE" {
+			left--
+		}
+	}
+	return left
+}
+
+// poolVdevNodes walks the topology of a pool
+func poolVdevNodes(pool map[string]interface{}) []vdevNode {
+	topology, _ := pool["topology"].(map[string]interface{})
+	nodes := []vdevNode{}
+	var walk func(vdev, parent, top map[string]interface{}, role string)
+	walk = func(vdev, parent, top map[string]interface{}, role string) {
+		nodes = append(nodes, vdevNode{vdev: vdev, parent: parent, top: top, role: role})
+		for _, child := range vdevChildren(vdev) {
+			walk(child, vdev, top, role)
+		}
+	}
+	for _, role := range poolTopologyRoles {
+		vdevs, _ := topology[role].([]interface{})
+		for _, v := range vdevs {
+			if vdev, ok := v.(map[string]interface{}); ok {
+				walk(vdev, nil, vdev, role)
+			}
+		}
+	}
+	return nodes
+}
+
+// findPoolMember finds a disk in a pool by disk name ("sda"), device name,
+// or GUID
+func findPoolMember(pool map[string]interface{}, ref string) (vdevNode, error) {
+	for _, node := range poolVdevNodes(pool) {
+		if len(vdevChildren(node.vdev)) > 0 {
+			continue
+		}
+		name, _ := node.vdev["name"].(string)
+		if node.guid() == ref || vdevDiskName(node.vdev) == ref || name == ref {
+			return node, nil
+		}
+	}
+	return vdevNode{}, newToolError(ErrorNotFound, "%s is not a disk in pool %v; get_pool_topology lists its members", ref, pool["name"])
+}
+
+// findPoolVdev finds a top-level vdev by name ("mirror-0") or GUID
+func findPoolVdev(pool map[string]interface{}, ref string) (vdevNode, bool) {
+	for _, node := range poolVdevNodes(pool) {
+		name, _ := node.vdev["name"].(string)
+		if node.parent == nil && (name == ref || node.guid() == ref) {
+			return node, true
+		}
+	}
+	return vdevNode{}, false
+}
+
+// getTopologyPool returns a pool by name
+func getTopologyPool(client truenas.Caller, name string) (map[string]interface{}, error) {
+	if name == "" {
+		return nil, fmt.Errorf("pool is required")
+	}
+	result, err := client.Call("pool.query", []interface{}{
+		[]interface{}{"name", "=", name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pool: %w", err)
+	}
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil {
+		return nil, fmt.Errorf("failed to parse pools: %w", err)
+	}
+	if len(pools) == 0 {
+		return nil, newToolError(ErrorNotFound, "pool '%s' not found", name)
+	}
+	return pools[0], nil
+}
+
+// poolResilvering reports whether a pool is resilvering; topology changes
+// wait until it finishes
+func poolResilvering(pool map[string]interface{}) bool {
+	scan, _ := pool["scan"].(map[string]interface{})
+	return scan["function"] == "RESILVER" && scan["state"] == "SCANNING"
+}
+
+// unusedDisks returns the disks in no pool, keyed by name
+func unusedDisks(client truenas.Caller) (map[string]map[string]interface{}, error) {
+	var disks []map[string]interface{}
+	if err := queryInto(client, "disk.get_unused", &disks); err != nil {
+		return nil, fmt.Errorf("failed to list unused disks: %w", err)
+	}
+	byName := map[string]map[string]interface{}{}
+	for _, disk := range disks {
+		if name, ok := disk["name"].(string); ok {
+			byName[name] = disk
+		}
+	}
+	return byName, nil
+}
+
+// requireUnusedDisk returns a disk that is in no pool
+func requireUnusedDisk(unused map[string]map[string]interface{}, name string) (map[string]interface{}, error) {
+	disk, ok := unused[name]
+	if !ok {
+		return nil, newToolError(ErrorPrecondition, "disk %s is not an unused disk; it may be in a pool, exported pool, or not exist (get_pool_topology lists unused disks)", name)
+	}
+	return disk, nil
+}
+
+func diskSize(disk map[string]interface{}) int64 {
+	size, _ := disk["size"].(float64)
+	return int64(size)
+}
+
+// memberDiskSizes returns the size of the pool disks by name
+func memberDiskSizes(client truenas.Caller, names []string) map[string]int64 {
+	sizes := map[string]int64{}
+	disks, err := queryDisks(client, []interface{}{})
+	if err != nil {
+		return sizes
+	}
+	for _, disk := range disks {
+		if name, _ := disk["name"].(string); containsString(names, name) {
+			sizes[name] = diskSize(disk)
+		}
+	}
+	return sizes
+}
+
+// simplifyVdev describes a vdev and its members
+func simplifyVdev(vdev map[string]interface{}) map[string]interface{} {
+	children := vdevChildren(vdev)
+	simplified := map[string]interface{}{
+		"name":   vdev["name"],
+		"type":   vdev["type"],
+		"guid":   vdevGUID(vdev),
+		"status": vdev["status"],
+		"errors": vdevErrors(vdev),
+	}
+	if len(children) == 0 {
+		simplified["disk"] = vdevDiskName(vdev)
+		return simplified
+	}
+	members := make([]map[string]interface{}, 0, len(children))
+	for _, child := range children {
+		members = append(members, simplifyVdev(child))
+	}
+	simplified["members"] = members
+	return simplified
+}
+
+func handleGetPoolTopology(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	poolName, _ := args["pool"].(string)
+	pool, err := getTopologyPool(client, poolName)
+	if err != nil {
+		return "", err
+	}
+
+	topology, _ := pool["topology"].(map[string]interface{})
+	groups := map[string]interface{}{}
+	issues := []string{}
+	for _, role := range poolTopologyRoles {
+		vdevs, _ := topology[role].([]interface{})
+		if len(vdevs) == 0 {
+			continue
+		}
+		simplified := make([]map[string]interface{}, 0, len(vdevs))
+		for _, v := range vdevs {
+			vdev, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entry := simplifyVdev(vdev)
+			name := fmt.Sprintf("%v", vdev["name"])
+			if role != "cache" && role != "spare" {
+				left := vdevRedundancyLeft(vdev)
+				entry["parity"] = vdevParity(vdev)
+				entry["redundancy_left"] = left
+				switch {
+				case left < 0:
+					issues = append(issues, fmt.Sprintf("%s vdev %s has lost more disks than it can tolerate", role, name))
+				case left == 0 && vdevParity(vdev) > 0:
+					issues = append(issues, fmt.Sprintf("%s vdev %s has no redundancy left; one more disk failure loses the pool", role, name))
+				case vdevParity(vdev) == 0:
+					issues = append(issues, fmt.Sprintf("%s vdev %s is a single disk without redundancy; losing it loses the pool (attach_disk can mirror it)", role, name))
+				}
+			}
+			for _, child := range append([]map[string]interface{}{vdev}, vdevChildren(vdev)...) {
+				if status, _ := child["status"].(string); status != "" && status != "ONLINE" && status != "AVAIL" && status != "INUSE" {
+					issues = append(issues, fmt.Sprintf("%s %v is %s", strings.ToLower(fmt.Sprint(child["type"])), child["name"], status))
+				}
+			}
+			simplified = append(simplified, entry)
+		}
+		groups[role] = simplified
+	}
+
+	response := map[string]interface{}{
+		"pool":     pool["name"],
+		"status":   pool["status"],
+		"healthy":  pool["healthy"],
+		"topology": groups,
+		"issues":   issues,
+	}
+	if scan, ok := pool["scan"].(map[string]interface{}); ok && scan["state"] == "SCANNING" {
+		response["scan"] = map[string]interface{}{
+			"function": scan["function"],
+			"percent":  scan["percentage"],
+		}
+	}
+
+	// Unused disks are the candidates for replace_disk, attach_disk, and add_vdevs
+	if unused, err := unusedDisks(client); err == nil {
+		candidates := make([]map[string]interface{}, 0, len(unused))
+		for _, name := range sortedDiskNames(unused) {
+			disk := unused[name]
+			candidates = append(candidates, map[string]interface{}{
+				"name":   name,
+				"size":   units.FormatBytes(diskSize(disk)),
+				"serial": disk["serial"],
+				"model":  disk["model"],
+				"type":   disk["type"],
+			})
+		}
+		response["unused_disks"] = candidates
+	} else {
+		response["collection_notes"] = []string{err.Error()}
+	}
+	return marshalJSON(response)
+}
+
+func sortedDiskNames(disks map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(disks))
+	for name := range disks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Replace
+
+// diskReplacement is a validated replace_disk request
+type diskReplacement struct {
+	pool     map[string]interface{}
+	member   vdevNode
+	vdev     map[string]interface{}
+	newDisk  map[string]interface{}
+	oldName  string
+	warnings []string
+}
+
+func planDiskReplacement(client truenas.Caller, args map[string]interface{}) (*diskReplacement, error) {
+	poolName, _ := args["pool"].(string)
+	pool, err := getTopologyPool(client, poolName)
+	if err != nil {
+		return nil, err
+	}
+	oldRef, _ := args["disk"].(string)
+	newName, _ := args["new_disk"].(string)
+	if oldRef == "" || newName == "" {
+		return nil, fmt.Errorf("disk and new_disk are required")
+	}
+	if poolResilvering(pool) {
+		return nil, newToolError(ErrorInProgress, "pool %s is resilvering; wait for it to finish before replacing another disk", poolName)
+	}
+
+	member, err := findPoolMember(pool, oldRef)
+	if err != nil {
+		return nil, err
+	}
+	unused, err := unusedDisks(client)
+	if err != nil {
+		return nil, err
+	}
+	newDisk, err := requireUnusedDisk(unused, newName)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &diskReplacement{
+		pool:    pool,
+		member:  member,
+		vdev:    member.top,
+		newDisk: newDisk,
+		oldName: vdevDiskName(member.vdev),
+	}
+
+	// The new disk must hold everything the old one did
+	if oldSize, ok := memberDiskSizes(client, []string{plan.oldName})[plan.oldName]; ok && diskSize(newDisk) < oldSize {
+		return nil, newToolError(ErrorPrecondition, "%s (%s) is smaller than %s (%s); a replacement must be at least as large",
+			newName, units.FormatBytes(diskSize(newDisk)), plan.oldName, units.FormatBytes(oldSize))
+	}
+
+	if member.status() == "ONLINE" {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("%s is ONLINE; it stays in the pool until the resilver finishes, so redundancy is kept throughout", plan.oldName))
+	}
+	if member.role == "data" && vdevParity(plan.vdev) > 0 && vdevRedundancyLeft(plan.vdev) <= 0 {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("vdev %v has no redundancy left; avoid heavy load until the resilver finishes", plan.vdev["name"]))
+	}
+	if vdevParity(plan.vdev) == 0 && member.role == "data" && member.status() != "ONLINE" {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("%s is a single-disk vdev and is %s; data that cannot be read from it cannot be rebuilt", plan.oldName, member.status()))
+	}
+	plan.warnings = append(plan.warnings, "Resilvering reads the whole vdev and can take many hours on large disks")
+	return plan, nil
+}
+
+func (r *Registry) handleReplaceDisk(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planDiskReplacement(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	options := map[string]interface{}{
+		"label": plan.member.guid(),
+		"disk":  plan.newDisk["identifier"],
+	}
+	if force, _ := args["force"].(bool); force {
+		options["force"] = true
+	}
+	result, err := client.Call("pool.replace", plan.pool["id"], options)
+	if err != nil {
+		return "", fmt.Errorf("failed to replace disk: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+	task, err := r.taskManager.CreateJobTask("replace_disk", args, jobID, time.Hour, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"pool":     plan.pool["name"],
+		"old_disk": plan.oldName,
+		"new_disk": plan.newDisk["name"],
+		"job_id":   jobID,
+		"task_id":  task.TaskID,
+		"warnings": plan.warnings,
+		"message":  fmt.Sprintf("Replacing %s with %v in pool %v. Track the replace with tasks_get using task_id: %s; the resilver that follows shows in get_pool_topology.", plan.oldName, plan.newDisk["name"], plan.pool["name"], task.TaskID),
+	})
+}
+
+// Attach and detach
+
+// diskAttachment is a validated attach_disk request
+type diskAttachment struct {
+	pool      map[string]interface{}
+	target    vdevNode
+	vdev      map[string]interface{} // Top-level vdev that grows
+	targetRef string                 // GUID passed to pool.attach
+	newDisk   map[string]interface{}
+	expansion bool // RAIDZ expansion rather than a mirror
+	warnings  []string
+}
+
+func planDiskAttachment(client truenas.Caller, args map[string]interface{}) (*diskAttachment, error) {
+	poolName, _ := args["pool"].(string)
+	pool, err := getTopologyPool(client, poolName)
+	if err != nil {
+		return nil, err
+	}
+	targetRef, _ := args["target"].(string)
+	newName, _ := args["new_disk"].(string)
+	if targetRef == "" || newName == "" {
+		return nil, fmt.Errorf("target and new_disk are required")
+	}
+	if poolResilvering(pool) {
+		return nil, newToolError(ErrorInProgress, "pool %s is resilvering; wait for it to finish before attaching a disk", poolName)
+	}
+
+	// The target is a top-level vdev ("mirror-0", "raidz1-0") or one of its disks
+	target, ok := findPoolVdev(pool, targetRef)
+	if !ok {
+		if target, err = findPoolMember(pool, targetRef); err != nil {
+			return nil, err
+		}
+	}
+	plan := &diskAttachment{pool: pool, target: target, vdev: target.top}
+	if plan.target.role == "cache" || plan.target.role == "spare" {
+		return nil, newToolError(ErrorValidation, "%s is a %s device; cache and spare devices cannot be mirrored", targetRef, plan.target.role)
+	}
+
+	vdevType, _ := plan.vdev["type"].(string)
+	members := vdevChildren(plan.vdev)
+	switch {
+	case vdevType == "DISK":
+		plan.targetRef = vdevGUID(plan.vdev)
+		members = []map[string]interface{}{plan.vdev}
+		plan.warnings = append(plan.warnings, fmt.Sprintf("%s becomes a two-way mirror", vdevDiskName(plan.vdev)))
+	case vdevType == "MIRROR":
+		// zpool attach takes a disk of the mirror
+		plan.targetRef = vdevGUID(members[0])
+		if len(vdevChildren(target.vdev)) == 0 {
+			plan.targetRef = target.guid()
+		}
+		plan.warnings = append(plan.warnings, fmt.Sprintf("mirror %v grows to %d disks", plan.vdev["name"], len(members)+1))
+	case strings.HasPrefix(vdevType, "RAIDZ"):
+		plan.targetRef = vdevGUID(plan.vdev)
+		plan.expansion = true
+		plan.warnings = append(plan.warnings,
+			fmt.Sprintf("RAIDZ expansion: %v grows from %d to %d disks; this cannot be undone and the pool cannot be imported by releases without RAIDZ expansion", plan.vdev["name"], len(members), len(members)+1),
+			"Existing data keeps its old data-to-parity ratio until it is rewritten, so less space is gained than a new vdev of that width would give",
+			"Expansion reflows all data in the vdev and can take days on large vdevs")
+	default:
+		return nil, newToolError(ErrorValidation, "cannot attach to a %s vdev", vdevType)
+	}
+
+	unused, err := unusedDisks(client)
+	if err != nil {
+		return nil, err
+	}
+	if plan.newDisk, err = requireUnusedDisk(unused, newName); err != nil {
+		return nil, err
+	}
+
+	// A smaller disk cannot hold a copy of (or its share of) the vdev
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		names = append(names, vdevDiskName(member))
+	}
+	for name, size := range memberDiskSizes(client, names) {
+		if diskSize(plan.newDisk) < size {
+			return nil, newToolError(ErrorPrecondition, "%s (%s) is smaller than member %s (%s) of %v",
+				newName, units.FormatBytes(diskSize(plan.newDisk)), name, units.FormatBytes(size), plan.vdev["name"])
+		}
+	}
+	return plan, nil
+}
+
+func (r *Registry) handleAttachDisk(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planDiskAttachment(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("pool.attach", plan.pool["id"], map[string]interface{}{
+		"target_vdev": plan.targetRef,
+		"new_disk":    plan.newDisk["name"],
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to attach disk: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+	timeout := time.Hour
+	if plan.expansion {
+		timeout = 7 * 24 * time.Hour
+	}
+	task, err := r.taskManager.CreateJobTask("attach_disk", args, jobID, timeout, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"pool":     plan.pool["name"],
+		"vdev":     plan.vdev["name"],
+		"new_disk": plan.newDisk["name"],
+		"job_id":   jobID,
+		"task_id":  task.TaskID,
+		"warnings": plan.warnings,
+		"message":  fmt.Sprintf("Attaching %v to %v in pool %v. Track progress with tasks_get using task_id: %s", plan.newDisk["name"], plan.vdev["name"], plan.pool["name"], task.TaskID),
+	})
+}
+
+// diskDetachment is a validated detach_disk request
+type diskDetachment struct {
+	pool     map[string]interface{}
+	member   vdevNode
+	warnings []string
+}
+
+func planDiskDetachment(client truenas.Caller, args map[string]interface{}) (*diskDetachment, error) {
+	poolName, _ := args["pool"].(string)
+	pool, err := getTopologyPool(client, poolName)
+	if err != nil {
+		return nil, err
+	}
+	ref, _ := args["disk"].(string)
+	if ref == "" {
+		return nil, fmt.Errorf("disk is required")
+	}
+	member, err := findPoolMember(pool, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only mirror members (including the halves of a replace or an in-use
+	// spare) can be detached; anything else would remove data
+	parentKind := member.parentKind()
+	if parentKind != "MIRROR" && parentKind != "REPLACING" && parentKind != "SPARE" {
+		return nil, newToolError(ErrorValidation, "%s is not part of a mirror; only mirror members can be detached (remove a vdev through the TrueNAS UI instead)", ref)
+	}
+
+	plan := &diskDetachment{pool: pool, member: member}
+	others := 0
+	for _, sibling := range vdevChildren(member.parent) {
+		if vdevGUID(sibling) != member.guid() && sibling["status"] == "ONLINE" {
+			others++
+		}
+	}
+	if others == 0 {
+		return nil, newToolError(ErrorPrecondition, "%s is the last ONLINE disk of %v; detaching it would take the vdev offline", ref, member.parent["name"])
+	}
+	if parentKind == "MIRROR" && others == 1 && member.status() == "ONLINE" {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("%v is left with a single disk and no redundancy", member.parent["name"]))
+	}
+	plan.warnings = append(plan.warnings, fmt.Sprintf("%s loses its copy of the pool data and can then be reused", vdevDiskName(member.vdev)))
+	return plan, nil
+}
+
+func handleDetachDisk(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planDiskDetachment(client, args)
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.Call("pool.detach", plan.pool["id"], map[string]interface{}{"label": plan.member.guid()}); err != nil {
+		return "", fmt.Errorf("failed to detach disk: %w", err)
+	}
+	return marshalJSON(map[string]interface{}{
+		"pool":     plan.pool["name"],
+		"disk":     vdevDiskName(plan.member.vdev),
+		"detached": true,
+		"warnings": plan.warnings,
+		"message":  fmt.Sprintf("Detached %s from %v in pool %v", vdevDiskName(plan.member.vdev), plan.member.parent["name"], plan.pool["name"]),
+	})
+}
+
+// Add vdevs
+
+// vdevAddition is a validated add_vdevs request
+type vdevAddition struct {
+	pool     map[string]interface{}
+	role     string
+	vdevType string
+	groups   [][]string // Disks of each new vdev
+	topology map[string]interface{}
+	warnings []string
+}
+
+// vdevRoles maps add_vdevs roles to pool.update topology keys
+var vdevRoles = map[string]string{"data": "data", "special": "special", "dedup": "dedup", "log": "log", "cache": "cache", "spare": "spares"}
+
+func planVdevAddition(client truenas.Caller, args map[string]interface{}) (*vdevAddition, error) {
+	poolName, _ := args["pool"].(string)
+	pool, err := getTopologyPool(client, poolName)
+	if err != nil {
+		return nil, err
+	}
+	role, _ := args["role"].(string)
+	if role == "" {
+		role = "data"
+	}
+	if _, ok := vdevRoles[role]; !ok {
+		return nil, newToolError(ErrorValidation, "role must be one of data, special, dedup, log, cache, spare (got: %s)", role)
+	}
+	vdevType, _ := args["vdev_type"].(string)
+	vdevType = strings.ToUpper(vdevType)
+	if role == "cache" || role == "spare" {
+		vdevType = "STRIPE"
+	}
+	if _, ok := vdevMinDisks[vdevType]; !ok {
+		return nil, newToolError(ErrorValidation, "vdev_type must be STRIPE, MIRROR, RAIDZ1, RAIDZ2, or RAIDZ3 (got: %v)", args["vdev_type"])
+	}
+	disks := stringList(args["disks"])
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("disks is required")
+	}
+	if poolResilvering(pool) {
+		return nil, newToolError(ErrorInProgress, "pool %s is resilvering; wait for it to finish before changing its layout", poolName)
+	}
+
+	unused, err := unusedDisks(client)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, name := range disks {
+		if seen[name] {
+			return nil, newToolError(ErrorValidation, "disk %s is listed twice", name)
+		}
+		seen[name] = true
+		if _, err := requireUnusedDisk(unused, name); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &vdevAddition{pool: pool, role: role, vdevType: vdevType}
+
+	// Disks are split into vdevs of vdev_width, by default one vdev of all
+	width := len(disks)
+	if w, ok := args["vdev_width"].(float64); ok && w > 0 {
+		width = int(w)
+	}
+	if vdevType == "STRIPE" {
+		width = 1
+	}
+	if len(disks)%width != 0 {
+		return nil, newToolError(ErrorValidation, "%d disks do not divide into vdevs of %d", len(disks), width)
+	}
+	if width < vdevMinDisks[vdevType] {
+		return nil, newToolError(ErrorValidation, "a %s vdev needs at least %d disks (got: %d)", vdevType, vdevMinDisks[vdevType], width)
+	}
+	for i := 0; i < len(disks); i += width {
+		plan.groups = append(plan.groups, disks[i:i+width])
+	}
+
+	// Data, special, and dedup vdevs hold pool data; losing one loses the pool
+	topology, _ := pool["topology"].(map[string]interface{})
+	existing, _ := topology["data"].([]interface{})
+	redundant := false
+	existingTypes := map[string]bool{}
+	existingWidths := map[int]bool{}
+	for _, v := range existing {
+		vdev, _ := v.(map[string]interface{})
+		if vdevParity(vdev) > 0 {
+			redundant = true
+		}
+		kind, _ := vdev["type"].(string)
+		existingTypes[kind] = true
+		existingWidths[len(vdevChildren(vdev))] = true
+	}
+	allowUnsafe, _ := args["allow_no_redundancy"].(bool)
+	storesData := role == "data" || role == "special" || role == "dedup"
+	if storesData && vdevType == "STRIPE" && redundant && !allowUnsafe {
+		return nil, newToolError(ErrorPrecondition, "adding a single-disk %s vdev to redundant pool %s makes the whole pool fail with that one disk; use MIRROR or RAIDZ, or set allow_no_redundancy", role, poolName)
+	}
+	if role == "data" && len(existing) > 0 {
+		if !existingTypes[vdevType] && !(vdevType == "STRIPE" && existingTypes["DISK"]) {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("The pool's data vdevs are %s; mixing in %s vdevs gives uneven redundancy and performance", strings.Join(mapKeys(existingTypes), ", "), vdevType))
+		} else if vdevType != "STRIPE" && !existingWidths[width] {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("Existing data vdevs have %v disks; the new vdevs have %d", intKeys(existingWidths), width))
+		}
+	}
+	if storesData {
+		plan.warnings = append(plan.warnings,
+			"Adding vdevs is permanent on pools with RAIDZ data vdevs; they cannot be removed again",
+			"Existing data is not rebalanced onto the new vdevs; only new writes are spread across them")
+	}
+	if role == "special" || role == "dedup" {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("Losing the %s vdev loses the whole pool; give it at least the redundancy of the data vdevs", role))
+	}
+
+	// Mixed sizes waste the larger disks' extra space
+	sizes := map[int64]bool{}
+	for _, name := range disks {
+		sizes[diskSize(unused[name])] = true
+	}
+	if len(sizes) > 1 && vdevType != "STRIPE" {
+		plan.warnings = append(plan.warnings, "The disks differ in size; each vdev is limited by its smallest disk")
+	}
+
+	key := vdevRoles[role]
+	if role == "spare" {
+		plan.topology = map[string]interface{}{key: disks}
+	} else {
+		// A STRIPE entry makes a single-disk vdev of each of its disks
+		vdevs := []interface{}{map[string]interface{}{"type": "STRIPE", "disks": disks}}
+		if vdevType != "STRIPE" {
+			vdevs = vdevs[:0]
+			for _, group := range plan.groups {
+				vdevs = append(vdevs, map[string]interface{}{"type": vdevType, "disks": group})
+			}
+		}
+		plan.topology = map[string]interface{}{key: vdevs}
+	}
+	return plan, nil
+}
+
+// intKeys returns the sorted keys of a set of ints
+func intKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func (r *Registry) handleAddVdevs(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planVdevAddition(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("pool.update", plan.pool["id"], map[string]interface{}{"topology": plan.topology})
+	if err != nil {
+		return "", fmt.Errorf("failed to add vdevs: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+	task, err := r.taskManager.CreateJobTask("add_vdevs", args, jobID, time.Hour, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"pool":      plan.pool["name"],
+		"role":      plan.role,
+		"vdev_type": plan.vdevType,
+		"vdevs":     plan.groups,
+		"job_id":    jobID,
+		"task_id":   task.TaskID,
+		"warnings":  plan.warnings,
+		"message":   fmt.Sprintf("Adding %d %s vdev(s) to pool %v. Track progress with tasks_get using task_id: %s", len(plan.groups), plan.role, plan.pool["name"], task.TaskID),
+	})
+}
+
+// Dry-run wrappers
+
+func (r *Registry) handleReplaceDiskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &replaceDiskDryRun{}, r.handleReplaceDisk)
+}
+
+func (r *Registry) handleAttachDiskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &attachDiskDryRun{}, r.handleAttachDisk)
+}
+
+func handleDetachDiskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &detachDiskDryRun{}, handleDetachDisk)
+}
+
+func (r *Registry) handleAddVdevsWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &addVdevsDryRun{}, r.handleAddVdevs)
+}
+
+// Dry-run implementations
+
+type replaceDiskDryRun struct{}
+
+func (d *replaceDiskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planDiskReplacement(client, args)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunResult{
+		Tool: "replace_disk",
+		CurrentState: map[string]interface{}{
+			"pool":   plan.pool["name"],
+			"vdev":   simplifyVdev(plan.vdev),
+			"member": simplifyVdev(plan.member.vdev),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Replace %s with %v (%s, serial %v) and resilver", plan.oldName, plan.newDisk["name"], units.FormatBytes(diskSize(plan.newDisk)), plan.newDisk["serial"]),
+				Operation:   "replace",
+				Target:      fmt.Sprintf("%v/%s", plan.pool["name"], plan.oldName),
+			},
+		},
+		Warnings: plan.warnings,
+	}, nil
+}
+
+type attachDiskDryRun struct{}
+
+func (d *attachDiskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planDiskAttachment(client, args)
+	if err != nil {
+		return nil, err
+	}
+	operation := "attach"
+	if plan.expansion {
+		operation = "expand"
+	}
+	return &DryRunResult{
+		Tool: "attach_disk",
+		CurrentState: map[string]interface{}{
+			"pool": plan.pool["name"],
+			"vdev": simplifyVdev(plan.vdev),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Attach %v (%s) to %v", plan.newDisk["name"], units.FormatBytes(diskSize(plan.newDisk)), plan.vdev["name"]),
+				Operation:   operation,
+				Target:      fmt.Sprintf("%v/%v", plan.pool["name"], plan.vdev["name"]),
+			},
+		},
+		Warnings: plan.warnings,
+	}, nil
+}
+
+type detachDiskDryRun struct{}
+
+func (d *detachDiskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planDiskDetachment(client, args)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunResult{
+		Tool: "detach_disk",
+		CurrentState: map[string]interface{}{
+			"pool": plan.pool["name"],
+			"vdev": simplifyVdev(plan.member.parent),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Detach %s from %v", vdevDiskName(plan.member.vdev), plan.member.parent["name"]),
+				Operation:   "detach",
+				Target:      fmt.Sprintf("%v/%s", plan.pool["name"], vdevDiskName(plan.member.vdev)),
+			},
+		},
+		Warnings: plan.warnings,
+	}, nil
+}
+
+type addVdevsDryRun struct{}
+
+func (d *addVdevsDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planVdevAddition(client, args)
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]PlannedAction, 0, len(plan.groups))
+	for i, group := range plan.groups {
+		actions = append(actions, PlannedAction{
+			Step:        i + 1,
+			Description: fmt.Sprintf("Add a %s %s vdev of %s", plan.vdevType, plan.role, strings.Join(group, ", ")),
+			Operation:   "add_vdev",
+			Target:      fmt.Sprintf("%v", plan.pool["name"]),
+		})
+	}
+	topology, _ := plan.pool["topology"].(map[string]interface{})
+	current := []map[string]interface{}{}
+	vdevs, _ := topology[plan.role].([]interface{})
+	for _, v := range vdevs {
+		if vdev, ok := v.(map[string]interface{}); ok {
+			current = append(current, simplifyVdev(vdev))
+		}
+	}
+	return &DryRunResult{
+		Tool: "add_vdevs",
+		CurrentState: map[string]interface{}{
+			"pool":       plan.pool["name"],
+			plan.role:    current,
+			"vdev_count": len(current),
+		},
+		PlannedActions: actions,
+		Warnings:       plan.warnings,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationPoolTopology(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	leaf := func(disk, guid, status string) map[string]interface{} {
+		return map[string]interface{}{"name": disk + "2", "type": "DISK", "disk": disk, "guid": guid, "status": status, "children": []interface{}{},
+			"stats": map[string]interface{}{"read_errors": float64(0), "write_errors": float64(0), "checksum_errors": float64(0)}}
+	}
+	tank := map[string]interface{}{"id": float64(1), "name": "tank", "status": "DEGRADED", "healthy": false,
+		"topology": map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{"name": "mirror-0", "type": "MIRROR", "guid": "100", "status": "DEGRADED", "children": []interface{}{
+					leaf("sda", "101", "ONLINE"), leaf("sdb", "102", "FAULTED"),
+				}},
+			},
+		},
+	}
+	media := map[string]interface{}{"id": float64(2), "name": "media", "status": "ONLINE", "healthy": true,
+		"topology": map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{"name": "raidz1-0", "type": "RAIDZ1", "guid": "200", "status": "ONLINE", "children": []interface{}{
+					leaf("sdc", "201", "ONLINE"), leaf("sdd", "202", "ONLINE"), leaf("sde", "203", "ONLINE"),
+				}},
+			},
+		},
+	}
+	scratch := map[string]interface{}{"id": float64(3), "name": "scratch", "status": "ONLINE", "healthy": true,
+		"topology": map[string]interface{}{"data": []interface{}{leaf("sdx", "300", "ONLINE")}},
+	}
+	server.SetRecords("pool.query", []map[string]interface{}{tank, media, scratch})
+	tb := float64(4000787030016)
+	server.SetRecords("disk.query", []map[string]interface{}{
+		{"name": "sda", "size": tb}, {"name": "sdb", "size": tb}, {"name": "sdc", "size": tb},
+		{"name": "sdd", "size": tb}, {"name": "sde", "size": tb}, {"name": "sdx", "size": tb},
+	})
+	server.SetResult("disk.get_unused", []map[string]interface{}{
+		{"name": "sdf", "identifier": "{serial}F", "serial": "F", "size": tb},
+		{"name": "sdg", "identifier": "{serial}G", "serial": "G", "size": float64(2000398934016)},
+		{"name": "sdh", "identifier": "{serial}H", "serial": "H", "size": tb},
+	})
+	server.HandleJob("pool.replace", truenastest.JobSpec{Steps: 100})
+	server.HandleJob("pool.attach", truenastest.JobSpec{Steps: 100})
+	server.HandleJob("pool.update", truenastest.JobSpec{Steps: 100})
+	server.SetResult("pool.detach", true)
+
+	result, err := registry.CallTool("get_pool_topology", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("get_pool_topology failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	mirror := response["topology"].(map[string]interface{})["data"].([]interface{})[0].(map[string]interface{})
+	if mirror["redundancy_left"] != float64(0) || len(mirror["members"].([]interface{})) != 2 {
+		t.Errorf("mirror-0 = %v, want no redundancy left", mirror)
+	}
+	if issues := fmt.Sprint(response["issues"]); !strings.Contains(issues, "sdb2 is FAULTED") || !strings.Contains(issues, "no redundancy left") {
+		t.Errorf("issues = %s", issues)
+	}
+	if unused := response["unused_disks"].([]interface{}); len(unused) != 3 {
+		t.Errorf("unused_disks = %v", unused)
+	}
+
+	// Refusals
+	refusals := []struct {
+		tool string
+		args map[string]interface{}
+		code ErrorCode
+	}{
+		{"replace_disk", map[string]interface{}{"pool": "tank", "disk": "sdb", "new_disk": "sdg"}, ErrorPrecondition},
+		{"replace_disk", map[string]interface{}{"pool": "tank", "disk": "sdb", "new_disk": "sdc"}, ErrorPrecondition},
+		{"replace_disk", map[string]interface{}{"pool": "tank", "disk": "sdz", "new_disk": "sdf"}, ErrorNotFound},
+		{"detach_disk", map[string]interface{}{"pool": "tank", "disk": "sda"}, ErrorPrecondition},
+		{"detach_disk", map[string]interface{}{"pool": "media", "disk": "sdc"}, ErrorValidation},
+		{"add_vdevs", map[string]interface{}{"pool": "media", "disks": []interface{}{"sdf"}, "vdev_type": "STRIPE"}, ErrorPrecondition},
+		{"add_vdevs", map[string]interface{}{"pool": "media", "disks": []interface{}{"sdf", "sdh"}, "vdev_type": "RAIDZ1"}, ErrorValidation},
+		{"add_vdevs", map[string]interface{}{"pool": "media", "disks": []interface{}{"sdf", "sdh", "sdg"}, "vdev_type": "MIRROR", "vdev_width": float64(2)}, ErrorValidation},
+	}
+	for _, refusal := range refusals {
+		_, err := registry.CallTool(refusal.tool, refusal.args)
+		if err == nil || ClassifyError(err).Code != refusal.code {
+			t.Errorf("%s %v error = %v, want %s", refusal.tool, refusal.args, err, refusal.code)
+		}
+	}
+
+	// Dry runs change nothing
+	result, err = registry.CallTool("attach_disk", map[string]interface{}{"pool": "media", "target": "raidz1-0", "new_disk": "sdf", "dry_run": true})
+	if err != nil {
+		t.Fatalf("attach_disk dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "RAIDZ expansion") || len(server.Calls("pool.attach")) != 0 {
+		t.Errorf("RAIDZ expansion dry run = %s", result)
+	}
+	result, err = registry.CallTool("add_vdevs", map[string]interface{}{"pool": "media", "disks": []interface{}{"sdf", "sdh"}, "vdev_type": "MIRROR", "dry_run": true})
+	if err != nil {
+		t.Fatalf("add_vdevs dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "mixing in MIRROR vdevs") || len(server.Calls("pool.update")) != 0 {
+		t.Errorf("add_vdevs dry run = %s", result)
+	}
+
+	// Replace the faulted disk of tank
+	result, err = registry.CallTool("replace_disk", map[string]interface{}{"pool": "tank", "disk": "sdb", "new_disk": "sdf"})
+	if err != nil {
+		t.Fatalf("replace_disk failed: %v", err)
+	}
+	if decodeResult(t, result)["task_id"] == nil {
+		t.Errorf("replace_disk = %s, want a task", result)
+	}
+	replace := server.Calls("pool.replace")[0].Params
+	if replace[0] != float64(1) || fmt.Sprint(replace[1]) != "map[disk:{serial}F label:102]" {
+		t.Errorf("pool.replace params = %v", replace)
+	}
+
+	// Mirror the single disk of scratch
+	if _, err := registry.CallTool("attach_disk", map[string]interface{}{"pool": "scratch", "target": "sdx", "new_disk": "sdh"}); err != nil {
+		t.Fatalf("attach_disk failed: %v", err)
+	}
+	attach := server.Calls("pool.attach")[0].Params
+	if fmt.Sprint(attach[1]) != "map[new_disk:sdh target_vdev:300]" {
+		t.Errorf("pool.attach params = %v", attach)
+	}
+
+	// Add two mirrors to media
+	if _, err := registry.CallTool("add_vdevs", map[string]interface{}{"pool": "media", "disks": []interface{}{"sdc", "sdd"}, "vdev_type": "MIRROR"}); err == nil {
+		t.Errorf("add_vdevs accepted disks already in a pool")
+	}
+	server.SetResult("disk.get_unused", []map[string]interface{}{
+		{"name": "sdi", "size": tb}, {"name": "sdj", "size": tb}, {"name": "sdk", "size": tb}, {"name": "sdl", "size": tb},
+	})
+	if _, err := registry.CallTool("add_vdevs", map[string]interface{}{"pool": "media", "disks": []interface{}{"sdi", "sdj", "sdk", "sdl"}, "vdev_type": "mirror", "vdev_width": float64(2)}); err != nil {
+		t.Fatalf("add_vdevs failed: %v", err)
+	}
+	update := server.Calls("pool.update")[0].Params
+	if fmt.Sprint(update[1]) != "map[topology:map[data:[map[disks:[sdi sdj] type:MIRROR] map[disks:[sdk sdl] type:MIRROR]]]]" {
+		t.Errorf("pool.update params = %v", update)
+	}
+}
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Pool feature flag upgrade handlers
+
+// poolUpgradeStatus is whether a pool has every feature flag of the running release enabled
+type poolUpgradeStatus struct {
+	ID       interface{}
+	Name     string
+	Upgraded bool
+	Err      error
+}
+
+// queryPoolUpgradeStatus checks pool.is_upgraded for every pool, or only for
+// poolName when given
+func queryPoolUpgradeStatus(client truenas.Caller, poolName string) ([]poolUpgradeStatus, error) {
+	filters := []interface{}{}
+	if poolName != "" {
+		filters = append(filters, []interface{}{"name", "=", poolName})
+	}
+
+	result, err := client.Call("pool.query", filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pools: %w", err)
+	}
+
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil {
+		return nil, fmt.Errorf("failed to parse pools: %w", err)
+	}
+	if poolName != "" && len(pools) == 0 {
+		return nil, fmt.Errorf("pool '%s' not found", poolName)
+	}
+
+	statuses := make([]poolUpgradeStatus, 0, len(pools))
+	for _, pool := range pools {
+		status := poolUpgradeStatus{ID: pool["id"]}
+		status.Name, _ = pool["name"].(string)
+
+		upgraded, err := client.Call("pool.is_upgraded", pool["id"])
+		if err == nil {
+			err = json.Unmarshal(upgraded, &status.Upgraded)
+		}
+		status.Err = err
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+const poolUpgradeWarning = "Upgraded pools cannot be imported by older TrueNAS versions, including older boot environments on this system. The upgrade cannot be undone."
+
+func handleQueryPoolUpgrades(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	statuses, err := queryPoolUpgradeStatus(client, "")
+	if err != nil {
+		return "", err
+	}
+
+	pools := make([]map[string]interface{}, 0, len(statuses))
+	outdated := 0
+	for _, status := range statuses {
+		entry := map[string]interface{}{
+			"pool": status.Name,
+		}
+		if status.Err != nil {
+			entry["error"] = status.Err.Error()
+		} else {
+			entry["upgraded"] = status.Upgraded
+			if !status.Upgraded {
+				outdated++
+				entry["note"] = "New ZFS feature flags are available"
+			}
+		}
+		pools = append(pools, entry)
+	}
+
+	response := map[string]interface{}{
+		"pools":          pools,
+		"outdated_count": outdated,
+	}
+	if outdated > 0 {
+		response["recommendation"] = "Upgrade with upgrade_pool once you are sure you will not roll back to an older TrueNAS version. " + poolUpgradeWarning
+	}
+
+	return marshalJSON(response)
+}
+
+func handleUpgradePool(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	poolName, ok := args["pool"].(string)
+	if !ok || poolName == "" {
+		return "", fmt.Errorf("pool is required")
+	}
+
+	statuses, err := queryPoolUpgradeStatus(client, poolName)
+	if err != nil {
+		return "", err
+	}
+	status := statuses[0]
+	if status.Err != nil {
+		return "", fmt.Errorf("failed to check upgrade status: %w", status.Err)
+	}
+
+	if status.Upgraded {
+		return marshalJSON(map[string]interface{}{
+			"pool":     poolName,
+			"upgraded": true,
+			"changed":  false,
+			"message":  fmt.Sprintf("Pool '%s' already has all feature flags enabled", poolName),
+		})
+	}
+
+	if _, err := client.Call("pool.upgrade", status.ID); err != nil {
+		return "", fmt.Errorf("failed to upgrade pool: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"pool":     poolName,
+		"upgraded": true,
+		"changed":  true,
+		"message":  fmt.Sprintf("Pool '%s' feature flags upgraded", poolName),
+		"reminder": poolUpgradeWarning,
+	})
+}
+
+func (r *Registry) handleUpgradePoolWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &upgradePoolDryRun{}, handleUpgradePool)
+}
+
+type upgradePoolDryRun struct{}
+
+func (u *upgradePoolDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	poolName, ok := args["pool"].(string)
+	if !ok || poolName == "" {
+		return nil, fmt.Errorf("pool is required")
+	}
+
+	statuses, err := queryPoolUpgradeStatus(client, poolName)
+	if err != nil {
+		return nil, err
+	}
+	status := statuses[0]
+	if status.Err != nil {
+		return nil, fmt.Errorf("failed to check upgrade status: %w", status.Err)
+	}
+
+	actions := []PlannedAction{}
+	warnings := []string{}
+	if status.Upgraded {
+		warnings = append(warnings, fmt.Sprintf("Pool '%s' already has all feature flags enabled; nothing to do", poolName))
+	} else {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: fmt.Sprintf("Enable all supported ZFS feature flags on pool '%s'", poolName),
+			Operation:   "upgrade",
+			Target:      poolName,
+		})
+		warnings = append(warnings,
+			"PERMANENT: "+poolUpgradeWarning,
+			"Replication targets running older TrueNAS versions may no longer accept streams that use new features",
+		)
+	}
+
+	return &DryRunResult{
+		Tool: "upgrade_pool",
+		CurrentState: map[string]interface{}{
+			"pool":     poolName,
+			"upgraded": status.Upgraded,
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
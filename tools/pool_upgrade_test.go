@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationUpgradePool(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{
+		{"id": float64(1), "name": "tank"},
+		{"id": float64(2), "name": "backup"},
+	})
+	server.Handle("pool.is_upgraded", func(params []interface{}) (interface{}, error) {
+		return params[0] == float64(1), nil
+	})
+	server.SetResult("pool.upgrade", true)
+
+	result, err := registry.CallTool("query_pool_upgrades", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_pool_upgrades failed: %v", err)
+	}
+	if report := decodeResult(t, result); report["outdated_count"] != float64(1) {
+		t.Errorf("outdated_count = %v, want 1", report["outdated_count"])
+	}
+
+	result, err = registry.CallTool("upgrade_pool", map[string]interface{}{"pool": "backup", "dry_run": true})
+	if err != nil {
+		t.Fatalf("upgrade_pool dry run failed: %v", err)
+	}
+	if n := len(server.Calls("pool.upgrade")); n != 0 {
+		t.Fatalf("dry run upgraded the pool (%d calls)", n)
+	}
+	preview := decodeResult(t, result)
+	warnings, _ := preview["warnings"].([]interface{})
+	if len(warnings) == 0 || !strings.Contains(warnings[0].(string), "older TrueNAS versions") {
+		t.Errorf("warnings = %v, want import compatibility warning", preview["warnings"])
+	}
+
+	if _, err := registry.CallTool("upgrade_pool", map[string]interface{}{"pool": "tank"}); err != nil {
+		t.Fatalf("upgrade_pool on upgraded pool failed: %v", err)
+	}
+	if n := len(server.Calls("pool.upgrade")); n != 0 {
+		t.Fatalf("already upgraded pool was upgraded again (%d calls)", n)
+	}
+
+	if _, err := registry.CallTool("upgrade_pool", map[string]interface{}{"pool": "backup"}); err != nil {
+		t.Fatalf("upgrade_pool failed: %v", err)
+	}
+	calls := server.Calls("pool.upgrade")
+	if len(calls) != 1 || calls[0].Params[0] != float64(2) {
+		t.Errorf("pool.upgrade calls = %v, want one call for pool 2", calls)
+	}
+}
@@ -0,0 +1,303 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Host port usage map
+
+// Port owner types
+const (
+	portOwnerSystem = "system"
+	portOwnerApp    = "app"
+	portOwnerVM     = "vm"
+)
+
+// appPortRangeStart is where TrueNAS catalog apps put their default ports;
+// suggestions start there so they do not collide with well-known services
+const appPortRangeStart = 30000
+
+// servicePorts are the fixed ports of services whose port is not configurable
+var servicePorts = map[string][]map[string]interface{}{
+	"cifs": {{"port": 445, "protocol": "tcp"}, {"port": 139, "protocol": "tcp"}},
+	"nfs":  {{"port": 2049, "protocol": "tcp"}, {"port": 111, "protocol": "tcp"}},
+	"snmp": {{"port": 161, "protocol": "udp"}},
+	"ups":  {{"port": 3493, "protocol": "tcp"}},
+}
+
+// portMap collects the host ports in use
+type portMap struct {
+	client  truenas.Caller
+	entries []map[string]interface{}
+	notes   []string
+}
+
+func (m *portMap) add(port int, protocol, ownerType, owner, detail string, active bool) {
+	if port <= 0 {
+		return
+	}
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	entry := map[string]interface{}{
+		"port":       port,
+		"protocol":   strings.ToLower(protocol),
+		"owner_type": ownerType,
+		"owner":      owner,
+		"active":     active,
+	}
+	if detail != "" {
+		entry["detail"] = detail
+	}
+	m.entries = append(m.entries, entry)
+}
+
+// config reads a *.config method, noting failures
+func (m *portMap) config(section, method string) map[string]interface{} {
+	var config map[string]interface{}
+	if err := queryInto(m.client, method, &config); err != nil {
+		m.notes = append(m.notes, fmt.Sprintf("%s not checked: %v", section, err))
+		return nil
+	}
+	return config
+}
+
+func configPort(config map[string]interface{}, key string, fallback int) int {
+	if port, ok := config[key].(float64); ok && port > 0 {
+		return int(port)
+	}
+	return fallback
+}
+
+func (m *portMap) findSystemPorts() {
+	// The web UI is always listening
+	if general := m.config("Web UI", "system.general.config"); general != nil {
+		m.add(configPort(general, "ui_port", 80), "tcp", portOwnerSystem, "web_ui", "HTTP", true)
+		m.add(configPort(general, "ui_httpsport", 443), "tcp", portOwnerSystem, "web_ui", "HTTPS", true)
+	}
+
+	services, err := inventoryQuery(m.client, "service.query", []interface{}{})
+	if err != nil {
+		m.notes = append(m.notes, fmt.Sprintf("Services not checked: %v", err))
+		return
+	}
+	for _, service := range services {
+		name, _ := service["service"].(string)
+		running := service["state"] == "RUNNING"
+		enabled, _ := service["enable"].(bool)
+		// Stopped services that start at boot claim their port too
+		if !running && !enabled {
+			continue
+		}
+		switch name {
+		case "ssh":
+			if config := m.config("SSH", "ssh.config"); config != nil {
+				m.add(configPort(config, "tcpport", 22), "tcp", portOwnerSystem, name, "", running)
+			}
+		case "ftp":
+			if config := m.config("FTP", "ftp.config"); config != nil {
+				m.add(configPort(config, "port", 21), "tcp", portOwnerSystem, name, "", running)
+			}
+		case "iscsitarget":
+			if config := m.config("iSCSI", "iscsi.global.config"); config != nil {
+				m.add(configPort(config, "listen_port", 3260), "tcp", portOwnerSystem, name, "", running)
+			}
+		default:
+			for _, p := range servicePorts[name] {
+				m.add(p["port"].(int), p["protocol"].(string), portOwnerSystem, name, "", running)
+			}
+		}
+	}
+}
+
+// appConfigPorts finds published ports in an app's configuration:
+// {"bind_mode": "published", "port_number": N} entries, and integer "*_port"
+// values of the older network sections
+func appConfigPorts(values map[string]interface{}, path string, ports map[int]string) {
+	if number, ok := values["port_number"].(float64); ok {
+		if mode, _ := values["bind_mode"].(string); mode != "exposed" && mode != "" {
+			ports[int(number)] = path
+		}
+	}
+	for key, value := range values {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			appConfigPorts(v, strings.TrimPrefix(path+"."+key, "."), ports)
+		case float64:
+			if strings.HasSuffix(key, "_port") && strings.HasPrefix(path, "network") && v > 0 {
+				ports[int(v)] = strings.TrimPrefix(path+"."+key, ".")
+			}
+		}
+	}
+}
+
+func (m *portMap) findAppPorts() {
+	apps, err := inventoryQuery(m.client, "app.query", []interface{}{}, map[string]interface{}{
+		"extra": map[string]interface{}{"retrieve_config": true},
+	})
+	if err != nil {
+		m.notes = append(m.notes, fmt.Sprintf("Apps not checked: %v", err))
+		return
+	}
+	for _, app := range apps {
+		name, _ := app["name"].(string)
+		running := app["state"] == "RUNNING" || app["state"] == "DEPLOYING"
+
+		// Ports of running containers as Docker publishes them
+		seen := map[string]bool{}
+		workloads, _ := app["active_workloads"].(map[string]interface{})
+		used, _ := workloads["used_ports"].([]interface{})
+		for _, raw := range used {
+			mapping, _ := raw.(map[string]interface{})
+			protocol, _ := mapping["protocol"].(string)
+			hostPorts, _ := mapping["host_ports"].([]interface{})
+			for _, rawHost := range hostPorts {
+				host, _ := rawHost.(map[string]interface{})
+				port, _ := host["host_port"].(float64)
+				key := fmt.Sprintf("%d/%s", int(port), strings.ToLower(protocol))
+				if port == 0 || seen[key] {
+					continue
+				}
+				seen[key] = true
+				m.add(int(port), protocol, portOwnerApp, name, fmt.Sprintf("container port %v", mapping["container_port"]), true)
+			}
+		}
+
+		// Stopped apps claim their configured ports when started
+		config, _ := app["config"].(map[string]interface{})
+		configured := map[int]string{}
+		appConfigPorts(config, "", configured)
+		for port, path := range configured {
+			if seen[fmt.Sprintf("%d/tcp", port)] || seen[fmt.Sprintf("%d/udp", port)] {
+				continue
+			}
+			m.add(port, "tcp", portOwnerApp, name, "configured at "+path, running)
+		}
+	}
+}
+
+func (m *portMap) findVMPorts() {
+	vms, err := inventoryQuery(m.client, "vm.query", []interface{}{})
+	if err != nil {
+		m.notes = append(m.notes, fmt.Sprintf("VMs not checked: %v", err))
+		return
+	}
+	for _, vm := range vms {
+		name, _ := vm["name"].(string)
+		status, _ := vm["status"].(map[string]interface{})
+		running := status["state"] == "RUNNING"
+		devices, _ := vm["devices"].([]interface{})
+		for _, raw := range devices {
+			device, _ := raw.(map[string]interface{})
+			attributes, _ := device["attributes"].(map[string]interface{})
+			dtype, _ := device["dtype"].(string)
+			if dtype == "" {
+				dtype, _ = attributes["dtype"].(string)
+			}
+			if dtype != "DISPLAY" {
+				continue
+			}
+			display, _ := attributes["type"].(string)
+			if port, ok := attributes["port"].(float64); ok {
+				m.add(int(port), "tcp", portOwnerVM, name, fmt.Sprintf("%s display", display), running)
+			}
+			if port, ok := attributes["web_port"].(float64); ok {
+				m.add(int(port), "tcp", portOwnerVM, name, fmt.Sprintf("%s web display", display), running)
+			}
+		}
+	}
+}
+
+func handleGetPortUsage(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	ports := &portMap{client: client}
+	ports.findSystemPorts()
+	ports.findAppPorts()
+	ports.findVMPorts()
+
+	entries := ports.entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i]["port"].(int), entries[j]["port"].(int)
+		if a != b {
+			return a < b
+		}
+		return entries[i]["protocol"].(string) < entries[j]["protocol"].(string)
+	})
+
+	// Two owners of one port cannot both be running
+	byKey := map[string][]string{}
+	used := map[int]bool{}
+	for _, entry := range entries {
+		key := fmt.Sprintf("%d/%s", entry["port"], entry["protocol"])
+		owner := fmt.Sprintf("%s %s", entry["owner_type"], entry["owner"])
+		if !containsString(byKey[key], owner) {
+			byKey[key] = append(byKey[key], owner)
+		}
+		used[entry["port"].(int)] = true
+	}
+	conflicts := []map[string]interface{}{}
+	for _, key := range sortedStringKeys(byKey) {
+		if owners := byKey[key]; len(owners) > 1 {
+			conflicts = append(conflicts, map[string]interface{}{"port": key, "owners": owners})
+		}
+	}
+
+	response := map[string]interface{}{
+		"ports":     entries,
+		"count":     len(entries),
+		"conflicts": conflicts,
+	}
+
+	if p, ok := args["port"].(float64); ok {
+		port := int(p)
+		if port < 1 || port > 65535 {
+			return "", newToolError(ErrorValidation, "port must be between 1 and 65535 (got: %d)", port)
+		}
+		users := []map[string]interface{}{}
+		for _, entry := range entries {
+			if entry["port"] == port {
+				users = append(users, entry)
+			}
+		}
+		check := map[string]interface{}{"port": port, "available": len(users) == 0, "used_by": users}
+		if port < 1024 {
+			check["note"] = "Ports below 1024 are reserved for system services; apps should use higher ports"
+		}
+		response["check"] = check
+	}
+
+	count := 3
+	if c, ok := args["suggest"].(float64); ok && c >= 0 {
+		count = int(c)
+	}
+	start := appPortRangeStart
+	if s, ok := args["range_start"].(float64); ok && s > 0 {
+		start = int(s)
+	}
+	suggestions := []int{}
+	for port := start; port <= 65535 && len(suggestions) < count; port++ {
+		if !used[port] {
+			suggestions = append(suggestions, port)
+		}
+	}
+	response["suggested_free_ports"] = suggestions
+
+	if len(ports.notes) > 0 {
+		response["notes"] = ports.notes
+	}
+	return marshalJSON(response)
+}
+
+// sortedStringKeys returns the sorted keys of a map
+func sortedStringKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
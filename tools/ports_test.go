@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIntegrationPortUsage(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.general.config", map[string]interface{}{"ui_port": float64(80), "ui_httpsport": float64(443)})
+	server.SetRecords("service.query", []map[string]interface{}{
+		{"service": "ssh", "state": "RUNNING", "enable": true},
+		{"service": "cifs", "state": "STOPPED", "enable": true},
+		{"service": "ftp", "state": "STOPPED", "enable": false},
+	})
+	server.SetResult("ssh.config", map[string]interface{}{"tcpport": float64(2222)})
+	server.SetRecords("app.query", []map[string]interface{}{
+		{"name": "jellyfin", "state": "RUNNING",
+			"active_workloads": map[string]interface{}{"used_ports": []interface{}{
+				map[string]interface{}{"container_port": float64(8096), "protocol": "tcp", "host_ports": []interface{}{
+					map[string]interface{}{"host_port": float64(30013), "host_ip": "0.0.0.0"},
+					map[string]interface{}{"host_port": float64(30013), "host_ip": "::"},
+				}},
+			}},
+			"config": map[string]interface{}{"network": map[string]interface{}{
+				"web_port": map[string]interface{}{"bind_mode": "published", "port_number": float64(30013)},
+			}}},
+		{"name": "nextcloud", "state": "STOPPED",
+			"config": map[string]interface{}{"network": map[string]interface{}{
+				"web_port": map[string]interface{}{"bind_mode": "published", "port_number": float64(30001)},
+				"internal": map[string]interface{}{"bind_mode": "exposed", "port_number": float64(9000)},
+			}}},
+		{"name": "legacy", "state": "STOPPED",
+			"config": map[string]interface{}{"network": map[string]interface{}{"web_port": float64(30001)}}},
+	})
+	server.SetRecords("vm.query", []map[string]interface{}{
+		{"id": float64(1), "name": "win", "status": map[string]interface{}{"state": "RUNNING"}, "devices": []interface{}{
+			map[string]interface{}{"attributes": map[string]interface{}{"dtype": "DISPLAY", "type": "SPICE", "port": float64(5900), "web_port": float64(5901)}},
+		}},
+	})
+
+	result, err := registry.CallTool("get_port_usage", map[string]interface{}{"port": float64(30001), "suggest": float64(2)})
+	if err != nil {
+		t.Fatalf("get_port_usage failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	owners := map[string]bool{}
+	for _, raw := range response["ports"].([]interface{}) {
+		entry := raw.(map[string]interface{})
+		owners[fmt.Sprintf("%v/%v", entry["port"], entry["owner"])] = true
+	}
+	for _, want := range []string{"80/web_ui", "443/web_ui", "2222/ssh", "445/cifs", "30013/jellyfin", "30001/nextcloud", "30001/legacy", "5900/win", "5901/win"} {
+		if !owners[want] {
+			t.Errorf("port map lacks %s: %v", want, owners)
+		}
+	}
+	if owners["21/ftp"] || owners["9000/nextcloud"] || response["count"] != float64(10) {
+		t.Errorf("port map = %v, want no disabled services, exposed ports, or duplicates", owners)
+	}
+	conflicts := response["conflicts"].([]interface{})
+	if len(conflicts) != 1 || conflicts[0].(map[string]interface{})["port"] != "30001/tcp" {
+		t.Errorf("conflicts = %v, want nextcloud and legacy on 30001", conflicts)
+	}
+	if check := response["check"].(map[string]interface{}); check["available"] != false {
+		t.Errorf("check = %v, want 30001 taken", check)
+	}
+	if suggested := fmt.Sprint(response["suggested_free_ports"]); suggested != "[30000 30002]" {
+		t.Errorf("suggested_free_ports = %s", suggested)
+	}
+}
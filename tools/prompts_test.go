@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/prompts"
+)
+
+func TestPromptsReferenceRegisteredTools(t *testing.T) {
+	registry := NewRegistry(nil, nil, Options{})
+	for _, p := range registry.ListPrompts() {
+		prompt, err := prompts.Lookup(p.Name)
+		if err != nil {
+			t.Fatalf("Lookup(%s) failed: %v", p.Name, err)
+		}
+		for _, name := range prompt.Tools {
+			if _, ok := registry.tools[name]; !ok {
+				t.Errorf("prompt %s references unknown tool %s", p.Name, name)
+			}
+		}
+	}
+}
+
+func TestGetPrompt(t *testing.T) {
+	registry := NewRegistry(nil, nil, Options{ReadOnly: true})
+
+	result, err := registry.GetPrompt("plan_app_install", map[string]string{"app": "jellyfin"})
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	text := result.Messages[0].Content.Text
+	if !strings.Contains(text, `"jellyfin"`) || !strings.Contains(text, "disabled on this server and cannot be called: install_app") {
+		t.Errorf("prompt text = %q", text)
+	}
+
+	if _, err := registry.GetPrompt("plan_app_install", nil); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("missing argument error = %v, want VALIDATION_ERROR", err)
+	}
+	if _, err := registry.GetPrompt("nope", nil); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown prompt error = %v, want NOT_FOUND", err)
+	}
+}
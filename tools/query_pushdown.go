@@ -0,0 +1,207 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Server-side query paging
+//
+// The query_* tools hand filtering, ordering, and paging to the middleware
+// rather than fetching every record and trimming the list here, so a page of
+// 50 snapshots costs the same on a system with 50,000 of them as on one with
+// 500. Each page is fetched alongside a count of all matching records, which
+// the middleware answers without returning them.
+
+// queryPage is one page of a middleware query and the number of records
+// matching its filters
+type queryPage struct {
+	Records []map[string]interface{}
+	Total   int
+}
+
+// queryPagingArgs reads the limit and offset arguments of a query tool
+func queryPagingArgs(args map[string]interface{}, defaultLimit int) (limit, offset int) {
+	limit = defaultLimit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+	if o, ok := args["offset"].(float64); ok && o > 0 {
+		offset = int(o)
+	}
+	return limit, offset
+}
+
+// queryPaged fetches the records of method matching filters, sorted by
+// orderBy and paged by limit and offset on the middleware, together with the
+// total number of matching records. A limit of 0 only counts.
+func queryPaged(client truenas.Caller, method string, filters []interface{}, options map[string]interface{}, orderBy []string, limit, offset int) (queryPage, error) {
+	countCall := truenas.BatchCall{Method: method, Params: []interface{}{filters, truenas.WithQueryOptions(options, map[string]interface{}{"count": true})}}
+	if limit <= 0 {
+		results := client.CallConcurrently(countCall)
+		total, err := parseQueryCount(method, results[0])
+		return queryPage{Records: []map[string]interface{}{}, Total: total}, err
+	}
+
+	pageOptions := truenas.WithQueryOptions(options, map[string]interface{}{
+		"order_by": orderBy,
+		"offset":   offset,
+		"limit":    limit,
+	})
+	results := client.CallConcurrently(
+		truenas.BatchCall{Method: method, Params: []interface{}{filters, pageOptions}},
+		countCall,
+	)
+	if results[0].Err != nil {
+		return queryPage{}, results[0].Err
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(results[0].Result, &records); err != nil {
+		return queryPage{}, fmt.Errorf("failed to parse %s result: %w", method, err)
+	}
+	total, err := parseQueryCount(method, results[1])
+	if err != nil {
+		return queryPage{}, err
+	}
+	return queryPage{Records: records, Total: total}, nil
+}
+
+// parseQueryCount decodes the result of a count query
+func parseQueryCount(method string, result truenas.BatchResult) (int, error) {
+	if result.Err != nil {
+		return 0, result.Err
+	}
+	var total int
+	if err := json.Unmarshal(result.Result, &total); err != nil {
+		return 0, fmt.Errorf("failed to parse %s count: %w", method, err)
+	}
+	return total, nil
+}
+
+// queryPagedFirst pages through the records of method matching filters with
+// those whose field equals value listed first (running apps ahead of stopped
+// ones, say), each group sorted by orderBy. The middleware cannot order by
+// such a condition, so the groups are queried separately and the page is
+// stitched together from the end of the first and the start of the second.
+func queryPagedFirst(client truenas.Caller, method string, filters []interface{}, options map[string]interface{}, field string, value interface{}, orderBy []string, limit, offset int) (queryPage, error) {
+	withFilter := func(op string) []interface{} {
+		combined := make([]interface{}, 0, len(filters)+1)
+		combined = append(combined, filters...)
+		return append(combined, []interface{}{field, op, value})
+	}
+
+	head, err := queryPaged(client, method, withFilter("="), options, orderBy, limit, offset)
+	if err != nil {
+		return queryPage{}, err
+	}
+	restOffset := offset - head.Total
+	if restOffset < 0 {
+		restOffset = 0
+	}
+	tail, err := queryPaged(client, method, withFilter("!="), options, orderBy, limit-len(head.Records), restOffset)
+	if err != nil {
+		return queryPage{}, err
+	}
+	return queryPage{
+		Records: append(head.Records, tail.Records...),
+		Total:   head.Total + tail.Total,
+	}, nil
+}
+
+// Query cursors
+//
+// Every query_* tool pages the same way: a response that stops short of the
+// full result carries next_cursor, and passing it back as cursor returns the
+// following page. The cursor is self-contained (the tool, the filters of the
+// first call, and where the next page starts), so nothing is kept on the
+// server between pages. Filters passed alongside a cursor are ignored; limit
+// may change from page to page.
+
+// queryCursor is the decoded form of a next_cursor
+type queryCursor struct {
+	Tool   string                 `json:"t"`
+	Args   map[string]interface{} `json:"a,omitempty"`
+	Offset int                    `json:"o"`
+}
+
+// queryCursorPagingArgs are the arguments that position a page rather than
+// select records, and so are not carried in a cursor
+var queryCursorPagingArgs = map[string]bool{"cursor": true, "limit": true, "offset": true, "stream": true}
+
+// encodeQueryCursor returns the cursor for the page of tool starting at offset
+func encodeQueryCursor(tool string, args map[string]interface{}, offset int) string {
+	cursor := queryCursor{Tool: tool, Args: map[string]interface{}{}, Offset: offset}
+	for k, v := range args {
+		if !queryCursorPagingArgs[k] {
+			cursor.Args[k] = v
+		}
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// resolveQueryCursor returns the arguments to run a query tool with. Without a
+// cursor they are args unchanged; with one they are the filters recorded in
+// the cursor, the offset it points at, and the caller's limit.
+func resolveQueryCursor(tool string, args map[string]interface{}) (map[string]interface{}, error) {
+	text, _ := args["cursor"].(string)
+	if text == "" {
+		return args, nil
+	}
+	var cursor queryCursor
+	data, err := base64.RawURLEncoding.DecodeString(text)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil || cursor.Offset < 0 {
+		return nil, newToolError(ErrorValidation, "invalid cursor %q; pass next_cursor from the previous page", text)
+	}
+	if cursor.Tool != tool {
+		return nil, newToolError(ErrorValidation, "cursor belongs to %s, not %s", cursor.Tool, tool)
+	}
+
+	resolved := map[string]interface{}{"offset": float64(cursor.Offset)}
+	for k, v := range cursor.Args {
+		resolved[k] = v
+	}
+	if limit, ok := args["limit"]; ok {
+		resolved["limit"] = limit
+	}
+	return resolved, nil
+}
+
+// isStreamCursor reports whether a cursor points into a streamed result
+// buffer ("<id>:<offset>") rather than encoding a query
+func isStreamCursor(cursor string) bool {
+	return strings.Contains(cursor, ":")
+}
+
+// addPageInfo records where a page of tool's results sits in the full result:
+// its offset and, when more remain, next_cursor (and next_offset) for the
+// following page with a note saying so. noun names the records ("apps").
+func addPageInfo(response map[string]interface{}, tool, noun string, args map[string]interface{}, offset, count, total int) {
+	response["offset"] = offset
+	end := offset + count
+	if end < total {
+		response["next_cursor"] = encodeQueryCursor(tool, args, end)
+		response["next_offset"] = end
+		response["note"] = fmt.Sprintf("Showing %s %d-%d of %d; pass next_cursor as cursor to %s for the next page", noun, offset+1, end, total, tool)
+	}
+}
+
+// pageWindow returns the bounds of the page [offset, offset+limit) within n
+// records, clamped to the records that exist
+func pageWindow(n, offset, limit int) (start, end int) {
+	start, end = offset, offset+limit
+	if start > n {
+		start = n
+	}
+	if end > n {
+		end = n
+	}
+	return start, end
+}
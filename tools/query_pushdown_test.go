@@ -0,0 +1,308 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationQuerySharesFilters(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{
+		{"id": float64(1), "name": "media", "path": "/mnt/tank/media", "enabled": true, "readonly": false, "audit": map[string]interface{}{"enable": false}},
+		{"id": float64(2), "name": "media-archive", "path": "/mnt/tank/media-archive", "enabled": false},
+		{"id": float64(3), "name": "backups", "path": "/mnt/backup/pc", "enabled": true},
+	})
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{
+		{"id": float64(1), "path": "/mnt/tank/media/movies", "enabled": true, "ro": true, "networks": []interface{}{"10.0.0.0/24"}, "hosts": []interface{}{}},
+	})
+
+	result, err := registry.CallTool("query_shares", map[string]interface{}{"dataset": "tank/media"})
+	if err != nil {
+		t.Fatalf("query_shares failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	smb := response["smb_shares"].([]interface{})
+	if len(smb) != 1 || smb[0].(map[string]interface{})["name"] != "media" {
+		t.Errorf("dataset filter matched SMB shares %v, want only media (not media-archive)", smb)
+	}
+	if _, ok := smb[0].(map[string]interface{})["audit"]; ok {
+		t.Errorf("SMB share was not simplified: %v", smb[0])
+	}
+	nfs := response["nfs_shares"].([]interface{})
+	if len(nfs) != 1 || nfs[0].(map[string]interface{})["hosts"] != nil {
+		t.Errorf("NFS shares = %v, want the movies export without an empty hosts list", nfs)
+	}
+	counts := response["counts"].(map[string]interface{})
+	if smbCounts := counts["smb"].(map[string]interface{}); smbCounts["total"] != float64(3) || smbCounts["matching"] != float64(1) {
+		t.Errorf("SMB counts = %v", smbCounts)
+	}
+
+	result, err = registry.CallTool("query_shares", map[string]interface{}{"share_type": "smb", "path": "/mnt/tank/media", "enabled": false})
+	if err != nil {
+		t.Fatalf("query_shares failed: %v", err)
+	}
+	response = decodeResult(t, result)
+	if smb := response["smb_shares"].([]interface{}); len(smb) != 1 || smb[0].(map[string]interface{})["name"] != "media-archive" {
+		t.Errorf("path prefix and enabled filters matched %v, want media-archive", smb)
+	}
+	if _, ok := response["nfs_shares"]; ok {
+		t.Error("share_type smb returned NFS shares")
+	}
+}
+
+func TestIntegrationQueryAppsPaging(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("app.query", []map[string]interface{}{
+		{"name": "plex", "state": "STOPPED", "upgrade_available": true},
+		{"name": "immich", "state": "RUNNING", "upgrade_available": false},
+		{"name": "nextcloud", "state": "RUNNING", "upgrade_available": true},
+		{"name": "gitea", "state": "DEPLOYING", "upgrade_available": false},
+	})
+
+	names := func(response map[string]interface{}) []string {
+		list := []string{}
+		for _, raw := range response["apps"].([]interface{}) {
+			list = append(list, raw.(map[string]interface{})["name"].(string))
+		}
+		return list
+	}
+
+	result, err := registry.CallTool("query_apps", map[string]interface{}{"limit": float64(2)})
+	if err != nil {
+		t.Fatalf("query_apps failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if got := strings.Join(names(response), ","); got != "gitea,immich" {
+		t.Errorf("first page = %s, want gitea,immich", got)
+	}
+	if response["total_apps"] != float64(4) || response["next_offset"] != float64(2) {
+		t.Errorf("paging = total %v next %v", response["total_apps"], response["next_offset"])
+	}
+
+	result, err = registry.CallTool("query_apps", map[string]interface{}{"limit": float64(2), "offset": float64(2)})
+	if err != nil {
+		t.Fatalf("query_apps second page failed: %v", err)
+	}
+	response = decodeResult(t, result)
+	if got := strings.Join(names(response), ","); got != "nextcloud,plex" {
+		t.Errorf("second page = %s, want nextcloud,plex", got)
+	}
+	if _, ok := response["next_offset"]; ok {
+		t.Errorf("last page has next_offset %v", response["next_offset"])
+	}
+
+	result, err = registry.CallTool("query_apps", map[string]interface{}{"order_by": "state"})
+	if err != nil {
+		t.Fatalf("query_apps by state failed: %v", err)
+	}
+	if got := strings.Join(names(decodeResult(t, result)), ","); got != "immich,nextcloud,gitea,plex" {
+		t.Errorf("state order = %s", got)
+	}
+
+	result, err = registry.CallTool("query_apps", map[string]interface{}{"state": "RUNNING", "upgrade_available": true})
+	if err != nil {
+		t.Fatalf("query_apps filtered failed: %v", err)
+	}
+	if got := strings.Join(names(decodeResult(t, result)), ","); got != "nextcloud" {
+		t.Errorf("filtered apps = %s, want nextcloud", got)
+	}
+}
+
+func TestIntegrationQuerySnapshotsStream(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	snapshots := []map[string]interface{}{}
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("auto-2025-01-0%d_00-00", i+1)
+		snapshots = append(snapshots, map[string]interface{}{
+			"id": "tank/media@" + name, "snapshot_name": name, "dataset": "tank/media", "pool": "tank",
+		})
+	}
+	server.SetRecords("pool.snapshot.query", snapshots)
+
+	result, err := registry.CallTool("query_snapshots", map[string]interface{}{"limit": float64(2)})
+	if err != nil {
+		t.Fatalf("query_snapshots failed: %v", err)
+	}
+	if !strings.Contains(result, "Use stream=true") {
+		t.Errorf("truncated result does not mention stream:\n%s", result)
+	}
+
+	seen := 0
+	args := map[string]interface{}{"dataset": "tank/media", "limit": float64(2), "stream": true}
+	for page := 1; ; page++ {
+		result, err := registry.CallTool("query_snapshots", args)
+		if err != nil {
+			t.Fatalf("page %d failed: %v", page, err)
+		}
+		response := decodeResult(t, result)
+		if response["total_snapshots"] != float64(5) || response["dataset_filter"] != "tank/media" {
+			t.Errorf("page %d: total %v filter %v", page, response["total_snapshots"], response["dataset_filter"])
+		}
+		seen += len(response["snapshots"].([]interface{}))
+
+		// Later pages come from the buffer, not a new query
+		server.SetRecords("pool.snapshot.query", nil)
+		cursor, ok := response["next_cursor"].(string)
+		if !ok {
+			break
+		}
+		args = map[string]interface{}{"cursor": cursor, "limit": float64(2)}
+	}
+	if seen != 5 {
+		t.Errorf("streamed %d snapshots, want 5", seen)
+	}
+	// One page and its count, then a single streamed read
+	if n := len(server.Calls("pool.snapshot.query")); n != 3 {
+		t.Errorf("pool.snapshot.query called %d times, want 3", n)
+	}
+
+	for cursor, code := range map[string]ErrorCode{"garbage": ErrorValidation, "00000000-0000-0000-0000-000000000000:2": ErrorNotFound} {
+		if _, err := registry.CallTool("query_snapshots", map[string]interface{}{"cursor": cursor}); err == nil || ClassifyError(err).Code != code {
+			t.Errorf("cursor %s error = %v, want %s", cursor, err, code)
+		}
+	}
+}
+
+func TestIntegrationQueryPushdown(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	datasets := []map[string]interface{}{}
+	for i, used := range []float64{30, 10, 50, 20, 40} {
+		datasets = append(datasets, map[string]interface{}{
+			"name": fmt.Sprintf("tank/ds%d", i), "pool": "tank", "encrypted": i%2 == 0,
+			"used": map[string]interface{}{"parsed": used, "value": fmt.Sprintf("%.0f B", used)},
+		})
+	}
+	server.SetRecords("pool.dataset.query", datasets)
+
+	result, err := registry.CallTool("query_datasets", map[string]interface{}{"pool": "tank", "encrypted_only": true, "limit": float64(2)})
+	if err != nil {
+		t.Fatalf("query_datasets failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	names := []string{}
+	for _, raw := range response["datasets"].([]interface{}) {
+		names = append(names, raw.(map[string]interface{})["name"].(string))
+	}
+	if got := strings.Join(names, ","); got != "tank/ds2,tank/ds4" {
+		t.Errorf("datasets = %s, want tank/ds2,tank/ds4", got)
+	}
+	if response["total_datasets"] != float64(3) || response["next_offset"] != float64(2) {
+		t.Errorf("paging = total %v next %v", response["total_datasets"], response["next_offset"])
+	}
+
+	// The filters, sort, and page go to the middleware
+	calls := server.Calls("pool.dataset.query")
+	if len(calls) != 2 {
+		t.Fatalf("pool.dataset.query called %d times, want a page and a count", len(calls))
+	}
+	for _, call := range calls {
+		if filters := fmt.Sprint(call.Params[0]); !strings.Contains(filters, "[encrypted = true]") {
+			t.Errorf("filters = %s, want the encryption filter", filters)
+		}
+		options := call.Params[1].(map[string]interface{})
+		if options["count"] == true {
+			continue
+		}
+		if options["limit"] != float64(2) || fmt.Sprint(options["order_by"]) != "[-used.parsed name]" {
+			t.Errorf("page options = %v", options)
+		}
+	}
+
+	server.SetRecords("vm.query", []map[string]interface{}{
+		{"id": float64(1), "name": "alpha", "status": map[string]interface{}{"state": "STOPPED"}},
+		{"id": float64(2), "name": "bravo", "status": map[string]interface{}{"state": "RUNNING"}},
+		{"id": float64(3), "name": "Charlie", "status": map[string]interface{}{"state": "STOPPED"}},
+		{"id": float64(4), "name": "delta", "status": map[string]interface{}{"state": "RUNNING"}},
+	})
+	vmNames := func(args map[string]interface{}) string {
+		t.Helper()
+		result, err := registry.CallTool("query_vms", args)
+		if err != nil {
+			t.Fatalf("query_vms(%v) failed: %v", args, err)
+		}
+		list := []string{}
+		for _, raw := range decodeResult(t, result)["vms"].([]interface{}) {
+			list = append(list, raw.(map[string]interface{})["name"].(string))
+		}
+		return strings.Join(list, ",")
+	}
+
+	// Running VMs come first, and a page may span both groups
+	if got := vmNames(map[string]interface{}{"order_by": "status", "limit": float64(3)}); got != "bravo,delta,Charlie" {
+		t.Errorf("status order page 1 = %s", got)
+	}
+	if got := vmNames(map[string]interface{}{"order_by": "status", "limit": float64(3), "offset": float64(3)}); got != "alpha" {
+		t.Errorf("status order page 2 = %s", got)
+	}
+	if got := vmNames(map[string]interface{}{"name": "CHAR"}); got != "Charlie" {
+		t.Errorf("name filter = %s, want Charlie", got)
+	}
+	if got := vmNames(map[string]interface{}{"state": "RUNNING"}); got != "bravo,delta" {
+		t.Errorf("state filter = %s, want bravo,delta", got)
+	}
+}
+
+func TestIntegrationQueryCursors(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{
+		{"id": float64(1), "name": "media", "path": "/mnt/tank/media", "enabled": true},
+		{"id": float64(2), "name": "docs", "path": "/mnt/tank/docs", "enabled": true},
+		{"id": float64(3), "name": "old", "path": "/mnt/tank/old", "enabled": false},
+	})
+	server.SetRecords("sharing.nfs.query", []map[string]interface{}{
+		{"id": float64(1), "path": "/mnt/tank/media/movies", "enabled": true},
+		{"id": float64(2), "path": "/mnt/tank/backups", "enabled": true},
+	})
+
+	// Walk the enabled shares two at a time; the cursor keeps the filter
+	paths := []string{}
+	args := map[string]interface{}{"enabled": true, "limit": float64(2)}
+	for page := 1; page <= 5; page++ {
+		result, err := registry.CallTool("query_shares", args)
+		if err != nil {
+			t.Fatalf("page %d failed: %v", page, err)
+		}
+		response := decodeResult(t, result)
+		for _, key := range []string{"smb_shares", "nfs_shares"} {
+			for _, raw := range response[key].([]interface{}) {
+				paths = append(paths, raw.(map[string]interface{})["path"].(string))
+			}
+		}
+		cursor, ok := response["next_cursor"].(string)
+		if !ok {
+			break
+		}
+		args = map[string]interface{}{"cursor": cursor, "limit": float64(2)}
+	}
+	want := "/mnt/tank/docs,/mnt/tank/media,/mnt/tank/backups,/mnt/tank/media/movies"
+	if got := strings.Join(paths, ","); got != want {
+		t.Errorf("walked shares %s, want %s", got, want)
+	}
+
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60), testPool("backup", 10, 90)})
+	result, err := registry.CallTool("query_pools", map[string]interface{}{"limit": float64(1)})
+	if err != nil {
+		t.Fatalf("query_pools failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["total_pools"] != float64(2) || response["pools"].([]interface{})[0].(map[string]interface{})["name"] != "backup" {
+		t.Errorf("first pool page = %v", response)
+	}
+	cursor := response["next_cursor"].(string)
+	result, err = registry.CallTool("query_pools", map[string]interface{}{"cursor": cursor})
+	if err != nil {
+		t.Fatalf("query_pools second page failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["pools"].([]interface{})[0].(map[string]interface{})["name"] != "tank" || response["next_cursor"] != nil {
+		t.Errorf("second pool page = %v", response)
+	}
+
+	// A cursor only works with the tool that issued it
+	if _, err := registry.CallTool("query_datasets", map[string]interface{}{"cursor": cursor}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("foreign cursor error = %v, want VALIDATION", err)
+	}
+	if _, err := registry.CallTool("query_apps", map[string]interface{}{"cursor": "not a cursor"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("garbage cursor error = %v, want VALIDATION", err)
+	}
+}
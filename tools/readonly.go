@@ -0,0 +1,42 @@
+package tools
+
+// writeToolsWithoutDryRun lists tools that change TrueNAS state but have no
+// dry-run mode. Every tool that accepts dry_run is a write tool as well.
+var writeToolsWithoutDryRun = map[string]bool{
+	"system_reboot":              true,
+	"dismiss_alert":              true,
+	"restore_alert":              true,
+	"refresh_directory_cache":    true,
+	"undo_pending_deletion":      true,
+	"cancel_scheduled_operation": true,
+}
+
+// isWriteTool reports whether a tool changes TrueNAS state. Tools that only
+// record local state (inventory snapshots, baselines, caches) are not writes.
+func isWriteTool(name string, tool Tool) bool {
+	if writeToolsWithoutDryRun[name] {
+		return true
+	}
+	props, _ := tool.Definition.InputSchema["properties"].(map[string]interface{})
+	_, writes := props["dry_run"]
+	return writes
+}
+
+// disableWriteTools unregisters every write tool for read-only mode
+func (r *Registry) disableWriteTools() {
+	for name, tool := range r.tools {
+		if isWriteTool(name, tool) {
+			r.disableTool(name, "it changes TrueNAS and the server is running in read-only mode")
+		}
+	}
+}
+
+// disableTool unregisters a tool, remembering why so calls to it get a clear
+// refusal instead of "unknown tool"
+func (r *Registry) disableTool(name, reason string) {
+	if r.disabledTools == nil {
+		r.disabledTools = make(map[string]string)
+	}
+	r.disabledTools[name] = reason
+	delete(r.tools, name)
+}
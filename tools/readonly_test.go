@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationReadOnly(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	registry := NewRegistry(server.Client(t), nil, Options{ReadOnly: true})
+
+	for _, tool := range registry.ListTools() {
+		if isWriteTool(tool.Name, Tool{Definition: tool}) {
+			t.Errorf("read-only registry lists write tool %s", tool.Name)
+		}
+	}
+
+	if _, err := registry.CallTool("query_pools", map[string]interface{}{}); err != nil {
+		t.Errorf("query_pools failed in read-only mode: %v", err)
+	}
+	for _, name := range []string{"create_dataset", "system_reboot", "generate_health_digest"} {
+		_, err := registry.CallTool(name, map[string]interface{}{"name": "tank/new", "dry_run": true})
+		if toolErr := ClassifyError(err); toolErr.Code != ErrorPermissionDenied || !strings.Contains(toolErr.Message, "read-only") {
+			t.Errorf("%s error = %v, want PERMISSION_DENIED mentioning read-only mode", name, err)
+		}
+	}
+	if calls := server.Calls("pool.dataset.create"); len(calls) != 0 {
+		t.Errorf("read-only registry called pool.dataset.create: %v", calls)
+	}
+}
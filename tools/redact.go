@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/truenas/truenas-mcp/redact"
+)
+
+// Redaction applied to every tool response and error (see package redact)
+
+// redactedValue replaces masked credentials in tool output
+const redactedValue = redact.Masked
+
+// isSensitiveKey reports whether a field name denotes a credential
+func isSensitiveKey(key string) bool {
+	return redact.IsSensitiveKey(key)
+}
+
+// redactOutput masks credentials in a tool response, including any
+// credential the call was given (secrets) that the response echoes back
+func redactOutput(output string, secrets ...string) string {
+	return redact.JSON(output, secrets...)
+}
+
+// redactError masks credentials in the message and details of a tool error.
+// Middleware errors can quote the payload they rejected, so the call's own
+// credentials (secrets) are masked by value as well as by pattern.
+func redactError(err error, secrets ...string) error {
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) {
+		return err
+	}
+	toolErr.Message, _ = redact.String(toolErr.Message, secrets...)
+	if toolErr.Details != nil {
+		// Details are any JSON value; round-trip them to walk them
+		data, err := json.Marshal(toolErr.Details)
+		if err != nil {
+			toolErr.Details = nil
+			return toolErr
+		}
+		var details interface{}
+		if err := json.Unmarshal(data, &details); err != nil {
+			toolErr.Details = nil
+			return toolErr
+		}
+		toolErr.Details, _ = redact.Value(details, secrets...)
+	}
+	return toolErr
+}
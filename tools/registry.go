@@ -1,35 +1,181 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/capacity"
+	"github.com/truenas/truenas-mcp/catalog"
+	"github.com/truenas/truenas-mcp/compliance"
+	"github.com/truenas/truenas-mcp/deletion"
+	"github.com/truenas/truenas-mcp/digest"
+	"github.com/truenas/truenas-mcp/events"
+	"github.com/truenas/truenas-mcp/inventory"
 	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/netdata"
+	"github.com/truenas/truenas-mcp/redact"
+	"github.com/truenas/truenas-mcp/schedule"
 	"github.com/truenas/truenas-mcp/tasks"
 	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
 )
 
 type Registry struct {
-	client      *truenas.Client
-	taskManager *tasks.Manager
-	tools       map[string]Tool
+	client          truenas.Caller
+	taskManager     *tasks.Manager
+	capacityTracker *capacity.Tracker
+	catalogCache    *catalog.Cache
+	complianceStore *compliance.Store
+	deletionQueue   *deletion.Queue
+	digestScheduler *digest.Scheduler
+	eventWatcher    *events.Watcher
+	eventCache      *events.Cache
+	inventoryStore  *inventory.Store
+	maxResponse     int // Response size budget in bytes (0 = no limit)
+	netdata         *netdata.Client
+	scheduler       *schedule.Queue
+	serverVersion   string
+	readOnly        bool
+	timeouts        TimeoutConfig
+	updatePreflight UpdatePreflightPolicy
+	locks           *operationLocks
+	resultBuffers   *resultBuffers
+	tools           map[string]Tool
+	disabledTools   map[string]string // Tools removed by read-only mode or the tool filter, with the reason
+	sessionDefaults *sessionDefaults
+	resources       map[string]Resource
+
+	system        string               // System this registry serves when it is not the default one
+	defaultSystem string               // Name of the default system when others are configured
+	systems       map[string]*Registry // Registries of the other systems, by name
+}
+
+// Options configures optional registry subsystems
+type Options struct {
+	// CapacityTracker provides locally recorded pool usage history (nil = disabled)
+	CapacityTracker *capacity.Tracker
+
+	// CatalogCache keeps catalog app details between calls (nil = always fetch)
+	CatalogCache *catalog.Cache
+
+	// ComplianceStore keeps the saved desired-state baseline (nil = disabled)
+	ComplianceStore *compliance.Store
+
+	// DeletionQueue defers delete tools by a grace period (nil = delete immediately)
+	DeletionQueue *deletion.Queue
+
+	// DigestScheduler generates and stores health digests (nil = disabled)
+	DigestScheduler *digest.Scheduler
+
+	// EventCache serves list_alerts and query_jobs from event-fed memory
+	// (nil = always query the middleware)
+	EventCache *events.Cache
+
+	// EventWatcher buffers middleware alert and job events (nil = disabled)
+	EventWatcher *events.Watcher
+
+	// InventoryStore keeps saved inventory snapshots for drift comparison (nil = disabled)
+	InventoryStore *inventory.Store
+
+	// MaxResponseBytes caps the size of a tool response; longer ones are cut
+	// short and marked truncated (0 = no limit)
+	MaxResponseBytes int
+
+	// DefaultSystem names the system client connects to when Systems lists
+	// others (default "default")
+	DefaultSystem string
+
+	// Netdata proxies high-resolution chart queries (nil = disabled)
+	Netdata *netdata.Client
+
+	// ReadOnly registers only query tools; write tools are refused
+	ReadOnly bool
+
+	// Scheduler queues write tool calls for a later time or maintenance
+	// window (nil = disabled)
+	Scheduler *schedule.Queue
+
+	// ServerVersion is reported in exported tool catalogs
+	ServerVersion string
+
+	// Systems are further TrueNAS systems, by name, that tool calls can be
+	// directed at with the host argument (nil = only the default system)
+	Systems map[string]truenas.Caller
+
+	// Timeouts bounds handler execution time (nil = DefaultTimeoutConfig)
+	Timeouts *TimeoutConfig
+
+	// ToolFilter limits which tools are exposed (zero value = all tools)
+	ToolFilter ToolFilter
+
+	// UpdatePreflight sets how apply_update treats failed preflight checks
+	// (nil = DefaultUpdatePreflightPolicy)
+	UpdatePreflight UpdatePreflightPolicy
 }
 
 type Tool struct {
 	Definition mcp.Tool
-	Handler    func(*truenas.Client, map[string]interface{}) (string, error)
+	Handler    func(context.Context, truenas.Caller, map[string]interface{}) (string, error)
+
+	// SessionHandler replaces Handler for tools that keep per-session state
+	SessionHandler func(ctx context.Context, sessionID string, client truenas.Caller, args map[string]interface{}) (string, error)
 }
 
-func NewRegistry(client *truenas.Client, taskManager *tasks.Manager) *Registry {
+func NewRegistry(client truenas.Caller, taskManager *tasks.Manager, opts Options) *Registry {
 	r := &Registry{
-		client:      client,
-		taskManager: taskManager,
-		tools:       make(map[string]Tool),
+		client:          client,
+		taskManager:     taskManager,
+		capacityTracker: opts.CapacityTracker,
+		catalogCache:    opts.CatalogCache,
+		complianceStore: opts.ComplianceStore,
+		deletionQueue:   opts.DeletionQueue,
+		digestScheduler: opts.DigestScheduler,
+		eventWatcher:    opts.EventWatcher,
+		eventCache:      opts.EventCache,
+		inventoryStore:  opts.InventoryStore,
+		maxResponse:     opts.MaxResponseBytes,
+		netdata:         opts.Netdata,
+		scheduler:       opts.Scheduler,
+		serverVersion:   opts.ServerVersion,
+		readOnly:        opts.ReadOnly,
+		locks:           newOperationLocks(),
+		resultBuffers:   newResultBuffers(),
+		sessionDefaults: newSessionDefaults(),
+		tools:           make(map[string]Tool),
+		resources:       make(map[string]Resource),
+	}
+	if opts.Timeouts != nil {
+		r.timeouts = *opts.Timeouts
+	} else {
+		r.timeouts = DefaultTimeoutConfig()
+	}
+	if opts.UpdatePreflight != nil {
+		r.updatePreflight = opts.UpdatePreflight
+	} else {
+		r.updatePreflight = DefaultUpdatePreflightPolicy()
 	}
 	r.registerTools()
+	r.addResponseShapingArguments()
+	if r.scheduler != nil {
+		r.addScheduleArguments()
+		r.scheduler.SetExecutor(r.runScheduledOperation)
+	}
+	if len(opts.Systems) > 0 {
+		r.addSystems(taskManager, opts)
+	}
+	if opts.ReadOnly {
+		r.disableWriteTools()
+	}
+	r.applyToolFilter(opts.ToolFilter)
+	r.registerResources()
 	return r
 }
 
@@ -73,6 +219,28 @@ func (r *Registry) registerTools() {
 		Handler: handleCheckUpdates,
 	}
 
+	r.tools["get_update_changelog"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_update_changelog",
+			Description: "Get the release notes and changelog of a pending TrueNAS update so they can be reviewed before download_update/apply_update. Defaults to the first available version.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"version": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Version to show (from check_updates). Defaults to the first available version",
+					},
+					"include_manifest": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Include the full update manifest (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: handleGetUpdateChangelog,
+	}
+
 	r.tools["download_update"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "download_update",
@@ -94,7 +262,7 @@ func (r *Registry) registerTools() {
 	r.tools["apply_update"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "apply_update",
-			Description: "Apply downloaded TrueNAS system update. System will reboot if reboot parameter is true. Supports dry-run mode to preview changes. Returns a task ID for tracking progress. This is a write operation. **Best Practice**: After successful update and reboot, use query_boot_environments to check for old boot environments that can be safely pruned with delete_boot_environment. Recommend keeping 2-3 recent boot environments for rollback safety.",
+			Description: "Apply downloaded TrueNAS system update. System will reboot if reboot parameter is true. Supports dry-run mode to preview changes. Returns a task ID for tracking progress. This is a write operation. **Best Practice**: After successful update and reboot, use plan_boot_environment_cleanup to pick old boot environments that can be safely pruned with delete_boot_environment. Recommend keeping 2-3 recent boot environments for rollback safety.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -126,6 +294,103 @@ func (r *Registry) registerTools() {
 		Handler: handleUpdateStatus,
 	}
 
+	r.tools["get_inventory"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_inventory",
+			Description: "Compact snapshot of the whole system: hostname, version, hardware, pools with usage, dataset count, share names, apps, VMs, local users, and network interfaces. Small enough to keep in context for the rest of a conversation; call it first to orient, then use the detailed tools. Also available as the truenas://inventory resource.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetInventory,
+	}
+
+	r.tools["save_inventory_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "save_inventory_snapshot",
+			Description: "Record the current configuration (pools, every dataset, SMB and NFS shares, apps with versions, VMs, local users, interface addresses, and TrueNAS version) as a saved snapshot. Take one before a maintenance window or upgrade so diff_inventory can show exactly what changed afterwards.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"label": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional name to refer to the snapshot by, e.g. 'pre-upgrade'",
+					},
+				},
+			},
+		},
+		Handler: r.handleSaveInventorySnapshot,
+	}
+
+	r.tools["list_inventory_snapshots"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_inventory_snapshots",
+			Description: "List saved inventory snapshots, newest first, with their IDs, labels, and when they were taken.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: r.handleListInventorySnapshots,
+	}
+
+	r.tools["diff_inventory"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "diff_inventory",
+			Description: "Report configuration drift between two inventory snapshots, or a snapshot and the live system: datasets, shares, pools, VMs, users, and interfaces added or removed, app installs, removals, and version changes, and TrueNAS version changes. Use for change review after maintenance and for post-incident analysis ('what changed since last week?').",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"from": map[string]interface{}{
+						"type":        "string",
+						"description": "Snapshot ID or label to compare from (default: the most recent snapshot)",
+					},
+					"to": map[string]interface{}{
+						"type":        "string",
+						"description": "Snapshot ID or label to compare to, or 'live' for the current system (default: live)",
+					},
+				},
+			},
+		},
+		Handler: r.handleDiffInventory,
+	}
+
+	r.tools["get_crash_report"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_crash_report",
+			Description: "Report recent process crashes from system core dumps, grouped by process, with middlewared crashes called out and the last boot time for context. Use to answer stability questions ('has anything been crashing?') with evidence.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"days": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: How many days back to report (default: 7)",
+						"default":     7,
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum crashes to list (default: 20)",
+						"default":     20,
+					},
+				},
+			},
+		},
+		Handler: handleGetCrashReport,
+	}
+
+	r.tools["get_pending_actions"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_pending_actions",
+			Description: "Report follow-up actions the system is waiting on: whether a reboot is required (e.g. after an update), services that need a restart or start after configuration changes, and pools with ZFS feature flag upgrades available. Use to decide next steps after updates or configuration changes.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetPendingActions,
+	}
+
 	// System reboot tool
 	r.tools["system_reboot"] = Tool{
 		Definition: mcp.Tool{
@@ -139,6 +404,187 @@ func (r *Registry) registerTools() {
 		Handler: handleSystemReboot,
 	}
 
+	// Wake-on-LAN for other hosts on the NAS's network
+	r.tools["wake_host"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "wake_host",
+			Description: "Wake a powered-down machine on the NAS's network with Wake-on-LAN, e.g. a backup target before replication. The NAS sends the magic packets, so the target must be on the same layer-2 network as one of its interfaces. The middleware has no Wake-on-LAN API, so this creates a temporary, disabled cron job (cronjob.create) that runs a python3 one-liner as root, runs it once, and deletes it again, also when sending fails; the API key needs permission to manage cron jobs. With several interfaces, pass interface, host_ip (picks the interface on the host's network), or broadcast. Sending does not confirm the host woke; it usually needs 10-60 seconds to boot. Whether the target supports Wake-on-LAN cannot be checked: the setting lives on its network card, which the NAS cannot see while it is off. Use dry_run=true to list the NAS interfaces that can send (IPv4 address and link not down) and the broadcast address that would be used.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"mac": map[string]interface{}{
+						"type":        "string",
+						"description": "MAC address of the machine to wake (e.g., 00:11:22:33:44:55)",
+					},
+					"interface": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: NAS interface to send from (e.g., enp1s0)",
+					},
+					"host_ip": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: IPv4 address the machine has when awake; selects the NAS interface on the same network",
+					},
+					"broadcast": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: IPv4 broadcast address to send to (default: the chosen interface's broadcast address)",
+					},
+					"port": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: UDP port (default: 9; some machines listen on 7)",
+						"default":     9,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Show the interfaces and target address without sending (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"mac"},
+			},
+		},
+		Handler: handleWakeHostWithDryRun,
+	}
+
+	// Login banner and message of the day
+	r.tools["get_system_banners"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_system_banners",
+			Description: "Get the message of the day (shown after SSH/console login) and the login banner (shown before login on the web UI and SSH), e.g. to check compliance notices are in place.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetSystemBanners,
+	}
+
+	r.tools["set_system_banners"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "set_system_banners",
+			Description: "Set the message of the day and/or the login banner, e.g. an authorized-use notice. Only the messages given are changed; an empty string clears one. A login banner must be acknowledged by web UI users before they log in. Use dry_run=true to compare the old and new text.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"motd": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Message of the day shown after SSH/console login (empty string clears it)",
+					},
+					"login_banner": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Banner shown before login on the web UI and SSH, up to 4096 characters (empty string clears it; TrueNAS SCALE 24.04 or later)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without updating (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: handleSetSystemBannersWithDryRun,
+	}
+
+	// System dataset location
+	r.tools["get_system_dataset"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_system_dataset",
+			Description: "Show which pool holds the system dataset (system logs, reporting history, SMB state, configuration backups), how much space it uses, and the pools it can be moved to.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetSystemDataset,
+	}
+
+	r.tools["update_system_dataset"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_system_dataset",
+			Description: "Move the system dataset to another pool, e.g. off a pool that is about to be exported or off the boot device. Runs as a job and returns a task_id for tracking. SMB is restarted during the move. Use dry_run=true to preview the migration: what is copied, its size, and the services affected.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool to move the system dataset to (from get_system_dataset pool_choices)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without moving (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"pool"},
+			},
+		},
+		Handler: r.handleUpdateSystemDatasetWithDryRun,
+	}
+
+	// Swap, syslog, console, and kernel settings
+	r.tools["get_advanced_settings"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_advanced_settings",
+			Description: "Get advanced system settings: swap partition size for new disks, syslog level and remote server, console menu and serial console, and extra kernel options.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetAdvancedSettings,
+	}
+
+	r.tools["update_advanced_settings"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_advanced_settings",
+			Description: "Change advanced system settings. Only the fields given are changed. Serial console and kernel changes take effect at the next reboot, and bad kernel options can stop the NAS from booting; use dry_run=true to compare old and new values first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"swap_size_gib": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Swap partition size in GiB created on disks added to pools from now on (0 for none)",
+					},
+					"syslog_level": map[string]interface{}{
+						"type":        "string",
+						"enum":        syslogLevels,
+						"description": "Optional: Lowest severity written to the system log",
+					},
+					"console_menu": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Show the setup menu on the local console instead of a login prompt",
+					},
+					"serial_console": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Enable the serial console",
+					},
+					"serial_port": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Serial console port (e.g. 'ttyS0')",
+					},
+					"serial_speed": map[string]interface{}{
+						"type":        "string",
+						"enum":        serialSpeeds,
+						"description": "Optional: Serial console baud rate",
+					},
+					"kernel_extra_options": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Extra kernel command line options (empty string clears them)",
+					},
+					"kernel_debug": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Enable kernel debugging",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without updating (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: handleUpdateAdvancedSettingsWithDryRun,
+	}
+
 	// Boot environment management tools
 	r.tools["query_boot_environments"] = Tool{
 		Definition: mcp.Tool{
@@ -197,6 +643,28 @@ func (r *Registry) registerTools() {
 		Handler: r.handleDeleteBootEnvironmentWithDryRun,
 	}
 
+	r.tools["plan_boot_environment_cleanup"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "plan_boot_environment_cleanup",
+			Description: "Plan which boot environments to delete, oldest first, to reach a number to keep or an amount of space to free. Active, activated, and protected environments are never selected. Returns ordered delete_boot_environment steps ready to run. Read-only: nothing is deleted.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"keep": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Minimum number of boot environments to keep, including active ones (default: 3)",
+						"default":     defaultBootEnvironmentsToKeep,
+					},
+					"free_space": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Stop once this much space would be freed, in bytes or human-readable (e.g., '5G'). Without it, deletes down to the keep count",
+					},
+				},
+			},
+		},
+		Handler: handlePlanBootEnvironmentCleanup,
+	}
+
 	r.tools["get_current_boot_environment"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "get_current_boot_environment",
@@ -339,681 +807,3860 @@ func (r *Registry) registerTools() {
 		Handler: r.handleRunScrubWithDryRun,
 	}
 
-	r.tools["delete_scrub_schedule"] = Tool{
+	r.tools["update_scrub_schedule"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "delete_scrub_schedule",
-			Description: "Remove a scrub schedule. **IMPORTANT**: Pool will no longer have automatic scrubbing. Recommend running manual scrubs monthly if schedule is deleted. Consider updating schedule instead of deleting.",
+			Name:        "update_scrub_schedule",
+			Description: "Change an existing scrub schedule in place: cron timing, threshold, enabled flag, or description. Only the fields given are changed; cron fields not given keep their current values. Use dry-run to compare the old and new next-run times before applying.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"id": map[string]interface{}{
 						"type":        "integer",
-						"description": "Required: Schedule ID to delete (from query_scrub_schedules)",
+						"description": "Required: Schedule ID to update (from query_scrub_schedules)",
+					},
+					"schedule": map[string]interface{}{
+						"type":        "object",
+						"description": "Optional: Cron fields to change (e.g., {hour: '3', dow: '6'} for Saturday 3am)",
+						"properties": map[string]interface{}{
+							"minute": map[string]interface{}{"type": "string"},
+							"hour":   map[string]interface{}{"type": "string"},
+							"dom":    map[string]interface{}{"type": "string"},
+							"month":  map[string]interface{}{"type": "string"},
+							"dow":    map[string]interface{}{"type": "string"},
+						},
+					},
+					"threshold": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Days between scrubs",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Human-readable description",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Enable or disable the schedule",
 					},
 					"dry_run": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Optional: Preview without deleting (default: false)",
+						"description": "Optional: Preview without updating (default: false)",
 						"default":     false,
 					},
 				},
 				"required": []string{"id"},
 			},
 		},
-		Handler: r.handleDeleteScrubScheduleWithDryRun,
+		Handler: r.handleUpdateScrubScheduleWithDryRun,
 	}
 
-	// Directory Services
-	r.tools["get_directory_service_status"] = Tool{
+	r.tools["delete_scrub_schedule"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "get_directory_service_status",
-			Description: "Get current directory service status and health. Returns service type (ACTIVEDIRECTORY, IPA, LDAP), status (DISABLED, HEALTHY, FAULTED, JOINING, LEAVING), and error messages if any. Use for quick health checks.",
+			Name:        "delete_scrub_schedule",
+			Description: "Remove a scrub schedule. **IMPORTANT**: Pool will no longer have automatic scrubbing. Recommend running manual scrubs monthly if schedule is deleted. Consider updating schedule instead of deleting.",
 			InputSchema: map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Schedule ID to delete (from query_scrub_schedules)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without deleting (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
 			},
 		},
-		Handler: handleGetDirectoryServiceStatus,
+		Handler: r.handleDeleteScrubScheduleWithDryRun,
 	}
 
-	r.tools["query_directory_services"] = Tool{
+	// Periodic snapshot tasks
+	snapshotTaskCron := map[string]interface{}{
+		"minute": map[string]interface{}{"type": "string"},
+		"hour":   map[string]interface{}{"type": "string"},
+		"dom":    map[string]interface{}{"type": "string"},
+		"month":  map[string]interface{}{"type": "string"},
+		"dow":    map[string]interface{}{"type": "string"},
+		"begin": map[string]interface{}{
+			"type":        "string",
+			"description": "HH:MM; hourly tasks run only from this time (default: 00:00)",
+		},
+		"end": map[string]interface{}{
+			"type":        "string",
+			"description": "HH:MM; hourly tasks run only until this time (default: 23:59)",
+		},
+	}
+	snapshotTaskProperties := func(required bool) map[string]interface{} {
+		prefix := "Optional: "
+		if required {
+			prefix = "Required: "
+		}
+		return map[string]interface{}{
+			"schedule": map[string]interface{}{
+				"type":        "object",
+				"description": prefix + "Cron schedule (e.g., {minute: '0', hour: '*'} for hourly, {minute: '0', hour: '0'} for daily at midnight)",
+				"properties":  snapshotTaskCron,
+			},
+			"lifetime_value": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: How many lifetime_units to keep each snapshot (default: 2)",
+			},
+			"lifetime_unit": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
+				"description": "Optional: Retention unit (default: WEEK)",
+			},
+			"recursive": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Also snapshot child datasets (default: false)",
+			},
+			"exclude": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional: Child datasets to skip in a recursive task",
+			},
+			"naming_schema": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: strftime snapshot name; must contain %Y %m %d %H %M (default: auto-%Y-%m-%d_%H-%M)",
+			},
+			"allow_empty": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Take snapshots even when no data changed (default: true)",
+			},
+			"enabled": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Enable or disable the task (default: true)",
+			},
+		}
+	}
+
+	r.tools["query_snapshot_tasks"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "query_directory_services",
-			Description: "Query full directory service configuration. Returns service type, enabled status, credentials (masked for security), and service-specific settings. All passwords and keytabs are masked in output.",
+			Name:        "query_snapshot_tasks",
+			Description: "Query periodic snapshot tasks with human-readable schedules, next run time, retention policy, and the approximate number of snapshots each task keeps. Use this to answer questions like 'how often is tank/data snapshotted?' or 'how long are snapshots kept?'",
 			InputSchema: map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Show tasks for this dataset and its children",
+					},
+					"enabled_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Show only enabled tasks (default: false)",
+					},
+				},
 			},
 		},
-		Handler: handleQueryDirectoryServices,
+		Handler: handleQuerySnapshotTasks,
 	}
 
-	r.tools["list_directory_certificates"] = Tool{
+	createSnapshotTaskProps := snapshotTaskProperties(true)
+	createSnapshotTaskProps["dataset"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Required: Dataset to snapshot (e.g., 'tank/data')",
+	}
+	createSnapshotTaskProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without creating (default: false)",
+		"default":     false,
+	}
+	r.tools["create_snapshot_task"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "list_directory_certificates",
-			Description: "List available certificates for LDAP MTLS authentication. Returns certificate IDs and names that can be used with LDAP_MTLS credential type.",
+			Name:        "create_snapshot_task",
+			Description: "Create a periodic snapshot task that snapshots a dataset on a cron schedule and destroys its snapshots once they exceed the retention lifetime. **Best practices**: hourly snapshots kept 2 weeks plus daily snapshots kept a few months cover most recovery needs; keep the snapshot count per dataset in the low thousands at most.",
 			InputSchema: map[string]interface{}{
 				"type":       "object",
-				"properties": map[string]interface{}{},
+				"properties": createSnapshotTaskProps,
+				"required":   []string{"dataset", "schedule"},
 			},
 		},
-		Handler: handleListDirectoryCertificates,
+		Handler: handleCreateSnapshotTaskWithDryRun,
 	}
 
-	r.tools["refresh_directory_cache"] = Tool{
+	updateSnapshotTaskProps := snapshotTaskProperties(false)
+	updateSnapshotTaskProps["id"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Required: Task ID to update (from query_snapshot_tasks)",
+	}
+	updateSnapshotTaskProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without updating (default: false)",
+		"default":     false,
+	}
+	r.tools["update_snapshot_task"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "refresh_directory_cache",
-			Description: "Refresh cached user and group data from the directory service. Use after making changes in Active Directory, LDAP, or IPA that need to be reflected immediately in TrueNAS.",
+			Name:        "update_snapshot_task",
+			Description: "Change a periodic snapshot task in place: schedule, retention, naming schema, recursion, or enabled flag. Only the fields given are changed; cron fields not given keep their current values. **IMPORTANT**: shortening retention destroys older snapshots on the next run. Use dry-run to compare before applying.",
 			InputSchema: map[string]interface{}{
 				"type":       "object",
-				"properties": map[string]interface{}{},
+				"properties": updateSnapshotTaskProps,
+				"required":   []string{"id"},
 			},
 		},
-		Handler: handleRefreshDirectoryCache,
+		Handler: handleUpdateSnapshotTaskWithDryRun,
 	}
 
-	r.tools["configure_directory_service"] = Tool{
+	r.tools["delete_snapshot_task"] = Tool{
 		Definition: mcp.Tool{
-			Name: "configure_directory_service",
-			Description: `Configure and join a directory service (Active Directory, LDAP, or IPA). Setting enable=true joins the domain automatically.
-
-**Service Types:**
-- ACTIVEDIRECTORY: Microsoft Active Directory integration
-- LDAP: Generic LDAP server (OpenLDAP, etc.)
-- IPA: FreeIPA / Red Hat Identity Management
-
-**Credential Types by Service:**
-
-Active Directory:
-- KERBEROS_USER: {type: "KERBEROS_USER", username: "admin", password: "pass"}
-- KERBEROS_PRINCIPAL: {type: "KERBEROS_PRINCIPAL", principal: "host/truenas", keytab: "..."}
-
-LDAP:
-- LDAP_PLAIN: {type: "LDAP_PLAIN", binddn: "cn=admin,dc=example,dc=com", bindpw: "pass"}
-- LDAP_ANONYMOUS: {type: "LDAP_ANONYMOUS"}
-- LDAP_MTLS: {type: "LDAP_MTLS", certificate_id: 123}
-- KERBEROS_USER or KERBEROS_PRINCIPAL (same as Active Directory)
-
-IPA:
-- KERBEROS_USER or KERBEROS_PRINCIPAL (same as Active Directory)
-
-**Configuration Object (service-specific):**
-For Active Directory: {hostname: "truenas-nyc", domain: "corp.example.com", ...}
-For LDAP: {hostname: "ldap.example.com", port: 389, ...}
-For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
-
-**Security:**
-- Credentials are stored in TrueNAS configuration
-- Use Kerberos principals with keytabs instead of passwords for production
-- Dry-run shows credential requirements without exposing values
-
-**Returns:** task_id for tracking long-running domain join operation (2-10 minutes typical)`,
+			Name:        "delete_snapshot_task",
+			Description: "Remove a periodic snapshot task. The dataset is no longer snapshotted automatically; snapshots the task already took are kept and no longer expire. Consider disabling with update_snapshot_task instead.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"service_type": map[string]interface{}{
-						"type":        "string",
-						"enum":        []string{"ACTIVEDIRECTORY", "LDAP", "IPA"},
-						"description": "Directory service type",
-					},
-					"enable": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Enable service (true to join domain, false to disable)",
-					},
-					"credential": map[string]interface{}{
-						"type":        "object",
-						"description": "Credential object with 'type' field and credential-specific fields (see tool description)",
-					},
-					"configuration": map[string]interface{}{
-						"type":        "object",
-						"description": "Service-specific configuration (domain, hostname, etc.)",
-					},
-					"enable_account_cache": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Cache user/group lists (default: true)",
-						"default":     true,
-					},
-					"enable_dns_updates": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Auto DNS updates via nsupdate (default: true)",
-						"default":     true,
-					},
-					"timeout": map[string]interface{}{
+					"id": map[string]interface{}{
 						"type":        "integer",
-						"description": "DNS query and LDAP request timeout in seconds (5-60, default: 10)",
-						"default":     10,
-					},
-					"kerberos_realm": map[string]interface{}{
-						"type":        "string",
-						"description": "Kerberos realm for authentication (optional)",
+						"description": "Required: Task ID to delete (from query_snapshot_tasks)",
 					},
 					"dry_run": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Preview configuration without executing (default: false)",
+						"description": "Optional: Preview without deleting (default: false)",
 						"default":     false,
 					},
 				},
-				"required": []string{"service_type", "enable", "credential"},
+				"required": []string{"id"},
 			},
 		},
-		Handler: r.handleConfigureDirectoryServiceWithDryRun,
+		Handler: handleDeleteSnapshotTaskWithDryRun,
 	}
 
-	r.tools["leave_directory_service"] = Tool{
+	// Replication
+	r.tools["query_replication_tasks"] = Tool{
 		Definition: mcp.Tool{
-			Name: "leave_directory_service",
-			Description: `Disconnect from directory service and leave the domain.
-
-**WARNING:** This is a destructive operation:
-- Removes TrueNAS from the domain
-- Deletes computer account (if possible)
-- Clears all cached user/group data
-- All domain user authentication will stop working
-- SMB/NFS shares configured with domain users will become inaccessible
-
-**Alternative:** Use configure_directory_service with enable=false for temporary disable without leaving the domain.
-
-**Returns:** task_id for tracking the leave operation (30 seconds to 5 minutes typical)`,
+			Name:        "query_replication_tasks",
+			Description: "Query replication tasks with direction, transport, source and target datasets, human-readable schedule, retention on the target, and last-run status. Use this to answer questions like 'is tank/data backed up anywhere?'",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"dry_run": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Show tasks replicating from or to this dataset or its children",
+					},
+					"enabled_only": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Preview operation without executing (default: false, STRONGLY RECOMMENDED to use dry_run first)",
-						"default":     false,
+						"description": "Optional: Show only enabled tasks (default: false)",
 					},
 				},
 			},
 		},
-		Handler: r.handleLeaveDirectoryServiceWithDryRun,
+		Handler: handleQueryReplicationTasks,
 	}
 
-	// Storage pools query
-	r.tools["query_pools"] = Tool{
+	r.tools["get_replication_status"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "query_pools",
-			Description: "Query storage pools with their status, capacity, and health information",
+			Name:        "get_replication_status",
+			Description: "Report the last-run status of replication tasks: state (FINISHED, RUNNING, ERROR, ...), when it ran, the last snapshot sent, errors, and progress of running replications. Summarizes failed and never-run tasks.",
 			InputSchema: map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Replication task ID (omit for all tasks)",
+					},
+				},
 			},
 		},
-		Handler: handleQueryPools,
+		Handler: handleGetReplicationStatus,
 	}
 
-	// Dataset query
-	r.tools["query_datasets"] = Tool{
+	r.tools["create_replication_task"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "query_datasets",
-			Description: "Query datasets with optional filtering and sorting. Returns simplified dataset information with capacity, encryption status, and usage details. Use 'limit' to control result size, 'order_by' to sort by size, and 'encrypted_only' to filter.",
+			Name:        "create_replication_task",
+			Description: "Create a replication task that copies dataset snapshots to another pool (LOCAL) or another system over SSH (PUSH), or from another system to this one (PULL). Push tasks send the snapshots of periodic snapshot tasks; if none are given, the tasks covering the source datasets are used. **Use dry_run=true first** to review source, target, schedule, and retention.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"pool": map[string]interface{}{
+					"name": map[string]interface{}{
 						"type":        "string",
-						"description": "Optional: Filter datasets by pool name",
+						"description": "Required: Task name",
 					},
-					"limit": map[string]interface{}{
+					"source_datasets": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Required: Datasets to replicate (local for PUSH, remote for PULL)",
+					},
+					"target_dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Dataset to replicate into (remote for PUSH over SSH, local for PULL and LOCAL)",
+					},
+					"direction": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"PUSH", "PULL"},
+						"description": "Optional: PUSH sends from this system, PULL fetches from a remote one (default: PUSH)",
+					},
+					"transport": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"SSH", "SSH+NETCAT", "LOCAL"},
+						"description": "Optional: SSH to another system, or LOCAL for another pool on this system (default: SSH)",
+					},
+					"ssh_credentials": map[string]interface{}{
 						"type":        "integer",
-						"description": "Optional: Maximum number of datasets to return (default: 50 for manageable response size)",
+						"description": "Optional: SSH connection ID (keychain credential); required unless transport is LOCAL",
 					},
-					"order_by": map[string]interface{}{
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Also replicate child datasets (default: false)",
+					},
+					"periodic_snapshot_tasks": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "integer"},
+						"description": "Optional: PUSH only - periodic snapshot task IDs whose snapshots are sent (from query_snapshot_tasks)",
+					},
+					"naming_schema": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Snapshot naming schemas to replicate; required for PULL (e.g., ['auto-%Y-%m-%d_%H-%M'])",
+					},
+					"schedule": map[string]interface{}{
+						"type":        "object",
+						"description": "Optional: Cron schedule to run on; without it, push tasks run after their periodic snapshot tasks",
+						"properties":  snapshotTaskCron,
+					},
+					"retention_policy": map[string]interface{}{
 						"type":        "string",
-						"description": "Optional: Sort by 'used' (space usage), 'available', or 'name' (default: used descending)",
-						"enum":        []string{"used", "available", "name"},
+						"enum":        []string{"SOURCE", "CUSTOM", "NONE"},
+						"description": "Optional: How long snapshots stay on the target: SOURCE mirrors the source, CUSTOM uses lifetime_value/lifetime_unit, NONE keeps them forever (default: SOURCE)",
 					},
-					"encrypted_only": map[string]interface{}{
+					"lifetime_value": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: With retention_policy CUSTOM, how many lifetime_units to keep snapshots on the target",
+					},
+					"lifetime_unit": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
+						"description": "Optional: With retention_policy CUSTOM, the retention unit",
+					},
+					"enabled": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Optional: Return only encrypted datasets (default: false)",
+						"description": "Optional: Enable the task (default: true)",
+					},
+					"skip_space_check": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Skip checking that a full send fits at the target (default: false)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without creating (default: false)",
+						"default":     false,
 					},
 				},
+				"required": []string{"name", "source_datasets", "target_dataset"},
 			},
 		},
-		Handler: handleQueryDatasets,
+		Handler: handleCreateReplicationTaskWithDryRun,
 	}
 
-	// Snapshots query
-	r.tools["query_snapshots"] = Tool{
+	r.tools["run_replication"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "query_snapshots",
-			Description: "Query ZFS snapshots with optional filtering and sorting. Returns simplified snapshot information with creation info, dataset, and holds status. Use 'limit' to control result size, 'order_by' to sort.",
+			Name:        "run_replication",
+			Description: "Run a replication task now instead of waiting for its schedule. Returns a task_id for progress tracking. The first run of a task sends a full copy and can take hours; it is refused when the estimated size does not fit at a local target.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"dataset": map[string]interface{}{
-						"type":        "string",
-						"description": "Optional: Filter snapshots by parent dataset name",
-					},
-					"pool": map[string]interface{}{
-						"type":        "string",
-						"description": "Optional: Filter snapshots by pool name",
-					},
-					"limit": map[string]interface{}{
+					"id": map[string]interface{}{
 						"type":        "integer",
-						"description": "Optional: Maximum number of snapshots to return (default: 50 for manageable response size)",
+						"description": "Required: Replication task ID (from query_replication_tasks)",
 					},
-					"order_by": map[string]interface{}{
-						"type":        "string",
-						"description": "Optional: Sort by 'name' (snapshot name, default descending), 'dataset' (parent dataset), or 'created' (parsed from name if available)",
-						"enum":        []string{"name", "dataset", "created"},
+					"skip_space_check": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Skip checking that a first, full send fits at the target (default: false)",
 					},
-					"holds_only": map[string]interface{}{
+					"dry_run": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Optional: Return only snapshots with holds that prevent deletion (default: false)",
+						"description": "Optional: Preview without starting (default: false)",
+						"default":     false,
 					},
 				},
+				"required": []string{"id"},
 			},
 		},
-		Handler: handleQuerySnapshots,
+		Handler: r.handleRunReplicationWithDryRun,
 	}
 
-	// Shares query
-	r.tools["query_shares"] = Tool{
+	// Cloud sync
+	r.tools["list_cloud_credentials"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "query_shares",
-			Description: "Query SMB and NFS shares configuration",
+			Name:        "list_cloud_credentials",
+			Description: "List cloud credentials (S3, B2, Azure, etc.) used by cloud sync tasks. Secrets are never returned; each credential shows its provider, endpoint/region when set, and which fields are configured.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"share_type": map[string]interface{}{
+					"provider": map[string]interface{}{
 						"type":        "string",
-						"enum":        []string{"smb", "nfs", "all"},
-						"description": "Type of shares to query (default: all)",
-						"default":     "all",
+						"description": "Optional: Only show credentials for this provider (e.g., S3, B2)",
 					},
 				},
 			},
 		},
-		Handler: handleQueryShares,
+		Handler: handleListCloudCredentials,
 	}
 
-	// VM query
-	r.tools["query_vms"] = Tool{
+	r.tools["query_cloud_sync_tasks"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "query_vms",
-			Description: "Query virtual machines with optional filtering and sorting. Returns simplified VM information with resource allocation, status, and device summary. Excludes sensitive data like display passwords.",
+			Name:        "query_cloud_sync_tasks",
+			Description: "List cloud sync tasks with their path, bucket, direction, transfer mode, schedule, next run, and last run status. Use for checking offsite backups to S3, B2, and other cloud providers.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{
-						"type":        "string",
-						"description": "Optional: Filter VMs by name (partial match)",
+					"enabled_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Only show enabled tasks (default: false)",
 					},
-					"state": map[string]interface{}{
-						"type":        "string",
+					"failed_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Only show tasks whose last run failed (default: false)",
+					},
+				},
+			},
+		},
+		Handler: handleQueryCloudSyncTasks,
+	}
+
+	r.tools["create_cloud_sync_task"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_cloud_sync_task",
+			Description: "Create a cloud sync task that backs up a local path to a cloud bucket (PUSH) or downloads from one (PULL). COPY never deletes; SYNC deletes files at the destination that are missing from the source; MOVE deletes source files after transfer. **Use dry_run=true first** to verify the credential, bucket, and path.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Local directory under /mnt/ (e.g., '/mnt/tank/documents')",
+					},
+					"credentials": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Cloud credential ID (from list_cloud_credentials)",
+					},
+					"bucket": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Bucket (or container) name",
+					},
+					"folder": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Folder inside the bucket (default: bucket root)",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Task description (default: generated from path and bucket)",
+					},
+					"direction": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"PUSH", "PULL"},
+						"description": "Optional: PUSH uploads to the bucket, PULL downloads from it (default: PUSH)",
+					},
+					"transfer_mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"SYNC", "COPY", "MOVE"},
+						"description": "Optional: How files are transferred (default: COPY)",
+					},
+					"schedule": map[string]interface{}{
+						"type":        "object",
+						"description": "Optional: Cron schedule (default: daily at 00:00)",
+						"properties":  snapshotTaskCron,
+					},
+					"snapshot": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: PUSH only - upload from a temporary snapshot so files are consistent (default: false)",
+					},
+					"encryption_password": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Encrypt data and filenames at the remote with this password",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Enable the task (default: true)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without creating (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"path", "credentials", "bucket"},
+			},
+		},
+		Handler: handleCreateCloudSyncTaskWithDryRun,
+	}
+
+	r.tools["run_cloud_sync"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "run_cloud_sync",
+			Description: "Run a cloud sync task now instead of waiting for its schedule. Returns a task_id for progress tracking.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Cloud sync task ID (from query_cloud_sync_tasks)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without starting (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: r.handleRunCloudSyncWithDryRun,
+	}
+
+	// Directory Services
+	r.tools["get_directory_service_status"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_directory_service_status",
+			Description: "Get current directory service status and health. Returns service type (ACTIVEDIRECTORY, IPA, LDAP), status (DISABLED, HEALTHY, FAULTED, JOINING, LEAVING), and error messages if any. Use for quick health checks.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetDirectoryServiceStatus,
+	}
+
+	r.tools["query_directory_services"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_directory_services",
+			Description: "Query full directory service configuration. Returns service type, enabled status, credentials (masked for security), and service-specific settings. All passwords and keytabs are masked in output.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleQueryDirectoryServices,
+	}
+
+	r.tools["list_directory_certificates"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_directory_certificates",
+			Description: "List available certificates for LDAP MTLS authentication. Returns certificate IDs and names that can be used with LDAP_MTLS credential type.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleListDirectoryCertificates,
+	}
+
+	r.tools["refresh_directory_cache"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "refresh_directory_cache",
+			Description: "Refresh cached user and group data from the directory service. Use after making changes in Active Directory, LDAP, or IPA that need to be reflected immediately in TrueNAS.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleRefreshDirectoryCache,
+	}
+
+	r.tools["configure_directory_service"] = Tool{
+		Definition: mcp.Tool{
+			Name: "configure_directory_service",
+			Description: `Configure and join a directory service (Active Directory, LDAP, or IPA). Setting enable=true joins the domain automatically.
+
+**Service Types:**
+- ACTIVEDIRECTORY: Microsoft Active Directory integration
+- LDAP: Generic LDAP server (OpenLDAP, etc.)
+- IPA: FreeIPA / Red Hat Identity Management
+
+**Credential Types by Service:**
+
+Active Directory:
+- KERBEROS_USER: {type: "KERBEROS_USER", username: "admin", password: "pass"}
+- KERBEROS_PRINCIPAL: {type: "KERBEROS_PRINCIPAL", principal: "host/truenas", keytab: "..."}
+
+LDAP:
+- LDAP_PLAIN: {type: "LDAP_PLAIN", binddn: "cn=admin,dc=example,dc=com", bindpw: "pass"}
+- LDAP_ANONYMOUS: {type: "LDAP_ANONYMOUS"}
+- LDAP_MTLS: {type: "LDAP_MTLS", certificate_id: 123}
+- KERBEROS_USER or KERBEROS_PRINCIPAL (same as Active Directory)
+
+IPA:
+- KERBEROS_USER or KERBEROS_PRINCIPAL (same as Active Directory)
+
+**Configuration Object (service-specific):**
+For Active Directory: {hostname: "truenas-nyc", domain: "corp.example.com", ...}
+For LDAP: {hostname: "ldap.example.com", port: 389, ...}
+For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
+
+**Security:**
+- Credentials are stored in TrueNAS configuration
+- Use Kerberos principals with keytabs instead of passwords for production
+- Dry-run shows credential requirements without exposing values
+
+**Returns:** task_id for tracking long-running domain join operation (2-10 minutes typical)`,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"service_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"ACTIVEDIRECTORY", "LDAP", "IPA"},
+						"description": "Directory service type",
+					},
+					"enable": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Enable service (true to join domain, false to disable)",
+					},
+					"credential": map[string]interface{}{
+						"type":        "object",
+						"description": "Credential object with 'type' field and credential-specific fields (see tool description)",
+					},
+					"configuration": map[string]interface{}{
+						"type":        "object",
+						"description": "Service-specific configuration (domain, hostname, etc.)",
+					},
+					"enable_account_cache": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Cache user/group lists (default: true)",
+						"default":     true,
+					},
+					"enable_dns_updates": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Auto DNS updates via nsupdate (default: true)",
+						"default":     true,
+					},
+					"timeout": map[string]interface{}{
+						"type":        "integer",
+						"description": "DNS query and LDAP request timeout in seconds (5-60, default: 10)",
+						"default":     10,
+					},
+					"kerberos_realm": map[string]interface{}{
+						"type":        "string",
+						"description": "Kerberos realm for authentication (optional)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview configuration without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"service_type", "enable", "credential"},
+			},
+		},
+		Handler: r.handleConfigureDirectoryServiceWithDryRun,
+	}
+
+	r.tools["leave_directory_service"] = Tool{
+		Definition: mcp.Tool{
+			Name: "leave_directory_service",
+			Description: `Disconnect from directory service and leave the domain.
+
+**WARNING:** This is a destructive operation:
+- Removes TrueNAS from the domain
+- Deletes computer account (if possible)
+- Clears all cached user/group data
+- All domain user authentication will stop working
+- SMB/NFS shares configured with domain users will become inaccessible
+
+**Alternative:** Use configure_directory_service with enable=false for temporary disable without leaving the domain.
+
+**Returns:** task_id for tracking the leave operation (30 seconds to 5 minutes typical)`,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview operation without executing (default: false, STRONGLY RECOMMENDED to use dry_run first)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleLeaveDirectoryServiceWithDryRun,
+	}
+
+	// SNMP service configuration
+	r.tools["get_snmp_config"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_snmp_config",
+			Description: "Get the SNMP service configuration (location, contact, community, SNMPv3 user and security) and whether the service is running and starts on boot. Secrets are masked.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetSNMPConfig,
+	}
+
+	r.tools["update_snmp_config"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_snmp_config",
+			Description: "Configure SNMP for monitoring: location, contact, v1/v2c community, SNMPv3 user with authentication and privacy, and enable (start on boot and start now) or disable the service. Only the settings given are changed. Use dry_run=true first; it warns about the default 'public' community and unencrypted v1/v2c.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"location": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: sysLocation, e.g. 'Rack 4, Server Room B'",
+					},
+					"contact": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: sysContact, e.g. an email address",
+					},
+					"community": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: SNMP v1/v2c community string (no spaces, quotes, backslashes, or #)",
+					},
+					"v3": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Enable SNMPv3 (requires v3_username and v3_password, new or already set)",
+					},
+					"v3_username": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: SNMPv3 username",
+					},
+					"v3_authtype": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"SHA", "MD5"},
+						"description": "Optional: SNMPv3 authentication protocol (SHA recommended)",
+					},
+					"v3_password": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: SNMPv3 authentication password (at least 8 characters)",
+					},
+					"v3_privproto": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"AES", "DES", "none"},
+						"description": "Optional: SNMPv3 privacy (encryption) protocol (AES recommended; 'none' disables privacy)",
+					},
+					"v3_privpassphrase": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: SNMPv3 privacy passphrase (at least 8 characters; required with v3_privproto)",
+					},
+					"enable_service": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: true starts the SNMP service and enables it on boot; false stops and disables it",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without updating (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: handleUpdateSNMPConfigWithDryRun,
+	}
+
+	// UPS service
+	r.tools["get_ups_config"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_ups_config",
+			Description: "Get the UPS service configuration: mode (MASTER for a UPS attached to this NAS, SLAVE to follow a UPS on another host), driver and port, when the NAS shuts down on battery, and whether the service runs and starts on boot. The monitor password is shown only as set or not.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetUPSConfig,
+	}
+
+	r.tools["get_ups_status"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_ups_status",
+			Description: "Report the current UPS status: on mains or battery, latest battery charge, runtime, and load, and active UPS alerts. Includes a shutdown_readiness verdict (READY, AT_RISK, NOT_READY) with the issues that would keep the NAS from shutting down cleanly on power loss. Use get_ups_metrics for history.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetUPSStatus,
+	}
+
+	r.tools["update_ups_config"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_ups_config",
+			Description: "Change the UPS service configuration and optionally enable or disable the service. Only the fields given are changed. The driver is checked against the NAS's supported drivers. Use dry-run to preview, then get_ups_status to confirm the NAS reads the UPS.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"MASTER", "SLAVE"},
+						"description": "Optional: MASTER when the UPS is attached to this NAS, SLAVE to follow a UPS attached to another host",
+					},
+					"identifier": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: UPS name (default: ups)",
+					},
+					"driver": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: MASTER driver, a key of ups.driver_choices (e.g., 'usbhid-ups$Back-UPS ES USB'); a partial name lists the matching drivers",
+					},
+					"port": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: MASTER port, 'auto' for most USB UPSes or a serial device such as /dev/ttyS0",
+					},
+					"remotehost": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: SLAVE host the UPS is attached to",
+					},
+					"remoteport": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: SLAVE upsd port on the remote host (default: 3493)",
+					},
+					"shutdown": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"LOWBATT", "BATT"},
+						"description": "Optional: Shut down when the UPS reports a low battery (LOWBATT) or after shutdowntimer seconds on battery (BATT)",
+					},
+					"shutdowntimer": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Seconds on battery before shutting down in BATT mode; keep well under the battery runtime",
+					},
+					"hostsync": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Seconds a MASTER waits for SLAVEs to disconnect before shutting down",
+					},
+					"powerdown": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Turn the UPS outlets off after the NAS shuts down, so it powers back on when mains returns",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Human-readable description",
+					},
+					"monuser": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: upsd monitor user",
+					},
+					"monpwd": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: upsd monitor password",
+					},
+					"enable_service": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: true starts the UPS service and enables it on boot; false stops and disables it",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without updating (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: handleUpdateUPSConfigWithDryRun,
+	}
+
+	// Cron jobs and init/shutdown scripts
+	cronJobProperties := func(required bool) map[string]interface{} {
+		prefix := "Optional: "
+		if required {
+			prefix = "Required: "
+		}
+		return map[string]interface{}{
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": prefix + "Shell command to run",
+			},
+			"user": map[string]interface{}{
+				"type":        "string",
+				"description": prefix + "User the command runs as",
+			},
+			"schedule": map[string]interface{}{
+				"type":        "object",
+				"description": prefix + "Cron schedule (e.g., {minute: '0', hour: '3'} for daily at 3am, {minute: '*/15', hour: '*'} for every 15 minutes); fields not given default to daily at midnight on create and keep their values on update",
+				"properties": map[string]interface{}{
+					"minute": map[string]interface{}{"type": "string"},
+					"hour":   map[string]interface{}{"type": "string"},
+					"dom":    map[string]interface{}{"type": "string"},
+					"month":  map[string]interface{}{"type": "string"},
+					"dow":    map[string]interface{}{"type": "string"},
+				},
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: Human-readable description",
+			},
+			"enabled": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Enable or disable the job (default: true)",
+			},
+			"hide_stdout": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Discard standard output instead of mailing it to the user (default: true)",
+			},
+			"hide_stderr": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Discard error output instead of mailing it to the user (default: false)",
+			},
+		}
+	}
+
+	r.tools["query_cron_jobs"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_cron_jobs",
+			Description: "Query cron jobs (scheduled shell commands) with the user they run as, human-readable schedules, and next run time in the NAS timezone.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"user": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Show only jobs running as this user",
+					},
+					"enabled_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Show only enabled jobs (default: false)",
+					},
+				},
+			},
+		},
+		Handler: handleQueryCronJobs,
+	}
+
+	createCronJobProps := cronJobProperties(true)
+	createCronJobProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without creating (default: false)",
+		"default":     false,
+	}
+	r.tools["create_cron_job"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_cron_job",
+			Description: "Create a cron job that runs a shell command as a user on a cron schedule. The user must exist. Prefer a non-root user where the command allows it.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": createCronJobProps,
+				"required":   []string{"command", "user", "schedule"},
+			},
+		},
+		Handler: handleCreateCronJobWithDryRun,
+	}
+
+	updateCronJobProps := cronJobProperties(false)
+	updateCronJobProps["id"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Required: Cron job ID to update (from query_cron_jobs)",
+	}
+	updateCronJobProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without updating (default: false)",
+		"default":     false,
+	}
+	r.tools["update_cron_job"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_cron_job",
+			Description: "Change a cron job in place: command, user, schedule, description, output handling, or enabled flag. Only the fields given are changed; cron fields not given keep their current values. Use dry-run to compare the old and new next-run times before applying.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": updateCronJobProps,
+				"required":   []string{"id"},
+			},
+		},
+		Handler: handleUpdateCronJobWithDryRun,
+	}
+
+	r.tools["delete_cron_job"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_cron_job",
+			Description: "Remove a cron job. Consider disabling it with update_cron_job instead.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Cron job ID to delete (from query_cron_jobs)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without deleting (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: handleDeleteCronJobWithDryRun,
+	}
+
+	r.tools["run_cron_job"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "run_cron_job",
+			Description: "Run a cron job's command now instead of waiting for its schedule, even if the job is disabled. Returns a task_id for progress tracking.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Cron job ID (from query_cron_jobs)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without starting (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: r.handleRunCronJobWithDryRun,
+	}
+
+	initShutdownScriptProperties := func() map[string]interface{} {
+		return map[string]interface{}{
+			"type": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"COMMAND", "SCRIPT"},
+				"description": "Optional: COMMAND runs a shell command, SCRIPT runs a script file (default: SCRIPT when script is given, otherwise COMMAND)",
+			},
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: Shell command to run (type COMMAND)",
+			},
+			"script": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: Absolute path of the script to run (type SCRIPT)",
+			},
+			"when": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"PREINIT", "POSTINIT", "SHUTDOWN"},
+				"description": "When it runs: PREINIT early in boot, POSTINIT after services start, SHUTDOWN before services stop (required on create)",
+			},
+			"timeout": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional: Seconds to wait for it to finish (default: 10)",
+			},
+			"enabled": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Optional: Enable or disable it (default: true)",
+			},
+			"comment": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: Human-readable description",
+			},
+		}
+	}
+
+	r.tools["query_init_shutdown_scripts"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_init_shutdown_scripts",
+			Description: "Query init/shutdown scripts: commands and scripts the NAS runs at boot or shutdown, with when each runs and its timeout.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"when": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"PREINIT", "POSTINIT", "SHUTDOWN"},
+						"description": "Optional: Show only scripts that run at this point",
+					},
+				},
+			},
+		},
+		Handler: handleQueryInitShutdownScripts,
+	}
+
+	createInitShutdownScriptProps := initShutdownScriptProperties()
+	createInitShutdownScriptProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without creating (default: false)",
+		"default":     false,
+	}
+	r.tools["create_init_shutdown_script"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_init_shutdown_script",
+			Description: "Create an init/shutdown script that runs a command or script file at boot (PREINIT or POSTINIT) or shutdown. Dry-run checks that a script file exists on the NAS.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": createInitShutdownScriptProps,
+				"required":   []string{"when"},
+			},
+		},
+		Handler: handleCreateInitShutdownScriptWithDryRun,
+	}
+
+	updateInitShutdownScriptProps := initShutdownScriptProperties()
+	updateInitShutdownScriptProps["id"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Required: Script ID to update (from query_init_shutdown_scripts)",
+	}
+	updateInitShutdownScriptProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without updating (default: false)",
+		"default":     false,
+	}
+	r.tools["update_init_shutdown_script"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_init_shutdown_script",
+			Description: "Change an init/shutdown script in place. Only the fields given are changed.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": updateInitShutdownScriptProps,
+				"required":   []string{"id"},
+			},
+		},
+		Handler: handleUpdateInitShutdownScriptWithDryRun,
+	}
+
+	r.tools["delete_init_shutdown_script"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_init_shutdown_script",
+			Description: "Remove an init/shutdown script. Consider disabling it with update_init_shutdown_script instead.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Script ID to delete (from query_init_shutdown_scripts)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without deleting (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: handleDeleteInitShutdownScriptWithDryRun,
+	}
+
+	// Global network settings and static routes
+	r.tools["get_network_config"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_network_config",
+			Description: "Get the global network settings: hostname, domain, search domains, nameservers, IPv4/IPv6 default gateways (configured and in effect, e.g. from DHCP), and static routes.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetNetworkConfig,
+	}
+
+	r.tools["update_network_config"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_network_config",
+			Description: "Change the hostname, domain, search domains, DNS nameservers, or default gateways. Only the settings given are changed. Use dry_run=true first: it shows an old/new diff and warns when a change can cut off access to the NAS.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"hostname": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Hostname (a single DNS label, e.g. 'nas')",
+					},
+					"domain": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Domain name, e.g. 'home.arpa'",
+					},
+					"search_domains": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Additional DNS search domains (replaces the list; [] clears it)",
+					},
+					"nameservers": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Up to 3 nameserver IP addresses in order (replaces the list; [] clears it to use DHCP)",
+					},
+					"ipv4_gateway": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: IPv4 default gateway ('' clears it to use DHCP)",
+					},
+					"ipv6_gateway": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: IPv6 default gateway ('' clears it)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the changes without applying them",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: handleUpdateNetworkConfigWithDryRun,
+	}
+
+	r.tools["create_static_route"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_static_route",
+			Description: "Add a static route sending traffic for a network through a gateway other than the default. Dry-run warns about overlapping routes and gateways outside the NAS's subnets.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"description": "Destination network in CIDR notation, e.g. '10.20.0.0/16'",
+					},
+					"gateway": map[string]interface{}{
+						"type":        "string",
+						"description": "Gateway IP address, of the same family as destination",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Description",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the route without creating it",
+						"default":     false,
+					},
+				},
+				"required": []string{"destination", "gateway"},
+			},
+		},
+		Handler: handleCreateStaticRouteWithDryRun,
+	}
+
+	r.tools["update_static_route"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_static_route",
+			Description: "Change a static route's destination, gateway, or description. Use dry_run=true to see the old/new diff.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Static route ID from get_network_config",
+					},
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: New destination network in CIDR notation",
+					},
+					"gateway": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: New gateway IP address",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: New description",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the changes without applying them",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: handleUpdateStaticRouteWithDryRun,
+	}
+
+	r.tools["delete_static_route"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_static_route",
+			Description: "Delete a static route; traffic for its network falls back to the default gateway.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Static route ID from get_network_config",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the deletion without deleting",
+						"default":     false,
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: handleDeleteStaticRouteWithDryRun,
+	}
+
+	// ACME certificates
+	r.tools["list_acme_dns_authenticators"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_acme_dns_authenticators",
+			Description: "List the DNS authenticators used to answer ACME DNS-01 challenges (provider and which fields are set; credentials are never shown) and the DNS providers TrueNAS supports with the fields each requires. Check this before issue_acme_certificate.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"provider": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only describe this DNS provider, e.g. 'cloudflare' or 'route53'",
+					},
+				},
+			},
+		},
+		Handler: handleListACMEDNSAuthenticators,
+	}
+
+	r.tools["create_acme_dns_authenticator"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_acme_dns_authenticator",
+			Description: "Create a DNS authenticator: the DNS provider account TrueNAS uses to create _acme-challenge TXT records when issuing and renewing ACME certificates. Attributes are checked against the provider's schema. Use dry_run=true first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Name for the authenticator, e.g. 'cloudflare-example-com'",
+					},
+					"provider": map[string]interface{}{
+						"type":        "string",
+						"description": "DNS provider key from list_acme_dns_authenticators, e.g. 'cloudflare'",
+					},
+					"attributes": map[string]interface{}{
+						"type":        "object",
+						"description": "Provider fields such as API tokens, e.g. {\"api_token\": \"...\"} for Cloudflare",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the authenticator without creating it",
+						"default":     false,
+					},
+				},
+				"required": []string{"name", "provider"},
+			},
+		},
+		Handler: handleCreateACMEDNSAuthenticatorWithDryRun,
+	}
+
+	r.tools["issue_acme_certificate"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "issue_acme_certificate",
+			Description: "Issue a certificate from Let's Encrypt (or another ACME directory) with DNS-01 validation: creates a CSR, requests the certificate through a DNS authenticator, and optionally makes it the web UI certificate. TrueNAS renews it automatically renew_days before it expires. Runs as a task; track it with tasks_get. Use dry_run=true first: it lists the DNS authenticator requirements and what will change. Requires accept_tos=true once the user has accepted the ACME terms of service.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Certificate name (letters, digits, _ and -); the CSR is named <name>_csr",
+					},
+					"domains": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Fully qualified domain names to cover, first one as the common name, e.g. ['nas.example.com'] or ['*.example.com']",
+					},
+					"authenticator": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: DNS authenticator name or ID answering every domain's challenge (default: the only one configured)",
+					},
+					"directory": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: 'production' (Let's Encrypt), 'staging' (Let's Encrypt test certificates, untrusted), or an https:// ACME directory URL (default: production)",
+						"default":     "production",
+					},
+					"renew_days": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Renew this many days before expiry, 1-30 (default: 10)",
+						"default":     defaultACMERenewDays,
+					},
+					"key_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"RSA", "EC"},
+						"description": "Optional: RSA 2048 or EC P-384 key (default: RSA)",
+						"default":     "RSA",
+					},
+					"set_as_ui_certificate": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Use the certificate for the TrueNAS web UI and restart the UI once issued (default: false)",
+						"default":     false,
+					},
+					"accept_tos": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Accept the ACME provider's terms of service (required to issue)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the steps and DNS authenticator requirements without issuing",
+						"default":     false,
+					},
+				},
+				"required": []string{"name", "domains"},
+			},
+		},
+		Handler: r.handleIssueACMECertificateWithDryRun,
+	}
+
+	// Service management
+	r.tools["query_services"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_services",
+			Description: "List system services (SMB as cifs, NFS, iSCSI as iscsitarget, SSH, FTP, UPS, SNMP, ...) with their state and whether they start on boot. Notes services that run now but would not come back after a reboot.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"state": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only services in this state",
+						"enum":        []string{"RUNNING", "STOPPED"},
+					},
+				},
+			},
+		},
+		Handler: handleQueryServices,
+	}
+
+	r.tools["start_service"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "start_service",
+			Description: "Start a system service, e.g. NFS after creating the first NFS share. Does not change whether it starts on boot (see set_service_autostart). Use dry_run=true to preview.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"service": map[string]interface{}{
+						"type":        "string",
+						"description": "Service name from query_services (e.g., 'cifs' or 'smb', 'nfs', 'iscsitarget' or 'iscsi', 'ssh', 'ups')",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the change and its warnings without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"service"},
+			},
+		},
+		Handler: handleStartServiceWithDryRun,
+	}
+
+	r.tools["stop_service"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "stop_service",
+			Description: "Stop a system service. Stopping SMB, NFS, or iSCSI cuts off every client using its shares; stopping SSH ends remote shell sessions. **Always use dry_run=true first**: it lists the enabled shares and connected clients that are affected. Confirm with the user. Does not change whether it starts on boot.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"service": map[string]interface{}{
+						"type":        "string",
+						"description": "Service name from query_services (e.g., 'cifs' or 'smb', 'nfs', 'iscsitarget' or 'iscsi', 'ssh', 'ups')",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the change and its warnings without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"service"},
+			},
+		},
+		Handler: handleStopServiceWithDryRun,
+	}
+
+	r.tools["restart_service"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "restart_service",
+			Description: "Restart a system service, e.g. to apply configuration changes. Clients are briefly disconnected; the dry run lists the shares and clients affected.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"service": map[string]interface{}{
+						"type":        "string",
+						"description": "Service name from query_services (e.g., 'cifs' or 'smb', 'nfs', 'iscsitarget' or 'iscsi', 'ssh', 'ups')",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the change and its warnings without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"service"},
+			},
+		},
+		Handler: handleRestartServiceWithDryRun,
+	}
+
+	r.tools["set_service_autostart"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "set_service_autostart",
+			Description: "Set whether a system service starts on boot. Does not start or stop it now (see start_service and stop_service).",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"service": map[string]interface{}{
+						"type":        "string",
+						"description": "Service name from query_services (e.g., 'cifs' or 'smb', 'nfs', 'iscsitarget' or 'iscsi', 'ssh', 'ups')",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Start the service on boot",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the change and its warnings without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"service", "enabled"},
+			},
+		},
+		Handler: handleSetServiceAutostartWithDryRun,
+	}
+
+	// Storage pools query
+	r.tools["query_pools"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_pools",
+			Description: "Query storage pools with their status, capacity, and health information. Results are paged: follow next_cursor while it is present.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of pools to return (default: 50)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: next_cursor from the previous page; returns the next page with the same filters",
+					},
+				},
+			},
+		},
+		Handler: handleQueryPools,
+	}
+
+	// Dataset query
+	r.tools["estimate_dedup_impact"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "estimate_dedup_impact",
+			Description: "Report dedup table sizes and RAM consumption for pools with deduplication enabled, and estimate the RAM cost of enabling dedup on an existing dataset. Use before recommending deduplication. Read-only.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only report this pool",
+					},
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Dataset being considered for dedup; estimates its table size from current usage and record size",
+					},
+				},
+			},
+		},
+		Handler: handleEstimateDedupImpact,
+	}
+
+	r.tools["compression_report"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "compression_report",
+			Description: "Rank datasets by compression ratio, estimate the space compression saves per dataset and pool, and suggest where enabling compression or switching LZ4 to ZSTD would help. Read-only. Perfect for 'is compression worth it?' or 'where can I save space?'",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only report datasets in this pool",
+					},
+					"min_size": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Skip datasets using less than this, in bytes or human-readable (default: '1G')",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum datasets in the ranking (default: 20)",
+					},
+				},
+			},
+		},
+		Handler: handleCompressionReport,
+	}
+
+	r.tools["query_pool_upgrades"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_pool_upgrades",
+			Description: "Report which pools have ZFS feature flags that are not yet enabled (pools created on, or not upgraded since, an older TrueNAS release). Read-only.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleQueryPoolUpgrades,
+	}
+
+	r.tools["upgrade_pool"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "upgrade_pool",
+			Description: "Enable all supported ZFS feature flags on a pool (pool.upgrade). **IMPORTANT**: This is permanent. Upgraded pools cannot be imported by older TrueNAS versions, including older boot environments on this system. Always use dry-run first and only upgrade once rollback to an older release is no longer needed.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool name to upgrade",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the upgrade and its warnings without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"pool"},
+			},
+		},
+		Handler: r.handleUpgradePoolWithDryRun,
+	}
+
+	// Pool topology and vdev management
+	r.tools["get_pool_topology"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_pool_topology",
+			Description: "Show a pool's vdev layout: data, special, dedup, log, cache, and spare vdevs with their member disks, status, and error counters, how many more disk failures each vdev can survive, a running resilver, and the unused disks available to repair or grow it. Start here before replace_disk, attach_disk, detach_disk, or add_vdevs.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool name",
+					},
+				},
+				"required": []string{"pool"},
+			},
+		},
+		Handler: handleGetPoolTopology,
+	}
+
+	r.tools["replace_disk"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "replace_disk",
+			Description: "Replace a failed (or failing) pool disk with an unused disk and resilver onto it. The new disk must be unused and at least as large. A disk being replaced stays in the pool until the resilver completes. Returns a task_id for progress tracking. Always use dry-run first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool name",
+					},
+					"disk": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Member to replace, by disk name (e.g. 'sdb') or GUID from get_pool_topology (use the GUID for a disk that has disappeared)",
+					},
+					"new_disk": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Unused disk to replace it with (e.g. 'sdf')",
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Use the new disk even if it holds leftover partitions or a pool label (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without replacing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"pool", "disk", "new_disk"},
+			},
+		},
+		Handler: r.handleReplaceDiskWithDryRun,
+	}
+
+	r.tools["attach_disk"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "attach_disk",
+			Description: "Attach an unused disk to a vdev: turns a single-disk vdev into a mirror, adds a disk to a mirror, or expands a RAIDZ vdev by one disk (RAIDZ expansion, permanent). Returns a task_id for progress tracking. Always use dry-run first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool name",
+					},
+					"target": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Vdev to grow, by vdev name (e.g. 'mirror-0', 'raidz1-0'), a member disk name, or GUID",
+					},
+					"new_disk": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Unused disk to attach (e.g. 'sdf')",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without attaching (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"pool", "target", "new_disk"},
+			},
+		},
+		Handler: r.handleAttachDiskWithDryRun,
+	}
+
+	r.tools["detach_disk"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "detach_disk",
+			Description: "Detach a disk from a mirror (or a finished replacement or in-use spare), reducing the mirror's redundancy. Refuses to detach the last ONLINE disk of a mirror and disks that are not in a mirror. Always use dry-run first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool name",
+					},
+					"disk": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Mirror member to detach, by disk name or GUID",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without detaching (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"pool", "disk"},
+			},
+		},
+		Handler: handleDetachDiskWithDryRun,
+	}
+
+	r.tools["add_vdevs"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "add_vdevs",
+			Description: "Expand a pool by adding new vdevs made of unused disks: data vdevs for capacity, or special, dedup, log (SLOG), cache (L2ARC), or spare devices. Refuses single-disk data vdevs on a redundant pool. **IMPORTANT**: data vdevs cannot be removed from pools with RAIDZ vdevs. Returns a task_id for progress tracking. Always use dry-run first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pool name",
+					},
+					"disks": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Required: Unused disks to add (e.g. ['sdf', 'sdg'])",
+					},
+					"vdev_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"STRIPE", "MIRROR", "RAIDZ1", "RAIDZ2", "RAIDZ3"},
+						"description": "Required for data, special, dedup, and log: Vdev layout (cache and spare are always STRIPE)",
+					},
+					"vdev_width": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Disks per vdev, to add several vdevs at once (default: all disks in one vdev)",
+					},
+					"role": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"data", "special", "dedup", "log", "cache", "spare"},
+						"description": "Optional: What the new vdevs are for (default: data)",
+						"default":     "data",
+					},
+					"allow_no_redundancy": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Allow single-disk data, special, or dedup vdevs on a redundant pool (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without changing the pool (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"pool", "disks"},
+			},
+		},
+		Handler: r.handleAddVdevsWithDryRun,
+	}
+
+	r.tools["query_datasets"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_datasets",
+			Description: "Query datasets with optional filtering and sorting. Returns simplified dataset information with capacity, encryption status, and usage details. Use 'limit' to control result size, 'order_by' to sort by size, and 'encrypted_only' to filter. Filtering, sorting, and paging run on the NAS; follow next_cursor while it is present.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter datasets by pool name",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of datasets to return (default: 50 for manageable response size)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of datasets to skip, for paging (default: 0)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: next_cursor from the previous page; returns the next page with the same filters",
+					},
+					"order_by": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Sort by 'used' (space usage), 'available', or 'name' (default: used descending)",
+						"enum":        []string{"used", "available", "name"},
+					},
+					"encrypted_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Return only encrypted datasets (default: false)",
+					},
+				},
+			},
+		},
+		Handler: handleQueryDatasets,
+	}
+
+	r.tools["audit_encryption"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "audit_encryption",
+			Description: "Audit dataset encryption: every encrypted dataset with its encryption root, key format (passphrase or key), locked state, and where its key is kept (system database or KMIP), plus per-pool totals. Flags the datasets that will NOT unlock by themselves after a reboot. Use before rebooting or updating to know what will need a passphrase.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only audit this pool",
+					},
+				},
+			},
+		},
+		Handler: handleAuditEncryption,
+	}
+
+	r.tools["what_uses_this_dataset"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "what_uses_this_dataset",
+			Description: "Reverse lookup of everything that references a dataset or path: SMB/NFS shares, iSCSI extents, app host-path mounts, VM disks and devices, periodic snapshot, replication, cloud sync and rsync tasks, and the system and apps datasets. Consumers of the dataset itself or anything inside it break if it is deleted, renamed, or moved; recursive tasks on a parent are listed as covering it. Run this before any deletion or rename.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Dataset or zvol name (e.g. 'tank/media')",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Alternatively, a path under /mnt/ (e.g. '/mnt/tank/media/movies')",
+					},
+				},
+			},
+		},
+		Handler: handleWhatUsesThisDataset,
+	}
+
+	// Snapshots query
+	r.tools["query_snapshots"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_snapshots",
+			Description: "Query ZFS snapshots with optional filtering and sorting. Returns simplified snapshot information with creation info, dataset, and holds status. Use 'limit' and 'offset' to page, 'order_by' to sort. On busy systems use stream=true to page through every snapshot with a cursor.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter snapshots by parent dataset name",
+					},
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter snapshots by pool name",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of snapshots to return (default: 50 for manageable response size)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of snapshots to skip, for paging (default: 0)",
+					},
+					"order_by": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Sort by 'name' (snapshot name, default descending), 'dataset' (parent dataset), or 'created' (creation time, newest first)",
+						"enum":        []string{"name", "dataset", "created"},
+					},
+					"holds_only": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Return only snapshots with holds that prevent deletion (default: false)",
+					},
+					"stream": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Keep the full result on the server and return it page by page (limit rows per page) with next_cursor, instead of truncating at limit (default: false)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: next_cursor from the previous page (streamed or not); returns the next page with the same filters",
+					},
+				},
+			},
+		},
+		Handler: r.handleQuerySnapshots,
+	}
+
+	// Snapshot lifecycle (write operations)
+	r.tools["create_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_snapshot",
+			Description: "Create a ZFS snapshot of a dataset, optionally including its child datasets. Use before risky changes so they can be undone with rollback_snapshot.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Dataset to snapshot (e.g., 'tank/media')",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Snapshot name, the part after @ (default: manual-YYYY-MM-DD_HH-MM)",
+					},
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Also snapshot every child dataset with the same name (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without making changes (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"dataset"},
+			},
+		},
+		Handler: handleCreateSnapshotWithDryRun,
+	}
+
+	r.tools["delete_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_snapshot",
+			Description: "Delete a ZFS snapshot. Refused while the snapshot has holds (e.g. from replication) or dependent clones. When the server runs with a deletion grace period, the deletion is queued and can be cancelled with undo_pending_deletion. **Use dry_run=true first** and confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"snapshot": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Full snapshot name (e.g., 'tank/media@manual-2025-01-01_12-00', the full_name from query_snapshots)",
+					},
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Also delete snapshots with the same name on child datasets (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without making changes (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"snapshot"},
+			},
+		},
+		Handler: r.handleDeleteSnapshotWithDryRun,
+	}
+
+	r.tools["rollback_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "rollback_snapshot",
+			Description: "Roll a dataset back to a snapshot, permanently discarding every change made since. Snapshots taken after it are destroyed, which must be confirmed with destroy_newer=true (and destroy_clones=true if they have clones). **Always use dry_run=true first** to see what would be destroyed, and confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"snapshot": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Full snapshot name to roll back to (e.g., 'tank/media@manual-2025-01-01_12-00')",
+					},
+					"destroy_newer": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Confirm destroying snapshots newer than the target (required when any exist; default: false)",
+						"default":     false,
+					},
+					"destroy_clones": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Confirm destroying clones of those newer snapshots (required when any exist; default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without making changes (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"snapshot"},
+			},
+		},
+		Handler: handleRollbackSnapshotWithDryRun,
+	}
+
+	r.tools["clone_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "clone_snapshot",
+			Description: "Create a writable dataset from a snapshot, e.g. to recover files or test against old data without rolling back. The clone must be in the same pool, and the snapshot cannot be deleted while the clone exists.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"snapshot": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Full snapshot name to clone (e.g., 'tank/media@manual-2025-01-01_12-00')",
+					},
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: New dataset name in the same pool (e.g., 'tank/media-restore'); its parent must exist",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without making changes (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"snapshot", "destination"},
+			},
+		},
+		Handler: handleCloneSnapshotWithDryRun,
+	}
+
+	// Snapshot browsing and single-file restore
+	r.tools["browse_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "browse_snapshot",
+			Description: "List the files and directories of a dataset snapshot (through the dataset's hidden .zfs/snapshot directory) as they were when it was taken. Each entry is compared with the live dataset, so files deleted or renamed since stand out with in_live=false. Use query_snapshots to pick a snapshot, browse into directories with path, then restore_file_from_snapshot to recover a file.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"snapshot": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Full snapshot name (e.g., 'tank/documents@auto-2025-06-01_00-00')",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Directory to list, relative to the dataset root (e.g., 'reports/2025') or as a live path under its mountpoint (default: dataset root)",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum entries to return, by name (default: 200, max: 1000)",
+						"default":     200,
+					},
+					"compare_live": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Mark whether each entry still exists in the live dataset (default: true)",
+						"default":     true,
+					},
+				},
+				"required": []string{"snapshot"},
+			},
+		},
+		Handler: handleBrowseSnapshot,
+	}
+
+	r.tools["restore_file_from_snapshot"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "restore_file_from_snapshot",
+			Description: "Copy a single file from a snapshot back into the live dataset, keeping its permissions mode and owner. Restores to the same path by default; refuses to replace an existing file unless overwrite=true (or restore next to it with destination). Only regular files can be restored; roll back or clone the snapshot to recover whole directories. The file is streamed through this server. Supports dry-run mode.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"snapshot": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Full snapshot name holding the file (e.g., 'tank/documents@auto-2025-06-01_00-00')",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: File to restore, relative to the dataset root (from browse_snapshot) or as a live path under its mountpoint",
+					},
+					"destination": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Where to write the file in the same dataset, relative to its root (default: the original path). Its directory must exist",
+					},
+					"overwrite": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Replace the destination if it exists (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without making changes (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"snapshot", "path"},
+			},
+		},
+		Handler: handleRestoreFileFromSnapshotWithDryRun,
+	}
+
+	// Shares query
+	r.tools["query_shares"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_shares",
+			Description: "Query SMB and NFS shares with optional filtering by path, dataset, and enabled state. Returns simplified share information (name, path, enabled, access settings) sorted by path, with per-protocol counts. Results are paged (SMB shares first, then NFS exports): follow next_cursor while it is present.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"share_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"smb", "nfs", "all"},
+						"description": "Type of shares to query (default: all)",
+						"default":     "all",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only shares whose path starts with this prefix (e.g., '/mnt/tank/media')",
+					},
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only shares on this dataset or its children (e.g., 'tank/media')",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Only enabled (true) or disabled (false) shares",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of shares to return across both protocols (default: 50)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: next_cursor from the previous page; returns the next page with the same filters",
+					},
+				},
+			},
+		},
+		Handler: handleQueryShares,
+	}
+
+	// VM query
+	r.tools["query_vms"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_vms",
+			Description: "Query virtual machines with optional filtering and sorting. Returns simplified VM information with resource allocation, status, and device summary. Excludes sensitive data like display passwords. Results are paged: follow next_cursor while it is present.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter VMs by name (partial match)",
+					},
+					"state": map[string]interface{}{
+						"type":        "string",
 						"description": "Optional: Filter by VM state (default: all)",
 						"enum":        []string{"RUNNING", "STOPPED", "all"},
 					},
-					"autostart": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Optional: Filter by autostart setting",
+					"autostart": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Filter by autostart setting",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of VMs to return (default: 50)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of VMs to skip, for paging (default: 0)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: next_cursor from the previous page; returns the next page with the same filters",
+					},
+					"order_by": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Sort by 'name' (default, alphabetical), 'memory' (descending), or 'status' (running first)",
+						"enum":        []string{"name", "memory", "status"},
+					},
+				},
+			},
+		},
+		Handler: handleQueryVMs,
+	}
+
+	r.tools["create_vm"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_vm",
+			Description: "Create a virtual machine with its CPU, memory, disks, NICs, installation ISO, and display in one step. Disks can use existing zvols or new zvols created with the VM. The VM is created stopped.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create a VM, ask these questions in order:\n\n1. **Name and Purpose**: Letters, digits, and underscores only. Ask what the guest OS is (Windows, Linux, other)\n2. **CPU**: vcpus (sockets) x cores x threads. Recommend 1 socket with 2-4 cores for most guests. Do not exceed the NAS's cores (check system_info). cpu_mode HOST-PASSTHROUGH gives the best performance but can prevent migration to other hardware\n3. **Memory**: e.g. \"4GiB\"; Windows needs at least 4GiB, small Linux servers 1-2GiB. The dry run checks what the NAS has free; VM memory is taken from the ZFS cache\n4. **Disks**: Query pools and datasets first. Suggest zvols under a dedicated dataset such as 'tank/vms/<name>-disk0'. Pass size to create a new zvol, omit it to attach an existing one. Bus VIRTIO is fastest; use AHCI for Windows unless the VirtIO drivers are loaded during install\n5. **Installation Media**: iso_path of an ISO under /mnt (the ISO must already be on the NAS)\n6. **Network**: nics with attach set to a bridge (e.g. br0) so the VM can reach the NAS, or a physical interface. Type VIRTIO, or E1000 for guests without VirtIO drivers\n7. **Display**: SPICE display with a password for the installer and console; bind to a specific NAS IP to limit who can connect\n8. **Boot**: bootloader UEFI (default) or UEFI_CSM for legacy guests; autostart to start with the NAS; time LOCAL for Windows guests, UTC otherwise\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview every zvol and device that will be created\n2. Show the summary and warnings (memory, CPU overcommit, disk space, display exposure)\n3. Get explicit user confirmation\n4. After creation: start it with start_vm and connect to the display to install the guest OS",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "VM name (letters, digits, and underscores)",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Description of the VM",
+					},
+					"vcpus": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Virtual CPU sockets (default: 1)",
+					},
+					"cores": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Cores per socket (default: 1)",
+					},
+					"threads": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Threads per core (default: 1)",
+					},
+					"cpu_mode": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: CPU mode (default: CUSTOM)",
+						"enum":        []string{"CUSTOM", "HOST-MODEL", "HOST-PASSTHROUGH"},
+					},
+					"memory": map[string]interface{}{
+						"type":        "string",
+						"description": "Memory as a size (e.g., '4GiB', '2048MiB'); a plain number is MiB",
+					},
+					"bootloader": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Firmware (default: UEFI)",
+						"enum":        []string{"UEFI", "UEFI_CSM"},
+					},
+					"autostart": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Start the VM when the NAS boots",
+					},
+					"time": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Guest clock, LOCAL for Windows guests or UTC",
+						"enum":        []string{"LOCAL", "UTC"},
+					},
+					"disks": map[string]interface{}{
+						"type":        "array",
+						"description": "Optional: Disks in boot order",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"zvol": map[string]interface{}{
+									"type":        "string",
+									"description": "zvol name, e.g. 'tank/vms/web-disk0'",
+								},
+								"size": map[string]interface{}{
+									"type":        "string",
+									"description": "Size of a new zvol (e.g., '50GiB'); omit to attach an existing zvol",
+								},
+								"bus": map[string]interface{}{
+									"type":        "string",
+									"description": "Disk bus (default: VIRTIO)",
+									"enum":        []string{"VIRTIO", "AHCI"},
+								},
+							},
+							"required": []string{"zvol"},
+						},
+					},
+					"iso_path": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Installation ISO under /mnt, attached as a CD-ROM that boots first",
+					},
+					"nics": map[string]interface{}{
+						"type":        "array",
+						"description": "Optional: Network interfaces",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"attach": map[string]interface{}{
+									"type":        "string",
+									"description": "NAS interface or bridge to attach to (e.g., 'br0')",
+								},
+								"type": map[string]interface{}{
+									"type":        "string",
+									"description": "NIC model (default: VIRTIO)",
+									"enum":        []string{"VIRTIO", "E1000"},
+								},
+								"mac": map[string]interface{}{
+									"type":        "string",
+									"description": "Optional: MAC address (default: generated)",
+								},
+							},
+							"required": []string{"attach"},
+						},
+					},
+					"display": map[string]interface{}{
+						"type":        "object",
+						"description": "Optional: SPICE display for the installer and console",
+						"properties": map[string]interface{}{
+							"password": map[string]interface{}{
+								"type":        "string",
+								"description": "Display password",
+							},
+							"bind": map[string]interface{}{
+								"type":        "string",
+								"description": "NAS IP address to listen on (default: 0.0.0.0, all addresses)",
+							},
+							"web": map[string]interface{}{
+								"type":        "boolean",
+								"description": "Serve the web console (default: true)",
+							},
+						},
+						"required": []string{"password"},
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the VM and every device and zvol to be created without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"name", "memory"},
+			},
+		},
+		Handler: handleCreateVMWithDryRun,
+	}
+
+	r.tools["start_vm"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "start_vm",
+			Description: "Start a stopped virtual machine. The dry run checks the VM's memory against what the NAS has available. When the middleware runs the start as a job, returns a task_id for tasks_get.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"vm": map[string]interface{}{
+						"type":        "string",
+						"description": "VM name or numeric ID from query_vms",
+					},
+					"overcommit": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Start even if the NAS does not have enough free memory (risks swapping or the OOM killer; default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"vm"},
+			},
+		},
+		Handler: r.handleStartVMWithDryRun,
+	}
+
+	r.tools["stop_vm"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "stop_vm",
+			Description: "Stop a running virtual machine. By default the guest is asked to shut down via ACPI; force=true powers it off immediately like pulling the plug, and force_after_timeout=true powers it off only if it has not shut down within its shutdown timeout. Job-based; use tasks_get with the returned task_id. **Use dry_run=true first** and confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"vm": map[string]interface{}{
+						"type":        "string",
+						"description": "VM name or numeric ID from query_vms",
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Power off immediately without a guest shutdown (default: false)",
+						"default":     false,
+					},
+					"force_after_timeout": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Power off if the guest has not shut down within the VM's shutdown timeout (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"vm"},
+			},
+		},
+		Handler: r.handleStopVMWithDryRun,
+	}
+
+	r.tools["restart_vm"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "restart_vm",
+			Description: "Restart a running virtual machine (guest shutdown, then start). Job-based; use tasks_get with the returned task_id. Supports dry_run to preview the action.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"vm": map[string]interface{}{
+						"type":        "string",
+						"description": "VM name or numeric ID from query_vms",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"vm"},
+			},
+		},
+		Handler: r.handleRestartVMWithDryRun,
+	}
+
+	r.tools["clone_vm"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "clone_vm",
+			Description: "Clone a virtual machine with its devices; disk zvols are snapshotted and cloned. The clone is created stopped. Cloning a running VM gives only crash-consistent disks. Use dry_run=true to preview.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"vm": map[string]interface{}{
+						"type":        "string",
+						"description": "VM name or numeric ID from query_vms",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Name of the clone (letters, digits, and underscores; default: chosen by the middleware)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"vm"},
+			},
+		},
+		Handler: r.handleCloneVMWithDryRun,
+	}
+
+	r.tools["delete_vm"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_vm",
+			Description: "Delete a virtual machine. Disk zvols are kept unless delete_zvols=true, which destroys them with all their data. Refused while the VM is running unless force=true. When the server runs with a deletion grace period, the deletion is queued and can be cancelled with undo_pending_deletion. **Always use dry_run=true first** and confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"vm": map[string]interface{}{
+						"type":        "string",
+						"description": "VM name or numeric ID from query_vms",
+					},
+					"delete_zvols": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also destroy the zvols backing the VM's disks (default: false)",
+						"default":     false,
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Delete even if the VM is running; it is powered off without a guest shutdown (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"vm"},
+			},
+		},
+		Handler: r.handleDeleteVMWithDryRun,
+	}
+
+	// Dataset creation (write operation)
+	r.tools["create_dataset"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_dataset",
+			Description: "Create a ZFS dataset (filesystem or volume) for storage. This tool is reusable for SMB shares, NFS exports, iSCSI LUNs, and application storage. Supports encryption, compression, quotas, and advanced ZFS features.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create datasets, ask these questions in order:\n\n1. **Pool Selection**: Query available pools first, ask which pool to use\n2. **Dataset Name**: Suggest format 'pool/shares/name' or 'pool/apps/name'\n3. **Dataset Type**: FILESYSTEM (default, for files) or VOLUME (for block storage/VMs)\n4. **Share Type Optimization** (if for sharing):\n   - SMB: Windows/Mac file shares (recommend for SMB shares)\n   - NFS: Unix/Linux file shares\n   - MULTIPROTOCOL: Both SMB and NFS access\n   - APPS: Application storage\n   - GENERIC: General purpose (default)\n5. **Encryption** (recommend for sensitive data):\n   - Ask: \"Is this for sensitive data?\"\n   - If yes: Recommend generate_key=true for simplicity\n   - If user wants passphrase: min 8 characters\n   - Algorithm: AES-256-GCM recommended\n6. **Compression**: LZ4 (recommended, balanced), ZSTD (modern), GZIP (higher compression), OFF\n7. **Space Quota** (optional): Ask if they want to limit size\n8. **ACL Type** (for SMB): NFSV4 (recommended for SMB/Windows), POSIX (Unix)\n9. **Advanced** (usually skip unless user asks):\n   - Deduplication: Warn about RAM overhead, recommend OFF\n   - Checksum, snapdir, atime, readonly\n\n**IMPORTANT RECOMMENDATIONS:**\n- For SMB shares: share_type=SMB, acltype=NFSV4, compression=LZ4\n- For NFS exports: share_type=NFS, acltype=POSIX, compression=LZ4\n- For multi-protocol: share_type=MULTIPROTOCOL, acltype=NFSV4\n- For apps: share_type=APPS, compression=LZ4 or ZSTD\n- Always recommend compression=LZ4 unless user has specific needs\n- Warn: Deduplication uses several GB of RAM per TB, not recommended for most users. The dry run shows the cost on this system; use estimate_dedup_impact for existing dedup tables\n- Warn: Encryption cannot be removed later, only option is to copy data elsewhere\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview the configuration\n2. Display summary showing: name, type, optimization, compression, encryption, quota, mountpoint\n3. Get explicit user confirmation with \"Shall I proceed?\"\n4. Warn: This is a WRITE operation creating permanent storage\n5. If encryption enabled, remind user to back up the key after creation\n\n**PRESETS:**\nFor common use cases, pass preset (smb-share, nfs-export, app-config, vm-zvol, media-library) instead of setting properties one by one. Explicit arguments override preset values.\n\n**ACL PRESETS:**\nFor SMB or MULTIPROTOCOL datasets, pass apply_acl_preset (OPEN, RESTRICTED, HOME) with optional owner and group to set the NFSv4 ACL right after creation, so no separate permissions step is needed. Ask who should have access: RESTRICTED with an owner/group for team shares, HOME for home directories, OPEN only when everyone on the network may write.\n\n**DRY RUN:**\nSet dry_run=true to preview what will be created without executing. The preview lists which properties are set explicitly (by argument or preset) and which will be inherited from the parent dataset, with their inherited values. Show user the preview, then ask for confirmation to proceed.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Dataset path including pool (e.g., 'tank/shares/documents' or 'pool/apps/immich')",
+					},
+					"preset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional named preset that fills recommended properties (explicit arguments override): smb-share (SMB, NFSV4 ACLs, LZ4), nfs-export (NFS, POSIX ACLs, LZ4), app-config (APPS, LZ4, 16K records, atime off), vm-zvol (VOLUME, 16K volblocksize, LZ4; volsize still required), media-library (SMB, NFSV4 ACLs, LZ4, 1M records, atime off)",
+						"enum":        []string{"smb-share", "nfs-export", "app-config", "vm-zvol", "media-library"},
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"description": "FILESYSTEM (default, for files/directories) or VOLUME (for block storage/iSCSI/VMs)",
+						"enum":        []string{"FILESYSTEM", "VOLUME"},
+						"default":     "FILESYSTEM",
+					},
+					"volsize": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Required for VOLUME type: size in bytes or human-readable (e.g., 1099511627776, \"1TiB\", \"500G\")",
+					},
+					"share_type": map[string]interface{}{
+						"type":        "string",
+						"description": "Optimization hint: GENERIC (default), SMB, NFS, MULTIPROTOCOL, APPS",
+						"enum":        []string{"GENERIC", "SMB", "NFS", "MULTIPROTOCOL", "APPS"},
+					},
+					"compression": map[string]interface{}{
+						"type":        "string",
+						"description": "LZ4 (recommended, balanced), ZSTD (modern), GZIP (higher compression), OFF, or INHERIT (default)",
+						"enum":        []string{"LZ4", "ZSTD", "GZIP", "GZIP-1", "GZIP-9", "OFF", "INHERIT"},
+					},
+					"acltype": map[string]interface{}{
+						"type":        "string",
+						"description": "NFSV4 (recommended for SMB/Windows ACLs) or POSIX (Unix permissions)",
+						"enum":        []string{"NFSV4", "POSIX", "INHERIT"},
+					},
+					"apply_acl_preset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional, for share_type SMB or MULTIPROTOCOL: apply an NFSv4 ACL right after creation so the dataset is ready to share. OPEN (everyone full control), RESTRICTED (owner and group only), HOME (owner full control, group and others read; for home directories)",
+						"enum":        []string{"OPEN", "RESTRICTED", "HOME"},
+					},
+					"owner": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional, with apply_acl_preset: user that owns the dataset root (e.g., 'alice')",
+					},
+					"group": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional, with apply_acl_preset: group that owns the dataset root (e.g., 'staff')",
+					},
+					"encryption_options": map[string]interface{}{
+						"type":        "object",
+						"description": "Encryption configuration (cannot be removed later)",
+						"properties": map[string]interface{}{
+							"generate_key": map[string]interface{}{
+								"type":        "boolean",
+								"description": "Auto-generate encryption key (recommended for simplicity)",
+							},
+							"passphrase": map[string]interface{}{
+								"type":        "string",
+								"description": "User passphrase (min 8 chars) - alternative to generate_key",
+							},
+							"algorithm": map[string]interface{}{
+								"type":        "string",
+								"description": "Encryption algorithm (default: AES-256-GCM recommended)",
+								"enum":        []string{"AES-128-CCM", "AES-192-CCM", "AES-256-CCM", "AES-128-GCM", "AES-192-GCM", "AES-256-GCM"},
+							},
+						},
+					},
+					"quota": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Maximum space for dataset + children, in bytes or human-readable (e.g., 1099511627776, \"1TiB\", \"500G\")",
+					},
+					"refquota": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Maximum space for dataset only (excluding children), in bytes or human-readable",
+					},
+					"create_ancestors": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Auto-create missing parent datasets (default: true)",
+						"default":     true,
+					},
+					"readonly": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Make dataset read-only (default: false)",
+						"default":     false,
+					},
+					"deduplication": map[string]interface{}{
+						"type":        "string",
+						"description": "OFF (recommended), ON, or VERIFY. Warning: Uses ~5GB RAM per TB of storage",
+						"enum":        []string{"OFF", "ON", "VERIFY", "INHERIT"},
+					},
+					"checksum": map[string]interface{}{
+						"type":        "string",
+						"description": "Data integrity algorithm: SHA256 (default), BLAKE3, SHA512, etc.",
+					},
+					"snapdir": map[string]interface{}{
+						"type":        "string",
+						"description": "Snapshot directory visibility: VISIBLE or HIDDEN",
+						"enum":        []string{"VISIBLE", "HIDDEN", "INHERIT"},
+					},
+					"atime": map[string]interface{}{
+						"type":        "string",
+						"description": "File access time tracking: ON or OFF (OFF improves performance)",
+						"enum":        []string{"ON", "OFF", "INHERIT"},
+					},
+					"recordsize": map[string]interface{}{
+						"type":        "string",
+						"description": "Maximum block size for files (e.g., 16K for databases, 128K default, 1M for large media files)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview what will be created without executing, including which properties are set explicitly vs inherited from the parent dataset (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		Handler: handleCreateDataset,
+	}
+
+	// Dataset update (write operation)
+	r.tools["update_dataset"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_dataset",
+			Description: "Change properties of an existing dataset or zvol: compression, quotas and reservations, atime, readonly, recordsize, sync, and more. Pass INHERIT for an enumerated property to clear the local value and take the parent's again (the dry run shows the value that would be inherited). Quotas and reservations cannot be inherited; pass 0 or \"none\" to remove them. Properties already set as requested are skipped. compression, recordsize, checksum, copies, and deduplication only apply to data written afterwards. volsize can only grow. **Use dry_run=true first** to show current values with their source and the planned changes, then confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Dataset or zvol name (e.g., 'tank/shares/documents')",
+					},
+					"compression": map[string]interface{}{
+						"type":        "string",
+						"description": "LZ4, ZSTD, ZSTD-1 to ZSTD-19, GZIP, GZIP-1 to GZIP-9, ZLE, OFF, or INHERIT",
+					},
+					"atime": map[string]interface{}{
+						"type":        "string",
+						"description": "ON, OFF, or INHERIT (filesystems only)",
+						"enum":        []string{"ON", "OFF", "INHERIT"},
+					},
+					"readonly": map[string]interface{}{
+						"type":        []string{"boolean", "string"},
+						"description": "true/false, or ON, OFF, INHERIT",
+					},
+					"exec": map[string]interface{}{
+						"type":        "string",
+						"description": "Allow executing files: ON, OFF, or INHERIT (filesystems only)",
+						"enum":        []string{"ON", "OFF", "INHERIT"},
+					},
+					"sync": map[string]interface{}{
+						"type":        "string",
+						"description": "STANDARD, ALWAYS, DISABLED, or INHERIT. DISABLED risks losing recent writes on power loss",
+						"enum":        []string{"STANDARD", "ALWAYS", "DISABLED", "INHERIT"},
+					},
+					"recordsize": map[string]interface{}{
+						"type":        "string",
+						"description": "Power of two from 512 to 16M (e.g., 16K, 128K, 1M) or INHERIT (filesystems only)",
+					},
+					"deduplication": map[string]interface{}{
+						"type":        "string",
+						"description": "OFF, ON, VERIFY, or INHERIT. Warning: the dedup table uses several GB of RAM per TB",
+						"enum":        []string{"OFF", "ON", "VERIFY", "INHERIT"},
+					},
+					"checksum": map[string]interface{}{
+						"type":        "string",
+						"description": "ON, FLETCHER2, FLETCHER4, SHA256, SHA512, SKEIN, EDONR, BLAKE3, or INHERIT",
+					},
+					"snapdir": map[string]interface{}{
+						"type":        "string",
+						"description": "VISIBLE, HIDDEN, or INHERIT (filesystems only)",
+						"enum":        []string{"VISIBLE", "HIDDEN", "INHERIT"},
+					},
+					"acltype": map[string]interface{}{
+						"type":        "string",
+						"description": "NFSV4, POSIX, OFF, or INHERIT (filesystems only). Existing permissions are not converted",
+						"enum":        []string{"NFSV4", "POSIX", "OFF", "INHERIT"},
+					},
+					"copies": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Copies of each block: 1, 2, 3, or INHERIT",
+					},
+					"quota": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Limit for the dataset and its children, in bytes or human-readable (\"500G\"); 0 or \"none\" removes it",
+					},
+					"refquota": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Limit for the dataset itself excluding children and snapshots; 0 or \"none\" removes it",
+					},
+					"reservation": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Space guaranteed to the dataset and its children; 0 or \"none\" removes it",
+					},
+					"refreservation": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Space guaranteed to the dataset itself; 0 or \"none\" removes it",
+					},
+					"volsize": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "New size of a zvol, in bytes or human-readable; must not be smaller than the current size",
+					},
+					"comments": map[string]interface{}{
+						"type":        "string",
+						"description": "Free-form description of the dataset; empty string clears it",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the changes with current values and their source without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		Handler: handleUpdateDatasetWithDryRun,
+	}
+
+	// Dataset deletion (destructive operation)
+	r.tools["delete_dataset"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_dataset",
+			Description: "Permanently destroy a dataset or zvol with all its data and snapshots. Child datasets require recursive=true. Refuses pool root datasets, snapshots that are held or have clones elsewhere, and the system or apps dataset. Shares, apps, VMs, and tasks using the dataset block the deletion unless ignore_consumers=true (see what_uses_this_dataset). When the server runs with a deletion grace period, the deletion is queued and can be cancelled with undo_pending_deletion until it executes. **Always use dry_run=true first**: it lists the child datasets, snapshots, and consumers that are affected. Confirm with the user; this cannot be undone.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Dataset or zvol name (e.g., 'tank/old-data')",
+					},
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also destroy child datasets and zvols (default: false)",
+						"default":     false,
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Unmount even if files are open (default: false)",
+						"default":     false,
+					},
+					"ignore_consumers": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Delete even though shares, apps, VMs, or tasks use the dataset; they break afterwards (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview what would be destroyed without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		Handler: r.handleDeleteDatasetWithDryRun,
+	}
+
+	// Filesystem permissions
+	r.tools["get_acl"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_acl",
+			Description: "Show who can access a path: ACL type (NFS4 or POSIX1E), owner, group, and the ACL entries in a simplified form with a one-line summary each. Use before set_acl or set_permissions and to check a share's access.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path under /mnt (e.g., '/mnt/tank/shares/docs')",
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		Handler: handleGetACL,
+	}
+
+	r.tools["set_acl"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "set_acl",
+			Description: "Replace the ACL of a path, from a template or explicit entries, optionally changing owner and group. Runs as a background job tracked with tasks_get.\n\n**TEMPLATES:**\n- smb_group_full_control: owner and group get full control, nobody else (typical team share)\n- smb_group_modify: group can read, write, and delete but not change permissions\n- smb_group_read_only: group can only read\n- smb_owner_only: only the owner has access\n- smb_everyone_modify: everyone can read, write, and delete\n- posix_group_rwx / posix_group_read_only: the same for datasets with POSIX ACLs\nGroup templates need group, which also becomes the owning group. smb_ templates need an NFSv4 ACL; posix_ templates a POSIX ACL.\n\n**ENTRIES:**\nNFSv4: {tag: owner@|group@|everyone@|USER|GROUP, who, type: ALLOW|DENY, perms: FULL_CONTROL|MODIFY|READ|TRAVERSE, inherit}. POSIX: {tag: USER_OBJ|GROUP_OBJ|OTHER|MASK|USER|GROUP, who, perms: rwx, default}.\n\n**Always use dry_run=true first**: it shows the entries that are added and removed and the owner/group change. Confirm with the user before applying, especially with recursive=true.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path under /mnt (e.g., '/mnt/tank/shares/docs')",
+					},
+					"template": map[string]interface{}{
+						"type":        "string",
+						"description": "ACL template to apply instead of entries",
+						"enum":        aclTemplateNames(),
+					},
+					"entries": map[string]interface{}{
+						"type":        "array",
+						"description": "ACL entries to set instead of a template; they replace the whole ACL",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"tag":     map[string]interface{}{"type": "string"},
+								"who":     map[string]interface{}{"type": "string", "description": "User or group name for USER and GROUP entries"},
+								"type":    map[string]interface{}{"type": "string", "enum": []string{"ALLOW", "DENY"}},
+								"perms":   map[string]interface{}{"type": "string"},
+								"inherit": map[string]interface{}{"type": "boolean", "description": "NFSv4: new files and directories inherit the entry"},
+								"default": map[string]interface{}{"type": "boolean", "description": "POSIX: default entry for new files"},
+							},
+							"required": []string{"tag", "perms"},
+						},
+					},
+					"owner": map[string]interface{}{
+						"type":        "string",
+						"description": "New owning user (optional)",
+					},
+					"group": map[string]interface{}{
+						"type":        "string",
+						"description": "New owning group; required by group templates, which grant it access",
+					},
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Apply to everything below the path (default: false)",
+						"default":     false,
+					},
+					"traverse": map[string]interface{}{
+						"type":        "boolean",
+						"description": "With recursive, also descend into child datasets (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the ACL diff without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		Handler: r.handleSetACLWithDryRun,
+	}
+
+	r.tools["set_permissions"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "set_permissions",
+			Description: "Change the owner, group, and/or Unix mode of a path (e.g. mode 770: owner and group full access, others none; 750: group read-only; 755: everyone can read). Paths with an ACL need strip_acl=true to set a mode, which removes the ACL; use set_acl to edit ACL entries instead. Owner/group-only changes keep the ACL. Runs as a background job tracked with tasks_get. Use dry_run=true to preview the change.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path under /mnt (e.g., '/mnt/tank/apps/config')",
+					},
+					"owner": map[string]interface{}{
+						"type":        "string",
+						"description": "New owning user (optional)",
+					},
+					"group": map[string]interface{}{
+						"type":        "string",
+						"description": "New owning group (optional)",
+					},
+					"mode": map[string]interface{}{
+						"type":        "string",
+						"description": "Octal mode such as 770, 750, 755, or 700 (optional)",
+					},
+					"strip_acl": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Remove an existing ACL so the mode applies (default: false)",
+						"default":     false,
+					},
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Apply to everything below the path (default: false)",
+						"default":     false,
+					},
+					"traverse": map[string]interface{}{
+						"type":        "boolean",
+						"description": "With recursive, also descend into child datasets (default: false)",
+						"default":     false,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the change without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		Handler: r.handleSetPermissionsWithDryRun,
+	}
+
+	// SMB share creation (write operation)
+	r.tools["create_smb_share"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_smb_share",
+			Description: "Create an SMB (Windows/macOS file sharing) share. This makes a ZFS dataset accessible over the network via the SMB/CIFS protocol.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create SMB shares, follow this conversation flow:\n\n**1. Dataset Selection:**\n- Ask: \"Do you want to create a new dataset or use an existing ZFS dataset?\"\n- If NEW: Use create_dataset tool first (with share_type=SMB, acltype=NFSV4)\n- If EXISTING: \n  * Query available datasets first with query_datasets\n  * Present options to user (NEVER suggest pool root like 'tank' or 'flash')\n  * Use the dataset's mountpoint as the path\n  * Warn: \"Never share a pool root - always use a child dataset\"\n- After dataset creation, use its mountpoint as the path\n\n**2. Share Name:**\n- Ask: \"What name should appear when browsing the network?\"\n- Rules: Max 80 chars, no \\ / [ ] : | < > + = ; , * ? \"\n- Cannot use: global, printers, homes\n- Suggest: Use a friendly, descriptive name like \"TeamDocs\" or \"PhotoArchive\"\n\n**3. Description:**\n- Ask: \"Add a description?\" (optional, shown when browsing shares)\n\n**4. Purpose Selection:**\n- Ask: \"What's this share for?\"\n- Options:\n  * DEFAULT_SHARE: Standard file sharing (most common)\n  * TIMEMACHINE_SHARE: macOS Time Machine backups\n  * MULTIPROTOCOL_SHARE: Both SMB and NFS access (complex permissions)\n  * PRIVATE_DATASETS_SHARE: User home directories\n  * VEEAM_REPOSITORY_SHARE: Veeam backup storage\n- Recommend DEFAULT_SHARE unless specific use case\n\n**5. Access Control:**\n- Ask: \"Read-only or read-write?\" (default: read-write)\n- Ask: \"Should it be visible when browsing?\" (default: yes)\n- Ask: \"Restrict to specific IP addresses?\" (optional, for hostsallow)\n- Ask: \"Hide from unauthorized users?\" (access_based_share_enumeration)\n\n**6. Purpose-Specific Questions:**\n\nFor TIMEMACHINE_SHARE:\n- Ask: \"What's the backup size limit?\" (recommend 2-3x Mac's disk size)\n- Set time_machine_quota in options\n\nFor MULTIPROTOCOL_SHARE:\n- Warn: \"Multi-protocol shares have complex permission interactions\"\n- Recommend: \"Use either SMB OR NFS, not both, unless you understand the implications\"\n\nFor PRIVATE_DATASETS_SHARE:\n- Suggest: \"Create separate datasets per user for isolation\"\n- Recommend: \"Use access_based_share_enumeration=true\"\n\n**7. Permissions:**\n- Ask: \"Which user and group should own the share?\" (owner, group)\n- Ask: \"Apply an ACL preset?\" (acl_preset, e.g., NFS4_RESTRICTED for owner/group only, NFS4_OPEN for everyone)\n- These are applied to the path right after the share is created, so no UI step is needed\n\n**8. Auditing (Optional):**\n- Ask: \"Enable access auditing?\" (tracks who accesses files)\n- If yes: Ask which groups to audit (empty = audit all)\n\n**IMPORTANT RECOMMENDATIONS:**\n- Default: enabled=true, browsable=true, readonly=false\n- For sensitive data: Set access_based_share_enumeration=true\n- For public shares: Use hostsdeny to block unwanted networks\n- For Time Machine: Set appropriate quota to prevent filling pool\n- For multi-protocol: Strongly recommend against unless necessary\n\n**SECURITY WARNINGS TO DISPLAY:**\n- If browsable=true + no hostsallow: \"Share visible and accessible from any network\"\n- If readonly=false: \"Users can modify, delete, and create files\"\n- If no access restrictions: \"Anyone on your network can access this share\"\n- If no owner/group/acl_preset: Offer to set permissions afterwards with set_acl (e.g. template smb_group_full_control) or set_permissions\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview the configuration\n2. Display complete summary including:\n   - Share name and network path (\\\\truenas\\sharename)\n   - Local path\n   - Purpose and access settings\n   - Security warnings if applicable\n3. Get explicit user confirmation: \"Shall I create this share?\"\n4. Warn: \"This is a WRITE operation that exposes data over your network\"\n5. After creation: Report the permissions job if owner/group/acl_preset were set\n\n**DRY RUN:**\nSet dry_run=true to preview what will be created without executing. Show user the preview including security warnings, then ask for confirmation.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Share name visible to clients (max 80 chars, case-insensitive, must be unique)",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "ZFS dataset mountpoint starting with /mnt/ (e.g., /mnt/tank/shares/docs, NOT /mnt/tank). Use 'EXTERNAL' only for DFS proxy shares.",
+					},
+					"purpose": map[string]interface{}{
+						"type":        "string",
+						"description": "Share purpose: DEFAULT_SHARE (standard), TIMEMACHINE_SHARE (macOS backups), MULTIPROTOCOL_SHARE (SMB+NFS), PRIVATE_DATASETS_SHARE (home dirs)",
+						"enum":        []string{"DEFAULT_SHARE", "LEGACY_SHARE", "TIMEMACHINE_SHARE", "MULTIPROTOCOL_SHARE", "TIME_LOCKED_SHARE", "PRIVATE_DATASETS_SHARE", "EXTERNAL_SHARE", "VEEAM_REPOSITORY_SHARE", "FCP_SHARE"},
+						"default":     "DEFAULT_SHARE",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Enable share for network access (default: true)",
+						"default":     true,
+					},
+					"comment": map[string]interface{}{
+						"type":        "string",
+						"description": "Description shown when clients list shares (optional)",
+					},
+					"readonly": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Prevent clients from creating/modifying files (default: false)",
+						"default":     false,
+					},
+					"browsable": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Show share in network browse lists (default: true)",
+						"default":     true,
+					},
+					"access_based_share_enumeration": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Hide share from users without filesystem ACL access (default: false)",
+						"default":     false,
+					},
+					"hostsallow": map[string]interface{}{
+						"type":        "array",
+						"description": "IP addresses/networks allowed to access (empty = allow all)",
+						"items": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"hostsdeny": map[string]interface{}{
+						"type":        "array",
+						"description": "IP addresses/networks denied access (empty = deny none)",
+						"items": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"audit": map[string]interface{}{
+						"type":        "object",
+						"description": "Audit configuration for tracking file access",
+						"properties": map[string]interface{}{
+							"enable": map[string]interface{}{
+								"type":        "boolean",
+								"description": "Enable audit logging",
+							},
+							"watch_list": map[string]interface{}{
+								"type":        "array",
+								"description": "Groups to audit (empty = audit all)",
+								"items": map[string]interface{}{
+									"type": "string",
+								},
+							},
+							"ignore_list": map[string]interface{}{
+								"type":        "array",
+								"description": "Groups to exclude from auditing",
+								"items": map[string]interface{}{
+									"type": "string",
+								},
+							},
+						},
+					},
+					"options": map[string]interface{}{
+						"type":        "object",
+						"description": "Purpose-specific options (varies by purpose)",
+					},
+					"owner": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: user to own the share path, applied recursively right after the share is created",
+					},
+					"group": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: group to own the share path, applied recursively right after the share is created",
+					},
+					"acl_preset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: ACL template applied to the share path right after creation (e.g., NFS4_RESTRICTED, NFS4_OPEN, NFS4_HOME for NFSv4 ACL datasets). owner/group become the ACL owner.",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview what will be created without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"name", "path"},
+			},
+		},
+		Handler: handleCreateSMBShare,
+	}
+
+	r.tools["delete_smb_share"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_smb_share",
+			Description: "Delete an SMB share. Only the share is removed; the dataset and files stay. When the server runs with a deletion grace period, the deletion is queued and can be cancelled with undo_pending_deletion until it executes. **Use dry_run=true first** and confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Share name (from query_shares)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without deleting (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		Handler: r.handleDeleteSMBShareWithDryRun,
+	}
+
+	r.tools["list_pending_deletions"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_pending_deletions",
+			Description: "List deletions waiting out the deletion grace period, with when each will execute. Use to review what is about to be deleted and to find the id for undo_pending_deletion.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"include_finished": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Also list recently executed, failed, and cancelled deletions (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleListPendingDeletions,
+	}
+
+	r.tools["undo_pending_deletion"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "undo_pending_deletion",
+			Description: "Cancel a deletion that is still within its grace period, so the object is never deleted. Deletions that already executed cannot be undone.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Pending deletion ID (from the delete tool's response or list_pending_deletions)",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: r.handleUndoPendingDeletion,
+	}
+
+	r.tools["list_scheduled_operations"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_scheduled_operations",
+			Description: "List write operations queued with schedule_at or maintenance_window, with when each runs and, once finished, its result. Use to review upcoming maintenance and to find the id for cancel_scheduled_operation.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"include_finished": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Also list completed, failed, missed, and cancelled operations (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleListScheduledOperations,
+	}
+
+	r.tools["cancel_scheduled_operation"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "cancel_scheduled_operation",
+			Description: "Cancel a queued operation before it runs. Operations that already started cannot be cancelled.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Scheduled operation ID (from the tool's response or list_scheduled_operations)",
+					},
+				},
+				"required": []string{"id"},
+			},
+		},
+		Handler: r.handleCancelScheduledOperation,
+	}
+
+	// NFS share creation (write operation)
+	r.tools["create_nfs_share"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_nfs_share",
+			Description: "Create an NFS (Network File System) share for Unix/Linux file sharing. This makes a ZFS dataset accessible over the network via the NFS protocol.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create NFS shares, follow this conversation flow:\n\n**1. Dataset Selection:**\n- Ask: \"Do you want to create a new dataset or use an existing ZFS dataset?\"\n- If NEW: Use create_dataset tool first (with share_type=NFS, acltype=POSIX)\n- If EXISTING: \n  * Query available datasets first with query_datasets\n  * Present options to user (NEVER suggest pool root like 'tank' or 'flash')\n  * Use the dataset's mountpoint as the path\n  * Warn: \"Never share a pool root - always use a child dataset\"\n- After dataset creation, use its mountpoint as the path\n\n**2. Access Control:**\n- Ask: \"Read-only or read-write?\" (default: read-write)\n- Ask: \"Restrict to specific networks?\" (CIDR notation: 192.168.1.0/24)\n- Ask: \"Restrict to specific hosts?\" (IP addresses or hostnames)\n- Recommend: At least one restriction (network or host) for security\n\n**3. User Mapping (Important for Security):**\n- Ask: \"How should root access be handled?\"\n  * **maproot_user**: Map root clients to specific user (recommended: 'nobody')\n  * **maproot_group**: Map root clients to specific group (recommended: 'nogroup')\n  * Warn if not set: \"Root clients will have full root access (security risk)\"\n- Ask: \"Map all users to a specific user?\" (optional, for anonymous access)\n  * **mapall_user**: Maps all clients to one user\n  * **mapall_group**: Maps all client groups to one group\n\n**4. Permissions:**\n- Ask: \"Which user and group should own the exported files?\" (owner, group)\n- Optional: acl_preset (e.g., POSIX_RESTRICTED, POSIX_OPEN) applied to the path right after creation\n\n**5. Security Level (Optional):**\n- Default: SYS (system authentication)\n- Advanced: KRB5, KRB5I, KRB5P (Kerberos, requires setup)\n- Usually skip unless user specifically needs Kerberos\n\n**IMPORTANT RECOMMENDATIONS:**\n- For NFS shares: share_type=NFS, acltype=POSIX (in dataset creation)\n- Compression: LZ4 recommended for balanced performance\n- Always set maproot_user='nobody' to prevent root access\n- Use network/host restrictions to limit access\n- Read-only for shared data that shouldn't be modified\n\n**SECURITY WARNINGS TO DISPLAY:**\n- If no network/host restrictions: \"Share accessible from any host\"\n- If no maproot_user: \"Root clients will have full root access\"\n- If read-write + no restrictions: \"Any host can modify/delete files\"\n- If the NFS service is not running: Offer to start it with start_service (service=nfs) and set_service_autostart\n- Remind: \"Ensure the firewall allows NFS traffic (port 2049)\"\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview the configuration\n2. Display complete summary including:\n   - Local path\n   - Access type (read-only/read-write)\n   - Network/host restrictions\n   - User mapping settings\n   - Security warnings if applicable\n3. Get explicit user confirmation: \"Shall I create this NFS share?\"\n4. Warn: \"This is a WRITE operation that exposes data over your network\"\n5. After creation: Run verify_nfs_export to confirm the export is active and get client mount commands\n\n**DRY RUN:**\nSet dry_run=true to preview what will be created without executing. Show user the preview including security warnings, then ask for confirmation.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "ZFS dataset mountpoint starting with /mnt/ (e.g., /mnt/tank/shares/data, NOT /mnt/tank)",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Enable share for network access (default: true)",
+						"default":     true,
+					},
+					"comment": map[string]interface{}{
+						"type":        "string",
+						"description": "Description for the share (optional)",
+					},
+					"ro": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Read-only export (default: false for read-write)",
+						"default":     false,
+					},
+					"networks": map[string]interface{}{
+						"type":        "array",
+						"description": "Authorized networks in CIDR notation (e.g., ['192.168.1.0/24']). Empty = allow all networks.",
+						"items": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"hosts": map[string]interface{}{
+						"type":        "array",
+						"description": "Authorized IP addresses or hostnames (e.g., ['192.168.1.10', 'client.local']). No quotes or spaces. Empty = allow all hosts.",
+						"items": map[string]interface{}{
+							"type": "string",
+						},
+					},
+					"maproot_user": map[string]interface{}{
+						"type":        "string",
+						"description": "Map root clients to this user (recommended: 'nobody' for security)",
+					},
+					"maproot_group": map[string]interface{}{
+						"type":        "string",
+						"description": "Map root clients to this group (recommended: 'nogroup' for security)",
+					},
+					"mapall_user": map[string]interface{}{
+						"type":        "string",
+						"description": "Map all clients to this user (optional, for anonymous access)",
+					},
+					"mapall_group": map[string]interface{}{
+						"type":        "string",
+						"description": "Map all client groups to this group (optional, for anonymous access)",
+					},
+					"security": map[string]interface{}{
+						"type":        "array",
+						"description": "Security mechanisms: ['SYS'] (default), ['KRB5'], ['KRB5I'], ['KRB5P']",
+						"items": map[string]interface{}{
+							"type": "string",
+							"enum": []string{"SYS", "KRB5", "KRB5I", "KRB5P"},
+						},
+					},
+					"owner": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: user to own the share path, applied recursively right after the share is created",
 					},
-					"limit": map[string]interface{}{
-						"type":        "integer",
-						"description": "Optional: Maximum number of VMs to return (default: 50)",
+					"group": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: group to own the share path, applied recursively right after the share is created",
 					},
-					"order_by": map[string]interface{}{
+					"acl_preset": map[string]interface{}{
 						"type":        "string",
-						"description": "Optional: Sort by 'name' (default, alphabetical), 'memory' (descending), or 'status' (running first)",
-						"enum":        []string{"name", "memory", "status"},
+						"description": "Optional: ACL template applied to the share path right after creation (e.g., POSIX_RESTRICTED, POSIX_OPEN, POSIX_HOME for POSIX ACL datasets). owner/group become the ACL owner.",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview what will be created without executing (default: false)",
+						"default":     false,
 					},
 				},
+				"required": []string{"path"},
 			},
 		},
-		Handler: handleQueryVMs,
+		Handler: handleCreateNFSShare,
 	}
 
-	// Dataset creation (write operation)
-	r.tools["create_dataset"] = Tool{
+	// NFS export verification
+	r.tools["verify_nfs_export"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "create_dataset",
-			Description: "Create a ZFS dataset (filesystem or volume) for storage. This tool is reusable for SMB shares, NFS exports, iSCSI LUNs, and application storage. Supports encryption, compression, quotas, and advanced ZFS features.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create datasets, ask these questions in order:\n\n1. **Pool Selection**: Query available pools first, ask which pool to use\n2. **Dataset Name**: Suggest format 'pool/shares/name' or 'pool/apps/name'\n3. **Dataset Type**: FILESYSTEM (default, for files) or VOLUME (for block storage/VMs)\n4. **Share Type Optimization** (if for sharing):\n   - SMB: Windows/Mac file shares (recommend for SMB shares)\n   - NFS: Unix/Linux file shares\n   - MULTIPROTOCOL: Both SMB and NFS access\n   - APPS: Application storage\n   - GENERIC: General purpose (default)\n5. **Encryption** (recommend for sensitive data):\n   - Ask: \"Is this for sensitive data?\"\n   - If yes: Recommend generate_key=true for simplicity\n   - If user wants passphrase: min 8 characters\n   - Algorithm: AES-256-GCM recommended\n6. **Compression**: LZ4 (recommended, balanced), ZSTD (modern), GZIP (higher compression), OFF\n7. **Space Quota** (optional): Ask if they want to limit size\n8. **ACL Type** (for SMB): NFSV4 (recommended for SMB/Windows), POSIX (Unix)\n9. **Advanced** (usually skip unless user asks):\n   - Deduplication: Warn about RAM overhead, recommend OFF\n   - Checksum, snapdir, atime, readonly\n\n**IMPORTANT RECOMMENDATIONS:**\n- For SMB shares: share_type=SMB, acltype=NFSV4, compression=LZ4\n- For NFS exports: share_type=NFS, acltype=POSIX, compression=LZ4\n- For multi-protocol: share_type=MULTIPROTOCOL, acltype=NFSV4\n- For apps: share_type=APPS, compression=LZ4 or ZSTD\n- Always recommend compression=LZ4 unless user has specific needs\n- Warn: Deduplication uses ~5GB RAM per TB, not recommended for most users\n- Warn: Encryption cannot be removed later, only option is to copy data elsewhere\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview the configuration\n2. Display summary showing: name, type, optimization, compression, encryption, quota, mountpoint\n3. Get explicit user confirmation with \"Shall I proceed?\"\n4. Warn: This is a WRITE operation creating permanent storage\n5. If encryption enabled, remind user to back up the key after creation\n\n**DRY RUN:**\nSet dry_run=true to preview what will be created without executing. Show user the preview, then ask for confirmation to proceed.",
+			Name:        "verify_nfs_export",
+			Description: "Verify that an NFS share is ready for clients: confirms the share is enabled and its dataset unlocked (so the middleware exports it), checks the NFS service is running, and returns ready-to-paste mount commands for Linux (including an fstab line) and macOS based on the enabled NFS protocol versions. Use after create_nfs_share or when a client cannot mount.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{
-						"type":        "string",
-						"description": "Dataset path including pool (e.g., 'tank/shares/documents' or 'pool/apps/immich')",
-					},
-					"type": map[string]interface{}{
+					"path": map[string]interface{}{
 						"type":        "string",
-						"description": "FILESYSTEM (default, for files/directories) or VOLUME (for block storage/iSCSI/VMs)",
-						"enum":        []string{"FILESYSTEM", "VOLUME"},
-						"default":     "FILESYSTEM",
+						"description": "Exported path (e.g., /mnt/tank/shares/data)",
 					},
-					"volsize": map[string]interface{}{
+					"id": map[string]interface{}{
 						"type":        "integer",
-						"description": "Required for VOLUME type: size in bytes (e.g., 1099511627776 for 1TB)",
+						"description": "NFS share ID (alternative to path)",
 					},
-					"share_type": map[string]interface{}{
+				},
+			},
+		},
+		Handler: handleVerifyNFSExport,
+	}
+
+	// Local users and groups
+	r.tools["query_users"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_users",
+			Description: "List local users with uid, primary and auxiliary groups, home, shell, SMB access, and whether they are locked or have an SSH key. Password hashes and SSH keys are never returned. Built-in system accounts are hidden unless include_builtin is set.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"username": map[string]interface{}{
 						"type":        "string",
-						"description": "Optimization hint: GENERIC (default), SMB, NFS, MULTIPROTOCOL, APPS",
-						"enum":        []string{"GENERIC", "SMB", "NFS", "MULTIPROTOCOL", "APPS"},
+						"description": "Optional: Only this user",
 					},
-					"compression": map[string]interface{}{
+					"group": map[string]interface{}{
 						"type":        "string",
-						"description": "LZ4 (recommended, balanced), ZSTD (modern), GZIP (higher compression), OFF, or INHERIT (default)",
-						"enum":        []string{"LZ4", "ZSTD", "GZIP", "GZIP-1", "GZIP-9", "OFF", "INHERIT"},
+						"description": "Optional: Only members of this group (primary or auxiliary)",
 					},
-					"acltype": map[string]interface{}{
+					"include_builtin": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Include built-in system accounts such as root (default: false)",
+					},
+				},
+			},
+		},
+		Handler: handleQueryUsers,
+	}
+
+	userProperties := func() map[string]interface{} {
+		return map[string]interface{}{
+			"full_name": map[string]interface{}{
+				"type":        "string",
+				"description": "Full name",
+			},
+			"password": map[string]interface{}{
+				"type":        "string",
+				"description": "Password (needed for SMB access)",
+			},
+			"password_disabled": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Disable password login (SSH key only; not allowed with smb)",
+			},
+			"smb": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Allow SMB share access",
+			},
+			"groups": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Auxiliary group names; replaces the current list",
+			},
+			"home": map[string]interface{}{
+				"type":        "string",
+				"description": "Home directory under /mnt/ (created if missing), or /var/empty for none",
+			},
+			"shell": map[string]interface{}{
+				"type":        "string",
+				"description": "Login shell (e.g., /usr/bin/bash, /usr/sbin/nologin)",
+			},
+			"ssh_public_key": map[string]interface{}{
+				"type":        "string",
+				"description": "OpenSSH public key for SSH login",
+			},
+			"email": map[string]interface{}{
+				"type":        "string",
+				"description": "Email address for notifications",
+			},
+			"locked": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Lock the account so it cannot log in",
+			},
+		}
+	}
+
+	createUserProps := userProperties()
+	createUserProps["username"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Required: Login name (letters, digits, '.', '_', '-'; max 32)",
+	}
+	createUserProps["full_name"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Required: Full name",
+	}
+	createUserProps["group"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Optional: Existing primary group name (default: a new group named after the user)",
+	}
+	createUserProps["uid"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Optional: UID (default: next free)",
+	}
+	createUserProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without creating (default: false)",
+		"default":     false,
+	}
+	r.tools["create_user"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_user",
+			Description: "Create a local user, e.g. to own or access SMB/NFS shares. SMB access is on by default and needs a password. Without group, a primary group named after the user is created. Use dry_run=true to preview.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": createUserProps,
+				"required":   []string{"username", "full_name"},
+			},
+		},
+		Handler: handleCreateUserWithDryRun,
+	}
+
+	updateUserProps := userProperties()
+	updateUserProps["username"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Required: User to update",
+	}
+	updateUserProps["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Optional: Preview without updating (default: false)",
+		"default":     false,
+	}
+	r.tools["update_user"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "update_user",
+			Description: "Update a local user: password, groups, SMB access, lock state, shell, home, SSH key, or email. Only given fields change; groups replaces the auxiliary group list. Built-in accounts cannot be changed. Use dry_run=true to preview.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": updateUserProps,
+				"required":   []string{"username"},
+			},
+		},
+		Handler: handleUpdateUserWithDryRun,
+	}
+
+	r.tools["delete_user"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "delete_user",
+			Description: "Delete a local user. Its files and home directory are kept but become unowned, and shares naming the user stop granting access. The primary group is deleted too unless other users use it. **Use dry_run=true first.**",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"username": map[string]interface{}{
 						"type":        "string",
-						"description": "NFSV4 (recommended for SMB/Windows ACLs) or POSIX (Unix permissions)",
-						"enum":        []string{"NFSV4", "POSIX", "INHERIT"},
+						"description": "Required: User to delete",
 					},
-					"encryption_options": map[string]interface{}{
-						"type":        "object",
-						"description": "Encryption configuration (cannot be removed later)",
-						"properties": map[string]interface{}{
-							"generate_key": map[string]interface{}{
-								"type":        "boolean",
-								"description": "Auto-generate encryption key (recommended for simplicity)",
-							},
-							"passphrase": map[string]interface{}{
-								"type":        "string",
-								"description": "User passphrase (min 8 chars) - alternative to generate_key",
-							},
-							"algorithm": map[string]interface{}{
-								"type":        "string",
-								"description": "Encryption algorithm (default: AES-256-GCM recommended)",
-								"enum":        []string{"AES-128-CCM", "AES-192-CCM", "AES-256-CCM", "AES-128-GCM", "AES-192-GCM", "AES-256-GCM"},
-							},
-						},
+					"delete_primary_group": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Delete the user's primary group when no one else uses it (default: true)",
 					},
-					"quota": map[string]interface{}{
-						"type":        "integer",
-						"description": "Maximum space for dataset + children in bytes (e.g., 1099511627776 for 1TB)",
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without deleting (default: false)",
+						"default":     false,
 					},
-					"refquota": map[string]interface{}{
+				},
+				"required": []string{"username"},
+			},
+		},
+		Handler: handleDeleteUserWithDryRun,
+	}
+
+	r.tools["query_groups"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_groups",
+			Description: "List local groups with gid, SMB flag, and members. Built-in system groups are hidden unless include_builtin is set.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only this group",
+					},
+					"include_builtin": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Include built-in system groups (default: false)",
+					},
+				},
+			},
+		},
+		Handler: handleQueryGroups,
+	}
+
+	r.tools["create_group"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "create_group",
+			Description: "Create a local group, e.g. for share permissions. Optionally add existing users as members. Use dry_run=true to preview.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Group name (letters, digits, '.', '_', '-'; max 32)",
+					},
+					"gid": map[string]interface{}{
 						"type":        "integer",
-						"description": "Maximum space for dataset only (excluding children) in bytes",
+						"description": "Optional: GID (default: next free)",
 					},
-					"create_ancestors": map[string]interface{}{
+					"smb": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Auto-create missing parent datasets (default: true)",
-						"default":     true,
+						"description": "Optional: Usable in SMB share ACLs (default: true)",
 					},
-					"readonly": map[string]interface{}{
+					"members": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Usernames to add to the group",
+					},
+					"dry_run": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Make dataset read-only (default: false)",
+						"description": "Optional: Preview without creating (default: false)",
 						"default":     false,
 					},
-					"deduplication": map[string]interface{}{
+				},
+				"required": []string{"name"},
+			},
+		},
+		Handler: handleCreateGroupWithDryRun,
+	}
+
+	// Alert list with filtering
+	r.tools["list_alerts"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_alerts",
+			Description: "List system alerts, most recent first, with optional filtering by dismissed status. Served from the server's event-fed cache when enabled; freshness shows whether the data came from the cache or a live query and when it was last fully synced.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dismissed": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Filter by dismissed status (true=dismissed only, false=active only, omit=all)",
+					},
+					"live": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Query the middleware directly instead of the event cache (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleListAlerts,
+	}
+
+	// Dismiss alert
+	r.tools["dismiss_alert"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "dismiss_alert",
+			Description: "Dismiss a system alert by UUID",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uuid": map[string]interface{}{
 						"type":        "string",
-						"description": "OFF (recommended), ON, or VERIFY. Warning: Uses ~5GB RAM per TB of storage",
-						"enum":        []string{"OFF", "ON", "VERIFY", "INHERIT"},
+						"description": "UUID of the alert to dismiss",
 					},
-					"checksum": map[string]interface{}{
+				},
+				"required": []string{"uuid"},
+			},
+		},
+		Handler: handleDismissAlert,
+	}
+
+	// Restore alert
+	r.tools["restore_alert"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "restore_alert",
+			Description: "Restore (un-dismiss) a previously dismissed alert by UUID",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uuid": map[string]interface{}{
 						"type":        "string",
-						"description": "Data integrity algorithm: SHA256 (default), BLAKE3, SHA512, etc.",
+						"description": "UUID of the alert to restore",
 					},
-					"snapdir": map[string]interface{}{
+				},
+				"required": []string{"uuid"},
+			},
+		},
+		Handler: handleRestoreAlert,
+	}
+
+	// Reporting graph discovery
+	r.tools["list_reporting_graphs"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_reporting_graphs",
+			Description: "List every reporting graph the system collects (e.g., cpu, cputemp, arcsize, nfsstat, upscharge, disktemp) with its title, unit, and identifiers (disks, interfaces, UPS names, etc.). Use this to discover which metrics exist beyond the fixed sets in the get_*_metrics tools.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"filter": map[string]interface{}{
 						"type":        "string",
-						"description": "Snapshot directory visibility: VISIBLE or HIDDEN",
-						"enum":        []string{"VISIBLE", "HIDDEN", "INHERIT"},
+						"description": "Optional: Case-insensitive substring to match against graph names and titles (e.g., 'arc', 'nfs', 'temp')",
+					},
+					"include_identifiers": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Include each graph's identifier list (default: true). Set false for a compact overview.",
+						"default":     true,
+					},
+				},
+			},
+		},
+		Handler: handleListReportingGraphs,
+	}
+
+	// Middleware API introspection
+	r.tools["describe_api_method"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "describe_api_method",
+			Description: "Describe a TrueNAS middleware API method from the system's own schema (core.get_methods): description, positional parameters with types, required fields, defaults, and enums, whether it runs as a job, and the roles it needs. Pass a service name (e.g. 'pool.dataset') to list its methods. Use it to find correct parameters for methods no tool covers.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"method": map[string]interface{}{
+						"type":        "string",
+						"description": "Method name (e.g., 'pool.dataset.create') or service name (e.g., 'sharing.smb')",
+					},
+					"full_schema": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Include the complete accepts/returns JSON schema (default: false; it can be large)",
+						"default":     false,
+					},
+				},
+				"required": []string{"method"},
+			},
+		},
+		Handler: handleDescribeAPIMethod,
+	}
+
+	// Tool catalog export
+	r.tools["describe_tools"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "describe_tools",
+			Description: "Export this server's tool catalog as a machine-readable bundle: every exposed tool (after read-only mode and the tool filter) with its description, full input schema, and whether it writes to TrueNAS or supports dry_run. Use format 'openapi' for an OpenAPI 3.1 document with one operation per tool. Useful for validating arguments, generating documentation, or diffing the tool surface between releases.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tools": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Tool names or * wildcard patterns to include (e.g., ['query_*', 'create_dataset']). Default: all tools",
 					},
-					"atime": map[string]interface{}{
+					"format": map[string]interface{}{
 						"type":        "string",
-						"description": "File access time tracking: ON or OFF (OFF improves performance)",
-						"enum":        []string{"ON", "OFF", "INHERIT"},
-					},
-					"dry_run": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Preview what will be created without executing (default: false)",
-						"default":     false,
+						"enum":        []string{"json", "openapi"},
+						"description": "Bundle format (default: json)",
+						"default":     "json",
 					},
 				},
-				"required": []string{"name"},
 			},
 		},
-		Handler: handleCreateDataset,
+		Handler: r.handleDescribeTools,
 	}
 
-	// SMB share creation (write operation)
-	r.tools["create_smb_share"] = Tool{
+	// Per-session argument defaults
+	r.tools["set_session_defaults"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "create_smb_share",
-			Description: "Create an SMB (Windows/macOS file sharing) share. This makes a ZFS dataset accessible over the network via the SMB/CIFS protocol.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create SMB shares, follow this conversation flow:\n\n**1. Dataset Selection:**\n- Ask: \"Do you want to create a new dataset or use an existing ZFS dataset?\"\n- If NEW: Use create_dataset tool first (with share_type=SMB, acltype=NFSV4)\n- If EXISTING: \n  * Query available datasets first with query_datasets\n  * Present options to user (NEVER suggest pool root like 'tank' or 'flash')\n  * Use the dataset's mountpoint as the path\n  * Warn: \"Never share a pool root - always use a child dataset\"\n- After dataset creation, use its mountpoint as the path\n\n**2. Share Name:**\n- Ask: \"What name should appear when browsing the network?\"\n- Rules: Max 80 chars, no \\ / [ ] : | < > + = ; , * ? \"\n- Cannot use: global, printers, homes\n- Suggest: Use a friendly, descriptive name like \"TeamDocs\" or \"PhotoArchive\"\n\n**3. Description:**\n- Ask: \"Add a description?\" (optional, shown when browsing shares)\n\n**4. Purpose Selection:**\n- Ask: \"What's this share for?\"\n- Options:\n  * DEFAULT_SHARE: Standard file sharing (most common)\n  * TIMEMACHINE_SHARE: macOS Time Machine backups\n  * MULTIPROTOCOL_SHARE: Both SMB and NFS access (complex permissions)\n  * PRIVATE_DATASETS_SHARE: User home directories\n  * VEEAM_REPOSITORY_SHARE: Veeam backup storage\n- Recommend DEFAULT_SHARE unless specific use case\n\n**5. Access Control:**\n- Ask: \"Read-only or read-write?\" (default: read-write)\n- Ask: \"Should it be visible when browsing?\" (default: yes)\n- Ask: \"Restrict to specific IP addresses?\" (optional, for hostsallow)\n- Ask: \"Hide from unauthorized users?\" (access_based_share_enumeration)\n\n**6. Purpose-Specific Questions:**\n\nFor TIMEMACHINE_SHARE:\n- Ask: \"What's the backup size limit?\" (recommend 2-3x Mac's disk size)\n- Set time_machine_quota in options\n\nFor MULTIPROTOCOL_SHARE:\n- Warn: \"Multi-protocol shares have complex permission interactions\"\n- Recommend: \"Use either SMB OR NFS, not both, unless you understand the implications\"\n\nFor PRIVATE_DATASETS_SHARE:\n- Suggest: \"Create separate datasets per user for isolation\"\n- Recommend: \"Use access_based_share_enumeration=true\"\n\n**7. Auditing (Optional):**\n- Ask: \"Enable access auditing?\" (tracks who accesses files)\n- If yes: Ask which groups to audit (empty = audit all)\n\n**IMPORTANT RECOMMENDATIONS:**\n- Default: enabled=true, browsable=true, readonly=false\n- For sensitive data: Set access_based_share_enumeration=true\n- For public shares: Use hostsdeny to block unwanted networks\n- For Time Machine: Set appropriate quota to prevent filling pool\n- For multi-protocol: Strongly recommend against unless necessary\n\n**SECURITY WARNINGS TO DISPLAY:**\n- If browsable=true + no hostsallow: \"Share visible and accessible from any network\"\n- If readonly=false: \"Users can modify, delete, and create files\"\n- If no access restrictions: \"Anyone on your network can access this share\"\n- Remind: \"Configure share permissions in TrueNAS UI after creation\"\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview the configuration\n2. Display complete summary including:\n   - Share name and network path (\\\\truenas\\sharename)\n   - Local path\n   - Purpose and access settings\n   - Security warnings if applicable\n3. Get explicit user confirmation: \"Shall I create this share?\"\n4. Warn: \"This is a WRITE operation that exposes data over your network\"\n5. After creation: Remind user to configure permissions via TrueNAS UI\n\n**DRY RUN:**\nSet dry_run=true to preview what will be created without executing. Show user the preview including security warnings, then ask for confirmation.",
+			Name:        "set_session_defaults",
+			Description: "Set defaults for this conversation's session, filled in automatically whenever a tool call omits the argument: pool, dataset, app_name, and vm (e.g., pool='tank' so query_datasets, run_scrub, and get_scrub_status target tank without repeating it). Explicit arguments always win; a call naming a dataset does not get the default pool; delete tools never use defaults. Each default must exist. Pass an empty string to clear one, clear=true to clear all, or no arguments to see the current defaults and which tools they reach.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name": map[string]interface{}{
+					"pool": map[string]interface{}{
 						"type":        "string",
-						"description": "Share name visible to clients (max 80 chars, case-insensitive, must be unique)",
+						"description": "Optional: Default pool name (empty string clears it)",
 					},
-					"path": map[string]interface{}{
+					"dataset": map[string]interface{}{
 						"type":        "string",
-						"description": "ZFS dataset mountpoint starting with /mnt/ (e.g., /mnt/tank/shares/docs, NOT /mnt/tank). Use 'EXTERNAL' only for DFS proxy shares.",
+						"description": "Optional: Default dataset (e.g., 'tank/media'); used instead of the default pool by tools accepting either (empty string clears it)",
 					},
-					"purpose": map[string]interface{}{
+					"app_name": map[string]interface{}{
 						"type":        "string",
-						"description": "Share purpose: DEFAULT_SHARE (standard), TIMEMACHINE_SHARE (macOS backups), MULTIPROTOCOL_SHARE (SMB+NFS), PRIVATE_DATASETS_SHARE (home dirs)",
-						"enum":        []string{"DEFAULT_SHARE", "LEGACY_SHARE", "TIMEMACHINE_SHARE", "MULTIPROTOCOL_SHARE", "TIME_LOCKED_SHARE", "PRIVATE_DATASETS_SHARE", "EXTERNAL_SHARE", "VEEAM_REPOSITORY_SHARE", "FCP_SHARE"},
-						"default":     "DEFAULT_SHARE",
-					},
-					"enabled": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Enable share for network access (default: true)",
-						"default":     true,
+						"description": "Optional: Default installed app name (empty string clears it)",
 					},
-					"comment": map[string]interface{}{
+					"vm": map[string]interface{}{
 						"type":        "string",
-						"description": "Description shown when clients list shares (optional)",
+						"description": "Optional: Default VM name or ID (empty string clears it)",
 					},
-					"readonly": map[string]interface{}{
+					"clear": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Prevent clients from creating/modifying files (default: false)",
+						"description": "Clear every default before applying the other arguments (default: false)",
 						"default":     false,
 					},
-					"browsable": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Show share in network browse lists (default: true)",
-						"default":     true,
+				},
+			},
+		},
+		SessionHandler: r.handleSetSessionDefaults,
+	}
+
+	// Arbitrary reporting graph fetch
+	r.tools["get_metrics"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_metrics",
+			Description: "Fetch any reporting graph by name and identifier (use list_reporting_graphs to discover them), with control over the time range and downsampling. Data rows are [timestamp, value1, value2, ...] matching the series legend. Each returned point combines 'downsample' raw points using the chosen aggregation; by default the series is reduced to at most max_points points. Per-series min/max/mean aggregations over the full range are always included.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"graph": map[string]interface{}{
+						"type":        "string",
+						"description": "Graph name from list_reporting_graphs (e.g., 'cpu', 'arcsize', 'nfsstat', 'disktemp')",
 					},
-					"access_based_share_enumeration": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Hide share from users without filesystem ACL access (default: false)",
-						"default":     false,
+					"identifier": map[string]interface{}{
+						"type":        []string{"string", "array"},
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Graph identifier or list of identifiers (e.g., a disk or interface name). Omit for graphs without identifiers.",
 					},
-					"hostsallow": map[string]interface{}{
-						"type":        "array",
-						"description": "IP addresses/networks allowed to access (empty = allow all)",
-						"items": map[string]interface{}{
-							"type": "string",
-						},
+					"unit": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
+						"description": "Time range ending now (default: HOUR). Ignored when start is set.",
+						"default":     "HOUR",
 					},
-					"hostsdeny": map[string]interface{}{
-						"type":        "array",
-						"description": "IP addresses/networks denied access (empty = deny none)",
-						"items": map[string]interface{}{
-							"type": "string",
-						},
+					"start": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Range start as a Unix timestamp in seconds",
 					},
-					"audit": map[string]interface{}{
-						"type":        "object",
-						"description": "Audit configuration for tracking file access",
-						"properties": map[string]interface{}{
-							"enable": map[string]interface{}{
-								"type":        "boolean",
-								"description": "Enable audit logging",
-							},
-							"watch_list": map[string]interface{}{
-								"type":        "array",
-								"description": "Groups to audit (empty = audit all)",
-								"items": map[string]interface{}{
-									"type": "string",
-								},
-							},
-							"ignore_list": map[string]interface{}{
-								"type":        "array",
-								"description": "Groups to exclude from auditing",
-								"items": map[string]interface{}{
-									"type": "string",
-								},
-							},
-						},
+					"end": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Range end as a Unix timestamp in seconds (default: now; requires start)",
 					},
-					"options": map[string]interface{}{
-						"type":        "object",
-						"description": "Purpose-specific options (varies by purpose)",
+					"aggregation": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"mean", "max", "min"},
+						"description": "How raw points are combined when downsampling (default: mean)",
+						"default":     "mean",
 					},
-					"dry_run": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Preview what will be created without executing (default: false)",
-						"default":     false,
+					"downsample": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of raw points combined into each returned point (1 = full resolution). Overrides max_points.",
+					},
+					"max_points": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum points returned per series when downsample is not set (default: 60)",
+						"default":     60,
 					},
 				},
-				"required": []string{"name", "path"},
+				"required": []string{"graph"},
 			},
 		},
-		Handler: handleCreateSMBShare,
+		Handler: handleGetMetrics,
 	}
 
-	// NFS share creation (write operation)
-	r.tools["create_nfs_share"] = Tool{
+	// Netdata passthrough for per-second data
+	r.tools["list_netdata_charts"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "create_nfs_share",
-			Description: "Create an NFS (Network File System) share for Unix/Linux file sharing. This makes a ZFS dataset accessible over the network via the NFS protocol.\n\n**WIZARD GUIDANCE FOR LLM:**\nWhen helping users create NFS shares, follow this conversation flow:\n\n**1. Dataset Selection:**\n- Ask: \"Do you want to create a new dataset or use an existing ZFS dataset?\"\n- If NEW: Use create_dataset tool first (with share_type=NFS, acltype=POSIX)\n- If EXISTING: \n  * Query available datasets first with query_datasets\n  * Present options to user (NEVER suggest pool root like 'tank' or 'flash')\n  * Use the dataset's mountpoint as the path\n  * Warn: \"Never share a pool root - always use a child dataset\"\n- After dataset creation, use its mountpoint as the path\n\n**2. Access Control:**\n- Ask: \"Read-only or read-write?\" (default: read-write)\n- Ask: \"Restrict to specific networks?\" (CIDR notation: 192.168.1.0/24)\n- Ask: \"Restrict to specific hosts?\" (IP addresses or hostnames)\n- Recommend: At least one restriction (network or host) for security\n\n**3. User Mapping (Important for Security):**\n- Ask: \"How should root access be handled?\"\n  * **maproot_user**: Map root clients to specific user (recommended: 'nobody')\n  * **maproot_group**: Map root clients to specific group (recommended: 'nogroup')\n  * Warn if not set: \"Root clients will have full root access (security risk)\"\n- Ask: \"Map all users to a specific user?\" (optional, for anonymous access)\n  * **mapall_user**: Maps all clients to one user\n  * **mapall_group**: Maps all client groups to one group\n\n**4. Security Level (Optional):**\n- Default: SYS (system authentication)\n- Advanced: KRB5, KRB5I, KRB5P (Kerberos, requires setup)\n- Usually skip unless user specifically needs Kerberos\n\n**IMPORTANT RECOMMENDATIONS:**\n- For NFS shares: share_type=NFS, acltype=POSIX (in dataset creation)\n- Compression: LZ4 recommended for balanced performance\n- Always set maproot_user='nobody' to prevent root access\n- Use network/host restrictions to limit access\n- Read-only for shared data that shouldn't be modified\n\n**SECURITY WARNINGS TO DISPLAY:**\n- If no network/host restrictions: \"Share accessible from any host\"\n- If no maproot_user: \"Root clients will have full root access\"\n- If read-write + no restrictions: \"Any host can modify/delete files\"\n- Remind: \"Ensure NFS service is running and firewall allows NFS traffic (port 2049)\"\n\n**BEFORE EXECUTING:**\n1. Use dry_run=true to preview the configuration\n2. Display complete summary including:\n   - Local path\n   - Access type (read-only/read-write)\n   - Network/host restrictions\n   - User mapping settings\n   - Security warnings if applicable\n3. Get explicit user confirmation: \"Shall I create this NFS share?\"\n4. Warn: \"This is a WRITE operation that exposes data over your network\"\n5. After creation: Provide mount command example\n\n**DRY RUN:**\nSet dry_run=true to preview what will be created without executing. Show user the preview including security warnings, then ask for confirmation.",
+			Name:        "list_netdata_charts",
+			Description: "List the Netdata charts available for high-resolution (per-second) queries, with units and dimensions. Only charts matching the server's allowed prefixes are shown. Requires the server to be started with --netdata-url.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: r.handleListNetdataCharts,
+	}
+
+	r.tools["get_netdata_chart"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_netdata_chart",
+			Description: "Fetch recent per-second samples of a Netdata chart for short-window performance investigations (e.g., a latency spike in the last few minutes) where get_metrics is too coarse. Rows are [timestamp, value1, ...] oldest first, matching labels. Windows are limited to one hour; use get_metrics for longer ranges. Requires the server to be started with --netdata-url.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"path": map[string]interface{}{
+					"chart": map[string]interface{}{
 						"type":        "string",
-						"description": "ZFS dataset mountpoint starting with /mnt/ (e.g., /mnt/tank/shares/data, NOT /mnt/tank)",
+						"description": "Chart ID from list_netdata_charts (e.g., 'system.cpu', 'disk.sda', 'net.eth0', 'zfs.arc_size')",
 					},
-					"enabled": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Enable share for network access (default: true)",
-						"default":     true,
+					"seconds": map[string]interface{}{
+						"type":        "integer",
+						"description": "How many seconds back from now to fetch (default: 60, max: 3600)",
+						"default":     60,
 					},
-					"comment": map[string]interface{}{
-						"type":        "string",
-						"description": "Description for the share (optional)",
+					"points": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of points to return; samples are grouped to fit (default: every collected sample)",
 					},
-					"ro": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Read-only export (default: false for read-write)",
-						"default":     false,
+					"group": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"average", "max", "min"},
+						"description": "How samples are combined when points is set (default: average)",
+						"default":     "average",
 					},
-					"networks": map[string]interface{}{
-						"type":        "array",
-						"description": "Authorized networks in CIDR notation (e.g., ['192.168.1.0/24']). Empty = allow all networks.",
+				},
+				"required": []string{"chart"},
+			},
+		},
+		Handler: r.handleGetNetdataChart,
+	}
+
+	// System reporting metrics
+	r.tools["get_share_activity"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_share_activity",
+			Description: "Estimate which SMB/NFS shares and clients are generating the current load. Combines SMB connections and open files with NFS client mounts and open state, ranks shares by activity, and reports current network and disk totals to attribute against. Perfect for 'what's hammering my NAS right now?'",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleGetShareActivity,
+	}
+
+	r.tools["get_system_metrics"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_system_metrics",
+			Description: "Get system performance metrics (CPU, memory, load average, CPU temperature, uptime)",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"graphs": map[string]interface{}{
+						"type": "array",
 						"items": map[string]interface{}{
 							"type": "string",
+							"enum": []string{"cpu", "cputemp", "memory", "load", "uptime"},
 						},
+						"description": "Metrics to retrieve (default: cpu, memory, load)",
 					},
-					"hosts": map[string]interface{}{
-						"type":        "array",
-						"description": "Authorized IP addresses or hostnames (e.g., ['192.168.1.10', 'client.local']). No quotes or spaces. Empty = allow all hosts.",
-						"items": map[string]interface{}{
-							"type": "string",
-						},
+					"unit": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
+						"description": "Time range for metrics (default: HOUR)",
+						"default":     "HOUR",
 					},
-					"maproot_user": map[string]interface{}{
+				},
+			},
+		},
+		Handler: handleGetSystemMetrics,
+	}
+
+	// Network reporting metrics
+	r.tools["get_network_metrics"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_network_metrics",
+			Description: "Get network interface traffic metrics",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"interface": map[string]interface{}{
 						"type":        "string",
-						"description": "Map root clients to this user (recommended: 'nobody' for security)",
+						"description": "Network interface name (e.g., 'eth0'). If omitted, returns all interfaces.",
 					},
-					"maproot_group": map[string]interface{}{
+					"unit": map[string]interface{}{
 						"type":        "string",
-						"description": "Map root clients to this group (recommended: 'nogroup' for security)",
+						"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
+						"description": "Time range for metrics (default: HOUR)",
+						"default":     "HOUR",
 					},
-					"mapall_user": map[string]interface{}{
+				},
+			},
+		},
+		Handler: handleGetNetworkMetrics,
+	}
+
+	// Disks and SMART health
+	r.tools["query_disks"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "query_disks",
+			Description: "List physical disks with model, serial, size, type (HDD/SSD), pool membership, current temperature, and the result of the latest SMART self-test, plus a health verdict (PASS, WARNING, FAIL, or UNKNOWN when never tested). Use get_smart_results for attributes and test history.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
 						"type":        "string",
-						"description": "Map all clients to this user (optional, for anonymous access)",
+						"description": "Optional: Only disks in this pool",
 					},
-					"mapall_group": map[string]interface{}{
+				},
+			},
+		},
+		Handler: handleQueryDisks,
+	}
+
+	r.tools["get_smart_results"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_smart_results",
+			Description: "SMART health of disks: self-test history and any test in progress, the failure-predicting attributes (reallocated, pending, and uncorrectable sectors; NVMe media errors and wear), temperature, and a PASS/WARNING/FAIL verdict with reasons. Answers 'are my disks healthy?'.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"disk": map[string]interface{}{
 						"type":        "string",
-						"description": "Map all client groups to this group (optional, for anonymous access)",
+						"description": "Optional: Disk name (e.g. 'sda'). If omitted, returns all disks.",
 					},
-					"security": map[string]interface{}{
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Self-tests of history per disk (default: 5)",
+						"default":     5,
+					},
+				},
+			},
+		},
+		Handler: handleGetSMARTResults,
+	}
+
+	r.tools["run_smart_test"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "run_smart_test",
+			Description: "Start a SMART self-test on one or more disks. SHORT takes minutes, LONG reads the whole disk and can take many hours, CONVEYANCE checks for transport damage (ATA only). Returns a task_id per disk for progress tracking; read the outcome with get_smart_results.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"disks": map[string]interface{}{
 						"type":        "array",
-						"description": "Security mechanisms: ['SYS'] (default), ['KRB5'], ['KRB5I'], ['KRB5P']",
-						"items": map[string]interface{}{
-							"type": "string",
-							"enum": []string{"SYS", "KRB5", "KRB5I", "KRB5P"},
-						},
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Required: Disk names (e.g. ['sda', 'sdb'])",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"SHORT", "LONG", "CONVEYANCE"},
+						"description": "Required: Self-test type",
 					},
 					"dry_run": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Preview what will be created without executing (default: false)",
+						"description": "Optional: Preview without starting (default: false)",
 						"default":     false,
 					},
 				},
-				"required": []string{"path"},
+				"required": []string{"disks", "type"},
 			},
 		},
-		Handler: handleCreateNFSShare,
+		Handler: r.handleRunSMARTTestWithDryRun,
 	}
 
-	// Alert list with filtering
-	r.tools["list_alerts"] = Tool{
+	// Periodic SMART test schedules
+	smartScheduleCron := map[string]interface{}{
+		"hour":  map[string]interface{}{"type": "string"},
+		"dom":   map[string]interface{}{"type": "string"},
+		"month": map[string]interface{}{"type": "string"},
+		"dow":   map[string]interface{}{"type": "string"},
+	}
+
+	r.tools["query_smart_test_schedules"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "list_alerts",
-			Description: "List system alerts with optional filtering by dismissed status",
+			Name:        "query_smart_test_schedules",
+			Description: "List scheduled SMART self-tests with the disks each one covers, a human-readable schedule, and the next run time in the NAS timezone. Also lists disks that no schedule tests.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"dismissed": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Filter by dismissed status (true=dismissed only, false=active only, omit=all)",
+					"disk": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only schedules that test this disk (e.g. 'sda')",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"SHORT", "LONG", "CONVEYANCE", "OFFLINE"},
+						"description": "Optional: Only schedules of this test type",
 					},
 				},
 			},
 		},
-		Handler: handleListAlerts,
+		Handler: handleQuerySMARTSchedules,
 	}
 
-	// Dismiss alert
-	r.tools["dismiss_alert"] = Tool{
+	r.tools["create_smart_test_schedule"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "dismiss_alert",
-			Description: "Dismiss a system alert by UUID",
+			Name:        "create_smart_test_schedule",
+			Description: "Schedule a periodic SMART self-test on chosen disks or on all disks. Schedules have no minute field; tests start within the scheduled hour. Each disk can have one schedule per test type. Use dry-run to preview the next run and check for LONG tests that overlap each other or a pool scrub.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"uuid": map[string]interface{}{
+					"type": map[string]interface{}{
 						"type":        "string",
-						"description": "UUID of the alert to dismiss",
+						"enum":        []string{"SHORT", "LONG", "CONVEYANCE", "OFFLINE"},
+						"description": "Required: Self-test type",
+					},
+					"schedule": map[string]interface{}{
+						"type":        "object",
+						"description": "Required: Cron fields (e.g., {hour: '3', dow: '0'} for Sunday 3am). Fields not given default to hour '0' and '*' for the rest.",
+						"properties":  smartScheduleCron,
+					},
+					"disks": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Disk names to test (e.g. ['sda', 'sdb']). Give either disks or all_disks.",
+					},
+					"all_disks": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Test every disk, including disks added later (default: false)",
+						"default":     false,
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Human-readable description",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without creating (default: false)",
+						"default":     false,
 					},
 				},
-				"required": []string{"uuid"},
+				"required": []string{"type", "schedule"},
 			},
 		},
-		Handler: handleDismissAlert,
+		Handler: handleCreateSMARTScheduleWithDryRun,
 	}
 
-	// Restore alert
-	r.tools["restore_alert"] = Tool{
+	r.tools["delete_smart_test_schedule"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "restore_alert",
-			Description: "Restore (un-dismiss) a previously dismissed alert by UUID",
+			Name:        "delete_smart_test_schedule",
+			Description: "Remove a SMART test schedule. Dry-run lists disks that would be left without any scheduled SMART test.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"uuid": map[string]interface{}{
-						"type":        "string",
-						"description": "UUID of the alert to restore",
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Schedule ID to delete (from query_smart_test_schedules)",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without deleting (default: false)",
+						"default":     false,
 					},
 				},
-				"required": []string{"uuid"},
+				"required": []string{"id"},
 			},
 		},
-		Handler: handleRestoreAlert,
+		Handler: handleDeleteSMARTScheduleWithDryRun,
 	}
 
-	// System reporting metrics
-	r.tools["get_system_metrics"] = Tool{
+	// Disk I/O reporting metrics
+	r.tools["get_disk_metrics"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "get_system_metrics",
-			Description: "Get system performance metrics (CPU, memory, load average, CPU temperature, uptime)",
+			Name:        "get_disk_metrics",
+			Description: "Get disk performance metrics (I/O or temperature)",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"graphs": map[string]interface{}{
-						"type": "array",
-						"items": map[string]interface{}{
-							"type": "string",
-							"enum": []string{"cpu", "cputemp", "memory", "load", "uptime"},
-						},
-						"description": "Metrics to retrieve (default: cpu, memory, load)",
+					"disk": map[string]interface{}{
+						"type":        "string",
+						"description": "Disk name (e.g., 'sda'). If omitted, returns all disks.",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"disk", "disktemp"},
+						"description": "Metric type: disk I/O or disk temperature (default: disk)",
+						"default":     "disk",
 					},
 					"unit": map[string]interface{}{
 						"type":        "string",
@@ -1024,61 +4671,85 @@ For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
 				},
 			},
 		},
-		Handler: handleGetSystemMetrics,
+		Handler: handleGetDiskMetrics,
 	}
 
-	// Network reporting metrics
-	r.tools["get_network_metrics"] = Tool{
+	r.tools["analyze_disk_latency"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "get_network_metrics",
-			Description: "Get network interface traffic metrics",
+			Name:        "analyze_disk_latency",
+			Description: "Compare per-disk latency and busy time with the other disks in the same pool and vdev role, and flag disks that are statistical outliers. A disk much slower than identical peers is often failing before SMART reports it.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"interface": map[string]interface{}{
+					"pool": map[string]interface{}{
 						"type":        "string",
-						"description": "Network interface name (e.g., 'eth0'). If omitted, returns all interfaces.",
+						"description": "Optional: Only analyze this pool",
 					},
 					"unit": map[string]interface{}{
 						"type":        "string",
 						"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
-						"description": "Time range for metrics (default: HOUR)",
-						"default":     "HOUR",
+						"description": "Time range to average over (default: DAY)",
+						"default":     "DAY",
+					},
+					"ratio": map[string]interface{}{
+						"type":        "number",
+						"description": "Optional: How many times its peers' median a disk must reach to be flagged (default: 2)",
+						"default":     2,
 					},
 				},
 			},
 		},
-		Handler: handleGetNetworkMetrics,
+		Handler: handleAnalyzeDiskLatency,
 	}
 
-	// Disk I/O reporting metrics
-	r.tools["get_disk_metrics"] = Tool{
+	// Self-encrypting drives
+	r.tools["get_sed_status"] = Tool{
 		Definition: mcp.Tool{
-			Name:        "get_disk_metrics",
-			Description: "Get disk performance metrics (I/O or temperature)",
+			Name:        "get_sed_status",
+			Description: "Show self-encrypting drive (SED) status: the global SED user and whether a global password is set, and per disk whether it supports SED, is locked or unlocked, and has its own password. Passwords themselves are never returned.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"disk": map[string]interface{}{
 						"type":        "string",
-						"description": "Disk name (e.g., 'sda'). If omitted, returns all disks.",
+						"description": "Optional: Disk name (e.g., 'sda'). If omitted, returns all disks.",
 					},
-					"type": map[string]interface{}{
+				},
+			},
+		},
+		Handler: handleGetSEDStatus,
+	}
+
+	r.tools["set_sed_password"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "set_sed_password",
+			Description: "Set or clear the SED password TrueNAS uses to unlock self-encrypting drives at boot: the global password (with optional sed_user) or a per-disk password that overrides it. **IMPORTANT**: this does not change the password on the drive; if the stored password does not match the drive, it stays locked after a power cycle.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"password": map[string]interface{}{
 						"type":        "string",
-						"enum":        []string{"disk", "disktemp"},
-						"description": "Metric type: disk I/O or disk temperature (default: disk)",
-						"default":     "disk",
+						"description": "Required: SED password; an empty string clears it (a disk then falls back to the global password)",
 					},
-					"unit": map[string]interface{}{
+					"disk": map[string]interface{}{
 						"type":        "string",
-						"enum":        []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"},
-						"description": "Time range for metrics (default: HOUR)",
-						"default":     "HOUR",
+						"description": "Optional: Disk name (e.g., 'sda') to set a per-disk password. If omitted, sets the global password.",
+					},
+					"sed_user": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"USER", "MASTER"},
+						"description": "Optional: Global only - the Opal user drives are unlocked as",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Preview without changing the password (default: false)",
+						"default":     false,
 					},
 				},
+				"required": []string{"password"},
 			},
 		},
-		Handler: handleGetDiskMetrics,
+		Handler: handleSetSEDPasswordWithDryRun,
 	}
 
 	// ZFS ARC reporting metrics
@@ -1152,7 +4823,7 @@ For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
 	r.tools["query_apps"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "query_apps",
-			Description: "Query installed applications with their status, versions, and available updates",
+			Description: "Query installed applications with their status, versions, and available updates. Results are paged: follow next_cursor while it is present.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1160,11 +4831,37 @@ For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
 						"type":        "string",
 						"description": "Optional: Filter by specific app name",
 					},
+					"state": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter by app state (default: all)",
+						"enum":        []string{"RUNNING", "STOPPED", "DEPLOYING", "CRASHED", "all"},
+					},
+					"upgrade_available": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: true for only apps with an upgrade available, false for only up-to-date apps",
+					},
 					"include_config": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Include app configuration details (default: false)",
 						"default":     false,
 					},
+					"order_by": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Sort by 'name' (default, alphabetical) or 'state' (running first, then by name)",
+						"enum":        []string{"name", "state"},
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of apps to return (default: 50)",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of apps to skip, for paging (default: 0)",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: next_cursor from the previous page; returns the next page with the same filters",
+					},
 				},
 			},
 		},
@@ -1175,7 +4872,7 @@ For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
 	r.tools["upgrade_app"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "upgrade_app",
-			Description: "Upgrade an application to a newer version. Supports dry-run mode to preview changes. Returns a task ID for tracking progress. This is a write operation that modifies the system.",
+			Description: "Upgrade an application to a newer version. Supports dry-run mode to preview changes. A bad upgrade can be undone with rollback_app. Returns a task ID for tracking progress. This is a write operation that modifies the system.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1253,6 +4950,63 @@ For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
 		Handler: r.handleStopAppWithDryRun,
 	}
 
+	// Roll back app
+	r.tools["rollback_app"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "rollback_app",
+			Description: "Roll an application back to a version it was upgraded from, e.g. after a bad upgrade. By default the snapshot of the app's data taken at upgrade time is restored too. Job-based; use tasks_get with the returned task_id. **Use dry_run=true first** to see the available versions, and confirm with the user.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"app_name": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the application to roll back",
+					},
+					"version": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Version to roll back to (default: the most recent earlier version)",
+					},
+					"rollback_snapshot": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Restore the app data snapshot taken at upgrade time, discarding later data changes (default: true)",
+						"default":     true,
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"app_name"},
+			},
+		},
+		Handler: r.handleRollbackAppWithDryRun,
+	}
+
+	// Redeploy app
+	r.tools["redeploy_app"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "redeploy_app",
+			Description: "Redeploy an application: re-create its containers from the current configuration, keeping its data. Use to restart a crashed or misbehaving app. Job-based; use tasks_get with the returned task_id. Supports dry_run to preview the action.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"app_name": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the application to redeploy",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview the action without executing it (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"app_name"},
+			},
+		},
+		Handler: r.handleRedeployAppWithDryRun,
+	}
+
 	// Search app catalog
 	r.tools["search_app_catalog"] = Tool{
 		Definition: mcp.Tool{
@@ -1308,7 +5062,30 @@ For IPA: {hostname: "ipa.example.com", domain: "example.com", ...}
 				"required": []string{"app_name"},
 			},
 		},
-		Handler: handleGetAppCatalogDetails,
+		Handler: r.handleGetAppCatalogDetails,
+	}
+
+	// Refresh the local catalog details cache
+	r.tools["refresh_catalog_cache"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "refresh_catalog_cache",
+			Description: "Discard locally cached app catalog details so get_app_catalog_details and install_app fetch them from TrueNAS again. Details are cached per TrueNAS version for the configured TTL; use this after a catalog sync or when a new app version was just published. With app_name, only that app is dropped and it is refetched immediately. Returns what remains cached.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"app_name": map[string]interface{}{
+						"type":        "string",
+						"description": "App to refresh (default: clear the whole cache)",
+					},
+					"train": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"stable", "enterprise", "community"},
+						"description": "Catalog train to refresh (default: all trains; stable when refetching app_name)",
+					},
+				},
+			},
+		},
+		Handler: r.handleRefreshCatalogCache,
 	}
 
 	// Install app
@@ -1399,11 +5176,11 @@ For each permanent storage volume (not temporary/tmpfs):
    - type: "FILESYSTEM"
    - share_type: "APPS"
    - compression: "LZ4"
-   - quota: <size_in_bytes> (optional)
+   - quota: <size> (optional, bytes or human-readable like "10GiB")
 2. Confirm creation
 3. Recommended quotas:
-   - config: 10GB (10737418240)
-   - cache: 50GB (53687091200)
+   - config: "10GiB"
+   - cache: "50GiB"
    - data: 1TB+ (varies by app)
 
 **STEP 5: Build Configuration by Group**
@@ -1527,57 +5304,151 @@ install_app(
   dry_run=false
 )
 
-Returns task_id for tracking progress with tasks_get.
+Returns task_id for tracking progress with tasks_get. The installation runs
+as steps (create any missing datasets, then app.create); the task result
+lists each step and its status.
+
+**STEP 10: Resume a Failed Installation**
+
+If a step fails (e.g. app.create fails after datasets were created), fix the
+cause and call install_app(resume_task_id="<task_id>"). Completed steps are
+re-checked against the system and skipped. Pass values to retry with a
+corrected configuration. Install tasks can be resumed for 24 hours.
+
+**CRITICAL SAFETY RULES:**
+- ALWAYS use "type": "host_path" for storage
+- NEVER use "type": "ix_volume"
+- ALWAYS create datasets before installation
+- ALWAYS validate paths start with /mnt/
+- ALWAYS use dry-run before final installation
+
+**ERROR RECOVERY:**
+- Missing datasets: Create with create_dataset, or set create_datasets=true
+- Failed step: Fix the cause, then resume with resume_task_id
+- ix_volume detected: Convert to host_path format
+- Invalid structure: Review schema and rebuild section
+- Validation failed: Check error message for exact location`,
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"app_name": map[string]interface{}{
+						"type":        "string",
+						"description": "Unique app instance name (lowercase, alphanumeric, hyphens, 1-40 chars). Pattern: ^[a-z]([-a-z0-9]*[a-z0-9])?$",
+						"pattern":     "^[a-z]([-a-z0-9]*[a-z0-9])?$",
+					},
+					"catalog_app": map[string]interface{}{
+						"type":        "string",
+						"description": "Catalog app name (from search results)",
+					},
+					"train": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"stable", "enterprise", "community"},
+						"description": "Catalog train (default: stable)",
+						"default":     "stable",
+					},
+					"version": map[string]interface{}{
+						"type":        "string",
+						"description": "App version (default: latest)",
+						"default":     "latest",
+					},
+					"values": map[string]interface{}{
+						"type":        "object",
+						"description": "Complete app configuration assembled from schema groups. Includes TZ, run_as, network, storage (host_path only), labels, and resources. Build this by iterating through schema groups from get_app_catalog_details. Required for new installs; when resuming, replaces the original configuration.",
+					},
+					"create_datasets": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Create missing host-path datasets (and their parents) as installation steps instead of failing (default: false)",
+						"default":     false,
+					},
+					"resume_task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Resume a failed installation from its install_app task. Steps already done are skipped; app_name, catalog_app and values are taken from the original request.",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview installation without executing (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleInstallAppWithDryRun,
+	}
+
+	r.tools["get_port_usage"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_port_usage",
+			Description: "Map of host ports in use: system services (web UI, SSH, SMB, NFS, FTP, iSCSI, SNMP, UPS), app published ports (running and configured), and VM display ports, with conflicts and suggested free ports. Use before choosing ports for install_app, install_from_template, or a VM display instead of guessing. Read-only.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"port": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Check whether this port is free",
+					},
+					"suggest": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Number of free ports to suggest (default: 3)",
+						"default":     3,
+					},
+					"range_start": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: First port to consider for suggestions (default: 30000, where catalog apps put their ports)",
+						"default":     30000,
+					},
+				},
+			},
+		},
+		Handler: handleGetPortUsage,
+	}
 
-**CRITICAL SAFETY RULES:**
-- ALWAYS use "type": "host_path" for storage
-- NEVER use "type": "ix_volume"
-- ALWAYS create datasets before installation
-- ALWAYS validate paths start with /mnt/
-- ALWAYS use dry-run before final installation
+	r.tools["list_app_templates"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_app_templates",
+			Description: "List the app templates shipped with the server (Jellyfin, Nextcloud with PostgreSQL, Home Assistant) and the parameters each needs. Use with install_from_template instead of the full install_app wizard for these apps.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: handleListAppTemplates,
+	}
 
-**ERROR RECOVERY:**
-- Missing datasets: Create with create_dataset
-- ix_volume detected: Convert to host_path format
-- Invalid structure: Review schema and rebuild section
-- Validation failed: Check error message for exact location`,
+	r.tools["install_from_template"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "install_from_template",
+			Description: "Install an app from a shipped template: fills in the pool, storage paths, ports, and generated database passwords, creates the app's datasets under <pool>/apps/<app_name>, and runs the install_app pipeline. Returns an install task_id; resume failures with install_app resume_task_id. Ask only for the template's required parameters (see list_app_templates). **Use dry_run=true first** to review datasets and configuration.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"app_name": map[string]interface{}{
-						"type":        "string",
-						"description": "Unique app instance name (lowercase, alphanumeric, hyphens, 1-40 chars). Pattern: ^[a-z]([-a-z0-9]*[a-z0-9])?$",
-						"pattern":     "^[a-z]([-a-z0-9]*[a-z0-9])?$",
-					},
-					"catalog_app": map[string]interface{}{
+					"template": map[string]interface{}{
 						"type":        "string",
-						"description": "Catalog app name (from search results)",
+						"enum":        appTemplateNames(),
+						"description": "Required: Template name",
 					},
-					"train": map[string]interface{}{
+					"pool": map[string]interface{}{
 						"type":        "string",
-						"enum":        []string{"stable", "enterprise", "community"},
-						"description": "Catalog train (default: stable)",
-						"default":     "stable",
+						"description": "Required: Pool for the app's datasets (e.g., 'tank')",
 					},
-					"version": map[string]interface{}{
+					"app_name": map[string]interface{}{
 						"type":        "string",
-						"description": "App version (default: latest)",
-						"default":     "latest",
+						"description": "Optional: App instance name (default: the template name)",
+						"pattern":     "^[a-z]([-a-z0-9]*[a-z0-9])?$",
 					},
-					"values": map[string]interface{}{
+					"parameters": map[string]interface{}{
 						"type":        "object",
-						"description": "Complete app configuration assembled from schema groups. Includes TZ, run_as, network, storage (host_path only), labels, and resources. Build this by iterating through schema groups from get_app_catalog_details.",
+						"description": "Optional: Template parameters, e.g. {\"media_path\": \"/mnt/tank/media\", \"web_port\": 30013}; required ones are listed by list_app_templates",
 					},
 					"dry_run": map[string]interface{}{
 						"type":        "boolean",
-						"description": "Preview installation without executing (default: false)",
+						"description": "Optional: Preview without installing (default: false)",
 						"default":     false,
 					},
 				},
-				"required": []string{"app_name", "catalog_app", "values"},
+				"required": []string{"template", "pool"},
 			},
 		},
-		Handler: r.handleInstallAppWithDryRun,
+		Handler: r.handleInstallFromTemplate,
 	}
 
 	// Delete app
@@ -1613,7 +5484,7 @@ Returns task_id for tracking progress with tasks_get.
 	r.tools["query_jobs"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "query_jobs",
-			Description: "Query system jobs (running, pending, or completed tasks like replication, snapshots, scrubs, etc.)",
+			Description: "Query system jobs (running, pending, or completed tasks like replication, snapshots, scrubs, etc.). Filter by state, method prefix, and start time range. Served from the server's event-fed cache when enabled; freshness shows the data source and last full sync.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1628,17 +5499,57 @@ Returns task_id for tracking progress with tasks_get.
 						"description": "Maximum number of jobs to return (default: 50)",
 						"default":     50,
 					},
+					"method": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Filter by method prefix (e.g., 'replication.*', 'pool.scrub')",
+					},
+					"started_after": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Only jobs started at or after this time (Unix seconds, RFC3339, or YYYY-MM-DD)",
+					},
+					"started_before": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Only jobs started before this time (Unix seconds, RFC3339, or YYYY-MM-DD)",
+					},
+					"live": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Query the middleware directly instead of the event cache (default: false)",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleQueryJobs,
+	}
+
+	r.tools["abort_job"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "abort_job",
+			Description: "Abort a running or waiting job by ID (from query_jobs). Only jobs marked abortable can be aborted. Work already done is not rolled back. Supports dry-run mode to check the job can be aborted.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Required: Job ID to abort",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview without aborting (default: false)",
+						"default":     false,
+					},
 				},
+				"required": []string{"id"},
 			},
 		},
-		Handler: handleQueryJobs,
+		Handler: r.handleAbortJobWithDryRun,
 	}
 
 	// Capacity analysis tool
 	r.tools["analyze_capacity"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "analyze_capacity",
-			Description: "Analyze system capacity utilization and trends for capacity planning. Provides utilization percentages, growth rates, and projections based on historical metrics. Includes CPU, memory, network, and disk I/O analysis.",
+			Description: "Analyze system capacity utilization and trends for capacity planning. Provides utilization percentages, growth rates, and projections based on historical metrics. Includes CPU, memory, network, disk I/O, and storage (pool growth from locally recorded usage history) analysis.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1652,21 +5563,38 @@ Returns task_id for tracking progress with tasks_get.
 						"type": "array",
 						"items": map[string]interface{}{
 							"type": "string",
-							"enum": []string{"cpu", "memory", "network", "disk", "all"},
+							"enum": []string{"cpu", "memory", "network", "disk", "storage", "all"},
 						},
 						"description": "Metrics to analyze (default: all)",
 					},
 				},
 			},
 		},
-		Handler: handleAnalyzeCapacity,
+		Handler: r.handleAnalyzeCapacity,
 	}
 
 	// Pool capacity details tool
+	r.tools["storage_health_report"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "storage_health_report",
+			Description: "Answer \"is my storage healthy?\" in one call. For each pool, merges pool status, member disk status and read/write/checksum error counters, the latest SMART test result per disk, last scrub result and age, capacity utilization, and active alerts that mention the pool or its disks. Each pool gets a verdict (HEALTHY, WARNING, CRITICAL) with a list of the issues that produced it.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Report on a single pool by name (default: all pools)",
+					},
+				},
+			},
+		},
+		Handler: handleStorageHealthReport,
+	}
+
 	r.tools["get_pool_capacity_details"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "get_pool_capacity_details",
-			Description: "Get detailed pool and dataset capacity information with utilization analysis. Returns current capacity snapshot with breakdown by dataset. Note: Historical capacity trends are not available from TrueNAS API; use Netdata graphs if available.",
+			Description: "Get detailed pool and dataset capacity information with utilization analysis. Returns current capacity snapshot with breakdown by dataset, plus growth rate and projected full date per pool when local capacity history has been recorded.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1677,14 +5605,278 @@ Returns task_id for tracking progress with tasks_get.
 				},
 			},
 		},
-		Handler: handleGetPoolCapacityDetails,
+		Handler: r.handleGetPoolCapacityDetails,
+	}
+
+	r.tools["forecast_dataset_growth"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "forecast_dataset_growth",
+			Description: "Forecast per-dataset storage growth from locally recorded usage history. Returns each dataset's growth rate and projected date it runs out of space - its quota when one is set and closer, otherwise the pool's free space - sorted soonest first. Requires the server to run with --capacity-sample-interval.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only this dataset and its children (e.g., 'tank/media')",
+					},
+					"pool": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Only datasets in this pool",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum datasets to return (default: 25)",
+						"default":     25,
+					},
+				},
+			},
+		},
+		Handler: r.handleForecastDatasetGrowth,
+	}
+
+	r.tools["configure_capacity_alerts"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "configure_capacity_alerts",
+			Description: "Set quota alert thresholds on a dataset so TrueNAS raises warning/critical alerts as it fills. Thresholds are percentages of the dataset's quota (quota_*) or refquota (refquota_*) and are inherited by child datasets; pass a pool name to set them on the pool's root dataset. A quota or refquota can be set in the same call, since thresholds only fire once one exists. Supports dry-run mode to preview current vs new values. Pool-wide usage alerts (80%/90%) are built in and not changed by this tool.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dataset": map[string]interface{}{
+						"type":        "string",
+						"description": "Required: Dataset name (e.g., 'tank/shares') or pool name for the root dataset",
+					},
+					"quota_warning": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Warning threshold as percent of quota (0-100, 0 disables) or 'INHERIT'",
+					},
+					"quota_critical": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Critical threshold as percent of quota (0-100, 0 disables) or 'INHERIT'",
+					},
+					"refquota_warning": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Warning threshold as percent of refquota (0-100, 0 disables) or 'INHERIT'",
+					},
+					"refquota_critical": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Critical threshold as percent of refquota (0-100, 0 disables) or 'INHERIT'",
+					},
+					"quota": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Quota to set, in bytes or human-readable (e.g., '500G'); 0 removes it",
+					},
+					"refquota": map[string]interface{}{
+						"type":        []string{"integer", "string"},
+						"description": "Optional: Refquota to set, in bytes or human-readable (e.g., '500G'); 0 removes it",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview threshold changes without executing (default: false)",
+						"default":     false,
+					},
+				},
+				"required": []string{"dataset"},
+			},
+		},
+		Handler: r.handleConfigureCapacityAlertsWithDryRun,
+	}
+
+	// Health digest tools
+	r.tools["generate_health_digest"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "generate_health_digest",
+			Description: "Generate a health digest report now: active alerts, pool health and last scrub results, SMART test failures, capacity changes over the period (from local history), and pending updates. Optionally emails the digest using the TrueNAS mail configuration or sends it through a TrueNAS alert service. The result is also stored as the latest digest for get_health_digest and the truenas://health-digest resource.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"period": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"daily", "weekly"},
+						"description": "Optional: Period covered by capacity deltas (default: daily)",
+						"default":     "daily",
+					},
+					"email_to": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional: Email recipients. Sent via TrueNAS mail.send, so outbound email must be configured on the NAS.",
+					},
+					"alert_service": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: Name of a TrueNAS alert service to send the digest through. Mail services email it to the service's address; Slack and Mattermost services post it to their webhook.",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Build the digest and check the delivery targets without storing or sending it",
+						"default":     false,
+					},
+				},
+			},
+		},
+		Handler: r.handleGenerateHealthDigestWithDryRun,
+	}
+
+	r.tools["get_health_digest"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_health_digest",
+			Description: "Get the most recently generated health digest (scheduled or on-demand) and the next scheduled run time. Use format 'text' for the plain-text version that is emailed.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"json", "text"},
+						"description": "Optional: Output format (default: json)",
+						"default":     "json",
+					},
+				},
+			},
+		},
+		Handler: r.handleGetHealthDigest,
+	}
+
+	// Event watch tools
+	r.tools["save_compliance_baseline"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "save_compliance_baseline",
+			Description: "Save the desired-state baseline that check_compliance validates the system against, replacing any previous baseline. Each rule is one requirement, e.g. a scrub schedule on every pool, weekly LONG SMART tests on every disk, or snapshot tasks covering every dataset under tank/critical. Confirm the rules with the user before saving.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"rules": map[string]interface{}{
+						"type":        "array",
+						"description": "Required: Baseline rules",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"type": map[string]interface{}{
+									"type":        "string",
+									"enum":        []string{compliance.RuleScrubSchedule, compliance.RuleSMARTTests, compliance.RuleSnapshotTasks},
+									"description": "scrub_schedule: pools have an enabled scrub schedule. smart_tests: every disk has a periodic SMART test. snapshot_tasks: the dataset and everything below it have an enabled periodic snapshot task",
+								},
+								"pools": map[string]interface{}{
+									"type":        "array",
+									"items":       map[string]interface{}{"type": "string"},
+									"description": "scrub_schedule only: pools to check (default: all pools)",
+								},
+								"dataset": map[string]interface{}{
+									"type":        "string",
+									"description": "snapshot_tasks only (required): dataset whose tree must be covered, e.g. tank/critical",
+								},
+								"test_type": map[string]interface{}{
+									"type":        "string",
+									"enum":        []string{"SHORT", "LONG", "CONVEYANCE", "OFFLINE"},
+									"description": "smart_tests only: required test type (default: any)",
+								},
+								"max_interval_days": map[string]interface{}{
+									"type":        "number",
+									"description": "Longest allowed gap between runs in days, e.g. 7 for weekly (default: any schedule)",
+								},
+							},
+							"required": []string{"type"},
+						},
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional: What the baseline is for",
+					},
+				},
+				"required": []string{"rules"},
+			},
+		},
+		Handler: r.handleSaveComplianceBaseline,
+	}
+
+	r.tools["get_compliance_baseline"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_compliance_baseline",
+			Description: "Show the saved desired-state baseline used by check_compliance.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: r.handleGetComplianceBaseline,
+	}
+
+	r.tools["check_compliance"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "check_compliance",
+			Description: "Validate the live system against the saved baseline and list every violation (pool, disk, or dataset, and what is wrong) with its remediation. Fixes available as MCP tool calls are collected into a remediation_plan that can be previewed and run with execute_plan; the rest come with manual instructions.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: r.handleCheckCompliance,
+	}
+
+	r.tools["watch_events"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "watch_events",
+			Description: "Start, stop, or check watching of live TrueNAS alert and job events. While watching, new alerts and job state changes (started, succeeded, failed) are buffered for get_recent_events and, when notify is enabled, pushed to the client as MCP notifications/message log notifications. Job progress updates are not forwarded; only state transitions are.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"start", "stop", "status"},
+						"description": "Optional: start (or reconfigure) watching, stop watching, or report status (default: start)",
+						"default":     "start",
+					},
+					"categories": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string", "enum": []string{"alert", "job"}},
+						"description": "Optional: Event categories to watch (default: alert and job)",
+					},
+					"min_alert_level": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"INFO", "NOTICE", "WARNING", "ERROR", "CRITICAL", "ALERT", "EMERGENCY"},
+						"description": "Optional: Ignore alerts below this level (default: WARNING)",
+						"default":     "WARNING",
+					},
+					"notify": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Optional: Push events to the client as MCP notifications (default: true). When false, events are only buffered.",
+						"default":     true,
+					},
+				},
+			},
+		},
+		Handler: r.handleWatchEvents,
+	}
+
+	r.tools["get_recent_events"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "get_recent_events",
+			Description: "Get alert and job events collected since watch_events was started. Pass the returned next_since value as since on the next call to receive only newer events.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"since": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Only return events with a sequence number greater than this (default: 0 = all buffered events)",
+					},
+					"category": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"alert", "job"},
+						"description": "Optional: Only return events of this category",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Optional: Maximum number of events to return, newest kept (default: 50, max: 500)",
+						"default":     50,
+					},
+				},
+			},
+		},
+		Handler: r.handleGetRecentEvents,
 	}
 
 	// Task management tools
 	r.tools["tasks_list"] = Tool{
 		Definition: mcp.Tool{
 			Name:        "tasks_list",
-			Description: "List all active and recent tasks. Tasks represent long-running operations like app upgrades.",
+			Description: "List all active and recent tasks. Tasks represent long-running operations like app upgrades. Tasks recovered after a server restart, or whose middleware job vanished, are flagged in their reconciled field.",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -1720,6 +5912,49 @@ Returns task_id for tracking progress with tasks_get.
 		},
 		Handler: r.handleTasksGet,
 	}
+
+	r.tools["execute_plan"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "execute_plan",
+			Description: "Run an ordered list of tool calls server-side under one task, e.g. create_dataset -> create_smb_share for a share wizard. Each step names a tool, its arguments, and whether to abort or continue the plan if it fails. Returns a task ID; tasks_get shows progress and every step's result. Steps run with the same checks as direct calls. With dry_run=true every write step is previewed with dry_run=true instead and the results are returned immediately. This is a write operation when any step writes: confirm the full plan with the user first.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"steps": map[string]interface{}{
+						"type":        "array",
+						"description": fmt.Sprintf("Tool calls to run in order (at most %d)", maxPlanSteps),
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"tool": map[string]interface{}{
+									"type":        "string",
+									"description": "Tool name",
+								},
+								"arguments": map[string]interface{}{
+									"type":        "object",
+									"description": "Arguments for the tool",
+								},
+								"on_failure": map[string]interface{}{
+									"type":        "string",
+									"enum":        []string{"abort", "continue"},
+									"description": "abort skips the remaining steps if this step fails; continue runs them anyway (default: abort)",
+									"default":     "abort",
+								},
+							},
+							"required": []string{"tool"},
+						},
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Preview every step with dry_run=true without making changes",
+						"default":     false,
+					},
+				},
+				"required": []string{"steps"},
+			},
+		},
+		Handler: r.handleExecutePlan,
+	}
 }
 
 func (r *Registry) ListTools() []mcp.Tool {
@@ -1727,21 +5962,131 @@ func (r *Registry) ListTools() []mcp.Tool {
 	for _, tool := range r.tools {
 		tools = append(tools, tool.Definition)
 	}
-	return tools
+	return tools
+}
+
+// NewCorrelationID returns an ID for tracing one tool call across middleware
+// calls, tasks, and logs
+func NewCorrelationID() string {
+	return uuid.New().String()
+}
+
+func (r *Registry) CallTool(name string, args map[string]interface{}) (string, error) {
+	return r.CallToolWithCorrelationID(NewCorrelationID(), name, args)
+}
+
+// CallToolWithCorrelationID runs a tool with correlationID attached to its
+// middleware call logs, any tasks it creates, and its error
+func (r *Registry) CallToolWithCorrelationID(correlationID, name string, args map[string]interface{}) (string, error) {
+	return r.callTool(context.Background(), "", correlationID, name, args)
+}
+
+// CallToolInSession runs a tool for an MCP session, filling arguments the
+// call omits from that session's defaults (see set_session_defaults). The
+// tool's middleware calls are abandoned when ctx is cancelled.
+func (r *Registry) CallToolInSession(ctx context.Context, sessionID, correlationID, name string, args map[string]interface{}) (string, error) {
+	if tool, exists := r.tools[name]; exists {
+		args = r.applySessionDefaults(sessionID, correlationID, name, tool, args)
+	}
+	return r.callTool(ctx, sessionID, correlationID, name, args)
+}
+
+// clientFor returns the registry's client with its calls tagged with
+// correlationID, or nil for a registry without one (see --dump-tools)
+func (r *Registry) clientFor(correlationID string) truenas.Caller {
+	if r.client == nil {
+		return nil
+	}
+	return r.client.WithCorrelationID(correlationID)
 }
 
-func (r *Registry) CallTool(name string, args map[string]interface{}) (string, error) {
+func (r *Registry) callTool(ctx context.Context, sessionID, correlationID, name string, args map[string]interface{}) (_ string, err error) {
+	// Credentials passed to the tool are masked wherever they are echoed back
+	secrets := redact.Secrets(args)
+	defer func() {
+		if err != nil {
+			err = redactError(err, secrets...)
+		}
+	}()
+
 	tool, exists := r.tools[name]
+	if reason, disabled := r.disabledTools[name]; !exists && disabled {
+		toolErr := newToolError(ErrorPermissionDenied, "%s is disabled: %s", name, reason)
+		toolErr.CorrelationID = correlationID
+		return "", toolErr
+	}
 	if !exists {
-		return "", fmt.Errorf("unknown tool: %s", name)
+		toolErr := newToolError(ErrorNotFound, "unknown tool: %s", name)
+		toolErr.CorrelationID = correlationID
+		return "", toolErr
+	}
+
+	// Calls can be directed at another system with host
+	args = r.withSystem(args)
+	system, args, err := r.systemFor(name, args)
+	if err != nil {
+		toolErr := ClassifyError(err)
+		toolErr.CorrelationID = correlationID
+		return "", toolErr
+	}
+
+	// Calls with schedule_at or maintenance_window are queued, not run
+	if result, handled, err := r.scheduleToolCall(correlationID, name, tool, args); handled {
+		if err != nil {
+			toolErr := ClassifyError(err)
+			toolErr.CorrelationID = correlationID
+			return "", toolErr
+		}
+		slog.Info("Tool scheduled", "correlation_id", correlationID, "tool", name)
+		return result, nil
+	}
+	if system != r {
+		return system.callTool(ctx, sessionID, correlationID, name, args)
+	}
+
+	// Session-aware tools are told which session called them
+	if tool.SessionHandler != nil {
+		sessionHandler := tool.SessionHandler
+		tool.Handler = func(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+			return sessionHandler(ctx, sessionID, client, args)
+		}
+	}
+
+	// Conflicting mutations of the same object are refused while one is running
+	if key := operationLockKey(name, tool, args); key != "" {
+		lock, toolErr := r.locks.acquire(key, name, correlationID, r.taskActive)
+		if toolErr != nil {
+			toolErr.CorrelationID = correlationID
+			slog.Warn("Tool refused", "correlation_id", correlationID, "tool", name, "reason", toolErr.Message)
+			return "", toolErr
+		}
+		tool.Handler = r.lockedHandler(lock, tool.Handler)
+	}
+
+	// Read tools can project their output with fields and verbosity
+	var shape responseShape
+	if !isWriteTool(name, tool) {
+		if shape, err = parseResponseShape(args); err != nil {
+			toolErr := ClassifyError(err)
+			toolErr.CorrelationID = correlationID
+			return "", toolErr
+		}
 	}
 
-	return tool.Handler(r.client, args)
+	slog.Info("Calling tool", "correlation_id", correlationID, "tool", name)
+	result, err := r.callWithTimeout(ctx, name, tool, r.clientFor(correlationID), args)
+	if err != nil {
+		toolErr := ClassifyError(err)
+		toolErr.CorrelationID = correlationID
+		slog.Warn("Tool failed", "correlation_id", correlationID, "tool", name, "code", toolErr.Code)
+		return "", toolErr
+	}
+	return fitResponse(name, shapeOutput(redactOutput(result, secrets...), shape), r.maxResponse), nil
 }
 
 // Tool handlers
 
-func handleSystemInfo(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleSystemInfo(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	result, err := client.Call("system.info")
 	if err != nil {
 		return "", err
@@ -1760,29 +6105,52 @@ func handleSystemInfo(client *truenas.Client, args map[string]interface{}) (stri
 	return string(formatted), nil
 }
 
-func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (string, error) {
-	// Get alerts
-	result, err := client.Call("alert.list")
-	if err != nil {
-		return "", err
+func handleSystemHealth(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// The checks are independent, so fetch everything at once
+	lastHour := map[string]interface{}{"unit": "HOUR"}
+	batch := client.CallConcurrently(
+		truenas.BatchCall{Method: "alert.list"},
+		truenas.BatchCall{Method: "core.get_jobs", Params: []interface{}{
+			[]interface{}{[]interface{}{"state", "=", "RUNNING"}},
+		}},
+		truenas.BatchCall{Method: "reporting.get_data", Params: []interface{}{
+			[]interface{}{map[string]interface{}{"name": "cpu", "identifier": nil}}, lastHour,
+		}},
+		truenas.BatchCall{Method: "system.info"},
+		truenas.BatchCall{Method: "reporting.get_data", Params: []interface{}{
+			[]interface{}{map[string]interface{}{"name": "memory", "identifier": nil}}, lastHour,
+		}},
+		truenas.BatchCall{Method: "pool.query"},
+		truenas.BatchCall{Method: "directoryservices.status"},
+	)
+
+	// Each check degrades on its own; the report lists the ones that failed
+	checks := []string{"alerts", "active_jobs", "cpu", "system_info", "memory", "pools", "directory_service"}
+	unavailable := make([]map[string]interface{}, 0)
+	for i, call := range batch {
+		if call.Err != nil {
+			unavailable = append(unavailable, map[string]interface{}{"check": checks[i], "error": call.Err.Error()})
+		}
+	}
+	if len(unavailable) == len(batch) {
+		// Nothing answered, e.g. TrueNAS is unreachable
+		return "", batch[0].Err
 	}
 
+	// Get alerts
 	var alerts []map[string]interface{}
-	if err := json.Unmarshal(result, &alerts); err != nil {
-		return "", fmt.Errorf("failed to parse alerts: %w", err)
+	if batch[0].Err == nil {
+		if err := json.Unmarshal(batch[0].Result, &alerts); err != nil {
+			unavailable = append(unavailable, map[string]interface{}{"check": "alerts", "error": fmt.Sprintf("failed to parse alerts: %v", err)})
+		}
 	}
 
 	// Get active jobs
-	jobsResult, err := client.Call("core.get_jobs", []interface{}{
-		[]interface{}{"state", "=", "RUNNING"},
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to get jobs: %w", err)
-	}
-
 	var jobs []map[string]interface{}
-	if err := json.Unmarshal(jobsResult, &jobs); err != nil {
-		return "", fmt.Errorf("failed to parse jobs: %w", err)
+	if batch[1].Err == nil {
+		if err := json.Unmarshal(batch[1].Result, &jobs); err != nil {
+			unavailable = append(unavailable, map[string]interface{}{"check": "active_jobs", "error": fmt.Sprintf("failed to parse jobs: %v", err)})
+		}
 	}
 
 	// Create summary of active jobs
@@ -1804,12 +6172,7 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 	capacityWarnings := make([]string, 0)
 
 	// Quick capacity check using reporting data (last hour)
-	cpuResult, err := client.Call("reporting.get_data", []interface{}{
-		map[string]interface{}{
-			"name":       "cpu",
-			"identifier": nil,
-		},
-	}, map[string]interface{}{"unit": "HOUR"})
+	cpuResult, err := batch[2].Result, batch[2].Err
 	if err == nil {
 		var cpuData []map[string]interface{}
 		if err := json.Unmarshal(cpuResult, &cpuData); err == nil && len(cpuData) > 0 {
@@ -1827,7 +6190,7 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 	}
 
 	// Check memory
-	sysInfoResult, err := client.Call("system.info")
+	sysInfoResult, err := batch[3].Result, batch[3].Err
 	var totalMemory float64
 	if err == nil {
 		var sysInfo map[string]interface{}
@@ -1839,12 +6202,7 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 	}
 
 	if totalMemory > 0 {
-		memResult, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       "memory",
-				"identifier": nil,
-			},
-		}, map[string]interface{}{"unit": "HOUR"})
+		memResult, err := batch[4].Result, batch[4].Err
 		if err == nil {
 			var memData []map[string]interface{}
 			if err := json.Unmarshal(memResult, &memData); err == nil && len(memData) > 0 {
@@ -1865,7 +6223,7 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 	}
 
 	// Check pool capacity
-	poolResult, err := client.Call("pool.query")
+	poolResult, err := batch[5].Result, batch[5].Err
 	if err == nil {
 		var pools []map[string]interface{}
 		if err := json.Unmarshal(poolResult, &pools); err == nil {
@@ -1888,7 +6246,7 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 
 	// Check directory service status
 	var directoryServiceStatus map[string]interface{}
-	dirStatusResult, err := client.Call("directoryservices.status")
+	dirStatusResult, err := batch[6].Result, batch[6].Err
 	if err == nil {
 		var dirStatus map[string]interface{}
 		if err := json.Unmarshal(dirStatusResult, &dirStatus); err == nil {
@@ -1924,6 +6282,9 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 		"directory_service": directoryServiceStatus,
 		"health_check":      "OK",
 	}
+	if alerts == nil {
+		response["alerts"] = []map[string]interface{}{}
+	}
 
 	if len(alerts) > 0 {
 		response["health_check"] = "ALERTS_PRESENT"
@@ -1945,6 +6306,13 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 		}
 	}
 
+	// Failed checks are reported rather than failing the whole report, but
+	// must not read as a clean bill of health
+	response["unavailable_checks"] = unavailable
+	if len(unavailable) > 0 && response["health_check"] == "OK" {
+		response["health_check"] = "INCOMPLETE"
+	}
+
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
 		return "", err
@@ -1953,18 +6321,27 @@ func handleSystemHealth(client *truenas.Client, args map[string]interface{}) (st
 	return string(formatted), nil
 }
 
-func handleQueryPools(client *truenas.Client, args map[string]interface{}) (string, error) {
-	result, err := client.Call("pool.query")
+func handleQueryPools(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// A cursor carries the position of the next page
+	args, err := resolveQueryCursor("query_pools", args)
 	if err != nil {
 		return "", err
 	}
 
-	var pools []map[string]interface{}
-	if err := json.Unmarshal(result, &pools); err != nil {
-		return "", fmt.Errorf("failed to parse pools (raw response: %s): %w", string(result), err)
+	limit, offset := queryPagingArgs(args, 50)
+	page, err := queryPaged(client, "pool.query", []interface{}{}, map[string]interface{}{}, []string{"name"}, limit, offset)
+	if err != nil {
+		return "", err
+	}
+
+	response := map[string]interface{}{
+		"pools":       page.Records,
+		"pool_count":  len(page.Records),
+		"total_pools": page.Total,
 	}
+	addPageInfo(response, "query_pools", "pools", args, offset, len(page.Records), page.Total)
 
-	formatted, err := json.MarshalIndent(pools, "", "  ")
+	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
 		return "", err
 	}
@@ -1972,74 +6349,64 @@ func handleQueryPools(client *truenas.Client, args map[string]interface{}) (stri
 	return string(formatted), nil
 }
 
-func handleQueryDatasets(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleQueryDatasets(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// A cursor carries the filters of the first page
+	args, err := resolveQueryCursor("query_datasets", args)
+	if err != nil {
+		return "", err
+	}
+
 	// Build query filters - initialize as empty array, not nil (API expects [] not null)
 	filters := []interface{}{}
 	if pool, ok := args["pool"].(string); ok && pool != "" {
-		filters = []interface{}{
-			[]interface{}{"name", "^", pool},
-		}
+		filters = append(filters, []interface{}{"name", "^", pool})
+	}
+	encryptedOnly, _ := args["encrypted_only"].(bool)
+	if encryptedOnly {
+		filters = append(filters, []interface{}{"encrypted", "=", true})
 	}
 
-	// Options parameter (required by API even if empty)
-	options := map[string]interface{}{}
+	// Sort on the middleware (default: space usage, largest first)
+	orderBy := "used"
+	if order, ok := args["order_by"].(string); ok && order != "" {
+		orderBy = order
+	}
+	sortKeys := map[string][]string{
+		"used":      {"-used.parsed", "name"},
+		"available": {"-available.parsed", "name"},
+		"name":      {"name"},
+	}[orderBy]
+	if sortKeys == nil {
+		sortKeys = []string{"name"}
+	}
 
-	result, err := client.Call("pool.dataset.query", filters, options)
+	// Apply limit (default to 50 for manageable response size) and offset
+	limit, offset := queryPagingArgs(args, 50)
+	page, err := queryPaged(client, "pool.dataset.query", filters, map[string]interface{}{}, sortKeys, limit, offset)
 	if err != nil {
 		return "", err
 	}
 
-	var datasets []map[string]interface{}
-	if err := json.Unmarshal(result, &datasets); err != nil {
-		return "", fmt.Errorf("failed to parse datasets: %w", err)
-	}
-
 	// Simplify response
-	simplified := make([]map[string]interface{}, 0, len(datasets))
-	for _, ds := range datasets {
+	simplified := make([]map[string]interface{}, 0, len(page.Records))
+	for _, ds := range page.Records {
 		summary := simplifyDataset(ds)
 		simplified = append(simplified, summary)
 	}
 
-	// Filter by encryption status if requested
-	if encryptedOnly, ok := args["encrypted_only"].(bool); ok && encryptedOnly {
-		filtered := make([]map[string]interface{}, 0)
-		for _, ds := range simplified {
-			if encrypted, ok := ds["encrypted"].(bool); ok && encrypted {
-				filtered = append(filtered, ds)
-			}
-		}
-		simplified = filtered
-	}
-
-	// Sort datasets
-	orderBy := "used" // default to sorting by space usage
-	if order, ok := args["order_by"].(string); ok && order != "" {
-		orderBy = order
-	}
-	sortDatasets(simplified, orderBy)
-
-	// Apply limit (default to 50 for manageable response size)
-	limit := 50
-	if l, ok := args["limit"].(float64); ok && l > 0 {
-		limit = int(l)
-	}
-	if len(simplified) > limit {
-		simplified = simplified[:limit]
-	}
-
 	// Add metadata wrapper
 	response := map[string]interface{}{
 		"datasets":       simplified,
 		"dataset_count":  len(simplified),
-		"total_datasets": len(datasets),
+		"total_datasets": page.Total,
 	}
 	if pool, ok := args["pool"].(string); ok && pool != "" {
 		response["pool_filter"] = pool
 	}
-	if len(simplified) < len(datasets) {
-		response["note"] = fmt.Sprintf("Showing %d of %d datasets (limited)", len(simplified), len(datasets))
+	if encryptedOnly {
+		response["encrypted_filter"] = "only encrypted datasets"
 	}
+	addPageInfo(response, "query_datasets", "datasets", args, offset, len(simplified), page.Total)
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -2150,53 +6517,32 @@ func simplifyDataset(ds map[string]interface{}) map[string]interface{} {
 	return summary
 }
 
-// sortDatasets sorts a slice of simplified datasets by the specified field
-func sortDatasets(datasets []map[string]interface{}, orderBy string) {
-	sort.Slice(datasets, func(i, j int) bool {
-		switch orderBy {
-		case "used":
-			// Sort by used_bytes descending (largest first)
-			iUsed, iOk := datasets[i]["used_bytes"].(float64)
-			jUsed, jOk := datasets[j]["used_bytes"].(float64)
-			if iOk && jOk {
-				return iUsed > jUsed
-			}
-			return false
-		case "available":
-			// Sort by available_bytes descending (most available first)
-			iAvail, iOk := datasets[i]["available_bytes"].(float64)
-			jAvail, jOk := datasets[j]["available_bytes"].(float64)
-			if iOk && jOk {
-				return iAvail > jAvail
-			}
-			return false
-		case "name":
-			// Sort by name alphabetically
-			iName, iOk := datasets[i]["name"].(string)
-			jName, jOk := datasets[j]["name"].(string)
-			if iOk && jOk {
-				return iName < jName
-			}
-			return false
-		default:
-			// Default to name
-			iName, iOk := datasets[i]["name"].(string)
-			jName, jOk := datasets[j]["name"].(string)
-			if iOk && jOk {
-				return iName < jName
-			}
-			return false
-		}
-	})
-}
+func handleQueryShares(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// A cursor carries the filters of the first page
+	args, err := resolveQueryCursor("query_shares", args)
+	if err != nil {
+		return "", err
+	}
 
-func handleQueryShares(client *truenas.Client, args map[string]interface{}) (string, error) {
 	shareType := "all"
 	if st, ok := args["share_type"].(string); ok && st != "" {
 		shareType = st
 	}
 
+	filter := shareFilter{}
+	if p, ok := args["path"].(string); ok && p != "" {
+		filter.pathPrefix = p
+	}
+	if ds, ok := args["dataset"].(string); ok && ds != "" {
+		filter.dataset = "/mnt/" + strings.Trim(strings.TrimPrefix(ds, "/mnt/"), "/")
+	}
+	if enabled, ok := args["enabled"].(bool); ok {
+		filter.enabled = &enabled
+	}
+
 	response := make(map[string]interface{})
+	counts := map[string]interface{}{}
+	var smbMatches, nfsMatches []map[string]interface{}
 
 	// Query SMB shares
 	if shareType == "smb" || shareType == "all" {
@@ -2209,7 +6555,16 @@ func handleQueryShares(client *truenas.Client, args map[string]interface{}) (str
 		if err := json.Unmarshal(result, &smbShares); err != nil {
 			return "", fmt.Errorf("failed to parse SMB shares: %w", err)
 		}
-		response["smb_shares"] = smbShares
+
+		simplified := make([]map[string]interface{}, 0, len(smbShares))
+		for _, share := range smbShares {
+			if filter.matches(share) {
+				simplified = append(simplified, simplifySMBShare(share))
+			}
+		}
+		sortSharesByPath(simplified)
+		smbMatches = simplified
+		counts["smb"] = shareCounts(simplified, len(smbShares))
 	}
 
 	// Query NFS shares
@@ -2223,7 +6578,46 @@ func handleQueryShares(client *truenas.Client, args map[string]interface{}) (str
 		if err := json.Unmarshal(result, &nfsShares); err != nil {
 			return "", fmt.Errorf("failed to parse NFS shares: %w", err)
 		}
-		response["nfs_shares"] = nfsShares
+
+		simplified := make([]map[string]interface{}, 0, len(nfsShares))
+		for _, share := range nfsShares {
+			if filter.matches(share) {
+				simplified = append(simplified, simplifyNFSShare(share))
+			}
+		}
+		sortSharesByPath(simplified)
+		nfsMatches = simplified
+		counts["nfs"] = shareCounts(simplified, len(nfsShares))
+	}
+
+	// Pages run through the SMB shares, then the NFS exports
+	limit, offset := queryPagingArgs(args, 50)
+	total := len(smbMatches) + len(nfsMatches)
+	start, end := pageWindow(len(smbMatches), offset, limit)
+	pageCount := end - start
+	if shareType == "smb" || shareType == "all" {
+		response["smb_shares"] = smbMatches[start:end]
+	}
+	nfsOffset := offset - len(smbMatches)
+	if nfsOffset < 0 {
+		nfsOffset = 0
+	}
+	start, end = pageWindow(len(nfsMatches), nfsOffset, limit-pageCount)
+	pageCount += end - start
+	if shareType == "nfs" || shareType == "all" {
+		response["nfs_shares"] = nfsMatches[start:end]
+	}
+	addPageInfo(response, "query_shares", "shares", args, offset, pageCount, total)
+
+	response["counts"] = counts
+	if filter.pathPrefix != "" {
+		response["path_filter"] = filter.pathPrefix
+	}
+	if filter.dataset != "" {
+		response["dataset_filter"] = strings.TrimPrefix(filter.dataset, "/mnt/")
+	}
+	if filter.enabled != nil {
+		response["enabled_filter"] = *filter.enabled
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -2234,7 +6628,111 @@ func handleQueryShares(client *truenas.Client, args map[string]interface{}) (str
 	return string(formatted), nil
 }
 
-func handleQuerySnapshots(client *truenas.Client, args map[string]interface{}) (string, error) {
+// shareFilter selects shares by path and enabled state
+type shareFilter struct {
+	pathPrefix string // Raw prefix of the share path
+	dataset    string // Mountpoint the share must be at or below
+	enabled    *bool
+}
+
+func (f shareFilter) matches(share map[string]interface{}) bool {
+	path, _ := share["path"].(string)
+	if f.pathPrefix != "" && !strings.HasPrefix(path, f.pathPrefix) {
+		return false
+	}
+	if f.dataset != "" && path != f.dataset && !strings.HasPrefix(path, f.dataset+"/") {
+		return false
+	}
+	if f.enabled != nil {
+		if enabled, _ := share["enabled"].(bool); enabled != *f.enabled {
+			return false
+		}
+	}
+	return true
+}
+
+// simplifySMBShare keeps the fields needed to identify and review an SMB share
+func simplifySMBShare(share map[string]interface{}) map[string]interface{} {
+	summary := map[string]interface{}{
+		"id":      share["id"],
+		"name":    share["name"],
+		"path":    share["path"],
+		"enabled": share["enabled"],
+	}
+	for _, key := range []string{"purpose", "comment", "readonly", "browsable", "locked"} {
+		if value, ok := share[key]; ok && value != nil && value != "" {
+			summary[key] = value
+		}
+	}
+	return summary
+}
+
+// simplifyNFSShare keeps the fields needed to identify and review an NFS export
+func simplifyNFSShare(share map[string]interface{}) map[string]interface{} {
+	summary := map[string]interface{}{
+		"id":      share["id"],
+		"path":    share["path"],
+		"enabled": share["enabled"],
+	}
+	for _, key := range []string{"comment", "ro", "maproot_user", "mapall_user", "locked"} {
+		if value, ok := share[key]; ok && value != nil && value != "" {
+			summary[key] = value
+		}
+	}
+	// Access lists are only shown when set; empty lists allow every client
+	for _, key := range []string{"networks", "hosts"} {
+		if list, ok := share[key].([]interface{}); ok && len(list) > 0 {
+			summary[key] = list
+		}
+	}
+	return summary
+}
+
+func sortSharesByPath(shares []map[string]interface{}) {
+	sort.SliceStable(shares, func(i, j int) bool {
+		pi, _ := shares[i]["path"].(string)
+		pj, _ := shares[j]["path"].(string)
+		return pi < pj
+	})
+}
+
+// shareCounts summarizes one protocol's shares after filtering
+func shareCounts(shares []map[string]interface{}, total int) map[string]interface{} {
+	enabled := 0
+	for _, share := range shares {
+		if e, _ := share["enabled"].(bool); e {
+			enabled++
+		}
+	}
+	return map[string]interface{}{
+		"total":    total,
+		"matching": len(shares),
+		"enabled":  enabled,
+		"disabled": len(shares) - enabled,
+	}
+}
+
+func (r *Registry) handleQuerySnapshots(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// Apply limit (default to 50 for manageable response size)
+	limit := 50
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	// Later pages of a streamed query come from its buffer; other cursors
+	// carry the filters of the first page
+	if cursor, ok := args["cursor"].(string); ok && isStreamCursor(cursor) {
+		page, err := r.resultBuffers.nextPage("query_snapshots", cursor, limit)
+		if err != nil {
+			return "", err
+		}
+		return marshalJSON(page)
+	}
+	args, err := resolveQueryCursor("query_snapshots", args)
+	if err != nil {
+		return "", err
+	}
+
 	// Build query filters - initialize as empty array, not nil (API expects [] not null)
 	filters := []interface{}{}
 	if dataset, ok := args["dataset"].(string); ok && dataset != "" {
@@ -2245,70 +6743,92 @@ func handleQuerySnapshots(client *truenas.Client, args map[string]interface{}) (
 	}
 
 	// Options parameter (required by API even if empty)
-	options := map[string]interface{}{}
-
-	result, err := client.Call("pool.snapshot.query", filters, options)
-	if err != nil {
-		return "", err
+	extra := map[string]interface{}{}
+	holdsOnly, _ := args["holds_only"].(bool)
+	if holdsOnly {
+		// Holds are only reported when asked for
+		extra["holds"] = true
+		filters = append(filters, []interface{}{"holds", "!=", map[string]interface{}{}})
 	}
 
-	var snapshots []map[string]interface{}
-	if err := json.Unmarshal(result, &snapshots); err != nil {
-		return "", fmt.Errorf("failed to parse snapshots: %w", err)
+	// Sort on the middleware (default: snapshot name descending, which puts
+	// the newest automatic snapshots first)
+	orderBy := "name"
+	if order, ok := args["order_by"].(string); ok && order != "" {
+		orderBy = order
+	}
+	var sortKeys []string
+	switch orderBy {
+	case "dataset":
+		sortKeys = []string{"dataset", "-snapshot_name"}
+	case "created":
+		extra["properties"] = []string{"creation"}
+		sortKeys = []string{"-properties.creation.parsed", "-snapshot_name"}
+	default:
+		sortKeys = []string{"-snapshot_name"}
+	}
+	options := map[string]interface{}{}
+	if len(extra) > 0 {
+		options["extra"] = extra
 	}
 
-	// Simplify response
-	simplified := make([]map[string]interface{}, 0, len(snapshots))
-	for _, snap := range snapshots {
-		summary := simplifySnapshot(snap)
-		simplified = append(simplified, summary)
+	// Filters are echoed in the response and on every streamed page
+	filterInfo := map[string]interface{}{}
+	if dataset, ok := args["dataset"].(string); ok && dataset != "" {
+		filterInfo["dataset_filter"] = dataset
+	}
+	if pool, ok := args["pool"].(string); ok && pool != "" {
+		filterInfo["pool_filter"] = pool
+	}
+	if holdsOnly {
+		filterInfo["holds_filter"] = "only snapshots with holds"
 	}
 
-	// Filter by holds_only if requested
-	if holdsOnly, ok := args["holds_only"].(bool); ok && holdsOnly {
-		filtered := make([]map[string]interface{}, 0)
-		for _, snap := range simplified {
-			if holdsCount, ok := snap["holds_count"].(int); ok && holdsCount > 0 {
-				filtered = append(filtered, snap)
-			}
+	// A streamed query reads every match once and pages from its buffer
+	if stream, ok := args["stream"].(bool); ok && stream {
+		result, err := client.Call("pool.snapshot.query", filters, truenas.WithQueryOptions(options, map[string]interface{}{"order_by": sortKeys}))
+		if err != nil {
+			return "", err
+		}
+		var snapshots []map[string]interface{}
+		if err := json.Unmarshal(result, &snapshots); err != nil {
+			return "", fmt.Errorf("failed to parse snapshots: %w", err)
+		}
+		simplified := make([]map[string]interface{}, 0, len(snapshots))
+		for _, snap := range snapshots {
+			simplified = append(simplified, simplifySnapshot(snap))
 		}
-		simplified = filtered
+		return marshalJSON(r.resultBuffers.firstPage("query_snapshots", "snapshots", simplified, limit, filterInfo))
 	}
 
-	// Sort snapshots
-	orderBy := "name" // default to sorting by snapshot name descending
-	if order, ok := args["order_by"].(string); ok && order != "" {
-		orderBy = order
+	offset := 0
+	if o, ok := args["offset"].(float64); ok && o > 0 {
+		offset = int(o)
 	}
-	sortSnapshots(simplified, orderBy)
-
-	// Apply limit (default to 50 for manageable response size)
-	limit := 50
-	if l, ok := args["limit"].(float64); ok && l > 0 {
-		limit = int(l)
+	page, err := queryPaged(client, "pool.snapshot.query", filters, options, sortKeys, limit, offset)
+	if err != nil {
+		return "", err
 	}
-	totalSnapshots := len(simplified)
-	if len(simplified) > limit {
-		simplified = simplified[:limit]
+
+	// Simplify response
+	simplified := make([]map[string]interface{}, 0, len(page.Records))
+	for _, snap := range page.Records {
+		summary := simplifySnapshot(snap)
+		simplified = append(simplified, summary)
 	}
 
 	// Add metadata wrapper
 	response := map[string]interface{}{
 		"snapshots":       simplified,
 		"snapshot_count":  len(simplified),
-		"total_snapshots": totalSnapshots,
-	}
-	if dataset, ok := args["dataset"].(string); ok && dataset != "" {
-		response["dataset_filter"] = dataset
-	}
-	if pool, ok := args["pool"].(string); ok && pool != "" {
-		response["pool_filter"] = pool
+		"total_snapshots": page.Total,
 	}
-	if holdsOnly, ok := args["holds_only"].(bool); ok && holdsOnly {
-		response["holds_filter"] = "only snapshots with holds"
+	for k, v := range filterInfo {
+		response[k] = v
 	}
-	if len(simplified) < totalSnapshots {
-		response["note"] = fmt.Sprintf("Showing %d of %d snapshots (limited)", len(simplified), totalSnapshots)
+	addPageInfo(response, "query_snapshots", "snapshots", args, offset, len(simplified), page.Total)
+	if note, ok := response["note"].(string); ok {
+		response["note"] = note + ". Use stream=true to page through all of them from one consistent query."
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -2399,129 +6919,59 @@ func parseSnapshotDate(name string) string {
 	return "" // No date found
 }
 
-// sortSnapshots sorts a slice of simplified snapshots by the specified field
-func sortSnapshots(snapshots []map[string]interface{}, orderBy string) {
-	sort.Slice(snapshots, func(i, j int) bool {
-		switch orderBy {
-		case "name":
-			// Sort by snapshot_name descending (newest automatic snapshots first)
-			iName, iOk := snapshots[i]["snapshot_name"].(string)
-			jName, jOk := snapshots[j]["snapshot_name"].(string)
-			if iOk && jOk {
-				return iName > jName // Descending
-			}
-			return false
-		case "dataset":
-			// Sort by dataset path alphabetically ascending
-			iDataset, iOk := snapshots[i]["dataset"].(string)
-			jDataset, jOk := snapshots[j]["dataset"].(string)
-			if iOk && jOk {
-				return iDataset < jDataset
-			}
-			return false
-		case "created":
-			// Sort by parsed created_date descending, fallback to name
-			iCreated, iOk := snapshots[i]["created_date"].(string)
-			jCreated, jOk := snapshots[j]["created_date"].(string)
-			if iOk && jOk {
-				return iCreated > jCreated
-			}
-			// Fallback to name comparison
-			iName, iOk := snapshots[i]["snapshot_name"].(string)
-			jName, jOk := snapshots[j]["snapshot_name"].(string)
-			if iOk && jOk {
-				return iName > jName
-			}
-			return false
-		default:
-			// Default to name descending
-			iName, iOk := snapshots[i]["snapshot_name"].(string)
-			jName, jOk := snapshots[j]["snapshot_name"].(string)
-			if iOk && jOk {
-				return iName > jName
-			}
-			return false
-		}
-	})
-}
-
-func handleQueryVMs(client *truenas.Client, args map[string]interface{}) (string, error) {
-	// Call vm.query with no filters (we'll filter in post-processing)
-	result, err := client.Call("vm.query")
+func handleQueryVMs(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// A cursor carries the filters of the first page
+	args, err := resolveQueryCursor("query_vms", args)
 	if err != nil {
 		return "", err
 	}
 
-	var vms []map[string]interface{}
-	if err := json.Unmarshal(result, &vms); err != nil {
-		return "", fmt.Errorf("failed to parse VMs: %w", err)
-	}
-
-	// Simplify response
-	simplified := make([]map[string]interface{}, 0, len(vms))
-	for _, vm := range vms {
-		summary := simplifyVM(vm)
-		simplified = append(simplified, summary)
-	}
-
-	// Filter by name (partial match)
+	// Build query filters - initialize as empty array, not nil (API expects [] not null)
+	filters := []interface{}{}
 	if name, ok := args["name"].(string); ok && name != "" {
-		filtered := make([]map[string]interface{}, 0)
-		nameLower := strings.ToLower(name)
-		for _, vm := range simplified {
-			if vmName, ok := vm["name"].(string); ok {
-				if strings.Contains(strings.ToLower(vmName), nameLower) {
-					filtered = append(filtered, vm)
-				}
-			}
-		}
-		simplified = filtered
+		// Partial, case-insensitive match; the middleware anchors ~ at the start
+		filters = append(filters, []interface{}{"name", "~", "(?i).*" + regexp.QuoteMeta(name)})
 	}
-
-	// Filter by state
 	if state, ok := args["state"].(string); ok && state != "" && state != "all" {
-		filtered := make([]map[string]interface{}, 0)
-		for _, vm := range simplified {
-			if vmState, ok := vm["state"].(string); ok && vmState == state {
-				filtered = append(filtered, vm)
-			}
-		}
-		simplified = filtered
-	}
-
-	// Filter by autostart
-	if autostart, ok := args["autostart"].(bool); ok {
-		filtered := make([]map[string]interface{}, 0)
-		for _, vm := range simplified {
-			if vmAutostart, ok := vm["autostart"].(bool); ok && vmAutostart == autostart {
-				filtered = append(filtered, vm)
-			}
-		}
-		simplified = filtered
+		filters = append(filters, []interface{}{"status.state", "=", state})
+	}
+	if autostart, ok := args["autostart"].(bool); ok {
+		filters = append(filters, []interface{}{"autostart", "=", autostart})
 	}
 
-	// Sort VMs
-	orderBy := "name" // default to sorting by name
+	// Sort on the middleware (default: name)
+	orderBy := "name"
 	if order, ok := args["order_by"].(string); ok && order != "" {
 		orderBy = order
 	}
-	sortVMs(simplified, orderBy)
 
-	// Apply limit (default to 50)
-	limit := 50
-	if l, ok := args["limit"].(float64); ok && l > 0 {
-		limit = int(l)
+	// Apply limit (default to 50) and offset
+	limit, offset := queryPagingArgs(args, 50)
+	var page queryPage
+	switch orderBy {
+	case "memory":
+		page, err = queryPaged(client, "vm.query", filters, map[string]interface{}{}, []string{"-memory", "name"}, limit, offset)
+	case "status":
+		page, err = queryPagedFirst(client, "vm.query", filters, map[string]interface{}{}, "status.state", "RUNNING", []string{"name"}, limit, offset)
+	default:
+		page, err = queryPaged(client, "vm.query", filters, map[string]interface{}{}, []string{"name"}, limit, offset)
 	}
-	totalVMs := len(simplified)
-	if len(simplified) > limit {
-		simplified = simplified[:limit]
+	if err != nil {
+		return "", err
+	}
+
+	// Simplify response
+	simplified := make([]map[string]interface{}, 0, len(page.Records))
+	for _, vm := range page.Records {
+		summary := simplifyVM(vm)
+		simplified = append(simplified, summary)
 	}
 
 	// Add metadata wrapper
 	response := map[string]interface{}{
 		"vms":       simplified,
 		"vm_count":  len(simplified),
-		"total_vms": totalVMs,
+		"total_vms": page.Total,
 	}
 	if name, ok := args["name"].(string); ok && name != "" {
 		response["name_filter"] = name
@@ -2532,9 +6982,7 @@ func handleQueryVMs(client *truenas.Client, args map[string]interface{}) (string
 	if autostart, ok := args["autostart"].(bool); ok {
 		response["autostart_filter"] = autostart
 	}
-	if len(simplified) < totalVMs {
-		response["note"] = fmt.Sprintf("Showing %d of %d VMs (limited)", len(simplified), totalVMs)
-	}
+	addPageInfo(response, "query_vms", "VMs", args, offset, len(simplified), page.Total)
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -2571,10 +7019,10 @@ func simplifyVM(vm map[string]interface{}) map[string]interface{} {
 		summary["cpu_mode"] = cpuMode
 	}
 
-	// Memory (convert to GB for readability)
+	// Memory is configured in MiB
 	if memory, ok := vm["memory"].(float64); ok {
 		summary["memory_mb"] = int(memory)
-		summary["memory_gb"] = fmt.Sprintf("%.1f GB", memory/1024.0)
+		summary["memory"] = units.NewSize(int64(memory) * units.MiB)
 	}
 
 	// Boot configuration
@@ -2691,68 +7139,21 @@ func simplifyVMDevices(devices []interface{}) map[string]interface{} {
 	return summary
 }
 
-// sortVMs sorts a slice of simplified VMs by the specified field
-func sortVMs(vms []map[string]interface{}, orderBy string) {
-	sort.Slice(vms, func(i, j int) bool {
-		switch orderBy {
-		case "name":
-			// Sort by name alphabetically ascending
-			iName, iOk := vms[i]["name"].(string)
-			jName, jOk := vms[j]["name"].(string)
-			if iOk && jOk {
-				return iName < jName
-			}
-			return false
-		case "memory":
-			// Sort by memory descending (largest first)
-			iMem, iOk := vms[i]["memory_mb"].(int)
-			jMem, jOk := vms[j]["memory_mb"].(int)
-			if iOk && jOk {
-				return iMem > jMem
-			}
-			return false
-		case "status":
-			// Sort by state (RUNNING first, then others)
-			iState, iOk := vms[i]["state"].(string)
-			jState, jOk := vms[j]["state"].(string)
-			if iOk && jOk {
-				if iState == "RUNNING" && jState != "RUNNING" {
-					return true
-				}
-				if jState == "RUNNING" && iState != "RUNNING" {
-					return false
-				}
-				// If both same state, sort by name
-				iName, _ := vms[i]["name"].(string)
-				jName, _ := vms[j]["name"].(string)
-				return iName < jName
-			}
-			return false
-		default:
-			// Default to name
-			iName, iOk := vms[i]["name"].(string)
-			jName, jOk := vms[j]["name"].(string)
-			if iOk && jOk {
-				return iName < jName
-			}
-			return false
-		}
-	})
-}
-
 // Alert management handlers
 
-func handleListAlerts(client *truenas.Client, args map[string]interface{}) (string, error) {
-	// alert.list doesn't take filter parameters in the same way as other queries
-	// It just returns all alerts, so we'll filter in post-processing if needed
-	result, err := client.Call("alert.list")
-	if err != nil {
-		return "", err
-	}
-
-	var alerts []map[string]interface{}
-	if err := json.Unmarshal(result, &alerts); err != nil {
-		return "", fmt.Errorf("failed to parse alerts: %w", err)
+func (r *Registry) handleListAlerts(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	alerts, freshness, cached := r.cachedAlerts(args)
+	if !cached {
+		// alert.list doesn't take filter parameters in the same way as other queries
+		// It just returns all alerts, so we'll filter in post-processing if needed
+		result, err := client.Call("alert.list")
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(result, &alerts); err != nil {
+			return "", fmt.Errorf("failed to parse alerts: %w", err)
+		}
+		freshness = events.LiveFreshness()
 	}
 
 	// Post-filter by dismissed status if requested
@@ -2765,16 +7166,24 @@ func handleListAlerts(client *truenas.Client, args map[string]interface{}) (stri
 		}
 		alerts = filtered
 	}
-
-	formatted, err := json.MarshalIndent(alerts, "", "  ")
-	if err != nil {
-		return "", err
+	if alerts == nil {
+		alerts = []map[string]interface{}{}
 	}
+	// Most recent first, matching alert.list
+	sort.SliceStable(alerts, func(i, j int) bool {
+		a, _ := middlewareTime(alerts[i]["datetime"])
+		b, _ := middlewareTime(alerts[j]["datetime"])
+		return a.After(b)
+	})
 
-	return string(formatted), nil
+	return marshalJSON(map[string]interface{}{
+		"alerts":      alerts,
+		"alert_count": len(alerts),
+		"freshness":   freshness,
+	})
 }
 
-func handleDismissAlert(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleDismissAlert(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	uuid, ok := args["uuid"].(string)
 	if !ok || uuid == "" {
 		return "", fmt.Errorf("uuid parameter is required")
@@ -2788,7 +7197,7 @@ func handleDismissAlert(client *truenas.Client, args map[string]interface{}) (st
 	return fmt.Sprintf("Alert %s dismissed successfully: %s", uuid, string(result)), nil
 }
 
-func handleRestoreAlert(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleRestoreAlert(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	uuid, ok := args["uuid"].(string)
 	if !ok || uuid == "" {
 		return "", fmt.Errorf("uuid parameter is required")
@@ -2804,10 +7213,10 @@ func handleRestoreAlert(client *truenas.Client, args map[string]interface{}) (st
 
 // Reporting handlers
 
-func handleGetSystemMetrics(client *truenas.Client, args map[string]interface{}) (string, error) {
-	unit := "HOUR"
-	if u, ok := args["unit"].(string); ok && u != "" {
-		unit = u
+func handleGetSystemMetrics(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	q, err := parseMetricsQuery(args)
+	if err != nil {
+		return "", err
 	}
 
 	// Default graphs if not specified
@@ -2824,70 +7233,19 @@ func handleGetSystemMetrics(client *truenas.Client, args map[string]interface{})
 	response := make(map[string]interface{})
 
 	for _, graph := range graphs {
-		var apiGraph string
 		switch graph {
-		case "cpu":
-			apiGraph = "cpu"
-		case "cputemp":
-			apiGraph = "cputemp"
-		case "memory":
-			apiGraph = "memory"
-		case "load":
-			apiGraph = "load"
-		case "uptime":
-			apiGraph = "uptime"
+		case "cpu", "cputemp", "memory", "load", "uptime":
 		default:
 			continue
 		}
 
-		result, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       apiGraph,
-				"identifier": nil,
-			},
-		}, map[string]interface{}{"unit": unit})
+		// Keep aggregations and metadata, downsample data points to reduce size
+		summaries, err := fetchReportingGraph(client, graph, nil, q)
 		if err != nil {
 			response[graph] = map[string]string{"error": err.Error()}
 			continue
 		}
-
-		var fullData []map[string]interface{}
-		if err := json.Unmarshal(result, &fullData); err != nil {
-			response[graph] = map[string]string{"error": fmt.Sprintf("parse error: %v", err)}
-			continue
-		}
-
-		// Keep aggregations and metadata, but sample data points to reduce size
-		summary := make(map[string]interface{})
-		if len(fullData) > 0 {
-			for key, value := range fullData[0] {
-				if key == "data" {
-					// Include sample of data points: first 10 and last 10
-					if dataArray, ok := value.([]interface{}); ok {
-						summary["data_points_total"] = len(dataArray)
-						sample := make([]interface{}, 0)
-
-						// First 10 points
-						for i := 0; i < 10 && i < len(dataArray); i++ {
-							sample = append(sample, dataArray[i])
-						}
-
-						// Last 10 points (if we have more than 20 total)
-						if len(dataArray) > 20 {
-							for i := len(dataArray) - 10; i < len(dataArray); i++ {
-								sample = append(sample, dataArray[i])
-							}
-						}
-
-						summary["data_sample"] = sample
-					}
-				} else {
-					// Keep all other fields: aggregations, start, end, legend, name, identifier
-					summary[key] = value
-				}
-			}
-		}
-		response[graph] = summary
+		response[graph] = firstReportingSummary(summaries)
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -2898,10 +7256,10 @@ func handleGetSystemMetrics(client *truenas.Client, args map[string]interface{})
 	return string(formatted), nil
 }
 
-func handleGetNetworkMetrics(client *truenas.Client, args map[string]interface{}) (string, error) {
-	unit := "HOUR"
-	if u, ok := args["unit"].(string); ok && u != "" {
-		unit = u
+func handleGetNetworkMetrics(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	q, err := parseMetricsQuery(args)
+	if err != nil {
+		return "", err
 	}
 
 	iface, _ := args["interface"].(string)
@@ -2938,56 +7296,13 @@ func handleGetNetworkMetrics(client *truenas.Client, args map[string]interface{}
 	allMetrics := make(map[string]interface{})
 
 	for _, ifaceName := range interfaces {
-		result, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       "interface",
-				"identifier": ifaceName,
-			},
-		}, map[string]interface{}{"unit": unit})
-
+		// Keep aggregations and metadata, downsample data points to reduce size
+		summaries, err := fetchReportingGraph(client, "interface", ifaceName, q)
 		if err != nil {
 			allMetrics[ifaceName] = map[string]string{"error": err.Error()}
 			continue
 		}
 
-		var fullData []map[string]interface{}
-		if err := json.Unmarshal(result, &fullData); err != nil {
-			allMetrics[ifaceName] = map[string]string{"error": fmt.Sprintf("parse error: %v", err)}
-			continue
-		}
-
-		// Keep aggregations and metadata, sample data points to reduce size
-		summaries := make([]map[string]interface{}, 0, len(fullData))
-		for _, item := range fullData {
-			summary := make(map[string]interface{})
-			for key, value := range item {
-				if key == "data" {
-					// Include sample: first 10 and last 10 data points
-					if dataArray, ok := value.([]interface{}); ok {
-						summary["data_points_total"] = len(dataArray)
-						if len(dataArray) > 0 {
-							sample := make([]interface{}, 0)
-
-							for i := 0; i < 10 && i < len(dataArray); i++ {
-								sample = append(sample, dataArray[i])
-							}
-
-							if len(dataArray) > 20 {
-								for i := len(dataArray) - 10; i < len(dataArray); i++ {
-									sample = append(sample, dataArray[i])
-								}
-							}
-
-							summary["data_sample"] = sample
-						}
-					}
-				} else {
-					summary[key] = value
-				}
-			}
-			summaries = append(summaries, summary)
-		}
-
 		if len(summaries) == 1 {
 			allMetrics[ifaceName] = summaries[0]
 		} else {
@@ -3003,10 +7318,10 @@ func handleGetNetworkMetrics(client *truenas.Client, args map[string]interface{}
 	return string(formatted), nil
 }
 
-func handleGetDiskMetrics(client *truenas.Client, args map[string]interface{}) (string, error) {
-	unit := "HOUR"
-	if u, ok := args["unit"].(string); ok && u != "" {
-		unit = u
+func handleGetDiskMetrics(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	q, err := parseMetricsQuery(args)
+	if err != nil {
+		return "", err
 	}
 
 	requestedDisk, _ := args["disk"].(string)
@@ -3069,56 +7384,13 @@ func handleGetDiskMetrics(client *truenas.Client, args map[string]interface{}) (
 			diskName = identifier[:idx]
 		}
 
-		result, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       graphType,
-				"identifier": identifier,
-			},
-		}, map[string]interface{}{"unit": unit})
-
+		// Keep aggregations and metadata, downsample data points to reduce size
+		summaries, err := fetchReportingGraph(client, graphType, identifier, q)
 		if err != nil {
 			allMetrics[diskName] = map[string]string{"error": err.Error()}
 			continue
 		}
 
-		var fullData []map[string]interface{}
-		if err := json.Unmarshal(result, &fullData); err != nil {
-			allMetrics[diskName] = map[string]string{"error": fmt.Sprintf("parse error: %v", err)}
-			continue
-		}
-
-		// Keep aggregations and metadata, sample data points to reduce size
-		summaries := make([]map[string]interface{}, 0, len(fullData))
-		for _, item := range fullData {
-			summary := make(map[string]interface{})
-			for key, value := range item {
-				if key == "data" {
-					// Include sample: first 10 and last 10 data points
-					if dataArray, ok := value.([]interface{}); ok {
-						summary["data_points_total"] = len(dataArray)
-						if len(dataArray) > 0 {
-							sample := make([]interface{}, 0)
-
-							for i := 0; i < 10 && i < len(dataArray); i++ {
-								sample = append(sample, dataArray[i])
-							}
-
-							if len(dataArray) > 20 {
-								for i := len(dataArray) - 10; i < len(dataArray); i++ {
-									sample = append(sample, dataArray[i])
-								}
-							}
-
-							summary["data_sample"] = sample
-						}
-					}
-				} else {
-					summary[key] = value
-				}
-			}
-			summaries = append(summaries, summary)
-		}
-
 		if len(summaries) == 1 {
 			allMetrics[diskName] = summaries[0]
 		} else {
@@ -3134,10 +7406,10 @@ func handleGetDiskMetrics(client *truenas.Client, args map[string]interface{}) (
 	return string(formatted), nil
 }
 
-func handleGetArcMetrics(client *truenas.Client, args map[string]interface{}) (string, error) {
-	unit := "HOUR"
-	if u, ok := args["unit"].(string); ok && u != "" {
-		unit = u
+func handleGetArcMetrics(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	q, err := parseMetricsQuery(args)
+	if err != nil {
+		return "", err
 	}
 
 	// Default graphs if not specified
@@ -3154,49 +7426,12 @@ func handleGetArcMetrics(client *truenas.Client, args map[string]interface{}) (s
 	response := make(map[string]interface{})
 
 	for _, graph := range graphs {
-		result, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       graph,
-				"identifier": nil,
-			},
-		}, map[string]interface{}{"unit": unit})
+		summaries, err := fetchReportingGraph(client, graph, nil, q)
 		if err != nil {
 			response[graph] = map[string]string{"error": err.Error()}
 			continue
 		}
-
-		var fullData []map[string]interface{}
-		if err := json.Unmarshal(result, &fullData); err != nil {
-			response[graph] = map[string]string{"error": fmt.Sprintf("parse error: %v", err)}
-			continue
-		}
-
-		summary := make(map[string]interface{})
-		if len(fullData) > 0 {
-			for key, value := range fullData[0] {
-				if key == "data" {
-					if dataArray, ok := value.([]interface{}); ok {
-						summary["data_points_total"] = len(dataArray)
-						sample := make([]interface{}, 0)
-
-						for i := 0; i < 10 && i < len(dataArray); i++ {
-							sample = append(sample, dataArray[i])
-						}
-
-						if len(dataArray) > 20 {
-							for i := len(dataArray) - 10; i < len(dataArray); i++ {
-								sample = append(sample, dataArray[i])
-							}
-						}
-
-						summary["data_sample"] = sample
-					}
-				} else {
-					summary[key] = value
-				}
-			}
-		}
-		response[graph] = summary
+		response[graph] = firstReportingSummary(summaries)
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -3207,10 +7442,10 @@ func handleGetArcMetrics(client *truenas.Client, args map[string]interface{}) (s
 	return string(formatted), nil
 }
 
-func handleGetUpsMetrics(client *truenas.Client, args map[string]interface{}) (string, error) {
-	unit := "HOUR"
-	if u, ok := args["unit"].(string); ok && u != "" {
-		unit = u
+func handleGetUpsMetrics(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	q, err := parseMetricsQuery(args)
+	if err != nil {
+		return "", err
 	}
 
 	// Default to all UPS graphs
@@ -3269,48 +7504,12 @@ func handleGetUpsMetrics(client *truenas.Client, args map[string]interface{}) (s
 				if identifier != "" {
 					callIdentifier = identifier
 				}
-				result, err := client.Call("reporting.get_data", []interface{}{
-					map[string]interface{}{
-						"name":       "upsvoltage",
-						"identifier": callIdentifier,
-					},
-				}, map[string]interface{}{"unit": unit})
+				summaries, err := fetchReportingGraph(client, "upsvoltage", callIdentifier, q)
 				if err != nil {
 					voltageData[identifier] = map[string]string{"error": err.Error()}
 					continue
 				}
-
-				var fullData []map[string]interface{}
-				if err := json.Unmarshal(result, &fullData); err != nil {
-					voltageData[identifier] = map[string]string{"error": fmt.Sprintf("parse error: %v", err)}
-					continue
-				}
-
-				summary := make(map[string]interface{})
-				if len(fullData) > 0 {
-					for key, value := range fullData[0] {
-						if key == "data" {
-							if dataArray, ok := value.([]interface{}); ok {
-								summary["data_points_total"] = len(dataArray)
-								sample := make([]interface{}, 0)
-
-								for i := 0; i < 10 && i < len(dataArray); i++ {
-									sample = append(sample, dataArray[i])
-								}
-
-								if len(dataArray) > 20 {
-									for i := len(dataArray) - 10; i < len(dataArray); i++ {
-										sample = append(sample, dataArray[i])
-									}
-								}
-
-								summary["data_sample"] = sample
-							}
-						} else {
-							summary[key] = value
-						}
-					}
-				}
+				summary := firstReportingSummary(summaries)
 				key := identifier
 				if key == "" {
 					key = "default"
@@ -3320,49 +7519,12 @@ func handleGetUpsMetrics(client *truenas.Client, args map[string]interface{}) (s
 			response[graph] = voltageData
 		} else {
 			// All other UPS graphs use nil identifier
-			result, err := client.Call("reporting.get_data", []interface{}{
-				map[string]interface{}{
-					"name":       graph,
-					"identifier": nil,
-				},
-			}, map[string]interface{}{"unit": unit})
+			summaries, err := fetchReportingGraph(client, graph, nil, q)
 			if err != nil {
 				response[graph] = map[string]string{"error": err.Error()}
 				continue
 			}
-
-			var fullData []map[string]interface{}
-			if err := json.Unmarshal(result, &fullData); err != nil {
-				response[graph] = map[string]string{"error": fmt.Sprintf("parse error: %v", err)}
-				continue
-			}
-
-			summary := make(map[string]interface{})
-			if len(fullData) > 0 {
-				for key, value := range fullData[0] {
-					if key == "data" {
-						if dataArray, ok := value.([]interface{}); ok {
-							summary["data_points_total"] = len(dataArray)
-							sample := make([]interface{}, 0)
-
-							for i := 0; i < 10 && i < len(dataArray); i++ {
-								sample = append(sample, dataArray[i])
-							}
-
-							if len(dataArray) > 20 {
-								for i := len(dataArray) - 10; i < len(dataArray); i++ {
-									sample = append(sample, dataArray[i])
-								}
-							}
-
-							summary["data_sample"] = sample
-						}
-					} else {
-						summary[key] = value
-					}
-				}
-			}
-			response[graph] = summary
+			response[graph] = firstReportingSummary(summaries)
 		}
 	}
 
@@ -3374,7 +7536,13 @@ func handleGetUpsMetrics(client *truenas.Client, args map[string]interface{}) (s
 	return string(formatted), nil
 }
 
-func handleQueryApps(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleQueryApps(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	// A cursor carries the filters of the first page
+	args, err := resolveQueryCursor("query_apps", args)
+	if err != nil {
+		return "", err
+	}
+
 	appName, _ := args["app_name"].(string)
 	includeConfig, _ := args["include_config"].(bool)
 
@@ -3382,9 +7550,15 @@ func handleQueryApps(client *truenas.Client, args map[string]interface{}) (strin
 	// Initialize as empty array, not nil (API expects [] not null)
 	filters := []interface{}{}
 	if appName != "" {
-		filters = []interface{}{
-			[]interface{}{"name", "=", appName},
-		}
+		filters = append(filters, []interface{}{"name", "=", appName})
+	}
+	state, _ := args["state"].(string)
+	if state != "" && state != "all" {
+		filters = append(filters, []interface{}{"state", "=", state})
+	}
+	upgradeAvailable, filterUpgrades := args["upgrade_available"].(bool)
+	if filterUpgrades {
+		filters = append(filters, []interface{}{"upgrade_available", "=", upgradeAvailable})
 	}
 
 	options := map[string]interface{}{
@@ -3393,19 +7567,21 @@ func handleQueryApps(client *truenas.Client, args map[string]interface{}) (strin
 		},
 	}
 
-	result, err := client.Call("app.query", filters, options)
+	// Sort and page on the middleware (default: name)
+	limit, offset := queryPagingArgs(args, 50)
+	var page queryPage
+	if order, _ := args["order_by"].(string); order == "state" {
+		page, err = queryPagedFirst(client, "app.query", filters, options, "state", "RUNNING", []string{"state", "name"}, limit, offset)
+	} else {
+		page, err = queryPaged(client, "app.query", filters, options, []string{"name"}, limit, offset)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to query apps: %w", err)
 	}
 
-	var apps []map[string]interface{}
-	if err := json.Unmarshal(result, &apps); err != nil {
-		return "", fmt.Errorf("failed to parse app list: %w", err)
-	}
-
 	// Simplify the response to show most relevant information
-	simplified := make([]map[string]interface{}, 0, len(apps))
-	for _, app := range apps {
+	simplified := make([]map[string]interface{}, 0, len(page.Records))
+	for _, app := range page.Records {
 		summary := map[string]interface{}{
 			"name":              app["name"],
 			"id":                app["id"],
@@ -3449,7 +7625,21 @@ func handleQueryApps(client *truenas.Client, args map[string]interface{}) (strin
 		simplified = append(simplified, summary)
 	}
 
-	formatted, err := json.MarshalIndent(simplified, "", "  ")
+	// Add metadata wrapper
+	response := map[string]interface{}{
+		"apps":       simplified,
+		"app_count":  len(simplified),
+		"total_apps": page.Total,
+	}
+	if state != "" && state != "all" {
+		response["state_filter"] = state
+	}
+	if filterUpgrades {
+		response["upgrade_available_filter"] = upgradeAvailable
+	}
+	addPageInfo(response, "query_apps", "apps", args, offset, len(simplified), page.Total)
+
+	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
 		return "", err
 	}
@@ -3457,7 +7647,7 @@ func handleQueryApps(client *truenas.Client, args map[string]interface{}) (strin
 	return string(formatted), nil
 }
 
-func (r *Registry) handleUpgradeApp(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleUpgradeApp(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
 		return "", fmt.Errorf("app_name is required")
@@ -3519,6 +7709,7 @@ func (r *Registry) handleUpgradeApp(client *truenas.Client, args map[string]inte
 		args,
 		jobID,
 		1*time.Hour, // 1 hour TTL
+		client.CorrelationID(),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
@@ -3544,14 +7735,14 @@ func (r *Registry) handleUpgradeApp(client *truenas.Client, args map[string]inte
 }
 
 // handleUpgradeAppWithDryRun wraps the upgrade handler with dry-run support
-func (r *Registry) handleUpgradeAppWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &upgradeAppDryRun{}, r.handleUpgradeApp)
+func (r *Registry) handleUpgradeAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &upgradeAppDryRun{}, r.handleUpgradeApp)
 }
 
 // upgradeAppDryRun implements dry-run preview for app upgrades
 type upgradeAppDryRun struct{}
 
-func (u *upgradeAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (u *upgradeAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
 		return nil, fmt.Errorf("app_name is required")
@@ -3650,7 +7841,7 @@ func (u *upgradeAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string
 	return result, nil
 }
 
-func (r *Registry) handleStartApp(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleStartApp(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
 		return "", fmt.Errorf("app_name is required")
@@ -3673,7 +7864,7 @@ func (r *Registry) handleStartApp(client *truenas.Client, args map[string]interf
 		jobID = jobIDArray[0]
 	}
 
-	task, err := r.taskManager.CreateJobTask("start_app", args, jobID, 10*time.Minute)
+	task, err := r.taskManager.CreateJobTask("start_app", args, jobID, 10*time.Minute, client.CorrelationID())
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
 	}
@@ -3693,13 +7884,13 @@ func (r *Registry) handleStartApp(client *truenas.Client, args map[string]interf
 	return string(formatted), nil
 }
 
-func (r *Registry) handleStartAppWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &startAppDryRun{}, r.handleStartApp)
+func (r *Registry) handleStartAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &startAppDryRun{}, r.handleStartApp)
 }
 
 type startAppDryRun struct{}
 
-func (s *startAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (s *startAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
 		return nil, fmt.Errorf("app_name is required")
@@ -3741,7 +7932,7 @@ func (s *startAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]i
 	}, nil
 }
 
-func (r *Registry) handleStopApp(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleStopApp(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
 		return "", fmt.Errorf("app_name is required")
@@ -3764,7 +7955,7 @@ func (r *Registry) handleStopApp(client *truenas.Client, args map[string]interfa
 		jobID = jobIDArray[0]
 	}
 
-	task, err := r.taskManager.CreateJobTask("stop_app", args, jobID, 5*time.Minute)
+	task, err := r.taskManager.CreateJobTask("stop_app", args, jobID, 5*time.Minute, client.CorrelationID())
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
 	}
@@ -3784,13 +7975,13 @@ func (r *Registry) handleStopApp(client *truenas.Client, args map[string]interfa
 	return string(formatted), nil
 }
 
-func (r *Registry) handleStopAppWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &stopAppDryRun{}, r.handleStopApp)
+func (r *Registry) handleStopAppWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &stopAppDryRun{}, r.handleStopApp)
 }
 
 type stopAppDryRun struct{}
 
-func (s *stopAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (s *stopAppDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	appName, ok := args["app_name"].(string)
 	if !ok || appName == "" {
 		return nil, fmt.Errorf("app_name is required")
@@ -3832,7 +8023,7 @@ func (s *stopAppDryRun) ExecuteDryRun(client *truenas.Client, args map[string]in
 	}, nil
 }
 
-func handleQueryJobs(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleQueryJobs(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	state := "RUNNING"
 	if s, ok := args["state"].(string); ok && s != "" {
 		state = s
@@ -3853,20 +8044,54 @@ func handleQueryJobs(client *truenas.Client, args map[string]interface{}) (strin
 		filters = []interface{}{}
 	}
 
-	// Build options
-	options := map[string]interface{}{
-		"limit":    limit,
-		"order_by": []string{"-time_started"}, // Most recent first
+	// Optional method prefix and start time range
+	appliedFilters := map[string]interface{}{}
+	prefix := ""
+	if method, ok := args["method"].(string); ok && method != "" {
+		prefix = jobMethodPrefix(method)
+		filters = append(filters, []interface{}{"method", "^", prefix})
+		appliedFilters["method"] = prefix
 	}
-
-	result, err := client.Call("core.get_jobs", filters, options)
-	if err != nil {
-		return "", fmt.Errorf("failed to query jobs: %w", err)
+	var startedAfter, startedBefore time.Time
+	if v, ok := args["started_after"]; ok {
+		t, err := parseJobTime(v)
+		if err != nil {
+			return "", fmt.Errorf("started_after: %w", err)
+		}
+		startedAfter = t
+		filters = append(filters, jobTimeFilter(">=", t))
+		appliedFilters["started_after"] = t.UTC().Format(time.RFC3339)
+	}
+	if v, ok := args["started_before"]; ok {
+		t, err := parseJobTime(v)
+		if err != nil {
+			return "", fmt.Errorf("started_before: %w", err)
+		}
+		startedBefore = t
+		filters = append(filters, jobTimeFilter("<", t))
+		appliedFilters["started_before"] = t.UTC().Format(time.RFC3339)
+	}
+	if !startedAfter.IsZero() && !startedBefore.IsZero() && !startedBefore.After(startedAfter) {
+		return "", fmt.Errorf("started_before must be after started_after")
 	}
 
-	var jobs []map[string]interface{}
-	if err := json.Unmarshal(result, &jobs); err != nil {
-		return "", fmt.Errorf("failed to parse jobs: %w", err)
+	jobs, freshness, cached := r.cachedJobs(args, state, prefix, startedAfter, startedBefore, limit)
+	if !cached {
+		// Build options
+		options := map[string]interface{}{
+			"limit":    limit,
+			"order_by": []string{"-time_started"}, // Most recent first
+		}
+
+		result, err := client.Call("core.get_jobs", filters, options)
+		if err != nil {
+			return "", fmt.Errorf("failed to query jobs: %w", err)
+		}
+
+		if err := json.Unmarshal(result, &jobs); err != nil {
+			return "", fmt.Errorf("failed to parse jobs: %w", err)
+		}
+		freshness = events.LiveFreshness()
 	}
 
 	// Create simplified response with relevant fields
@@ -3909,6 +8134,10 @@ func handleQueryJobs(client *truenas.Client, args map[string]interface{}) (strin
 		"jobs":         simplified,
 		"job_count":    len(simplified),
 		"state_filter": state,
+		"freshness":    freshness,
+	}
+	if len(appliedFilters) > 0 {
+		response["filters_applied"] = appliedFilters
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -3921,20 +8150,20 @@ func handleQueryJobs(client *truenas.Client, args map[string]interface{}) (strin
 
 // Capacity analysis handlers
 
-func handleAnalyzeCapacity(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleAnalyzeCapacity(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	timeRange := "MONTH"
 	if tr, ok := args["time_range"].(string); ok && tr != "" {
 		timeRange = tr
 	}
 
 	// Default to all metrics
-	metrics := []string{"cpu", "memory", "network", "disk"}
+	metrics := []string{"cpu", "memory", "network", "disk", "storage"}
 	if m, ok := args["metrics"].([]interface{}); ok && len(m) > 0 {
 		metrics = make([]string, 0, len(m))
 		for _, v := range m {
 			if s, ok := v.(string); ok {
 				if s == "all" {
-					metrics = []string{"cpu", "memory", "network", "disk"}
+					metrics = []string{"cpu", "memory", "network", "disk", "storage"}
 					break
 				}
 				metrics = append(metrics, s)
@@ -3942,39 +8171,23 @@ func handleAnalyzeCapacity(client *truenas.Client, args map[string]interface{})
 		}
 	}
 
-	analysis := make(map[string]interface{})
+	// Analyze the metrics concurrently; a failed metric reports its error
+	// in place of its analysis
+	results := make([]interface{}, len(metrics))
+	var wg sync.WaitGroup
+	for i, metric := range metrics {
+		wg.Add(1)
+		go func(i int, metric string) {
+			defer wg.Done()
+			results[i] = r.analyzeCapacityMetric(client, metric, timeRange)
+		}(i, metric)
+	}
+	wg.Wait()
 
-	// Analyze each metric
-	for _, metric := range metrics {
-		switch metric {
-		case "cpu":
-			cpuAnalysis, err := analyzeCPUCapacity(client, timeRange)
-			if err != nil {
-				analysis["cpu"] = map[string]string{"error": err.Error()}
-			} else {
-				analysis["cpu"] = cpuAnalysis
-			}
-		case "memory":
-			memAnalysis, err := analyzeMemoryCapacity(client, timeRange)
-			if err != nil {
-				analysis["memory"] = map[string]string{"error": err.Error()}
-			} else {
-				analysis["memory"] = memAnalysis
-			}
-		case "network":
-			netAnalysis, err := analyzeNetworkCapacity(client, timeRange)
-			if err != nil {
-				analysis["network"] = map[string]string{"error": err.Error()}
-			} else {
-				analysis["network"] = netAnalysis
-			}
-		case "disk":
-			diskAnalysis, err := analyzeDiskCapacity(client, timeRange)
-			if err != nil {
-				analysis["disk"] = map[string]string{"error": err.Error()}
-			} else {
-				analysis["disk"] = diskAnalysis
-			}
+	analysis := make(map[string]interface{})
+	for i, metric := range metrics {
+		if results[i] != nil {
+			analysis[metric] = results[i]
 		}
 	}
 
@@ -3989,7 +8202,32 @@ func handleAnalyzeCapacity(client *truenas.Client, args map[string]interface{})
 	return string(formatted), nil
 }
 
-func analyzeCPUCapacity(client *truenas.Client, timeRange string) (map[string]interface{}, error) {
+// analyzeCapacityMetric returns the analysis of one analyze_capacity metric,
+// an error entry if it failed, or nil for an unknown metric
+func (r *Registry) analyzeCapacityMetric(client truenas.Caller, metric, timeRange string) interface{} {
+	var analyze func(truenas.Caller, string) (map[string]interface{}, error)
+	switch metric {
+	case "cpu":
+		analyze = analyzeCPUCapacity
+	case "memory":
+		analyze = analyzeMemoryCapacity
+	case "network":
+		analyze = analyzeNetworkCapacity
+	case "disk":
+		analyze = analyzeDiskCapacity
+	case "storage":
+		return r.analyzeStorageGrowth()
+	default:
+		return nil
+	}
+	result, err := analyze(client, timeRange)
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	return result
+}
+
+func analyzeCPUCapacity(client truenas.Caller, timeRange string) (map[string]interface{}, error) {
 	// Get CPU metrics for time range
 	result, err := client.Call("reporting.get_data", []interface{}{
 		map[string]interface{}{
@@ -4045,7 +8283,7 @@ func analyzeCPUCapacity(client *truenas.Client, timeRange string) (map[string]in
 	return analysis, nil
 }
 
-func analyzeMemoryCapacity(client *truenas.Client, timeRange string) (map[string]interface{}, error) {
+func analyzeMemoryCapacity(client truenas.Caller, timeRange string) (map[string]interface{}, error) {
 	// Get system info to find total memory
 	sysInfoResult, err := client.Call("system.info")
 	if err != nil {
@@ -4127,7 +8365,7 @@ func analyzeMemoryCapacity(client *truenas.Client, timeRange string) (map[string
 	return analysis, nil
 }
 
-func analyzeNetworkCapacity(client *truenas.Client, timeRange string) (map[string]interface{}, error) {
+func analyzeNetworkCapacity(client truenas.Caller, timeRange string) (map[string]interface{}, error) {
 	// Get all network interfaces
 	ifaceResult, err := client.Call("interface.query")
 	if err != nil {
@@ -4141,6 +8379,10 @@ func analyzeNetworkCapacity(client *truenas.Client, timeRange string) (map[strin
 
 	interfaceAnalysis := make(map[string]interface{})
 
+	// Fetch every interface's metrics at once
+	var names []string
+	var calls []truenas.BatchCall
+	linkSpeeds := map[string]float64{}
 	for _, iface := range ifaceList {
 		ifaceName, ok := iface["name"].(string)
 		if !ok || ifaceName == "" {
@@ -4148,21 +8390,23 @@ func analyzeNetworkCapacity(client *truenas.Client, timeRange string) (map[strin
 		}
 
 		// Get link speed if available
-		var linkSpeed float64
 		if state, ok := iface["state"].(map[string]interface{}); ok {
 			if speed, ok := state["link_speed"].(float64); ok {
-				linkSpeed = speed // In Mbps
+				linkSpeeds[ifaceName] = speed // In Mbps
 			}
 		}
 
-		// Get network metrics for this interface
-		result, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       "interface",
-				"identifier": ifaceName,
-			},
-		}, map[string]interface{}{"unit": timeRange})
+		names = append(names, ifaceName)
+		calls = append(calls, truenas.BatchCall{Method: "reporting.get_data", Params: []interface{}{
+			[]interface{}{map[string]interface{}{"name": "interface", "identifier": ifaceName}},
+			map[string]interface{}{"unit": timeRange},
+		}})
+	}
+	batch := client.CallConcurrently(calls...)
 
+	for i, ifaceName := range names {
+		linkSpeed := linkSpeeds[ifaceName]
+		result, err := batch[i].Result, batch[i].Err
 		if err != nil {
 			interfaceAnalysis[ifaceName] = map[string]string{"error": err.Error()}
 			continue
@@ -4228,7 +8472,7 @@ func analyzeNetworkCapacity(client *truenas.Client, timeRange string) (map[strin
 	return interfaceAnalysis, nil
 }
 
-func analyzeDiskCapacity(client *truenas.Client, timeRange string) (map[string]interface{}, error) {
+func analyzeDiskCapacity(client truenas.Caller, timeRange string) (map[string]interface{}, error) {
 	// Get available disk graphs
 	graphsResult, err := client.Call("reporting.graphs")
 	if err != nil {
@@ -4263,19 +8507,23 @@ func analyzeDiskCapacity(client *truenas.Client, timeRange string) (map[string]i
 
 	diskAnalysis := make(map[string]interface{})
 
-	for _, identifier := range diskIdentifiers {
+	// Fetch every disk's metrics at once
+	calls := make([]truenas.BatchCall, len(diskIdentifiers))
+	for i, identifier := range diskIdentifiers {
+		calls[i] = truenas.BatchCall{Method: "reporting.get_data", Params: []interface{}{
+			[]interface{}{map[string]interface{}{"name": "disk", "identifier": identifier}},
+			map[string]interface{}{"unit": timeRange},
+		}}
+	}
+	batch := client.CallConcurrently(calls...)
+
+	for i, identifier := range diskIdentifiers {
 		diskName := identifier
 		if idx := strings.Index(identifier, " |"); idx != -1 {
 			diskName = identifier[:idx]
 		}
 
-		result, err := client.Call("reporting.get_data", []interface{}{
-			map[string]interface{}{
-				"name":       "disk",
-				"identifier": identifier,
-			},
-		}, map[string]interface{}{"unit": timeRange})
-
+		result, err := batch[i].Result, batch[i].Err
 		if err != nil {
 			diskAnalysis[diskName] = map[string]string{"error": err.Error()}
 			continue
@@ -4321,7 +8569,7 @@ func analyzeDiskCapacity(client *truenas.Client, timeRange string) (map[string]i
 	return diskAnalysis, nil
 }
 
-func handleGetPoolCapacityDetails(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleGetPoolCapacityDetails(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	poolName, _ := args["pool_name"].(string)
 
 	// Get pool information
@@ -4371,12 +8619,23 @@ func handleGetPoolCapacityDetails(client *truenas.Client, args map[string]interf
 			poolAnalysis["capacity_warning"] = determineCapacityStatus(utilPct, 70.0, 85.0)
 		}
 
+		// Attach growth trend from local history when available
+		if r.capacityTracker != nil {
+			if name, ok := pool["name"].(string); ok {
+				if growth := r.capacityTracker.Growth(name); growth != nil {
+					poolAnalysis["growth"] = growth
+				}
+			}
+		}
+
 		analysis = append(analysis, poolAnalysis)
 	}
 
 	result := map[string]interface{}{
 		"pools": analysis,
-		"note":  "Historical capacity trends are not available from TrueNAS API. This shows current snapshot only. For growth trend analysis, query this tool periodically and track results externally.",
+	}
+	if r.capacityTracker == nil || !r.capacityTracker.Enabled() {
+		result["note"] = "Capacity history tracking is disabled. Start the server with --capacity-sample-interval to record pool usage over time and enable growth projections."
 	}
 
 	formatted, err := json.MarshalIndent(result, "", "  ")
@@ -4387,6 +8646,59 @@ func handleGetPoolCapacityDetails(client *truenas.Client, args map[string]interf
 	return string(formatted), nil
 }
 
+// analyzeStorageGrowth summarizes pool growth trends from locally recorded history
+func (r *Registry) analyzeStorageGrowth() map[string]interface{} {
+	if r.capacityTracker == nil || !r.capacityTracker.Enabled() {
+		return map[string]interface{}{
+			"error": "capacity history tracking is disabled (start the server with --capacity-sample-interval)",
+		}
+	}
+
+	pools := make(map[string]interface{})
+	for _, name := range r.capacityTracker.Pools() {
+		history := r.capacityTracker.History(name)
+		if len(history) == 0 {
+			continue
+		}
+		latest := history[len(history)-1]
+
+		poolAnalysis := map[string]interface{}{
+			"current_used_bytes":      latest.UsedBytes,
+			"current_available_bytes": latest.AvailableBytes,
+			"current_used":            units.FormatBytes(latest.UsedBytes),
+			"current_available":       units.FormatBytes(latest.AvailableBytes),
+		}
+		if total := latest.TotalBytes(); total > 0 {
+			utilPct := (float64(latest.UsedBytes) / float64(total)) * 100
+			poolAnalysis["utilization_pct"] = utilPct
+			poolAnalysis["capacity_status"] = determineCapacityStatus(utilPct, 70.0, 85.0)
+		}
+
+		if growth := r.capacityTracker.Growth(name); growth != nil {
+			poolAnalysis["growth"] = growth
+			switch {
+			case growth.DaysUntilFull != nil:
+				poolAnalysis["projection"] = fmt.Sprintf("Pool full in ~%.0f days at current growth rate", *growth.DaysUntilFull)
+			case growth.GrowthBytesPerDay > 0 && latest.AvailableBytes > 0:
+				poolAnalysis["projection"] = "Pool usage is growing too slowly to project (full in more than 100 years)"
+			case growth.GrowthBytesPerDay > 0:
+				poolAnalysis["projection"] = "Pool has no space left"
+			default:
+				poolAnalysis["projection"] = "Pool usage is not growing"
+			}
+		} else {
+			poolAnalysis["projection"] = fmt.Sprintf("Not enough history yet (%d samples)", len(history))
+		}
+
+		pools[name] = poolAnalysis
+	}
+
+	return map[string]interface{}{
+		"pools":           pools,
+		"sample_interval": r.capacityTracker.SampleInterval().String(),
+	}
+}
+
 // Helper functions for capacity analysis
 
 func extractDataPoints(metric map[string]interface{}) ([]float64, error) {
@@ -4602,6 +8914,25 @@ func generateCapacityRecommendations(analysis map[string]interface{}) map[string
 		}
 	}
 
+	// Check pool growth from local history
+	if storageAnalysis, ok := analysis["storage"].(map[string]interface{}); ok {
+		if pools, ok := storageAnalysis["pools"].(map[string]interface{}); ok {
+			for poolName, poolData := range pools {
+				poolInfo, ok := poolData.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if status, ok := poolInfo["capacity_status"].(string); ok {
+					overallStatuses = append(overallStatuses, status)
+				}
+				if growth, ok := poolInfo["growth"].(*capacity.Growth); ok && growth.DaysUntilFull != nil && *growth.DaysUntilFull < 90 {
+					recommendations = append(recommendations,
+						fmt.Sprintf("Pool '%s' is projected to fill in ~%.0f days. Plan to expand the pool or free space.", poolName, *growth.DaysUntilFull))
+				}
+			}
+		}
+	}
+
 	// Determine overall status
 	overallStatus := "healthy"
 	for _, status := range overallStatuses {
@@ -4669,6 +9000,8 @@ func calculatePoolCapacity(pool map[string]interface{}) map[string]interface{} {
 				capacity["utilization_pct"] = utilPct
 				capacity["total_bytes"] = total
 			}
+			capacity["used_human"] = units.FormatBytes(used)
+			capacity["available_human"] = units.FormatBytes(available)
 		}
 	}
 
@@ -4732,7 +9065,7 @@ func analyzeDatasetCapacity(datasets []map[string]interface{}) []map[string]inte
 }
 
 // handleTasksList lists all active and recent tasks
-func (r *Registry) handleTasksList(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleTasksList(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	cursor := ""
 	if c, ok := args["cursor"].(string); ok {
 		cursor = c
@@ -4755,12 +9088,21 @@ func (r *Registry) handleTasksList(client *truenas.Client, args map[string]inter
 		response["next_cursor"] = nextCursor
 	}
 
+	// Tasks recovered after a restart or whose job vanished are flagged in
+	// their "reconciled" field; summarize the last pass so they stand out
+	if report := r.taskManager.LastReconcile(); len(report.Recovered) > 0 || len(report.Vanished) > 0 {
+		response["reconciliation"] = report
+		if len(report.Vanished) > 0 {
+			response["warning"] = fmt.Sprintf("%d task(s) lost their middleware job (e.g. the middleware restarted); their outcome is unknown, so check the affected resources", len(report.Vanished))
+		}
+	}
+
 	formatted, _ := json.MarshalIndent(response, "", "  ")
 	return string(formatted), nil
 }
 
 // handleTasksGet retrieves a specific task by ID
-func (r *Registry) handleTasksGet(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleTasksGet(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	taskID, ok := args["task_id"].(string)
 	if !ok || taskID == "" {
 		return "", fmt.Errorf("task_id is required")
@@ -4771,6 +9113,14 @@ func (r *Registry) handleTasksGet(client *truenas.Client, args map[string]interf
 		return "", fmt.Errorf("failed to get task: %w", err)
 	}
 
+	// Tasks the server runs itself (execute_plan) report their results here
+	if task.OperationType == tasks.OperationTypeLocal && task.Result != nil {
+		return marshalJSON(struct {
+			*tasks.Task
+			Result interface{} `json:"result"`
+		}{task, task.Result})
+	}
+
 	formatted, _ := json.MarshalIndent(task, "", "  ")
 	return string(formatted), nil
 }
@@ -4778,7 +9128,7 @@ func (r *Registry) handleTasksGet(client *truenas.Client, args map[string]interf
 // System Update Handlers
 
 // handleCheckUpdates checks for available TrueNAS system updates
-func handleCheckUpdates(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleCheckUpdates(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	result, err := client.Call("update.available_versions")
 	if err != nil {
 		return "", fmt.Errorf("failed to check for updates: %w", err)
@@ -4798,7 +9148,7 @@ func handleCheckUpdates(client *truenas.Client, args map[string]interface{}) (st
 }
 
 // handleUpdateStatus gets current system update status
-func handleUpdateStatus(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleUpdateStatus(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	result, err := client.Call("update.status")
 	if err != nil {
 		return "", fmt.Errorf("failed to get update status: %w", err)
@@ -4818,7 +9168,7 @@ func handleUpdateStatus(client *truenas.Client, args map[string]interface{}) (st
 }
 
 // handleDownloadUpdate downloads a TrueNAS system update
-func (r *Registry) handleDownloadUpdate(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleDownloadUpdate(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	train, _ := args["train"].(string)
 	version, _ := args["version"].(string)
 
@@ -4868,6 +9218,7 @@ func (r *Registry) handleDownloadUpdate(client *truenas.Client, args map[string]
 		args,
 		jobID,
 		2*time.Hour, // 2 hour TTL
+		client.CorrelationID(),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
@@ -4892,14 +9243,14 @@ func (r *Registry) handleDownloadUpdate(client *truenas.Client, args map[string]
 }
 
 // handleDownloadUpdateWithDryRun wraps the download handler with dry-run support
-func (r *Registry) handleDownloadUpdateWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &downloadUpdateDryRun{}, r.handleDownloadUpdate)
+func (r *Registry) handleDownloadUpdateWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &downloadUpdateDryRun{}, r.handleDownloadUpdate)
 }
 
 // downloadUpdateDryRun implements dry-run preview for update downloads
 type downloadUpdateDryRun struct{}
 
-func (d *downloadUpdateDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (d *downloadUpdateDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	train, _ := args["train"].(string)
 	version, _ := args["version"].(string)
 
@@ -4958,12 +9309,18 @@ func (d *downloadUpdateDryRun) ExecuteDryRun(client *truenas.Client, args map[st
 }
 
 // handleApplyUpdate applies a downloaded TrueNAS system update
-func (r *Registry) handleApplyUpdate(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleApplyUpdate(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	reboot := false
 	if r, ok := args["reboot"].(bool); ok {
 		reboot = r
 	}
 
+	// Refuse to update a system that fails blocking health checks
+	preflight := runUpdatePreflight(client, r.updatePreflight, time.Now())
+	if !preflight.Passed {
+		return "", preflightError(preflight)
+	}
+
 	// Build update options
 	updateOptions := map[string]interface{}{
 		"reboot": reboot,
@@ -4987,6 +9344,7 @@ func (r *Registry) handleApplyUpdate(client *truenas.Client, args map[string]int
 		args,
 		jobID,
 		2*time.Hour, // 2 hour TTL
+		client.CorrelationID(),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
@@ -5004,6 +9362,9 @@ func (r *Registry) handleApplyUpdate(client *truenas.Client, args map[string]int
 	if reboot {
 		response["warning"] = "System will reboot after update completes. Connection will be lost."
 	}
+	if len(preflight.Warnings) > 0 {
+		response["preflight_warnings"] = preflight.Warnings
+	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -5014,14 +9375,17 @@ func (r *Registry) handleApplyUpdate(client *truenas.Client, args map[string]int
 }
 
 // handleApplyUpdateWithDryRun wraps the apply handler with dry-run support
-func (r *Registry) handleApplyUpdateWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &applyUpdateDryRun{}, r.handleApplyUpdate)
+func (r *Registry) handleApplyUpdateWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &applyUpdateDryRun{policy: r.updatePreflight}, r.handleApplyUpdate)
 }
 
-// applyUpdateDryRun implements dry-run preview for update application
-type applyUpdateDryRun struct{}
+// applyUpdateDryRun implements dry-run preview for update application,
+// including the preflight checks the real run enforces
+type applyUpdateDryRun struct {
+	policy UpdatePreflightPolicy
+}
 
-func (a *applyUpdateDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (a *applyUpdateDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	reboot := false
 	if r, ok := args["reboot"].(bool); ok {
 		reboot = r
@@ -5082,11 +9446,14 @@ func (a *applyUpdateDryRun) ExecuteDryRun(client *truenas.Client, args map[strin
 		})
 	}
 
+	preflight := runUpdatePreflight(client, a.policy, time.Now())
+
 	result := &DryRunResult{
 		Tool: "apply_update",
 		CurrentState: map[string]interface{}{
 			"current_version": currentVersion,
 			"update_status":   status,
+			"preflight":       preflight,
 		},
 		PlannedActions: actions,
 		EstimatedTime: &EstimatedTime{
@@ -5111,11 +9478,21 @@ func (a *applyUpdateDryRun) ExecuteDryRun(client *truenas.Client, args map[strin
 		)
 	}
 
+	// Preflight results lead the warnings so they are not missed
+	preflightWarnings := []string{}
+	for _, blocking := range preflight.Blocking {
+		preflightWarnings = append(preflightWarnings, "BLOCKED: apply_update will refuse to run: "+blocking)
+	}
+	for _, warning := range preflight.Warnings {
+		preflightWarnings = append(preflightWarnings, "PREFLIGHT WARNING: "+warning)
+	}
+	result.Warnings = append(preflightWarnings, result.Warnings...)
+
 	return result, nil
 }
 
 // handleSystemReboot reboots the TrueNAS system
-func handleSystemReboot(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleSystemReboot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Call system.reboot with reason parameter
 	reason := "System reboot requested via MCP"
 	result, err := client.Call("system.reboot", reason)
@@ -5145,7 +9522,7 @@ func handleSystemReboot(client *truenas.Client, args map[string]interface{}) (st
 
 // Boot Environment Management Handlers
 
-func handleQueryBootEnvironments(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleQueryBootEnvironments(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Query all boot environments
 	result, err := client.Call("boot.environment.query", []interface{}{})
 	if err != nil {
@@ -5248,7 +9625,7 @@ func handleQueryBootEnvironments(client *truenas.Client, args map[string]interfa
 		"activated_environment": activatedEnv,
 		"storage_summary": map[string]interface{}{
 			"total_size_bytes": totalSizeBytes,
-			"total_size_human": formatBytes(totalSizeBytes),
+			"total_size_human": units.FormatBytes(totalSizeBytes),
 		},
 	}
 
@@ -5264,7 +9641,7 @@ func handleQueryBootEnvironments(client *truenas.Client, args map[string]interfa
 	return string(formatted), nil
 }
 
-func handleDeleteBootEnvironment(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleDeleteBootEnvironment(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	id, ok := args["id"].(string)
 	if !ok || id == "" {
 		return "", fmt.Errorf("id parameter is required")
@@ -5326,7 +9703,7 @@ func handleDeleteBootEnvironment(client *truenas.Client, args map[string]interfa
 	response := map[string]interface{}{
 		"status":      "deleted",
 		"id":          id,
-		"space_freed": formatBytes(sizeBytes),
+		"space_freed": units.FormatBytes(sizeBytes),
 		"space_bytes": sizeBytes,
 		"message":     fmt.Sprintf("Boot environment '%s' deleted successfully", id),
 		"reminder":    "Keep at least 2-3 boot environments for system recovery",
@@ -5340,7 +9717,7 @@ func handleDeleteBootEnvironment(client *truenas.Client, args map[string]interfa
 	return string(formatted), nil
 }
 
-func handleGetCurrentBootEnvironment(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleGetCurrentBootEnvironment(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Query all boot environments
 	result, err := client.Call("boot.environment.query", []interface{}{})
 	if err != nil {
@@ -5418,7 +9795,7 @@ func simplifyBootEnvironment(env map[string]interface{}) map[string]interface{}
 		"created":           created,
 		"created_timestamp": createdTimestamp,
 		"size_bytes":        sizeBytes,
-		"size_human":        formatBytes(sizeBytes),
+		"size_human":        units.FormatBytes(sizeBytes),
 		"active":            active,
 		"activated":         activated,
 		"protected":         keep,
@@ -5456,27 +9833,11 @@ func sortBootEnvironments(envs []map[string]interface{}, orderBy string) {
 	})
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-
-	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
-	return fmt.Sprintf("%.2f %s", float64(bytes)/float64(div), units[exp])
-}
-
 // Dry-run handler for delete boot environment
 
 type deleteBootEnvironmentDryRun struct{}
 
-func (d *deleteBootEnvironmentDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (d *deleteBootEnvironmentDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	id, ok := args["id"].(string)
 	if !ok || id == "" {
 		return nil, fmt.Errorf("id parameter is required")
@@ -5532,7 +9893,7 @@ func (d *deleteBootEnvironmentDryRun) ExecuteDryRun(client *truenas.Client, args
 		}
 	} else {
 		warnings = append(warnings, "PERMANENT: This operation cannot be undone")
-		warnings = append(warnings, fmt.Sprintf("SPACE: Will free approximately %s", formatBytes(sizeBytes)))
+		warnings = append(warnings, fmt.Sprintf("SPACE: Will free approximately %s", units.FormatBytes(sizeBytes)))
 		warnings = append(warnings, "RECOMMENDATION: Keep at least 2-3 boot environments for system recovery")
 	}
 
@@ -5545,7 +9906,7 @@ func (d *deleteBootEnvironmentDryRun) ExecuteDryRun(client *truenas.Client, args
 			Operation:   "delete",
 			Target:      id,
 			Details: map[string]interface{}{
-				"size_to_free": formatBytes(sizeBytes),
+				"size_to_free": units.FormatBytes(sizeBytes),
 			},
 		})
 	}
@@ -5561,6 +9922,6 @@ func (d *deleteBootEnvironmentDryRun) ExecuteDryRun(client *truenas.Client, args
 	}, nil
 }
 
-func (r *Registry) handleDeleteBootEnvironmentWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &deleteBootEnvironmentDryRun{}, handleDeleteBootEnvironment)
+func (r *Registry) handleDeleteBootEnvironmentWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteBootEnvironmentDryRun{}, handleDeleteBootEnvironment)
 }
@@ -0,0 +1,680 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Replication task management (replication.*)
+
+var (
+	replicationDirections = map[string]bool{"PUSH": true, "PULL": true}
+	replicationTransports = map[string]bool{"SSH": true, "SSH+NETCAT": true, "LOCAL": true}
+	replicationRetention  = map[string]bool{"SOURCE": true, "CUSTOM": true, "NONE": true}
+)
+
+// middlewareTime converts a middleware {"$date": ms} value
+func middlewareTime(v interface{}) (time.Time, bool) {
+	date, ok := v.(map[string]interface{})
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, ok := date["$date"].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(ms)), true
+}
+
+// queryReplicationTasks returns replication.query results
+func queryReplicationTasks(client truenas.Caller, filters []interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call("replication.query", filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication tasks: %w", err)
+	}
+
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse replication tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// getReplicationTask returns a replication task by ID
+func getReplicationTask(client truenas.Caller, id int) (map[string]interface{}, error) {
+	tasks, err := queryReplicationTasks(client, []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, newToolError(ErrorNotFound, "replication task with id %d not found", id)
+	}
+	return tasks[0], nil
+}
+
+// replicationSchedule describes when a task runs
+func replicationSchedule(task map[string]interface{}) string {
+	if auto, _ := task["auto"].(bool); !auto {
+		return "Manual only (run_replication)"
+	}
+	if schedule, ok := task["schedule"].(map[string]interface{}); ok {
+		human := formatCronSchedule(schedule)
+		if window := snapshotTaskWindow(schedule); window != "" {
+			human += " " + window
+		}
+		return human
+	}
+	return "After each run of its periodic snapshot tasks"
+}
+
+// replicationRetentionHuman describes how long replicated snapshots are kept
+// on the target
+func replicationRetentionHuman(task map[string]interface{}) string {
+	switch policy, _ := task["retention_policy"].(string); policy {
+	case "SOURCE":
+		return "Same as the source: snapshots are removed from the target when removed from the source"
+	case "CUSTOM":
+		value, _ := task["lifetime_value"].(float64)
+		unit, _ := task["lifetime_unit"].(string)
+		return "Keep on the target for " + formatRetention(int(value), unit)
+	case "NONE":
+		return "Never removed from the target"
+	default:
+		return policy
+	}
+}
+
+// replicationLastRun summarizes a task's state from its last run
+func replicationLastRun(task map[string]interface{}) map[string]interface{} {
+	state, _ := task["state"].(map[string]interface{})
+	status, _ := state["state"].(string)
+	if status == "" {
+		status = "NEVER_RUN"
+	}
+
+	lastRun := map[string]interface{}{"status": status}
+	if at, ok := middlewareTime(state["datetime"]); ok {
+		lastRun["time"] = at.Format(time.RFC3339)
+	}
+	if snapshot, ok := state["last_snapshot"].(string); ok && snapshot != "" {
+		lastRun["last_snapshot"] = snapshot
+	}
+	if msg, ok := state["error"].(string); ok && msg != "" {
+		lastRun["error"] = msg
+	}
+	if job, ok := task["job"].(map[string]interface{}); ok && status == "RUNNING" {
+		lastRun["job_id"] = job["id"]
+		if progress, ok := job["progress"].(map[string]interface{}); ok {
+			lastRun["progress"] = progress["percent"]
+			lastRun["progress_description"] = progress["description"]
+		}
+	}
+	return lastRun
+}
+
+// replicationCredentialsName returns the SSH connection a task uses
+func replicationCredentialsName(task map[string]interface{}) interface{} {
+	if creds, ok := task["ssh_credentials"].(map[string]interface{}); ok {
+		return creds["name"]
+	}
+	return task["ssh_credentials"]
+}
+
+func simplifyReplicationTask(task map[string]interface{}) map[string]interface{} {
+	periodicTasks := []interface{}{}
+	if list, ok := task["periodic_snapshot_tasks"].([]interface{}); ok {
+		for _, item := range list {
+			if pt, ok := item.(map[string]interface{}); ok {
+				periodicTasks = append(periodicTasks, pt["id"])
+			} else {
+				periodicTasks = append(periodicTasks, item)
+			}
+		}
+	}
+
+	simplified := map[string]interface{}{
+		"id":                      task["id"],
+		"name":                    task["name"],
+		"enabled":                 task["enabled"],
+		"direction":               task["direction"],
+		"transport":               task["transport"],
+		"source_datasets":         task["source_datasets"],
+		"target_dataset":          task["target_dataset"],
+		"recursive":               task["recursive"],
+		"periodic_snapshot_tasks": periodicTasks,
+		"schedule_human":          replicationSchedule(task),
+		"retention":               replicationRetentionHuman(task),
+		"last_run":                replicationLastRun(task),
+	}
+	if transport, _ := task["transport"].(string); transport != "LOCAL" {
+		simplified["ssh_connection"] = replicationCredentialsName(task)
+	}
+	if schemas, ok := task["naming_schema"].([]interface{}); ok && len(schemas) > 0 {
+		simplified["naming_schema"] = schemas
+	}
+	return simplified
+}
+
+func handleQueryReplicationTasks(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	tasks, err := queryReplicationTasks(client, []interface{}{})
+	if err != nil {
+		return "", err
+	}
+
+	datasetFilter, _ := args["dataset"].(string)
+	enabledOnly, _ := args["enabled_only"].(bool)
+
+	filtered := []map[string]interface{}{}
+	for _, task := range tasks {
+		if enabled, _ := task["enabled"].(bool); enabledOnly && !enabled {
+			continue
+		}
+		if datasetFilter != "" && !replicationInvolves(task, datasetFilter) {
+			continue
+		}
+		filtered = append(filtered, simplifyReplicationTask(task))
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"replication_tasks": filtered,
+		"count":             len(filtered),
+	})
+}
+
+// replicationInvolves reports whether a task replicates from or to dataset
+// or one of its children
+func replicationInvolves(task map[string]interface{}, dataset string) bool {
+	within := func(name string) bool {
+		return name == dataset || strings.HasPrefix(name, dataset+"/")
+	}
+	if target, _ := task["target_dataset"].(string); within(target) {
+		return true
+	}
+	sources, _ := task["source_datasets"].([]interface{})
+	for _, source := range sources {
+		if name, _ := source.(string); within(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleGetReplicationStatus(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filters := []interface{}{}
+	if id, ok := args["id"].(float64); ok {
+		filters = append(filters, []interface{}{"id", "=", int(id)})
+	}
+	tasks, err := queryReplicationTasks(client, filters)
+	if err != nil {
+		return "", err
+	}
+	if len(tasks) == 0 && len(filters) > 0 {
+		return "", newToolError(ErrorNotFound, "replication task with id %v not found", args["id"])
+	}
+
+	statuses := []map[string]interface{}{}
+	counts := map[string]int{}
+	problems := []string{}
+	for _, task := range tasks {
+		lastRun := replicationLastRun(task)
+		status := lastRun["status"].(string)
+		counts[status]++
+
+		statuses = append(statuses, map[string]interface{}{
+			"id":       task["id"],
+			"name":     task["name"],
+			"enabled":  task["enabled"],
+			"schedule": replicationSchedule(task),
+			"last_run": lastRun,
+		})
+
+		switch status {
+		case "ERROR":
+			problems = append(problems, fmt.Sprintf("%v failed: %v", task["name"], lastRun["error"]))
+		case "NEVER_RUN":
+			if enabled, _ := task["enabled"].(bool); enabled {
+				problems = append(problems, fmt.Sprintf("%v has never run", task["name"]))
+			}
+		}
+	}
+
+	response := map[string]interface{}{
+		"tasks":     statuses,
+		"count":     len(statuses),
+		"by_status": counts,
+		"healthy":   len(problems) == 0,
+	}
+	if len(problems) > 0 {
+		response["problems"] = problems
+	}
+	return marshalJSON(response)
+}
+
+// stringList reads a JSON array of strings
+func stringList(v interface{}) []string {
+	items, _ := v.([]interface{})
+	list := []string{}
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// replicationTaskCreate builds the replication.create payload from args.
+// Push tasks without periodic_snapshot_tasks or naming_schema use the
+// periodic snapshot tasks covering their source datasets.
+func replicationTaskCreate(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	sources := stringList(args["source_datasets"])
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("source_datasets is required")
+	}
+	target, _ := args["target_dataset"].(string)
+	if target == "" {
+		return nil, fmt.Errorf("target_dataset is required")
+	}
+
+	direction := "PUSH"
+	if d, ok := args["direction"].(string); ok && d != "" {
+		direction = strings.ToUpper(d)
+		if !replicationDirections[direction] {
+			return nil, newToolError(ErrorValidation, "direction must be PUSH or PULL")
+		}
+	}
+	transport := "SSH"
+	if t, ok := args["transport"].(string); ok && t != "" {
+		transport = strings.ToUpper(t)
+		if !replicationTransports[transport] {
+			return nil, newToolError(ErrorValidation, "transport must be SSH, SSH+NETCAT, or LOCAL")
+		}
+	}
+	retention := "SOURCE"
+	if p, ok := args["retention_policy"].(string); ok && p != "" {
+		retention = strings.ToUpper(p)
+		if !replicationRetention[retention] {
+			return nil, newToolError(ErrorValidation, "retention_policy must be SOURCE, CUSTOM, or NONE")
+		}
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	create := map[string]interface{}{
+		"name":             name,
+		"direction":        direction,
+		"transport":        transport,
+		"source_datasets":  sources,
+		"target_dataset":   target,
+		"recursive":        recursive,
+		"retention_policy": retention,
+		"enabled":          true,
+	}
+	if enabled, ok := args["enabled"].(bool); ok {
+		create["enabled"] = enabled
+	}
+
+	if transport == "LOCAL" {
+		if direction == "PULL" {
+			return nil, newToolError(ErrorValidation, "local replication is always PUSH")
+		}
+		for _, source := range sources {
+			if target == source || strings.HasPrefix(target, source+"/") {
+				return nil, newToolError(ErrorValidation, "target_dataset %s is inside source %s", target, source)
+			}
+		}
+	} else {
+		creds, ok := args["ssh_credentials"].(float64)
+		if !ok {
+			return nil, newToolError(ErrorValidation, "ssh_credentials (SSH connection id) is required for %s transport", transport)
+		}
+		create["ssh_credentials"] = int(creds)
+	}
+
+	if retention == "CUSTOM" {
+		value, ok := args["lifetime_value"].(float64)
+		unit, _ := args["lifetime_unit"].(string)
+		unit = strings.ToUpper(unit)
+		if _, known := snapshotLifetimeUnits[unit]; !ok || value < 1 || !known {
+			return nil, newToolError(ErrorValidation, "retention_policy CUSTOM requires lifetime_value and lifetime_unit (HOUR, DAY, WEEK, MONTH, or YEAR)")
+		}
+		create["lifetime_value"] = float64(int(value))
+		create["lifetime_unit"] = unit
+	}
+
+	schemas := stringList(args["naming_schema"])
+	for _, schema := range schemas {
+		if err := validateNamingSchema(schema); err != nil {
+			return nil, err
+		}
+	}
+	periodic := []int{}
+	if ids, ok := args["periodic_snapshot_tasks"].([]interface{}); ok {
+		for _, id := range ids {
+			if n, ok := id.(float64); ok {
+				periodic = append(periodic, int(n))
+			}
+		}
+	}
+
+	if direction == "PULL" {
+		if len(periodic) > 0 {
+			return nil, newToolError(ErrorValidation, "pull replication selects snapshots by naming_schema, not periodic_snapshot_tasks")
+		}
+		if len(schemas) == 0 {
+			return nil, newToolError(ErrorValidation, "pull replication requires naming_schema matching the remote snapshots")
+		}
+		create["naming_schema"] = schemas
+	} else {
+		if len(periodic) == 0 && len(schemas) == 0 {
+			found, err := periodicTasksForDatasets(client, sources)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, newToolError(ErrorPrecondition, "no periodic snapshot task covers %s; create one with create_snapshot_task or give naming_schema", strings.Join(sources, ", "))
+			}
+			periodic = found
+		}
+		create["periodic_snapshot_tasks"] = periodic
+		if len(schemas) > 0 {
+			create["also_include_naming_schema"] = schemas
+		}
+	}
+
+	// Runs after its snapshot tasks unless given a schedule; pull tasks and
+	// tasks without snapshot tasks need one to run automatically
+	if scheduleArg, ok := args["schedule"].(map[string]interface{}); ok {
+		create["schedule"] = snapshotTaskSchedule(map[string]interface{}{
+			"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*", "begin": "00:00", "end": "23:59",
+		}, scheduleArg)
+		create["auto"] = true
+	} else {
+		create["auto"] = len(periodic) > 0
+	}
+
+	return create, nil
+}
+
+// periodicTasksForDatasets returns the IDs of periodic snapshot tasks that
+// snapshot each of the datasets
+func periodicTasksForDatasets(client truenas.Caller, datasets []string) ([]int, error) {
+	result, err := client.Call("pool.snapshottask.query", []interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot tasks: %w", err)
+	}
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot tasks: %w", err)
+	}
+
+	ids := []int{}
+	for _, dataset := range datasets {
+		covered := false
+		for _, task := range tasks {
+			taskDataset, _ := task["dataset"].(string)
+			recursive, _ := task["recursive"].(bool)
+			if taskDataset == dataset || (recursive && strings.HasPrefix(dataset, taskDataset+"/")) {
+				id, _ := task["id"].(float64)
+				ids = append(ids, int(id))
+				covered = true
+			}
+		}
+		if !covered {
+			return []int{}, nil
+		}
+	}
+	return ids, nil
+}
+
+func handleCreateReplicationTask(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := replicationTaskCreate(client, args)
+	if err != nil {
+		return "", err
+	}
+	if skip, _ := args["skip_space_check"].(bool); !skip {
+		if _, err := replicationSpacePreflight(client, create, true); err != nil {
+			return "", err
+		}
+	}
+
+	result, err := client.Call("replication.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create replication task: %w", err)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	task := simplifyReplicationTask(created)
+	return marshalJSON(map[string]interface{}{
+		"created": true,
+		"task":    task,
+		"message": fmt.Sprintf("Replication task '%v' created (%v). Start the first run now with run_replication id %v; it sends a full copy and can take a long time.", created["name"], task["schedule_human"], created["id"]),
+	})
+}
+
+func (r *Registry) handleRunReplication(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	task, err := getReplicationTask(client, id)
+	if err != nil {
+		return "", err
+	}
+	if err := checkReplicationRunnable(task); err != nil {
+		return "", err
+	}
+	if skip, _ := args["skip_space_check"].(bool); !skip {
+		if _, err := replicationSpacePreflight(client, task, replicationNeedsFullSend(task)); err != nil {
+			return "", err
+		}
+	}
+
+	result, err := client.Call("replication.run", id)
+	if err != nil {
+		return "", fmt.Errorf("failed to start replication: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+
+	tracked, err := r.taskManager.CreateJobTask("run_replication", args, jobID, 24*time.Hour, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"replication":   task["name"],
+		"job_id":        jobID,
+		"task_id":       tracked.TaskID,
+		"task_status":   tracked.Status,
+		"poll_interval": tracked.PollInterval,
+		"message":       fmt.Sprintf("Replication '%v' started. Track progress with tasks_get using task_id: %s, or get_replication_status", task["name"], tracked.TaskID),
+	})
+}
+
+// checkReplicationRunnable refuses to start a task that is disabled or
+// already running
+func checkReplicationRunnable(task map[string]interface{}) error {
+	if enabled, _ := task["enabled"].(bool); !enabled {
+		return newToolError(ErrorPrecondition, "replication task '%v' is disabled; enable it before running", task["name"])
+	}
+	if state, _ := task["state"].(map[string]interface{}); state["state"] == "RUNNING" {
+		return newToolError(ErrorInProgress, "replication task '%v' is already running", task["name"])
+	}
+	return nil
+}
+
+// Dry-run wrappers
+
+func handleCreateReplicationTaskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createReplicationTaskDryRun{}, handleCreateReplicationTask)
+}
+
+func (r *Registry) handleRunReplicationWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &runReplicationDryRun{}, r.handleRunReplication)
+}
+
+// Dry-run implementations
+
+type createReplicationTaskDryRun struct{}
+
+func (c *createReplicationTaskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := replicationTaskCreate(client, args)
+	if err != nil {
+		return nil, err
+	}
+	direction := create["direction"].(string)
+	transport := create["transport"].(string)
+	target := create["target_dataset"].(string)
+
+	current := map[string]interface{}{}
+	warnings := []string{}
+
+	clock := getNASClock(client)
+
+	// Push sources and local targets are on this system and can be checked
+	if direction == "PUSH" {
+		for _, source := range create["source_datasets"].([]string) {
+			exists, err := datasetExists(client, source)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, newToolError(ErrorNotFound, "source dataset %s not found", source)
+			}
+		}
+		if periodic, ok := create["periodic_snapshot_tasks"].([]int); ok && len(periodic) > 0 {
+			snapshotTasks := []map[string]interface{}{}
+			for _, id := range periodic {
+				task, err := getSnapshotTask(client, id)
+				if err != nil {
+					return nil, err
+				}
+				snapshotTasks = append(snapshotTasks, simplifySnapshotTask(task, clock))
+			}
+			current["periodic_snapshot_tasks"] = snapshotTasks
+		}
+	}
+	if skip, _ := args["skip_space_check"].(bool); !skip {
+		space, err := replicationSpacePreflight(client, create, true)
+		if err != nil {
+			return nil, err
+		}
+		current["space_check"] = space
+		if space["target_exists"] == true && (transport == "LOCAL" || direction == "PULL") {
+			warnings = append(warnings, fmt.Sprintf("Target %s already exists; replication fails unless it holds a common snapshot with the source", target))
+		}
+		if note, ok := space["note"].(string); ok {
+			warnings = append(warnings, note)
+		}
+	} else {
+		warnings = append(warnings, "The free-space preflight is skipped; a full send that does not fit fails part way")
+	}
+
+	switch create["retention_policy"] {
+	case "NONE":
+		warnings = append(warnings, "retention_policy NONE: replicated snapshots accumulate on the target until removed by hand")
+	case "SOURCE":
+		warnings = append(warnings, "retention_policy SOURCE: snapshots deleted on the source are also deleted from the target at the next run")
+	}
+	if auto, _ := create["auto"].(bool); !auto {
+		warnings = append(warnings, "The task has no schedule or periodic snapshot tasks and only runs when started with run_replication")
+	}
+	warnings = append(warnings, "The first run sends a full copy of the source datasets")
+
+	preview := simplifyReplicationTask(create)
+	delete(preview, "id")
+	delete(preview, "last_run")
+	if periodic, ok := create["periodic_snapshot_tasks"]; ok {
+		preview["periodic_snapshot_tasks"] = periodic
+	}
+
+	return &DryRunResult{
+		Tool:         "create_replication_task",
+		CurrentState: current,
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create %s replication '%s' from %s to %s", strings.ToLower(direction), create["name"], strings.Join(create["source_datasets"].([]string), ", "), target),
+				Operation:   "create",
+				Target:      target,
+				Details:     preview,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type runReplicationDryRun struct{}
+
+func (d *runReplicationDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	task, err := getReplicationTask(client, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReplicationRunnable(task); err != nil {
+		return nil, err
+	}
+
+	simplified := simplifyReplicationTask(task)
+	current := map[string]interface{}{
+		"task": simplified,
+	}
+	warnings := []string{}
+	if skip, _ := args["skip_space_check"].(bool); !skip {
+		space, err := replicationSpacePreflight(client, task, replicationNeedsFullSend(task))
+		if err != nil {
+			return nil, err
+		}
+		current["space_check"] = space
+		if note, ok := space["note"].(string); ok {
+			warnings = append(warnings, note)
+		}
+	}
+	lastRun := simplified["last_run"].(map[string]interface{})
+	if lastRun["status"] == "NEVER_RUN" {
+		warnings = append(warnings, "This is the first run: it sends a full copy and can take hours on large datasets")
+	} else {
+		warnings = append(warnings, "Only snapshots newer than the target's latest common snapshot are sent")
+	}
+	if task["retention_policy"] == "SOURCE" {
+		warnings = append(warnings, "Snapshots already deleted on the source are deleted from the target")
+	}
+
+	return &DryRunResult{
+		Tool:         "run_replication",
+		CurrentState: current,
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Run replication '%v' now", task["name"]),
+				Operation:   "run",
+				Target:      fmt.Sprintf("%v", task["target_dataset"]),
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
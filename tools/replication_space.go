@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Replication target free-space preflight
+
+// findDatasetOrAncestor returns the dataset, or its nearest existing
+// ancestor when it does not exist yet, or nil when neither does
+func findDatasetOrAncestor(client truenas.Caller, name string) (map[string]interface{}, error) {
+	for current := name; current != ""; {
+		result, err := client.Call("pool.dataset.query",
+			[]interface{}{
+				[]interface{}{"id", "=", current},
+			},
+			map[string]interface{}{"extra": map[string]interface{}{"retrieve_children": false}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query dataset %s: %w", current, err)
+		}
+
+		var datasets []map[string]interface{}
+		if err := json.Unmarshal(result, &datasets); err != nil {
+			return nil, fmt.Errorf("failed to parse dataset query: %w", err)
+		}
+		if len(datasets) > 0 {
+			return datasets[0], nil
+		}
+
+		i := strings.LastIndex(current, "/")
+		if i < 0 {
+			break
+		}
+		current = current[:i]
+	}
+	return nil, nil
+}
+
+// replicationSendEstimate estimates the bytes a full send of the sources
+// transfers: their data and snapshots, with children for recursive tasks
+func replicationSendEstimate(client truenas.Caller, sources []string, recursive bool) (int64, error) {
+	var total int64
+	for _, source := range sources {
+		ds, err := findDatasetOrAncestor(client, source)
+		if err != nil {
+			return 0, err
+		}
+		if ds == nil || ds["name"] != source {
+			return 0, newToolError(ErrorNotFound, "source dataset %s not found", source)
+		}
+		used := datasetParsedBytes(ds, "used")
+		if !recursive {
+			used -= datasetParsedBytes(ds, "usedbychildren")
+		}
+		total += used
+	}
+	return total, nil
+}
+
+// remoteDatasetNames lists the datasets on the remote side of an SSH
+// connection
+func remoteDatasetNames(client truenas.Caller, transport string, credentials interface{}) (map[string]bool, error) {
+	result, err := client.Call("replication.list_datasets", transport, credentials)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal(result, &names); err != nil {
+		return nil, fmt.Errorf("failed to parse remote datasets: %w", err)
+	}
+	datasets := map[string]bool{}
+	for _, name := range names {
+		datasets[name] = true
+	}
+	return datasets, nil
+}
+
+// replicationSpacePreflight compares the estimated send size of a task with
+// the free space at its target and fails with PRECONDITION when it does not
+// fit. Only full sends (fullSend) are sized; incremental sends are usually a
+// small fraction of the source. The middleware cannot report free space on a
+// remote system, so push over SSH only checks that the target is reachable.
+func replicationSpacePreflight(client truenas.Caller, task map[string]interface{}, fullSend bool) (map[string]interface{}, error) {
+	direction, _ := task["direction"].(string)
+	transport, _ := task["transport"].(string)
+	target, _ := task["target_dataset"].(string)
+	recursive, _ := task["recursive"].(bool)
+	sources, ok := task["source_datasets"].([]string)
+	if !ok {
+		sources = stringList(task["source_datasets"])
+	}
+
+	check := map[string]interface{}{"target": target}
+
+	// Pull sources are remote, so their size is unknown
+	var estimate int64
+	if direction != "PULL" && fullSend {
+		var err error
+		if estimate, err = replicationSendEstimate(client, sources, recursive); err != nil {
+			return nil, err
+		}
+		check["estimated_send"] = units.FormatBytes(estimate)
+	}
+
+	if transport != "LOCAL" && direction != "PULL" {
+		credentials := task["ssh_credentials"]
+		if creds, ok := credentials.(map[string]interface{}); ok {
+			credentials = creds["id"]
+		}
+		remote, err := remoteDatasetNames(client, transport, credentials)
+		if err != nil {
+			return nil, newToolError(ErrorPrecondition, "cannot list datasets on the remote system through SSH connection %v: %v", credentials, err)
+		}
+		parent := target
+		if i := strings.LastIndex(target, "/"); i >= 0 {
+			parent = target[:i]
+		}
+		if !remote[target] && !remote[parent] {
+			return nil, newToolError(ErrorPrecondition, "neither target %s nor its parent %s exists on the remote system", target, parent)
+		}
+		check["target_exists"] = remote[target]
+		check["space_checked"] = false
+		check["note"] = "Free space on the remote system cannot be read through the middleware; check it there before a full send"
+		return check, nil
+	}
+
+	ds, err := findDatasetOrAncestor(client, target)
+	if err != nil {
+		return nil, err
+	}
+	if ds == nil {
+		return nil, newToolError(ErrorPrecondition, "target %s is not on an existing pool", target)
+	}
+	available := datasetParsedBytes(ds, "available")
+	check["target_exists"] = ds["name"] == target
+	check["checked_dataset"] = ds["name"]
+	check["target_available"] = units.FormatBytes(available)
+	check["space_checked"] = estimate > 0
+
+	if estimate > available {
+		return nil, newToolError(ErrorPrecondition, "not enough space at %s: the full send is estimated at %s but only %s is available on %v; free space or choose another target",
+			target, units.FormatBytes(estimate), units.FormatBytes(available), ds["name"])
+	}
+	switch {
+	case !fullSend:
+		check["note"] = "Incremental send; only the snapshots since the last run are sent, so the size is not estimated"
+	case direction == "PULL":
+		check["note"] = "The sources of a pull are on the remote system, so the send size is not estimated"
+	}
+	return check, nil
+}
+
+// replicationNeedsFullSend reports whether the next run of a task sends a
+// full copy: it has never completed a run
+func replicationNeedsFullSend(task map[string]interface{}) bool {
+	state, _ := task["state"].(map[string]interface{})
+	snapshot, _ := state["last_snapshot"].(string)
+	return snapshot == ""
+}
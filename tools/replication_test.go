@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationReplication(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank/data", "name": "tank/data", "used": map[string]interface{}{"parsed": float64(10 << 30)}},
+		{"id": "backup", "name": "backup", "available": map[string]interface{}{"parsed": float64(100 << 30)}},
+	})
+	server.SetResult("replication.list_datasets", []string{"remote", "remote/data"})
+	server.SetRecords("pool.snapshottask.query", []map[string]interface{}{
+		{"id": float64(1), "dataset": "tank", "recursive": true, "lifetime_value": float64(2), "lifetime_unit": "WEEK",
+			"schedule": map[string]interface{}{"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*"}},
+		{"id": float64(2), "dataset": "backup", "recursive": true},
+	})
+	server.SetRecords("replication.query", []map[string]interface{}{
+		{
+			"id": float64(7), "name": "offsite", "enabled": true, "direction": "PUSH", "transport": "SSH",
+			"ssh_credentials":         map[string]interface{}{"id": float64(3), "name": "backup-host"},
+			"source_datasets":         []interface{}{"tank/data"},
+			"target_dataset":          "remote/data",
+			"periodic_snapshot_tasks": []interface{}{map[string]interface{}{"id": float64(1)}},
+			"auto":                    true,
+			"retention_policy":        "CUSTOM", "lifetime_value": float64(3), "lifetime_unit": "MONTH",
+			"state": map[string]interface{}{"state": "ERROR", "error": "connection refused", "datetime": map[string]interface{}{"$date": float64(1700000000000)}},
+		},
+		{"id": float64(8), "name": "disabled", "enabled": false, "direction": "PULL", "transport": "SSH", "target_dataset": "tank/pulled", "auto": false},
+	})
+	server.HandleJob("replication.run", truenastest.JobSpec{Steps: 1})
+	server.Handle("replication.create", func(params []interface{}) (interface{}, error) {
+		created, _ := params[0].(map[string]interface{})
+		created["id"] = float64(9)
+		return created, nil
+	})
+
+	result, err := registry.CallTool("query_replication_tasks", map[string]interface{}{"dataset": "tank"})
+	if err != nil {
+		t.Fatalf("query_replication_tasks failed: %v", err)
+	}
+	tasks := decodeResult(t, result)["replication_tasks"].([]interface{})
+	if len(tasks) != 2 {
+		t.Fatalf("replication_tasks = %v, want both tasks touching tank", tasks)
+	}
+	offsite := tasks[0].(map[string]interface{})
+	if offsite["ssh_connection"] != "backup-host" || offsite["retention"] != "Keep on the target for 3 months" ||
+		offsite["schedule_human"] != "After each run of its periodic snapshot tasks" {
+		t.Errorf("offsite = %v", offsite)
+	}
+
+	result, err = registry.CallTool("get_replication_status", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_replication_status failed: %v", err)
+	}
+	status := decodeResult(t, result)
+	if status["healthy"] != false || !strings.Contains(fmt.Sprint(status["problems"]), "offsite failed: connection refused") {
+		t.Errorf("status = %v", status)
+	}
+	if strings.Contains(fmt.Sprint(status["problems"]), "disabled") {
+		t.Errorf("disabled task that never ran reported as a problem: %v", status["problems"])
+	}
+
+	// Push tasks pick up the periodic snapshot tasks covering their sources
+	create := map[string]interface{}{
+		"name":            "local-copy",
+		"source_datasets": []interface{}{"tank/data"},
+		"target_dataset":  "backup/data",
+		"transport":       "local",
+		"dry_run":         true,
+	}
+	result, err = registry.CallTool("create_replication_task", create)
+	if err != nil {
+		t.Fatalf("create_replication_task dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "snapshots deleted on the source are also deleted") || len(server.Calls("replication.create")) != 0 {
+		t.Errorf("create dry run = %s", result)
+	}
+	delete(create, "dry_run")
+	if _, err := registry.CallTool("create_replication_task", create); err != nil {
+		t.Fatalf("create_replication_task failed: %v", err)
+	}
+	payload := server.Calls("replication.create")[0].Params[0].(map[string]interface{})
+	if payload["transport"] != "LOCAL" || payload["auto"] != true || fmt.Sprint(payload["periodic_snapshot_tasks"]) != "[1]" {
+		t.Errorf("replication.create payload = %v", payload)
+	}
+	if _, ok := payload["ssh_credentials"]; ok {
+		t.Errorf("local replication sent ssh_credentials: %v", payload)
+	}
+
+	_, err = registry.CallTool("create_replication_task", map[string]interface{}{
+		"name": "pull", "direction": "PULL", "ssh_credentials": float64(3),
+		"source_datasets": []interface{}{"remote/data"}, "target_dataset": "tank/pulled",
+	})
+	if ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("pull without naming_schema error = %v, want VALIDATION", err)
+	}
+
+	// Disabled tasks are not run
+	if _, err := registry.CallTool("run_replication", map[string]interface{}{"id": float64(8)}); ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("run of disabled task error = %v, want PRECONDITION_FAILED", err)
+	}
+	result, err = registry.CallTool("run_replication", map[string]interface{}{"id": float64(7)})
+	if err != nil {
+		t.Fatalf("run_replication failed: %v", err)
+	}
+	if decodeResult(t, result)["task_id"] == nil {
+		t.Errorf("run_replication returned no task_id:\n%s", result)
+	}
+	if calls := server.Calls("replication.run"); len(calls) != 1 || calls[0].Params[0] != float64(7) {
+		t.Errorf("replication.run calls = %v", calls)
+	}
+}
+
+func TestIntegrationReplicationSpacePreflight(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank/media", "name": "tank/media", "used": map[string]interface{}{"parsed": float64(500 << 30)},
+			"usedbychildren": map[string]interface{}{"parsed": float64(400 << 30)}},
+		{"id": "backup", "name": "backup", "available": map[string]interface{}{"parsed": float64(200 << 30)}},
+	})
+	server.SetRecords("replication.query", []map[string]interface{}{
+		{"id": float64(1), "name": "media-recursive", "enabled": true, "direction": "PUSH", "transport": "LOCAL",
+			"source_datasets": []interface{}{"tank/media"}, "target_dataset": "backup/media", "recursive": true},
+		{"id": float64(2), "name": "media-incremental", "enabled": true, "direction": "PUSH", "transport": "LOCAL",
+			"source_datasets": []interface{}{"tank/media"}, "target_dataset": "backup/media", "recursive": true,
+			"state": map[string]interface{}{"state": "FINISHED", "last_snapshot": "tank/media@auto-2026-10-01_00-00"}},
+		{"id": float64(3), "name": "offsite", "enabled": true, "direction": "PUSH", "transport": "SSH",
+			"ssh_credentials": map[string]interface{}{"id": float64(4), "name": "backup-host"},
+			"source_datasets": []interface{}{"tank/media"}, "target_dataset": "remote/media"},
+	})
+	server.HandleJob("replication.run", truenastest.JobSpec{Steps: 1})
+
+	// 500 GiB recursive send does not fit in 200 GiB
+	_, err := registry.CallTool("run_replication", map[string]interface{}{"id": float64(1)})
+	if ClassifyError(err).Code != ErrorPrecondition || !strings.Contains(err.Error(), "500.00 GiB") {
+		t.Errorf("full send error = %v, want PRECONDITION_FAILED with the estimate", err)
+	}
+	if len(server.Calls("replication.run")) != 0 {
+		t.Fatalf("replication.run called despite failed preflight")
+	}
+
+	// Without children only 100 GiB is sent
+	result, err := registry.CallTool("create_replication_task", map[string]interface{}{
+		"name": "media", "source_datasets": []interface{}{"tank/media"}, "target_dataset": "backup/media",
+		"transport": "LOCAL", "naming_schema": []interface{}{"auto-%Y-%m-%d_%H-%M"}, "dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("create_replication_task dry run failed: %v", err)
+	}
+	space := decodeResult(t, result)["current_state"].(map[string]interface{})["space_check"].(map[string]interface{})
+	if space["estimated_send"] != "100.00 GiB" || space["checked_dataset"] != "backup" || space["space_checked"] != true {
+		t.Errorf("space_check = %v", space)
+	}
+
+	// Incremental runs are not sized
+	if _, err := registry.CallTool("run_replication", map[string]interface{}{"id": float64(2)}); err != nil {
+		t.Errorf("incremental run failed: %v", err)
+	}
+	if _, err := registry.CallTool("run_replication", map[string]interface{}{"id": float64(1), "skip_space_check": true}); err != nil {
+		t.Errorf("run with skip_space_check failed: %v", err)
+	}
+
+	// Remote targets must be reachable and have a parent
+	server.SetError("replication.list_datasets", 22, "connection refused")
+	_, err = registry.CallTool("run_replication", map[string]interface{}{"id": float64(3)})
+	if ClassifyError(err).Code != ErrorPrecondition || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("unreachable remote error = %v, want PRECONDITION_FAILED", err)
+	}
+	server.SetResult("replication.list_datasets", []string{"other"})
+	_, err = registry.CallTool("run_replication", map[string]interface{}{"id": float64(3)})
+	if ClassifyError(err).Code != ErrorPrecondition || !strings.Contains(err.Error(), "parent remote exists") {
+		t.Errorf("missing remote parent error = %v, want PRECONDITION_FAILED", err)
+	}
+}
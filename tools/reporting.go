@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Reporting graph discovery handler
+
+// handleListReportingGraphs lists the reporting graphs the system exposes with
+// their identifiers and units, so any graph can be fetched by name
+func handleListReportingGraphs(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filter, _ := args["filter"].(string)
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	includeIdentifiers := true
+	if include, ok := args["include_identifiers"].(bool); ok {
+		includeIdentifiers = include
+	}
+
+	result, err := client.Call("reporting.graphs")
+	if err != nil {
+		return "", fmt.Errorf("failed to query reporting graphs: %w", err)
+	}
+
+	var graphs []map[string]interface{}
+	if err := json.Unmarshal(result, &graphs); err != nil {
+		return "", fmt.Errorf("failed to parse reporting graphs: %w", err)
+	}
+
+	summaries := make([]map[string]interface{}, 0, len(graphs))
+	for _, graph := range graphs {
+		name, _ := graph["name"].(string)
+		title, _ := graph["title"].(string)
+		if filter != "" && !strings.Contains(strings.ToLower(name), filter) && !strings.Contains(strings.ToLower(title), filter) {
+			continue
+		}
+
+		summary := map[string]interface{}{
+			"name":  name,
+			"title": title,
+		}
+		if unit, ok := graph["vertical_label"].(string); ok && unit != "" {
+			summary["unit"] = unit
+		}
+
+		// Graphs without identifiers (cpu, memory, load) are fetched by name alone
+		identifiers := []string{}
+		if list, ok := graph["identifiers"].([]interface{}); ok {
+			for _, id := range list {
+				if s, ok := id.(string); ok {
+					identifiers = append(identifiers, s)
+				}
+			}
+		}
+		summary["identifier_count"] = len(identifiers)
+		if includeIdentifiers && len(identifiers) > 0 {
+			summary["identifiers"] = identifiers
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i]["name"].(string) < summaries[j]["name"].(string)
+	})
+
+	return marshalJSON(map[string]interface{}{
+		"graphs": summaries,
+		"count":  len(summaries),
+	})
+}
+
+// Reporting data fetch and downsampling
+
+const (
+	// defaultMetricsMaxPoints caps the data points returned per series when no
+	// downsampling factor is given
+	defaultMetricsMaxPoints = 60
+)
+
+// metricsQuery holds the time range and sampling controls for reporting.get_data
+type metricsQuery struct {
+	Unit        string
+	Start       int64 // Unix seconds, 0 = use Unit
+	End         int64 // Unix seconds, 0 = now
+	MaxPoints   int
+	Factor      int // raw points per returned point, 0 = derive from MaxPoints
+	Aggregation string
+}
+
+// parseMetricsQuery reads unit, start, end, max_points, downsample, and
+// aggregation from tool arguments
+func parseMetricsQuery(args map[string]interface{}) (metricsQuery, error) {
+	q := metricsQuery{
+		Unit:        "HOUR",
+		MaxPoints:   defaultMetricsMaxPoints,
+		Aggregation: "mean",
+	}
+
+	if unit, ok := args["unit"].(string); ok && unit != "" {
+		q.Unit = unit
+	}
+	if start, ok := args["start"].(float64); ok {
+		q.Start = int64(start)
+	}
+	if end, ok := args["end"].(float64); ok {
+		q.End = int64(end)
+	}
+	if q.End != 0 && q.Start == 0 {
+		return q, fmt.Errorf("end requires start")
+	}
+	if q.Start != 0 && q.End != 0 && q.End <= q.Start {
+		return q, fmt.Errorf("end must be after start")
+	}
+
+	if maxPoints, ok := args["max_points"].(float64); ok {
+		if maxPoints < 1 {
+			return q, fmt.Errorf("max_points must be at least 1")
+		}
+		q.MaxPoints = int(maxPoints)
+	}
+	if factor, ok := args["downsample"].(float64); ok {
+		if factor < 1 {
+			return q, fmt.Errorf("downsample must be at least 1")
+		}
+		q.Factor = int(factor)
+	}
+
+	if aggregation, ok := args["aggregation"].(string); ok && aggregation != "" {
+		switch aggregation {
+		case "mean", "max", "min":
+			q.Aggregation = aggregation
+		default:
+			return q, fmt.Errorf("aggregation must be mean, max, or min, got: %s", aggregation)
+		}
+	}
+
+	return q, nil
+}
+
+// options returns the reporting.get_data query options for the time range
+func (q metricsQuery) options() map[string]interface{} {
+	if q.Start == 0 {
+		return map[string]interface{}{"unit": q.Unit}
+	}
+	options := map[string]interface{}{"start": q.Start}
+	if q.End != 0 {
+		options["end"] = q.End
+	}
+	return options
+}
+
+// fetchReportingGraph returns the summarized series for one graph. A nil
+// identifier selects graphs that have no identifiers (cpu, memory, load).
+func fetchReportingGraph(client truenas.Caller, name string, identifier interface{}, q metricsQuery) ([]map[string]interface{}, error) {
+	result, err := client.Call("reporting.get_data", []interface{}{
+		map[string]interface{}{
+			"name":       name,
+			"identifier": identifier,
+		},
+	}, q.options())
+	if err != nil {
+		return nil, err
+	}
+
+	var fullData []map[string]interface{}
+	if err := json.Unmarshal(result, &fullData); err != nil {
+		return nil, fmt.Errorf("parse error: %v", err)
+	}
+
+	summaries := make([]map[string]interface{}, 0, len(fullData))
+	for _, item := range fullData {
+		summaries = append(summaries, summarizeReportingData(item, q))
+	}
+	return summaries, nil
+}
+
+// summarizeReportingData keeps a series' metadata and aggregations and
+// downsamples its data rows
+func summarizeReportingData(item map[string]interface{}, q metricsQuery) map[string]interface{} {
+	summary := make(map[string]interface{})
+	for key, value := range item {
+		if key != "data" {
+			// Keep all other fields: aggregations, start, end, legend, name, identifier
+			summary[key] = value
+			continue
+		}
+
+		rows, _ := value.([]interface{})
+		factor := q.Factor
+		if factor == 0 {
+			factor = (len(rows) + q.MaxPoints - 1) / q.MaxPoints
+		}
+		if factor < 1 {
+			factor = 1
+		}
+
+		summary["data"] = downsampleRows(rows, factor, q.Aggregation)
+		summary["data_points_total"] = len(rows)
+		summary["downsampling"] = map[string]interface{}{
+			"factor":      factor,
+			"aggregation": q.Aggregation,
+		}
+	}
+	return summary
+}
+
+// downsampleRows combines every factor consecutive [timestamp, v1, v2, ...] rows
+// into one row stamped with the bucket's first timestamp. Each value column is
+// aggregated independently, skipping nulls.
+func downsampleRows(rows []interface{}, factor int, aggregation string) []interface{} {
+	if factor <= 1 {
+		return rows
+	}
+
+	out := make([]interface{}, 0, (len(rows)+factor-1)/factor)
+	for start := 0; start < len(rows); start += factor {
+		end := start + factor
+		if end > len(rows) {
+			end = len(rows)
+		}
+		bucket := rows[start:end]
+
+		first, ok := bucket[0].([]interface{})
+		if !ok || len(first) == 0 {
+			continue
+		}
+
+		combined := make([]interface{}, len(first))
+		combined[0] = first[0]
+		for col := 1; col < len(first); col++ {
+			var acc float64
+			count := 0
+			for _, raw := range bucket {
+				row, ok := raw.([]interface{})
+				if !ok || col >= len(row) {
+					continue
+				}
+				v, ok := row[col].(float64)
+				if !ok {
+					continue
+				}
+				switch {
+				case count == 0:
+					acc = v
+				case aggregation == "max" && v > acc:
+					acc = v
+				case aggregation == "min" && v < acc:
+					acc = v
+				case aggregation == "mean":
+					acc += v
+				}
+				count++
+			}
+			if count == 0 {
+				continue
+			}
+			if aggregation == "mean" {
+				acc /= float64(count)
+			}
+			combined[col] = acc
+		}
+		out = append(out, combined)
+	}
+	return out
+}
+
+// handleGetMetrics fetches any reporting graph by name and identifier
+func handleGetMetrics(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	graph, ok := args["graph"].(string)
+	if !ok || graph == "" {
+		return "", fmt.Errorf("graph is required (use list_reporting_graphs to discover names)")
+	}
+
+	q, err := parseMetricsQuery(args)
+	if err != nil {
+		return "", err
+	}
+
+	var identifiers []interface{}
+	switch v := args["identifier"].(type) {
+	case string:
+		if v != "" {
+			identifiers = []interface{}{v}
+		}
+	case []interface{}:
+		identifiers = v
+	}
+	if len(identifiers) == 0 {
+		identifiers = []interface{}{nil}
+	}
+
+	clock := getNASClock(client)
+	series := make(map[string]interface{})
+	for _, identifier := range identifiers {
+		key := "default"
+		if s, ok := identifier.(string); ok {
+			key = s
+		}
+
+		summaries, err := fetchReportingGraph(client, graph, identifier, q)
+		if err != nil {
+			series[key] = map[string]string{"error": err.Error()}
+			continue
+		}
+		for _, summary := range summaries {
+			for _, field := range []string{"start", "end"} {
+				if ts, ok := summary[field].(float64); ok {
+					summary[field+"_time"] = clock.format(time.Unix(int64(ts), 0))
+				}
+			}
+		}
+		if len(summaries) == 1 {
+			series[key] = summaries[0]
+		} else {
+			series[key] = summaries
+		}
+	}
+
+	response := map[string]interface{}{
+		"graph":  graph,
+		"series": series,
+	}
+	if q.Start != 0 {
+		response["start"] = q.Start
+		response["start_time"] = clock.format(time.Unix(q.Start, 0))
+		if q.End != 0 {
+			response["end"] = q.End
+			response["end_time"] = clock.format(time.Unix(q.End, 0))
+		}
+	} else {
+		response["unit"] = q.Unit
+	}
+	response["timezone"] = clock.info()
+	response["timestamps"] = "Data rows start with Unix seconds (UTC); *_time fields are in the NAS timezone"
+
+	return marshalJSON(response)
+}
+
+// firstReportingSummary returns the first series of a single-series graph, or
+// an empty summary if the graph returned no data
+func firstReportingSummary(summaries []map[string]interface{}) map[string]interface{} {
+	if len(summaries) == 0 {
+		return map[string]interface{}{}
+	}
+	return summaries[0]
+}
@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDownsampleRows(t *testing.T) {
+	rows := []interface{}{
+		[]interface{}{float64(0), float64(1), float64(10)},
+		[]interface{}{float64(10), float64(3), nil},
+		[]interface{}{float64(20), float64(2), float64(30)},
+		[]interface{}{float64(30), float64(8), float64(40)},
+		[]interface{}{float64(40), float64(5), float64(50)},
+	}
+
+	tests := []struct {
+		aggregation string
+		expected    []interface{}
+	}{
+		{
+			aggregation: "mean",
+			expected: []interface{}{
+				[]interface{}{float64(0), float64(2), float64(20)},
+				[]interface{}{float64(30), 6.5, float64(45)},
+			},
+		},
+		{
+			aggregation: "max",
+			expected: []interface{}{
+				[]interface{}{float64(0), float64(3), float64(30)},
+				[]interface{}{float64(30), float64(8), float64(50)},
+			},
+		},
+		{
+			aggregation: "min",
+			expected: []interface{}{
+				[]interface{}{float64(0), float64(1), float64(10)},
+				[]interface{}{float64(30), float64(5), float64(40)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.aggregation, func(t *testing.T) {
+			got := downsampleRows(rows, 3, tt.aggregation)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("downsampleRows(%s) = %v, want %v", tt.aggregation, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMetricsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		wantErr bool
+		options map[string]interface{}
+	}{
+		{
+			name:    "defaults",
+			args:    map[string]interface{}{},
+			options: map[string]interface{}{"unit": "HOUR"},
+		},
+		{
+			name:    "custom range",
+			args:    map[string]interface{}{"start": float64(1000), "end": float64(2000), "unit": "DAY"},
+			options: map[string]interface{}{"start": int64(1000), "end": int64(2000)},
+		},
+		{name: "end before start", args: map[string]interface{}{"start": float64(2000), "end": float64(1000)}, wantErr: true},
+		{name: "end without start", args: map[string]interface{}{"end": float64(1000)}, wantErr: true},
+		{name: "bad aggregation", args: map[string]interface{}{"aggregation": "median"}, wantErr: true},
+		{name: "zero downsample", args: map[string]interface{}{"downsample": float64(0)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseMetricsQuery(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(q.options(), tt.options) {
+				t.Errorf("options = %v, want %v", q.options(), tt.options)
+			}
+		})
+	}
+}
+
+func TestSummarizeReportingDataCapsPoints(t *testing.T) {
+	rows := make([]interface{}, 0, 125)
+	for i := 0; i < 125; i++ {
+		rows = append(rows, []interface{}{float64(i), float64(i)})
+	}
+
+	summary := summarizeReportingData(map[string]interface{}{
+		"name": "cpu",
+		"data": rows,
+	}, metricsQuery{MaxPoints: 60, Aggregation: "mean"})
+
+	if summary["data_points_total"] != 125 {
+		t.Errorf("data_points_total = %v, want 125", summary["data_points_total"])
+	}
+	if data := summary["data"].([]interface{}); len(data) != 42 {
+		t.Errorf("returned %d points, want 42 (factor 3)", len(data))
+	}
+	if summary["name"] != "cpu" {
+		t.Errorf("metadata not preserved: %v", summary)
+	}
+}
+
+func TestIntegrationListReportingGraphs(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("reporting.graphs", []map[string]interface{}{
+		{"name": "disktemp", "title": "Disks Temperature", "vertical_label": "Celsius", "identifiers": []interface{}{"sda", "sdb"}},
+		{"name": "cpu", "title": "CPU Usage", "vertical_label": "%CPU", "identifiers": nil},
+		{"name": "arcsize", "title": "ARC Size", "vertical_label": "Bytes", "identifiers": nil},
+	})
+
+	result, err := registry.CallTool("list_reporting_graphs", map[string]interface{}{"filter": "TEMP"})
+	if err != nil {
+		t.Fatalf("list_reporting_graphs failed: %v", err)
+	}
+
+	response := decodeResult(t, result)
+	graphs, _ := response["graphs"].([]interface{})
+	if len(graphs) != 1 {
+		t.Fatalf("got %d graphs, want 1:\n%s", len(graphs), result)
+	}
+	graph, _ := graphs[0].(map[string]interface{})
+	if graph["name"] != "disktemp" || graph["unit"] != "Celsius" || graph["identifier_count"] != float64(2) {
+		t.Errorf("unexpected graph summary: %v", graph)
+	}
+}
+
+func TestIntegrationGetMetrics(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("reporting.get_data", []map[string]interface{}{
+		{
+			"name":       "nfsstat",
+			"identifier": nil,
+			"legend":     []interface{}{"time", "reads"},
+			"data": []interface{}{
+				[]interface{}{float64(100), float64(4)},
+				[]interface{}{float64(110), float64(9)},
+				[]interface{}{float64(120), float64(1)},
+				[]interface{}{float64(130), float64(2)},
+			},
+		},
+	})
+
+	result, err := registry.CallTool("get_metrics", map[string]interface{}{
+		"graph":       "nfsstat",
+		"start":       float64(100),
+		"end":         float64(140),
+		"aggregation": "max",
+		"downsample":  float64(2),
+	})
+	if err != nil {
+		t.Fatalf("get_metrics failed: %v", err)
+	}
+
+	response := decodeResult(t, result)
+	series, _ := response["series"].(map[string]interface{})
+	summary, _ := series["default"].(map[string]interface{})
+	data, _ := summary["data"].([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("got %d points, want 2:\n%s", len(data), result)
+	}
+	if first, _ := data[0].([]interface{}); first[1] != float64(9) {
+		t.Errorf("first bucket = %v, want max 9", first)
+	}
+
+	calls := server.Calls("reporting.get_data")
+	if options, _ := calls[0].Params[1].(map[string]interface{}); options["start"] != float64(100) || options["end"] != float64(140) {
+		t.Errorf("query options = %v, want start/end range", calls[0].Params[1])
+	}
+}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/digest"
+	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/prompts"
+	"github.com/truenas/truenas-mcp/resources"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// MCP resources and prompts: read-only documents a client can attach to its
+// context, and runbook templates built on the tools.
+// Besides the documents registered here, TrueNAS objects (pools, datasets,
+// shares, VMs, apps, alerts) are served by the resources package.
+
+// Resource is a document served through resources/read. It is only served
+// while Tool, which returns the same content, is enabled.
+type Resource struct {
+	Definition mcp.Resource
+	Tool       string
+	Read       func(truenas.Caller) (string, error)
+}
+
+func (r *Registry) registerResources() {
+	r.resources[inventoryResourceURI] = Resource{
+		Definition: mcp.Resource{
+			URI:         inventoryResourceURI,
+			Name:        "System inventory",
+			Description: "Compact snapshot of the system: hostname, version, pools, dataset count, shares, apps, VMs, users, and network interfaces. Same content as the get_inventory tool.",
+			MimeType:    "application/json",
+		},
+		Tool: "get_inventory",
+		Read: func(client truenas.Caller) (string, error) {
+			return handleGetInventory(client.Context(), client, nil)
+		},
+	}
+
+	if r.digestScheduler != nil {
+		r.resources[digest.ResourceURI] = Resource{
+			Definition: mcp.Resource{
+				URI:         digest.ResourceURI,
+				Name:        "Health digest",
+				Description: "Latest health digest (scheduled or generated with generate_health_digest) and the next scheduled run. Same content as the get_health_digest tool.",
+				MimeType:    "application/json",
+			},
+			Tool: "get_health_digest",
+			Read: func(client truenas.Caller) (string, error) {
+				return r.handleGetHealthDigest(client.Context(), client, nil)
+			},
+		}
+	}
+}
+
+// toolEnabled reports whether a tool is registered, i.e. not removed by
+// read-only mode or the tool filter
+func (r *Registry) toolEnabled(name string) bool {
+	_, ok := r.tools[name]
+	return ok
+}
+
+// ListResources lists the resources whose backing tool is enabled
+func (r *Registry) ListResources() []mcp.Resource {
+	list := make([]mcp.Resource, 0, len(r.resources))
+	for _, resource := range r.resources {
+		if r.toolEnabled(resource.Tool) {
+			list = append(list, resource.Definition)
+		}
+	}
+
+	objects, err := resources.List(r.clientFor(NewCorrelationID()), r.toolEnabled)
+	if err != nil {
+		slog.Warn("Listing TrueNAS resources was incomplete", "error", err)
+	}
+	list = append(list, objects...)
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].URI < list[j].URI
+	})
+	return list
+}
+
+// ListResourceTemplates returns URI templates for TrueNAS objects, so clients
+// can read objects (such as datasets beyond the listed ones) by name
+func (r *Registry) ListResourceTemplates() []mcp.ResourceTemplate {
+	return resources.Templates(r.toolEnabled)
+}
+
+// ReadResource returns a resource's content, with the same timeout and output
+// redaction as tool calls. The read is abandoned when ctx is cancelled.
+// Resources whose backing tool is disabled are not found.
+func (r *Registry) ReadResource(ctx context.Context, uri string) (string, error) {
+	read := func(client truenas.Caller) (string, error) {
+		return resources.Read(client, uri, r.toolEnabled)
+	}
+	if resource, exists := r.resources[uri]; exists {
+		if !r.toolEnabled(resource.Tool) {
+			return "", newToolError(ErrorNotFound, "unknown resource: %s", uri)
+		}
+		read = resource.Read
+	}
+
+	tool := Tool{Handler: func(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+		return read(client)
+	}}
+	result, err := r.callWithTimeout(ctx, uri, tool, r.clientFor(NewCorrelationID()), nil)
+	if errors.Is(err, resources.ErrNotFound) {
+		return "", newToolError(ErrorNotFound, "unknown resource: %s", uri)
+	}
+	if err != nil {
+		return "", err
+	}
+	return redactOutput(result), nil
+}
+
+func (r *Registry) ListPrompts() []mcp.Prompt {
+	return prompts.List()
+}
+
+// GetPrompt renders a runbook prompt. Runbooks that rely on tools disabled on
+// this server (read-only mode or the tool filter) say so, so the model does
+// not plan around tools it cannot call.
+func (r *Registry) GetPrompt(name string, args map[string]string) (*mcp.PromptGetResult, error) {
+	prompt, err := prompts.Lookup(name)
+	if err != nil {
+		return nil, newToolError(ErrorNotFound, "unknown prompt: %s", name)
+	}
+	result, err := prompt.Render(args)
+	if err != nil {
+		return nil, newToolError(ErrorValidation, "%v", err)
+	}
+
+	var disabled []string
+	for _, tool := range prompt.Tools {
+		if _, ok := r.disabledTools[tool]; ok {
+			disabled = append(disabled, tool)
+		}
+	}
+	if len(disabled) > 0 {
+		message := &result.Messages[0].Content
+		message.Text += fmt.Sprintf("\n\nNote: these tools are disabled on this server and cannot be called: %s. Skip those steps and tell me what would need to be done manually.", strings.Join(disabled, ", "))
+	}
+	return result, nil
+}
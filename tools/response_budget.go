@@ -0,0 +1,296 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Response shaping and size budget
+//
+// Read tools accept fields (keep only these fields of each record) and
+// verbosity=summary (keep only each record's scalar fields) to project their
+// output, and every response is held to the server's byte budget: when it is
+// over, the longest lists are cut short and the response is marked truncated
+// with a hint on how to ask for less.
+
+// Verbosity levels accepted by read tools
+const (
+	verbosityFull    = "full"
+	verbositySummary = "summary"
+)
+
+// addResponseShapingArguments adds fields and verbosity to the schema of every
+// read tool
+func (r *Registry) addResponseShapingArguments() {
+	for name, tool := range r.tools {
+		if isWriteTool(name, tool) {
+			continue
+		}
+		props, ok := tool.Definition.InputSchema["properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, taken := props["fields"]; !taken {
+			props["fields"] = map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional: Keep only these fields of each returned record (dotted paths such as 'status.state' reach into nested objects); totals and paging fields are always kept",
+			}
+		}
+		if _, taken := props["verbosity"]; !taken {
+			props["verbosity"] = map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{verbositySummary, verbosityFull},
+				"description": "Optional: 'summary' keeps only the plain (non-nested) fields of each record; 'full' returns everything (default: full)",
+			}
+		}
+	}
+}
+
+// responseShape is the projection a read tool call asked for
+type responseShape struct {
+	fields  []string
+	summary bool
+}
+
+// parseResponseShape reads the fields and verbosity arguments
+func parseResponseShape(args map[string]interface{}) (responseShape, error) {
+	var shape responseShape
+	switch verbosity, _ := args["verbosity"].(string); verbosity {
+	case "", verbosityFull:
+	case verbositySummary:
+		shape.summary = true
+	default:
+		return shape, newToolError(ErrorValidation, "verbosity must be '%s' or '%s', got %q", verbositySummary, verbosityFull, verbosity)
+	}
+	if raw, ok := args["fields"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return shape, newToolError(ErrorValidation, "fields must be a list of field names")
+		}
+		for _, item := range list {
+			field, ok := item.(string)
+			if !ok || field == "" {
+				return shape, newToolError(ErrorValidation, "fields must be a list of field names")
+			}
+			shape.fields = append(shape.fields, field)
+		}
+	}
+	return shape, nil
+}
+
+func (s responseShape) empty() bool {
+	return len(s.fields) == 0 && !s.summary
+}
+
+// shapeOutput applies a projection to a JSON tool response. Records are the
+// objects in the top-level list, or in the lists held by the top-level
+// object; a response without lists is a record itself. Output that is not
+// JSON is returned unchanged.
+func shapeOutput(output string, shape responseShape) string {
+	if shape.empty() {
+		return output
+	}
+	decoded, ok := decodeOutput(output)
+	if !ok {
+		return output
+	}
+
+	switch v := decoded.(type) {
+	case []interface{}:
+		decoded = shapeRecords(v, shape)
+	case map[string]interface{}:
+		hasLists := false
+		for key, value := range v {
+			if list, ok := value.([]interface{}); ok {
+				v[key] = shapeRecords(list, shape)
+				hasLists = true
+			}
+		}
+		if !hasLists {
+			decoded = shapeRecord(v, shape)
+		}
+	}
+	return encodeOutput(decoded)
+}
+
+// shapeRecords projects the objects in a list, leaving other items alone
+func shapeRecords(list []interface{}, shape responseShape) []interface{} {
+	for i, item := range list {
+		if record, ok := item.(map[string]interface{}); ok {
+			list[i] = shapeRecord(record, shape)
+		}
+	}
+	return list
+}
+
+// shapeRecord keeps the requested fields of one record
+func shapeRecord(record map[string]interface{}, shape responseShape) map[string]interface{} {
+	if len(shape.fields) > 0 {
+		projected := map[string]interface{}{}
+		for _, field := range shape.fields {
+			copyField(record, projected, strings.Split(field, "."))
+		}
+		record = projected
+	}
+	if shape.summary {
+		for key, value := range record {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				delete(record, key)
+			}
+		}
+	}
+	return record
+}
+
+// copyField copies the value at path in src into the same path in dst
+func copyField(src, dst map[string]interface{}, path []string) {
+	value, ok := src[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = value
+		return
+	}
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	child, _ := dst[path[0]].(map[string]interface{})
+	if child == nil {
+		child = map[string]interface{}{}
+		dst[path[0]] = child
+	}
+	copyField(nested, child, path[1:])
+}
+
+// fitResponse holds a tool response to maxBytes (0 = no limit). Over the
+// budget, items are dropped from the end of the longest lists until it fits,
+// and the response says how many were left out and how to page.
+func fitResponse(tool, output string, maxBytes int) string {
+	if maxBytes <= 0 || len(output) <= maxBytes {
+		return output
+	}
+	hint := fmt.Sprintf("The %s response exceeded %d bytes and was cut short. Ask for less: a smaller limit and next_cursor to page, fields to keep only what you need, or verbosity=summary.", tool, maxBytes)
+
+	decoded, ok := decodeOutput(output)
+	if ok {
+		var response map[string]interface{}
+		switch v := decoded.(type) {
+		case map[string]interface{}:
+			response = v
+		case []interface{}:
+			response = map[string]interface{}{"items": v}
+		}
+		if response != nil && trimLists(response, maxBytes) {
+			response["truncated"] = true
+			response["truncation_hint"] = hint
+			if fitted := encodeOutput(response); len(fitted) <= maxBytes {
+				return fitted
+			}
+		}
+	}
+
+	// Not JSON, or nothing left to drop: return the start of the text,
+	// shrinking it until the escaped preview fits
+	preview := map[string]interface{}{"truncated": true, "truncation_hint": hint}
+	cut := maxBytes
+	if cut > len(output) {
+		cut = len(output)
+	}
+	for {
+		for cut > 0 && !utf8.ValidString(output[:cut]) {
+			cut--
+		}
+		preview["preview"] = output[:cut]
+		fitted := encodeOutput(preview)
+		if len(fitted) <= maxBytes || cut == 0 {
+			return fitted
+		}
+		cut -= (len(fitted) - maxBytes + 1) / 2
+		if cut < 0 {
+			cut = 0
+		}
+	}
+}
+
+// trimLists drops items from the end of the top-level lists in response,
+// longest first, until its encoding fits maxBytes (leaving room for the
+// truncation marker). The counts left out are recorded in
+// truncated_items. It reports whether anything was dropped.
+func trimLists(response map[string]interface{}, maxBytes int) bool {
+	budget := maxBytes - 512
+	omitted := map[string]interface{}{}
+	for len(encodeOutput(response)) > budget {
+		// The longest list in encoded size gives up items first
+		longest, longestSize := "", 0
+		keys := make([]string, 0, len(response))
+		for key := range response {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			list, ok := response[key].([]interface{})
+			if !ok || len(list) == 0 {
+				continue
+			}
+			if size := len(encodeOutput(list)); size > longestSize {
+				longest, longestSize = key, size
+			}
+		}
+		if longest == "" {
+			break
+		}
+
+		// Keep as many leading items as fit with everything else as it is
+		list := response[longest].([]interface{})
+		lo, hi := 0, len(list)-1
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			response[longest] = list[:mid]
+			if len(encodeOutput(response)) <= budget {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		keep := lo
+		response[longest] = list[:keep]
+		previous, _ := omitted[longest].(int)
+		omitted[longest] = previous + len(list) - keep
+	}
+	if len(omitted) == 0 {
+		return false
+	}
+	response["truncated_items"] = omitted
+	return true
+}
+
+// decodeOutput parses a JSON tool response, keeping numbers as written
+func decodeOutput(output string) (interface{}, bool) {
+	decoder := json.NewDecoder(strings.NewReader(output))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil || decoder.More() {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// encodeOutput formats a value the way tool responses are written
+func encodeOutput(v interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
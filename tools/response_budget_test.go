@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestShapeOutput(t *testing.T) {
+	output := `{
+  "total_pools": 2,
+  "pools": [
+    {"name": "tank", "status": "ONLINE", "topology": {"data": []}, "scan": {"state": "FINISHED", "errors": 0}},
+    {"name": "backup", "status": "DEGRADED", "topology": {"data": []}, "scan": {"state": "SCANNING", "errors": 3}}
+  ]
+}`
+
+	fields := decodeResult(t, shapeOutput(output, responseShape{fields: []string{"name", "scan.state", "missing"}}))
+	if fields["total_pools"] != float64(2) {
+		t.Errorf("totals were dropped: %v", fields)
+	}
+	pool := fields["pools"].([]interface{})[1].(map[string]interface{})
+	if len(pool) != 2 || pool["name"] != "backup" || fmt.Sprint(pool["scan"]) != "map[state:SCANNING]" {
+		t.Errorf("projected pool = %v, want name and scan.state only", pool)
+	}
+
+	summary := decodeResult(t, shapeOutput(output, responseShape{summary: true}))
+	pool = summary["pools"].([]interface{})[0].(map[string]interface{})
+	if _, nested := pool["topology"]; nested || pool["status"] != "ONLINE" {
+		t.Errorf("summary pool = %v, want scalars only", pool)
+	}
+
+	// A response without lists is one record
+	single := decodeResult(t, shapeOutput(`{"hostname": "nas", "version": "25.04", "cores": 8}`, responseShape{fields: []string{"hostname"}}))
+	if len(single) != 1 || single["hostname"] != "nas" {
+		t.Errorf("projected object = %v", single)
+	}
+
+	if got := shapeOutput("plain text", responseShape{summary: true}); got != "plain text" {
+		t.Errorf("text output was rewritten: %q", got)
+	}
+}
+
+func TestParseResponseShape(t *testing.T) {
+	if _, err := parseResponseShape(map[string]interface{}{"verbosity": "loud"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("bad verbosity error = %v, want VALIDATION", err)
+	}
+	if _, err := parseResponseShape(map[string]interface{}{"fields": "name"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("string fields error = %v, want VALIDATION", err)
+	}
+	shape, err := parseResponseShape(map[string]interface{}{"fields": []interface{}{"name"}, "verbosity": "summary"})
+	if err != nil || !shape.summary || len(shape.fields) != 1 {
+		t.Errorf("shape = %+v, %v", shape, err)
+	}
+}
+
+func TestFitResponse(t *testing.T) {
+	snapshots := []map[string]interface{}{}
+	for i := 0; i < 500; i++ {
+		snapshots = append(snapshots, map[string]interface{}{"name": fmt.Sprintf("tank/media@auto-%04d", i), "holds": []string{}})
+	}
+	data, _ := json.MarshalIndent(map[string]interface{}{"snapshots": snapshots, "total_snapshots": 500}, "", "  ")
+	output := string(data)
+
+	if got := fitResponse("query_snapshots", output, 0); got != output {
+		t.Error("unlimited budget changed the response")
+	}
+
+	got := fitResponse("query_snapshots", output, 8192)
+	if len(got) > 8192 {
+		t.Fatalf("fitted response is %d bytes, want at most 8192", len(got))
+	}
+	response := decodeResult(t, got)
+	kept := len(response["snapshots"].([]interface{}))
+	omitted := response["truncated_items"].(map[string]interface{})["snapshots"].(float64)
+	if response["truncated"] != true || kept == 0 || kept+int(omitted) != 500 {
+		t.Errorf("kept %d and omitted %v of 500, truncated=%v", kept, omitted, response["truncated"])
+	}
+	if hint, _ := response["truncation_hint"].(string); !strings.Contains(hint, "next_cursor") {
+		t.Errorf("hint = %q, want paging advice", hint)
+	}
+
+	// Top-level lists and plain text are cut short too
+	list, _ := json.Marshal(snapshots)
+	if got := fitResponse("x", string(list), 4096); len(got) > 4096 || decodeResult(t, got)["truncated"] != true {
+		t.Errorf("fitted list = %d bytes: %.200s", len(got), got)
+	}
+	text := strings.Repeat(`line with "quotes"`+"\n", 1000)
+	got = fitResponse("x", text, 2048)
+	if len(got) > 2048 {
+		t.Errorf("fitted text is %d bytes, want at most 2048", len(got))
+	}
+	if preview, _ := decodeResult(t, got)["preview"].(string); !strings.HasPrefix(text, preview) || preview == "" {
+		t.Errorf("preview %q is not the start of the text", preview)
+	}
+}
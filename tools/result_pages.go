@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Paged results for large queries
+//
+// Query tools that can return thousands of rows keep the whole result in a
+// server-side buffer when called with stream=true and return the first page
+// plus a cursor. Later pages are read by passing the cursor back to the same
+// tool, so every page comes from one consistent query.
+
+const (
+	// resultBufferTTL is how long a buffer stays readable after its last page
+	resultBufferTTL = 15 * time.Minute
+
+	// maxResultBuffers bounds memory use; the least recently read buffer is
+	// dropped first
+	maxResultBuffers = 16
+)
+
+// resultBuffer holds the rows of one streamed query
+type resultBuffer struct {
+	tool     string
+	noun     string
+	rows     []map[string]interface{}
+	meta     map[string]interface{}
+	lastRead time.Time
+}
+
+// resultBuffers stores the buffers of streamed queries by ID
+type resultBuffers struct {
+	mu      sync.Mutex
+	buffers map[string]*resultBuffer
+}
+
+func newResultBuffers() *resultBuffers {
+	return &resultBuffers{buffers: make(map[string]*resultBuffer)}
+}
+
+// evictLocked drops expired buffers and, when full, the least recently read
+func (b *resultBuffers) evictLocked(now time.Time) {
+	var oldestID string
+	var oldest time.Time
+	for id, buf := range b.buffers {
+		if now.Sub(buf.lastRead) > resultBufferTTL {
+			delete(b.buffers, id)
+			continue
+		}
+		if oldestID == "" || buf.lastRead.Before(oldest) {
+			oldestID, oldest = id, buf.lastRead
+		}
+	}
+	if len(b.buffers) >= maxResultBuffers && oldestID != "" {
+		delete(b.buffers, oldestID)
+	}
+}
+
+// firstPage buffers the rows of a query and returns its first page. noun
+// names the rows in the response ("snapshots" gives snapshots,
+// snapshot_count, and total_snapshots); meta is repeated on every page.
+func (b *resultBuffers) firstPage(tool, noun string, rows []map[string]interface{}, limit int, meta map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	id := uuid.New().String()
+
+	b.mu.Lock()
+	b.evictLocked(now)
+	buf := &resultBuffer{tool: tool, noun: noun, rows: rows, meta: meta, lastRead: now}
+	b.buffers[id] = buf
+	b.mu.Unlock()
+
+	return buf.page(id, 0, limit, now)
+}
+
+// nextPage returns the page a cursor points at
+func (b *resultBuffers) nextPage(tool, cursor string, limit int) (map[string]interface{}, error) {
+	id, offsetText, ok := strings.Cut(cursor, ":")
+	offset, err := strconv.Atoi(offsetText)
+	if !ok || err != nil || offset < 0 {
+		return nil, newToolError(ErrorValidation, "invalid cursor %q; pass next_cursor from the previous page", cursor)
+	}
+
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	buf, found := b.buffers[id]
+	if !found || now.Sub(buf.lastRead) > resultBufferTTL {
+		delete(b.buffers, id)
+		return nil, newToolError(ErrorNotFound, "cursor expired (results are kept %s after the last page is read); run %s again with stream=true", resultBufferTTL, tool)
+	}
+	if buf.tool != tool {
+		return nil, newToolError(ErrorValidation, "cursor belongs to %s, not %s", buf.tool, tool)
+	}
+	if offset > len(buf.rows) {
+		return nil, newToolError(ErrorValidation, "cursor offset %d is past the end of the %d results", offset, len(buf.rows))
+	}
+	buf.lastRead = now
+	return buf.page(id, offset, limit, now), nil
+}
+
+// page builds the response for rows [offset, offset+limit)
+func (buf *resultBuffer) page(id string, offset, limit int, now time.Time) map[string]interface{} {
+	end := offset + limit
+	if end > len(buf.rows) {
+		end = len(buf.rows)
+	}
+	rows := buf.rows[offset:end]
+
+	singular := strings.TrimSuffix(buf.noun, "s")
+	response := map[string]interface{}{
+		buf.noun:            rows,
+		singular + "_count": len(rows),
+		"total_" + buf.noun: len(buf.rows),
+		"offset":            offset,
+		"results_expire_at": now.Add(resultBufferTTL).UTC().Format(time.RFC3339),
+	}
+	for k, v := range buf.meta {
+		response[k] = v
+	}
+	switch {
+	case len(buf.rows) == 0:
+		response["note"] = fmt.Sprintf("No %s match.", buf.noun)
+	case end < len(buf.rows):
+		response["next_cursor"] = fmt.Sprintf("%s:%d", id, end)
+		response["note"] = fmt.Sprintf("Showing %s %d-%d of %d. Pass next_cursor as cursor to %s for the next page.",
+			buf.noun, offset+1, end, len(buf.rows), buf.tool)
+	default:
+		response["note"] = fmt.Sprintf("Showing %s %d-%d of %d (last page).", buf.noun, offset+1, end, len(buf.rows))
+	}
+	return response
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/schedule"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Scheduled write operations: write tools accept schedule_at or
+// maintenance_window and are queued instead of run
+
+// maxScheduleAhead bounds how far in the future an operation can be scheduled
+const maxScheduleAhead = 90 * 24 * time.Hour
+
+// unschedulableTools are write tools that make no sense to run later
+var unschedulableTools = map[string]bool{
+	"cancel_scheduled_operation": true,
+	"undo_pending_deletion":      true,
+}
+
+// addScheduleArguments adds schedule_at and maintenance_window to the schema
+// of every schedulable write tool
+func (r *Registry) addScheduleArguments() {
+	for name, tool := range r.tools {
+		if !isWriteTool(name, tool) || unschedulableTools[name] {
+			continue
+		}
+		props, ok := tool.Definition.InputSchema["properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		props["schedule_at"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Optional: Queue the operation to run at this time instead of now: RFC 3339 (e.g., '2025-06-07T02:00:00Z') or server local time ('2025-06-07 02:00'). It runs within an hour of that time or not at all. Track it with list_scheduled_operations.",
+		}
+		props["maintenance_window"] = map[string]interface{}{
+			"type":        "string",
+			"description": "Optional: Queue the operation for the next opening of a maintenance window in server local time, e.g. 'sat 02:00-04:00', 'sat,sun 01:00', or 'daily 03:00-05:00' (default length 1 hour). It runs at the window start or is missed if the server is down for the whole window.",
+		}
+	}
+}
+
+// scheduleRequest extracts and validates schedule_at / maintenance_window,
+// removing them from args. ok is false when the call is not scheduled.
+func scheduleRequest(args map[string]interface{}, now time.Time) (executeAt, deadline time.Time, window string, ok bool, err error) {
+	at, _ := args["schedule_at"].(string)
+	window, _ = args["maintenance_window"].(string)
+	delete(args, "schedule_at")
+	delete(args, "maintenance_window")
+	at, window = strings.TrimSpace(at), strings.TrimSpace(window)
+
+	switch {
+	case at == "" && window == "":
+		return executeAt, deadline, "", false, nil
+	case at != "" && window != "":
+		return executeAt, deadline, "", false, newToolError(ErrorValidation, "schedule_at and maintenance_window cannot be combined")
+	case at != "":
+		executeAt, err = schedule.ParseTime(at)
+		if err != nil {
+			return executeAt, deadline, "", false, newToolError(ErrorValidation, "schedule_at: %v", err)
+		}
+		if !executeAt.After(now) {
+			return executeAt, deadline, "", false, newToolError(ErrorValidation, "schedule_at %s is in the past", executeAt.Format(time.RFC3339))
+		}
+		deadline = executeAt.Add(schedule.DefaultWindowLength)
+	default:
+		parsed, parseErr := schedule.ParseWindow(window)
+		if parseErr != nil {
+			return executeAt, deadline, "", false, newToolError(ErrorValidation, "maintenance_window: %v", parseErr)
+		}
+		executeAt, deadline = parsed.Next(now)
+	}
+
+	if executeAt.Sub(now) > maxScheduleAhead {
+		return executeAt, deadline, "", false, newToolError(ErrorValidation, "operations can be scheduled at most %d days ahead", int(maxScheduleAhead.Hours()/24))
+	}
+	return executeAt, deadline, window, true, nil
+}
+
+// scheduleToolCall queues a write tool call when its arguments ask for it.
+// Dry runs are not queued; their preview says when the operation would run.
+// handled is false when the call should run now.
+func (r *Registry) scheduleToolCall(correlationID, name string, tool Tool, args map[string]interface{}) (result string, handled bool, err error) {
+	if _, scheduled := args["schedule_at"]; !scheduled {
+		if _, scheduled := args["maintenance_window"]; !scheduled {
+			return "", false, nil
+		}
+	}
+	if !isWriteTool(name, tool) || unschedulableTools[name] {
+		return "", true, newToolError(ErrorValidation, "%s cannot be scheduled", name)
+	}
+	if r.scheduler == nil {
+		return "", true, newToolError(ErrorPrecondition, "scheduled operations are not available on this server")
+	}
+
+	// The queued arguments must not carry the schedule, or the operation
+	// would be queued again when it runs
+	queued := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		queued[key] = value
+	}
+	executeAt, deadline, window, ok, err := scheduleRequest(queued, time.Now())
+	if err != nil || !ok {
+		return "", true, err
+	}
+	when := map[string]interface{}{
+		"execute_at": executeAt.Format(time.RFC3339),
+		"deadline":   deadline.Format(time.RFC3339),
+	}
+
+	if dryRun, _ := queued["dry_run"].(bool); dryRun {
+		preview, err := r.CallToolWithCorrelationID(correlationID, name, queued)
+		if err != nil {
+			return "", true, err
+		}
+		var response map[string]interface{}
+		if json.Unmarshal([]byte(preview), &response) != nil {
+			return preview, true, nil
+		}
+		when["note"] = "Without dry_run the operation is queued, not run now. The preview reflects the current state, which may change by then."
+		response["schedule"] = when
+		result, err := marshalJSON(response)
+		return result, true, err
+	}
+
+	op, err := r.scheduler.Schedule(name, queued, executeAt, deadline, window, correlationID)
+	if err != nil {
+		return "", true, err
+	}
+	result, err = marshalJSON(map[string]interface{}{
+		"scheduled": true,
+		"operation": op,
+		"message": fmt.Sprintf("%s is queued to run at %s (id %s). Nothing has changed yet. If the server is not running before %s, the operation is missed instead of run late. Use list_scheduled_operations to follow it and cancel_scheduled_operation to withdraw it.",
+			name, executeAt.Format(time.RFC3339), op.ID, deadline.Format(time.RFC3339)),
+	})
+	return result, true, err
+}
+
+// runScheduledOperation is the scheduler's executor: it calls the queued tool
+// through the normal path, so locks, timeouts, and read-only mode apply
+func (r *Registry) runScheduledOperation(op schedule.Operation) (string, error) {
+	return r.CallToolWithCorrelationID(op.CorrelationID, op.Tool, op.Arguments)
+}
+
+func (r *Registry) handleListScheduledOperations(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.scheduler == nil {
+		return "", newToolError(ErrorPrecondition, "scheduled operations are not available on this server")
+	}
+
+	includeFinished, _ := args["include_finished"].(bool)
+	operations := []schedule.Operation{}
+	for _, op := range r.scheduler.List() {
+		if includeFinished || op.Status == schedule.StatusPending || op.Status == schedule.StatusRunning {
+			operations = append(operations, op)
+		}
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"operations":  operations,
+		"count":       len(operations),
+		"server_time": time.Now().Format(time.RFC3339),
+	})
+}
+
+func (r *Registry) handleCancelScheduledOperation(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	if r.scheduler == nil {
+		return "", newToolError(ErrorPrecondition, "scheduled operations are not available on this server")
+	}
+
+	id, _ := args["id"].(string)
+	if id == "" {
+		return "", fmt.Errorf("id is required")
+	}
+
+	cancelled, err := r.scheduler.Cancel(id)
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(map[string]interface{}{
+		"operation": cancelled,
+		"message":   fmt.Sprintf("Scheduled %s was cancelled; it will not run.", cancelled.Tool),
+	})
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/schedule"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationScheduledOperations(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetResult("alert.dismiss", nil)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	scheduler, err := schedule.NewQueue(schedule.Config{})
+	if err != nil {
+		t.Fatalf("NewQueue failed: %v", err)
+	}
+	registry := NewRegistry(server.Client(t), nil, Options{Scheduler: scheduler})
+
+	props := registry.tools["dismiss_alert"].Definition.InputSchema["properties"].(map[string]interface{})
+	if _, ok := props["maintenance_window"]; !ok {
+		t.Error("dismiss_alert schema lacks maintenance_window")
+	}
+	props = registry.tools["query_pools"].Definition.InputSchema["properties"].(map[string]interface{})
+	if _, ok := props["schedule_at"]; ok {
+		t.Error("query_pools schema has schedule_at, want it only on write tools")
+	}
+
+	result, err := registry.CallTool("dismiss_alert", map[string]interface{}{"uuid": "a1", "maintenance_window": "daily 02:00-04:00"})
+	if err != nil {
+		t.Fatalf("scheduling dismiss_alert failed: %v", err)
+	}
+	operation := decodeResult(t, result)["operation"].(map[string]interface{})
+	if calls := server.Calls("alert.dismiss"); len(calls) != 0 {
+		t.Fatalf("scheduled call ran immediately: %v", calls)
+	}
+	args := operation["arguments"].(map[string]interface{})
+	if _, ok := args["maintenance_window"]; ok || args["uuid"] != "a1" {
+		t.Errorf("queued arguments = %v, want uuid without the schedule", args)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"uuid": "a1", "schedule_at": "2001-01-01T00:00:00Z"},
+		{"uuid": "a1", "maintenance_window": "whenever"},
+		{"uuid": "a1", "schedule_at": "2099-01-01 00:00", "maintenance_window": "daily 02:00"},
+	} {
+		if _, err := registry.CallTool("dismiss_alert", bad); ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("dismiss_alert(%v) error = %v, want VALIDATION", bad, err)
+		}
+	}
+	if _, err := registry.CallTool("query_pools", map[string]interface{}{"schedule_at": "2099-01-01 00:00"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("scheduling a query tool error = %v, want VALIDATION", err)
+	}
+
+	// Dry runs preview now and report when the operation would run
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank", "name": "tank"}})
+	result, err = registry.CallTool("create_dataset", map[string]interface{}{"name": "tank/new", "dry_run": true, "schedule_at": time.Now().Add(48 * time.Hour).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("scheduled dry run failed: %v", err)
+	}
+	if _, ok := decodeResult(t, result)["schedule"]; !ok {
+		t.Errorf("dry run result lacks the schedule: %s", result)
+	}
+	if len(scheduler.List()) != 1 {
+		t.Errorf("dry run queued an operation: %v", scheduler.List())
+	}
+
+	// Run the queued call once its window opens
+	executeAt, _ := time.Parse(time.RFC3339, operation["execute_at"].(string))
+	scheduler.ExecuteDue(executeAt.Add(time.Second))
+	calls := server.Calls("alert.dismiss")
+	if len(calls) != 1 || calls[0].Params[0] != "a1" {
+		t.Fatalf("alert.dismiss calls = %v, want one for a1", calls)
+	}
+
+	result, err = registry.CallTool("list_scheduled_operations", map[string]interface{}{"include_finished": true})
+	if err != nil {
+		t.Fatalf("list_scheduled_operations failed: %v", err)
+	}
+	listed := decodeResult(t, result)["operations"].([]interface{})
+	if len(listed) != 1 || listed[0].(map[string]interface{})["status"] != string(schedule.StatusCompleted) {
+		t.Errorf("operations = %v, want the dismissal completed", listed)
+	}
+	if _, err := registry.CallTool("cancel_scheduled_operation", map[string]interface{}{"id": operation["id"]}); err == nil {
+		t.Error("cancelling a completed operation should fail")
+	}
+}
@@ -1,17 +1,20 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
 )
 
 // Pool scrub management handlers
 
-func handleQueryScrubSchedules(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleQueryScrubSchedules(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Query all scrub schedules
 	result, err := client.Call("pool.scrub.query", []interface{}{})
 	if err != nil {
@@ -26,6 +29,7 @@ func handleQueryScrubSchedules(client *truenas.Client, args map[string]interface
 	// Apply filters
 	poolFilter, hasPoolFilter := args["pool"].(string)
 	enabledOnly, _ := args["enabled_only"].(bool)
+	clock := getNASClock(client)
 
 	filtered := []map[string]interface{}{}
 	poolsWithSchedules := make(map[string]bool)
@@ -45,7 +49,7 @@ func handleQueryScrubSchedules(client *truenas.Client, args map[string]interface
 		}
 
 		poolsWithSchedules[poolName] = true
-		simplified := simplifyScrubSchedule(schedule)
+		simplified := simplifyScrubSchedule(schedule, clock)
 		filtered = append(filtered, simplified)
 	}
 
@@ -73,6 +77,7 @@ func handleQueryScrubSchedules(client *truenas.Client, args map[string]interface
 		"count":                   len(filtered),
 		"pools_with_schedules":    mapKeys(poolsWithSchedules),
 		"pools_without_schedules": poolsWithoutSchedules,
+		"timezone":                clock.info(),
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -83,8 +88,9 @@ func handleQueryScrubSchedules(client *truenas.Client, args map[string]interface
 	return string(formatted), nil
 }
 
-func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleGetScrubStatus(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	poolFilter, hasPoolFilter := args["pool"].(string)
+	clock := getNASClock(client)
 
 	// Query all pools
 	poolsResult, err := client.Call("pool.query", []interface{}{})
@@ -141,7 +147,7 @@ func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (
 			"name":       poolName,
 			"id":         int(poolID),
 			"size_bytes": pool["size"],
-			"size_human": formatBytes(int64(pool["size"].(float64))),
+			"size_human": units.FormatBytes(int64(pool["size"].(float64))),
 			"status":     pool["status"],
 		}
 
@@ -155,7 +161,7 @@ func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (
 
 				schedObj := schedule["schedule"].(map[string]interface{})
 				scheduleHuman := formatCronSchedule(schedObj)
-				nextRun := calculateNextRun(schedObj, time.Now())
+				nextRun := calculateNextRun(schedObj, clock.now())
 
 				status["schedule"] = map[string]interface{}{
 					"enabled":        enabled,
@@ -190,7 +196,7 @@ func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (
 						"job_id":      int(job["id"].(float64)),
 						"progress":    percent,
 						"description": description,
-						"started":     started.Format(time.RFC3339),
+						"started":     clock.format(started),
 					}
 					break
 				}
@@ -217,7 +223,7 @@ func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (
 				if endTime, ok := scan["end_time"].(map[string]interface{}); ok {
 					if endSec, ok := endTime["$date"].(float64); ok {
 						completed := time.Unix(int64(endSec/1000), 0)
-						lastScrub["completed"] = completed.Format(time.RFC3339)
+						lastScrub["completed"] = clock.format(completed)
 						lastScrub["days_ago"] = int(time.Since(completed).Hours() / 24)
 					}
 				}
@@ -246,6 +252,7 @@ func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (
 			"with_schedules":    withSchedules,
 			"without_schedules": withoutSchedules,
 		},
+		"timezone": clock.info(),
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -256,7 +263,7 @@ func handleGetScrubStatus(client *truenas.Client, args map[string]interface{}) (
 	return string(formatted), nil
 }
 
-func (r *Registry) handleCreateScrubSchedule(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleCreateScrubSchedule(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	poolName, ok := args["pool"].(string)
 	if !ok || poolName == "" {
 		return "", fmt.Errorf("pool is required")
@@ -302,7 +309,7 @@ func (r *Registry) handleCreateScrubSchedule(client *truenas.Client, args map[st
 	}
 
 	if len(existing) > 0 {
-		return "", fmt.Errorf("pool '%s' already has a scrub schedule (id: %v). Delete it first or use a different pool", poolName, existing[0]["id"])
+		return "", fmt.Errorf("pool '%s' already has a scrub schedule (id: %v). Use update_scrub_schedule to change it", poolName, existing[0]["id"])
 	}
 
 	// Create schedule
@@ -324,14 +331,17 @@ func (r *Registry) handleCreateScrubSchedule(client *truenas.Client, args map[st
 		return "", fmt.Errorf("failed to parse result: %w", err)
 	}
 
+	clock := getNASClock(client)
+	nextRun := calculateNextRun(scheduleObj, clock.now())
 	response := map[string]interface{}{
 		"pool":           poolName,
 		"schedule_id":    created["id"],
 		"enabled":        enabled,
 		"threshold_days": threshold,
 		"schedule_human": formatCronSchedule(scheduleObj),
-		"next_run":       calculateNextRun(scheduleObj, time.Now()),
-		"message":        fmt.Sprintf("Scrub schedule created for pool '%s'. First run: %s", poolName, calculateNextRun(scheduleObj, time.Now())),
+		"next_run":       nextRun,
+		"timezone":       clock.info(),
+		"message":        fmt.Sprintf("Scrub schedule created for pool '%s'. First run: %s", poolName, nextRun),
 	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
@@ -342,7 +352,7 @@ func (r *Registry) handleCreateScrubSchedule(client *truenas.Client, args map[st
 	return string(formatted), nil
 }
 
-func (r *Registry) handleRunScrub(client *truenas.Client, args map[string]interface{}) (string, error) {
+func (r *Registry) handleRunScrub(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	poolName, ok := args["pool"].(string)
 	if !ok || poolName == "" {
 		return "", fmt.Errorf("pool is required")
@@ -402,6 +412,7 @@ func (r *Registry) handleRunScrub(client *truenas.Client, args map[string]interf
 		args,
 		jobID,
 		48*time.Hour, // Scrubs can take days on large pools
+		client.CorrelationID(),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create task: %w", err)
@@ -428,7 +439,7 @@ func (r *Registry) handleRunScrub(client *truenas.Client, args map[string]interf
 	return string(formatted), nil
 }
 
-func handleDeleteScrubSchedule(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleDeleteScrubSchedule(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	scheduleID, ok := args["id"].(float64)
 	if !ok {
 		return "", fmt.Errorf("id is required")
@@ -478,25 +489,145 @@ func handleDeleteScrubSchedule(client *truenas.Client, args map[string]interface
 	return string(formatted), nil
 }
 
+// getScrubSchedule returns a scrub schedule by ID
+func getScrubSchedule(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("pool.scrub.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule: %w", err)
+	}
+
+	var schedules []map[string]interface{}
+	if err := json.Unmarshal(result, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules: %w", err)
+	}
+
+	if len(schedules) == 0 {
+		return nil, fmt.Errorf("schedule with id %d not found", id)
+	}
+
+	return schedules[0], nil
+}
+
+// scrubScheduleUpdate builds the pool.scrub.update payload from the fields
+// present in args. Cron fields not given keep their current values.
+func scrubScheduleUpdate(existing map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if scheduleArg, ok := args["schedule"].(map[string]interface{}); ok {
+		schedule := map[string]interface{}{}
+		if current, ok := existing["schedule"].(map[string]interface{}); ok {
+			for k, v := range current {
+				schedule[k] = v
+			}
+		}
+		for _, field := range []string{"minute", "hour", "dom", "month", "dow"} {
+			if v, ok := scheduleArg[field].(string); ok && v != "" {
+				schedule[field] = v
+			}
+		}
+		update["schedule"] = schedule
+	}
+
+	if t, ok := args["threshold"].(float64); ok {
+		if t < 0 {
+			return nil, fmt.Errorf("threshold must not be negative")
+		}
+		update["threshold"] = int(t)
+	}
+
+	if e, ok := args["enabled"].(bool); ok {
+		update["enabled"] = e
+	}
+
+	if d, ok := args["description"].(string); ok {
+		update["description"] = d
+	}
+
+	if len(update) == 0 {
+		return nil, fmt.Errorf("nothing to update: provide schedule, threshold, enabled, or description")
+	}
+
+	return update, nil
+}
+
+func handleUpdateScrubSchedule(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	scheduleID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+
+	id := int(scheduleID)
+
+	existing, err := getScrubSchedule(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	update, err := scrubScheduleUpdate(existing, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("pool.scrub.update", id, update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	if _, ok := updated["schedule"].(map[string]interface{}); !ok {
+		return "", fmt.Errorf("unexpected update result: missing schedule")
+	}
+	clock := getNASClock(client)
+	simplified := simplifyScrubSchedule(updated, clock)
+	poolName, _ := existing["pool_name"].(string)
+
+	response := map[string]interface{}{
+		"updated":  true,
+		"schedule": simplified,
+		"timezone": clock.info(),
+		"message":  fmt.Sprintf("Scrub schedule updated for pool '%s'. Next run: %s", poolName, simplified["next_run"]),
+	}
+	if enabled, ok := updated["enabled"].(bool); ok && !enabled {
+		response["message"] = fmt.Sprintf("Scrub schedule updated for pool '%s'. Schedule is disabled and will not run", poolName)
+	}
+
+	formatted, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(formatted), nil
+}
+
 // Dry-run wrappers
 
-func (r *Registry) handleCreateScrubScheduleWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &createScrubScheduleDryRun{}, r.handleCreateScrubSchedule)
+func (r *Registry) handleCreateScrubScheduleWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createScrubScheduleDryRun{}, r.handleCreateScrubSchedule)
 }
 
-func (r *Registry) handleRunScrubWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &runScrubDryRun{}, r.handleRunScrub)
+func (r *Registry) handleRunScrubWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &runScrubDryRun{}, r.handleRunScrub)
 }
 
-func (r *Registry) handleDeleteScrubScheduleWithDryRun(client *truenas.Client, args map[string]interface{}) (string, error) {
-	return ExecuteWithDryRun(client, args, &deleteScrubScheduleDryRun{}, handleDeleteScrubSchedule)
+func (r *Registry) handleDeleteScrubScheduleWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteScrubScheduleDryRun{}, handleDeleteScrubSchedule)
+}
+
+func (r *Registry) handleUpdateScrubScheduleWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateScrubScheduleDryRun{}, handleUpdateScrubSchedule)
 }
 
 // Dry-run implementations
 
 type createScrubScheduleDryRun struct{}
 
-func (c *createScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (c *createScrubScheduleDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	poolName, ok := args["pool"].(string)
 	if !ok || poolName == "" {
 		return nil, fmt.Errorf("pool is required")
@@ -554,25 +685,26 @@ func (c *createScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args m
 		return nil, fmt.Errorf("failed to parse pool: %w", err)
 	}
 
+	clock := getNASClock(client)
 	var lastScrubDate string
 	if len(pools) > 0 {
 		if scan, ok := pools[0]["scan"].(map[string]interface{}); ok {
 			if endTime, ok := scan["end_time"].(map[string]interface{}); ok {
 				if endSec, ok := endTime["$date"].(float64); ok {
-					lastScrubDate = time.Unix(int64(endSec/1000), 0).Format(time.RFC3339)
+					lastScrubDate = clock.format(time.Unix(int64(endSec/1000), 0))
 				}
 			}
 		}
 	}
 
 	scheduleHuman := formatCronSchedule(scheduleObj)
-	firstRun := calculateNextRun(scheduleObj, time.Now())
+	firstRun := calculateNextRun(scheduleObj, clock.now())
 	estimatedHours := estimateScrubDuration(int64(poolInfo["size"].(float64)))
 
 	warnings := []string{}
 	if existingSchedule != nil {
 		warnings = append(warnings, fmt.Sprintf("ERROR: Pool '%s' already has a scrub schedule (id: %v)", poolName, existingSchedule["id"]))
-		warnings = append(warnings, "Delete the existing schedule first, or choose a different pool")
+		warnings = append(warnings, "Use update_scrub_schedule to change the existing schedule")
 	} else {
 		warnings = append(warnings, fmt.Sprintf("First scrub will run on %s", firstRun))
 		warnings = append(warnings, fmt.Sprintf("Scrub may take %d-%d hours based on pool size", estimatedHours, estimatedHours*3))
@@ -601,6 +733,7 @@ func (c *createScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args m
 			Details: map[string]interface{}{
 				"schedule_human": scheduleHuman,
 				"first_run":      firstRun,
+				"timezone":       clock.info(),
 				"threshold_days": threshold,
 				"enabled":        enabled,
 			},
@@ -612,7 +745,7 @@ func (c *createScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args m
 		CurrentState: map[string]interface{}{
 			"pool":              poolName,
 			"pool_id":           poolInfo["id"],
-			"pool_size":         formatBytes(int64(poolInfo["size"].(float64))),
+			"pool_size":         units.FormatBytes(int64(poolInfo["size"].(float64))),
 			"existing_schedule": existingSchedule,
 			"last_scrub":        lastScrubDate,
 		},
@@ -621,14 +754,14 @@ func (c *createScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args m
 		EstimatedTime: &EstimatedTime{
 			MinSeconds: estimatedHours * 3600,
 			MaxSeconds: estimatedHours * 3 * 3600,
-			Note:       fmt.Sprintf("Scrub duration: %d-%d hours for %s pools", estimatedHours, estimatedHours*3, formatBytes(int64(poolInfo["size"].(float64)))),
+			Note:       fmt.Sprintf("Scrub duration: %d-%d hours for %s pools", estimatedHours, estimatedHours*3, units.FormatBytes(int64(poolInfo["size"].(float64)))),
 		},
 	}, nil
 }
 
 type runScrubDryRun struct{}
 
-func (r *runScrubDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (r *runScrubDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	poolName, ok := args["pool"].(string)
 	if !ok || poolName == "" {
 		return nil, fmt.Errorf("pool is required")
@@ -687,6 +820,7 @@ func (r *runScrubDryRun) ExecuteDryRun(client *truenas.Client, args map[string]i
 		return nil, fmt.Errorf("failed to parse pool: %w", err)
 	}
 
+	clock := getNASClock(client)
 	var lastScrub map[string]interface{}
 	if len(pools) > 0 {
 		if scan, ok := pools[0]["scan"].(map[string]interface{}); ok {
@@ -697,7 +831,7 @@ func (r *runScrubDryRun) ExecuteDryRun(client *truenas.Client, args map[string]i
 			if endTime, ok := scan["end_time"].(map[string]interface{}); ok {
 				if endSec, ok := endTime["$date"].(float64); ok {
 					completed := time.Unix(int64(endSec/1000), 0)
-					lastScrub["date"] = completed.Format(time.RFC3339)
+					lastScrub["date"] = clock.format(completed)
 					lastScrub["days_ago"] = int(time.Since(completed).Hours() / 24)
 				}
 			}
@@ -734,7 +868,7 @@ func (r *runScrubDryRun) ExecuteDryRun(client *truenas.Client, args map[string]i
 			Operation:   "scrub",
 			Target:      poolName,
 			Details: map[string]interface{}{
-				"pool_size":                formatBytes(sizeBytes),
+				"pool_size":                units.FormatBytes(sizeBytes),
 				"estimated_duration_hours": estimatedHours,
 			},
 		})
@@ -752,7 +886,7 @@ func (r *runScrubDryRun) ExecuteDryRun(client *truenas.Client, args map[string]i
 		CurrentState: map[string]interface{}{
 			"pool":          poolName,
 			"pool_id":       poolInfo["id"],
-			"size":          formatBytes(sizeBytes),
+			"size":          units.FormatBytes(sizeBytes),
 			"status":        status,
 			"last_scrub":    lastScrub,
 			"scrub_running": scrubRunning,
@@ -770,7 +904,7 @@ func (r *runScrubDryRun) ExecuteDryRun(client *truenas.Client, args map[string]i
 
 type deleteScrubScheduleDryRun struct{}
 
-func (d *deleteScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args map[string]interface{}) (*DryRunResult, error) {
+func (d *deleteScrubScheduleDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
 	scheduleID, ok := args["id"].(float64)
 	if !ok {
 		return nil, fmt.Errorf("id is required")
@@ -833,9 +967,101 @@ func (d *deleteScrubScheduleDryRun) ExecuteDryRun(client *truenas.Client, args m
 	}, nil
 }
 
+type updateScrubScheduleDryRun struct{}
+
+func (u *updateScrubScheduleDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	scheduleID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	id := int(scheduleID)
+
+	existing, err := getScrubSchedule(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := scrubScheduleUpdate(existing, args)
+	if err != nil {
+		return nil, err
+	}
+
+	poolName, _ := existing["pool_name"].(string)
+	oldSchedule, _ := existing["schedule"].(map[string]interface{})
+	clock := getNASClock(client)
+	now := clock.now()
+
+	changes := map[string]interface{}{}
+	for _, field := range []string{"threshold", "enabled", "description"} {
+		if v, ok := update[field]; ok {
+			changes[field] = map[string]interface{}{"old": existing[field], "new": v}
+		}
+	}
+
+	newSchedule := oldSchedule
+	if s, ok := update["schedule"].(map[string]interface{}); ok {
+		newSchedule = s
+		changes["schedule"] = map[string]interface{}{
+			"old": formatCronSchedule(oldSchedule),
+			"new": formatCronSchedule(newSchedule),
+		}
+	}
+
+	oldEnabled, _ := existing["enabled"].(bool)
+	newEnabled := oldEnabled
+	if e, ok := update["enabled"].(bool); ok {
+		newEnabled = e
+	}
+
+	nextRun := func(schedule map[string]interface{}, enabled bool) string {
+		if !enabled {
+			return "disabled"
+		}
+		return calculateNextRun(schedule, now)
+	}
+	changes["next_run"] = map[string]interface{}{
+		"old":      nextRun(oldSchedule, oldEnabled),
+		"new":      nextRun(newSchedule, newEnabled),
+		"timezone": clock.loc.String(),
+	}
+
+	warnings := []string{}
+	if oldEnabled && !newEnabled {
+		warnings = append(warnings, fmt.Sprintf("Pool '%s' will no longer be scrubbed automatically while the schedule is disabled", poolName))
+	}
+	if s, ok := update["schedule"].(map[string]interface{}); ok {
+		hour, _ := s["hour"].(string)
+		if hour != "*" {
+			hourInt := 0
+			fmt.Sscanf(hour, "%d", &hourInt)
+			if hourInt >= 8 && hourInt <= 18 {
+				warnings = append(warnings, "WARNING: Schedule runs during typical business hours - may impact performance")
+			}
+		}
+	}
+
+	return &DryRunResult{
+		Tool: "update_scrub_schedule",
+		CurrentState: map[string]interface{}{
+			"schedule": simplifyScrubSchedule(existing, clock),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Update scrub schedule for pool '%s'", poolName),
+				Operation:   "update",
+				Target:      poolName,
+				Details:     changes,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
 // Helper functions for scrub management
 
-func simplifyScrubSchedule(schedule map[string]interface{}) map[string]interface{} {
+func simplifyScrubSchedule(schedule map[string]interface{}, clock nasClock) map[string]interface{} {
 	scheduleObj := schedule["schedule"].(map[string]interface{})
 
 	return map[string]interface{}{
@@ -847,7 +1073,7 @@ func simplifyScrubSchedule(schedule map[string]interface{}) map[string]interface
 		"description":    schedule["description"],
 		"schedule":       scheduleObj,
 		"schedule_human": formatCronSchedule(scheduleObj),
-		"next_run":       calculateNextRun(scheduleObj, time.Now()),
+		"next_run":       calculateNextRun(scheduleObj, clock.now()),
 	}
 }
 
@@ -855,8 +1081,13 @@ func formatCronSchedule(schedule map[string]interface{}) string {
 	minute, _ := schedule["minute"].(string)
 	hour, _ := schedule["hour"].(string)
 	dom, _ := schedule["dom"].(string)
+	month, _ := schedule["month"].(string)
 	dow, _ := schedule["dow"].(string)
 
+	if !simpleCronSchedule(schedule) {
+		return fmt.Sprintf("Custom: %s %s %s %s %s", minute, hour, dom, month, dow)
+	}
+
 	// Weekly pattern (specific day of week)
 	if dow != "*" && dom == "*" {
 		dayMap := map[string]string{
@@ -894,9 +1125,27 @@ func formatCronSchedule(schedule map[string]interface{}) string {
 	}
 
 	// Custom pattern
-	return fmt.Sprintf("Custom: %s %s %s * %s", minute, hour, dom, dow)
+	return fmt.Sprintf("Custom: %s %s %s %s %s", minute, hour, dom, month, dow)
 }
 
+// simpleCronSchedule reports whether a schedule fits the hourly, daily,
+// weekly, and monthly patterns formatCronSchedule and calculateNextRun
+// follow: single values or "*", every month. Steps, lists, and ranges
+// (common in cron jobs) do not.
+func simpleCronSchedule(schedule map[string]interface{}) bool {
+	minute, _ := schedule["minute"].(string)
+	hour, _ := schedule["hour"].(string)
+	dom, _ := schedule["dom"].(string)
+	month, _ := schedule["month"].(string)
+	dow, _ := schedule["dow"].(string)
+	if month != "" && month != "*" {
+		return false
+	}
+	return !strings.ContainsAny(minute+hour+dom+dow, ",/-")
+}
+
+// calculateNextRun returns the next run of a cron schedule after fromTime, in
+// fromTime's location; pass the NAS clock's time since schedules run on it
 func calculateNextRun(schedule map[string]interface{}, fromTime time.Time) string {
 	// Simplified calculation - just add one week/month/day based on pattern
 	// In production, would use a proper cron library
@@ -942,6 +1191,15 @@ func calculateNextRun(schedule map[string]interface{}, fromTime time.Time) strin
 		return next.Format(time.RFC3339)
 	}
 
+	// Hourly
+	if hour == "*" {
+		next := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), minuteInt, 0, 0, now.Location())
+		if next.Before(now) {
+			next = next.Add(time.Hour)
+		}
+		return next.Format(time.RFC3339)
+	}
+
 	// Daily
 	next := time.Date(now.Year(), now.Month(), now.Day(), hourInt, minuteInt, 0, 0, now.Location())
 	if next.Before(now) {
@@ -950,7 +1208,7 @@ func calculateNextRun(schedule map[string]interface{}, fromTime time.Time) strin
 	return next.Format(time.RFC3339)
 }
 
-func getPoolByName(client *truenas.Client, poolName string) (map[string]interface{}, error) {
+func getPoolByName(client truenas.Caller, poolName string) (map[string]interface{}, error) {
 	result, err := client.Call("pool.query", []interface{}{
 		[]interface{}{"name", "=", poolName},
 	})
@@ -970,7 +1228,7 @@ func getPoolByName(client *truenas.Client, poolName string) (map[string]interfac
 	return pools[0], nil
 }
 
-func findLatestScrubJob(client *truenas.Client, poolName string) (int, error) {
+func findLatestScrubJob(client truenas.Caller, poolName string) (int, error) {
 	result, err := client.Call("core.get_jobs", []interface{}{
 		[]interface{}{"method", "=", "pool.scrub.scrub"},
 	})
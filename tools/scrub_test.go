@@ -3,6 +3,9 @@ package tools
 import (
 	"testing"
 	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenastest"
 )
 
 func TestFormatCronSchedule(t *testing.T) {
@@ -55,6 +58,17 @@ func TestFormatCronSchedule(t *testing.T) {
 			},
 			expected: "Monthly on 23rd at 14:30",
 		},
+		{
+			name: "weekdays only",
+			schedule: map[string]interface{}{
+				"minute": "0",
+				"hour":   "9",
+				"dom":    "*",
+				"dow":    "1-5",
+				"month":  "*",
+			},
+			expected: "Custom: 0 9 * * 1-5",
+		},
 	}
 
 	for _, tt := range tests {
@@ -219,3 +233,119 @@ func TestMapKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegrationRunScrubTracksJob(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+
+	// pool.scrub.run starts a pool.scrub.scrub job in the middleware
+	server.Handle("pool.scrub.run", func(params []interface{}) (interface{}, error) {
+		server.AddJob("pool.scrub.scrub", params, truenastest.JobSpec{Steps: 2})
+		return nil, nil
+	})
+
+	result, err := registry.CallTool("run_scrub", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("run_scrub failed: %v", err)
+	}
+
+	started := decodeResult(t, result)
+	taskID, _ := started["task_id"].(string)
+	if taskID == "" {
+		t.Fatalf("run_scrub returned no task_id:\n%s", result)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := registry.CallTool("tasks_get", map[string]interface{}{"task_id": taskID})
+		if err != nil {
+			t.Fatalf("tasks_get failed: %v", err)
+		}
+		if decodeResult(t, result)["status"] == string(tasks.TaskStatusCompleted) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task did not complete:\n%s", result)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestIntegrationUpdateScrubSchedule(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.scrub.query", []map[string]interface{}{
+		{
+			"id":          float64(3),
+			"pool":        float64(1),
+			"pool_name":   "tank",
+			"threshold":   float64(35),
+			"enabled":     true,
+			"description": "",
+			"schedule":    map[string]interface{}{"minute": "0", "hour": "0", "dom": "*", "month": "*", "dow": "0"},
+		},
+	})
+	server.Handle("pool.scrub.update", func(params []interface{}) (interface{}, error) {
+		update, _ := params[1].(map[string]interface{})
+		return map[string]interface{}{
+			"id":          params[0],
+			"pool":        float64(1),
+			"pool_name":   "tank",
+			"threshold":   update["threshold"],
+			"enabled":     true,
+			"description": "",
+			"schedule":    update["schedule"],
+		}, nil
+	})
+
+	args := map[string]interface{}{
+		"id":        float64(3),
+		"schedule":  map[string]interface{}{"hour": "3", "dow": "6"},
+		"threshold": float64(28),
+		"dry_run":   true,
+	}
+	result, err := registry.CallTool("update_scrub_schedule", args)
+	if err != nil {
+		t.Fatalf("update_scrub_schedule dry run failed: %v", err)
+	}
+	if n := len(server.Calls("pool.scrub.update")); n != 0 {
+		t.Fatalf("dry run updated the schedule (%d calls)", n)
+	}
+	preview := decodeResult(t, result)
+	actions, _ := preview["planned_actions"].([]interface{})
+	if len(actions) != 1 {
+		t.Fatalf("planned_actions = %v, want one action", preview["planned_actions"])
+	}
+	details, _ := actions[0].(map[string]interface{})["details"].(map[string]interface{})
+	schedule, _ := details["schedule"].(map[string]interface{})
+	if schedule["old"] != "Weekly on Sunday at 0:0" || schedule["new"] != "Weekly on Saturday at 3:0" {
+		t.Errorf("schedule change = %v", schedule)
+	}
+	nextRun, _ := details["next_run"].(map[string]interface{})
+	if nextRun["old"] == nil || nextRun["old"] == nextRun["new"] {
+		t.Errorf("next_run change = %v, want differing old and new times", nextRun)
+	}
+
+	delete(args, "dry_run")
+	if _, err := registry.CallTool("update_scrub_schedule", args); err != nil {
+		t.Fatalf("update_scrub_schedule failed: %v", err)
+	}
+
+	calls := server.Calls("pool.scrub.update")
+	if len(calls) != 1 {
+		t.Fatalf("pool.scrub.update called %d times, want 1", len(calls))
+	}
+	update, _ := calls[0].Params[1].(map[string]interface{})
+	if update["threshold"] != float64(28) {
+		t.Errorf("threshold = %v, want 28", update["threshold"])
+	}
+	if _, ok := update["enabled"]; ok {
+		t.Errorf("enabled sent although not requested: %v", update)
+	}
+	cron, _ := update["schedule"].(map[string]interface{})
+	want := map[string]interface{}{"minute": "0", "hour": "3", "dom": "*", "month": "*", "dow": "6"}
+	for field, value := range want {
+		if cron[field] != value {
+			t.Errorf("schedule[%s] = %v, want %v", field, cron[field], value)
+		}
+	}
+}
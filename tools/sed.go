@@ -0,0 +1,300 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Self-encrypting drive (SED) management
+
+// sedUsers are the TCG Opal users TrueNAS can unlock drives as
+var sedUsers = map[string]bool{"USER": true, "MASTER": true}
+
+// sedPasswordNote explains what the stored passwords are for; it is repeated
+// in every dry run because it is the most common SED misunderstanding
+const sedPasswordNote = "TrueNAS stores this password to unlock drives at boot; it does not change the password on the drive itself (set that with sedutil-cli). If they differ, the drive stays locked after its next power cycle."
+
+// queryDisksWithPasswords returns disk.query with the per-disk SED password
+// and, on versions that report it, the SED status
+func queryDisksWithPasswords(client truenas.Caller, filters []interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call("disk.query", filters, map[string]interface{}{
+		"extra": map[string]interface{}{"passwords": true, "sed_status": true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disks: %w", err)
+	}
+
+	var disks []map[string]interface{}
+	if err := json.Unmarshal(result, &disks); err != nil {
+		return nil, fmt.Errorf("failed to parse disks: %w", err)
+	}
+	return disks, nil
+}
+
+// getSEDDisk returns a disk by name (e.g. "sda")
+func getSEDDisk(client truenas.Caller, name string) (map[string]interface{}, error) {
+	disks, err := queryDisksWithPasswords(client, []interface{}{
+		[]interface{}{"name", "=", name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(disks) == 0 {
+		return nil, newToolError(ErrorNotFound, "disk %s not found", name)
+	}
+	return disks[0], nil
+}
+
+// sedGlobalPasswordSet reports whether the global SED password is set. Only
+// whether it is set leaves this function.
+func sedGlobalPasswordSet(client truenas.Caller, config map[string]interface{}) bool {
+	if result, err := client.Call("system.advanced.sed_global_password"); err == nil {
+		var password string
+		if json.Unmarshal(result, &password) == nil {
+			return password != ""
+		}
+	}
+	password, _ := config["sed_passwd"].(string)
+	return password != ""
+}
+
+// simplifySEDDisk summarizes a disk's SED state without its password
+func simplifySEDDisk(disk map[string]interface{}) map[string]interface{} {
+	password, _ := disk["passwd"].(string)
+	summary := map[string]interface{}{
+		"name":         disk["name"],
+		"serial":       disk["serial"],
+		"model":        disk["model"],
+		"pool":         disk["pool"],
+		"password_set": password != "",
+	}
+	if status, ok := disk["sed_status"].(string); ok {
+		summary["sed_status"] = status
+		summary["sed_capable"] = status != "UNSUPPORTED"
+	} else {
+		summary["sed_status"] = "unknown"
+	}
+	return summary
+}
+
+func handleGetSEDStatus(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return "", err
+	}
+
+	filters := []interface{}{}
+	if name, ok := args["disk"].(string); ok && name != "" {
+		filters = append(filters, []interface{}{"name", "=", name})
+	}
+	disks, err := queryDisksWithPasswords(client, filters)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		a, _ := disks[i]["name"].(string)
+		b, _ := disks[j]["name"].(string)
+		return a < b
+	})
+
+	globalSet := sedGlobalPasswordSet(client, config)
+	summaries := []map[string]interface{}{}
+	locked, unprotected := []string{}, []string{}
+	statusKnown := false
+	for _, disk := range disks {
+		summary := simplifySEDDisk(disk)
+		summaries = append(summaries, summary)
+		name, _ := disk["name"].(string)
+		switch summary["sed_status"] {
+		case "unknown":
+			continue
+		case "LOCKED":
+			locked = append(locked, name)
+		}
+		statusKnown = true
+		// A capable disk with no password TrueNAS can use cannot be unlocked at boot
+		if summary["sed_capable"] == true && summary["sed_status"] != "UNINITIALIZED" && !globalSet && summary["password_set"] == false {
+			unprotected = append(unprotected, name)
+		}
+	}
+
+	response := map[string]interface{}{
+		"global": map[string]interface{}{
+			"sed_user":     config["sed_user"],
+			"password_set": globalSet,
+		},
+		"disks": summaries,
+		"count": len(summaries),
+	}
+	warnings := []string{}
+	if len(locked) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Locked disks: %s. Check the stored password matches the drive, then unlock them or reboot", strings.Join(locked, ", ")))
+	}
+	if len(unprotected) > 0 {
+		warnings = append(warnings, fmt.Sprintf("No global or per-disk SED password for %s; TrueNAS cannot unlock them at boot", strings.Join(unprotected, ", ")))
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	if !statusKnown && len(summaries) > 0 {
+		response["note"] = "This TrueNAS version does not report per-disk SED status; only the stored passwords are shown"
+	}
+	return marshalJSON(response)
+}
+
+// sedPasswordRequest validates set_sed_password arguments
+func sedPasswordRequest(args map[string]interface{}) (disk string, password string, sedUser string, err error) {
+	disk, _ = args["disk"].(string)
+	password, ok := args["password"].(string)
+	if !ok {
+		return "", "", "", fmt.Errorf("password is required (an empty string clears it)")
+	}
+	if user, ok := args["sed_user"].(string); ok && user != "" {
+		sedUser = strings.ToUpper(user)
+		if !sedUsers[sedUser] {
+			return "", "", "", newToolError(ErrorValidation, "sed_user must be USER or MASTER")
+		}
+		if disk != "" {
+			return "", "", "", newToolError(ErrorValidation, "sed_user is a global setting and cannot be combined with disk")
+		}
+	}
+	return disk, password, sedUser, nil
+}
+
+func handleSetSEDPassword(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	diskName, password, sedUser, err := sedPasswordRequest(args)
+	if err != nil {
+		return "", err
+	}
+
+	if diskName != "" {
+		disk, err := getSEDDisk(client, diskName)
+		if err != nil {
+			return "", err
+		}
+		if _, err := client.Call("disk.update", disk["identifier"], map[string]interface{}{"passwd": password}); err != nil {
+			return "", fmt.Errorf("failed to set SED password for %s: %w", diskName, err)
+		}
+
+		message := fmt.Sprintf("SED password for %s updated.", diskName)
+		if password == "" {
+			message = fmt.Sprintf("SED password for %s cleared; the global SED password is used to unlock it.", diskName)
+		}
+		return marshalJSON(map[string]interface{}{
+			"updated":      true,
+			"disk":         diskName,
+			"password_set": password != "",
+			"message":      message + " " + sedPasswordNote,
+		})
+	}
+
+	update := map[string]interface{}{"sed_passwd": password}
+	if sedUser != "" {
+		update["sed_user"] = sedUser
+	}
+	result, err := client.Call("system.advanced.update", update)
+	if err != nil {
+		return "", fmt.Errorf("failed to set global SED password: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"updated":      true,
+		"sed_user":     updated["sed_user"],
+		"password_set": password != "",
+		"message":      "Global SED password updated. " + sedPasswordNote,
+	})
+}
+
+func handleSetSEDPasswordWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &setSEDPasswordDryRun{}, handleSetSEDPassword)
+}
+
+type setSEDPasswordDryRun struct{}
+
+func (s *setSEDPasswordDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	diskName, password, sedUser, err := sedPasswordRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	warnings := []string{sedPasswordNote}
+
+	if diskName != "" {
+		disk, err := getSEDDisk(client, diskName)
+		if err != nil {
+			return nil, err
+		}
+		current := simplifySEDDisk(disk)
+		if current["sed_status"] == "UNSUPPORTED" {
+			warnings = append(warnings, fmt.Sprintf("%s does not support SED; the password has no effect", diskName))
+		}
+		description := fmt.Sprintf("Set the SED password TrueNAS uses for %s", diskName)
+		if password == "" {
+			description = fmt.Sprintf("Clear the SED password for %s so the global password is used", diskName)
+			config, err := getAdvancedConfig(client)
+			if err != nil {
+				return nil, err
+			}
+			if !sedGlobalPasswordSet(client, config) {
+				warnings = append(warnings, "No global SED password is set; TrueNAS will have no password to unlock this disk at boot")
+			}
+		}
+		return &DryRunResult{
+			Tool:         "set_sed_password",
+			CurrentState: current,
+			PlannedActions: []PlannedAction{
+				{
+					Step:        1,
+					Description: description,
+					Operation:   "update",
+					Target:      diskName,
+					Details:     map[string]interface{}{"password": map[string]interface{}{"changed": true}},
+				},
+			},
+			Warnings: warnings,
+		}, nil
+	}
+
+	config, err := getAdvancedConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	globalSet := sedGlobalPasswordSet(client, config)
+	changes := map[string]interface{}{"password": map[string]interface{}{"changed": true}}
+	if sedUser != "" {
+		changes["sed_user"] = map[string]interface{}{"old": config["sed_user"], "new": sedUser}
+	}
+	if password == "" && globalSet {
+		warnings = append(warnings, "Clearing the global password leaves disks without their own SED password locked at their next power cycle")
+	}
+	if sedUser == "MASTER" {
+		warnings = append(warnings, "The MASTER user unlocks with the drive's master password, which is not the same as the USER password on most drives")
+	}
+
+	return &DryRunResult{
+		Tool: "set_sed_password",
+		CurrentState: map[string]interface{}{
+			"sed_user":     config["sed_user"],
+			"password_set": globalSet,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: "Set the global SED password TrueNAS uses for disks without their own",
+				Operation:   "update",
+				Target:      "system.advanced",
+				Details:     changes,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationSEDPasswords(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.advanced.config", map[string]interface{}{"sed_user": "USER", "sed_passwd": ""})
+	server.SetResult("system.advanced.sed_global_password", "")
+	server.SetRecords("disk.query", []map[string]interface{}{
+		{"name": "sda", "identifier": "{serial}A", "serial": "A", "passwd": "", "sed_status": "LOCKED"},
+		{"name": "sdb", "identifier": "{serial}B", "serial": "B", "passwd": "drive-secret", "sed_status": "UNLOCKED"},
+		{"name": "sdc", "identifier": "{serial}C", "serial": "C", "passwd": "", "sed_status": "UNSUPPORTED"},
+	})
+	server.Handle("system.advanced.update", func(params []interface{}) (interface{}, error) {
+		update, _ := params[0].(map[string]interface{})
+		return map[string]interface{}{"sed_user": update["sed_user"]}, nil
+	})
+	server.SetResult("disk.update", map[string]interface{}{"name": "sda"})
+
+	result, err := registry.CallTool("get_sed_status", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_sed_status failed: %v", err)
+	}
+	if strings.Contains(result, "drive-secret") {
+		t.Fatalf("get_sed_status leaked a password:\n%s", result)
+	}
+	status := decodeResult(t, result)
+	disks, _ := status["disks"].([]interface{})
+	if len(disks) != 3 || disks[1].(map[string]interface{})["password_set"] != true {
+		t.Errorf("disks = %v", disks)
+	}
+	warnings := fmt.Sprint(status["warnings"])
+	if !strings.Contains(warnings, "Locked disks: sda") || !strings.Contains(warnings, "for sda;") {
+		t.Errorf("warnings = %s, want sda reported locked and without a password", warnings)
+	}
+
+	// sed_user is global only
+	if _, err := registry.CallTool("set_sed_password", map[string]interface{}{"disk": "sda", "password": "x", "sed_user": "MASTER"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("per-disk sed_user error = %v, want VALIDATION", err)
+	}
+
+	result, err = registry.CallTool("set_sed_password", map[string]interface{}{"disk": "sda", "password": "new-secret", "dry_run": true})
+	if err != nil {
+		t.Fatalf("set_sed_password dry run failed: %v", err)
+	}
+	if strings.Contains(result, "new-secret") || len(server.Calls("disk.update")) != 0 {
+		t.Errorf("dry run leaked the password or updated the disk:\n%s", result)
+	}
+	if _, err := registry.CallTool("set_sed_password", map[string]interface{}{"disk": "sda", "password": "new-secret"}); err != nil {
+		t.Fatalf("set_sed_password failed: %v", err)
+	}
+	calls := server.Calls("disk.update")
+	if len(calls) != 1 || calls[0].Params[0] != "{serial}A" || calls[0].Params[1].(map[string]interface{})["passwd"] != "new-secret" {
+		t.Errorf("disk.update calls = %v", calls)
+	}
+
+	if _, err := registry.CallTool("set_sed_password", map[string]interface{}{"password": "global-secret", "sed_user": "master"}); err != nil {
+		t.Fatalf("set_sed_password (global) failed: %v", err)
+	}
+	update := server.Calls("system.advanced.update")[0].Params[0].(map[string]interface{})
+	if update["sed_passwd"] != "global-secret" || update["sed_user"] != "MASTER" {
+		t.Errorf("system.advanced.update payload = %v", update)
+	}
+}
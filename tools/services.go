@@ -0,0 +1,404 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Service management
+
+const (
+	// serviceJobTimeout bounds how long a start, stop, or restart may take
+	serviceJobTimeout      = 60 * time.Second
+	serviceJobPollInterval = 500 * time.Millisecond
+)
+
+// serviceAliases maps common names to middleware service names
+var serviceAliases = map[string]string{
+	"smb":   "cifs",
+	"samba": "cifs",
+	"iscsi": "iscsitarget",
+}
+
+// serviceLabels are the names services are known by in the UI
+var serviceLabels = map[string]string{
+	"cifs":        "SMB",
+	"nfs":         "NFS",
+	"iscsitarget": "iSCSI",
+	"ssh":         "SSH",
+	"ftp":         "FTP",
+	"ups":         "UPS",
+	"snmp":        "SNMP",
+	"nvmet":       "NVMe-oF",
+}
+
+// serviceName resolves a service argument to the middleware name
+func serviceName(args map[string]interface{}) (string, error) {
+	name, _ := args["service"].(string)
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("service is required")
+	}
+	if alias, ok := serviceAliases[name]; ok {
+		name = alias
+	}
+	return name, nil
+}
+
+// serviceLabel returns the display name of a middleware service
+func serviceLabel(name string) string {
+	if label, ok := serviceLabels[name]; ok {
+		return label
+	}
+	return strings.ToUpper(name)
+}
+
+// simplifyService summarizes a service.query entry
+func simplifyService(service map[string]interface{}) map[string]interface{} {
+	name, _ := service["service"].(string)
+	summary := map[string]interface{}{
+		"service":       name,
+		"label":         serviceLabel(name),
+		"state":         service["state"],
+		"start_on_boot": service["enable"],
+	}
+	if pids, ok := service["pids"].([]interface{}); ok && len(pids) > 0 {
+		summary["pids"] = pids
+	}
+	return summary
+}
+
+// serviceUsage describes what depends on a running service: enabled shares
+// and connected clients, in one line each
+func serviceUsage(client truenas.Caller, name string) []string {
+	usage := []string{}
+	countRows := func(method string, params ...interface{}) int {
+		result, err := client.Call(method, params...)
+		if err != nil {
+			return 0
+		}
+		var rows []interface{}
+		if err := json.Unmarshal(result, &rows); err != nil {
+			return 0
+		}
+		return len(rows)
+	}
+	enabledShares := []interface{}{[]interface{}{"enabled", "=", true}}
+
+	switch name {
+	case "cifs":
+		if n := countRows("sharing.smb.query", enabledShares); n > 0 {
+			usage = append(usage, fmt.Sprintf("%d enabled SMB shares become unreachable", n))
+		}
+		if n := countRows("smb.status", "SESSIONS"); n > 0 {
+			usage = append(usage, fmt.Sprintf("%d connected SMB sessions are disconnected; open files may lose unsaved changes", n))
+		}
+	case "nfs":
+		if n := countRows("sharing.nfs.query", enabledShares); n > 0 {
+			usage = append(usage, fmt.Sprintf("%d enabled NFS exports become unreachable", n))
+		}
+		if n := countRows("nfs.get_nfs4_clients") + countRows("nfs.get_nfs3_clients"); n > 0 {
+			usage = append(usage, fmt.Sprintf("%d NFS clients have the exports mounted; their mounts hang until the service is back", n))
+		}
+	case "iscsitarget":
+		if n := countRows("iscsi.target.query"); n > 0 {
+			usage = append(usage, fmt.Sprintf("%d iSCSI targets become unreachable", n))
+		}
+		if n := countRows("iscsi.global.sessions"); n > 0 {
+			usage = append(usage, fmt.Sprintf("%d iSCSI sessions are dropped; initiators may see I/O errors or corrupt file systems on the LUNs", n))
+		}
+	case "ssh":
+		usage = append(usage, "SSH sessions are disconnected and remote shell access stops; make sure the web UI or console is reachable")
+	case "ups":
+		usage = append(usage, "The NAS no longer shuts down cleanly on power loss")
+	}
+	return usage
+}
+
+// serviceActionResult interprets the result of service.start/stop/restart,
+// which is a boolean on older releases and a job on newer ones
+func serviceActionResult(ctx context.Context, client truenas.Caller, result json.RawMessage) (bool, error) {
+	var ok bool
+	if err := json.Unmarshal(result, &ok); err == nil {
+		return ok, nil
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return false, err
+	}
+	job, err := waitForJobWithin(ctx, client, jobID, serviceJobPollInterval, serviceJobTimeout)
+	if err != nil {
+		return false, err
+	}
+	if ok, isBool := job["result"].(bool); isBool {
+		return ok, nil
+	}
+	return true, nil
+}
+
+func handleQueryServices(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	services, err := inventoryQuery(client, "service.query")
+	if err != nil {
+		return "", fmt.Errorf("failed to query services: %w", err)
+	}
+
+	stateFilter, _ := args["state"].(string)
+	stateFilter = strings.ToUpper(stateFilter)
+	summaries := make([]map[string]interface{}, 0, len(services))
+	running := 0
+	for _, service := range services {
+		if service["state"] == "RUNNING" {
+			running++
+		}
+		if stateFilter != "" && service["state"] != stateFilter {
+			continue
+		}
+		summaries = append(summaries, simplifyService(service))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i]["service"].(string) < summaries[j]["service"].(string)
+	})
+
+	// Services that run without starting on boot stop at the next reboot
+	notes := []string{}
+	for _, s := range summaries {
+		if s["state"] == "RUNNING" && s["start_on_boot"] == false {
+			notes = append(notes, fmt.Sprintf("%s is running but does not start on boot", s["label"]))
+		}
+	}
+	response := map[string]interface{}{
+		"services":       summaries,
+		"service_count":  len(summaries),
+		"running_count":  running,
+		"total_services": len(services),
+	}
+	if stateFilter != "" {
+		response["state_filter"] = stateFilter
+	}
+	if len(notes) > 0 {
+		response["notes"] = notes
+	}
+	return marshalJSON(response)
+}
+
+// serviceAction is a validated start, stop, or restart request
+type serviceAction struct {
+	action   string
+	name     string
+	service  map[string]interface{}
+	warnings []string
+}
+
+func planServiceAction(client truenas.Caller, action string, args map[string]interface{}) (*serviceAction, error) {
+	name, err := serviceName(args)
+	if err != nil {
+		return nil, err
+	}
+	service, err := getService(client, name)
+	if err != nil {
+		return nil, err
+	}
+	plan := &serviceAction{action: action, name: name, service: service}
+	state, _ := service["state"].(string)
+	label := serviceLabel(name)
+
+	switch action {
+	case "start":
+		if state == "RUNNING" {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s is already running", label))
+		}
+		if enable, _ := service["enable"].(bool); !enable {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s does not start on boot; use set_service_autostart to keep it running after a reboot", label))
+		}
+	case "stop":
+		if state != "RUNNING" {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s is not running (state %s)", label, state))
+			break
+		}
+		plan.warnings = append(plan.warnings, serviceUsage(client, name)...)
+		if enable, _ := service["enable"].(bool); enable {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s still starts on boot; use set_service_autostart enabled=false to keep it stopped", label))
+		}
+	case "restart":
+		if state == "RUNNING" {
+			for _, usage := range serviceUsage(client, name) {
+				plan.warnings = append(plan.warnings, "Briefly: "+usage)
+			}
+		}
+	}
+	return plan, nil
+}
+
+func runServiceAction(ctx context.Context, client truenas.Caller, action string, args map[string]interface{}) (string, error) {
+	plan, err := planServiceAction(client, action, args)
+	if err != nil {
+		return "", err
+	}
+	label := serviceLabel(plan.name)
+
+	result, err := client.Call("service."+action, plan.name, map[string]interface{}{"silent": false})
+	if err != nil {
+		return "", fmt.Errorf("failed to %s %s: %w", action, label, err)
+	}
+	ok, err := serviceActionResult(ctx, client, result)
+	if err != nil {
+		return "", fmt.Errorf("failed to %s %s: %w", action, label, err)
+	}
+
+	service, err := getService(client, plan.name)
+	if err != nil {
+		return "", err
+	}
+	response := simplifyService(service)
+	response["action"] = action
+	if len(plan.warnings) > 0 {
+		response["warnings"] = plan.warnings
+	}
+	wantRunning := action != "stop"
+	if !ok || (service["state"] == "RUNNING") != wantRunning {
+		response["succeeded"] = false
+		response["message"] = fmt.Sprintf("%s did not %s (state %v); check list_alerts and the service configuration", label, action, service["state"])
+		return marshalJSON(response)
+	}
+	response["succeeded"] = true
+	response["message"] = fmt.Sprintf("%s is %s", label, strings.ToLower(fmt.Sprint(service["state"])))
+	return marshalJSON(response)
+}
+
+func handleStartService(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return runServiceAction(ctx, client, "start", args)
+}
+
+func handleStopService(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return runServiceAction(ctx, client, "stop", args)
+}
+
+func handleRestartService(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return runServiceAction(ctx, client, "restart", args)
+}
+
+func handleSetServiceAutostart(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	name, err := serviceName(args)
+	if err != nil {
+		return "", err
+	}
+	enabled, ok := args["enabled"].(bool)
+	if !ok {
+		return "", fmt.Errorf("enabled is required")
+	}
+	if _, err := getService(client, name); err != nil {
+		return "", err
+	}
+	if _, err := client.Call("service.update", name, map[string]interface{}{"enable": enabled}); err != nil {
+		return "", fmt.Errorf("failed to set %s start on boot: %w", serviceLabel(name), err)
+	}
+
+	service, err := getService(client, name)
+	if err != nil {
+		return "", err
+	}
+	response := simplifyService(service)
+	if enabled {
+		response["message"] = fmt.Sprintf("%s starts on boot", serviceLabel(name))
+		if service["state"] != "RUNNING" {
+			response["note"] = "Start on boot does not start it now; use start_service"
+		}
+	} else {
+		response["message"] = fmt.Sprintf("%s no longer starts on boot", serviceLabel(name))
+		if service["state"] == "RUNNING" {
+			response["note"] = "It keeps running until stopped; use stop_service to stop it now"
+		}
+	}
+	return marshalJSON(response)
+}
+
+// Dry-run wrappers
+
+func handleStartServiceWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &serviceActionDryRun{action: "start"}, handleStartService)
+}
+
+func handleStopServiceWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &serviceActionDryRun{action: "stop"}, handleStopService)
+}
+
+func handleRestartServiceWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &serviceActionDryRun{action: "restart"}, handleRestartService)
+}
+
+func handleSetServiceAutostartWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &setServiceAutostartDryRun{}, handleSetServiceAutostart)
+}
+
+// Dry-run implementations
+
+type serviceActionDryRun struct {
+	action string
+}
+
+func (d *serviceActionDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planServiceAction(client, d.action, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DryRunResult{
+		Tool:         d.action + "_service",
+		CurrentState: simplifyService(plan.service),
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("%s the %s service", strings.ToUpper(d.action[:1])+d.action[1:], serviceLabel(plan.name)),
+				Operation:   d.action,
+				Target:      plan.name,
+			},
+		},
+		Warnings: plan.warnings,
+	}, nil
+}
+
+type setServiceAutostartDryRun struct{}
+
+func (d *setServiceAutostartDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	name, err := serviceName(args)
+	if err != nil {
+		return nil, err
+	}
+	enabled, ok := args["enabled"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("enabled is required")
+	}
+	service, err := getService(client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Start %s on boot", serviceLabel(name))
+	if !enabled {
+		description = fmt.Sprintf("Do not start %s on boot", serviceLabel(name))
+	}
+	warnings := []string{}
+	if current, _ := service["enable"].(bool); current == enabled {
+		warnings = append(warnings, "Start on boot is already set that way")
+	}
+	return &DryRunResult{
+		Tool:         "set_service_autostart",
+		CurrentState: simplifyService(service),
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: description,
+				Operation:   "update",
+				Target:      name,
+				Details:     map[string]interface{}{"enable": enabled},
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationServiceManagement(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	services := func(nfsState string) []map[string]interface{} {
+		return []map[string]interface{}{
+			{"service": "cifs", "state": "RUNNING", "enable": true},
+			{"service": "nfs", "state": nfsState, "enable": false},
+			{"service": "ssh", "state": "STOPPED", "enable": false},
+		}
+	}
+	server.SetRecords("service.query", services("STOPPED"))
+	server.SetRecords("sharing.smb.query", []map[string]interface{}{
+		{"id": float64(1), "name": "docs", "enabled": true},
+		{"id": float64(2), "name": "media", "enabled": true},
+		{"id": float64(3), "name": "old", "enabled": false},
+	})
+	server.SetResult("smb.status", []map[string]interface{}{{"session_id": "1", "username": "alice"}})
+
+	result, err := registry.CallTool("query_services", map[string]interface{}{"state": "stopped"})
+	if err != nil {
+		t.Fatalf("query_services failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["service_count"] != float64(2) || response["running_count"] != float64(1) {
+		t.Errorf("unexpected service counts:\n%s", result)
+	}
+
+	// Newer middleware runs service.start as a job
+	server.Handle("service.start", func(params []interface{}) (interface{}, error) {
+		if params[0] != "nfs" {
+			t.Errorf("service.start(%v), want nfs", params[0])
+		}
+		server.SetRecords("service.query", services("RUNNING"))
+		return float64(server.AddJob("service.start", params, truenastest.JobSpec{Result: true})), nil
+	})
+	result, err = registry.CallTool("start_service", map[string]interface{}{"service": "NFS"})
+	if err != nil {
+		t.Fatalf("start_service failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["succeeded"] != true || response["state"] != "RUNNING" {
+		t.Errorf("start_service result:\n%s", result)
+	}
+	if !strings.Contains(result, "set_service_autostart") {
+		t.Errorf("missing start-on-boot warning:\n%s", result)
+	}
+
+	result, err = registry.CallTool("stop_service", map[string]interface{}{"service": "smb", "dry_run": true})
+	if err != nil {
+		t.Fatalf("stop_service dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "2 enabled SMB shares") || !strings.Contains(result, "1 connected SMB sessions") {
+		t.Errorf("dry run missing share warnings:\n%s", result)
+	}
+	if n := len(server.Calls("service.stop")); n != 0 {
+		t.Errorf("dry run called service.stop %d times", n)
+	}
+
+	// A stop that leaves the service running is reported, not hidden
+	server.SetResult("service.stop", false)
+	result, err = registry.CallTool("stop_service", map[string]interface{}{"service": "smb"})
+	if err != nil {
+		t.Fatalf("stop_service failed: %v", err)
+	}
+	if decodeResult(t, result)["succeeded"] != false {
+		t.Errorf("stop_service should report failure:\n%s", result)
+	}
+
+	server.SetResult("service.update", map[string]interface{}{})
+	if _, err := registry.CallTool("set_service_autostart", map[string]interface{}{"service": "nfs", "enabled": true}); err != nil {
+		t.Fatalf("set_service_autostart failed: %v", err)
+	}
+	calls := server.Calls("service.update")
+	if len(calls) != 1 || calls[0].Params[0] != "nfs" || fmt.Sprint(calls[0].Params[1]) != "map[enable:true]" {
+		t.Errorf("unexpected service.update calls: %v", calls)
+	}
+
+	if _, err := registry.CallTool("start_service", map[string]interface{}{"service": "gopher"}); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown service error = %v, want NOT_FOUND", err)
+	}
+}
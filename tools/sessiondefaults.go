@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Session defaults
+
+// sessionDefaultArgs are the arguments set_session_defaults can default,
+// most specific first within a scope
+var sessionDefaultArgs = []string{"dataset", "pool", "app_name", "vm"}
+
+// sessionDefaultScopes groups arguments that name the same thing at
+// different levels. A call gets at most one default per scope, and none when
+// it already names something in that scope (a dataset implies its pool).
+var sessionDefaultScopes = map[string]string{
+	"dataset":  "storage",
+	"pool":     "storage",
+	"app_name": "app",
+	"vm":       "vm",
+}
+
+// sessionDefaultExclusions lists tools where an argument means something
+// other than an existing object (e.g., the name of an app to install)
+var sessionDefaultExclusions = map[string]map[string]bool{
+	"app_name": {
+		"install_app":             true,
+		"install_from_template":   true,
+		"get_app_catalog_details": true,
+		"refresh_catalog_cache":   true,
+	},
+}
+
+// sessionDefaults holds the defaults of each MCP session by session ID
+type sessionDefaults struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]string
+}
+
+func newSessionDefaults() *sessionDefaults {
+	return &sessionDefaults{sessions: make(map[string]map[string]string)}
+}
+
+// get returns a copy of a session's defaults
+func (d *sessionDefaults) get(sessionID string) map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defaults := make(map[string]string, len(d.sessions[sessionID]))
+	for arg, value := range d.sessions[sessionID] {
+		defaults[arg] = value
+	}
+	return defaults
+}
+
+// set replaces a session's defaults
+func (d *sessionDefaults) set(sessionID string, defaults map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(defaults) == 0 {
+		delete(d.sessions, sessionID)
+		return
+	}
+	d.sessions[sessionID] = defaults
+}
+
+// EndSession discards the defaults of a closed MCP session
+func (r *Registry) EndSession(sessionID string) {
+	r.sessionDefaults.set(sessionID, nil)
+}
+
+// sessionDefaultsFor returns the defaults a call of tool would receive,
+// given the arguments it already has
+func sessionDefaultsFor(name string, tool Tool, args map[string]interface{}, defaults map[string]string) map[string]string {
+	// Deletions always name their target explicitly
+	if strings.HasPrefix(name, "delete_") || name == "set_session_defaults" {
+		return nil
+	}
+	props, _ := tool.Definition.InputSchema["properties"].(map[string]interface{})
+	named := map[string]bool{}
+	for _, arg := range sessionDefaultArgs {
+		if value, ok := args[arg]; ok && value != nil && value != "" {
+			named[sessionDefaultScopes[arg]] = true
+		}
+	}
+
+	applied := map[string]string{}
+	for _, arg := range sessionDefaultArgs {
+		scope := sessionDefaultScopes[arg]
+		value := defaults[arg]
+		if _, accepted := props[arg]; !accepted || value == "" || named[scope] || sessionDefaultExclusions[arg][name] {
+			continue
+		}
+		applied[arg] = value
+		named[scope] = true
+	}
+	return applied
+}
+
+// applySessionDefaults returns args with the session's defaults filled in
+// for arguments the call omits. The caller's map is not modified.
+func (r *Registry) applySessionDefaults(sessionID, correlationID, name string, tool Tool, args map[string]interface{}) map[string]interface{} {
+	applied := sessionDefaultsFor(name, tool, args, r.sessionDefaults.get(sessionID))
+	if len(applied) == 0 {
+		return args
+	}
+	merged := make(map[string]interface{}, len(args)+len(applied))
+	for key, value := range args {
+		merged[key] = value
+	}
+	names := make([]string, 0, len(applied))
+	for arg, value := range applied {
+		merged[arg] = value
+		names = append(names, fmt.Sprintf("%s=%s", arg, value))
+	}
+	sort.Strings(names)
+	slog.Info("Session defaults applied", "correlation_id", correlationID, "tool", name, "arguments", strings.Join(names, ", "))
+	return merged
+}
+
+func (r *Registry) handleSetSessionDefaults(ctx context.Context, sessionID string, client truenas.Caller, args map[string]interface{}) (string, error) {
+	defaults := r.sessionDefaults.get(sessionID)
+	if clear, _ := args["clear"].(bool); clear {
+		defaults = map[string]string{}
+	}
+
+	for _, arg := range sessionDefaultArgs {
+		raw, ok := args[arg]
+		if !ok {
+			continue
+		}
+		value, ok := raw.(string)
+		if !ok {
+			return "", newToolError(ErrorValidation, "%s must be a string", arg)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			delete(defaults, arg)
+			continue
+		}
+		if err := validateSessionDefault(client, arg, value); err != nil {
+			return "", err
+		}
+		defaults[arg] = value
+	}
+	r.sessionDefaults.set(sessionID, defaults)
+
+	warnings := []string{}
+	if pool, dataset := defaults["pool"], defaults["dataset"]; pool != "" && dataset != "" && !strings.HasPrefix(dataset+"/", pool+"/") {
+		warnings = append(warnings, fmt.Sprintf("Default dataset %s is not in default pool %s; tools accepting both use the dataset", dataset, pool))
+	}
+
+	// Which tools each default reaches when the argument is omitted
+	appliesTo := map[string][]string{}
+	for arg, value := range defaults {
+		only := map[string]string{arg: value}
+		tools := []string{}
+		for name, tool := range r.tools {
+			if _, ok := sessionDefaultsFor(name, tool, nil, only)[arg]; ok {
+				tools = append(tools, name)
+			}
+		}
+		sort.Strings(tools)
+		appliesTo[arg] = tools
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"session_defaults": defaults,
+		"applies_to":       appliesTo,
+		"warnings":         warnings,
+		"message":          "Defaults fill these arguments when a tool call omits them, for the rest of this session. Explicit arguments always win; delete tools never use defaults.",
+	})
+}
+
+// validateSessionDefault checks that a default names an existing object
+func validateSessionDefault(client truenas.Caller, arg, value string) error {
+	switch arg {
+	case "pool":
+		pools, err := inventoryQuery(client, "pool.query", []interface{}{[]interface{}{"name", "=", value}})
+		if err != nil {
+			return fmt.Errorf("failed to query pools: %w", err)
+		}
+		if len(pools) == 0 {
+			return newToolError(ErrorNotFound, "pool %s not found", value)
+		}
+	case "dataset":
+		if err := validateDatasetName(value); err != nil {
+			return newToolError(ErrorValidation, "%v", err)
+		}
+		datasets, err := inventoryQuery(client, "pool.dataset.query", []interface{}{[]interface{}{"id", "=", value}})
+		if err != nil {
+			return fmt.Errorf("failed to query datasets: %w", err)
+		}
+		if len(datasets) == 0 {
+			return newToolError(ErrorNotFound, "dataset %s not found", value)
+		}
+	case "app_name":
+		if _, err := getApp(client, value); err != nil {
+			return err
+		}
+	case "vm":
+		if _, err := getVM(client, map[string]interface{}{"vm": value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
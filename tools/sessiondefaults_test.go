@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationSessionDefaults(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{
+		{"name": "tank", "status": "ONLINE", "topology": map[string]interface{}{}},
+		{"name": "backup", "status": "ONLINE", "topology": map[string]interface{}{}},
+	})
+	lastPool := func() string {
+		calls := server.Calls("pool.query")
+		return fmt.Sprint(calls[len(calls)-1].Params)
+	}
+
+	_, err := registry.CallToolInSession(context.Background(), "s1", "corr", "set_session_defaults", map[string]interface{}{"pool": "missing"})
+	if ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("default pool missing error = %v, want NOT_FOUND", err)
+	}
+	result, err := registry.CallToolInSession(context.Background(), "s1", "corr", "set_session_defaults", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("set_session_defaults failed: %v", err)
+	}
+	appliesTo := decodeResult(t, result)["applies_to"].(map[string]interface{})
+	if tools := fmt.Sprint(appliesTo["pool"]); !strings.Contains(tools, "get_pool_topology") || strings.Contains(tools, "delete_") {
+		t.Errorf("pool default applies to %s", tools)
+	}
+
+	// Omitted pool comes from the session; explicit arguments win
+	if _, err := registry.CallToolInSession(context.Background(), "s1", "corr", "get_pool_topology", map[string]interface{}{}); err != nil {
+		t.Fatalf("get_pool_topology with default pool failed: %v", err)
+	}
+	if !strings.Contains(lastPool(), "tank") {
+		t.Errorf("pool.query params = %s, want the default pool tank", lastPool())
+	}
+	if _, err := registry.CallToolInSession(context.Background(), "s1", "corr", "get_pool_topology", map[string]interface{}{"pool": "backup"}); err != nil || !strings.Contains(lastPool(), "backup") {
+		t.Errorf("explicit pool: err %v, pool.query params %s", err, lastPool())
+	}
+
+	// Other sessions and ended sessions have no defaults
+	if _, err := registry.CallToolInSession(context.Background(), "s2", "corr", "get_pool_topology", map[string]interface{}{}); err == nil {
+		t.Error("another session used s1's default pool")
+	}
+	registry.EndSession("s1")
+	if _, err := registry.CallToolInSession(context.Background(), "s1", "corr", "get_pool_topology", map[string]interface{}{}); err == nil {
+		t.Error("default pool survived EndSession")
+	}
+
+	// A named dataset implies its pool
+	applied := sessionDefaultsFor("query_snapshots", registry.tools["query_snapshots"], map[string]interface{}{"dataset": "backup/db"}, map[string]string{"pool": "tank"})
+	if len(applied) != 0 {
+		t.Errorf("query_snapshots with a dataset got defaults %v", applied)
+	}
+}
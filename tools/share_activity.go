@@ -0,0 +1,403 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Per-share and per-client activity attribution for SMB and NFS
+
+// shareActivity accumulates activity indicators for one share
+type shareActivity struct {
+	Protocol  string
+	Name      string
+	Path      string
+	OpenFiles int
+	Clients   map[string]*clientActivity
+}
+
+// clientActivity accumulates activity indicators for one client of a share
+type clientActivity struct {
+	Address   string
+	User      string
+	OpenFiles int
+}
+
+// weight scores a share's activity: every open file counts, and every
+// connected client counts once even when idle
+func (s *shareActivity) weight() int {
+	return s.OpenFiles + len(s.Clients)
+}
+
+func (s *shareActivity) client(address string) *clientActivity {
+	if c, ok := s.Clients[address]; ok {
+		return c
+	}
+	c := &clientActivity{Address: address}
+	s.Clients[address] = c
+	return c
+}
+
+// shareActivitySet indexes shares by protocol key and resolves file paths to shares
+type shareActivitySet struct {
+	shares map[string]*shareActivity
+}
+
+func (set *shareActivitySet) add(protocol, name, path string) *shareActivity {
+	key := protocol + ":" + name
+	if share, ok := set.shares[key]; ok {
+		return share
+	}
+	share := &shareActivity{Protocol: protocol, Name: name, Path: path, Clients: map[string]*clientActivity{}}
+	set.shares[key] = share
+	return share
+}
+
+func (set *shareActivitySet) get(protocol, name string) *shareActivity {
+	return set.shares[protocol+":"+name]
+}
+
+// byPath returns the share of the given protocol with the longest path prefix
+// containing filePath
+func (set *shareActivitySet) byPath(protocol, filePath string) *shareActivity {
+	var best *shareActivity
+	for _, share := range set.shares {
+		if share.Protocol != protocol || share.Path == "" {
+			continue
+		}
+		if filePath != share.Path && !strings.HasPrefix(filePath, strings.TrimRight(share.Path, "/")+"/") {
+			continue
+		}
+		if best == nil || len(share.Path) > len(best.Path) {
+			best = share
+		}
+	}
+	return best
+}
+
+// nfsClientAddress strips the port from an NFSv4 client address ("10.0.0.5:798")
+func nfsClientAddress(address string) string {
+	if idx := strings.LastIndex(address, ":"); idx != -1 && strings.Count(address, ":") == 1 {
+		return address[:idx]
+	}
+	return address
+}
+
+// collectSMBActivity adds SMB tree connects and open files to the set
+func collectSMBActivity(client truenas.Caller, set *shareActivitySet) error {
+	sharesResult, err := client.Call("smb.status", "SHARES")
+	if err != nil {
+		return fmt.Errorf("SMB connections unavailable: %w", err)
+	}
+	var tcons []map[string]interface{}
+	if err := json.Unmarshal(sharesResult, &tcons); err != nil {
+		return fmt.Errorf("failed to parse SMB connections: %w", err)
+	}
+
+	// Sessions name the user of a connection; their smbd process ID ties open
+	// files back to the client machine
+	users := map[string]string{}
+	machines := map[string]string{}
+	if sessionsResult, err := client.Call("smb.status", "SESSIONS"); err == nil {
+		var sessions []map[string]interface{}
+		if err := json.Unmarshal(sessionsResult, &sessions); err == nil {
+			for _, session := range sessions {
+				username, _ := session["username"].(string)
+				users[fmt.Sprint(session["session_id"])] = username
+				if pid := smbServerPID(session); pid != "" {
+					machines[pid], _ = session["remote_machine"].(string)
+				}
+			}
+		}
+	}
+
+	for _, tcon := range tcons {
+		service, _ := tcon["service"].(string)
+		if service == "" || service == "IPC$" {
+			continue
+		}
+		share := set.get("smb", service)
+		if share == nil {
+			share = set.add("smb", service, "")
+		}
+		machine, _ := tcon["machine"].(string)
+		c := share.client(machine)
+		if user := users[fmt.Sprint(tcon["session_id"])]; user != "" {
+			c.User = user
+		}
+	}
+
+	if locksResult, err := client.Call("smb.status", "LOCKS"); err == nil {
+		var openFiles []map[string]interface{}
+		if err := json.Unmarshal(locksResult, &openFiles); err == nil {
+			for _, file := range openFiles {
+				servicePath, _ := file["service_path"].(string)
+				share := set.byPath("smb", servicePath)
+				if share == nil {
+					continue
+				}
+				opens, _ := file["opens"].(map[string]interface{})
+				if len(opens) == 0 {
+					share.OpenFiles++
+					continue
+				}
+				for _, raw := range opens {
+					share.OpenFiles++
+					open, _ := raw.(map[string]interface{})
+					if machine := machines[smbServerPID(open)]; machine != "" {
+						share.client(machine).OpenFiles++
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// smbServerPID returns the smbd process ID of an SMB status entry
+func smbServerPID(entry map[string]interface{}) string {
+	serverID, _ := entry["server_id"].(map[string]interface{})
+	if pid, ok := serverID["pid"]; ok && pid != nil {
+		return fmt.Sprint(pid)
+	}
+	return ""
+}
+
+// collectNFSActivity adds NFSv4 client open state and NFSv3 mounts to the set
+func collectNFSActivity(client truenas.Caller, set *shareActivitySet) []string {
+	notes := []string{}
+
+	if result, err := client.Call("nfs.get_nfs4_clients"); err == nil {
+		var clients []map[string]interface{}
+		if err := json.Unmarshal(result, &clients); err == nil {
+			for _, entry := range clients {
+				info, _ := entry["info"].(map[string]interface{})
+				address, _ := info["address"].(string)
+				address = nfsClientAddress(address)
+
+				states, _ := entry["states"].([]interface{})
+				for _, raw := range states {
+					state, _ := raw.(map[string]interface{})
+					filename, _ := state["filename"].(string)
+					share := set.byPath("nfs", filename)
+					if share == nil {
+						continue
+					}
+					share.OpenFiles++
+					share.client(address).OpenFiles++
+				}
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("NFSv4 clients unavailable: %v", err))
+	}
+
+	if result, err := client.Call("nfs.get_nfs3_clients"); err == nil {
+		var mounts []map[string]interface{}
+		if err := json.Unmarshal(result, &mounts); err == nil {
+			for _, mount := range mounts {
+				ip, _ := mount["ip"].(string)
+				export, _ := mount["export"].(string)
+				if share := set.byPath("nfs", export); share != nil {
+					share.client(ip)
+				}
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("NFSv3 clients unavailable: %v", err))
+	}
+
+	return notes
+}
+
+// latestReportingTotals sums the most recent sample of every identifier of a
+// reporting graph, keyed by legend (e.g. received/sent, read/write)
+func latestReportingTotals(client truenas.Caller, graph string, identifiers []string) map[string]float64 {
+	totals := map[string]float64{}
+	q := metricsQuery{Unit: "HOUR", MaxPoints: math.MaxInt32, Factor: 1, Aggregation: "mean"}
+
+	for _, identifier := range identifiers {
+		summaries, err := fetchReportingGraph(client, graph, identifier, q)
+		if err != nil {
+			continue
+		}
+		for _, summary := range summaries {
+			legend, _ := summary["legend"].([]interface{})
+			rows, _ := summary["data"].([]interface{})
+			for i := len(rows) - 1; i >= 0; i-- {
+				row, ok := rows[i].([]interface{})
+				if !ok || len(row) < 2 || row[1] == nil {
+					continue
+				}
+				for col := 1; col < len(row) && col < len(legend); col++ {
+					name, _ := legend[col].(string)
+					if v, ok := row[col].(float64); ok {
+						totals[name] += v
+					}
+				}
+				break
+			}
+		}
+	}
+	return totals
+}
+
+// reportingIdentifiers returns the identifiers of each named graph
+func reportingIdentifiers(client truenas.Caller, names ...string) (map[string][]string, error) {
+	result, err := client.Call("reporting.graphs")
+	if err != nil {
+		return nil, err
+	}
+	var graphs []map[string]interface{}
+	if err := json.Unmarshal(result, &graphs); err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	identifiers := map[string][]string{}
+	for _, graph := range graphs {
+		name, _ := graph["name"].(string)
+		if !wanted[name] {
+			continue
+		}
+		list, _ := graph["identifiers"].([]interface{})
+		for _, id := range list {
+			if s, ok := id.(string); ok {
+				identifiers[name] = append(identifiers[name], s)
+			}
+		}
+	}
+	return identifiers, nil
+}
+
+// attributeShareLoad ranks shares by activity weight and assigns each its
+// proportional share of the total
+func attributeShareLoad(shares []*shareActivity) []map[string]interface{} {
+	total := 0
+	for _, share := range shares {
+		total += share.weight()
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].weight() != shares[j].weight() {
+			return shares[i].weight() > shares[j].weight()
+		}
+		return shares[i].Protocol+shares[i].Name < shares[j].Protocol+shares[j].Name
+	})
+
+	ranked := []map[string]interface{}{}
+	for _, share := range shares {
+		if share.weight() == 0 {
+			continue
+		}
+
+		clients := make([]map[string]interface{}, 0, len(share.Clients))
+		for _, c := range share.Clients {
+			entry := map[string]interface{}{
+				"address":    c.Address,
+				"open_files": c.OpenFiles,
+			}
+			if c.User != "" {
+				entry["user"] = c.User
+			}
+			clients = append(clients, entry)
+		}
+		sort.Slice(clients, func(i, j int) bool {
+			if clients[i]["open_files"].(int) != clients[j]["open_files"].(int) {
+				return clients[i]["open_files"].(int) > clients[j]["open_files"].(int)
+			}
+			return clients[i]["address"].(string) < clients[j]["address"].(string)
+		})
+
+		entry := map[string]interface{}{
+			"protocol":       share.Protocol,
+			"share":          share.Name,
+			"open_files":     share.OpenFiles,
+			"client_count":   len(share.Clients),
+			"clients":        clients,
+			"activity_share": math.Round(float64(share.weight())/float64(total)*1000) / 10,
+		}
+		if share.Path != "" {
+			entry["path"] = share.Path
+		}
+		ranked = append(ranked, entry)
+	}
+	return ranked
+}
+
+func handleGetShareActivity(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	set := &shareActivitySet{shares: map[string]*shareActivity{}}
+	notes := []string{}
+
+	if result, err := client.Call("sharing.smb.query"); err == nil {
+		var shares []map[string]interface{}
+		if err := json.Unmarshal(result, &shares); err == nil {
+			for _, share := range shares {
+				name, _ := share["name"].(string)
+				path, _ := share["path"].(string)
+				set.add("smb", name, path)
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("SMB shares unavailable: %v", err))
+	}
+
+	if result, err := client.Call("sharing.nfs.query"); err == nil {
+		var shares []map[string]interface{}
+		if err := json.Unmarshal(result, &shares); err == nil {
+			for _, share := range shares {
+				path, _ := share["path"].(string)
+				set.add("nfs", path, path)
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("NFS shares unavailable: %v", err))
+	}
+
+	if err := collectSMBActivity(client, set); err != nil {
+		notes = append(notes, err.Error())
+	}
+	notes = append(notes, collectNFSActivity(client, set)...)
+
+	shares := make([]*shareActivity, 0, len(set.shares))
+	for _, share := range set.shares {
+		shares = append(shares, share)
+	}
+	ranked := attributeShareLoad(shares)
+
+	response := map[string]interface{}{
+		"shares": ranked,
+		"method": "activity_share is each share's percent of open files plus connected clients across all shares. SMB and NFS do not report per-share throughput, so apply it to the current load as an estimate.",
+	}
+
+	// Current system load the activity is attributed against
+	if identifiers, err := reportingIdentifiers(client, "interface", "disk"); err == nil {
+		response["current_load"] = map[string]interface{}{
+			"network": latestReportingTotals(client, "interface", identifiers["interface"]),
+			"disk":    latestReportingTotals(client, "disk", identifiers["disk"]),
+			"note":    "Latest sample summed across interfaces and disks; units as in list_reporting_graphs",
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("current load unavailable: %v", err))
+	}
+
+	if len(ranked) == 0 {
+		response["message"] = "No SMB or NFS clients are connected"
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+
+	return marshalJSON(response)
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIntegrationGetShareActivity(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("sharing.smb.query", []interface{}{
+		map[string]interface{}{"name": "projects", "path": "/mnt/tank/projects"},
+		map[string]interface{}{"name": "archive", "path": "/mnt/tank/archive"},
+	})
+	server.SetResult("sharing.nfs.query", []interface{}{
+		map[string]interface{}{"path": "/mnt/tank/vmstore"},
+	})
+	server.Handle("smb.status", func(params []interface{}) (interface{}, error) {
+		switch params[0] {
+		case "SESSIONS":
+			return []interface{}{
+				map[string]interface{}{"session_id": "1", "username": "alice", "remote_machine": "10.0.0.21", "server_id": map[string]interface{}{"pid": "100"}},
+			}, nil
+		case "SHARES":
+			return []interface{}{
+				map[string]interface{}{"service": "projects", "machine": "10.0.0.21", "session_id": "1"},
+				map[string]interface{}{"service": "IPC$", "machine": "10.0.0.21", "session_id": "1"},
+			}, nil
+		case "LOCKS":
+			return []interface{}{
+				map[string]interface{}{
+					"service_path": "/mnt/tank/projects",
+					"filename":     "plan.docx",
+					"opens": map[string]interface{}{
+						"100/1": map[string]interface{}{"server_id": map[string]interface{}{"pid": "100"}},
+						"100/2": map[string]interface{}{"server_id": map[string]interface{}{"pid": "100"}},
+					},
+				},
+			}, nil
+		}
+		return nil, fmt.Errorf("unexpected info level %v", params[0])
+	})
+	server.SetResult("nfs.get_nfs4_clients", []interface{}{
+		map[string]interface{}{
+			"info": map[string]interface{}{"address": "10.0.0.50:801"},
+			"states": []interface{}{
+				map[string]interface{}{"type": "open", "filename": "/mnt/tank/vmstore/vm1.img"},
+				map[string]interface{}{"type": "open", "filename": "/mnt/tank/vmstore/vm2.img"},
+				map[string]interface{}{"type": "open", "filename": "/mnt/tank/vmstore/vm3.img"},
+			},
+		},
+	})
+	server.SetResult("nfs.get_nfs3_clients", []interface{}{})
+	server.SetResult("reporting.graphs", []interface{}{
+		map[string]interface{}{"name": "interface", "identifiers": []interface{}{"eno1"}},
+	})
+	server.SetResult("reporting.get_data", []interface{}{
+		map[string]interface{}{
+			"name":   "interface",
+			"legend": []interface{}{"time", "received", "sent"},
+			"data":   []interface{}{[]interface{}{1.0, 100.0, 50.0}, []interface{}{2.0, 300.0, 80.0}, []interface{}{3.0, nil, nil}},
+		},
+	})
+
+	result, err := registry.CallTool("get_share_activity", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_share_activity failed: %v", err)
+	}
+	response := decodeResult(t, result)
+
+	shares, _ := response["shares"].([]interface{})
+	if len(shares) != 2 {
+		t.Fatalf("shares = %v, want vmstore and projects (archive idle)", response["shares"])
+	}
+	top := shares[0].(map[string]interface{})
+	if top["share"] != "/mnt/tank/vmstore" || top["open_files"] != float64(3) || top["activity_share"] != float64(57.1) {
+		t.Errorf("top share = %v, want vmstore with 3 open files and 57.1%%", top)
+	}
+	smb := shares[1].(map[string]interface{})
+	clients, _ := smb["clients"].([]interface{})
+	if len(clients) != 1 {
+		t.Fatalf("projects clients = %v, want one", smb["clients"])
+	}
+	if c := clients[0].(map[string]interface{}); c["user"] != "alice" || c["open_files"] != float64(2) {
+		t.Errorf("projects client = %v, want alice with 2 open files", c)
+	}
+
+	load, _ := response["current_load"].(map[string]interface{})
+	network, _ := load["network"].(map[string]interface{})
+	if network["received"] != float64(300) || network["sent"] != float64(80) {
+		t.Errorf("network load = %v, want latest non-null sample", network)
+	}
+}
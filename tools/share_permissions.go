@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Ownership and ACL setup applied to a share's path after creation
+
+// sharePermissions holds the optional owner, group, and ACL preset requested
+// alongside a share
+type sharePermissions struct {
+	Owner     string
+	Group     string
+	ACLPreset string
+}
+
+// parseSharePermissions reads owner, group, and acl_preset from tool arguments.
+// It returns nil when none were requested.
+func parseSharePermissions(args map[string]interface{}) *sharePermissions {
+	owner, _ := args["owner"].(string)
+	group, _ := args["group"].(string)
+	aclPreset, _ := args["acl_preset"].(string)
+
+	perms := &sharePermissions{
+		Owner:     strings.TrimSpace(owner),
+		Group:     strings.TrimSpace(group),
+		ACLPreset: strings.TrimSpace(aclPreset),
+	}
+	if perms.Owner == "" && perms.Group == "" && perms.ACLPreset == "" {
+		return nil
+	}
+	return perms
+}
+
+// method returns the filesystem method used to apply the permissions
+func (p *sharePermissions) method() string {
+	if p.ACLPreset != "" {
+		return "filesystem.setacl"
+	}
+	return "filesystem.chown"
+}
+
+// describe summarizes the permission change for dry-run previews and responses
+func (p *sharePermissions) describe(path string) map[string]interface{} {
+	summary := map[string]interface{}{
+		"path":   path,
+		"method": p.method(),
+	}
+	if p.Owner != "" {
+		summary["owner"] = p.Owner
+	}
+	if p.Group != "" {
+		summary["group"] = p.Group
+	}
+	if p.ACLPreset != "" {
+		summary["acl_preset"] = p.ACLPreset
+	}
+	return summary
+}
+
+// applySharePermissions sets ownership and, if requested, an ACL preset on path.
+// Both middleware methods run as jobs; the returned summary carries the job ID.
+func applySharePermissions(client truenas.Caller, path string, perms *sharePermissions) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"path": path,
+		"options": map[string]interface{}{
+			"recursive": true,
+			"traverse":  false,
+		},
+	}
+	if perms.Owner != "" {
+		payload["user"] = perms.Owner
+	}
+	if perms.Group != "" {
+		payload["group"] = perms.Group
+	}
+
+	if perms.ACLPreset != "" {
+		template, err := findACLTemplate(client, path, perms.ACLPreset)
+		if err != nil {
+			return nil, err
+		}
+		payload["dacl"] = template["acl"]
+		payload["acltype"] = template["acltype"]
+	}
+
+	result, err := client.Call(perms.method(), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply permissions to %s: %w", path, err)
+	}
+
+	summary := perms.describe(path)
+	var jobID float64
+	if err := json.Unmarshal(result, &jobID); err == nil {
+		summary["job_id"] = int(jobID)
+	}
+	return summary, nil
+}
+
+// findACLTemplate returns the ACL template named preset, rendered for path
+func findACLTemplate(client truenas.Caller, path, preset string) (map[string]interface{}, error) {
+	result, err := client.Call("filesystem.acltemplate.by_path", map[string]interface{}{
+		"path": path,
+		"format-options": map[string]interface{}{
+			"canonicalize":  true,
+			"resolve_names": true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ACL templates: %w", err)
+	}
+
+	var templates []map[string]interface{}
+	if err := json.Unmarshal(result, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse ACL templates: %w", err)
+	}
+
+	names := make([]string, 0, len(templates))
+	for _, template := range templates {
+		name, _ := template["name"].(string)
+		if strings.EqualFold(name, preset) {
+			return template, nil
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return nil, fmt.Errorf("ACL preset %q is not available for %s (available: %s)", preset, path, strings.Join(names, ", "))
+}
@@ -0,0 +1,454 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Periodic SMART test schedules (smart.test)
+
+// smartScheduleTypes are the self-tests a schedule can run; OFFLINE is an
+// immediate offline data collection that only a schedule can start
+var smartScheduleTypes = map[string]bool{"SHORT": true, "LONG": true, "CONVEYANCE": true, "OFFLINE": true}
+
+// smartScheduleCron returns a SMART schedule as a cron schedule. SMART
+// schedules have no minute; smartd starts tests within the scheduled hour.
+func smartScheduleCron(schedule map[string]interface{}) map[string]interface{} {
+	cron := map[string]interface{}{"minute": "00"}
+	for _, field := range []string{"hour", "dom", "month", "dow"} {
+		cron[field] = schedule[field]
+	}
+	return cron
+}
+
+// diskNamesByIdentifier maps disk identifiers (as stored in SMART schedules)
+// and names to disk names
+func diskNamesByIdentifier(disks []map[string]interface{}) map[string]string {
+	names := map[string]string{}
+	for _, disk := range disks {
+		name, _ := disk["name"].(string)
+		if identifier, ok := disk["identifier"].(string); ok && identifier != "" {
+			names[identifier] = name
+		}
+		names[name] = name
+	}
+	return names
+}
+
+// smartScheduleDisks returns the names of the disks a schedule tests
+func smartScheduleDisks(schedule map[string]interface{}, names map[string]string) []string {
+	disks := []string{}
+	if all, _ := schedule["all_disks"].(bool); all {
+		for identifier, name := range names {
+			if identifier == name {
+				disks = append(disks, name)
+			}
+		}
+	} else {
+		list, _ := schedule["disks"].([]interface{})
+		for _, d := range list {
+			id, _ := d.(string)
+			if name, ok := names[id]; ok {
+				disks = append(disks, name)
+			} else {
+				// Disks removed since the schedule was made keep their identifier
+				disks = append(disks, id)
+			}
+		}
+	}
+	sort.Strings(disks)
+	return disks
+}
+
+func simplifySMARTSchedule(schedule map[string]interface{}, names map[string]string, clock nasClock) map[string]interface{} {
+	scheduleObj, _ := schedule["schedule"].(map[string]interface{})
+	cron := smartScheduleCron(scheduleObj)
+	allDisks, _ := schedule["all_disks"].(bool)
+
+	return map[string]interface{}{
+		"id":             schedule["id"],
+		"type":           schedule["type"],
+		"description":    schedule["desc"],
+		"all_disks":      allDisks,
+		"disks":          smartScheduleDisks(schedule, names),
+		"schedule":       scheduleObj,
+		"schedule_human": formatCronSchedule(cron),
+		"next_run":       cronNextRun(cron, true, clock),
+	}
+}
+
+// querySMARTSchedules returns smart.test.query
+func querySMARTSchedules(client truenas.Caller) ([]map[string]interface{}, error) {
+	result, err := client.Call("smart.test.query", []interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SMART test schedules: %w", err)
+	}
+
+	var schedules []map[string]interface{}
+	if err := json.Unmarshal(result, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse SMART test schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// getSMARTSchedule returns a SMART test schedule by ID
+func getSMARTSchedule(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("smart.test.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SMART test schedule: %w", err)
+	}
+
+	var schedules []map[string]interface{}
+	if err := json.Unmarshal(result, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse SMART test schedules: %w", err)
+	}
+
+	if len(schedules) == 0 {
+		return nil, newToolError(ErrorNotFound, "SMART test schedule with id %d not found", id)
+	}
+
+	return schedules[0], nil
+}
+
+// smartScheduleRequest validates create_smart_test_schedule arguments
+// against the disks and existing schedules, returning the smart.test.create
+// payload and the selected disks
+func smartScheduleRequest(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, []map[string]interface{}, error) {
+	testType, _ := args["type"].(string)
+	testType = strings.ToUpper(testType)
+	if !smartScheduleTypes[testType] {
+		return nil, nil, newToolError(ErrorValidation, "type must be SHORT, LONG, CONVEYANCE, or OFFLINE (got: %v)", args["type"])
+	}
+	scheduleArg, ok := args["schedule"].(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("schedule is required")
+	}
+	allDisks, _ := args["all_disks"].(bool)
+	names := stringList(args["disks"])
+	if allDisks == (len(names) > 0) {
+		return nil, nil, newToolError(ErrorValidation, "give either disks or all_disks=true")
+	}
+
+	schedule := map[string]interface{}{"hour": "0", "dom": "*", "month": "*", "dow": "*"}
+	for _, field := range []string{"hour", "dom", "month", "dow"} {
+		if v, ok := scheduleArg[field].(string); ok && v != "" {
+			schedule[field] = v
+		}
+	}
+
+	disks, err := queryDisks(client, []interface{}{})
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := map[string]map[string]interface{}{}
+	for _, disk := range disks {
+		if name, ok := disk["name"].(string); ok {
+			byName[name] = disk
+		}
+	}
+	selected := []map[string]interface{}{}
+	identifiers := []string{}
+	if allDisks {
+		selected = disks
+	} else {
+		for _, name := range names {
+			disk, ok := byName[name]
+			if !ok {
+				return nil, nil, newToolError(ErrorNotFound, "disk %s not found", name)
+			}
+			selected = append(selected, disk)
+			identifier, _ := disk["identifier"].(string)
+			identifiers = append(identifiers, identifier)
+		}
+	}
+
+	// The middleware allows one schedule of each type per disk
+	existing, err := querySMARTSchedules(client)
+	if err != nil {
+		return nil, nil, err
+	}
+	lookup := diskNamesByIdentifier(disks)
+	for _, other := range existing {
+		if !strings.EqualFold(fmt.Sprint(other["type"]), testType) {
+			continue
+		}
+		covered := smartScheduleDisks(other, lookup)
+		for _, disk := range selected {
+			name, _ := disk["name"].(string)
+			if allDisks || containsString(covered, name) {
+				return nil, nil, newToolError(ErrorValidation, "%s already has a %s test schedule (id: %v); delete it first or choose other disks", name, testType, other["id"])
+			}
+		}
+	}
+
+	description, _ := args["description"].(string)
+	create := map[string]interface{}{
+		"type":      testType,
+		"schedule":  schedule,
+		"all_disks": allDisks,
+		"disks":     identifiers,
+		"desc":      description,
+	}
+	return create, selected, nil
+}
+
+func handleQuerySMARTSchedules(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	schedules, err := querySMARTSchedules(client)
+	if err != nil {
+		return "", err
+	}
+	disks, err := queryDisks(client, []interface{}{})
+	if err != nil {
+		return "", err
+	}
+	names := diskNamesByIdentifier(disks)
+
+	diskFilter, _ := args["disk"].(string)
+	typeFilter, _ := args["type"].(string)
+	clock := getNASClock(client)
+
+	filtered := []map[string]interface{}{}
+	tested := map[string]bool{}
+	for _, schedule := range schedules {
+		covered := smartScheduleDisks(schedule, names)
+		for _, name := range covered {
+			tested[name] = true
+		}
+		if diskFilter != "" && !containsString(covered, diskFilter) {
+			continue
+		}
+		if typeFilter != "" && !strings.EqualFold(fmt.Sprint(schedule["type"]), typeFilter) {
+			continue
+		}
+		filtered = append(filtered, simplifySMARTSchedule(schedule, names, clock))
+	}
+
+	untested := []string{}
+	for _, disk := range disks {
+		if name, _ := disk["name"].(string); !tested[name] {
+			untested = append(untested, name)
+		}
+	}
+	sort.Strings(untested)
+
+	return marshalJSON(map[string]interface{}{
+		"smart_test_schedules":    filtered,
+		"count":                   len(filtered),
+		"disks_without_schedules": untested,
+		"timezone":                clock.info(),
+	})
+}
+
+func handleCreateSMARTSchedule(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, disks, err := smartScheduleRequest(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("smart.test.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SMART test schedule: %w", err)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	clock := getNASClock(client)
+	schedule := simplifySMARTSchedule(created, diskNamesByIdentifier(disks), clock)
+	return marshalJSON(map[string]interface{}{
+		"created":  true,
+		"schedule": schedule,
+		"timezone": clock.info(),
+		"message":  fmt.Sprintf("%s SMART test scheduled on %d disk(s) (%s). Next run: %s", create["type"], len(disks), schedule["schedule_human"], schedule["next_run"]),
+	})
+}
+
+func handleDeleteSMARTSchedule(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	scheduleID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(scheduleID)
+
+	existing, err := getSMARTSchedule(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("smart.test.delete", id); err != nil {
+		return "", fmt.Errorf("failed to delete SMART test schedule: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"deleted": true,
+		"id":      id,
+		"type":    existing["type"],
+		"message": fmt.Sprintf("%v SMART test schedule %d deleted. Run tests by hand with run_smart_test, or check coverage with query_smart_test_schedules.", existing["type"], id),
+	})
+}
+
+// Dry-run wrappers
+
+func handleCreateSMARTScheduleWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createSMARTScheduleDryRun{}, handleCreateSMARTSchedule)
+}
+
+func handleDeleteSMARTScheduleWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteSMARTScheduleDryRun{}, handleDeleteSMARTSchedule)
+}
+
+// Dry-run implementations
+
+type createSMARTScheduleDryRun struct{}
+
+func (c *createSMARTScheduleDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, disks, err := smartScheduleRequest(client, args)
+	if err != nil {
+		return nil, err
+	}
+	testType := create["type"].(string)
+	schedule := create["schedule"].(map[string]interface{})
+
+	clock := getNASClock(client)
+	preview := simplifySMARTSchedule(create, diskNamesByIdentifier(disks), clock)
+	delete(preview, "id")
+
+	byPool := map[string][]string{}
+	for _, disk := range disks {
+		if pool, _ := disk["pool"].(string); pool != "" {
+			name, _ := disk["name"].(string)
+			byPool[pool] = append(byPool[pool], name)
+		}
+	}
+
+	warnings := []string{}
+	if schedule["hour"] == "*" {
+		warnings = append(warnings, "The schedule starts a test every hour of the days it matches")
+	}
+	if testType == "LONG" {
+		for pool, members := range byPool {
+			if len(members) > 1 {
+				warnings = append(warnings, fmt.Sprintf("%d disks of %s run a LONG test at the same time; pool I/O is slower for hours", len(members), pool))
+			}
+		}
+		// Long tests and scrubs both read every disk; together they slow
+		// each other down
+		if result, err := client.Call("pool.scrub.query", []interface{}{}); err == nil {
+			var scrubs []map[string]interface{}
+			if json.Unmarshal(result, &scrubs) == nil {
+				for _, scrub := range scrubs {
+					pool, _ := scrub["pool_name"].(string)
+					scrubSchedule, _ := scrub["schedule"].(map[string]interface{})
+					if len(byPool[pool]) == 0 || scrubSchedule == nil {
+						continue
+					}
+					sameDay := scrubSchedule["dow"] == schedule["dow"] && scrubSchedule["dom"] == schedule["dom"]
+					if sameDay && scrubSchedule["hour"] == schedule["hour"] {
+						warnings = append(warnings, fmt.Sprintf("The scrub of %s starts at the same time (%s); move one of them", pool, formatCronSchedule(scrubSchedule)))
+					}
+				}
+			}
+		}
+	}
+	if testType == "CONVEYANCE" {
+		warnings = append(warnings, "Conveyance tests are only supported by some ATA disks; others reject them")
+	}
+
+	target := "all disks"
+	if all, _ := create["all_disks"].(bool); !all {
+		target = strings.Join(preview["disks"].([]string), ", ")
+	}
+
+	return &DryRunResult{
+		Tool: "create_smart_test_schedule",
+		CurrentState: map[string]interface{}{
+			"disks":    len(disks),
+			"timezone": clock.info(),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Schedule a %s SMART test on %s", testType, target),
+				Operation:   "create",
+				Target:      target,
+				Details:     preview,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type deleteSMARTScheduleDryRun struct{}
+
+func (d *deleteSMARTScheduleDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	scheduleID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(scheduleID)
+
+	existing, err := getSMARTSchedule(client, id)
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := querySMARTSchedules(client)
+	if err != nil {
+		return nil, err
+	}
+	disks, err := queryDisks(client, []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	names := diskNamesByIdentifier(disks)
+
+	// Disks no other schedule tests lose periodic SMART testing entirely
+	stillTested := map[string]bool{}
+	for _, schedule := range schedules {
+		if fmt.Sprint(schedule["id"]) == fmt.Sprint(existing["id"]) {
+			continue
+		}
+		for _, name := range smartScheduleDisks(schedule, names) {
+			stillTested[name] = true
+		}
+	}
+	covered := smartScheduleDisks(existing, names)
+	untested := []string{}
+	for _, name := range covered {
+		if !stillTested[name] {
+			untested = append(untested, name)
+		}
+	}
+
+	warnings := []string{}
+	if len(untested) > 0 {
+		warnings = append(warnings, fmt.Sprintf("No other schedule tests %s; failing disks may go unnoticed until they fail outright", strings.Join(untested, ", ")))
+	} else {
+		warnings = append(warnings, fmt.Sprintf("Disks keep their other SMART test schedules but lose this %v test", existing["type"]))
+	}
+
+	clock := getNASClock(client)
+	return &DryRunResult{
+		Tool: "delete_smart_test_schedule",
+		CurrentState: map[string]interface{}{
+			"schedule": simplifySMARTSchedule(existing, names, clock),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Delete %v SMART test schedule %d", existing["type"], id),
+				Operation:   "delete",
+				Target:      strings.Join(covered, ", "),
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
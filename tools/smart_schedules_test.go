@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationSMARTSchedules(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("disk.query", []map[string]interface{}{
+		{"name": "sda", "identifier": "{serial}A1", "pool": "tank"},
+		{"name": "sdb", "identifier": "{serial}B2", "pool": "tank"},
+		{"name": "nvme0n1", "identifier": "{serial}N3", "pool": nil},
+	})
+	server.SetRecords("smart.test.query", []map[string]interface{}{
+		{"id": float64(1), "type": "SHORT", "desc": "", "all_disks": true, "disks": []interface{}{},
+			"schedule": map[string]interface{}{"hour": "2", "dom": "*", "month": "*", "dow": "*"}},
+		{"id": float64(2), "type": "LONG", "desc": "monthly", "all_disks": false, "disks": []interface{}{"{serial}A1"},
+			"schedule": map[string]interface{}{"hour": "3", "dom": "1", "month": "*", "dow": "*"}},
+	})
+	server.SetRecords("pool.scrub.query", []map[string]interface{}{
+		{"id": float64(1), "pool_name": "tank", "enabled": true,
+			"schedule": map[string]interface{}{"minute": "0", "hour": "0", "dom": "*", "month": "*", "dow": "0"}},
+	})
+	server.Handle("smart.test.create", func(params []interface{}) (interface{}, error) {
+		created := map[string]interface{}{"id": float64(9)}
+		for k, v := range params[0].(map[string]interface{}) {
+			created[k] = v
+		}
+		return created, nil
+	})
+	server.SetResult("smart.test.delete", true)
+
+	result, err := registry.CallTool("query_smart_test_schedules", map[string]interface{}{"disk": "sda", "type": "long"})
+	if err != nil {
+		t.Fatalf("query_smart_test_schedules failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	schedules := response["smart_test_schedules"].([]interface{})
+	if len(schedules) != 1 {
+		t.Fatalf("filtered schedules = %v, want the LONG schedule only", schedules)
+	}
+	long := schedules[0].(map[string]interface{})
+	if fmt.Sprint(long["disks"]) != "[sda]" || long["schedule_human"] != "Monthly on 1st at 3:00" || !strings.Contains(fmt.Sprint(long["next_run"]), "T03:00:00") {
+		t.Errorf("LONG schedule = %v", long)
+	}
+
+	result, err = registry.CallTool("query_smart_test_schedules", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_smart_test_schedules failed: %v", err)
+	}
+	response = decodeResult(t, result)
+	all := response["smart_test_schedules"].([]interface{})[0].(map[string]interface{})
+	if response["count"] != float64(2) || fmt.Sprint(all["disks"]) != "[nvme0n1 sda sdb]" || len(response["disks_without_schedules"].([]interface{})) != 0 {
+		t.Errorf("all schedules = %v", response)
+	}
+
+	// A LONG test at the pool's scrub time is flagged in the preview
+	create := map[string]interface{}{
+		"type":     "LONG",
+		"disks":    []interface{}{"sdb", "nvme0n1"},
+		"schedule": map[string]interface{}{"hour": "0", "dow": "0"},
+		"dry_run":  true,
+	}
+	result, err = registry.CallTool("create_smart_test_schedule", create)
+	if err != nil {
+		t.Fatalf("create_smart_test_schedule dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "scrub of tank starts at the same time") || len(server.Calls("smart.test.create")) != 0 {
+		t.Errorf("create dry run = %s", result)
+	}
+	create["disks"] = []interface{}{"sda", "sdb"}
+	if _, err := registry.CallTool("create_smart_test_schedule", create); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("duplicate LONG schedule error = %v, want VALIDATION", err)
+	}
+	create["disks"] = []interface{}{"sdz"}
+	if _, err := registry.CallTool("create_smart_test_schedule", create); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown disk error = %v, want NOT_FOUND", err)
+	}
+
+	result, err = registry.CallTool("create_smart_test_schedule", map[string]interface{}{
+		"type":        "LONG",
+		"disks":       []interface{}{"sdb", "nvme0n1"},
+		"schedule":    map[string]interface{}{"hour": "4", "dow": "6"},
+		"description": "weekly long",
+	})
+	if err != nil {
+		t.Fatalf("create_smart_test_schedule failed: %v", err)
+	}
+	created := decodeResult(t, result)["schedule"].(map[string]interface{})
+	if created["schedule_human"] != "Weekly on Saturday at 4:00" || fmt.Sprint(created["disks"]) != "[nvme0n1 sdb]" {
+		t.Errorf("created schedule = %v", created)
+	}
+	request := server.Calls("smart.test.create")[0].Params[0].(map[string]interface{})
+	if fmt.Sprint(request["disks"]) != "[{serial}B2 {serial}N3]" || request["desc"] != "weekly long" || request["schedule"].(map[string]interface{})["dom"] != "*" {
+		t.Errorf("smart.test.create request = %v", request)
+	}
+
+	// Deleting the only schedule for every disk leaves them all untested
+	result, err = registry.CallTool("delete_smart_test_schedule", map[string]interface{}{"id": float64(1), "dry_run": true})
+	if err != nil {
+		t.Fatalf("delete_smart_test_schedule dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "No other schedule tests nvme0n1, sdb") || len(server.Calls("smart.test.delete")) != 0 {
+		t.Errorf("delete dry run = %s", result)
+	}
+	if _, err := registry.CallTool("delete_smart_test_schedule", map[string]interface{}{"id": float64(1)}); err != nil {
+		t.Fatalf("delete_smart_test_schedule failed: %v", err)
+	}
+	if _, err := registry.CallTool("delete_smart_test_schedule", map[string]interface{}{"id": float64(42)}); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown schedule error = %v, want NOT_FOUND", err)
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // handleCreateSMBShare creates a new SMB share
-func handleCreateSMBShare(client *truenas.Client, args map[string]interface{}) (string, error) {
+func handleCreateSMBShare(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
 	// Extract required parameters
 	name, ok := args["name"].(string)
 	if !ok || name == "" {
@@ -92,6 +92,9 @@ func handleCreateSMBShare(client *truenas.Client, args map[string]interface{}) (
 		optionsMap["hostsdeny"] = hostsdeny
 	}
 
+	// Ownership and ACL to apply after creation
+	perms := parseSharePermissions(args)
+
 	// Check if this is a dry run
 	if dryRun, ok := args["dry_run"].(bool); ok && dryRun {
 		// Return preview of what would be created
@@ -122,6 +125,10 @@ func handleCreateSMBShare(client *truenas.Client, args map[string]interface{}) (
 			warnings = append(warnings, dirWarnings...)
 		}
 
+		if perms != nil {
+			preview["permissions"] = perms.describe(path)
+		}
+
 		if len(warnings) > 0 {
 			preview["security_warnings"] = warnings
 		}
@@ -163,7 +170,17 @@ func handleCreateSMBShare(client *truenas.Client, args map[string]interface{}) (
 
 	// Add connection information
 	response["network_path"] = fmt.Sprintf("\\\\truenas\\%s", name)
-	response["note"] = "Share is now accessible over the network. You may need to configure permissions."
+	response["note"] = "Share is now accessible over the network. Check access with get_acl and change it with set_acl or set_permissions if needed."
+
+	// The share exists at this point, so a permission failure is reported rather than returned
+	if perms != nil {
+		if applied, err := applySharePermissions(client, path, perms); err != nil {
+			response["permissions_error"] = err.Error()
+		} else {
+			response["permissions"] = applied
+			response["note"] = "Share is now accessible over the network. Ownership and ACL are being applied by the permissions job."
+		}
+	}
 
 	formatted, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
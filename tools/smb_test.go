@@ -306,3 +306,41 @@ func TestValidateSharePath(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegrationCreateSMBShareAppliesACLPreset(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("sharing.smb.create", map[string]interface{}{
+		"id": float64(3), "name": "docs", "path": "/mnt/tank/docs", "enabled": true,
+	})
+	server.SetResult("filesystem.acltemplate.by_path", []map[string]interface{}{
+		{"name": "NFS4_OPEN", "acltype": "NFS4", "acl": []interface{}{}},
+		{"name": "NFS4_RESTRICTED", "acltype": "NFS4", "acl": []interface{}{map[string]interface{}{"tag": "owner@"}}},
+	})
+	server.SetResult("filesystem.setacl", float64(42))
+
+	result, err := registry.CallTool("create_smb_share", map[string]interface{}{
+		"name":       "docs",
+		"path":       "/mnt/tank/docs",
+		"owner":      "alice",
+		"group":      "staff",
+		"acl_preset": "NFS4_RESTRICTED",
+	})
+	if err != nil {
+		t.Fatalf("create_smb_share failed: %v", err)
+	}
+
+	response := decodeResult(t, result)
+	perms, _ := response["permissions"].(map[string]interface{})
+	if perms["job_id"] != float64(42) {
+		t.Errorf("permissions = %v, want job_id 42", response["permissions"])
+	}
+
+	calls := server.Calls("filesystem.setacl")
+	if len(calls) != 1 {
+		t.Fatalf("filesystem.setacl called %d times, want 1", len(calls))
+	}
+	payload, _ := calls[0].Params[0].(map[string]interface{})
+	if payload["user"] != "alice" || payload["group"] != "staff" || payload["acltype"] != "NFS4" {
+		t.Errorf("unexpected setacl payload: %v", payload)
+	}
+}
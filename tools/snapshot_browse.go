@@ -0,0 +1,432 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Snapshot browsing and single-file restore
+
+const (
+	// fileRestoreJobTimeout bounds the wait for the write and ownership jobs
+	// after a file has been streamed to the NAS
+	fileRestoreJobTimeout      = 60 * time.Second
+	fileRestoreJobPollInterval = 250 * time.Millisecond
+
+	// largeRestoreBytes is the file size above which dry runs warn that the
+	// copy streams through this server
+	largeRestoreBytes = 1 << 30
+)
+
+// snapshotFiles locates a snapshot's files through the dataset's hidden
+// .zfs/snapshot directory
+type snapshotFiles struct {
+	snapshot   string
+	dataset    string
+	mountpoint string
+	root       string // mountpoint/.zfs/snapshot/<name>
+}
+
+func getSnapshotFiles(client truenas.Caller, id string) (*snapshotFiles, error) {
+	dataset, name, err := splitSnapshotID(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := getSnapshot(client, id); err != nil {
+		return nil, err
+	}
+	datasets, err := inventoryQuery(client, "pool.dataset.query", []interface{}{[]interface{}{"id", "=", dataset}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dataset: %w", err)
+	}
+	if len(datasets) == 0 {
+		return nil, newToolError(ErrorNotFound, "dataset %s not found", dataset)
+	}
+	if datasets[0]["type"] == "VOLUME" {
+		return nil, newToolError(ErrorValidation, "%s is a zvol; only filesystem snapshots can be browsed", dataset)
+	}
+	mountpoint, _ := datasets[0]["mountpoint"].(string)
+	if !strings.HasPrefix(mountpoint, "/") {
+		return nil, newToolError(ErrorPrecondition, "dataset %s is not mounted, so its snapshots cannot be browsed", dataset)
+	}
+	return &snapshotFiles{
+		snapshot:   id,
+		dataset:    dataset,
+		mountpoint: mountpoint,
+		root:       mountpoint + "/.zfs/snapshot/" + name,
+	}, nil
+}
+
+// relative normalizes a path within the dataset. It accepts paths relative to
+// the dataset root or absolute paths under its mountpoint; "" is the root.
+func (f *snapshotFiles) relative(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == f.mountpoint {
+		return "", nil
+	}
+	if strings.HasPrefix(p, "/") {
+		if !strings.HasPrefix(p, f.mountpoint+"/") {
+			return "", newToolError(ErrorValidation, "absolute path %s is outside dataset %s (mounted at %s); give a path under the mountpoint or relative to it", p, f.dataset, f.mountpoint)
+		}
+		p = strings.TrimPrefix(p, f.mountpoint+"/")
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return "", newToolError(ErrorValidation, "path must stay within dataset %s (got: %s)", f.dataset, p)
+		}
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/"), nil
+}
+
+// snapshotPath and livePath join a relative path to the snapshot and live roots
+func (f *snapshotFiles) snapshotPath(rel string) string { return joinDatasetPath(f.root, rel) }
+func (f *snapshotFiles) livePath(rel string) string     { return joinDatasetPath(f.mountpoint, rel) }
+
+func joinDatasetPath(root, rel string) string {
+	if rel == "" {
+		return root
+	}
+	return root + "/" + rel
+}
+
+// statPath returns filesystem.stat of p, or nil when it does not exist
+func statPath(client truenas.Caller, p string) (map[string]interface{}, error) {
+	result, err := client.Call("filesystem.stat", p)
+	if err != nil {
+		if ClassifyError(err).Code == ErrorNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to stat %s: %w", p, err)
+	}
+	var stat map[string]interface{}
+	if err := json.Unmarshal(result, &stat); err != nil {
+		return nil, fmt.Errorf("failed to parse stat of %s: %w", p, err)
+	}
+	return stat, nil
+}
+
+// listDirectory returns filesystem.listdir entries of p, or nil when p does
+// not exist
+func listDirectory(client truenas.Caller, p string, filters []interface{}, limit int) ([]map[string]interface{}, error) {
+	options := map[string]interface{}{"order_by": []string{"name"}}
+	if limit > 0 {
+		options["limit"] = limit
+	}
+	result, err := client.Call("filesystem.listdir", p, filters, options)
+	if err != nil {
+		if ClassifyError(err).Code == ErrorNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list %s: %w", p, err)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(result, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse listing of %s: %w", p, err)
+	}
+	return entries, nil
+}
+
+func handleBrowseSnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	id, _ := args["snapshot"].(string)
+	files, err := getSnapshotFiles(client, id)
+	if err != nil {
+		return "", err
+	}
+	requested, _ := args["path"].(string)
+	rel, err := files.relative(requested)
+	if err != nil {
+		return "", err
+	}
+	limit := 200
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	compareLive := true
+	if c, ok := args["compare_live"].(bool); ok {
+		compareLive = c
+	}
+
+	dir := files.snapshotPath(rel)
+	entries, err := listDirectory(client, dir, []interface{}{}, limit+1)
+	if err != nil {
+		return "", err
+	}
+	if entries == nil {
+		stat, err := statPath(client, dir)
+		if err != nil {
+			return "", err
+		}
+		if stat != nil {
+			return "", newToolError(ErrorValidation, "%s is a file in snapshot %s; use restore_file_from_snapshot to restore it", rel, id)
+		}
+		return "", newToolError(ErrorNotFound, "%s does not exist in snapshot %s", joinDatasetPath(files.dataset, rel), id)
+	}
+	truncated := len(entries) > limit
+	if truncated {
+		entries = entries[:limit]
+	}
+
+	// Mark entries that are gone from (or were never in) the live directory
+	var live map[string]bool
+	liveDirExists := true
+	if compareLive && len(entries) > 0 {
+		names := make([]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry["name"])
+		}
+		liveEntries, err := listDirectory(client, files.livePath(rel), []interface{}{[]interface{}{"name", "in", names}}, 0)
+		if err != nil {
+			return "", err
+		}
+		liveDirExists = liveEntries != nil
+		live = map[string]bool{}
+		for _, entry := range liveEntries {
+			if name, ok := entry["name"].(string); ok {
+				live[name] = true
+			}
+		}
+	}
+
+	listing := make([]map[string]interface{}, 0, len(entries))
+	missing := 0
+	for _, entry := range entries {
+		name, _ := entry["name"].(string)
+		item := map[string]interface{}{
+			"name": name,
+			"path": joinDatasetPath(rel, name),
+			"type": entry["type"],
+		}
+		if size, ok := entry["size"].(float64); ok && entry["type"] == "FILE" {
+			item["size"] = units.FormatBytes(int64(size))
+			item["size_bytes"] = int64(size)
+		}
+		if live != nil {
+			item["in_live"] = live[name]
+			if !live[name] {
+				missing++
+			}
+		}
+		listing = append(listing, item)
+	}
+
+	response := map[string]interface{}{
+		"snapshot":      id,
+		"dataset":       files.dataset,
+		"path":          rel,
+		"snapshot_path": dir,
+		"entry_count":   len(listing),
+		"entries":       listing,
+		"truncated":     truncated,
+	}
+	if live != nil {
+		response["missing_from_live"] = missing
+		if !liveDirExists {
+			response["note"] = fmt.Sprintf("Directory %s no longer exists in the live dataset", files.livePath(rel))
+		}
+	}
+	if truncated {
+		response["message"] = fmt.Sprintf("Showing the first %d entries; raise limit or browse a subdirectory to see more", limit)
+	} else {
+		response["message"] = "Browse into a DIRECTORY by passing its path; restore a FILE with restore_file_from_snapshot"
+	}
+	return marshalJSON(response)
+}
+
+// fileRestore is a validated restore_file_from_snapshot request
+type fileRestore struct {
+	files       *snapshotFiles
+	path        string // relative path in the snapshot
+	source      string
+	destination string
+	size        int64
+	mode        int64
+	uid, gid    interface{}
+	overwrite   bool
+}
+
+func planFileRestore(client truenas.Caller, args map[string]interface{}) (*fileRestore, error) {
+	id, _ := args["snapshot"].(string)
+	files, err := getSnapshotFiles(client, id)
+	if err != nil {
+		return nil, err
+	}
+	requested, _ := args["path"].(string)
+	rel, err := files.relative(requested)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "" {
+		return nil, fmt.Errorf("path is required: the file to restore, relative to the dataset root (from browse_snapshot)")
+	}
+
+	plan := &fileRestore{files: files, path: rel, source: files.snapshotPath(rel)}
+	source, err := statPath(client, plan.source)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, newToolError(ErrorNotFound, "%s does not exist in snapshot %s", rel, id)
+	}
+	if source["type"] != "FILE" {
+		return nil, newToolError(ErrorValidation, "%s is a %v; only regular files can be restored (browse into directories with browse_snapshot and restore files one at a time)", rel, source["type"])
+	}
+	if size, ok := source["size"].(float64); ok {
+		plan.size = int64(size)
+	}
+	if mode, ok := source["mode"].(float64); ok {
+		plan.mode = int64(mode) & 0o7777
+	}
+	plan.uid, plan.gid = source["uid"], source["gid"]
+
+	destRel := rel
+	if d, _ := args["destination"].(string); d != "" {
+		if destRel, err = files.relative(d); err != nil {
+			return nil, err
+		}
+		if destRel == "" {
+			return nil, newToolError(ErrorValidation, "destination must be a file path within dataset %s", files.dataset)
+		}
+	}
+	plan.destination = files.livePath(destRel)
+	plan.overwrite, _ = args["overwrite"].(bool)
+
+	parent, err := statPath(client, path.Dir(plan.destination))
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil || parent["type"] != "DIRECTORY" {
+		return nil, newToolError(ErrorPrecondition, "directory %s does not exist in the live dataset; create it or pass a destination in an existing directory", path.Dir(plan.destination))
+	}
+	existing, err := statPath(client, plan.destination)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case existing == nil:
+		plan.overwrite = false
+	case existing["type"] != "FILE":
+		return nil, newToolError(ErrorValidation, "destination %s is a %v, not a file", plan.destination, existing["type"])
+	case !plan.overwrite:
+		return nil, newToolError(ErrorPrecondition, "%s already exists in the live dataset; set overwrite=true to replace it or pass a different destination (e.g., '%s.restored')", plan.destination, destRel)
+	}
+	return plan, nil
+}
+
+// waitFileJob waits for a short filesystem job to finish
+func waitFileJob(ctx context.Context, client truenas.Caller, jobID int, what string) error {
+	if _, err := waitForJobWithin(ctx, client, jobID, fileRestoreJobPollInterval, fileRestoreJobTimeout); err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	return nil
+}
+
+func handleRestoreFileFromSnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planFileRestore(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	// Stream the snapshot copy straight into the live dataset
+	_, contents, err := client.Download("filesystem.get", []interface{}{plan.source}, path.Base(plan.source))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from the snapshot: %w", plan.path, err)
+	}
+	defer contents.Close()
+	jobID, err := client.Upload("filesystem.put", []interface{}{plan.destination, map[string]interface{}{"mode": plan.mode}}, contents)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", plan.destination, err)
+	}
+	if err := waitFileJob(ctx, client, jobID, "writing "+plan.destination); err != nil {
+		return "", err
+	}
+
+	// Files are written as root; give the restored copy its original owner
+	response := map[string]interface{}{
+		"restored":    true,
+		"snapshot":    plan.files.snapshot,
+		"source":      plan.source,
+		"destination": plan.destination,
+		"size":        units.FormatBytes(plan.size),
+		"overwritten": plan.overwrite,
+		"mode":        fmt.Sprintf("%04o", plan.mode),
+	}
+	if plan.uid != nil && plan.gid != nil {
+		result, err := client.Call("filesystem.chown", map[string]interface{}{"path": plan.destination, "uid": plan.uid, "gid": plan.gid})
+		if err == nil {
+			var chownJob int
+			if chownJob, err = parseJobID(result); err == nil {
+				err = waitFileJob(ctx, client, chownJob, "restoring ownership of "+plan.destination)
+			}
+		}
+		if err != nil {
+			response["warning"] = fmt.Sprintf("The file was restored but its owner could not be set to uid %v / gid %v: %v", plan.uid, plan.gid, err)
+		} else {
+			response["uid"], response["gid"] = plan.uid, plan.gid
+		}
+	}
+	response["message"] = fmt.Sprintf("Restored %s from %s to %s", plan.path, plan.files.snapshot, plan.destination)
+	return marshalJSON(response)
+}
+
+func handleRestoreFileFromSnapshotWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &restoreFileDryRun{}, handleRestoreFileFromSnapshot)
+}
+
+type restoreFileDryRun struct{}
+
+func (d *restoreFileDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planFileRestore(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	write := "Create"
+	warnings := []string{}
+	if plan.overwrite {
+		write = "Overwrite"
+		warnings = append(warnings, fmt.Sprintf("The current contents of %s are replaced and cannot be recovered unless another snapshot holds them", plan.destination))
+	}
+	if plan.size > largeRestoreBytes {
+		warnings = append(warnings, fmt.Sprintf("The file is %s and is streamed through this server; the copy may take a while", units.FormatBytes(plan.size)))
+	}
+	actions := []PlannedAction{
+		{
+			Step:        1,
+			Description: fmt.Sprintf("%s %s with the copy from %s", write, plan.destination, plan.files.snapshot),
+			Operation:   "restore",
+			Target:      plan.destination,
+			Details:     map[string]interface{}{"source": plan.source, "size": units.FormatBytes(plan.size), "mode": fmt.Sprintf("%04o", plan.mode)},
+		},
+	}
+	if plan.uid != nil && plan.gid != nil {
+		actions = append(actions, PlannedAction{
+			Step:        2,
+			Description: fmt.Sprintf("Set owner to uid %v / gid %v as in the snapshot", plan.uid, plan.gid),
+			Operation:   "chown",
+			Target:      plan.destination,
+		})
+	}
+
+	return &DryRunResult{
+		Tool: "restore_file_from_snapshot",
+		CurrentState: map[string]interface{}{
+			"snapshot":           plan.files.snapshot,
+			"source":             plan.source,
+			"destination":        plan.destination,
+			"destination_exists": plan.overwrite,
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+		EstimatedTime:  &EstimatedTime{MinSeconds: 1, MaxSeconds: 60, Note: "Depends on file size"},
+	}, nil
+}
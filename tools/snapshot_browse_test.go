@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationSnapshotFileRestore(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.snapshot.query", []map[string]interface{}{{"id": "tank/docs@daily", "dataset": "tank/docs", "snapshot_name": "daily"}})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank/docs", "type": "FILESYSTEM", "mountpoint": "/mnt/tank/docs"}})
+	snapRoot := "/mnt/tank/docs/.zfs/snapshot/daily"
+	stats := map[string]interface{}{
+		snapRoot + "/report.txt": map[string]interface{}{"type": "FILE", "size": 5, "mode": 0o100644, "uid": 3000, "gid": 3000},
+		"/mnt/tank/docs":         map[string]interface{}{"type": "DIRECTORY"},
+	}
+	server.Handle("filesystem.stat", func(params []interface{}) (interface{}, error) {
+		if stat, ok := stats[params[0].(string)]; ok {
+			return stat, nil
+		}
+		return nil, &truenastest.Error{Code: 2, Message: "not found", ErrName: "ENOENT"}
+	})
+	server.Handle("filesystem.listdir", func(params []interface{}) (interface{}, error) {
+		switch params[0] {
+		case snapRoot:
+			return []interface{}{
+				map[string]interface{}{"name": "old", "type": "DIRECTORY", "size": 4096},
+				map[string]interface{}{"name": "report.txt", "type": "FILE", "size": 5},
+			}, nil
+		case "/mnt/tank/docs":
+			return []interface{}{map[string]interface{}{"name": "old", "type": "DIRECTORY"}}, nil
+		}
+		return nil, &truenastest.Error{Code: 2, Message: "not found", ErrName: "ENOENT"}
+	})
+	server.HandleJob("filesystem.chown", truenastest.JobSpec{})
+	server.SetFile(snapRoot+"/report.txt", []byte("hello"))
+
+	result, err := registry.CallTool("browse_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily"})
+	if err != nil {
+		t.Fatalf("browse_snapshot failed: %v", err)
+	}
+	listing := decodeResult(t, result)
+	if listing["missing_from_live"] != float64(1) || !strings.Contains(result, `"in_live": false`) {
+		t.Errorf("browse_snapshot did not flag report.txt as missing:\n%s", result)
+	}
+	_, err = registry.CallTool("browse_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily", "path": "../other"})
+	if ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("browsing outside the dataset error = %v, want VALIDATION", err)
+	}
+	for _, outside := range []string{"/mnt/tank/other/report.txt", "/mnt/tank/docs2", "/etc"} {
+		_, err = registry.CallTool("browse_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily", "path": outside})
+		if ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("browsing absolute path %s error = %v, want VALIDATION", outside, err)
+		}
+	}
+	if _, err := registry.CallTool("restore_file_from_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily", "path": "/mnt/tank/docs/report.txt", "dry_run": true}); err != nil {
+		t.Errorf("restoring by an absolute path under the mountpoint failed: %v", err)
+	}
+
+	preview, err := registry.CallTool("restore_file_from_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily", "path": "report.txt", "dry_run": true})
+	if err != nil {
+		t.Fatalf("restore dry run failed: %v", err)
+	}
+	if !strings.Contains(preview, "Create /mnt/tank/docs/report.txt") || len(server.Calls("filesystem.put")) != 0 {
+		t.Errorf("unexpected dry run:\n%s", preview)
+	}
+
+	result, err = registry.CallTool("restore_file_from_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily", "path": "/mnt/tank/docs/report.txt"})
+	if err != nil {
+		t.Fatalf("restore_file_from_snapshot failed: %v", err)
+	}
+	if content, _ := server.File("/mnt/tank/docs/report.txt"); string(content) != "hello" {
+		t.Errorf("restored content = %q, want hello", content)
+	}
+	if options := server.Calls("filesystem.put")[0].Params[1].(map[string]interface{}); options["mode"] != float64(0o644) {
+		t.Errorf("filesystem.put options = %v, want mode 0644", options)
+	}
+	if calls := server.Calls("filesystem.chown"); len(calls) != 1 || !strings.Contains(result, `"uid": 3000`) {
+		t.Errorf("ownership not restored: %v\n%s", calls, result)
+	}
+
+	// The restored file now exists, so a second restore needs overwrite
+	stats["/mnt/tank/docs/report.txt"] = map[string]interface{}{"type": "FILE", "size": 5}
+	_, err = registry.CallTool("restore_file_from_snapshot", map[string]interface{}{"snapshot": "tank/docs@daily", "path": "report.txt"})
+	if ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("restore over an existing file error = %v, want PRECONDITION", err)
+	}
+}
@@ -0,0 +1,629 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Periodic snapshot task management (pool.snapshottask)
+
+// defaultSnapshotNamingSchema is the naming schema the TrueNAS UI proposes
+const defaultSnapshotNamingSchema = "auto-%Y-%m-%d_%H-%M"
+
+// snapshotLifetimeUnits maps pool.snapshottask lifetime units to their
+// approximate length, used to estimate how many snapshots a task keeps
+var snapshotLifetimeUnits = map[string]time.Duration{
+	"HOUR":  time.Hour,
+	"DAY":   24 * time.Hour,
+	"WEEK":  7 * 24 * time.Hour,
+	"MONTH": 30 * 24 * time.Hour,
+	"YEAR":  365 * 24 * time.Hour,
+}
+
+// getSnapshotTask returns a periodic snapshot task by ID
+func getSnapshotTask(client truenas.Caller, id int) (map[string]interface{}, error) {
+	result, err := client.Call("pool.snapshottask.query", []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot task: %w", err)
+	}
+
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot tasks: %w", err)
+	}
+
+	if len(tasks) == 0 {
+		return nil, newToolError(ErrorNotFound, "snapshot task with id %d not found", id)
+	}
+
+	return tasks[0], nil
+}
+
+// formatRetention renders a task lifetime such as "2 weeks"
+func formatRetention(value int, unit string) string {
+	name := strings.ToLower(unit)
+	if value != 1 {
+		name += "s"
+	}
+	return fmt.Sprintf("%d %s", value, name)
+}
+
+// snapshotTaskInterval approximates the time between runs of a task schedule,
+// using the same patterns formatCronSchedule recognizes. It returns 0 for
+// custom schedules.
+func snapshotTaskInterval(schedule map[string]interface{}) time.Duration {
+	minute, _ := schedule["minute"].(string)
+	hour, _ := schedule["hour"].(string)
+	dom, _ := schedule["dom"].(string)
+	dow, _ := schedule["dow"].(string)
+
+	if strings.ContainsAny(minute+hour+dom+dow, ",/-") {
+		return 0
+	}
+	switch {
+	case dow != "*" && dom == "*":
+		return 7 * 24 * time.Hour
+	case dom != "*" && dow == "*":
+		return 30 * 24 * time.Hour
+	case dom == "*" && dow == "*" && hour != "*" && minute != "*":
+		return 24 * time.Hour
+	case dom == "*" && dow == "*" && hour == "*" && minute != "*":
+		return time.Hour
+	}
+	return 0
+}
+
+// snapshotTaskWindow describes the begin/end window of an hourly task, or ""
+// when the task may run all day
+func snapshotTaskWindow(schedule map[string]interface{}) string {
+	begin, _ := schedule["begin"].(string)
+	end, _ := schedule["end"].(string)
+	if (begin == "" || begin == "00:00") && (end == "" || end == "23:59") {
+		return ""
+	}
+	return fmt.Sprintf("between %s and %s", begin, end)
+}
+
+// snapshotTaskLifetime returns how long a task keeps its snapshots
+func snapshotTaskLifetime(task map[string]interface{}) time.Duration {
+	value, _ := task["lifetime_value"].(float64)
+	unit, _ := task["lifetime_unit"].(string)
+	return time.Duration(value) * snapshotLifetimeUnits[unit]
+}
+
+// expectedSnapshotsRetained estimates how many snapshots a task keeps per
+// dataset once its retention is reached. It returns 0 when the schedule is
+// too irregular to estimate.
+func expectedSnapshotsRetained(schedule map[string]interface{}, value int, unit string) int {
+	interval := snapshotTaskInterval(schedule)
+	lifetime, ok := snapshotLifetimeUnits[unit]
+	if interval == 0 || !ok {
+		return 0
+	}
+	return int(time.Duration(value) * lifetime / interval)
+}
+
+func simplifySnapshotTask(task map[string]interface{}, clock nasClock) map[string]interface{} {
+	scheduleObj, _ := task["schedule"].(map[string]interface{})
+	lifetimeValue, _ := task["lifetime_value"].(float64)
+	lifetimeUnit, _ := task["lifetime_unit"].(string)
+	enabled, _ := task["enabled"].(bool)
+
+	scheduleHuman := formatCronSchedule(scheduleObj)
+	if window := snapshotTaskWindow(scheduleObj); window != "" {
+		scheduleHuman += " " + window
+	}
+	nextRun := "disabled"
+	if enabled {
+		nextRun = calculateNextRun(scheduleObj, clock.now())
+	}
+
+	retention := map[string]interface{}{
+		"value": int(lifetimeValue),
+		"unit":  lifetimeUnit,
+		"human": "Keep for " + formatRetention(int(lifetimeValue), lifetimeUnit),
+	}
+	if count := expectedSnapshotsRetained(scheduleObj, int(lifetimeValue), lifetimeUnit); count > 0 {
+		retention["expected_snapshots"] = count
+	}
+
+	simplified := map[string]interface{}{
+		"id":             task["id"],
+		"dataset":        task["dataset"],
+		"recursive":      task["recursive"],
+		"exclude":        task["exclude"],
+		"enabled":        enabled,
+		"naming_schema":  task["naming_schema"],
+		"allow_empty":    task["allow_empty"],
+		"schedule":       scheduleObj,
+		"schedule_human": scheduleHuman,
+		"next_run":       nextRun,
+		"retention":      retention,
+	}
+	if state, ok := task["state"].(map[string]interface{}); ok {
+		simplified["state"] = state["state"]
+		if msg, ok := state["error"].(string); ok && msg != "" {
+			simplified["error"] = msg
+		}
+	}
+	return simplified
+}
+
+// snapshotTaskSchedule merges cron fields from args over base. begin and end
+// limit hourly tasks to part of the day.
+func snapshotTaskSchedule(base map[string]interface{}, scheduleArg map[string]interface{}) map[string]interface{} {
+	schedule := map[string]interface{}{}
+	for k, v := range base {
+		schedule[k] = v
+	}
+	for _, field := range []string{"minute", "hour", "dom", "month", "dow", "begin", "end"} {
+		if v, ok := scheduleArg[field].(string); ok && v != "" {
+			schedule[field] = v
+		}
+	}
+	return schedule
+}
+
+// validateNamingSchema checks a naming schema yields unique, parseable names,
+// as the middleware requires
+func validateNamingSchema(schema string) error {
+	for _, token := range []string{"%Y", "%m", "%d", "%H", "%M"} {
+		if !strings.Contains(schema, token) {
+			return newToolError(ErrorValidation, "naming_schema must contain %%Y, %%m, %%d, %%H, and %%M (missing %s)", token)
+		}
+	}
+	if strings.Contains(schema, "/") || strings.Contains(schema, "@") {
+		return newToolError(ErrorValidation, "naming_schema must not contain '/' or '@'")
+	}
+	return nil
+}
+
+// snapshotTaskFields validates the task settings shared by create and update,
+// copying those present in args into payload
+func snapshotTaskFields(payload map[string]interface{}, args map[string]interface{}) error {
+	if v, ok := args["lifetime_value"].(float64); ok {
+		if v < 1 {
+			return newToolError(ErrorValidation, "lifetime_value must be at least 1")
+		}
+		payload["lifetime_value"] = float64(int(v))
+	}
+	if unit, ok := args["lifetime_unit"].(string); ok {
+		unit = strings.ToUpper(unit)
+		if _, known := snapshotLifetimeUnits[unit]; !known {
+			return newToolError(ErrorValidation, "lifetime_unit must be HOUR, DAY, WEEK, MONTH, or YEAR")
+		}
+		payload["lifetime_unit"] = unit
+	}
+	if schema, ok := args["naming_schema"].(string); ok {
+		if err := validateNamingSchema(schema); err != nil {
+			return err
+		}
+		payload["naming_schema"] = schema
+	}
+	for _, field := range []string{"recursive", "allow_empty", "enabled"} {
+		if v, ok := args[field].(bool); ok {
+			payload[field] = v
+		}
+	}
+	if exclude, ok := args["exclude"].([]interface{}); ok {
+		datasets := []string{}
+		for _, item := range exclude {
+			if s, ok := item.(string); ok && s != "" {
+				datasets = append(datasets, s)
+			}
+		}
+		payload["exclude"] = datasets
+	}
+	return nil
+}
+
+// snapshotTaskCreate builds the pool.snapshottask.create payload from args
+func snapshotTaskCreate(args map[string]interface{}) (map[string]interface{}, error) {
+	dataset, _ := args["dataset"].(string)
+	if dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
+	scheduleArg, ok := args["schedule"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schedule is required")
+	}
+
+	create := map[string]interface{}{
+		"dataset":        dataset,
+		"recursive":      false,
+		"exclude":        []string{},
+		"lifetime_value": float64(2),
+		"lifetime_unit":  "WEEK",
+		"naming_schema":  defaultSnapshotNamingSchema,
+		"allow_empty":    true,
+		"enabled":        true,
+		"schedule": snapshotTaskSchedule(map[string]interface{}{
+			"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*", "begin": "00:00", "end": "23:59",
+		}, scheduleArg),
+	}
+	if err := snapshotTaskFields(create, args); err != nil {
+		return nil, err
+	}
+	if exclude, _ := create["exclude"].([]string); len(exclude) > 0 {
+		if recursive, _ := create["recursive"].(bool); !recursive {
+			return nil, newToolError(ErrorValidation, "exclude only applies to recursive tasks")
+		}
+		for _, child := range exclude {
+			if !strings.HasPrefix(child, dataset+"/") {
+				return nil, newToolError(ErrorValidation, "excluded dataset %s is not a child of %s", child, dataset)
+			}
+		}
+	}
+	return create, nil
+}
+
+// snapshotTaskUpdate builds the pool.snapshottask.update payload from the
+// fields present in args. Cron fields not given keep their current values.
+func snapshotTaskUpdate(existing map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if scheduleArg, ok := args["schedule"].(map[string]interface{}); ok {
+		current, _ := existing["schedule"].(map[string]interface{})
+		update["schedule"] = snapshotTaskSchedule(current, scheduleArg)
+	}
+	if err := snapshotTaskFields(update, args); err != nil {
+		return nil, err
+	}
+
+	if len(update) == 0 {
+		return nil, fmt.Errorf("nothing to update: provide schedule, lifetime_value, lifetime_unit, naming_schema, recursive, exclude, allow_empty, or enabled")
+	}
+	return update, nil
+}
+
+// snapshotTaskWarnings flags settings likely to surprise the user
+func snapshotTaskWarnings(task map[string]interface{}) []string {
+	warnings := []string{}
+	scheduleObj, _ := task["schedule"].(map[string]interface{})
+	lifetimeValue, _ := task["lifetime_value"].(float64)
+	value := int(lifetimeValue)
+	unit, _ := task["lifetime_unit"].(string)
+
+	if interval := snapshotTaskInterval(scheduleObj); interval > 0 {
+		if lifetime := snapshotTaskLifetime(task); lifetime > 0 && lifetime < interval {
+			warnings = append(warnings, "Retention is shorter than the interval between runs, so at most one snapshot from this task exists at a time")
+		}
+	}
+	if count := expectedSnapshotsRetained(scheduleObj, value, unit); count > 1000 {
+		warnings = append(warnings, fmt.Sprintf("This task keeps about %d snapshots per dataset; large snapshot counts slow down listing and replication", count))
+	}
+	if allowEmpty, _ := task["allow_empty"].(bool); allowEmpty {
+		if interval := snapshotTaskInterval(scheduleObj); interval > 0 && interval <= time.Hour {
+			warnings = append(warnings, "allow_empty is on: a snapshot is taken every run even when nothing changed")
+		}
+	}
+	return warnings
+}
+
+func handleQuerySnapshotTasks(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	result, err := client.Call("pool.snapshottask.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query snapshot tasks: %w", err)
+	}
+
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(result, &tasks); err != nil {
+		return "", fmt.Errorf("failed to parse snapshot tasks: %w", err)
+	}
+
+	datasetFilter, _ := args["dataset"].(string)
+	enabledOnly, _ := args["enabled_only"].(bool)
+
+	filtered := []map[string]interface{}{}
+	clock := getNASClock(client)
+	for _, task := range tasks {
+		dataset, _ := task["dataset"].(string)
+		enabled, _ := task["enabled"].(bool)
+		if datasetFilter != "" && dataset != datasetFilter && !strings.HasPrefix(dataset, datasetFilter+"/") {
+			continue
+		}
+		if enabledOnly && !enabled {
+			continue
+		}
+		filtered = append(filtered, simplifySnapshotTask(task, clock))
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"snapshot_tasks": filtered,
+		"count":          len(filtered),
+		"timezone":       clock.info(),
+	})
+}
+
+func handleCreateSnapshotTask(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := snapshotTaskCreate(args)
+	if err != nil {
+		return "", err
+	}
+	dataset := create["dataset"].(string)
+
+	exists, err := datasetExists(client, dataset)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", newToolError(ErrorNotFound, "dataset %s not found", dataset)
+	}
+
+	result, err := client.Call("pool.snapshottask.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot task: %w", err)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	clock := getNASClock(client)
+	task := simplifySnapshotTask(created, clock)
+	return marshalJSON(map[string]interface{}{
+		"created":  true,
+		"task":     task,
+		"warnings": snapshotTaskWarnings(create),
+		"message":  fmt.Sprintf("Periodic snapshot task created for %s (%s, %s). Next run: %s", dataset, task["schedule_human"], strings.ToLower(task["retention"].(map[string]interface{})["human"].(string)), task["next_run"]),
+	})
+}
+
+func handleUpdateSnapshotTask(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	existing, err := getSnapshotTask(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	update, err := snapshotTaskUpdate(existing, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("pool.snapshottask.update", id, update)
+	if err != nil {
+		return "", fmt.Errorf("failed to update snapshot task: %w", err)
+	}
+
+	var updated map[string]interface{}
+	if err := json.Unmarshal(result, &updated); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	clock := getNASClock(client)
+	task := simplifySnapshotTask(updated, clock)
+	return marshalJSON(map[string]interface{}{
+		"updated": true,
+		"task":    task,
+		"message": fmt.Sprintf("Periodic snapshot task %d for %v updated. Next run: %s", id, existing["dataset"], task["next_run"]),
+	})
+}
+
+func handleDeleteSnapshotTask(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	existing, err := getSnapshotTask(client, id)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("pool.snapshottask.delete", id); err != nil {
+		return "", fmt.Errorf("failed to delete snapshot task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"deleted": true,
+		"id":      id,
+		"dataset": existing["dataset"],
+		"message": fmt.Sprintf("Periodic snapshot task %d for %v deleted. Snapshots it already took are kept and no longer expire automatically; remove them with delete_snapshot when no longer needed.", id, existing["dataset"]),
+	})
+}
+
+// Dry-run wrappers
+
+func handleCreateSnapshotTaskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createSnapshotTaskDryRun{}, handleCreateSnapshotTask)
+}
+
+func handleUpdateSnapshotTaskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateSnapshotTaskDryRun{}, handleUpdateSnapshotTask)
+}
+
+func handleDeleteSnapshotTaskWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteSnapshotTaskDryRun{}, handleDeleteSnapshotTask)
+}
+
+// Dry-run implementations
+
+type createSnapshotTaskDryRun struct{}
+
+func (c *createSnapshotTaskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := snapshotTaskCreate(args)
+	if err != nil {
+		return nil, err
+	}
+	dataset := create["dataset"].(string)
+
+	exists, err := datasetExists(client, dataset)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, newToolError(ErrorNotFound, "dataset %s not found", dataset)
+	}
+
+	// Other tasks on the same dataset are worth knowing about before adding one
+	result, err := client.Call("pool.snapshottask.query", []interface{}{
+		[]interface{}{"dataset", "=", dataset},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot tasks: %w", err)
+	}
+	var existing []map[string]interface{}
+	if err := json.Unmarshal(result, &existing); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot tasks: %w", err)
+	}
+
+	warnings := snapshotTaskWarnings(create)
+	current := []map[string]interface{}{}
+	clock := getNASClock(client)
+	for _, task := range existing {
+		current = append(current, simplifySnapshotTask(task, clock))
+		if task["naming_schema"] == create["naming_schema"] {
+			warnings = append(warnings, fmt.Sprintf("Task %v on %s uses the same naming schema; the tasks will prune each other's snapshots by the shorter retention", task["id"], dataset))
+		}
+	}
+
+	preview := simplifySnapshotTask(create, clock)
+	delete(preview, "id")
+	delete(preview, "state")
+
+	return &DryRunResult{
+		Tool: "create_snapshot_task",
+		CurrentState: map[string]interface{}{
+			"dataset":        dataset,
+			"existing_tasks": current,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create periodic snapshot task for %s", dataset),
+				Operation:   "create",
+				Target:      dataset,
+				Details:     preview,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type updateSnapshotTaskDryRun struct{}
+
+func (u *updateSnapshotTaskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	existing, err := getSnapshotTask(client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := snapshotTaskUpdate(existing, args)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]interface{}{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	clock := getNASClock(client)
+	before := simplifySnapshotTask(existing, clock)
+	after := simplifySnapshotTask(merged, clock)
+
+	changes := map[string]interface{}{}
+	for _, field := range []string{"recursive", "exclude", "naming_schema", "allow_empty", "enabled"} {
+		if v, ok := update[field]; ok {
+			changes[field] = map[string]interface{}{"old": existing[field], "new": v}
+		}
+	}
+	if _, ok := update["schedule"]; ok {
+		changes["schedule"] = map[string]interface{}{"old": before["schedule_human"], "new": after["schedule_human"]}
+	}
+	_, valueChanged := update["lifetime_value"]
+	_, unitChanged := update["lifetime_unit"]
+	if valueChanged || unitChanged {
+		changes["retention"] = map[string]interface{}{"old": before["retention"], "new": after["retention"]}
+	}
+	changes["next_run"] = map[string]interface{}{"old": before["next_run"], "new": after["next_run"]}
+
+	warnings := snapshotTaskWarnings(merged)
+	oldEnabled, _ := existing["enabled"].(bool)
+	if enabled, ok := update["enabled"].(bool); ok && oldEnabled && !enabled {
+		warnings = append(warnings, fmt.Sprintf("No new snapshots of %v are taken while the task is disabled, and its existing snapshots stop expiring", existing["dataset"]))
+	}
+	if _, ok := update["naming_schema"]; ok {
+		warnings = append(warnings, "Snapshots taken under the old naming schema no longer match this task and are not pruned by its retention")
+	}
+	if (valueChanged || unitChanged) && snapshotTaskLifetime(merged) < snapshotTaskLifetime(existing) {
+		warnings = append(warnings, "Shorter retention: existing snapshots older than the new lifetime are destroyed on the task's next run")
+	}
+
+	return &DryRunResult{
+		Tool: "update_snapshot_task",
+		CurrentState: map[string]interface{}{
+			"task": before,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Update periodic snapshot task %d for %v", id, existing["dataset"]),
+				Operation:   "update",
+				Target:      fmt.Sprintf("%v", existing["dataset"]),
+				Details:     changes,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type deleteSnapshotTaskDryRun struct{}
+
+func (d *deleteSnapshotTaskDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	taskID, ok := args["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("id is required")
+	}
+	id := int(taskID)
+
+	existing, err := getSnapshotTask(client, id)
+	if err != nil {
+		return nil, err
+	}
+	dataset := fmt.Sprintf("%v", existing["dataset"])
+
+	clock := getNASClock(client)
+	return &DryRunResult{
+		Tool: "delete_snapshot_task",
+		CurrentState: map[string]interface{}{
+			"task": simplifySnapshotTask(existing, clock),
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Delete periodic snapshot task %d for %s", id, dataset),
+				Operation:   "delete",
+				Target:      dataset,
+			},
+		},
+		Warnings: []string{
+			fmt.Sprintf("%s will no longer be snapshotted automatically by this task", dataset),
+			"Snapshots the task already took are kept and no longer expire automatically; remove them with delete_snapshot when no longer needed",
+			"Replication tasks that rely on this task's snapshots will have nothing new to send",
+		},
+	}, nil
+}
@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotTaskRetention(t *testing.T) {
+	hourly := map[string]interface{}{"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*"}
+	daily := map[string]interface{}{"minute": "0", "hour": "0", "dom": "*", "month": "*", "dow": "*"}
+	custom := map[string]interface{}{"minute": "*/15", "hour": "*", "dom": "*", "month": "*", "dow": "*"}
+
+	tests := []struct {
+		name     string
+		schedule map[string]interface{}
+		value    int
+		unit     string
+		human    string
+		expected int
+	}{
+		{"hourly for 2 weeks", hourly, 2, "WEEK", "2 weeks", 336},
+		{"daily for 1 month", daily, 1, "MONTH", "1 month", 30},
+		{"custom schedule", custom, 3, "DAY", "3 days", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatRetention(tt.value, tt.unit); got != tt.human {
+				t.Errorf("formatRetention() = %q, want %q", got, tt.human)
+			}
+			if got := expectedSnapshotsRetained(tt.schedule, tt.value, tt.unit); got != tt.expected {
+				t.Errorf("expectedSnapshotsRetained() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCalculateNextRunHourly(t *testing.T) {
+	schedule := map[string]interface{}{"minute": "15", "hour": "*", "dom": "*", "month": "*", "dow": "*"}
+	from := time.Date(2026, 2, 9, 10, 30, 0, 0, time.UTC)
+
+	if got := calculateNextRun(schedule, from); got != "2026-02-09T11:15:00Z" {
+		t.Errorf("calculateNextRun() = %s, want 2026-02-09T11:15:00Z", got)
+	}
+}
+
+func TestValidateNamingSchema(t *testing.T) {
+	if err := validateNamingSchema(defaultSnapshotNamingSchema); err != nil {
+		t.Errorf("default naming schema rejected: %v", err)
+	}
+	for _, schema := range []string{"auto-%Y-%m-%d", "daily/%Y-%m-%d_%H-%M"} {
+		if err := validateNamingSchema(schema); ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("validateNamingSchema(%q) = %v, want VALIDATION", schema, err)
+		}
+	}
+}
+
+func TestIntegrationSnapshotTasks(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{{"id": "tank/data", "name": "tank/data"}})
+	server.SetRecords("pool.snapshottask.query", []map[string]interface{}{
+		{
+			"id":             float64(4),
+			"dataset":        "tank/data",
+			"recursive":      false,
+			"exclude":        []interface{}{},
+			"lifetime_value": float64(2),
+			"lifetime_unit":  "WEEK",
+			"naming_schema":  "auto-%Y-%m-%d_%H-%M",
+			"allow_empty":    true,
+			"enabled":        true,
+			"schedule":       map[string]interface{}{"minute": "0", "hour": "*", "dom": "*", "month": "*", "dow": "*", "begin": "00:00", "end": "23:59"},
+			"state":          map[string]interface{}{"state": "FINISHED"},
+		},
+	})
+	server.Handle("pool.snapshottask.create", func(params []interface{}) (interface{}, error) {
+		created, _ := params[0].(map[string]interface{})
+		created["id"] = float64(5)
+		return created, nil
+	})
+	server.Handle("pool.snapshottask.update", func(params []interface{}) (interface{}, error) {
+		update, _ := params[1].(map[string]interface{})
+		task := map[string]interface{}{"id": params[0], "dataset": "tank/data", "enabled": true}
+		for k, v := range update {
+			task[k] = v
+		}
+		return task, nil
+	})
+	server.SetResult("pool.snapshottask.delete", true)
+
+	result, err := registry.CallTool("query_snapshot_tasks", map[string]interface{}{"dataset": "tank"})
+	if err != nil {
+		t.Fatalf("query_snapshot_tasks failed: %v", err)
+	}
+	tasks := decodeResult(t, result)["snapshot_tasks"].([]interface{})
+	if len(tasks) != 1 {
+		t.Fatalf("snapshot_tasks = %v, want one task", tasks)
+	}
+	task := tasks[0].(map[string]interface{})
+	retention := task["retention"].(map[string]interface{})
+	if task["schedule_human"] != "Hourly at :0" || retention["human"] != "Keep for 2 weeks" || retention["expected_snapshots"] != float64(336) {
+		t.Errorf("task = %v", task)
+	}
+
+	// Creating a task with the same naming schema warns that they prune each other
+	create := map[string]interface{}{
+		"dataset":        "tank/data",
+		"schedule":       map[string]interface{}{"minute": "0", "hour": "0"},
+		"lifetime_value": float64(3),
+		"lifetime_unit":  "month",
+		"dry_run":        true,
+	}
+	result, err = registry.CallTool("create_snapshot_task", create)
+	if err != nil {
+		t.Fatalf("create_snapshot_task dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "same naming schema") || len(server.Calls("pool.snapshottask.create")) != 0 {
+		t.Errorf("create dry run = %s", result)
+	}
+	create["naming_schema"] = "daily-%Y-%m-%d_%H-%M"
+	delete(create, "dry_run")
+	if _, err := registry.CallTool("create_snapshot_task", create); err != nil {
+		t.Fatalf("create_snapshot_task failed: %v", err)
+	}
+	payload := server.Calls("pool.snapshottask.create")[0].Params[0].(map[string]interface{})
+	schedule := payload["schedule"].(map[string]interface{})
+	if payload["lifetime_unit"] != "MONTH" || schedule["hour"] != "0" || schedule["dow"] != "*" {
+		t.Errorf("pool.snapshottask.create payload = %v", payload)
+	}
+	if _, err := registry.CallTool("create_snapshot_task", map[string]interface{}{"dataset": "tank/missing", "schedule": map[string]interface{}{}}); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("create on missing dataset error = %v, want NOT_FOUND", err)
+	}
+
+	// Shortening retention warns that older snapshots are destroyed
+	update := map[string]interface{}{"id": float64(4), "lifetime_value": float64(3), "lifetime_unit": "DAY", "dry_run": true}
+	result, err = registry.CallTool("update_snapshot_task", update)
+	if err != nil {
+		t.Fatalf("update_snapshot_task dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Shorter retention") || len(server.Calls("pool.snapshottask.update")) != 0 {
+		t.Errorf("update dry run = %s", result)
+	}
+	delete(update, "dry_run")
+	if _, err := registry.CallTool("update_snapshot_task", update); err != nil {
+		t.Fatalf("update_snapshot_task failed: %v", err)
+	}
+	sent := server.Calls("pool.snapshottask.update")[0].Params[1].(map[string]interface{})
+	if _, ok := sent["schedule"]; ok || sent["lifetime_unit"] != "DAY" {
+		t.Errorf("pool.snapshottask.update payload = %v, want only retention fields", sent)
+	}
+
+	if _, err := registry.CallTool("delete_snapshot_task", map[string]interface{}{"id": float64(9)}); ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("delete of unknown task error = %v, want NOT_FOUND", err)
+	}
+	if _, err := registry.CallTool("delete_snapshot_task", map[string]interface{}{"id": float64(4)}); err != nil {
+		t.Fatalf("delete_snapshot_task failed: %v", err)
+	}
+	if calls := server.Calls("pool.snapshottask.delete"); len(calls) != 1 || calls[0].Params[0] != float64(4) {
+		t.Errorf("pool.snapshottask.delete calls = %v", calls)
+	}
+}
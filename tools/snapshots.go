@@ -0,0 +1,606 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Snapshot lifecycle: create, delete, rollback, clone
+
+// snapshotNamePattern matches the part of a snapshot ID after the @
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+$`)
+
+// snapshotQueryOptions requests the properties the lifecycle tools report
+var snapshotQueryOptions = map[string]interface{}{
+	"extra": map[string]interface{}{
+		"holds":      true,
+		"properties": []string{"used", "referenced", "creation", "clones"},
+	},
+}
+
+// splitSnapshotID splits "pool/dataset@name" into its dataset and name
+func splitSnapshotID(id string) (string, string, error) {
+	dataset, name, ok := strings.Cut(id, "@")
+	if !ok || dataset == "" || name == "" {
+		return "", "", newToolError(ErrorValidation, "snapshot must be a full snapshot name such as 'tank/media@manual-2025-01-01' (from query_snapshots)")
+	}
+	return dataset, name, nil
+}
+
+// querySnapshots returns snapshots matching filters with their properties
+func querySnapshots(client truenas.Caller, filters []interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call("pool.snapshot.query", filters, snapshotQueryOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+
+	var snapshots []map[string]interface{}
+	if err := json.Unmarshal(result, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// getSnapshot returns a single snapshot by its full name
+func getSnapshot(client truenas.Caller, id string) (map[string]interface{}, error) {
+	if _, _, err := splitSnapshotID(id); err != nil {
+		return nil, err
+	}
+	snapshots, err := querySnapshots(client, []interface{}{
+		[]interface{}{"id", "=", id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, newToolError(ErrorNotFound, "snapshot '%s' not found", id)
+	}
+	return snapshots[0], nil
+}
+
+// snapshotProperties returns a snapshot's requested ZFS properties
+func snapshotProperties(snap map[string]interface{}) map[string]interface{} {
+	props, _ := snap["properties"].(map[string]interface{})
+	return props
+}
+
+// snapshotTxg returns the transaction group a snapshot was created in, which
+// orders the snapshots of a dataset
+func snapshotTxg(snap map[string]interface{}) int64 {
+	switch v := snap["createtxg"].(type) {
+	case string:
+		txg, _ := strconv.ParseInt(v, 10, 64)
+		return txg
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// snapshotClones returns the datasets cloned from a snapshot
+func snapshotClones(snap map[string]interface{}) []string {
+	propMap, _ := snapshotProperties(snap)["clones"].(map[string]interface{})
+	value, _ := propMap["value"].(string)
+	clones := []string{}
+	for _, clone := range strings.Split(value, ",") {
+		if clone = strings.TrimSpace(clone); clone != "" && clone != "-" {
+			clones = append(clones, clone)
+		}
+	}
+	return clones
+}
+
+// snapshotHolds returns the names of user holds on a snapshot
+func snapshotHolds(snap map[string]interface{}) []string {
+	holds, _ := snap["holds"].(map[string]interface{})
+	names := make([]string, 0, len(holds))
+	for name := range holds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// snapshotSummary describes a snapshot for tool responses
+func snapshotSummary(snap map[string]interface{}) map[string]interface{} {
+	summary := simplifySnapshot(snap)
+	props := snapshotProperties(snap)
+	if used := datasetParsedBytes(props, "used"); used > 0 {
+		summary["used"] = units.FormatBytes(used)
+	}
+	if referenced := datasetParsedBytes(props, "referenced"); referenced > 0 {
+		summary["referenced"] = units.FormatBytes(referenced)
+	}
+	if propMap, ok := props["creation"].(map[string]interface{}); ok {
+		summary["created"] = propMap["value"]
+	}
+	if clones := snapshotClones(snap); len(clones) > 0 {
+		summary["clones"] = clones
+	}
+	return summary
+}
+
+// newerSnapshots returns the snapshots of snap's dataset taken after it,
+// oldest first
+func newerSnapshots(client truenas.Caller, snap map[string]interface{}) ([]map[string]interface{}, error) {
+	dataset, _ := snap["dataset"].(string)
+	siblings, err := querySnapshots(client, []interface{}{
+		[]interface{}{"dataset", "=", dataset},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	txg := snapshotTxg(snap)
+	newer := []map[string]interface{}{}
+	for _, sibling := range siblings {
+		if snapshotTxg(sibling) > txg {
+			newer = append(newer, sibling)
+		}
+	}
+	sort.Slice(newer, func(i, j int) bool {
+		return snapshotTxg(newer[i]) < snapshotTxg(newer[j])
+	})
+	return newer, nil
+}
+
+// snapshotIDs returns the full names of snapshots
+func snapshotIDs(snapshots []map[string]interface{}) []string {
+	ids := make([]string, 0, len(snapshots))
+	for _, snap := range snapshots {
+		id, _ := snap["id"].(string)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// create_snapshot
+
+// snapshotCreateArgs validates create_snapshot arguments, defaulting the name
+// to manual-<timestamp>
+func snapshotCreateArgs(client truenas.Caller, args map[string]interface{}) (string, string, bool, error) {
+	dataset, _ := args["dataset"].(string)
+	dataset = strings.Trim(dataset, "/")
+	if dataset == "" {
+		return "", "", false, fmt.Errorf("dataset is required")
+	}
+	name, _ := args["name"].(string)
+	if name == "" {
+		name = "manual-" + time.Now().Format("2006-01-02_15-04")
+	}
+	if !snapshotNamePattern.MatchString(name) {
+		return "", "", false, newToolError(ErrorValidation, "snapshot name may only contain letters, numbers, _, ., :, and -")
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	exists, err := datasetExists(client, dataset)
+	if err != nil {
+		return "", "", false, err
+	}
+	if !exists {
+		return "", "", false, newToolError(ErrorNotFound, "dataset '%s' not found", dataset)
+	}
+	existing, err := querySnapshots(client, []interface{}{
+		[]interface{}{"id", "=", dataset + "@" + name},
+	})
+	if err != nil {
+		return "", "", false, err
+	}
+	if len(existing) > 0 {
+		return "", "", false, newToolError(ErrorValidation, "snapshot '%s@%s' already exists", dataset, name)
+	}
+	return dataset, name, recursive, nil
+}
+
+func handleCreateSnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	dataset, name, recursive, err := snapshotCreateArgs(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("pool.snapshot.create", map[string]interface{}{
+		"dataset":   dataset,
+		"name":      name,
+		"recursive": recursive,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	var snap map[string]interface{}
+	if err := json.Unmarshal(result, &snap); err != nil {
+		return "", fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	response := map[string]interface{}{
+		"created":  true,
+		"snapshot": simplifySnapshot(snap),
+	}
+	if recursive {
+		response["note"] = fmt.Sprintf("Child datasets of %s were snapshotted as @%s too", dataset, name)
+	}
+	return marshalJSON(response)
+}
+
+func handleCreateSnapshotWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createSnapshotDryRun{}, handleCreateSnapshot)
+}
+
+type createSnapshotDryRun struct{}
+
+func (c *createSnapshotDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	dataset, name, recursive, err := snapshotCreateArgs(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Create snapshot %s@%s", dataset, name)
+	warnings := []string{}
+	if recursive {
+		description += " and the same snapshot of every child dataset"
+	}
+	if _, named := args["name"].(string); !named {
+		warnings = append(warnings, fmt.Sprintf("No name given; the snapshot will be named '%s'", name))
+	}
+
+	return &DryRunResult{
+		Tool: "create_snapshot",
+		CurrentState: map[string]interface{}{
+			"dataset": dataset,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: description,
+				Operation:   "create",
+				Target:      dataset + "@" + name,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+// delete_snapshot
+
+// checkSnapshotDeletable refuses deletions ZFS would reject
+func checkSnapshotDeletable(snap map[string]interface{}) error {
+	id, _ := snap["id"].(string)
+	if holds := snapshotHolds(snap); len(holds) > 0 {
+		return newToolError(ErrorPrecondition, "snapshot '%s' has holds (%s), usually from replication; release them before deleting", id, strings.Join(holds, ", "))
+	}
+	if clones := snapshotClones(snap); len(clones) > 0 {
+		return newToolError(ErrorPrecondition, "snapshot '%s' has dependent clones (%s); delete or promote them first", id, strings.Join(clones, ", "))
+	}
+	return nil
+}
+
+func (r *Registry) handleDeleteSnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	id, _ := args["snapshot"].(string)
+	if id == "" {
+		return "", fmt.Errorf("snapshot is required")
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	snap, err := getSnapshot(client, id)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSnapshotDeletable(snap); err != nil {
+		return "", err
+	}
+
+	response := snapshotSummary(snap)
+	if recursive {
+		response["note"] = "Snapshots with the same name on child datasets are deleted too"
+	}
+	return r.deleteOrDefer(client, "delete_snapshot", id, "pool.snapshot.delete",
+		[]interface{}{id, map[string]interface{}{"recursive": recursive}}, response)
+}
+
+func (r *Registry) handleDeleteSnapshotWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteSnapshotDryRun{registry: r}, r.handleDeleteSnapshot)
+}
+
+type deleteSnapshotDryRun struct {
+	registry *Registry
+}
+
+func (d *deleteSnapshotDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	id, _ := args["snapshot"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("snapshot is required")
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	snap, err := getSnapshot(client, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSnapshotDeletable(snap); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Delete snapshot %s", id)
+	if recursive {
+		description += " and snapshots with the same name on child datasets"
+	}
+	warnings := []string{"Files can no longer be restored from this snapshot"}
+	if used := datasetParsedBytes(snapshotProperties(snap), "used"); used > 0 {
+		warnings = append(warnings, fmt.Sprintf("Frees about %s (space unique to this snapshot)", units.FormatBytes(used)))
+	}
+
+	return &DryRunResult{
+		Tool:           "delete_snapshot",
+		CurrentState:   map[string]interface{}{"snapshot": snapshotSummary(snap)},
+		PlannedActions: []PlannedAction{d.registry.deletionPlan(description, id)},
+		Warnings:       warnings,
+	}, nil
+}
+
+// rollback_snapshot
+
+// rollbackPlan checks a rollback can proceed: snapshots taken after the
+// target are destroyed, which must be confirmed, and must be destroyable
+type rollbackPlan struct {
+	snapshot map[string]interface{}
+	newer    []map[string]interface{}
+	clones   []string
+}
+
+func planRollback(client truenas.Caller, args map[string]interface{}) (*rollbackPlan, error) {
+	id, _ := args["snapshot"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("snapshot is required")
+	}
+	destroyNewer, _ := args["destroy_newer"].(bool)
+	destroyClones, _ := args["destroy_clones"].(bool)
+
+	snap, err := getSnapshot(client, id)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := newerSnapshots(client, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &rollbackPlan{snapshot: snap, newer: newer}
+	for _, n := range newer {
+		newerID, _ := n["id"].(string)
+		if holds := snapshotHolds(n); len(holds) > 0 {
+			return nil, newToolError(ErrorPrecondition, "newer snapshot '%s' has holds (%s), usually from replication, and cannot be destroyed by a rollback", newerID, strings.Join(holds, ", "))
+		}
+		plan.clones = append(plan.clones, snapshotClones(n)...)
+	}
+
+	if len(newer) > 0 && !destroyNewer {
+		return nil, newToolError(ErrorPrecondition, "rolling back to '%s' destroys %d newer snapshot(s): %s. Set destroy_newer=true to confirm",
+			id, len(newer), strings.Join(snapshotIDs(newer), ", "))
+	}
+	if len(plan.clones) > 0 && !destroyClones {
+		return nil, newToolError(ErrorPrecondition, "newer snapshots have clones that the rollback would destroy: %s. Set destroy_clones=true to confirm",
+			strings.Join(plan.clones, ", "))
+	}
+	return plan, nil
+}
+
+func handleRollbackSnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planRollback(client, args)
+	if err != nil {
+		return "", err
+	}
+	id, _ := plan.snapshot["id"].(string)
+
+	options := map[string]interface{}{
+		"recursive":        len(plan.newer) > 0,
+		"recursive_clones": len(plan.clones) > 0,
+	}
+	if _, err := client.Call("pool.snapshot.rollback", id, options); err != nil {
+		return "", fmt.Errorf("failed to roll back to %s: %w", id, err)
+	}
+
+	dataset, _ := plan.snapshot["dataset"].(string)
+	response := map[string]interface{}{
+		"rolled_back": true,
+		"dataset":     dataset,
+		"snapshot":    id,
+		"message":     fmt.Sprintf("%s was rolled back to %s", dataset, id),
+	}
+	if len(plan.newer) > 0 {
+		response["destroyed_snapshots"] = snapshotIDs(plan.newer)
+	}
+	if len(plan.clones) > 0 {
+		response["destroyed_clones"] = plan.clones
+	}
+	return marshalJSON(response)
+}
+
+func handleRollbackSnapshotWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &rollbackSnapshotDryRun{}, handleRollbackSnapshot)
+}
+
+type rollbackSnapshotDryRun struct{}
+
+func (r *rollbackSnapshotDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	// Preview what would be destroyed even before it is confirmed
+	preview := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		preview[key] = value
+	}
+	preview["destroy_newer"] = true
+	preview["destroy_clones"] = true
+	plan, err := planRollback(client, preview)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := plan.snapshot["id"].(string)
+	dataset, _ := plan.snapshot["dataset"].(string)
+
+	created := "it was taken"
+	if propMap, ok := snapshotProperties(plan.snapshot)["creation"].(map[string]interface{}); ok {
+		if value, ok := propMap["value"].(string); ok && value != "" {
+			created = value
+		}
+	}
+	warnings := []string{
+		fmt.Sprintf("All changes to %s since %s are permanently lost", dataset, created),
+		"Clients of shares, apps, or VMs using this dataset see their files revert; stop them first",
+	}
+
+	actions := []PlannedAction{}
+	if len(plan.newer) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d newer snapshot(s) are destroyed: %s", len(plan.newer), strings.Join(snapshotIDs(plan.newer), ", ")))
+		if confirmed, _ := args["destroy_newer"].(bool); !confirmed {
+			warnings = append(warnings, "destroy_newer=true is required to run this rollback")
+		}
+		for _, n := range plan.newer {
+			newerID, _ := n["id"].(string)
+			actions = append(actions, PlannedAction{
+				Step:        len(actions) + 1,
+				Description: fmt.Sprintf("Destroy newer snapshot %s", newerID),
+				Operation:   "delete",
+				Target:      newerID,
+			})
+		}
+	}
+	if len(plan.clones) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Clones of newer snapshots are destroyed: %s", strings.Join(plan.clones, ", ")))
+		if confirmed, _ := args["destroy_clones"].(bool); !confirmed {
+			warnings = append(warnings, "destroy_clones=true is required to run this rollback")
+		}
+		for _, clone := range plan.clones {
+			actions = append(actions, PlannedAction{
+				Step:        len(actions) + 1,
+				Description: fmt.Sprintf("Destroy clone %s", clone),
+				Operation:   "delete",
+				Target:      clone,
+			})
+		}
+	}
+	actions = append(actions, PlannedAction{
+		Step:        len(actions) + 1,
+		Description: fmt.Sprintf("Roll back %s to %s", dataset, id),
+		Operation:   "rollback",
+		Target:      dataset,
+	})
+
+	newer := make([]map[string]interface{}, 0, len(plan.newer))
+	for _, n := range plan.newer {
+		newer = append(newer, snapshotSummary(n))
+	}
+	return &DryRunResult{
+		Tool: "rollback_snapshot",
+		CurrentState: map[string]interface{}{
+			"snapshot":          snapshotSummary(plan.snapshot),
+			"newer_snapshots":   newer,
+			"newer_clone_count": len(plan.clones),
+		},
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
+
+// clone_snapshot
+
+// cloneArgs validates clone_snapshot arguments. ZFS clones must stay in the
+// source pool, and the destination's parent must exist.
+func cloneArgs(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, string, error) {
+	id, _ := args["snapshot"].(string)
+	if id == "" {
+		return nil, "", fmt.Errorf("snapshot is required")
+	}
+	destination, _ := args["destination"].(string)
+	destination = strings.Trim(destination, "/")
+	if destination == "" {
+		return nil, "", fmt.Errorf("destination is required")
+	}
+	if err := validateDatasetName(destination); err != nil {
+		return nil, "", newToolError(ErrorValidation, "invalid destination: %v", err)
+	}
+
+	snap, err := getSnapshot(client, id)
+	if err != nil {
+		return nil, "", err
+	}
+	source, _, _ := splitSnapshotID(id)
+	if pool := strings.SplitN(source, "/", 2)[0]; strings.SplitN(destination, "/", 2)[0] != pool {
+		return nil, "", newToolError(ErrorValidation, "a clone must be in the same pool as its snapshot (%s); use replication to copy across pools", pool)
+	}
+
+	exists, err := datasetExists(client, destination)
+	if err != nil {
+		return nil, "", err
+	}
+	if exists {
+		return nil, "", newToolError(ErrorValidation, "dataset '%s' already exists", destination)
+	}
+	parent := destination[:strings.LastIndex(destination, "/")]
+	if exists, err := datasetExists(client, parent); err != nil {
+		return nil, "", err
+	} else if !exists {
+		return nil, "", newToolError(ErrorNotFound, "parent dataset '%s' not found; create it first", parent)
+	}
+	return snap, destination, nil
+}
+
+func handleCloneSnapshot(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	snap, destination, err := cloneArgs(client, args)
+	if err != nil {
+		return "", err
+	}
+	id, _ := snap["id"].(string)
+
+	if _, err := client.Call("pool.snapshot.clone", map[string]interface{}{
+		"snapshot":    id,
+		"dataset_dst": destination,
+	}); err != nil {
+		return "", fmt.Errorf("failed to clone %s: %w", id, err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"cloned":      true,
+		"snapshot":    id,
+		"destination": destination,
+		"mountpoint":  "/mnt/" + destination,
+		"note":        "The clone depends on the snapshot, which cannot be deleted while the clone exists. Files are shared with the snapshot until modified.",
+	})
+}
+
+func handleCloneSnapshotWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &cloneSnapshotDryRun{}, handleCloneSnapshot)
+}
+
+type cloneSnapshotDryRun struct{}
+
+func (c *cloneSnapshotDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	snap, destination, err := cloneArgs(client, args)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := snap["id"].(string)
+
+	return &DryRunResult{
+		Tool:         "clone_snapshot",
+		CurrentState: map[string]interface{}{"snapshot": snapshotSummary(snap)},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create dataset %s as a writable clone of %s", destination, id),
+				Operation:   "create",
+				Target:      destination,
+			},
+		},
+		Warnings: []string{
+			fmt.Sprintf("%s cannot be deleted while the clone exists", id),
+		},
+	}, nil
+}
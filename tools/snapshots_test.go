@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationSnapshotLifecycle(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank", "name": "tank"},
+		{"id": "tank/media", "name": "tank/media"},
+	})
+	snapshot := func(name, txg string, holds map[string]interface{}, clones string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": "tank/media@" + name, "name": "tank/media@" + name, "snapshot_name": name,
+			"dataset": "tank/media", "pool": "tank", "createtxg": txg, "holds": holds,
+			"properties": map[string]interface{}{
+				"used":     map[string]interface{}{"parsed": float64(1 << 30)},
+				"creation": map[string]interface{}{"value": "Wed Jan  1 12:00 2025"},
+				"clones":   map[string]interface{}{"value": clones},
+			},
+		}
+	}
+	server.SetRecords("pool.snapshot.query", []map[string]interface{}{
+		snapshot("base", "100", map[string]interface{}{}, ""),
+		snapshot("middle", "200", map[string]interface{}{}, ""),
+		snapshot("latest", "300", map[string]interface{}{"replication": float64(1)}, ""),
+		snapshot("cloned", "50", map[string]interface{}{}, "tank/media-test"),
+	})
+	server.SetResult("pool.snapshot.create", map[string]interface{}{"id": "tank/media@manual", "snapshot_name": "manual", "dataset": "tank/media", "pool": "tank"})
+	server.SetResult("pool.snapshot.delete", true)
+	server.SetResult("pool.snapshot.rollback", nil)
+	server.SetResult("pool.snapshot.clone", true)
+
+	// create_snapshot names unnamed snapshots and refuses duplicates
+	if _, err := registry.CallTool("create_snapshot", map[string]interface{}{"dataset": "tank/media"}); err != nil {
+		t.Fatalf("create_snapshot failed: %v", err)
+	}
+	created := server.Calls("pool.snapshot.create")[0].Params[0].(map[string]interface{})
+	if name, _ := created["name"].(string); !strings.HasPrefix(name, "manual-") {
+		t.Errorf("default snapshot name = %q, want manual-<timestamp>", name)
+	}
+	if _, err := registry.CallTool("create_snapshot", map[string]interface{}{"dataset": "tank/media", "name": "base"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("duplicate snapshot error = %v, want VALIDATION", err)
+	}
+
+	// delete_snapshot refuses held and cloned snapshots
+	for _, id := range []string{"tank/media@latest", "tank/media@cloned"} {
+		if _, err := registry.CallTool("delete_snapshot", map[string]interface{}{"snapshot": id}); ClassifyError(err).Code != ErrorPrecondition {
+			t.Errorf("delete_snapshot(%s) error = %v, want PRECONDITION_FAILED", id, err)
+		}
+	}
+	if _, err := registry.CallTool("delete_snapshot", map[string]interface{}{"snapshot": "tank/media@middle"}); err != nil {
+		t.Fatalf("delete_snapshot failed: %v", err)
+	}
+	if calls := server.Calls("pool.snapshot.delete"); len(calls) != 1 || calls[0].Params[0] != "tank/media@middle" {
+		t.Errorf("pool.snapshot.delete calls = %v", calls)
+	}
+
+	// rollback_snapshot needs confirmation to destroy newer snapshots and
+	// cannot destroy held ones
+	_, err := registry.CallTool("rollback_snapshot", map[string]interface{}{"snapshot": "tank/media@base", "dry_run": true})
+	if toolErr := ClassifyError(err); toolErr.Code != ErrorPrecondition || !strings.Contains(toolErr.Message, "tank/media@latest") {
+		t.Errorf("rollback past a held snapshot error = %v, want PRECONDITION_FAILED naming the held snapshot", err)
+	}
+	server.SetRecords("pool.snapshot.query", []map[string]interface{}{
+		snapshot("base", "100", map[string]interface{}{}, ""),
+		snapshot("middle", "200", map[string]interface{}{}, ""),
+	})
+	result, err := registry.CallTool("rollback_snapshot", map[string]interface{}{"snapshot": "tank/media@base", "dry_run": true})
+	if err != nil {
+		t.Fatalf("rollback dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Destroy newer snapshot tank/media@middle") || !strings.Contains(result, "destroy_newer=true is required") {
+		t.Errorf("rollback dry run = %s", result)
+	}
+	if _, err := registry.CallTool("rollback_snapshot", map[string]interface{}{"snapshot": "tank/media@base"}); ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("unconfirmed rollback error = %v, want PRECONDITION_FAILED", err)
+	}
+	if _, err := registry.CallTool("rollback_snapshot", map[string]interface{}{"snapshot": "tank/media@base", "destroy_newer": true}); err != nil {
+		t.Fatalf("rollback_snapshot failed: %v", err)
+	}
+	calls := server.Calls("pool.snapshot.rollback")
+	if len(calls) != 1 || calls[0].Params[1].(map[string]interface{})["recursive"] != true {
+		t.Errorf("pool.snapshot.rollback calls = %v, want one with recursive", calls)
+	}
+
+	// clone_snapshot stays within the pool
+	if _, err := registry.CallTool("clone_snapshot", map[string]interface{}{"snapshot": "tank/media@base", "destination": "backup/restore"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("cross-pool clone error = %v, want VALIDATION", err)
+	}
+	if _, err := registry.CallTool("clone_snapshot", map[string]interface{}{"snapshot": "tank/media@base", "destination": "tank/media-restore"}); err != nil {
+		t.Fatalf("clone_snapshot failed: %v", err)
+	}
+	clone := server.Calls("pool.snapshot.clone")[0].Params[0].(map[string]interface{})
+	if clone["snapshot"] != "tank/media@base" || clone["dataset_dst"] != "tank/media-restore" {
+		t.Errorf("pool.snapshot.clone payload = %v", clone)
+	}
+}
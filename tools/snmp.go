@@ -0,0 +1,313 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// SNMP service configuration
+
+// defaultSNMPCommunity is the well-known community string TrueNAS ships with
+const defaultSNMPCommunity = "public"
+
+// minSNMPv3SecretLength is the net-snmp minimum for v3 passwords and passphrases
+const minSNMPv3SecretLength = 8
+
+var (
+	snmpAuthTypes     = map[string]bool{"SHA": true, "MD5": true}
+	snmpPrivProtocols = map[string]bool{"AES": true, "DES": true}
+)
+
+// getSNMPConfig returns snmp.config
+func getSNMPConfig(client truenas.Caller) (map[string]interface{}, error) {
+	result, err := client.Call("snmp.config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SNMP configuration: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse SNMP configuration: %w", err)
+	}
+	return config, nil
+}
+
+// getService returns the service.query entry for a service such as "snmp"
+func getService(client truenas.Caller, name string) (map[string]interface{}, error) {
+	result, err := client.Call("service.query", []interface{}{
+		[]interface{}{"service", "=", name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s service: %w", name, err)
+	}
+
+	var services []map[string]interface{}
+	if err := json.Unmarshal(result, &services); err != nil {
+		return nil, fmt.Errorf("failed to parse services: %w", err)
+	}
+	if len(services) == 0 {
+		return nil, newToolError(ErrorNotFound, "%s service not found", name)
+	}
+	return services[0], nil
+}
+
+// simplifySNMPConfig summarizes the SNMP configuration and service state.
+// v3 secrets are reported only as set or not; the community is masked by
+// output redaction.
+func simplifySNMPConfig(config, service map[string]interface{}) map[string]interface{} {
+	community, _ := config["community"].(string)
+	summary := map[string]interface{}{
+		"location":             config["location"],
+		"contact":              config["contact"],
+		"community":            community,
+		"community_is_default": community == defaultSNMPCommunity,
+		"traps":                config["traps"],
+		"v3": map[string]interface{}{
+			"enabled":        config["v3"],
+			"username":       config["v3_username"],
+			"auth_type":      config["v3_authtype"],
+			"password_set":   config["v3_password"] != nil && config["v3_password"] != "",
+			"privacy":        config["v3_privproto"],
+			"passphrase_set": config["v3_privpassphrase"] != nil && config["v3_privpassphrase"] != "",
+		},
+	}
+	if service != nil {
+		summary["service"] = map[string]interface{}{
+			"state":         service["state"],
+			"start_on_boot": service["enable"],
+		}
+	}
+	return summary
+}
+
+// snmpUpdate builds the snmp.update payload from args, checking the result
+// leaves a usable configuration
+func snmpUpdate(config map[string]interface{}, args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	for _, field := range []string{"location", "contact"} {
+		if value, ok := args[field].(string); ok {
+			update[field] = value
+		}
+	}
+	if community, ok := args["community"].(string); ok {
+		if community == "" || strings.ContainsAny(community, " \t\"'\\#") {
+			return nil, newToolError(ErrorValidation, "community must be non-empty and contain no spaces, quotes, backslashes, or #")
+		}
+		update["community"] = community
+	}
+
+	if v3, ok := args["v3"].(bool); ok {
+		update["v3"] = v3
+	}
+	if username, ok := args["v3_username"].(string); ok {
+		update["v3_username"] = username
+	}
+	if authType, ok := args["v3_authtype"].(string); ok {
+		authType = strings.ToUpper(authType)
+		if !snmpAuthTypes[authType] {
+			return nil, newToolError(ErrorValidation, "v3_authtype must be SHA or MD5")
+		}
+		update["v3_authtype"] = authType
+	}
+	if password, ok := args["v3_password"].(string); ok {
+		if len(password) < minSNMPv3SecretLength {
+			return nil, newToolError(ErrorValidation, "v3_password must be at least %d characters", minSNMPv3SecretLength)
+		}
+		update["v3_password"] = password
+	}
+	if privProto, ok := args["v3_privproto"].(string); ok {
+		privProto = strings.ToUpper(privProto)
+		if privProto == "" || privProto == "NONE" {
+			update["v3_privproto"] = nil
+		} else if snmpPrivProtocols[privProto] {
+			update["v3_privproto"] = privProto
+		} else {
+			return nil, newToolError(ErrorValidation, "v3_privproto must be AES, DES, or none")
+		}
+	}
+	if passphrase, ok := args["v3_privpassphrase"].(string); ok {
+		if len(passphrase) < minSNMPv3SecretLength {
+			return nil, newToolError(ErrorValidation, "v3_privpassphrase must be at least %d characters", minSNMPv3SecretLength)
+		}
+		update["v3_privpassphrase"] = passphrase
+	}
+
+	// v3 needs a user and password once it is on, whether they are new or kept
+	merged := func(field string) string {
+		if value, ok := update[field]; ok {
+			s, _ := value.(string)
+			return s
+		}
+		s, _ := config[field].(string)
+		return s
+	}
+	v3, ok := update["v3"].(bool)
+	if !ok {
+		v3, _ = config["v3"].(bool)
+	}
+	if v3 {
+		if merged("v3_username") == "" || merged("v3_password") == "" {
+			return nil, newToolError(ErrorValidation, "SNMPv3 requires v3_username and v3_password")
+		}
+		if merged("v3_privproto") != "" && merged("v3_privpassphrase") == "" {
+			return nil, newToolError(ErrorValidation, "v3_privproto requires v3_privpassphrase")
+		}
+	}
+
+	_, serviceChange := args["enable_service"].(bool)
+	if len(update) == 0 && !serviceChange {
+		return nil, fmt.Errorf("nothing to update: provide SNMP settings or enable_service")
+	}
+	return update, nil
+}
+
+// snmpWarnings flags weak configurations the update would leave in place
+func snmpWarnings(config, update map[string]interface{}, enableService *bool) []string {
+	warnings := []string{}
+	community, ok := update["community"].(string)
+	if !ok {
+		community, _ = config["community"].(string)
+	}
+	v3, ok := update["v3"].(bool)
+	if !ok {
+		v3, _ = config["v3"].(bool)
+	}
+	if community == defaultSNMPCommunity {
+		warnings = append(warnings, "The community string is the well-known default 'public'; set a unique community so other hosts cannot read system data")
+	}
+	if !v3 {
+		warnings = append(warnings, "SNMPv1/v2c sends the community string in clear text; enable v3 with authentication and privacy on untrusted networks")
+	}
+	if enableService != nil && !*enableService {
+		warnings = append(warnings, "Monitoring systems polling this host over SNMP will stop receiving data")
+	}
+	return warnings
+}
+
+func handleGetSNMPConfig(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getSNMPConfig(client)
+	if err != nil {
+		return "", err
+	}
+	service, err := getService(client, "snmp")
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(simplifySNMPConfig(config, service))
+}
+
+func handleUpdateSNMPConfig(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getSNMPConfig(client)
+	if err != nil {
+		return "", err
+	}
+	update, err := snmpUpdate(config, args)
+	if err != nil {
+		return "", err
+	}
+
+	if len(update) > 0 {
+		result, err := client.Call("snmp.update", update)
+		if err != nil {
+			return "", fmt.Errorf("failed to update SNMP configuration: %w", err)
+		}
+		if err := json.Unmarshal(result, &config); err != nil {
+			return "", fmt.Errorf("failed to parse result: %w", err)
+		}
+	}
+
+	// Start on boot and running state follow enable_service together
+	if enable, ok := args["enable_service"].(bool); ok {
+		if _, err := client.Call("service.update", "snmp", map[string]interface{}{"enable": enable}); err != nil {
+			return "", fmt.Errorf("failed to set SNMP service start on boot: %w", err)
+		}
+		method, action := "service.start", "started"
+		if !enable {
+			method, action = "service.stop", "stopped"
+		}
+		if _, err := client.Call(method, "snmp"); err != nil {
+			return "", fmt.Errorf("SNMP settings were saved but the service could not be %s: %w", action, err)
+		}
+	}
+
+	service, err := getService(client, "snmp")
+	if err != nil {
+		return "", err
+	}
+	response := simplifySNMPConfig(config, service)
+	response["updated"] = true
+	if enable, ok := args["enable_service"].(bool); ok && !enable {
+		response["message"] = "SNMP service stopped and disabled on boot"
+	} else if state, _ := service["state"].(string); state != "RUNNING" {
+		response["note"] = "The SNMP service is not running; set enable_service=true to start it"
+	}
+	return marshalJSON(response)
+}
+
+func handleUpdateSNMPConfigWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateSNMPConfigDryRun{}, handleUpdateSNMPConfig)
+}
+
+type updateSNMPConfigDryRun struct{}
+
+func (u *updateSNMPConfigDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	config, err := getSNMPConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	service, err := getService(client, "snmp")
+	if err != nil {
+		return nil, err
+	}
+	update, err := snmpUpdate(config, args)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{}
+	if len(update) > 0 {
+		changes := map[string]interface{}{}
+		for field, value := range update {
+			// Output redaction masks strings, not old/new pairs
+			if isSensitiveKey(field) {
+				changes[field] = map[string]interface{}{"changed": true}
+				continue
+			}
+			changes[field] = map[string]interface{}{"old": config[field], "new": value}
+		}
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: "Update the SNMP configuration",
+			Operation:   "update",
+			Target:      "snmp",
+			Details:     changes,
+		})
+	}
+
+	var enableService *bool
+	if enable, ok := args["enable_service"].(bool); ok {
+		enableService = &enable
+		description := "Enable the SNMP service on boot and start it"
+		if !enable {
+			description = "Disable the SNMP service on boot and stop it"
+		}
+		actions = append(actions, PlannedAction{
+			Step:        len(actions) + 1,
+			Description: description,
+			Operation:   "update",
+			Target:      "service:snmp",
+		})
+	}
+
+	return &DryRunResult{
+		Tool:           "update_snmp_config",
+		CurrentState:   simplifySNMPConfig(config, service),
+		PlannedActions: actions,
+		Warnings:       snmpWarnings(config, update, enableService),
+	}, nil
+}
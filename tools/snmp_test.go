@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationSNMPConfig(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("snmp.config", map[string]interface{}{
+		"id": float64(1), "location": "", "contact": "", "community": "public", "traps": false,
+		"v3": false, "v3_username": "", "v3_authtype": "SHA", "v3_password": "", "v3_privproto": nil, "v3_privpassphrase": nil,
+	})
+	server.SetRecords("service.query", []map[string]interface{}{{"service": "snmp", "state": "STOPPED", "enable": false}})
+	server.SetResult("snmp.update", map[string]interface{}{
+		"id": float64(1), "location": "Rack 4", "contact": "ops@example.com", "community": "public", "traps": false,
+		"v3": true, "v3_username": "monitor", "v3_authtype": "SHA", "v3_password": "s3cretpass", "v3_privproto": "AES", "v3_privpassphrase": "s3cretphrase",
+	})
+	server.SetResult("service.update", float64(1))
+	server.SetResult("service.start", true)
+
+	result, err := registry.CallTool("get_snmp_config", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_snmp_config failed: %v", err)
+	}
+	config := decodeResult(t, result)
+	if config["community"] != redactedValue || config["community_is_default"] != true {
+		t.Errorf("community = %v (default %v), want it masked and flagged as default", config["community"], config["community_is_default"])
+	}
+
+	if _, err := registry.CallTool("update_snmp_config", map[string]interface{}{"v3": true, "v3_username": "monitor"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("v3 without a password error = %v, want VALIDATION", err)
+	}
+
+	args := map[string]interface{}{
+		"location": "Rack 4", "contact": "ops@example.com",
+		"v3": true, "v3_username": "monitor", "v3_password": "s3cretpass", "v3_privproto": "aes", "v3_privpassphrase": "s3cretphrase",
+		"enable_service": true,
+	}
+	args["dry_run"] = true
+	result, err = registry.CallTool("update_snmp_config", args)
+	if err != nil {
+		t.Fatalf("update_snmp_config dry run failed: %v", err)
+	}
+	if strings.Contains(result, "s3cret") || !strings.Contains(result, "'public'") {
+		t.Errorf("dry run should mask secrets and warn about the default community: %s", result)
+	}
+	if calls := server.Calls("snmp.update"); len(calls) != 0 {
+		t.Fatalf("dry run called snmp.update: %v", calls)
+	}
+
+	delete(args, "dry_run")
+	if _, err := registry.CallTool("update_snmp_config", args); err != nil {
+		t.Fatalf("update_snmp_config failed: %v", err)
+	}
+	payload := server.Calls("snmp.update")[0].Params[0].(map[string]interface{})
+	if payload["v3_privproto"] != "AES" || payload["location"] != "Rack 4" {
+		t.Errorf("snmp.update payload = %v", payload)
+	}
+	if _, ok := payload["enable_service"]; ok {
+		t.Errorf("snmp.update payload includes enable_service: %v", payload)
+	}
+	update := server.Calls("service.update")
+	if len(update) != 1 || update[0].Params[0] != "snmp" || update[0].Params[1].(map[string]interface{})["enable"] != true {
+		t.Errorf("service.update calls = %v, want snmp enabled on boot", update)
+	}
+	if calls := server.Calls("service.start"); len(calls) != 1 {
+		t.Errorf("service.start calls = %v, want one", calls)
+	}
+}
@@ -0,0 +1,319 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Storage health report handler
+
+// scrubOverdueDays is the age after which a pool's last scrub is flagged
+const scrubOverdueDays = 35
+
+func handleStorageHealthReport(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	poolFilter, _ := args["pool"].(string)
+
+	poolsResult, err := client.Call("pool.query", []interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("failed to query pools: %w", err)
+	}
+
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(poolsResult, &pools); err != nil {
+		return "", fmt.Errorf("failed to parse pools: %w", err)
+	}
+
+	if poolFilter != "" {
+		filtered := []map[string]interface{}{}
+		for _, pool := range pools {
+			if name, _ := pool["name"].(string); name == poolFilter {
+				filtered = append(filtered, pool)
+			}
+		}
+		if len(filtered) == 0 {
+			return "", fmt.Errorf("pool not found: %s", poolFilter)
+		}
+		pools = filtered
+	}
+
+	// Supporting data is best-effort; a failure degrades the report instead of failing it
+	notes := []string{}
+
+	smartByDisk := map[string]map[string]interface{}{}
+	if result, err := client.Call("smart.test.results"); err == nil {
+		var disks []map[string]interface{}
+		if err := json.Unmarshal(result, &disks); err == nil {
+			for _, disk := range disks {
+				if name, ok := disk["disk"].(string); ok {
+					smartByDisk[name] = disk
+				}
+			}
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("SMART results unavailable: %v", err))
+	}
+
+	var alerts []map[string]interface{}
+	if result, err := client.Call("alert.list"); err == nil {
+		if err := json.Unmarshal(result, &alerts); err != nil {
+			notes = append(notes, fmt.Sprintf("failed to parse alerts: %v", err))
+		}
+	} else {
+		notes = append(notes, fmt.Sprintf("alerts unavailable: %v", err))
+	}
+
+	reports := make([]map[string]interface{}, 0, len(pools))
+	overall := "HEALTHY"
+	for _, pool := range pools {
+		report := buildPoolHealthReport(pool, smartByDisk, alerts, time.Now())
+		reports = append(reports, report)
+		overall = worseHealth(overall, report["health"].(string))
+	}
+
+	response := map[string]interface{}{
+		"overall_health": overall,
+		"pools":          reports,
+		"pool_count":     len(reports),
+	}
+	if len(notes) > 0 {
+		response["collection_notes"] = notes
+	}
+
+	return marshalJSON(response)
+}
+
+// buildPoolHealthReport merges pool status, scrub, member disk SMART results,
+// related alerts, and capacity into a single report with an overall verdict
+func buildPoolHealthReport(pool map[string]interface{}, smartByDisk map[string]map[string]interface{}, alerts []map[string]interface{}, now time.Time) map[string]interface{} {
+	poolName, _ := pool["name"].(string)
+	status, _ := pool["status"].(string)
+	healthy, _ := pool["healthy"].(bool)
+
+	health := "HEALTHY"
+	issues := []string{}
+	flag := func(level, issue string) {
+		health = worseHealth(health, level)
+		issues = append(issues, issue)
+	}
+
+	if !healthy || (status != "" && status != "ONLINE") {
+		flag("CRITICAL", fmt.Sprintf("Pool status is %s", status))
+	}
+
+	// Member disks with vdev error counters and SMART results
+	disks := collectPoolDisks(pool)
+	diskNames := make([]string, 0, len(disks))
+	for _, disk := range disks {
+		name, _ := disk["disk"].(string)
+		diskNames = append(diskNames, name)
+
+		// Hot spares report AVAIL while idle
+		if diskStatus, _ := disk["status"].(string); diskStatus != "" && diskStatus != "ONLINE" && diskStatus != "AVAIL" {
+			flag("CRITICAL", fmt.Sprintf("Disk %s is %s", name, diskStatus))
+		}
+		if errs, _ := disk["errors"].(int); errs > 0 {
+			flag("WARNING", fmt.Sprintf("Disk %s has %d read/write/checksum errors", name, errs))
+		}
+
+		smart := map[string]interface{}{"tested": false}
+		if result, ok := smartByDisk[name]; ok {
+			if tests, ok := result["tests"].([]interface{}); ok && len(tests) > 0 {
+				// Tests are returned newest first
+				if latest, ok := tests[0].(map[string]interface{}); ok {
+					testStatus, _ := latest["status"].(string)
+					smart = map[string]interface{}{
+						"tested":      true,
+						"last_status": testStatus,
+						"last_type":   latest["type"],
+					}
+					if testStatus != "" && testStatus != "SUCCESS" && testStatus != "RUNNING" {
+						flag("CRITICAL", fmt.Sprintf("Latest SMART test on %s reported %s", name, testStatus))
+					}
+				}
+			}
+		}
+		disk["smart"] = smart
+	}
+
+	// Last scrub
+	var scrub map[string]interface{}
+	if scan, ok := pool["scan"].(map[string]interface{}); ok {
+		if fn, _ := scan["function"].(string); fn == "SCRUB" {
+			state, _ := scan["state"].(string)
+			errors, _ := scan["errors"].(float64)
+			scrub = map[string]interface{}{
+				"state":  state,
+				"errors": int(errors),
+			}
+			if endTime, ok := scan["end_time"].(map[string]interface{}); ok {
+				if endMs, ok := endTime["$date"].(float64); ok {
+					completed := time.UnixMilli(int64(endMs))
+					daysAgo := int(now.Sub(completed).Hours() / 24)
+					scrub["completed"] = completed.Format(time.RFC3339)
+					scrub["days_ago"] = daysAgo
+					if daysAgo > scrubOverdueDays {
+						flag("WARNING", fmt.Sprintf("Last scrub was %d days ago", daysAgo))
+					}
+				}
+			}
+			if errors > 0 {
+				flag("WARNING", fmt.Sprintf("Last scrub found %d errors", int(errors)))
+			}
+		}
+	}
+	if scrub == nil {
+		flag("WARNING", "No scrub has been recorded for this pool")
+	}
+
+	// Capacity
+	capacity := calculatePoolCapacity(pool)
+	if utilPct, ok := capacity["utilization_pct"].(float64); ok {
+		capacityStatus := determineCapacityStatus(utilPct, 70.0, 85.0)
+		capacity["status"] = capacityStatus
+		switch capacityStatus {
+		case "critical":
+			flag("CRITICAL", fmt.Sprintf("Pool is %.1f%% full", utilPct))
+		case "warning":
+			flag("WARNING", fmt.Sprintf("Pool is %.1f%% full", utilPct))
+		}
+	}
+
+	// Alerts that mention this pool or one of its disks
+	related := relatedStorageAlerts(alerts, poolName, diskNames)
+	for _, alert := range related {
+		level, _ := alert["level"].(string)
+		message, _ := alert["message"].(string)
+		switch level {
+		case "CRITICAL", "ALERT", "EMERGENCY":
+			flag("CRITICAL", fmt.Sprintf("%s alert: %s", level, message))
+		case "WARNING", "ERROR":
+			flag("WARNING", fmt.Sprintf("%s alert: %s", level, message))
+		}
+	}
+
+	return map[string]interface{}{
+		"name":       poolName,
+		"health":     health,
+		"issues":     issues,
+		"status":     status,
+		"healthy":    healthy,
+		"disks":      disks,
+		"last_scrub": scrub,
+		"capacity":   capacity,
+		"alerts":     related,
+	}
+}
+
+// collectPoolDisks walks every topology group of a pool and returns its leaf disks
+// with vdev role, status, and summed error counters
+func collectPoolDisks(pool map[string]interface{}) []map[string]interface{} {
+	disks := []map[string]interface{}{}
+	topology, ok := pool["topology"].(map[string]interface{})
+	if !ok {
+		return disks
+	}
+
+	var walk func(vdev map[string]interface{}, role string)
+	walk = func(vdev map[string]interface{}, role string) {
+		children, _ := vdev["children"].([]interface{})
+		if len(children) == 0 {
+			name, _ := vdev["disk"].(string)
+			if name == "" {
+				name, _ = vdev["name"].(string)
+			}
+			errors := 0
+			if stats, ok := vdev["stats"].(map[string]interface{}); ok {
+				for _, key := range []string{"read_errors", "write_errors", "checksum_errors"} {
+					if n, ok := stats[key].(float64); ok {
+						errors += int(n)
+					}
+				}
+			}
+			disks = append(disks, map[string]interface{}{
+				"disk":   name,
+				"role":   role,
+				"status": vdev["status"],
+				"errors": errors,
+			})
+			return
+		}
+		for _, child := range children {
+			if c, ok := child.(map[string]interface{}); ok {
+				walk(c, role)
+			}
+		}
+	}
+
+	roles := make([]string, 0, len(topology))
+	for role := range topology {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		vdevs, _ := topology[role].([]interface{})
+		for _, v := range vdevs {
+			if vdev, ok := v.(map[string]interface{}); ok {
+				walk(vdev, role)
+			}
+		}
+	}
+
+	return disks
+}
+
+// relatedStorageAlerts returns active alerts whose text mentions the pool or any member disk
+func relatedStorageAlerts(alerts []map[string]interface{}, poolName string, diskNames []string) []map[string]interface{} {
+	related := []map[string]interface{}{}
+	for _, alert := range alerts {
+		if dismissed, _ := alert["dismissed"].(bool); dismissed {
+			continue
+		}
+		text, _ := alert["formatted"].(string)
+		if !mentionsStorage(text, poolName, diskNames) {
+			continue
+		}
+		related = append(related, map[string]interface{}{
+			"uuid":    alert["uuid"],
+			"level":   alert["level"],
+			"klass":   alert["klass"],
+			"message": text,
+		})
+	}
+	return related
+}
+
+// mentionsStorage reports whether text names the pool or one of its disks as a whole word
+func mentionsStorage(text, poolName string, diskNames []string) bool {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !(r == '-' || r == '_' || r == '.' || r == '/' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+	})
+	for _, word := range words {
+		word = strings.Trim(word, "./")
+		if poolName != "" && (word == poolName || strings.HasPrefix(word, poolName+"/")) {
+			return true
+		}
+		for _, disk := range diskNames {
+			if disk != "" && (word == disk || strings.HasSuffix(word, "/"+disk)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// worseHealth returns the more severe of two health verdicts
+func worseHealth(a, b string) string {
+	rank := map[string]int{"HEALTHY": 0, "WARNING": 1, "CRITICAL": 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectPoolDisks(t *testing.T) {
+	pool := map[string]interface{}{
+		"topology": map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{
+					"type": "MIRROR",
+					"children": []interface{}{
+						map[string]interface{}{"disk": "sda", "status": "ONLINE", "stats": map[string]interface{}{"read_errors": float64(0)}},
+						map[string]interface{}{"disk": "sdb", "status": "FAULTED", "stats": map[string]interface{}{"read_errors": float64(2), "checksum_errors": float64(3)}},
+					},
+				},
+			},
+			"cache": []interface{}{
+				map[string]interface{}{"disk": "nvme0n1", "status": "ONLINE"},
+			},
+		},
+	}
+
+	disks := collectPoolDisks(pool)
+	if len(disks) != 3 {
+		t.Fatalf("got %d disks, want 3", len(disks))
+	}
+
+	byName := map[string]map[string]interface{}{}
+	for _, d := range disks {
+		byName[d["disk"].(string)] = d
+	}
+	if byName["sdb"]["errors"] != 5 {
+		t.Errorf("sdb errors = %v, want 5", byName["sdb"]["errors"])
+	}
+	if byName["nvme0n1"]["role"] != "cache" {
+		t.Errorf("nvme0n1 role = %v, want cache", byName["nvme0n1"]["role"])
+	}
+}
+
+func TestMentionsStorage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "pool name", text: "Pool tank state is DEGRADED", expected: true},
+		{name: "child dataset", text: "Quota exceeded on tank/media.", expected: true},
+		{name: "device path", text: "Device: /dev/sda, 8 Currently unreadable sectors", expected: true},
+		{name: "substring only", text: "Pool tanker state is ONLINE", expected: false},
+		{name: "unrelated", text: "System update available", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mentionsStorage(tt.text, "tank", []string{"sda"}); got != tt.expected {
+				t.Errorf("mentionsStorage(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildPoolHealthReport(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	recentScrub := float64(now.Add(-3 * 24 * time.Hour).UnixMilli())
+	oldScrub := float64(now.Add(-60 * 24 * time.Hour).UnixMilli())
+
+	basePool := func(scrubEnd float64, allocated float64) map[string]interface{} {
+		return map[string]interface{}{
+			"name":      "tank",
+			"status":    "ONLINE",
+			"healthy":   true,
+			"allocated": allocated,
+			"free":      100 - allocated,
+			"scan": map[string]interface{}{
+				"function": "SCRUB",
+				"state":    "FINISHED",
+				"errors":   float64(0),
+				"end_time": map[string]interface{}{"$date": scrubEnd},
+			},
+			"topology": map[string]interface{}{
+				"data": []interface{}{
+					map[string]interface{}{"disk": "sda", "status": "ONLINE"},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pool     map[string]interface{}
+		smart    map[string]map[string]interface{}
+		alerts   []map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "healthy",
+			pool:     basePool(recentScrub, 40),
+			expected: "HEALTHY",
+		},
+		{
+			name:     "scrub overdue",
+			pool:     basePool(oldScrub, 40),
+			expected: "WARNING",
+		},
+		{
+			name:     "nearly full",
+			pool:     basePool(recentScrub, 90),
+			expected: "CRITICAL",
+		},
+		{
+			name: "failed SMART test",
+			pool: basePool(recentScrub, 40),
+			smart: map[string]map[string]interface{}{
+				"sda": {"disk": "sda", "tests": []interface{}{map[string]interface{}{"status": "FAILED"}}},
+			},
+			expected: "CRITICAL",
+		},
+		{
+			name: "related warning alert",
+			pool: basePool(recentScrub, 40),
+			alerts: []map[string]interface{}{
+				{"level": "WARNING", "formatted": "Device /dev/sda is causing slow I/O"},
+				{"level": "CRITICAL", "formatted": "Unrelated", "dismissed": false},
+			},
+			expected: "WARNING",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := buildPoolHealthReport(tt.pool, tt.smart, tt.alerts, now)
+			if report["health"] != tt.expected {
+				t.Errorf("health = %v, want %v (issues: %v)", report["health"], tt.expected, report["issues"])
+			}
+		})
+	}
+}
+
+func TestIntegrationStorageHealthReport(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	server.SetResult("smart.test.results", []map[string]interface{}{
+		{"disk": "sda", "tests": []interface{}{map[string]interface{}{"status": "SUCCESS"}}},
+		{"disk": "sdb", "tests": []interface{}{map[string]interface{}{"status": "FAILED"}}},
+	})
+	server.SetResult("alert.list", []map[string]interface{}{})
+
+	result, err := registry.CallTool("storage_health_report", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("storage_health_report failed: %v", err)
+	}
+
+	report := decodeResult(t, result)
+	if report["overall_health"] != "CRITICAL" {
+		t.Errorf("overall_health = %v, want CRITICAL", report["overall_health"])
+	}
+	if !strings.Contains(result, "Latest SMART test on sdb reported FAILED") {
+		t.Errorf("report does not explain the SMART failure:\n%s", result)
+	}
+}
@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// System dataset location (systemdataset)
+
+// systemDatasetContents is what lives on the system dataset and moves with it
+var systemDatasetContents = []string{
+	"system logs (syslog)",
+	"reporting metrics history",
+	"SMB state (share ACLs, user mappings)",
+	"daily configuration backups",
+	"core dumps",
+}
+
+// getSystemDatasetConfig returns systemdataset.config
+func getSystemDatasetConfig(client truenas.Caller) (map[string]interface{}, error) {
+	result, err := client.Call("systemdataset.config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system dataset configuration: %w", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse system dataset configuration: %w", err)
+	}
+	return config, nil
+}
+
+// systemDatasetPoolChoices returns the pools the system dataset can move to
+func systemDatasetPoolChoices(client truenas.Caller) ([]string, error) {
+	result, err := client.Call("systemdataset.pool_choices")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system dataset pool choices: %w", err)
+	}
+	var choices map[string]interface{}
+	if err := json.Unmarshal(result, &choices); err != nil {
+		return nil, fmt.Errorf("failed to parse system dataset pool choices: %w", err)
+	}
+	pools := make([]string, 0, len(choices))
+	for pool := range choices {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	return pools, nil
+}
+
+// bootPoolName returns the boot pool's name, "" when it cannot be read
+func bootPoolName(client truenas.Caller) string {
+	var bootPool map[string]interface{}
+	if queryInto(client, "boot.get_state", &bootPool) != nil {
+		return ""
+	}
+	name, _ := bootPool["name"].(string)
+	return name
+}
+
+// systemDatasetUsed returns the space the system dataset uses
+func systemDatasetUsed(client truenas.Caller, basename string) (float64, bool) {
+	if basename == "" {
+		return 0, false
+	}
+	ds, err := getDatasetByName(client, basename)
+	if err != nil {
+		return 0, false
+	}
+	usedProp, _ := ds["used"].(map[string]interface{})
+	used, ok := usedProp["parsed"].(float64)
+	return used, ok
+}
+
+// simplifySystemDataset summarizes where the system dataset lives
+func simplifySystemDataset(config map[string]interface{}, bootPool string) map[string]interface{} {
+	pool, _ := config["pool"].(string)
+	return map[string]interface{}{
+		"pool":         pool,
+		"dataset":      config["basename"],
+		"path":         config["path"],
+		"on_boot_pool": pool != "" && pool == bootPool,
+	}
+}
+
+// systemDatasetTarget validates update_system_dataset's pool against the
+// middleware's pool choices, returning it with the current configuration
+func systemDatasetTarget(client truenas.Caller, args map[string]interface{}) (string, map[string]interface{}, error) {
+	pool, ok := args["pool"].(string)
+	if !ok || pool == "" {
+		return "", nil, fmt.Errorf("pool is required")
+	}
+	config, err := getSystemDatasetConfig(client)
+	if err != nil {
+		return "", nil, err
+	}
+	choices, err := systemDatasetPoolChoices(client)
+	if err != nil {
+		return "", nil, err
+	}
+	if !containsString(choices, pool) {
+		return "", nil, newToolError(ErrorValidation, "the system dataset cannot be moved to %s; choices: %s (locked, passphrase-encrypted, and degraded pools are not offered)", pool, strings.Join(choices, ", "))
+	}
+	return pool, config, nil
+}
+
+func handleGetSystemDataset(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getSystemDatasetConfig(client)
+	if err != nil {
+		return "", err
+	}
+	choices, err := systemDatasetPoolChoices(client)
+	if err != nil {
+		return "", err
+	}
+
+	response := simplifySystemDataset(config, bootPoolName(client))
+	response["pool_choices"] = choices
+	response["contents"] = systemDatasetContents
+	basename, _ := config["basename"].(string)
+	if used, ok := systemDatasetUsed(client, basename); ok {
+		response["used"] = units.FormatBytes(int64(used))
+	}
+	return marshalJSON(response)
+}
+
+func (r *Registry) handleUpdateSystemDataset(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	pool, config, err := systemDatasetTarget(client, args)
+	if err != nil {
+		return "", err
+	}
+	if config["pool"] == pool {
+		response := simplifySystemDataset(config, bootPoolName(client))
+		response["updated"] = false
+		response["message"] = fmt.Sprintf("The system dataset is already on %s", pool)
+		return marshalJSON(response)
+	}
+
+	result, err := client.Call("systemdataset.update", map[string]interface{}{"pool": pool})
+	if err != nil {
+		return "", fmt.Errorf("failed to move system dataset: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return "", err
+	}
+
+	tracked, err := r.taskManager.CreateJobTask("update_system_dataset", args, jobID, 30*time.Minute, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"from":          config["pool"],
+		"to":            pool,
+		"job_id":        jobID,
+		"task_id":       tracked.TaskID,
+		"task_status":   tracked.Status,
+		"poll_interval": tracked.PollInterval,
+		"message":       fmt.Sprintf("Moving the system dataset from %v to %s. Track progress with tasks_get using task_id: %s", config["pool"], pool, tracked.TaskID),
+	})
+}
+
+func (r *Registry) handleUpdateSystemDatasetWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateSystemDatasetDryRun{}, r.handleUpdateSystemDataset)
+}
+
+type updateSystemDatasetDryRun struct{}
+
+func (u *updateSystemDatasetDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	pool, config, err := systemDatasetTarget(client, args)
+	if err != nil {
+		return nil, err
+	}
+	bootPool := bootPoolName(client)
+	current := simplifySystemDataset(config, bootPool)
+
+	if config["pool"] == pool {
+		return &DryRunResult{
+			Tool:           "update_system_dataset",
+			CurrentState:   current,
+			PlannedActions: []PlannedAction{},
+			Warnings:       []string{fmt.Sprintf("The system dataset is already on %s; nothing to do", pool)},
+		}, nil
+	}
+
+	warnings := []string{}
+	from, _ := config["pool"].(string)
+	basename, _ := config["basename"].(string)
+	copyDetails := map[string]interface{}{
+		"from":     basename,
+		"to":       pool + "/.system",
+		"contents": systemDatasetContents,
+	}
+	used, ok := systemDatasetUsed(client, basename)
+	if ok {
+		copyDetails["size"] = units.FormatBytes(int64(used))
+	}
+	if ok && pool != bootPool {
+		var pools []map[string]interface{}
+		if queryInto(client, "pool.query", &pools) == nil {
+			for _, p := range pools {
+				free, ok := p["free"].(float64)
+				if p["name"] == pool && ok && free < used {
+					warnings = append(warnings, fmt.Sprintf("%s has %s free but the system dataset uses %s; the move will fail", pool, units.FormatBytes(int64(free)), units.FormatBytes(int64(used))))
+				}
+			}
+		}
+	}
+
+	if pool == bootPool {
+		warnings = append(warnings, "Logs and reporting metrics are written continuously; on USB or SD boot media this wears out the boot device")
+	}
+	if smb, err := getService(client, "cifs"); err == nil && smb["state"] == "RUNNING" {
+		warnings = append(warnings, "SMB keeps its state on the system dataset and is restarted during the move; connected SMB clients are disconnected")
+	}
+	warnings = append(warnings, "Logging and reporting pause while the data is copied; schedule the move for a quiet period")
+
+	return &DryRunResult{
+		Tool:         "update_system_dataset",
+		CurrentState: current,
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Copy the system dataset from %s to %s", from, pool),
+				Operation:   "create",
+				Target:      pool + "/.system",
+				Details:     copyDetails,
+			},
+			{
+				Step:        2,
+				Description: "Remount /var/db/system from the new dataset and restart the services that use it",
+				Operation:   "update",
+				Target:      systemDatasetPath,
+			},
+			{
+				Step:        3,
+				Description: fmt.Sprintf("Destroy the old system dataset on %s", from),
+				Operation:   "delete",
+				Target:      basename,
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationSystemDataset(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("systemdataset.config", map[string]interface{}{
+		"id": float64(1), "pool": "boot-pool", "pool_set": false, "basename": "boot-pool/.system", "path": "/var/db/system",
+	})
+	server.SetResult("systemdataset.pool_choices", map[string]interface{}{"boot-pool": "boot-pool", "tank": "tank"})
+	server.SetResult("boot.get_state", map[string]interface{}{"name": "boot-pool", "status": "ONLINE"})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "boot-pool/.system", "used": map[string]interface{}{"parsed": float64(2 << 30)}},
+	})
+	server.SetRecords("pool.query", []map[string]interface{}{{"name": "tank", "free": float64(1 << 30)}})
+	server.SetRecords("service.query", []map[string]interface{}{{"service": "cifs", "state": "RUNNING"}})
+	server.HandleJob("systemdataset.update", truenastest.JobSpec{Steps: 1})
+
+	result, err := registry.CallTool("get_system_dataset", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_system_dataset failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["pool"] != "boot-pool" || response["on_boot_pool"] != true || fmt.Sprint(response["pool_choices"]) != "[boot-pool tank]" || response["used"] == nil {
+		t.Errorf("get_system_dataset = %v", response)
+	}
+
+	// The preview shows the copy and flags the SMB restart and lack of space
+	result, err = registry.CallTool("update_system_dataset", map[string]interface{}{"pool": "tank", "dry_run": true})
+	if err != nil {
+		t.Fatalf("update_system_dataset dry run failed: %v", err)
+	}
+	for _, want := range []string{"Copy the system dataset from boot-pool to tank", "tank/.system", "SMB clients are disconnected", "the move will fail"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("dry run lacks %q: %s", want, result)
+		}
+	}
+	if len(server.Calls("systemdataset.update")) != 0 {
+		t.Fatal("dry run moved the system dataset")
+	}
+
+	if _, err := registry.CallTool("update_system_dataset", map[string]interface{}{"pool": "backup"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("unknown pool error = %v, want VALIDATION", err)
+	}
+	result, err = registry.CallTool("update_system_dataset", map[string]interface{}{"pool": "boot-pool"})
+	if err != nil {
+		t.Fatalf("update_system_dataset to the current pool failed: %v", err)
+	}
+	if decodeResult(t, result)["updated"] != false || len(server.Calls("systemdataset.update")) != 0 {
+		t.Errorf("move to the current pool = %s", result)
+	}
+
+	result, err = registry.CallTool("update_system_dataset", map[string]interface{}{"pool": "tank"})
+	if err != nil {
+		t.Fatalf("update_system_dataset failed: %v", err)
+	}
+	if decodeResult(t, result)["task_id"] == nil {
+		t.Errorf("move = %s, want a tracked job", result)
+	}
+	if payload := server.Calls("systemdataset.update")[0].Params[0].(map[string]interface{}); payload["pool"] != "tank" {
+		t.Errorf("systemdataset.update payload = %v", payload)
+	}
+}
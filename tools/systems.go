@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Multiple TrueNAS systems
+//
+// A server can manage several TrueNAS systems. Tool calls run on the default
+// system unless their host argument names another one. Each other system has
+// a registry of its own that shares the task manager, deletion queue, catalog
+// cache, and compliance baseline, so operation locks stay per system while
+// tasks and pending deletions are listed in one place.
+
+// defaultSystemName names the default system when none is configured
+const defaultSystemName = "default"
+
+// defaultSystemTools take no host argument: they report state the server
+// gathers from the default system (events, capacity history, digests,
+// inventory snapshots, Netdata charts) or about the server itself
+var defaultSystemTools = map[string]bool{
+	"list_systems":               true,
+	"set_session_defaults":       true,
+	"list_scheduled_operations":  true,
+	"cancel_scheduled_operation": true,
+	"list_pending_deletions":     true,
+	"undo_pending_deletion":      true,
+	"tasks_list":                 true,
+	"tasks_get":                  true,
+	"watch_events":               true,
+	"get_recent_events":          true,
+	"generate_health_digest":     true,
+	"get_health_digest":          true,
+	"forecast_dataset_growth":    true,
+	"list_netdata_charts":        true,
+	"get_netdata_chart":          true,
+	"save_inventory_snapshot":    true,
+	"list_inventory_snapshots":   true,
+	"diff_inventory":             true,
+}
+
+// addSystems creates a registry for each other system, adds the host argument
+// to every tool that can run on them, and registers list_systems
+func (r *Registry) addSystems(taskManager *tasks.Manager, opts Options) {
+	r.defaultSystem = opts.DefaultSystem
+	if r.defaultSystem == "" {
+		r.defaultSystem = defaultSystemName
+	}
+	r.systems = make(map[string]*Registry, len(opts.Systems))
+	for name, client := range opts.Systems {
+		system := NewRegistry(client, taskManager, Options{
+			CatalogCache:     opts.CatalogCache,
+			ComplianceStore:  opts.ComplianceStore,
+			DeletionQueue:    opts.DeletionQueue,
+			MaxResponseBytes: opts.MaxResponseBytes,
+			ReadOnly:         opts.ReadOnly,
+			ServerVersion:    opts.ServerVersion,
+			Timeouts:         opts.Timeouts,
+			ToolFilter:       opts.ToolFilter,
+			UpdatePreflight:  opts.UpdatePreflight,
+		})
+		system.system = name
+		r.systems[name] = system
+	}
+
+	names := r.systemNames()
+	for name, tool := range r.tools {
+		if defaultSystemTools[name] {
+			continue
+		}
+		props, ok := tool.Definition.InputSchema["properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		props["host"] = map[string]interface{}{
+			"type":        "string",
+			"enum":        names,
+			"description": fmt.Sprintf("Optional: TrueNAS system to run on (default: %s); see list_systems", r.defaultSystem),
+		}
+	}
+
+	r.tools["list_systems"] = Tool{
+		Definition: mcp.Tool{
+			Name:        "list_systems",
+			Description: "List the TrueNAS systems this server manages, with the hostname, version, and reachability of each. Direct other tools at a system with their host argument.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		Handler: r.handleListSystems,
+	}
+}
+
+// systemNames returns the names of every system, the default one first
+func (r *Registry) systemNames() []string {
+	names := make([]string, 0, len(r.systems))
+	for name := range r.systems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{r.defaultSystem}, names...)
+}
+
+// systemFor returns the registry a call runs in, as named by its host
+// argument, and args without host when it names the default system. The
+// returned registry is r itself for the default system.
+func (r *Registry) systemFor(name string, args map[string]interface{}) (*Registry, map[string]interface{}, error) {
+	raw, given := args["host"]
+	if !given || r.systems == nil || defaultSystemTools[name] {
+		return r, args, nil
+	}
+	host, ok := raw.(string)
+	if !ok {
+		return nil, nil, newToolError(ErrorValidation, "host must be a string")
+	}
+	if system, ok := r.systems[host]; ok {
+		return system, args, nil
+	}
+	if host != "" && host != r.defaultSystem {
+		toolErr := newToolError(ErrorValidation, "unknown host %q", host)
+		toolErr.Details = map[string]interface{}{"systems": r.systemNames()}
+		return nil, nil, toolErr
+	}
+
+	// The default system runs here; tasks record no system for it
+	local := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		if key != "host" {
+			local[key] = value
+		}
+	}
+	return r, local, nil
+}
+
+// withSystem returns args naming this registry's system as host, so tasks
+// and deletions it starts (including the steps of a plan) are tracked on it.
+// The caller's map is not modified.
+func (r *Registry) withSystem(args map[string]interface{}) map[string]interface{} {
+	if r.system == "" || args["host"] == r.system {
+		return args
+	}
+	tagged := make(map[string]interface{}, len(args)+1)
+	for key, value := range args {
+		tagged[key] = value
+	}
+	tagged["host"] = r.system
+	return tagged
+}
+
+func (r *Registry) handleListSystems(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	names := r.systemNames()
+
+	// Ask every system at once; an unreachable one is reported, not fatal
+	results := make([]truenas.BatchResult, len(names))
+	done := make(chan struct{})
+	for i, name := range names {
+		caller := client
+		if system, ok := r.systems[name]; ok {
+			caller = system.clientFor(client.CorrelationID())
+		}
+		go func(i int, caller truenas.Caller) {
+			defer func() { done <- struct{}{} }()
+			results[i].Result, results[i].Err = caller.Call("system.info")
+		}(i, caller)
+	}
+	for range names {
+		<-done
+	}
+
+	systems := make([]map[string]interface{}, 0, len(names))
+	for i, name := range names {
+		system := map[string]interface{}{
+			"name":    name,
+			"default": name == r.defaultSystem,
+		}
+		if err := results[i].Err; err != nil {
+			system["reachable"] = false
+			system["error"] = err.Error()
+		} else {
+			var info map[string]interface{}
+			if err := json.Unmarshal(results[i].Result, &info); err != nil {
+				return "", fmt.Errorf("failed to parse system info of %s: %w", name, err)
+			}
+			system["reachable"] = true
+			system["hostname"] = info["hostname"]
+			system["version"] = info["version"]
+		}
+		systems = append(systems, system)
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"systems":        systems,
+		"default_system": r.defaultSystem,
+	})
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/tasks"
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationMultipleSystems(t *testing.T) {
+	primary := truenastest.NewServer(t)
+	primary.SetRecords("pool.query", []map[string]interface{}{testPool("tank", 40, 60)})
+	primary.SetResult("system.info", map[string]interface{}{"hostname": "nas1", "version": "25.04.1"})
+	second := truenastest.NewServer(t)
+	second.SetRecords("pool.query", []map[string]interface{}{testPool("backup", 10, 90)})
+	second.SetResult("system.info", map[string]interface{}{"hostname": "nas2", "version": "24.10.2"})
+	second.SetRecords("app.query", []map[string]interface{}{{"name": "nginx", "state": "CRASHED", "version": "1.2.0"}})
+	second.HandleJob("app.redeploy", truenastest.JobSpec{Steps: 1})
+
+	secondClient := second.Client(t)
+	taskManager := tasks.NewManager(primary.Client(t), tasks.PollerConfig{
+		PollInterval:    20 * time.Millisecond,
+		CleanupInterval: time.Minute,
+	})
+	taskManager.AddSystem("nas2", secondClient)
+	taskManager.Start()
+	t.Cleanup(taskManager.Shutdown)
+	registry := NewRegistry(primary.Client(t), taskManager, Options{
+		DefaultSystem: "nas1",
+		Systems:       map[string]truenas.Caller{"nas2": secondClient},
+	})
+
+	poolOn := func(args map[string]interface{}) string {
+		t.Helper()
+		result, err := registry.CallTool("query_pools", args)
+		if err != nil {
+			t.Fatalf("query_pools %v failed: %v", args, err)
+		}
+		return decodeResult(t, result)["pools"].([]interface{})[0].(map[string]interface{})["name"].(string)
+	}
+	if pool := poolOn(map[string]interface{}{}); pool != "tank" {
+		t.Errorf("default system pool = %s, want tank", pool)
+	}
+	if pool := poolOn(map[string]interface{}{"host": "nas1"}); pool != "tank" {
+		t.Errorf("host=nas1 pool = %s, want tank", pool)
+	}
+	if pool := poolOn(map[string]interface{}{"host": "nas2"}); pool != "backup" {
+		t.Errorf("host=nas2 pool = %s, want backup", pool)
+	}
+	if _, err := registry.CallTool("query_pools", map[string]interface{}{"host": "nas3"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("unknown host error = %v, want VALIDATION", err)
+	}
+
+	// The host argument lists every system; server-wide tools do without it
+	props := registry.tools["query_pools"].Definition.InputSchema["properties"].(map[string]interface{})
+	if host, ok := props["host"].(map[string]interface{}); !ok || len(host["enum"].([]string)) != 2 {
+		t.Errorf("query_pools host argument = %v", props["host"])
+	}
+	if _, ok := registry.tools["tasks_get"].Definition.InputSchema["properties"].(map[string]interface{})["host"]; ok {
+		t.Error("tasks_get should not take a host argument")
+	}
+
+	result, err := registry.CallTool("list_systems", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("list_systems failed: %v", err)
+	}
+	systems := decodeResult(t, result)["systems"].([]interface{})
+	if len(systems) != 2 || !strings.Contains(result, `"hostname": "nas2"`) || systems[0].(map[string]interface{})["default"] != true {
+		t.Errorf("list_systems result:\n%s", result)
+	}
+
+	// A job started on another system is followed on that system
+	result, err = registry.CallTool("redeploy_app", map[string]interface{}{"app_name": "nginx", "host": "nas2"})
+	if err != nil {
+		t.Fatalf("redeploy_app on nas2 failed: %v", err)
+	}
+	if calls := primary.Calls("app.redeploy"); len(calls) != 0 {
+		t.Errorf("redeploy ran on the default system: %v", calls)
+	}
+	taskID, _ := decodeResult(t, result)["task_id"].(string)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, err := taskManager.Get(taskID)
+		if err != nil {
+			t.Fatalf("task %q not found: %v", taskID, err)
+		}
+		if task.System != "nas2" {
+			t.Fatalf("task system = %q, want nas2", task.System)
+		}
+		if task.Status == tasks.TaskStatusCompleted {
+			break
+		}
+		if task.Status != tasks.TaskStatusWorking || time.Now().After(deadline) {
+			t.Fatalf("task status = %s (%s), want completed", task.Status, task.StatusMessage)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Timeout categories
+const (
+	TimeoutCategoryQuery  = "query"
+	TimeoutCategoryDryRun = "dry_run"
+	TimeoutCategoryJob    = "job"
+)
+
+// TimeoutConfig bounds how long a tool handler may run. A zero or negative
+// duration disables the limit.
+type TimeoutConfig struct {
+	Query  time.Duration // read-only tools
+	DryRun time.Duration // write tools called with dry_run=true
+	Job    time.Duration // write tools that submit changes or middleware jobs
+
+	// PerTool overrides the category timeout for individual tools
+	PerTool map[string]time.Duration
+}
+
+// DefaultTimeoutConfig returns the built-in timeouts
+func DefaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		Query:   30 * time.Second,
+		DryRun:  60 * time.Second,
+		Job:     120 * time.Second,
+		PerTool: map[string]time.Duration{
+			// Fan out over several reporting queries
+			"analyze_capacity":       90 * time.Second,
+			"generate_health_digest": 90 * time.Second,
+			// Dry runs preview every step in one call
+			"execute_plan": 5 * time.Minute,
+			// Streams the file through this server
+			"restore_file_from_snapshot": 10 * time.Minute,
+		},
+	}
+}
+
+// ParseTimeoutOverrides applies a comma-separated list of name=duration pairs to
+// base, where name is a category (query, dry_run, job) or a tool name, e.g.
+// "query=45s,analyze_capacity=2m"
+func ParseTimeoutOverrides(spec string, base TimeoutConfig) (TimeoutConfig, error) {
+	config := base
+	config.PerTool = make(map[string]time.Duration, len(base.PerTool))
+	for name, d := range base.PerTool {
+		config.PerTool[name] = d
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return config, fmt.Errorf("invalid timeout override %q (expected name=duration)", entry)
+		}
+		name = strings.TrimSpace(name)
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return config, fmt.Errorf("invalid duration for %s: %w", name, err)
+		}
+
+		switch name {
+		case TimeoutCategoryQuery:
+			config.Query = d
+		case TimeoutCategoryDryRun:
+			config.DryRun = d
+		case TimeoutCategoryJob:
+			config.Job = d
+		default:
+			config.PerTool[name] = d
+		}
+	}
+
+	return config, nil
+}
+
+// timeoutCategory determines which category a call falls into. Tools that accept
+// dry_run are write operations; everything else is treated as a query.
+func timeoutCategory(tool Tool, args map[string]interface{}) string {
+	props, _ := tool.Definition.InputSchema["properties"].(map[string]interface{})
+	if _, writes := props["dry_run"]; !writes {
+		return TimeoutCategoryQuery
+	}
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		return TimeoutCategoryDryRun
+	}
+	return TimeoutCategoryJob
+}
+
+// timeoutFor returns the effective timeout and category for a tool call
+func (c TimeoutConfig) timeoutFor(name string, tool Tool, args map[string]interface{}) (time.Duration, string) {
+	category := timeoutCategory(tool, args)
+	if d, ok := c.PerTool[name]; ok {
+		return d, category
+	}
+	switch category {
+	case TimeoutCategoryDryRun:
+		return c.DryRun, category
+	case TimeoutCategoryJob:
+		return c.Job, category
+	}
+	return c.Query, category
+}
+
+// callWithTimeout runs a handler with client, returning a TIMEOUT error if it
+// does not finish in time and a CANCELLED error if ctx is cancelled first.
+// The handler's context and client carry the deadline, so its pending
+// middleware calls return as soon as the call is abandoned; the middleware
+// may still finish work it already received.
+func (r *Registry) callWithTimeout(ctx context.Context, name string, tool Tool, client truenas.Caller, args map[string]interface{}) (string, error) {
+	timeout, category := r.timeouts.timeoutFor(name, tool, args)
+
+	var callCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		callCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{err: newToolError(ErrorInternal, "tool %s panicked: %v", name, p)}
+			}
+		}()
+		if client != nil {
+			client = client.WithContext(callCtx)
+		}
+		result, err := tool.Handler(callCtx, client, args)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-callCtx.Done():
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		toolErr := newToolError(ErrorCancelled, "tool %s was cancelled", name)
+		if category == TimeoutCategoryJob {
+			toolErr.Message += ". The change may still be applied on the NAS; check query_jobs or the affected resource before retrying"
+		}
+		return "", toolErr
+	case category == TimeoutCategoryJob:
+		return "", &ToolError{
+			Code:    ErrorTimeout,
+			Message: fmt.Sprintf("tool %s did not complete within %s. The change may still be applied on the NAS; check query_jobs or the affected resource before retrying", name, timeout),
+		}
+	}
+	return "", &ToolError{
+		Code:      ErrorTimeout,
+		Message:   fmt.Sprintf("tool %s did not complete within %s", name, timeout),
+		Retryable: true,
+	}
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/mcp"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+func TestParseTimeoutOverrides(t *testing.T) {
+	config, err := ParseTimeoutOverrides("query=45s, job=5m,analyze_capacity=0", DefaultTimeoutConfig())
+	if err != nil {
+		t.Fatalf("ParseTimeoutOverrides failed: %v", err)
+	}
+	if config.Query != 45*time.Second || config.Job != 5*time.Minute || config.DryRun != 60*time.Second {
+		t.Errorf("categories = %v/%v/%v, want 45s/60s/5m", config.Query, config.DryRun, config.Job)
+	}
+	if d, ok := config.PerTool["analyze_capacity"]; !ok || d != 0 {
+		t.Errorf("analyze_capacity override = %v (set: %v), want 0", d, ok)
+	}
+
+	for _, spec := range []string{"query", "query=soon"} {
+		if _, err := ParseTimeoutOverrides(spec, DefaultTimeoutConfig()); err == nil {
+			t.Errorf("ParseTimeoutOverrides(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestTimeoutCategory(t *testing.T) {
+	writeTool := Tool{Definition: mcp.Tool{InputSchema: map[string]interface{}{
+		"properties": map[string]interface{}{"dry_run": map[string]interface{}{"type": "boolean"}},
+	}}}
+	readTool := Tool{Definition: mcp.Tool{InputSchema: map[string]interface{}{
+		"properties": map[string]interface{}{},
+	}}}
+
+	tests := []struct {
+		name     string
+		tool     Tool
+		args     map[string]interface{}
+		expected string
+	}{
+		{name: "read-only tool", tool: readTool, args: map[string]interface{}{}, expected: TimeoutCategoryQuery},
+		{name: "write tool dry run", tool: writeTool, args: map[string]interface{}{"dry_run": true}, expected: TimeoutCategoryDryRun},
+		{name: "write tool execution", tool: writeTool, args: map[string]interface{}{}, expected: TimeoutCategoryJob},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeoutCategory(tt.tool, tt.args); got != tt.expected {
+				t.Errorf("timeoutCategory() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCallWithTimeout(t *testing.T) {
+	config := DefaultTimeoutConfig()
+	config.Query = 20 * time.Millisecond
+	r := &Registry{timeouts: config, tools: map[string]Tool{}}
+
+	r.tools["slow"] = Tool{
+		Definition: mcp.Tool{Name: "slow", InputSchema: map[string]interface{}{}},
+		Handler: func(context.Context, truenas.Caller, map[string]interface{}) (string, error) {
+			time.Sleep(time.Second)
+			return "late", nil
+		},
+	}
+	r.tools["fast"] = Tool{
+		Definition: mcp.Tool{Name: "fast", InputSchema: map[string]interface{}{}},
+		Handler: func(context.Context, truenas.Caller, map[string]interface{}) (string, error) {
+			return "ok", nil
+		},
+	}
+	r.tools["panics"] = Tool{
+		Definition: mcp.Tool{Name: "panics", InputSchema: map[string]interface{}{}},
+		Handler: func(context.Context, truenas.Caller, map[string]interface{}) (string, error) {
+			panic("boom")
+		},
+	}
+	r.tools["deadline"] = Tool{
+		Definition: mcp.Tool{Name: "deadline", InputSchema: map[string]interface{}{}},
+		Handler: func(ctx context.Context, _ truenas.Caller, _ map[string]interface{}) (string, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return "", errors.New("handler context has no deadline")
+			}
+			return "ok", nil
+		},
+	}
+
+	if result, err := r.CallTool("fast", nil); err != nil || result != "ok" {
+		t.Errorf("fast = %q, %v; want ok", result, err)
+	}
+
+	if _, err := r.CallTool("deadline", nil); err != nil {
+		t.Errorf("deadline: %v", err)
+	}
+
+	_, err := r.CallTool("slow", nil)
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != ErrorTimeout || !toolErr.Retryable {
+		t.Errorf("slow error = %v, want retryable TIMEOUT", err)
+	}
+
+	_, err = r.CallTool("panics", nil)
+	if !errors.As(err, &toolErr) || toolErr.Code != ErrorInternal {
+		t.Errorf("panics error = %v, want INTERNAL", err)
+	}
+}
+
+func TestCallToolCancelled(t *testing.T) {
+	r := &Registry{timeouts: DefaultTimeoutConfig(), tools: map[string]Tool{}}
+	r.tools["slow"] = Tool{
+		Definition: mcp.Tool{Name: "slow", InputSchema: map[string]interface{}{}},
+		Handler: func(context.Context, truenas.Caller, map[string]interface{}) (string, error) {
+			time.Sleep(time.Second)
+			return "late", nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := r.callTool(ctx, "", "corr", "slow", nil)
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Code != ErrorCancelled {
+		t.Errorf("cancelled call error = %v, want CANCELLED", err)
+	}
+}
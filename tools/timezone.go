@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// NAS timezone
+//
+// Cron schedules run on the NAS clock, so next-run times and timestamps are
+// computed and shown in the NAS's configured timezone rather than the MCP
+// server's, with an explicit UTC offset.
+
+// nasClock tells time in the NAS timezone
+type nasClock struct {
+	loc     *time.Location
+	fromNAS bool
+}
+
+// getNASClock reads the NAS timezone from system.general.config. When it
+// cannot be read or loaded, the MCP server's local zone is used instead and
+// info() says so.
+func getNASClock(client truenas.Caller) nasClock {
+	result, err := client.Call("system.general.config")
+	if err != nil {
+		return nasClock{loc: time.Local}
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nasClock{loc: time.Local}
+	}
+	name, _ := config["timezone"].(string)
+	if name == "" {
+		return nasClock{loc: time.Local}
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nasClock{loc: time.Local}
+	}
+	return nasClock{loc: loc, fromNAS: true}
+}
+
+// now returns the current time in the NAS timezone
+func (c nasClock) now() time.Time {
+	return time.Now().In(c.loc)
+}
+
+// format formats a time in the NAS timezone as RFC 3339 with its offset
+func (c nasClock) format(t time.Time) string {
+	return t.In(c.loc).Format(time.RFC3339)
+}
+
+// info describes the timezone used for the times in a response
+func (c nasClock) info() map[string]interface{} {
+	info := map[string]interface{}{
+		"name":       c.loc.String(),
+		"utc_offset": c.now().Format("-07:00"),
+		"source":     "nas",
+	}
+	if !c.fromNAS {
+		info["source"] = "mcp_server"
+		info["note"] = "NAS timezone unavailable; times use the MCP server's timezone and may differ from when the NAS runs schedules"
+	}
+	return info
+}
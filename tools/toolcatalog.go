@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Tool catalog export
+
+// CatalogServerName identifies this server in exported tool catalogs
+const CatalogServerName = "truenas-mcp"
+
+// ToolCatalog is a machine-readable bundle of the exposed tools and their
+// input schemas, for validation, documentation, and diffing between releases
+type ToolCatalog struct {
+	Server    string        `json:"server"`
+	Version   string        `json:"version"`
+	ReadOnly  bool          `json:"read_only"`
+	ToolCount int           `json:"tool_count"`
+	Tools     []CatalogTool `json:"tools"`
+}
+
+// CatalogTool describes one tool in a ToolCatalog
+type CatalogTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	WriteTool   bool                   `json:"write_tool"`
+	DryRun      bool                   `json:"dry_run"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ToolCatalog returns the exposed tools sorted by name, limited to those
+// matching patterns (* wildcards; empty = all tools)
+func (r *Registry) ToolCatalog(patterns []string) ToolCatalog {
+	catalog := ToolCatalog{
+		Server:   CatalogServerName,
+		Version:  r.serverVersion,
+		ReadOnly: r.readOnly,
+		Tools:    []CatalogTool{},
+	}
+	for name, tool := range r.tools {
+		if len(patterns) > 0 && !matchTool(patterns, name) {
+			continue
+		}
+		props, _ := tool.Definition.InputSchema["properties"].(map[string]interface{})
+		_, dryRun := props["dry_run"]
+		catalog.Tools = append(catalog.Tools, CatalogTool{
+			Name:        name,
+			Description: tool.Definition.Description,
+			WriteTool:   isWriteTool(name, tool),
+			DryRun:      dryRun,
+			InputSchema: tool.Definition.InputSchema,
+		})
+	}
+	sort.Slice(catalog.Tools, func(i, j int) bool { return catalog.Tools[i].Name < catalog.Tools[j].Name })
+	catalog.ToolCount = len(catalog.Tools)
+	return catalog
+}
+
+// OpenAPI renders the catalog as an OpenAPI 3.1 document with one POST
+// operation per tool, whose request body is the tool's input schema
+func (c ToolCatalog) OpenAPI() map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, tool := range c.Tools {
+		paths["/tools/"+tool.Name] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": tool.Name,
+				"description": tool.Description,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": tool.InputSchema},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Tool result (usually JSON text)",
+						"content": map[string]interface{}{
+							"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+						},
+					},
+				},
+				"x-write-tool": tool.WriteTool,
+				"x-dry-run":    tool.DryRun,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       c.Server,
+			"version":     c.Version,
+			"description": "MCP tools exposed by truenas-mcp. Each operation is a tools/call of the named tool with the request body as its arguments.",
+		},
+		"paths": paths,
+	}
+}
+
+// ToolCatalogBundle renders the catalog in the given format: "json" (the
+// catalog itself) or "openapi"
+func (r *Registry) ToolCatalogBundle(format string, patterns []string) (interface{}, error) {
+	catalog := r.ToolCatalog(patterns)
+	switch format {
+	case "", "json":
+		return catalog, nil
+	case "openapi":
+		return catalog.OpenAPI(), nil
+	default:
+		return nil, newToolError(ErrorValidation, "format must be 'json' or 'openapi', got: %s", format)
+	}
+}
+
+func (r *Registry) handleDescribeTools(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	var patterns []string
+	if raw, ok := args["tools"].([]interface{}); ok {
+		for _, v := range raw {
+			pattern, ok := v.(string)
+			if !ok || pattern == "" {
+				return "", fmt.Errorf("tools must be a list of tool names or patterns")
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	if _, err := parseToolPatterns(strings.Join(patterns, ",")); err != nil {
+		return "", newToolError(ErrorValidation, "%v", err)
+	}
+	format, _ := args["format"].(string)
+	bundle, err := r.ToolCatalogBundle(format, patterns)
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(bundle)
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestToolCatalog(t *testing.T) {
+	registry := NewRegistry(nil, nil, Options{ReadOnly: true, ServerVersion: "1.2.3"})
+
+	catalog := registry.ToolCatalog(nil)
+	if catalog.Version != "1.2.3" || !catalog.ReadOnly || catalog.ToolCount != len(registry.ListTools()) {
+		t.Errorf("catalog header = %s %v %d, want 1.2.3 true %d", catalog.Version, catalog.ReadOnly, catalog.ToolCount, len(registry.ListTools()))
+	}
+	for i, tool := range catalog.Tools {
+		if i > 0 && catalog.Tools[i-1].Name >= tool.Name {
+			t.Errorf("tools not sorted: %s before %s", catalog.Tools[i-1].Name, tool.Name)
+		}
+		if tool.WriteTool {
+			t.Errorf("read-only catalog lists write tool %s", tool.Name)
+		}
+	}
+
+	out, err := registry.handleDescribeTools(context.Background(), nil, map[string]interface{}{
+		"tools":  []interface{}{"query_pools", "describe_*"},
+		"format": "openapi",
+	})
+	if err != nil {
+		t.Fatalf("describe_tools failed: %v", err)
+	}
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" || len(doc.Paths) != 3 {
+		t.Errorf("openapi %s with paths %v, want 3.1.0 with query_pools, describe_api_method, describe_tools", doc.OpenAPI, doc.Paths)
+	}
+	if doc.Paths["/tools/query_pools"]["post"]["operationId"] != "query_pools" {
+		t.Errorf("query_pools operation = %v", doc.Paths["/tools/query_pools"])
+	}
+
+	_, err = registry.handleDescribeTools(context.Background(), nil, map[string]interface{}{"format": "yaml"})
+	if ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("format yaml error = %v, want VALIDATION", err)
+	}
+}
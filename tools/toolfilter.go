@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// ToolFilter limits which tools the registry exposes. Patterns are tool names
+// and may contain * wildcards, e.g. "query_*" or "delete_*".
+type ToolFilter struct {
+	Enable  []string // Expose only tools matching one of these (empty = all tools)
+	Disable []string // Never expose tools matching one of these; wins over Enable
+}
+
+// ParseToolFilter builds a filter from comma-separated enable and disable
+// lists, e.g. "query_*,create_dataset" and "system_reboot,delete_*"
+func ParseToolFilter(enable, disable string) (ToolFilter, error) {
+	var filter ToolFilter
+	var err error
+	if filter.Enable, err = parseToolPatterns(enable); err != nil {
+		return filter, err
+	}
+	if filter.Disable, err = parseToolPatterns(disable); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func parseToolPatterns(spec string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchTool reports whether any pattern matches name
+func matchTool(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyToolFilter unregisters the tools the filter excludes. Patterns that
+// match no tool are logged, since they are usually typos.
+func (r *Registry) applyToolFilter(filter ToolFilter) {
+	all := make([]string, 0, len(r.tools)+len(r.disabledTools))
+	for name := range r.tools {
+		all = append(all, name)
+	}
+	for name := range r.disabledTools {
+		all = append(all, name)
+	}
+	for _, pattern := range append(append([]string(nil), filter.Enable...), filter.Disable...) {
+		if !matchAnyTool(pattern, all) {
+			slog.Warn("Tool filter pattern matches no tools", "pattern", pattern)
+		}
+	}
+
+	for name := range r.tools {
+		switch {
+		case matchTool(filter.Disable, name):
+			r.disableTool(name, "it is excluded by the server's tool filter (--disable-tools)")
+		case len(filter.Enable) > 0 && !matchTool(filter.Enable, name):
+			r.disableTool(name, "it is not in the server's tool filter (--enable-tools)")
+		}
+	}
+}
+
+// matchAnyTool reports whether pattern matches any of names
+func matchAnyTool(pattern string, names []string) bool {
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestParseToolFilter(t *testing.T) {
+	filter, err := ParseToolFilter("query_*, create_dataset,", "system_reboot")
+	if err != nil {
+		t.Fatalf("ParseToolFilter failed: %v", err)
+	}
+	if len(filter.Enable) != 2 || filter.Enable[1] != "create_dataset" || len(filter.Disable) != 1 {
+		t.Errorf("filter = %+v", filter)
+	}
+
+	if _, err := ParseToolFilter("query_[", ""); err == nil {
+		t.Error("ParseToolFilter accepted a malformed pattern")
+	}
+}
+
+func TestApplyToolFilter(t *testing.T) {
+	filter, _ := ParseToolFilter("query_*,create_dataset,delete_*", "delete_app")
+	registry := NewRegistry(nil, nil, Options{ToolFilter: filter})
+
+	listed := map[string]bool{}
+	for _, tool := range registry.ListTools() {
+		listed[tool.Name] = true
+	}
+	for _, name := range []string{"query_pools", "query_datasets", "create_dataset", "delete_smb_share"} {
+		if !listed[name] {
+			t.Errorf("%s is missing from tools/list", name)
+		}
+	}
+	for _, name := range []string{"delete_app", "system_reboot", "install_app"} {
+		if listed[name] {
+			t.Errorf("%s is listed, want it filtered out", name)
+		}
+	}
+
+	_, err := registry.CallTool("delete_app", map[string]interface{}{"app_name": "plex"})
+	if toolErr := ClassifyError(err); toolErr.Code != ErrorPermissionDenied || !strings.Contains(toolErr.Message, "--disable-tools") {
+		t.Errorf("delete_app error = %v, want PERMISSION_DENIED naming --disable-tools", err)
+	}
+	_, err = registry.CallTool("system_reboot", map[string]interface{}{})
+	if toolErr := ClassifyError(err); toolErr.Code != ErrorPermissionDenied || !strings.Contains(toolErr.Message, "--enable-tools") {
+		t.Errorf("system_reboot error = %v, want PERMISSION_DENIED naming --enable-tools", err)
+	}
+}
+
+func TestToolFilterHidesResources(t *testing.T) {
+	server := truenastest.NewServer(t)
+	server.SetRecords("pool.query", []map[string]interface{}{{"name": "tank"}})
+	filter, _ := ParseToolFilter("query_pools", "")
+	registry := NewRegistry(server.Client(t), nil, Options{ToolFilter: filter})
+
+	listed := registry.ListResources()
+	if len(listed) != 1 || listed[0].URI != "truenas://pool/tank" {
+		t.Errorf("ListResources = %v, want only the pool", listed)
+	}
+	for _, uri := range []string{"truenas://inventory", "truenas://alerts", "truenas://dataset/tank"} {
+		if _, err := registry.ReadResource(context.Background(), uri); ClassifyError(err).Code != ErrorNotFound {
+			t.Errorf("ReadResource(%s) error = %v, want NOT_FOUND", uri, err)
+		}
+	}
+	if _, err := registry.ReadResource(context.Background(), "truenas://pool/tank"); err != nil {
+		t.Errorf("ReadResource(pool) failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := registry.ReadResource(ctx, "truenas://pool/tank"); err == nil {
+		t.Error("ReadResource succeeded with a cancelled context")
+	}
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Update release notes handler
+
+// handleGetUpdateChangelog returns the release notes and manifest of a pending
+// update so it can be reviewed before download_update/apply_update
+func handleGetUpdateChangelog(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	requested, _ := args["version"].(string)
+	includeManifest, _ := args["include_manifest"].(bool)
+
+	result, err := client.Call("update.available_versions")
+	if err != nil {
+		return "", fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	var available []map[string]interface{}
+	if err := json.Unmarshal(result, &available); err != nil {
+		return "", fmt.Errorf("failed to parse update information: %w", err)
+	}
+
+	currentVersion := ""
+	if infoResult, err := client.Call("system.info"); err == nil {
+		var info map[string]interface{}
+		if err := json.Unmarshal(infoResult, &info); err == nil {
+			currentVersion, _ = info["version"].(string)
+		}
+	}
+
+	pending := make([]string, 0, len(available))
+	var train string
+	var release map[string]interface{}
+	for _, entry := range available {
+		version, ok := entry["version"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := version["version"].(string)
+		pending = append(pending, name)
+		if release == nil && (requested == "" || name == requested) {
+			release = version
+			train, _ = entry["train"].(string)
+		}
+	}
+
+	if release == nil {
+		if requested != "" {
+			return "", fmt.Errorf("version %s is not an available update (available: %v)", requested, pending)
+		}
+		return marshalJSON(map[string]interface{}{
+			"current_version": currentVersion,
+			"update_pending":  false,
+			"message":         "No update is available; the system is up to date",
+		})
+	}
+
+	response := map[string]interface{}{
+		"current_version": currentVersion,
+		"update_pending":  true,
+		"version":         release["version"],
+		"train":           train,
+	}
+	if len(pending) > 1 {
+		response["available_versions"] = pending
+	}
+
+	notes, _ := release["release_notes"].(string)
+	notesURL, _ := release["release_notes_url"].(string)
+	if notes != "" {
+		response["release_notes"] = notes
+	}
+	if notesURL != "" {
+		response["release_notes_url"] = notesURL
+	}
+
+	if manifest, ok := release["manifest"].(map[string]interface{}); ok {
+		if changelog, ok := manifest["changelog"].(string); ok && changelog != "" {
+			response["changelog"] = changelog
+		}
+		if includeManifest {
+			response["manifest"] = manifest
+		}
+	}
+
+	switch {
+	case notes == "" && response["changelog"] == nil && notesURL != "":
+		response["note"] = "The update server did not include release notes text; review them at release_notes_url before running download_update"
+	case notes == "" && response["changelog"] == nil:
+		response["note"] = "The update server did not include release notes for this version"
+	default:
+		response["note"] = "Review these notes before running download_update and apply_update"
+	}
+
+	return marshalJSON(response)
+}
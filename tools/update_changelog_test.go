@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"testing"
+)
+
+func TestIntegrationGetUpdateChangelog(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("system.info", map[string]interface{}{"version": "25.04.1"})
+	server.SetResult("update.available_versions", []interface{}{
+		map[string]interface{}{
+			"train": "TrueNAS-SCALE-Fangtooth",
+			"version": map[string]interface{}{
+				"version":           "25.04.2",
+				"release_notes":     "Fixes SMB share ACL inheritance.",
+				"release_notes_url": "https://www.truenas.com/docs/scale/25.04/gettingstarted/scalereleasenotes/",
+				"manifest":          map[string]interface{}{"changelog": "NAS-1234 SMB fix", "date": "20250801"},
+			},
+		},
+	})
+
+	result, err := registry.CallTool("get_update_changelog", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_update_changelog failed: %v", err)
+	}
+	response := decodeResult(t, result)
+	if response["version"] != "25.04.2" || response["current_version"] != "25.04.1" {
+		t.Errorf("version = %v, current_version = %v", response["version"], response["current_version"])
+	}
+	if response["release_notes"] != "Fixes SMB share ACL inheritance." || response["changelog"] != "NAS-1234 SMB fix" {
+		t.Errorf("release_notes = %v, changelog = %v", response["release_notes"], response["changelog"])
+	}
+	if _, ok := response["manifest"]; ok {
+		t.Errorf("manifest included without include_manifest")
+	}
+
+	if _, err := registry.CallTool("get_update_changelog", map[string]interface{}{"version": "26.04.0"}); err == nil {
+		t.Fatal("expected error for unavailable version")
+	}
+}
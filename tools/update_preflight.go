@@ -0,0 +1,373 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Pre-update health gate for apply_update
+
+// Preflight check names, used as keys in --update-preflight
+const (
+	PreflightBootPoolHealth = "boot_pool_health"
+	PreflightBootPoolSpace  = "boot_pool_space"
+	PreflightConfigBackup   = "config_backup"
+	PreflightPools          = "pools"
+	PreflightSMART          = "smart"
+	PreflightCriticalAlerts = "critical_alerts"
+)
+
+// preflightChecks lists the checks in the order they are reported
+var preflightChecks = []string{
+	PreflightBootPoolHealth,
+	PreflightBootPoolSpace,
+	PreflightConfigBackup,
+	PreflightPools,
+	PreflightSMART,
+	PreflightCriticalAlerts,
+}
+
+// Policies for a failed preflight check
+const (
+	PreflightBlock  = "block"  // apply_update refuses to run
+	PreflightWarn   = "warn"   // apply_update runs and reports the failure
+	PreflightIgnore = "ignore" // the check is skipped
+)
+
+const (
+	// minBootPoolFreeBytes is the boot pool space an update needs for the new
+	// boot environment
+	minBootPoolFreeBytes = 3 << 30
+
+	// maxConfigBackupAge is how old the newest configuration backup may be
+	maxConfigBackupAge = 7 * 24 * time.Hour
+
+	// systemDatasetPath holds the daily configuration backups
+	// (configs-<uuid>/<version>/<YYYYMMDD>.db)
+	systemDatasetPath = "/var/db/system"
+)
+
+// UpdatePreflightPolicy maps each preflight check to its policy
+type UpdatePreflightPolicy map[string]string
+
+// DefaultUpdatePreflightPolicy blocks updates on any failed check
+func DefaultUpdatePreflightPolicy() UpdatePreflightPolicy {
+	policy := UpdatePreflightPolicy{}
+	for _, check := range preflightChecks {
+		policy[check] = PreflightBlock
+	}
+	return policy
+}
+
+// ParseUpdatePreflightPolicy applies overrides given as "check=policy" pairs
+// ("all" sets every check) to the default policy, e.g. "all=warn,pools=block"
+func ParseUpdatePreflightPolicy(spec string) (UpdatePreflightPolicy, error) {
+	policy := DefaultUpdatePreflightPolicy()
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid preflight policy %q (expected check=block|warn|ignore)", entry)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != PreflightBlock && value != PreflightWarn && value != PreflightIgnore {
+			return nil, fmt.Errorf("invalid policy for %s: %q (must be block, warn, or ignore)", name, value)
+		}
+
+		if name == "all" {
+			for _, check := range preflightChecks {
+				policy[check] = value
+			}
+			continue
+		}
+		if _, known := policy[name]; !known {
+			return nil, fmt.Errorf("unknown preflight check %q (must be all or one of %s)", name, strings.Join(preflightChecks, ", "))
+		}
+		policy[name] = value
+	}
+
+	return policy, nil
+}
+
+// preflightCheck is the outcome of one check. Status is pass, fail, unknown
+// (the check could not be performed), or skipped (policy ignore).
+type preflightCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Policy string `json:"policy"`
+	Detail string `json:"detail"`
+}
+
+// preflightReport is the outcome of all checks
+type preflightReport struct {
+	Passed   bool             `json:"passed"`
+	Checks   []preflightCheck `json:"checks"`
+	Blocking []string         `json:"blocking,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// runUpdatePreflight checks that the system is safe to update. Failed checks
+// with policy block make the report fail; failures under warn and checks
+// that could not be performed are reported as warnings.
+func runUpdatePreflight(client truenas.Caller, policy UpdatePreflightPolicy, now time.Time) *preflightReport {
+	report := &preflightReport{Passed: true, Checks: []preflightCheck{}}
+
+	var bootPool map[string]interface{}
+	bootErr := queryInto(client, "boot.get_state", &bootPool)
+	var pools []map[string]interface{}
+	poolsErr := queryInto(client, "pool.query", &pools)
+
+	results := map[string]func() (bool, string, error){
+		PreflightBootPoolHealth: func() (bool, string, error) {
+			if bootErr != nil {
+				return false, "", bootErr
+			}
+			return checkPoolHealth(bootPool)
+		},
+		PreflightBootPoolSpace: func() (bool, string, error) {
+			if bootErr != nil {
+				return false, "", bootErr
+			}
+			free, ok := bootPool["free"].(float64)
+			if !ok {
+				return false, "", fmt.Errorf("boot pool free space not reported")
+			}
+			detail := fmt.Sprintf("%s free on the boot pool (%s required)", units.FormatBytes(int64(free)), units.FormatBytes(minBootPoolFreeBytes))
+			if free < minBootPoolFreeBytes {
+				return false, detail + "; use plan_boot_environment_cleanup to free space", nil
+			}
+			return true, detail, nil
+		},
+		PreflightConfigBackup: func() (bool, string, error) {
+			latest, err := latestConfigBackup(client)
+			if err != nil {
+				return false, "", err
+			}
+			if latest.IsZero() {
+				return false, fmt.Sprintf("No configuration backup found in %s; download one from System > General > Manage Configuration before updating", systemDatasetPath), nil
+			}
+			age := now.Sub(latest)
+			detail := fmt.Sprintf("Newest configuration backup is from %s (%d days ago)", latest.Format("2006-01-02"), int(age.Hours()/24))
+			if age > maxConfigBackupAge {
+				return false, detail + "; download one from System > General > Manage Configuration before updating", nil
+			}
+			return true, detail, nil
+		},
+		PreflightPools: func() (bool, string, error) {
+			if poolsErr != nil {
+				return false, "", poolsErr
+			}
+			problems := []string{}
+			for _, pool := range pools {
+				if ok, detail, _ := checkPoolHealth(pool); !ok {
+					problems = append(problems, detail)
+				}
+			}
+			if len(problems) > 0 {
+				return false, strings.Join(problems, "; "), nil
+			}
+			return true, fmt.Sprintf("All %d pools are healthy", len(pools)), nil
+		},
+		PreflightSMART: func() (bool, string, error) {
+			var results []map[string]interface{}
+			if err := queryInto(client, "smart.test.results", &results); err != nil {
+				return false, "", err
+			}
+			failing := failingSMARTDisks(results)
+			if len(failing) > 0 {
+				return false, fmt.Sprintf("Latest SMART test failed on %s", strings.Join(failing, ", ")), nil
+			}
+			return true, "No disk has a failed latest SMART test", nil
+		},
+		PreflightCriticalAlerts: func() (bool, string, error) {
+			var alerts []map[string]interface{}
+			if err := queryInto(client, "alert.list", &alerts); err != nil {
+				return false, "", err
+			}
+			critical := []string{}
+			for _, alert := range alerts {
+				if dismissed, _ := alert["dismissed"].(bool); dismissed {
+					continue
+				}
+				switch level, _ := alert["level"].(string); level {
+				case "CRITICAL", "ALERT", "EMERGENCY":
+					text, _ := alert["formatted"].(string)
+					if text == "" {
+						text, _ = alert["klass"].(string)
+					}
+					critical = append(critical, fmt.Sprintf("%s: %s", level, text))
+				}
+			}
+			if len(critical) > 0 {
+				return false, strings.Join(critical, "; "), nil
+			}
+			return true, "No critical alerts", nil
+		},
+	}
+
+	for _, name := range preflightChecks {
+		check := preflightCheck{Name: name, Policy: policy[name]}
+		if check.Policy == "" {
+			check.Policy = PreflightBlock
+		}
+		if check.Policy == PreflightIgnore {
+			check.Status = "skipped"
+			check.Detail = "Ignored by policy"
+			report.Checks = append(report.Checks, check)
+			continue
+		}
+
+		ok, detail, err := results[name]()
+		switch {
+		case err != nil:
+			check.Status = "unknown"
+			check.Detail = fmt.Sprintf("Could not be checked: %v", err)
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s: %s", name, check.Detail))
+		case ok:
+			check.Status = "pass"
+			check.Detail = detail
+		default:
+			check.Status = "fail"
+			check.Detail = detail
+			if check.Policy == PreflightBlock {
+				report.Passed = false
+				report.Blocking = append(report.Blocking, fmt.Sprintf("%s: %s", name, detail))
+			} else {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s: %s", name, detail))
+			}
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	return report
+}
+
+// queryInto calls a middleware method and decodes its result into v
+func queryInto(client truenas.Caller, method string, v interface{}) error {
+	result, err := client.Call(method)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(result, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", method, err)
+	}
+	return nil
+}
+
+// checkPoolHealth reports whether a pool and its member disks are online
+func checkPoolHealth(pool map[string]interface{}) (bool, string, error) {
+	name, _ := pool["name"].(string)
+	status, _ := pool["status"].(string)
+	healthy, hasHealthy := pool["healthy"].(bool)
+
+	problems := []string{}
+	if status != "" && status != "ONLINE" {
+		problems = append(problems, fmt.Sprintf("pool %s is %s", name, status))
+	} else if hasHealthy && !healthy {
+		problems = append(problems, fmt.Sprintf("pool %s is not healthy", name))
+	}
+	for _, disk := range collectPoolDisks(pool) {
+		if diskStatus, _ := disk["status"].(string); diskStatus != "" && diskStatus != "ONLINE" && diskStatus != "AVAIL" {
+			problems = append(problems, fmt.Sprintf("disk %v in %s is %s", disk["disk"], name, diskStatus))
+		}
+	}
+
+	if len(problems) > 0 {
+		return false, strings.Join(problems, "; "), nil
+	}
+	return true, fmt.Sprintf("Pool %s is ONLINE", name), nil
+}
+
+// failingSMARTDisks returns the disks whose latest SMART test did not succeed
+func failingSMARTDisks(results []map[string]interface{}) []string {
+	failing := []string{}
+	for _, disk := range results {
+		name, _ := disk["disk"].(string)
+		tests, _ := disk["tests"].([]interface{})
+		if len(tests) == 0 {
+			continue
+		}
+		// Tests are returned newest first
+		latest, _ := tests[0].(map[string]interface{})
+		if status, _ := latest["status"].(string); status != "" && status != "SUCCESS" && status != "RUNNING" {
+			failing = append(failing, fmt.Sprintf("%s (%s)", name, status))
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// latestConfigBackup finds the newest automatic configuration backup in the
+// system dataset. Returns the zero time when there is none.
+func latestConfigBackup(client truenas.Caller) (time.Time, error) {
+	listdir := func(path string) ([]map[string]interface{}, error) {
+		result, err := client.Call("filesystem.listdir", path)
+		if err != nil {
+			return nil, err
+		}
+		var entries []map[string]interface{}
+		if err := json.Unmarshal(result, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse listing of %s: %w", path, err)
+		}
+		return entries, nil
+	}
+
+	roots, err := listdir(systemDatasetPath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list %s: %w", systemDatasetPath, err)
+	}
+
+	var latest time.Time
+	for _, root := range roots {
+		name, _ := root["name"].(string)
+		path, _ := root["path"].(string)
+		if !strings.HasPrefix(name, "configs-") || path == "" {
+			continue
+		}
+		versions, err := listdir(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to list %s: %w", path, err)
+		}
+		for _, version := range versions {
+			versionPath, _ := version["path"].(string)
+			if kind, _ := version["type"].(string); kind != "DIRECTORY" || versionPath == "" {
+				continue
+			}
+			backups, err := listdir(versionPath)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("failed to list %s: %w", versionPath, err)
+			}
+			for _, backup := range backups {
+				file, _ := backup["name"].(string)
+				taken, err := time.ParseInLocation("20060102", strings.TrimSuffix(file, ".db"), time.Local)
+				if err == nil && taken.After(latest) {
+					latest = taken
+				}
+			}
+		}
+	}
+
+	return latest, nil
+}
+
+// preflightError refuses an update whose blocking checks failed
+func preflightError(report *preflightReport) *ToolError {
+	return &ToolError{
+		Code: ErrorPrecondition,
+		Message: fmt.Sprintf("update blocked by preflight checks: %s. Fix these first, or relax the policy with --update-preflight if the risk is understood",
+			strings.Join(report.Blocking, "; ")),
+		Details: report,
+	}
+}
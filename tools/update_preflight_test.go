@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestParseUpdatePreflightPolicy(t *testing.T) {
+	policy, err := ParseUpdatePreflightPolicy("")
+	if err != nil {
+		t.Fatalf("ParseUpdatePreflightPolicy(\"\") failed: %v", err)
+	}
+	for _, check := range preflightChecks {
+		if policy[check] != PreflightBlock {
+			t.Errorf("default policy for %s = %s, want block", check, policy[check])
+		}
+	}
+
+	policy, err = ParseUpdatePreflightPolicy("all=warn, pools=BLOCK,config_backup=ignore")
+	if err != nil {
+		t.Fatalf("ParseUpdatePreflightPolicy failed: %v", err)
+	}
+	want := map[string]string{
+		PreflightBootPoolHealth: PreflightWarn,
+		PreflightConfigBackup:   PreflightIgnore,
+		PreflightPools:          PreflightBlock,
+		PreflightCriticalAlerts: PreflightWarn,
+	}
+	for check, policyValue := range want {
+		if policy[check] != policyValue {
+			t.Errorf("policy for %s = %s, want %s", check, policy[check], policyValue)
+		}
+	}
+
+	for _, spec := range []string{"pools", "pools=maybe", "firmware=warn"} {
+		if _, err := ParseUpdatePreflightPolicy(spec); err == nil {
+			t.Errorf("ParseUpdatePreflightPolicy(%q) = nil error, want error", spec)
+		}
+	}
+}
+
+func TestFailingSMARTDisks(t *testing.T) {
+	results := []map[string]interface{}{
+		{"disk": "sda", "tests": []interface{}{map[string]interface{}{"status": "SUCCESS"}}},
+		{"disk": "sdb", "tests": []interface{}{map[string]interface{}{"status": "FAILED"}, map[string]interface{}{"status": "SUCCESS"}}},
+		{"disk": "sdc", "tests": []interface{}{}},
+	}
+	if got := strings.Join(failingSMARTDisks(results), ","); got != "sdb (FAILED)" {
+		t.Errorf("failingSMARTDisks() = %s, want sdb (FAILED)", got)
+	}
+}
+
+func TestIntegrationApplyUpdatePreflight(t *testing.T) {
+	registry, server := newTestRegistry(t)
+
+	bootPool := testPool("boot-pool", 2<<30, 10<<30)
+	server.SetResult("boot.get_state", bootPool)
+	degraded := testPool("tank", 40, 60)
+	degraded["status"] = "DEGRADED"
+	degraded["healthy"] = false
+	server.SetRecords("pool.query", []map[string]interface{}{degraded})
+	server.SetResult("smart.test.results", []interface{}{})
+	server.SetResult("alert.list", []interface{}{})
+	server.SetResult("system.info", map[string]interface{}{"version": "25.04.0"})
+	server.SetResult("update.status", map[string]interface{}{"status": map[string]interface{}{"new_version": "25.04.1"}})
+	today := time.Now().Format("20060102")
+	server.Handle("filesystem.listdir", func(params []interface{}) (interface{}, error) {
+		switch params[0] {
+		case "/var/db/system":
+			return []interface{}{map[string]interface{}{"name": "configs-abc", "path": "/var/db/system/configs-abc", "type": "DIRECTORY"}}, nil
+		case "/var/db/system/configs-abc":
+			return []interface{}{map[string]interface{}{"name": "25.04.0", "path": "/var/db/system/configs-abc/25.04.0", "type": "DIRECTORY"}}, nil
+		default:
+			return []interface{}{map[string]interface{}{"name": today + ".db", "type": "FILE"}}, nil
+		}
+	})
+	server.HandleJob("update.run", truenastest.JobSpec{Steps: 1})
+
+	preview, err := registry.CallTool("apply_update", map[string]interface{}{"dry_run": true})
+	if err != nil {
+		t.Fatalf("apply_update dry run failed: %v", err)
+	}
+	if !strings.Contains(preview, "BLOCKED: apply_update will refuse to run: pools: pool tank is DEGRADED") {
+		t.Errorf("dry run does not lead with the blocking check:\n%s", preview)
+	}
+
+	_, err = registry.CallTool("apply_update", map[string]interface{}{})
+	if toolErr := ClassifyError(err); toolErr == nil || toolErr.Code != ErrorPrecondition {
+		t.Fatalf("apply_update error = %v, want PRECONDITION_FAILED", err)
+	}
+	if calls := server.Calls("update.run"); len(calls) != 0 {
+		t.Fatalf("update.run called despite failed preflight: %v", calls)
+	}
+
+	// Under a warn policy the update runs and reports the failure
+	registry.updatePreflight[PreflightPools] = PreflightWarn
+	result, err := registry.CallTool("apply_update", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("apply_update with warn policy failed: %v", err)
+	}
+	warnings, _ := decodeResult(t, result)["preflight_warnings"].([]interface{})
+	if len(warnings) != 1 || !strings.Contains(warnings[0].(string), "DEGRADED") {
+		t.Errorf("preflight_warnings = %v, want the degraded pool", warnings)
+	}
+	if calls := server.Calls("update.run"); len(calls) != 1 {
+		t.Errorf("update.run called %d times, want 1", len(calls))
+	}
+}
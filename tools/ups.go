@@ -0,0 +1,541 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// UPS service configuration and status (ups)
+
+// upsShutdownModes maps the ups.config shutdown setting to when it starts a
+// shutdown
+var upsShutdownModes = map[string]string{
+	"LOWBATT": "when the UPS reports a low battery",
+	"BATT":    "after running on battery for shutdowntimer seconds",
+}
+
+// upsIdentifierPattern matches UPS names upsd accepts
+var upsIdentifierPattern = regexp.MustCompile(`^[\w.-]+$`)
+
+// upsLowChargePercent is the battery charge below which a power cut may not
+// leave time for a clean shutdown
+const upsLowChargePercent = 50
+
+// Readiness verdicts, best to worst
+const (
+	upsReady    = "READY"
+	upsAtRisk   = "AT_RISK"
+	upsNotReady = "NOT_READY"
+)
+
+// getUPSConfig returns ups.config
+func getUPSConfig(client truenas.Caller) (map[string]interface{}, error) {
+	result, err := client.Call("ups.config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UPS configuration: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(result, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse UPS configuration: %w", err)
+	}
+	return config, nil
+}
+
+// simplifyUPSConfig summarizes the UPS configuration and service state. The
+// monitor password is reported only as set or not.
+func simplifyUPSConfig(config, service map[string]interface{}) map[string]interface{} {
+	shutdown, _ := config["shutdown"].(string)
+	summary := map[string]interface{}{
+		"mode":           config["mode"],
+		"identifier":     config["identifier"],
+		"driver":         config["driver"],
+		"port":           config["port"],
+		"remotehost":     config["remotehost"],
+		"remoteport":     config["remoteport"],
+		"description":    config["description"],
+		"shutdown":       shutdown,
+		"shutdown_human": "Shut down " + upsShutdownModes[shutdown],
+		"shutdowntimer":  config["shutdowntimer"],
+		"hostsync":       config["hostsync"],
+		"powerdown":      config["powerdown"],
+		"monuser":        config["monuser"],
+		"monpwd_set":     config["monpwd"] != nil && config["monpwd"] != "",
+	}
+	if shutdown == "" {
+		delete(summary, "shutdown_human")
+	}
+	if service != nil {
+		summary["service"] = map[string]interface{}{
+			"state":         service["state"],
+			"start_on_boot": service["enable"],
+		}
+	}
+	return summary
+}
+
+// upsUpdate builds the ups.update payload from args
+func upsUpdate(args map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+
+	if mode, ok := args["mode"].(string); ok {
+		mode = strings.ToUpper(mode)
+		if mode != "MASTER" && mode != "SLAVE" {
+			return nil, newToolError(ErrorValidation, "mode must be MASTER (UPS attached to this NAS) or SLAVE (monitor a UPS on another host)")
+		}
+		update["mode"] = mode
+	}
+	if identifier, ok := args["identifier"].(string); ok {
+		if !upsIdentifierPattern.MatchString(identifier) {
+			return nil, newToolError(ErrorValidation, "identifier must contain only letters, digits, '.', '_', and '-', got %q", identifier)
+		}
+		update["identifier"] = identifier
+	}
+	for _, field := range []string{"driver", "port", "remotehost", "description", "monuser", "monpwd"} {
+		if value, ok := args[field].(string); ok {
+			update[field] = value
+		}
+	}
+	if port, ok := args["remoteport"].(float64); ok {
+		if port < 1 || port > 65535 {
+			return nil, newToolError(ErrorValidation, "remoteport must be between 1 and 65535")
+		}
+		update["remoteport"] = float64(int(port))
+	}
+	if shutdown, ok := args["shutdown"].(string); ok {
+		shutdown = strings.ToUpper(shutdown)
+		if _, known := upsShutdownModes[shutdown]; !known {
+			return nil, newToolError(ErrorValidation, "shutdown must be LOWBATT or BATT")
+		}
+		update["shutdown"] = shutdown
+	}
+	for _, field := range []string{"shutdowntimer", "hostsync"} {
+		if value, ok := args[field].(float64); ok {
+			if value < 0 {
+				return nil, newToolError(ErrorValidation, "%s must not be negative", field)
+			}
+			update[field] = float64(int(value))
+		}
+	}
+	if powerdown, ok := args["powerdown"].(bool); ok {
+		update["powerdown"] = powerdown
+	}
+
+	_, serviceChange := args["enable_service"].(bool)
+	if len(update) == 0 && !serviceChange {
+		return nil, fmt.Errorf("nothing to update: provide UPS settings or enable_service")
+	}
+	return update, nil
+}
+
+// mergeUPSConfig returns config with update applied
+func mergeUPSConfig(config, update map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range config {
+		merged[k] = v
+	}
+	for k, v := range update {
+		merged[k] = v
+	}
+	return merged
+}
+
+// checkUPSConnection requires what the mode needs to reach the UPS: a
+// driver and port for an attached UPS, a remote host otherwise
+func checkUPSConnection(config map[string]interface{}) error {
+	if config["mode"] == "SLAVE" {
+		if host, _ := config["remotehost"].(string); host == "" {
+			return newToolError(ErrorValidation, "SLAVE mode requires remotehost, the host the UPS is attached to")
+		}
+		return nil
+	}
+	driver, _ := config["driver"].(string)
+	port, _ := config["port"].(string)
+	if driver == "" || port == "" {
+		return newToolError(ErrorValidation, "MASTER mode requires driver (see ups.driver_choices) and port ('auto' for most USB UPSes)")
+	}
+	return nil
+}
+
+// checkUPSDriver requires a driver from ups.driver_choices. An unknown
+// driver is reported with the choices that mention it.
+func checkUPSDriver(client truenas.Caller, driver string) error {
+	result, err := client.Call("ups.driver_choices")
+	if err != nil {
+		return fmt.Errorf("failed to get UPS driver choices: %w", err)
+	}
+	var choices map[string]string
+	if err := json.Unmarshal(result, &choices); err != nil {
+		return fmt.Errorf("failed to parse UPS driver choices: %w", err)
+	}
+	if _, ok := choices[driver]; ok {
+		return nil
+	}
+
+	matches := []string{}
+	needle := strings.ToLower(driver)
+	for key, description := range choices {
+		if needle != "" && strings.Contains(strings.ToLower(key+" "+description), needle) {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	if len(matches) == 0 {
+		return newToolError(ErrorValidation, "unknown UPS driver %q; driver must be a key of ups.driver_choices", driver)
+	}
+	if len(matches) > 10 {
+		matches = append(matches[:10], "...")
+	}
+	return newToolError(ErrorValidation, "unknown UPS driver %q; matching drivers: %s", driver, strings.Join(matches, ", "))
+}
+
+// validateUPSUpdate checks an update against the NAS: the driver must be one
+// it knows, and turning the service on needs a way to reach the UPS
+func validateUPSUpdate(client truenas.Caller, config, update map[string]interface{}, args map[string]interface{}) error {
+	if driver, ok := update["driver"].(string); ok && driver != "" {
+		if err := checkUPSDriver(client, driver); err != nil {
+			return err
+		}
+	}
+	_, modeChanged := update["mode"]
+	enable, _ := args["enable_service"].(bool)
+	if modeChanged || enable {
+		return checkUPSConnection(mergeUPSConfig(config, update))
+	}
+	return nil
+}
+
+// latestUPSReading returns the newest value of a UPS reporting graph in the
+// last hour and when it was taken
+func latestUPSReading(client truenas.Caller, graph string) (float64, time.Time, bool, error) {
+	summaries, err := fetchReportingGraph(client, graph, nil, metricsQuery{Unit: "HOUR", Factor: 1})
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	rows, _ := firstReportingSummary(summaries)["data"].([]interface{})
+	for i := len(rows) - 1; i >= 0; i-- {
+		row, ok := rows[i].([]interface{})
+		if !ok || len(row) < 2 {
+			continue
+		}
+		value, ok := row[1].(float64)
+		if !ok {
+			continue
+		}
+		ts, _ := row[0].(float64)
+		return value, time.Unix(int64(ts), 0), true, nil
+	}
+	return 0, time.Time{}, false, nil
+}
+
+// upsAlerts returns the active UPS alerts (on battery, low battery, lost
+// communication, replace battery)
+func upsAlerts(client truenas.Caller) ([]map[string]interface{}, error) {
+	result, err := client.Call("alert.list")
+	if err != nil {
+		return nil, err
+	}
+	var alerts []map[string]interface{}
+	if err := json.Unmarshal(result, &alerts); err != nil {
+		return nil, fmt.Errorf("failed to parse alerts: %w", err)
+	}
+
+	active := []map[string]interface{}{}
+	for _, alert := range alerts {
+		klass, _ := alert["klass"].(string)
+		if !strings.HasPrefix(klass, "UPS") {
+			continue
+		}
+		if dismissed, _ := alert["dismissed"].(bool); dismissed {
+			continue
+		}
+		active = append(active, map[string]interface{}{
+			"klass":   klass,
+			"level":   alert["level"],
+			"message": alert["formatted"],
+		})
+	}
+	return active, nil
+}
+
+// upsReadiness judges whether the NAS will shut down cleanly on power loss,
+// listing the issues that produced the verdict
+func upsReadiness(config, service, readings map[string]interface{}, alerts []map[string]interface{}) (string, []string) {
+	verdict := upsReady
+	issues := []string{}
+	flag := func(level, issue string) {
+		if level == upsNotReady || verdict == upsReady {
+			verdict = level
+		}
+		issues = append(issues, issue)
+	}
+
+	if state, _ := service["state"].(string); state != "RUNNING" {
+		flag(upsNotReady, "The UPS service is not running, so nothing shuts the NAS down on power loss")
+	} else if enabled, _ := service["enable"].(bool); !enabled {
+		flag(upsAtRisk, "The UPS service does not start on boot; after a reboot the NAS is unprotected until it is started")
+	}
+	if err := checkUPSConnection(config); err != nil {
+		flag(upsNotReady, err.Error())
+	}
+
+	for _, alert := range alerts {
+		switch alert["klass"] {
+		case "UPSCommbad":
+			flag(upsNotReady, "The NAS has lost communication with the UPS")
+		case "UPSReplbatt":
+			flag(upsAtRisk, "The UPS reports its battery needs replacing")
+		case "UPSBatteryLow":
+			flag(upsAtRisk, "The UPS battery is low")
+		}
+	}
+
+	if charge, ok := readings["battery_charge_percent"].(float64); ok && charge < upsLowChargePercent {
+		flag(upsAtRisk, fmt.Sprintf("Battery charge is %.0f%%; a power cut now may not leave time for a clean shutdown", charge))
+	}
+	if config["shutdown"] == "BATT" {
+		timer, _ := config["shutdowntimer"].(float64)
+		if runtime, ok := readings["runtime_seconds"].(float64); ok {
+			switch {
+			case timer >= runtime:
+				flag(upsNotReady, fmt.Sprintf("shutdowntimer (%.0fs) is not shorter than the battery runtime (%.0fs); the battery runs out before the shutdown starts", timer, runtime))
+			case timer > runtime/2:
+				flag(upsAtRisk, fmt.Sprintf("shutdowntimer (%.0fs) uses more than half of the battery runtime (%.0fs), leaving little time to shut down", timer, runtime))
+			}
+		}
+	}
+	if len(readings) == 0 {
+		if state, _ := service["state"].(string); state == "RUNNING" {
+			flag(upsAtRisk, "No UPS readings in the last hour; check the driver, port, and cable")
+		}
+	}
+	return verdict, issues
+}
+
+func handleGetUPSConfig(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getUPSConfig(client)
+	if err != nil {
+		return "", err
+	}
+	service, err := getService(client, "ups")
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(simplifyUPSConfig(config, service))
+}
+
+func handleGetUPSStatus(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getUPSConfig(client)
+	if err != nil {
+		return "", err
+	}
+	service, err := getService(client, "ups")
+	if err != nil {
+		return "", err
+	}
+
+	notes := []string{}
+	readings := map[string]interface{}{}
+	var readAt time.Time
+	for graph, name := range map[string]string{
+		"upscharge":  "battery_charge_percent",
+		"upsruntime": "runtime_seconds",
+		"upsload":    "load_percent",
+	} {
+		value, at, ok, err := latestUPSReading(client, graph)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("%s unavailable: %v", graph, err))
+			continue
+		}
+		if ok {
+			readings[name] = value
+			if at.After(readAt) {
+				readAt = at
+			}
+		}
+	}
+
+	alerts, err := upsAlerts(client)
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("alerts unavailable: %v", err))
+		alerts = []map[string]interface{}{}
+	}
+
+	power := "UNKNOWN"
+	if len(readings) > 0 {
+		power = "ONLINE"
+	}
+	for _, alert := range alerts {
+		if alert["klass"] == "UPSOnBattery" {
+			power = "ON_BATTERY"
+		}
+	}
+
+	verdict, issues := upsReadiness(config, service, readings, alerts)
+	response := map[string]interface{}{
+		"power":    power,
+		"readings": readings,
+		"alerts":   alerts,
+		"shutdown_readiness": map[string]interface{}{
+			"verdict": verdict,
+			"issues":  issues,
+		},
+		"config": simplifyUPSConfig(config, service),
+	}
+	if !readAt.IsZero() {
+		response["readings_as_of"] = getNASClock(client).format(readAt)
+	}
+	if powerdown, _ := config["powerdown"].(bool); !powerdown {
+		notes = append(notes, "powerdown is off: the UPS keeps its outlets on after the NAS shuts down, so if power returns before the battery runs out the NAS stays off until started by hand")
+	}
+	if len(notes) > 0 {
+		response["notes"] = notes
+	}
+	return marshalJSON(response)
+}
+
+func handleUpdateUPSConfig(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	config, err := getUPSConfig(client)
+	if err != nil {
+		return "", err
+	}
+	update, err := upsUpdate(args)
+	if err != nil {
+		return "", err
+	}
+	if err := validateUPSUpdate(client, config, update, args); err != nil {
+		return "", err
+	}
+
+	if len(update) > 0 {
+		result, err := client.Call("ups.update", update)
+		if err != nil {
+			return "", fmt.Errorf("failed to update UPS configuration: %w", err)
+		}
+		if err := json.Unmarshal(result, &config); err != nil {
+			return "", fmt.Errorf("failed to parse result: %w", err)
+		}
+	}
+
+	// Start on boot and running state follow enable_service together
+	if enable, ok := args["enable_service"].(bool); ok {
+		if _, err := client.Call("service.update", "ups", map[string]interface{}{"enable": enable}); err != nil {
+			return "", fmt.Errorf("failed to set UPS service start on boot: %w", err)
+		}
+		method, action := "service.start", "started"
+		if !enable {
+			method, action = "service.stop", "stopped"
+		}
+		if _, err := client.Call(method, "ups"); err != nil {
+			return "", fmt.Errorf("UPS settings were saved but the service could not be %s: %w", action, err)
+		}
+	}
+
+	service, err := getService(client, "ups")
+	if err != nil {
+		return "", err
+	}
+	response := simplifyUPSConfig(config, service)
+	response["updated"] = true
+	if enable, ok := args["enable_service"].(bool); ok && !enable {
+		response["message"] = "UPS service stopped and disabled on boot; the NAS will not shut down on power loss"
+	} else if state, _ := service["state"].(string); state != "RUNNING" {
+		response["note"] = "The UPS service is not running; set enable_service=true to start it"
+	} else {
+		response["note"] = "Check get_ups_status to confirm the NAS is reading the UPS"
+	}
+	return marshalJSON(response)
+}
+
+func handleUpdateUPSConfigWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateUPSConfigDryRun{}, handleUpdateUPSConfig)
+}
+
+type updateUPSConfigDryRun struct{}
+
+func (u *updateUPSConfigDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	config, err := getUPSConfig(client)
+	if err != nil {
+		return nil, err
+	}
+	service, err := getService(client, "ups")
+	if err != nil {
+		return nil, err
+	}
+	update, err := upsUpdate(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUPSUpdate(client, config, update, args); err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{}
+	warnings := []string{}
+	if len(update) > 0 {
+		changes := map[string]interface{}{}
+		for field, value := range update {
+			// Output redaction masks strings, not old/new pairs
+			if isSensitiveKey(field) {
+				changes[field] = map[string]interface{}{"changed": true}
+				continue
+			}
+			changes[field] = map[string]interface{}{"old": config[field], "new": value}
+		}
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: "Update the UPS configuration",
+			Operation:   "update",
+			Target:      "ups",
+			Details:     changes,
+		})
+
+		for _, field := range []string{"mode", "driver", "port", "remotehost", "identifier"} {
+			if _, ok := update[field]; ok {
+				if state, _ := service["state"].(string); state == "RUNNING" {
+					warnings = append(warnings, "UPS monitoring restarts; power events during the restart are missed")
+				}
+				break
+			}
+		}
+		merged := mergeUPSConfig(config, update)
+		if merged["shutdown"] == "BATT" {
+			if runtime, _, ok, err := latestUPSReading(client, "upsruntime"); err == nil && ok {
+				if timer, _ := merged["shutdowntimer"].(float64); timer >= runtime {
+					warnings = append(warnings, fmt.Sprintf("shutdowntimer (%.0fs) is not shorter than the current battery runtime (%.0fs); the battery would run out before the shutdown starts", timer, runtime))
+				}
+			}
+		}
+		if powerdown, ok := update["powerdown"].(bool); ok && powerdown {
+			warnings = append(warnings, "powerdown turns the UPS outlets off after the NAS shuts down; other equipment on the UPS loses power too")
+		}
+	}
+
+	if enable, ok := args["enable_service"].(bool); ok {
+		description := "Enable the UPS service on boot and start it"
+		if !enable {
+			description = "Disable the UPS service on boot and stop it"
+			warnings = append(warnings, "Without the UPS service the NAS will not shut down on power loss")
+		}
+		actions = append(actions, PlannedAction{
+			Step:        len(actions) + 1,
+			Description: description,
+			Operation:   "update",
+			Target:      "service:ups",
+		})
+	}
+
+	return &DryRunResult{
+		Tool:           "update_ups_config",
+		CurrentState:   simplifyUPSConfig(config, service),
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationUPS(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetResult("ups.config", map[string]interface{}{
+		"id": float64(1), "mode": "MASTER", "identifier": "ups", "driver": "usbhid-ups$Back-UPS ES USB", "port": "auto",
+		"remotehost": "", "remoteport": float64(3493), "shutdown": "BATT", "shutdowntimer": float64(900), "hostsync": float64(15),
+		"powerdown": true, "monuser": "upsmon", "monpwd": "upsm0npass", "description": "",
+	})
+	server.SetRecords("service.query", []map[string]interface{}{{"service": "ups", "state": "RUNNING", "enable": true}})
+	server.SetResult("ups.driver_choices", map[string]interface{}{
+		"usbhid-ups$Back-UPS ES USB":    "APC ups 2 Back-UPS ES USB (usbhid-ups)",
+		"usbhid-ups$Back-UPS Pro USB":   "APC ups 2 Back-UPS Pro USB (usbhid-ups)",
+		"blazer_ser$Cyber Power Online": "Cyber Power Systems ups Online (blazer_ser)",
+	})
+	server.Handle("reporting.get_data", func(params []interface{}) (interface{}, error) {
+		values := map[string]float64{"upscharge": 100, "upsruntime": 1200, "upsload": 23}
+		name := params[0].([]interface{})[0].(map[string]interface{})["name"].(string)
+		return []interface{}{map[string]interface{}{
+			"name": name,
+			"data": []interface{}{
+				[]interface{}{float64(1767225600), float64(values[name] + 1)},
+				[]interface{}{float64(1767225660), values[name]},
+				[]interface{}{float64(1767225720), nil},
+			},
+		}}, nil
+	})
+	server.SetResult("alert.list", []map[string]interface{}{
+		{"klass": "UPSOnBattery", "level": "CRITICAL", "formatted": "UPS ups is on battery power", "dismissed": false},
+		{"klass": "ScrubPaused", "level": "WARNING", "formatted": "Scrub of tank paused", "dismissed": false},
+	})
+	server.SetResult("ups.update", map[string]interface{}{"id": float64(1), "mode": "MASTER", "shutdown": "LOWBATT"})
+
+	result, err := registry.CallTool("get_ups_config", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_ups_config failed: %v", err)
+	}
+	if strings.Contains(result, "upsm0npass") || decodeResult(t, result)["monpwd_set"] != true {
+		t.Errorf("get_ups_config should report the monitor password only as set: %s", result)
+	}
+
+	result, err = registry.CallTool("get_ups_status", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("get_ups_status failed: %v", err)
+	}
+	status := decodeResult(t, result)
+	readings := status["readings"].(map[string]interface{})
+	if status["power"] != "ON_BATTERY" || readings["runtime_seconds"] != float64(1200) || len(status["alerts"].([]interface{})) != 1 {
+		t.Errorf("get_ups_status = %s", result)
+	}
+	readiness := status["shutdown_readiness"].(map[string]interface{})
+	if readiness["verdict"] != "AT_RISK" || !strings.Contains(fmt.Sprint(readiness["issues"]), "more than half") {
+		t.Errorf("shutdown_readiness = %v, want AT_RISK for a timer over half the runtime", readiness)
+	}
+
+	if _, err := registry.CallTool("update_ups_config", map[string]interface{}{"driver": "back-ups"}); ClassifyError(err).Code != ErrorValidation || !strings.Contains(err.Error(), "usbhid-ups$Back-UPS Pro USB") {
+		t.Errorf("partial driver error = %v, want VALIDATION listing the matches", err)
+	}
+	if _, err := registry.CallTool("update_ups_config", map[string]interface{}{"mode": "SLAVE"}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("SLAVE without remotehost error = %v, want VALIDATION", err)
+	}
+
+	result, err = registry.CallTool("update_ups_config", map[string]interface{}{"shutdowntimer": float64(1500), "monpwd": "n3wmonpass", "dry_run": true})
+	if err != nil {
+		t.Fatalf("update_ups_config dry run failed: %v", err)
+	}
+	if strings.Contains(result, "n3wmonpass") || !strings.Contains(result, "battery would run out") {
+		t.Errorf("dry run should mask the password and warn about the timer: %s", result)
+	}
+	if calls := server.Calls("ups.update"); len(calls) != 0 {
+		t.Fatalf("dry run called ups.update: %v", calls)
+	}
+
+	if _, err := registry.CallTool("update_ups_config", map[string]interface{}{"shutdown": "lowbatt"}); err != nil {
+		t.Fatalf("update_ups_config failed: %v", err)
+	}
+	if payload := server.Calls("ups.update")[0].Params[0].(map[string]interface{}); len(payload) != 1 || payload["shutdown"] != "LOWBATT" {
+		t.Errorf("ups.update payload = %v", payload)
+	}
+}
@@ -0,0 +1,828 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Local user and group management (user.*, group.*)
+
+// Same rules as the middleware: letters, digits, '.', '_', '-' and a trailing
+// '$' for machine accounts, not starting with a digit, dot or hyphen
+var accountNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*\$?$`)
+
+// validateAccountName checks a user or group name
+func validateAccountName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name is required", kind)
+	}
+	if len(name) > 32 {
+		return newToolError(ErrorValidation, "%s name must be at most 32 characters (got %d)", kind, len(name))
+	}
+	if !accountNamePattern.MatchString(name) {
+		return newToolError(ErrorValidation, "invalid %s name %q: use letters, digits, '.', '_' and '-', not starting with a digit, '.' or '-'", kind, name)
+	}
+	return nil
+}
+
+// queryAccounts runs user.query or group.query
+func queryAccounts(client truenas.Caller, method string, filters []interface{}) ([]map[string]interface{}, error) {
+	result, err := client.Call(method, filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", strings.TrimSuffix(method, ".query")+"s", err)
+	}
+
+	var accounts []map[string]interface{}
+	if err := json.Unmarshal(result, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", method, err)
+	}
+	return accounts, nil
+}
+
+// getUser returns a user by username
+func getUser(client truenas.Caller, username string) (map[string]interface{}, error) {
+	users, err := queryAccounts(client, "user.query", []interface{}{
+		[]interface{}{"username", "=", username},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, newToolError(ErrorNotFound, "user %s not found", username)
+	}
+	return users[0], nil
+}
+
+// getGroup returns a group by name
+func getGroup(client truenas.Caller, name string) (map[string]interface{}, error) {
+	groups, err := queryAccounts(client, "group.query", []interface{}{
+		[]interface{}{"group", "=", name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, newToolError(ErrorNotFound, "group %s not found", name)
+	}
+	return groups[0], nil
+}
+
+// groupNamesByID maps group database IDs (as used in user groups lists) to
+// group names
+func groupNamesByID(client truenas.Caller) (map[float64]string, error) {
+	groups, err := queryAccounts(client, "group.query", []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	names := map[float64]string{}
+	for _, group := range groups {
+		if id, ok := group["id"].(float64); ok {
+			names[id], _ = group["group"].(string)
+		}
+	}
+	return names, nil
+}
+
+// groupIDs resolves group names to database IDs
+func groupIDs(client truenas.Caller, names []string) ([]int, error) {
+	ids := []int{}
+	for _, name := range names {
+		group, err := getGroup(client, name)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := group["id"].(float64)
+		ids = append(ids, int(id))
+	}
+	return ids, nil
+}
+
+// userGroupNames returns the names of a user's auxiliary groups
+func userGroupNames(user map[string]interface{}, names map[float64]string) []string {
+	groups := []string{}
+	ids, _ := user["groups"].([]interface{})
+	for _, raw := range ids {
+		if id, ok := raw.(float64); ok {
+			if name, ok := names[id]; ok {
+				groups = append(groups, name)
+			} else {
+				groups = append(groups, fmt.Sprintf("id %v", id))
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// simplifyUser summarizes a user. Password hashes are never included and the
+// SSH key only as whether one is set.
+func simplifyUser(user map[string]interface{}, groupNames map[float64]string) map[string]interface{} {
+	sshKey, _ := user["sshpubkey"].(string)
+	summary := map[string]interface{}{
+		"id":                user["id"],
+		"uid":               user["uid"],
+		"username":          user["username"],
+		"full_name":         user["full_name"],
+		"email":             user["email"],
+		"home":              user["home"],
+		"shell":             user["shell"],
+		"locked":            user["locked"],
+		"password_disabled": user["password_disabled"],
+		"smb":               user["smb"],
+		"ssh_key_set":       strings.TrimSpace(sshKey) != "",
+		"builtin":           user["builtin"],
+		"groups":            userGroupNames(user, groupNames),
+	}
+	if group, ok := user["group"].(map[string]interface{}); ok {
+		summary["primary_group"] = group["bsdgrp_group"]
+		summary["gid"] = group["bsdgrp_gid"]
+	}
+	return summary
+}
+
+func handleQueryUsers(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filters := []interface{}{}
+	if includeBuiltin, _ := args["include_builtin"].(bool); !includeBuiltin {
+		filters = append(filters, []interface{}{"builtin", "=", false})
+	}
+	if username, ok := args["username"].(string); ok && username != "" {
+		filters = append(filters, []interface{}{"username", "=", username})
+	}
+
+	users, err := queryAccounts(client, "user.query", filters)
+	if err != nil {
+		return "", err
+	}
+	groupNames, err := groupNamesByID(client)
+	if err != nil {
+		return "", err
+	}
+
+	groupFilter, _ := args["group"].(string)
+	summaries := []map[string]interface{}{}
+	for _, user := range users {
+		summary := simplifyUser(user, groupNames)
+		if groupFilter != "" && summary["primary_group"] != groupFilter && !containsString(summary["groups"].([]string), groupFilter) {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, _ := summaries[i]["username"].(string)
+		b, _ := summaries[j]["username"].(string)
+		return a < b
+	})
+
+	return marshalJSON(map[string]interface{}{
+		"users": summaries,
+		"count": len(summaries),
+	})
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func handleQueryGroups(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	filters := []interface{}{}
+	if includeBuiltin, _ := args["include_builtin"].(bool); !includeBuiltin {
+		filters = append(filters, []interface{}{"builtin", "=", false})
+	}
+	if name, ok := args["name"].(string); ok && name != "" {
+		filters = append(filters, []interface{}{"group", "=", name})
+	}
+
+	groups, err := queryAccounts(client, "group.query", filters)
+	if err != nil {
+		return "", err
+	}
+	users, err := queryAccounts(client, "user.query", []interface{}{})
+	if err != nil {
+		return "", err
+	}
+	usernames := map[float64]string{}
+	for _, user := range users {
+		if id, ok := user["id"].(float64); ok {
+			usernames[id], _ = user["username"].(string)
+		}
+	}
+
+	summaries := []map[string]interface{}{}
+	for _, group := range groups {
+		members := []string{}
+		ids, _ := group["users"].([]interface{})
+		for _, raw := range ids {
+			if id, ok := raw.(float64); ok {
+				if name, ok := usernames[id]; ok {
+					members = append(members, name)
+				}
+			}
+		}
+		sort.Strings(members)
+		summaries = append(summaries, map[string]interface{}{
+			"id":           group["id"],
+			"gid":          group["gid"],
+			"name":         group["group"],
+			"smb":          group["smb"],
+			"builtin":      group["builtin"],
+			"members":      members,
+			"member_count": len(members),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, _ := summaries[i]["name"].(string)
+		b, _ := summaries[j]["name"].(string)
+		return a < b
+	})
+
+	return marshalJSON(map[string]interface{}{
+		"groups": summaries,
+		"count":  len(summaries),
+	})
+}
+
+// userFields reads the fields shared by create_user and update_user into a
+// user.create/user.update payload
+func userFields(client truenas.Caller, args map[string]interface{}, payload map[string]interface{}) error {
+	for arg, field := range map[string]string{"full_name": "full_name", "email": "email", "shell": "shell", "ssh_public_key": "sshpubkey"} {
+		if v, ok := args[arg].(string); ok {
+			payload[field] = v
+		}
+	}
+	if key, ok := payload["sshpubkey"].(string); ok && key != "" && !strings.HasPrefix(key, "ssh-") && !strings.HasPrefix(key, "ecdsa-") && !strings.HasPrefix(key, "sk-") {
+		return newToolError(ErrorValidation, "ssh_public_key must be an OpenSSH public key (e.g., 'ssh-ed25519 AAAA...')")
+	}
+	for _, field := range []string{"smb", "locked", "password_disabled"} {
+		if v, ok := args[field].(bool); ok {
+			payload[field] = v
+		}
+	}
+	if password, ok := args["password"].(string); ok && password != "" {
+		payload["password"] = password
+	}
+	if _, ok := args["groups"]; ok {
+		ids, err := groupIDs(client, stringList(args["groups"]))
+		if err != nil {
+			return err
+		}
+		payload["groups"] = ids
+	}
+	if home, ok := args["home"].(string); ok && home != "" {
+		if !strings.HasPrefix(home, "/mnt/") && home != "/var/empty" {
+			return newToolError(ErrorValidation, "home must be a directory under /mnt/ or /var/empty (got: %s)", home)
+		}
+		payload["home"] = home
+		if home != "/var/empty" {
+			payload["home_create"] = true
+		}
+	}
+	if payload["smb"] == true && payload["password_disabled"] == true {
+		return newToolError(ErrorValidation, "SMB users need a password; password_disabled cannot be combined with smb")
+	}
+	return nil
+}
+
+// userCreate builds the user.create payload from args
+func userCreate(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, error) {
+	username, _ := args["username"].(string)
+	if err := validateAccountName("user", username); err != nil {
+		return nil, err
+	}
+	fullName, _ := args["full_name"].(string)
+	if fullName == "" {
+		return nil, fmt.Errorf("full_name is required")
+	}
+
+	create := map[string]interface{}{
+		"username": username,
+		"smb":      true,
+	}
+	if err := userFields(client, args, create); err != nil {
+		return nil, err
+	}
+	if _, ok := create["password"]; !ok && create["password_disabled"] != true {
+		return nil, newToolError(ErrorValidation, "password is required unless password_disabled is set")
+	}
+	if uid, ok := args["uid"].(float64); ok {
+		create["uid"] = int(uid)
+	}
+
+	if groupName, ok := args["group"].(string); ok && groupName != "" {
+		group, err := getGroup(client, groupName)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := group["id"].(float64)
+		create["group"] = int(id)
+	} else {
+		create["group_create"] = true
+	}
+	return create, nil
+}
+
+// userPayloadPreview shows a user payload with the password and SSH key
+// reduced to whether they change
+func userPayloadPreview(payload map[string]interface{}, groupNames map[float64]string) map[string]interface{} {
+	preview := map[string]interface{}{}
+	for k, v := range payload {
+		switch k {
+		case "password", "sshpubkey":
+			preview[k] = map[string]interface{}{"changed": true}
+		case "groups":
+			names := []string{}
+			for _, id := range v.([]int) {
+				names = append(names, groupNames[float64(id)])
+			}
+			sort.Strings(names)
+			preview["groups"] = names
+		default:
+			preview[k] = v
+		}
+	}
+	return preview
+}
+
+func handleCreateUser(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := userCreate(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("user.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+
+	var id float64
+	if err := json.Unmarshal(result, &id); err != nil {
+		var created map[string]interface{}
+		if err := json.Unmarshal(result, &created); err != nil {
+			return "", fmt.Errorf("failed to parse result: %w", err)
+		}
+		id, _ = created["id"].(float64)
+	}
+
+	user, err := getUser(client, create["username"].(string))
+	if err != nil {
+		return "", err
+	}
+	groupNames, err := groupNamesByID(client)
+	if err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("User %s created.", create["username"])
+	if create["smb"] == true {
+		message += " It can be used for SMB shares (owner, or in share ACLs)."
+	}
+	return marshalJSON(map[string]interface{}{
+		"created": true,
+		"id":      id,
+		"user":    simplifyUser(user, groupNames),
+		"message": message,
+	})
+}
+
+// checkUserEditable refuses changes to built-in system accounts
+func checkUserEditable(user map[string]interface{}) error {
+	if builtin, _ := user["builtin"].(bool); builtin {
+		return newToolError(ErrorPrecondition, "user %v is a built-in system account and cannot be changed here", user["username"])
+	}
+	return nil
+}
+
+// userUpdate returns the user and the user.update payload for args
+func userUpdate(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	username, _ := args["username"].(string)
+	if username == "" {
+		return nil, nil, fmt.Errorf("username is required")
+	}
+	user, err := getUser(client, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkUserEditable(user); err != nil {
+		return nil, nil, err
+	}
+
+	update := map[string]interface{}{}
+	if err := userFields(client, args, update); err != nil {
+		return nil, nil, err
+	}
+	if len(update) == 0 {
+		return nil, nil, newToolError(ErrorValidation, "no changes given")
+	}
+	// SMB access needs an SMB password hash, which only a new password sets
+	if update["smb"] == true && user["smb"] != true && update["password"] == nil {
+		return nil, nil, newToolError(ErrorValidation, "enabling smb requires setting password in the same update")
+	}
+	return user, update, nil
+}
+
+func handleUpdateUser(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	user, update, err := userUpdate(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("user.update", user["id"], update); err != nil {
+		return "", fmt.Errorf("failed to update user: %w", err)
+	}
+
+	updated, err := getUser(client, user["username"].(string))
+	if err != nil {
+		return "", err
+	}
+	groupNames, err := groupNamesByID(client)
+	if err != nil {
+		return "", err
+	}
+
+	changed := mapKeys(boolSet(update))
+	return marshalJSON(map[string]interface{}{
+		"updated": true,
+		"changed": changed,
+		"user":    simplifyUser(updated, groupNames),
+		"message": fmt.Sprintf("User %v updated (%s)", user["username"], strings.Join(changed, ", ")),
+	})
+}
+
+// boolSet returns the keys of m as a set
+func boolSet(m map[string]interface{}) map[string]bool {
+	set := map[string]bool{}
+	for k := range m {
+		set[k] = true
+	}
+	return set
+}
+
+// userPrimaryGroupShared reports whether anyone else uses the user's primary
+// group, in which case deleting the user keeps it
+func userPrimaryGroupShared(client truenas.Caller, user map[string]interface{}) (bool, error) {
+	group, _ := user["group"].(map[string]interface{})
+	name, _ := group["bsdgrp_group"].(string)
+	if name == "" {
+		return false, nil
+	}
+	if name != user["username"] {
+		return true, nil
+	}
+	users, err := queryAccounts(client, "user.query", []interface{}{})
+	if err != nil {
+		return false, err
+	}
+	for _, other := range users {
+		otherGroup, _ := other["group"].(map[string]interface{})
+		if other["username"] != user["username"] && otherGroup["bsdgrp_group"] == name {
+			return true, nil
+		}
+	}
+	g, err := getGroup(client, name)
+	if err != nil {
+		return false, nil
+	}
+	members, _ := g["users"].([]interface{})
+	for _, id := range members {
+		if id != user["id"] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// userDeletion returns the user to delete and whether its primary group goes
+// with it
+func userDeletion(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, bool, error) {
+	username, _ := args["username"].(string)
+	if username == "" {
+		return nil, false, fmt.Errorf("username is required")
+	}
+	user, err := getUser(client, username)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := checkUserEditable(user); err != nil {
+		return nil, false, err
+	}
+
+	deleteGroup := true
+	if v, ok := args["delete_primary_group"].(bool); ok {
+		deleteGroup = v
+	}
+	if deleteGroup {
+		shared, err := userPrimaryGroupShared(client, user)
+		if err != nil {
+			return nil, false, err
+		}
+		deleteGroup = !shared
+	}
+	return user, deleteGroup, nil
+}
+
+func handleDeleteUser(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	user, deleteGroup, err := userDeletion(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := client.Call("user.delete", user["id"], map[string]interface{}{"delete_group": deleteGroup}); err != nil {
+		return "", fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	message := fmt.Sprintf("User %v deleted.", user["username"])
+	if deleteGroup {
+		message += " Its primary group was deleted too."
+	}
+	return marshalJSON(map[string]interface{}{
+		"deleted":       true,
+		"username":      user["username"],
+		"group_deleted": deleteGroup,
+		"message":       message + fmt.Sprintf(" Files it owned keep uid %v and show as unowned.", user["uid"]),
+	})
+}
+
+// groupCreate builds the group.create payload from args
+func groupCreate(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := args["name"].(string)
+	if err := validateAccountName("group", name); err != nil {
+		return nil, err
+	}
+	create := map[string]interface{}{
+		"name": name,
+		"smb":  true,
+	}
+	if smb, ok := args["smb"].(bool); ok {
+		create["smb"] = smb
+	}
+	if gid, ok := args["gid"].(float64); ok {
+		create["gid"] = int(gid)
+	}
+	if _, ok := args["members"]; ok {
+		ids := []int{}
+		for _, username := range stringList(args["members"]) {
+			user, err := getUser(client, username)
+			if err != nil {
+				return nil, err
+			}
+			id, _ := user["id"].(float64)
+			ids = append(ids, int(id))
+		}
+		create["users"] = ids
+	}
+	return create, nil
+}
+
+func handleCreateGroup(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	create, err := groupCreate(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("group.create", create)
+	if err != nil {
+		return "", fmt.Errorf("failed to create group: %w", err)
+	}
+
+	var id float64
+	if err := json.Unmarshal(result, &id); err != nil {
+		var created map[string]interface{}
+		if err := json.Unmarshal(result, &created); err != nil {
+			return "", fmt.Errorf("failed to parse result: %w", err)
+		}
+		id, _ = created["id"].(float64)
+	}
+
+	group, err := getGroup(client, create["name"].(string))
+	if err != nil {
+		return "", err
+	}
+	return marshalJSON(map[string]interface{}{
+		"created": true,
+		"id":      id,
+		"gid":     group["gid"],
+		"name":    group["group"],
+		"members": stringList(args["members"]),
+		"message": fmt.Sprintf("Group %s created (gid %v). Add users with update_user groups or use it in share ACLs.", create["name"], group["gid"]),
+	})
+}
+
+// Dry-run wrappers
+
+func handleCreateUserWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createUserDryRun{}, handleCreateUser)
+}
+
+func handleUpdateUserWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &updateUserDryRun{}, handleUpdateUser)
+}
+
+func handleDeleteUserWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteUserDryRun{}, handleDeleteUser)
+}
+
+func handleCreateGroupWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createGroupDryRun{}, handleCreateGroup)
+}
+
+// Dry-run implementations
+
+type createUserDryRun struct{}
+
+func (c *createUserDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := userCreate(client, args)
+	if err != nil {
+		return nil, err
+	}
+	username := create["username"].(string)
+	if _, err := getUser(client, username); err == nil {
+		return nil, newToolError(ErrorValidation, "user %s already exists", username)
+	} else if ClassifyError(err).Code != ErrorNotFound {
+		return nil, err
+	}
+	groupNames, err := groupNamesByID(client)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{}
+	if create["group_create"] == true {
+		actions = append(actions, PlannedAction{
+			Step:        1,
+			Description: fmt.Sprintf("Create primary group %s", username),
+			Operation:   "create",
+			Target:      username,
+		})
+	}
+	actions = append(actions, PlannedAction{
+		Step:        len(actions) + 1,
+		Description: fmt.Sprintf("Create user %s", username),
+		Operation:   "create",
+		Target:      username,
+		Details:     userPayloadPreview(create, groupNames),
+	})
+
+	warnings := []string{}
+	if create["password_disabled"] == true {
+		warnings = append(warnings, "Password login is disabled; the user can only log in with an SSH key")
+	}
+	if create["home_create"] == true {
+		warnings = append(warnings, fmt.Sprintf("Home directory %s is created and owned by the user", create["home"]))
+	}
+
+	return &DryRunResult{
+		Tool:           "create_user",
+		CurrentState:   map[string]interface{}{"user_exists": false},
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
+
+type updateUserDryRun struct{}
+
+func (u *updateUserDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	user, update, err := userUpdate(client, args)
+	if err != nil {
+		return nil, err
+	}
+	groupNames, err := groupNamesByID(client)
+	if err != nil {
+		return nil, err
+	}
+
+	current := simplifyUser(user, groupNames)
+	warnings := []string{}
+	if update["locked"] == true {
+		warnings = append(warnings, fmt.Sprintf("%v cannot log in (SMB, SSH, web UI) while locked", user["username"]))
+	}
+	if update["password_disabled"] == true {
+		warnings = append(warnings, "Password login is disabled; SMB access stops working")
+	}
+	if groups, ok := update["groups"].([]int); ok {
+		removed := []string{}
+		kept := map[string]bool{}
+		for _, id := range groups {
+			kept[groupNames[float64(id)]] = true
+		}
+		for _, name := range current["groups"].([]string) {
+			if !kept[name] {
+				removed = append(removed, name)
+			}
+		}
+		if len(removed) > 0 {
+			warnings = append(warnings, fmt.Sprintf("groups replaces the current list; %v loses membership in: %s", user["username"], strings.Join(removed, ", ")))
+		}
+	}
+
+	return &DryRunResult{
+		Tool:         "update_user",
+		CurrentState: current,
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Update user %v", user["username"]),
+				Operation:   "update",
+				Target:      fmt.Sprintf("%v", user["username"]),
+				Details:     userPayloadPreview(update, groupNames),
+			},
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+type deleteUserDryRun struct{}
+
+func (d *deleteUserDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	user, deleteGroup, err := userDeletion(client, args)
+	if err != nil {
+		return nil, err
+	}
+	groupNames, err := groupNamesByID(client)
+	if err != nil {
+		return nil, err
+	}
+	current := simplifyUser(user, groupNames)
+
+	actions := []PlannedAction{
+		{
+			Step:        1,
+			Description: fmt.Sprintf("Delete user %v", user["username"]),
+			Operation:   "delete",
+			Target:      fmt.Sprintf("%v", user["username"]),
+		},
+	}
+	if deleteGroup {
+		actions = append(actions, PlannedAction{
+			Step:        2,
+			Description: fmt.Sprintf("Delete primary group %v", current["primary_group"]),
+			Operation:   "delete",
+			Target:      fmt.Sprintf("%v", current["primary_group"]),
+		})
+	}
+
+	warnings := []string{
+		fmt.Sprintf("Files owned by %v keep uid %v and show as unowned; shares and ACLs naming the user stop granting access", user["username"], user["uid"]),
+	}
+	if home, _ := user["home"].(string); strings.HasPrefix(home, "/mnt/") {
+		warnings = append(warnings, fmt.Sprintf("The home directory %s is not deleted", home))
+	}
+	if want, ok := args["delete_primary_group"].(bool); (!ok || want) && !deleteGroup {
+		warnings = append(warnings, fmt.Sprintf("Primary group %v is kept because other users use it", current["primary_group"]))
+	}
+
+	return &DryRunResult{
+		Tool:           "delete_user",
+		CurrentState:   current,
+		PlannedActions: actions,
+		Warnings:       warnings,
+	}, nil
+}
+
+type createGroupDryRun struct{}
+
+func (c *createGroupDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	create, err := groupCreate(client, args)
+	if err != nil {
+		return nil, err
+	}
+	name := create["name"].(string)
+	if _, err := getGroup(client, name); err == nil {
+		return nil, newToolError(ErrorValidation, "group %s already exists", name)
+	} else if ClassifyError(err).Code != ErrorNotFound {
+		return nil, err
+	}
+
+	details := map[string]interface{}{}
+	for k, v := range create {
+		details[k] = v
+	}
+	if _, ok := create["users"]; ok {
+		delete(details, "users")
+		details["members"] = stringList(args["members"])
+	}
+
+	return &DryRunResult{
+		Tool:         "create_group",
+		CurrentState: map[string]interface{}{"group_exists": false},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Create group %s", name),
+				Operation:   "create",
+				Target:      name,
+				Details:     details,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationUsersAndGroups(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	users := []map[string]interface{}{
+		{"id": float64(1), "uid": float64(0), "username": "root", "builtin": true, "unixhash": "$6$roothash",
+			"group": map[string]interface{}{"id": float64(1), "bsdgrp_gid": float64(0), "bsdgrp_group": "wheel"}},
+		{"id": float64(30), "uid": float64(3000), "username": "alice", "full_name": "Alice", "builtin": false, "smb": true,
+			"unixhash": "$6$alicehash", "smbhash": "ALICENTHASH", "sshpubkey": "ssh-ed25519 AAAAalicekey",
+			"home": "/mnt/tank/home/alice", "groups": []interface{}{float64(41)},
+			"group": map[string]interface{}{"id": float64(40), "bsdgrp_gid": float64(3000), "bsdgrp_group": "alice"}},
+		{"id": float64(31), "uid": float64(3001), "username": "bob", "full_name": "Bob", "builtin": false, "smb": true,
+			"group": map[string]interface{}{"id": float64(41), "bsdgrp_gid": float64(3001), "bsdgrp_group": "staff"}},
+	}
+	server.SetRecords("user.query", users)
+	server.SetRecords("group.query", []map[string]interface{}{
+		{"id": float64(1), "gid": float64(0), "group": "wheel", "builtin": true, "users": []interface{}{float64(1)}},
+		{"id": float64(40), "gid": float64(3000), "group": "alice", "builtin": false, "users": []interface{}{}},
+		{"id": float64(41), "gid": float64(3001), "group": "staff", "builtin": false, "smb": true, "users": []interface{}{float64(30)}},
+	})
+	server.Handle("user.create", func(params []interface{}) (interface{}, error) {
+		created := params[0].(map[string]interface{})
+		users = append(users, map[string]interface{}{"id": float64(32), "uid": float64(3002), "username": created["username"], "builtin": false,
+			"group": map[string]interface{}{"id": float64(41), "bsdgrp_gid": float64(3001), "bsdgrp_group": "staff"}})
+		server.SetRecords("user.query", users)
+		return float64(32), nil
+	})
+	server.SetResult("user.update", float64(30))
+	server.SetResult("user.delete", float64(30))
+
+	result, err := registry.CallTool("query_users", map[string]interface{}{"group": "staff"})
+	if err != nil {
+		t.Fatalf("query_users failed: %v", err)
+	}
+	for _, secret := range []string{"alicehash", "ALICENTHASH", "AAAAalicekey"} {
+		if strings.Contains(result, secret) {
+			t.Errorf("query_users leaked %q: %s", secret, result)
+		}
+	}
+	listed := decodeResult(t, result)["users"].([]interface{})
+	if len(listed) != 2 {
+		t.Fatalf("users in staff = %v, want alice (auxiliary) and bob (primary)", listed)
+	}
+	alice := listed[0].(map[string]interface{})
+	if alice["username"] != "alice" || alice["ssh_key_set"] != true || fmt.Sprint(alice["groups"]) != "[staff]" {
+		t.Errorf("alice = %v", alice)
+	}
+
+	result, err = registry.CallTool("query_groups", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("query_groups failed: %v", err)
+	}
+	groups := decodeResult(t, result)["groups"].([]interface{})
+	if len(groups) != 2 || fmt.Sprint(groups[1].(map[string]interface{})["members"]) != "[alice]" {
+		t.Errorf("groups = %v", groups)
+	}
+
+	create := map[string]interface{}{
+		"username": "carol", "full_name": "Carol", "password": "hunter2-secret",
+		"group": "staff", "groups": []interface{}{"staff"}, "dry_run": true,
+	}
+	result, err = registry.CallTool("create_user", create)
+	if err != nil {
+		t.Fatalf("create_user dry run failed: %v", err)
+	}
+	if strings.Contains(result, "hunter2-secret") || len(server.Calls("user.create")) != 0 {
+		t.Errorf("create_user dry run = %s", result)
+	}
+	delete(create, "dry_run")
+	result, err = registry.CallTool("create_user", create)
+	if err != nil {
+		t.Fatalf("create_user failed: %v", err)
+	}
+	if strings.Contains(result, "hunter2-secret") {
+		t.Errorf("create_user leaked the password: %s", result)
+	}
+	payload := server.Calls("user.create")[0].Params[0].(map[string]interface{})
+	if payload["group"] != float64(41) || fmt.Sprint(payload["groups"]) != "[41]" || payload["smb"] != true || payload["group_create"] != nil {
+		t.Errorf("user.create payload = %v", payload)
+	}
+
+	for _, tc := range []struct {
+		tool string
+		args map[string]interface{}
+		code ErrorCode
+	}{
+		{"create_user", map[string]interface{}{"username": "9lives", "full_name": "x", "password": "p"}, ErrorValidation},
+		{"create_user", map[string]interface{}{"username": "dave", "full_name": "Dave"}, ErrorValidation},
+		{"create_user", map[string]interface{}{"username": "dave", "full_name": "Dave", "password": "p", "group": "nope"}, ErrorNotFound},
+		{"create_user", map[string]interface{}{"username": "alice", "full_name": "Alice", "password": "p", "dry_run": true}, ErrorValidation},
+		{"update_user", map[string]interface{}{"username": "root", "locked": true}, ErrorPrecondition},
+		{"update_user", map[string]interface{}{"username": "alice"}, ErrorValidation},
+		{"delete_user", map[string]interface{}{"username": "root"}, ErrorPrecondition},
+		{"delete_user", map[string]interface{}{"username": "nobody-here"}, ErrorNotFound},
+	} {
+		if _, err := registry.CallTool(tc.tool, tc.args); ClassifyError(err).Code != tc.code {
+			t.Errorf("%s(%v) error = %v, want %s", tc.tool, tc.args, err, tc.code)
+		}
+	}
+
+	// Replacing groups drops alice from staff
+	result, err = registry.CallTool("update_user", map[string]interface{}{
+		"username": "alice", "groups": []interface{}{}, "ssh_public_key": "ssh-ed25519 AAAAnewkey", "dry_run": true,
+	})
+	if err != nil {
+		t.Fatalf("update_user dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "loses membership in: staff") || strings.Contains(result, "AAAAnewkey") {
+		t.Errorf("update_user dry run = %s", result)
+	}
+	if _, err := registry.CallTool("update_user", map[string]interface{}{"username": "alice", "locked": true}); err != nil {
+		t.Fatalf("update_user failed: %v", err)
+	}
+	call := server.Calls("user.update")[0]
+	if call.Params[0] != float64(30) || fmt.Sprint(call.Params[1]) != "map[locked:true]" {
+		t.Errorf("user.update params = %v", call.Params)
+	}
+
+	// bob's primary group staff is shared, so it is kept
+	result, err = registry.CallTool("delete_user", map[string]interface{}{"username": "bob", "dry_run": true})
+	if err != nil {
+		t.Fatalf("delete_user dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Primary group staff is kept") {
+		t.Errorf("delete_user dry run = %s", result)
+	}
+	if _, err := registry.CallTool("delete_user", map[string]interface{}{"username": "alice"}); err != nil {
+		t.Fatalf("delete_user failed: %v", err)
+	}
+	call = server.Calls("user.delete")[0]
+	if call.Params[0] != float64(30) || fmt.Sprint(call.Params[1]) != "map[delete_group:true]" {
+		t.Errorf("user.delete params = %v", call.Params)
+	}
+
+	server.Handle("group.create", func(params []interface{}) (interface{}, error) {
+		server.SetRecords("group.query", []map[string]interface{}{{"id": float64(42), "gid": float64(3002), "group": "editors"}})
+		return float64(42), nil
+	})
+	if _, err := registry.CallTool("create_group", map[string]interface{}{"name": "staff", "dry_run": true}); ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("create_group of existing group error = %v, want VALIDATION", err)
+	}
+	result, err = registry.CallTool("create_group", map[string]interface{}{"name": "editors", "members": []interface{}{"alice", "bob"}})
+	if err != nil {
+		t.Fatalf("create_group failed: %v", err)
+	}
+	if decodeResult(t, result)["gid"] != float64(3002) {
+		t.Errorf("create_group = %s", result)
+	}
+	groupPayload := server.Calls("group.create")[0].Params[0].(map[string]interface{})
+	if groupPayload["name"] != "editors" || fmt.Sprint(groupPayload["users"]) != "[30 31]" || groupPayload["smb"] != true {
+		t.Errorf("group.create payload = %v", groupPayload)
+	}
+}
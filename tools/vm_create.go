@@ -0,0 +1,482 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// VM creation wizard
+
+// minVMMemory is the least memory a VM is created with
+const minVMMemory = 256 * units.MiB
+
+// vmCreate is a validated create_vm request
+type vmCreate struct {
+	name     string
+	payload  map[string]interface{}
+	devices  []map[string]interface{} // Device summaries without secrets
+	newZvols []map[string]interface{}
+	warnings []string
+}
+
+// vmIntArg reads a positive integer argument, returning def when absent
+func vmIntArg(args map[string]interface{}, key string, def int) (int, error) {
+	raw, ok := args[key]
+	if !ok {
+		return def, nil
+	}
+	v, ok := raw.(float64)
+	if !ok || v < 1 || v != float64(int(v)) {
+		return 0, newToolError(ErrorValidation, "%s must be a positive whole number (got: %v)", key, raw)
+	}
+	return int(v), nil
+}
+
+// vmEnumArg reads an optional upper-cased enum argument
+func vmEnumArg(args map[string]interface{}, key string, allowed []string) (string, error) {
+	value, _ := args[key].(string)
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return "", nil
+	}
+	if !containsString(allowed, value) {
+		return "", newToolError(ErrorValidation, "%s must be one of %s (got: %s)", key, strings.Join(allowed, ", "), value)
+	}
+	return value, nil
+}
+
+// vmMemoryArg reads memory as a size string or a number of MiB
+func vmMemoryArg(args map[string]interface{}) (int64, error) {
+	var bytes int64
+	switch v := args["memory"].(type) {
+	case nil:
+		return 0, fmt.Errorf("memory is required")
+	case float64:
+		bytes = int64(v) * units.MiB
+	case string:
+		parsed, err := units.ParseBytes(v)
+		if err != nil {
+			return 0, newToolError(ErrorValidation, "memory: %v", err)
+		}
+		bytes = parsed
+	default:
+		return 0, newToolError(ErrorValidation, "memory must be a size like \"4GiB\" or a number of MiB")
+	}
+	if bytes < minVMMemory {
+		return 0, newToolError(ErrorValidation, "memory must be at least %s (got: %s)", units.FormatBytes(minVMMemory), units.FormatBytes(bytes))
+	}
+	return bytes / units.MiB, nil
+}
+
+// getZvol returns the dataset with the given name, or nil if it does not exist
+func getZvol(client truenas.Caller, name string) (map[string]interface{}, error) {
+	datasets, err := inventoryQuery(client, "pool.dataset.query", []interface{}{[]interface{}{"id", "=", name}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dataset %s: %w", name, err)
+	}
+	if len(datasets) == 0 {
+		return nil, nil
+	}
+	return datasets[0], nil
+}
+
+// vmZvolUsers maps each zvol used as a VM disk to the VM using it
+func vmZvolUsers(client truenas.Caller) (map[string]string, error) {
+	vms, err := inventoryQuery(client, "vm.query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query VMs: %w", err)
+	}
+	users := map[string]string{}
+	for _, vm := range vms {
+		for _, zvol := range vmDiskZvols(vm) {
+			users[zvol] = fmt.Sprint(vm["name"])
+		}
+	}
+	return users, nil
+}
+
+// planVMDisks validates the disks argument and returns their device attributes
+func planVMDisks(client truenas.Caller, plan *vmCreate, args map[string]interface{}) ([]map[string]interface{}, error) {
+	rawDisks, _ := args["disks"].([]interface{})
+	if len(rawDisks) == 0 {
+		plan.warnings = append(plan.warnings, "The VM has no disks; add one unless it boots from the network or a live ISO")
+		return nil, nil
+	}
+	users, err := vmZvolUsers(client)
+	if err != nil {
+		return nil, err
+	}
+
+	disks := []map[string]interface{}{}
+	seen := map[string]bool{}
+	for i, raw := range rawDisks {
+		disk, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, newToolError(ErrorValidation, "disks[%d] must be an object with zvol and optional size and bus", i)
+		}
+		zvol, _ := disk["zvol"].(string)
+		zvol = strings.Trim(strings.TrimSpace(zvol), "/")
+		if zvol == "" {
+			return nil, newToolError(ErrorValidation, "disks[%d].zvol is required (e.g., 'tank/vms/%s-disk0')", i, plan.name)
+		}
+		if seen[zvol] {
+			return nil, newToolError(ErrorValidation, "zvol %s is listed twice", zvol)
+		}
+		seen[zvol] = true
+		bus, err := vmEnumArg(disk, "bus", []string{"VIRTIO", "AHCI"})
+		if err != nil {
+			return nil, newToolError(ErrorValidation, "disks[%d]: %v", i, err)
+		}
+		if bus == "" {
+			bus = "VIRTIO"
+		}
+		existing, err := getZvol(client, zvol)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs := map[string]interface{}{"dtype": "DISK", "type": bus}
+		rawSize, create := disk["size"]
+		if !create {
+			if existing == nil {
+				return nil, newToolError(ErrorNotFound, "zvol %s does not exist; pass size to create it", zvol)
+			}
+			if existing["type"] != "VOLUME" {
+				return nil, newToolError(ErrorValidation, "%s is a filesystem dataset, not a zvol", zvol)
+			}
+			if vm, used := users[zvol]; used {
+				return nil, newToolError(ErrorValidation, "zvol %s is already a disk of VM %s", zvol, vm)
+			}
+			attrs["path"] = "/dev/zvol/" + zvol
+			disks = append(disks, attrs)
+			continue
+		}
+
+		if existing != nil {
+			return nil, newToolError(ErrorValidation, "zvol %s already exists; omit size to attach it as is", zvol)
+		}
+		if err := validateDatasetName(zvol); err != nil {
+			return nil, newToolError(ErrorValidation, "disks[%d].zvol: %v", i, err)
+		}
+		size, err := units.ParseSizeValue(rawSize)
+		if err != nil || size <= 0 {
+			return nil, newToolError(ErrorValidation, "disks[%d].size must be a size like \"50GiB\"", i)
+		}
+		parentName := zvol[:strings.LastIndex(zvol, "/")]
+		parent, err := getZvol(client, parentName)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, newToolError(ErrorNotFound, "parent dataset %s of zvol %s does not exist; create it with create_dataset first", parentName, zvol)
+		}
+		if available := datasetParsedBytes(parent, "available"); available > 0 && size > available {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("zvol %s (%s) is larger than the %s available in %s; creation fails unless space is freed",
+				zvol, units.FormatBytes(size), units.FormatBytes(available), parentName))
+		}
+		attrs["create_zvol"] = true
+		attrs["zvol_name"] = zvol
+		attrs["zvol_volsize"] = size
+		plan.newZvols = append(plan.newZvols, map[string]interface{}{"name": zvol, "size": units.NewSize(size)})
+		disks = append(disks, attrs)
+	}
+	return disks, nil
+}
+
+// planVMNICs validates the nics argument against the NAS's attach choices
+func planVMNICs(client truenas.Caller, plan *vmCreate, args map[string]interface{}) ([]map[string]interface{}, error) {
+	rawNICs, _ := args["nics"].([]interface{})
+	if len(rawNICs) == 0 {
+		plan.warnings = append(plan.warnings, "The VM has no network interface and will be reachable only through its display")
+		return nil, nil
+	}
+
+	// Choices are interface names mapped to labels; skip the check if unavailable
+	var choices map[string]interface{}
+	if result, err := client.Call("vm.device.nic_attach_choices"); err == nil {
+		json.Unmarshal(result, &choices)
+	}
+
+	nics := []map[string]interface{}{}
+	for i, raw := range rawNICs {
+		nic, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, newToolError(ErrorValidation, "nics[%d] must be an object with attach and optional type and mac", i)
+		}
+		attach, _ := nic["attach"].(string)
+		attach = strings.TrimSpace(attach)
+		if attach == "" {
+			return nil, newToolError(ErrorValidation, "nics[%d].attach is required (a NAS interface or bridge such as br0)", i)
+		}
+		if len(choices) > 0 {
+			if _, ok := choices[attach]; !ok {
+				names := make([]string, 0, len(choices))
+				for name := range choices {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return nil, newToolError(ErrorValidation, "nics[%d].attach %s is not an interface VMs can use; choices: %s", i, attach, strings.Join(names, ", "))
+			}
+		}
+		nicType, err := vmEnumArg(nic, "type", []string{"VIRTIO", "E1000"})
+		if err != nil {
+			return nil, newToolError(ErrorValidation, "nics[%d]: %v", i, err)
+		}
+		if nicType == "" {
+			nicType = "VIRTIO"
+		}
+		attrs := map[string]interface{}{"dtype": "NIC", "type": nicType, "nic_attach": attach}
+		if mac, _ := nic["mac"].(string); mac != "" {
+			attrs["mac"] = mac
+		}
+		if !strings.HasPrefix(attach, "br") {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("NIC attached directly to %s: the VM cannot reach the NAS itself over it; attach to a bridge for NAS access", attach))
+		}
+		nics = append(nics, attrs)
+	}
+	return nics, nil
+}
+
+// planVMDisplay validates the display argument
+func planVMDisplay(plan *vmCreate, args map[string]interface{}) (map[string]interface{}, error) {
+	display, ok := args["display"].(map[string]interface{})
+	if !ok {
+		plan.warnings = append(plan.warnings, "The VM has no display; without one the installer and console cannot be reached")
+		return nil, nil
+	}
+	password, _ := display["password"].(string)
+	if password == "" {
+		return nil, newToolError(ErrorValidation, "display.password is required to protect the console")
+	}
+	bind, _ := display["bind"].(string)
+	if bind == "" {
+		bind = "0.0.0.0"
+	}
+	web := true
+	if v, ok := display["web"].(bool); ok {
+		web = v
+	}
+	if bind == "0.0.0.0" {
+		plan.warnings = append(plan.warnings, "The display listens on every NAS address; anyone on the network with the password can use the console")
+	}
+	return map[string]interface{}{
+		"dtype":    "DISPLAY",
+		"type":     "SPICE",
+		"bind":     bind,
+		"web":      web,
+		"password": password,
+	}, nil
+}
+
+func planVMCreate(client truenas.Caller, args map[string]interface{}) (*vmCreate, error) {
+	name, _ := args["name"].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if !vmNamePattern.MatchString(name) {
+		return nil, newToolError(ErrorValidation, "name may only contain letters, digits, and underscores (got: %q)", name)
+	}
+	if _, err := getVM(client, map[string]interface{}{"vm": name}); err == nil {
+		return nil, newToolError(ErrorValidation, "a VM named %s already exists", name)
+	} else if ClassifyError(err).Code != ErrorNotFound {
+		return nil, err
+	}
+	plan := &vmCreate{name: name}
+
+	// CPU and memory
+	vcpus, err := vmIntArg(args, "vcpus", 1)
+	if err != nil {
+		return nil, err
+	}
+	cores, err := vmIntArg(args, "cores", 1)
+	if err != nil {
+		return nil, err
+	}
+	threads, err := vmIntArg(args, "threads", 1)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := vmMemoryArg(args)
+	if err != nil {
+		return nil, err
+	}
+	plan.payload = map[string]interface{}{
+		"name":    name,
+		"vcpus":   vcpus,
+		"cores":   cores,
+		"threads": threads,
+		"memory":  memory,
+	}
+	if desc, _ := args["description"].(string); desc != "" {
+		plan.payload["description"] = desc
+	}
+	for key, allowed := range map[string][]string{
+		"cpu_mode":   {"CUSTOM", "HOST-MODEL", "HOST-PASSTHROUGH"},
+		"bootloader": {"UEFI", "UEFI_CSM"},
+		"time":       {"LOCAL", "UTC"},
+	} {
+		value, err := vmEnumArg(args, key, allowed)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			plan.payload[key] = value
+		}
+	}
+	if autostart, ok := args["autostart"].(bool); ok {
+		plan.payload["autostart"] = autostart
+	}
+
+	if result, err := client.Call("system.info"); err == nil {
+		var info map[string]interface{}
+		if json.Unmarshal(result, &info) == nil {
+			if hostCores, ok := info["cores"].(float64); ok && vcpus*cores*threads > int(hostCores) {
+				plan.warnings = append(plan.warnings, fmt.Sprintf("The VM gets %d virtual CPUs but the NAS has %d; overcommitted CPUs slow the VM and the NAS",
+					vcpus*cores*threads, int(hostCores)))
+			}
+		}
+	}
+	if warning := vmMemoryWarning(client, map[string]interface{}{"memory": float64(memory)}, false); warning != "" {
+		plan.warnings = append(plan.warnings, strings.Replace(warning, "the start will fail", "starting it will fail", 1))
+	}
+
+	// Devices, in boot order: installation media first, then disks
+	devices := []map[string]interface{}{}
+	if isoPath, _ := args["iso_path"].(string); isoPath != "" {
+		if !strings.HasPrefix(isoPath, "/mnt/") {
+			return nil, newToolError(ErrorValidation, "iso_path must be a file under /mnt (got: %s)", isoPath)
+		}
+		if _, err := client.Call("filesystem.stat", isoPath); err != nil {
+			return nil, newToolError(ErrorNotFound, "iso_path %s not found: %v", isoPath, err)
+		}
+		devices = append(devices, map[string]interface{}{"dtype": "CDROM", "path": isoPath})
+	}
+	disks, err := planVMDisks(client, plan, args)
+	if err != nil {
+		return nil, err
+	}
+	devices = append(devices, disks...)
+	if len(plan.newZvols) > 0 && args["iso_path"] == nil {
+		plan.warnings = append(plan.warnings, "New disks are empty and there is no iso_path; the VM has nothing to boot or install from")
+	}
+	nics, err := planVMNICs(client, plan, args)
+	if err != nil {
+		return nil, err
+	}
+	devices = append(devices, nics...)
+	display, err := planVMDisplay(plan, args)
+	if err != nil {
+		return nil, err
+	}
+	if display != nil {
+		devices = append(devices, display)
+	}
+
+	payloadDevices := make([]map[string]interface{}, 0, len(devices))
+	for i, attrs := range devices {
+		payloadDevices = append(payloadDevices, map[string]interface{}{
+			"attributes": attrs,
+			"order":      1000 + i,
+		})
+		summary := map[string]interface{}{"boot_order": 1000 + i}
+		for k, v := range attrs {
+			if k == "password" {
+				v = "********"
+			}
+			summary[k] = v
+		}
+		if size, ok := attrs["zvol_volsize"].(int64); ok {
+			summary["zvol_volsize"] = units.NewSize(size)
+		}
+		plan.devices = append(plan.devices, summary)
+	}
+	plan.payload["devices"] = payloadDevices
+	return plan, nil
+}
+
+func handleCreateVM(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planVMCreate(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.Call("vm.create", plan.payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to create VM %s: %w", plan.name, err)
+	}
+	var vm map[string]interface{}
+	if err := json.Unmarshal(result, &vm); err != nil {
+		return "", fmt.Errorf("failed to parse VM response: %w", err)
+	}
+
+	response := map[string]interface{}{
+		"created": true,
+		"vm":      simplifyVM(vm),
+		"message": fmt.Sprintf("VM %s created (stopped). Start it with start_vm, then open the display to install the guest OS.", plan.name),
+	}
+	if len(plan.newZvols) > 0 {
+		response["zvols_created"] = plan.newZvols
+	}
+	if len(plan.warnings) > 0 {
+		response["warnings"] = plan.warnings
+	}
+	return marshalJSON(response)
+}
+
+func handleCreateVMWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &createVMDryRun{}, handleCreateVM)
+}
+
+type createVMDryRun struct{}
+
+func (d *createVMDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planVMCreate(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []PlannedAction{}
+	for _, zvol := range plan.newZvols {
+		actions = append(actions, PlannedAction{
+			Step:        len(actions) + 1,
+			Description: fmt.Sprintf("Create zvol %s (%v) for a VM disk", zvol["name"], zvol["size"]),
+			Operation:   "create",
+			Target:      fmt.Sprint(zvol["name"]),
+		})
+	}
+	memory, _ := plan.payload["memory"].(int64)
+	actions = append(actions, PlannedAction{
+		Step:        len(actions) + 1,
+		Description: fmt.Sprintf("Create VM %s with %d devices, stopped", plan.name, len(plan.devices)),
+		Operation:   "create",
+		Target:      "vm.create",
+		Details: map[string]interface{}{
+			"vcpus":       plan.payload["vcpus"],
+			"cores":       plan.payload["cores"],
+			"threads":     plan.payload["threads"],
+			"memory":      units.NewSize(memory * units.MiB),
+			"cpu_mode":    plan.payload["cpu_mode"],
+			"bootloader":  plan.payload["bootloader"],
+			"autostart":   plan.payload["autostart"],
+			"devices":     plan.devices,
+			"description": plan.payload["description"],
+		},
+	})
+
+	return &DryRunResult{
+		Tool: "create_vm",
+		CurrentState: map[string]interface{}{
+			"vm_exists": false,
+		},
+		PlannedActions: actions,
+		Warnings:       plan.warnings,
+		EstimatedTime:  &EstimatedTime{MinSeconds: 2, MaxSeconds: 30, Note: "The zvols are created by the middleware as part of vm.create"},
+	}, nil
+}
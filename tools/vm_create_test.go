@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrationCreateVM(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("vm.query", []map[string]interface{}{
+		{"id": float64(1), "name": "web", "status": map[string]interface{}{"state": "RUNNING"},
+			"devices": []interface{}{
+				map[string]interface{}{"attributes": map[string]interface{}{"dtype": "DISK", "path": "/dev/zvol/tank/vms/web-disk0"}},
+			}},
+	})
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"id": "tank/vms", "type": "FILESYSTEM", "available": map[string]interface{}{"parsed": float64(10 << 30)}},
+		{"id": "tank/vms/web-disk0", "type": "VOLUME"},
+		{"id": "tank/vms/spare", "type": "VOLUME"},
+	})
+	server.SetResult("system.info", map[string]interface{}{"cores": float64(4)})
+	server.SetResult("vm.get_available_memory", float64(8<<30))
+	server.SetResult("vm.device.nic_attach_choices", map[string]interface{}{"br0": "br0", "enp1s0": "enp1s0"})
+	server.SetResult("filesystem.stat", map[string]interface{}{"type": "FILE"})
+
+	args := map[string]interface{}{
+		"name":     "db",
+		"memory":   "4GiB",
+		"cores":    float64(8),
+		"disks":    []interface{}{map[string]interface{}{"zvol": "tank/vms/db-disk0", "size": "20GiB"}},
+		"iso_path": "/mnt/tank/iso/debian.iso",
+		"nics":     []interface{}{map[string]interface{}{"attach": "br0"}},
+		"display":  map[string]interface{}{"password": "hunter2"},
+		"dry_run":  true,
+	}
+	result, err := registry.CallTool("create_vm", args)
+	if err != nil {
+		t.Fatalf("create_vm dry run failed: %v", err)
+	}
+	for _, want := range []string{"Create zvol tank/vms/db-disk0", "8 virtual CPUs", "larger than the 10.00 GiB available", "CDROM"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("dry run missing %q:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "hunter2") {
+		t.Errorf("dry run shows the display password:\n%s", result)
+	}
+	if n := len(server.Calls("vm.create")); n != 0 {
+		t.Errorf("dry run called vm.create %d times", n)
+	}
+
+	for name, bad := range map[string]map[string]interface{}{
+		"zvol in use":       {"disks": []interface{}{map[string]interface{}{"zvol": "tank/vms/web-disk0"}}},
+		"unknown interface": {"nics": []interface{}{map[string]interface{}{"attach": "eth9"}}},
+		"display password":  {"display": map[string]interface{}{"bind": "10.0.0.5"}},
+		"existing name":     {"name": "web"},
+		"too little memory": {"memory": "128MiB"},
+	} {
+		badArgs := map[string]interface{}{"name": "db", "memory": "2GiB"}
+		for k, v := range bad {
+			badArgs[k] = v
+		}
+		if _, err := registry.CallTool("create_vm", badArgs); err == nil || ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("%s: error = %v, want VALIDATION", name, err)
+		}
+	}
+
+	server.Handle("vm.create", func(params []interface{}) (interface{}, error) {
+		payload := params[0].(map[string]interface{})
+		return map[string]interface{}{"id": float64(2), "name": payload["name"], "memory": payload["memory"],
+			"status": map[string]interface{}{"state": "STOPPED"}}, nil
+	})
+	result, err = registry.CallTool("create_vm", map[string]interface{}{
+		"name":   "db",
+		"memory": float64(2048),
+		"disks": []interface{}{
+			map[string]interface{}{"zvol": "tank/vms/spare", "bus": "ahci"},
+			map[string]interface{}{"zvol": "tank/vms/db-disk1", "size": "5GiB"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create_vm failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["created"] != true || response["zvols_created"] == nil {
+		t.Errorf("create_vm result:\n%s", result)
+	}
+	calls := server.Calls("vm.create")
+	if len(calls) != 1 {
+		t.Fatalf("vm.create called %d times, want 1", len(calls))
+	}
+	payload := calls[0].Params[0].(map[string]interface{})
+	devices := payload["devices"].([]interface{})
+	if payload["memory"] != float64(2048) || len(devices) != 2 {
+		t.Fatalf("unexpected vm.create payload: %v", payload)
+	}
+	existing := devices[0].(map[string]interface{})["attributes"].(map[string]interface{})
+	if existing["path"] != "/dev/zvol/tank/vms/spare" || existing["type"] != "AHCI" {
+		t.Errorf("existing zvol device = %v", existing)
+	}
+	created := devices[1].(map[string]interface{})["attributes"].(map[string]interface{})
+	if created["create_zvol"] != true || created["zvol_name"] != "tank/vms/db-disk1" || created["zvol_volsize"] != float64(5<<30) {
+		t.Errorf("new zvol device = %v", created)
+	}
+}
@@ -0,0 +1,490 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+	"github.com/truenas/truenas-mcp/units"
+)
+
+// Virtual machine lifecycle
+
+// vmNamePattern matches the VM names the middleware accepts
+var vmNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// vmJobTimeout bounds how long VM jobs are tracked; a graceful stop waits for
+// the guest's shutdown timeout before giving up
+const vmJobTimeout = 10 * time.Minute
+
+// getVM returns the VM with the given name or numeric ID
+func getVM(client truenas.Caller, args map[string]interface{}) (map[string]interface{}, error) {
+	var ref string
+	switch v := args["vm"].(type) {
+	case string:
+		ref = strings.TrimSpace(v)
+	case float64:
+		ref = strconv.Itoa(int(v))
+	}
+	if ref == "" {
+		return nil, fmt.Errorf("vm is required")
+	}
+
+	vms, err := inventoryQuery(client, "vm.query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query VMs: %w", err)
+	}
+	id, idErr := strconv.Atoi(ref)
+	for _, vm := range vms {
+		if vm["name"] == ref {
+			return vm, nil
+		}
+		if vmID, ok := vm["id"].(float64); ok && idErr == nil && int(vmID) == id {
+			return vm, nil
+		}
+	}
+	names := inventoryNames(vms, "name")
+	if len(names) == 0 {
+		return nil, newToolError(ErrorNotFound, "VM '%s' not found; there are no VMs", ref)
+	}
+	return nil, newToolError(ErrorNotFound, "VM '%s' not found; VMs: %s", ref, strings.Join(names, ", "))
+}
+
+// vmState returns the VM's status state, e.g. RUNNING or STOPPED
+func vmState(vm map[string]interface{}) string {
+	status, _ := vm["status"].(map[string]interface{})
+	state, _ := status["state"].(string)
+	return state
+}
+
+// vmDiskZvols lists the zvols backing the VM's DISK devices
+func vmDiskZvols(vm map[string]interface{}) []string {
+	zvols := []string{}
+	devices, _ := vm["devices"].([]interface{})
+	for _, raw := range devices {
+		device, _ := raw.(map[string]interface{})
+		attrs, _ := device["attributes"].(map[string]interface{})
+		if attrs["dtype"] != "DISK" {
+			continue
+		}
+		path, _ := attrs["path"].(string)
+		if zvol := zvolDatasetName(path); zvol != "" {
+			zvols = append(zvols, zvol)
+		}
+	}
+	return zvols
+}
+
+// vmJobID reads the job ID of VM methods that run as jobs; others return a
+// boolean or null
+func vmJobID(result json.RawMessage) (int, bool) {
+	if string(result) == "null" {
+		return 0, false
+	}
+	jobID, err := parseJobID(result)
+	return jobID, err == nil
+}
+
+// trackVMJob records a VM job as a task and adds its tracking fields to response
+func (r *Registry) trackVMJob(client truenas.Caller, tool string, args map[string]interface{}, jobID int, verb string, response map[string]interface{}) (string, error) {
+	task, err := r.taskManager.CreateJobTask(tool, args, jobID, vmJobTimeout, client.CorrelationID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create task: %w", err)
+	}
+	response["job_id"] = jobID
+	response["task_id"] = task.TaskID
+	response["task_status"] = task.Status
+	response["poll_interval"] = task.PollInterval
+	response["message"] = fmt.Sprintf("VM %s %s. Track progress with tasks_get using task_id: %s", response["name"], verb, task.TaskID)
+	return marshalJSON(response)
+}
+
+// vmActionResponse finishes a VM action. Middleware releases that run the
+// action as a job are tracked as a task; otherwise the VM is queried again
+// and its new state reported.
+func (r *Registry) vmActionResponse(client truenas.Caller, tool string, args map[string]interface{}, vm map[string]interface{}, result json.RawMessage, verb, wantState string) (string, error) {
+	response := map[string]interface{}{
+		"id":             vm["id"],
+		"name":           vm["name"],
+		"previous_state": vmState(vm),
+	}
+	if jobID, ok := vmJobID(result); ok {
+		return r.trackVMJob(client, tool, args, jobID, verb+" initiated", response)
+	}
+
+	updated, err := getVM(client, map[string]interface{}{"vm": vm["name"]})
+	if err != nil {
+		return "", err
+	}
+	response["state"] = vmState(updated)
+	if vmState(updated) != wantState {
+		response["succeeded"] = false
+		response["message"] = fmt.Sprintf("VM %s is %s after %s; check list_alerts and the VM's devices", vm["name"], vmState(updated), verb)
+		return marshalJSON(response)
+	}
+	response["succeeded"] = true
+	response["message"] = fmt.Sprintf("VM %s is %s", vm["name"], strings.ToLower(wantState))
+	return marshalJSON(response)
+}
+
+// vmMemoryWarning checks the VM's memory against what the NAS can give it
+func vmMemoryWarning(client truenas.Caller, vm map[string]interface{}, overcommit bool) string {
+	memory, ok := vm["memory"].(float64)
+	if !ok {
+		return ""
+	}
+	result, err := client.Call("vm.get_available_memory", overcommit)
+	if err != nil {
+		return ""
+	}
+	var available int64
+	if err := json.Unmarshal(result, &available); err != nil {
+		return ""
+	}
+	needed := int64(memory) * units.MiB
+	if needed <= available {
+		return ""
+	}
+	warning := fmt.Sprintf("VM needs %s of memory but only %s is available; the start will fail",
+		units.FormatBytes(needed), units.FormatBytes(available))
+	if !overcommit {
+		warning += " unless overcommit=true (risks swapping or the OOM killer)"
+	}
+	return warning
+}
+
+func (r *Registry) handleStartVM(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	vm, err := getVM(client, args)
+	if err != nil {
+		return "", err
+	}
+	if vmState(vm) == "RUNNING" {
+		return "", newToolError(ErrorPrecondition, "VM %s is already running", vm["name"])
+	}
+	overcommit, _ := args["overcommit"].(bool)
+
+	result, err := client.Call("vm.start", vm["id"], map[string]interface{}{"overcommit": overcommit})
+	if err != nil {
+		return "", fmt.Errorf("failed to start VM %s: %w", vm["name"], err)
+	}
+	return r.vmActionResponse(client, "start_vm", args, vm, result, "start", "RUNNING")
+}
+
+func (r *Registry) handleStopVM(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	vm, err := getVM(client, args)
+	if err != nil {
+		return "", err
+	}
+	if vmState(vm) != "RUNNING" {
+		return "", newToolError(ErrorPrecondition, "VM %s is not running (state %s)", vm["name"], vmState(vm))
+	}
+	force, _ := args["force"].(bool)
+	forceAfterTimeout, _ := args["force_after_timeout"].(bool)
+
+	result, err := client.Call("vm.stop", vm["id"], map[string]interface{}{
+		"force":               force,
+		"force_after_timeout": forceAfterTimeout,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to stop VM %s: %w", vm["name"], err)
+	}
+	return r.vmActionResponse(client, "stop_vm", args, vm, result, "stop", "STOPPED")
+}
+
+func (r *Registry) handleRestartVM(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	vm, err := getVM(client, args)
+	if err != nil {
+		return "", err
+	}
+	if vmState(vm) != "RUNNING" {
+		return "", newToolError(ErrorPrecondition, "VM %s is not running (state %s); use start_vm", vm["name"], vmState(vm))
+	}
+
+	result, err := client.Call("vm.restart", vm["id"])
+	if err != nil {
+		return "", fmt.Errorf("failed to restart VM %s: %w", vm["name"], err)
+	}
+	return r.vmActionResponse(client, "restart_vm", args, vm, result, "restart", "RUNNING")
+}
+
+// vmClone is a validated clone_vm request
+type vmClone struct {
+	vm       map[string]interface{}
+	name     string
+	warnings []string
+}
+
+func planVMClone(client truenas.Caller, args map[string]interface{}) (*vmClone, error) {
+	vm, err := getVM(client, args)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := args["name"].(string)
+	name = strings.TrimSpace(name)
+	if name != "" {
+		if !vmNamePattern.MatchString(name) {
+			return nil, newToolError(ErrorValidation, "name may only contain letters, digits, and underscores (got: %q)", name)
+		}
+		if _, err := getVM(client, map[string]interface{}{"vm": name}); err == nil {
+			return nil, newToolError(ErrorValidation, "a VM named %s already exists", name)
+		}
+	}
+
+	plan := &vmClone{vm: vm, name: name}
+	if vmState(vm) == "RUNNING" {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("VM %s is running; its disks are cloned from a snapshot taken now, which is only crash-consistent. Stop it first for a clean copy.", vm["name"]))
+	}
+	if zvols := vmDiskZvols(vm); len(zvols) > 0 {
+		plan.warnings = append(plan.warnings, fmt.Sprintf("Disk zvols are snapshotted and cloned (%s); the clones depend on those snapshots", strings.Join(zvols, ", ")))
+	}
+	plan.warnings = append(plan.warnings, "The clone gets new NIC MAC addresses but the same guest OS identity (hostname, machine ID); change them before running both at once")
+	return plan, nil
+}
+
+func (r *Registry) handleCloneVM(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planVMClone(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	params := []interface{}{plan.vm["id"]}
+	if plan.name != "" {
+		params = append(params, plan.name)
+	}
+	result, err := client.Call("vm.clone", params...)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone VM %s: %w", plan.vm["name"], err)
+	}
+	response := map[string]interface{}{
+		"source": plan.vm["name"],
+	}
+	if len(plan.warnings) > 0 {
+		response["warnings"] = plan.warnings
+	}
+	if jobID, ok := vmJobID(result); ok {
+		response["name"] = plan.name
+		return r.trackVMJob(client, "clone_vm", args, jobID, "clone initiated", response)
+	}
+
+	if plan.name != "" {
+		if clone, err := getVM(client, map[string]interface{}{"vm": plan.name}); err == nil {
+			response["clone"] = simplifyVM(clone)
+		}
+	}
+	response["cloned"] = true
+	response["message"] = fmt.Sprintf("VM %s was cloned; the clone is stopped. Review it with query_vms and start it with start_vm.", plan.vm["name"])
+	return marshalJSON(response)
+}
+
+// vmDeletion is a validated delete_vm request
+type vmDeletion struct {
+	vm       map[string]interface{}
+	zvols    []string
+	options  map[string]interface{}
+	warnings []string
+}
+
+func planVMDeletion(client truenas.Caller, args map[string]interface{}) (*vmDeletion, error) {
+	vm, err := getVM(client, args)
+	if err != nil {
+		return nil, err
+	}
+	deleteZvols, _ := args["delete_zvols"].(bool)
+	force, _ := args["force"].(bool)
+
+	plan := &vmDeletion{
+		vm:      vm,
+		zvols:   vmDiskZvols(vm),
+		options: map[string]interface{}{"zvols": deleteZvols, "force": force},
+	}
+	if vmState(vm) == "RUNNING" {
+		if !force {
+			return nil, newToolError(ErrorPrecondition, "VM %s is running; stop it with stop_vm first or pass force=true to power it off", vm["name"])
+		}
+		plan.warnings = append(plan.warnings, fmt.Sprintf("VM %s is running and will be powered off without a guest shutdown", vm["name"]))
+	}
+	if len(plan.zvols) > 0 {
+		if deleteZvols {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("Disk zvols are permanently destroyed with all their data: %s", strings.Join(plan.zvols, ", ")))
+		} else {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("Disk zvols are kept and keep using space: %s (pass delete_zvols=true to destroy them)", strings.Join(plan.zvols, ", ")))
+		}
+	}
+	return plan, nil
+}
+
+func (r *Registry) handleDeleteVM(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	plan, err := planVMDeletion(client, args)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprint(plan.vm["name"])
+	response := map[string]interface{}{
+		"id":   plan.vm["id"],
+		"name": name,
+	}
+	if len(plan.zvols) > 0 {
+		response["disk_zvols"] = plan.zvols
+		response["zvols_deleted"] = plan.options["zvols"]
+	}
+	return r.deleteOrDefer(client, "delete_vm", name, "vm.delete", []interface{}{plan.vm["id"], plan.options}, response)
+}
+
+// Dry-run wrappers
+
+func (r *Registry) handleStartVMWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &vmActionDryRun{action: "start"}, r.handleStartVM)
+}
+
+func (r *Registry) handleStopVMWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &vmActionDryRun{action: "stop"}, r.handleStopVM)
+}
+
+func (r *Registry) handleRestartVMWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &vmActionDryRun{action: "restart"}, r.handleRestartVM)
+}
+
+func (r *Registry) handleCloneVMWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &cloneVMDryRun{}, r.handleCloneVM)
+}
+
+func (r *Registry) handleDeleteVMWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &deleteVMDryRun{registry: r}, r.handleDeleteVM)
+}
+
+// Dry-run implementations
+
+type vmActionDryRun struct {
+	action string
+}
+
+func (d *vmActionDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	vm, err := getVM(client, args)
+	if err != nil {
+		return nil, err
+	}
+	state := vmState(vm)
+	warnings := []string{}
+	details := map[string]interface{}{"id": vm["id"]}
+	description := ""
+	estimate := &EstimatedTime{MinSeconds: 5, MaxSeconds: 60, Note: "Depends on the guest OS"}
+
+	switch d.action {
+	case "start":
+		overcommit, _ := args["overcommit"].(bool)
+		details["overcommit"] = overcommit
+		description = fmt.Sprintf("Start VM %s", vm["name"])
+		if state == "RUNNING" {
+			warnings = append(warnings, fmt.Sprintf("VM %s is already running", vm["name"]))
+		}
+		if warning := vmMemoryWarning(client, vm, overcommit); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	case "stop":
+		force, _ := args["force"].(bool)
+		forceAfterTimeout, _ := args["force_after_timeout"].(bool)
+		details["force"] = force
+		details["force_after_timeout"] = forceAfterTimeout
+		if state != "RUNNING" {
+			warnings = append(warnings, fmt.Sprintf("VM %s is not running (state %s)", vm["name"], state))
+		}
+		timeout, _ := vm["shutdown_timeout"].(float64)
+		switch {
+		case force:
+			description = fmt.Sprintf("Power off VM %s immediately", vm["name"])
+			warnings = append(warnings, "Powering off skips the guest shutdown, like pulling the plug: unsaved data is lost and guest filesystems may need repair")
+			estimate = &EstimatedTime{MinSeconds: 1, MaxSeconds: 10}
+		case forceAfterTimeout:
+			description = fmt.Sprintf("Shut down VM %s via ACPI, powering it off if it has not stopped after %d seconds", vm["name"], int(timeout))
+			warnings = append(warnings, "If the guest does not shut down in time it is powered off, losing unsaved data")
+		default:
+			description = fmt.Sprintf("Shut down VM %s via ACPI", vm["name"])
+			warnings = append(warnings, fmt.Sprintf("A guest that ignores the ACPI shutdown keeps running after the %d-second shutdown timeout; pass force_after_timeout=true to power it off then", int(timeout)))
+		}
+		if timeout > 0 && !force {
+			estimate = &EstimatedTime{MinSeconds: 5, MaxSeconds: int(timeout), Note: "Up to the VM's shutdown timeout"}
+		}
+	case "restart":
+		description = fmt.Sprintf("Restart VM %s (shut down, then start)", vm["name"])
+		if state != "RUNNING" {
+			warnings = append(warnings, fmt.Sprintf("VM %s is not running (state %s); use start_vm", vm["name"], state))
+		}
+		warnings = append(warnings, "Services running in the guest are unavailable until it has booted again")
+	}
+
+	return &DryRunResult{
+		Tool:         d.action + "_vm",
+		CurrentState: simplifyVM(vm),
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: description,
+				Operation:   d.action,
+				Target:      "vm." + d.action,
+				Details:     details,
+			},
+		},
+		Warnings:      warnings,
+		EstimatedTime: estimate,
+	}, nil
+}
+
+type cloneVMDryRun struct{}
+
+func (d *cloneVMDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planVMClone(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	name := plan.name
+	if name == "" {
+		name = fmt.Sprintf("%v_cloneN (chosen by the middleware)", plan.vm["name"])
+	}
+	return &DryRunResult{
+		Tool:         "clone_vm",
+		CurrentState: simplifyVM(plan.vm),
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Clone VM %s as %s, including its devices and disks", plan.vm["name"], name),
+				Operation:   "clone",
+				Target:      "vm.clone",
+				Details:     map[string]interface{}{"id": plan.vm["id"], "name": name, "disk_zvols": vmDiskZvols(plan.vm)},
+			},
+		},
+		Warnings: plan.warnings,
+	}, nil
+}
+
+type deleteVMDryRun struct {
+	registry *Registry
+}
+
+func (d *deleteVMDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	plan, err := planVMDeletion(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprint(plan.vm["name"])
+	action := d.registry.deletionPlan(fmt.Sprintf("Delete VM %s", name), name)
+	action.Details = map[string]interface{}{
+		"id":            plan.vm["id"],
+		"disk_zvols":    plan.zvols,
+		"zvols_deleted": plan.options["zvols"],
+		"force":         plan.options["force"],
+	}
+	return &DryRunResult{
+		Tool:           "delete_vm",
+		CurrentState:   simplifyVM(plan.vm),
+		PlannedActions: []PlannedAction{action},
+		Warnings:       plan.warnings,
+	}, nil
+}
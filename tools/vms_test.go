@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationVMLifecycle(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	vms := func(dbState string) []map[string]interface{} {
+		return []map[string]interface{}{
+			{"id": float64(1), "name": "web", "memory": float64(2048), "shutdown_timeout": float64(90),
+				"status": map[string]interface{}{"state": "RUNNING"}},
+			{"id": float64(2), "name": "db", "memory": float64(4096),
+				"status": map[string]interface{}{"state": dbState}},
+			{"id": float64(3), "name": "old", "memory": float64(1024),
+				"status": map[string]interface{}{"state": "STOPPED"},
+				"devices": []interface{}{
+					map[string]interface{}{"attributes": map[string]interface{}{"dtype": "DISK", "path": "/dev/zvol/tank/vms/old-disk0"}},
+				}},
+		}
+	}
+	server.SetRecords("vm.query", vms("STOPPED"))
+
+	result, err := registry.CallTool("stop_vm", map[string]interface{}{"vm": "web", "force": true, "dry_run": true})
+	if err != nil {
+		t.Fatalf("stop_vm dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "Power off VM web immediately") || !strings.Contains(result, "pulling the plug") {
+		t.Errorf("force stop dry run missing power-off warning:\n%s", result)
+	}
+	if n := len(server.Calls("vm.stop")); n != 0 {
+		t.Errorf("dry run called vm.stop %d times", n)
+	}
+
+	server.HandleJob("vm.stop", truenastest.JobSpec{})
+	result, err = registry.CallTool("stop_vm", map[string]interface{}{"vm": "web"})
+	if err != nil {
+		t.Fatalf("stop_vm failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["task_id"] == nil || response["job_id"] == nil {
+		t.Errorf("stop_vm should track its job:\n%s", result)
+	}
+	calls := server.Calls("vm.stop")
+	if len(calls) != 1 || calls[0].Params[0] != float64(1) || fmt.Sprint(calls[0].Params[1]) != "map[force:false force_after_timeout:false]" {
+		t.Errorf("unexpected vm.stop calls: %v", calls)
+	}
+
+	server.SetResult("vm.get_available_memory", float64(1<<30))
+	result, err = registry.CallTool("start_vm", map[string]interface{}{"vm": "db", "dry_run": true})
+	if err != nil {
+		t.Fatalf("start_vm dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "needs 4.00 GiB") || !strings.Contains(result, "overcommit=true") {
+		t.Errorf("start dry run missing memory warning:\n%s", result)
+	}
+
+	// Releases where vm.start is not a job report the new state directly
+	server.Handle("vm.start", func(params []interface{}) (interface{}, error) {
+		server.SetRecords("vm.query", vms("RUNNING"))
+		return nil, nil
+	})
+	result, err = registry.CallTool("start_vm", map[string]interface{}{"vm": float64(2)})
+	if err != nil {
+		t.Fatalf("start_vm failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["succeeded"] != true || response["state"] != "RUNNING" {
+		t.Errorf("start_vm result:\n%s", result)
+	}
+
+	if _, err := registry.CallTool("delete_vm", map[string]interface{}{"vm": "db"}); err == nil || ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("deleting a running VM error = %v, want PRECONDITION", err)
+	}
+	if _, err := registry.CallTool("clone_vm", map[string]interface{}{"vm": "old", "name": "old-copy"}); err == nil || ClassifyError(err).Code != ErrorValidation {
+		t.Errorf("invalid clone name error = %v, want VALIDATION", err)
+	}
+
+	result, err = registry.CallTool("delete_vm", map[string]interface{}{"vm": "old", "delete_zvols": true, "dry_run": true})
+	if err != nil {
+		t.Fatalf("delete_vm dry run failed: %v", err)
+	}
+	if !strings.Contains(result, "permanently destroyed") || !strings.Contains(result, "tank/vms/old-disk0") {
+		t.Errorf("delete dry run missing zvol warning:\n%s", result)
+	}
+	server.SetResult("vm.delete", true)
+	result, err = registry.CallTool("delete_vm", map[string]interface{}{"vm": "old", "delete_zvols": true})
+	if err != nil {
+		t.Fatalf("delete_vm failed: %v", err)
+	}
+	if decodeResult(t, result)["deleted"] != true {
+		t.Errorf("delete_vm result:\n%s", result)
+	}
+	calls = server.Calls("vm.delete")
+	if len(calls) != 1 || calls[0].Params[0] != float64(3) || fmt.Sprint(calls[0].Params[1]) != "map[force:false zvols:true]" {
+		t.Errorf("unexpected vm.delete calls: %v", calls)
+	}
+
+	if _, err := registry.CallTool("restart_vm", map[string]interface{}{"vm": "mail"}); err == nil || ClassifyError(err).Code != ErrorNotFound {
+		t.Errorf("unknown VM error = %v, want NOT_FOUND", err)
+	}
+}
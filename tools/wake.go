@@ -0,0 +1,376 @@
+package tools
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Wake-on-LAN
+//
+// The middleware has no Wake-on-LAN method, so the magic packet is sent by a
+// temporary, disabled cron job that runs a python3 one-liner as root; it is
+// run once and deleted again. Every value in its command is validated first.
+//
+// Wake-on-LAN support is not checked. It is a setting of the target's network
+// card, which the NAS cannot see while the target is off, and interface.query
+// does not report it for the NAS's own cards, which only send a broadcast.
+// The NAS-side check is can_send: an IPv4 address and a link that is not down.
+
+const (
+	// wakePacketCount is how many magic packets are sent; WOL is UDP and
+	// a single packet is easily lost
+	wakePacketCount = 3
+
+	// wakeJobTimeout bounds how long sending may take
+	wakeJobTimeout      = 15 * time.Second
+	wakeJobPollInterval = 250 * time.Millisecond
+
+	// wakeCleanupTimeout bounds deleting the temporary cron job
+	wakeCleanupTimeout = 15 * time.Second
+)
+
+// wakeInterfaceName matches the interface names that are safe to put in the
+// wake command
+var wakeInterfaceName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// wakeInterface is a NAS interface that can send a wake packet
+type wakeInterface struct {
+	Name      string   `json:"name"`
+	Link      string   `json:"link,omitempty"`
+	Networks  []string `json:"networks"`
+	Broadcast []string `json:"broadcast"`
+	CanSend   bool     `json:"can_send"`
+	networks  []*net.IPNet
+}
+
+// parseWakeMAC validates a 48-bit unicast MAC address
+func parseWakeMAC(value string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(strings.TrimSpace(value))
+	if err != nil || len(mac) != 6 {
+		return nil, newToolError(ErrorValidation, "mac must be a 48-bit MAC address like 00:11:22:33:44:55 (got: %q)", value)
+	}
+	if mac[0]&1 == 1 {
+		return nil, newToolError(ErrorValidation, "%s is a multicast or broadcast address, not a host's MAC address", mac)
+	}
+	return mac, nil
+}
+
+// broadcastAddress returns the IPv4 broadcast address of a network
+func broadcastAddress(network *net.IPNet) net.IP {
+	ip := network.IP.To4()
+	broadcast := make(net.IP, len(ip))
+	for i := range ip {
+		broadcast[i] = ip[i] | ^network.Mask[i]
+	}
+	return broadcast
+}
+
+// queryWakeInterfaces lists the NAS interfaces with their IPv4 networks,
+// from both configured aliases and addresses assigned by DHCP
+func queryWakeInterfaces(client truenas.Caller) ([]*wakeInterface, error) {
+	interfaces, err := inventoryQuery(client, "interface.query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query interfaces: %w", err)
+	}
+
+	result := make([]*wakeInterface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		name, _ := iface["name"].(string)
+		wi := &wakeInterface{Name: name, Networks: []string{}, Broadcast: []string{}}
+		state, _ := iface["state"].(map[string]interface{})
+		if link, ok := state["link_state"].(string); ok {
+			wi.Link = strings.TrimPrefix(link, "LINK_STATE_")
+		}
+
+		aliases, _ := iface["aliases"].([]interface{})
+		stateAliases, _ := state["aliases"].([]interface{})
+		for _, raw := range append(aliases, stateAliases...) {
+			alias, _ := raw.(map[string]interface{})
+			address, _ := alias["address"].(string)
+			netmask, ok := alias["netmask"].(float64)
+			if !ok || net.ParseIP(address).To4() == nil {
+				continue
+			}
+			_, network, err := net.ParseCIDR(fmt.Sprintf("%s/%d", address, int(netmask)))
+			if err != nil || containsString(wi.Networks, network.String()) {
+				continue
+			}
+			wi.networks = append(wi.networks, network)
+			wi.Networks = append(wi.Networks, network.String())
+			wi.Broadcast = append(wi.Broadcast, broadcastAddress(network).String())
+		}
+		wi.CanSend = len(wi.networks) > 0 && wi.Link != "DOWN"
+		result = append(result, wi)
+	}
+	return result, nil
+}
+
+// wakeRequest is a validated wake_host request
+type wakeRequest struct {
+	mac        net.HardwareAddr
+	broadcast  string
+	port       int
+	iface      string
+	interfaces []*wakeInterface
+}
+
+// command is the shell command that sends the magic packets on the NAS
+func (w *wakeRequest) command() string {
+	bind := ""
+	if w.iface != "" {
+		bind = fmt.Sprintf(`s.setsockopt(socket.SOL_SOCKET, socket.SO_BINDTODEVICE, b"%s"); `, w.iface)
+	}
+	return fmt.Sprintf(`python3 -c 'import socket; s = socket.socket(socket.AF_INET, socket.SOCK_DGRAM); s.setsockopt(socket.SOL_SOCKET, socket.SO_BROADCAST, 1); %sp = bytes.fromhex("ff" * 6 + "%s" * 16); [s.sendto(p, ("%s", %d)) for _ in range(%d)]'`,
+		bind, hex.EncodeToString(w.mac), w.broadcast, w.port, wakePacketCount)
+}
+
+func parseWakeRequest(client truenas.Caller, args map[string]interface{}) (*wakeRequest, error) {
+	macArg, _ := args["mac"].(string)
+	if macArg == "" {
+		return nil, fmt.Errorf("mac is required")
+	}
+	mac, err := parseWakeMAC(macArg)
+	if err != nil {
+		return nil, err
+	}
+	w := &wakeRequest{mac: mac, port: 9}
+	if p, ok := args["port"].(float64); ok {
+		if p < 1 || p > 65535 {
+			return nil, newToolError(ErrorValidation, "port must be between 1 and 65535 (got: %v)", p)
+		}
+		w.port = int(p)
+	}
+
+	w.interfaces, err = queryWakeInterfaces(client)
+	if err != nil {
+		return nil, err
+	}
+	usable := []*wakeInterface{}
+	for _, wi := range w.interfaces {
+		if wi.CanSend {
+			usable = append(usable, wi)
+		}
+	}
+
+	var chosen *wakeInterface
+	if name, _ := args["interface"].(string); name != "" {
+		for _, wi := range w.interfaces {
+			if wi.Name == name {
+				chosen = wi
+			}
+		}
+		if chosen == nil {
+			return nil, newToolError(ErrorNotFound, "interface %s not found", name)
+		}
+		if !chosen.CanSend {
+			return nil, newToolError(ErrorPrecondition, "interface %s has no IPv4 address or its link is down", name)
+		}
+	}
+	if ipArg, _ := args["host_ip"].(string); ipArg != "" && chosen == nil {
+		ip := net.ParseIP(ipArg).To4()
+		if ip == nil {
+			return nil, newToolError(ErrorValidation, "host_ip must be an IPv4 address (got: %q)", ipArg)
+		}
+		for _, wi := range usable {
+			for i, network := range wi.networks {
+				if network.Contains(ip) {
+					chosen, w.broadcast = wi, wi.Broadcast[i]
+				}
+			}
+		}
+		if chosen == nil {
+			return nil, newToolError(ErrorPrecondition, "no NAS interface is on the same network as %s; wake packets do not cross routers", ipArg)
+		}
+	}
+
+	if b, _ := args["broadcast"].(string); b != "" {
+		ip := net.ParseIP(b).To4()
+		if ip == nil {
+			return nil, newToolError(ErrorValidation, "broadcast must be an IPv4 address (got: %q)", b)
+		}
+		w.broadcast = ip.String()
+	}
+	if chosen == nil && w.broadcast == "" {
+		if len(usable) != 1 {
+			names := make([]string, 0, len(usable))
+			for _, wi := range usable {
+				names = append(names, fmt.Sprintf("%s (%s)", wi.Name, strings.Join(wi.Networks, ", ")))
+			}
+			return nil, newToolError(ErrorValidation, "the NAS has %d usable interfaces; pass interface, host_ip, or broadcast to pick the target network: %s",
+				len(usable), strings.Join(names, "; "))
+		}
+		chosen = usable[0]
+	}
+	if chosen != nil {
+		if !wakeInterfaceName.MatchString(chosen.Name) {
+			return nil, newToolError(ErrorValidation, "interface name %q cannot be used in the wake command", chosen.Name)
+		}
+		w.iface = chosen.Name
+		if w.broadcast == "" {
+			w.broadcast = chosen.Broadcast[0]
+		}
+	}
+	return w, nil
+}
+
+// runWakeCommand runs the wake command once on the NAS through a temporary
+// cron job. The job is deleted again on every path once it may exist; a
+// failed deletion after the packets were sent is returned as a warning.
+func runWakeCommand(ctx context.Context, client truenas.Caller, w *wakeRequest) (warnings []string, err error) {
+	// The description identifies the job if cronjob.create fails after the
+	// middleware created it
+	description := fmt.Sprintf("truenas-mcp wake_host %s %s (temporary)", w.mac, uuid.New().String()[:8])
+	id := 0
+	defer func() {
+		cleanupErr := deleteWakeCronJob(client, id, description)
+		if cleanupErr == nil {
+			return
+		}
+		message := fmt.Sprintf("the temporary cron job %q could not be deleted; delete it under System > Advanced > Cron Jobs: %v", description, cleanupErr)
+		if err != nil {
+			err = fmt.Errorf("%w (%s)", err, message)
+		} else {
+			warnings = append(warnings, message)
+		}
+	}()
+
+	result, err := client.Call("cronjob.create", map[string]interface{}{
+		"command":     w.command(),
+		"user":        "root",
+		"enabled":     false,
+		"description": description,
+		"stdout":      true,
+		"stderr":      false,
+		"schedule":    map[string]interface{}{"minute": "0", "hour": "0", "dom": "1", "month": "1", "dow": "*"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary cron job: %w", err)
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(result, &created); err != nil {
+		return nil, fmt.Errorf("failed to parse cron job: %w", err)
+	}
+	createdID, ok := created["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("cronjob.create returned no id")
+	}
+	id = int(createdID)
+
+	result, err = client.Call("cronjob.run", id, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run wake command: %w", err)
+	}
+	jobID, err := parseJobID(result)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := waitForJobWithin(ctx, client, jobID, wakeJobPollInterval, wakeJobTimeout); err != nil {
+		return nil, fmt.Errorf("wake command failed on the NAS: %w", err)
+	}
+	return nil, nil
+}
+
+// deleteWakeCronJob deletes the temporary cron job, looking it up by its
+// description when its ID is unknown. It runs under its own timeout so the
+// job is also deleted after the call was cancelled.
+func deleteWakeCronJob(client truenas.Caller, id int, description string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wakeCleanupTimeout)
+	defer cancel()
+	client = client.WithContext(ctx)
+
+	ids := []int{id}
+	if id == 0 {
+		result, err := client.Call("cronjob.query", []interface{}{
+			[]interface{}{"description", "=", description},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to look up the cron job: %w", err)
+		}
+		var jobs []map[string]interface{}
+		if err := json.Unmarshal(result, &jobs); err != nil {
+			return fmt.Errorf("failed to parse cron jobs: %w", err)
+		}
+		ids = ids[:0]
+		for _, job := range jobs {
+			if jobID, ok := job["id"].(float64); ok {
+				ids = append(ids, int(jobID))
+			}
+		}
+	}
+	for _, id := range ids {
+		if _, err := client.Call("cronjob.delete", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleWakeHost(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	w, err := parseWakeRequest(client, args)
+	if err != nil {
+		return "", err
+	}
+	warnings, err := runWakeCommand(ctx, client, w)
+	if err != nil {
+		return "", err
+	}
+
+	response := map[string]interface{}{
+		"mac":       w.mac.String(),
+		"broadcast": w.broadcast,
+		"port":      w.port,
+		"interface": w.iface,
+		"packets":   wakePacketCount,
+		"sent":      true,
+		"message": fmt.Sprintf("Sent %d magic packets for %s to %s:%d. The host needs Wake-on-LAN enabled in its firmware and network card and usually takes 10-60 seconds to boot; sending does not confirm it woke, so check it answers before relying on it.",
+			wakePacketCount, w.mac, w.broadcast, w.port),
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	return marshalJSON(response)
+}
+
+// Dry-run wrapper
+
+func handleWakeHostWithDryRun(ctx context.Context, client truenas.Caller, args map[string]interface{}) (string, error) {
+	return ExecuteWithDryRun(ctx, client, args, &wakeHostDryRun{}, handleWakeHost)
+}
+
+type wakeHostDryRun struct{}
+
+func (d *wakeHostDryRun) ExecuteDryRun(client truenas.Caller, args map[string]interface{}) (*DryRunResult, error) {
+	w, err := parseWakeRequest(client, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DryRunResult{
+		Tool: "wake_host",
+		CurrentState: map[string]interface{}{
+			"interfaces": w.interfaces,
+		},
+		PlannedActions: []PlannedAction{
+			{
+				Step:        1,
+				Description: fmt.Sprintf("Send %d Wake-on-LAN packets for %s to %s:%d", wakePacketCount, w.mac, w.broadcast, w.port),
+				Operation:   "send",
+				Target:      w.broadcast,
+				Details:     map[string]interface{}{"interface": w.iface, "via": "temporary root cron job running python3, deleted after it runs"},
+			},
+		},
+		Warnings: []string{
+			"Wake packets only reach hosts on the same layer-2 network; they do not cross routers unless directed broadcast is allowed",
+			"Whether the target supports Wake-on-LAN cannot be checked from the NAS; it must be enabled in the target's firmware and network card",
+		},
+	}, nil
+}
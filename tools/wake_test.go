@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenastest"
+)
+
+func TestIntegrationWakeHost(t *testing.T) {
+	registry, server := newTestRegistry(t)
+	server.SetRecords("interface.query", []map[string]interface{}{
+		{"name": "enp1s0", "state": map[string]interface{}{"link_state": "LINK_STATE_UP"},
+			"aliases": []interface{}{map[string]interface{}{"type": "INET", "address": "192.168.1.10", "netmask": float64(24)}}},
+		{"name": "enp2s0", "aliases": []interface{}{}, "state": map[string]interface{}{"link_state": "LINK_STATE_UP",
+			"aliases": []interface{}{map[string]interface{}{"type": "INET", "address": "10.0.5.5", "netmask": float64(16)}}}},
+		{"name": "eno3", "state": map[string]interface{}{"link_state": "LINK_STATE_DOWN"}},
+	})
+	server.Handle("cronjob.create", func(params []interface{}) (interface{}, error) {
+		return map[string]interface{}{"id": float64(7)}, nil
+	})
+	server.HandleJob("cronjob.run", truenastest.JobSpec{Steps: 1})
+	server.SetResult("cronjob.delete", true)
+
+	// Two usable interfaces: the target network must be chosen
+	_, err := registry.CallTool("wake_host", map[string]interface{}{"mac": "00:11:22:33:44:55"})
+	if err == nil || ClassifyError(err).Code != ErrorValidation || !strings.Contains(err.Error(), "enp2s0") {
+		t.Errorf("ambiguous interface error = %v, want VALIDATION listing interfaces", err)
+	}
+
+	result, err := registry.CallTool("wake_host", map[string]interface{}{"mac": "00-11-22-33-44-55", "host_ip": "10.0.9.20", "dry_run": true})
+	if err != nil {
+		t.Fatalf("wake_host dry run failed: %v", err)
+	}
+	action := decodeResult(t, result)["planned_actions"].([]interface{})[0].(map[string]interface{})
+	if action["target"] != "10.0.255.255" || action["details"].(map[string]interface{})["interface"] != "enp2s0" {
+		t.Errorf("dry run action = %v, want enp2s0 broadcast 10.0.255.255", action)
+	}
+	if len(server.Calls("cronjob.create")) != 0 {
+		t.Fatal("dry run created a cron job")
+	}
+
+	result, err = registry.CallTool("wake_host", map[string]interface{}{"mac": "00:11:22:33:44:55", "interface": "enp1s0"})
+	if err != nil {
+		t.Fatalf("wake_host failed: %v", err)
+	}
+	if response := decodeResult(t, result); response["sent"] != true || response["broadcast"] != "192.168.1.255" {
+		t.Errorf("wake_host response = %v", response)
+	}
+	creates := server.Calls("cronjob.create")
+	if len(creates) != 1 {
+		t.Fatalf("cronjob.create called %d times, want 1", len(creates))
+	}
+	job := creates[0].Params[0].(map[string]interface{})
+	command := job["command"].(string)
+	for _, want := range []string{`"001122334455" * 16`, `("192.168.1.255", 9)`, `b"enp1s0"`} {
+		if !strings.Contains(command, want) {
+			t.Errorf("command %q does not contain %s", command, want)
+		}
+	}
+	if job["enabled"] != false {
+		t.Errorf("temporary cron job is enabled: %v", job)
+	}
+	if deletes := server.Calls("cronjob.delete"); len(deletes) != 1 || deletes[0].Params[0] != float64(7) {
+		t.Errorf("cronjob.delete calls = %v, want the temporary job deleted", deletes)
+	}
+
+	// A failed wake command still deletes the cron job
+	server.HandleJob("cronjob.run", truenastest.JobSpec{Error: "Network is unreachable"})
+	_, err = registry.CallTool("wake_host", map[string]interface{}{"mac": "00:11:22:33:44:55", "interface": "enp1s0"})
+	if err == nil || !strings.Contains(err.Error(), "Network is unreachable") {
+		t.Errorf("failed wake command error = %v", err)
+	}
+	if deletes := server.Calls("cronjob.delete"); len(deletes) != 2 || deletes[1].Params[0] != float64(7) {
+		t.Errorf("cronjob.delete calls = %v, want the job deleted after a failed run", deletes)
+	}
+
+	// When cronjob.create fails after creating the job, it is found by its
+	// description and deleted
+	server.Handle("cronjob.create", func(params []interface{}) (interface{}, error) {
+		description := params[0].(map[string]interface{})["description"]
+		server.SetRecords("cronjob.query", []map[string]interface{}{{"id": float64(8), "description": description}})
+		return nil, &truenastest.Error{Code: 14, Message: "Connection reset"}
+	})
+	_, err = registry.CallTool("wake_host", map[string]interface{}{"mac": "00:11:22:33:44:55", "interface": "enp1s0"})
+	if err == nil {
+		t.Error("wake_host succeeded although cronjob.create failed")
+	}
+	if deletes := server.Calls("cronjob.delete"); len(deletes) != 3 || deletes[2].Params[0] != float64(8) {
+		t.Errorf("cronjob.delete calls = %v, want the job found by description deleted", deletes)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"mac": "not-a-mac"},
+		{"mac": "ff:ff:ff:ff:ff:ff", "interface": "enp1s0"},
+		{"mac": "00:11:22:33:44:55", "broadcast": "'; rm -rf /"},
+	} {
+		if _, err := registry.CallTool("wake_host", args); err == nil || ClassifyError(err).Code != ErrorValidation {
+			t.Errorf("wake_host(%v) error = %v, want VALIDATION", args, err)
+		}
+	}
+	_, err = registry.CallTool("wake_host", map[string]interface{}{"mac": "00:11:22:33:44:55", "interface": "eno3"})
+	if err == nil || ClassifyError(err).Code != ErrorPrecondition {
+		t.Errorf("down interface error = %v, want PRECONDITION", err)
+	}
+}
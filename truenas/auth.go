@@ -0,0 +1,94 @@
+package truenas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Credentials are how a Client logs in to the middleware: with an API key,
+// a username and password, or a session token. Exactly one is used.
+type Credentials struct {
+	// APIKey logs in with auth.login_with_api_key
+	APIKey string
+
+	// Username and Password log in with auth.login, for systems where API
+	// keys are disabled by policy. OTP is the two-factor one-time password
+	// when the account requires one; it is only good for a short time, so
+	// reconnecting after it expires fails and the server must be restarted
+	// with a new one.
+	Username string
+	Password string
+	OTP      string
+
+	// Token logs in with auth.login_with_token, using a session token from
+	// auth.generate_token; it stops working when the token expires
+	Token string
+}
+
+// Authentication methods reported by Credentials.Method
+const (
+	AuthAPIKey   = "api_key"
+	AuthPassword = "password"
+	AuthToken    = "token"
+)
+
+// Method names the kind of login the credentials make ("" when none is set)
+func (c Credentials) Method() string {
+	switch {
+	case c.APIKey != "":
+		return AuthAPIKey
+	case c.Username != "" || c.Password != "":
+		return AuthPassword
+	case c.Token != "":
+		return AuthToken
+	}
+	return ""
+}
+
+// Validate checks that exactly one complete kind of credential is set
+func (c Credentials) Validate() error {
+	set := 0
+	for _, given := range []bool{c.APIKey != "", c.Username != "" || c.Password != "", c.Token != ""} {
+		if given {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		return fmt.Errorf("credentials required: an API key, a username and password, or a session token")
+	case set > 1:
+		return fmt.Errorf("give only one of an API key, a username and password, or a session token")
+	case (c.Username == "") != (c.Password == ""):
+		return fmt.Errorf("username and password must be given together")
+	case c.OTP != "" && c.Username == "":
+		return fmt.Errorf("a one-time password is only used with a username and password")
+	}
+	return nil
+}
+
+// login returns the middleware call that authenticates with the credentials
+func (c Credentials) login() (method string, params []interface{}) {
+	switch c.Method() {
+	case AuthPassword:
+		params = []interface{}{c.Username, c.Password}
+		if c.OTP != "" {
+			params = append(params, c.OTP)
+		}
+		return "auth.login", params
+	case AuthToken:
+		return "auth.login_with_token", []interface{}{c.Token}
+	}
+	return "auth.login_with_api_key", []interface{}{c.APIKey}
+}
+
+// authorize sets the Authorization header of an HTTP file transfer request
+func (c Credentials) authorize(req *http.Request) {
+	switch c.Method() {
+	case AuthPassword:
+		req.SetBasicAuth(c.Username, c.Password)
+	case AuthToken:
+		req.Header.Set("Authorization", "Token "+c.Token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+}
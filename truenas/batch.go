@@ -0,0 +1,46 @@
+package truenas
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Concurrent calls
+//
+// Requests on the connection are multiplexed by ID, so independent calls
+// need not wait for each other: the middleware answers them in parallel and
+// the read loop routes each response to its caller.
+
+// maxBatchConcurrency bounds the calls one batch keeps in flight
+const maxBatchConcurrency = 8
+
+// BatchCall is one method call in a CallConcurrently batch
+type BatchCall struct {
+	Method string
+	Params []interface{}
+}
+
+// BatchResult is the outcome of one BatchCall
+type BatchResult struct {
+	Result json.RawMessage
+	Err    error
+}
+
+// CallConcurrently sends independent calls at the same time and returns
+// their results in the same order. Each call fails or succeeds on its own.
+func (c *Client) CallConcurrently(calls ...BatchCall) []BatchResult {
+	results := make([]BatchResult, len(calls))
+	slots := make(chan struct{}, maxBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, call BatchCall) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i].Result, results[i].Err = c.Call(call.Method, call.Params...)
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,41 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// Caller is what tool handlers need from a middleware connection. *Client
+// implements it over the WebSocket; truenastest.Mock implements it with
+// scripted responses so handlers can be unit tested without a NAS.
+type Caller interface {
+	// Call invokes a middleware method and returns its raw JSON result
+	Call(method string, params ...interface{}) (json.RawMessage, error)
+
+	// CallConcurrently sends independent calls at the same time and returns
+	// their results in the same order
+	CallConcurrently(calls ...BatchCall) []BatchResult
+
+	// Download runs a method that produces a file and returns its job ID and
+	// the file contents, which the caller must close
+	Download(method string, params []interface{}, filename string) (int, io.ReadCloser, error)
+
+	// Upload runs a method that consumes a file with body as its contents and
+	// returns the job ID
+	Upload(method string, params []interface{}, body io.Reader) (int, error)
+
+	// WithCorrelationID returns a Caller whose calls are tagged with id
+	WithCorrelationID(id string) Caller
+
+	// WithContext returns a Caller whose calls stop waiting once ctx is done
+	WithContext(ctx context.Context) Caller
+
+	// Context returns the context set by WithContext, or context.Background()
+	Context() context.Context
+
+	// CorrelationID returns the ID set by WithCorrelationID, or ""
+	CorrelationID() string
+}
+
+var _ Caller = (*Client)(nil)
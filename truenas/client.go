@@ -1,27 +1,58 @@
 package truenas
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/truenas/truenas-mcp/redact"
 )
 
+// Client is a handle on a shared middleware connection. Handles derived with
+// WithCorrelationID or WithContext share the connection but tag their calls
+// in the logs or bound how long they wait.
 type Client struct {
-	endpoint  string
-	apiKey    string
-	tlsConfig *tls.Config
+	*connection
+
+	// correlationID ties this handle's middleware calls to one tool call
+	correlationID string
+
+	// ctx cancels this handle's calls (nil = never)
+	ctx context.Context
+}
 
-	// connMu protects conn and authenticated; also gates connect/authenticate
+// connection is the WebSocket session shared by all handles of a Client
+type connection struct {
+	endpoint    string
+	credentials Credentials
+	tlsConfig   *tls.Config
+
+	// connMu protects conn, authenticated, and closed; also gates connect
 	connMu        sync.Mutex
 	conn          *websocket.Conn
 	authenticated bool
+	closed        bool // Close was called; dropped connections are not restored
+
+	// established is set once authentication has succeeded, after which a
+	// dropped connection is reconnected with backoff
+	established atomic.Bool
+
+	// attemptMu serializes reconnect attempts; reconnecting is set while the
+	// background reconnect loop runs
+	attemptMu    sync.Mutex
+	reconnecting atomic.Bool
+
+	policyMu sync.Mutex
+	policy   ReconnectPolicy
 
 	// writeMu protects concurrent WebSocket writes
 	writeMu sync.Mutex
@@ -30,7 +61,19 @@ type Client struct {
 	pendingMu sync.Mutex
 	pending   map[string]chan *responseResult
 
+	// subs maps subscribed event names -> handlers (re-established after reconnect)
+	subsMu sync.Mutex
+	subs   map[string][]EventHandler
+
+	// recorder captures request/response pairs for fixtures (nil = not recording)
+	recorderMu sync.Mutex
+	recorder   *Recorder
+
 	requestID atomic.Uint64
+
+	// generation changes when the connection drops or is authenticated, so
+	// subscribers can tell when events may have been missed
+	generation atomic.Uint64
 }
 
 type responseResult struct {
@@ -66,22 +109,77 @@ type APIResponse struct {
 type APIError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
-	Trace   interface{} `json:"trace,omitempty"` // Can be string or object
+	ErrName string      `json:"errname,omitempty"` // errno name, e.g. "ENOENT"
+	Extra   interface{} `json:"extra,omitempty"`   // validation error details
+	Trace   interface{} `json:"trace,omitempty"`   // Can be string or object
 }
 
+// NewClient returns a client that logs in with an API key
 func NewClient(endpoint, apiKey string, tlsConfig *tls.Config) (*Client, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("apiKey cannot be empty")
+	}
+	return NewClientWithCredentials(endpoint, Credentials{APIKey: apiKey}, tlsConfig)
+}
+
+// NewClientWithCredentials returns a client that logs in with credentials
+// (an API key, a username and password, or a session token)
+func NewClientWithCredentials(endpoint string, credentials Credentials, tlsConfig *tls.Config) (*Client, error) {
 	if endpoint == "" {
 		return nil, fmt.Errorf("endpoint cannot be empty")
 	}
-	if apiKey == "" {
-		return nil, fmt.Errorf("apiKey cannot be empty")
+	if err := credentials.Validate(); err != nil {
+		return nil, err
+	}
+	return &Client{connection: &connection{
+		endpoint:    endpoint,
+		credentials: credentials,
+		tlsConfig:   tlsConfig,
+		pending:     make(map[string]chan *responseResult),
+		subs:        make(map[string][]EventHandler),
+		policy:      DefaultReconnectPolicy,
+	}}, nil
+}
+
+// WithCorrelationID returns a handle on the same connection whose middleware
+// calls are logged with id, so they can be traced back to the tool call that
+// made them
+func (c *Client) WithCorrelationID(id string) Caller {
+	if c == nil {
+		return nil
+	}
+	return &Client{connection: c.connection, correlationID: id, ctx: c.ctx}
+}
+
+// WithContext returns a handle on the same connection whose calls stop
+// waiting once ctx is done. The middleware cannot abort a call it has
+// received, so its work may still complete.
+func (c *Client) WithContext(ctx context.Context) Caller {
+	if c == nil {
+		return nil
+	}
+	return &Client{connection: c.connection, correlationID: c.correlationID, ctx: ctx}
+}
+
+// Context returns the context set by WithContext, or context.Background()
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
 	}
-	return &Client{
-		endpoint:  endpoint,
-		apiKey:    apiKey,
-		tlsConfig: tlsConfig,
-		pending:   make(map[string]chan *responseResult),
-	}, nil
+	return c.ctx
+}
+
+// CorrelationID returns the ID set by WithCorrelationID, or "" for the base client
+func (c *Client) CorrelationID() string {
+	return c.correlationID
+}
+
+// logger returns the default logger, tagged with the correlation ID if any
+func (c *Client) logger() *slog.Logger {
+	if c.correlationID == "" {
+		return slog.Default()
+	}
+	return slog.Default().With("correlation_id", c.correlationID)
 }
 
 // connect establishes the WebSocket connection and starts the read loop.
@@ -105,10 +203,10 @@ func (c *Client) connect() error {
 
 	var lastErr error
 	for _, url := range urls {
-		log.Printf("Connecting to %s...", url)
+		slog.Info("Connecting to TrueNAS", "url", url)
 		conn, _, err := wsDialer.Dial(url, nil)
 		if err != nil {
-			log.Printf("Connection failed: %v", err)
+			slog.Warn("Connection failed", "url", url, "error", err)
 			lastErr = err
 			continue
 		}
@@ -122,7 +220,7 @@ func (c *Client) connect() error {
 			Version: "1",
 			Support: []string{"1"},
 		}
-		log.Printf("Sending connect message: %+v", connectMsg)
+		slog.Debug("Sending connect message", "version", connectMsg.Version)
 		if err := conn.WriteJSON(connectMsg); err != nil {
 			conn.Close()
 			lastErr = fmt.Errorf("failed to send connect message: %w", err)
@@ -136,7 +234,7 @@ func (c *Client) connect() error {
 			lastErr = fmt.Errorf("failed to read connect response: %w", err)
 			continue
 		}
-		log.Printf("Received connect response: %+v", connectResp)
+		slog.Debug("Received connect response", "msg", connectResp.Msg, "session", connectResp.Session)
 
 		if connectResp.Msg != "connected" {
 			conn.Close()
@@ -150,19 +248,19 @@ func (c *Client) connect() error {
 		// Start the read loop to multiplex concurrent responses
 		go c.readLoop(conn)
 
-		log.Printf("Successfully connected via %s", url)
+		slog.Info("Connected to TrueNAS", "url", url)
 		return nil
 	}
 
-	return fmt.Errorf("all connection attempts failed: %w", lastErr)
+	return connectionLost(fmt.Errorf("all connection attempts failed: %w", lastErr))
 }
 
 // readLoop reads all WebSocket responses and routes them to the waiting callers
 // via the pending map. Runs as a goroutine for the lifetime of the connection.
 func (c *Client) readLoop(conn *websocket.Conn) {
 	for {
-		var resp APIResponse
-		if err := conn.ReadJSON(&resp); err != nil {
+		var msg wireMessage
+		if err := conn.ReadJSON(&msg); err != nil {
 			// Connection dropped - fail all pending requests
 			c.failAllPending(fmt.Errorf("failed to read response: %w", err))
 
@@ -173,12 +271,29 @@ func (c *Client) readLoop(conn *websocket.Conn) {
 				c.authenticated = false
 			}
 			c.connMu.Unlock()
+			c.generation.Add(1)
+			c.reconnectInBackground()
 			return
 		}
 
-		respJSON, _ := json.Marshal(resp)
-		log.Printf("Received response: %s", string(respJSON))
-		log.Printf("Result length: %d bytes", len(resp.Result))
+		// Subscription events are routed to event handlers, not pending calls
+		switch msg.Msg {
+		case "added", "changed", "removed":
+			c.dispatchEvent(msg)
+			continue
+		case "ready":
+			continue
+		case "nosub":
+			slog.Warn("Event subscription rejected", "subscription", string(msg.ID))
+			continue
+		}
+
+		resp := msg.response()
+
+		if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+			respJSON, _ := json.Marshal(resp)
+			slog.Debug("Received response", "id", resp.ID, "bytes", len(resp.Result), "response", string(respJSON))
+		}
 
 		// Route response to the waiting caller
 		c.pendingMu.Lock()
@@ -191,7 +306,7 @@ func (c *Client) readLoop(conn *websocket.Conn) {
 		if ok {
 			ch <- &responseResult{resp: &resp}
 		} else if resp.ID != "" {
-			log.Printf("Warning: received response for unknown request ID %s (may have timed out)", resp.ID)
+			slog.Warn("Received response for unknown request (may have timed out)", "id", resp.ID)
 		}
 	}
 }
@@ -239,10 +354,11 @@ func (c *Client) Authenticate() error {
 		return err
 	}
 
-	log.Println("Authenticating with TrueNAS middleware...")
+	slog.Info("Authenticating with TrueNAS middleware", "method", c.credentials.Method())
 
-	// Call auth.login_with_api_key
-	result, err := c.callRaw("auth.login_with_api_key", c.apiKey)
+	// Log in once: reconnecting is the caller's job
+	method, params := c.credentials.login()
+	result, _, err := c.send(method, params)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -259,154 +375,157 @@ func (c *Client) Authenticate() error {
 	c.connMu.Lock()
 	c.authenticated = true
 	c.connMu.Unlock()
+	c.established.Store(true)
+	c.generation.Add(1)
+
+	slog.Info("TrueNAS middleware authentication successful")
 
-	log.Println("TrueNAS middleware authentication successful")
+	// Re-establish event subscriptions on the (possibly new) connection
+	c.resubscribeAll()
 	return nil
 }
 
 func (c *Client) Call(method string, params ...interface{}) (json.RawMessage, error) {
-	// Ensure connected and authenticated (serialized to prevent concurrent reconnects)
-	c.connMu.Lock()
-	if err := c.connect(); err != nil {
-		c.connMu.Unlock()
-		return nil, err
+	if err := c.Context().Err(); err != nil {
+		return nil, contextError(method, err)
 	}
-	needsAuth := !c.authenticated
-	c.connMu.Unlock()
 
-	if needsAuth {
-		if err := c.Authenticate(); err != nil {
-			return nil, fmt.Errorf("re-authentication failed: %w", err)
-		}
+	// Ensure connected and authenticated, waiting out a reconnect if needed
+	if err := c.ensureReady(); err != nil {
+		return nil, err
 	}
-
 	return c.callRaw(method, params...)
 }
 
-// callRaw sends a request and waits for its response via the pending map.
-// Safe for concurrent use.
+// callRaw sends a request, reconnecting and sending it again if the
+// connection drops. A request that may already have reached the middleware
+// is only sent again when the method is idempotent.
 func (c *Client) callRaw(method string, params ...interface{}) (json.RawMessage, error) {
-	var lastErr error
+	for attempt := 1; ; attempt++ {
+		result, sent, err := c.send(method, params)
+		if err == nil || !errors.Is(err, ErrConnectionLost) {
+			return result, err
+		}
+		if sent && !isIdempotent(method) {
+			c.reconnectInBackground()
+			return nil, &ReconnectingError{Method: method, Err: err}
+		}
+		if attempt == maxCallAttempts {
+			return nil, err
+		}
 
-	// Try up to 2 times (initial attempt + 1 retry on connection error)
-	for attempt := 0; attempt < 2; attempt++ {
-		if attempt > 0 {
-			log.Printf("Retrying request after connection error (attempt %d/2)...", attempt+1)
-			c.connMu.Lock()
-			if err := c.connect(); err != nil {
-				c.connMu.Unlock()
-				return nil, fmt.Errorf("reconnection failed: %w", err)
-			}
-			c.connMu.Unlock()
-			if err := c.Authenticate(); err != nil {
-				return nil, fmt.Errorf("re-authentication failed: %w", err)
-			}
+		c.logger().Warn("Connection lost, retrying after reconnect", "method", method, "attempt", attempt+1, "max_attempts", maxCallAttempts)
+		if err := c.reconnect(c.reconnectPolicy().MaxWait); err != nil {
+			return nil, err
 		}
+	}
+}
 
-		// Snapshot the connection under the lock to avoid nil dereference
-		c.connMu.Lock()
-		conn := c.conn
-		c.connMu.Unlock()
+// send makes one request on the current connection and waits for its
+// response via the pending map. sent reports whether the request was written,
+// and so may have reached the middleware even though no response arrived.
+// Safe for concurrent use.
+func (c *Client) send(method string, params []interface{}) (result json.RawMessage, sent bool, err error) {
+	// Snapshot the connection under the lock to avoid nil dereference
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+	if conn == nil {
+		return nil, false, connectionLost(fmt.Errorf("not connected"))
+	}
 
-		if conn == nil {
-			lastErr = fmt.Errorf("not connected")
-			if attempt == 0 {
-				// Try to reconnect
-				c.connMu.Lock()
-				if err := c.connect(); err != nil {
-					c.connMu.Unlock()
-					return nil, fmt.Errorf("reconnection failed: %w", err)
-				}
-				c.connMu.Unlock()
-				if err := c.Authenticate(); err != nil {
-					return nil, fmt.Errorf("re-authentication failed: %w", err)
-				}
-				continue
-			}
-			return nil, lastErr
+	id := fmt.Sprintf("%d", c.requestID.Add(1))
+
+	// Register the response channel BEFORE writing, to avoid a race where
+	// the response arrives before we add the channel to the pending map.
+	ch := make(chan *responseResult, 1)
+	c.pendingMu.Lock()
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+
+	req := APIRequest{
+		ID:     id,
+		Msg:    "method",
+		Method: method,
+		Params: params,
+	}
+
+	if logger := c.logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+		logged := req
+		if credentialMethods[method] {
+			logged.Params = []interface{}{redact.Masked}
 		}
+		reqJSON, _ := json.Marshal(logged)
+		logger.Debug("Sending request", "id", id, "method", method, "request", string(reqJSON))
+	}
 
-		id := fmt.Sprintf("%d", c.requestID.Add(1))
+	// writeMu ensures only one goroutine writes to the WebSocket at a time
+	c.writeMu.Lock()
+	err = conn.WriteJSON(req)
+	c.writeMu.Unlock()
 
-		// Register the response channel BEFORE writing, to avoid a race where
-		// the response arrives before we add the channel to the pending map.
-		ch := make(chan *responseResult, 1)
+	if err != nil {
+		// Remove our pending channel since we failed to send
 		c.pendingMu.Lock()
-		c.pending[id] = ch
+		delete(c.pending, id)
 		c.pendingMu.Unlock()
 
-		req := APIRequest{
-			ID:     id,
-			Msg:    "method",
-			Method: method,
-			Params: params,
+		// Clear the connection if it's still this one
+		c.connMu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+			c.authenticated = false
 		}
+		c.connMu.Unlock()
 
-		reqJSON, _ := json.Marshal(req)
-		log.Printf("Sending request: %s", string(reqJSON))
-
-		// writeMu ensures only one goroutine writes to the WebSocket at a time
-		c.writeMu.Lock()
-		err := conn.WriteJSON(req)
-		c.writeMu.Unlock()
+		err = fmt.Errorf("failed to send request: %w", err)
+		if isConnectionError(err) {
+			return nil, false, connectionLost(err)
+		}
+		return nil, false, err
+	}
 
-		if err != nil {
-			// Remove our pending channel since we failed to send
-			c.pendingMu.Lock()
-			delete(c.pending, id)
-			c.pendingMu.Unlock()
+	// Wait for the response router to deliver our response
+	select {
+	case result := <-ch:
+		if result.err != nil {
+			return nil, true, connectionLost(result.err)
+		}
 
-			// Clear the connection if it's still this one
-			c.connMu.Lock()
-			if c.conn == conn {
-				c.conn = nil
-				c.authenticated = false
-			}
-			c.connMu.Unlock()
+		resp := result.resp
+		c.recordInteraction(method, params, resp)
 
-			lastErr = fmt.Errorf("failed to send request: %w", err)
-			if isConnectionError(err) && attempt == 0 {
-				continue
-			}
-			return nil, lastErr
+		if resp.Error != nil && c.correlationID != "" {
+			c.logger().Warn("Request failed", "id", id, "method", method, "error", resp.Error.Message)
 		}
 
-		// Wait for the response router to deliver our response
-		select {
-		case result := <-ch:
-			if result.err != nil {
-				lastErr = result.err
-				if isConnectionError(result.err) && attempt == 0 {
-					continue
-				}
-				return nil, result.err
+		if resp.Msg == "failed" {
+			if resp.Error != nil {
+				return nil, true, &MiddlewareError{Method: method, Params: params, APIError: *resp.Error}
 			}
+			return nil, true, fmt.Errorf("API call failed with no error details")
+		}
 
-			resp := result.resp
-
-			if resp.Msg == "failed" {
-				if resp.Error != nil {
-					return nil, formatAPIErrorWithContext(resp.Error, method, params)
-				}
-				return nil, fmt.Errorf("API call failed with no error details")
-			}
+		if resp.Error != nil {
+			return nil, true, &MiddlewareError{Method: method, Params: params, APIError: *resp.Error}
+		}
 
-			if resp.Error != nil {
-				return nil, formatAPIErrorWithContext(resp.Error, method, params)
-			}
+		return resp.Result, true, nil
 
-			return resp.Result, nil
+	case <-time.After(120 * time.Second):
+		// Timeout - clean up pending entry
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+		return nil, true, timedOut(fmt.Errorf("request timed out after 120 seconds (method: %s)", method))
 
-		case <-time.After(120 * time.Second):
-			// Timeout - clean up pending entry
-			c.pendingMu.Lock()
-			delete(c.pending, id)
-			c.pendingMu.Unlock()
-			return nil, fmt.Errorf("request timed out after 120 seconds (method: %s)", method)
-		}
+	case <-c.Context().Done():
+		// The caller gave up; a late response is dropped by the read loop
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+		return nil, true, contextError(method, c.Context().Err())
 	}
-
-	return nil, lastErr
 }
 
 // isConnectionError checks if an error is a connection-related error that should trigger a retry
@@ -428,6 +547,7 @@ func (c *Client) Close() error {
 	c.connMu.Lock()
 	defer c.connMu.Unlock()
 	c.authenticated = false
+	c.closed = true
 	if c.conn != nil {
 		return c.conn.Close()
 	}
@@ -0,0 +1,88 @@
+package truenas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTimeout is matched (via errors.Is) by requests that received no response in time
+	ErrTimeout = errors.New("middleware request timed out")
+
+	// ErrConnectionLost is matched (via errors.Is) by requests that failed because the
+	// WebSocket connection could not be established or dropped mid-request
+	ErrConnectionLost = errors.New("connection to TrueNAS lost")
+
+	// ErrCancelled is matched (via errors.Is) by requests abandoned because the
+	// caller's context was cancelled
+	ErrCancelled = errors.New("middleware request cancelled")
+
+	// ErrReconnecting is matched (via errors.Is) by non-idempotent requests cut
+	// off by a dropped connection after they were sent
+	ErrReconnecting = errors.New("connection to TrueNAS lost while a request was in flight")
+)
+
+// ReconnectingError reports a non-idempotent request whose connection dropped
+// after it was sent. It is not retried automatically: the middleware may
+// already have applied it.
+type ReconnectingError struct {
+	Method string
+	Err    error
+}
+
+func (e *ReconnectingError) Error() string {
+	return fmt.Sprintf("connection to TrueNAS lost while %s was in flight and the client is reconnecting; "+
+		"the request may or may not have been applied, so check the current state before retrying: %v", e.Method, e.Err)
+}
+
+func (e *ReconnectingError) Unwrap() []error {
+	return []error{ErrReconnecting, e.Err}
+}
+
+// MiddlewareError is an error response returned by a middleware method
+type MiddlewareError struct {
+	Method string
+	Params []interface{}
+	APIError
+}
+
+func (e *MiddlewareError) Error() string {
+	return formatAPIErrorWithContext(&e.APIError, e.Method, e.Params).Error()
+}
+
+// kindError tags an underlying error with a sentinel kind while keeping its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// connectionLost marks err as a connection failure
+func connectionLost(err error) error {
+	if err == nil || errors.Is(err, ErrConnectionLost) {
+		return err
+	}
+	return &kindError{kind: ErrConnectionLost, err: err}
+}
+
+// timedOut marks err as a request timeout
+func timedOut(err error) error {
+	return &kindError{kind: ErrTimeout, err: err}
+}
+
+// contextError converts the error of a done context into a timeout or a
+// cancellation of method
+func contextError(method string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return timedOut(fmt.Errorf("deadline exceeded waiting for %s", method))
+	}
+	return &kindError{kind: ErrCancelled, err: fmt.Errorf("%s cancelled: %w", method, err)}
+}
@@ -0,0 +1,155 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Event is a collection change pushed by the middleware for an active subscription
+// (e.g., "alert.list" or "core.get_jobs")
+type Event struct {
+	Type       string                 `json:"type"` // "added", "changed", or "removed"
+	Collection string                 `json:"collection"`
+	ID         interface{}            `json:"id,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// EventHandler receives subscription events. Handlers run on the connection's read
+// loop and must not block or make client calls synchronously.
+type EventHandler func(Event)
+
+// wireMessage is any frame received from the middleware: method responses carry
+// id/result/error, subscription events carry collection/fields
+type wireMessage struct {
+	ID         json.RawMessage        `json:"id,omitempty"`
+	Msg        string                 `json:"msg"`
+	Result     json.RawMessage        `json:"result,omitempty"`
+	Error      *APIError              `json:"error,omitempty"`
+	Collection string                 `json:"collection,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// response converts a method response frame to an APIResponse
+func (m wireMessage) response() APIResponse {
+	return APIResponse{
+		ID:     m.idString(),
+		Msg:    m.Msg,
+		Result: m.Result,
+		Error:  m.Error,
+	}
+}
+
+// idString returns the frame ID as a string regardless of its JSON type
+func (m wireMessage) idString() string {
+	if len(m.ID) == 0 {
+		return ""
+	}
+	var id string
+	if err := json.Unmarshal(m.ID, &id); err == nil {
+		return id
+	}
+	return strings.Trim(string(m.ID), `"`)
+}
+
+// Subscribe registers a handler for middleware events published under name and
+// starts the subscription if it is not already active. Subscriptions are restored
+// automatically after reconnecting.
+func (c *Client) Subscribe(name string, handler EventHandler) error {
+	c.subsMu.Lock()
+	first := len(c.subs[name]) == 0
+	c.subs[name] = append(c.subs[name], handler)
+	c.subsMu.Unlock()
+
+	if !first {
+		return nil
+	}
+
+	c.connMu.Lock()
+	err := c.connect()
+	needsAuth := !c.authenticated
+	c.connMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Authenticate re-establishes every registered subscription, including this one
+	if needsAuth {
+		return c.Authenticate()
+	}
+
+	return c.sendSub(name)
+}
+
+// ConnectionGeneration returns a number that changes whenever the connection
+// drops or a new one is authenticated. Events published while disconnected
+// are not replayed, so state built from events is suspect once it changes.
+func (c *Client) ConnectionGeneration() uint64 {
+	return c.generation.Load()
+}
+
+// sendSub writes a subscription request for name on the current connection
+func (c *Client) sendSub(name string) error {
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	sub := map[string]interface{}{
+		"msg":  "sub",
+		"id":   fmt.Sprintf("sub-%d", c.requestID.Add(1)),
+		"name": name,
+	}
+
+	c.writeMu.Lock()
+	err := conn.WriteJSON(sub)
+	c.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", name, err)
+	}
+
+	slog.Info("Subscribed to events", "collection", name)
+	return nil
+}
+
+// resubscribeAll re-sends every registered subscription (called after authentication)
+func (c *Client) resubscribeAll() {
+	c.subsMu.Lock()
+	names := make([]string, 0, len(c.subs))
+	for name := range c.subs {
+		names = append(names, name)
+	}
+	c.subsMu.Unlock()
+
+	for _, name := range names {
+		if err := c.sendSub(name); err != nil {
+			slog.Warn("Restoring event subscription failed", "collection", name, "error", err)
+		}
+	}
+}
+
+// dispatchEvent delivers an event frame to the handlers registered for its collection
+func (c *Client) dispatchEvent(msg wireMessage) {
+	var id interface{}
+	if len(msg.ID) > 0 {
+		_ = json.Unmarshal(msg.ID, &id)
+	}
+
+	event := Event{
+		Type:       msg.Msg,
+		Collection: msg.Collection,
+		ID:         id,
+		Fields:     msg.Fields,
+	}
+
+	c.subsMu.Lock()
+	handlers := append([]EventHandler(nil), c.subs[msg.Collection]...)
+	c.subsMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
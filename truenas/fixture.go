@@ -0,0 +1,157 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/truenas/truenas-mcp/internal/atomicfile"
+)
+
+// Interaction is one recorded middleware method call and its response
+type Interaction struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *APIError       `json:"error,omitempty"`
+}
+
+// Fixture is an ordered set of recorded interactions that can be replayed by a
+// fake middleware (see the truenastest package)
+type Fixture struct {
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// credentialMethods take credentials as parameters, so their calls are never
+// recorded and their parameters never logged
+var credentialMethods = map[string]bool{
+	"auth.login_with_api_key": true,
+	"auth.login":              true,
+	"auth.login_with_token":   true,
+}
+
+// LoadFixture reads a fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// CanonicalParams returns params re-encoded in a canonical JSON form so that
+// recorded and live calls with equal parameters compare equal
+func CanonicalParams(params json.RawMessage) string {
+	if len(params) == 0 {
+		return "[]"
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(params, &decoded); err != nil {
+		return string(params)
+	}
+	if decoded == nil {
+		return "[]"
+	}
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		return string(params)
+	}
+	return string(canonical)
+}
+
+// Recorder captures middleware request/response pairs made through a client
+type Recorder struct {
+	mu           sync.Mutex
+	startedAt    time.Time
+	interactions []Interaction
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{startedAt: time.Now()}
+}
+
+// record appends one interaction. Credential-bearing methods are skipped.
+func (r *Recorder) record(method string, params []interface{}, resp *APIResponse) {
+	if credentialMethods[method] {
+		return
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, Interaction{
+		Method: method,
+		Params: json.RawMessage(CanonicalParams(paramsJSON)),
+		Result: resp.Result,
+		Error:  resp.Error,
+	})
+}
+
+// Len returns the number of recorded interactions
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.interactions)
+}
+
+// Fixture returns a snapshot of everything recorded so far
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{
+		RecordedAt:   r.startedAt,
+		Interactions: append([]Interaction(nil), r.interactions...),
+	}
+}
+
+// Save writes the recorded interactions to path as a fixture file
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Fixture(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// SetRecorder records every subsequent middleware call made by the client
+// (nil stops recording)
+func (c *Client) SetRecorder(r *Recorder) {
+	c.recorderMu.Lock()
+	defer c.recorderMu.Unlock()
+	c.recorder = r
+}
+
+// recordInteraction passes a completed call to the recorder, if any
+func (c *Client) recordInteraction(method string, params []interface{}, resp *APIResponse) {
+	c.recorderMu.Lock()
+	r := c.recorder
+	c.recorderMu.Unlock()
+	if r != nil {
+		r.record(method, params, resp)
+	}
+}
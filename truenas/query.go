@@ -0,0 +1,14 @@
+package truenas
+
+// WithQueryOptions copies method-specific query options (such as extra) and
+// adds the given query-options to the copy
+func WithQueryOptions(options map[string]interface{}, add map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(options)+len(add))
+	for k, v := range options {
+		merged[k] = v
+	}
+	for k, v := range add {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,184 @@
+package truenas
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reconnection
+//
+// When the connection drops (middleware restart, reboot, network blip), a
+// background loop re-establishes it with exponential backoff and restores
+// event subscriptions. Calls made meanwhile wait for it, up to MaxWait.
+// Calls cut off by the drop are retried only when the method is idempotent;
+// others fail with a ReconnectingError since they may already have run.
+
+// ReconnectPolicy controls how a dropped connection is re-established
+type ReconnectPolicy struct {
+	// InitialBackoff is the delay after the first failed attempt; it doubles
+	// after each further failure up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxWait bounds how long a call waits for the connection to return
+	MaxWait time.Duration
+}
+
+// DefaultReconnectPolicy rides out a middleware restart or a short outage
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     15 * time.Second,
+	MaxWait:        90 * time.Second,
+}
+
+// maxCallAttempts is how many times an idempotent call is sent before its
+// connection error is returned
+const maxCallAttempts = 3
+
+// SetReconnectPolicy replaces the policy used after the connection drops
+func (c *Client) SetReconnectPolicy(policy ReconnectPolicy) {
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+	c.policy = policy
+}
+
+func (c *Client) reconnectPolicy() ReconnectPolicy {
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+	return c.policy
+}
+
+// ensureReady connects and authenticates if needed. The first connection
+// is attempted once so configuration errors surface immediately; after a
+// drop the connection is retried with backoff.
+func (c *Client) ensureReady() error {
+	c.connMu.Lock()
+	ready := c.conn != nil && c.authenticated
+	c.connMu.Unlock()
+	if ready {
+		return nil
+	}
+	if !c.established.Load() {
+		return c.tryReconnect()
+	}
+	return c.reconnect(c.reconnectPolicy().MaxWait)
+}
+
+// tryReconnect makes one attempt to connect and authenticate. It is a no-op
+// when another caller has already restored the connection.
+func (c *Client) tryReconnect() error {
+	c.attemptMu.Lock()
+	defer c.attemptMu.Unlock()
+
+	c.connMu.Lock()
+	err := c.connect()
+	ready := c.authenticated
+	c.connMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if ready {
+		return nil
+	}
+	if err := c.Authenticate(); err != nil {
+		return fmt.Errorf("re-authentication failed: %w", err)
+	}
+	return nil
+}
+
+// reconnect retries tryReconnect with exponential backoff while the failure
+// is a connection error, for up to wait (0 = until the client is closed)
+func (c *Client) reconnect(wait time.Duration) error {
+	policy := c.reconnectPolicy()
+	start := time.Now()
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if wait == 0 && c.isClosed() {
+			return nil
+		}
+		err := c.tryReconnect()
+		if err == nil {
+			if attempt > 1 {
+				c.logger().Info("Reconnected to TrueNAS", "attempts", attempt)
+			}
+			return nil
+		}
+		if !errors.Is(err, ErrConnectionLost) {
+			return err
+		}
+		if wait > 0 && time.Since(start)+backoff > wait {
+			return connectionLost(fmt.Errorf("gave up reconnecting after %s: %w", time.Since(start).Round(time.Second), err))
+		}
+
+		c.logger().Warn("Reconnect attempt failed", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-c.Context().Done():
+			return contextError("reconnect", c.Context().Err())
+		}
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// reconnectInBackground restores a dropped connection so subscriptions
+// resume without waiting for the next call. At most one loop runs at a time.
+func (c *Client) reconnectInBackground() {
+	if !c.established.Load() || c.isClosed() || !c.reconnecting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.reconnecting.Store(false)
+		c.logger().Warn("Connection to TrueNAS lost, reconnecting")
+		if err := c.reconnect(0); err != nil && !c.isClosed() {
+			c.logger().Error("Reconnecting to TrueNAS failed", "error", err)
+		}
+	}()
+}
+
+func (c *Client) isClosed() bool {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.closed
+}
+
+// idempotentMethods lists read-only methods not covered by the naming
+// patterns in isIdempotent
+var idempotentMethods = map[string]bool{
+	"auth.login_with_api_key":        true,
+	"app.available":                  true,
+	"app.rollback_versions":          true,
+	"app.upgrade_summary":            true,
+	"cloudsync.list_buckets":         true,
+	"core.ping":                      true,
+	"disk.smart_attributes":          true,
+	"disk.temperatures":              true,
+	"filesystem.acltemplate.by_path": true,
+	"filesystem.getacl":              true,
+	"filesystem.listdir":             true,
+	"filesystem.stat":                true,
+	"pool.is_upgraded":               true,
+	"replication.list_datasets":      true,
+	"reporting.graphs":               true,
+	"smart.test.results":             true,
+	"system.coredumps":               true,
+	"update.available_versions":      true,
+}
+
+// isIdempotent reports whether a method can safely be sent again when it is
+// unknown whether the first request reached the middleware
+func isIdempotent(method string) bool {
+	if idempotentMethods[method] {
+		return true
+	}
+	name := method[strings.LastIndex(method, ".")+1:]
+	switch name {
+	case "query", "config", "info", "status", "list":
+		return true
+	}
+	return strings.HasPrefix(name, "get_") || strings.HasSuffix(name, "_choices")
+}
@@ -0,0 +1,137 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// File transfers
+//
+// Methods that read or write file contents (filesystem.get, filesystem.put)
+// stream them over HTTP instead of the WebSocket: downloads are started with
+// core.download and fetched from the returned URL, uploads are posted to
+// /_upload together with the method call.
+
+// httpBaseURL returns the https:// origin of the middleware endpoint
+func (c *Client) httpBaseURL() (string, error) {
+	urls, err := c.buildConnectionURLs()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(urls[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %s: %w", urls[0], err)
+	}
+	return "https://" + u.Host, nil
+}
+
+// httpClient returns an HTTP client using the connection's TLS settings. It
+// has no overall timeout; transfers are bounded by the caller.
+func (c *Client) httpClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: c.tlsConfig}}
+}
+
+// Download runs a method that produces a file (e.g., filesystem.get) and
+// returns its job ID and the file contents, which the caller must close
+func (c *Client) Download(method string, params []interface{}, filename string) (int, io.ReadCloser, error) {
+	result, err := c.Call("core.download", method, params, filename)
+	if err != nil {
+		return 0, nil, err
+	}
+	var started []json.RawMessage
+	if err := json.Unmarshal(result, &started); err != nil || len(started) != 2 {
+		return 0, nil, fmt.Errorf("unexpected core.download result: %s", string(result))
+	}
+	var jobID int
+	var path string
+	if err := json.Unmarshal(started[0], &jobID); err != nil {
+		return 0, nil, fmt.Errorf("unexpected download job ID: %s", string(started[0]))
+	}
+	if err := json.Unmarshal(started[1], &path); err != nil {
+		return 0, nil, fmt.Errorf("unexpected download URL: %s", string(started[1]))
+	}
+
+	base, err := c.httpBaseURL()
+	if err != nil {
+		return 0, nil, err
+	}
+	c.logger().Info("Downloading job output", "method", method, "job_id", jobID)
+	req, err := http.NewRequestWithContext(c.Context(), http.MethodGet, base+path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("download failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return 0, nil, fmt.Errorf("download failed: HTTP %s", resp.Status)
+	}
+	return jobID, resp.Body, nil
+}
+
+// Upload runs a method that consumes a file (e.g., filesystem.put) with body
+// as its contents and returns the job ID
+func (c *Client) Upload(method string, params []interface{}, body io.Reader) (int, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	data, err := json.Marshal(map[string]interface{}{"method": method, "params": params})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode upload call: %w", err)
+	}
+	base, err := c.httpBaseURL()
+	if err != nil {
+		return 0, err
+	}
+
+	// Stream the multipart form so the file is never held in memory
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		err := form.WriteField("data", string(data))
+		if err == nil {
+			var part io.Writer
+			if part, err = form.CreateFormFile("file", "file"); err == nil {
+				_, err = io.Copy(part, body)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(c.Context(), http.MethodPost, base+"/_upload", pr)
+	if err != nil {
+		pr.Close()
+		return 0, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	c.credentials.authorize(req)
+
+	c.logger().Info("Uploading job input", "method", method)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		pr.Close()
+		return 0, fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("upload failed: HTTP %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	var started struct {
+		JobID int `json:"job_id"`
+	}
+	if err := json.Unmarshal(reply, &started); err != nil {
+		return 0, fmt.Errorf("unexpected upload response: %s", strings.TrimSpace(string(reply)))
+	}
+	return started.JobID, nil
+}
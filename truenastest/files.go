@@ -0,0 +1,112 @@
+package truenastest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Simulated file transfers: filesystem.get through core.download and
+// /_download, filesystem.put through /_upload, both backed by an in-memory
+// file table
+
+// SetFile stores a file served by filesystem.get downloads
+func (s *Server) SetFile(path string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = content
+}
+
+// File returns a file stored with SetFile or written by a filesystem.put upload
+func (s *Server) File(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.files[path]
+	return content, ok
+}
+
+// startDownload answers core.download for filesystem.get with a job ID and
+// the URL the file is served from
+func (s *Server) startDownload(params []interface{}) (interface{}, error) {
+	method, _ := firstString(params)
+	var args []interface{}
+	if len(params) > 1 {
+		args, _ = params[1].([]interface{})
+	}
+	path, _ := firstString(args)
+	if method != "filesystem.get" {
+		return nil, &Error{Code: 22, Message: fmt.Sprintf("Downloads of %s are not simulated", method)}
+	}
+
+	s.mu.Lock()
+	_, ok := s.files[path]
+	s.mu.Unlock()
+	if !ok {
+		return nil, &Error{Code: 2, Message: fmt.Sprintf("%s: not found", path), ErrName: "ENOENT"}
+	}
+	id := s.jobs.add(method, args, JobSpec{})
+
+	s.mu.Lock()
+	s.downloads[id] = path
+	s.mu.Unlock()
+	return []interface{}{id, fmt.Sprintf("/_download/%d?auth_token=truenastest", id)}, nil
+}
+
+// serveDownload sends the file of a started download
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/_download/"))
+	s.mu.Lock()
+	path, ok := s.downloads[id]
+	content := s.files[path]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(content)
+}
+
+// serveUpload runs a filesystem.put upload, recording it as a call
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var call struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(r.FormValue("data")), &call); err != nil {
+		http.Error(w, "invalid data field", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: call.Method, Params: call.Params})
+	s.mu.Unlock()
+	path, _ := firstString(call.Params)
+	if call.Method != "filesystem.put" || path == "" {
+		http.Error(w, fmt.Sprintf("uploads to %s are not simulated", call.Method), http.StatusBadRequest)
+		return
+	}
+	s.SetFile(path, content)
+
+	id := s.jobs.add(call.Method, call.Params, JobSpec{Result: true})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"job_id": id})
+}
@@ -0,0 +1,241 @@
+package truenastest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobSpec scripts how a simulated middleware job progresses
+type JobSpec struct {
+	// Steps is how many core.get_jobs lookups report the job as RUNNING before it
+	// finishes (0 = finished immediately)
+	Steps int
+
+	// Result is the job result on success
+	Result interface{}
+
+	// Error makes the job finish in the FAILED state with this message
+	Error string
+}
+
+// job is a simulated middleware job
+type job struct {
+	id        int
+	method    string
+	arguments []interface{}
+	spec      JobSpec
+	started   time.Time
+	polls     int
+	aborted   bool
+}
+
+// jobTable stores simulated jobs served by the default core.get_jobs handler
+type jobTable struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   []*job
+}
+
+func newJobTable() *jobTable {
+	return &jobTable{nextID: 1}
+}
+
+// HandleJob registers method as a job-based middleware call: each call creates a
+// simulated job following spec and returns its job ID, as the middleware does.
+func (s *Server) HandleJob(method string, spec JobSpec) {
+	s.Handle(method, func(params []interface{}) (interface{}, error) {
+		return s.jobs.add(method, params, spec), nil
+	})
+}
+
+// AddJob creates a simulated job directly, e.g. to represent work started outside
+// the code under test, and returns its ID
+func (s *Server) AddJob(method string, arguments []interface{}, spec JobSpec) int {
+	return s.jobs.add(method, arguments, spec)
+}
+
+// AbortJob marks a simulated job as ABORTED
+func (s *Server) AbortJob(id int) {
+	s.jobs.abort(id)
+}
+
+func (t *jobTable) add(method string, arguments []interface{}, spec JobSpec) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if arguments == nil {
+		arguments = []interface{}{}
+	}
+	j := &job{id: t.nextID, method: method, arguments: arguments, spec: spec, started: time.Now()}
+	t.nextID++
+	t.jobs = append(t.jobs, j)
+	return j.id
+}
+
+func (t *jobTable) abort(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, j := range t.jobs {
+		if j.id == id {
+			j.aborted = true
+		}
+	}
+}
+
+// query answers core.get_jobs with query-filters support. Each returned job counts
+// as one poll toward its completion.
+func (t *jobTable) query(params []interface{}) []map[string]interface{} {
+	var filters []interface{}
+	if len(params) > 0 {
+		filters, _ = params[0].([]interface{})
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	results := []map[string]interface{}{}
+	for _, j := range t.jobs {
+		record := j.record()
+		if !matchFilters(record, filters) {
+			continue
+		}
+		j.polls++
+		results = append(results, record)
+	}
+	return results
+}
+
+// record renders a job as core.get_jobs returns it
+func (j *job) record() map[string]interface{} {
+	record := map[string]interface{}{
+		"id":        j.id,
+		"method":    j.method,
+		"arguments": j.arguments,
+		"result":    nil,
+		"error":     nil,
+		"time_started": map[string]interface{}{
+			"$date": float64(j.started.UnixMilli()),
+		},
+	}
+
+	switch {
+	case j.aborted:
+		record["state"] = "ABORTED"
+	case j.polls < j.spec.Steps:
+		record["state"] = "RUNNING"
+		record["progress"] = map[string]interface{}{
+			"percent":     float64(j.polls) * 100 / float64(j.spec.Steps),
+			"description": fmt.Sprintf("Step %d of %d", j.polls+1, j.spec.Steps),
+		}
+	case j.spec.Error != "":
+		record["state"] = "FAILED"
+		record["error"] = j.spec.Error
+	default:
+		record["state"] = "SUCCESS"
+		record["result"] = j.spec.Result
+		record["progress"] = map[string]interface{}{"percent": float64(100), "description": ""}
+	}
+
+	return record
+}
+
+// matchFilters applies middleware query-filters ([field, op, value] triples) to a
+// record. Supported operators: =, !=, <, <=, >, >=, in, nin, ^, $, ~. Ordering
+// operators compare numbers and {"$date": ms} timestamps, ~ anchors its regular
+// expression at the start like Python's re.match, and dotted fields such as
+// status.state reach into nested objects. Unknown operators match.
+func matchFilters(record map[string]interface{}, filters []interface{}) bool {
+	for _, f := range filters {
+		filter, ok := f.([]interface{})
+		if !ok || len(filter) != 3 {
+			continue
+		}
+		field, _ := filter[0].(string)
+		op, _ := filter[1].(string)
+		value := filter[2]
+		actual := fieldValue(record, field)
+
+		switch op {
+		case "=":
+			if !looseEqual(actual, value) {
+				return false
+			}
+		case "!=":
+			if looseEqual(actual, value) {
+				return false
+			}
+		case "<", "<=", ">", ">=":
+			a, aOk := toFloat(actual)
+			v, vOk := toFloat(value)
+			if !aOk || !vOk {
+				return false
+			}
+			if (op == "<" && !(a < v)) || (op == "<=" && !(a <= v)) ||
+				(op == ">" && !(a > v)) || (op == ">=" && !(a >= v)) {
+				return false
+			}
+		case "in", "nin":
+			found := false
+			if list, ok := value.([]interface{}); ok {
+				for _, v := range list {
+					if looseEqual(actual, v) {
+						found = true
+						break
+					}
+				}
+			}
+			if found != (op == "in") {
+				return false
+			}
+		case "^", "$":
+			a, _ := actual.(string)
+			v, _ := value.(string)
+			if op == "^" && !strings.HasPrefix(a, v) {
+				return false
+			}
+			if op == "$" && !strings.HasSuffix(a, v) {
+				return false
+			}
+		case "~":
+			a, aOk := actual.(string)
+			v, _ := value.(string)
+			re, err := regexp.Compile(`^(?:` + v + `)`)
+			if !aOk || err != nil || !re.MatchString(a) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// looseEqual compares values decoded from JSON against native Go values, treating
+// all numbers as float64
+func looseEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return fa == fb
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case map[string]interface{}:
+		// Middleware timestamps: {"$date": milliseconds}
+		if date, ok := n["$date"]; ok && len(n) == 1 {
+			return toFloat(date)
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,255 @@
+package truenastest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Mock is a truenas.Caller with scripted responses, for unit testing tool
+// handlers without a connection. Methods are scripted as on Server (Handle,
+// SetResult, SetRecords, SetError, HandleJob), and Respond queues one-off
+// responses ahead of them. Parameters and results go through JSON, so
+// handlers see numbers as float64 just as over a real connection.
+type Mock struct {
+	*mockState
+	correlationID string
+	ctx           context.Context
+}
+
+// mockState is shared by a Mock and the handles derived from it
+type mockState struct {
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	queued   map[string][]mockResponse
+	calls    []Call
+	uploads  map[string][][]byte
+	jobs     *jobTable
+}
+
+// mockResponse is a one-off response queued with Respond
+type mockResponse struct {
+	result interface{}
+	err    error
+}
+
+var _ truenas.Caller = (*Mock)(nil)
+
+// NewMock returns a Mock with no methods scripted. Like Server, it answers
+// core.get_jobs from its simulated jobs and fails any other unscripted method.
+func NewMock() *Mock {
+	return &Mock{mockState: &mockState{
+		handlers: make(map[string]HandlerFunc),
+		queued:   make(map[string][]mockResponse),
+		uploads:  make(map[string][][]byte),
+		jobs:     newJobTable(),
+	}}
+}
+
+// Handle registers a handler for a middleware method, replacing any existing one
+func (m *Mock) Handle(method string, handler HandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[method] = handler
+}
+
+// SetResult makes method always return result
+func (m *Mock) SetResult(method string, result interface{}) {
+	m.Handle(method, func(params []interface{}) (interface{}, error) {
+		return result, nil
+	})
+}
+
+// SetRecords makes a query-style method return records, applying query-filters
+// and query-options as Server.SetRecords does
+func (m *Mock) SetRecords(method string, records []map[string]interface{}) {
+	m.Handle(method, recordsHandler(records))
+}
+
+// SetError makes method always fail with the given middleware error
+func (m *Mock) SetError(method string, code int, message string) {
+	m.Handle(method, func(params []interface{}) (interface{}, error) {
+		return nil, &Error{Code: code, Message: message}
+	})
+}
+
+// HandleJob registers method as a job-based call: each call creates a
+// simulated job following spec and returns its job ID
+func (m *Mock) HandleJob(method string, spec JobSpec) {
+	m.Handle(method, func(params []interface{}) (interface{}, error) {
+		return m.jobs.add(method, params, spec), nil
+	})
+}
+
+// Respond queues a response for the next call of method, ahead of its
+// handler. Queued responses are used once each, in order; err may be an
+// *Error for a middleware error response.
+func (m *Mock) Respond(method string, result interface{}, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queued[method] = append(m.queued[method], mockResponse{result: result, err: err})
+}
+
+// Calls returns every call made for method, or all calls if method is empty
+func (m *Mock) Calls(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range m.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Uploads returns the contents passed to Upload for method, in call order
+func (m *Mock) Uploads(method string) [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.uploads[method]...)
+}
+
+// Call runs the scripted response for method
+func (m *Mock) Call(method string, params ...interface{}) (json.RawMessage, error) {
+	if err := m.Context().Err(); err != nil {
+		kind := truenas.ErrCancelled
+		if errors.Is(err, context.DeadlineExceeded) {
+			kind = truenas.ErrTimeout
+		}
+		return nil, fmt.Errorf("%w (method: %s): %w", kind, method, err)
+	}
+
+	// Parameters arrive as the middleware would decode them
+	var decoded []interface{}
+	if data, err := json.Marshal(params); err != nil {
+		return nil, fmt.Errorf("failed to encode %s params: %w", method, err)
+	} else if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: method, Params: decoded})
+	var respond HandlerFunc
+	if queued := m.queued[method]; len(queued) > 0 {
+		next := queued[0]
+		m.queued[method] = queued[1:]
+		respond = func([]interface{}) (interface{}, error) { return next.result, next.err }
+	} else if handler, ok := m.handlers[method]; ok {
+		respond = handler
+	}
+	m.mu.Unlock()
+
+	if respond == nil {
+		respond = m.defaultHandler(method)
+	}
+	result, err := respond(decoded)
+	if err != nil {
+		apiErr, ok := err.(*Error)
+		if !ok {
+			apiErr = &Error{Code: 1, Message: err.Error()}
+		}
+		return nil, &truenas.MiddlewareError{Method: method, Params: params, APIError: truenas.APIError{
+			Code: apiErr.Code, Message: apiErr.Message, ErrName: apiErr.ErrName,
+		}}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s result: %w", method, err)
+	}
+	return data, nil
+}
+
+// defaultHandler answers methods with no script: job lookups and aborts from
+// the simulated jobs, and an error for anything else
+func (m *Mock) defaultHandler(method string) HandlerFunc {
+	return func(params []interface{}) (interface{}, error) {
+		switch method {
+		case "core.get_jobs":
+			return m.jobs.query(params), nil
+		case "core.job_abort":
+			if len(params) > 0 {
+				if id, ok := toFloat(params[0]); ok {
+					m.jobs.abort(int(id))
+					return nil, nil
+				}
+			}
+		}
+		return nil, &Error{Code: 22, Message: fmt.Sprintf("Method %s not found", method)}
+	}
+}
+
+// CallConcurrently runs the calls one after another, so scripted responses
+// are consumed in a predictable order
+func (m *Mock) CallConcurrently(calls ...truenas.BatchCall) []truenas.BatchResult {
+	results := make([]truenas.BatchResult, len(calls))
+	for i, call := range calls {
+		results[i].Result, results[i].Err = m.Call(call.Method, call.Params...)
+	}
+	return results
+}
+
+// Download calls method's handler, whose result (a string or []byte) is the
+// file contents
+func (m *Mock) Download(method string, params []interface{}, filename string) (int, io.ReadCloser, error) {
+	result, err := m.Call(method, params...)
+	if err != nil {
+		return 0, nil, err
+	}
+	var content []byte
+	var text string
+	if err := json.Unmarshal(result, &text); err == nil {
+		content = []byte(text)
+	} else if err := json.Unmarshal(result, &content); err != nil {
+		return 0, nil, fmt.Errorf("%s result is not file contents: %s", method, string(result))
+	}
+	id := m.jobs.add(method, params, JobSpec{})
+	return id, io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Upload records body (see Uploads) and calls method's handler
+func (m *Mock) Upload(method string, params []interface{}, body io.Reader) (int, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.uploads[method] = append(m.uploads[method], content)
+	m.mu.Unlock()
+
+	if _, err := m.Call(method, params...); err != nil {
+		return 0, err
+	}
+	return m.jobs.add(method, params, JobSpec{}), nil
+}
+
+// WithCorrelationID returns a handle on the same script tagged with id
+func (m *Mock) WithCorrelationID(id string) truenas.Caller {
+	return &Mock{mockState: m.mockState, correlationID: id, ctx: m.ctx}
+}
+
+// WithContext returns a handle on the same script whose calls fail once ctx
+// is done
+func (m *Mock) WithContext(ctx context.Context) truenas.Caller {
+	return &Mock{mockState: m.mockState, correlationID: m.correlationID, ctx: ctx}
+}
+
+// Context returns the context set by WithContext, or context.Background()
+func (m *Mock) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// CorrelationID returns the ID set by WithCorrelationID
+func (m *Mock) CorrelationID() string {
+	return m.correlationID
+}
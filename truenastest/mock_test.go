@@ -0,0 +1,102 @@
+package truenastest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+func TestMockScriptedResponses(t *testing.T) {
+	mock := NewMock()
+	mock.SetRecords("pool.query", []map[string]interface{}{
+		{"name": "tank", "size": float64(100)},
+		{"name": "backup", "size": float64(50)},
+	})
+	mock.Respond("pool.query", []interface{}{}, nil)
+
+	// Queued responses come first, then the handler
+	if result, err := mock.Call("pool.query"); err != nil || string(result) != "[]" {
+		t.Errorf("first call = %s, %v; want the queued empty list", result, err)
+	}
+	result, err := mock.Call("pool.query", []interface{}{[]interface{}{"name", "=", "tank"}})
+	if err != nil {
+		t.Fatalf("pool.query failed: %v", err)
+	}
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil || len(pools) != 1 || pools[0]["name"] != "tank" {
+		t.Errorf("filtered pools = %s", result)
+	}
+
+	calls := mock.Calls("pool.query")
+	if len(calls) != 2 || len(calls[1].Params) != 1 {
+		t.Errorf("calls = %v", calls)
+	}
+
+	// Derived handles share the script
+	tagged := mock.WithCorrelationID("corr-1")
+	if tagged.CorrelationID() != "corr-1" || len(mock.Calls("")) != 2 {
+		t.Errorf("tagged handle = %q", tagged.CorrelationID())
+	}
+	tagged.Call("pool.query")
+	if len(mock.Calls("pool.query")) != 3 {
+		t.Error("call through a derived handle was not recorded")
+	}
+}
+
+func TestMockErrors(t *testing.T) {
+	mock := NewMock()
+	mock.Handle("pool.dataset.create", func(params []interface{}) (interface{}, error) {
+		return nil, &Error{Code: 17, Message: "dataset exists", ErrName: "EEXIST"}
+	})
+
+	_, err := mock.Call("pool.dataset.create", map[string]interface{}{"name": "tank/a"})
+	var mwErr *truenas.MiddlewareError
+	if !errors.As(err, &mwErr) || mwErr.ErrName != "EEXIST" || mwErr.Method != "pool.dataset.create" {
+		t.Errorf("error = %#v, want a MiddlewareError with EEXIST", err)
+	}
+
+	if _, err := mock.Call("system.reboot"); !errors.As(err, &mwErr) || !strings.Contains(mwErr.Message, "not found") {
+		t.Errorf("unscripted method error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mock.WithContext(ctx).Call("pool.query"); !errors.Is(err, truenas.ErrCancelled) {
+		t.Errorf("cancelled call error = %v, want ErrCancelled", err)
+	}
+}
+
+func TestMockJobsAndTransfers(t *testing.T) {
+	mock := NewMock()
+	mock.HandleJob("pool.scrub.run", JobSpec{Result: "done"})
+	result, err := mock.Call("pool.scrub.run", "tank", "START")
+	if err != nil {
+		t.Fatalf("pool.scrub.run failed: %v", err)
+	}
+	jobs, _ := mock.Call("core.get_jobs", []interface{}{[]interface{}{"id", "=", json.Number(result)}})
+	if !strings.Contains(string(jobs), `"SUCCESS"`) {
+		t.Errorf("core.get_jobs = %s", jobs)
+	}
+
+	mock.SetResult("filesystem.get", "file contents")
+	_, body, err := mock.Download("filesystem.get", []interface{}{"/mnt/tank/a"}, "a")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if content, _ := io.ReadAll(body); string(content) != "file contents" {
+		t.Errorf("downloaded %q", content)
+	}
+
+	mock.SetResult("filesystem.put", nil)
+	if _, err := mock.Upload("filesystem.put", []interface{}{"/mnt/tank/b"}, strings.NewReader("new")); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if uploads := mock.Uploads("filesystem.put"); len(uploads) != 1 || string(uploads[0]) != "new" {
+		t.Errorf("uploads = %q", uploads)
+	}
+}
@@ -0,0 +1,99 @@
+package truenastest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// applyQueryOptions applies middleware query-options to records that already
+// passed the query-filters: order_by (a "-" prefix sorts descending), offset,
+// limit (0 = no limit), select (top-level fields to return), and count, which
+// returns the number of records instead
+func applyQueryOptions(records []map[string]interface{}, options map[string]interface{}) interface{} {
+	if count, _ := options["count"].(bool); count {
+		return len(records)
+	}
+
+	if orderBy, ok := options["order_by"].([]interface{}); ok && len(orderBy) > 0 {
+		sort.SliceStable(records, func(i, j int) bool {
+			for _, raw := range orderBy {
+				key, _ := raw.(string)
+				key = strings.TrimPrefix(strings.TrimPrefix(key, "nulls_first:"), "nulls_last:")
+				descending := strings.HasPrefix(key, "-")
+				key = strings.TrimPrefix(key, "-")
+				if c := compareValues(fieldValue(records[i], key), fieldValue(records[j], key)); c != 0 {
+					return (c < 0) != descending
+				}
+			}
+			return false
+		})
+	}
+
+	if offset, ok := toFloat(options["offset"]); ok && offset > 0 {
+		if int(offset) >= len(records) {
+			return []map[string]interface{}{}
+		}
+		records = records[int(offset):]
+	}
+	if limit, ok := toFloat(options["limit"]); ok && limit > 0 && int(limit) < len(records) {
+		records = records[:int(limit)]
+	}
+	if fields, ok := options["select"].([]interface{}); ok && len(fields) > 0 {
+		selected := make([]map[string]interface{}, 0, len(records))
+		for _, record := range records {
+			projected := map[string]interface{}{}
+			for _, raw := range fields {
+				if field, _ := raw.(string); field != "" {
+					if value, exists := record[field]; exists {
+						projected[field] = value
+					}
+				}
+			}
+			selected = append(selected, projected)
+		}
+		records = selected
+	}
+	return records
+}
+
+// fieldValue looks up a query field in a record, following dotted paths such
+// as used.parsed into nested objects
+func fieldValue(record map[string]interface{}, field string) interface{} {
+	var value interface{} = record
+	for _, part := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// compareValues orders two field values: numbers and timestamps numerically,
+// anything else by its string form, with missing values first
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
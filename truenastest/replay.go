@@ -0,0 +1,93 @@
+package truenastest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// replayer serves recorded interactions keyed by method and canonical params.
+// Repeated calls with the same key are answered in recorded order; once exhausted
+// the last response is repeated.
+type replayer struct {
+	mu      sync.Mutex
+	byKey   map[string][]truenas.Interaction
+	served  map[string]int
+	methods map[string]bool
+}
+
+// Replay serves the interactions in fixture for every method it contains. Calls
+// to a recorded method with parameters that were never recorded fail with a
+// descriptive error so regressions in request construction are caught.
+func (s *Server) Replay(fixture *truenas.Fixture) {
+	r := &replayer{
+		byKey:   make(map[string][]truenas.Interaction),
+		served:  make(map[string]int),
+		methods: make(map[string]bool),
+	}
+	for _, interaction := range fixture.Interactions {
+		key := replayKey(interaction.Method, interaction.Params)
+		r.byKey[key] = append(r.byKey[key], interaction)
+		r.methods[interaction.Method] = true
+	}
+
+	for method := range r.methods {
+		method := method
+		s.Handle(method, func(params []interface{}) (interface{}, error) {
+			return r.serve(method, params)
+		})
+	}
+}
+
+// ReplayFile loads a fixture file and replays it, failing the test if the file
+// cannot be read
+func (s *Server) ReplayFile(t testing.TB, path string) {
+	t.Helper()
+
+	fixture, err := truenas.LoadFixture(path)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	s.Replay(fixture)
+}
+
+func (r *replayer) serve(method string, params []interface{}) (interface{}, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	key := replayKey(method, paramsJSON)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recorded := r.byKey[key]
+	if len(recorded) == 0 {
+		return nil, &Error{
+			Code:    22,
+			Message: fmt.Sprintf("no recorded response for %s with params %s", method, truenas.CanonicalParams(paramsJSON)),
+		}
+	}
+
+	i := r.served[key]
+	if i >= len(recorded) {
+		i = len(recorded) - 1
+	}
+	r.served[key]++
+
+	interaction := recorded[i]
+	if interaction.Error != nil {
+		return nil, &Error{Code: interaction.Error.Code, Message: interaction.Error.Message, ErrName: interaction.Error.ErrName}
+	}
+	return interaction.Result, nil
+}
+
+func replayKey(method string, params json.RawMessage) string {
+	return method + " " + truenas.CanonicalParams(params)
+}
@@ -0,0 +1,408 @@
+// Package truenastest provides a fake TrueNAS middleware WebSocket endpoint for
+// exercising the client and tool handlers end-to-end without a real NAS.
+package truenastest
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+// Credentials accepted by a new Server
+const (
+	DefaultAPIKey   = "test-api-key"
+	DefaultUsername = "admin"
+	DefaultPassword = "test-password"
+	DefaultToken    = "test-token"
+)
+
+// HandlerFunc answers a middleware method call. A returned *Error is sent as a
+// middleware error response; any other error is sent with code 1.
+type HandlerFunc func(params []interface{}) (interface{}, error)
+
+// Error is a middleware error response
+type Error struct {
+	Code    int
+	Message string
+	ErrName string // optional errno name, e.g. "ENOENT"
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Call records one method invocation received by the server
+type Call struct {
+	Method string
+	Params []interface{}
+}
+
+// Server is a scriptable fake of the TrueNAS middleware WebSocket API. It speaks
+// the DDP-style protocol used by the client (connect, method, sub) over TLS.
+type Server struct {
+	// APIKey is the key accepted by auth.login_with_api_key
+	APIKey string
+
+	// Username and Password are accepted by auth.login, which also requires
+	// OTP as the one-time password when it is set
+	Username string
+	Password string
+	OTP      string
+
+	// Token is the session token accepted by auth.login_with_token
+	Token string
+
+	httpServer *httptest.Server
+	upgrader   websocket.Upgrader
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	calls    []Call
+	jobs     *jobTable
+	conns    map[*serverConn]bool
+
+	// files backs filesystem.get downloads and filesystem.put uploads
+	files     map[string][]byte
+	downloads map[int]string
+}
+
+// serverConn is one client connection and its active subscriptions
+type serverConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	subs    map[string]bool
+}
+
+// NewServer starts a fake middleware server. It is closed automatically when the
+// test completes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		APIKey:    DefaultAPIKey,
+		Username:  DefaultUsername,
+		Password:  DefaultPassword,
+		Token:     DefaultToken,
+		handlers:  make(map[string]HandlerFunc),
+		jobs:      newJobTable(),
+		conns:     make(map[*serverConn]bool),
+		files:     make(map[string][]byte),
+		downloads: make(map[int]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_download/", s.serveDownload)
+	mux.HandleFunc("/_upload", s.serveUpload)
+	mux.HandleFunc("/", s.serveWebSocket)
+	s.httpServer = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// URL returns the wss:// endpoint of the server
+func (s *Server) URL() string {
+	return "wss" + strings.TrimPrefix(s.httpServer.URL, "https") + "/websocket"
+}
+
+// TLSConfig returns a client TLS configuration that trusts the server certificate
+func (s *Server) TLSConfig() *tls.Config {
+	transport := s.httpServer.Client().Transport.(*http.Transport)
+	return transport.TLSClientConfig.Clone()
+}
+
+// Client returns a client connected to the server using its API key. The client
+// is closed when the test completes.
+func (s *Server) Client(t testing.TB) *truenas.Client {
+	t.Helper()
+
+	client, err := truenas.NewClient(s.URL(), s.APIKey, s.TLSConfig())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// Close disconnects all clients and stops the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.ws.Close()
+	}
+	s.mu.Unlock()
+	s.httpServer.Close()
+}
+
+// DropConnections closes every client connection without stopping the server,
+// as a middleware restart or network failure would
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.ws.Close()
+	}
+}
+
+// Handle registers a handler for a middleware method, replacing any existing one
+func (s *Server) Handle(method string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// SetResult makes method always return result
+func (s *Server) SetResult(method string, result interface{}) {
+	s.Handle(method, func(params []interface{}) (interface{}, error) {
+		return result, nil
+	})
+}
+
+// SetRecords makes a query-style method (e.g. pool.query) return records, applying
+// any query-filters passed as the first parameter and the order_by, offset,
+// limit, and count query-options passed as the second
+func (s *Server) SetRecords(method string, records []map[string]interface{}) {
+	s.Handle(method, recordsHandler(records))
+}
+
+// recordsHandler answers a query-style method from records
+func recordsHandler(records []map[string]interface{}) HandlerFunc {
+	return func(params []interface{}) (interface{}, error) {
+		var filters []interface{}
+		if len(params) > 0 {
+			filters, _ = params[0].([]interface{})
+		}
+		var options map[string]interface{}
+		if len(params) > 1 {
+			options, _ = params[1].(map[string]interface{})
+		}
+		matched := []map[string]interface{}{}
+		for _, record := range records {
+			if matchFilters(record, filters) {
+				matched = append(matched, record)
+			}
+		}
+		return applyQueryOptions(matched, options), nil
+	}
+}
+
+// SetError makes method always fail with the given middleware error
+func (s *Server) SetError(method string, code int, message string) {
+	s.Handle(method, func(params []interface{}) (interface{}, error) {
+		return nil, &Error{Code: code, Message: message}
+	})
+}
+
+// Calls returns every call received for method, or all calls if method is empty.
+// Authentication and job polling calls are included.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range s.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Publish sends a collection event ("added", "changed", or "removed") to every
+// client subscribed to collection
+func (s *Server) Publish(msgType, collection string, id interface{}, fields map[string]interface{}) {
+	frame := map[string]interface{}{
+		"msg":        msgType,
+		"collection": collection,
+		"id":         id,
+	}
+	if fields != nil {
+		frame["fields"] = fields
+	}
+
+	s.mu.Lock()
+	targets := []*serverConn{}
+	for conn := range s.conns {
+		if conn.subs[collection] {
+			targets = append(targets, conn)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, conn := range targets {
+		conn.write(frame)
+	}
+}
+
+// serveWebSocket runs the protocol for one client connection
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn := &serverConn{ws: ws, subs: make(map[string]bool)}
+
+	s.mu.Lock()
+	s.conns[conn] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	authenticated := false
+	for {
+		var frame struct {
+			ID     interface{}     `json:"id"`
+			Msg    string          `json:"msg"`
+			Method string          `json:"method"`
+			Name   string          `json:"name"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := ws.ReadJSON(&frame); err != nil {
+			return
+		}
+
+		switch frame.Msg {
+		case "connect":
+			conn.write(map[string]interface{}{"msg": "connected", "session": "truenastest"})
+
+		case "sub":
+			s.mu.Lock()
+			conn.subs[frame.Name] = true
+			s.mu.Unlock()
+			conn.write(map[string]interface{}{"msg": "ready", "subs": []interface{}{frame.ID}})
+
+		case "method":
+			var params []interface{}
+			if len(frame.Params) > 0 {
+				_ = json.Unmarshal(frame.Params, &params)
+			}
+
+			s.mu.Lock()
+			s.calls = append(s.calls, Call{Method: frame.Method, Params: params})
+			s.mu.Unlock()
+
+			switch {
+			case frame.Method == "auth.login_with_api_key":
+				key, _ := firstString(params)
+				authenticated = key == s.APIKey
+				conn.write(responseFrame(frame.ID, authenticated, nil))
+			case frame.Method == "auth.login":
+				authenticated = s.passwordLogin(params)
+				conn.write(responseFrame(frame.ID, authenticated, nil))
+			case frame.Method == "auth.login_with_token":
+				token, _ := firstString(params)
+				authenticated = token == s.Token
+				conn.write(responseFrame(frame.ID, authenticated, nil))
+			case !authenticated:
+				conn.write(responseFrame(frame.ID, nil, &Error{Code: 13, Message: "Not authenticated"}))
+			default:
+				// Like the middleware, answer calls concurrently: a slow call
+				// does not hold up the ones sent after it
+				go func(id interface{}, method string) {
+					result, callErr := s.dispatch(method, params)
+					conn.write(responseFrame(id, result, callErr))
+				}(frame.ID, frame.Method)
+			}
+		}
+	}
+}
+
+// passwordLogin checks auth.login parameters: username, password, and the
+// one-time password when the server requires one
+func (s *Server) passwordLogin(params []interface{}) bool {
+	var given [3]string
+	for i := 0; i < len(params) && i < len(given); i++ {
+		given[i], _ = params[i].(string)
+	}
+	return given[0] == s.Username && given[1] == s.Password && given[2] == s.OTP
+}
+
+// authorized checks the Authorization header of an HTTP file transfer
+// against the server's credentials
+func (s *Server) authorized(r *http.Request) bool {
+	if username, password, ok := r.BasicAuth(); ok {
+		return username == s.Username && password == s.Password
+	}
+	switch r.Header.Get("Authorization") {
+	case "Bearer " + s.APIKey, "Token " + s.Token:
+		return true
+	}
+	return false
+}
+
+// dispatch routes a method call to its registered handler or a built-in default
+func (s *Server) dispatch(method string, params []interface{}) (interface{}, error) {
+	s.mu.Lock()
+	handler, ok := s.handlers[method]
+	s.mu.Unlock()
+
+	if ok {
+		return handler(params)
+	}
+
+	if method == "core.get_jobs" {
+		return s.jobs.query(params), nil
+	}
+	if method == "core.download" {
+		return s.startDownload(params)
+	}
+	if method == "core.job_abort" && len(params) > 0 {
+		if id, ok := toFloat(params[0]); ok {
+			s.AbortJob(int(id))
+			return nil, nil
+		}
+	}
+
+	return nil, &Error{Code: 22, Message: fmt.Sprintf("Method %s not found", method)}
+}
+
+// responseFrame builds a method response with either a result or an error
+func responseFrame(id interface{}, result interface{}, err error) map[string]interface{} {
+	frame := map[string]interface{}{"id": id}
+	if err != nil {
+		apiErr, ok := err.(*Error)
+		if !ok {
+			apiErr = &Error{Code: 1, Message: err.Error()}
+		}
+		frame["msg"] = "failed"
+		errFrame := map[string]interface{}{
+			"code":    apiErr.Code,
+			"message": apiErr.Message,
+		}
+		if apiErr.ErrName != "" {
+			errFrame["errname"] = apiErr.ErrName
+		}
+		frame["error"] = errFrame
+		return frame
+	}
+	frame["msg"] = "result"
+	frame["result"] = result
+	return frame
+}
+
+// write sends a frame to the client, serializing concurrent writers
+func (c *serverConn) write(frame interface{}) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.ws.WriteJSON(frame)
+}
+
+// firstString returns params[0] when it is a string
+func firstString(params []interface{}) (string, bool) {
+	if len(params) == 0 {
+		return "", false
+	}
+	s, ok := params[0].(string)
+	return s, ok
+}
@@ -0,0 +1,413 @@
+package truenastest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/truenas/truenas-mcp/truenas"
+)
+
+func TestServerRejectsWrongAPIKey(t *testing.T) {
+	server := NewServer(t)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas"})
+
+	client, err := truenas.NewClient(server.URL(), "wrong-key", server.TLSConfig())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Call("system.info"); err == nil {
+		t.Fatal("expected authentication failure")
+	}
+}
+
+func TestClientLogsInWithPasswordOrToken(t *testing.T) {
+	server := NewServer(t)
+	server.OTP = "123456"
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas"})
+
+	login := func(credentials truenas.Credentials) error {
+		t.Helper()
+		client, err := truenas.NewClientWithCredentials(server.URL(), credentials, server.TLSConfig())
+		if err != nil {
+			t.Fatalf("NewClientWithCredentials failed: %v", err)
+		}
+		defer client.Close()
+		if _, err := client.Call("system.info"); err != nil {
+			return err
+		}
+		// File transfers authenticate the same way
+		_, err = client.Upload("filesystem.put", []interface{}{"/mnt/tank/a"}, strings.NewReader("data"))
+		return err
+	}
+
+	if err := login(truenas.Credentials{Username: DefaultUsername, Password: DefaultPassword, OTP: "123456"}); err != nil {
+		t.Errorf("password login failed: %v", err)
+	}
+	if err := login(truenas.Credentials{Username: DefaultUsername, Password: DefaultPassword, OTP: "654321"}); err == nil {
+		t.Error("password login with a wrong one-time password succeeded")
+	}
+	if err := login(truenas.Credentials{Token: DefaultToken}); err != nil {
+		t.Errorf("token login failed: %v", err)
+	}
+	if calls := server.Calls("auth.login"); len(calls) != 2 || calls[0].Params[2] != "123456" {
+		t.Errorf("auth.login calls = %v", calls)
+	}
+
+	for _, invalid := range []truenas.Credentials{
+		{},
+		{APIKey: DefaultAPIKey, Token: DefaultToken},
+		{Username: DefaultUsername},
+		{Token: DefaultToken, OTP: "123456"},
+	} {
+		if _, err := truenas.NewClientWithCredentials(server.URL(), invalid, nil); err == nil {
+			t.Errorf("credentials %+v accepted", invalid)
+		}
+	}
+}
+
+func TestServerJobProgression(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+	server.HandleJob("pool.scrub.scrub", JobSpec{Steps: 2, Result: "done"})
+
+	result, err := client.Call("pool.scrub.scrub", "tank")
+	if err != nil {
+		t.Fatalf("job call failed: %v", err)
+	}
+	var jobID int
+	if err := json.Unmarshal(result, &jobID); err != nil {
+		t.Fatalf("job call did not return an ID: %s", result)
+	}
+
+	states := []string{}
+	for i := 0; i < 3; i++ {
+		result, err := client.Call("core.get_jobs", []interface{}{[]interface{}{"id", "=", jobID}})
+		if err != nil {
+			t.Fatalf("core.get_jobs failed: %v", err)
+		}
+		var jobs []map[string]interface{}
+		if err := json.Unmarshal(result, &jobs); err != nil || len(jobs) != 1 {
+			t.Fatalf("unexpected core.get_jobs result: %s", result)
+		}
+		states = append(states, jobs[0]["state"].(string))
+	}
+
+	expected := []string{"RUNNING", "RUNNING", "SUCCESS"}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Fatalf("job states = %v, want %v", states, expected)
+		}
+	}
+}
+
+func TestServerPublishesSubscribedEvents(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+
+	received := make(chan truenas.Event, 1)
+	if err := client.Subscribe("alert.list", func(ev truenas.Event) { received <- ev }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// The subscription is registered asynchronously; publish until it is delivered
+	deadline := time.After(5 * time.Second)
+	for {
+		server.Publish("added", "alert.list", "a1", map[string]interface{}{"level": "CRITICAL"})
+		select {
+		case ev := <-received:
+			if ev.Type != "added" || ev.ID != "a1" || ev.Fields["level"] != "CRITICAL" {
+				t.Errorf("unexpected event: %+v", ev)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("event was not delivered")
+		}
+	}
+}
+
+func TestMatchFilters(t *testing.T) {
+	record := map[string]interface{}{
+		"id":           3,
+		"method":       "pool.scrub.scrub",
+		"state":        "RUNNING",
+		"time_started": map[string]interface{}{"$date": float64(1700000000000)},
+		"progress":     map[string]interface{}{"percent": float64(40)},
+	}
+
+	tests := []struct {
+		name     string
+		filters  []interface{}
+		expected bool
+	}{
+		{name: "no filters", filters: nil, expected: true},
+		{name: "numeric equality", filters: []interface{}{[]interface{}{"id", "=", float64(3)}}, expected: true},
+		{name: "in list", filters: []interface{}{[]interface{}{"state", "in", []interface{}{"RUNNING", "WAITING"}}}, expected: true},
+		{name: "not in list", filters: []interface{}{[]interface{}{"state", "nin", []interface{}{"RUNNING"}}}, expected: false},
+		{name: "prefix", filters: []interface{}{[]interface{}{"method", "^", "pool."}}, expected: true},
+		{name: "mismatch", filters: []interface{}{[]interface{}{"method", "=", "app.upgrade"}}, expected: false},
+		{name: "greater than", filters: []interface{}{[]interface{}{"id", ">", float64(2)}}, expected: true},
+		{name: "less or equal", filters: []interface{}{[]interface{}{"id", "<=", float64(2)}}, expected: false},
+		{name: "date after", filters: []interface{}{[]interface{}{"time_started", ">=", map[string]interface{}{"$date": float64(1600000000000)}}}, expected: true},
+		{name: "date before", filters: []interface{}{[]interface{}{"time_started", "<", map[string]interface{}{"$date": float64(1600000000000)}}}, expected: false},
+		{name: "nested field", filters: []interface{}{[]interface{}{"progress.percent", ">", float64(30)}}, expected: true},
+		{name: "regex", filters: []interface{}{[]interface{}{"method", "~", "(?i).*SCRUB"}}, expected: true},
+		{name: "regex anchored at start", filters: []interface{}{[]interface{}{"method", "~", "scrub"}}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchFilters(record, tt.filters); got != tt.expected {
+				t.Errorf("matchFilters() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetRecordsQueryOptions(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+	server.SetRecords("pool.dataset.query", []map[string]interface{}{
+		{"name": "tank/a", "used": map[string]interface{}{"parsed": float64(10)}},
+		{"name": "tank/b", "used": map[string]interface{}{"parsed": float64(30)}},
+		{"name": "tank/c", "used": map[string]interface{}{"parsed": float64(20)}},
+		{"name": "backup/d", "used": map[string]interface{}{"parsed": float64(40)}},
+	})
+	filters := []interface{}{[]interface{}{"name", "^", "tank/"}}
+
+	result, err := client.Call("pool.dataset.query", filters, map[string]interface{}{
+		"order_by": []string{"-used.parsed"}, "offset": 1, "limit": 1,
+	})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	var page []map[string]interface{}
+	if err := json.Unmarshal(result, &page); err != nil || len(page) != 1 || page[0]["name"] != "tank/c" {
+		t.Errorf("page = %s, want only tank/c", result)
+	}
+
+	result, err = client.Call("pool.dataset.query", filters, map[string]interface{}{"select": []string{"name"}, "limit": 1})
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if string(result) != `[{"name":"tank/a"}]` {
+		t.Errorf("selected = %s, want only the name of tank/a", result)
+	}
+
+	result, err = client.Call("pool.dataset.query", filters, map[string]interface{}{"count": true})
+	if err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if string(result) != "3" {
+		t.Errorf("count = %s, want 3", result)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	live := NewServer(t)
+	live.SetRecords("pool.query", []map[string]interface{}{{"name": "tank"}, {"name": "backup"}})
+	live.SetError("app.query", 13, "Not authorized")
+
+	recorder := truenas.NewRecorder()
+	client := live.Client(t)
+	client.SetRecorder(recorder)
+
+	filter := []interface{}{[]interface{}{"name", "=", "tank"}}
+	if _, err := client.Call("pool.query", filter); err != nil {
+		t.Fatalf("pool.query failed: %v", err)
+	}
+	if _, err := client.Call("app.query"); err == nil {
+		t.Fatal("expected app.query to fail")
+	}
+	if n := recorder.Len(); n != 2 {
+		t.Fatalf("recorded %d interactions, want 2 (credentials must not be recorded)", n)
+	}
+
+	replay := NewServer(t)
+	replay.Replay(recorder.Fixture())
+	replayClient := replay.Client(t)
+
+	result, err := replayClient.Call("pool.query", filter)
+	if err != nil {
+		t.Fatalf("replayed pool.query failed: %v", err)
+	}
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil || len(pools) != 1 || pools[0]["name"] != "tank" {
+		t.Errorf("replayed result = %s, want only tank", result)
+	}
+
+	if _, err := replayClient.Call("app.query"); err == nil {
+		t.Error("replayed app.query should fail like the recording")
+	}
+	if _, err := replayClient.Call("pool.query"); err == nil {
+		t.Error("unrecorded params should not be served")
+	}
+}
+
+// fastReconnect keeps reconnection tests quick
+var fastReconnect = truenas.ReconnectPolicy{
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     50 * time.Millisecond,
+	MaxWait:        5 * time.Second,
+}
+
+func TestClientRetriesIdempotentCallAfterDrop(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+	client.SetReconnectPolicy(fastReconnect)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas"})
+
+	dropped := false
+	server.Handle("pool.query", func(params []interface{}) (interface{}, error) {
+		if !dropped {
+			dropped = true
+			server.DropConnections()
+		}
+		return []interface{}{map[string]interface{}{"name": "tank"}}, nil
+	})
+
+	if _, err := client.Call("system.info"); err != nil {
+		t.Fatalf("system.info failed: %v", err)
+	}
+	result, err := client.Call("pool.query")
+	if err != nil {
+		t.Fatalf("pool.query failed after reconnect: %v", err)
+	}
+	var pools []map[string]interface{}
+	if err := json.Unmarshal(result, &pools); err != nil || len(pools) != 1 {
+		t.Fatalf("unexpected result %s", result)
+	}
+	if calls := len(server.Calls("pool.query")); calls != 2 {
+		t.Errorf("pool.query sent %d times, want 2", calls)
+	}
+}
+
+func TestClientReportsReconnectingForInterruptedWrite(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+	client.SetReconnectPolicy(fastReconnect)
+	server.SetResult("system.info", map[string]interface{}{"hostname": "nas"})
+	server.Handle("pool.snapshot.create", func(params []interface{}) (interface{}, error) {
+		server.DropConnections()
+		return nil, nil
+	})
+
+	if _, err := client.Call("system.info"); err != nil {
+		t.Fatalf("system.info failed: %v", err)
+	}
+	_, err := client.Call("pool.snapshot.create", map[string]interface{}{"dataset": "tank", "name": "s1"})
+	var reconnErr *truenas.ReconnectingError
+	if !errors.As(err, &reconnErr) || reconnErr.Method != "pool.snapshot.create" {
+		t.Fatalf("err = %v, want ReconnectingError for pool.snapshot.create", err)
+	}
+	if !errors.Is(err, truenas.ErrReconnecting) {
+		t.Error("error does not match ErrReconnecting")
+	}
+	if calls := len(server.Calls("pool.snapshot.create")); calls != 1 {
+		t.Errorf("pool.snapshot.create sent %d times, want 1 (writes are not retried)", calls)
+	}
+
+	if _, err := client.Call("system.info"); err != nil {
+		t.Fatalf("system.info failed after reconnect: %v", err)
+	}
+}
+
+func TestClientRestoresSubscriptionsAfterDrop(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+	client.SetReconnectPolicy(fastReconnect)
+
+	received := make(chan truenas.Event, 16)
+	if err := client.Subscribe("alert.list", func(ev truenas.Event) { received <- ev }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	generation := client.ConnectionGeneration()
+	server.DropConnections()
+
+	// The background reconnect resubscribes without any call being made
+	deadline := time.After(5 * time.Second)
+	for {
+		server.Publish("added", "alert.list", "a2", nil)
+		select {
+		case ev := <-received:
+			if ev.ID == "a2" {
+				if client.ConnectionGeneration() == generation {
+					t.Error("connection generation unchanged after reconnect")
+				}
+				return
+			}
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("events were not delivered after reconnect")
+		}
+	}
+}
+
+func TestClientCallsConcurrently(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+
+	// Each call waits until all of them have arrived, so the batch only
+	// completes if the calls are in flight at the same time
+	const n = 4
+	var arrived sync.WaitGroup
+	arrived.Add(n)
+	server.Handle("pool.query", func(params []interface{}) (interface{}, error) {
+		arrived.Done()
+		arrived.Wait()
+		return params[0], nil
+	})
+
+	calls := make([]truenas.BatchCall, n)
+	for i := range calls {
+		calls[i] = truenas.BatchCall{Method: "pool.query", Params: []interface{}{float64(i)}}
+	}
+	done := make(chan []truenas.BatchResult, 1)
+	go func() { done <- client.CallConcurrently(calls...) }()
+
+	select {
+	case results := <-done:
+		for i, result := range results {
+			if result.Err != nil || string(result.Result) != fmt.Sprint(i) {
+				t.Errorf("result %d = %s, %v; want %d", i, result.Result, result.Err, i)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("calls were serialized")
+	}
+}
+
+func TestClientCallHonorsContext(t *testing.T) {
+	server := NewServer(t)
+	client := server.Client(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	server.Handle("pool.query", func(params []interface{}) (interface{}, error) {
+		<-release
+		return []interface{}{}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := client.WithContext(ctx).Call("pool.query"); !errors.Is(err, truenas.ErrCancelled) {
+		t.Errorf("cancelled call err = %v, want ErrCancelled", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.WithContext(ctx).Call("pool.query"); !errors.Is(err, truenas.ErrTimeout) {
+		t.Errorf("expired call err = %v, want ErrTimeout", err)
+	}
+	if _, err := client.WithContext(ctx).Call("system.info"); !errors.Is(err, truenas.ErrTimeout) || len(server.Calls("system.info")) != 0 {
+		t.Errorf("call on an expired context err = %v, want ErrTimeout without sending", err)
+	}
+}
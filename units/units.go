@@ -0,0 +1,151 @@
+// Package units formats and parses storage sizes consistently across tools.
+//
+// Output always uses IEC binary units (KiB, MiB, GiB, ...). Input accepts plain
+// byte counts and both binary and decimal suffixes:
+//
+//	"1TiB", "1T", "1 tib" -> 1099511627776 (binary, ZFS convention for bare letters)
+//	"500GB", "500 gb"     -> 500000000000 (decimal SI)
+//	"1.5G"                -> 1610612736
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	KiB int64 = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+)
+
+// binaryUnits are the IEC unit names used for output
+var binaryUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// int64Limit is 2^63, the first float64 byte count that does not fit an
+// int64. math.MaxInt64 rounds up to it as a float64, so comparing against
+// that with > lets 2^63 through.
+const int64Limit = float64(1 << 63)
+
+// Size is a byte count with its canonical human-readable form. Tools emit sizes
+// as this type so every response carries both representations.
+type Size struct {
+	Bytes int64  `json:"bytes"`
+	Human string `json:"human"`
+}
+
+// NewSize returns a Size for bytes
+func NewSize(bytes int64) Size {
+	return Size{Bytes: bytes, Human: FormatBytes(bytes)}
+}
+
+// FormatBytes formats bytes using IEC binary units with two decimals (e.g., "1.50 GiB")
+func FormatBytes(bytes int64) string {
+	if bytes < 0 {
+		// -math.MinInt64 overflows, so the magnitude is taken as unsigned
+		return "-" + formatMagnitude(uint64(-(bytes+1))+1)
+	}
+	return formatMagnitude(uint64(bytes))
+}
+
+// formatMagnitude formats a non-negative byte count for FormatBytes
+func formatMagnitude(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit && exp < len(binaryUnits)-1; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.2f %s", float64(bytes)/float64(div), binaryUnits[exp])
+}
+
+// suffixMultipliers maps lower-cased suffixes to byte multipliers
+var suffixMultipliers = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   float64(KiB),
+	"kib": float64(KiB),
+	"m":   float64(MiB),
+	"mib": float64(MiB),
+	"g":   float64(GiB),
+	"gib": float64(GiB),
+	"t":   float64(TiB),
+	"tib": float64(TiB),
+	"p":   float64(PiB),
+	"pib": float64(PiB),
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+}
+
+// ParseBytes parses a human-readable size such as "1TiB", "500G", "2.5 GB", or
+// "1048576". Bare letters (K, M, G, T, P) and IEC suffixes are binary; KB, MB,
+// GB, TB, PB are decimal.
+func ParseBytes(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return 0, fmt.Errorf("size is empty")
+	}
+
+	// Split numeric part from suffix
+	i := 0
+	for i < len(trimmed) && (trimmed[i] >= '0' && trimmed[i] <= '9' || trimmed[i] == '.') {
+		i++
+	}
+	number, suffix := trimmed[:i], strings.ToLower(strings.TrimSpace(trimmed[i:]))
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q: must start with a number", s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	multiplier, ok := suffixMultipliers[suffix]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q (use B, KiB, MiB, GiB, TiB, PiB, or KB, MB, GB, TB, PB)", s, strings.TrimSpace(trimmed[i:]))
+	}
+
+	bytes := value * multiplier
+	if math.Round(bytes) >= int64Limit {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(math.Round(bytes)), nil
+}
+
+// ParseSizeValue accepts a size as either a JSON number of bytes or a
+// human-readable string, as tool arguments may carry either
+func ParseSizeValue(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case float64:
+		if val < 0 || val != math.Trunc(val) {
+			return 0, fmt.Errorf("invalid size %v: must be a whole number of bytes", val)
+		}
+		if val >= int64Limit {
+			return 0, fmt.Errorf("invalid size %v: too large", val)
+		}
+		return int64(val), nil
+	case int:
+		return ParseSizeValue(int64(val))
+	case int64:
+		if val < 0 {
+			return 0, fmt.Errorf("invalid size %d: must not be negative", val)
+		}
+		return val, nil
+	case string:
+		return ParseBytes(val)
+	}
+	return 0, fmt.Errorf("invalid size %v: expected a number of bytes or a string like \"500GiB\"", v)
+}
@@ -0,0 +1,171 @@
+package units
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytes    int64
+		expected string
+	}{
+		{
+			name:     "bytes",
+			bytes:    500,
+			expected: "500 B",
+		},
+		{
+			name:     "kilobytes",
+			bytes:    2048,
+			expected: "2.00 KiB",
+		},
+		{
+			name:     "megabytes",
+			bytes:    5242880, // 5 MB
+			expected: "5.00 MiB",
+		},
+		{
+			name:     "gigabytes",
+			bytes:    1234567890,
+			expected: "1.15 GiB",
+		},
+		{
+			name:     "terabytes",
+			bytes:    2199023255552, // 2 TB
+			expected: "2.00 TiB",
+		},
+		{
+			name:     "zero bytes",
+			bytes:    0,
+			expected: "0 B",
+		},
+		{
+			name:     "exactly 1 KiB",
+			bytes:    1024,
+			expected: "1.00 KiB",
+		},
+		{
+			name:     "exactly 1 MiB",
+			bytes:    1048576,
+			expected: "1.00 MiB",
+		},
+		{
+			name:     "exactly 1 GiB",
+			bytes:    1073741824,
+			expected: "1.00 GiB",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatBytes(tt.bytes)
+			if result != tt.expected {
+				t.Errorf("FormatBytes(%d) = %v, want %v", tt.bytes, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatBytesNegative(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{-2048, "-2.00 KiB"},
+		{math.MinInt64, "-8.00 EiB"},
+		{math.MaxInt64, "8.00 EiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes); got != tt.expected {
+			t.Errorf("FormatBytes(%d) = %v, want %v", tt.bytes, got, tt.expected)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1048576", 1048576},
+		{"512B", 512},
+		{"1TiB", 1099511627776},
+		{"1T", 1099511627776},
+		{"1 tib", 1099511627776},
+		{"500G", 500 * GiB},
+		{"500GB", 500000000000},
+		{"1.5G", 1610612736},
+		{"  2 MiB ", 2 * MiB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseBytes(tt.input)
+			if err != nil {
+				t.Fatalf("ParseBytes(%q) error: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseBytes(%q) = %d, want %d", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseBytesInvalid(t *testing.T) {
+	for _, input := range []string{"", "GiB", "10 parsecs", "1.2.3G", "-5G", "9223372036854775808", "8192P"} {
+		if _, err := ParseBytes(input); err == nil {
+			t.Errorf("ParseBytes(%q) expected error", input)
+		}
+	}
+}
+
+func TestParseSizeValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected int64
+		wantErr  bool
+	}{
+		{name: "json number", input: float64(1073741824), expected: GiB},
+		{name: "human string", input: "1TiB", expected: TiB},
+		{name: "fractional bytes", input: float64(1.5), wantErr: true},
+		{name: "negative bytes", input: float64(-1), wantErr: true},
+		{name: "too large", input: float64(1 << 63), wantErr: true},
+		{name: "int", input: 4096, expected: 4096},
+		{name: "negative int", input: -1, wantErr: true},
+		{name: "negative int64", input: int64(-1), wantErr: true},
+		{name: "min int64", input: int64(math.MinInt64), wantErr: true},
+		{name: "wrong type", input: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSizeValue(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSizeValue(%v) expected error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSizeValue(%v) error: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseSizeValue(%v) = %d, want %d", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSizeJSON(t *testing.T) {
+	data, err := json.Marshal(NewSize(3 * GiB))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(data) != `{"bytes":3221225472,"human":"3.00 GiB"}` {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+}